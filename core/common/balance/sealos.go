@@ -196,7 +196,7 @@ func cacheGetUserRealNameLocal(userUID string) (bool, bool) {
 func cacheSetGroupBalance(ctx context.Context, group string, balance int64, userUID string) error {
 	cacheSetGroupBalanceLocal(group, balance, userUID)
 
-	if !common.RedisEnabled || !sealosRedisCacheEnable {
+	if !common.RedisAvailable() || !sealosRedisCacheEnable {
 		return nil
 	}
 
@@ -221,7 +221,7 @@ func cacheGetGroupBalance(ctx context.Context, group string) (*sealosCache, erro
 		return cache, nil
 	}
 
-	if !common.RedisEnabled || !sealosRedisCacheEnable {
+	if !common.RedisAvailable() || !sealosRedisCacheEnable {
 		return nil, redis.Nil
 	}
 
@@ -250,7 +250,7 @@ return redis.status_reply("ok")
 func cacheDecreaseGroupBalance(ctx context.Context, group string, amount int64) error {
 	cacheDecreaseGroupBalanceLocal(group, amount)
 
-	if !common.RedisEnabled || !sealosRedisCacheEnable {
+	if !common.RedisAvailable() || !sealosRedisCacheEnable {
 		return nil
 	}
 
@@ -293,7 +293,7 @@ func cacheGetUserRealName(ctx context.Context, userUID string) (bool, error) {
 		return realName, nil
 	}
 
-	if !common.RedisEnabled || !sealosRedisCacheEnable {
+	if !common.RedisAvailable() || !sealosRedisCacheEnable {
 		return true, redis.Nil
 	}
 
@@ -313,7 +313,7 @@ func cacheGetUserRealName(ctx context.Context, userUID string) (bool, error) {
 func cacheSetUserRealName(ctx context.Context, userUID string, realName bool) error {
 	cacheSetUserRealNameLocal(userUID, realName)
 
-	if !common.RedisEnabled || !sealosRedisCacheEnable {
+	if !common.RedisAvailable() || !sealosRedisCacheEnable {
 		return nil
 	}
 
@@ -0,0 +1,288 @@
+// Package billing generates the monthly invoices of the groups from the group
+// summaries and the version history of the model configs
+package billing
+
+import (
+	"cmp"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/consume"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/shopspring/decimal"
+)
+
+// InvoiceFinalizeDelay is how long after the end of a month its invoices are
+// stored, leaving the time for the late summaries to land
+const InvoiceFinalizeDelay = time.Hour
+
+// MonthStart returns the start of the month of the time in UTC
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// ParseMonth parses a month in the invoice month layout, e.g. 2025-01
+func ParseMonth(month string) (time.Time, error) {
+	return time.ParseInLocation(model.InvoiceMonthLayout, month, time.UTC)
+}
+
+// IsMonthFinalized reports whether the invoices of the month are stored
+func IsMonthFinalized(month, now time.Time) bool {
+	return !MonthStart(month).AddDate(0, 1, 0).Add(InvoiceFinalizeDelay).After(now)
+}
+
+// GetInvoice returns the invoice of the group for the month, the invoices of
+// the finalized months are generated and stored on the first read, the
+// invoices of the other months are generated on every read
+func GetInvoice(group string, month time.Time) (*model.Invoice, error) {
+	if !IsMonthFinalized(month, time.Now()) {
+		return GenerateInvoice(group, month)
+	}
+
+	invoice, err := model.GetInvoice(group, MonthStart(month).Format(model.InvoiceMonthLayout))
+	if err == nil {
+		return invoice, nil
+	}
+
+	if err := model.IgnoreNotFound(err); err != nil {
+		return nil, err
+	}
+
+	return RegenerateInvoice(group, month)
+}
+
+// RegenerateInvoice generates the invoice again from the current summaries,
+// it is stored when the month is finalized
+func RegenerateInvoice(group string, month time.Time) (*model.Invoice, error) {
+	invoice, err := GenerateInvoice(group, month)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsMonthFinalized(month, time.Now()) {
+		return invoice, nil
+	}
+
+	if err := model.SaveInvoice(invoice); err != nil {
+		return nil, err
+	}
+
+	return invoice, nil
+}
+
+// GenerateInvoice builds the invoice of the group for the month, the usage of
+// a model is split by the version of the model config in effect at the hour of
+// its summary, so the line items keep the prices the usage was billed at
+func GenerateInvoice(group string, month time.Time) (*model.Invoice, error) {
+	start := MonthStart(month)
+	end := start.AddDate(0, 1, 0)
+
+	summaries, err := model.GetGroupModelSummaries(group, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsByModel := make(map[string][]model.SummaryDataV2)
+	for _, row := range summaries {
+		rowsByModel[row.Model] = append(rowsByModel[row.Model], row)
+	}
+
+	invoice := &model.Invoice{
+		GroupID:     group,
+		Month:       start.Format(model.InvoiceMonthLayout),
+		PeriodStart: start,
+		PeriodEnd:   end,
+		LineItems:   []model.InvoiceLineItem{},
+		GeneratedAt: time.Now(),
+	}
+
+	for _, modelName := range slices.Sorted(maps.Keys(rowsByModel)) {
+		periods, err := modelPricePeriods(modelName, start, end)
+		if err != nil {
+			return nil, err
+		}
+
+		invoice.LineItems = append(
+			invoice.LineItems,
+			buildLineItems(modelName, rowsByModel[modelName], periods)...,
+		)
+	}
+
+	var listTotal, discountTotal, total decimal.Decimal
+	for _, item := range invoice.LineItems {
+		listTotal = listTotal.Add(decimal.NewFromFloat(item.ListAmount))
+		discountTotal = discountTotal.Add(decimal.NewFromFloat(item.Discount))
+		total = total.Add(decimal.NewFromFloat(item.Amount.UsedAmount))
+	}
+
+	invoice.ListAmount = listTotal.InexactFloat64()
+	invoice.Discount = discountTotal.InexactFloat64()
+	invoice.Total = total.InexactFloat64()
+
+	return invoice, nil
+}
+
+// pricePeriod is the range of the month a version of the model config is in
+// effect
+type pricePeriod struct {
+	version int64
+	start   time.Time
+	end     time.Time
+	price   model.Price
+}
+
+func modelPricePeriods(modelName string, start, end time.Time) ([]pricePeriod, error) {
+	versions, err := model.GetModelConfigVersions(modelName)
+	if err != nil {
+		return nil, err
+	}
+
+	// the price before the versioning of the model config is unknown, the
+	// earliest snapshot or the current config is the closest to it
+	var fallback model.Price
+
+	if len(versions) > 0 {
+		fallback = versions[len(versions)-1].Config.Price
+	} else {
+		config, err := model.GetModelConfig(modelName)
+		if err := model.IgnoreNotFound(err); err != nil {
+			return nil, err
+		}
+
+		fallback = config.Price
+	}
+
+	return pricePeriods(versions, fallback, start, end), nil
+}
+
+// pricePeriods splits [start, end) by the effective times of the versions,
+// the range before the first version is billed at the fallback price
+func pricePeriods(
+	versions []model.ModelConfigVersion,
+	fallback model.Price,
+	start, end time.Time,
+) []pricePeriod {
+	versions = slices.Clone(versions)
+	slices.SortFunc(versions, func(a, b model.ModelConfigVersion) int {
+		return cmp.Or(a.EffectiveAt.Compare(b.EffectiveAt), cmp.Compare(a.Version, b.Version))
+	})
+
+	periods := make([]pricePeriod, 0, len(versions)+1)
+	current := pricePeriod{start: start, price: fallback}
+
+	for _, v := range versions {
+		if v.EffectiveAt.After(current.start) {
+			if !v.EffectiveAt.Before(end) {
+				break
+			}
+
+			current.end = v.EffectiveAt
+			periods = append(periods, current)
+			current = pricePeriod{start: v.EffectiveAt}
+		}
+
+		current.version = v.Version
+		current.price = v.Config.Price
+
+		// no price is in effect after the deletion of the model config
+		if v.Deleted {
+			current.price = model.Price{}
+		}
+	}
+
+	current.end = end
+
+	return append(periods, current)
+}
+
+// buildLineItems sums the summaries of the model by the price periods, the
+// summaries of the rollups are billed at the period of their day or month
+func buildLineItems(
+	modelName string,
+	rows []model.SummaryDataV2,
+	periods []pricePeriod,
+) []model.InvoiceLineItem {
+	sets := make([]*model.SummaryDataSet, len(periods))
+
+	for _, row := range rows {
+		ts := time.Unix(row.Timestamp, 0)
+
+		i, _ := slices.BinarySearchFunc(periods, ts, func(p pricePeriod, t time.Time) int {
+			if !t.Before(p.end) {
+				return -1
+			}
+
+			if t.Before(p.start) {
+				return 1
+			}
+
+			return 0
+		})
+		i = min(i, len(periods)-1)
+
+		if sets[i] == nil {
+			sets[i] = &model.SummaryDataSet{}
+		}
+
+		sets[i].Add(row.SummaryDataSet)
+	}
+
+	items := make([]model.InvoiceLineItem, 0, len(sets))
+
+	for i, set := range sets {
+		if set == nil {
+			continue
+		}
+
+		item := model.InvoiceLineItem{
+			Model:        modelName,
+			PriceVersion: periods[i].version,
+			PeriodStart:  periods[i].start,
+			PeriodEnd:    periods[i].end,
+			Price:        periods[i].price,
+			RequestCount: set.RequestCount,
+			Usage:        set.Usage,
+			Amount:       set.Amount,
+		}
+
+		item.ListAmount, item.Discount = listAmount(
+			periods[i].price,
+			set.Count,
+			set.Usage,
+			set.Amount.UsedAmount,
+		)
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// listAmount prices the usage at the price snapshot, the conditional prices
+// depend on the single requests and are not priced again, and the billed
+// amount above the list amount, e.g. of the service tiers, is no discount
+func listAmount(
+	price model.Price,
+	count model.Count,
+	usage model.Usage,
+	billed float64,
+) (list, discount float64) {
+	switch {
+	case len(price.ConditionalPrices) > 0:
+		return billed, 0
+	case price.PerRequestPrice != 0:
+		list = decimal.NewFromFloat(float64(price.PerRequestPrice)).
+			Mul(decimal.NewFromInt(count.RequestCount - int64(count.ExceptionCount))).
+			InexactFloat64()
+	default:
+		list = consume.CalculateAmount(http.StatusOK, usage, model.UsageContext{}, price)
+	}
+
+	if list <= billed {
+		return billed, 0
+	}
+
+	return list, decimal.NewFromFloat(list).Sub(decimal.NewFromFloat(billed)).InexactFloat64()
+}
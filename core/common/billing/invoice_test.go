@@ -0,0 +1,210 @@
+//nolint:testpackage
+package billing
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/pdf"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func priceVersion(
+	version int64,
+	effectiveAt time.Time,
+	inputPrice float64,
+) model.ModelConfigVersion {
+	return model.ModelConfigVersion{
+		Version:     version,
+		EffectiveAt: effectiveAt,
+		Config: model.ModelConfig{
+			Price: model.Price{
+				InputPrice:     model.ZeroNullFloat64(inputPrice),
+				InputPriceUnit: 1000,
+			},
+		},
+	}
+}
+
+func TestPricePeriods(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	fallback := model.Price{InputPrice: 1}
+
+	t.Run("no versions", func(t *testing.T) {
+		t.Parallel()
+
+		periods := pricePeriods(nil, fallback, start, end)
+		require.Len(t, periods, 1)
+		assert.Equal(t, int64(0), periods[0].version)
+		assert.Equal(t, start, periods[0].start)
+		assert.Equal(t, end, periods[0].end)
+		assert.Equal(t, model.ZeroNullFloat64(1), periods[0].price.InputPrice)
+	})
+
+	t.Run("changes in the month", func(t *testing.T) {
+		t.Parallel()
+
+		mid := start.Add(10 * 24 * time.Hour)
+		deleted := priceVersion(4, start.Add(20*24*time.Hour), 0)
+		deleted.Deleted = true
+
+		periods := pricePeriods([]model.ModelConfigVersion{
+			deleted,
+			priceVersion(3, mid, 3),
+			priceVersion(2, start.Add(-time.Hour), 2),
+			priceVersion(1, start.Add(-24*time.Hour), 1),
+			priceVersion(5, end, 5),
+		}, fallback, start, end)
+		require.Len(t, periods, 3)
+
+		assert.Equal(t, int64(2), periods[0].version)
+		assert.Equal(t, start, periods[0].start)
+		assert.Equal(t, mid, periods[0].end)
+		assert.Equal(t, model.ZeroNullFloat64(2), periods[0].price.InputPrice)
+
+		assert.Equal(t, int64(3), periods[1].version)
+		assert.Equal(t, mid, periods[1].start)
+		assert.Equal(t, deleted.EffectiveAt, periods[1].end)
+
+		assert.Equal(t, int64(4), periods[2].version)
+		assert.Equal(t, end, periods[2].end)
+		assert.Equal(t, model.ZeroNullFloat64(0), periods[2].price.InputPrice)
+	})
+
+	t.Run("first version in the month", func(t *testing.T) {
+		t.Parallel()
+
+		mid := start.Add(10 * 24 * time.Hour)
+
+		periods := pricePeriods(
+			[]model.ModelConfigVersion{priceVersion(1, mid, 3)},
+			fallback,
+			start,
+			end,
+		)
+		require.Len(t, periods, 2)
+		assert.Equal(t, int64(0), periods[0].version)
+		assert.Equal(t, model.ZeroNullFloat64(1), periods[0].price.InputPrice)
+		assert.Equal(t, int64(1), periods[1].version)
+	})
+}
+
+func TestBuildLineItems(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	mid := start.Add(10 * 24 * time.Hour)
+	end := start.AddDate(0, 1, 0)
+
+	periods := pricePeriods([]model.ModelConfigVersion{
+		priceVersion(1, start.Add(-time.Hour), 2),
+		priceVersion(2, mid, 4),
+	}, model.Price{}, start, end)
+
+	row := func(ts time.Time, inputTokens int64, usedAmount float64) model.SummaryDataV2 {
+		return model.SummaryDataV2{
+			Timestamp: ts.Unix(),
+			Model:     "gpt",
+			SummaryDataSet: model.SummaryDataSet{
+				Count:  model.Count{RequestCount: 1},
+				Usage:  model.Usage{InputTokens: model.ZeroNullInt64(inputTokens)},
+				Amount: model.Amount{UsedAmount: usedAmount},
+			},
+		}
+	}
+
+	items := buildLineItems("gpt", []model.SummaryDataV2{
+		row(start, 1000, 2),
+		row(mid.Add(-time.Hour), 1000, 1),
+		row(mid, 1000, 4),
+	}, periods)
+	require.Len(t, items, 2)
+
+	assert.Equal(t, int64(1), items[0].PriceVersion)
+	assert.Equal(t, int64(2), items[0].RequestCount)
+	assert.Equal(t, model.ZeroNullInt64(2000), items[0].Usage.InputTokens)
+	assert.InDelta(t, 3, items[0].Amount.UsedAmount, 1e-9)
+	assert.InDelta(t, 4, items[0].ListAmount, 1e-9)
+	assert.InDelta(t, 1, items[0].Discount, 1e-9)
+
+	assert.Equal(t, int64(2), items[1].PriceVersion)
+	assert.Equal(t, mid, items[1].PeriodStart)
+	assert.InDelta(t, 4, items[1].ListAmount, 1e-9)
+	assert.Zero(t, items[1].Discount)
+}
+
+func TestListAmount(t *testing.T) {
+	t.Parallel()
+
+	count := model.Count{RequestCount: 5, ExceptionCount: 1}
+
+	list, discount := listAmount(model.Price{PerRequestPrice: 0.5}, count, model.Usage{}, 1.5)
+	assert.InDelta(t, 2, list, 1e-9)
+	assert.InDelta(t, 0.5, discount, 1e-9)
+
+	// a surcharge above the list price is no discount
+	list, discount = listAmount(model.Price{PerRequestPrice: 0.5}, count, model.Usage{}, 3)
+	assert.InDelta(t, 3, list, 1e-9)
+	assert.Zero(t, discount)
+
+	list, discount = listAmount(model.Price{
+		InputPrice:        1,
+		ConditionalPrices: []model.ConditionalPrice{{}},
+	}, count, model.Usage{InputTokens: 100000}, 1)
+	assert.InDelta(t, 1, list, 1e-9)
+	assert.Zero(t, discount)
+}
+
+func TestInvoiceLines(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	invoice := &model.Invoice{
+		GroupID:     "group",
+		Month:       "2025-01",
+		PeriodStart: start,
+		PeriodEnd:   start.AddDate(0, 1, 0),
+		GeneratedAt: start.AddDate(0, 1, 0),
+		LineItems: []model.InvoiceLineItem{
+			{
+				Model:        "gpt",
+				PriceVersion: 2,
+				PeriodStart:  start,
+				PeriodEnd:    start.AddDate(0, 1, 0),
+				Price:        model.Price{InputPrice: 2, InputPriceUnit: 1000, OutputPrice: 8},
+				RequestCount: 3,
+				Usage:        model.Usage{InputTokens: 1000, OutputTokens: 10},
+				Amount:       model.Amount{UsedAmount: 1.5},
+				ListAmount:   2,
+				Discount:     0.5,
+			},
+			{Model: strings.Repeat("m", 40), PeriodStart: start, PeriodEnd: start.AddDate(0, 1, 0)},
+		},
+		ListAmount: 2,
+		Discount:   0.5,
+		Total:      1.5,
+	}
+
+	lines := InvoiceLines(invoice)
+	for _, line := range lines {
+		assert.LessOrEqual(t, len(line), pdf.TextLineWidth, line)
+	}
+
+	text := strings.Join(lines, "\n")
+	assert.Contains(t, text, "Month:      2025-01")
+	assert.Contains(t, text, "01-01..01-31")
+	assert.Contains(t, text, "unit price: input 2/1000, output 8/1000")
+	assert.Contains(t, text, "unit price: no list price")
+	assert.Contains(t, text, strings.Repeat("m", 40)+"\n")
+	assert.Contains(t, text, "Total:")
+
+	doc, err := pdf.Parse(InvoicePDF(invoice))
+	require.NoError(t, err)
+	assert.Contains(t, doc.PageTexts()[0], "INVOICE")
+}
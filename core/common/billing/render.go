@@ -0,0 +1,158 @@
+package billing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/pdf"
+	"github.com/labring/aiproxy/core/model"
+)
+
+const (
+	invoiceTimeLayout  = "2006-01-02 15:04 UTC"
+	invoiceModelWidth  = 30
+	invoiceAmountWidth = 12
+)
+
+// InvoicePDF renders the invoice as a pdf document
+func InvoicePDF(invoice *model.Invoice) []byte {
+	return pdf.WriteText(InvoiceLines(invoice))
+}
+
+// InvoiceLines renders the invoice as the lines of a plain text document
+func InvoiceLines(invoice *model.Invoice) []string {
+	lines := []string{
+		"INVOICE",
+		"",
+		"Group:      " + invoice.GroupID,
+		"Month:      " + invoice.Month,
+		"Period:     " + invoice.PeriodStart.UTC().Format(invoiceTimeLayout) +
+			" - " + invoice.PeriodEnd.UTC().Format(invoiceTimeLayout),
+		"Generated:  " + invoice.GeneratedAt.UTC().Format(invoiceTimeLayout),
+		"",
+		fmt.Sprintf("%-*s %4s %-12s %9s %13s %13s %*s %*s %*s",
+			invoiceModelWidth, "MODEL", "VER", "PERIOD", "REQUESTS",
+			"INPUT TOKENS", "OUTPUT TOKENS",
+			invoiceAmountWidth, "LIST AMOUNT",
+			invoiceAmountWidth, "DISCOUNT",
+			invoiceAmountWidth, "AMOUNT"),
+	}
+
+	for _, item := range invoice.LineItems {
+		name := item.Model
+		if len(name) > invoiceModelWidth {
+			lines = append(lines, name)
+			name = ""
+		}
+
+		lines = append(lines, fmt.Sprintf("%-*s %4d %-12s %9d %13d %13d %*s %*s %*s",
+			invoiceModelWidth, name,
+			item.PriceVersion,
+			item.PeriodStart.UTC().Format("01-02")+".."+
+				item.PeriodEnd.Add(-time.Second).UTC().Format("01-02"),
+			item.RequestCount,
+			item.Usage.InputTokens,
+			item.Usage.OutputTokens,
+			invoiceAmountWidth, formatInvoiceAmount(item.ListAmount),
+			invoiceAmountWidth, formatInvoiceAmount(item.Discount),
+			invoiceAmountWidth, formatInvoiceAmount(item.Amount.UsedAmount),
+		))
+
+		lines = append(lines, wrapInvoiceLine("  unit price: ", describePrice(item.Price))...)
+	}
+
+	if len(invoice.LineItems) == 0 {
+		lines = append(lines, "no usage")
+	}
+
+	summaryIndent := pdf.TextLineWidth - invoiceAmountWidth - 16
+
+	lines = append(lines,
+		"",
+		fmt.Sprintf("%*s%-16s%*s", summaryIndent, "", "List amount:",
+			invoiceAmountWidth, formatInvoiceAmount(invoice.ListAmount)),
+		fmt.Sprintf("%*s%-16s%*s", summaryIndent, "", "Discount:",
+			invoiceAmountWidth, formatInvoiceAmount(invoice.Discount)),
+		fmt.Sprintf("%*s%-16s%*s", summaryIndent, "", "Total:",
+			invoiceAmountWidth, formatInvoiceAmount(invoice.Total)),
+	)
+
+	return lines
+}
+
+func formatInvoiceAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 4, 64)
+}
+
+func describePrice(price model.Price) []string {
+	var parts []string
+
+	add := func(name string, value model.ZeroNullFloat64, unit int64) {
+		if value == 0 {
+			return
+		}
+
+		parts = append(parts, fmt.Sprintf("%s %s/%d",
+			name, strconv.FormatFloat(float64(value), 'f', -1, 64), unit))
+	}
+
+	if price.PerRequestPrice != 0 {
+		parts = append(parts, "per request "+
+			strconv.FormatFloat(float64(price.PerRequestPrice), 'f', -1, 64))
+	}
+
+	add("input", price.InputPrice, price.GetInputPriceUnit())
+	add("image input", price.ImageInputPrice, price.GetImageInputPriceUnit())
+	add("audio input", price.AudioInputPrice, price.GetAudioInputPriceUnit())
+	add("video input", price.VideoInputPrice, price.GetVideoInputPriceUnit())
+	add("output", price.OutputPrice, price.GetOutputPriceUnit())
+	add("image output", price.ImageOutputPrice, price.GetImageOutputPriceUnit())
+	add("audio output", price.AudioOutputPrice, price.GetAudioOutputPriceUnit())
+
+	thinkingUnit := int64(price.ThinkingModeOutputPriceUnit)
+	if thinkingUnit <= 0 {
+		thinkingUnit = model.PriceUnit
+	}
+
+	add("thinking output", price.ThinkingModeOutputPrice, thinkingUnit)
+	add("cached", price.CachedPrice, price.GetCachedPriceUnit())
+	add("cache creation", price.CacheCreationPrice, price.GetCacheCreationPriceUnit())
+	add("web search", price.WebSearchPrice, price.GetWebSearchPriceUnit())
+
+	if len(price.ConditionalPrices) > 0 {
+		parts = append(parts, "conditional prices apply")
+	}
+
+	if len(parts) == 0 {
+		parts = append(parts, "no list price")
+	}
+
+	return parts
+}
+
+// wrapInvoiceLine joins the parts onto the lines fitting the pdf page
+func wrapInvoiceLine(prefix string, parts []string) []string {
+	var (
+		lines []string
+		line  = prefix
+	)
+
+	indent := strings.Repeat(" ", len(prefix))
+
+	for i, part := range parts {
+		if i > 0 {
+			if len(line)+2+len(part) > pdf.TextLineWidth {
+				lines = append(lines, line+",")
+				line = indent
+			} else {
+				line += ", "
+			}
+		}
+
+		line += part
+	}
+
+	return append(lines, line)
+}
@@ -0,0 +1,109 @@
+// Package celexpr evaluates the common expression language expressions
+// (https://github.com/google/cel-spec) with cel-go in a restricted
+// environment: the expressions only see the declared variables and the
+// standard functions, the comprehension macros are disabled, and the size and
+// the evaluation cost of the expressions are bounded
+package celexpr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+)
+
+const (
+	// maxExpressionSize bounds the code points of an expression
+	maxExpressionSize = 4096
+	// maxEvalCost bounds the cost of an evaluation, the cost of the
+	// expressions over the request variables is far below it
+	maxEvalCost = 100000
+)
+
+// Program is a compiled expression
+type Program struct {
+	source  string
+	program cel.Program
+}
+
+// Compile parses and type checks the expression, the identifiers must be one
+// of the declared variables
+func Compile(source string, vars map[string]*cel.Type) (*Program, error) {
+	return compile(source, vars, nil)
+}
+
+// CompileBool compiles the expression, an error is returned unless the
+// expression is a bool
+func CompileBool(source string, vars map[string]*cel.Type) (*Program, error) {
+	return compile(source, vars, cel.BoolType)
+}
+
+func compile(source string, vars map[string]*cel.Type, output *cel.Type) (*Program, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, errors.New("empty expression")
+	}
+
+	options := []cel.EnvOption{
+		cel.ClearMacros(),
+		cel.ParserExpressionSizeLimit(maxExpressionSize),
+	}
+	for name, t := range vars {
+		options = append(options, cel.Variable(name, t))
+	}
+
+	env, err := cel.NewEnv(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	ast, issues := env.Compile(source)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+
+	if output != nil && !ast.OutputType().IsExactType(output) {
+		return nil, fmt.Errorf("expression must be a %s, got %s", output, ast.OutputType())
+	}
+
+	program, err := env.Program(ast, cel.CostLimit(maxEvalCost))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Program{source: source, program: program}, nil
+}
+
+func (p *Program) String() string {
+	return p.source
+}
+
+// Eval evaluates the expression with the variables
+func (p *Program) Eval(vars map[string]any) (any, error) {
+	if vars == nil {
+		vars = map[string]any{}
+	}
+
+	val, _, err := p.program.Eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	return val.Value(), nil
+}
+
+// EvalBool evaluates the expression, an error is returned when the result is
+// not a bool
+func (p *Program) EvalBool(vars map[string]any) (bool, error) {
+	v, err := p.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression result is %T, not bool", v)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,133 @@
+package celexpr_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/labring/aiproxy/core/common/celexpr"
+	"github.com/stretchr/testify/require"
+)
+
+var testVars = map[string]*cel.Type{
+	"prompt_length": cel.IntType,
+	"has_images":    cel.BoolType,
+	"has_tools":     cel.BoolType,
+	"language":      cel.StringType,
+	"ratio":         cel.DoubleType,
+	"tags":          cel.ListType(cel.StringType),
+}
+
+func TestEval(t *testing.T) {
+	vars := map[string]any{
+		"prompt_length": int64(12000),
+		"has_images":    true,
+		"has_tools":     false,
+		"language":      "zh",
+		"ratio":         0.5,
+		"tags":          []string{"a", "b"},
+	}
+
+	tests := []struct {
+		expr string
+		want any
+	}{
+		{`prompt_length > 8000`, true},
+		{`prompt_length > 8000 && !has_images`, false},
+		{`has_images || has_tools`, true},
+		{`language in ["zh", "ja", "ko"]`, true},
+		{`language == 'en'`, false},
+		{`prompt_length / 1000 + 1`, int64(13)},
+		{`double(prompt_length) * ratio`, float64(6000)},
+		{`-ratio < 0.0`, true},
+		{`prompt_length > 100 ? "long" : "short"`, "long"},
+		{`size(tags) == 2 && tags[1] == "b"`, true},
+		{`"hello world".contains("wor")`, true},
+		{`"hello".startsWith("he") && "hello".endsWith("lo")`, true},
+		{`language.matches("^z[a-z]$")`, true},
+		{`size("你好") == 2`, true},
+		{`string(prompt_length) + "!"`, "12000!"},
+		{`int(ratio * 10.0) == 5 && double(1) == 1.0`, true},
+		{`1e3 == 1000.0`, true},
+		{`(1 + 2) * 3 % 4`, int64(1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			program, err := celexpr.Compile(tt.expr, testVars)
+			require.NoError(t, err)
+
+			got, err := program.Eval(vars)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEvalShortCircuit(t *testing.T) {
+	program, err := celexpr.CompileBool(`false && 1 / 0 == 1`, nil)
+	require.NoError(t, err)
+
+	got, err := program.EvalBool(nil)
+	require.NoError(t, err)
+	require.False(t, got)
+
+	program, err = celexpr.CompileBool(`1 / 0 == 1`, nil)
+	require.NoError(t, err)
+
+	_, err = program.EvalBool(nil)
+	require.ErrorContains(t, err, "division by zero")
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`unknown > 1`,
+		`1 +`,
+		`(1 + 2`,
+		`"unterminated`,
+		`foo(1)`,
+		`"a".bar("b")`,
+		`1 2`,
+		`1 # 2`,
+		`"a" < 1`,
+		`prompt_length * ratio`,
+		// the comprehension macros are disabled
+		`tags.exists(t, t == "a")`,
+		`tags.map(t, t + t).size() > 0`,
+		strings.Repeat("1 + ", 2048) + "1",
+	} {
+		_, err := celexpr.Compile(expr, testVars)
+		require.Error(t, err, expr)
+	}
+}
+
+func TestCompileBoolType(t *testing.T) {
+	_, err := celexpr.CompileBool(`1 + 1`, nil)
+	require.Error(t, err)
+
+	_, err = celexpr.CompileBool(`prompt_length > 1`, testVars)
+	require.NoError(t, err)
+}
+
+func TestEvalCostLimit(t *testing.T) {
+	program, err := celexpr.CompileBool(`"a".matches("^(a|b)+$")`, nil)
+	require.NoError(t, err)
+
+	got, err := program.EvalBool(nil)
+	require.NoError(t, err)
+	require.True(t, got)
+
+	program, err = celexpr.Compile(`language`, testVars)
+	require.NoError(t, err)
+
+	got2, err := program.Eval(map[string]any{"language": strings.Repeat("a", 200000)})
+	require.NoError(t, err)
+	require.Len(t, got2, 200000)
+
+	program, err = celexpr.CompileBool(`language.contains("b")`, testVars)
+	require.NoError(t, err)
+
+	_, err = program.EvalBool(map[string]any{"language": strings.Repeat("a", 10000000)})
+	require.ErrorContains(t, err, "cost limit")
+}
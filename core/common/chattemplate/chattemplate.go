@@ -0,0 +1,735 @@
+// Package chattemplate renders the jinja chat templates of the models into
+// prompts, only the subset of jinja used by the chat templates is supported:
+// the if, for, set and macro statements, the whitespace control and the common
+// filters, tests and methods
+package chattemplate
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Template is a parsed chat template
+type Template struct {
+	body []node
+}
+
+// Parse parses the template with the trim_blocks and the lstrip_blocks
+// behavior of the chat templates
+func Parse(src string) (*Template, error) {
+	segments, err := splitSegments(src)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := &templateParser{segments: segments}
+
+	body, _, _, err := tp.parseBody()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Template{body: body}, nil
+}
+
+type cachedTemplate struct {
+	hash     [sha256.Size]byte
+	template *Template
+}
+
+// templateCache holds the parsed template of each model, an edited template
+// replaces the entry of its model so the old parse trees are not kept
+var templateCache sync.Map
+
+// Load returns the parsed template of the model, the parsed template is cached
+// by the model and reused while the hash of its source does not change
+func Load(model, src string) (*Template, error) {
+	hash := sha256.Sum256([]byte(src))
+
+	if v, ok := templateCache.Load(model); ok {
+		if cached, _ := v.(*cachedTemplate); cached != nil && cached.hash == hash {
+			return cached.template, nil
+		}
+	}
+
+	t, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	templateCache.Store(model, &cachedTemplate{hash: hash, template: t})
+
+	return t, nil
+}
+
+// ExceptionError is raised by the raise_exception of the template
+type ExceptionError struct {
+	Message string
+}
+
+func (e *ExceptionError) Error() string {
+	return e.Message
+}
+
+// LimitError is returned when the render exceeds the call depth, the loop
+// iterations or the output size a template is allowed
+type LimitError struct {
+	Message string
+}
+
+func (e *LimitError) Error() string {
+	return e.Message
+}
+
+// Render renders the template with the variables, the values are the json
+// values such as the ones decoded by DecodeJSON or encoding/json, the keys of
+// the go maps are sorted since the maps have no order
+func (t *Template) Render(vars map[string]any) (string, error) {
+	s := newScope(globals())
+	for k, v := range vars {
+		s.vars[k] = normalize(v)
+	}
+
+	var out strings.Builder
+	if err := renderNodes(t.body, s, &out); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// normalize converts the json numbers to the integers when possible, so the
+// ids and the indexes render like the template expects
+func normalize(v any) any {
+	switch v := v.(type) {
+	case float64:
+		if i, ok := toInt(v); ok {
+			return i
+		}
+
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+
+		return v
+	case map[string]any:
+		return dictFromMap(v)
+	case []map[string]any:
+		list := make([]any, 0, len(v))
+		for _, item := range v {
+			list = append(list, normalize(item))
+		}
+
+		return list
+	default:
+		return v
+	}
+}
+
+func globals() *scope {
+	s := newScope(nil)
+
+	s.vars["raise_exception"] = callable(func(args []any, _ map[string]any) (any, error) {
+		if len(args) == 0 {
+			return nil, &ExceptionError{Message: "template error"}
+		}
+
+		return nil, &ExceptionError{Message: toString(args[0])}
+	})
+	s.vars["namespace"] = callable(func(_ []any, kwargs map[string]any) (any, error) {
+		return dictFromMap(kwargs), nil
+	})
+	s.vars["range"] = callable(rangeFunc)
+	s.vars["strftime_now"] = callable(func(args []any, _ map[string]any) (any, error) {
+		if len(args) == 0 {
+			return nil, errors.New("strftime_now requires a format")
+		}
+
+		return strftime(time.Now(), toString(args[0])), nil
+	})
+
+	return s
+}
+
+func rangeFunc(args []any, _ map[string]any) (any, error) {
+	bounds := make([]int, 0, len(args))
+
+	for _, arg := range args {
+		n, ok := toInt(arg)
+		if !ok {
+			return nil, fmt.Errorf("range requires integers, got %s", typeName(arg))
+		}
+
+		bounds = append(bounds, n)
+	}
+
+	start, stop, step := 0, 0, 1
+
+	switch len(bounds) {
+	case 1:
+		stop = bounds[0]
+	case 2:
+		start, stop = bounds[0], bounds[1]
+	case 3:
+		start, stop, step = bounds[0], bounds[1], bounds[2]
+	default:
+		return nil, errors.New("range requires 1 to 3 arguments")
+	}
+
+	if step == 0 {
+		return nil, errors.New("range step can not be zero")
+	}
+
+	if n := rangeLen(start, stop, step); n > uint64(maxIterations) {
+		return nil, &LimitError{Message: fmt.Sprintf("range of %d items exceeds %d", n, maxIterations)}
+	}
+
+	var items []any
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		items = append(items, i)
+	}
+
+	return items, nil
+}
+
+// rangeLen returns the number of the items of the range, the distance is
+// computed unsigned so the extreme bounds do not overflow
+func rangeLen(start, stop, step int) uint64 {
+	if step > 0 && start < stop {
+		return (uint64(stop)-uint64(start)-1)/uint64(step) + 1
+	}
+
+	if step < 0 && start > stop {
+		return (uint64(start)-uint64(stop)-1)/(-uint64(step)) + 1
+	}
+
+	return 0
+}
+
+var strftimeLayouts = map[byte]string{
+	'd': "02",
+	'm': "01",
+	'y': "06",
+	'Y': "2006",
+	'b': "Jan",
+	'B': "January",
+	'a': "Mon",
+	'A': "Monday",
+	'H': "15",
+	'I': "03",
+	'M': "04",
+	'S': "05",
+	'p': "PM",
+}
+
+func strftime(t time.Time, format string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 >= len(format) {
+			sb.WriteByte(format[i])
+			continue
+		}
+
+		i++
+
+		if layout, ok := strftimeLayouts[format[i]]; ok {
+			sb.WriteString(t.Format(layout))
+			continue
+		}
+
+		if format[i] != '%' {
+			sb.WriteByte('%')
+		}
+
+		sb.WriteByte(format[i])
+	}
+
+	return sb.String()
+}
+
+type filterFunc func(v any, args []any, kwargs map[string]any) (any, error)
+
+var filters map[string]filterFunc
+
+func init() {
+	filters = map[string]filterFunc{
+		"trim":       stringFilter(strings.TrimSpace),
+		"upper":      stringFilter(strings.ToUpper),
+		"lower":      stringFilter(strings.ToLower),
+		"title":      stringFilter(title),
+		"capitalize": stringFilter(capitalize),
+		"string": func(v any, _ []any, _ map[string]any) (any, error) {
+			return toString(v), nil
+		},
+		"safe": func(v any, _ []any, _ map[string]any) (any, error) {
+			return v, nil
+		},
+		"length": lengthFilter,
+		"count":  lengthFilter,
+		"tojson": tojsonFilter,
+		"first": func(v any, _ []any, _ map[string]any) (any, error) {
+			return getItem(v, 0), nil
+		},
+		"last": func(v any, _ []any, _ map[string]any) (any, error) {
+			return getItem(v, -1), nil
+		},
+		"join":       joinFilter,
+		"default":    defaultFilter,
+		"d":          defaultFilter,
+		"replace":    replaceFilter,
+		"int":        intFilter,
+		"list":       listFilter,
+		"items":      itemsFilter,
+		"reverse":    reverseFilter,
+		"selectattr": selectAttrFilter(true),
+		"rejectattr": selectAttrFilter(false),
+		"map":        mapFilter,
+	}
+}
+
+func stringFilter(fn func(string) string) filterFunc {
+	return func(v any, _ []any, _ map[string]any) (any, error) {
+		return fn(toString(v)), nil
+	}
+}
+
+func title(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		words[i] = capitalize(w)
+	}
+
+	return strings.Join(words, " ")
+}
+
+func capitalize(s string) string {
+	runes := []rune(strings.ToLower(s))
+	if len(runes) == 0 {
+		return s
+	}
+
+	return strings.ToUpper(string(runes[0])) + string(runes[1:])
+}
+
+func lengthFilter(v any, _ []any, _ map[string]any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return len([]rune(v)), nil
+	case []any:
+		return len(v), nil
+	case *dict:
+		return v.len(), nil
+	case nil, undefined:
+		return 0, nil
+	default:
+		return nil, fmt.Errorf("%s has no length", typeName(v))
+	}
+}
+
+func tojsonFilter(v any, args []any, kwargs map[string]any) (any, error) {
+	indent, ok := kwargs["indent"]
+	if !ok && len(args) > 0 {
+		indent = args[0]
+	}
+
+	prefix := ""
+	if n, ok := toInt(indent); ok && n > 0 {
+		prefix = strings.Repeat(" ", n)
+	}
+
+	return toJSON(v, prefix)
+}
+
+func joinFilter(v any, args []any, _ map[string]any) (any, error) {
+	items, err := iterate(v)
+	if err != nil {
+		return nil, err
+	}
+
+	sep := ""
+	if len(args) > 0 {
+		sep = toString(args[0])
+	}
+
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		parts = append(parts, toString(item))
+	}
+
+	return strings.Join(parts, sep), nil
+}
+
+func defaultFilter(v any, args []any, kwargs map[string]any) (any, error) {
+	var def any = ""
+	if len(args) > 0 {
+		def = args[0]
+	}
+
+	boolean := len(args) > 1 && truthy(args[1])
+	if b, ok := kwargs["boolean"]; ok {
+		boolean = truthy(b)
+	}
+
+	if _, ok := v.(undefined); ok || (boolean && !truthy(v)) {
+		return def, nil
+	}
+
+	return v, nil
+}
+
+func replaceFilter(v any, args []any, _ map[string]any) (any, error) {
+	if len(args) < 2 {
+		return nil, errors.New("replace requires the old and the new strings")
+	}
+
+	return strings.ReplaceAll(toString(v), toString(args[0]), toString(args[1])), nil
+}
+
+func intFilter(v any, _ []any, _ map[string]any) (any, error) {
+	if n, ok := toNumber(v); ok {
+		return int(n), nil
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(toString(v)))
+	if err != nil {
+		return 0, nil
+	}
+
+	return n, nil
+}
+
+func listFilter(v any, _ []any, _ map[string]any) (any, error) {
+	return iterate(v)
+}
+
+func itemsFilter(v any, _ []any, _ map[string]any) (any, error) {
+	d, ok := v.(*dict)
+	if !ok {
+		return nil, fmt.Errorf("can not get the items of %s", typeName(v))
+	}
+
+	return dictItems(d), nil
+}
+
+func reverseFilter(v any, _ []any, _ map[string]any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		runes := []rune(v)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+
+		return string(runes), nil
+	default:
+		items, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+
+		reversed := make([]any, 0, len(items))
+		for i := len(items) - 1; i >= 0; i-- {
+			reversed = append(reversed, items[i])
+		}
+
+		return reversed, nil
+	}
+}
+
+func selectAttrFilter(keep bool) filterFunc {
+	return func(v any, args []any, _ map[string]any) (any, error) {
+		if len(args) == 0 {
+			return nil, errors.New("selectattr requires an attribute")
+		}
+
+		items, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+
+		attr := toString(args[0])
+		selected := make([]any, 0, len(items))
+
+		for _, item := range items {
+			value := getAttr(item, attr)
+
+			ok := truthy(value)
+			if len(args) > 1 {
+				ok, err = runTest(toString(args[1]), value, args[2:])
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			if ok == keep {
+				selected = append(selected, item)
+			}
+		}
+
+		return selected, nil
+	}
+}
+
+func mapFilter(v any, args []any, kwargs map[string]any) (any, error) {
+	items, err := iterate(v)
+	if err != nil {
+		return nil, err
+	}
+
+	mapped := make([]any, 0, len(items))
+
+	if attr, ok := kwargs["attribute"]; ok {
+		for _, item := range items {
+			mapped = append(mapped, getAttr(item, toString(attr)))
+		}
+
+		return mapped, nil
+	}
+
+	if len(args) == 0 {
+		return nil, errors.New("map requires a filter or an attribute")
+	}
+
+	f, ok := filters[toString(args[0])]
+	if !ok {
+		return nil, fmt.Errorf("unsupported filter %q", toString(args[0]))
+	}
+
+	for _, item := range items {
+		value, err := f(item, args[1:], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		mapped = append(mapped, value)
+	}
+
+	return mapped, nil
+}
+
+func runTest(name string, v any, args []any) (bool, error) {
+	arg := func() (any, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("test %q requires an argument", name)
+		}
+
+		return args[0], nil
+	}
+
+	switch name {
+	case "defined":
+		_, ok := v.(undefined)
+		return !ok, nil
+	case "undefined":
+		_, ok := v.(undefined)
+		return ok, nil
+	case "none":
+		return v == nil, nil
+	case "true":
+		b, ok := v.(bool)
+		return ok && b, nil
+	case "false":
+		b, ok := v.(bool)
+		return ok && !b, nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok, nil
+	case "string":
+		_, ok := v.(string)
+		return ok, nil
+	case "number", "integer", "float":
+		return isNumber(name, v), nil
+	case "mapping":
+		_, ok := v.(*dict)
+		return ok, nil
+	case "sequence", "iterable":
+		switch v.(type) {
+		case string, []any, *dict:
+			return true, nil
+		}
+
+		return false, nil
+	case "callable":
+		_, ok := v.(callable)
+		return ok, nil
+	case "odd", "even":
+		n, ok := toInt(v)
+		return ok && (n%2 == 0) == (name == "even"), nil
+	case "divisibleby":
+		d, err := arg()
+		if err != nil {
+			return false, err
+		}
+
+		n, nok := toInt(v)
+		m, mok := toInt(d)
+
+		return nok && mok && m != 0 && n%m == 0, nil
+	case "equalto", "eq", "==", "sameas":
+		other, err := arg()
+		return err == nil && equal(v, other), err
+	case "ne", "!=":
+		other, err := arg()
+		return err == nil && !equal(v, other), err
+	case "in":
+		container, err := arg()
+		if err != nil {
+			return false, err
+		}
+
+		return contains(container, v)
+	default:
+		return false, fmt.Errorf("unsupported test %q", name)
+	}
+}
+
+func isNumber(name string, v any) bool {
+	switch v := v.(type) {
+	case int:
+		return name != "float"
+	case float64:
+		return name == "number" || name == "float" || (name == "integer" && v == float64(int(v)))
+	default:
+		return false
+	}
+}
+
+func dictItems(d *dict) []any {
+	items := make([]any, 0, d.len())
+	for _, k := range d.keys {
+		items = append(items, []any{k, d.values[k]})
+	}
+
+	return items
+}
+
+func dictMethod(d *dict, name string) any {
+	switch name {
+	case "items":
+		return callable(func([]any, map[string]any) (any, error) {
+			return dictItems(d), nil
+		})
+	case "keys":
+		return callable(func([]any, map[string]any) (any, error) {
+			return iterate(d)
+		})
+	case "values":
+		return callable(func([]any, map[string]any) (any, error) {
+			values := make([]any, 0, d.len())
+			for _, k := range d.keys {
+				values = append(values, d.values[k])
+			}
+
+			return values, nil
+		})
+	case "get":
+		return callable(func(args []any, _ map[string]any) (any, error) {
+			if len(args) == 0 {
+				return nil, errors.New("get requires a key")
+			}
+
+			if v, ok := d.get(toString(args[0])); ok {
+				return v, nil
+			}
+
+			if len(args) > 1 {
+				return args[1], nil
+			}
+
+			return nil, nil
+		})
+	default:
+		return undefined{}
+	}
+}
+
+func stringMethod(s, name string) any {
+	strArg := func(args []any, i int) (string, bool) {
+		if i >= len(args) || args[i] == nil {
+			return "", false
+		}
+
+		return toString(args[i]), true
+	}
+
+	trim := func(fn func(string, string) string, space func(string) string) callable {
+		return func(args []any, _ map[string]any) (any, error) {
+			if chars, ok := strArg(args, 0); ok {
+				return fn(s, chars), nil
+			}
+
+			return space(s), nil
+		}
+	}
+
+	switch name {
+	case "strip":
+		return trim(strings.Trim, strings.TrimSpace)
+	case "lstrip":
+		return trim(strings.TrimLeft, func(s string) string {
+			return strings.TrimLeft(s, " \t\r\n")
+		})
+	case "rstrip":
+		return trim(strings.TrimRight, func(s string) string {
+			return strings.TrimRight(s, " \t\r\n")
+		})
+	case "upper", "lower", "title", "capitalize":
+		f := filters[name]
+		return callable(func([]any, map[string]any) (any, error) {
+			return f(s, nil, nil)
+		})
+	case "startswith", "endswith":
+		return callable(func(args []any, _ map[string]any) (any, error) {
+			affix, _ := strArg(args, 0)
+			if name == "startswith" {
+				return strings.HasPrefix(s, affix), nil
+			}
+
+			return strings.HasSuffix(s, affix), nil
+		})
+	case "split":
+		return callable(func(args []any, _ map[string]any) (any, error) {
+			var parts []string
+			if sep, ok := strArg(args, 0); ok {
+				n := -1
+				if len(args) > 1 {
+					if maxSplit, ok := toInt(args[1]); ok && maxSplit >= 0 {
+						n = maxSplit + 1
+					}
+				}
+
+				parts = strings.SplitN(s, sep, n)
+			} else {
+				parts = strings.Fields(s)
+			}
+
+			items := make([]any, 0, len(parts))
+			for _, p := range parts {
+				items = append(items, p)
+			}
+
+			return items, nil
+		})
+	case "replace":
+		return callable(func(args []any, _ map[string]any) (any, error) {
+			return replaceFilter(s, args, nil)
+		})
+	case "join":
+		return callable(func(args []any, _ map[string]any) (any, error) {
+			if len(args) == 0 {
+				return nil, errors.New("join requires the items")
+			}
+
+			return joinFilter(args[0], []any{s}, nil)
+		})
+	default:
+		return undefined{}
+	}
+}
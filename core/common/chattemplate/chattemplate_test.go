@@ -0,0 +1,498 @@
+package chattemplate_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labring/aiproxy/core/common/chattemplate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const chatMLTemplate = `{% for message in messages %}
+{{- '<|im_start|>' + message['role'] + '\n' + message['content'] + '<|im_end|>' + '\n' }}
+{%- endfor %}
+{%- if add_generation_prompt %}
+{{- '<|im_start|>assistant\n' }}
+{%- endif %}`
+
+const llama2Template = "{% if messages[0]['role'] == 'system' %}{% set loop_messages = messages[1:] %}" +
+	"{% set system_message = messages[0]['content'] %}{% else %}{% set loop_messages = messages %}" +
+	"{% set system_message = false %}{% endif %}{% for message in loop_messages %}" +
+	"{% if (message['role'] == 'user') != (loop.index0 % 2 == 0) %}" +
+	"{{ raise_exception('Conversation roles must alternate user/assistant/user/assistant/...') }}{% endif %}" +
+	"{% if loop.index0 == 0 and system_message != false %}" +
+	"{% set content = '<<SYS>>\\n' + system_message + '\\n<</SYS>>\\n\\n' + message['content'] %}" +
+	"{% else %}{% set content = message['content'] %}{% endif %}" +
+	"{% if message['role'] == 'user' %}{{ bos_token + '[INST] ' + content.strip() + ' [/INST]' }}" +
+	"{% elif message['role'] == 'assistant' %}{{ ' ' + content.strip() + ' ' + eos_token }}{% endif %}" +
+	"{% endfor %}"
+
+func render(t *testing.T, src string, vars map[string]any) string {
+	t.Helper()
+
+	tmpl, err := chattemplate.Parse(src)
+	require.NoError(t, err)
+
+	out, err := tmpl.Render(vars)
+	require.NoError(t, err)
+
+	return out
+}
+
+func TestRenderChatML(t *testing.T) {
+	out := render(t, chatMLTemplate, map[string]any{
+		"messages": []any{
+			map[string]any{"role": "system", "content": "Be brief."},
+			map[string]any{"role": "user", "content": "Hi"},
+		},
+		"add_generation_prompt": true,
+	})
+
+	assert.Equal(
+		t,
+		"<|im_start|>system\nBe brief.<|im_end|>\n<|im_start|>user\nHi<|im_end|>\n<|im_start|>assistant\n",
+		out,
+	)
+}
+
+func TestRenderLlama2(t *testing.T) {
+	vars := map[string]any{
+		"messages": []any{
+			map[string]any{"role": "system", "content": "Be brief."},
+			map[string]any{"role": "user", "content": "Hi"},
+			map[string]any{"role": "assistant", "content": "Hello"},
+			map[string]any{"role": "user", "content": "Bye"},
+		},
+		"bos_token": "<s>",
+		"eos_token": "</s>",
+	}
+
+	out := render(t, llama2Template, vars)
+	assert.Equal(
+		t,
+		"<s>[INST] <<SYS>>\nBe brief.\n<</SYS>>\n\nHi [/INST] Hello </s><s>[INST] Bye [/INST]",
+		out,
+	)
+
+	tmpl, err := chattemplate.Parse(llama2Template)
+	require.NoError(t, err)
+
+	_, err = tmpl.Render(map[string]any{
+		"messages": []any{
+			map[string]any{"role": "assistant", "content": "Hello"},
+		},
+	})
+
+	var exception *chattemplate.ExceptionError
+	require.True(t, errors.As(err, &exception))
+	assert.Contains(t, exception.Message, "must alternate")
+}
+
+func TestRenderNamespaceAndFilters(t *testing.T) {
+	src := `{%- set ns = namespace(system=none) -%}
+{%- for m in messages if m.role == 'system' -%}
+{%- set ns.system = m.content | trim -%}
+{%- endfor -%}
+{{ ns.system | default('none', true) }}|
+{{- messages | selectattr('role', 'equalto', 'user') | map(attribute='content') | join(',') }}|
+{{- tools | tojson }}|
+{{- messages | length }}|{{ 'yes' if tools is defined else 'no' }}|{{ missing is defined }}`
+
+	out := render(t, src, map[string]any{
+		"messages": []any{
+			map[string]any{"role": "system", "content": "  sys  "},
+			map[string]any{"role": "user", "content": "a"},
+			map[string]any{"role": "user", "content": "b"},
+		},
+		"tools": []any{map[string]any{"name": "f", "id": float64(1)}},
+	})
+
+	assert.Equal(t, `sys|a,b|[{"id": 1, "name": "f"}]|3|yes|False`, out)
+}
+
+func TestRenderLoopAndMacro(t *testing.T) {
+	src := `{% macro tag(name, value='x') %}<{{ name }}={{ value }}>{% endmacro %}
+{%- for k, v in data.items() -%}
+{%- if loop.first %}[{% endif %}{{ tag(k, v) }}{% if not loop.last %},{% else %}]{% endif -%}
+{%- endfor %}{{ tag('d') }}{% for i in range(5) %}{% if i == 3 %}{% break %}{% endif %}{{ i }}{% endfor %}`
+
+	out := render(t, src, map[string]any{
+		"data": map[string]any{"b": "2", "a": "1"},
+	})
+
+	assert.Equal(t, "[<a=1>,<b=2>]<d=x>012", out)
+}
+
+func TestParseError(t *testing.T) {
+	_, err := chattemplate.Parse(`{% for m in messages %}{{ m }}`)
+	require.Error(t, err)
+
+	_, err = chattemplate.Parse(`{{ messages[0] `)
+	require.Error(t, err)
+}
+
+func TestRenderFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"trim", `{{ '  hi  ' | trim }}`, "hi"},
+		{"upper", `{{ 'hi' | upper }}`, "HI"},
+		{"lower", `{{ 'HI' | lower }}`, "hi"},
+		{"title", `{{ 'hello world' | title }}`, "Hello World"},
+		{"capitalize", `{{ 'hELLO' | capitalize }}`, "Hello"},
+		{"length of list", `{{ [1, 2, 3] | length }}`, "3"},
+		{"length of string", `{{ 'héllo' | length }}`, "5"},
+		{"count", `{{ {'a': 1} | count }}`, "1"},
+		{"first and last", `{{ [1, 2, 3] | first }}{{ [1, 2, 3] | last }}`, "13"},
+		{"join", `{{ ['a', 'b', 'c'] | join('-') }}`, "a-b-c"},
+		{"join without separator", `{{ ['a', 'b'] | join }}`, "ab"},
+		{"default of undefined", `{{ missing | default('x') }}`, "x"},
+		{"default keeps empty", `{{ '' | default('x') }}`, ""},
+		{"default boolean", `{{ '' | default('x', true) }}`, "x"},
+		{"d alias", `{{ missing | d('y') }}`, "y"},
+		{"replace", `{{ 'a.b.c' | replace('.', '/') }}`, "a/b/c"},
+		{"int", `{{ '42' | int + 1 }}`, "43"},
+		{"int of invalid", `{{ 'x' | int }}`, "0"},
+		{"reverse list", `{{ [1, 2, 3] | reverse | join(',') }}`, "3,2,1"},
+		{"reverse string", `{{ 'abc' | reverse }}`, "cba"},
+		{"tojson", `{{ {'a': [1, 'x'], 'b': none} | tojson }}`, `{"a": [1, "x"], "b": null}`},
+		{"tojson indent", `{{ {'a': 1} | tojson(indent=2) }}`, "{\n  \"a\": 1\n}"},
+		{"tojson keeps non ascii", `{{ '<é>' | tojson }}`, `"<é>"`},
+		{"tojson keeps the key order", `{{ {'b': 1, 'a': {'d': 2, 'c': 3}} | tojson }}`, `{"b": 1, "a": {"d": 2, "c": 3}}`},
+		{
+			"tojson indent nested",
+			`{{ {'a': [1, {}], 'b': []} | tojson(indent=2) }}`,
+			"{\n  \"a\": [\n    1,\n    {}\n  ],\n  \"b\": []\n}",
+		},
+		{"tojson floats", `{{ [1.0, 2.5, 0.00001, 1e16] | tojson }}`, `[1.0, 2.5, 1e-05, 1e+16]`},
+		{"tojson escapes", `{{ 'a"\\\n\t\x01' | tojson }}`, `"a\"\\\n\t\u0001"`},
+		{"items", `{% for k, v in {'b': 2, 'a': 1} | items %}{{ k }}{{ v }}{% endfor %}`, "b2a1"},
+		{
+			"selectattr",
+			`{{ users | selectattr('admin') | map(attribute='name') | join(',') }}`,
+			"a,c",
+		},
+		{
+			"rejectattr",
+			`{{ users | rejectattr('admin') | map(attribute='name') | join(',') }}`,
+			"b",
+		},
+		{
+			"selectattr test",
+			`{{ users | selectattr('name', 'equalto', 'b') | map(attribute='name') | first }}`,
+			"b",
+		},
+		{"map filter", `{{ ['a', 'b'] | map('upper') | join }}`, "AB"},
+		{"chained", `{{ ' A ' | trim | lower | replace('a', 'b') }}`, "b"},
+	}
+
+	vars := map[string]any{
+		"users": []any{
+			map[string]any{"name": "a", "admin": true},
+			map[string]any{"name": "b", "admin": false},
+			map[string]any{"name": "c", "admin": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, render(t, tt.src, vars))
+		})
+	}
+}
+
+func TestRenderLoops(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			"loop indexes",
+			`{% for x in ['a', 'b', 'c'] %}{{ loop.index }}{{ loop.index0 }}{{ loop.revindex }}` +
+				`{{ loop.revindex0 }}{{ loop.length }}|{% endfor %}`,
+			"10323|21213|32103|",
+		},
+		{
+			"loop first and last",
+			`{% for x in [1, 2, 3] %}{% if loop.first %}[{% endif %}{{ x }}` +
+				`{% if not loop.last %},{% else %}]{% endif %}{% endfor %}`,
+			"[1,2,3]",
+		},
+		{
+			"loop previtem and nextitem",
+			`{% for x in [1, 2, 3] %}{{ loop.previtem | default('-') }}{{ x }}` +
+				`{{ loop.nextitem | default('-') }} {% endfor %}`,
+			"-12 123 23- ",
+		},
+		{"else of empty loop", `{% for x in [] %}x{% else %}empty{% endfor %}`, "empty"},
+		{
+			"loop filter counts the kept items",
+			`{% for x in range(6) if x is even %}{{ loop.index }}:{{ x }} {% endfor %}`,
+			"1:0 2:2 3:4 ",
+		},
+		{
+			"continue",
+			`{% for x in range(5) %}{% if x is odd %}{% continue %}{% endif %}{{ x }}{% endfor %}`,
+			"024",
+		},
+		{
+			"break",
+			`{% for x in range(5) %}{% if x == 2 %}{% break %}{% endif %}{{ x }}{% endfor %}`,
+			"01",
+		},
+		{"range with step", `{% for x in range(10, 0, -3) %}{{ x }} {% endfor %}`, "10 7 4 1 "},
+		{"unpacking", `{% for a, b in [[1, 2], [3, 4]] %}{{ a + b }} {% endfor %}`, "3 7 "},
+		{
+			"set in a loop is local",
+			`{% set x = 1 %}{% for i in range(2) %}{% set x = 5 %}{% endfor %}{{ x }}`,
+			"1",
+		},
+		{
+			"namespace in nested loops",
+			`{% set ns = namespace(n=0) %}{% for i in range(3) %}{% for j in range(2) %}` +
+				`{% set ns.n = ns.n + 1 %}{% endfor %}{% endfor %}{{ ns.n }}`,
+			"6",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, render(t, tt.src, nil))
+		})
+	}
+}
+
+func TestRenderMacros(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			"defaults and keyword arguments",
+			`{% macro f(a, b='B', c='C') %}{{ a }}{{ b }}{{ c }}{% endmacro %}` +
+				`{{ f(1) }}|{{ f(1, 2) }}|{{ f(1, c=3) }}`,
+			"1BC|12C|1B3",
+		},
+		{
+			"missing argument is undefined",
+			`{% macro f(a, b) %}{{ a }}{{ b is defined }}{% endmacro %}{{ f('x') }}`,
+			"xFalse",
+		},
+		{
+			"macro calling a macro",
+			`{% macro inner(x) %}[{{ x }}]{% endmacro %}` +
+				`{% macro outer(x) %}{{ inner(x) }}{{ inner(x | upper) }}{% endmacro %}{{ outer('a') }}`,
+			"[a][A]",
+		},
+		{
+			"recursion that ends",
+			`{% macro count(n) %}{{ n }}{% if n > 0 %}{{ count(n - 1) }}{% endif %}{% endmacro %}{{ count(3) }}`,
+			"3210",
+		},
+		{
+			"macro output in a set block",
+			`{% macro f(x) %}<{{ x }}>{% endmacro %}{% set s %}{{ f(1) }}{{ f(2) }}{% endset %}{{ s | length }}`,
+			"6",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, render(t, tt.src, nil))
+		})
+	}
+}
+
+func TestRenderWhitespaceControl(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"trim around blocks", "a  {%- if true -%}  b  {%- endif -%}  c", "abc"},
+		{"trim around output", "a {{- 'b' -}} c", "abc"},
+		{"trim left only", "a  {{- 'b' }}  c", "ab  c"},
+		{"trim blocks", "{% if true %}\nx\n{% endif %}\ny", "x\ny"},
+		{"lstrip blocks", "a\n  {% if true %}x{% endif %}\n", "a\nx"},
+		{"output keeps the newline", "{{ 'x' }}\ny", "x\ny"},
+		{"comment", "a{# comment #}b", "ab"},
+		{"trimmed comment", "a {#- comment -#} b", "ab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, render(t, tt.src, nil))
+		})
+	}
+}
+
+func TestRenderRaiseException(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		vars    map[string]any
+		message string
+	}{
+		{"message", `{{ raise_exception('bad ' ~ 'role') }}`, nil, "bad role"},
+		{"without message", `{{ raise_exception() }}`, nil, "template error"},
+		{
+			"in a loop",
+			`{% for m in messages %}{% if m.role not in ['user', 'assistant'] %}` +
+				`{{ raise_exception('unknown role ' + m.role) }}{% endif %}{% endfor %}`,
+			map[string]any{"messages": []any{
+				map[string]any{"role": "user"},
+				map[string]any{"role": "tool"},
+			}},
+			"unknown role tool",
+		},
+		{
+			"in a macro",
+			`{% macro check(x) %}{{ raise_exception('no ' ~ x) }}{% endmacro %}{{ check(1) }}`,
+			nil,
+			"no 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := chattemplate.Parse(tt.src)
+			require.NoError(t, err)
+
+			_, err = tmpl.Render(tt.vars)
+
+			var exception *chattemplate.ExceptionError
+			require.ErrorAs(t, err, &exception)
+			assert.Equal(t, tt.message, exception.Message)
+		})
+	}
+
+	out := render(t, `{% if false %}{{ raise_exception('no') }}{% endif %}ok`, nil)
+	assert.Equal(t, "ok", out)
+}
+
+func TestRenderLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		vars map[string]any
+	}{
+		{"self calling macro", `{% macro f() %}{{ f() }}{% endmacro %}{{ f() }}`, nil},
+		{
+			"mutually recursive macros",
+			`{% macro a(n) %}{{ b(n + 1) }}{% endmacro %}{% macro b(n) %}{{ a(n + 1) }}{% endmacro %}{{ a(0) }}`,
+			nil,
+		},
+		{"huge range", `{% for i in range(1000000000000) %}{% endfor %}`, nil},
+		{"extreme range bounds", `{{ range(-9223372036854775807, 9223372036854775807) | length }}`, nil},
+		{
+			"nested loops",
+			`{% for i in range(1000) %}{% for j in range(1000) %}{% endfor %}{% endfor %}`,
+			nil,
+		},
+		{
+			"doubling string",
+			`{% set ns = namespace(s='a') %}{% for i in range(64) %}{% set ns.s = ns.s ~ ns.s %}{% endfor %}`,
+			nil,
+		},
+		{
+			"doubling list",
+			`{% set ns = namespace(l=[1]) %}{% for i in range(64) %}{% set ns.l = ns.l + ns.l %}{% endfor %}`,
+			nil,
+		},
+		{
+			"output size",
+			`{% for i in range(100000) %}{{ big }}{% endfor %}`,
+			map[string]any{"big": strings.Repeat("x", 1024)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := chattemplate.Parse(tt.src)
+			require.NoError(t, err)
+
+			_, err = tmpl.Render(tt.vars)
+
+			var limit *chattemplate.LimitError
+			require.ErrorAs(t, err, &limit)
+		})
+	}
+}
+
+func TestLoadReplacesEditedTemplate(t *testing.T) {
+	first, err := chattemplate.Load("load-test", `{{ 'a' }}`)
+	require.NoError(t, err)
+
+	cached, err := chattemplate.Load("load-test", `{{ 'a' }}`)
+	require.NoError(t, err)
+	assert.Same(t, first, cached)
+
+	edited, err := chattemplate.Load("load-test", `{{ 'b' }}`)
+	require.NoError(t, err)
+	assert.NotSame(t, first, edited)
+
+	out, err := edited.Render(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "b", out)
+
+	_, err = chattemplate.Load("load-test", `{{ 'b' `)
+	require.Error(t, err)
+}
+
+func TestDecodeJSONKeepsOrder(t *testing.T) {
+	v, err := chattemplate.DecodeJSON([]byte(`{"z": 1, "a": [2.0, 3.5, {"y": null, "b": true}]}`))
+	require.NoError(t, err)
+
+	out := render(t, `{{ v | tojson }}|{{ v.keys() | join(',') }}|{{ v.a[0] }}`, map[string]any{"v": v})
+	assert.Equal(t, `{"z": 1, "a": [2.0, 3.5, {"y": null, "b": true}]}|z,a|2.0`, out)
+
+	_, err = chattemplate.DecodeJSON([]byte(`{"a": 1} {}`))
+	require.Error(t, err)
+}
+
+// TestRenderGolden renders the model templates of testdata with the variables
+// of the json next to them, the expected outputs are the renders of the jinja
+// environment of transformers, whose tojson keeps the key order. They can be
+// regenerated with:
+//
+//	python -c 'import json, sys; from transformers.utils.chat_template_utils import _compile_jinja_template as c; print(c(open(sys.argv[1] + ".jinja").read()).render(**json.load(open(sys.argv[1] + ".json"))), end="")' testdata/<name> > testdata/<name>.txt
+func TestRenderGolden(t *testing.T) {
+	templates, err := filepath.Glob("testdata/*.jinja")
+	require.NoError(t, err)
+	require.NotEmpty(t, templates)
+
+	for _, path := range templates {
+		name := strings.TrimSuffix(path, ".jinja")
+
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			require.NoError(t, err)
+
+			data, err := os.ReadFile(name + ".json")
+			require.NoError(t, err)
+
+			want, err := os.ReadFile(name + ".txt")
+			require.NoError(t, err)
+
+			var raw map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(data, &raw))
+
+			vars := make(map[string]any, len(raw))
+			for k, v := range raw {
+				vars[k], err = chattemplate.DecodeJSON(v)
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, string(want), render(t, string(src), vars))
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package chattemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// dict is the mapping of the templates, it keeps the insertion order of its
+// keys like the python dicts so the items and the tojson of the json objects
+// render in the order of the request
+type dict struct {
+	keys   []string
+	values map[string]any
+}
+
+func newDict(size int) *dict {
+	return &dict{keys: make([]string, 0, size), values: make(map[string]any, size)}
+}
+
+// dictFromMap converts a go map to a dict, the go maps have no order so the
+// keys are sorted to render the same on every call
+func dictFromMap(m map[string]any) *dict {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	d := newDict(len(keys))
+	for _, k := range keys {
+		d.set(k, normalize(m[k]))
+	}
+
+	return d
+}
+
+func (d *dict) get(key string) (any, bool) {
+	v, ok := d.values[key]
+	return v, ok
+}
+
+func (d *dict) set(key string, v any) {
+	if _, ok := d.values[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+
+	d.values[key] = v
+}
+
+func (d *dict) len() int {
+	return len(d.keys)
+}
+
+// DecodeJSON decodes the json into the values of the templates like the python
+// json.loads: the keys of the objects keep their order and the numbers without
+// a fraction or an exponent are the integers. The values can be passed to
+// Render as they are
+func DecodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	v, err := decodeValue(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err == nil {
+		return nil, errors.New("invalid json: trailing data")
+	}
+
+	return v, nil
+}
+
+func decodeValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			list := []any{}
+
+			for dec.More() {
+				v, err := decodeValue(dec)
+				if err != nil {
+					return nil, err
+				}
+
+				list = append(list, v)
+			}
+
+			_, err := dec.Token()
+
+			return list, err
+		}
+
+		d := newDict(0)
+
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			v, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			k, _ := key.(string)
+			d.set(k, v)
+		}
+
+		_, err := dec.Token()
+
+		return d, err
+	case json.Number:
+		if i, err := tok.Int64(); err == nil {
+			return int(i), nil
+		}
+
+		return tok.Float64()
+	default:
+		return tok, nil
+	}
+}
+
+// toJSON marshals the value like the python json.dumps with ensure_ascii off,
+// which the tojson of the chat templates is: the keys are not sorted, the non
+// ascii and the html characters are not escaped and the separators are spaced
+func toJSON(v any, indent string) (string, error) {
+	var sb strings.Builder
+	if err := writeJSON(&sb, v, indent, 0); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+func writeJSON(sb *strings.Builder, v any, indent string, depth int) error {
+	if depth > maxCallDepth {
+		return &LimitError{Message: fmt.Sprintf("tojson exceeds the depth of %d", maxCallDepth)}
+	}
+
+	switch v := v.(type) {
+	case nil, undefined:
+		sb.WriteString("null")
+	case bool:
+		sb.WriteString(strconv.FormatBool(v))
+	case int:
+		sb.WriteString(strconv.Itoa(v))
+	case float64:
+		sb.WriteString(pyFloat(v))
+	case string:
+		writeJSONString(sb, v)
+	case []any:
+		if len(v) == 0 {
+			sb.WriteString("[]")
+			return nil
+		}
+
+		sb.WriteByte('[')
+
+		for i, item := range v {
+			if i > 0 {
+				writeJSONSeparator(sb, indent)
+			}
+
+			writeJSONIndent(sb, indent, depth+1)
+
+			if err := writeJSON(sb, item, indent, depth+1); err != nil {
+				return err
+			}
+		}
+
+		writeJSONIndent(sb, indent, depth)
+		sb.WriteByte(']')
+	case *dict:
+		if v.len() == 0 {
+			sb.WriteString("{}")
+			return nil
+		}
+
+		sb.WriteByte('{')
+
+		for i, k := range v.keys {
+			if i > 0 {
+				writeJSONSeparator(sb, indent)
+			}
+
+			writeJSONIndent(sb, indent, depth+1)
+			writeJSONString(sb, k)
+			sb.WriteString(": ")
+
+			if err := writeJSON(sb, v.values[k], indent, depth+1); err != nil {
+				return err
+			}
+		}
+
+		writeJSONIndent(sb, indent, depth)
+		sb.WriteByte('}')
+	default:
+		return fmt.Errorf("%s is not json serializable", typeName(v))
+	}
+
+	return nil
+}
+
+// writeJSONSeparator writes the item separator, python drops the space after
+// the comma when the json is indented
+func writeJSONSeparator(sb *strings.Builder, indent string) {
+	if indent != "" {
+		sb.WriteByte(',')
+		return
+	}
+
+	sb.WriteString(", ")
+}
+
+func writeJSONIndent(sb *strings.Builder, indent string, depth int) {
+	if indent == "" {
+		return
+	}
+
+	sb.WriteByte('\n')
+	sb.WriteString(strings.Repeat(indent, depth))
+}
+
+func writeJSONString(sb *strings.Builder, s string) {
+	sb.WriteByte('"')
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(sb, `\u%04x`, r)
+				continue
+			}
+
+			sb.WriteRune(r)
+		}
+	}
+
+	sb.WriteByte('"')
+}
+
+// pyFloat formats the float like the python json.dumps, which is the repr for
+// the finite floats
+func pyFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-4 || abs >= 1e16) {
+		return strconv.FormatFloat(f, 'e', -1, 64)
+	}
+
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+
+	return s
+}
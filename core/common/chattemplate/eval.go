@@ -0,0 +1,1154 @@
+package chattemplate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// undefined is the value of the missing variables, attributes and items, it
+// renders as an empty string and is false
+type undefined struct{}
+
+// callable is a function, a macro or a bound method in the templates
+type callable func(args []any, kwargs map[string]any) (any, error)
+
+var (
+	errLoopBreak    = errors.New("break outside of a loop")
+	errLoopContinue = errors.New("continue outside of a loop")
+)
+
+// the limits of a render, the templates come from the model configs so a
+// template that recurses, loops or grows without an end fails with a
+// LimitError instead of exhausting the stack or the memory of the proxy
+const (
+	maxCallDepth   = 64
+	maxIterations  = 100000
+	maxOutputBytes = 16 << 20
+)
+
+// renderState is shared by the scopes of a render to enforce the limits
+type renderState struct {
+	depth      int
+	iterations int
+	written    int
+}
+
+func (st *renderState) write(out *strings.Builder, str string) error {
+	st.written += len(str)
+	if st.written > maxOutputBytes {
+		return &LimitError{Message: fmt.Sprintf("template output exceeds %d bytes", maxOutputBytes)}
+	}
+
+	out.WriteString(str)
+
+	return nil
+}
+
+func (st *renderState) iterate() error {
+	st.iterations++
+	if st.iterations > maxIterations {
+		return &LimitError{Message: fmt.Sprintf("template loops exceed %d iterations", maxIterations)}
+	}
+
+	return nil
+}
+
+// checkSize rejects the strings and the lists too large for a render, so the
+// concatenations and the filters can not grow a value without an end
+func checkSize(v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		if len(v) > maxOutputBytes {
+			return nil, &LimitError{Message: fmt.Sprintf("template string exceeds %d bytes", maxOutputBytes)}
+		}
+	case []any:
+		if len(v) > maxIterations {
+			return nil, &LimitError{Message: fmt.Sprintf("template list exceeds %d items", maxIterations)}
+		}
+	}
+
+	return v, nil
+}
+
+type scope struct {
+	vars   map[string]any
+	parent *scope
+	state  *renderState
+}
+
+func newScope(parent *scope) *scope {
+	s := &scope{vars: make(map[string]any), parent: parent, state: &renderState{}}
+	if parent != nil {
+		s.state = parent.state
+	}
+
+	return s
+}
+
+func (s *scope) lookup(name string) any {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
+			return v
+		}
+	}
+
+	return undefined{}
+}
+
+type node interface {
+	render(s *scope, out *strings.Builder) error
+}
+
+func renderNodes(nodes []node, s *scope, out *strings.Builder) error {
+	for _, n := range nodes {
+		if err := n.render(s, out); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type textNode string
+
+func (n textNode) render(s *scope, out *strings.Builder) error {
+	return s.state.write(out, string(n))
+}
+
+type outputNode struct {
+	x expr
+}
+
+func (n *outputNode) render(s *scope, out *strings.Builder) error {
+	v, err := n.x.eval(s)
+	if err != nil {
+		return err
+	}
+
+	return s.state.write(out, toString(v))
+}
+
+type ifNode struct {
+	conds     []expr
+	bodies    [][]node
+	otherwise []node
+}
+
+func (n *ifNode) render(s *scope, out *strings.Builder) error {
+	for i, cond := range n.conds {
+		v, err := cond.eval(s)
+		if err != nil {
+			return err
+		}
+
+		if truthy(v) {
+			return renderNodes(n.bodies[i], s, out)
+		}
+	}
+
+	return renderNodes(n.otherwise, s, out)
+}
+
+type forNode struct {
+	targets   []string
+	iter      expr
+	filter    expr
+	body      []node
+	otherwise []node
+}
+
+func (n *forNode) render(s *scope, out *strings.Builder) error {
+	v, err := n.iter.eval(s)
+	if err != nil {
+		return err
+	}
+
+	items, err := iterate(v)
+	if err != nil {
+		return err
+	}
+
+	if n.filter != nil {
+		filtered := make([]any, 0, len(items))
+
+		for _, item := range items {
+			if err := s.state.iterate(); err != nil {
+				return err
+			}
+
+			itemScope := newScope(s)
+			if err := n.bind(itemScope, item); err != nil {
+				return err
+			}
+
+			ok, err := n.filter.eval(itemScope)
+			if err != nil {
+				return err
+			}
+
+			if truthy(ok) {
+				filtered = append(filtered, item)
+			}
+		}
+
+		items = filtered
+	}
+
+	if len(items) == 0 {
+		return renderNodes(n.otherwise, s, out)
+	}
+
+	for i, item := range items {
+		if err := s.state.iterate(); err != nil {
+			return err
+		}
+
+		itemScope := newScope(s)
+		if err := n.bind(itemScope, item); err != nil {
+			return err
+		}
+
+		itemScope.vars["loop"] = loopVars(items, i)
+
+		err := renderNodes(n.body, itemScope, out)
+		switch {
+		case errors.Is(err, errLoopBreak):
+			return nil
+		case errors.Is(err, errLoopContinue):
+		case err != nil:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *forNode) bind(s *scope, item any) error {
+	if len(n.targets) == 1 {
+		s.vars[n.targets[0]] = item
+		return nil
+	}
+
+	values, ok := item.([]any)
+	if !ok || len(values) != len(n.targets) {
+		return fmt.Errorf("can not unpack %s into %d values", typeName(item), len(n.targets))
+	}
+
+	for i, target := range n.targets {
+		s.vars[target] = values[i]
+	}
+
+	return nil
+}
+
+func loopVars(items []any, i int) *dict {
+	loop := newDict(9)
+	loop.set("index", i+1)
+	loop.set("index0", i)
+	loop.set("revindex", len(items)-i)
+	loop.set("revindex0", len(items)-i-1)
+	loop.set("first", i == 0)
+	loop.set("last", i == len(items)-1)
+	loop.set("length", len(items))
+	loop.set("previtem", undefined{})
+	loop.set("nextitem", undefined{})
+
+	if i > 0 {
+		loop.set("previtem", items[i-1])
+	}
+
+	if i < len(items)-1 {
+		loop.set("nextitem", items[i+1])
+	}
+
+	return loop
+}
+
+type setNode struct {
+	name  string
+	attr  string
+	value expr
+	body  []node
+}
+
+func (n *setNode) render(s *scope, _ *strings.Builder) error {
+	var value any
+
+	if n.value != nil {
+		v, err := n.value.eval(s)
+		if err != nil {
+			return err
+		}
+
+		value = v
+	} else {
+		var body strings.Builder
+		if err := renderNodes(n.body, s, &body); err != nil {
+			return err
+		}
+
+		value = body.String()
+	}
+
+	if n.attr == "" {
+		s.vars[n.name] = value
+		return nil
+	}
+
+	ns, ok := s.lookup(n.name).(*dict)
+	if !ok {
+		return fmt.Errorf("can not set the attribute of %s", n.name)
+	}
+
+	ns.set(n.attr, value)
+
+	return nil
+}
+
+type macroNode struct {
+	name     string
+	params   []string
+	defaults []expr
+	body     []node
+}
+
+func (n *macroNode) render(s *scope, _ *strings.Builder) error {
+	s.vars[n.name] = callable(func(args []any, kwargs map[string]any) (any, error) {
+		if s.state.depth >= maxCallDepth {
+			return nil, &LimitError{
+				Message: fmt.Sprintf("macro %s exceeds the call depth of %d", n.name, maxCallDepth),
+			}
+		}
+
+		s.state.depth++
+		defer func() {
+			s.state.depth--
+		}()
+
+		macroScope := newScope(s)
+
+		for i, param := range n.params {
+			kwarg, hasKwarg := kwargs[param]
+
+			switch {
+			case i < len(args):
+				macroScope.vars[param] = args[i]
+			case hasKwarg:
+				macroScope.vars[param] = kwarg
+			case n.defaults[i] != nil:
+				v, err := n.defaults[i].eval(s)
+				if err != nil {
+					return nil, err
+				}
+
+				macroScope.vars[param] = v
+			default:
+				macroScope.vars[param] = undefined{}
+			}
+		}
+
+		var out strings.Builder
+		if err := renderNodes(n.body, macroScope, &out); err != nil {
+			return nil, err
+		}
+
+		return out.String(), nil
+	})
+
+	return nil
+}
+
+type loopControlNode struct {
+	err error
+}
+
+func (n loopControlNode) render(*scope, *strings.Builder) error {
+	return n.err
+}
+
+type expr interface {
+	eval(s *scope) (any, error)
+}
+
+type literalExpr struct {
+	value any
+}
+
+func (e literalExpr) eval(*scope) (any, error) {
+	return e.value, nil
+}
+
+type nameExpr struct {
+	name string
+}
+
+func (e nameExpr) eval(s *scope) (any, error) {
+	return s.lookup(e.name), nil
+}
+
+type listExpr struct {
+	items []expr
+}
+
+func (e *listExpr) eval(s *scope) (any, error) {
+	list := make([]any, 0, len(e.items))
+
+	for _, item := range e.items {
+		v, err := item.eval(s)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, v)
+	}
+
+	return list, nil
+}
+
+type dictExpr struct {
+	keys   []expr
+	values []expr
+}
+
+func (e *dictExpr) eval(s *scope) (any, error) {
+	d := newDict(len(e.keys))
+
+	for i, key := range e.keys {
+		k, err := key.eval(s)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := e.values[i].eval(s)
+		if err != nil {
+			return nil, err
+		}
+
+		d.set(toString(k), v)
+	}
+
+	return d, nil
+}
+
+type attrExpr struct {
+	x    expr
+	name string
+}
+
+func (e *attrExpr) eval(s *scope) (any, error) {
+	v, err := e.x.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return getAttr(v, e.name), nil
+}
+
+type itemExpr struct {
+	x   expr
+	key expr
+}
+
+func (e *itemExpr) eval(s *scope) (any, error) {
+	v, err := e.x.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := e.key.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return getItem(v, key), nil
+}
+
+type sliceExpr struct {
+	x     expr
+	start expr
+	stop  expr
+	step  expr
+}
+
+func (e *sliceExpr) eval(s *scope) (any, error) {
+	v, err := e.x.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var bounds [3]*int
+
+	for i, part := range []expr{e.start, e.stop, e.step} {
+		if part == nil {
+			continue
+		}
+
+		b, err := part.eval(s)
+		if err != nil {
+			return nil, err
+		}
+
+		n, ok := toInt(b)
+		if !ok {
+			return nil, fmt.Errorf("slice indices must be integers, got %s", typeName(b))
+		}
+
+		bounds[i] = &n
+	}
+
+	switch v := v.(type) {
+	case string:
+		runes := sliceItems([]rune(v), bounds)
+		return string(runes), nil
+	case []any:
+		return sliceItems(v, bounds), nil
+	default:
+		return nil, fmt.Errorf("can not slice %s", typeName(v))
+	}
+}
+
+// sliceItems slices the items like the python slices
+func sliceItems[T any](items []T, bounds [3]*int) []T {
+	step := 1
+	if bounds[2] != nil && *bounds[2] != 0 {
+		step = *bounds[2]
+	}
+
+	n := len(items)
+
+	clamp := func(b *int, def int) int {
+		if b == nil {
+			return def
+		}
+
+		i := *b
+		if i < 0 {
+			i += n
+		}
+
+		if step > 0 {
+			return min(max(i, 0), n)
+		}
+
+		return min(max(i, -1), n-1)
+	}
+
+	var result []T
+
+	if step > 0 {
+		for i := clamp(bounds[0], 0); i < clamp(bounds[1], n); i += step {
+			result = append(result, items[i])
+		}
+	} else {
+		for i := clamp(bounds[0], n-1); i > clamp(bounds[1], -1); i += step {
+			result = append(result, items[i])
+		}
+	}
+
+	return result
+}
+
+type callExpr struct {
+	fn     expr
+	args   []expr
+	kwargs map[string]expr
+}
+
+func (e *callExpr) eval(s *scope) (any, error) {
+	fn, err := e.fn.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := fn.(callable)
+	if !ok {
+		return nil, fmt.Errorf("%s is not callable", typeName(fn))
+	}
+
+	args, kwargs, err := evalArgs(s, e.args, e.kwargs)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := f(args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkSize(v)
+}
+
+func evalArgs(s *scope, args []expr, kwargs map[string]expr) ([]any, map[string]any, error) {
+	values := make([]any, 0, len(args))
+
+	for _, arg := range args {
+		v, err := arg.eval(s)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		values = append(values, v)
+	}
+
+	named := make(map[string]any, len(kwargs))
+
+	for name, arg := range kwargs {
+		v, err := arg.eval(s)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		named[name] = v
+	}
+
+	return values, named, nil
+}
+
+type filterExpr struct {
+	x      expr
+	name   string
+	args   []expr
+	kwargs map[string]expr
+}
+
+func (e *filterExpr) eval(s *scope) (any, error) {
+	f, ok := filters[e.name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported filter %q", e.name)
+	}
+
+	v, err := e.x.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	args, kwargs, err := evalArgs(s, e.args, e.kwargs)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err = f(v, args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkSize(v)
+}
+
+type testExpr struct {
+	x       expr
+	name    string
+	args    []expr
+	negated bool
+}
+
+func (e *testExpr) eval(s *scope) (any, error) {
+	v, err := e.x.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	args, _, err := evalArgs(s, e.args, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := runTest(e.name, v, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return ok != e.negated, nil
+}
+
+type unaryExpr struct {
+	op string
+	x  expr
+}
+
+func (e *unaryExpr) eval(s *scope) (any, error) {
+	v, err := e.x.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "not":
+		return !truthy(v), nil
+	case "-":
+		return arithmetic("-", 0, v)
+	default:
+		return arithmetic("+", 0, v)
+	}
+}
+
+type binaryExpr struct {
+	op    string
+	left  expr
+	right expr
+}
+
+func (e *binaryExpr) eval(s *scope) (any, error) {
+	left, err := e.left.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "and":
+		if !truthy(left) {
+			return left, nil
+		}
+
+		return e.right.eval(s)
+	case "or":
+		if truthy(left) {
+			return left, nil
+		}
+
+		return e.right.eval(s)
+	}
+
+	right, err := e.right.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", ">", "<=", ">=":
+		return compare(e.op, left, right)
+	case "in":
+		return contains(right, left)
+	case "not in":
+		ok, err := contains(right, left)
+		return !ok, err
+	case "~":
+		return checkSize(toString(left) + toString(right))
+	default:
+		v, err := arithmetic(e.op, left, right)
+		if err != nil {
+			return nil, err
+		}
+
+		return checkSize(v)
+	}
+}
+
+type condExpr struct {
+	cond      expr
+	value     expr
+	otherwise expr
+}
+
+func (e *condExpr) eval(s *scope) (any, error) {
+	cond, err := e.cond.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if truthy(cond) {
+		return e.value.eval(s)
+	}
+
+	return e.otherwise.eval(s)
+}
+
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case nil, undefined:
+		return false
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case *dict:
+		return v.len() > 0
+	default:
+		return true
+	}
+}
+
+func toNumber(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+func toInt(v any) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case float64:
+		if v == math.Trunc(v) {
+			return int(v), true
+		}
+	case bool:
+		if v {
+			return 1, true
+		}
+
+		return 0, true
+	}
+
+	return 0, false
+}
+
+func arithmetic(op string, left, right any) (any, error) {
+	if op == "+" {
+		switch l := left.(type) {
+		case string:
+			if r, ok := right.(string); ok {
+				return l + r, nil
+			}
+		case []any:
+			if r, ok := right.([]any); ok {
+				return append(slices.Clone(l), r...), nil
+			}
+		}
+	}
+
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+
+	if !lok || !rok {
+		return nil, fmt.Errorf(
+			"unsupported operand types for %s: %s and %s",
+			op,
+			typeName(left),
+			typeName(right),
+		)
+	}
+
+	li, lint := left.(int)
+	ri, rint := right.(int)
+	ints := lint && rint
+
+	switch op {
+	case "+":
+		if ints {
+			return li + ri, nil
+		}
+
+		return l + r, nil
+	case "-":
+		if ints {
+			return li - ri, nil
+		}
+
+		return l - r, nil
+	case "*":
+		if ints {
+			return li * ri, nil
+		}
+
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, errors.New("division by zero")
+		}
+
+		return l / r, nil
+	case "//":
+		if r == 0 {
+			return nil, errors.New("division by zero")
+		}
+
+		if ints {
+			return int(math.Floor(l / r)), nil
+		}
+
+		return math.Floor(l / r), nil
+	case "%":
+		if r == 0 {
+			return nil, errors.New("division by zero")
+		}
+
+		if ints {
+			return ((li % ri) + ri) % ri, nil
+		}
+
+		return l - r*math.Floor(l/r), nil
+	default:
+		return nil, fmt.Errorf("unsupported operator %q", op)
+	}
+}
+
+func equal(left, right any) bool {
+	if l, ok := toNumber(left); ok {
+		r, ok := toNumber(right)
+		return ok && l == r
+	}
+
+	switch l := left.(type) {
+	case nil:
+		return right == nil
+	case undefined:
+		_, ok := right.(undefined)
+		return ok
+	case string:
+		r, ok := right.(string)
+		return ok && l == r
+	case []any:
+		r, ok := right.([]any)
+		return ok && slices.EqualFunc(l, r, equal)
+	case *dict:
+		r, ok := right.(*dict)
+		if !ok || l.len() != r.len() {
+			return false
+		}
+
+		for k, v := range l.values {
+			rv, ok := r.get(k)
+			if !ok || !equal(v, rv) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return false
+	}
+}
+
+func compare(op string, left, right any) (bool, error) {
+	var c int
+
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+
+	switch {
+	case lok && rok:
+		c = cmpFloat(l, r)
+	default:
+		ls, lok := left.(string)
+		rs, rok := right.(string)
+
+		if !lok || !rok {
+			return false, fmt.Errorf(
+				"can not compare %s with %s",
+				typeName(left),
+				typeName(right),
+			)
+		}
+
+		c = strings.Compare(ls, rs)
+	}
+
+	switch op {
+	case "<":
+		return c < 0, nil
+	case ">":
+		return c > 0, nil
+	case "<=":
+		return c <= 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+func cmpFloat(l, r float64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func contains(container, item any) (bool, error) {
+	switch c := container.(type) {
+	case string:
+		s, ok := item.(string)
+		if !ok {
+			return false, fmt.Errorf("'in <string>' requires string, got %s", typeName(item))
+		}
+
+		return strings.Contains(c, s), nil
+	case []any:
+		return slices.ContainsFunc(c, func(v any) bool {
+			return equal(v, item)
+		}), nil
+	case *dict:
+		s, ok := item.(string)
+		if !ok {
+			return false, nil
+		}
+
+		_, ok = c.get(s)
+
+		return ok, nil
+	case nil, undefined:
+		return false, nil
+	default:
+		return false, fmt.Errorf("%s is not a container", typeName(container))
+	}
+}
+
+// iterate returns the items of a loop, the keys are iterated in their order
+// for a mapping
+func iterate(v any) ([]any, error) {
+	switch v := v.(type) {
+	case []any:
+		return v, nil
+	case *dict:
+		items := make([]any, 0, v.len())
+		for _, k := range v.keys {
+			items = append(items, k)
+		}
+
+		return items, nil
+	case string:
+		items := make([]any, 0, len(v))
+		for _, r := range v {
+			items = append(items, string(r))
+		}
+
+		return items, nil
+	case nil, undefined:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%s is not iterable", typeName(v))
+	}
+}
+
+func getAttr(v any, name string) any {
+	switch v := v.(type) {
+	case *dict:
+		if attr, ok := v.get(name); ok {
+			return attr
+		}
+
+		return dictMethod(v, name)
+	case string:
+		return stringMethod(v, name)
+	default:
+		return undefined{}
+	}
+}
+
+func getItem(v, key any) any {
+	switch v := v.(type) {
+	case *dict:
+		k, ok := key.(string)
+		if !ok {
+			return undefined{}
+		}
+
+		if item, ok := v.get(k); ok {
+			return item
+		}
+	case []any:
+		if i, ok := toInt(key); ok {
+			if i < 0 {
+				i += len(v)
+			}
+
+			if i >= 0 && i < len(v) {
+				return v[i]
+			}
+		}
+	case string:
+		if i, ok := toInt(key); ok {
+			runes := []rune(v)
+			if i < 0 {
+				i += len(runes)
+			}
+
+			if i >= 0 && i < len(runes) {
+				return string(runes[i])
+			}
+		}
+	}
+
+	return undefined{}
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "none"
+	case undefined:
+		return "undefined"
+	case bool:
+		return "boolean"
+	case int:
+		return "integer"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case []any:
+		return "list"
+	case *dict:
+		return "mapping"
+	case callable:
+		return "callable"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// toString formats the value like the python str
+func toString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "None"
+	case undefined:
+		return ""
+	case bool:
+		if v {
+			return "True"
+		}
+
+		return "False"
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "nan"
+		case math.IsInf(v, 1):
+			return "inf"
+		case math.IsInf(v, -1):
+			return "-inf"
+		}
+
+		return pyFloat(v)
+	case string:
+		return v
+	default:
+		s, err := toJSON(v, "")
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+
+		return s
+	}
+}
@@ -0,0 +1,1251 @@
+package chattemplate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type segmentKind int
+
+const (
+	segmentText segmentKind = iota
+	segmentOutput
+	segmentStatement
+)
+
+type segment struct {
+	kind segmentKind
+	text string
+}
+
+// splitSegments splits the source into the text, the output and the statement
+// segments, the whitespace control of the tags is applied to the text with the
+// trim_blocks and the lstrip_blocks behavior of the chat templates
+func splitSegments(src string) ([]segment, error) {
+	var (
+		segments  []segment
+		trimLeft  bool
+		trimBlock bool
+		pos       int
+	)
+
+	for pos < len(src) {
+		idx := nextTagIndex(src, pos)
+
+		end := len(src)
+		if idx >= 0 {
+			end = idx
+		}
+
+		text := src[pos:end]
+
+		switch {
+		case trimLeft:
+			text = strings.TrimLeft(text, " \t\r\n")
+		case trimBlock:
+			text = strings.TrimPrefix(strings.TrimPrefix(text, "\r"), "\n")
+		}
+
+		if idx < 0 {
+			if text != "" {
+				segments = append(segments, segment{kind: segmentText, text: text})
+			}
+
+			break
+		}
+
+		open := src[idx : idx+2]
+		inner := idx + 2
+
+		switch {
+		case inner < len(src) && src[inner] == '-':
+			text = strings.TrimRight(text, " \t\r\n")
+			inner++
+		case inner < len(src) && src[inner] == '+':
+			inner++
+		case open != "{{":
+			text = lstripBlock(src, pos, text)
+		}
+
+		if text != "" {
+			segments = append(segments, segment{kind: segmentText, text: text})
+		}
+
+		closeTag := map[string]string{"{{": "}}", "{%": "%}", "{#": "#}"}[open]
+
+		closeIdx, err := findClose(src, inner, closeTag, open == "{#")
+		if err != nil {
+			return nil, err
+		}
+
+		content := src[inner:closeIdx]
+
+		trimLeft = strings.HasSuffix(content, "-")
+		if trimLeft {
+			content = content[:len(content)-1]
+		}
+
+		trimBlock = open != "{{"
+		pos = closeIdx + 2
+
+		switch open {
+		case "{{":
+			segments = append(segments, segment{kind: segmentOutput, text: content})
+		case "{%":
+			segments = append(segments, segment{kind: segmentStatement, text: content})
+		}
+	}
+
+	return segments, nil
+}
+
+func nextTagIndex(src string, pos int) int {
+	for i := pos; i+1 < len(src); i++ {
+		if src[i] != '{' {
+			continue
+		}
+
+		switch src[i+1] {
+		case '{', '%', '#':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// lstripBlock strips the spaces and the tabs before a block tag when the tag
+// starts the line
+func lstripBlock(src string, textStart int, text string) string {
+	lineStart := strings.LastIndexByte(text, '\n') + 1
+	if lineStart == 0 && textStart > 0 && src[textStart-1] != '\n' {
+		return text
+	}
+
+	if strings.Trim(text[lineStart:], " \t") != "" {
+		return text
+	}
+
+	return text[:lineStart]
+}
+
+// findClose returns the index of the close tag, the quoted strings of the
+// expressions are skipped and the close tag only matches outside of the
+// brackets like jinja, so a nested dict literal does not end the tag
+func findClose(src string, pos int, closeTag string, comment bool) (int, error) {
+	var (
+		quote byte
+		depth int
+	)
+
+	for i := pos; i < len(src); i++ {
+		c := src[i]
+
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case !comment && (c == '\'' || c == '"'):
+			quote = c
+		case depth == 0 && strings.HasPrefix(src[i:], closeTag):
+			return i, nil
+		case !comment && (c == '(' || c == '[' || c == '{'):
+			depth++
+		case !comment && depth > 0 && (c == ')' || c == ']' || c == '}'):
+			depth--
+		}
+	}
+
+	return 0, fmt.Errorf("unclosed tag, expected %q", closeTag)
+}
+
+type tokenKind int
+
+const (
+	tokenName tokenKind = iota
+	tokenString
+	tokenInt
+	tokenFloat
+	tokenOperator
+	tokenEOF
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+var operators = []string{
+	"==", "!=", "<=", ">=", "//", "**",
+	"(", ")", "[", "]", "{", "}", ".", ",", ":", "|", "~",
+	"+", "-", "*", "/", "%", "<", ">", "=",
+}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(src); {
+		c := src[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case isNameStart(c):
+			start := i
+			for i < len(src) && (isNameStart(src[i]) || isDigit(src[i])) {
+				i++
+			}
+
+			tokens = append(tokens, token{kind: tokenName, value: src[start:i]})
+		case isDigit(c):
+			start := i
+			kind := tokenInt
+
+			for i < len(src) && (isDigit(src[i]) || src[i] == '_') {
+				i++
+			}
+
+			if i+1 < len(src) && src[i] == '.' && isDigit(src[i+1]) {
+				kind = tokenFloat
+
+				i++
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+			}
+
+			if n := exponentLen(src[i:]); n > 0 {
+				kind = tokenFloat
+				i += n
+			}
+
+			tokens = append(tokens, token{
+				kind:  kind,
+				value: strings.ReplaceAll(src[start:i], "_", ""),
+			})
+		case c == '\'' || c == '"':
+			s, n, err := lexString(src[i:])
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, token{kind: tokenString, value: s})
+			i += n
+		default:
+			op := ""
+
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+
+			tokens = append(tokens, token{kind: tokenOperator, value: op})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+// exponentLen returns the length of the exponent of a float literal at the
+// start of src, or 0 if there is none
+func exponentLen(src string) int {
+	if len(src) < 2 || (src[0] != 'e' && src[0] != 'E') {
+		return 0
+	}
+
+	i := 1
+	if src[i] == '+' || src[i] == '-' {
+		i++
+	}
+
+	start := i
+	for i < len(src) && isDigit(src[i]) {
+		i++
+	}
+
+	if i == start {
+		return 0
+	}
+
+	return i
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// lexString returns the unquoted string literal at the start of src and the
+// length of the literal
+func lexString(src string) (string, int, error) {
+	quote := src[0]
+
+	var sb strings.Builder
+
+	for i := 1; i < len(src); i++ {
+		c := src[i]
+
+		switch c {
+		case quote:
+			return sb.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(src) {
+				return "", 0, errors.New("unterminated string")
+			}
+
+			i++
+
+			switch src[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case '\\', '\'', '"':
+				sb.WriteByte(src[i])
+			case 'x', 'u', 'U':
+				size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[src[i]]
+				if i+size >= len(src) {
+					return "", 0, errors.New("truncated escape in string")
+				}
+
+				r, err := strconv.ParseUint(src[i+1:i+1+size], 16, 32)
+				if err != nil {
+					return "", 0, fmt.Errorf("invalid escape in string: %w", err)
+				}
+
+				sb.WriteRune(rune(r))
+
+				i += size
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(src[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return "", 0, errors.New("unterminated string")
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func newParser(src string) (*parser, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parser{tokens: tokens}, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(offset int) token {
+	if p.pos+offset >= len(p.tokens) {
+		return token{kind: tokenEOF}
+	}
+
+	return p.tokens[p.pos+offset]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) isOperator(op string) bool {
+	t := p.peek()
+	return t.kind == tokenOperator && t.value == op
+}
+
+func (p *parser) isName(names ...string) bool {
+	t := p.peek()
+	return t.kind == tokenName && slices.Contains(names, t.value)
+}
+
+func (p *parser) skipOperator(op string) bool {
+	if !p.isOperator(op) {
+		return false
+	}
+
+	p.next()
+
+	return true
+}
+
+func (p *parser) skipName(name string) bool {
+	if !p.isName(name) {
+		return false
+	}
+
+	p.next()
+
+	return true
+}
+
+func (p *parser) expectOperator(op string) error {
+	if !p.skipOperator(op) {
+		return fmt.Errorf("expected %q, got %q", op, p.peek().value)
+	}
+
+	return nil
+}
+
+func (p *parser) expectName() (string, error) {
+	t := p.next()
+	if t.kind != tokenName {
+		return "", fmt.Errorf("expected a name, got %q", t.value)
+	}
+
+	return t.value, nil
+}
+
+func (p *parser) expectEnd() error {
+	if t := p.peek(); t.kind != tokenEOF {
+		return fmt.Errorf("unexpected %q", t.value)
+	}
+
+	return nil
+}
+
+// parseExpression parses a full expression including the conditional
+// expression
+func (p *parser) parseExpression() (expr, error) {
+	value, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.skipName("if") {
+		return value, nil
+	}
+
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	var otherwise expr = literalExpr{value: undefined{}}
+	if p.skipName("else") {
+		otherwise, err = p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &condExpr{cond: cond, value: value, otherwise: otherwise}, nil
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.skipName("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryExpr{op: "or", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.skipName("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryExpr{op: "and", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.skipName("not") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return &unaryExpr{op: "not", x: x}, nil
+	}
+
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (expr, error) {
+	left, err := p.parseAdd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var op string
+
+		switch {
+		case p.isOperator("==") || p.isOperator("!=") || p.isOperator("<") ||
+			p.isOperator(">") || p.isOperator("<=") || p.isOperator(">="):
+			op = p.next().value
+		case p.isName("in"):
+			p.next()
+
+			op = "in"
+		case p.isName("not") && p.peekAt(1).kind == tokenName && p.peekAt(1).value == "in":
+			p.next()
+			p.next()
+
+			op = "not in"
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseAdd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseAdd() (expr, error) {
+	left, err := p.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("+") || p.isOperator("-") {
+		op := p.next().value
+
+		right, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseConcat() (expr, error) {
+	left, err := p.parseMul()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.skipOperator("~") {
+		right, err := p.parseMul()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryExpr{op: "~", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseMul() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("*") || p.isOperator("/") || p.isOperator("//") || p.isOperator("%") {
+		op := p.next().value
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.isOperator("-") || p.isOperator("+") {
+		op := p.next().value
+
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &unaryExpr{op: op, x: x}, nil
+	}
+
+	x, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.parseFilters(x)
+}
+
+func (p *parser) parsePostfix() (expr, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.skipOperator("."):
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+
+			x = &attrExpr{x: x, name: name}
+		case p.skipOperator("["):
+			x, err = p.parseSubscript(x)
+			if err != nil {
+				return nil, err
+			}
+		case p.skipOperator("("):
+			args, kwargs, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+
+			x = &callExpr{fn: x, args: args, kwargs: kwargs}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) parseSubscript(x expr) (expr, error) {
+	var (
+		parts [3]expr
+		slice bool
+	)
+
+	for i := range parts {
+		if !p.isOperator(":") && !p.isOperator("]") {
+			part, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+
+			parts[i] = part
+		}
+
+		if i == len(parts)-1 || !p.skipOperator(":") {
+			break
+		}
+
+		slice = true
+	}
+
+	if err := p.expectOperator("]"); err != nil {
+		return nil, err
+	}
+
+	if !slice {
+		if parts[0] == nil {
+			return nil, errors.New("empty subscript")
+		}
+
+		return &itemExpr{x: x, key: parts[0]}, nil
+	}
+
+	return &sliceExpr{x: x, start: parts[0], stop: parts[1], step: parts[2]}, nil
+}
+
+// parseArgs parses the arguments after the open parenthesis of a call
+func (p *parser) parseArgs() ([]expr, map[string]expr, error) {
+	var (
+		args   []expr
+		kwargs map[string]expr
+	)
+
+	for !p.skipOperator(")") {
+		if len(args) > 0 || len(kwargs) > 0 {
+			if err := p.expectOperator(","); err != nil {
+				return nil, nil, err
+			}
+
+			if p.skipOperator(")") {
+				break
+			}
+		}
+
+		if p.peek().kind == tokenName && p.peekAt(1).kind == tokenOperator &&
+			p.peekAt(1).value == "=" {
+			name := p.next().value
+			p.next()
+
+			value, err := p.parseExpression()
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if kwargs == nil {
+				kwargs = make(map[string]expr)
+			}
+
+			kwargs[name] = value
+
+			continue
+		}
+
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		args = append(args, arg)
+	}
+
+	return args, kwargs, nil
+}
+
+func (p *parser) parseFilters(x expr) (expr, error) {
+	for {
+		switch {
+		case p.skipOperator("|"):
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+
+			f := &filterExpr{x: x, name: name}
+			if p.skipOperator("(") {
+				f.args, f.kwargs, err = p.parseArgs()
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			x = f
+		case p.skipName("is"):
+			t := &testExpr{x: x, negated: p.skipName("not")}
+
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+
+			t.name = name
+
+			arg, err := p.parseTestArg()
+			if err != nil {
+				return nil, err
+			}
+
+			if arg != nil {
+				t.args = []expr{arg}
+			}
+
+			x = t
+		default:
+			return x, nil
+		}
+	}
+}
+
+// parseTestArg parses the optional argument of a test, such as the 3 of
+// "is divisibleby 3"
+func (p *parser) parseTestArg() (expr, error) {
+	if p.skipOperator("(") {
+		args, _, err := p.parseArgs()
+		if err != nil || len(args) == 0 {
+			return nil, err
+		}
+
+		return args[0], nil
+	}
+
+	t := p.peek()
+	switch {
+	case t.kind == tokenString || t.kind == tokenInt || t.kind == tokenFloat:
+	case t.kind == tokenName &&
+		!slices.Contains([]string{"and", "or", "not", "in", "is", "if", "else"}, t.value):
+	default:
+		return nil, nil
+	}
+
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenString:
+		s := t.value
+		// adjacent string literals are concatenated
+		for p.peek().kind == tokenString {
+			s += p.next().value
+		}
+
+		return literalExpr{value: s}, nil
+	case tokenInt:
+		n, err := strconv.Atoi(t.value)
+		if err != nil {
+			return nil, err
+		}
+
+		return literalExpr{value: n}, nil
+	case tokenFloat:
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		return literalExpr{value: f}, nil
+	case tokenName:
+		switch t.value {
+		case "true", "True":
+			return literalExpr{value: true}, nil
+		case "false", "False":
+			return literalExpr{value: false}, nil
+		case "none", "None":
+			return literalExpr{value: nil}, nil
+		}
+
+		return nameExpr{name: t.value}, nil
+	case tokenOperator:
+		switch t.value {
+		case "(":
+			return p.parseParen()
+		case "[":
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+
+			return &listExpr{items: items}, nil
+		case "{":
+			return p.parseDict()
+		}
+	}
+
+	if t.kind == tokenEOF {
+		return nil, errors.New("unexpected end of expression")
+	}
+
+	return nil, fmt.Errorf("unexpected %q", t.value)
+}
+
+func (p *parser) parseParen() (expr, error) {
+	if p.skipOperator(")") {
+		return &listExpr{}, nil
+	}
+
+	x, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.skipOperator(")") {
+		return x, nil
+	}
+
+	if err := p.expectOperator(","); err != nil {
+		return nil, err
+	}
+
+	rest, err := p.parseList(")")
+	if err != nil {
+		return nil, err
+	}
+
+	return &listExpr{items: append([]expr{x}, rest...)}, nil
+}
+
+// parseList parses the comma separated expressions until the close operator
+func (p *parser) parseList(closeOp string) ([]expr, error) {
+	var items []expr
+
+	for !p.skipOperator(closeOp) {
+		if len(items) > 0 {
+			if err := p.expectOperator(","); err != nil {
+				return nil, err
+			}
+
+			if p.skipOperator(closeOp) {
+				break
+			}
+		}
+
+		item, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func (p *parser) parseDict() (expr, error) {
+	d := &dictExpr{}
+
+	for !p.skipOperator("}") {
+		if len(d.keys) > 0 {
+			if err := p.expectOperator(","); err != nil {
+				return nil, err
+			}
+
+			if p.skipOperator("}") {
+				break
+			}
+		}
+
+		key, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expectOperator(":"); err != nil {
+			return nil, err
+		}
+
+		value, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		d.keys = append(d.keys, key)
+		d.values = append(d.values, value)
+	}
+
+	return d, nil
+}
+
+// templateParser builds the node tree of the segments
+type templateParser struct {
+	segments []segment
+	pos      int
+}
+
+// parseBody parses the nodes until one of the end statements, the end
+// statement found and the parser of its remaining tokens are returned
+func (tp *templateParser) parseBody(ends ...string) ([]node, string, *parser, error) {
+	var nodes []node
+
+	for tp.pos < len(tp.segments) {
+		seg := tp.segments[tp.pos]
+		tp.pos++
+
+		switch seg.kind {
+		case segmentText:
+			nodes = append(nodes, textNode(seg.text))
+		case segmentOutput:
+			p, err := newParser(seg.text)
+			if err != nil {
+				return nil, "", nil, err
+			}
+
+			x, err := p.parseExpression()
+			if err != nil {
+				return nil, "", nil, err
+			}
+
+			if err := p.expectEnd(); err != nil {
+				return nil, "", nil, err
+			}
+
+			nodes = append(nodes, &outputNode{x: x})
+		case segmentStatement:
+			p, err := newParser(seg.text)
+			if err != nil {
+				return nil, "", nil, err
+			}
+
+			keyword, err := p.expectName()
+			if err != nil {
+				return nil, "", nil, err
+			}
+
+			if slices.Contains(ends, keyword) {
+				return nodes, keyword, p, nil
+			}
+
+			n, err := tp.parseStatement(keyword, p)
+			if err != nil {
+				return nil, "", nil, fmt.Errorf("%s: %w", keyword, err)
+			}
+
+			if n != nil {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+
+	if len(ends) > 0 {
+		return nil, "", nil, fmt.Errorf("unexpected end of template, expected %s", strings.Join(ends, " or "))
+	}
+
+	return nodes, "", nil, nil
+}
+
+func (tp *templateParser) parseStatement(keyword string, p *parser) (node, error) {
+	var (
+		n   node
+		err error
+	)
+
+	switch keyword {
+	case "if":
+		n, err = tp.parseIf(p)
+	case "for":
+		n, err = tp.parseFor(p)
+	case "set":
+		n, err = tp.parseSet(p)
+	case "macro":
+		n, err = tp.parseMacro(p)
+	case "break":
+		n = loopControlNode{errLoopBreak}
+	case "continue":
+		n = loopControlNode{errLoopContinue}
+	case "generation", "endgeneration":
+		// the generation blocks only mark the assistant tokens for training
+	default:
+		return nil, errors.New("unsupported statement")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return n, p.expectEnd()
+}
+
+func (tp *templateParser) parseIf(p *parser) (node, error) {
+	n := &ifNode{}
+
+	for {
+		cond, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expectEnd(); err != nil {
+			return nil, err
+		}
+
+		body, end, rest, err := tp.parseBody("elif", "else", "endif")
+		if err != nil {
+			return nil, err
+		}
+
+		n.conds = append(n.conds, cond)
+		n.bodies = append(n.bodies, body)
+
+		switch end {
+		case "elif":
+			p = rest
+			continue
+		case "else":
+			if err := rest.expectEnd(); err != nil {
+				return nil, err
+			}
+
+			n.otherwise, _, rest, err = tp.parseBody("endif")
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return n, rest.expectEnd()
+	}
+}
+
+func (tp *templateParser) parseFor(p *parser) (node, error) {
+	n := &forNode{}
+
+	for {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+
+		n.targets = append(n.targets, name)
+
+		if !p.skipOperator(",") {
+			break
+		}
+	}
+
+	if !p.skipName("in") {
+		return nil, errors.New(`expected "in"`)
+	}
+
+	iter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	n.iter = iter
+
+	if p.skipName("if") {
+		n.filter, err = p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expectEnd(); err != nil {
+		return nil, err
+	}
+
+	body, end, rest, err := tp.parseBody("else", "endfor")
+	if err != nil {
+		return nil, err
+	}
+
+	n.body = body
+
+	if end == "else" {
+		if err := rest.expectEnd(); err != nil {
+			return nil, err
+		}
+
+		n.otherwise, _, rest, err = tp.parseBody("endfor")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return n, rest.expectEnd()
+}
+
+func (tp *templateParser) parseSet(p *parser) (node, error) {
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &setNode{name: name}
+
+	if p.skipOperator(".") {
+		n.attr, err = p.expectName()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.skipOperator("=") {
+		n.value, err = p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		return n, nil
+	}
+
+	if err := p.expectEnd(); err != nil {
+		return nil, err
+	}
+
+	body, _, rest, err := tp.parseBody("endset")
+	if err != nil {
+		return nil, err
+	}
+
+	n.body = body
+
+	return n, rest.expectEnd()
+}
+
+func (tp *templateParser) parseMacro(p *parser) (node, error) {
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &macroNode{name: name}
+
+	if err := p.expectOperator("("); err != nil {
+		return nil, err
+	}
+
+	for !p.skipOperator(")") {
+		if len(n.params) > 0 {
+			if err := p.expectOperator(","); err != nil {
+				return nil, err
+			}
+		}
+
+		param, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+
+		var def expr
+		if p.skipOperator("=") {
+			def, err = p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		n.params = append(n.params, param)
+		n.defaults = append(n.defaults, def)
+	}
+
+	if err := p.expectEnd(); err != nil {
+		return nil, err
+	}
+
+	body, _, rest, err := tp.parseBody("endmacro")
+	if err != nil {
+		return nil, err
+	}
+
+	n.body = body
+
+	return n, rest.expectEnd()
+}
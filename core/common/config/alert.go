@@ -0,0 +1,187 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sync/atomic"
+
+	"github.com/labring/aiproxy/core/common/env"
+)
+
+const (
+	AlertNotifierFeishu   = "feishu"
+	AlertNotifierSlack    = "slack"
+	AlertNotifierDingTalk = "dingtalk"
+	// AlertNotifierWebhook posts the alert as json to the url
+	AlertNotifierWebhook = "webhook"
+
+	defaultBudgetAlertDedupSeconds = 24 * 60 * 60
+)
+
+// AlertNotifier sends the usage and budget alerts to a chat group or a webhook,
+// the fields tagged with secret:"true" are redacted from the audit logs
+type AlertNotifier struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	URL      string `json:"url"                secret:"true"`
+	Disabled bool   `json:"disabled,omitempty"`
+	// Secret signs the dingtalk robot requests and the webhook bodies
+	Secret string `json:"secret,omitempty" secret:"true"`
+}
+
+func (n AlertNotifier) Validate() error {
+	if n.Name == "" {
+		return errors.New("alert notifier name is required")
+	}
+
+	switch n.Type {
+	case AlertNotifierFeishu, AlertNotifierSlack, AlertNotifierDingTalk, AlertNotifierWebhook:
+	default:
+		return fmt.Errorf("alert notifier %s: unknown type %s", n.Name, n.Type)
+	}
+
+	u, err := url.Parse(n.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("alert notifier %s: invalid url", n.Name)
+	}
+
+	return nil
+}
+
+func ValidateAlertNotifiers(notifiers []AlertNotifier) error {
+	names := make(map[string]struct{}, len(notifiers))
+	for _, notifier := range notifiers {
+		if err := notifier.Validate(); err != nil {
+			return err
+		}
+
+		if _, ok := names[notifier.Name]; ok {
+			return fmt.Errorf("duplicate alert notifier: %s", notifier.Name)
+		}
+
+		names[notifier.Name] = struct{}{}
+	}
+
+	return nil
+}
+
+// AlertTemplate is the text/template of the title and the message of an alert
+// kind, an empty one keeps the default
+type AlertTemplate struct {
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// ValidateBudgetAlertThresholds checks the thresholds are ratios of the quota
+func ValidateBudgetAlertThresholds(thresholds []float64) error {
+	for _, threshold := range thresholds {
+		if threshold <= 0 || threshold > 1 {
+			return fmt.Errorf("budget alert threshold %g must be in (0, 1]", threshold)
+		}
+	}
+
+	return nil
+}
+
+var (
+	alertNotifiers             atomic.Value
+	alertTemplates             atomic.Value // alert kind -> template
+	budgetAlertThresholds      atomic.Value // default 0 thresholds means disabled
+	budgetAlertGroupThresholds atomic.Value // group -> thresholds
+	budgetAlertDedupSeconds    atomic.Int64
+)
+
+func init() {
+	alertNotifiers.Store(make([]AlertNotifier, 0))
+	alertTemplates.Store(make(map[string]AlertTemplate))
+	budgetAlertThresholds.Store(make([]float64, 0))
+	budgetAlertGroupThresholds.Store(make(map[string][]float64))
+	budgetAlertDedupSeconds.Store(defaultBudgetAlertDedupSeconds)
+}
+
+func GetAlertNotifiers() []AlertNotifier {
+	n, _ := alertNotifiers.Load().([]AlertNotifier)
+	return n
+}
+
+func SetAlertNotifiers(notifiers []AlertNotifier) {
+	notifiers = env.JSON("ALERT_NOTIFIERS", notifiers)
+	if notifiers == nil {
+		notifiers = make([]AlertNotifier, 0)
+	}
+
+	alertNotifiers.Store(notifiers)
+}
+
+func GetAlertTemplates() map[string]AlertTemplate {
+	t, _ := alertTemplates.Load().(map[string]AlertTemplate)
+	return t
+}
+
+func SetAlertTemplates(templates map[string]AlertTemplate) {
+	templates = env.JSON("ALERT_TEMPLATES", templates)
+	if templates == nil {
+		templates = make(map[string]AlertTemplate)
+	}
+
+	alertTemplates.Store(templates)
+}
+
+func GetBudgetAlertDefaultThresholds() []float64 {
+	t, _ := budgetAlertThresholds.Load().([]float64)
+	return t
+}
+
+func SetBudgetAlertDefaultThresholds(thresholds []float64) {
+	thresholds = env.JSON("BUDGET_ALERT_THRESHOLDS", thresholds)
+	if thresholds == nil {
+		thresholds = make([]float64, 0)
+	}
+
+	budgetAlertThresholds.Store(thresholds)
+}
+
+func GetBudgetAlertGroupThresholds() map[string][]float64 {
+	t, _ := budgetAlertGroupThresholds.Load().(map[string][]float64)
+	return t
+}
+
+func SetBudgetAlertGroupThresholds(thresholds map[string][]float64) {
+	thresholds = env.JSON("BUDGET_ALERT_GROUP_THRESHOLDS", thresholds)
+	if thresholds == nil {
+		thresholds = make(map[string][]float64)
+	}
+
+	budgetAlertGroupThresholds.Store(thresholds)
+}
+
+// GetBudgetAlertThresholds returns the ascending quota ratios alerted for the
+// tokens of the group, the group thresholds override the default ones and an
+// empty list disables the alerts of the group
+func GetBudgetAlertThresholds(group string) []float64 {
+	thresholds, ok := GetBudgetAlertGroupThresholds()[group]
+	if !ok {
+		thresholds = GetBudgetAlertDefaultThresholds()
+	}
+
+	if slices.IsSorted(thresholds) {
+		return thresholds
+	}
+
+	return slices.Sorted(slices.Values(thresholds))
+}
+
+func GetBudgetAlertDedupSeconds() int64 {
+	return budgetAlertDedupSeconds.Load()
+}
+
+func SetBudgetAlertDedupSeconds(seconds int64) {
+	seconds = env.Int64("BUDGET_ALERT_DEDUP_SECONDS", seconds)
+	if seconds <= 0 {
+		seconds = defaultBudgetAlertDedupSeconds
+	}
+
+	budgetAlertDedupSeconds.Store(seconds)
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/labring/aiproxy/core/common/env"
+)
+
+const (
+	// AutoBanActionBanModel bans the model on the channel
+	AutoBanActionBanModel = "ban_model"
+	// AutoBanActionBanChannel bans all models of the channel
+	AutoBanActionBanChannel = "ban_channel"
+
+	defaultAutoBanWindowSeconds   = 60
+	defaultAutoBanCooldownSeconds = 300
+)
+
+// AutoBanRule bans a channel model, or the whole channel, for the cooldown when
+// the matching errors reach the threshold within the window
+type AutoBanRule struct {
+	Name     string `json:"name"`
+	Disabled bool   `json:"disabled,omitempty"`
+	// conditions, an empty condition matches all errors
+	StatusCodes   []int    `json:"status_codes,omitempty"`
+	ErrorContains []string `json:"error_contains,omitempty"`
+	Models        []string `json:"models,omitempty"`
+	Channels      []int64  `json:"channels,omitempty"`
+	// frequency window, default one match within 60 seconds
+	Threshold     int64 `json:"threshold,omitempty"`
+	WindowSeconds int64 `json:"window_seconds,omitempty"`
+	// action, default ban the model on the channel for 300 seconds
+	Action          string `json:"action,omitempty"`
+	CooldownSeconds int64  `json:"cooldown_seconds,omitempty"`
+}
+
+func (r AutoBanRule) Validate() error {
+	if r.Name == "" {
+		return errors.New("auto ban rule name is required")
+	}
+
+	if strings.ContainsAny(r.Name, ": ") {
+		return fmt.Errorf("auto ban rule %s: name must not contain spaces or colons", r.Name)
+	}
+
+	switch r.Action {
+	case "", AutoBanActionBanModel, AutoBanActionBanChannel:
+	default:
+		return fmt.Errorf("auto ban rule %s: unknown action %s", r.Name, r.Action)
+	}
+
+	if r.Threshold < 0 || r.WindowSeconds < 0 || r.CooldownSeconds < 0 {
+		return fmt.Errorf(
+			"auto ban rule %s: threshold, window and cooldown must not be negative",
+			r.Name,
+		)
+	}
+
+	if len(r.StatusCodes) == 0 && len(r.ErrorContains) == 0 {
+		return fmt.Errorf(
+			"auto ban rule %s: status codes or error substrings are required",
+			r.Name,
+		)
+	}
+
+	return nil
+}
+
+func (r AutoBanRule) GetThreshold() int64 {
+	if r.Threshold <= 0 {
+		return 1
+	}
+
+	return r.Threshold
+}
+
+func (r AutoBanRule) GetWindowSeconds() int64 {
+	if r.WindowSeconds <= 0 {
+		return defaultAutoBanWindowSeconds
+	}
+
+	return r.WindowSeconds
+}
+
+func (r AutoBanRule) GetAction() string {
+	if r.Action == "" {
+		return AutoBanActionBanModel
+	}
+
+	return r.Action
+}
+
+func (r AutoBanRule) GetCooldownSeconds() int64 {
+	if r.CooldownSeconds <= 0 {
+		return defaultAutoBanCooldownSeconds
+	}
+
+	return r.CooldownSeconds
+}
+
+func ValidateAutoBanRules(rules []AutoBanRule) error {
+	names := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+
+		if _, ok := names[rule.Name]; ok {
+			return fmt.Errorf("duplicate auto ban rule: %s", rule.Name)
+		}
+
+		names[rule.Name] = struct{}{}
+	}
+
+	return nil
+}
+
+var autoBanRules atomic.Value
+
+func init() {
+	autoBanRules.Store(make([]AutoBanRule, 0))
+}
+
+func GetAutoBanRules() []AutoBanRule {
+	r, _ := autoBanRules.Load().([]AutoBanRule)
+	return r
+}
+
+func SetAutoBanRules(rules []AutoBanRule) {
+	rules = env.JSON("AUTO_BAN_RULES", rules)
+	if rules == nil {
+		rules = make([]AutoBanRule, 0)
+	}
+
+	autoBanRules.Store(rules)
+}
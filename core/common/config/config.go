@@ -4,6 +4,7 @@ import (
 	"math"
 	"slices"
 	"strconv"
+	"strings"
 	"sync/atomic"
 
 	"github.com/labring/aiproxy/core/common/env"
@@ -22,6 +23,16 @@ var (
 	ipGroupsThreshold            atomic.Int64
 	ipGroupsBanThreshold         atomic.Int64
 	retryTimes                   atomic.Int64
+	clientAbortGraceSeconds      atomic.Int64 // default 0 cancels the upstream request at once
+	idempotencyKeyTTLSeconds     atomic.Int64 // 0 disables the idempotency keys
+	liveMaxSessionSeconds        atomic.Int64 // 0 does not limit the live sessions
+	archivePurgeHours            atomic.Int64 // default 0 keeps the archived channels and tokens
+	auditLogStorageHours         atomic.Int64 // default 0 keeps the audit logs
+	summaryMinuteStorageHours    atomic.Int64 // default 0 keeps the minute summaries
+	summaryHourStorageHours      atomic.Int64 // default 0 keeps the hourly summaries
+	summaryDayStorageHours       atomic.Int64 // default 0 keeps the daily summaries
+	fairQueueMaxConcurrency      atomic.Int64 // default 0 disables the fair queuing of the groups
+	fairQueueTimeoutSeconds      atomic.Int64
 	defaultChannelModels         atomic.Value
 	defaultChannelModelMapping   atomic.Value
 	groupMaxTokenNum             atomic.Int64
@@ -29,9 +40,29 @@ var (
 	usageAlertThreshold          atomic.Int64 // default 0 means disabled
 	usageAlertWhitelist          atomic.Value
 	usageAlertMinAvgThreshold    atomic.Int64 // 前三天平均用量最低阈值，default 0 means no limit
+	geoRoutingRules              atomic.Value // client country or continent code -> rule
+	sseEventNames                atomic.Value // rendered sse event name -> emitted event name
+
+	// logDetailStreamTranscriptMaxSize caps the reassembled text of the
+	// streamed responses, default 0 disables the transcripts
+	logDetailStreamTranscriptMaxSize atomic.Int64
 
 	defaultWarnNotifyErrorRate uint64 = math.Float64bits(0.5)
 
+	// circuit breaker defaults, zero error rate and latency slo disable the breaker
+	circuitBreakerErrorRate    uint64 = math.Float64bits(0)
+	circuitBreakerSlowRate     uint64 = math.Float64bits(0.5)
+	circuitBreakerLatencySLOMs atomic.Int64
+	circuitBreakerOpenSeconds  atomic.Int64
+
+	// hedgeSurchargeRatio is added to the amount of the hedged requests
+	hedgeSurchargeRatio uint64 = math.Float64bits(0)
+
+	// conversionDebugSampleRate is the share of the requests whose bodies
+	// before and after the conversion are logged, default 0 disables it
+	conversionDebugSampleRate   uint64 = math.Float64bits(0)
+	conversionDebugMaxPerMinute atomic.Int64
+
 	defaultHost    atomic.Value
 	defaultMCPHost atomic.Value
 	publicMCPHost  atomic.Value
@@ -45,10 +76,16 @@ var (
 )
 
 func init() {
+	idempotencyKeyTTLSeconds.Store(24 * 60 * 60)
+	liveMaxSessionSeconds.Store(30 * 60)
+	fairQueueTimeoutSeconds.Store(30)
+	conversionDebugMaxPerMinute.Store(60)
 	defaultChannelModels.Store(make(map[int][]string))
 	defaultChannelModelMapping.Store(make(map[int]map[string]string))
 	groupConsumeLevelRatio.Store(make(map[float64]float64))
 	usageAlertWhitelist.Store(make([]string, 0))
+	geoRoutingRules.Store(make(map[string]GeoRoutingRule))
+	sseEventNames.Store(make(map[string]string))
 	notifyNote.Store("")
 	defaultHost.Store("")
 	defaultMCPHost.Store("")
@@ -65,6 +102,117 @@ func SetRetryTimes(times int64) {
 	retryTimes.Store(times)
 }
 
+// GetClientAbortGraceSeconds returns how long the upstream stream keeps running
+// after the client disconnected, so the upstream usage can still arrive, the
+// non-stream requests always run to the end
+func GetClientAbortGraceSeconds() int64 {
+	return clientAbortGraceSeconds.Load()
+}
+
+func SetClientAbortGraceSeconds(seconds int64) {
+	seconds = env.Int64("CLIENT_ABORT_GRACE_SECONDS", seconds)
+	clientAbortGraceSeconds.Store(seconds)
+}
+
+// GetIdempotencyKeyTTLSeconds returns how long the response of a request with
+// an Idempotency-Key header is replayed to the duplicate requests
+func GetIdempotencyKeyTTLSeconds() int64 {
+	return idempotencyKeyTTLSeconds.Load()
+}
+
+func SetIdempotencyKeyTTLSeconds(seconds int64) {
+	seconds = env.Int64("IDEMPOTENCY_KEY_TTL_SECONDS", seconds)
+	idempotencyKeyTTLSeconds.Store(seconds)
+}
+
+// GetLiveMaxSessionSeconds returns how long a realtime websocket session, e.g.
+// a gemini live session, may last before it is closed
+func GetLiveMaxSessionSeconds() int64 {
+	return liveMaxSessionSeconds.Load()
+}
+
+func SetLiveMaxSessionSeconds(seconds int64) {
+	seconds = env.Int64("LIVE_MAX_SESSION_SECONDS", seconds)
+	liveMaxSessionSeconds.Store(seconds)
+}
+
+// GetArchivePurgeHours returns how long the deleted channels and tokens are
+// archived before they are purged, the logs keep referring to them meanwhile
+func GetArchivePurgeHours() int64 {
+	return archivePurgeHours.Load()
+}
+
+func SetArchivePurgeHours(hours int64) {
+	hours = env.Int64("ARCHIVE_PURGE_HOURS", hours)
+	archivePurgeHours.Store(hours)
+}
+
+// GetAuditLogStorageHours returns how long the audit logs of the admin api are
+// kept, 0 keeps them for good
+func GetAuditLogStorageHours() int64 {
+	return auditLogStorageHours.Load()
+}
+
+func SetAuditLogStorageHours(hours int64) {
+	hours = env.Int64("AUDIT_LOG_STORAGE_HOURS", hours)
+	auditLogStorageHours.Store(hours)
+}
+
+// GetSummaryMinuteStorageHours returns how long the minute summaries are kept,
+// the hourly summaries cover the older ranges
+func GetSummaryMinuteStorageHours() int64 {
+	return summaryMinuteStorageHours.Load()
+}
+
+func SetSummaryMinuteStorageHours(hours int64) {
+	hours = env.Int64("SUMMARY_MINUTE_STORAGE_HOURS", hours)
+	summaryMinuteStorageHours.Store(hours)
+}
+
+// GetSummaryHourStorageHours returns how long the hourly summaries are kept,
+// the older ranges are read from the daily rollups
+func GetSummaryHourStorageHours() int64 {
+	return summaryHourStorageHours.Load()
+}
+
+func SetSummaryHourStorageHours(hours int64) {
+	hours = env.Int64("SUMMARY_HOUR_STORAGE_HOURS", hours)
+	summaryHourStorageHours.Store(hours)
+}
+
+// GetSummaryDayStorageHours returns how long the daily rollups are kept, the
+// older ranges are read from the monthly rollups
+func GetSummaryDayStorageHours() int64 {
+	return summaryDayStorageHours.Load()
+}
+
+func SetSummaryDayStorageHours(hours int64) {
+	hours = env.Int64("SUMMARY_DAY_STORAGE_HOURS", hours)
+	summaryDayStorageHours.Store(hours)
+}
+
+// GetFairQueueMaxConcurrency returns the in-flight relay requests of this
+// instance, the requests over it are admitted by the fair share of the groups
+func GetFairQueueMaxConcurrency() int64 {
+	return fairQueueMaxConcurrency.Load()
+}
+
+func SetFairQueueMaxConcurrency(concurrency int64) {
+	concurrency = env.Int64("FAIR_QUEUE_MAX_CONCURRENCY", concurrency)
+	fairQueueMaxConcurrency.Store(concurrency)
+}
+
+// GetFairQueueTimeoutSeconds returns how long a request waits for the
+// admission before it is rejected
+func GetFairQueueTimeoutSeconds() int64 {
+	return fairQueueTimeoutSeconds.Load()
+}
+
+func SetFairQueueTimeoutSeconds(seconds int64) {
+	seconds = env.Int64("FAIR_QUEUE_TIMEOUT_SECONDS", seconds)
+	fairQueueTimeoutSeconds.Store(seconds)
+}
+
 func GetLogStorageHours() int64 {
 	return logStorageHours.Load()
 }
@@ -146,6 +294,15 @@ func SetLogDetailResponseBodyMaxSize(size int64) {
 	atomic.StoreInt64(&logDetailResponseBodyMaxSize, size)
 }
 
+func GetLogDetailStreamTranscriptMaxSize() int64 {
+	return logDetailStreamTranscriptMaxSize.Load()
+}
+
+func SetLogDetailStreamTranscriptMaxSize(size int64) {
+	size = env.Int64("LOG_DETAIL_STREAM_TRANSCRIPT_MAX_SIZE", size)
+	logDetailStreamTranscriptMaxSize.Store(size)
+}
+
 func GetDisableServe() bool {
 	return disableServe.Load()
 }
@@ -279,6 +436,69 @@ func SetDefaultWarnNotifyErrorRate(rate float64) {
 	atomic.StoreUint64(&defaultWarnNotifyErrorRate, math.Float64bits(rate))
 }
 
+func GetCircuitBreakerErrorRate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&circuitBreakerErrorRate))
+}
+
+func SetCircuitBreakerErrorRate(rate float64) {
+	rate = env.Float64("CIRCUIT_BREAKER_ERROR_RATE", rate)
+	atomic.StoreUint64(&circuitBreakerErrorRate, math.Float64bits(rate))
+}
+
+func GetHedgeSurchargeRatio() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&hedgeSurchargeRatio))
+}
+
+func SetHedgeSurchargeRatio(ratio float64) {
+	ratio = env.Float64("HEDGE_SURCHARGE_RATIO", ratio)
+	atomic.StoreUint64(&hedgeSurchargeRatio, math.Float64bits(ratio))
+}
+
+func GetConversionDebugSampleRate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&conversionDebugSampleRate))
+}
+
+func SetConversionDebugSampleRate(rate float64) {
+	rate = env.Float64("CONVERSION_DEBUG_SAMPLE_RATE", rate)
+	atomic.StoreUint64(&conversionDebugSampleRate, math.Float64bits(rate))
+}
+
+func GetConversionDebugMaxPerMinute() int64 {
+	return conversionDebugMaxPerMinute.Load()
+}
+
+func SetConversionDebugMaxPerMinute(limit int64) {
+	limit = env.Int64("CONVERSION_DEBUG_MAX_PER_MINUTE", limit)
+	conversionDebugMaxPerMinute.Store(limit)
+}
+
+func GetCircuitBreakerSlowRate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&circuitBreakerSlowRate))
+}
+
+func SetCircuitBreakerSlowRate(rate float64) {
+	rate = env.Float64("CIRCUIT_BREAKER_SLOW_RATE", rate)
+	atomic.StoreUint64(&circuitBreakerSlowRate, math.Float64bits(rate))
+}
+
+func GetCircuitBreakerLatencySLOMs() int64 {
+	return circuitBreakerLatencySLOMs.Load()
+}
+
+func SetCircuitBreakerLatencySLOMs(ms int64) {
+	ms = env.Int64("CIRCUIT_BREAKER_LATENCY_SLO_MS", ms)
+	circuitBreakerLatencySLOMs.Store(ms)
+}
+
+func GetCircuitBreakerOpenSeconds() int64 {
+	return circuitBreakerOpenSeconds.Load()
+}
+
+func SetCircuitBreakerOpenSeconds(seconds int64) {
+	seconds = env.Int64("CIRCUIT_BREAKER_OPEN_SECONDS", seconds)
+	circuitBreakerOpenSeconds.Store(seconds)
+}
+
 func GetUsageAlertThreshold() int64 {
 	return usageAlertThreshold.Load()
 }
@@ -298,6 +518,45 @@ func SetUsageAlertWhitelist(whitelist []string) {
 	usageAlertWhitelist.Store(whitelist)
 }
 
+// GeoRoutingRule routes the clients of a region to the channels of the given regions
+type GeoRoutingRule struct {
+	ChannelRegions []string `json:"channel_regions"`
+	// Strict only allows the channel regions, otherwise they are only preferred
+	Strict bool `json:"strict,omitempty"`
+}
+
+func GetGeoRoutingRules() map[string]GeoRoutingRule {
+	r, _ := geoRoutingRules.Load().(map[string]GeoRoutingRule)
+	return r
+}
+
+func SetGeoRoutingRules(rules map[string]GeoRoutingRule) {
+	rules = env.JSON("GEO_ROUTING_RULES", rules)
+
+	normalized := make(map[string]GeoRoutingRule, len(rules))
+	for region, rule := range rules {
+		normalized[strings.ToUpper(region)] = rule
+	}
+
+	geoRoutingRules.Store(normalized)
+}
+
+// GetSSEEventNames returns the event names emitted instead of the rendered
+// ones, the events passed through from the same protocol upstream keep their names
+func GetSSEEventNames() map[string]string {
+	n, _ := sseEventNames.Load().(map[string]string)
+	return n
+}
+
+func SetSSEEventNames(names map[string]string) {
+	names = env.JSON("SSE_EVENT_NAMES", names)
+	if names == nil {
+		names = make(map[string]string)
+	}
+
+	sseEventNames.Store(names)
+}
+
 func GetUsageAlertMinAvgThreshold() int64 {
 	return usageAlertMinAvgThreshold.Load()
 }
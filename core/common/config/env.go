@@ -19,13 +19,82 @@ var (
 	InternalToken        string
 	DisableModelConfig   bool
 	Redis                string
-	RedisKeyPrefix       string
-	ConfigFilePath       string
+	// RedisStandby is the warm standby redis used while the primary is down
+	RedisStandby string
+	// RedisKeyPrefix namespaces all the redis keys, so several environments
+	// can share one redis
+	RedisKeyPrefix string
+	// RedisRateLimitDB, RedisCacheDB and RedisIPBlackDB move the rate limit,
+	// cache and ip black list keys to a separate logical db with its own
+	// connection pool, a negative value shares the main client
+	RedisRateLimitDB int64
+	RedisCacheDB     int64
+	RedisIPBlackDB   int64
+	ConfigFilePath   string
+	// TokenizerApproximate counts the tokens without loading the tiktoken
+	// vocabularies, the usage counted by the proxy is then estimated
+	TokenizerApproximate bool
+	// SummaryBatchMaxPending bounds the summary updates pending in memory, a
+	// flush is triggered once it is reached and the failed updates beyond it
+	// are spilled to SummarySpillDir
+	SummaryBatchMaxPending int64
+	// SummarySpillDir keeps the summary updates that could not be written to
+	// the database, they are restored on the next start, empty disables it
+	SummarySpillDir string
+	// ShutdownDrainTimeoutSeconds is how long the shutdown waits for the
+	// in-flight relay requests, ShutdownDrainModeTimeouts overrides it by mode
+	// name, the requests still running after it are canceled
+	ShutdownDrainTimeoutSeconds int64
+	ShutdownDrainModeTimeouts   map[string]int64
+	// ShutdownRetryAfterSeconds is the Retry-After of the requests rejected
+	// while draining
+	ShutdownRetryAfterSeconds int64
+	// MediaStorageDir keeps the generated images and audio served by signed
+	// urls, empty disables it
+	MediaStorageDir string
+	// MediaURLTTLSeconds is how long the signed media urls are valid, the
+	// files are removed once it has passed
+	MediaURLTTLSeconds int64
+	// MediaSigningKey signs the media urls, the admin key by default
+	MediaSigningKey string
+	// MediaPublicURL is the base url of the signed media urls, the host of
+	// the request by default
+	MediaPublicURL string
+	// ConfigSyncURL is polled for the desired state of the channels, model
+	// configs and tokens, empty disables the polling
+	ConfigSyncURL string
+	// ConfigSyncAuthorization is the Authorization header of the polling
+	ConfigSyncAuthorization   string
+	ConfigSyncIntervalSeconds int64
+	// ConversionDebugLogFile receives the sampled bodies of the requests
+	// before and after the conversion as json lines, empty disables the
+	// conversion debug
+	ConversionDebugLogFile string
+	// ConversionDebugLogContent keeps the message content in the logged
+	// bodies, only their structure is logged by default
+	ConversionDebugLogContent bool
+	// TrustedProxies are the proxy ips or cidrs whose X-Forwarded-For and
+	// X-Real-IP headers are used as the client ip, every peer is trusted when
+	// it is empty except by the geoip which locates the peer address then
+	TrustedProxies []string
 
 	// OnCall Lark configuration for urgent alerts
 	OnCallLarkAppID     string
 	OnCallLarkAppSecret string
 	OnCallLarkOpenIDs   []string // comma-separated open IDs
+
+	// OIDC single sign-on for the admin api and dashboard
+	OIDCIssuer            string
+	OIDCClientID          string
+	OIDCClientSecret      string
+	OIDCRedirectURL       string
+	OIDCScopes            []string
+	OIDCGroupsClaim       string
+	OIDCRoleMapping       map[string]string // idp group -> aiproxy role
+	OIDCDefaultRole       string
+	OIDCPostLoginRedirect string
+	AdminJWTSecret        string
+	AdminJWTTTLSeconds    int64
 )
 
 func ReloadEnv() {
@@ -40,13 +109,52 @@ func ReloadEnv() {
 	InternalToken = os.Getenv("INTERNAL_TOKEN")
 	DisableModelConfig = env.Bool("DISABLE_MODEL_CONFIG", false)
 	Redis = env.String("REDIS", os.Getenv("REDIS_CONN_STRING"))
-	RedisKeyPrefix = os.Getenv("REDIS_KEY_PREFIX")
+	RedisStandby = os.Getenv("REDIS_STANDBY")
+	RedisKeyPrefix = env.String("REDIS_KEY_PREFIX", os.Getenv("REDIS_NAMESPACE"))
+	RedisRateLimitDB = env.Int64("REDIS_RATE_LIMIT_DB", -1)
+	RedisCacheDB = env.Int64("REDIS_CACHE_DB", -1)
+	RedisIPBlackDB = env.Int64("REDIS_IP_BLACK_DB", -1)
 	ConfigFilePath = env.String("CONFIG_FILE_PATH", "./config.yaml")
+	TokenizerApproximate = env.String("TOKENIZER_MODE", "exact") == "approximate"
+	SummaryBatchMaxPending = env.Int64("SUMMARY_BATCH_MAX_PENDING", 100000)
+	SummarySpillDir = os.Getenv("SUMMARY_SPILL_DIR")
+	ShutdownDrainTimeoutSeconds = env.Int64("SHUTDOWN_DRAIN_TIMEOUT_SECONDS", 600)
+	ShutdownDrainModeTimeouts = env.JSON[map[string]int64]("SHUTDOWN_DRAIN_MODE_TIMEOUTS", nil)
+	ShutdownRetryAfterSeconds = env.Int64("SHUTDOWN_RETRY_AFTER_SECONDS", 5)
+	MediaStorageDir = os.Getenv("MEDIA_STORAGE_DIR")
+	MediaURLTTLSeconds = env.Int64("MEDIA_URL_TTL_SECONDS", 3600)
+	MediaSigningKey = env.String("MEDIA_SIGNING_KEY", AdminKey)
+	MediaPublicURL = strings.TrimSuffix(os.Getenv("MEDIA_PUBLIC_URL"), "/")
+	ConfigSyncURL = os.Getenv("CONFIG_SYNC_URL")
+	ConfigSyncAuthorization = os.Getenv("CONFIG_SYNC_AUTHORIZATION")
+	ConfigSyncIntervalSeconds = env.Int64("CONFIG_SYNC_INTERVAL_SECONDS", 60)
+	ConversionDebugLogFile = os.Getenv("CONVERSION_DEBUG_LOG_FILE")
+	ConversionDebugLogContent = env.Bool("CONVERSION_DEBUG_LOG_CONTENT", false)
+	TrustedProxies = strings.FieldsFunc(os.Getenv("TRUSTED_PROXIES"), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
 
 	// OnCall Lark configuration
 	OnCallLarkAppID = os.Getenv("ON_CALL_LARK_APP_ID")
 	OnCallLarkAppSecret = os.Getenv("ON_CALL_LARK_APP_SECRET")
 	OnCallLarkOpenIDs = parseOpenIDs(os.Getenv("ON_CALL_LARK_OPEN_ID"))
+
+	// OIDC configuration
+	OIDCIssuer = strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/")
+	OIDCClientID = os.Getenv("OIDC_CLIENT_ID")
+	OIDCClientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+	OIDCRedirectURL = os.Getenv("OIDC_REDIRECT_URL")
+	OIDCScopes = strings.Fields(env.String("OIDC_SCOPES", "openid profile email groups"))
+	OIDCGroupsClaim = env.String("OIDC_GROUPS_CLAIM", "groups")
+	OIDCRoleMapping = env.JSON[map[string]string]("OIDC_ROLE_MAPPING", nil)
+	OIDCDefaultRole = os.Getenv("OIDC_DEFAULT_ROLE")
+	OIDCPostLoginRedirect = os.Getenv("OIDC_POST_LOGIN_REDIRECT")
+	AdminJWTSecret = os.Getenv("ADMIN_JWT_SECRET")
+	AdminJWTTTLSeconds = env.Int64("ADMIN_JWT_TTL_SECONDS", 3600)
+}
+
+func OIDCEnabled() bool {
+	return OIDCIssuer != "" && OIDCClientID != "" && OIDCRedirectURL != ""
 }
 
 // parseOpenIDs parses comma-separated open IDs
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/labring/aiproxy/core/common/env"
+)
+
+// the feature flags gating the conversion behaviors, a flag without config
+// uses its default
+const (
+	// FeatureGeminiSystemParts keeps every system message part in the gemini
+	// system instruction instead of only the last system message
+	FeatureGeminiSystemParts = "gemini_system_parts"
+)
+
+var featureFlagDefaults = map[string]bool{
+	FeatureGeminiSystemParts: true,
+}
+
+// FeatureFlag rolls a conversion behavior out to a percentage of the requests,
+// setting the percentage to 0 rolls it back at once
+type FeatureFlag struct {
+	// Percentage of the requests the flag is on for, from 0 to 100
+	Percentage float64 `json:"percentage"`
+	// GroupPercentages overrides the percentage for the groups
+	GroupPercentages map[string]float64 `json:"group_percentages,omitempty"`
+	// AllowHeader lets the requests turn the flag on or off with the feature
+	// flags header
+	AllowHeader bool `json:"allow_header,omitempty"`
+}
+
+// GetFeatureFlagDefaults returns the known feature flags and their defaults
+func GetFeatureFlagDefaults() map[string]bool {
+	return featureFlagDefaults
+}
+
+func ValidateFeatureFlags(flags map[string]FeatureFlag) error {
+	for name, flag := range flags {
+		if _, ok := featureFlagDefaults[name]; !ok {
+			return fmt.Errorf("unknown feature flag: %s", name)
+		}
+
+		if flag.Percentage < 0 || flag.Percentage > 100 {
+			return fmt.Errorf("feature flag %s: percentage must be between 0 and 100", name)
+		}
+
+		for group, percentage := range flag.GroupPercentages {
+			if percentage < 0 || percentage > 100 {
+				return fmt.Errorf(
+					"feature flag %s: percentage of group %s must be between 0 and 100",
+					name,
+					group,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+var featureFlags atomic.Value
+
+func init() {
+	featureFlags.Store(make(map[string]FeatureFlag))
+}
+
+func GetFeatureFlags() map[string]FeatureFlag {
+	f, _ := featureFlags.Load().(map[string]FeatureFlag)
+	return f
+}
+
+func SetFeatureFlags(flags map[string]FeatureFlag) {
+	flags = env.JSON("FEATURE_FLAGS", flags)
+	if flags == nil {
+		flags = make(map[string]FeatureFlag)
+	}
+
+	featureFlags.Store(flags)
+}
+
+// ResolveFeatureFlags returns the sorted feature flags on for the request, the
+// request falls in the percentage by its id so the retries keep the same
+// flags, the header lists the flags to turn on and the ones prefixed with -
+// to turn off, it is only honored by the flags allowing it
+func ResolveFeatureFlags(group, requestID, header string) []string {
+	flags := GetFeatureFlags()
+
+	overrides := make(map[string]bool)
+	for item := range strings.SplitSeq(header, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, off := strings.CutPrefix(item, "-")
+		overrides[name] = !off
+	}
+
+	enabled := make([]string, 0, len(featureFlagDefaults))
+	for name, defaultOn := range featureFlagDefaults {
+		flag, ok := flags[name]
+		if !ok {
+			if defaultOn {
+				enabled = append(enabled, name)
+			}
+
+			continue
+		}
+
+		if on, ok := overrides[name]; ok && flag.AllowHeader {
+			if on {
+				enabled = append(enabled, name)
+			}
+
+			continue
+		}
+
+		percentage := flag.Percentage
+		if p, ok := flag.GroupPercentages[group]; ok {
+			percentage = p
+		}
+
+		if featureFlagBucket(name, requestID) < percentage {
+			enabled = append(enabled, name)
+		}
+	}
+
+	slices.Sort(enabled)
+
+	return enabled
+}
+
+// featureFlagBucket places the request of the flag in [0, 100)
+func featureFlagBucket(name, requestID string) float64 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	_, _ = h.Write([]byte{':'})
+	_, _ = h.Write([]byte(requestID))
+
+	return float64(h.Sum32()%10000) / 100
+}
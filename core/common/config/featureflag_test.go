@@ -0,0 +1,92 @@
+package config_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/stretchr/testify/require"
+)
+
+func setFeatureFlags(t *testing.T, flags map[string]config.FeatureFlag) {
+	t.Helper()
+	t.Setenv("FEATURE_FLAGS", "")
+
+	old := config.GetFeatureFlags()
+	t.Cleanup(func() {
+		config.SetFeatureFlags(old)
+	})
+
+	config.SetFeatureFlags(flags)
+}
+
+func TestResolveFeatureFlagsDefaults(t *testing.T) {
+	setFeatureFlags(t, nil)
+
+	require.Equal(
+		t,
+		[]string{config.FeatureGeminiSystemParts},
+		config.ResolveFeatureFlags("group", "request", ""),
+	)
+}
+
+func TestResolveFeatureFlagsPercentage(t *testing.T) {
+	setFeatureFlags(t, map[string]config.FeatureFlag{
+		config.FeatureGeminiSystemParts: {
+			Percentage:       0,
+			GroupPercentages: map[string]float64{"canary": 50},
+		},
+	})
+
+	enabled := 0
+
+	for i := range 1000 {
+		requestID := fmt.Sprintf("request-%d", i)
+		require.Empty(t, config.ResolveFeatureFlags("group", requestID, ""))
+
+		flags := config.ResolveFeatureFlags("canary", requestID, "")
+		if len(flags) > 0 {
+			enabled++
+		}
+
+		require.Equal(t, flags, config.ResolveFeatureFlags("canary", requestID, ""))
+	}
+
+	require.InDelta(t, 500, enabled, 100)
+}
+
+func TestResolveFeatureFlagsHeader(t *testing.T) {
+	setFeatureFlags(t, map[string]config.FeatureFlag{
+		config.FeatureGeminiSystemParts: {Percentage: 100},
+	})
+
+	require.Equal(
+		t,
+		[]string{config.FeatureGeminiSystemParts},
+		config.ResolveFeatureFlags("group", "request", "-"+config.FeatureGeminiSystemParts),
+	)
+
+	setFeatureFlags(t, map[string]config.FeatureFlag{
+		config.FeatureGeminiSystemParts: {Percentage: 100, AllowHeader: true},
+	})
+
+	require.Empty(
+		t,
+		config.ResolveFeatureFlags("group", "request", " -"+config.FeatureGeminiSystemParts),
+	)
+}
+
+func TestValidateFeatureFlags(t *testing.T) {
+	require.NoError(t, config.ValidateFeatureFlags(map[string]config.FeatureFlag{
+		config.FeatureGeminiSystemParts: {Percentage: 10},
+	}))
+	require.Error(t, config.ValidateFeatureFlags(map[string]config.FeatureFlag{
+		"unknown": {Percentage: 10},
+	}))
+	require.Error(t, config.ValidateFeatureFlags(map[string]config.FeatureFlag{
+		config.FeatureGeminiSystemParts: {Percentage: 101},
+	}))
+	require.Error(t, config.ValidateFeatureFlags(map[string]config.FeatureFlag{
+		config.FeatureGeminiSystemParts: {GroupPercentages: map[string]float64{"g": -1}},
+	}))
+}
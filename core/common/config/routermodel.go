@@ -0,0 +1,190 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/google/cel-go/cel"
+	"github.com/labring/aiproxy/core/common/celexpr"
+	"github.com/labring/aiproxy/core/common/env"
+	log "github.com/sirupsen/logrus"
+)
+
+// the variables of the router rule conditions, taken from the request
+const (
+	// RouterVarPromptLength is the characters of the prompt text
+	RouterVarPromptLength = "prompt_length"
+	// RouterVarMessageCount is the messages of the conversation
+	RouterVarMessageCount = "message_count"
+	// RouterVarMaxTokens is the requested max output tokens, 0 without one
+	RouterVarMaxTokens = "max_tokens"
+	// RouterVarHasImages is true when the prompt contains an image
+	RouterVarHasImages = "has_images"
+	// RouterVarHasTools is true when the request declares tools
+	RouterVarHasTools = "has_tools"
+	// RouterVarLanguage is the dominant language of the prompt text, e.g. en,
+	// zh, ja, ko, ru or ar, empty when it is unknown
+	RouterVarLanguage = "language"
+	// RouterVarMode is the relay mode of the request, e.g. ChatCompletions
+	RouterVarMode = "mode"
+	// RouterVarGroup is the group of the request
+	RouterVarGroup = "group"
+)
+
+var routerVars = map[string]*cel.Type{
+	RouterVarPromptLength: cel.IntType,
+	RouterVarMessageCount: cel.IntType,
+	RouterVarMaxTokens:    cel.IntType,
+	RouterVarHasImages:    cel.BoolType,
+	RouterVarHasTools:     cel.BoolType,
+	RouterVarLanguage:     cel.StringType,
+	RouterVarMode:         cel.StringType,
+	RouterVarGroup:        cel.StringType,
+}
+
+// RouterRule routes the request to the model when the condition, a cel
+// expression over the request variables, is true
+type RouterRule struct {
+	Condition string `json:"condition"`
+	Model     string `json:"model"`
+
+	program *celexpr.Program
+}
+
+// RouterModel is a virtual model the clients request, the backend is the
+// model of the first matching rule, the model of the prompt language, or the
+// default model
+type RouterModel struct {
+	Rules []RouterRule `json:"rules,omitempty"`
+	// LanguageModels maps the detected prompt language to the backend model,
+	// a shorthand of the rules matching a single language
+	LanguageModels map[string]string `json:"language_models,omitempty"`
+	DefaultModel   string            `json:"default_model"`
+}
+
+// Route returns the backend model of the request variables, the rules failing
+// to evaluate are skipped
+func (m RouterModel) Route(vars map[string]any) string {
+	for _, rule := range m.Rules {
+		if rule.program == nil {
+			continue
+		}
+
+		matched, err := rule.program.EvalBool(vars)
+		if err == nil && matched {
+			return rule.Model
+		}
+	}
+
+	if language, _ := vars[RouterVarLanguage].(string); language != "" {
+		if model, ok := m.LanguageModels[language]; ok {
+			return model
+		}
+	}
+
+	return m.DefaultModel
+}
+
+// compileRouterModels compiles the rule conditions of the router models
+func compileRouterModels(models map[string]RouterModel) (map[string]RouterModel, error) {
+	compiled := make(map[string]RouterModel, len(models))
+
+	for name, m := range models {
+		if name == "" {
+			return nil, errors.New("router model name is required")
+		}
+
+		if m.DefaultModel == "" {
+			return nil, fmt.Errorf("router model %s: default model is required", name)
+		}
+
+		if _, ok := models[m.DefaultModel]; ok {
+			return nil, fmt.Errorf(
+				"router model %s: default model must not be a router model",
+				name,
+			)
+		}
+
+		rules := make([]RouterRule, len(m.Rules))
+		for i, rule := range m.Rules {
+			if rule.Model == "" {
+				return nil, fmt.Errorf("router model %s: rule %d: model is required", name, i)
+			}
+
+			if _, ok := models[rule.Model]; ok {
+				return nil, fmt.Errorf(
+					"router model %s: rule %d: model must not be a router model",
+					name,
+					i,
+				)
+			}
+
+			program, err := celexpr.CompileBool(rule.Condition, routerVars)
+			if err != nil {
+				return nil, fmt.Errorf("router model %s: rule %d: %w", name, i, err)
+			}
+
+			rule.program = program
+			rules[i] = rule
+		}
+
+		for language, model := range m.LanguageModels {
+			if language == "" || model == "" {
+				return nil, fmt.Errorf(
+					"router model %s: language models require the language and the model",
+					name,
+				)
+			}
+
+			if _, ok := models[model]; ok {
+				return nil, fmt.Errorf(
+					"router model %s: language %s: model must not be a router model",
+					name,
+					language,
+				)
+			}
+		}
+
+		m.Rules = rules
+		compiled[name] = m
+	}
+
+	return compiled, nil
+}
+
+func ValidateRouterModels(models map[string]RouterModel) error {
+	_, err := compileRouterModels(models)
+	return err
+}
+
+var routerModels atomic.Value
+
+func init() {
+	routerModels.Store(make(map[string]RouterModel))
+}
+
+func GetRouterModels() map[string]RouterModel {
+	m, _ := routerModels.Load().(map[string]RouterModel)
+	return m
+}
+
+func GetRouterModel(name string) (RouterModel, bool) {
+	m, ok := GetRouterModels()[name]
+	return m, ok
+}
+
+// SetRouterModels compiles and stores the router models, the router models of
+// the env are dropped when they are invalid
+func SetRouterModels(models map[string]RouterModel) {
+	models = env.JSON("ROUTER_MODELS", models)
+
+	compiled, err := compileRouterModels(models)
+	if err != nil {
+		log.Errorf("invalid ROUTER_MODELS: %v", err)
+
+		compiled = make(map[string]RouterModel)
+	}
+
+	routerModels.Store(compiled)
+}
@@ -0,0 +1,133 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouterModelRoute(t *testing.T) {
+	t.Setenv("ROUTER_MODELS", "")
+
+	old := config.GetRouterModels()
+	t.Cleanup(func() {
+		config.SetRouterModels(old)
+	})
+
+	config.SetRouterModels(map[string]config.RouterModel{
+		"auto": {
+			Rules: []config.RouterRule{
+				{Condition: `has_images`, Model: "gpt-4o"},
+				{Condition: `language in ["zh", "ja"]`, Model: "qwen-max"},
+				{Condition: `prompt_length > 8000 || has_tools`, Model: "claude-sonnet-4"},
+			},
+			DefaultModel: "gpt-4o-mini",
+		},
+	})
+
+	router, ok := config.GetRouterModel("auto")
+	require.True(t, ok)
+
+	vars := func(promptLength int, hasImages, hasTools bool, language string) map[string]any {
+		return map[string]any{
+			config.RouterVarPromptLength: promptLength,
+			config.RouterVarMessageCount: 1,
+			config.RouterVarMaxTokens:    0,
+			config.RouterVarHasImages:    hasImages,
+			config.RouterVarHasTools:     hasTools,
+			config.RouterVarLanguage:     language,
+			config.RouterVarMode:         "ChatCompletions",
+			config.RouterVarGroup:        "group",
+		}
+	}
+
+	require.Equal(t, "gpt-4o", router.Route(vars(100, true, true, "zh")))
+	require.Equal(t, "qwen-max", router.Route(vars(100, false, true, "zh")))
+	require.Equal(t, "claude-sonnet-4", router.Route(vars(9000, false, false, "en")))
+	require.Equal(t, "claude-sonnet-4", router.Route(vars(100, false, true, "en")))
+	require.Equal(t, "gpt-4o-mini", router.Route(vars(100, false, false, "en")))
+	// the rules failing to evaluate are skipped
+	require.Equal(t, "gpt-4o-mini", router.Route(map[string]any{}))
+}
+
+func TestRouterModelRouteLanguage(t *testing.T) {
+	t.Setenv("ROUTER_MODELS", "")
+
+	old := config.GetRouterModels()
+	t.Cleanup(func() {
+		config.SetRouterModels(old)
+	})
+
+	config.SetRouterModels(map[string]config.RouterModel{
+		"auto": {
+			Rules: []config.RouterRule{
+				{Condition: `has_images`, Model: "gpt-4o"},
+			},
+			LanguageModels: map[string]string{
+				"zh": "qwen-max",
+				"ko": "glm-4",
+			},
+			DefaultModel: "gpt-4o-mini",
+		},
+	})
+
+	router, ok := config.GetRouterModel("auto")
+	require.True(t, ok)
+
+	vars := func(hasImages bool, language string) map[string]any {
+		return map[string]any{
+			config.RouterVarHasImages: hasImages,
+			config.RouterVarLanguage:  language,
+		}
+	}
+
+	require.Equal(t, "gpt-4o", router.Route(vars(true, "zh")))
+	require.Equal(t, "qwen-max", router.Route(vars(false, "zh")))
+	require.Equal(t, "glm-4", router.Route(vars(false, "ko")))
+	require.Equal(t, "gpt-4o-mini", router.Route(vars(false, "en")))
+	require.Equal(t, "gpt-4o-mini", router.Route(vars(false, "")))
+}
+
+func TestValidateRouterModels(t *testing.T) {
+	require.NoError(t, config.ValidateRouterModels(map[string]config.RouterModel{
+		"auto": {DefaultModel: "gpt-4o-mini"},
+	}))
+
+	for _, models := range []map[string]config.RouterModel{
+		{"auto": {}},
+		{"auto": {
+			Rules:        []config.RouterRule{{Condition: `prompt_tokens > 1`, Model: "gpt-4o"}},
+			DefaultModel: "gpt-4o-mini",
+		}},
+		{"auto": {
+			Rules:        []config.RouterRule{{Condition: `prompt_length + 1`, Model: "gpt-4o"}},
+			DefaultModel: "gpt-4o-mini",
+		}},
+		{"auto": {
+			Rules:        []config.RouterRule{{Condition: `language > 1`, Model: "gpt-4o"}},
+			DefaultModel: "gpt-4o-mini",
+		}},
+		{"auto": {
+			Rules:        []config.RouterRule{{Condition: `has_tools`}},
+			DefaultModel: "gpt-4o-mini",
+		}},
+		{
+			"auto":  {DefaultModel: "auto2"},
+			"auto2": {DefaultModel: "gpt-4o-mini"},
+		},
+		{"auto": {
+			LanguageModels: map[string]string{"zh": ""},
+			DefaultModel:   "gpt-4o-mini",
+		}},
+		{
+			"auto": {
+				LanguageModels: map[string]string{"zh": "auto2"},
+				DefaultModel:   "gpt-4o-mini",
+			},
+			"auto2": {DefaultModel: "gpt-4o-mini"},
+		},
+	} {
+		require.Error(t, config.ValidateRouterModels(models))
+	}
+}
@@ -108,6 +108,7 @@ func Consume(
 			modelPrice,
 			priceSelectionOptions(meta),
 		)
+		amountDetail.UsedAmount = WithHedgeSurcharge(amountDetail.UsedAmount, meta)
 	}
 
 	if downstreamResult {
@@ -171,6 +172,7 @@ func Summary(
 		modelPrice,
 		priceSelectionOptions(meta),
 	)
+	amountDetail.UsedAmount = WithHedgeSurcharge(amountDetail.UsedAmount, meta)
 
 	recordSummary(
 		time.Now(),
@@ -184,6 +186,18 @@ func Summary(
 	)
 }
 
+// WithHedgeSurcharge adds the hedge surcharge of the winning attempt of a
+// hedged request to the amount
+func WithHedgeSurcharge(amount float64, meta *meta.Meta) float64 {
+	if meta == nil || meta.HedgeSurchargeRatio <= 0 {
+		return amount
+	}
+
+	return decimal.NewFromFloat(amount).
+		Mul(decimal.NewFromFloat(1 + meta.HedgeSurchargeRatio)).
+		InexactFloat64()
+}
+
 func checkNeedRecordConsume(code int, meta *meta.Meta) bool {
 	if meta == nil {
 		return true
@@ -199,10 +213,15 @@ func checkNeedRecordConsume(code int, meta *meta.Meta) bool {
 		mode.GeminiVideoOperations,
 		mode.AliVideoTasks,
 		mode.DoubaoVideoTasks,
+		mode.AudioGenerationsGet,
 		mode.ResponsesGet,
 		mode.ResponsesDelete,
 		mode.ResponsesCancel,
-		mode.ResponsesInputItems:
+		mode.ResponsesInputItems,
+		mode.Files,
+		mode.FilesGet,
+		mode.FilesContent,
+		mode.FilesDelete:
 		return code != http.StatusOK
 	case mode.DoubaoVideoTasksDelete:
 		return code != http.StatusOK && code != http.StatusNoContent
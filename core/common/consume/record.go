@@ -1,8 +1,11 @@
 package consume
 
 import (
+	"maps"
+	"strconv"
 	"time"
 
+	"github.com/labring/aiproxy/core/common/tiktoken"
 	"github.com/labring/aiproxy/core/model"
 	"github.com/labring/aiproxy/core/relay/meta"
 )
@@ -39,6 +42,7 @@ func recordConsume(
 		meta.RequestAt,
 		meta.RetryAt,
 		firstByteAt,
+		streamMetrics(meta),
 		meta.Group.ID,
 		code,
 		meta.Channel.ID,
@@ -57,15 +61,54 @@ func recordConsume(
 		modelPrice,
 		amount,
 		meta.User,
-		metadata,
+		documentMetadata(meta, metadata),
 		meta.PromptCacheKey,
 		upstreamID,
+		meta.Seed,
+		meta.SystemFingerprint,
+		meta.ModelConfig.Version,
+		meta.ClientAborted,
+		usageEstimated(meta, usage),
 		asyncUsageStatus,
 		summaryServiceTier,
 		summaryClaudeLongContext,
 	)
 }
 
+func streamMetrics(meta *meta.Meta) model.StreamMetrics {
+	return model.StreamMetrics{
+		UpstreamConnect: meta.UpstreamConnectDuration,
+		StreamDuration:  meta.StreamDuration,
+		StreamChunks:    meta.StreamChunks,
+	}
+}
+
+// documentMetadata adds the number of the pdf pages sent in the request to the
+// log metadata
+func documentMetadata(meta *meta.Meta, metadata map[string]string) map[string]string {
+	if meta.DocumentPages <= 0 {
+		return metadata
+	}
+
+	metadata = maps.Clone(metadata)
+	if metadata == nil {
+		metadata = make(map[string]string, 1)
+	}
+
+	metadata["pdf_pages"] = strconv.FormatInt(meta.DocumentPages, 10)
+
+	return metadata
+}
+
+// usageEstimated reports whether the usage is the request usage counted by the
+// approximate tokenizer, the handlers fall back to it when the upstream reports
+// no usage
+func usageEstimated(meta *meta.Meta, usage model.Usage) bool {
+	return tiktoken.Approximate() &&
+		meta.RequestUsage.InputTokens > 0 &&
+		usage.InputTokens == meta.RequestUsage.InputTokens
+}
+
 func recordSummary(
 	now time.Time,
 	meta *meta.Meta,
@@ -87,6 +130,7 @@ func recordSummary(
 		now,
 		meta.RequestAt,
 		firstByteAt,
+		streamMetrics(meta),
 		meta.Group.ID,
 		code,
 		meta.Channel.ID,
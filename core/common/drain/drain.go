@@ -0,0 +1,214 @@
+// Package drain tracks the in-flight relay requests by mode so the shutdown
+// can wait for them, the requests still running once the drain deadline of
+// their mode is over are canceled
+package drain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Tracker counts the in-flight requests of the modes
+type Tracker struct {
+	mu        sync.Mutex
+	draining  bool
+	startedAt time.Time
+	seq       uint64
+	inFlight  map[string]map[uint64]context.CancelFunc
+	changed   chan struct{}
+}
+
+func New() *Tracker {
+	return &Tracker{
+		inFlight: make(map[string]map[uint64]context.CancelFunc),
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+// Track records the request of the mode, the returned context is canceled when
+// the request overruns the drain deadline of the mode and the returned func
+// must be called once the request is done
+func (t *Tracker) Track(ctx context.Context, mode string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	t.mu.Lock()
+	t.seq++
+	id := t.seq
+
+	requests, ok := t.inFlight[mode]
+	if !ok {
+		requests = make(map[uint64]context.CancelFunc)
+		t.inFlight[mode] = requests
+	}
+
+	requests[id] = cancel
+	t.mu.Unlock()
+
+	var once sync.Once
+
+	return ctx, func() {
+		once.Do(func() {
+			cancel()
+
+			t.mu.Lock()
+			delete(requests, id)
+			t.mu.Unlock()
+
+			select {
+			case t.changed <- struct{}{}:
+			default:
+			}
+		})
+	}
+}
+
+// StartDraining marks the tracker as draining, false is returned when it is
+// already draining
+func (t *Tracker) StartDraining() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.draining {
+		return false
+	}
+
+	t.draining = true
+	t.startedAt = time.Now()
+
+	return true
+}
+
+// Draining reports whether the tracker is draining
+func (t *Tracker) Draining() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.draining
+}
+
+// Expired reports whether the drain deadline of the mode is over, the new
+// requests of the mode are rejected then
+func (t *Tracker) Expired(deadline time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.draining && time.Since(t.startedAt) >= deadline
+}
+
+// Drain waits for the in-flight requests to finish, the requests of a mode are
+// canceled once the deadline of the mode is over, it returns when no request
+// is in flight or the context is done
+func (t *Tracker) Drain(
+	ctx context.Context,
+	deadline func(mode string) time.Duration,
+	interval time.Duration,
+) {
+	t.StartDraining()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last map[string]int
+
+	for {
+		counts := t.cancelExpired(deadline)
+		if len(counts) == 0 {
+			log.Info("all in-flight requests drained")
+			return
+		}
+
+		if !equalCounts(last, counts) {
+			log.Infof("waiting for in-flight requests: %v", counts)
+			last = counts
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Warnf("drain deadline exceeded, in-flight requests: %v", counts)
+			return
+		case <-ticker.C:
+		case <-t.changed:
+		}
+	}
+}
+
+// cancelExpired cancels the requests of the modes whose deadline is over and
+// returns the in-flight requests of the modes
+func (t *Tracker) cancelExpired(deadline func(mode string) time.Duration) map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	elapsed := time.Since(t.startedAt)
+
+	counts := make(map[string]int, len(t.inFlight))
+	for mode, requests := range t.inFlight {
+		if len(requests) == 0 {
+			continue
+		}
+
+		counts[mode] = len(requests)
+
+		if elapsed < deadline(mode) {
+			continue
+		}
+
+		log.Warnf("drain deadline of %s exceeded, canceling %d requests", mode, len(requests))
+
+		for _, cancel := range requests {
+			cancel()
+		}
+	}
+
+	return counts
+}
+
+func equalCounts(a, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Stats is the drain state of this instance
+type Stats struct {
+	Draining bool `json:"draining"`
+	// DrainingSeconds is how long the instance has been draining
+	DrainingSeconds float64        `json:"draining_seconds,omitempty"`
+	InFlight        map[string]int `json:"in_flight"`
+	Total           int            `json:"total"`
+}
+
+func (t *Tracker) Stats() Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := Stats{
+		Draining: t.draining,
+		InFlight: make(map[string]int, len(t.inFlight)),
+	}
+
+	if t.draining {
+		stats.DrainingSeconds = time.Since(t.startedAt).Seconds()
+	}
+
+	for mode, requests := range t.inFlight {
+		if len(requests) == 0 {
+			continue
+		}
+
+		stats.InFlight[mode] = len(requests)
+		stats.Total += len(requests)
+	}
+
+	return stats
+}
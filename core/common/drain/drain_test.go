@@ -0,0 +1,83 @@
+package drain_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/drain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerStats(t *testing.T) {
+	tracker := drain.New()
+
+	_, doneChat := tracker.Track(t.Context(), "ChatCompletions")
+	_, doneEmbeddings := tracker.Track(t.Context(), "Embeddings")
+	_, doneChat2 := tracker.Track(t.Context(), "ChatCompletions")
+
+	stats := tracker.Stats()
+	require.False(t, stats.Draining)
+	require.Equal(t, 3, stats.Total)
+	require.Equal(t, map[string]int{"ChatCompletions": 2, "Embeddings": 1}, stats.InFlight)
+
+	doneChat()
+	doneChat()
+	doneEmbeddings()
+
+	stats = tracker.Stats()
+	require.Equal(t, 1, stats.Total)
+	require.Equal(t, map[string]int{"ChatCompletions": 1}, stats.InFlight)
+
+	doneChat2()
+	require.Equal(t, 0, tracker.Stats().Total)
+}
+
+func TestTrackerDrainWaitsForRequests(t *testing.T) {
+	tracker := drain.New()
+
+	_, done := tracker.Track(t.Context(), "ChatCompletions")
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		done()
+	}()
+
+	start := time.Now()
+	tracker.Drain(t.Context(), func(string) time.Duration { return time.Minute }, time.Second)
+
+	require.Less(t, time.Since(start), time.Second)
+	require.True(t, tracker.Draining())
+	require.False(t, tracker.StartDraining())
+}
+
+func TestTrackerDrainCancelsExpiredModes(t *testing.T) {
+	tracker := drain.New()
+
+	embeddingsCtx, doneEmbeddings := tracker.Track(t.Context(), "Embeddings")
+	chatCtx, doneChat := tracker.Track(t.Context(), "ChatCompletions")
+
+	go func() {
+		<-embeddingsCtx.Done()
+		doneEmbeddings()
+		time.Sleep(20 * time.Millisecond)
+		doneChat()
+	}()
+
+	deadline := func(mode string) time.Duration {
+		if mode == "Embeddings" {
+			return 0
+		}
+
+		return time.Minute
+	}
+
+	tracker.Drain(t.Context(), deadline, 10*time.Millisecond)
+
+	require.ErrorIs(t, embeddingsCtx.Err(), context.Canceled)
+	require.Equal(t, 0, tracker.Stats().Total)
+	require.True(t, tracker.Expired(0))
+	require.False(t, tracker.Expired(time.Minute))
+
+	<-chatCtx.Done()
+}
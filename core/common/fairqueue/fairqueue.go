@@ -0,0 +1,209 @@
+// Package fairqueue admits the requests of the groups by weighted fair
+// queuing, the groups waiting for a slot are served in the order of their
+// virtual finish time so a bursty group can not monopolize the slots
+package fairqueue
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// Scheduler limits the in-flight requests, the requests over the capacity wait
+// in the queue of their group
+type Scheduler struct {
+	mu          sync.Mutex
+	inFlight    int
+	waiting     int
+	virtualTime float64
+	seq         uint64
+	groups      map[string]*groupQueue
+}
+
+type groupQueue struct {
+	lastFinish float64
+	waiters    *list.List
+}
+
+type waiter struct {
+	// seq breaks the ties of the finish time by the arrival order
+	seq      uint64
+	start    float64
+	finish   float64
+	ready    chan struct{}
+	admitted bool
+}
+
+func New() *Scheduler {
+	return &Scheduler{
+		groups: make(map[string]*groupQueue),
+	}
+}
+
+// Acquire takes a slot for the request of the group, the returned func
+// releases it and must be called once the request is done, a capacity of zero
+// means the requests are not limited
+func (s *Scheduler) Acquire(
+	ctx context.Context,
+	group string,
+	weight float64,
+	capacity int,
+) (func(), error) {
+	if capacity <= 0 {
+		return func() {}, nil
+	}
+
+	if weight <= 0 {
+		weight = 1
+	}
+
+	s.mu.Lock()
+
+	queue, ok := s.groups[group]
+	if !ok {
+		queue = &groupQueue{waiters: list.New()}
+		s.groups[group] = queue
+	}
+
+	s.seq++
+
+	w := &waiter{
+		seq:   s.seq,
+		start: max(s.virtualTime, queue.lastFinish),
+		ready: make(chan struct{}),
+	}
+	w.finish = w.start + 1/weight
+	queue.lastFinish = w.finish
+
+	if s.waiting == 0 && s.inFlight < capacity {
+		s.admit(w)
+		s.mu.Unlock()
+
+		return s.releaseFunc(capacity), nil
+	}
+
+	el := queue.waiters.PushBack(w)
+	s.waiting++
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return s.releaseFunc(capacity), nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if w.admitted {
+			s.release(capacity)
+		} else {
+			queue.waiters.Remove(el)
+			s.waiting--
+			s.cleanup(group, queue)
+		}
+
+		return nil, ctx.Err()
+	}
+}
+
+func (s *Scheduler) releaseFunc(capacity int) func() {
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			s.release(capacity)
+		})
+	}
+}
+
+func (s *Scheduler) admit(w *waiter) {
+	s.inFlight++
+	s.virtualTime = max(s.virtualTime, w.start)
+	w.admitted = true
+}
+
+// release frees a slot and admits the waiters with the smallest finish time
+func (s *Scheduler) release(capacity int) {
+	s.inFlight--
+
+	for s.waiting > 0 && s.inFlight < capacity {
+		var (
+			next      *waiter
+			nextGroup string
+			nextQueue *groupQueue
+		)
+
+		for group, queue := range s.groups {
+			front := queue.waiters.Front()
+			if front == nil {
+				continue
+			}
+
+			w, _ := front.Value.(*waiter)
+			if next == nil || w.finish < next.finish ||
+				(w.finish == next.finish && w.seq < next.seq) {
+				next = w
+				nextGroup = group
+				nextQueue = queue
+			}
+		}
+
+		if next == nil {
+			return
+		}
+
+		nextQueue.waiters.Remove(nextQueue.waiters.Front())
+		s.waiting--
+		s.admit(next)
+		close(next.ready)
+		s.cleanup(nextGroup, nextQueue)
+	}
+
+	// the virtual time restarts once the scheduler is idle, so the groups
+	// served earlier are not behind the new ones
+	if s.inFlight == 0 && s.waiting == 0 {
+		s.virtualTime = 0
+		clear(s.groups)
+
+		return
+	}
+
+	for group, queue := range s.groups {
+		s.cleanup(group, queue)
+	}
+}
+
+// cleanup drops the queue of a group without waiters once its finish time is
+// passed, the group starts from the virtual time again on its next request
+func (s *Scheduler) cleanup(group string, queue *groupQueue) {
+	if queue.waiters.Len() == 0 && queue.lastFinish <= s.virtualTime {
+		delete(s.groups, group)
+	}
+}
+
+// Stats is the state of the scheduler on this instance
+type Stats struct {
+	InFlight int            `json:"in_flight"`
+	Waiting  int            `json:"waiting"`
+	Groups   map[string]int `json:"groups,omitempty"`
+}
+
+func (s *Scheduler) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	groups := make(map[string]int)
+	for group, queue := range s.groups {
+		if queue.waiters.Len() > 0 {
+			groups[group] = queue.waiters.Len()
+		}
+	}
+
+	return Stats{
+		InFlight: s.inFlight,
+		Waiting:  s.waiting,
+		Groups:   groups,
+	}
+}
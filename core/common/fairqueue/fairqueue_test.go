@@ -0,0 +1,130 @@
+package fairqueue_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/fairqueue"
+	"github.com/stretchr/testify/require"
+)
+
+func acquireAsync(
+	t *testing.T,
+	s *fairqueue.Scheduler,
+	group string,
+	weight float64,
+	admitted chan<- string,
+) {
+	t.Helper()
+
+	go func() {
+		release, err := s.Acquire(t.Context(), group, weight, 1)
+		if err != nil {
+			return
+		}
+
+		admitted <- group
+
+		release()
+	}()
+}
+
+func waitForWaiting(t *testing.T, s *fairqueue.Scheduler, waiting int) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		return s.Stats().Waiting == waiting
+	}, time.Second, time.Millisecond)
+}
+
+func TestSchedulerUnlimited(t *testing.T) {
+	s := fairqueue.New()
+
+	for range 10 {
+		release, err := s.Acquire(t.Context(), "group", 1, 0)
+		require.NoError(t, err)
+
+		defer release()
+	}
+
+	require.Equal(t, 0, s.Stats().InFlight)
+}
+
+func TestSchedulerInterleavesGroups(t *testing.T) {
+	s := fairqueue.New()
+
+	release, err := s.Acquire(t.Context(), "holder", 1, 1)
+	require.NoError(t, err)
+
+	admitted := make(chan string, 6)
+
+	// the noisy group queues its burst before the quiet group
+	for i := range 4 {
+		acquireAsync(t, s, "noisy", 1, admitted)
+		waitForWaiting(t, s, i+1)
+	}
+
+	for i := range 2 {
+		acquireAsync(t, s, "quiet", 1, admitted)
+		waitForWaiting(t, s, 5+i)
+	}
+
+	release()
+
+	order := make([]string, 0, 6)
+	for range 6 {
+		order = append(order, <-admitted)
+	}
+
+	require.Equal(t, []string{"noisy", "quiet", "noisy", "quiet", "noisy", "noisy"}, order)
+}
+
+func TestSchedulerWeights(t *testing.T) {
+	s := fairqueue.New()
+
+	release, err := s.Acquire(t.Context(), "holder", 1, 1)
+	require.NoError(t, err)
+
+	admitted := make(chan string, 6)
+
+	for i := range 3 {
+		acquireAsync(t, s, "light", 1, admitted)
+		waitForWaiting(t, s, i+1)
+	}
+
+	for i := range 3 {
+		acquireAsync(t, s, "heavy", 3, admitted)
+		waitForWaiting(t, s, 4+i)
+	}
+
+	release()
+
+	order := make([]string, 0, 6)
+	for range 6 {
+		order = append(order, <-admitted)
+	}
+
+	// the third request of the heavy group finishes with the first of the light
+	// group, which arrived earlier
+	require.Equal(t, []string{"heavy", "heavy", "light", "heavy", "light", "light"}, order)
+}
+
+func TestSchedulerTimeout(t *testing.T) {
+	s := fairqueue.New()
+
+	release, err := s.Acquire(t.Context(), "holder", 1, 1)
+	require.NoError(t, err)
+
+	defer release()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = s.Acquire(ctx, "waiter", 1, 1)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	stats := s.Stats()
+	require.Equal(t, 1, stats.InFlight)
+	require.Equal(t, 0, stats.Waiting)
+}
@@ -0,0 +1,114 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+type Location struct {
+	// Country is the ISO 3166-1 alpha-2 country code, e.g. DE
+	Country string `json:"country,omitempty"`
+	// Continent is the two letter continent code, e.g. EU
+	Continent string `json:"continent,omitempty"`
+}
+
+func (l Location) IsZero() bool {
+	return l.Country == "" && l.Continent == ""
+}
+
+// Match reports whether the location is in one of the regions,
+// a region is either a country or a continent code
+func (l Location) Match(regions []string) bool {
+	for _, region := range regions {
+		if l.Country != "" && strings.EqualFold(region, l.Country) ||
+			l.Continent != "" && strings.EqualFold(region, l.Continent) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var reader atomic.Pointer[maxminddb.Reader]
+
+// Init loads the mmdb file, e.g. GeoLite2-Country.mmdb
+func Init(path string) error {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read geoip database failed: %w", err)
+	}
+
+	r, err := maxminddb.FromBytes(buf)
+	if err != nil {
+		return fmt.Errorf("load geoip database failed: %w", err)
+	}
+
+	reader.Store(r)
+
+	return nil
+}
+
+func Enabled() bool {
+	return reader.Load() != nil
+}
+
+// Lookup returns the location of the ip, an empty location is returned when
+// the database is not loaded or the ip is unknown
+func Lookup(ip string) Location {
+	r := reader.Load()
+	if r == nil {
+		return Location{}
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return Location{}
+	}
+
+	var record any
+	if err := r.Lookup(addr, &record); err != nil {
+		return Location{}
+	}
+
+	return parseLocation(record)
+}
+
+// parseLocation supports both the maxmind layout
+// {"country":{"iso_code":"DE"},"continent":{"code":"EU"}}
+// and the flat layout {"country":"DE","continent":"EU"} used by other vendors
+func parseLocation(record any) Location {
+	m, ok := record.(map[string]any)
+	if !ok {
+		return Location{}
+	}
+
+	location := Location{
+		Country:   field(m, "country", "iso_code"),
+		Continent: field(m, "continent", "code"),
+	}
+	if location.Country == "" {
+		location.Country = field(m, "registered_country", "iso_code")
+	}
+
+	location.Country = strings.ToUpper(location.Country)
+	location.Continent = strings.ToUpper(location.Continent)
+
+	return location
+}
+
+func field(m map[string]any, key, subKey string) string {
+	switch v := m[key].(type) {
+	case string:
+		return v
+	case map[string]any:
+		s, _ := v[subKey].(string)
+		return s
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,123 @@
+//nolint:testpackage
+package geoip
+
+import (
+	"net"
+	"testing"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var metadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+const dataSectionSeparatorSize = 16
+
+func encodeString(s string) []byte {
+	return append([]byte{2<<5 | byte(len(s))}, s...)
+}
+
+func encodeUint16(v uint16) []byte {
+	return []byte{5<<5 | 2, byte(v >> 8), byte(v)}
+}
+
+func encodeUint32(v uint32) []byte {
+	return []byte{6<<5 | 4, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+func encodeMap(kvs ...[]byte) []byte {
+	b := []byte{7<<5 | byte(len(kvs)/2)}
+	for _, kv := range kvs {
+		b = append(b, kv...)
+	}
+
+	return b
+}
+
+func put24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v>>16), byte(v>>8), byte(v)
+}
+
+// buildTestDB builds an ipv4 database with 24 bit records where 1.0.0.0/8
+// is in germany and 2.0.0.0/8 uses the flat layout
+func buildTestDB(t *testing.T) []byte {
+	t.Helper()
+
+	maxmindRecord := encodeMap(
+		encodeString("country"), encodeMap(encodeString("iso_code"), encodeString("DE")),
+		encodeString("continent"), encodeMap(encodeString("code"), encodeString("EU")),
+	)
+	flatRecord := encodeMap(
+		encodeString("country"), encodeString("us"),
+		encodeString("continent"), encodeString("na"),
+	)
+	data := append(append([]byte{}, maxmindRecord...), flatRecord...)
+
+	// node 0..5 walk the six leading zero bits, node 6 splits 1.x and 2.x,
+	// node 7 is the last bit of 1.x, node 8 is the last bit of 2.x
+	const nodeCount = 9
+
+	empty := uint32(nodeCount)
+	dataRecord := func(offset int) uint32 {
+		return uint32(nodeCount + dataSectionSeparatorSize + offset)
+	}
+
+	tree := make([]byte, nodeCount*6)
+	setNode := func(node int, left, right uint32) {
+		put24(tree[node*6:], left)
+		put24(tree[node*6+3:], right)
+	}
+
+	for i := range 6 {
+		setNode(i, uint32(i+1), empty)
+	}
+
+	setNode(6, 7, 8)
+	setNode(7, empty, dataRecord(0))
+	setNode(8, dataRecord(len(maxmindRecord)), empty)
+
+	buf := append(tree, make([]byte, dataSectionSeparatorSize)...)
+	buf = append(buf, data...)
+	buf = append(buf, metadataStartMarker...)
+	buf = append(buf, encodeMap(
+		encodeString("node_count"), encodeUint32(nodeCount),
+		encodeString("record_size"), encodeUint16(24),
+		encodeString("ip_version"), encodeUint16(4),
+	)...)
+
+	return buf
+}
+
+func TestReaderLookup(t *testing.T) {
+	t.Parallel()
+
+	r, err := maxminddb.FromBytes(buildTestDB(t))
+	require.NoError(t, err)
+
+	tests := []struct {
+		ip   string
+		want Location
+	}{
+		{ip: "1.2.3.4", want: Location{Country: "DE", Continent: "EU"}},
+		{ip: "2.255.0.1", want: Location{Country: "US", Continent: "NA"}},
+		{ip: "3.0.0.1", want: Location{}},
+		{ip: "::ffff:1.1.1.1", want: Location{Country: "DE", Continent: "EU"}},
+	}
+
+	for _, tt := range tests {
+		var record any
+		require.NoError(t, r.Lookup(net.ParseIP(tt.ip), &record), tt.ip)
+		assert.Equal(t, tt.want, parseLocation(record), tt.ip)
+	}
+}
+
+func TestLocationMatch(t *testing.T) {
+	t.Parallel()
+
+	l := Location{Country: "DE", Continent: "EU"}
+	assert.True(t, l.Match([]string{"eu"}))
+	assert.True(t, l.Match([]string{"US", "DE"}))
+	assert.False(t, l.Match([]string{"CN"}))
+	assert.False(t, (Location{}).Match([]string{""}), "empty location must not match")
+}
@@ -0,0 +1,104 @@
+// Package idempotency records the responses of the requests with an
+// idempotency key, so the retries of the clients get the recorded response
+// instead of calling the upstream and being billed again
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/labring/aiproxy/core/common"
+	log "github.com/sirupsen/logrus"
+)
+
+const redisTimeout = 2 * time.Second
+
+var (
+	// ErrInProgress is returned when the request of the key is not finished yet
+	ErrInProgress = errors.New("a request with the same idempotency key is in progress")
+	// ErrBodyMismatch is returned when the key was taken by a request with
+	// another body, the key must not be reused for a different request
+	ErrBodyMismatch = errors.New(
+		"the idempotency key was used with a different request body",
+	)
+)
+
+// Response is the recorded final response of the request, the body is not
+// kept when it exceeds the max size, e.g. a long stream transcript, and the
+// request id references the original request in the logs
+type Response struct {
+	StatusCode int               `json:"status_code"`
+	Header     map[string]string `json:"header,omitempty"`
+	Body       []byte            `json:"body,omitempty"`
+	RequestID  string            `json:"request_id"`
+	Truncated  bool              `json:"truncated,omitempty"`
+}
+
+type record struct {
+	Done     bool      `json:"done"`
+	BodyHash string    `json:"body_hash,omitempty"`
+	Response *Response `json:"response,omitempty"`
+}
+
+// check returns the recorded response of the record taken by another request
+func (r *record) check(bodyHash string) (*Response, error) {
+	if r.BodyHash != bodyHash {
+		return nil, ErrBodyMismatch
+	}
+
+	if !r.Done {
+		return nil, ErrInProgress
+	}
+
+	return r.Response, nil
+}
+
+// Acquire takes the key for the request with the body hash, the recorded
+// response is returned when the key is already done, ErrInProgress is returned
+// when the request of the key is still running and ErrBodyMismatch when the
+// key was taken by a request with another body
+func Acquire(key, bodyHash string, ttl time.Duration) (*Response, error) {
+	if common.RedisAvailable() {
+		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+		defer cancel()
+
+		resp, err := redisAcquire(ctx, key, bodyHash, ttl)
+		if err == nil || errors.Is(err, ErrInProgress) || errors.Is(err, ErrBodyMismatch) {
+			return resp, err
+		}
+
+		log.Errorf("failed to acquire idempotency key %s: %s", key, err)
+	}
+
+	return memAcquire(key, bodyHash, ttl)
+}
+
+// Complete records the final response of the key
+func Complete(key, bodyHash string, resp *Response, ttl time.Duration) {
+	memComplete(key, bodyHash, resp, ttl)
+
+	if common.RedisAvailable() {
+		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+		defer cancel()
+
+		if err := redisComplete(ctx, key, bodyHash, resp, ttl); err != nil {
+			log.Errorf("failed to complete idempotency key %s: %s", key, err)
+		}
+	}
+}
+
+// Release drops the key, so the request can be retried, e.g. after an
+// upstream failure that was not billed
+func Release(key string) {
+	memRelease(key)
+
+	if common.RedisAvailable() {
+		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+		defer cancel()
+
+		if err := redisRelease(ctx, key); err != nil {
+			log.Errorf("failed to release idempotency key %s: %s", key, err)
+		}
+	}
+}
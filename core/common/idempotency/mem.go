@@ -0,0 +1,36 @@
+package idempotency
+
+import (
+	"time"
+
+	gcache "github.com/patrickmn/go-cache"
+)
+
+var memRecords = gcache.New(time.Minute, 5*time.Minute)
+
+func memAcquire(key, bodyHash string, ttl time.Duration) (*Response, error) {
+	if err := memRecords.Add(key, &record{BodyHash: bodyHash}, ttl); err == nil {
+		return nil, nil
+	}
+
+	v, ok := memRecords.Get(key)
+	if !ok {
+		// expired between the add and the get
+		return memAcquire(key, bodyHash, ttl)
+	}
+
+	r, ok := v.(*record)
+	if !ok {
+		return nil, ErrInProgress
+	}
+
+	return r.check(bodyHash)
+}
+
+func memComplete(key, bodyHash string, resp *Response, ttl time.Duration) {
+	memRecords.Set(key, &record{Done: true, BodyHash: bodyHash, Response: resp}, ttl)
+}
+
+func memRelease(key string) {
+	memRecords.Delete(key)
+}
@@ -0,0 +1,71 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/redis/go-redis/v9"
+)
+
+const idempotencyKey = "idempotency:%s"
+
+func redisAcquire(
+	ctx context.Context,
+	key, bodyHash string,
+	ttl time.Duration,
+) (*Response, error) {
+	redisKey := common.RedisKeyf(idempotencyKey, key)
+
+	pending, err := sonic.Marshal(record{BodyHash: bodyHash})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = common.RDB.SetArgs(ctx, redisKey, pending, redis.SetArgs{Mode: "NX", TTL: ttl}).
+		Result()
+	if err == nil {
+		return nil, nil
+	}
+
+	if !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	data, err := common.RDB.Get(ctx, redisKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// expired between the set and the get
+		return redisAcquire(ctx, key, bodyHash, ttl)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var r record
+	if err := sonic.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+
+	return r.check(bodyHash)
+}
+
+func redisComplete(
+	ctx context.Context,
+	key, bodyHash string,
+	resp *Response,
+	ttl time.Duration,
+) error {
+	data, err := sonic.Marshal(record{Done: true, BodyHash: bodyHash, Response: resp})
+	if err != nil {
+		return err
+	}
+
+	return common.RDB.Set(ctx, common.RedisKeyf(idempotencyKey, key), data, ttl).Err()
+}
+
+func redisRelease(ctx context.Context, key string) error {
+	return common.RDB.Del(ctx, common.RedisKeyf(idempotencyKey, key)).Err()
+}
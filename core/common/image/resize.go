@@ -0,0 +1,81 @@
+package image
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+)
+
+// the vision detail levels of the openai image inputs
+const (
+	DetailLow  = "low"
+	DetailHigh = "high"
+	DetailAuto = "auto"
+)
+
+// LowDetailMaxEdge is the longest edge of the images sent with the low detail,
+// the model only receives a low resolution version of them
+const LowDetailMaxEdge = 512
+
+// ResizeBase64 scales the base64 image down so that its longest edge is at
+// most maxEdge, the images within it are returned unchanged, the png and gif
+// images are encoded as png to keep their transparency and the others as jpeg
+func ResizeBase64(mimeType, data string, maxEdge int) (string, string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", "", err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(decoded))
+	if err != nil {
+		return "", "", err
+	}
+
+	width, height, ok := fitWithin(config.Width, config.Height, maxEdge)
+	if !ok {
+		return mimeType, data, nil
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(decoded))
+	if err != nil {
+		return "", "", err
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	var buf bytes.Buffer
+
+	switch format {
+	case "png", "gif":
+		mimeType = "image/png"
+		err = png.Encode(&buf, dst)
+	default:
+		mimeType = "image/jpeg"
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 90})
+	}
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return mimeType, base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// fitWithin returns the size of the image scaled down to fit the max edge, ok
+// is false when the image already fits
+func fitWithin(width, height, maxEdge int) (int, int, bool) {
+	if maxEdge <= 0 || (width <= maxEdge && height <= maxEdge) {
+		return width, height, false
+	}
+
+	if width >= height {
+		return maxEdge, max(1, height*maxEdge/width), true
+	}
+
+	return max(1, width*maxEdge/height), maxEdge, true
+}
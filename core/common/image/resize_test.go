@@ -0,0 +1,73 @@
+package image_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	stdimage "image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/labring/aiproxy/core/common/image"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func encodeTestImage(t *testing.T, width, height int, asPNG bool) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	img := stdimage.NewRGBA(stdimage.Rect(0, 0, width, height))
+
+	var err error
+	if asPNG {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, nil)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestResizeBase64(t *testing.T) {
+	convey.Convey("ResizeBase64", t, func() {
+		convey.Convey("should scale the longest edge down to the max edge", func() {
+			data := encodeTestImage(t, 2048, 1024, false)
+
+			mimeType, resized, err := image.ResizeBase64("image/jpeg", data, image.LowDetailMaxEdge)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(mimeType, convey.ShouldEqual, "image/jpeg")
+
+			w, h, err := image.GetImageSizeFromBase64(resized)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(w, convey.ShouldEqual, 512)
+			convey.So(h, convey.ShouldEqual, 256)
+		})
+
+		convey.Convey("should keep png images as png", func() {
+			data := encodeTestImage(t, 300, 1200, true)
+
+			mimeType, resized, err := image.ResizeBase64("image/png", data, 600)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(mimeType, convey.ShouldEqual, "image/png")
+
+			w, h, err := image.GetImageSizeFromBase64(resized)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(w, convey.ShouldEqual, 150)
+			convey.So(h, convey.ShouldEqual, 600)
+		})
+
+		convey.Convey("should return the small images unchanged", func() {
+			data := encodeTestImage(t, 100, 100, true)
+
+			mimeType, resized, err := image.ResizeBase64("image/png", data, image.LowDetailMaxEdge)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(mimeType, convey.ShouldEqual, "image/png")
+			convey.So(resized, convey.ShouldEqual, data)
+		})
+	})
+}
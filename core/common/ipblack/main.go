@@ -14,7 +14,7 @@ func SetIPBlackAnyWay(ip string, duration time.Duration) {
 	memSetIPBlack(ip, duration)
 	cacheSetIPBlackLocal(ip, true)
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
 		defer cancel()
 
@@ -32,7 +32,7 @@ func GetIPIsBlockAnyWay(ctx context.Context, ip string) bool {
 		return ok
 	}
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		ok, err := redisGetIPIsBlock(ctx, ip)
 		if err == nil {
 			cacheSetIPBlackLocal(ip, ok)
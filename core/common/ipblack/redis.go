@@ -16,7 +16,7 @@ const (
 func redisSetIPBlack(ctx context.Context, ip string, duration time.Duration) (bool, error) {
 	key := common.RedisKeyf(ipBlackKey, ip)
 
-	_, err := common.RDB.SetArgs(ctx, key, duration.Seconds(), redis.SetArgs{Mode: "NX", TTL: duration}).
+	_, err := common.IPBlackRDB().SetArgs(ctx, key, duration.Seconds(), redis.SetArgs{Mode: "NX", TTL: duration}).
 		Result()
 	if errors.Is(err, redis.Nil) {
 		// Key already exists, IP is already blocked
@@ -34,7 +34,7 @@ func redisSetIPBlack(ctx context.Context, ip string, duration time.Duration) (bo
 func redisGetIPIsBlock(ctx context.Context, ip string) (bool, error) {
 	key := common.RedisKeyf(ipBlackKey, ip)
 
-	exists, err := common.RDB.Exists(ctx, key).Result()
+	exists, err := common.IPBlackRDB().Exists(ctx, key).Result()
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,221 @@
+package mediastore
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/config"
+)
+
+// PathPrefix is the path of the media route, the key of the file follows it
+const PathPrefix = "/v1/media/"
+
+var (
+	ErrInvalidKey       = errors.New("invalid media key")
+	ErrInvalidSignature = errors.New("invalid media signature")
+	ErrExpired          = errors.New("media url expired")
+)
+
+// keyPattern only matches the keys generated by Save, so a key never leaves
+// the storage dir
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{32}\.[a-z0-9]+$`)
+
+var contentTypeExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+	"audio/mpeg": ".mp3",
+	"audio/wav":  ".wav",
+	"audio/ogg":  ".ogg",
+	"audio/flac": ".flac",
+	"audio/aac":  ".aac",
+	"audio/pcm":  ".pcm",
+}
+
+// Store keeps the media files in a local dir, the dir must be shared by all
+// the instances, e.g. a shared volume
+type Store struct {
+	dir    string
+	secret []byte
+}
+
+func New(dir string, secret []byte) *Store {
+	return &Store{dir: dir, secret: secret}
+}
+
+var (
+	defaultStore     *Store
+	defaultStoreOnce sync.Once
+)
+
+// Default returns the store of MEDIA_STORAGE_DIR, nil when it is not set
+func Default() *Store {
+	defaultStoreOnce.Do(func() {
+		if config.MediaStorageDir == "" {
+			return
+		}
+
+		secret := []byte(config.MediaSigningKey)
+		if len(secret) == 0 {
+			// the urls are only valid on this instance until it restarts
+			secret = make([]byte, 32)
+			_, _ = rand.Read(secret)
+		}
+
+		defaultStore = New(config.MediaStorageDir, secret)
+	})
+
+	return defaultStore
+}
+
+// TTL is how long the signed urls are valid
+func TTL() time.Duration {
+	return time.Duration(config.MediaURLTTLSeconds) * time.Second
+}
+
+func extension(contentType string) string {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	if ext, ok := contentTypeExtensions[strings.TrimSpace(contentType)]; ok {
+		return ext
+	}
+
+	return ".bin"
+}
+
+// ContentType returns the content type of the key by its extension
+func ContentType(key string) string {
+	ext := filepath.Ext(key)
+	for contentType, v := range contentTypeExtensions {
+		if v == ext {
+			return contentType
+		}
+	}
+
+	return "application/octet-stream"
+}
+
+// Save writes the data to a new file and returns its key, the content type
+// is detected from the data when it is empty
+func (s *Store) Save(data []byte, contentType string) (string, error) {
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return "", fmt.Errorf("create media storage dir: %w", err)
+	}
+
+	key := hex.EncodeToString(id) + extension(contentType)
+
+	if err := os.WriteFile(filepath.Join(s.dir, key), data, 0o644); err != nil {
+		return "", fmt.Errorf("write media file: %w", err)
+	}
+
+	return key, nil
+}
+
+// Path returns the path of the file of the key
+func (s *Store) Path(key string) (string, error) {
+	if !keyPattern.MatchString(key) {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(s.dir, key), nil
+}
+
+func (s *Store) sign(key string, expiresAt int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.FormatInt(expiresAt, 10)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignedURL returns the url of the key valid until the returned time
+func (s *Store) SignedURL(baseURL, key string, ttl time.Duration) (string, time.Time) {
+	expiresAt := time.Now().Add(ttl)
+	expires := expiresAt.Unix()
+
+	values := url.Values{
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"signature": {s.sign(key, expires)},
+	}
+
+	return strings.TrimSuffix(baseURL, "/") + PathPrefix + key + "?" + values.Encode(),
+		time.Unix(expires, 0)
+}
+
+// Verify checks the signature and the expiration of a signed url
+func (s *Store) Verify(key, expires, signature string) error {
+	if !keyPattern.MatchString(key) {
+		return ErrInvalidKey
+	}
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(s.sign(key, expiresAt)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	if time.Now().Unix() > expiresAt {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+// Cleanup removes the files older than maxAge and returns how many were
+// removed
+func (s *Store) Cleanup(maxAge time.Duration) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	deadline := time.Now().Add(-maxAge)
+	removed := 0
+
+	for _, entry := range entries {
+		if entry.IsDir() || !keyPattern.MatchString(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(deadline) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err == nil {
+			removed++
+		}
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,106 @@
+package mediastore_test
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/mediastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestSaveAndSignedURL(t *testing.T) {
+	t.Parallel()
+
+	store := mediastore.New(t.TempDir(), []byte("secret"))
+
+	key, err := store.Save(pngHeader, "")
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(key, ".png"))
+	assert.Equal(t, "image/png", mediastore.ContentType(key))
+
+	path, err := store.Path(key)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, pngHeader, data)
+
+	signedURL, expiresAt := store.SignedURL("https://aiproxy.example.com/", key, time.Hour)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, 2*time.Second)
+
+	u, err := url.Parse(signedURL)
+	require.NoError(t, err)
+	assert.Equal(t, "aiproxy.example.com", u.Host)
+	assert.Equal(t, mediastore.PathPrefix+key, u.Path)
+
+	expires := u.Query().Get("expires")
+	signature := u.Query().Get("signature")
+	require.NoError(t, store.Verify(key, expires, signature))
+
+	assert.ErrorIs(t, store.Verify(key, expires, strings.Repeat("0", 64)), mediastore.ErrInvalidSignature)
+	assert.ErrorIs(
+		t,
+		mediastore.New(t.TempDir(), []byte("other")).Verify(key, expires, signature),
+		mediastore.ErrInvalidSignature,
+	)
+	assert.ErrorIs(t, store.Verify("../"+key, expires, signature), mediastore.ErrInvalidKey)
+
+	// the expiration can not be extended without the secret
+	later := strconv.FormatInt(time.Now().Add(2*time.Hour).Unix(), 10)
+	assert.ErrorIs(t, store.Verify(key, later, signature), mediastore.ErrInvalidSignature)
+}
+
+func TestVerifyExpired(t *testing.T) {
+	t.Parallel()
+
+	store := mediastore.New(t.TempDir(), []byte("secret"))
+
+	key, err := store.Save([]byte("ID3"), "audio/mpeg")
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(key, ".mp3"))
+
+	signedURL, _ := store.SignedURL("http://localhost:3000", key, -time.Minute)
+	u, err := url.Parse(signedURL)
+	require.NoError(t, err)
+
+	assert.ErrorIs(
+		t,
+		store.Verify(key, u.Query().Get("expires"), u.Query().Get("signature")),
+		mediastore.ErrExpired,
+	)
+}
+
+func TestCleanup(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	store := mediastore.New(dir, []byte("secret"))
+
+	oldKey, err := store.Save(pngHeader, "image/png")
+	require.NoError(t, err)
+
+	newKey, err := store.Save(pngHeader, "image/png")
+	require.NoError(t, err)
+
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, oldKey), old, old))
+
+	removed, err := store.Cleanup(time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	assert.NoFileExists(t, filepath.Join(dir, oldKey))
+	assert.FileExists(t, filepath.Join(dir, newKey))
+
+	removed, err = mediastore.New(filepath.Join(dir, "missing"), nil).Cleanup(time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"errors"
+
+	"github.com/bytedance/sonic"
+)
+
+var ErrMergePatchNotObject = errors.New("merge patch target is not a json object")
+
+// MergePatchJSON applies the json merge patches (RFC 7396) to the json object
+// in order, the null values of a patch remove the fields and the nested
+// objects are merged recursively
+func MergePatchJSON(doc []byte, patches ...map[string]any) ([]byte, error) {
+	if len(patches) == 0 {
+		return doc, nil
+	}
+
+	var target map[string]any
+	if err := sonic.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+
+	if target == nil {
+		return nil, ErrMergePatchNotObject
+	}
+
+	for _, patch := range patches {
+		target = mergePatch(target, patch)
+	}
+
+	return sonic.Marshal(target)
+}
+
+func mergePatch(target, patch map[string]any) map[string]any {
+	if target == nil {
+		target = make(map[string]any, len(patch))
+	}
+
+	for key, value := range patch {
+		switch value := value.(type) {
+		case nil:
+			delete(target, key)
+		case map[string]any:
+			nested, _ := target[key].(map[string]any)
+			target[key] = mergePatch(nested, value)
+		default:
+			target[key] = value
+		}
+	}
+
+	return target
+}
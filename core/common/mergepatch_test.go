@@ -0,0 +1,52 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/common"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestMergePatchJSON(t *testing.T) {
+	convey.Convey("MergePatchJSON", t, func() {
+		convey.Convey("should override and add fields", func() {
+			out, err := common.MergePatchJSON(
+				[]byte(`{"model":"deepseek-r1","temperature":1}`),
+				map[string]any{"temperature": 0.6, "top_p": 0.95},
+			)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(
+				string(out),
+				convey.ShouldEqualJSON,
+				`{"model":"deepseek-r1","temperature":0.6,"top_p":0.95}`,
+			)
+		})
+
+		convey.Convey("should remove null fields and merge nested objects", func() {
+			out, err := common.MergePatchJSON(
+				[]byte(`{"a":{"b":1,"c":2},"d":3}`),
+				map[string]any{"a": map[string]any{"c": nil, "e": 4}, "d": nil},
+			)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(string(out), convey.ShouldEqualJSON, `{"a":{"b":1,"e":4}}`)
+		})
+
+		convey.Convey("should apply the patches in order", func() {
+			out, err := common.MergePatchJSON(
+				[]byte(`{"temperature":1}`),
+				map[string]any{"temperature": 0.5},
+				map[string]any{"temperature": 0.6},
+			)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(string(out), convey.ShouldEqualJSON, `{"temperature":0.6}`)
+		})
+
+		convey.Convey("should reject non object documents", func() {
+			_, err := common.MergePatchJSON([]byte(`[1]`), map[string]any{"a": 1})
+			convey.So(err, convey.ShouldNotBeNil)
+
+			_, err = common.MergePatchJSON([]byte(`null`), map[string]any{"a": 1})
+			convey.So(err, convey.ShouldEqual, common.ErrMergePatchNotObject)
+		})
+	})
+}
@@ -3,6 +3,7 @@ package network
 import (
 	"fmt"
 	"net"
+	"strings"
 )
 
 func IsValidSubnet(subnet string) error {
@@ -44,3 +45,33 @@ func IsIPInSubnets(ip string, subnets []string) (bool, error) {
 
 	return false, nil
 }
+
+// IsTrustedProxy reports whether the ip is one of the proxies, the ips or the
+// cidrs of TRUSTED_PROXIES. Every ip is trusted when the proxies are empty,
+// like gin whose trusted proxies are only set when TRUSTED_PROXIES is
+func IsTrustedProxy(ip string, proxies []string) bool {
+	if len(proxies) == 0 {
+		return true
+	}
+
+	peer := net.ParseIP(ip)
+	if peer == nil {
+		return false
+	}
+
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if net.ParseIP(proxy).Equal(peer) {
+				return true
+			}
+
+			continue
+		}
+
+		if _, ipNet, err := net.ParseCIDR(proxy); err == nil && ipNet.Contains(peer) {
+			return true
+		}
+	}
+
+	return false
+}
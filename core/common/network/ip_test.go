@@ -5,6 +5,7 @@ import (
 
 	"github.com/labring/aiproxy/core/common/network"
 	"github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestIsIpInSubnet(t *testing.T) {
@@ -25,3 +26,18 @@ func TestIsIpInSubnet(t *testing.T) {
 		}
 	})
 }
+
+func TestIsTrustedProxy(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, network.IsTrustedProxy("203.0.113.1", nil))
+
+	proxies := []string{"10.0.0.0/8", "192.168.1.1", "::1"}
+	for _, ip := range []string{"10.1.2.3", "192.168.1.1", "::1"} {
+		assert.True(t, network.IsTrustedProxy(ip, proxies), ip)
+	}
+
+	for _, ip := range []string{"192.168.1.2", "203.0.113.1", "", "invalid"} {
+		assert.False(t, network.IsTrustedProxy(ip, proxies), ip)
+	}
+}
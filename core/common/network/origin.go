@@ -0,0 +1,77 @@
+package network
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// IsValidOrigin checks an allowed origin, the origin is `*`, a
+// `scheme://host[:port]` origin, or a `scheme://*.domain[:port]` origin matching
+// the subdomains
+func IsValidOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+
+	u, err := url.Parse(strings.Replace(origin, "://*.", "://wildcard.", 1))
+	if err != nil {
+		return fmt.Errorf("failed to parse origin: %w", err)
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("origin %q must be scheme://host", origin)
+	}
+
+	if u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("origin %q must not have a path, query or user", origin)
+	}
+
+	return nil
+}
+
+func IsValidOrigins(origins []string) error {
+	for _, origin := range origins {
+		if err := IsValidOrigin(origin); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func normalizeOrigin(origin string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+}
+
+// IsOriginAllowed reports whether the Origin header of a browser request
+// matches the allowed origins, the `null` origin only matches `*`
+func IsOriginAllowed(origin string, allowedOrigins []string) bool {
+	origin = normalizeOrigin(origin)
+	if origin == "" {
+		return false
+	}
+
+	for _, allowed := range allowedOrigins {
+		allowed = normalizeOrigin(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+
+		scheme, domain, ok := strings.Cut(allowed, "://*.")
+		if !ok {
+			continue
+		}
+
+		host, ok := strings.CutPrefix(origin, scheme+"://")
+		if !ok {
+			continue
+		}
+
+		if strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
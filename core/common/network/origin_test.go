@@ -0,0 +1,50 @@
+package network_test
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/common/network"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValidOrigin(t *testing.T) {
+	t.Parallel()
+
+	for _, origin := range []string{
+		"*",
+		"https://app.example.com",
+		"http://localhost:3000",
+		"https://*.example.com",
+	} {
+		assert.NoError(t, network.IsValidOrigin(origin), origin)
+	}
+
+	for _, origin := range []string{
+		"",
+		"example.com",
+		"https://example.com/path",
+		"https://example.com?a=1",
+		"https://user@example.com",
+	} {
+		assert.Error(t, network.IsValidOrigin(origin), origin)
+	}
+}
+
+func TestIsOriginAllowed(t *testing.T) {
+	t.Parallel()
+
+	allowed := []string{"https://app.example.com", "https://*.internal.example.com"}
+
+	assert.True(t, network.IsOriginAllowed("https://app.example.com", allowed))
+	assert.True(t, network.IsOriginAllowed("HTTPS://APP.example.com", allowed))
+	assert.True(t, network.IsOriginAllowed("https://a.b.internal.example.com", allowed))
+
+	assert.False(t, network.IsOriginAllowed("http://app.example.com", allowed))
+	assert.False(t, network.IsOriginAllowed("https://app.example.com:8443", allowed))
+	assert.False(t, network.IsOriginAllowed("https://internal.example.com", allowed))
+	assert.False(t, network.IsOriginAllowed("https://evilinternal.example.com", allowed))
+	assert.False(t, network.IsOriginAllowed("null", allowed))
+	assert.False(t, network.IsOriginAllowed("", allowed))
+
+	assert.True(t, network.IsOriginAllowed("null", []string{"*"}))
+}
@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// the alert kinds with the templates editable by the admin
+const (
+	AlertKindUsage       = "usage"
+	AlertKindBudget      = "budget"
+	AlertKindDeprecation = "deprecation"
+)
+
+const alertPostTimeout = 10 * time.Second
+
+var alertTemplateFuncs = template.FuncMap{
+	"percent": func(ratio float64) string {
+		return strconv.FormatFloat(ratio*100, 'f', 1, 64) + "%"
+	},
+}
+
+func parseAlertTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(alertTemplateFuncs).Option("missingkey=zero").Parse(text)
+}
+
+// ValidateAlertTemplates checks the alert kinds and parses their templates
+func ValidateAlertTemplates(templates map[string]config.AlertTemplate) error {
+	for kind, t := range templates {
+		switch kind {
+		case AlertKindUsage, AlertKindBudget, AlertKindDeprecation:
+		default:
+			return fmt.Errorf("unknown alert kind: %s", kind)
+		}
+
+		if _, err := parseAlertTemplate(kind+" title", t.Title); err != nil {
+			return fmt.Errorf("alert template %s: invalid title: %w", kind, err)
+		}
+
+		if _, err := parseAlertTemplate(kind+" message", t.Message); err != nil {
+			return fmt.Errorf("alert template %s: invalid message: %w", kind, err)
+		}
+	}
+
+	return nil
+}
+
+func renderAlertTemplate(name, text string, data any) (string, error) {
+	t, err := parseAlertTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// renderAlertField renders the field with the configured template, the default
+// template is used when none is configured or it fails to render
+func renderAlertField(name, configured, fallback string, data any) string {
+	if configured != "" {
+		out, err := renderAlertTemplate(name, configured, data)
+		if err == nil {
+			return out
+		}
+
+		log.Warnf("render alert template %s failed, the default is used: %s", name, err)
+	}
+
+	out, err := renderAlertTemplate(name, fallback, data)
+	if err != nil {
+		log.Errorf("render default alert template %s failed: %s", name, err)
+	}
+
+	return out
+}
+
+// RenderAlert renders the title and the message of the alert kind with the
+// templates configured by the admin, falling back to the defaults
+func RenderAlert(kind string, defaults config.AlertTemplate, data any) (title, message string) {
+	configured := config.GetAlertTemplates()[kind]
+
+	title = renderAlertField(kind+" title", configured.Title, defaults.Title, data)
+	message = renderAlertField(kind+" message", configured.Message, defaults.Message, data)
+
+	return title, message
+}
+
+// Alert renders the alert and sends it to the default notifier and the alert
+// notifiers configured by the admin
+func Alert(level Level, kind string, defaults config.AlertTemplate, data any) {
+	title, message := RenderAlert(kind, defaults, data)
+
+	Notify(level, title, message)
+
+	for _, notifier := range config.GetAlertNotifiers() {
+		if notifier.Disabled {
+			continue
+		}
+
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), alertPostTimeout)
+			defer cancel()
+
+			if err := PostAlert(ctx, notifier, level, kind, title, message); err != nil {
+				log.Errorf("post alert to notifier %s failed: %s", notifier.Name, err)
+			}
+		}()
+	}
+}
+
+// PostAlert posts the rendered alert to the notifier
+func PostAlert(
+	ctx context.Context,
+	notifier config.AlertNotifier,
+	level Level,
+	kind, title, message string,
+) error {
+	switch notifier.Type {
+	case config.AlertNotifierFeishu:
+		return PostToFeiShuv2(ctx, level2Color(level), title, message, notifier.URL)
+	case config.AlertNotifierSlack:
+		return PostToSlack(ctx, title, message, notifier.URL)
+	case config.AlertNotifierDingTalk:
+		return PostToDingTalk(ctx, title, message, notifier.URL, notifier.Secret)
+	case config.AlertNotifierWebhook:
+		return PostToWebhook(ctx, WebhookMessage{
+			Level:   level,
+			Kind:    kind,
+			Title:   title,
+			Message: message,
+		}, notifier.URL, notifier.Secret)
+	default:
+		return fmt.Errorf("unknown alert notifier type: %s", notifier.Type)
+	}
+}
@@ -0,0 +1,59 @@
+package notify_test
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/common/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderAlert(t *testing.T) {
+	old := config.GetAlertTemplates()
+	t.Cleanup(func() {
+		config.SetAlertTemplates(old)
+	})
+
+	defaults := config.AlertTemplate{
+		Title:   "{{ len .Alerts }} alerts",
+		Message: "{{ range .Alerts }}{{ .Name }}: {{ percent .Ratio }}\n{{ end }}",
+	}
+	data := map[string]any{
+		"Alerts": []map[string]any{
+			{"Name": "a", "Ratio": 0.8},
+			{"Name": "b", "Ratio": 0.95},
+		},
+	}
+
+	title, message := notify.RenderAlert(notify.AlertKindBudget, defaults, data)
+	assert.Equal(t, "2 alerts", title)
+	assert.Equal(t, "a: 80.0%\nb: 95.0%\n", message)
+
+	config.SetAlertTemplates(map[string]config.AlertTemplate{
+		notify.AlertKindBudget: {Title: "budget {{ .Missing.Field }}"},
+	})
+
+	title, message = notify.RenderAlert(notify.AlertKindBudget, defaults, data)
+	assert.Equal(t, "2 alerts", title)
+	assert.Equal(t, "a: 80.0%\nb: 95.0%\n", message)
+
+	config.SetAlertTemplates(map[string]config.AlertTemplate{
+		notify.AlertKindBudget: {Title: "budget: {{ len .Alerts }}"},
+	})
+
+	title, _ = notify.RenderAlert(notify.AlertKindBudget, defaults, data)
+	assert.Equal(t, "budget: 2", title)
+}
+
+func TestValidateAlertTemplates(t *testing.T) {
+	require.NoError(t, notify.ValidateAlertTemplates(map[string]config.AlertTemplate{
+		notify.AlertKindUsage: {Message: "{{ range .Alerts }}{{ .GroupID }}{{ end }}"},
+	}))
+	require.Error(t, notify.ValidateAlertTemplates(map[string]config.AlertTemplate{
+		"unknown": {Title: "x"},
+	}))
+	require.Error(t, notify.ValidateAlertTemplates(map[string]config.AlertTemplate{
+		notify.AlertKindBudget: {Title: "{{ if }}"},
+	}))
+}
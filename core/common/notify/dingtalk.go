@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/labring/aiproxy/core/common/config"
+)
+
+type dingTalkMessage struct {
+	MsgType  string           `json:"msgtype"`
+	Markdown dingTalkMarkdown `json:"markdown"`
+}
+
+type dingTalkMarkdown struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+type dingTalkResp struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// PostToDingTalk posts the message to the dingtalk robot webhook, the request
+// is signed when the robot has a sign secret
+func PostToDingTalk(ctx context.Context, title, text, wh, secret string) error {
+	if wh == "" {
+		return errors.New("dingtalk webhook url is empty")
+	}
+
+	if secret != "" {
+		signed, err := signDingTalkURL(wh, secret, time.Now())
+		if err != nil {
+			return err
+		}
+
+		wh = signed
+	}
+
+	content := "### " + title + "\n\n" + text
+	if note := config.GetNotifyNote(); note != "" {
+		content += "\n\n> " + note
+	}
+
+	data, err := sonic.ConfigDefault.Marshal(dingTalkMessage{
+		MsgType: "markdown",
+		Markdown: dingTalkMarkdown{
+			Title: title,
+			Text:  content,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dingTalkResp := dingTalkResp{}
+	if err := sonic.ConfigDefault.NewDecoder(resp.Body).Decode(&dingTalkResp); err != nil {
+		return err
+	}
+
+	if dingTalkResp.ErrCode != 0 {
+		return errors.New(dingTalkResp.ErrMsg)
+	}
+
+	return nil
+}
+
+// signDingTalkURL adds the timestamp and the hmac sign of the robot secret to
+// the webhook url
+func signDingTalkURL(wh, secret string, now time.Time) (string, error) {
+	u, err := url.Parse(wh)
+	if err != nil {
+		return "", err
+	}
+
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp + "\n" + secret))
+
+	query := u.Query()
+	query.Set("timestamp", timestamp)
+	query.Set("sign", base64.StdEncoding.EncodeToString(h.Sum(nil)))
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
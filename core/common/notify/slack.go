@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/bytedance/sonic"
+	"github.com/labring/aiproxy/core/common/config"
+)
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// PostToSlack posts the message to the slack incoming webhook
+func PostToSlack(ctx context.Context, title, text, wh string) error {
+	if wh == "" {
+		return errors.New("slack webhook url is empty")
+	}
+
+	content := "*" + title + "*\n" + text
+	if note := config.GetNotifyNote(); note != "" {
+		content += "\n_" + note + "_"
+	}
+
+	data, err := sonic.ConfigDefault.Marshal(slackMessage{Text: content})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("slack webhook status %d: %s", resp.StatusCode, body)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/labring/aiproxy/core/common/config"
+)
+
+// WebhookSignatureHeader carries the hex hmac-sha256 of the body keyed by the
+// webhook secret
+const WebhookSignatureHeader = "X-Aiproxy-Signature"
+
+// WebhookMessage is the json body posted to the generic webhooks
+type WebhookMessage struct {
+	Level     Level  `json:"level"`
+	Kind      string `json:"kind,omitempty"`
+	Title     string `json:"title"`
+	Message   string `json:"message"`
+	Note      string `json:"note,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// PostToWebhook posts the message as json to the webhook, the body is signed
+// when the webhook has a secret
+func PostToWebhook(ctx context.Context, msg WebhookMessage, wh, secret string) error {
+	if wh == "" {
+		return errors.New("webhook url is empty")
+	}
+
+	if msg.Timestamp == 0 {
+		msg.Timestamp = time.Now().Unix()
+	}
+
+	if msg.Note == "" {
+		msg.Note = config.GetNotifyNote()
+	}
+
+	data, err := sonic.ConfigDefault.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookBody(data, secret))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// SignWebhookBody returns the hex hmac-sha256 of the body keyed by the secret
+func SignWebhookBody(body []byte, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
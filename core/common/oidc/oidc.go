@@ -0,0 +1,181 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/labring/aiproxy/core/common/config"
+	"golang.org/x/oauth2"
+)
+
+var (
+	providerMu     sync.Mutex
+	provider       *gooidc.Provider
+	providerIssuer string
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Init checks the oidc configuration at startup, the admin jwt must be signed
+// with its own secret instead of the admin key
+func Init() error {
+	if !config.OIDCEnabled() {
+		return nil
+	}
+
+	if config.AdminJWTSecret == "" {
+		return errors.New("ADMIN_JWT_SECRET must be set when oidc is enabled")
+	}
+
+	return nil
+}
+
+// getProvider fetches and caches the discovery document of the issuer, the
+// keys of its jwks_uri are fetched lazily to verify the id tokens
+func getProvider() (*gooidc.Provider, error) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+
+	if provider != nil && providerIssuer == config.OIDCIssuer {
+		return provider, nil
+	}
+
+	// the context is kept by the provider to refresh the jwks, so it must not
+	// be bound to a request
+	ctx := gooidc.ClientContext(context.Background(), httpClient)
+
+	p, err := gooidc.NewProvider(ctx, config.OIDCIssuer)
+	if err != nil {
+		return nil, fmt.Errorf("fetch oidc discovery document failed: %w", err)
+	}
+
+	provider = p
+	providerIssuer = config.OIDCIssuer
+
+	return p, nil
+}
+
+func oauth2Config(p *gooidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     config.OIDCClientID,
+		ClientSecret: config.OIDCClientSecret,
+		RedirectURL:  config.OIDCRedirectURL,
+		Scopes:       config.OIDCScopes,
+		Endpoint:     p.Endpoint(),
+	}
+}
+
+func AuthCodeURL(state, nonce string) (string, error) {
+	p, err := getProvider()
+	if err != nil {
+		return "", err
+	}
+
+	return oauth2Config(p).AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce)), nil
+}
+
+type Identity struct {
+	Subject string
+	Email   string
+	Name    string
+	Groups  []string
+}
+
+// Exchange redeems the authorization code and returns the identity of the
+// id token, whose signature is verified against the jwks of the issuer
+func Exchange(ctx context.Context, code, nonce string) (*Identity, error) {
+	p, err := getProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = gooidc.ClientContext(ctx, httpClient)
+
+	token, err := oauth2Config(p).Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("exchange oidc code failed: %w", err)
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, errors.New("id_token is missing in token response")
+	}
+
+	verifier := p.Verifier(&gooidc.Config{ClientID: config.OIDCClientID})
+
+	return parseIDToken(ctx, verifier, rawIDToken, nonce)
+}
+
+func parseIDToken(
+	ctx context.Context,
+	verifier *gooidc.IDTokenVerifier,
+	rawIDToken, nonce string,
+) (*Identity, error) {
+	// checks the signature, issuer, audience and expiry
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify id_token failed: %w", err)
+	}
+
+	if idToken.Nonce != nonce {
+		return nil, errors.New("invalid id_token nonce")
+	}
+
+	claims := map[string]any{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("parse id_token claims failed: %w", err)
+	}
+
+	identity := &Identity{Subject: idToken.Subject}
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	identity.Groups = claimStrings(claims[config.OIDCGroupsClaim])
+
+	if identity.Subject == "" {
+		return nil, errors.New("id_token subject is empty")
+	}
+
+	return identity, nil
+}
+
+func claimStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+
+		return result
+	default:
+		return nil
+	}
+}
+
+// MapRole maps the idp groups to the most privileged aiproxy role,
+// falling back to OIDC_DEFAULT_ROLE when no group is mapped
+func MapRole(groups []string) (Role, bool) {
+	var role Role
+
+	for _, group := range groups {
+		r, ok := ParseRole(config.OIDCRoleMapping[group])
+		if ok && r.privilege() > role.privilege() {
+			role = r
+		}
+	}
+
+	if role != "" {
+		return role, true
+	}
+
+	return ParseRole(config.OIDCDefaultRole)
+}
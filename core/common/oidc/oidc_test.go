@@ -0,0 +1,129 @@
+//nolint:testpackage
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminTokenRoundTrip(t *testing.T) {
+	config.AdminJWTSecret = "test-secret"
+	config.AdminJWTTTLSeconds = 60
+
+	token, expiresAt, err := IssueAdminToken("user-1", "user@example.com", "User", RoleViewer)
+	require.NoError(t, err)
+	require.True(t, IsAdminToken(token))
+	require.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, 5*time.Second)
+
+	claims, err := ParseAdminToken(token)
+	require.NoError(t, err)
+	require.Equal(t, RoleViewer, claims.Role)
+	require.Equal(t, "user@example.com", claims.Actor())
+
+	config.AdminJWTSecret = "rotated-secret"
+
+	_, err = ParseAdminToken(token)
+	require.Error(t, err)
+}
+
+func TestMapRole(t *testing.T) {
+	config.OIDCRoleMapping = map[string]string{
+		"platform-admins": "admin",
+		"developers":      "viewer",
+	}
+	config.OIDCDefaultRole = ""
+
+	role, ok := MapRole([]string{"developers", "platform-admins"})
+	require.True(t, ok)
+	require.Equal(t, RoleAdmin, role)
+
+	role, ok = MapRole([]string{"developers"})
+	require.True(t, ok)
+	require.Equal(t, RoleViewer, role)
+
+	_, ok = MapRole([]string{"sales"})
+	require.False(t, ok)
+
+	config.OIDCDefaultRole = "viewer"
+
+	role, ok = MapRole(nil)
+	require.True(t, ok)
+	require.Equal(t, RoleViewer, role)
+}
+
+func TestParseIDToken(t *testing.T) {
+	config.OIDCClientID = "aiproxy"
+	config.OIDCGroupsClaim = "groups"
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	const issuer = "https://idp.example.com"
+
+	verifier := gooidc.NewVerifier(
+		issuer,
+		&gooidc.StaticKeySet{PublicKeys: []crypto.PublicKey{key.Public()}},
+		&gooidc.Config{ClientID: config.OIDCClientID},
+	)
+
+	sign := func(claims jwt.MapClaims, key any) string {
+		method := jwt.SigningMethod(jwt.SigningMethodRS256)
+		if _, ok := key.([]byte); ok {
+			method = jwt.SigningMethodHS256
+		}
+
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		require.NoError(t, err)
+
+		return token
+	}
+
+	claims := jwt.MapClaims{
+		"iss":    issuer,
+		"aud":    "aiproxy",
+		"sub":    "user-1",
+		"email":  "user@example.com",
+		"nonce":  "nonce-1",
+		"exp":    time.Now().Add(time.Minute).Unix(),
+		"groups": []string{"platform-admins"},
+	}
+
+	identity, err := parseIDToken(t.Context(), verifier, sign(claims, key), "nonce-1")
+	require.NoError(t, err)
+	require.Equal(t, "user-1", identity.Subject)
+	require.Equal(t, "user@example.com", identity.Email)
+	require.Equal(t, []string{"platform-admins"}, identity.Groups)
+
+	_, err = parseIDToken(t.Context(), verifier, sign(claims, key), "nonce-2")
+	require.Error(t, err)
+
+	// tokens that are not signed by the issuer are rejected
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	_, err = parseIDToken(t.Context(), verifier, sign(claims, otherKey), "nonce-1")
+	require.Error(t, err)
+
+	_, err = parseIDToken(t.Context(), verifier, sign(claims, []byte("idp")), "nonce-1")
+	require.Error(t, err)
+
+	claims["aud"] = "other"
+	_, err = parseIDToken(t.Context(), verifier, sign(claims, key), "nonce-1")
+	require.Error(t, err)
+}
+
+func TestAdminTokenRequiresDedicatedSecret(t *testing.T) {
+	config.AdminJWTSecret = ""
+	config.AdminKey = "admin-key"
+
+	_, _, err := IssueAdminToken("user-1", "", "", RoleAdmin)
+	require.Error(t, err)
+}
@@ -74,7 +74,7 @@ func redisKeyAlerted(key string) string {
 // RecordError records that an error occurred for the given key
 // Returns the first seen time (either from existing state or now)
 func RecordError(key string) time.Time {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return recordErrorMemory(key)
 	}
 
@@ -142,7 +142,7 @@ func recordErrorMemory(key string) time.Time {
 
 // HasError checks if an error state exists for the given key
 func HasError(key string) bool {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return hasErrorMemory(key)
 	}
 
@@ -181,7 +181,7 @@ func hasErrorMemory(key string) bool {
 
 // ClearError clears the error state for the given key
 func ClearError(key string) {
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		if err := clearErrorRedis(key); err != nil {
 			// Throttle error logs to avoid flooding when Redis is down
 			if trylock.MemLock("oncall_clear_error_log", 5*time.Second) {
@@ -206,7 +206,7 @@ func clearErrorMemory(key string) {
 
 // HasAlerted checks if we've already alerted for the given key within the cooldown period
 func HasAlerted(key string) bool {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return hasAlertedMemory(key)
 	}
 
@@ -256,7 +256,7 @@ func hasAlertedMemory(key string) bool {
 // MarkAlerted marks that we've sent an alert for the given key
 // Returns false if already alerted (to prevent duplicate alerts)
 func MarkAlerted(key string) bool {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return markAlertedMemory(key)
 	}
 
@@ -322,7 +322,7 @@ func markAlertedMemory(key string) bool {
 
 // ClearAlerted clears the alerted state for the given key
 func ClearAlerted(key string) {
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		if err := clearAlertedRedis(key); err != nil {
 			// Throttle error logs to avoid flooding when Redis is down
 			if trylock.MemLock("oncall_clear_alerted_log", 5*time.Second) {
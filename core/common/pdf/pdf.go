@@ -0,0 +1,466 @@
+// Package pdf reads the pages of the pdf documents sent to the models without
+// native pdf input, it understands enough of the format to count the pages and
+// to extract their text and the embedded jpeg images, e.g. of scanned pages
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/labring/aiproxy/core/common"
+)
+
+const (
+	MaxPDFSize = 1024 * 1024 * 32 // 32MB
+
+	MediaType = "application/pdf"
+)
+
+var ErrNotPDF = errors.New("not a pdf document")
+
+// IsPDF reports whether the data starts with the pdf header
+func IsPDF(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF-"))
+}
+
+// DecodeBase64 decodes a base64 pdf, a data url prefix is accepted
+func DecodeBase64(data string) ([]byte, error) {
+	if strings.HasPrefix(data, "data:") {
+		_, data, _ = strings.Cut(data, ",")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode pdf error: %w", err)
+	}
+
+	if !IsPDF(decoded) {
+		return nil, ErrNotPDF
+	}
+
+	return decoded, nil
+}
+
+// Download downloads a pdf document
+func Download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download pdf error: status code: %d", resp.StatusCode)
+	}
+
+	buf, err := common.GetResponseBodyLimit(resp, MaxPDFSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsPDF(buf) {
+		return nil, ErrNotPDF
+	}
+
+	return buf, nil
+}
+
+type object struct {
+	dict      string
+	stream    []byte
+	hasStream bool
+}
+
+// Document is a parsed pdf document
+type Document struct {
+	objects map[int]*object
+	pages   []int
+}
+
+var (
+	objectStartPattern = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+	refPattern         = regexp.MustCompile(`(\d+)\s+\d+\s+R\b`)
+	pageTypePattern    = regexp.MustCompile(`/Type\s*/Page\b`)
+	pagesTypePattern   = regexp.MustCompile(`/Type\s*/Pages\b`)
+	objStmTypePattern  = regexp.MustCompile(`/Type\s*/ObjStm\b`)
+)
+
+// Parse parses the objects and the page tree of the pdf document
+func Parse(data []byte) (*Document, error) {
+	if !IsPDF(data) {
+		return nil, ErrNotPDF
+	}
+
+	doc := &Document{
+		objects: make(map[int]*object),
+	}
+
+	for _, loc := range objectStartPattern.FindAllSubmatchIndex(data, -1) {
+		num, err := strconv.Atoi(string(data[loc[2]:loc[3]]))
+		if err != nil {
+			continue
+		}
+
+		// a later revision of the object replaces the earlier one
+		doc.objects[num] = parseObject(data[loc[1]:])
+	}
+
+	doc.loadObjectStreams()
+	doc.loadPages()
+
+	if len(doc.pages) == 0 {
+		return nil, errors.New("pdf document has no pages")
+	}
+
+	return doc, nil
+}
+
+func parseObject(data []byte) *object {
+	end := bytes.Index(data, []byte("endobj"))
+	if end < 0 {
+		end = len(data)
+	}
+
+	body := data[:end]
+
+	streamAt := bytes.Index(body, []byte("stream"))
+	if streamAt < 0 {
+		return &object{dict: string(bytes.TrimSpace(body))}
+	}
+
+	obj := &object{
+		dict:      string(bytes.TrimSpace(body[:streamAt])),
+		hasStream: true,
+	}
+
+	// the binary stream may contain the endobj keyword, so it is read from
+	// the whole data when its length is known
+	stream := data[streamAt+len("stream"):]
+	stream = bytes.TrimPrefix(stream, []byte("\r"))
+	stream = bytes.TrimPrefix(stream, []byte("\n"))
+
+	if length, err := strconv.Atoi(dictValue(obj.dict, "Length")); err == nil &&
+		length >= 0 && length <= len(stream) {
+		obj.stream = stream[:length]
+		return obj
+	}
+
+	if streamEnd := bytes.Index(stream, []byte("endstream")); streamEnd >= 0 {
+		stream = stream[:streamEnd]
+	}
+
+	obj.stream = bytes.TrimRight(stream, "\r\n")
+
+	return obj
+}
+
+// loadObjectStreams loads the objects compressed into the object streams
+func (d *Document) loadObjectStreams() {
+	for _, obj := range d.objects {
+		if !obj.hasStream || !objStmTypePattern.MatchString(obj.dict) {
+			continue
+		}
+
+		data, ok := decodeStream(obj)
+		if !ok {
+			continue
+		}
+
+		n, _ := strconv.Atoi(dictValue(obj.dict, "N"))
+		first, _ := strconv.Atoi(dictValue(obj.dict, "First"))
+
+		if n <= 0 || first <= 0 || first > len(data) {
+			continue
+		}
+
+		header := strings.Fields(string(data[:first]))
+
+		for i := 0; i+1 < len(header) && i/2 < n; i += 2 {
+			num, err1 := strconv.Atoi(header[i])
+			offset, err2 := strconv.Atoi(header[i+1])
+
+			if err1 != nil || err2 != nil || first+offset > len(data) {
+				continue
+			}
+
+			end := len(data)
+			if i+3 < len(header) {
+				if next, err := strconv.Atoi(header[i+3]); err == nil && first+next <= end {
+					end = first + next
+				}
+			}
+
+			if _, ok := d.objects[num]; ok || first+offset > end {
+				continue
+			}
+
+			d.objects[num] = &object{
+				dict: strings.TrimSpace(string(data[first+offset : end])),
+			}
+		}
+	}
+}
+
+// loadPages walks the page tree from its root, the pages are ordered by their
+// object numbers when the tree is broken
+func (d *Document) loadPages() {
+	for num, obj := range d.objects {
+		if pagesTypePattern.MatchString(obj.dict) && dictValue(obj.dict, "Parent") == "" {
+			d.walkPages(num, make(map[int]struct{}))
+		}
+	}
+
+	if len(d.pages) > 0 {
+		return
+	}
+
+	for num, obj := range d.objects {
+		if pageTypePattern.MatchString(obj.dict) && !pagesTypePattern.MatchString(obj.dict) {
+			d.pages = append(d.pages, num)
+		}
+	}
+
+	slices.Sort(d.pages)
+}
+
+func (d *Document) walkPages(num int, seen map[int]struct{}) {
+	if _, ok := seen[num]; ok {
+		return
+	}
+
+	seen[num] = struct{}{}
+
+	obj, ok := d.objects[num]
+	if !ok {
+		return
+	}
+
+	if !pagesTypePattern.MatchString(obj.dict) {
+		if pageTypePattern.MatchString(obj.dict) {
+			d.pages = append(d.pages, num)
+		}
+
+		return
+	}
+
+	for _, kid := range refs(d.resolve(dictValue(obj.dict, "Kids"))) {
+		d.walkPages(kid, seen)
+	}
+}
+
+// resolve returns the value the indirect reference points to
+func (d *Document) resolve(value string) string {
+	if m := refPattern.FindStringSubmatch(value); m != nil && strings.TrimSpace(value) == m[0] {
+		num, _ := strconv.Atoi(m[1])
+		if obj, ok := d.objects[num]; ok {
+			return obj.dict
+		}
+	}
+
+	return value
+}
+
+// PageCount returns the number of the pages
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// PageTexts returns the text of every page
+func (d *Document) PageTexts() []string {
+	texts := make([]string, len(d.pages))
+	for i, num := range d.pages {
+		texts[i] = d.pageText(num)
+	}
+
+	return texts
+}
+
+func (d *Document) pageText(num int) string {
+	var b strings.Builder
+
+	for _, ref := range refs(dictValue(d.objects[num].dict, "Contents")) {
+		obj, ok := d.objects[ref]
+		if !ok {
+			continue
+		}
+
+		// the contents array may itself be an indirect object
+		if !obj.hasStream {
+			for _, inner := range refs(obj.dict) {
+				if innerObj, ok := d.objects[inner]; ok {
+					if data, ok := decodeStream(innerObj); ok {
+						b.WriteString(extractText(data))
+					}
+				}
+			}
+
+			continue
+		}
+
+		if data, ok := decodeStream(obj); ok {
+			b.WriteString(extractText(data))
+		}
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// PageImages returns the jpeg images drawn on every page
+func (d *Document) PageImages() [][][]byte {
+	images := make([][][]byte, len(d.pages))
+	for i, num := range d.pages {
+		images[i] = d.pageImages(num)
+	}
+
+	return images
+}
+
+func (d *Document) pageImages(num int) [][]byte {
+	resources := d.resolve(dictValue(d.objects[num].dict, "Resources"))
+	xobjects := d.resolve(dictValue(resources, "XObject"))
+
+	var images [][]byte
+
+	for _, ref := range refs(xobjects) {
+		obj, ok := d.objects[ref]
+		if !ok || !obj.hasStream ||
+			!strings.Contains(obj.dict, "/Image") ||
+			dictValue(obj.dict, "Filter") != "/DCTDecode" {
+			continue
+		}
+
+		images = append(images, obj.stream)
+	}
+
+	return images
+}
+
+func decodeStream(obj *object) ([]byte, bool) {
+	switch dictValue(obj.dict, "Filter") {
+	case "":
+		return obj.stream, true
+	case "/FlateDecode", "[/FlateDecode]", "[ /FlateDecode ]":
+		r, err := zlib.NewReader(bytes.NewReader(obj.stream))
+		if err != nil {
+			return nil, false
+		}
+		defer r.Close()
+
+		// the truncated streams still yield the text decoded so far
+		data, err := io.ReadAll(io.LimitReader(r, MaxPDFSize))
+		if err != nil && len(data) == 0 {
+			return nil, false
+		}
+
+		return data, true
+	default:
+		return nil, false
+	}
+}
+
+func refs(value string) []int {
+	matches := refPattern.FindAllStringSubmatch(value, -1)
+
+	nums := make([]int, 0, len(matches))
+	for _, m := range matches {
+		if num, err := strconv.Atoi(m[1]); err == nil {
+			nums = append(nums, num)
+		}
+	}
+
+	return nums
+}
+
+// dictValue returns the raw value of the key in the dictionary, nested
+// dictionaries and arrays are returned whole
+func dictValue(dict, key string) string {
+	name := "/" + key
+
+	for i := 0; i < len(dict); {
+		at := strings.Index(dict[i:], name)
+		if at < 0 {
+			return ""
+		}
+
+		at += i
+		end := at + len(name)
+
+		// the key must not be the prefix of a longer name
+		if end < len(dict) && isRegular(dict[end]) {
+			i = end
+			continue
+		}
+
+		return readValue(strings.TrimLeft(dict[end:], " \t\r\n"))
+	}
+
+	return ""
+}
+
+func readValue(s string) string {
+	switch {
+	case strings.HasPrefix(s, "<<"):
+		return s[:matchDelimiter(s, "<<", ">>")]
+	case strings.HasPrefix(s, "["):
+		return s[:matchDelimiter(s, "[", "]")]
+	}
+
+	if m := refPattern.FindStringIndex(s); m != nil && m[0] == 0 {
+		return s[:m[1]]
+	}
+
+	end := 1
+	for end < len(s) && isRegular(s[end]) {
+		end++
+	}
+
+	return strings.TrimSpace(s[:min(end, len(s))])
+}
+
+func matchDelimiter(s, open, closing string) int {
+	depth := 0
+
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], open):
+			depth++
+			i += len(open)
+		case strings.HasPrefix(s[i:], closing):
+			depth--
+			i += len(closing)
+
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+
+	return len(s)
+}
+
+func isRegular(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0,
+		'/', '[', ']', '<', '>', '(', ')', '{', '}', '%':
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,111 @@
+package pdf_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/labring/aiproxy/core/common/pdf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func flate(t *testing.T, data string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	w := zlib.NewWriter(&buf)
+	_, err := w.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.String()
+}
+
+func buildPDF(t *testing.T) []byte {
+	t.Helper()
+
+	page1 := "BT /F1 12 Tf 72 712 Td (Hello \\(PDF\\)) Tj T* [(Wor) -20 (ld)] TJ ET"
+	page2 := flate(t, "BT /F1 12 Tf 72 712 Td <FEFF00500061006700650020003200> Tj ET")
+	jpeg := "\xff\xd8\xff\xe0fake-jpeg\xff\xd9"
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [4 0 R 3 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 6 0 R " +
+			"/Resources << /XObject << /Im1 7 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(page1), page1),
+		fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream",
+			len(page2), page2),
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image /Filter /DCTDecode /Length %d >>\n"+
+			"stream\n%s\nendstream", len(jpeg), jpeg),
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteString("%PDF-1.4\n")
+
+	for i, obj := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	buf.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+
+	return buf.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	data := buildPDF(t)
+
+	doc, err := pdf.Parse(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, doc.PageCount())
+	assert.Equal(t, []string{"Hello (PDF)\nWorld", "Page 2"}, doc.PageTexts())
+
+	images := doc.PageImages()
+	require.Len(t, images, 2)
+	assert.Empty(t, images[0])
+	require.Len(t, images[1], 1)
+	assert.Equal(t, []byte("\xff\xd8\xff\xe0fake-jpeg\xff\xd9"), images[1][0])
+}
+
+func TestParseNotPDF(t *testing.T) {
+	_, err := pdf.Parse([]byte("hello"))
+	require.ErrorIs(t, err, pdf.ErrNotPDF)
+
+	_, err = pdf.DecodeBase64(base64.StdEncoding.EncodeToString([]byte("hello")))
+	require.ErrorIs(t, err, pdf.ErrNotPDF)
+}
+
+func TestDecodeBase64(t *testing.T) {
+	data := buildPDF(t)
+
+	decoded, err := pdf.DecodeBase64(
+		"data:application/pdf;base64," + base64.StdEncoding.EncodeToString(data),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, data, decoded)
+}
+
+func TestWriteText(t *testing.T) {
+	lines := make([]string, 0, 60)
+
+	lines = append(lines, "Invoice (2025-01)", `a\b`, "café 模型")
+	for i := range 57 {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+
+	doc, err := pdf.Parse(pdf.WriteText(lines))
+	require.NoError(t, err)
+	require.Equal(t, 2, doc.PageCount())
+
+	texts := doc.PageTexts()
+	assert.True(t, strings.HasPrefix(texts[0], "Invoice (2025-01)\na\\b\ncafé ??\nline 0\n"))
+	assert.Equal(t, "line 56", texts[1][strings.LastIndex(texts[1], "\n")+1:])
+}
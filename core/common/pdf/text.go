@@ -0,0 +1,204 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"unicode/utf16"
+)
+
+// extractText extracts the strings shown by the text operators of the content
+// stream, the text of the fonts with custom encodings is not mapped
+func extractText(data []byte) string {
+	var (
+		b       strings.Builder
+		operand []string
+	)
+
+	for i := 0; i < len(data); {
+		c := data[i]
+
+		switch {
+		case c == '(':
+			s, end := readLiteralString(data, i)
+			operand = append(operand, s)
+			i = end
+		case c == '<' && i+1 < len(data) && data[i+1] != '<':
+			s, end := readHexString(data, i)
+			operand = append(operand, s)
+			i = end
+		case c == '[' || c == ']':
+			i++
+		case c == '%':
+			for i < len(data) && data[i] != '\n' && data[i] != '\r' {
+				i++
+			}
+		case isSpace(c):
+			i++
+		default:
+			end := i + 1
+			for end < len(data) && isRegular(data[end]) {
+				end++
+			}
+
+			switch string(data[i:end]) {
+			case "Tj", "TJ":
+				b.WriteString(strings.Join(operand, ""))
+			case "'", `"`:
+				b.WriteString("\n")
+				b.WriteString(strings.Join(operand, ""))
+			case "T*", "Td", "TD":
+				b.WriteString("\n")
+			case "ET":
+				b.WriteString("\n")
+			}
+
+			// numbers and names are the operands of the next operator,
+			// only the strings are kept
+			if !isOperand(data[i]) {
+				operand = operand[:0]
+			}
+
+			i = end
+		}
+	}
+
+	return collapseLines(b.String())
+}
+
+func isOperand(c byte) bool {
+	return c == '/' || c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9')
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0:
+		return true
+	default:
+		return false
+	}
+}
+
+func readLiteralString(data []byte, start int) (string, int) {
+	var (
+		buf   []byte
+		depth = 0
+		i     = start
+	)
+
+	for ; i < len(data); i++ {
+		c := data[i]
+
+		switch c {
+		case '(':
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				return decodeText(buf), i + 1
+			}
+		case '\\':
+			i++
+			if i >= len(data) {
+				break
+			}
+
+			switch e := data[i]; e {
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'b':
+				buf = append(buf, '\b')
+			case 'f':
+				buf = append(buf, '\f')
+			case '\r', '\n':
+				// line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					v := 0
+
+					j := i
+					for ; j < len(data) && j < i+3 && data[j] >= '0' && data[j] <= '7'; j++ {
+						v = v*8 + int(data[j]-'0')
+					}
+
+					buf = append(buf, byte(v))
+					i = j - 1
+				} else {
+					buf = append(buf, e)
+				}
+			}
+
+			continue
+		}
+
+		buf = append(buf, c)
+	}
+
+	return decodeText(buf), i
+}
+
+func readHexString(data []byte, start int) (string, int) {
+	end := bytes.IndexByte(data[start:], '>')
+	if end < 0 {
+		return "", len(data)
+	}
+
+	digits := bytes.Map(func(r rune) rune {
+		if isSpace(byte(r)) {
+			return -1
+		}
+
+		return r
+	}, data[start+1:start+end])
+
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	decoded, err := hex.DecodeString(string(digits))
+	if err != nil {
+		return "", start + end + 1
+	}
+
+	return decodeText(decoded), start + end + 1
+}
+
+// decodeText decodes the utf-16 strings marked by the byte order mark, the
+// other strings are read as latin-1
+func decodeText(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+		units := make([]uint16, 0, (len(b)-2)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+
+		return string(utf16.Decode(units))
+	}
+
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+
+	return string(runes)
+}
+
+func collapseLines(s string) string {
+	lines := strings.Split(s, "\n")
+
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			kept = append(kept, line)
+		}
+	}
+
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,123 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// the text documents are written on landscape letter pages in courier, so the
+// columns of the lines stay aligned
+const (
+	textPageWidth  = 792
+	textPageHeight = 612
+	textMargin     = 48
+	textFontSize   = 9
+	textLeading    = 12
+	// TextLineWidth is the number of the characters fitting on a line
+	TextLineWidth = (textPageWidth - 2*textMargin) * 10 / (textFontSize * 6)
+
+	textLinesPerPage = (textPageHeight - 2*textMargin) / textLeading
+)
+
+// WriteText writes the lines as a pdf document of monospaced text, the lines
+// run onto the next pages and the characters outside of latin-1 are written
+// as '?'
+func WriteText(lines []string) []byte {
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+
+	pages := make([][]string, 0, len(lines)/textLinesPerPage+1)
+	for len(lines) > textLinesPerPage {
+		pages = append(pages, lines[:textLinesPerPage])
+		lines = lines[textLinesPerPage:]
+	}
+
+	pages = append(pages, lines)
+
+	// 1 catalog, 2 page tree, 3 font, then the page and its contents
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+i*2)
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>",
+			strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+	}
+
+	for i, page := range pages {
+		content := textContent(page)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+				"/Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				textPageWidth, textPageHeight, 5+i*2),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		)
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteString("%PDF-1.4\n")
+
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(objects)+1, xref)
+
+	return buf.Bytes()
+}
+
+func textContent(lines []string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "BT /F1 %d Tf %d TL %d %d Td",
+		textFontSize, textLeading, textMargin, textPageHeight-textMargin-textFontSize)
+
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString(" T*")
+		}
+
+		b.WriteString(" (")
+		writeLiteralString(&b, line)
+		b.WriteString(") Tj")
+	}
+
+	b.WriteString(" ET")
+
+	return b.String()
+}
+
+func writeLiteralString(b *strings.Builder, s string) {
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteByte(' ')
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+}
@@ -14,8 +14,37 @@ import (
 var (
 	RDB          *redis.Client
 	RedisEnabled = false
+
+	// the clients of the separate logical dbs, nil when they share RDB
+	rateLimitRDB *redis.Client
+	cacheRDB     *redis.Client
+	ipBlackRDB   *redis.Client
 )
 
+// RateLimitRDB returns the client of the rate limit records
+func RateLimitRDB() *redis.Client {
+	if rateLimitRDB != nil {
+		return rateLimitRDB
+	}
+	return RDB
+}
+
+// CacheRDB returns the client of the response and embedding caches
+func CacheRDB() *redis.Client {
+	if cacheRDB != nil {
+		return cacheRDB
+	}
+	return RDB
+}
+
+// IPBlackRDB returns the client of the ip black list
+func IPBlackRDB() *redis.Client {
+	if ipBlackRDB != nil {
+		return ipBlackRDB
+	}
+	return RDB
+}
+
 const defaultRedisKeyPrefix = "aiproxy"
 
 func RedisKeyPrefix() string {
@@ -44,6 +73,27 @@ func RedisKey(keys ...string) string {
 	return RedisKeyPrefix() + ":" + strings.Join(keys, ":")
 }
 
+// validRedisKeyPrefix reports whether the prefix can be used in the SCAN
+// patterns, the glob characters would match the keys of other namespaces
+func validRedisKeyPrefix(prefix string) bool {
+	return !strings.ContainsAny(prefix, "*?[]\\ \t\r\n")
+}
+
+// newRedisDBClient creates the client of a separate logical db, it shares the
+// failover dialer of the main client but has its own connection pool
+func newRedisDBClient(opt *redis.Options, name string, db int64) *redis.Client {
+	if db < 0 || int(db) == opt.DB {
+		return nil
+	}
+
+	dbOpt := *opt
+	dbOpt.DB = int(db)
+
+	log.Infof("redis %s uses the db %d", name, db)
+
+	return redis.NewClient(&dbOpt)
+}
+
 // InitRedisClient This function is called after init()
 func InitRedisClient() (err error) {
 	redisConn := config.Redis
@@ -52,16 +102,35 @@ func InitRedisClient() (err error) {
 		return nil
 	}
 
+	if !validRedisKeyPrefix(config.RedisKeyPrefix) {
+		log.Fatalf("invalid redis key prefix: %q", config.RedisKeyPrefix)
+	}
+
 	RedisEnabled = true
 
-	log.Info("redis is enabled")
+	log.Infof("redis is enabled, key prefix: %s", RedisKeyPrefix())
 
 	opt, err := redis.ParseURL(redisConn)
 	if err != nil {
 		log.Fatal("failed to parse redis connection string: " + err.Error())
 	}
 
+	var standbyOpt *redis.Options
+	if config.RedisStandby != "" {
+		standbyOpt, err = redis.ParseURL(config.RedisStandby)
+		if err != nil {
+			log.Fatal("failed to parse standby redis connection string: " + err.Error())
+		}
+
+		log.Info("standby redis is enabled")
+	}
+
+	setupRedisFailover(opt, standbyOpt)
+
 	RDB = redis.NewClient(opt)
+	rateLimitRDB = newRedisDBClient(opt, "rate limit", config.RedisRateLimitDB)
+	cacheRDB = newRedisDBClient(opt, "cache", config.RedisCacheDB)
+	ipBlackRDB = newRedisDBClient(opt, "ip black list", config.RedisIPBlackDB)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -0,0 +1,402 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	redisWatchInterval = 2 * time.Second
+	redisPingTimeout   = time.Second
+	// redisDownThreshold is the consecutive failed pings before redis is
+	// marked down and the standby is tried
+	redisDownThreshold = 2
+	// maxRedisStaleKeys bounds the keys recorded while redis is down, the keys
+	// over the bound expire by their ttl instead of being reconciled
+	maxRedisStaleKeys     = 100000
+	redisReconcileBatch   = 500
+	redisReconcileTimeout = 30 * time.Second
+)
+
+var (
+	redisDown     atomic.Bool
+	redisFailover *failover
+
+	redisStaleMu       sync.Mutex
+	redisStaleKeys     = make(map[string]struct{})
+	redisStaleOverflow bool
+
+	redisCacheNamespacesMu sync.Mutex
+	redisCacheNamespaces   []string
+)
+
+// RedisAvailable reports whether redis is enabled and healthy, the callers
+// use their in-memory fallback without waiting for redis when it is down
+func RedisAvailable() bool {
+	return RedisEnabled && !redisDown.Load()
+}
+
+// MarkRedisStale records the cache keys whose writes did not reach redis, the
+// keys are deleted from redis once it recovers so the values from before the
+// writes are not served
+func MarkRedisStale(keys ...string) {
+	if !RedisEnabled || len(keys) == 0 {
+		return
+	}
+
+	redisStaleMu.Lock()
+	defer redisStaleMu.Unlock()
+
+	for _, key := range keys {
+		if len(redisStaleKeys) >= maxRedisStaleKeys {
+			if !redisStaleOverflow {
+				redisStaleOverflow = true
+
+				log.Warnf("too many stale redis keys, the keys over %d expire by their ttl", maxRedisStaleKeys)
+			}
+
+			return
+		}
+
+		redisStaleKeys[key] = struct{}{}
+	}
+}
+
+// RegisterRedisCacheNamespaces registers the key formats of the caches, e.g.
+// token:%s, the keys of the namespaces are flushed when redis fails over to
+// another target, as the values there were written before the switch and the
+// writes made on the previous target since then are missing
+func RegisterRedisCacheNamespaces(formats ...string) {
+	redisCacheNamespacesMu.Lock()
+	defer redisCacheNamespacesMu.Unlock()
+
+	redisCacheNamespaces = append(redisCacheNamespaces, formats...)
+}
+
+// redisCacheNamespacePattern converts the key format to the scan pattern
+func redisCacheNamespacePattern(format string) string {
+	return RedisKey(strings.NewReplacer("%s", "*", "%d", "*").Replace(format))
+}
+
+// RedisHealth is the state of redis on this instance
+type RedisHealth struct {
+	Enabled   bool   `json:"enabled"`
+	Available bool   `json:"available"`
+	Active    string `json:"active,omitempty"`
+	StaleKeys int    `json:"stale_keys"`
+}
+
+func GetRedisHealth() RedisHealth {
+	redisStaleMu.Lock()
+	staleKeys := len(redisStaleKeys)
+	redisStaleMu.Unlock()
+
+	health := RedisHealth{
+		Enabled:   RedisEnabled,
+		Available: RedisAvailable(),
+		StaleKeys: staleKeys,
+	}
+
+	if redisFailover != nil {
+		health.Active = redisFailover.active.Load().name
+	}
+
+	return health
+}
+
+// WatchRedis pings redis until the context is done, redis is marked down
+// after the failed pings and the standby takes over when it is configured,
+// the stale keys are reconciled once redis is back
+func WatchRedis(ctx context.Context) {
+	if redisFailover == nil {
+		return
+	}
+
+	ticker := time.NewTicker(redisWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			redisFailover.check(ctx)
+		}
+	}
+}
+
+// redisTarget is a redis server the client connects to, the probe client
+// pings it without going through the pool of the shared client
+type redisTarget struct {
+	name  string
+	opt   *redis.Options
+	dial  func(ctx context.Context, network, addr string) (net.Conn, error)
+	probe *redis.Client
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func newRedisTarget(name string, opt *redis.Options) *redisTarget {
+	probeOpt := *opt
+	probeOpt.PoolSize = 1
+	probeOpt.MaxRetries = -1
+	probeOpt.DialTimeout = redisPingTimeout
+
+	dialOpt := *opt
+	if dialOpt.DialTimeout == 0 {
+		dialOpt.DialTimeout = 5 * time.Second
+	}
+
+	return &redisTarget{
+		name:  name,
+		opt:   opt,
+		dial:  redis.NewDialer(&dialOpt),
+		probe: redis.NewClient(&probeOpt),
+		conns: make(map[net.Conn]struct{}),
+	}
+}
+
+func (t *redisTarget) ping(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, redisPingTimeout)
+	defer cancel()
+
+	return t.probe.Ping(ctx).Err() == nil
+}
+
+// closeConns closes the connections of the shared client to the target, the
+// pool drops them and dials the active target instead
+func (t *redisTarget) closeConns() {
+	t.mu.Lock()
+	conns := make([]net.Conn, 0, len(t.conns))
+
+	for conn := range t.conns {
+		conns = append(conns, conn)
+	}
+	t.mu.Unlock()
+
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+}
+
+type trackedConn struct {
+	net.Conn
+	target *redisTarget
+	once   sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.target.mu.Lock()
+		delete(c.target.conns, c)
+		c.target.mu.Unlock()
+	})
+
+	return c.Conn.Close()
+}
+
+// SyscallConn lets the pool check the health of the idle connections, so the
+// closed ones are dropped before they are used
+func (c *trackedConn) SyscallConn() (syscall.RawConn, error) {
+	sc, ok := c.Conn.(syscall.Conn)
+	if !ok {
+		return nil, errors.New("connection does not support syscall")
+	}
+
+	return sc.SyscallConn()
+}
+
+// failover routes the connections of the shared client to the active target,
+// the primary is preferred whenever it is healthy
+type failover struct {
+	primary  *redisTarget
+	standby  *redisTarget
+	active   atomic.Pointer[redisTarget]
+	failures int
+}
+
+// setupRedisFailover installs the dialer routing the connections of the
+// client options to the healthy target, the standby is optional
+func setupRedisFailover(opt, standbyOpt *redis.Options) {
+	f := &failover{primary: newRedisTarget("primary", opt)}
+	if standbyOpt != nil {
+		if standbyOpt.DB != opt.DB {
+			log.Warnf("standby redis uses the db %d of the primary instead of %d", opt.DB, standbyOpt.DB)
+		}
+
+		f.standby = newRedisTarget("standby", standbyOpt)
+	}
+
+	f.active.Store(f.primary)
+
+	opt.Dialer = f.dial
+	opt.CredentialsProvider = func() (string, string) {
+		t := f.active.Load()
+		return t.opt.Username, t.opt.Password
+	}
+
+	redisFailover = f
+}
+
+func (f *failover) dial(ctx context.Context, network, _ string) (net.Conn, error) {
+	t := f.active.Load()
+
+	conn, err := t.dial(ctx, network, t.opt.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := &trackedConn{Conn: conn, target: t}
+
+	t.mu.Lock()
+	t.conns[tracked] = struct{}{}
+	t.mu.Unlock()
+
+	return tracked, nil
+}
+
+func (f *failover) check(ctx context.Context) {
+	if f.primary.ping(ctx) {
+		f.failures = 0
+		f.use(ctx, f.primary)
+
+		return
+	}
+
+	f.failures++
+	if f.failures < redisDownThreshold {
+		return
+	}
+
+	if f.standby != nil && f.standby.ping(ctx) {
+		f.use(ctx, f.standby)
+		return
+	}
+
+	if !redisDown.Swap(true) {
+		log.Error("redis is down, the in-memory fallbacks are used")
+	}
+}
+
+// use makes the target active, the stale keys are reconciled when redis was
+// down or the target changed, the caches are flushed when the target changed
+func (f *failover) use(ctx context.Context, t *redisTarget) {
+	previous := f.active.Swap(t)
+	if previous != t {
+		log.Warnf("redis fails over from the %s to the %s", previous.name, t.name)
+		previous.closeConns()
+	}
+
+	wasDown := redisDown.Swap(false)
+	if wasDown {
+		log.Infof("redis %s is back", t.name)
+	}
+
+	if previous != t {
+		flushRedisCaches(ctx)
+	}
+
+	if wasDown || previous != t {
+		reconcileRedis(ctx)
+	}
+}
+
+// flushRedisCaches deletes the keys of the cache namespaces from the active
+// target, the caches are then refilled from the database
+func flushRedisCaches(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, redisReconcileTimeout)
+	defer cancel()
+
+	redisCacheNamespacesMu.Lock()
+	formats := slices.Clone(redisCacheNamespaces)
+	redisCacheNamespacesMu.Unlock()
+
+	flushed := 0
+
+	for _, format := range formats {
+		n, err := flushRedisCache(ctx, redisCacheNamespacePattern(format))
+		flushed += n
+
+		if err != nil {
+			log.Errorf("flush redis cache %s failed: %s", format, err)
+		}
+	}
+
+	log.Infof("flushed %d redis cache keys after the failover", flushed)
+}
+
+func flushRedisCache(ctx context.Context, pattern string) (int, error) {
+	flushed := 0
+	batch := make([]string, 0, redisReconcileBatch)
+
+	iter := RDB.Scan(ctx, 0, pattern, redisReconcileBatch).Iterator()
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) < redisReconcileBatch {
+			continue
+		}
+
+		if err := RDB.Del(ctx, batch...).Err(); err != nil {
+			return flushed, err
+		}
+
+		flushed += len(batch)
+		batch = batch[:0]
+	}
+
+	if err := iter.Err(); err != nil {
+		return flushed, err
+	}
+
+	if len(batch) == 0 {
+		return flushed, nil
+	}
+
+	if err := RDB.Del(ctx, batch...).Err(); err != nil {
+		return flushed, err
+	}
+
+	return flushed + len(batch), nil
+}
+
+// reconcileRedis deletes the stale keys from redis
+func reconcileRedis(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, redisReconcileTimeout)
+	defer cancel()
+
+	redisStaleMu.Lock()
+	keys := make([]string, 0, len(redisStaleKeys))
+
+	for key := range redisStaleKeys {
+		keys = append(keys, key)
+	}
+
+	clear(redisStaleKeys)
+	redisStaleOverflow = false
+	redisStaleMu.Unlock()
+
+	for start := 0; start < len(keys); start += redisReconcileBatch {
+		batch := keys[start:min(start+redisReconcileBatch, len(keys))]
+		if err := RDB.Del(ctx, batch...).Err(); err != nil {
+			log.Errorf("reconcile stale redis keys failed: %s", err)
+			MarkRedisStale(keys[start:]...)
+
+			return
+		}
+	}
+
+	if len(keys) > 0 {
+		log.Infof("reconciled %d stale redis keys", len(keys))
+	}
+}
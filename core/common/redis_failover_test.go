@@ -0,0 +1,34 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/common"
+)
+
+func TestRedisHealthStaleKeys(t *testing.T) {
+	oldRedisEnabled := common.RedisEnabled
+
+	t.Cleanup(func() {
+		common.RedisEnabled = oldRedisEnabled
+	})
+
+	common.RedisEnabled = false
+	common.MarkRedisStale("disabled")
+
+	if health := common.GetRedisHealth(); health.StaleKeys != 0 || health.Available {
+		t.Fatalf("expected no stale keys while redis is disabled, got %+v", health)
+	}
+
+	common.RedisEnabled = true
+	common.MarkRedisStale("token:a", "token:b", "token:a")
+
+	health := common.GetRedisHealth()
+	if !health.Enabled || !health.Available {
+		t.Fatalf("expected redis to be available, got %+v", health)
+	}
+
+	if health.StaleKeys != 2 {
+		t.Fatalf("expected 2 stale keys, got %d", health.StaleKeys)
+	}
+}
@@ -0,0 +1,34 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/common"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisDBClientsShareMainClient(t *testing.T) {
+	oldRDB := common.RDB
+
+	t.Cleanup(func() {
+		common.RDB = oldRDB
+	})
+
+	common.RDB = redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+
+	t.Cleanup(func() {
+		_ = common.RDB.Close()
+	})
+
+	if common.RateLimitRDB() != common.RDB {
+		t.Fatal("expected the rate limit records to share the main client")
+	}
+
+	if common.CacheRDB() != common.RDB {
+		t.Fatal("expected the caches to share the main client")
+	}
+
+	if common.IPBlackRDB() != common.RDB {
+		t.Fatal("expected the ip black list to share the main client")
+	}
+}
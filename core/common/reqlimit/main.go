@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/labring/aiproxy/core/common"
-	"github.com/redis/go-redis/v9"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -36,7 +35,7 @@ func snapshotRateRecord(
 		err          error
 	)
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		rawSnapshots, err = redisRecord.SnapshotByPattern(ctx, duration, keys...)
 		if err != nil {
 			log.Error("redis snapshot error: " + err.Error())
@@ -61,7 +60,7 @@ func snapshotRateRecord(
 
 var (
 	memoryGroupModelLimiter = NewInMemoryRecord()
-	redisGroupModelLimiter  = newRedisGroupModelRecord(func() *redis.Client { return common.RDB })
+	redisGroupModelLimiter  = newRedisGroupModelRecord(common.RateLimitRDB)
 )
 
 func PushGroupModelRequest(
@@ -69,7 +68,7 @@ func PushGroupModelRequest(
 	group, model string,
 	overed int64,
 ) (int64, int64, int64) {
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		count, overLimitCount, secondCount, err := redisGroupModelLimiter.PushRequest(
 			ctx,
 			overed,
@@ -93,7 +92,7 @@ func GetGroupModelRequest(ctx context.Context, group, model string) (int64, int6
 		model = "*"
 	}
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		totalCount, secondCount, err := redisGroupModelLimiter.GetRequest(
 			ctx,
 			time.Minute,
@@ -135,7 +134,7 @@ func GetGroupModelRequestSnapshots(
 var (
 	memoryGroupModelTokennameLimiter = NewInMemoryRecord()
 	redisGroupModelTokennameLimiter  = newRedisGroupModelTokennameRecord(
-		func() *redis.Client { return common.RDB },
+		common.RateLimitRDB,
 	)
 )
 
@@ -143,7 +142,7 @@ func PushGroupModelTokennameRequest(
 	ctx context.Context,
 	group, model, tokenname string,
 ) (int64, int64, int64) {
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		count, overLimitCount, secondCount, err := redisGroupModelTokennameLimiter.PushRequest(
 			ctx,
 			0,
@@ -175,7 +174,7 @@ func GetGroupModelTokennameRequest(
 		tokenname = "*"
 	}
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		totalCount, secondCount, err := redisGroupModelTokennameLimiter.GetRequest(
 			ctx,
 			time.Minute,
@@ -223,12 +222,12 @@ func GetGroupModelTokennameRequestSnapshots(
 var (
 	memoryChannelModelRecord = NewInMemoryRecord()
 	redisChannelModelRecord  = newRedisChannelModelRecord(
-		func() *redis.Client { return common.RDB },
+		common.RateLimitRDB,
 	)
 )
 
 func PushChannelModelRequest(ctx context.Context, channel, model string) (int64, int64, int64) {
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		count, overLimitCount, secondCount, err := redisChannelModelRecord.PushRequest(
 			ctx,
 			0,
@@ -256,7 +255,7 @@ func GetChannelModelRequest(ctx context.Context, channel, model string) (int64,
 		model = "*"
 	}
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		totalCount, secondCount, err := redisChannelModelRecord.GetRequest(
 			ctx,
 			time.Minute,
@@ -276,7 +275,7 @@ func GetChannelModelRequest(ctx context.Context, channel, model string) (int64,
 var (
 	memoryGroupModelTokensLimiter = NewInMemoryRecord()
 	redisGroupModelTokensLimiter  = newRedisGroupModelTokensRecord(
-		func() *redis.Client { return common.RDB },
+		common.RateLimitRDB,
 	)
 )
 
@@ -285,7 +284,7 @@ func PushGroupModelTokensRequest(
 	group, model string,
 	maxTokens, tokens int64,
 ) (int64, int64, int64) {
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		count, overLimitCount, secondCount, err := redisGroupModelTokensLimiter.PushRequest(
 			ctx,
 			maxTokens,
@@ -309,7 +308,7 @@ func GetGroupModelTokensRequest(ctx context.Context, group, model string) (int64
 		model = "*"
 	}
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		totalCount, secondCount, err := redisGroupModelTokensLimiter.GetRequest(
 			ctx,
 			time.Minute,
@@ -351,7 +350,7 @@ func GetGroupModelTokensRequestSnapshots(
 var (
 	memoryGroupModelTokennameTokensLimiter = NewInMemoryRecord()
 	redisGroupModelTokennameTokensLimiter  = newRedisGroupModelTokennameTokensRecord(
-		func() *redis.Client { return common.RDB },
+		common.RateLimitRDB,
 	)
 )
 
@@ -360,7 +359,7 @@ func PushGroupModelTokennameTokensRequest(
 	group, model, tokenname string,
 	tokens int64,
 ) (int64, int64, int64) {
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		count, overLimitCount, secondCount, err := redisGroupModelTokennameTokensLimiter.PushRequest(
 			ctx,
 			0,
@@ -399,7 +398,7 @@ func GetGroupModelTokennameTokensRequest(
 		tokenname = "*"
 	}
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		totalCount, secondCount, err := redisGroupModelTokennameTokensLimiter.GetRequest(
 			ctx,
 			time.Minute,
@@ -447,7 +446,7 @@ func GetGroupModelTokennameTokensRequestSnapshots(
 var (
 	memoryChannelModelTokensRecord = NewInMemoryRecord()
 	redisChannelModelTokensRecord  = newRedisChannelModelTokensRecord(
-		func() *redis.Client { return common.RDB },
+		common.RateLimitRDB,
 	)
 )
 
@@ -456,7 +455,7 @@ func PushChannelModelTokensRequest(
 	channel, model string,
 	tokens int64,
 ) (int64, int64, int64) {
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		count, overLimitCount, secondCount, err := redisChannelModelTokensRecord.PushRequest(
 			ctx,
 			0,
@@ -484,7 +483,7 @@ func GetChannelModelTokensRequest(ctx context.Context, channel, model string) (i
 		model = "*"
 	}
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		totalCount, secondCount, err := redisChannelModelTokensRecord.GetRequest(
 			ctx,
 			time.Minute,
@@ -530,7 +529,7 @@ func GetAllChannelModelRates(ctx context.Context) (map[int64]map[string]ChannelM
 		err              error
 	)
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		requestSnapshots, err = redisChannelModelRecord.Snapshot(ctx, time.Minute)
 		if err != nil {
 			log.Error("redis snapshot request error: " + err.Error())
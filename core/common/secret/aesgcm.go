@@ -0,0 +1,59 @@
+package secret
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+)
+
+const AESGCMName = "aesgcm"
+
+// AESGCM encrypts the secrets with a local aes-256-gcm key, the nonce is
+// prepended to the ciphertext
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+var _ Cipher = (*AESGCM)(nil)
+
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	if len(key) != 32 {
+		return nil, errors.New("aes-gcm key must be 32 bytes")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AESGCM{aead: aead}, nil
+}
+
+func (a *AESGCM) Name() string {
+	return AESGCMName
+}
+
+func (a *AESGCM) Encrypt(_ context.Context, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, a.aead.NonceSize(), a.aead.NonceSize()+len(plaintext)+a.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return a.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (a *AESGCM) Decrypt(_ context.Context, ciphertext []byte) ([]byte, error) {
+	nonceSize := a.aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	return a.aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], nil)
+}
@@ -0,0 +1,207 @@
+package secret
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/env"
+	gcache "github.com/patrickmn/go-cache"
+)
+
+// Cipher encrypts the secrets stored in the database, e.g. the channel keys
+type Cipher interface {
+	Name() string
+	Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// encryptedPrefix marks an encrypted value, the format is enc:<cipher>:<base64 ciphertext>
+const (
+	encryptedPrefix = "enc:"
+	cipherTimeout   = 10 * time.Second
+)
+
+var (
+	ErrCipherNotFound = errors.New("secret cipher not found")
+	// ErrEncryptedPrefix is returned for a value starting with the encrypted
+	// prefix that cannot be decrypted, a plaintext secret like that would be
+	// taken as a ciphertext after it is saved
+	ErrEncryptedPrefix = errors.New(
+		"a plaintext secret must not start with " + encryptedPrefix,
+	)
+
+	ciphersMu sync.RWMutex
+	ciphers   = map[string]Cipher{}
+	// Default encrypts the new secrets, the secrets are stored in plaintext when nil
+	Default Cipher
+
+	// decrypted caches the decrypted secrets so that an external kms is not
+	// called for every request
+	decrypted = gcache.New(10*time.Minute, time.Minute)
+)
+
+// Register registers a cipher to decrypt the secrets encrypted by it, an
+// external kms can be plugged in by registering its cipher and setting Default
+func Register(c Cipher) {
+	ciphersMu.Lock()
+	defer ciphersMu.Unlock()
+
+	ciphers[c.Name()] = c
+}
+
+func getCipher(name string) (Cipher, bool) {
+	ciphersMu.RLock()
+	defer ciphersMu.RUnlock()
+
+	c, ok := ciphers[name]
+
+	return c, ok
+}
+
+// Init sets up the default cipher from the env:
+// SECRET_ENCRYPTION_KEY enables aes-gcm with a base64 encoded 32 bytes key,
+// VAULT_TRANSIT_KEY enables the vault transit engine
+func Init() error {
+	if key := os.Getenv("SECRET_ENCRYPTION_KEY"); key != "" {
+		rawKey, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return fmt.Errorf("invalid SECRET_ENCRYPTION_KEY: %w", err)
+		}
+
+		c, err := NewAESGCM(rawKey)
+		if err != nil {
+			return fmt.Errorf("invalid SECRET_ENCRYPTION_KEY: %w", err)
+		}
+
+		Register(c)
+		Default = c
+
+		return nil
+	}
+
+	if transitKey := os.Getenv("VAULT_TRANSIT_KEY"); transitKey != "" {
+		c, err := NewVaultTransit(
+			os.Getenv("VAULT_ADDR"),
+			os.Getenv("VAULT_TOKEN"),
+			env.String("VAULT_TRANSIT_MOUNT", "transit"),
+			transitKey,
+		)
+		if err != nil {
+			return err
+		}
+
+		Register(c)
+		Default = c
+	}
+
+	return nil
+}
+
+// Enabled reports whether the new secrets are encrypted
+func Enabled() bool {
+	return Default != nil
+}
+
+// IsEncrypted reports whether the value is encrypted by a cipher
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// Verify checks a value before it is saved, the encrypted values read from
+// the api are kept as is so a value starting with the encrypted prefix must
+// be decrypted by a registered cipher, otherwise it is a plaintext secret
+// that collides with the prefix and is rejected
+func Verify(value string) error {
+	if !IsEncrypted(value) {
+		return nil
+	}
+
+	if _, err := Decrypt(value); err != nil {
+		return fmt.Errorf("%w: %w", ErrEncryptedPrefix, err)
+	}
+
+	return nil
+}
+
+// Hash returns the deterministic hash of the plaintext value, the encrypted
+// values are looked up by it since their ciphertext is randomized
+func Hash(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(value))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Encrypt encrypts the value with the default cipher, the value is returned
+// as is when the encryption is disabled or the value is already encrypted
+func Encrypt(value string) (string, error) {
+	if Default == nil || value == "" || IsEncrypted(value) {
+		return value, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cipherTimeout)
+	defer cancel()
+
+	ciphertext, err := Default.Encrypt(ctx, []byte(value))
+	if err != nil {
+		return "", fmt.Errorf("encrypt secret with %s failed: %w", Default.Name(), err)
+	}
+
+	encrypted := encryptedPrefix + Default.Name() + ":" +
+		base64.StdEncoding.EncodeToString(ciphertext)
+
+	decrypted.SetDefault(encrypted, value)
+
+	return encrypted, nil
+}
+
+// Decrypt decrypts the value encrypted by Encrypt, a plaintext value is returned as is
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	if v, ok := decrypted.Get(value); ok {
+		if plaintext, ok := v.(string); ok {
+			return plaintext, nil
+		}
+	}
+
+	name, data, ok := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if !ok {
+		return "", errors.New("invalid encrypted secret")
+	}
+
+	c, ok := getCipher(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrCipherNotFound, name)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted secret: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cipherTimeout)
+	defer cancel()
+
+	plaintext, err := c.Decrypt(ctx, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret with %s failed: %w", name, err)
+	}
+
+	decrypted.SetDefault(value, string(plaintext))
+
+	return string(plaintext), nil
+}
@@ -0,0 +1,119 @@
+package secret_test
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/labring/aiproxy/core/common/secret"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAESGCMRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	c, err := secret.NewAESGCM([]byte(strings.Repeat("k", 32)))
+	require.NoError(t, err)
+
+	ciphertext, err := c.Encrypt(context.Background(), []byte("sk-test"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(ciphertext), "sk-test")
+
+	plaintext, err := c.Decrypt(context.Background(), ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "sk-test", string(plaintext))
+
+	ciphertext[len(ciphertext)-1] ^= 0xff
+	_, err = c.Decrypt(context.Background(), ciphertext)
+	assert.Error(t, err)
+
+	_, err = secret.NewAESGCM([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestDecryptPlaintextPassthrough(t *testing.T) {
+	t.Parallel()
+
+	plaintext, err := secret.Decrypt("sk-plain")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-plain", plaintext)
+
+	_, err = secret.Decrypt("enc:unknown:AAAA")
+	require.ErrorIs(t, err, secret.ErrCipherNotFound)
+}
+
+func TestVerifyRejectsPlaintextWithEncryptedPrefix(t *testing.T) {
+	t.Parallel()
+
+	c, err := secret.NewAESGCM([]byte(strings.Repeat("v", 32)))
+	require.NoError(t, err)
+	secret.Register(c)
+
+	ciphertext, err := c.Encrypt(context.Background(), []byte("sk-test"))
+	require.NoError(t, err)
+
+	require.NoError(t, secret.Verify(""))
+	require.NoError(t, secret.Verify("sk-plain"))
+	require.NoError(
+		t,
+		secret.Verify("enc:"+secret.AESGCMName+":"+base64.StdEncoding.EncodeToString(ciphertext)),
+	)
+
+	for _, value := range []string{
+		"enc:sk-plain",
+		"enc:unknown:c2stdGVzdA==",
+		"enc:" + secret.AESGCMName + ":c2stdGVzdA==",
+	} {
+		require.ErrorIs(t, secret.Verify(value), secret.ErrEncryptedPrefix, value)
+	}
+}
+
+func TestHash(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, secret.Hash(""))
+	assert.Equal(t, secret.Hash("sk-test"), secret.Hash("sk-test"))
+	assert.Len(t, secret.Hash("sk-test"), 64)
+	assert.NotEqual(t, secret.Hash("sk-test"), secret.Hash("sk-test2"))
+}
+
+func TestVaultTransitRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "root", r.Header.Get("X-Vault-Token"))
+
+		var body map[string]string
+		data, _ := io.ReadAll(r.Body)
+		_ = sonic.Unmarshal(data, &body)
+
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/aiproxy":
+			_, _ = io.WriteString(w, `{"data":{"ciphertext":"vault:v1:`+body["plaintext"]+`"}}`)
+		case "/v1/transit/decrypt/aiproxy":
+			_, _ = io.WriteString(w, `{"data":{"plaintext":"`+
+				strings.TrimPrefix(body["ciphertext"], "vault:v1:")+`"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"errors":["not found"]}`)
+		}
+	}))
+	defer server.Close()
+
+	c, err := secret.NewVaultTransit(server.URL, "root", "transit", "aiproxy")
+	require.NoError(t, err)
+
+	ciphertext, err := c.Encrypt(context.Background(), []byte("sk-test"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(ciphertext), "vault:v1:"))
+
+	plaintext, err := c.Decrypt(context.Background(), ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "sk-test", string(plaintext))
+}
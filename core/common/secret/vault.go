@@ -0,0 +1,124 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+const VaultTransitName = "vault"
+
+// VaultTransit encrypts the secrets with the transit secrets engine of vault,
+// the encryption key never leaves vault
+// https://developer.hashicorp.com/vault/api-docs/secret/transit
+type VaultTransit struct {
+	addr   string
+	token  string
+	mount  string
+	key    string
+	client *http.Client
+}
+
+var _ Cipher = (*VaultTransit)(nil)
+
+func NewVaultTransit(addr, token, mount, key string) (*VaultTransit, error) {
+	if addr == "" || token == "" {
+		return nil, errors.New("VAULT_ADDR and VAULT_TOKEN are required by the vault transit cipher")
+	}
+
+	return &VaultTransit{
+		addr:   strings.TrimSuffix(addr, "/"),
+		token:  token,
+		mount:  strings.Trim(mount, "/"),
+		key:    key,
+		client: &http.Client{Timeout: cipherTimeout},
+	}, nil
+}
+
+func (v *VaultTransit) Name() string {
+	return VaultTransitName
+}
+
+type vaultTransitResponse struct {
+	Data struct {
+		Ciphertext string `json:"ciphertext"`
+		Plaintext  string `json:"plaintext"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func (v *VaultTransit) do(
+	ctx context.Context,
+	action string,
+	body map[string]string,
+) (*vaultTransitResponse, error) {
+	reqBody, err := sonic.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := url.JoinPath(v.addr, "v1", v.mount, action, v.key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-Vault-Token", v.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result vaultTransitResponse
+	if err := sonic.ConfigDefault.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("vault transit %s: status %d: %w", action, resp.StatusCode, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(
+			"vault transit %s: status %d: %s",
+			action,
+			resp.StatusCode,
+			strings.Join(result.Errors, "; "),
+		)
+	}
+
+	return &result, nil
+}
+
+func (v *VaultTransit) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	result, err := v.do(ctx, "encrypt", map[string]string{
+		"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the ciphertext is like vault:v1:..., it is stored as is
+	return []byte(result.Data.Ciphertext), nil
+}
+
+func (v *VaultTransit) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	result, err := v.do(ctx, "decrypt", map[string]string{
+		"ciphertext": string(ciphertext),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(result.Data.Plaintext)
+}
@@ -0,0 +1,97 @@
+package tiktoken
+
+import (
+	"errors"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ApproximateEncoding is the name of the approximate codec
+const ApproximateEncoding = "approximate"
+
+// approximateCharsPerToken is the average bytes of a latin word piece in the
+// bpe vocabularies
+const approximateCharsPerToken = 4
+
+var errApproximateDecode = errors.New("approximate codec can not decode tokens")
+
+// approximateCodec counts the tokens by scanning the text once, the words are
+// split into the pieces of approximateCharsPerToken bytes and every other
+// symbol is a token, so no vocabulary is loaded
+type approximateCodec struct{}
+
+func (approximateCodec) GetName() string {
+	return ApproximateEncoding
+}
+
+func (approximateCodec) Count(text string) (int, error) {
+	count := 0
+
+	scanApproximateTokens(text, func(string) {
+		count++
+	})
+
+	return count, nil
+}
+
+// Encode returns the approximate token pieces, the ids are the positions of
+// the pieces as there is no vocabulary
+func (approximateCodec) Encode(text string) ([]uint, []string, error) {
+	var (
+		ids    []uint
+		tokens []string
+	)
+
+	scanApproximateTokens(text, func(token string) {
+		ids = append(ids, uint(len(ids)))
+		tokens = append(tokens, token)
+	})
+
+	return ids, tokens, nil
+}
+
+func (approximateCodec) Decode([]uint) (string, error) {
+	return "", errApproximateDecode
+}
+
+// scanApproximateTokens calls fn with every approximate token of the text, a
+// word keeps its leading space like the bpe tokens do
+func scanApproximateTokens(text string, fn func(token string)) {
+	start := -1
+
+	flush := func(end int) {
+		for start >= 0 && start < end {
+			pieceEnd := min(start+approximateCharsPerToken, end)
+			fn(text[start:pieceEnd])
+			start = pieceEnd
+		}
+
+		start = -1
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+
+		switch {
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if start < 0 {
+				start = i
+			}
+		case r == ' ':
+			flush(i)
+
+			start = i
+		case unicode.IsSpace(r):
+			flush(i)
+		default:
+			// the punctuations and the non latin characters, such as the cjk
+			// characters, are about one token each
+			flush(i)
+			fn(text[i : i+size])
+		}
+
+		i += size
+	}
+
+	flush(len(text))
+}
@@ -1,56 +1,160 @@
 package tiktoken
 
 import (
+	"container/list"
 	"errors"
+	"slices"
 	"sync"
+	"sync/atomic"
 
+	"github.com/labring/aiproxy/core/common/config"
 	log "github.com/sirupsen/logrus"
 	"github.com/tiktoken-go/tokenizer"
+	"golang.org/x/sync/singleflight"
 )
 
-// tokenEncoderMap won't grow after initialization
+// defaultTokenEncoderCacheSize bounds the model encoders, the model names come
+// from the requests so the cache must not grow without limit
+const defaultTokenEncoderCacheSize = 4096
+
 var (
-	tokenEncoderMap     = map[string]tokenizer.Codec{}
-	defaultTokenEncoder tokenizer.Codec
-	tokenEncoderLock    sync.RWMutex
+	tokenEncoders     = newEncoderCache(defaultTokenEncoderCacheSize)
+	tokenEncoderGroup singleflight.Group
+
+	tokenEncoderHits   atomic.Int64
+	tokenEncoderMisses atomic.Int64
 )
 
-func init() {
+// defaultTokenEncoder is loaded on the first use, so the vocabulary is never
+// loaded in the approximate mode
+var defaultTokenEncoder = sync.OnceValue(func() tokenizer.Codec {
 	gpt4oTokenEncoder, err := tokenizer.ForModel(tokenizer.GPT4o)
 	if err != nil {
 		log.Fatal("failed to get gpt-4o token encoder: " + err.Error())
 	}
 
-	defaultTokenEncoder = gpt4oTokenEncoder
+	return gpt4oTokenEncoder
+})
+
+// Approximate reports whether the tokens are counted by the approximate codec,
+// the usage counted by the proxy is estimated in this mode
+func Approximate() bool {
+	return config.TokenizerApproximate
 }
 
-func GetTokenEncoder(model string) tokenizer.Codec {
-	tokenEncoderLock.RLock()
+type encoderEntry struct {
+	model string
+	codec tokenizer.Codec
+}
+
+// encoderCache is a lru of the model encoders, the models of the same encoding
+// share one codec
+type encoderCache struct {
+	mu     sync.Mutex
+	size   int
+	ll     *list.List
+	items  map[string]*list.Element
+	codecs map[string]tokenizer.Codec
+}
 
-	tokenEncoder, ok := tokenEncoderMap[model]
+func newEncoderCache(size int) *encoderCache {
+	return &encoderCache{
+		size:   size,
+		ll:     list.New(),
+		items:  make(map[string]*list.Element),
+		codecs: make(map[string]tokenizer.Codec),
+	}
+}
 
-	tokenEncoderLock.RUnlock()
+func (c *encoderCache) get(model string) (tokenizer.Codec, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	if ok {
-		return tokenEncoder
+	el, ok := c.items[model]
+	if !ok {
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+
+	entry, _ := el.Value.(*encoderEntry)
+
+	return entry.codec, true
+}
+
+func (c *encoderCache) add(model string, codec tokenizer.Codec) tokenizer.Codec {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[model]; ok {
+		c.ll.MoveToFront(el)
+
+		entry, _ := el.Value.(*encoderEntry)
+
+		return entry.codec
+	}
+
+	if shared, ok := c.codecs[codec.GetName()]; ok {
+		codec = shared
+	} else {
+		c.codecs[codec.GetName()] = codec
 	}
 
-	tokenEncoderLock.Lock()
-	defer tokenEncoderLock.Unlock()
+	c.items[model] = c.ll.PushFront(&encoderEntry{model: model, codec: codec})
 
-	if tokenEncoder, ok := tokenEncoderMap[model]; ok {
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+
+		entry, _ := oldest.Value.(*encoderEntry)
+		delete(c.items, entry.model)
+	}
+
+	return codec
+}
+
+func (c *encoderCache) stats() (int, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	encodings := make([]string, 0, len(c.codecs))
+	for name := range c.codecs {
+		encodings = append(encodings, name)
+	}
+
+	slices.Sort(encodings)
+
+	return c.ll.Len(), encodings
+}
+
+func GetTokenEncoder(model string) tokenizer.Codec {
+	if Approximate() {
+		return approximateCodec{}
+	}
+
+	if tokenEncoder, ok := tokenEncoders.get(model); ok {
+		tokenEncoderHits.Add(1)
 		return tokenEncoder
 	}
 
-	log.Info("loading encoding for model " + model)
+	tokenEncoderMisses.Add(1)
 
+	tokenEncoder, _, _ := tokenEncoderGroup.Do(model, func() (any, error) {
+		return tokenEncoders.add(model, loadTokenEncoder(model)), nil
+	})
+
+	codec, _ := tokenEncoder.(tokenizer.Codec)
+
+	return codec
+}
+
+func loadTokenEncoder(model string) tokenizer.Codec {
 	// ForModel has built-in prefix matching for model names
 	tokenEncoder, err := tokenizer.ForModel(tokenizer.Model(model))
 	if err != nil {
 		if errors.Is(err, tokenizer.ErrModelNotSupported) {
-			log.Warnf("model %s not supported, using default encoder (gpt-4o)", model)
-			tokenEncoderMap[model] = defaultTokenEncoder
-			return defaultTokenEncoder
+			log.Debugf("model %s not supported, using default encoder (gpt-4o)", model)
+			return defaultTokenEncoder()
 		}
 
 		log.Errorf(
@@ -58,14 +162,55 @@ func GetTokenEncoder(model string) tokenizer.Codec {
 			model,
 			err,
 		)
-		tokenEncoderMap[model] = defaultTokenEncoder
 
-		return defaultTokenEncoder
+		return defaultTokenEncoder()
 	}
 
-	log.Infof("loaded encoding for model %s: %s", model, tokenEncoder.GetName())
-
-	tokenEncoderMap[model] = tokenEncoder
+	log.Debugf("loaded encoding for model %s: %s", model, tokenEncoder.GetName())
 
 	return tokenEncoder
 }
+
+// PreWarm loads the encoders of the models ahead of the requests, so the first
+// requests of the models do not pay for building the encoders
+func PreWarm(models []string) int {
+	if Approximate() {
+		return 0
+	}
+
+	loaded := 0
+
+	for _, model := range models {
+		if _, ok := tokenEncoders.get(model); ok {
+			continue
+		}
+
+		tokenEncoders.add(model, loadTokenEncoder(model))
+
+		loaded++
+	}
+
+	return loaded
+}
+
+type CacheStats struct {
+	Hits        int64    `json:"hits"`
+	Misses      int64    `json:"misses"`
+	Models      int      `json:"models"`
+	Encodings   []string `json:"encodings"`
+	Approximate bool     `json:"approximate,omitempty"`
+}
+
+// Stats returns the encoder cache stats of this instance, the misses are the
+// requests building the encoder of a model not pre-warmed
+func Stats() CacheStats {
+	models, encodings := tokenEncoders.stats()
+
+	return CacheStats{
+		Hits:        tokenEncoderHits.Load(),
+		Misses:      tokenEncoderMisses.Load(),
+		Models:      models,
+		Encodings:   encodings,
+		Approximate: Approximate(),
+	}
+}
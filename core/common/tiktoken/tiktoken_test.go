@@ -3,6 +3,7 @@ package tiktoken_test
 import (
 	"testing"
 
+	"github.com/labring/aiproxy/core/common/config"
 	"github.com/labring/aiproxy/core/common/tiktoken"
 	"github.com/smartystreets/goconvey/convey"
 )
@@ -50,3 +51,67 @@ func TestEncoding(t *testing.T) {
 		})
 	})
 }
+
+func TestPreWarm(t *testing.T) {
+	convey.Convey("PreWarm", t, func() {
+		convey.Convey("should load the encoders once without counting misses", func() {
+			before := tiktoken.Stats()
+
+			loaded := tiktoken.PreWarm([]string{"prewarm-gpt-4o", "prewarm-gpt-4o", "prewarm-o1"})
+			convey.So(loaded, convey.ShouldEqual, 2)
+
+			enc := tiktoken.GetTokenEncoder("prewarm-gpt-4o")
+			convey.So(enc, convey.ShouldNotBeNil)
+
+			after := tiktoken.Stats()
+			convey.So(after.Misses, convey.ShouldEqual, before.Misses)
+			convey.So(after.Hits, convey.ShouldEqual, before.Hits+1)
+		})
+
+		convey.Convey("should count the misses of the models not pre-warmed", func() {
+			before := tiktoken.Stats()
+
+			tiktoken.GetTokenEncoder("not-prewarmed-model")
+
+			convey.So(tiktoken.Stats().Misses, convey.ShouldEqual, before.Misses+1)
+		})
+
+		convey.Convey("should share the encoder of the same encoding", func() {
+			enc1 := tiktoken.GetTokenEncoder("gpt-4o")
+			enc2 := tiktoken.GetTokenEncoder("gpt-4o-mini")
+			convey.So(enc1.GetName(), convey.ShouldEqual, enc2.GetName())
+			convey.So(enc1, convey.ShouldEqual, enc2)
+		})
+	})
+}
+
+func TestApproximate(t *testing.T) {
+	convey.Convey("Approximate", t, func() {
+		config.TokenizerApproximate = true
+
+		defer func() {
+			config.TokenizerApproximate = false
+		}()
+
+		enc := tiktoken.GetTokenEncoder("gpt-4o")
+		convey.So(enc.GetName(), convey.ShouldEqual, tiktoken.ApproximateEncoding)
+
+		convey.Convey("should count the word pieces and the symbols", func() {
+			count, err := enc.Count("hello world, internationalization!")
+			convey.So(err, convey.ShouldBeNil)
+			// "hell" "o" " wor" "ld" "," " int" "erna" "tion" "aliz" "atio" "n" "!"
+			convey.So(count, convey.ShouldEqual, 12)
+		})
+
+		convey.Convey("should count a cjk character as a token", func() {
+			count, err := enc.Count("你好")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(count, convey.ShouldEqual, 2)
+		})
+
+		convey.Convey("should not pre-warm the encoders", func() {
+			convey.So(tiktoken.PreWarm([]string{"approximate-gpt-4o"}), convey.ShouldEqual, 0)
+			convey.So(tiktoken.Stats().Approximate, convey.ShouldBeTrue)
+		})
+	})
+}
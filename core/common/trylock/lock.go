@@ -61,7 +61,7 @@ func MemLock(key string, expiration time.Duration) bool {
 }
 
 func Lock(key string, expiration time.Duration) bool {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return MemLock(key, expiration)
 	}
 
@@ -0,0 +1,20 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/relay/adaptors"
+)
+
+// GetAdaptors godoc
+//
+//	@Summary		Get adaptor capabilities
+//	@Description	Returns the capability matrix of the adaptors: the supported modes and protocols, the config keys, the optional features and the known limitations, generated from the adaptors
+//	@Tags			channels
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	middleware.APIResponse{data=[]adaptors.AdaptorCapabilities}
+//	@Router			/api/adaptors [get]
+func GetAdaptors(c *gin.Context) {
+	middleware.SuccessResponse(c, adaptors.GetCapabilityMatrix())
+}
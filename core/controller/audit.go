@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/controller/utils"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+)
+
+// GetAuditLogs godoc
+//
+//	@Summary		Get audit logs
+//	@Description	Returns a paginated list of admin api mutations with optional filters
+//	@Tags			audit
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			page			query		int		false	"Page number"
+//	@Param			per_page		query		int		false	"Items per page"
+//	@Param			start_timestamp	query		int		false	"Start timestamp (milliseconds)"
+//	@Param			end_timestamp	query		int		false	"End timestamp (milliseconds)"
+//	@Param			actor			query		string	false	"Actor"
+//	@Param			action			query		string	false	"Action"	Enums(create, update, delete)
+//	@Param			resource		query		string	false	"Resource, e.g. channel, token, group, option, model_config"
+//	@Param			resource_id		query		string	false	"Resource ID"
+//	@Param			request_id		query		string	false	"Request ID"
+//	@Success		200				{object}	middleware.APIResponse{data=model.GetAuditLogsResult}
+//	@Router			/api/audit_logs/ [get]
+func GetAuditLogs(c *gin.Context) {
+	page, perPage := utils.ParsePageParams(c)
+	// audit logs are kept for compliance, so the time range is not limited
+	startTime, endTime := utils.ParseTimeRange(c, -1)
+
+	result, err := model.GetAuditLogs(
+		startTime,
+		endTime,
+		c.Query("actor"),
+		c.Query("action"),
+		c.Query("resource"),
+		c.Query("resource_id"),
+		c.Query("request_id"),
+		page,
+		perPage,
+	)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, result)
+}
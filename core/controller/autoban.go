@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/common/conv"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/monitor"
+)
+
+const (
+	defaultAutoBanDryRunHours = 24
+	maxAutoBanDryRunHours     = 7 * 24
+	defaultAutoBanDryRunLimit = 10000
+	maxAutoBanDryRunLimit     = 100000
+)
+
+// GetAutoBanRules godoc
+//
+//	@Summary		Get auto ban rules
+//	@Description	Returns the rules banning channel models by the relay errors
+//	@Tags			monitor
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	middleware.APIResponse{data=[]config.AutoBanRule}
+//	@Router			/api/monitor/auto_ban_rules [get]
+func GetAutoBanRules(c *gin.Context) {
+	middleware.SuccessResponse(c, config.GetAutoBanRules())
+}
+
+// SaveAutoBanRules godoc
+//
+//	@Summary		Save auto ban rules
+//	@Description	Replaces the auto ban rules, the rules are evaluated in order
+//	@Tags			monitor
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			rules	body		[]config.AutoBanRule	true	"Auto ban rules"
+//	@Success		200		{object}	middleware.APIResponse
+//	@Router			/api/monitor/auto_ban_rules [put]
+func SaveAutoBanRules(c *gin.Context) {
+	var rules []config.AutoBanRule
+	if err := c.ShouldBindJSON(&rules); err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := config.ValidateAutoBanRules(rules); err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	value, err := sonic.Marshal(rules)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := model.UpdateOption("AutoBanRules", conv.BytesToString(value)); err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, nil)
+}
+
+// AutoBanDryRunRequest replays the recent error logs against the rules, the
+// saved rules are used when the rules are omitted
+type AutoBanDryRunRequest struct {
+	Rules []config.AutoBanRule `json:"rules,omitempty"`
+	Hours int                  `json:"hours,omitempty"`
+	Limit int                  `json:"limit,omitempty"`
+}
+
+// DryRunAutoBanRules godoc
+//
+//	@Summary		Dry run auto ban rules
+//	@Description	Replays the recent error logs against the rules and returns the bans they would have executed, nothing is banned
+//	@Tags			monitor
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			request	body		controller.AutoBanDryRunRequest	true	"Dry run request"
+//	@Success		200		{object}	middleware.APIResponse{data=monitor.AutoBanDryRunResult}
+//	@Router			/api/monitor/auto_ban_rules/dry_run [post]
+func DryRunAutoBanRules(c *gin.Context) {
+	var req AutoBanDryRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rules := req.Rules
+	if rules == nil {
+		rules = config.GetAutoBanRules()
+	} else if err := config.ValidateAutoBanRules(rules); err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hours := req.Hours
+	if hours <= 0 {
+		hours = defaultAutoBanDryRunHours
+	}
+
+	hours = min(hours, maxAutoBanDryRunHours)
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultAutoBanDryRunLimit
+	}
+
+	limit = min(limit, maxAutoBanDryRunLimit)
+
+	logs, err := model.GetRecentErrorLogs(
+		time.Now().Add(-time.Duration(hours)*time.Hour),
+		limit,
+	)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	events := make([]monitor.AutoBanEvent, 0, len(logs))
+	for _, log := range logs {
+		events = append(events, monitor.AutoBanEvent{
+			Time:       log.CreatedAt,
+			Model:      log.Model,
+			ChannelID:  int64(log.ChannelID),
+			StatusCode: log.Code,
+			Message:    string(log.Content),
+		})
+	}
+
+	middleware.SuccessResponse(c, monitor.DryRunAutoBanRules(rules, events))
+}
@@ -6,7 +6,6 @@ import (
 	"net/http"
 	"strconv"
 	"sync"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/labring/aiproxy/core/common/notify"
@@ -119,7 +118,9 @@ func UpdateChannelBalance(c *gin.Context) {
 	middleware.SuccessResponse(c, balance)
 }
 
-func updateAllChannelsBalance() error {
+// RefreshAllChannelsBalance updates the balance of the channels with the auto
+// balance check enabled
+func RefreshAllChannelsBalance() error {
 	channels, err := model.GetAllChannels()
 	if err != nil {
 		return err
@@ -172,7 +173,7 @@ func updateAllChannelsBalance() error {
 //	@Success		200	{object}	middleware.APIResponse
 //	@Router			/api/channels/balance [get]
 func UpdateAllChannelsBalance(c *gin.Context) {
-	err := updateAllChannelsBalance()
+	err := RefreshAllChannelsBalance()
 	if err != nil {
 		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -180,11 +181,3 @@ func UpdateAllChannelsBalance(c *gin.Context) {
 
 	middleware.SuccessResponse(c, nil)
 }
-
-func UpdateChannelsBalance(frequency time.Duration) {
-	for {
-		time.Sleep(frequency)
-
-		_ = updateAllChannelsBalance()
-	}
-}
@@ -0,0 +1,367 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/notify"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptors"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+// ChannelModelsDiff is the diff between the models of the channel and the
+// models listed by the upstream
+type ChannelModelsDiff struct {
+	Upstream []string `json:"upstream"`
+	// New are the upstream models not configured in the channel
+	New []string `json:"new"`
+	// Missing are the channel models not listed by the upstream
+	Missing []string `json:"missing"`
+}
+
+type SyncChannelModelsRequest struct {
+	// AddNew adds the new upstream models to the channel
+	AddNew bool `json:"add_new"`
+	// RemoveMissing removes the channel models not listed by the upstream
+	RemoveMissing bool `json:"remove_missing"`
+}
+
+type SyncChannelModelsResult struct {
+	ChannelModelsDiff
+	// Models are the models of the channel after the sync
+	Models []string `json:"models"`
+	// Registered are the model configs created for the new models, the prices
+	// are placeholders and the configs are flagged for review
+	Registered []string `json:"registered"`
+}
+
+func listChannelUpstreamModels(
+	ctx context.Context,
+	channel *model.Channel,
+) ([]string, error) {
+	a, ok := adaptors.GetAdaptor(channel.Type)
+	if !ok {
+		return nil, fmt.Errorf("invalid channel type: %d", channel.Type)
+	}
+
+	lister, ok := a.(adaptor.ModelLister)
+	if !ok {
+		return nil, fmt.Errorf(
+			"channel type %s does not support listing the upstream models",
+			channel.Type.String(),
+		)
+	}
+
+	ch := *channel
+	if ch.BaseURL == "" {
+		ch.BaseURL = a.DefaultBaseURL()
+	}
+
+	return lister.ListModels(ctx, &ch)
+}
+
+// diffChannelModels compares the upstream models with the models of the
+// channel, the channel model is matched by its mapped model name
+func diffChannelModels(channel *model.Channel, upstream []string) ChannelModelsDiff {
+	upstream = slices.Compact(slices.Sorted(slices.Values(upstream)))
+
+	diff := ChannelModelsDiff{
+		Upstream: upstream,
+		New:      []string{},
+		Missing:  []string{},
+	}
+
+	configured := make(map[string]struct{}, len(channel.Models)*2)
+	for _, m := range channel.Models {
+		actual, _ := meta.GetMappedModelName(m, channel.ModelMapping)
+		configured[m] = struct{}{}
+		configured[actual] = struct{}{}
+
+		if _, found := slices.BinarySearch(upstream, actual); !found {
+			diff.Missing = append(diff.Missing, m)
+		}
+	}
+
+	for _, m := range upstream {
+		if _, ok := configured[m]; !ok {
+			diff.New = append(diff.New, m)
+		}
+	}
+
+	slices.Sort(diff.Missing)
+
+	return diff
+}
+
+// guessModelType guesses the type of the model not built in the adaptor by
+// its name
+func guessModelType(modelName string) mode.Mode {
+	name := strings.ToLower(modelName)
+
+	switch {
+	case strings.Contains(name, "rerank"):
+		return mode.Rerank
+	case strings.Contains(name, "embed"),
+		strings.Contains(name, "bge-"):
+		return mode.Embeddings
+	case strings.Contains(name, "moderation"):
+		return mode.Moderations
+	case strings.Contains(name, "whisper"),
+		strings.Contains(name, "transcribe"):
+		return mode.AudioTranscription
+	case strings.Contains(name, "tts"):
+		return mode.AudioSpeech
+	case strings.Contains(name, "dall-e"),
+		strings.Contains(name, "gpt-image"):
+		return mode.ImagesGenerations
+	default:
+		return mode.ChatCompletions
+	}
+}
+
+// newSyncedModelConfig creates the model config of a model discovered from the
+// upstream, the built in config of the adaptor is used when it exists,
+// otherwise the price is left empty as a placeholder
+func newSyncedModelConfig(channelType model.ChannelType, modelName string) model.ModelConfig {
+	config := model.NewDefaultModelConfig(modelName)
+
+	if a, ok := adaptors.GetAdaptor(channelType); ok {
+		for _, builtin := range a.Metadata().Models {
+			if builtin.Model == modelName {
+				config = builtin
+				break
+			}
+		}
+	}
+
+	if config.Type == mode.Unknown {
+		config.Type = guessModelType(modelName)
+	}
+
+	config.NeedsReview = true
+
+	return config
+}
+
+// registerSyncedModelConfigs creates the model configs of the models without
+// one, the existing configs are kept
+func registerSyncedModelConfigs(
+	channelType model.ChannelType,
+	models []string,
+) ([]string, error) {
+	_, missing, err := model.GetModelConfigWithModels(models)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(missing) == 0 {
+		return []string{}, nil
+	}
+
+	configs := make([]model.ModelConfig, 0, len(missing))
+	for _, m := range missing {
+		configs = append(configs, newSyncedModelConfig(channelType, m))
+	}
+
+	if err := model.SaveModelConfigs(configs); err != nil {
+		return nil, err
+	}
+
+	slices.Sort(missing)
+
+	return missing, nil
+}
+
+func syncChannelModels(
+	ctx context.Context,
+	channel *model.Channel,
+	req SyncChannelModelsRequest,
+) (SyncChannelModelsResult, error) {
+	upstream, err := listChannelUpstreamModels(ctx, channel)
+	if err != nil {
+		return SyncChannelModelsResult{}, err
+	}
+
+	result := SyncChannelModelsResult{
+		ChannelModelsDiff: diffChannelModels(channel, upstream),
+		Models:            slices.Clone(channel.Models),
+		Registered:        []string{},
+	}
+
+	changed := false
+
+	if req.RemoveMissing && len(result.Missing) > 0 {
+		result.Models = slices.DeleteFunc(result.Models, func(m string) bool {
+			_, found := slices.BinarySearch(result.Missing, m)
+			return found
+		})
+		changed = true
+	}
+
+	if req.AddNew && len(result.New) > 0 {
+		result.Registered, err = registerSyncedModelConfigs(channel.Type, result.New)
+		if err != nil {
+			return result, err
+		}
+
+		result.Models = append(result.Models, result.New...)
+		changed = true
+	}
+
+	if !changed {
+		return result, nil
+	}
+
+	if err := model.UpdateChannelModels(channel.ID, result.Models); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func getSyncChannel(c *gin.Context) (*model.Channel, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	channel, err := model.GetChannelByID(id)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return nil, false
+	}
+
+	return channel, true
+}
+
+// DiffChannelModels godoc
+//
+//	@Summary		Diff channel models
+//	@Description	Lists the upstream models of the channel and diffs them against the configured models
+//	@Tags			channel
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id	path		int	true	"Channel ID"
+//	@Success		200	{object}	middleware.APIResponse{data=ChannelModelsDiff}
+//	@Router			/api/channel/{id}/models/sync [get]
+func DiffChannelModels(c *gin.Context) {
+	channel, ok := getSyncChannel(c)
+	if !ok {
+		return
+	}
+
+	upstream, err := listChannelUpstreamModels(c.Request.Context(), channel)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, diffChannelModels(channel, upstream))
+}
+
+// SyncChannelModels godoc
+//
+//	@Summary		Sync channel models
+//	@Description	Syncs the models of the channel with the upstream, the new models without a model config are registered with placeholder prices and flagged for review
+//	@Tags			channel
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id		path		int							true	"Channel ID"
+//	@Param			request	body		SyncChannelModelsRequest	true	"Sync options"
+//	@Success		200		{object}	middleware.APIResponse{data=SyncChannelModelsResult}
+//	@Router			/api/channel/{id}/models/sync [post]
+func SyncChannelModels(c *gin.Context) {
+	channel, ok := getSyncChannel(c)
+	if !ok {
+		return
+	}
+
+	var req SyncChannelModelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := syncChannelModels(c.Request.Context(), channel, req)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, result)
+}
+
+// SyncAllChannelsModels adds the new upstream models to the channels with
+// the auto model sync enabled, the missing models are never removed
+func SyncAllChannelsModels(ctx context.Context) error {
+	channels, err := model.GetAllChannels()
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+
+	semaphore := make(chan struct{}, 10)
+
+	for _, channel := range channels {
+		if !channel.EnabledAutoModelSync || channel.Status != model.ChannelStatusEnabled {
+			continue
+		}
+
+		wg.Add(1)
+
+		semaphore <- struct{}{}
+
+		go func(ch *model.Channel) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			result, err := syncChannelModels(ctx, ch, SyncChannelModelsRequest{AddNew: true})
+			if err != nil && !errors.Is(err, context.Canceled) {
+				notify.ErrorThrottle(
+					"syncChannelModels:"+strconv.Itoa(ch.ID),
+					time.Hour,
+					fmt.Sprintf(
+						"sync channel %s (type: %d, id: %d) models error",
+						ch.Name,
+						ch.Type,
+						ch.ID,
+					),
+					err.Error(),
+				)
+
+				return
+			}
+
+			if len(result.Registered) > 0 {
+				notify.Info(
+					fmt.Sprintf(
+						"channel %s (type: %d, id: %d) synced new models",
+						ch.Name,
+						ch.Type,
+						ch.ID,
+					),
+					"model configs registered for review: "+strings.Join(result.Registered, ", "),
+				)
+			}
+		}(channel)
+	}
+
+	wg.Wait()
+
+	return nil
+}
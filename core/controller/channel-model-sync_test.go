@@ -0,0 +1,56 @@
+//nolint:testpackage
+package controller
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffChannelModels(t *testing.T) {
+	t.Parallel()
+
+	channel := &model.Channel{
+		Models: []string{"gpt-4o", "gpt-4", "legacy"},
+		ModelMapping: map[string]string{
+			"gpt-4": "gpt-4-0613",
+		},
+	}
+
+	diff := diffChannelModels(channel, []string{
+		"gpt-4o-mini",
+		"gpt-4o",
+		"gpt-4-0613",
+		"text-embedding-3-small",
+		"gpt-4o",
+	})
+
+	assert.Equal(
+		t,
+		[]string{"gpt-4-0613", "gpt-4o", "gpt-4o-mini", "text-embedding-3-small"},
+		diff.Upstream,
+	)
+	assert.Equal(t, []string{"gpt-4o-mini", "text-embedding-3-small"}, diff.New)
+	assert.Equal(t, []string{"legacy"}, diff.Missing)
+}
+
+func TestGuessModelType(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]mode.Mode{
+		"gpt-4o-mini":            mode.ChatCompletions,
+		"text-embedding-3-large": mode.Embeddings,
+		"BAAI/bge-m3":            mode.Embeddings,
+		"bge-reranker-v2-m3":     mode.Rerank,
+		"whisper-1":              mode.AudioTranscription,
+		"tts-1-hd":               mode.AudioSpeech,
+		"gpt-image-1":            mode.ImagesGenerations,
+		"omni-moderation-latest": mode.Moderations,
+	}
+
+	for modelName, expected := range tests {
+		assert.Equal(t, expected, guessModelType(modelName), modelName)
+	}
+}
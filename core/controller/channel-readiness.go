@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/monitor"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptors"
+	log "github.com/sirupsen/logrus"
+)
+
+// readinessProbeTimeout bounds the probe of a single channel
+const readinessProbeTimeout = 5 * time.Second
+
+// enabledChannels returns the enabled channels of the model caches once
+func enabledChannels(mc *model.ModelCaches) []*model.Channel {
+	seen := make(map[int]struct{})
+
+	var channels []*model.Channel
+
+	for _, models := range mc.EnabledModel2ChannelsBySet {
+		for _, modelChannels := range models {
+			for _, channel := range modelChannels {
+				if _, ok := seen[channel.ID]; ok {
+					continue
+				}
+
+				seen[channel.ID] = struct{}{}
+				channels = append(channels, channel)
+			}
+		}
+	}
+
+	return channels
+}
+
+// ProbeChannelsReadiness probes the enabled channels whose adaptor reports the
+// readiness of the upstream, the channels not ready are skipped by the channel
+// selection of this instance until the ttl is over or a probe finds them ready
+func ProbeChannelsReadiness(ctx context.Context, ttl time.Duration) {
+	var wg sync.WaitGroup
+
+	semaphore := make(chan struct{}, 10)
+
+	for _, channel := range enabledChannels(model.LoadModelCaches()) {
+		a, ok := adaptors.GetAdaptor(channel.Type)
+		if !ok {
+			continue
+		}
+
+		prober, ok := a.(adaptor.ReadinessProber)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+
+		semaphore <- struct{}{}
+
+		go func(ch *model.Channel) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			probeCtx, cancel := context.WithTimeout(ctx, readinessProbeTimeout)
+			defer cancel()
+
+			readiness, err := prober.ProbeReadiness(probeCtx, ch)
+			if err != nil {
+				if !errors.Is(err, context.Canceled) {
+					log.Warnf(
+						"probe channel %s (type: %d, id: %d) readiness failed: %v",
+						ch.Name,
+						ch.Type,
+						ch.ID,
+						err,
+					)
+				}
+
+				return
+			}
+
+			if readiness.Ready {
+				monitor.SetChannelReady(int64(ch.ID))
+				return
+			}
+
+			log.Debugf(
+				"channel %s (type: %d, id: %d) not ready: %s",
+				ch.Name,
+				ch.Type,
+				ch.ID,
+				readiness.Reason,
+			)
+			monitor.SetChannelNotReady(int64(ch.ID), readiness.Reason, ttl)
+		}(channel)
+	}
+
+	wg.Wait()
+}
@@ -101,6 +101,10 @@ func testSingleModel(
 		}, nil
 	}
 
+	if err := channel.CheckSecrets(); err != nil {
+		return nil, err
+	}
+
 	body, m, err := utils.BuildRequest(modelConfig)
 	if err != nil {
 		return nil, err
@@ -11,6 +11,7 @@ import (
 
 	"github.com/bytedance/sonic"
 	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/secret"
 	"github.com/labring/aiproxy/core/controller/utils"
 	"github.com/labring/aiproxy/core/middleware"
 	"github.com/labring/aiproxy/core/model"
@@ -60,19 +61,42 @@ func (c *ChannelResponse) MarshalJSON() ([]byte, error) {
 	})
 }
 
-func buildChannelResponse(channel *model.Channel) *ChannelResponse {
+func buildChannelResponse(c *gin.Context, channel *model.Channel) *ChannelResponse {
 	lastRequestAt, _ := model.GetChannelLastRequestTimeMinute(channel.ID)
 
+	if !middleware.IsAdminRole(c) {
+		masked := *channel
+		masked.Key = middleware.MaskKey(masked.Key)
+		masked.Configs = maskChannelConfigs(masked.Configs)
+		channel = &masked
+	}
+
 	return &ChannelResponse{
 		Channel:    channel,
 		AccessedAt: lastRequestAt,
 	}
 }
 
-func buildChannelResponses(channels []*model.Channel) []*ChannelResponse {
+// maskChannelConfigs hides the config values from the non-admin roles, the
+// configs hold credentials such as the aws and vertex keys, only the names of
+// the configured keys are kept
+func maskChannelConfigs(configs model.ChannelConfigs) model.ChannelConfigs {
+	if len(configs) == 0 {
+		return configs
+	}
+
+	masked := make(model.ChannelConfigs, len(configs))
+	for k := range configs {
+		masked[k] = "*****"
+	}
+
+	return masked
+}
+
+func buildChannelResponses(c *gin.Context, channels []*model.Channel) []*ChannelResponse {
 	responses := make([]*ChannelResponse, len(channels))
 	for i, channel := range channels {
-		responses[i] = buildChannelResponse(channel)
+		responses[i] = buildChannelResponse(c, channel)
 	}
 
 	return responses
@@ -120,7 +144,7 @@ func GetChannels(c *gin.Context) {
 	}
 
 	middleware.SuccessResponse(c, gin.H{
-		"channels": buildChannelResponses(channels),
+		"channels": buildChannelResponses(c, channels),
 		"total":    total,
 	})
 }
@@ -141,7 +165,7 @@ func GetAllChannels(c *gin.Context) {
 		return
 	}
 
-	middleware.SuccessResponse(c, buildChannelResponses(channels))
+	middleware.SuccessResponse(c, buildChannelResponses(c, channels))
 }
 
 // AddChannels godoc
@@ -229,7 +253,7 @@ func SearchChannels(c *gin.Context) {
 	}
 
 	middleware.SuccessResponse(c, gin.H{
-		"channels": buildChannelResponses(channels),
+		"channels": buildChannelResponses(c, channels),
 		"total":    total,
 	})
 }
@@ -257,13 +281,16 @@ func GetChannel(c *gin.Context) {
 		return
 	}
 
-	middleware.SuccessResponse(c, buildChannelResponse(channel))
+	middleware.SuccessResponse(c, buildChannelResponse(c, channel))
 }
 
 // AddChannelRequest represents the request body for adding a channel
 type AddChannelRequest struct {
 	ModelMapping            map[string]string    `json:"model_mapping"`
 	Configs                 model.ChannelConfigs `json:"configs"`
+	ParamOverrides          model.ParamOverrides `json:"param_overrides"`
+	URLTemplates            model.URLTemplates   `json:"url_templates"`
+	AllowedModes            model.AllowedModes   `json:"allowed_modes"`
 	Name                    string               `json:"name"`
 	Key                     string               `json:"key"`
 	BaseURL                 string               `json:"base_url"`
@@ -274,10 +301,16 @@ type AddChannelRequest struct {
 	Status                  int                  `json:"status"`
 	Sets                    []string             `json:"sets"`
 	EnabledAutoBalanceCheck bool                 `json:"enabled_auto_balance_check"`
+	EnabledAutoModelSync    bool                 `json:"enabled_auto_model_sync"`
 	SkipTLSVerify           bool                 `json:"skip_tls_verify"`
 	EnabledNoPermissionBan  bool                 `json:"enabled_no_permission_ban"`
 	WarnErrorRate           float64              `json:"warn_error_rate"`
 	MaxErrorRate            float64              `json:"max_error_rate"`
+	BreakerErrorRate        float64              `json:"breaker_error_rate"`
+	BreakerLatencySLOMs     int64                `json:"breaker_latency_slo_ms"`
+	MaxConcurrentStreams    int                  `json:"max_concurrent_streams"` // per instance
+	Region                  string               `json:"region"`
+	DataResidency           []string             `json:"data_residency"`
 }
 
 func (r *AddChannelRequest) ToChannel() (*model.Channel, error) {
@@ -286,9 +319,41 @@ func (r *AddChannelRequest) ToChannel() (*model.Channel, error) {
 		return nil, fmt.Errorf("invalid channel type: %d", r.Type)
 	}
 
+	if r.MaxConcurrentStreams < 0 {
+		return nil, fmt.Errorf("%s max concurrent streams must not be negative", r.Name)
+	}
+
+	if err := r.URLTemplates.Validate(); err != nil {
+		return nil, fmt.Errorf("%s invalid url templates: %w", r.Name, err)
+	}
+
+	if err := r.AllowedModes.Validate(); err != nil {
+		return nil, fmt.Errorf("%s invalid allowed modes: %w", r.Name, err)
+	}
+
+	for key := range strings.SplitSeq(r.Key, "\n") {
+		if err := secret.Verify(key); err != nil {
+			return nil, fmt.Errorf("%s invalid key: %w", r.Name, err)
+		}
+	}
+
+	for name, value := range r.Configs {
+		if v, ok := value.(string); ok {
+			if err := secret.Verify(v); err != nil {
+				return nil, fmt.Errorf("%s invalid config %s: %w", r.Name, name, err)
+			}
+		}
+	}
+
 	metadata := a.Metadata()
 	if validator := adaptors.GetKeyValidator(a); validator != nil {
-		err := validator.ValidateKey(r.Key)
+		// the key read from the api is encrypted when the secret encryption is enabled
+		key, err := secret.Decrypt(r.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%s decrypt key failed: %w", r.Name, err)
+		}
+
+		err = validator.ValidateKey(key)
 		if err != nil {
 			keyHelp := metadata.KeyHelp
 			if keyHelp == "" {
@@ -323,12 +388,21 @@ func (r *AddChannelRequest) ToChannel() (*model.Channel, error) {
 		Priority:                r.Priority,
 		Status:                  r.Status,
 		Configs:                 r.Configs,
+		ParamOverrides:          r.ParamOverrides,
+		URLTemplates:            maps.Clone(r.URLTemplates),
+		AllowedModes:            slices.Clone(r.AllowedModes),
 		Sets:                    slices.Clone(r.Sets),
 		EnabledAutoBalanceCheck: r.EnabledAutoBalanceCheck,
+		EnabledAutoModelSync:    r.EnabledAutoModelSync,
 		SkipTLSVerify:           r.SkipTLSVerify,
 		EnabledNoPermissionBan:  r.EnabledNoPermissionBan,
 		WarnErrorRate:           r.WarnErrorRate,
 		MaxErrorRate:            r.MaxErrorRate,
+		BreakerErrorRate:        r.BreakerErrorRate,
+		BreakerLatencySLOMs:     r.BreakerLatencySLOMs,
+		MaxConcurrentStreams:    r.MaxConcurrentStreams,
+		Region:                  strings.ToUpper(strings.TrimSpace(r.Region)),
+		DataResidency:           slices.Clone(r.DataResidency),
 	}, nil
 }
 
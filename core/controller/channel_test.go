@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/labring/aiproxy/core/common/secret"
 	"github.com/labring/aiproxy/core/model"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
@@ -118,3 +119,35 @@ func TestRunAutoTestBannedModelsClearsWhenModelRemovedFromChannel(t *testing.T)
 	require.Equal(t, int32(1), cleared.Load())
 	require.Equal(t, int64(123), clearedChannel.Load())
 }
+
+func TestMaskChannelConfigs(t *testing.T) {
+	configs := model.ChannelConfigs{"region": "us-east-1", "ak": "id", "sk": "secret"}
+
+	masked := maskChannelConfigs(configs)
+	require.Equal(t, model.ChannelConfigs{"region": "*****", "ak": "*****", "sk": "*****"}, masked)
+	// the configs of the cached channel are not changed
+	require.Equal(t, "secret", configs["sk"])
+
+	require.Nil(t, maskChannelConfigs(nil))
+}
+
+func TestToChannelRejectsPlaintextWithEncryptedPrefix(t *testing.T) {
+	req := AddChannelRequest{
+		Type: model.ChannelTypeOpenAI,
+		Name: "openai",
+		Key:  "sk-test\nenc:sk-plain",
+	}
+
+	_, err := req.ToChannels()
+	require.ErrorIs(t, err, secret.ErrEncryptedPrefix)
+
+	req.Key = "sk-test"
+	req.Configs = model.ChannelConfigs{"client_secret": "enc:secret"}
+	_, err = req.ToChannel()
+	require.ErrorIs(t, err, secret.ErrEncryptedPrefix)
+
+	req.Configs = model.ChannelConfigs{"client_secret": "secret"}
+	ch, err := req.ToChannel()
+	require.NoError(t, err)
+	require.Equal(t, "sk-test", ch.Key)
+}
@@ -0,0 +1,655 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// ConfigSyncChannel is the desired state of a channel, identified by its name
+type ConfigSyncChannel struct {
+	AddChannelRequest
+	// TypeName is an alternative to the type, e.g. openai or claude
+	TypeName string `json:"type_name,omitempty"`
+}
+
+// ConfigSyncToken is the desired state of a token, identified by the group
+// and its name, the key is only used when the token is created
+type ConfigSyncToken struct {
+	AddTokenRequest
+	Group  string `json:"group"`
+	Key    string `json:"key,omitempty"`
+	Status int    `json:"status,omitempty"`
+}
+
+// ConfigSyncSpec is the desired state pushed by an operator, the channels,
+// model configs and tokens created by the sync are deleted once they leave it,
+// the other resources are only updated when they are listed
+type ConfigSyncSpec struct {
+	Channels     []ConfigSyncChannel `json:"channels,omitempty"`
+	ModelConfigs []model.ModelConfig `json:"model_configs,omitempty"`
+	Tokens       []ConfigSyncToken   `json:"tokens,omitempty"`
+}
+
+type ConfigSyncChanges struct {
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Deleted   []string `json:"deleted"`
+	Unchanged []string `json:"unchanged"`
+}
+
+type ConfigSyncResult struct {
+	DryRun       bool              `json:"dry_run"`
+	Channels     ConfigSyncChanges `json:"channels"`
+	ModelConfigs ConfigSyncChanges `json:"model_configs"`
+	Tokens       ConfigSyncChanges `json:"tokens"`
+}
+
+func newConfigSyncChanges() ConfigSyncChanges {
+	return ConfigSyncChanges{
+		Created:   []string{},
+		Updated:   []string{},
+		Deleted:   []string{},
+		Unchanged: []string{},
+	}
+}
+
+// ParseConfigSyncSpec parses the desired state of json or yaml
+func ParseConfigSyncSpec(data []byte, contentType string) (*ConfigSyncSpec, error) {
+	if strings.Contains(contentType, "yaml") {
+		var raw any
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("invalid yaml: %w", err)
+		}
+
+		var err error
+
+		data, err = sonic.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var spec ConfigSyncSpec
+	if err := sonic.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid config sync spec: %w", err)
+	}
+
+	return &spec, nil
+}
+
+func configSyncHash(v any) (string, error) {
+	data, err := sonic.ConfigStd.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func configSyncTokenName(group, name string) string {
+	return group + "/" + name
+}
+
+// validate checks the whole spec before anything is changed
+func (s *ConfigSyncSpec) validate() error {
+	channelNames := make(map[string]struct{}, len(s.Channels))
+
+	for i := range s.Channels {
+		ch := &s.Channels[i]
+		if ch.Name == "" {
+			return fmt.Errorf("channel %d: name is required", i)
+		}
+
+		if _, ok := channelNames[ch.Name]; ok {
+			return fmt.Errorf("channel %s: duplicated name", ch.Name)
+		}
+
+		channelNames[ch.Name] = struct{}{}
+
+		if ch.Type == 0 && ch.TypeName != "" {
+			ch.Type = model.ChannelType(model.ChannelTypeNameToType(ch.TypeName))
+		}
+
+		if strings.Contains(strings.TrimSpace(ch.Key), "\n") {
+			return fmt.Errorf("channel %s: a synced channel has a single key", ch.Name)
+		}
+
+		if _, err := ch.ToChannel(); err != nil {
+			return fmt.Errorf("channel %s: %w", ch.Name, err)
+		}
+	}
+
+	models := make(map[string]struct{}, len(s.ModelConfigs))
+	for i, mc := range s.ModelConfigs {
+		if mc.Model == "" {
+			return fmt.Errorf("model config %d: model is required", i)
+		}
+
+		if _, ok := models[mc.Model]; ok {
+			return fmt.Errorf("model config %s: duplicated model", mc.Model)
+		}
+
+		models[mc.Model] = struct{}{}
+	}
+
+	tokenNames := make(map[string]struct{}, len(s.Tokens))
+	for i, token := range s.Tokens {
+		if token.Group == "" || token.Name == "" {
+			return fmt.Errorf("token %d: group and name are required", i)
+		}
+
+		name := configSyncTokenName(token.Group, token.Name)
+		if _, ok := tokenNames[name]; ok {
+			return fmt.Errorf("token %s: duplicated name", name)
+		}
+
+		tokenNames[name] = struct{}{}
+
+		if token.Key != "" && len(token.Key) != 48 {
+			return fmt.Errorf("token %s: key must be 48 characters", name)
+		}
+
+		if err := validateToken(token.AddTokenRequest); err != nil {
+			return fmt.Errorf("token %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// configSyncMu serializes the reconciles of the endpoint and the poller
+var configSyncMu sync.Mutex
+
+// ApplyConfigSync reconciles the channels, model configs and tokens to the
+// desired state in a single transaction, the model configs are applied first
+// as the channels need their models, and deleted last
+func ApplyConfigSync(spec *ConfigSyncSpec, dryRun bool) (*ConfigSyncResult, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+
+	configSyncMu.Lock()
+	defer configSyncMu.Unlock()
+
+	result := &ConfigSyncResult{
+		DryRun:       dryRun,
+		Channels:     newConfigSyncChanges(),
+		ModelConfigs: newConfigSyncChanges(),
+		Tokens:       newConfigSyncChanges(),
+	}
+
+	err := model.ConfigSyncTransaction(func(tx *model.ConfigSyncTx) error {
+		managed := make(map[string]map[string]model.ConfigSyncResource, 3)
+		for _, kind := range []string{
+			model.ConfigSyncKindChannel,
+			model.ConfigSyncKindModelConfig,
+			model.ConfigSyncKindToken,
+		} {
+			resources, err := tx.GetResources(kind)
+			if err != nil {
+				return err
+			}
+
+			managed[kind] = make(map[string]model.ConfigSyncResource, len(resources))
+			for _, resource := range resources {
+				managed[kind][resource.Name] = resource
+			}
+		}
+
+		if err := syncModelConfigs(tx, spec, managed, result, dryRun); err != nil {
+			return err
+		}
+
+		if err := syncChannels(tx, spec, managed, result, dryRun); err != nil {
+			return err
+		}
+
+		if err := syncTokens(tx, spec, managed, result, dryRun); err != nil {
+			return err
+		}
+
+		return pruneConfigSync(tx, managed, result, dryRun)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func syncModelConfigs(
+	tx *model.ConfigSyncTx,
+	spec *ConfigSyncSpec,
+	managed map[string]map[string]model.ConfigSyncResource,
+	result *ConfigSyncResult,
+	dryRun bool,
+) error {
+	resources := managed[model.ConfigSyncKindModelConfig]
+
+	for _, mc := range spec.ModelConfigs {
+		hash, err := configSyncHash(mc)
+		if err != nil {
+			return err
+		}
+
+		resource, isManaged := resources[mc.Model]
+		delete(resources, mc.Model)
+
+		_, err = tx.GetModelConfig(mc.Model)
+		exists := err == nil
+
+		switch {
+		case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		case exists && isManaged && resource.Hash == hash:
+			result.ModelConfigs.Unchanged = append(result.ModelConfigs.Unchanged, mc.Model)
+			continue
+		case exists:
+			result.ModelConfigs.Updated = append(result.ModelConfigs.Updated, mc.Model)
+		default:
+			result.ModelConfigs.Created = append(result.ModelConfigs.Created, mc.Model)
+		}
+
+		if dryRun {
+			continue
+		}
+
+		if err := tx.SaveModelConfig(mc); err != nil {
+			return fmt.Errorf("model config %s: %w", mc.Model, err)
+		}
+
+		err = tx.SaveResource(model.ConfigSyncResource{
+			Kind: model.ConfigSyncKindModelConfig,
+			Name: mc.Model,
+			Hash: hash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func syncChannels(
+	tx *model.ConfigSyncTx,
+	spec *ConfigSyncSpec,
+	managed map[string]map[string]model.ConfigSyncResource,
+	result *ConfigSyncResult,
+	dryRun bool,
+) error {
+	resources := managed[model.ConfigSyncKindChannel]
+
+	for _, ch := range spec.Channels {
+		hash, err := configSyncHash(ch.AddChannelRequest)
+		if err != nil {
+			return err
+		}
+
+		resource, isManaged := resources[ch.Name]
+		delete(resources, ch.Name)
+
+		existing, err := tx.GetChannelsByName(ch.Name)
+		if err != nil {
+			return err
+		}
+
+		if len(existing) > 1 {
+			return fmt.Errorf("channel %s: the name is used by %d channels", ch.Name, len(existing))
+		}
+
+		switch {
+		case len(existing) == 1 && isManaged && resource.Hash == hash &&
+			resource.ResourceID == existing[0].ID:
+			result.Channels.Unchanged = append(result.Channels.Unchanged, ch.Name)
+			continue
+		case len(existing) == 1:
+			result.Channels.Updated = append(result.Channels.Updated, ch.Name)
+		default:
+			result.Channels.Created = append(result.Channels.Created, ch.Name)
+		}
+
+		if dryRun {
+			continue
+		}
+
+		channel, err := ch.ToChannel()
+		if err != nil {
+			return fmt.Errorf("channel %s: %w", ch.Name, err)
+		}
+
+		if len(existing) == 1 {
+			channel.ID = existing[0].ID
+			if err := tx.UpdateChannel(channel); err != nil {
+				return fmt.Errorf("channel %s: %w", ch.Name, err)
+			}
+
+			if channel.Status != 0 && channel.Status != existing[0].Status {
+				if err := tx.UpdateChannelStatus(channel.ID, channel.Status); err != nil {
+					return fmt.Errorf("channel %s: %w", ch.Name, err)
+				}
+			}
+		} else if err := tx.InsertChannel(channel); err != nil {
+			return fmt.Errorf("channel %s: %w", ch.Name, err)
+		}
+
+		err = tx.SaveResource(model.ConfigSyncResource{
+			Kind:       model.ConfigSyncKindChannel,
+			Name:       ch.Name,
+			ResourceID: channel.ID,
+			Hash:       hash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func syncTokens(
+	tx *model.ConfigSyncTx,
+	spec *ConfigSyncSpec,
+	managed map[string]map[string]model.ConfigSyncResource,
+	result *ConfigSyncResult,
+	dryRun bool,
+) error {
+	resources := managed[model.ConfigSyncKindToken]
+
+	for _, token := range spec.Tokens {
+		name := configSyncTokenName(token.Group, token.Name)
+
+		// the key only seeds the created token, changing it does not update it
+		hashed := token
+		hashed.Key = ""
+
+		hash, err := configSyncHash(hashed)
+		if err != nil {
+			return err
+		}
+
+		resource, isManaged := resources[name]
+		delete(resources, name)
+
+		existing, err := tx.GetGroupTokenByName(token.Group, token.Name)
+		exists := err == nil
+
+		switch {
+		case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		case exists && isManaged && resource.Hash == hash && resource.ResourceID == existing.ID:
+			result.Tokens.Unchanged = append(result.Tokens.Unchanged, name)
+			continue
+		case exists:
+			result.Tokens.Updated = append(result.Tokens.Updated, name)
+		default:
+			result.Tokens.Created = append(result.Tokens.Created, name)
+		}
+
+		if dryRun {
+			continue
+		}
+
+		var id int
+		if exists {
+			id = existing.ID
+
+			err := tx.UpdateToken(id, model.UpdateTokenRequest{
+				Subnets:                  &token.Subnets,
+				Regions:                  &token.Regions,
+				Models:                   &token.Models,
+				Status:                   token.Status,
+				DebugUpstreamErrors:      &token.DebugUpstreamErrors,
+				DebugConversion:          &token.DebugConversion,
+				HedgeAfterMs:             &token.HedgeAfterMs,
+				AllowProviderPreferences: &token.AllowProviderPreferences,
+				AllowedOrigins:           &token.AllowedOrigins,
+				ServerOnly:               &token.ServerOnly,
+				Quota:                    &token.Quota,
+				PeriodQuota:              &token.PeriodQuota,
+				PeriodType:               &token.PeriodType,
+			})
+			if err != nil {
+				return fmt.Errorf("token %s: %w", name, err)
+			}
+		} else {
+			created := token.ToToken()
+			created.GroupID = token.Group
+			created.Key = token.Key
+			created.Status = token.Status
+
+			if err := tx.InsertToken(created); err != nil {
+				return fmt.Errorf("token %s: %w", name, err)
+			}
+
+			id = created.ID
+		}
+
+		err = tx.SaveResource(model.ConfigSyncResource{
+			Kind:       model.ConfigSyncKindToken,
+			Name:       name,
+			ResourceID: id,
+			Hash:       hash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pruneConfigSync deletes the managed resources left out of the desired state,
+// the tokens and channels are deleted before the model configs they use
+func pruneConfigSync(
+	tx *model.ConfigSyncTx,
+	managed map[string]map[string]model.ConfigSyncResource,
+	result *ConfigSyncResult,
+	dryRun bool,
+) error {
+	for _, resource := range managed[model.ConfigSyncKindToken] {
+		result.Tokens.Deleted = append(result.Tokens.Deleted, resource.Name)
+
+		if dryRun {
+			continue
+		}
+
+		err := model.IgnoreNotFound(tx.DeleteToken(resource.ResourceID))
+		if err != nil {
+			return fmt.Errorf("token %s: %w", resource.Name, err)
+		}
+
+		if err := tx.DeleteResource(resource.Kind, resource.Name); err != nil {
+			return err
+		}
+	}
+
+	for _, resource := range managed[model.ConfigSyncKindChannel] {
+		result.Channels.Deleted = append(result.Channels.Deleted, resource.Name)
+
+		if dryRun {
+			continue
+		}
+
+		err := model.IgnoreNotFound(tx.DeleteChannel(resource.ResourceID))
+		if err != nil {
+			return fmt.Errorf("channel %s: %w", resource.Name, err)
+		}
+
+		if err := tx.DeleteResource(resource.Kind, resource.Name); err != nil {
+			return err
+		}
+	}
+
+	for _, resource := range managed[model.ConfigSyncKindModelConfig] {
+		result.ModelConfigs.Deleted = append(result.ModelConfigs.Deleted, resource.Name)
+
+		if dryRun {
+			continue
+		}
+
+		err := model.IgnoreNotFound(tx.DeleteModelConfig(resource.Name))
+		if err != nil {
+			return fmt.Errorf("model config %s: %w", resource.Name, err)
+		}
+
+		if err := tx.DeleteResource(resource.Kind, resource.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SyncConfig godoc
+//
+//	@Summary		Sync the desired config state
+//	@Description	Reconciles the channels, model configs and tokens to a desired state document of json or yaml, the resources created by the sync are deleted once they leave the document
+//	@Tags			config_sync
+//	@Accept			json
+//	@Accept			application/yaml
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			dry_run	query		bool			false	"Only report the changes"
+//	@Param			spec	body		ConfigSyncSpec	true	"Desired state"
+//	@Success		200		{object}	middleware.APIResponse{data=ConfigSyncResult}
+//	@Router			/api/config_sync/ [post]
+func SyncConfig(c *gin.Context) {
+	body, err := common.GetRequestBody(c.Request)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	spec, err := ParseConfigSyncSpec(body, c.ContentType())
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := spec.validate(); err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := ApplyConfigSync(spec, c.Query("dry_run") == "true")
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, result)
+}
+
+// GetConfigSyncResources godoc
+//
+//	@Summary		Get the resources managed by the config sync
+//	@Description	Returns the channels, model configs and tokens created or adopted by the config sync
+//	@Tags			config_sync
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			kind	query		string	false	"channel, model_config or token"
+//	@Success		200		{object}	middleware.APIResponse{data=[]model.ConfigSyncResource}
+//	@Router			/api/config_sync/ [get]
+func GetConfigSyncResources(c *gin.Context) {
+	resources, err := model.GetConfigSyncResources(c.Query("kind"))
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, resources)
+}
+
+const maxConfigSyncSpecSize = 16 * 1024 * 1024
+
+var configSyncClient = &http.Client{Timeout: time.Minute}
+
+// PollConfigSync fetches the desired state of CONFIG_SYNC_URL and applies it
+// when it changed since the last poll, the last hash is returned
+func PollConfigSync(ctx context.Context, lastHash string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.ConfigSyncURL, nil)
+	if err != nil {
+		return lastHash, err
+	}
+
+	if config.ConfigSyncAuthorization != "" {
+		req.Header.Set("Authorization", config.ConfigSyncAuthorization)
+	}
+
+	resp, err := configSyncClient.Do(req)
+	if err != nil {
+		return lastHash, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return lastHash, fmt.Errorf("fetch config sync spec: status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxConfigSyncSpecSize+1))
+	if err != nil {
+		return lastHash, err
+	}
+
+	if len(body) > maxConfigSyncSpecSize {
+		return lastHash, fmt.Errorf(
+			"config sync spec exceeds the limit of %d bytes",
+			maxConfigSyncSpecSize,
+		)
+	}
+
+	sum := sha256.Sum256(body)
+
+	hash := hex.EncodeToString(sum[:])
+	if hash == lastHash {
+		return lastHash, nil
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if strings.HasSuffix(config.ConfigSyncURL, ".yaml") ||
+		strings.HasSuffix(config.ConfigSyncURL, ".yml") {
+		contentType = "application/yaml"
+	}
+
+	spec, err := ParseConfigSyncSpec(body, contentType)
+	if err != nil {
+		return lastHash, err
+	}
+
+	result, err := ApplyConfigSync(spec, false)
+	if err != nil {
+		return lastHash, err
+	}
+
+	log.Infof(
+		"config sync applied: %d channels, %d model configs and %d tokens changed",
+		len(result.Channels.Created)+len(result.Channels.Updated)+len(result.Channels.Deleted),
+		len(result.ModelConfigs.Created)+
+			len(result.ModelConfigs.Updated)+
+			len(result.ModelConfigs.Deleted),
+		len(result.Tokens.Created)+len(result.Tokens.Updated)+len(result.Tokens.Deleted),
+	)
+
+	return hash, nil
+}
@@ -0,0 +1,107 @@
+//nolint:testpackage
+package controller
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfigSyncSpec(t *testing.T) {
+	t.Parallel()
+
+	yamlSpec := `
+model_configs:
+  - model: gpt-4o
+    type: 1
+channels:
+  - name: openai-main
+    type_name: openai
+    base_url: https://api.openai.com/v1
+    models: [gpt-4o]
+    model_mapping:
+      gpt-4o: gpt-4o-2024-08-06
+tokens:
+  - group: team-a
+    name: ci
+    models: [gpt-4o]
+    quota: 10
+`
+
+	spec, err := ParseConfigSyncSpec([]byte(yamlSpec), "application/yaml")
+	require.NoError(t, err)
+
+	require.Len(t, spec.ModelConfigs, 1)
+	assert.Equal(t, "gpt-4o", spec.ModelConfigs[0].Model)
+
+	require.Len(t, spec.Channels, 1)
+	assert.Equal(t, "openai-main", spec.Channels[0].Name)
+	assert.Equal(t, "openai", spec.Channels[0].TypeName)
+	assert.Equal(t, "https://api.openai.com/v1", spec.Channels[0].BaseURL)
+	assert.Equal(t, []string{"gpt-4o"}, spec.Channels[0].Models)
+	assert.Equal(t, "gpt-4o-2024-08-06", spec.Channels[0].ModelMapping["gpt-4o"])
+
+	require.Len(t, spec.Tokens, 1)
+	assert.Equal(t, "team-a", spec.Tokens[0].Group)
+	assert.Equal(t, "ci", spec.Tokens[0].Name)
+	assert.InDelta(t, 10, spec.Tokens[0].Quota, 0)
+
+	jsonSpec := `{"tokens":[{"group":"team-a","name":"ci","server_only":true}]}`
+
+	spec, err = ParseConfigSyncSpec([]byte(jsonSpec), "application/json")
+	require.NoError(t, err)
+	require.Len(t, spec.Tokens, 1)
+	assert.True(t, spec.Tokens[0].ServerOnly)
+
+	_, err = ParseConfigSyncSpec([]byte("channels: [a"), "application/yaml")
+	require.Error(t, err)
+}
+
+func TestConfigSyncSpecValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, spec := range []ConfigSyncSpec{
+		{Tokens: []ConfigSyncToken{{Group: "team-a"}}},
+		{Tokens: []ConfigSyncToken{
+			{Group: "team-a", AddTokenRequest: AddTokenRequest{Name: "ci"}},
+			{Group: "team-a", AddTokenRequest: AddTokenRequest{Name: "ci"}},
+		}},
+		{Tokens: []ConfigSyncToken{
+			{Group: "team-a", Key: "short", AddTokenRequest: AddTokenRequest{Name: "ci"}},
+		}},
+		{Channels: []ConfigSyncChannel{{}}},
+		{ModelConfigs: []model.ModelConfig{{Model: "gpt-4o"}, {Model: "gpt-4o"}}},
+	} {
+		require.Error(t, spec.validate())
+	}
+
+	spec := ConfigSyncSpec{
+		Tokens: []ConfigSyncToken{
+			{Group: "team-a", AddTokenRequest: AddTokenRequest{Name: "ci"}},
+			{Group: "team-b", AddTokenRequest: AddTokenRequest{Name: "ci"}},
+		},
+	}
+	require.NoError(t, spec.validate())
+}
+
+func TestPollConfigSyncRejectsOversizedSpec(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(bytes.Repeat([]byte(" "), maxConfigSyncSpecSize+1))
+	}))
+	defer server.Close()
+
+	url := config.ConfigSyncURL
+	config.ConfigSyncURL = server.URL
+
+	defer func() { config.ConfigSyncURL = url }()
+
+	hash, err := PollConfigSync(t.Context(), "last")
+	require.ErrorContains(t, err, "exceeds the limit")
+	assert.Equal(t, "last", hash)
+}
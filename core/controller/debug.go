@@ -0,0 +1,228 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/conv"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/controller"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+const debugConvertRequestID = "debug-convert"
+
+// the short names of the modes accepted by the dry run, the other modes are
+// accepted by their full names
+var debugConvertModes = map[string]mode.Mode{
+	"chat":        mode.ChatCompletions,
+	"completions": mode.Completions,
+	"embeddings":  mode.Embeddings,
+	"moderations": mode.Moderations,
+	"images":      mode.ImagesGenerations,
+	"speech":      mode.AudioSpeech,
+	"rerank":      mode.Rerank,
+	"anthropic":   mode.Anthropic,
+	"messages":    mode.Anthropic,
+	"responses":   mode.Responses,
+	"gemini":      mode.Gemini,
+}
+
+// DebugConvertResult is the upstream request an adaptor builds, the
+// credentials in the header and the url are masked
+type DebugConvertResult struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	Header      http.Header `json:"header"`
+	Body        string      `json:"body"`
+	ActualModel string      `json:"actual_model"`
+}
+
+func parseDebugConvertMode(name string) (mode.Mode, bool) {
+	if m, ok := debugConvertModes[strings.ToLower(name)]; ok {
+		return m, true
+	}
+
+	return mode.Parse(name)
+}
+
+func getDebugConvertChannel(c *gin.Context) (*model.Channel, error) {
+	if id := c.Query("channel_id"); id != "" {
+		channelID, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, errors.New("invalid channel_id")
+		}
+
+		return model.GetChannelByID(channelID)
+	}
+
+	channelType, err := strconv.Atoi(c.Query("channel_type"))
+	if err != nil {
+		return nil, errors.New("channel_type or channel_id is required")
+	}
+
+	return &model.Channel{
+		Type:    model.ChannelType(channelType),
+		Key:     c.Query("key"),
+		BaseURL: c.Query("base_url"),
+	}, nil
+}
+
+// DebugConvert godoc
+//
+//	@Summary		Dry run the request conversion
+//	@Description	Returns the upstream request the adaptor of the channel builds for the client payload without sending it.
+//	@Description	The conversion is not side-effect free: it runs the request preparation of the adaptor like a relay, with the channel key,
+//	@Description	so it may fetch the access token of the channel (e.g. Baidu, Vertex AI), download the media urls of the payload to inline them,
+//	@Description	and upload the media of the payload to the storage of the provider (e.g. the DashScope temporary storage).
+//	@Description	Use a payload without media, or a channel without a live key, to avoid them.
+//	@Tags			debug
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			channel_type	query		int		false	"Channel type, required without channel_id"
+//	@Param			channel_id		query		int		false	"Channel ID, the config of the channel is used"
+//	@Param			mode			query		string	true	"Relay mode, e.g. chat, anthropic, responses, gemini"
+//	@Param			model			query		string	false	"Model name, defaults to the model of the payload"
+//	@Param			key				query		string	false	"Channel key used without channel_id"
+//	@Param			base_url		query		string	false	"Channel base url used without channel_id"
+//	@Param			request			body		object	true	"Client payload"
+//	@Success		200				{object}	middleware.APIResponse{data=DebugConvertResult}
+//	@Router			/api/debug/convert [post]
+func DebugConvert(c *gin.Context) {
+	m, ok := parseDebugConvertMode(c.Query("mode"))
+	if !ok || m == mode.Unknown {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "invalid mode: "+c.Query("mode"))
+		return
+	}
+
+	channel, err := getDebugConvertChannel(c)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	modelName := c.Query("model")
+	if modelName == "" {
+		var payload struct {
+			Model string `json:"model"`
+		}
+
+		_ = common.UnmarshalRequestReusable(c.Request, &payload)
+		modelName = payload.Model
+	}
+
+	if modelName == "" {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "model is required")
+		return
+	}
+
+	mc := model.LoadModelCaches()
+
+	modelConfig, ok := mc.ModelConfig.GetModelConfig(modelName)
+	if !ok {
+		modelConfig = guessModelConfig(modelName)
+		modelConfig.Model = modelName
+	}
+
+	debugMeta := meta.NewMeta(
+		channel,
+		m,
+		modelName,
+		modelConfig,
+		meta.WithRequestID(debugConvertRequestID),
+	)
+
+	// the conversion is not sent, so neither are the requests of the plugins
+	a, ok := relayAdaptor(debugMeta, mc, func(string) (*model.Channel, error) {
+		return nil, errors.New("the plugin requests are not sent by the debug conversion")
+	})
+	if !ok {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "invalid channel type")
+		return
+	}
+
+	if !a.SupportMode(debugMeta) {
+		middleware.ErrorResponse(c, http.StatusBadRequest, m.String()+" not supported by adaptor")
+		return
+	}
+
+	// the adaptor prepares and converts the request like a relay, so the
+	// token fetches, the media downloads and the media uploads of the
+	// conversion still happen, as the endpoint docs say
+	req, relayErr := controller.PrepareRequest(a, c, debugMeta, AdaptorStore)
+	if relayErr != nil {
+		body, _ := relayErr.MarshalJSON()
+		middleware.ErrorResponse(c, relayErr.StatusCode(), conv.BytesToString(body))
+
+		return
+	}
+	defer controller.CloseRequest(req)
+
+	var body []byte
+	if req.Body != nil {
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	middleware.SuccessResponse(c, DebugConvertResult{
+		Method:      req.Method,
+		URL:         maskDebugURL(req.URL),
+		Header:      maskDebugHeader(req.Header),
+		Body:        conv.BytesToString(body),
+		ActualModel: debugMeta.ActualModel,
+	})
+}
+
+func isDebugCredential(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"authorization", "key", "token", "secret", "signature"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func maskDebugHeader(header http.Header) http.Header {
+	masked := header.Clone()
+	for name, values := range masked {
+		if !isDebugCredential(name) {
+			continue
+		}
+
+		for i := range values {
+			values[i] = "***"
+		}
+	}
+
+	return masked
+}
+
+func maskDebugURL(u *url.URL) string {
+	masked := *u
+	masked.User = nil
+
+	query := masked.Query()
+	for name := range query {
+		if isDebugCredential(name) {
+			query.Set(name, "***")
+		}
+	}
+
+	masked.RawQuery = query.Encode()
+
+	return masked.String()
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/controller/utils"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+)
+
+const (
+	defaultFingerprintDriftLimit = 10000
+	maxFingerprintDriftLimit     = 100000
+)
+
+// GetFingerprintDrift godoc
+//
+//	@Summary		Get fingerprint drift
+//	@Description	Returns the system fingerprints reported by each channel model, a channel model with more than one fingerprint has changed its backend
+//	@Tags			monitor
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			start_timestamp	query		int		false	"Start timestamp (milliseconds)"
+//	@Param			end_timestamp	query		int		false	"End timestamp (milliseconds)"
+//	@Param			channel			query		int		false	"Channel ID"
+//	@Param			model_name		query		string	false	"Model name"
+//	@Param			limit			query		int		false	"Max logs scanned, default 10000"
+//	@Success		200				{object}	middleware.APIResponse{data=[]model.FingerprintDrift}
+//	@Router			/api/monitor/fingerprint_drift [get]
+func GetFingerprintDrift(c *gin.Context) {
+	startTime, endTime := utils.ParseTimeRange(c, 0)
+	channelID, _ := strconv.Atoi(c.Query("channel"))
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = defaultFingerprintDriftLimit
+	}
+
+	limit = min(limit, maxFingerprintDriftLimit)
+
+	logs, err := model.GetFingerprintLogs(
+		startTime,
+		endTime,
+		channelID,
+		c.Query("model_name"),
+		limit,
+	)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, model.FingerprintDriftReport(logs))
+}
@@ -358,9 +358,15 @@ type CreateGroupRequest struct {
 	RPMRatio      float64  `json:"rpm_ratio"`
 	TPMRatio      float64  `json:"tpm_ratio"`
 	AvailableSets []string `json:"available_sets"`
+	DataResidency []string `json:"data_residency"`
 
 	BalanceAlertEnabled   bool    `json:"balance_alert_enabled"`
 	BalanceAlertThreshold float64 `json:"balance_alert_threshold"`
+
+	FileStorageQuota int64   `json:"file_storage_quota"`
+	FairShareWeight  float64 `json:"fair_share_weight"`
+
+	Guardrails *model.GroupGuardrails `json:"guardrails,omitempty"`
 }
 
 func (r *CreateGroupRequest) ToGroup() *model.Group {
@@ -368,9 +374,15 @@ func (r *CreateGroupRequest) ToGroup() *model.Group {
 		RPMRatio:      r.RPMRatio,
 		TPMRatio:      r.TPMRatio,
 		AvailableSets: r.AvailableSets,
+		DataResidency: r.DataResidency,
 
 		BalanceAlertEnabled:   r.BalanceAlertEnabled,
 		BalanceAlertThreshold: r.BalanceAlertThreshold,
+
+		FileStorageQuota: r.FileStorageQuota,
+		FairShareWeight:  r.FairShareWeight,
+
+		Guardrails: r.Guardrails,
 	}
 }
 
@@ -491,6 +503,11 @@ type SaveGroupModelConfigRequest struct {
 
 	OverrideSummaryClaudeLongContext bool `json:"override_summary_claude_long_context"`
 	SummaryClaudeLongContext         bool `json:"summary_claude_long_context"`
+
+	MonthlySpendCap       float64 `json:"monthly_spend_cap"`
+	SpendCapFallbackModel string  `json:"spend_cap_fallback_model"`
+
+	AliasModel string `json:"alias_model"`
 }
 
 func (r *SaveGroupModelConfigRequest) ToGroupModelConfig(groupID string) model.GroupModelConfig {
@@ -527,6 +544,9 @@ func (r *SaveGroupModelConfigRequest) ToGroupModelConfig(groupID string) model.G
 		SummaryServiceTier:                 r.SummaryServiceTier,
 		OverrideSummaryClaudeLongContext:   r.OverrideSummaryClaudeLongContext,
 		SummaryClaudeLongContext:           r.SummaryClaudeLongContext,
+		MonthlySpendCap:                    r.MonthlySpendCap,
+		SpendCapFallbackModel:              r.SpendCapFallbackModel,
+		AliasModel:                         r.AliasModel,
 	}
 }
 
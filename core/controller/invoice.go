@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/billing"
+	"github.com/labring/aiproxy/core/controller/utils"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+)
+
+func parseInvoiceMonth(c *gin.Context) (time.Time, bool) {
+	monthStr := c.Query("month")
+	if monthStr == "" {
+		return billing.MonthStart(time.Now()), true
+	}
+
+	month, err := billing.ParseMonth(monthStr)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "invalid month, e.g. 2025-01")
+		return time.Time{}, false
+	}
+
+	return month, true
+}
+
+func writeInvoice(c *gin.Context, invoice *model.Invoice) {
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		middleware.SuccessResponse(c, invoice)
+	case "pdf":
+		disposition := mime.FormatMediaType("attachment", map[string]string{
+			"filename": "invoice-" + invoice.GroupID + "-" + invoice.Month + ".pdf",
+		})
+
+		c.Header("Content-Disposition", disposition)
+		c.Header("Cache-Control", "no-store")
+		c.Data(http.StatusOK, "application/pdf", billing.InvoicePDF(invoice))
+	default:
+		middleware.ErrorResponse(c, http.StatusBadRequest, "invalid format, must be json or pdf")
+	}
+}
+
+// GetGroupInvoice godoc
+//
+//	@Summary		Get group invoice
+//	@Description	Returns the monthly invoice of a group with the line items by model and price version, the invoices of the finished months are stored on the first read
+//	@Tags			invoice
+//	@Produce		json
+//	@Produce		application/pdf
+//	@Security		ApiKeyAuth
+//	@Param			group	path		string	true	"Group name"
+//	@Param			month	query		string	false	"Month in UTC, e.g. 2025-01, default is the current month"
+//	@Param			format	query		string	false	"Export format, default json"	Enums(json, pdf)
+//	@Success		200		{object}	middleware.APIResponse{data=model.Invoice}
+//	@Router			/api/invoice/{group} [get]
+func GetGroupInvoice(c *gin.Context) {
+	group := c.Param("group")
+	if group == "" {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "invalid group parameter")
+		return
+	}
+
+	month, ok := parseInvoiceMonth(c)
+	if !ok {
+		return
+	}
+
+	invoice, err := billing.GetInvoice(group, month)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeInvoice(c, invoice)
+}
+
+// RegenerateGroupInvoice godoc
+//
+//	@Summary		Regenerate group invoice
+//	@Description	Generates the monthly invoice of a group again from the current summaries and replaces the stored one
+//	@Tags			invoice
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			group	path		string	true	"Group name"
+//	@Param			month	query		string	false	"Month in UTC, e.g. 2025-01, default is the current month"
+//	@Success		200		{object}	middleware.APIResponse{data=model.Invoice}
+//	@Router			/api/invoice/{group}/regenerate [post]
+func RegenerateGroupInvoice(c *gin.Context) {
+	group := c.Param("group")
+	if group == "" {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "invalid group parameter")
+		return
+	}
+
+	month, ok := parseInvoiceMonth(c)
+	if !ok {
+		return
+	}
+
+	invoice, err := billing.RegenerateInvoice(group, month)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, invoice)
+}
+
+// GetInvoices godoc
+//
+//	@Summary		Get stored invoices
+//	@Description	Returns a paginated list of the stored invoices without their line items
+//	@Tags			invoice
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			page		query		int		false	"Page number"
+//	@Param			per_page	query		int		false	"Items per page"
+//	@Param			group		query		string	false	"Group name"
+//	@Param			month		query		string	false	"Month in UTC, e.g. 2025-01"
+//	@Success		200			{object}	middleware.APIResponse{data=model.GetInvoicesResult}
+//	@Router			/api/invoices/ [get]
+func GetInvoices(c *gin.Context) {
+	page, perPage := utils.ParsePageParams(c)
+
+	result, err := model.GetInvoices(c.Query("group"), c.Query("month"), page, perPage)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, result)
+}
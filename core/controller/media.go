@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/mediastore"
+	"github.com/labring/aiproxy/core/middleware"
+)
+
+// GetMedia godoc
+//
+//	@Summary		Get media
+//	@Description	Serves the images and audio stored by the media-url plugin, the url is signed and short-lived so no token is required
+//	@Tags			relay
+//	@Produce		octet-stream
+//	@Param			key			path		string	true	"Media key"
+//	@Param			expires		query		int		true	"Expiration unix seconds"
+//	@Param			signature	query		string	true	"Signature"
+//	@Success		200			{file}		binary
+//	@Router			/v1/media/{key} [get]
+func GetMedia(c *gin.Context) {
+	store := mediastore.Default()
+	if store == nil {
+		middleware.ErrorResponse(c, http.StatusNotFound, "media storage is not enabled")
+		return
+	}
+
+	key := c.Param("key")
+
+	err := store.Verify(key, c.Query("expires"), c.Query("signature"))
+	switch {
+	case errors.Is(err, mediastore.ErrExpired):
+		middleware.ErrorResponse(c, http.StatusGone, err.Error())
+		return
+	case err != nil:
+		middleware.ErrorResponse(c, http.StatusForbidden, err.Error())
+		return
+	}
+
+	path, err := store.Path(key)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusForbidden, err.Error())
+		return
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		middleware.ErrorResponse(c, http.StatusNotFound, "media not found")
+		return
+	}
+
+	c.Header("Content-Type", mediastore.ContentType(key))
+	c.Header("Cache-Control", "private, max-age=300")
+	c.File(path)
+}
@@ -7,7 +7,8 @@ import (
 )
 
 type StatusData struct {
-	StartTime int64 `json:"startTime"`
+	StartTime int64               `json:"startTime"`
+	Redis     *common.RedisHealth `json:"redis,omitempty"`
 }
 
 // GetStatus godoc
@@ -19,7 +20,14 @@ type StatusData struct {
 //	@Success		200	{object}	middleware.APIResponse{data=StatusData}
 //	@Router			/api/status [get]
 func GetStatus(c *gin.Context) {
-	middleware.SuccessResponse(c, &StatusData{
+	status := &StatusData{
 		StartTime: common.StartTime,
-	})
+	}
+
+	if common.RedisEnabled {
+		health := common.GetRedisHealth()
+		status.Redis = &health
+	}
+
+	middleware.SuccessResponse(c, status)
 }
@@ -5,6 +5,7 @@ import (
 	"slices"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/bytedance/sonic"
 	"github.com/gin-gonic/gin"
@@ -33,13 +34,33 @@ type OpenAIModelPermission struct {
 }
 
 type OpenAIModels struct {
-	Parent     *string                 `json:"parent"`
-	ID         string                  `json:"id"`
-	Object     string                  `json:"object"`
-	OwnedBy    string                  `json:"owned_by"`
-	Root       string                  `json:"root"`
-	Permission []OpenAIModelPermission `json:"permission"`
-	Created    int                     `json:"created"`
+	Parent           *string                 `json:"parent"`
+	ID               string                  `json:"id"`
+	Object           string                  `json:"object"`
+	OwnedBy          string                  `json:"owned_by"`
+	Root             string                  `json:"root"`
+	Permission       []OpenAIModelPermission `json:"permission"`
+	Created          int                     `json:"created"`
+	Deprecated       bool                    `json:"deprecated,omitempty"`
+	SunsetAt         *time.Time              `json:"sunset_at,omitempty"`
+	ReplacementModel string                  `json:"replacement_model,omitempty"`
+}
+
+// newOpenAIModels returns the model of the models list, the deprecated models
+// are flagged with their sunset date and replacement
+func newOpenAIModels(id string, mc model.ModelConfig) *OpenAIModels {
+	return &OpenAIModels{
+		ID:               id,
+		Object:           "model",
+		Created:          1626777600,
+		OwnedBy:          string(mc.Owner),
+		Root:             id,
+		Permission:       permission,
+		Parent:           nil,
+		Deprecated:       mc.IsDeprecated(),
+		SunsetAt:         mc.SunsetAt,
+		ReplacementModel: mc.ReplacementModel,
+	}
 }
 
 type BuiltinModelConfig model.ModelConfig
@@ -0,0 +1,286 @@
+package controller
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/consume"
+	"github.com/labring/aiproxy/core/controller/utils"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+// ModelRecommendationProfile describes the expected requests of a workload
+type ModelRecommendationProfile struct {
+	InputTokens  int64     `json:"input_tokens"`
+	OutputTokens int64     `json:"output_tokens"`
+	Type         mode.Mode `json:"type"`
+	Features     []string  `json:"features,omitempty"`
+}
+
+// ModelRecommendationWeights are the weights of the normalized cost, latency
+// and error rate in the score, a lower score is better
+type ModelRecommendationWeights struct {
+	Cost      float64 `json:"cost"`
+	Latency   float64 `json:"latency"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+var defaultModelRecommendationWeights = ModelRecommendationWeights{
+	Cost:      0.6,
+	Latency:   0.2,
+	ErrorRate: 0.2,
+}
+
+type ModelRecommendation struct {
+	Rank          int              `json:"rank"`
+	Model         string           `json:"model"`
+	Owner         model.ModelOwner `json:"owner"`
+	ProjectedCost float64          `json:"projected_cost"`
+	// CostSaving is the saving ratio compared with the baseline model
+	CostSaving   *float64 `json:"cost_saving,omitempty"`
+	RequestCount int64    `json:"request_count"`
+	ErrorRate    float64  `json:"error_rate"`
+	AvgLatencyMs float64  `json:"avg_latency_ms"`
+	AvgTTFBMs    float64  `json:"avg_ttfb_ms"`
+	HasHistory   bool     `json:"has_history"`
+	Score        float64  `json:"score"`
+}
+
+// modelSupportsProfile reports whether the model config satisfies the type,
+// the token limits and the required features of the profile
+func modelSupportsProfile(mc model.ModelConfig, profile ModelRecommendationProfile) bool {
+	if profile.Type != mode.Unknown && mc.Type != profile.Type {
+		return false
+	}
+
+	if maxContext, ok := mc.MaxContextTokens(); ok && maxContext > 0 &&
+		int64(maxContext) < profile.InputTokens+profile.OutputTokens {
+		return false
+	}
+
+	if maxInput, ok := mc.MaxInputTokens(); ok && maxInput > 0 &&
+		int64(maxInput) < profile.InputTokens {
+		return false
+	}
+
+	if maxOutput, ok := mc.MaxOutputTokens(); ok && maxOutput > 0 &&
+		int64(maxOutput) < profile.OutputTokens {
+		return false
+	}
+
+	for _, feature := range profile.Features {
+		supported, _ := model.GetModelConfigBool(mc.Config, model.ModelConfigKey(feature))
+		if !supported {
+			return false
+		}
+	}
+
+	return true
+}
+
+func projectModelCost(mc model.ModelConfig, profile ModelRecommendationProfile) float64 {
+	return consume.CalculateAmount(
+		http.StatusOK,
+		model.Usage{
+			InputTokens:  model.ZeroNullInt64(profile.InputTokens),
+			OutputTokens: model.ZeroNullInt64(profile.OutputTokens),
+			TotalTokens:  model.ZeroNullInt64(profile.InputTokens + profile.OutputTokens),
+		},
+		model.UsageContext{},
+		mc.Price,
+	)
+}
+
+// rankModels scores the candidate models, the cost and the latency are
+// normalized by the max value of the candidates, models without history
+// are scored with the worst latency and error rate
+func rankModels(
+	configs []model.ModelConfig,
+	performances map[string]model.ModelPerformance,
+	profile ModelRecommendationProfile,
+	weights ModelRecommendationWeights,
+	baseline string,
+) []ModelRecommendation {
+	recommendations := make([]ModelRecommendation, 0, len(configs))
+
+	var (
+		maxCost, maxLatency float64
+		baselineCost        = -1.0
+	)
+
+	for _, mc := range configs {
+		cost := projectModelCost(mc, profile)
+		if mc.Model == baseline {
+			baselineCost = cost
+		}
+
+		if !modelSupportsProfile(mc, profile) {
+			continue
+		}
+
+		recommendation := ModelRecommendation{
+			Model:         mc.Model,
+			Owner:         mc.Owner,
+			ProjectedCost: cost,
+		}
+
+		if performance, ok := performances[mc.Model]; ok && performance.RequestCount > 0 {
+			recommendation.HasHistory = true
+			recommendation.RequestCount = performance.RequestCount
+			recommendation.ErrorRate = performance.ErrorRate()
+			recommendation.AvgLatencyMs = performance.AvgLatencyMilliseconds()
+			recommendation.AvgTTFBMs = performance.AvgTTFBMilliseconds()
+			maxLatency = max(maxLatency, recommendation.AvgLatencyMs)
+		}
+
+		maxCost = max(maxCost, cost)
+
+		recommendations = append(recommendations, recommendation)
+	}
+
+	for i := range recommendations {
+		r := &recommendations[i]
+
+		costScore := 0.0
+		if maxCost > 0 {
+			costScore = r.ProjectedCost / maxCost
+		}
+
+		latencyScore, errorScore := 1.0, 1.0
+		if r.HasHistory {
+			latencyScore = 0
+			if maxLatency > 0 {
+				latencyScore = r.AvgLatencyMs / maxLatency
+			}
+
+			errorScore = r.ErrorRate
+		}
+
+		r.Score = weights.Cost*costScore +
+			weights.Latency*latencyScore +
+			weights.ErrorRate*errorScore
+
+		if baselineCost > 0 {
+			saving := (baselineCost - r.ProjectedCost) / baselineCost
+			r.CostSaving = &saving
+		}
+	}
+
+	slices.SortStableFunc(recommendations, func(a, b ModelRecommendation) int {
+		return cmp.Or(
+			cmp.Compare(a.Score, b.Score),
+			cmp.Compare(a.ProjectedCost, b.ProjectedCost),
+			strings.Compare(a.Model, b.Model),
+		)
+	})
+
+	for i := range recommendations {
+		recommendations[i].Rank = i + 1
+	}
+
+	return recommendations
+}
+
+func parseQueryFloat(c *gin.Context, key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(c.Query(key), 64)
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+
+	return value
+}
+
+// GetModelRecommendations godoc
+//
+//	@Summary		Get model recommendations
+//	@Description	Ranks the enabled models by the projected cost of a prompt profile and the observed latency and error rate from summary data
+//	@Tags			model
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			input_tokens	query		int		true	"Average input tokens per request"
+//	@Param			output_tokens	query		int		true	"Average output tokens per request"
+//	@Param			type			query		int		false	"Model type, default is chat completions, 0 means any type"
+//	@Param			features		query		string	false	"Required features, comma separated model config keys, e.g. vision,tool_choice"
+//	@Param			baseline		query		string	false	"Baseline model to compute the cost saving"
+//	@Param			cost_weight		query		number	false	"Weight of the cost in the score"		default(0.6)
+//	@Param			latency_weight	query		number	false	"Weight of the latency in the score"	default(0.2)
+//	@Param			error_weight	query		number	false	"Weight of the error rate in the score"	default(0.2)
+//	@Param			start_timestamp	query		int64	false	"Start timestamp of the history"
+//	@Param			end_timestamp	query		int64	false	"End timestamp of the history"
+//	@Success		200				{object}	middleware.APIResponse{data=map[string]any{items=[]ModelRecommendation}}
+//	@Router			/api/models/recommendations [get]
+func GetModelRecommendations(c *gin.Context) {
+	inputTokens, _ := strconv.ParseInt(c.Query("input_tokens"), 10, 64)
+	outputTokens, _ := strconv.ParseInt(c.Query("output_tokens"), 10, 64)
+
+	if inputTokens < 0 || outputTokens < 0 || inputTokens+outputTokens == 0 {
+		middleware.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			"input_tokens and output_tokens must be non-negative and not both zero",
+		)
+
+		return
+	}
+
+	profile := ModelRecommendationProfile{
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		Type:         mode.ChatCompletions,
+	}
+
+	if typeStr := c.Query("type"); typeStr != "" {
+		t, err := strconv.Atoi(typeStr)
+		if err != nil {
+			middleware.ErrorResponse(c, http.StatusBadRequest, "invalid type")
+			return
+		}
+
+		profile.Type = mode.Mode(t)
+	}
+
+	for feature := range strings.SplitSeq(c.Query("features"), ",") {
+		if feature = strings.TrimSpace(feature); feature != "" {
+			profile.Features = append(profile.Features, feature)
+		}
+	}
+
+	weights := ModelRecommendationWeights{
+		Cost:      parseQueryFloat(c, "cost_weight", defaultModelRecommendationWeights.Cost),
+		Latency:   parseQueryFloat(c, "latency_weight", defaultModelRecommendationWeights.Latency),
+		ErrorRate: parseQueryFloat(c, "error_weight", defaultModelRecommendationWeights.ErrorRate),
+	}
+
+	startTime, endTime := utils.ParseTimeRange(c, 0)
+
+	performances, err := model.GetModelPerformances(startTime, endTime)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	enabledConfigs := model.LoadModelCaches().EnabledModelConfigsMap
+
+	configs := make([]model.ModelConfig, 0, len(enabledConfigs))
+	for _, mc := range enabledConfigs {
+		configs = append(configs, mc)
+	}
+
+	middleware.SuccessResponse(c, gin.H{
+		"profile": profile,
+		"weights": weights,
+		"items": rankModels(
+			configs,
+			performances,
+			profile,
+			weights,
+			c.Query("baseline"),
+		),
+	})
+}
@@ -0,0 +1,102 @@
+//nolint:testpackage
+package controller
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRankModels(t *testing.T) {
+	t.Parallel()
+
+	configs := []model.ModelConfig{
+		{
+			Model: "expensive",
+			Type:  mode.ChatCompletions,
+			Price: model.Price{InputPrice: 10, OutputPrice: 30},
+		},
+		{
+			Model:  "cheap",
+			Type:   mode.ChatCompletions,
+			Price:  model.Price{InputPrice: 1, OutputPrice: 2},
+			Config: model.NewModelConfig(model.WithModelConfigVision(true)),
+		},
+		{
+			Model: "small-context",
+			Type:  mode.ChatCompletions,
+			Price: model.Price{InputPrice: 0.1, OutputPrice: 0.2},
+			Config: model.NewModelConfig(
+				model.WithModelConfigMaxContextTokens(1000),
+			),
+		},
+		{
+			Model: "embedding",
+			Type:  mode.Embeddings,
+			Price: model.Price{InputPrice: 0.01},
+		},
+	}
+	performances := map[string]model.ModelPerformance{
+		"expensive": {Model: "expensive", RequestCount: 100, TotalTimeMilliseconds: 100000},
+		"cheap": {
+			Model:                 "cheap",
+			RequestCount:          100,
+			ExceptionCount:        10,
+			TotalTimeMilliseconds: 50000,
+		},
+	}
+	profile := ModelRecommendationProfile{
+		InputTokens:  2000,
+		OutputTokens: 500,
+		Type:         mode.ChatCompletions,
+	}
+
+	items := rankModels(
+		configs,
+		performances,
+		profile,
+		defaultModelRecommendationWeights,
+		"expensive",
+	)
+	require.Len(t, items, 2)
+	assert.Equal(t, "cheap", items[0].Model)
+	assert.Equal(t, 1, items[0].Rank)
+	assert.InDelta(t, 0.1, items[0].ErrorRate, 1e-9)
+	assert.InDelta(t, 500, items[0].AvgLatencyMs, 1e-9)
+	require.NotNil(t, items[0].CostSaving)
+	assert.Greater(t, *items[0].CostSaving, 0.9)
+	assert.Less(t, items[0].ProjectedCost, items[1].ProjectedCost)
+
+	profile.Features = []string{string(model.ModelConfigVisionKey)}
+	items = rankModels(configs, performances, profile, defaultModelRecommendationWeights, "")
+	require.Len(t, items, 1)
+	assert.Equal(t, "cheap", items[0].Model)
+	assert.Nil(t, items[0].CostSaving)
+}
+
+func TestRankModelsWithoutHistory(t *testing.T) {
+	t.Parallel()
+
+	configs := []model.ModelConfig{
+		{Model: "new", Type: mode.ChatCompletions, Price: model.Price{InputPrice: 1}},
+		{Model: "known", Type: mode.ChatCompletions, Price: model.Price{InputPrice: 1}},
+	}
+	performances := map[string]model.ModelPerformance{
+		"known": {Model: "known", RequestCount: 10, TotalTimeMilliseconds: 1000},
+	}
+
+	items := rankModels(
+		configs,
+		performances,
+		ModelRecommendationProfile{InputTokens: 100, Type: mode.ChatCompletions},
+		defaultModelRecommendationWeights,
+		"",
+	)
+	require.Len(t, items, 2)
+	assert.Equal(t, "known", items[0].Model)
+	assert.True(t, items[0].HasHistory)
+	assert.False(t, items[1].HasHistory)
+}
@@ -1,13 +1,17 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/labring/aiproxy/core/controller/utils"
 	"github.com/labring/aiproxy/core/middleware"
 	"github.com/labring/aiproxy/core/model"
+	"gorm.io/gorm"
 )
 
 // GetModelConfigs godoc
@@ -258,3 +262,83 @@ func GetModelConfig(c *gin.Context) {
 
 	middleware.SuccessResponse(c, config)
 }
+
+// GetModelConfigVersions godoc
+//
+//	@Summary		Get model config versions
+//	@Description	Returns the versions of a model config, the latest version first, or the version in effect at the timestamp
+//	@Tags			modelconfig
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			model		path		string	true	"Model name"
+//	@Param			timestamp	query		int		false	"Timestamp (milliseconds), returns the version in effect at the timestamp"
+//	@Success		200			{object}	middleware.APIResponse{data=[]model.ModelConfigVersion}
+//	@Router			/api/model_configs/versions/{model} [get]
+func GetModelConfigVersions(c *gin.Context) {
+	modelName := strings.TrimPrefix(c.Param("model"), "/")
+	if modelName == "" {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "invalid parameter")
+		return
+	}
+
+	if timestamp, _ := strconv.ParseInt(c.Query("timestamp"), 10, 64); timestamp > 0 {
+		version, err := model.GetModelConfigVersionAt(modelName, time.UnixMilli(timestamp))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				middleware.ErrorResponse(c, http.StatusNotFound, err.Error())
+				return
+			}
+
+			middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		middleware.SuccessResponse(c, []model.ModelConfigVersion{version})
+
+		return
+	}
+
+	versions, err := model.GetModelConfigVersions(modelName)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, versions)
+}
+
+// RollbackModelConfig godoc
+//
+//	@Summary		Rollback model config
+//	@Description	Saves the snapshot of the version as the latest version of the model config
+//	@Tags			modelconfig
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			model	path		string	true	"Model name"
+//	@Param			version	query		int		true	"Version to rollback to"
+//	@Success		200		{object}	middleware.APIResponse{data=model.ModelConfig}
+//	@Router			/api/model_configs/rollback/{model} [post]
+func RollbackModelConfig(c *gin.Context) {
+	modelName := strings.TrimPrefix(c.Param("model"), "/")
+
+	version, _ := strconv.ParseInt(c.Query("version"), 10, 64)
+	if modelName == "" || version <= 0 {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "invalid parameter")
+		return
+	}
+
+	config, err := model.RollbackModelConfig(modelName, version)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			middleware.ErrorResponse(c, http.StatusNotFound, err.Error())
+			return
+		}
+
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+
+		return
+	}
+
+	middleware.SuccessResponse(c, config)
+}
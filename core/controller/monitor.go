@@ -8,9 +8,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/labring/aiproxy/core/common/reqlimit"
+	"github.com/labring/aiproxy/core/common/tiktoken"
 	"github.com/labring/aiproxy/core/middleware"
 	"github.com/labring/aiproxy/core/model"
 	"github.com/labring/aiproxy/core/monitor"
+	"github.com/labring/aiproxy/core/relay/plugin/conversion"
 )
 
 type RuntimeChannelModelMetric struct {
@@ -722,6 +724,111 @@ func GetAllBannedModelChannels(c *gin.Context) {
 	middleware.SuccessResponse(c, channels)
 }
 
+// GetCircuitBreakers godoc
+//
+//	@Summary		Get circuit breakers
+//	@Description	Returns the channel-model circuit breaker states of this instance
+//	@Tags			monitor
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	middleware.APIResponse{data=[]monitor.BreakerSnapshot}
+//	@Router			/api/monitor/circuit_breakers [get]
+func GetCircuitBreakers(c *gin.Context) {
+	middleware.SuccessResponse(c, monitor.GetBreakerSnapshots())
+}
+
+// ResetCircuitBreakers godoc
+//
+//	@Summary		Reset circuit breakers
+//	@Description	Closes all circuit breakers of this instance
+//	@Tags			monitor
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	middleware.APIResponse
+//	@Router			/api/monitor/circuit_breakers [delete]
+func ResetCircuitBreakers(c *gin.Context) {
+	monitor.ResetAllBreakers()
+	middleware.SuccessResponse(c, nil)
+}
+
+// GetChannelBandit godoc
+//
+//	@Summary		Get channel bandit
+//	@Description	Returns the feedback scores of the channel-model pairs on this instance, the models enabling the channel bandit shift the traffic to the channels with the best reward
+//	@Tags			monitor
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	middleware.APIResponse{data=[]monitor.BanditArmSnapshot}
+//	@Router			/api/monitor/channel_bandit [get]
+func GetChannelBandit(c *gin.Context) {
+	middleware.SuccessResponse(c, monitor.GetBanditSnapshots())
+}
+
+// GetChannelStreams godoc
+//
+//	@Summary		Get channel streams
+//	@Description	Returns the in-flight requests of the channels on this instance, a request to a channel at its max_concurrent_streams on this instance is retried on another channel, the limit is not shared across the cluster
+//	@Tags			monitor
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	middleware.APIResponse{data=[]monitor.ChannelStreamCount}
+//	@Router			/api/monitor/channel_streams [get]
+func GetChannelStreams(c *gin.Context) {
+	middleware.SuccessResponse(c, monitor.GetChannelStreamCounts())
+}
+
+// GetChannelReadiness godoc
+//
+//	@Summary		Get channel readiness
+//	@Description	Returns the channels found not ready by the readiness probes of this instance, e.g. the self hosted inference servers with a saturated gpu, they are skipped by the selector until they are ready again
+//	@Tags			monitor
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	middleware.APIResponse{data=[]monitor.NotReadyChannel}
+//	@Router			/api/monitor/channel_readiness [get]
+func GetChannelReadiness(c *gin.Context) {
+	middleware.SuccessResponse(c, monitor.GetNotReadyChannels())
+}
+
+// GetFairQueueStats godoc
+//
+//	@Summary		Get fair queue stats
+//	@Description	Returns the in-flight requests and the requests of the groups waiting for the admission on this instance
+//	@Tags			monitor
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	middleware.APIResponse{data=fairqueue.Stats}
+//	@Router			/api/monitor/fair_queue [get]
+func GetFairQueueStats(c *gin.Context) {
+	middleware.SuccessResponse(c, middleware.GetFairQueueStats())
+}
+
+// GetDrainStats godoc
+//
+//	@Summary		Get drain stats
+//	@Description	Returns whether this instance is draining for the shutdown and its in-flight relay requests by mode
+//	@Tags			monitor
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	middleware.APIResponse{data=drain.Stats}
+//	@Router			/api/monitor/drain [get]
+func GetDrainStats(c *gin.Context) {
+	middleware.SuccessResponse(c, middleware.GetDrainStats())
+}
+
+// GetBatchSummaryStats godoc
+//
+//	@Summary		Get batch summary stats
+//	@Description	Returns the summary updates pending in memory, the flush results and the updates spilled to the disk on this instance
+//	@Tags			monitor
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	middleware.APIResponse{data=model.BatchSummaryStats}
+//	@Router			/api/monitor/batch_summary [get]
+func GetBatchSummaryStats(c *gin.Context) {
+	middleware.SuccessResponse(c, model.GetBatchSummaryStats())
+}
+
 // GetRuntimeMetrics godoc
 //
 //	@Summary		Get runtime metrics for models and channels
@@ -741,6 +848,46 @@ func GetRuntimeMetrics(c *gin.Context) {
 	middleware.SuccessResponse(c, resp)
 }
 
+// GetConversionMetrics godoc
+//
+//	@Summary		Get adaptor conversion latency metrics
+//	@Description	Returns per-adaptor request/response conversion latency histograms of this instance
+//	@Tags			monitor
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	middleware.APIResponse{data=[]conversion.Histogram}
+//	@Router			/api/monitor/conversion_metrics [get]
+func GetConversionMetrics(c *gin.Context) {
+	middleware.SuccessResponse(c, conversion.Snapshot())
+}
+
+// ResetConversionMetrics godoc
+//
+//	@Summary		Reset adaptor conversion latency metrics
+//	@Description	Clears the conversion latency histograms of this instance
+//	@Tags			monitor
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	middleware.APIResponse
+//	@Router			/api/monitor/conversion_metrics [delete]
+func ResetConversionMetrics(c *gin.Context) {
+	conversion.Reset()
+	middleware.SuccessResponse(c, nil)
+}
+
+// GetTokenizerMetrics godoc
+//
+//	@Summary		Get tokenizer encoder cache metrics
+//	@Description	Returns the token encoder cache hits and misses of this instance
+//	@Tags			monitor
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	middleware.APIResponse{data=tiktoken.CacheStats}
+//	@Router			/api/monitor/tokenizer_metrics [get]
+func GetTokenizerMetrics(c *gin.Context) {
+	middleware.SuccessResponse(c, tiktoken.Stats())
+}
+
 // GetGroupSummaryMetrics godoc
 //
 //	@Summary		Get summary metrics for multiple groups
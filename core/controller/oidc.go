@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"crypto/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/common/oidc"
+	"github.com/labring/aiproxy/core/middleware"
+)
+
+const (
+	oidcStateCookie    = "aiproxy_oidc_state"
+	oidcStateCookieTTL = 10 * time.Minute
+)
+
+type OIDCLoginResult struct {
+	Token     string    `json:"token"`
+	Role      oidc.Role `json:"role"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	ExpiresAt int64     `json:"expires_at"`
+}
+
+// OIDCLogin godoc
+//
+//	@Summary		OIDC login
+//	@Description	Redirects to the identity provider to sign in to the admin api
+//	@Tags			oidc
+//	@Success		302
+//	@Router			/api/oidc/login [get]
+func OIDCLogin(c *gin.Context) {
+	if !config.OIDCEnabled() {
+		middleware.ErrorResponse(c, http.StatusNotFound, "oidc is not enabled")
+		return
+	}
+
+	state := rand.Text()
+	nonce := rand.Text()
+
+	authURL, err := oidc.AuthCodeURL(state, nonce)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(
+		oidcStateCookie,
+		state+"."+nonce,
+		int(oidcStateCookieTTL.Seconds()),
+		"/api/oidc",
+		"",
+		c.Request.TLS != nil,
+		true,
+	)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback godoc
+//
+//	@Summary		OIDC callback
+//	@Description	Exchanges the authorization code for a short-lived admin token,
+//	@Description	redirects to OIDC_POST_LOGIN_REDIRECT with the token in the url fragment when it is set
+//	@Tags			oidc
+//	@Produce		json
+//	@Param			code	query		string	true	"Authorization code"
+//	@Param			state	query		string	true	"State"
+//	@Success		200		{object}	middleware.APIResponse{data=OIDCLoginResult}
+//	@Router			/api/oidc/callback [get]
+func OIDCCallback(c *gin.Context) {
+	if !config.OIDCEnabled() {
+		middleware.ErrorResponse(c, http.StatusNotFound, "oidc is not enabled")
+		return
+	}
+
+	if errMsg := c.Query("error"); errMsg != "" {
+		middleware.ErrorResponse(
+			c,
+			http.StatusUnauthorized,
+			"oidc login failed: "+errMsg+" "+c.Query("error_description"),
+		)
+
+		return
+	}
+
+	cookie, err := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, "/api/oidc", "", c.Request.TLS != nil, true)
+
+	state, nonce, ok := strings.Cut(cookie, ".")
+	if err != nil || !ok || state == "" || state != c.Query("state") {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "invalid oidc state")
+		return
+	}
+
+	identity, err := oidc.Exchange(c.Request.Context(), c.Query("code"), nonce)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	role, ok := oidc.MapRole(identity.Groups)
+	if !ok {
+		middleware.ErrorResponse(
+			c,
+			http.StatusForbidden,
+			"no aiproxy role is mapped to the groups of "+identity.Subject,
+		)
+
+		return
+	}
+
+	token, expiresAt, err := oidc.IssueAdminToken(
+		identity.Subject,
+		identity.Email,
+		identity.Name,
+		role,
+	)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if config.OIDCPostLoginRedirect != "" {
+		fragment := url.Values{
+			"token":      {token},
+			"role":       {string(role)},
+			"expires_at": {expiresAt.Format(time.RFC3339)},
+		}
+		c.Redirect(http.StatusFound, config.OIDCPostLoginRedirect+"#"+fragment.Encode())
+
+		return
+	}
+
+	middleware.SuccessResponse(c, &OIDCLoginResult{
+		Token:     token,
+		Role:      role,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		Name:      identity.Name,
+		ExpiresAt: expiresAt.UnixMilli(),
+	})
+}
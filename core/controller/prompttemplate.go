@@ -0,0 +1,247 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+	"gorm.io/gorm"
+)
+
+type SavePromptTemplateRequest struct {
+	Name        string                        `json:"name"`
+	Description string                        `json:"description"`
+	Model       string                        `json:"model"`
+	Messages    []model.PromptTemplateMessage `json:"messages"`
+	Defaults    map[string]string             `json:"defaults"`
+}
+
+type RenderPromptTemplateRequest struct {
+	Version   int64             `json:"version"`
+	Variables map[string]string `json:"variables"`
+}
+
+type RenderPromptTemplateResponse struct {
+	Name     string                        `json:"name"`
+	Version  int64                         `json:"version"`
+	Messages []model.PromptTemplateMessage `json:"messages"`
+}
+
+func promptTemplateErrorStatus(err error) int {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// GetGroupPromptTemplates godoc
+//
+//	@Summary		Get group prompt templates
+//	@Description	Returns the latest versions of the prompt templates of the group
+//	@Tags			group
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			group	path		string	true	"Group name"
+//	@Success		200		{object}	middleware.APIResponse{data=[]model.PromptTemplate}
+//	@Router			/api/group/{group}/prompt_templates/ [get]
+func GetGroupPromptTemplates(c *gin.Context) {
+	group := c.Param("group")
+	if group == "" {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "invalid parameter")
+		return
+	}
+
+	templates, err := model.GetPromptTemplates(group)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, templates)
+}
+
+// SaveGroupPromptTemplate godoc
+//
+//	@Summary		Save group prompt template
+//	@Description	Saves the prompt template as its next version, the previous versions are kept
+//	@Tags			group
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			group		path		string						true	"Group name"
+//	@Param			template	body		SavePromptTemplateRequest	true	"Prompt template"
+//	@Success		200			{object}	middleware.APIResponse{data=model.PromptTemplate}
+//	@Router			/api/group/{group}/prompt_templates/ [post]
+func SaveGroupPromptTemplate(c *gin.Context) {
+	group := c.Param("group")
+	if group == "" {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "invalid parameter")
+		return
+	}
+
+	var req SavePromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	template := &model.PromptTemplate{
+		GroupID:     group,
+		Name:        req.Name,
+		Description: req.Description,
+		Model:       req.Model,
+		Messages:    req.Messages,
+		Defaults:    req.Defaults,
+	}
+
+	if err := template.Validate(); err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := model.SavePromptTemplate(template); err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, template)
+}
+
+// GetGroupPromptTemplate godoc
+//
+//	@Summary		Get group prompt template
+//	@Description	Returns the version of the prompt template, the latest version without the version query
+//	@Tags			group
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			group	path		string	true	"Group name"
+//	@Param			name	path		string	true	"Template name"
+//	@Param			version	query		int		false	"Template version"
+//	@Success		200		{object}	middleware.APIResponse{data=model.PromptTemplate}
+//	@Router			/api/group/{group}/prompt_template/{name} [get]
+func GetGroupPromptTemplate(c *gin.Context) {
+	group := c.Param("group")
+	name := c.Param("name")
+
+	if group == "" || name == "" {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "invalid parameter")
+		return
+	}
+
+	version, _ := strconv.ParseInt(c.Query("version"), 10, 64)
+
+	template, err := model.GetPromptTemplate(group, name, version)
+	if err != nil {
+		middleware.ErrorResponse(c, promptTemplateErrorStatus(err), err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, template)
+}
+
+// GetGroupPromptTemplateVersions godoc
+//
+//	@Summary		Get group prompt template versions
+//	@Description	Returns all the versions of the prompt template, the latest version first
+//	@Tags			group
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			group	path		string	true	"Group name"
+//	@Param			name	path		string	true	"Template name"
+//	@Success		200		{object}	middleware.APIResponse{data=[]model.PromptTemplate}
+//	@Router			/api/group/{group}/prompt_template/{name}/versions [get]
+func GetGroupPromptTemplateVersions(c *gin.Context) {
+	group := c.Param("group")
+	name := c.Param("name")
+
+	if group == "" || name == "" {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "invalid parameter")
+		return
+	}
+
+	templates, err := model.GetPromptTemplateVersions(group, name)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, templates)
+}
+
+// RenderGroupPromptTemplate godoc
+//
+//	@Summary		Render group prompt template
+//	@Description	Renders the prompt template with the variables, the messages sent with the template query are the same
+//	@Tags			group
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			group	path		string						true	"Group name"
+//	@Param			name	path		string						true	"Template name"
+//	@Param			request	body		RenderPromptTemplateRequest	true	"Variables"
+//	@Success		200		{object}	middleware.APIResponse{data=RenderPromptTemplateResponse}
+//	@Router			/api/group/{group}/prompt_template/{name}/render [post]
+func RenderGroupPromptTemplate(c *gin.Context) {
+	group := c.Param("group")
+	name := c.Param("name")
+
+	if group == "" || name == "" {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "invalid parameter")
+		return
+	}
+
+	var req RenderPromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	template, err := model.GetPromptTemplate(group, name, req.Version)
+	if err != nil {
+		middleware.ErrorResponse(c, promptTemplateErrorStatus(err), err.Error())
+		return
+	}
+
+	messages, err := template.Render(req.Variables)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, RenderPromptTemplateResponse{
+		Name:     template.Name,
+		Version:  template.Version,
+		Messages: messages,
+	})
+}
+
+// DeleteGroupPromptTemplate godoc
+//
+//	@Summary		Delete group prompt template
+//	@Description	Deletes all the versions of the prompt template
+//	@Tags			group
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			group	path		string	true	"Group name"
+//	@Param			name	path		string	true	"Template name"
+//	@Success		200		{object}	middleware.APIResponse
+//	@Router			/api/group/{group}/prompt_template/{name} [delete]
+func DeleteGroupPromptTemplate(c *gin.Context) {
+	group := c.Param("group")
+	name := c.Param("name")
+
+	if group == "" || name == "" {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "invalid parameter")
+		return
+	}
+
+	if err := model.DeletePromptTemplate(group, name); err != nil {
+		middleware.ErrorResponse(c, promptTemplateErrorStatus(err), err.Error())
+		return
+	}
+
+	middleware.SuccessResponse(c, nil)
+}
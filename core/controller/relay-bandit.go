@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/monitor"
+)
+
+// getBanditConfig returns the bandit config of the model, false is returned
+// when the model does not enable the bandit
+func getBanditConfig(modelConfig model.ModelConfig) (monitor.BanditConfig, bool) {
+	enabled, _ := model.GetModelConfigBool(modelConfig.Config, model.ModelConfigChannelBanditKey)
+	if !enabled {
+		return monitor.BanditConfig{}, false
+	}
+
+	cfg := monitor.BanditConfig{
+		ExplorationRate: monitor.DefaultBanditExplorationRate,
+	}
+
+	if explorationRate, ok := model.GetModelConfigFloat(
+		modelConfig.Config,
+		model.ModelConfigChannelBanditExplorationRateKey,
+	); ok {
+		cfg.ExplorationRate = min(max(explorationRate, 0), 1)
+	}
+
+	cfg.CostWeight, _ = model.GetModelConfigFloat(
+		modelConfig.Config,
+		model.ModelConfigChannelBanditCostWeightKey,
+	)
+
+	costs, _ := model.GetModelConfigFloatMap(
+		modelConfig.Config,
+		model.ModelConfigChannelBanditChannelCostsKey,
+	)
+	for channelID, cost := range costs {
+		id, err := strconv.ParseInt(channelID, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if cfg.ChannelCosts == nil {
+			cfg.ChannelCosts = make(map[int64]float64, len(costs))
+		}
+
+		cfg.ChannelCosts[id] = cost
+	}
+
+	return cfg, true
+}
+
+// pickModelChannel picks the channel with the bandit when the model enables
+// it, otherwise by the priorities weighted by the error rates
+func pickModelChannel(
+	modelConfig model.ModelConfig,
+	modelName string,
+	channels []*model.Channel,
+	errorRates map[int64]float64,
+) (*model.Channel, error) {
+	cfg, ok := getBanditConfig(modelConfig)
+	if !ok || len(channels) == 0 {
+		return pickChannel(channels, errorRates)
+	}
+
+	channelIDs := make([]int64, len(channels))
+	for i, channel := range channels {
+		channelIDs[i] = int64(channel.ID)
+	}
+
+	return channels[monitor.PickBanditChannel(modelName, channelIDs, errorRates, cfg)], nil
+}
@@ -0,0 +1,103 @@
+//nolint:testpackage
+package controller
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/monitor"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type banditModelConfigCache map[string]model.ModelConfig
+
+func (c banditModelConfigCache) GetModelConfig(modelName string) (model.ModelConfig, bool) {
+	config, ok := c[modelName]
+	return config, ok
+}
+
+func TestGetBanditConfig(t *testing.T) {
+	_, ok := getBanditConfig(model.ModelConfig{})
+	assert.False(t, ok)
+
+	cfg, ok := getBanditConfig(model.ModelConfig{
+		Config: model.NewModelConfig(
+			model.WithModelConfigChannelBandit(0.2, 0.5, map[int]float64{1: 2, 2: 1}),
+		),
+	})
+	require.True(t, ok)
+	assert.InDelta(t, 0.2, cfg.ExplorationRate, 1e-9)
+	assert.InDelta(t, 0.5, cfg.CostWeight, 1e-9)
+	assert.Equal(t, map[int64]float64{1: 2, 2: 1}, cfg.ChannelCosts)
+
+	// the configs loaded from json
+	cfg, ok = getBanditConfig(model.ModelConfig{
+		Config: map[model.ModelConfigKey]any{
+			model.ModelConfigChannelBanditKey:             true,
+			model.ModelConfigChannelBanditChannelCostsKey: map[string]any{"3": float64(1.5), "x": 1},
+		},
+	})
+	require.True(t, ok)
+	assert.InDelta(t, monitor.DefaultBanditExplorationRate, cfg.ExplorationRate, 1e-9)
+	assert.Equal(t, map[int64]float64{3: 1.5}, cfg.ChannelCosts)
+}
+
+func TestGetChannelWithFallbackBandit(t *testing.T) {
+	const modelName = "gpt-bandit-test"
+
+	ch1 := &model.Channel{
+		ID:       101,
+		Type:     model.ChannelTypeOpenAI,
+		Status:   model.ChannelStatusEnabled,
+		Priority: 1000,
+	}
+	ch2 := &model.Channel{
+		ID:       102,
+		Type:     model.ChannelTypeOpenAI,
+		Status:   model.ChannelStatusEnabled,
+		Priority: 1,
+	}
+
+	mc := &model.ModelCaches{
+		ModelConfig: banditModelConfigCache{
+			modelName: {
+				Model: modelName,
+				Config: model.NewModelConfig(
+					model.WithModelConfigChannelBandit(0, 0, nil),
+				),
+			},
+		},
+		EnabledModel2ChannelsBySet: map[string]map[string][]*model.Channel{
+			model.ChannelDefaultSet: {
+				modelName: {ch1, ch2},
+			},
+		},
+	}
+
+	for range 10 {
+		monitor.RecordBanditFeedback(modelName, 101, 0)
+		monitor.RecordBanditFeedback(modelName, 102, 1)
+	}
+
+	t.Cleanup(func() {
+		monitor.ResetChannelBandit(101)
+		monitor.ResetChannelBandit(102)
+	})
+
+	// the feedback outweighs the priority without exploration
+	for range 20 {
+		channel, _, err := getChannelWithFallback(
+			mc,
+			[]string{model.ChannelDefaultSet},
+			modelName,
+			mode.ChatCompletions,
+			nil,
+			nil,
+			nil,
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 102, channel.ID)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"math"
 	"math/rand/v2"
 	"strconv"
@@ -14,6 +15,7 @@ import (
 	"github.com/labring/aiproxy/core/model"
 	"github.com/labring/aiproxy/core/monitor"
 	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
 	"github.com/labring/aiproxy/core/relay/adaptors"
 	relaymeta "github.com/labring/aiproxy/core/relay/meta"
 	"github.com/labring/aiproxy/core/relay/mode"
@@ -55,6 +57,21 @@ func adaptorSupportsMode(
 	modelName string,
 	m mode.Mode,
 ) bool {
+	// the follow-up requests of a pinned channel were allowed when the
+	// resource was created
+	if !needPinChannel(m) && !channel.AllowedModes.Allows(m) {
+		return false
+	}
+
+	switch m {
+	case mode.ChatCompletions:
+		a = openai.NewChatTemplateAdaptor(a)
+	case mode.Completions:
+		a = openai.NewCompletionsToChatAdaptor(a)
+	case mode.Responses:
+		a = openai.NewResponsesToChatAdaptor(a)
+	}
+
 	return a.SupportMode(supportModeMeta(mc, channel, modelName, m))
 }
 
@@ -118,10 +135,14 @@ func needPinChannel(m mode.Mode) bool {
 		mode.VideosGet,
 		mode.VideosContent,
 		mode.VideosDelete,
+		mode.AudioGenerationsGet,
 		mode.ResponsesGet,
 		mode.ResponsesDelete,
 		mode.ResponsesCancel,
-		mode.ResponsesInputItems:
+		mode.ResponsesInputItems,
+		mode.FilesGet,
+		mode.FilesContent,
+		mode.FilesDelete:
 		return true
 	default:
 		return false
@@ -320,6 +341,28 @@ func getChannelWithFallback(
 	preferChannelIDs []int,
 	errorRates map[int64]float64,
 	ignoreChannelIDs map[int64]struct{},
+) (*model.Channel, []*model.Channel, error) {
+	return getChannelWithPolicy(
+		cache,
+		availableSet,
+		modelName,
+		mode,
+		nil,
+		preferChannelIDs,
+		errorRates,
+		ignoreChannelIDs,
+	)
+}
+
+func getChannelWithPolicy(
+	cache *model.ModelCaches,
+	availableSet []string,
+	modelName string,
+	mode mode.Mode,
+	policy *channelPolicy,
+	preferChannelIDs []int,
+	errorRates map[int64]float64,
+	ignoreChannelIDs map[int64]struct{},
 ) (*model.Channel, []*model.Channel, error) {
 	migratedChannels, err := getAvailableChannels(
 		cache,
@@ -331,6 +374,11 @@ func getChannelWithFallback(
 		return nil, nil, err
 	}
 
+	migratedChannels, err = policy.filter(migratedChannels, modelName)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	filteredChannels := filterChannels(
 		migratedChannels,
 		errorRates,
@@ -338,9 +386,21 @@ func getChannelWithFallback(
 		ignoreChannelIDs,
 	)
 
+	// the channels preferred by the policy are tried first
+	preferredChannels := policy.preferred(filteredChannels)
+
+	// the channels relaying the request in the protocol of the client are tried
+	// before the channels converting it to another protocol and back
+	nativeChannels := filterNativeChannels(cache, filteredChannels, modelName, mode)
+
 	if len(preferChannelIDs) > 0 {
+		candidates := filteredChannels
+		if len(preferredChannels) > 0 {
+			candidates = preferredChannels
+		}
+
 		channel := pickPreferredChannel(
-			filteredChannels,
+			candidates,
 			preferChannelIDs,
 		)
 		if channel != nil {
@@ -349,6 +409,15 @@ func getChannelWithFallback(
 	}
 
 	pipeline := []func() []*model.Channel{
+		func() []*model.Channel {
+			return filterNativeChannels(cache, preferredChannels, modelName, mode)
+		},
+		func() []*model.Channel {
+			return preferredChannels
+		},
+		func() []*model.Channel {
+			return nativeChannels
+		},
 		func() []*model.Channel {
 			return filteredChannels
 		},
@@ -369,8 +438,13 @@ func getChannelWithFallback(
 		},
 	}
 
+	var modelConfig model.ModelConfig
+	if cache.ModelConfig != nil {
+		modelConfig, _ = cache.ModelConfig.GetModelConfig(modelName)
+	}
+
 	for _, step := range pipeline {
-		channel, err := pickChannel(step(), errorRates)
+		channel, err := pickModelChannel(modelConfig, modelName, step(), errorRates)
 		if err == nil {
 			return channel, migratedChannels, nil
 		}
@@ -379,6 +453,44 @@ func getChannelWithFallback(
 	return nil, nil, ErrChannelsExhausted
 }
 
+// nativeProtocolModes are the modes preferring the channels that speak the
+// protocol of the client, the other modes are converted by most adaptors
+var nativeProtocolModes = map[mode.Mode]struct{}{
+	mode.Anthropic: {},
+}
+
+// filterNativeChannels returns the channels whose adaptor relays the mode in
+// the protocol of the client, nil is returned for the modes without a native
+// preference
+func filterNativeChannels(
+	mc *model.ModelCaches,
+	channels []*model.Channel,
+	modelName string,
+	m mode.Mode,
+) []*model.Channel {
+	if _, ok := nativeProtocolModes[m]; !ok {
+		return nil
+	}
+
+	var native []*model.Channel
+
+	for _, channel := range channels {
+		a, ok := adaptors.GetAdaptor(channel.Type)
+		if !ok {
+			continue
+		}
+
+		nativeAdaptor, ok := a.(adaptor.NativeModeAdaptor)
+		if !ok || !nativeAdaptor.NativeMode(supportModeMeta(mc, channel, modelName, m)) {
+			continue
+		}
+
+		native = append(native, channel)
+	}
+
+	return native
+}
+
 func pickPreferredChannel(
 	channels []*model.Channel,
 	preferChannelIDs []int,
@@ -469,6 +581,8 @@ func getInitialChannel(c *gin.Context, modelName string, m mode.Mode) (*initialC
 		log.Errorf("get %s auto banned channels failed: %+v", modelName, err)
 	}
 
+	ignoreChannelIDs = mergeBreakerOpenChannels(modelName, ignoreChannelIDs)
+
 	log.Debugf("%s model banned channels: %+v", modelName, ignoreChannelIDs)
 
 	errorRates, err := monitor.GetModelChannelErrorRate(c.Request.Context(), modelName)
@@ -483,15 +597,25 @@ func getInitialChannel(c *gin.Context, modelName string, m mode.Mode) (*initialC
 
 	preferChannelIDs := getPreferChannelIDs(c, modelName, m)
 
+	// the channels ordered by the provider preferences are tried before the
+	// channels followed for the cache
+	if preferences := getProviderPreferences(c); preferences != nil {
+		channels, _ := getAvailableChannels(mc, availableSet, modelName, m)
+		if ordered := preferences.orderedChannelIDs(channels); len(ordered) > 0 {
+			preferChannelIDs = append(ordered, preferChannelIDs...)
+		}
+	}
+
 	if len(preferChannelIDs) > 0 {
 		log.Data["prefer_channels"] = fmt.Sprintf("%v", preferChannelIDs)
 	}
 
-	channel, migratedChannels, err := getChannelWithFallback(
+	channel, migratedChannels, err := getChannelWithPolicy(
 		mc,
 		availableSet,
 		modelName,
 		m,
+		getChannelPolicy(c),
 		preferChannelIDs,
 		errorRates,
 		ignoreChannelIDs,
@@ -621,19 +745,43 @@ func getPreferChannelIDs(c *gin.Context, modelName string, m mode.Mode) []int {
 	return preferChannelIDs
 }
 
+// mergeBreakerOpenChannels returns a new set, the banned set may be shared by the local cache
+func mergeBreakerOpenChannels(
+	modelName string,
+	ignoreChannelIDs map[int64]struct{},
+) map[int64]struct{} {
+	openChannels := monitor.GetBreakerOpenChannels(modelName)
+	if len(openChannels) == 0 {
+		return ignoreChannelIDs
+	}
+
+	merged := make(map[int64]struct{}, len(ignoreChannelIDs)+len(openChannels))
+	maps.Copy(merged, ignoreChannelIDs)
+	maps.Copy(merged, openChannels)
+
+	return merged
+}
+
+// getWebSearchChannel picks the channel of the requests sent by the plugins,
+// e.g. the search query rewrite, within the sets and the channel policy of the
+// request, no channel is returned when none of them complies
 func getWebSearchChannel(
 	ctx context.Context,
 	mc *model.ModelCaches,
+	availableSet []string,
+	policy *channelPolicy,
 	modelName string,
 ) (*model.Channel, error) {
 	ignoreChannelIDs, _ := monitor.GetBannedChannelsMapWithModel(ctx, modelName)
+	ignoreChannelIDs = mergeBreakerOpenChannels(modelName, ignoreChannelIDs)
 	errorRates, _ := monitor.GetModelChannelErrorRate(ctx, modelName)
 
-	channel, _, err := getChannelWithFallback(
+	channel, _, err := getChannelWithPolicy(
 		mc,
-		nil,
+		availableSet,
 		modelName,
 		mode.ChatCompletions,
+		policy,
 		nil,
 		errorRates,
 		ignoreChannelIDs)
@@ -644,6 +792,22 @@ func getWebSearchChannel(
 	return channel, nil
 }
 
+// newPluginChannelGetter binds getWebSearchChannel to the sets and the channel
+// policy of the request
+func newPluginChannelGetter(
+	c *gin.Context,
+	mc *model.ModelCaches,
+) func(modelName string) (*model.Channel, error) {
+	ctx := c.Request.Context()
+	group := middleware.GetGroup(c)
+	availableSet := group.GetAvailableSets()
+	policy := getChannelPolicy(c)
+
+	return func(modelName string) (*model.Channel, error) {
+		return getWebSearchChannel(ctx, mc, availableSet, policy, modelName)
+	}
+}
+
 func getRetryChannel(
 	ctx context.Context,
 	state *retryState,
@@ -689,7 +853,9 @@ func getRetryChannel(
 		}
 	}
 
-	newChannel, err := pickChannel(
+	newChannel, err := pickModelChannel(
+		state.meta.ModelConfig,
+		state.meta.OriginModel,
 		filteredChannels,
 		errorRates,
 	)
@@ -745,6 +911,19 @@ func isChannelSelectable(
 
 	chid := int64(channel.ID)
 
+	// a channel found not ready by its readiness probe, e.g. a self hosted
+	// inference server with a saturated gpu, is skipped by every step
+	if monitor.ChannelNotReady(chid) {
+		return false
+	}
+
+	// a channel with every stream slot taken on this instance would reject
+	// the request, TryAcquireChannelStream still guards the race on the last slot
+	if channel.MaxConcurrentStreams > 0 &&
+		monitor.GetChannelStreams(chid) >= int64(channel.MaxConcurrentStreams) {
+		return false
+	}
+
 	if maxErrorRate != 0 {
 		// Filter out channels with error rate higher than threshold
 		// This avoids amplifying attacks and retrying with bad channels.
@@ -309,6 +309,57 @@ func TestGetPriorityWeightHandlesNilErrorRatesMap(t *testing.T) {
 	assert.InDelta(t, 1000.0, getPriorityWeight(channel, getChannelErrorRate(nil, 123)), 0.0001)
 }
 
+func TestGetChannelWithFallbackPrefersNativeProtocolChannels(t *testing.T) {
+	t.Parallel()
+
+	mc := &model.ModelCaches{
+		EnabledModel2ChannelsBySet: map[string]map[string][]*model.Channel{
+			model.ChannelDefaultSet: {
+				"claude-sonnet-4-5": {
+					{
+						ID:       1,
+						Type:     model.ChannelTypeOpenAI,
+						Status:   model.ChannelStatusEnabled,
+						Priority: 1000,
+					},
+					{
+						ID:       2,
+						Type:     model.ChannelTypeAnthropic,
+						Status:   model.ChannelStatusEnabled,
+						Priority: 1,
+					},
+				},
+			},
+		},
+	}
+
+	for range 20 {
+		channel, _, err := getChannelWithFallback(
+			mc,
+			[]string{model.ChannelDefaultSet},
+			"claude-sonnet-4-5",
+			mode.Anthropic,
+			nil,
+			map[int64]float64{},
+			nil,
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 2, channel.ID)
+	}
+
+	channel, _, err := getChannelWithFallback(
+		mc,
+		[]string{model.ChannelDefaultSet},
+		"claude-sonnet-4-5",
+		mode.Anthropic,
+		nil,
+		map[int64]float64{},
+		map[int64]struct{}{2: {}},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 1, channel.ID)
+}
+
 func TestGetChannelWithFallbackHandlesNilInputs(t *testing.T) {
 	t.Parallel()
 
@@ -681,3 +732,56 @@ func withTestStoreDB(t *testing.T, fn func()) {
 
 	fn()
 }
+
+func TestGetAvailableChannelsRespectsAllowedModes(t *testing.T) {
+	t.Parallel()
+
+	embeddingsOnly := &model.Channel{
+		ID:           1,
+		Type:         model.ChannelTypeOpenAI,
+		Status:       model.ChannelStatusEnabled,
+		AllowedModes: model.AllowedModes{mode.Embeddings.String()},
+	}
+	all := &model.Channel{
+		ID:     2,
+		Type:   model.ChannelTypeOpenAI,
+		Status: model.ChannelStatusEnabled,
+	}
+
+	mc := &model.ModelCaches{
+		EnabledModel2ChannelsBySet: map[string]map[string][]*model.Channel{
+			model.ChannelDefaultSet: {
+				"gpt-image-1": {embeddingsOnly, all},
+			},
+		},
+	}
+
+	channels, err := getAvailableChannels(
+		mc,
+		[]string{model.ChannelDefaultSet},
+		"gpt-image-1",
+		mode.ImagesGenerations,
+	)
+	require.NoError(t, err)
+	require.Len(t, channels, 1)
+	assert.Equal(t, 2, channels[0].ID)
+
+	channels, err = getAvailableChannels(
+		mc,
+		[]string{model.ChannelDefaultSet},
+		"gpt-image-1",
+		mode.Embeddings,
+	)
+	require.NoError(t, err)
+	assert.Len(t, channels, 2)
+
+	all.AllowedModes = model.AllowedModes{mode.ChatCompletions.String()}
+
+	_, err = getAvailableChannels(
+		mc,
+		[]string{model.ChannelDefaultSet},
+		"gpt-image-1",
+		mode.ImagesGenerations,
+	)
+	require.ErrorIs(t, err, ErrChannelsNotFound)
+}
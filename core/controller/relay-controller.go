@@ -2,7 +2,6 @@ package controller
 
 import (
 	"bytes"
-	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -22,6 +21,7 @@ import (
 	"github.com/labring/aiproxy/core/model"
 	"github.com/labring/aiproxy/core/monitor"
 	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
 	"github.com/labring/aiproxy/core/relay/adaptors"
 	"github.com/labring/aiproxy/core/relay/controller"
 	"github.com/labring/aiproxy/core/relay/meta"
@@ -30,9 +30,16 @@ import (
 	"github.com/labring/aiproxy/core/relay/plugin"
 	"github.com/labring/aiproxy/core/relay/plugin/cache"
 	"github.com/labring/aiproxy/core/relay/plugin/cachefollow"
+	"github.com/labring/aiproxy/core/relay/plugin/contentfilter"
+	"github.com/labring/aiproxy/core/relay/plugin/contextguard"
+	"github.com/labring/aiproxy/core/relay/plugin/conversion"
+	"github.com/labring/aiproxy/core/relay/plugin/embeddingcache"
+	"github.com/labring/aiproxy/core/relay/plugin/mediaurl"
 	monitorplugin "github.com/labring/aiproxy/core/relay/plugin/monitor"
 	"github.com/labring/aiproxy/core/relay/plugin/patch"
+	"github.com/labring/aiproxy/core/relay/plugin/promptcompress"
 	"github.com/labring/aiproxy/core/relay/plugin/streamfake"
+	"github.com/labring/aiproxy/core/relay/plugin/strictschema"
 	"github.com/labring/aiproxy/core/relay/plugin/thinksplit"
 	"github.com/labring/aiproxy/core/relay/plugin/timeout"
 	websearch "github.com/labring/aiproxy/core/relay/plugin/web-search"
@@ -119,19 +126,28 @@ func (s *storeImpl) SaveIfNotExistStore(store adaptor.StoreCache) error {
 	return err
 }
 
-func wrapPlugin(ctx context.Context, mc *model.ModelCaches, a adaptor.Adaptor) adaptor.Adaptor {
+func wrapPlugin(
+	mc *model.ModelCaches,
+	getChannel func(modelName string) (*model.Channel, error),
+	a adaptor.Adaptor,
+) adaptor.Adaptor {
 	return plugin.WrapperAdaptor(a,
 		monitorplugin.NewGroupMonitorPlugin(),
-		cache.NewCachePlugin(common.RDB),
+		cache.NewCachePlugin(common.CacheRDB()),
+		embeddingcache.NewEmbeddingCachePlugin(common.CacheRDB()),
 		cachefollow.NewCacheFollowPlugin(),
+		strictschema.NewStrictSchemaPlugin(),
 		streamfake.NewStreamFakePlugin(),
 		timeout.NewTimeoutPlugin(),
-		websearch.NewWebSearchPlugin(func(modelName string) (*model.Channel, error) {
-			return getWebSearchChannel(ctx, mc, modelName)
-		}),
+		mediaurl.NewMediaURLPlugin(),
+		websearch.NewWebSearchPlugin(getChannel),
+		promptcompress.NewPromptCompressPlugin(getChannel, mc.ModelConfig.GetModelConfig),
+		contextguard.NewContextGuardPlugin(getChannel, mc.ModelConfig.GetModelConfig),
+		contentfilter.NewContentFilterPlugin(),
 		thinksplit.NewThinkPlugin(),
 		monitorplugin.NewChannelMonitorPlugin(),
 		patch.NewPatchPlugin(),
+		conversion.NewConversionPlugin(),
 	)
 }
 
@@ -139,7 +155,7 @@ func relayHandler(c *gin.Context, meta *meta.Meta, mc *model.ModelCaches) *contr
 	log := common.GetLogger(c)
 	middleware.SetLogFieldsFromMeta(meta, log.Data)
 
-	adaptor, ok := adaptors.GetAdaptor(meta.Channel.Type)
+	adaptor, ok := relayAdaptor(meta, mc, newPluginChannelGetter(c, mc))
 	if !ok {
 		return &controller.HandleResult{
 			Error: relaymodel.WrapperOpenAIErrorWithMessage(
@@ -150,11 +166,57 @@ func relayHandler(c *gin.Context, meta *meta.Meta, mc *model.ModelCaches) *contr
 		}
 	}
 
-	adaptor = wrapPlugin(c.Request.Context(), mc, adaptor)
+	// the selector skips the saturated channels, the slot is taken here so
+	// two requests can not both pass the check of the last free slot, the
+	// loser fails with a retriable status and the retry ignores the channel
+	release, ok := monitor.TryAcquireChannelStream(
+		int64(meta.Channel.ID),
+		meta.Channel.MaxConcurrentStreams,
+	)
+	if !ok {
+		return &controller.HandleResult{
+			Error: relaymodel.WrapperOpenAIErrorWithMessage(
+				fmt.Sprintf(
+					"channel (id: %d) reached its max concurrent streams",
+					meta.Channel.ID,
+				),
+				"channel_streams_saturated",
+				http.StatusTooManyRequests,
+			),
+			StreamsSaturated: true,
+		}
+	}
+
+	defer release()
 
 	return controller.Handle(adaptor, c, meta, AdaptorStore, buildBodyDetailOption(meta))
 }
 
+// relayAdaptor returns the adaptor of the channel wrapped by the mode
+// conversions and the plugins, getChannel picks the channels of the requests
+// sent by the plugins
+func relayAdaptor(
+	meta *meta.Meta,
+	mc *model.ModelCaches,
+	getChannel func(modelName string) (*model.Channel, error),
+) (adaptor.Adaptor, bool) {
+	a, ok := adaptors.GetAdaptor(meta.Channel.Type)
+	if !ok {
+		return nil, false
+	}
+
+	switch meta.Mode {
+	case mode.ChatCompletions:
+		a = openai.NewChatTemplateAdaptor(a)
+	case mode.Completions:
+		a = openai.NewCompletionsToChatAdaptor(a)
+	case mode.Responses:
+		a = openai.NewResponsesToChatAdaptor(a)
+	}
+
+	return wrapPlugin(mc, getChannel, a), true
+}
+
 func defaultPriceFunc(_ *gin.Context, mc model.ModelConfig) (model.Price, error) {
 	return mc.Price, nil
 }
@@ -231,9 +293,38 @@ func RelayHelper(
 		return result, false
 	}
 
+	if nonRetriableAfterSend(meta) {
+		common.GetLogger(c).Data["retry_unsafe"] = true
+		return result, false
+	}
+
 	return result, monitorplugin.ShouldRetry(result.Error)
 }
 
+// nonRetriableAfterSend reports the request reached the upstream of an
+// adaptor billing it once received and was not rejected by it, e.g. it timed
+// out, a retry on another channel could bill it twice
+func nonRetriableAfterSend(meta *meta.Meta) bool {
+	if !meta.RequestSent() {
+		return false
+	}
+
+	// an error status of the upstream is not billed
+	if meta.UpstreamStatusCode != 0 &&
+		!adaptor.IsSuccessfulResponseStatus(meta.Mode, meta.UpstreamStatusCode) {
+		return false
+	}
+
+	a, ok := adaptors.GetAdaptor(meta.Channel.Type)
+	if !ok {
+		return false
+	}
+
+	retrySafety, ok := a.(adaptor.RetrySafety)
+
+	return ok && retrySafety.NonRetriableAfterSend(meta)
+}
+
 func NewRelay(mode mode.Mode) func(c *gin.Context) {
 	relayController := relayController(mode)
 	return func(c *gin.Context) {
@@ -272,8 +363,28 @@ func relay(c *gin.Context, mode mode.Mode, relayController RelayController) {
 		}
 	}
 
+	if statusCode, err := applyProviderPreferences(c, mode); err != nil {
+		middleware.AbortLogWithMessageWithMode(mode, c,
+			statusCode,
+			err.Error(),
+		)
+
+		return
+	}
+
 	// Get initial channel
 	initialChannel, err := getInitialChannel(c, requestModel, mode)
+
+	var policyErr *ChannelPolicyError
+	if errors.As(err, &policyErr) {
+		middleware.AbortLogWithMessageWithMode(mode, c,
+			http.StatusForbidden,
+			policyErr.Error(),
+		)
+
+		return
+	}
+
 	if err != nil || initialChannel == nil || initialChannel.channel == nil {
 		middleware.AbortLogWithMessageWithMode(mode, c,
 			http.StatusServiceUnavailable,
@@ -315,6 +426,24 @@ func relay(c *gin.Context, mode mode.Mode, relayController RelayController) {
 
 	meta.RequestUsageContext.ServiceTier = meta.RequestServiceTier
 
+	if err := middleware.CheckRequestTPM(c, int64(meta.RequestUsage.InputTokens)); err != nil {
+		middleware.AbortLogWithMessageWithMode(mode, c,
+			http.StatusTooManyRequests,
+			err.Error(),
+		)
+
+		return
+	}
+
+	if statusCode, err := applyMaxCost(c, mode, meta, mc, price); err != nil {
+		middleware.AbortLogWithMessageWithMode(mode, c,
+			statusCode,
+			err.Error(),
+		)
+
+		return
+	}
+
 	gbc := middleware.GetGroupBalanceConsumerFromContext(c)
 
 	requiredBalance := math.Max(
@@ -339,15 +468,29 @@ func relay(c *gin.Context, mode mode.Mode, relayController RelayController) {
 		return
 	}
 
-	// First attempt
-	result, retry := RelayHelper(c, meta, relayController.Handler)
+	// First attempt, hedged to a second channel for the latency critical tokens
+	first := relayFirstAttempt(c, mode, meta, initialChannel, relayController.Handler)
+	meta, result := first.meta, first.result
+
+	if first.loser != nil {
+		recordResult(
+			c,
+			first.loser.meta,
+			price,
+			first.loser.result,
+			0,
+			false,
+			middleware.GetRequestMetadata(c),
+			nil,
+		)
+	}
 
 	retryTimes := int(config.GetRetryTimes())
 	if mc.RetryTimes > 0 {
 		retryTimes = int(mc.RetryTimes)
 	}
 
-	if handleRelayResult(c, result.Error, retry, retryTimes) {
+	if handleRelayResult(c, result.Error, first.retry, retryTimes) {
 		recordResult(
 			c,
 			meta,
@@ -356,6 +499,7 @@ func relay(c *gin.Context, mode mode.Mode, relayController RelayController) {
 			0,
 			true,
 			middleware.GetRequestMetadata(c),
+			first.attempts,
 		)
 
 		return
@@ -370,6 +514,11 @@ func relay(c *gin.Context, mode mode.Mode, relayController RelayController) {
 		price,
 		time.Now(),
 	)
+	retryState.attempts = append(retryState.attempts, first.attempts...)
+
+	if first.loser != nil {
+		retryState.failedChannelIDs[int64(first.loser.meta.Channel.ID)] = struct{}{}
+	}
 
 	// Retry loop
 	retryLoop(c, mode, retryState, relayController.Handler)
@@ -384,6 +533,7 @@ func recordResult(
 	retryTimes int,
 	downstreamResult bool,
 	metadata map[string]string,
+	attempts []model.RequestAttempt,
 ) {
 	code := http.StatusOK
 
@@ -411,6 +561,18 @@ func recordResult(
 		)
 	}
 
+	if downstreamResult && len(attempts) > 1 {
+		if detail == nil {
+			detail = &model.RequestDetail{}
+		}
+
+		detail.Attempts = attempts
+	}
+
+	if result.SystemFingerprint != "" {
+		meta.SystemFingerprint = result.SystemFingerprint
+	}
+
 	gbc := middleware.GetGroupBalanceConsumerFromContext(c)
 	usageContext := result.UsageContext.WithFallback(meta.RequestUsageContext)
 
@@ -423,6 +585,8 @@ func recordResult(
 			DisableResolutionFuzzyMatch: meta.ModelConfig.DisableResolutionFuzzyMatch,
 		},
 	)
+
+	amount = consume.WithHedgeSurcharge(amount, meta)
 	if amount > 0 {
 		log := common.GetLogger(c)
 		log.Data["amount"] = strconv.FormatFloat(amount, 'f', -1, 64)
@@ -518,6 +682,11 @@ func buildRequestDetailForLog(
 	}
 	detail.DropInvalidUTF8Bodies()
 
+	if responseBodyMaxSize >= 0 {
+		detail.StreamTranscript = bodyDetail.StreamTranscript
+		detail.StreamTranscriptTruncated = bodyDetail.StreamTranscriptTruncated
+	}
+
 	if controller.ShouldSkipRequestBodyDetailForStatus(code) && !forceSaveDetail {
 		detail.RequestBody = ""
 	}
@@ -537,12 +706,19 @@ func buildBodyDetailOption(meta *meta.Meta) controller.BodyDetailOption {
 		config.GetLogDetailResponseBodyMaxSize(),
 	)
 
-	return controller.BodyDetailOption{
+	opt := controller.BodyDetailOption{
 		IncludeRequestBody:  requestBodyMaxSize >= 0,
 		IncludeResponseBody: responseBodyMaxSize >= 0,
 		MaxRequestBodySize:  requestBodyMaxSize,
 		MaxResponseBodySize: responseBodyMaxSize,
 	}
+
+	// the transcripts are not captured when the response bodies are not stored
+	if responseBodyMaxSize >= 0 {
+		opt.MaxStreamTranscriptSize = config.GetLogDetailStreamTranscriptMaxSize()
+	}
+
+	return opt
 }
 
 type retryState struct {
@@ -560,6 +736,7 @@ type retryState struct {
 	result              *controller.HandleResult
 	migratedChannels    []*model.Channel
 	channelRetryInfo    map[int]channelRetryInfo
+	attempts            []model.RequestAttempt
 }
 
 type channelRetryInfo struct {
@@ -567,6 +744,27 @@ type channelRetryInfo struct {
 	lastEndAt time.Time
 }
 
+// AIProxyAttemptsHeader is the number of upstream attempts of the request, it is
+// only set when the request is retried
+const AIProxyAttemptsHeader = "X-Aiproxy-Attempts"
+
+func newRequestAttempt(
+	channelID int,
+	result *controller.HandleResult,
+	startAt, endAt time.Time,
+) model.RequestAttempt {
+	statusCode := http.StatusOK
+	if result.Error != nil {
+		statusCode = result.Error.StatusCode()
+	}
+
+	return model.RequestAttempt{
+		ChannelID:           channelID,
+		StatusCode:          statusCode,
+		LatencyMilliseconds: endAt.Sub(startAt).Milliseconds(),
+	}
+}
+
 const (
 	relayRetryBaseDelay = time.Second
 	relayRetryMaxDelay  = 5 * time.Second
@@ -617,7 +815,7 @@ func initRetryState(
 
 	// Record initial failed channel
 	state.failedChannelIDs[int64(meta.Channel.ID)] = struct{}{}
-	if shouldBackoffStatus(result.Error.StatusCode()) {
+	if shouldBackoff(result) {
 		state.recordChannelFailure(meta.Channel.ID, initialEndAt)
 	}
 
@@ -625,7 +823,7 @@ func initRetryState(
 		state.exhausted = true
 	}
 
-	if !monitorplugin.ChannelHasPermission(result.Error) {
+	if result.StreamsSaturated || !monitorplugin.ChannelHasPermission(result.Error) {
 		if state.ignoreChannelIDs == nil {
 			state.ignoreChannelIDs = make(map[int64]struct{})
 		}
@@ -716,6 +914,7 @@ func retryLoop(c *gin.Context, mode mode.Mode, state *retryState, relayControlle
 					i,
 					true,
 					middleware.GetRequestMetadata(c),
+					state.attempts,
 				)
 			}
 
@@ -731,6 +930,7 @@ func retryLoop(c *gin.Context, mode mode.Mode, state *retryState, relayControlle
 				i,
 				false,
 				middleware.GetRequestMetadata(c),
+				nil,
 			)
 			state.meta = nil
 			state.result = nil
@@ -756,10 +956,17 @@ func retryLoop(c *gin.Context, mode mode.Mode, state *retryState, relayControlle
 			meta.WithRetryAt(time.Now()),
 		)
 
+		c.Header(AIProxyAttemptsHeader, strconv.Itoa(len(state.attempts)+1))
+
 		var retry bool
 
+		attemptStartAt := time.Now()
 		state.result, retry = RelayHelper(c, state.meta, relayController)
-		if state.result.Error != nil && shouldBackoffStatus(state.result.Error.StatusCode()) {
+		state.attempts = append(
+			state.attempts,
+			newRequestAttempt(newChannel.ID, state.result, attemptStartAt, time.Now()),
+		)
+		if shouldBackoff(state.result) {
 			state.recordChannelFailure(newChannel.ID, time.Now())
 		}
 
@@ -779,6 +986,7 @@ func retryLoop(c *gin.Context, mode mode.Mode, state *retryState, relayControlle
 				i+1,
 				true,
 				middleware.GetRequestMetadata(c),
+				state.attempts,
 			)
 
 			break
@@ -817,6 +1025,18 @@ func handleRetryResult(
 		return true
 	}
 
+	// the request was not sent to the saturated channel, it is not picked
+	// again and is not the fallback of the exhausted retries
+	if state.result.StreamsSaturated {
+		if state.ignoreChannelIDs == nil {
+			state.ignoreChannelIDs = make(map[int64]struct{})
+		}
+
+		state.ignoreChannelIDs[int64(newChannel.ID)] = struct{}{}
+
+		return false
+	}
+
 	hasPermission := monitorplugin.ChannelHasPermission(state.result.Error)
 
 	if state.exhausted {
@@ -867,6 +1087,14 @@ func handleRetryResult(
 	return false
 }
 
+// shouldBackoff reports the failed channel is delayed before it is retried,
+// a saturated channel is ignored instead
+func shouldBackoff(result *controller.HandleResult) bool {
+	return result.Error != nil &&
+		!result.StreamsSaturated &&
+		shouldBackoffStatus(result.Error.StatusCode())
+}
+
 func shouldBackoffStatus(statusCode int) bool {
 	return statusCode == http.StatusTooManyRequests ||
 		statusCode == http.StatusServiceUnavailable
@@ -3,15 +3,19 @@ package controller
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/glebarez/sqlite"
 	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/monitor"
 	relaycontroller "github.com/labring/aiproxy/core/relay/controller"
 	"github.com/labring/aiproxy/core/relay/meta"
 	"github.com/labring/aiproxy/core/relay/mode"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
@@ -292,3 +296,119 @@ func TestBuildRequestDetailForLogDropsInvalidUTF8Bodies(t *testing.T) {
 	assert.Empty(t, detail.RequestBody)
 	assert.Empty(t, detail.ResponseBody)
 }
+
+func TestNewRequestAttempt(t *testing.T) {
+	t.Parallel()
+
+	startAt := time.Unix(100, 0)
+
+	attempt := newRequestAttempt(
+		3,
+		&relaycontroller.HandleResult{
+			Error: relaymodel.WrapperOpenAIErrorWithMessage(
+				"overloaded",
+				"upstream_overloaded",
+				http.StatusServiceUnavailable,
+			),
+		},
+		startAt,
+		startAt.Add(1500*time.Millisecond),
+	)
+	assert.Equal(t, model.RequestAttempt{
+		ChannelID:           3,
+		StatusCode:          http.StatusServiceUnavailable,
+		LatencyMilliseconds: 1500,
+	}, attempt)
+
+	attempt = newRequestAttempt(
+		4,
+		&relaycontroller.HandleResult{},
+		startAt,
+		startAt.Add(200*time.Millisecond),
+	)
+	assert.Equal(t, http.StatusOK, attempt.StatusCode)
+	assert.Equal(t, int64(200), attempt.LatencyMilliseconds)
+}
+
+func TestNonRetriableAfterSend(t *testing.T) {
+	t.Parallel()
+
+	channel := &model.Channel{ID: 1, Type: model.ChannelTypeOpenAI}
+
+	newMeta := func(m mode.Mode, sent bool, statusCode int) *meta.Meta {
+		relayMeta := meta.NewMeta(channel, m, "gpt-image-1", model.ModelConfig{})
+		if sent {
+			relayMeta.MarkRequestSent()
+		}
+
+		relayMeta.UpstreamStatusCode = statusCode
+
+		return relayMeta
+	}
+
+	// not sent, e.g. the connection was refused
+	assert.False(t, nonRetriableAfterSend(newMeta(mode.ImagesGenerations, false, 0)))
+	// sent and timed out
+	assert.True(t, nonRetriableAfterSend(newMeta(mode.ImagesGenerations, true, 0)))
+	// the body of a successful response was lost
+	assert.True(t, nonRetriableAfterSend(newMeta(mode.ImagesGenerations, true, http.StatusOK)))
+	// rejected by the upstream
+	assert.False(t, nonRetriableAfterSend(
+		newMeta(mode.ImagesGenerations, true, http.StatusTooManyRequests),
+	))
+	// the chat completions are not billed on receipt
+	assert.False(t, nonRetriableAfterSend(newMeta(mode.ChatCompletions, true, 0)))
+}
+
+func TestSaturatedChannelIsIgnoredWithoutBackoff(t *testing.T) {
+	t.Parallel()
+
+	const channelID = 923001
+
+	release, ok := monitor.TryAcquireChannelStream(channelID, 1)
+	require.True(t, ok)
+
+	saturated := &model.Channel{
+		ID:                   channelID,
+		Status:               model.ChannelStatusEnabled,
+		MaxConcurrentStreams: 1,
+	}
+	idle := &model.Channel{
+		ID:                   channelID + 1,
+		Status:               model.ChannelStatusEnabled,
+		MaxConcurrentStreams: 1,
+	}
+
+	assert.Equal(t, []*model.Channel{idle}, filterChannels(
+		[]*model.Channel{saturated, idle},
+		nil,
+		0,
+	))
+
+	release()
+
+	assert.Equal(t, []*model.Channel{saturated, idle}, filterChannels(
+		[]*model.Channel{saturated, idle},
+		nil,
+		0,
+	))
+
+	result := &relaycontroller.HandleResult{
+		Error: relaymodel.WrapperOpenAIErrorWithMessage(
+			"saturated",
+			"channel_streams_saturated",
+			http.StatusTooManyRequests,
+		),
+		StreamsSaturated: true,
+	}
+	assert.False(t, shouldBackoff(result))
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	state := &retryState{result: result}
+	assert.False(t, handleRetryResult(c, true, saturated, state))
+	assert.Contains(t, state.ignoreChannelIDs, int64(channelID))
+	assert.Nil(t, state.lastMinErrorRateHasPermissionChannel)
+	assert.Empty(t, state.channelRetryInfo)
+}
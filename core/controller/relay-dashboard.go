@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/labring/aiproxy/core/common/balance"
@@ -117,3 +118,45 @@ func GetUsage(c *gin.Context) {
 		},
 	)
 }
+
+// maxSelfServeUsageSpan limits the range of the usage time series queried by
+// the tokens
+const maxSelfServeUsageSpan = 90 * 24 * time.Hour
+
+// GetTokenUsageTimeSeries godoc
+//
+//	@Summary		Get usage time series
+//	@Description	Returns the requests, tokens, cost and error rate of the token, or of its group with scope=group, bucketed by hour or day
+//	@Tags			relay
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			scope			query		string	false	"Scope (token, group), default is token"
+//	@Param			start_timestamp	query		int64	false	"Start timestamp"
+//	@Param			end_timestamp	query		int64	false	"End timestamp"
+//	@Param			timezone		query		string	false	"Timezone, default is Local"
+//	@Param			bucket			query		string	false	"Bucket (hour, day), default is hour"
+//	@Success		200				{object}	model.UsageTimeSeries
+//	@Router			/v1/dashboard/usage/timeseries [get]
+func GetTokenUsageTimeSeries(c *gin.Context) {
+	group := middleware.GetGroup(c)
+	token := middleware.GetToken(c)
+
+	var tokenName string
+
+	switch c.DefaultQuery("scope", "token") {
+	case "token":
+		tokenName = token.Name
+	case "group":
+	default:
+		middleware.ErrorResponse(c, http.StatusBadRequest, "scope must be token or group")
+		return
+	}
+
+	series, err := getUsageTimeSeries(c, group.ID, tokenName, maxSelfServeUsageSpan)
+	if err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
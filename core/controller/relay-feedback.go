@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/monitor"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type FeedbackRequest struct {
+	RequestID string `json:"request_id" binding:"required"`
+	// Rating of the response, from 0 for a bad response to 1 for a good one
+	Rating *float64 `json:"rating"     binding:"required"`
+}
+
+// Feedback godoc
+//
+//	@Summary		Rate a response
+//	@Description	Rates a response of the group, the ratings are the quality signal of the models enabling the channel bandit
+//	@Tags			relay
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			request	body	FeedbackRequest	true	"Feedback"
+//	@Success		204
+//	@Router			/v1/feedback [post]
+func Feedback(c *gin.Context) {
+	var req FeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if *req.Rating < 0 || *req.Rating > 1 {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "rating must be between 0 and 1")
+		return
+	}
+
+	group := middleware.GetGroup(c)
+
+	modelName, channelID, err := model.GetLogChannelByRequestID(group.ID, req.RequestID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			middleware.ErrorResponse(c, http.StatusNotFound, "request not found")
+			return
+		}
+
+		log.Errorf("get log of request (%s) failed: %s", req.RequestID, err)
+		middleware.ErrorResponse(c, http.StatusInternalServerError, "get request failed")
+
+		return
+	}
+
+	if channelID == 0 {
+		middleware.ErrorResponse(c, http.StatusBadRequest, "request was not served by a channel")
+		return
+	}
+
+	monitor.RecordBanditFeedback(modelName, int64(channelID), *req.Rating)
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
+	log "github.com/sirupsen/logrus"
+)
+
+// ListFiles godoc
+//
+//	@Summary		List files
+//	@Description	List the files uploaded by the token, the files are listed from the proxy so the files of the other tenants sharing a channel are never exposed
+//	@Tags			relay
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			purpose	query		string	false	"Only return the files with the purpose"
+//	@Success		200		{object}	openai.FileListResponse
+//	@Router			/v1/files [get]
+func ListFiles(c *gin.Context) {
+	group := middleware.GetGroup(c)
+	token := middleware.GetToken(c)
+
+	files, err := model.GetOpenAIFiles(group.ID, token.ID)
+	if err != nil {
+		log.Errorf("get group (%s) files failed: %s", group.ID, err)
+		middleware.ErrorResponse(c, http.StatusInternalServerError, "get files failed")
+
+		return
+	}
+
+	purpose := c.Query("purpose")
+
+	data := make([]openai.FileObject, 0, len(files))
+	for _, file := range files {
+		if purpose != "" && file.Purpose != purpose {
+			continue
+		}
+
+		data = append(data, toFileObject(file))
+	}
+
+	c.JSON(http.StatusOK, openai.FileListResponse{
+		Object: "list",
+		Data:   data,
+	})
+}
+
+func toFileObject(file model.OpenAIFile) openai.FileObject {
+	return openai.FileObject{
+		ID:        file.ID,
+		Object:    "file",
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+	}
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/consume"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor/gemini"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+// geminiLiveBridge upgrades the connection and reads the setup message as the
+// request body, the session itself is relayed by the gemini adaptor, the
+// errors before the session starts are sent as one message before closing
+func geminiLiveBridge(c *gin.Context) {
+	conn, err := webSocketUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader has replied the handshake error
+		c.Abort()
+		return
+	}
+
+	conn.SetReadLimit(common.MaxRequestBodySize)
+	_ = conn.SetReadDeadline(time.Now().Add(webSocketRequestTimeout))
+
+	_, setup, err := conn.ReadMessage()
+	if err != nil {
+		common.GetLogger(c).Debugf("read gemini live setup failed: %v", err)
+		closeWebSocket(conn, websocket.CloseUnsupportedData, "invalid setup")
+		c.Abort()
+
+		return
+	}
+
+	_ = conn.SetReadDeadline(time.Time{})
+
+	c.Request.Method = http.MethodPost
+	c.Request.Header.Set("Content-Type", "application/json")
+	common.SetRequestBody(c.Request, setup)
+	gemini.SetLiveClientConn(c, conn)
+
+	w := &webSocketResponseWriter{
+		ResponseWriter: c.Writer,
+		conn:           conn,
+		header:         make(http.Header),
+		status:         http.StatusOK,
+		size:           -1,
+	}
+	c.Writer = w
+
+	c.Next()
+
+	if w.status < http.StatusBadRequest {
+		// the adaptor has forwarded the close frame of the upstream
+		_ = conn.Close()
+		return
+	}
+
+	w.finish()
+
+	if w.err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	if w.status >= http.StatusInternalServerError {
+		closeWebSocket(conn, websocket.CloseInternalServerErr, http.StatusText(w.status))
+		return
+	}
+
+	closeWebSocket(conn, websocket.ClosePolicyViolation, http.StatusText(w.status))
+}
+
+// geminiLiveBalanceCheck lets the live session check the balance of the group
+// against the usage accumulated by the session, the balance was read when the
+// session started
+func geminiLiveBalanceCheck(c *gin.Context) {
+	gbc := middleware.GetGroupBalanceConsumerFromContext(c)
+	if gbc == nil {
+		return
+	}
+
+	mc := middleware.GetModelConfig(c)
+	options := model.PriceSelectionOptions{
+		DisableResolutionFuzzyMatch: mc.DisableResolutionFuzzyMatch,
+	}
+
+	gemini.SetLiveBalanceCheck(c, func(usage model.Usage) bool {
+		amount := consume.CalculateAmountWithOptions(
+			http.StatusOK,
+			usage,
+			model.UsageContext{},
+			mc.Price,
+			options,
+		)
+
+		return gbc.CheckBalance(amount)
+	})
+}
+
+// GeminiLive godoc
+//
+//	@Summary		Gemini Live API
+//	@Description	Relays the Gemini Live (BidiGenerateContent) websocket session, the first message must be the setup message, the model of the setup is rewritten to the model of the channel and the usage metadata sent during the session is billed, the session is closed once it exceeds LiveMaxSessionSeconds or the usage exceeds the group balance
+//	@Tags			relay
+//	@Security		ApiKeyAuth
+//	@Param			version	path	string	true	"API Version (v1alpha or v1beta)"
+//	@Router			/ws/google.ai.generativelanguage.{version}.GenerativeService.BidiGenerateContent [get]
+func GeminiLive() []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		geminiLiveBridge,
+		middleware.NewDistribute(mode.GeminiLive),
+		geminiLiveBalanceCheck,
+		NewRelay(mode.GeminiLive),
+	}
+}
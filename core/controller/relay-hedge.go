@@ -0,0 +1,444 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/monitor"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/controller"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+)
+
+// firstAttempt is the outcome of the first attempt of a request, a hedged
+// request runs two attempts and the winner is the outcome
+type firstAttempt struct {
+	meta     *meta.Meta
+	result   *controller.HandleResult
+	retry    bool
+	attempts []model.RequestAttempt
+	// loser is the other attempt of a hedged request, it is recorded without
+	// being billed
+	loser *hedgeAttempt
+}
+
+func relayFirstAttempt(
+	c *gin.Context,
+	m mode.Mode,
+	meta *meta.Meta,
+	initial *initialChannel,
+	handler RelayHandler,
+) *firstAttempt {
+	if delay := hedgeDelay(c, meta, initial); delay > 0 {
+		return hedgeRelay(c, m, meta, initial, handler, delay)
+	}
+
+	startAt := time.Now()
+	result, retry := RelayHelper(c, meta, handler)
+
+	return &firstAttempt{
+		meta:   meta,
+		result: result,
+		retry:  retry,
+		attempts: []model.RequestAttempt{
+			newRequestAttempt(meta.Channel.ID, result, startAt, time.Now()),
+		},
+	}
+}
+
+func isHedgeMode(m mode.Mode) bool {
+	switch m {
+	case mode.ChatCompletions,
+		mode.Completions,
+		mode.Anthropic,
+		mode.Gemini,
+		mode.Responses:
+		return true
+	default:
+		return false
+	}
+}
+
+// hedgeDelay returns how long the first attempt may run without writing the
+// first byte before the request is hedged, zero disables the hedging
+func hedgeDelay(c *gin.Context, meta *meta.Meta, initial *initialChannel) time.Duration {
+	if meta.Token.HedgeAfterMs <= 0 ||
+		initial.designatedChannel ||
+		!isHedgeMode(meta.Mode) {
+		return 0
+	}
+
+	// the body is sent twice, so it must be reusable
+	body, err := common.GetRequestBodyReusable(c.Request)
+	if err != nil || body == nil {
+		return 0
+	}
+
+	return time.Duration(meta.Token.HedgeAfterMs) * time.Millisecond
+}
+
+func getHedgeChannel(
+	ctx context.Context,
+	meta *meta.Meta,
+	initial *initialChannel,
+) (*model.Channel, error) {
+	errorRates, err := monitor.GetModelChannelErrorRate(ctx, meta.OriginModel)
+	if err != nil {
+		return nil, err
+	}
+
+	filteredChannels := filterChannels(
+		initial.migratedChannels,
+		errorRates,
+		maxRetryErrorRate,
+		initial.ignoreChannelIDs,
+		map[int64]struct{}{int64(meta.Channel.ID): {}},
+	)
+
+	if channel := pickPreferredChannel(filteredChannels, initial.preferChannelIDs); channel != nil {
+		return channel, nil
+	}
+
+	return pickChannel(filteredChannels, errorRates)
+}
+
+// hedgeRace is won by the first attempt writing to the client
+type hedgeRace struct {
+	mu      sync.Mutex
+	target  gin.ResponseWriter
+	winner  *hedgeWriter
+	claimed chan struct{}
+}
+
+func newHedgeRace(target gin.ResponseWriter) *hedgeRace {
+	return &hedgeRace{
+		target:  target,
+		claimed: make(chan struct{}),
+	}
+}
+
+func (r *hedgeRace) claim(w *hedgeWriter) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.winner == nil {
+		r.winner = w
+		close(r.claimed)
+	}
+
+	return r.winner == w
+}
+
+func (r *hedgeRace) getWinner() *hedgeWriter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.winner
+}
+
+// hedgeWriter buffers the header of an attempt until it writes the body, the
+// first attempt writing wins the race and writes to the client, the writes of
+// the loser fail
+type hedgeWriter struct {
+	gin.ResponseWriter
+	race   *hedgeRace
+	header http.Header
+	status int
+	won    bool
+}
+
+func newHedgeWriter(race *hedgeRace) *hedgeWriter {
+	return &hedgeWriter{
+		ResponseWriter: race.target,
+		race:           race,
+		header:         make(http.Header),
+	}
+}
+
+func (w *hedgeWriter) claim() bool {
+	if w.won {
+		return true
+	}
+
+	if !w.race.claim(w) {
+		return false
+	}
+
+	w.won = true
+
+	maps.Copy(w.ResponseWriter.Header(), w.header)
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+
+	return true
+}
+
+func (w *hedgeWriter) Header() http.Header {
+	if w.won {
+		return w.ResponseWriter.Header()
+	}
+
+	return w.header
+}
+
+func (w *hedgeWriter) WriteHeader(code int) {
+	if w.won {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	w.status = code
+}
+
+func (w *hedgeWriter) WriteHeaderNow() {
+	if w.claim() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *hedgeWriter) Write(b []byte) (int, error) {
+	if !w.claim() {
+		return 0, adaptor.ErrHedgeLost
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *hedgeWriter) WriteString(s string) (int, error) {
+	if !w.claim() {
+		return 0, adaptor.ErrHedgeLost
+	}
+
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *hedgeWriter) Status() int {
+	if w.won {
+		return w.ResponseWriter.Status()
+	}
+
+	if w.status != 0 {
+		return w.status
+	}
+
+	return http.StatusOK
+}
+
+func (w *hedgeWriter) Size() int {
+	if w.won {
+		return w.ResponseWriter.Size()
+	}
+
+	return -1
+}
+
+func (w *hedgeWriter) Written() bool {
+	return w.won && w.ResponseWriter.Written()
+}
+
+func (w *hedgeWriter) Flush() {
+	if w.won {
+		w.ResponseWriter.Flush()
+	}
+}
+
+type hedgeAttempt struct {
+	ctx     *gin.Context
+	meta    *meta.Meta
+	writer  *hedgeWriter
+	cancel  context.CancelCauseFunc
+	done    chan struct{}
+	startAt time.Time
+	endAt   time.Time
+	result  *controller.HandleResult
+	retry   bool
+}
+
+// startHedgeAttempt runs the attempt on a copy of the context with its own
+// request, logger and writer, so that the two attempts do not share state
+func startHedgeAttempt(
+	c *gin.Context,
+	race *hedgeRace,
+	meta *meta.Meta,
+	handler RelayHandler,
+) *hedgeAttempt {
+	ctx, cancel := context.WithCancelCause(c.Request.Context())
+
+	req := c.Request.Clone(ctx)
+	body, _ := common.GetRequestBodyReusable(c.Request)
+	common.SetRequestBody(req, body)
+
+	log := common.NewLogger()
+	maps.Copy(log.Data, common.GetLogger(c).Data)
+	common.SetLogger(req, log)
+
+	attempt := &hedgeAttempt{
+		ctx:     c.Copy(),
+		meta:    meta,
+		writer:  newHedgeWriter(race),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		startAt: time.Now(),
+	}
+	attempt.ctx.Request = req
+	attempt.ctx.Writer = attempt.writer
+
+	go func() {
+		defer close(attempt.done)
+		defer func() {
+			if r := recover(); r != nil {
+				log.Errorf("panic in hedged request: %v", r)
+
+				attempt.result = &controller.HandleResult{
+					Error: relaymodel.WrapperErrorWithMessage(
+						meta.Mode,
+						http.StatusInternalServerError,
+						fmt.Sprintf("hedged request panic: %v", r),
+					),
+				}
+			}
+
+			attempt.endAt = time.Now()
+		}()
+
+		attempt.result, attempt.retry = RelayHelper(attempt.ctx, meta, handler)
+		if attempt.result.Error == nil {
+			attempt.writer.claim()
+		}
+	}()
+
+	return attempt
+}
+
+func (a *hedgeAttempt) requestAttempt() model.RequestAttempt {
+	return newRequestAttempt(a.meta.Channel.ID, a.result, a.startAt, a.endAt)
+}
+
+// hedgeRelay runs the first attempt of a latency critical request, when it has
+// not written the first byte within the delay the request is also sent to a
+// second channel, the attempt writing first is kept and the other is canceled
+func hedgeRelay(
+	c *gin.Context,
+	m mode.Mode,
+	first *meta.Meta,
+	initial *initialChannel,
+	handler RelayHandler,
+	delay time.Duration,
+) *firstAttempt {
+	log := common.GetLogger(c)
+
+	race := newHedgeRace(c.Writer)
+	primary := startHedgeAttempt(c, race, first, handler)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var secondary *hedgeAttempt
+
+	select {
+	case <-race.claimed:
+	case <-primary.done:
+	case <-timer.C:
+		channel, err := getHedgeChannel(c.Request.Context(), first, initial)
+		if err != nil {
+			break
+		}
+
+		log.Data["hedge_channel"] = strconv.Itoa(channel.ID)
+		log.Warnf("channel %d has no first byte after %s, hedging with channel %s (type: %d, id: %d)",
+			first.Channel.ID,
+			delay,
+			channel.Name,
+			channel.Type,
+			channel.ID,
+		)
+
+		secondary = startHedgeAttempt(c, race, NewMetaByContext(
+			c,
+			channel,
+			m,
+			meta.WithRequestUsage(first.RequestUsage),
+			meta.WithRequestUsageContext(first.RequestUsageContext),
+			meta.WithRetryAt(time.Now()),
+		), handler)
+	}
+
+	attempts := []*hedgeAttempt{primary}
+	if secondary != nil {
+		attempts = append(attempts, secondary)
+	}
+
+	waitHedgeAttempts(race, attempts)
+
+	winner := race.getWinner()
+	for _, attempt := range attempts {
+		if attempt.writer != winner {
+			attempt.cancel(adaptor.ErrHedgeLost)
+		}
+	}
+
+	for _, attempt := range attempts {
+		<-attempt.done
+		attempt.cancel(nil)
+	}
+
+	kept, loser := primary, secondary
+	if secondary != nil && secondary.writer == winner {
+		kept, loser = secondary, primary
+	}
+
+	// the log fields and the keys set by the kept attempt belong to the request
+	maps.Copy(log.Data, common.GetLogger(kept.ctx).Data)
+
+	for k, v := range kept.ctx.Keys {
+		c.Set(k, v)
+	}
+
+	outcome := &firstAttempt{
+		meta:   kept.meta,
+		result: kept.result,
+		retry:  kept.retry,
+	}
+
+	if loser == nil {
+		outcome.attempts = []model.RequestAttempt{kept.requestAttempt()}
+		return outcome
+	}
+
+	outcome.loser = loser
+	outcome.attempts = []model.RequestAttempt{
+		primary.requestAttempt(),
+		secondary.requestAttempt(),
+	}
+
+	if winner != nil {
+		kept.meta.HedgeSurchargeRatio = config.GetHedgeSurchargeRatio()
+	}
+
+	return outcome
+}
+
+// waitHedgeAttempts waits until an attempt won the race or all attempts are
+// done
+func waitHedgeAttempts(race *hedgeRace, attempts []*hedgeAttempt) {
+	for _, attempt := range attempts {
+		select {
+		case <-race.claimed:
+			return
+		case <-attempt.done:
+		}
+	}
+}
@@ -0,0 +1,50 @@
+//nolint:testpackage
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedgeWriterFirstWriteWins(t *testing.T) {
+	t.Parallel()
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+
+	race := newHedgeRace(c.Writer)
+	primary := newHedgeWriter(race)
+	secondary := newHedgeWriter(race)
+
+	primary.Header().Set("X-Attempt", "primary")
+	primary.WriteHeader(http.StatusAccepted)
+	secondary.Header().Set("X-Attempt", "secondary")
+	secondary.WriteHeader(http.StatusOK)
+
+	assert.Empty(t, recorder.Header().Get("X-Attempt"))
+	assert.False(t, secondary.Written())
+
+	_, err := secondary.Write([]byte("secondary"))
+	require.NoError(t, err)
+
+	_, err = primary.Write([]byte("primary"))
+	require.ErrorIs(t, err, adaptor.ErrHedgeLost)
+
+	assert.Same(t, secondary, race.getWinner())
+
+	select {
+	case <-race.claimed:
+	default:
+		t.Fatal("race is not claimed")
+	}
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "secondary", recorder.Header().Get("X-Attempt"))
+	assert.Equal(t, "secondary", recorder.Body.String())
+}
@@ -0,0 +1,327 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/consume"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+const (
+	// XAiproxyMaxCost is the highest amount the request may cost, estimated
+	// from the prompt tokens and the max output tokens
+	XAiproxyMaxCost = "X-Aiproxy-Max-Cost"
+	// XAiproxyMaxCostAction is the action when the max output tokens of the
+	// request may exceed the max cost, clamp by default or reject
+	XAiproxyMaxCostAction = "X-Aiproxy-Max-Cost-Action"
+	// XAiproxyMaxTokensClamped is set to the max output tokens the request is
+	// clamped to under the max cost
+	XAiproxyMaxTokensClamped = "X-Aiproxy-Max-Tokens-Clamped"
+
+	// maxCostBodyField is the body extension of the max cost, it is removed
+	// before the request is sent upstream
+	maxCostBodyField = "aiproxy_max_cost"
+
+	MaxCostActionClamp  = "clamp"
+	MaxCostActionReject = "reject"
+
+	// maxCostOutputTokensUpperBound bounds the output tokens of the requests
+	// without max output tokens on the models without a max output
+	maxCostOutputTokensUpperBound = 1 << 20
+
+	// minThinkingBudgetTokens is the smallest thinking budget of anthropic,
+	// the anthropic adaptor raises the smaller budgets to it
+	minThinkingBudgetTokens = 1024
+)
+
+var errMaxCostExceeded = errors.New("max cost exceeded")
+
+// maxTokensPaths returns the paths of the max output tokens of the mode, the
+// first existing path is used
+func maxTokensPaths(m mode.Mode) [][]any {
+	switch m {
+	case mode.ChatCompletions:
+		return [][]any{{"max_completion_tokens"}, {"max_tokens"}}
+	case mode.Completions, mode.Anthropic:
+		return [][]any{{"max_tokens"}}
+	case mode.Responses:
+		return [][]any{{"max_output_tokens"}}
+	case mode.Gemini:
+		return [][]any{{"generationConfig", "maxOutputTokens"}}
+	default:
+		return nil
+	}
+}
+
+// getRequestMaxTokens returns the max output tokens of the request and its
+// path, the path to set is returned with zero tokens without one
+func getRequestMaxTokens(node *ast.Node, paths [][]any) (int64, []any) {
+	for _, path := range paths {
+		if v, err := node.GetByPath(path...).Int64(); err == nil && v > 0 {
+			return v, path
+		}
+	}
+
+	return 0, paths[len(paths)-1]
+}
+
+func setRequestMaxTokens(node *ast.Node, path []any, tokens int64) error {
+	parent := node
+
+	for _, p := range path[:len(path)-1] {
+		key, _ := p.(string)
+
+		child := parent.Get(key)
+		if child == nil || !child.Exists() || child.TypeSafe() != ast.V_OBJECT {
+			if _, err := parent.Set(key, ast.NewObject(nil)); err != nil {
+				return err
+			}
+
+			child = parent.Get(key)
+		}
+
+		parent = child
+	}
+
+	key, _ := path[len(path)-1].(string)
+	_, err := parent.Set(key, ast.NewNumber(strconv.FormatInt(tokens, 10)))
+
+	return err
+}
+
+// maxAffordableOutputTokens returns the most output tokens up to the upper
+// bound whose cost is within the max cost, the cost grows with the tokens
+func maxAffordableOutputTokens(cost func(outputTokens int64) float64, maxCost float64, upper int64) int64 {
+	lo, hi := int64(0), upper
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+		if cost(mid) <= maxCost {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	return lo
+}
+
+// clampThinkingBudget keeps the anthropic thinking budget below the clamped max
+// tokens, the anthropic adaptor raises the max tokens over the budget
+// otherwise and the max cost would not hold, the request is rejected when the
+// max tokens can not fit the min thinking budget
+func clampThinkingBudget(node *ast.Node, maxTokens int64, maxCost float64) error {
+	thinking := node.Get("thinking")
+	if thinking == nil || !thinking.Exists() || thinking.TypeSafe() != ast.V_OBJECT {
+		return nil
+	}
+
+	if typ, _ := thinking.Get("type").String(); typ == "disabled" {
+		return nil
+	}
+
+	budget, err := thinking.Get("budget_tokens").Int64()
+	if err != nil {
+		return nil
+	}
+
+	if maxTokens <= minThinkingBudgetTokens {
+		return fmt.Errorf(
+			"%w: the %d output tokens affordable within the max cost of %s are not above the min thinking budget of %d",
+			errMaxCostExceeded,
+			maxTokens,
+			strconv.FormatFloat(maxCost, 'f', -1, 64),
+			minThinkingBudgetTokens,
+		)
+	}
+
+	if budget < maxTokens {
+		return nil
+	}
+
+	_, err = thinking.Set("budget_tokens", ast.NewNumber(strconv.FormatInt(maxTokens-1, 10)))
+
+	return err
+}
+
+// getRequestMaxCost returns the max cost of the request from the header, or
+// the body extension removed from the body
+func getRequestMaxCost(c *gin.Context, node *ast.Node) (float64, bool, bool, error) {
+	bodyChanged := false
+
+	var value string
+
+	if field := node.Get(maxCostBodyField); field != nil && field.Exists() {
+		if raw, err := field.Raw(); err == nil {
+			value = strings.Trim(raw, `"`)
+		}
+
+		if _, err := node.Unset(maxCostBodyField); err != nil {
+			return 0, false, false, err
+		}
+
+		bodyChanged = true
+	}
+
+	if header := c.GetHeader(XAiproxyMaxCost); header != "" {
+		value = header
+	}
+
+	if value == "" || value == "null" {
+		return 0, false, bodyChanged, nil
+	}
+
+	maxCost, err := strconv.ParseFloat(value, 64)
+	if err != nil || maxCost <= 0 {
+		return 0, false, bodyChanged, fmt.Errorf("invalid max cost: %s", value)
+	}
+
+	return maxCost, true, bodyChanged, nil
+}
+
+// applyMaxCost estimates the cost of the request from the prompt tokens and
+// the max output tokens, the request is rejected when the prompt alone exceeds
+// the max cost, the max output tokens are clamped to the max cost otherwise,
+// or the request is rejected when the action is reject
+func applyMaxCost(
+	c *gin.Context,
+	m mode.Mode,
+	meta *meta.Meta,
+	mc model.ModelConfig,
+	price model.Price,
+) (int, error) {
+	paths := maxTokensPaths(m)
+	if len(paths) == 0 {
+		return http.StatusOK, nil
+	}
+
+	node, err := common.UnmarshalRequest2NodeReusable(c.Request)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	maxCost, ok, bodyChanged, err := getRequestMaxCost(c, &node)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if ok {
+		clamped, err := clampMaxTokens(c, &node, paths, meta, mc, price, maxCost)
+		if err != nil {
+			if errors.Is(err, errMaxCostExceeded) {
+				return http.StatusBadRequest, err
+			}
+
+			return http.StatusInternalServerError, err
+		}
+
+		bodyChanged = bodyChanged || clamped
+	}
+
+	if !bodyChanged {
+		return http.StatusOK, nil
+	}
+
+	body, err := node.MarshalJSON()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	common.SetRequestBody(c.Request, body)
+
+	return http.StatusOK, nil
+}
+
+func clampMaxTokens(
+	c *gin.Context,
+	node *ast.Node,
+	paths [][]any,
+	meta *meta.Meta,
+	mc model.ModelConfig,
+	price model.Price,
+	maxCost float64,
+) (bool, error) {
+	log := common.GetLogger(c)
+	log.Data["max_cost"] = maxCost
+
+	cost := func(outputTokens int64) float64 {
+		usage := meta.RequestUsage
+		usage.OutputTokens = model.ZeroNullInt64(outputTokens)
+		usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+
+		return consume.CalculateAmountWithOptions(
+			http.StatusOK,
+			usage,
+			meta.RequestUsageContext,
+			price,
+			model.PriceSelectionOptions{
+				DisableResolutionFuzzyMatch: mc.DisableResolutionFuzzyMatch,
+			},
+		)
+	}
+
+	if promptCost := cost(0); promptCost > maxCost {
+		return false, fmt.Errorf(
+			"%w: the prompt is estimated to cost %s, over the max cost of %s",
+			errMaxCostExceeded,
+			strconv.FormatFloat(promptCost, 'f', -1, 64),
+			strconv.FormatFloat(maxCost, 'f', -1, 64),
+		)
+	}
+
+	maxTokens, path := getRequestMaxTokens(node, paths)
+	if maxTokens > 0 && cost(maxTokens) <= maxCost {
+		return false, nil
+	}
+
+	upper := maxTokens
+	if upper <= 0 {
+		upper = maxCostOutputTokensUpperBound
+		if maxOutput, ok := mc.MaxOutputTokens(); ok && maxOutput > 0 {
+			upper = int64(maxOutput)
+		}
+
+		// the output is free or cheap enough to never exceed the max cost
+		if cost(upper) <= maxCost {
+			return false, nil
+		}
+	}
+
+	if strings.EqualFold(c.GetHeader(XAiproxyMaxCostAction), MaxCostActionReject) {
+		return false, fmt.Errorf(
+			"%w: the max output tokens may cost over the max cost of %s",
+			errMaxCostExceeded,
+			strconv.FormatFloat(maxCost, 'f', -1, 64),
+		)
+	}
+
+	affordable := maxAffordableOutputTokens(cost, maxCost, upper)
+	if affordable < 1 {
+		return false, fmt.Errorf(
+			"%w: no output token is affordable within the max cost of %s",
+			errMaxCostExceeded,
+			strconv.FormatFloat(maxCost, 'f', -1, 64),
+		)
+	}
+
+	if err := clampThinkingBudget(node, affordable, maxCost); err != nil {
+		return false, err
+	}
+
+	if err := setRequestMaxTokens(node, path, affordable); err != nil {
+		return false, err
+	}
+
+	log.Data["max_cost_clamped_max_tokens"] = affordable
+	c.Header(XAiproxyMaxTokensClamped, strconv.FormatInt(affordable, 10))
+
+	return true, nil
+}
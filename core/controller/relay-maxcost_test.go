@@ -0,0 +1,140 @@
+//nolint:testpackage
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxAffordableOutputTokens(t *testing.T) {
+	t.Parallel()
+
+	// 1 for the prompt and 0.001 per output token
+	cost := func(outputTokens int64) float64 {
+		return 1 + float64(outputTokens)*0.001
+	}
+
+	assert.Equal(t, int64(1000), maxAffordableOutputTokens(cost, 2, 4096))
+	assert.Equal(t, int64(4096), maxAffordableOutputTokens(cost, 10, 4096))
+	assert.Equal(t, int64(0), maxAffordableOutputTokens(cost, 1, 4096))
+}
+
+func TestRequestMaxTokensPaths(t *testing.T) {
+	t.Parallel()
+
+	node, err := common.GetJSONNodeNoCopy([]byte(`{"max_tokens":100}`))
+	require.NoError(t, err)
+
+	tokens, path := getRequestMaxTokens(&node, maxTokensPaths(mode.ChatCompletions))
+	assert.Equal(t, int64(100), tokens)
+	assert.Equal(t, []any{"max_tokens"}, path)
+
+	require.NoError(t, setRequestMaxTokens(&node, path, 50))
+
+	body, err := node.MarshalJSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"max_tokens":50}`, string(body))
+
+	node, err = common.GetJSONNodeNoCopy([]byte(`{"contents":[]}`))
+	require.NoError(t, err)
+
+	tokens, path = getRequestMaxTokens(&node, maxTokensPaths(mode.Gemini))
+	assert.Equal(t, int64(0), tokens)
+	require.NoError(t, setRequestMaxTokens(&node, path, 64))
+
+	body, err = node.MarshalJSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"contents":[],"generationConfig":{"maxOutputTokens":64}}`, string(body))
+}
+
+func TestClampThinkingBudget(t *testing.T) {
+	t.Parallel()
+
+	clamp := func(body string, maxTokens int64) (string, error) {
+		node, err := common.GetJSONNodeNoCopy([]byte(body))
+		require.NoError(t, err)
+
+		if err := clampThinkingBudget(&node, maxTokens, 0.01); err != nil {
+			return "", err
+		}
+
+		out, err := node.MarshalJSON()
+		require.NoError(t, err)
+
+		return string(out), nil
+	}
+
+	out, err := clamp(`{"thinking":{"type":"enabled","budget_tokens":8000}}`, 4000)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"thinking":{"type":"enabled","budget_tokens":3999}}`, out)
+
+	out, err = clamp(`{"thinking":{"type":"enabled","budget_tokens":2000}}`, 4000)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"thinking":{"type":"enabled","budget_tokens":2000}}`, out)
+
+	out, err = clamp(`{"thinking":{"type":"disabled"}}`, 100)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"thinking":{"type":"disabled"}}`, out)
+
+	out, err = clamp(`{"messages":[]}`, 100)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"messages":[]}`, out)
+
+	_, err = clamp(`{"thinking":{"type":"enabled","budget_tokens":2000}}`, 1024)
+	require.ErrorIs(t, err, errMaxCostExceeded)
+	assert.Contains(t, err.Error(), "min thinking budget")
+}
+
+func TestGetRequestMaxCost(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+
+	newContext := func(header string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/v1/chat/completions", nil)
+
+		if header != "" {
+			c.Request.Header.Set(XAiproxyMaxCost, header)
+		}
+
+		return c
+	}
+
+	node, err := common.GetJSONNodeNoCopy([]byte(`{"model":"gpt-4o","aiproxy_max_cost":0.5}`))
+	require.NoError(t, err)
+
+	maxCost, ok, bodyChanged, err := getRequestMaxCost(newContext(""), &node)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, bodyChanged)
+	assert.InDelta(t, 0.5, maxCost, 1e-9)
+	assert.False(t, node.Get(maxCostBodyField).Exists())
+
+	// the header takes precedence over the body extension
+	node, err = common.GetJSONNodeNoCopy([]byte(`{"aiproxy_max_cost":"0.5"}`))
+	require.NoError(t, err)
+
+	maxCost, ok, _, err = getRequestMaxCost(newContext("0.25"), &node)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.InDelta(t, 0.25, maxCost, 1e-9)
+
+	node, err = common.GetJSONNodeNoCopy([]byte(`{}`))
+	require.NoError(t, err)
+
+	_, ok, bodyChanged, err = getRequestMaxCost(newContext(""), &node)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, bodyChanged)
+
+	_, _, _, err = getRequestMaxCost(newContext("-1"), &node)
+	require.Error(t, err)
+}
@@ -0,0 +1,323 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor/anthropic"
+	"github.com/labring/aiproxy/core/relay/adaptors"
+	"github.com/labring/aiproxy/core/relay/meta"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	"github.com/labring/aiproxy/core/relay/utils"
+	gcache "github.com/patrickmn/go-cache"
+)
+
+const (
+	defaultAnthropicModelsLimit = 20
+	maxAnthropicModelsLimit     = 1000
+	anthropicModelsFetchTimeout = 10 * time.Second
+)
+
+// AnthropicModel is the model object of the anthropic models api
+// https://docs.anthropic.com/en/api/models
+type AnthropicModel struct {
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	CreatedAt   string `json:"created_at"`
+	// the token limits are emulated from the model config
+	MaxInputTokens int `json:"max_input_tokens,omitempty"`
+	MaxTokens      int `json:"max_tokens,omitempty"`
+}
+
+type AnthropicModelList struct {
+	Data    []*AnthropicModel `json:"data"`
+	HasMore bool              `json:"has_more"`
+	FirstID *string           `json:"first_id"`
+	LastID  *string           `json:"last_id"`
+}
+
+// upstreamAnthropicModels caches the models list of the anthropic channels by channel id
+var upstreamAnthropicModels = gcache.New(10*time.Minute, time.Minute)
+
+// isAnthropicModelsRequest reports whether the models request is sent by an
+// anthropic sdk, which always sends the anthropic-version header
+func isAnthropicModelsRequest(c *gin.Context) bool {
+	return c.Request.Header.Get("Anthropic-Version") != ""
+}
+
+func fetchUpstreamAnthropicModels(
+	ctx context.Context,
+	channel *model.Channel,
+) (map[string]*AnthropicModel, error) {
+	cacheKey := strconv.Itoa(channel.ID)
+	if v, ok := upstreamAnthropicModels.Get(cacheKey); ok {
+		if models, ok := v.(map[string]*AnthropicModel); ok {
+			return models, nil
+		}
+	}
+
+	baseURL := channel.BaseURL
+	if baseURL == "" {
+		a, ok := adaptors.GetAdaptor(channel.Type)
+		if !ok {
+			return nil, fmt.Errorf("adaptor not found for channel %d", channel.ID)
+		}
+
+		baseURL = a.DefaultBaseURL()
+	}
+
+	modelsURL, err := url.JoinPath(baseURL, "/models")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, anthropicModelsFetchTimeout)
+	defer cancel()
+
+	key, err := channel.GetKey()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		modelsURL+"?limit="+strconv.Itoa(maxAnthropicModelsLimit),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set(anthropic.AnthropicTokenHeader, key)
+	req.Header.Set("Anthropic-Version", anthropic.AnthropicVersion)
+
+	client, err := utils.LoadHTTPClientWithTLSConfigE(
+		anthropicModelsFetchTimeout,
+		channel.ProxyURL,
+		channel.SkipTLSVerify,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(
+			"list models of channel %d failed: status %d",
+			channel.ID,
+			resp.StatusCode,
+		)
+	}
+
+	var list AnthropicModelList
+	if err := sonic.ConfigDefault.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	models := make(map[string]*AnthropicModel, len(list.Data))
+	for _, m := range list.Data {
+		models[m.ID] = m
+	}
+
+	upstreamAnthropicModels.SetDefault(cacheKey, models)
+
+	return models, nil
+}
+
+// upstreamAnthropicModel returns the model info of the first anthropic channel
+// serving the model, nil when the model is not served by an anthropic channel
+func upstreamAnthropicModel(
+	c *gin.Context,
+	mc *model.ModelCaches,
+	availableSets []string,
+	modelName string,
+) *AnthropicModel {
+	for _, set := range availableSets {
+		for _, channel := range mc.EnabledModel2ChannelsBySet[set][modelName] {
+			if channel.Type != model.ChannelTypeAnthropic {
+				continue
+			}
+
+			models, err := fetchUpstreamAnthropicModels(c.Request.Context(), channel)
+			if err != nil {
+				common.GetLogger(c).Debugf("fetch anthropic models failed: %v", err)
+				// avoid fetching the failed channel again for every model
+				upstreamAnthropicModels.Set(
+					strconv.Itoa(channel.ID),
+					map[string]*AnthropicModel{},
+					time.Minute,
+				)
+
+				continue
+			}
+
+			actualModel, _ := meta.GetMappedModelName(modelName, channel.ModelMapping)
+			if m, ok := models[actualModel]; ok {
+				return m
+			}
+		}
+	}
+
+	return nil
+}
+
+// buildAnthropicModel builds the anthropic model object from the model config,
+// the display name and the creation time are passed through from the upstream
+// when the model is served by an anthropic channel
+func buildAnthropicModel(
+	c *gin.Context,
+	mc *model.ModelCaches,
+	availableSets []string,
+	modelName string,
+	config model.ModelConfig,
+) *AnthropicModel {
+	m := &AnthropicModel{
+		Type:        "model",
+		ID:          modelName,
+		DisplayName: modelName,
+		CreatedAt:   time.Unix(1626777600, 0).UTC().Format(time.RFC3339),
+	}
+
+	if !config.CreatedAt.IsZero() {
+		m.CreatedAt = config.CreatedAt.UTC().Format(time.RFC3339)
+	}
+
+	if maxInput, ok := config.MaxInputTokens(); ok {
+		m.MaxInputTokens = maxInput
+	}
+
+	if maxOutput, ok := config.MaxOutputTokens(); ok {
+		m.MaxTokens = maxOutput
+	}
+
+	if upstream := upstreamAnthropicModel(c, mc, availableSets, modelName); upstream != nil {
+		m.DisplayName = upstream.DisplayName
+		m.CreatedAt = upstream.CreatedAt
+	}
+
+	return m
+}
+
+// paginateAnthropicModels applies the before_id, after_id and limit query of the anthropic models api
+func paginateAnthropicModels(
+	models []*AnthropicModel,
+	beforeID, afterID string,
+	limit int,
+) AnthropicModelList {
+	if limit <= 0 {
+		limit = defaultAnthropicModelsLimit
+	}
+
+	limit = min(limit, maxAnthropicModelsLimit)
+
+	start, end := 0, len(models)
+	fromEnd := false
+
+	switch {
+	case afterID != "":
+		index := slices.IndexFunc(models, func(m *AnthropicModel) bool { return m.ID == afterID })
+		if index >= 0 {
+			start = index + 1
+		}
+	case beforeID != "":
+		index := slices.IndexFunc(models, func(m *AnthropicModel) bool { return m.ID == beforeID })
+		if index >= 0 {
+			end = index
+		}
+
+		fromEnd = true
+	}
+
+	page := models[start:end]
+	hasMore := len(page) > limit
+
+	if hasMore {
+		if fromEnd {
+			page = page[len(page)-limit:]
+		} else {
+			page = page[:limit]
+		}
+	}
+
+	list := AnthropicModelList{
+		Data:    page,
+		HasMore: hasMore,
+	}
+
+	if len(page) > 0 {
+		list.FirstID = &page[0].ID
+		list.LastID = &page[len(page)-1].ID
+	}
+
+	return list
+}
+
+func listAnthropicModels(c *gin.Context) {
+	mc := middleware.GetModelCaches(c)
+	token := middleware.GetToken(c)
+	group := middleware.GetGroup(c)
+	availableSets := group.GetAvailableSets()
+
+	models := make([]*AnthropicModel, 0)
+
+	token.Range(func(modelName string) bool {
+		if config, ok := mc.EnabledModelConfigsMap[modelName]; ok {
+			models = append(models, buildAnthropicModel(c, mc, availableSets, modelName, config))
+		}
+
+		return true
+	})
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	c.JSON(http.StatusOK, paginateAnthropicModels(
+		models,
+		c.Query("before_id"),
+		c.Query("after_id"),
+		limit,
+	))
+}
+
+func retrieveAnthropicModel(c *gin.Context) {
+	mc := middleware.GetModelCaches(c)
+	token := middleware.GetToken(c)
+	modelName := c.Param("model")
+	findModelName := token.FindModel(modelName)
+
+	config, ok := mc.EnabledModelConfigsMap[findModelName]
+	if !ok {
+		c.JSON(http.StatusNotFound, relaymodel.AnthropicErrorResponse{
+			Type: "error",
+			Error: relaymodel.AnthropicError{
+				Type:    "not_found_error",
+				Message: "model: " + modelName,
+			},
+		})
+
+		return
+	}
+
+	group := middleware.GetGroup(c)
+
+	m := buildAnthropicModel(c, mc, group.GetAvailableSets(), findModelName, config)
+	m.ID = modelName
+
+	c.JSON(http.StatusOK, m)
+}
@@ -0,0 +1,53 @@
+//nolint:testpackage
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func anthropicModelIDs(models []*AnthropicModel) []string {
+	ids := make([]string, 0, len(models))
+	for _, m := range models {
+		ids = append(ids, m.ID)
+	}
+
+	return ids
+}
+
+func TestPaginateAnthropicModels(t *testing.T) {
+	models := []*AnthropicModel{
+		{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}, {ID: "e"},
+	}
+
+	list := paginateAnthropicModels(models, "", "", 2)
+	assert.Equal(t, []string{"a", "b"}, anthropicModelIDs(list.Data))
+	assert.True(t, list.HasMore)
+	require.NotNil(t, list.FirstID)
+	require.NotNil(t, list.LastID)
+	assert.Equal(t, "a", *list.FirstID)
+	assert.Equal(t, "b", *list.LastID)
+
+	list = paginateAnthropicModels(models, "", "b", 2)
+	assert.Equal(t, []string{"c", "d"}, anthropicModelIDs(list.Data))
+	assert.True(t, list.HasMore)
+
+	list = paginateAnthropicModels(models, "", "d", 2)
+	assert.Equal(t, []string{"e"}, anthropicModelIDs(list.Data))
+	assert.False(t, list.HasMore)
+
+	list = paginateAnthropicModels(models, "d", "", 2)
+	assert.Equal(t, []string{"b", "c"}, anthropicModelIDs(list.Data))
+	assert.True(t, list.HasMore)
+
+	list = paginateAnthropicModels(models, "", "", 0)
+	assert.Len(t, list.Data, 5)
+	assert.False(t, list.HasMore)
+
+	list = paginateAnthropicModels(nil, "", "", 0)
+	assert.Empty(t, list.Data)
+	assert.Nil(t, list.FirstID)
+	assert.Nil(t, list.LastID)
+}
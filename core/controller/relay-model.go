@@ -2,23 +2,32 @@ package controller
 
 import (
 	"fmt"
+	"maps"
 	"net/http"
+	"slices"
 
 	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/config"
 	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
 	relaymodel "github.com/labring/aiproxy/core/relay/model"
 )
 
 // ListModels godoc
 //
 //	@Summary		List models
-//	@Description	List all models
+//	@Description	List all models, the anthropic models list format is returned when the anthropic-version header is set
 //	@Tags			relay
 //	@Produce		json
 //	@Security		ApiKeyAuth
 //	@Success		200	{object}	object{object=string,data=[]OpenAIModels}
 //	@Router			/v1/models [get]
 func ListModels(c *gin.Context) {
+	if isAnthropicModelsRequest(c) {
+		listAnthropicModels(c)
+		return
+	}
+
 	enabledModelConfigsMap := middleware.GetModelCaches(c).EnabledModelConfigsMap
 	token := middleware.GetToken(c)
 
@@ -26,20 +35,19 @@ func ListModels(c *gin.Context) {
 
 	token.Range(func(model string) bool {
 		if mc, ok := enabledModelConfigsMap[model]; ok {
-			availableOpenAIModels = append(availableOpenAIModels, &OpenAIModels{
-				ID:         model,
-				Object:     "model",
-				Created:    1626777600,
-				OwnedBy:    string(mc.Owner),
-				Root:       model,
-				Permission: permission,
-				Parent:     nil,
-			})
+			availableOpenAIModels = append(availableOpenAIModels, newOpenAIModels(model, mc))
 		}
 
 		return true
 	})
 
+	routerModels := config.GetRouterModels()
+	for _, name := range slices.Sorted(maps.Keys(routerModels)) {
+		if mc, ok := findRouterModelConfig(c, routerModels[name]); ok {
+			availableOpenAIModels = append(availableOpenAIModels, newOpenAIModels(name, mc))
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"object": "list",
 		"data":   availableOpenAIModels,
@@ -56,12 +64,23 @@ func ListModels(c *gin.Context) {
 //	@Success		200	{object}	OpenAIModels
 //	@Router			/v1/models/{model} [get]
 func RetrieveModel(c *gin.Context) {
+	if isAnthropicModelsRequest(c) {
+		retrieveAnthropicModel(c)
+		return
+	}
+
 	token := middleware.GetToken(c)
 	modelName := c.Param("model")
 	findModelName := token.FindModel(modelName)
 	enabledModelConfigsMap := middleware.GetModelCaches(c).EnabledModelConfigsMap
 
 	mc, ok := enabledModelConfigsMap[findModelName]
+	if !ok {
+		if router, isRouter := config.GetRouterModel(modelName); isRouter {
+			mc, ok = findRouterModelConfig(c, router)
+		}
+	}
+
 	if !ok {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": &relaymodel.OpenAIError{
@@ -75,13 +94,20 @@ func RetrieveModel(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, &OpenAIModels{
-		ID:         modelName,
-		Object:     "model",
-		Created:    1626777600,
-		OwnedBy:    string(mc.Owner),
-		Root:       modelName,
-		Permission: permission,
-		Parent:     nil,
-	})
+	c.JSON(http.StatusOK, newOpenAIModels(modelName, mc))
+}
+
+// findRouterModelConfig returns the model config of the default model of the
+// router model, the router model is only listed when the token can access it
+func findRouterModelConfig(c *gin.Context, router config.RouterModel) (model.ModelConfig, bool) {
+	token := middleware.GetToken(c)
+
+	findModelName := token.FindModel(router.DefaultModel)
+	if findModelName == "" {
+		return model.ModelConfig{}, false
+	}
+
+	mc, ok := middleware.GetModelCaches(c).EnabledModelConfigsMap[findModelName]
+
+	return mc, ok
 }
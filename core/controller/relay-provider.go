@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/bytedance/sonic/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+const (
+	// providerBodyField is the OpenRouter style provider preferences of the
+	// body, it is removed before the request is sent upstream
+	providerBodyField = "provider"
+
+	providerPreferencesKey = "provider_preferences"
+)
+
+var errProviderPreferencesNotAllowed = errors.New(
+	"provider preferences are not allowed for this token",
+)
+
+// ProviderPreferences restricts and orders the channels of a request, a
+// provider is matched against the channel name, the channel id or the channel
+// type name
+type ProviderPreferences struct {
+	// Order is the providers tried first, in order
+	Order []string `json:"order"`
+	// AllowFallbacks allows the providers out of the order after the ordered
+	// ones fail, true by default
+	AllowFallbacks *bool `json:"allow_fallbacks"`
+	// Ignore is the providers never used
+	Ignore []string `json:"ignore"`
+}
+
+func (p *ProviderPreferences) allowFallbacks() bool {
+	return p.AllowFallbacks == nil || *p.AllowFallbacks
+}
+
+func providerMatches(channel *model.Channel, provider string) bool {
+	provider = strings.TrimSpace(provider)
+	if provider == "" {
+		return false
+	}
+
+	return strings.EqualFold(channel.Name, provider) ||
+		strconv.Itoa(channel.ID) == provider ||
+		strings.EqualFold(channel.Type.String(), provider)
+}
+
+// providerIndex returns the index of the first provider of the order matching
+// the channel, -1 when none matches
+func providerIndex(channel *model.Channel, providers []string) int {
+	return slices.IndexFunc(providers, func(provider string) bool {
+		return providerMatches(channel, provider)
+	})
+}
+
+// policy ignores the ignored providers and, without fallbacks, the providers
+// out of the order, the ordered providers are preferred
+func (p *ProviderPreferences) policy() *channelPolicy {
+	if p == nil || (len(p.Order) == 0 && len(p.Ignore) == 0) {
+		return nil
+	}
+
+	policy := &channelPolicy{
+		description: fmt.Sprintf(
+			"the provider preferences of the request, order: %v, ignore: %v, allow fallbacks: %t",
+			p.Order,
+			p.Ignore,
+			p.allowFallbacks(),
+		),
+	}
+
+	onlyOrdered := !p.allowFallbacks() && len(p.Order) > 0
+	if len(p.Ignore) > 0 || onlyOrdered {
+		policy.allow = func(channel *model.Channel) bool {
+			if providerIndex(channel, p.Ignore) >= 0 {
+				return false
+			}
+
+			return !onlyOrdered || providerIndex(channel, p.Order) >= 0
+		}
+	}
+
+	if len(p.Order) > 0 {
+		policy.prefer = func(channel *model.Channel) bool {
+			return providerIndex(channel, p.Order) >= 0
+		}
+	}
+
+	return policy
+}
+
+// orderedChannelIDs returns the ids of the channels matching the order, in
+// the order of the providers, the channels of the same provider are ordered
+// by their priority
+func (p *ProviderPreferences) orderedChannelIDs(channels []*model.Channel) []int {
+	if p == nil || len(p.Order) == 0 {
+		return nil
+	}
+
+	type ranked struct {
+		channel *model.Channel
+		index   int
+	}
+
+	matched := make([]ranked, 0, len(channels))
+	for _, channel := range channels {
+		if providerIndex(channel, p.Ignore) >= 0 {
+			continue
+		}
+
+		if index := providerIndex(channel, p.Order); index >= 0 {
+			matched = append(matched, ranked{channel: channel, index: index})
+		}
+	}
+
+	slices.SortStableFunc(matched, func(a, b ranked) int {
+		if a.index != b.index {
+			return a.index - b.index
+		}
+
+		if a.channel.GetPriority() != b.channel.GetPriority() {
+			return int(b.channel.GetPriority() - a.channel.GetPriority())
+		}
+
+		return a.channel.ID - b.channel.ID
+	})
+
+	ids := make([]int, 0, len(matched))
+	for _, m := range matched {
+		ids = append(ids, m.channel.ID)
+	}
+
+	return ids
+}
+
+func supportsProviderPreferencesMode(m mode.Mode) bool {
+	switch m {
+	case mode.ChatCompletions,
+		mode.Completions,
+		mode.Responses,
+		mode.Anthropic,
+		mode.Embeddings:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseProviderPreferences returns the provider preferences of the body and
+// removes them from the body
+func parseProviderPreferences(node *ast.Node) (*ProviderPreferences, bool, error) {
+	field := node.Get(providerBodyField)
+	if field == nil || !field.Exists() {
+		return nil, false, nil
+	}
+
+	var preferences *ProviderPreferences
+
+	if field.TypeSafe() != ast.V_NULL {
+		raw, err := field.Raw()
+		if err != nil {
+			return nil, false, err
+		}
+
+		preferences = &ProviderPreferences{}
+		if err := sonic.UnmarshalString(raw, preferences); err != nil {
+			return nil, false, fmt.Errorf("invalid provider preferences: %w", err)
+		}
+	}
+
+	if _, err := node.Unset(providerBodyField); err != nil {
+		return nil, false, err
+	}
+
+	return preferences, true, nil
+}
+
+// applyProviderPreferences parses the provider preferences of the request,
+// the preferences are only accepted for the tokens allowed to send them
+func applyProviderPreferences(c *gin.Context, m mode.Mode) (int, error) {
+	if !supportsProviderPreferencesMode(m) {
+		return http.StatusOK, nil
+	}
+
+	node, err := common.UnmarshalRequest2NodeReusable(c.Request)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	preferences, bodyChanged, err := parseProviderPreferences(&node)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if !bodyChanged {
+		return http.StatusOK, nil
+	}
+
+	if preferences != nil {
+		if !middleware.GetToken(c).AllowProviderPreferences {
+			return http.StatusForbidden, errProviderPreferencesNotAllowed
+		}
+
+		c.Set(providerPreferencesKey, preferences)
+
+		log := common.GetLogger(c)
+		log.Data["provider_order"] = fmt.Sprintf("%v", preferences.Order)
+		log.Data["provider_ignore"] = fmt.Sprintf("%v", preferences.Ignore)
+	}
+
+	body, err := node.MarshalJSON()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	common.SetRequestBody(c.Request, body)
+
+	return http.StatusOK, nil
+}
+
+func getProviderPreferences(c *gin.Context) *ProviderPreferences {
+	v, ok := c.Get(providerPreferencesKey)
+	if !ok {
+		return nil
+	}
+
+	preferences, _ := v.(*ProviderPreferences)
+
+	return preferences
+}
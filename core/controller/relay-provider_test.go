@@ -0,0 +1,171 @@
+//nolint:testpackage
+package controller
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProviderPreferences(t *testing.T) {
+	t.Parallel()
+
+	node, err := common.GetJSONNodeNoCopy([]byte(
+		`{"model":"gpt-5","provider":{"order":["azure","openai"],"allow_fallbacks":false,"ignore":["7"]}}`,
+	))
+	require.NoError(t, err)
+
+	preferences, bodyChanged, err := parseProviderPreferences(&node)
+	require.NoError(t, err)
+	assert.True(t, bodyChanged)
+	require.NotNil(t, preferences)
+	assert.Equal(t, []string{"azure", "openai"}, preferences.Order)
+	assert.Equal(t, []string{"7"}, preferences.Ignore)
+	assert.False(t, preferences.allowFallbacks())
+
+	body, err := node.MarshalJSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"model":"gpt-5"}`, string(body))
+
+	node, err = common.GetJSONNodeNoCopy([]byte(`{"model":"gpt-5"}`))
+	require.NoError(t, err)
+
+	preferences, bodyChanged, err = parseProviderPreferences(&node)
+	require.NoError(t, err)
+	assert.False(t, bodyChanged)
+	assert.Nil(t, preferences)
+
+	node, err = common.GetJSONNodeNoCopy([]byte(`{"provider":"openai"}`))
+	require.NoError(t, err)
+
+	_, _, err = parseProviderPreferences(&node)
+	require.Error(t, err)
+}
+
+func TestGetChannelWithProviderPreferences(t *testing.T) {
+	t.Parallel()
+
+	openaiChannel := &model.Channel{
+		ID:       1,
+		Name:     "openai-main",
+		Type:     model.ChannelTypeOpenAI,
+		Status:   model.ChannelStatusEnabled,
+		Priority: 1000,
+	}
+	azureChannel := &model.Channel{
+		ID:       2,
+		Name:     "azure-eastus",
+		Type:     model.ChannelTypeAzure2,
+		Status:   model.ChannelStatusEnabled,
+		Priority: 1,
+	}
+	backupChannel := &model.Channel{
+		ID:       3,
+		Name:     "backup",
+		Type:     model.ChannelTypeOpenAI,
+		Status:   model.ChannelStatusEnabled,
+		Priority: 1,
+	}
+	channels := []*model.Channel{openaiChannel, azureChannel, backupChannel}
+	mc := &model.ModelCaches{
+		EnabledModel2ChannelsBySet: map[string]map[string][]*model.Channel{
+			model.ChannelDefaultSet: {
+				"gpt-5": channels,
+			},
+		},
+	}
+
+	t.Run("order is tried first", func(t *testing.T) {
+		t.Parallel()
+
+		preferences := &ProviderPreferences{Order: []string{"azure-eastus", "openai"}}
+
+		ids := preferences.orderedChannelIDs(channels)
+		assert.Equal(t, []int{2, 1, 3}, ids)
+
+		channel, migratedChannels, err := getChannelWithPolicy(
+			mc,
+			nil,
+			"gpt-5",
+			mode.ChatCompletions,
+			preferences.policy(),
+			ids,
+			nil,
+			nil,
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 2, channel.ID)
+		assert.Len(t, migratedChannels, 3)
+	})
+
+	t.Run("ignore removes the channels", func(t *testing.T) {
+		t.Parallel()
+
+		preferences := &ProviderPreferences{Ignore: []string{"openai"}}
+
+		channel, migratedChannels, err := getChannelWithPolicy(
+			mc,
+			nil,
+			"gpt-5",
+			mode.ChatCompletions,
+			preferences.policy(),
+			nil,
+			nil,
+			nil,
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 2, channel.ID)
+		assert.Len(t, migratedChannels, 1)
+	})
+
+	t.Run("without fallbacks only the ordered channels are used", func(t *testing.T) {
+		t.Parallel()
+
+		allowFallbacks := false
+		preferences := &ProviderPreferences{
+			Order:          []string{"1", "2"},
+			AllowFallbacks: &allowFallbacks,
+			Ignore:         []string{"azure"},
+		}
+
+		assert.Equal(t, []int{1}, preferences.orderedChannelIDs(channels))
+
+		_, migratedChannels, err := getChannelWithPolicy(
+			mc,
+			nil,
+			"gpt-5",
+			mode.ChatCompletions,
+			preferences.policy(),
+			nil,
+			nil,
+			nil,
+		)
+		require.NoError(t, err)
+		assert.Len(t, migratedChannels, 1)
+		assert.Equal(t, 1, migratedChannels[0].ID)
+	})
+
+	t.Run("no channel left is a policy error", func(t *testing.T) {
+		t.Parallel()
+
+		preferences := &ProviderPreferences{Ignore: []string{"openai", "azure"}}
+
+		_, _, err := getChannelWithPolicy(
+			mc,
+			nil,
+			"gpt-5",
+			mode.ChatCompletions,
+			preferences.policy(),
+			nil,
+			nil,
+			nil,
+		)
+
+		var policyErr *ChannelPolicyError
+		require.ErrorAs(t, err, &policyErr)
+	})
+}
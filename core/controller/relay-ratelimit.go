@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/reqlimit"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/shopspring/decimal"
+)
+
+// rateLimitWindow is the sliding window of the rpm and tpm limits, reported
+// like the reset of the x-ratelimit-* headers
+const rateLimitWindow = "1m0s"
+
+// ModelRateLimit mirrors the x-ratelimit-* headers of the responses of the
+// model, a zero limit means the model is not limited
+type ModelRateLimit struct {
+	Model             string `json:"model"`
+	LimitRequests     int64  `json:"limit_requests"`
+	RemainingRequests int64  `json:"remaining_requests"`
+	UsedRequests      int64  `json:"used_requests"`
+	ResetRequests     string `json:"reset_requests"`
+	LimitTokens       int64  `json:"limit_tokens"`
+	RemainingTokens   int64  `json:"remaining_tokens"`
+	UsedTokens        int64  `json:"used_tokens"`
+	ResetTokens       string `json:"reset_tokens"`
+}
+
+// TokenQuotaStatus is the quota consumption of the token, a zero quota means
+// the token is not limited
+type TokenQuotaStatus struct {
+	Quota           float64 `json:"quota"`
+	UsedAmount      float64 `json:"used_amount"`
+	RemainingAmount float64 `json:"remaining_amount,omitempty"`
+
+	PeriodQuota           float64 `json:"period_quota"`
+	PeriodType            string  `json:"period_type,omitempty"`
+	PeriodUsedAmount      float64 `json:"period_used_amount,omitempty"`
+	PeriodRemainingAmount float64 `json:"period_remaining_amount,omitempty"`
+	// PeriodResetAt is the unix milliseconds the period usage is reset at
+	PeriodResetAt int64 `json:"period_reset_at,omitempty"`
+}
+
+type RateLimitsResponse struct {
+	Group  string           `json:"group"`
+	Token  string           `json:"token"`
+	Models []ModelRateLimit `json:"models"`
+	Quota  TokenQuotaStatus `json:"quota"`
+}
+
+func getModelRateLimit(c *gin.Context, group model.GroupCache, mc model.ModelConfig) ModelRateLimit {
+	ctx := c.Request.Context()
+	mc = middleware.GetGroupAdjustedModelConfig(group, mc)
+
+	usedRequests, _ := reqlimit.GetGroupModelRequest(ctx, group.ID, mc.Model)
+	usedTokens, _ := reqlimit.GetGroupModelTokensRequest(ctx, group.ID, mc.Model)
+
+	status := ModelRateLimit{
+		Model:         mc.Model,
+		UsedRequests:  usedRequests,
+		ResetRequests: rateLimitWindow,
+		UsedTokens:    usedTokens,
+		ResetTokens:   rateLimitWindow,
+	}
+
+	// the internal groups are never limited
+	if group.Status == model.GroupStatusInternal {
+		return status
+	}
+
+	if mc.RPM > 0 {
+		status.LimitRequests = mc.RPM
+		status.RemainingRequests = max(0, mc.RPM-usedRequests)
+	}
+
+	if mc.TPM > 0 {
+		status.LimitTokens = mc.TPM
+		status.RemainingTokens = max(0, mc.TPM-usedTokens)
+	}
+
+	return status
+}
+
+func getTokenQuotaStatus(token model.TokenCache) TokenQuotaStatus {
+	status := TokenQuotaStatus{
+		Quota:       token.Quota,
+		UsedAmount:  token.UsedAmount,
+		PeriodQuota: token.PeriodQuota,
+	}
+
+	if token.Quota > 0 {
+		status.RemainingAmount = max(0, decimal.NewFromFloat(token.Quota).
+			Sub(decimal.NewFromFloat(token.UsedAmount)).
+			InexactFloat64())
+	}
+
+	if token.PeriodQuota > 0 {
+		used, resetAt := token.PeriodUsage()
+
+		status.PeriodType = token.PeriodType
+		if status.PeriodType == "" {
+			status.PeriodType = model.PeriodTypeMonthly
+		}
+
+		status.PeriodUsedAmount = used
+		status.PeriodRemainingAmount = max(0, decimal.NewFromFloat(token.PeriodQuota).
+			Sub(decimal.NewFromFloat(used)).
+			InexactFloat64())
+		status.PeriodResetAt = resetAt.UnixMilli()
+	}
+
+	return status
+}
+
+// requestedModels returns the models of the model query, comma separated or
+// repeated, the models of the token by default
+func requestedModels(c *gin.Context, token model.TokenCache) []string {
+	var models []string
+
+	for _, value := range c.QueryArray("model") {
+		for name := range strings.SplitSeq(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				models = append(models, name)
+			}
+		}
+	}
+
+	if len(models) > 0 {
+		return models
+	}
+
+	token.Range(func(model string) bool {
+		models = append(models, model)
+		return true
+	})
+
+	return models
+}
+
+// GetRateLimits godoc
+//
+//	@Summary		Get rate limits
+//	@Description	Returns the current rpm and tpm consumption of the models of the token and the quota consumption of the token, the x-ratelimit-* headers are set when a single model is queried
+//	@Tags			relay
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			model	query		string	false	"Models, comma separated, default is all the models of the token"
+//	@Success		200		{object}	RateLimitsResponse
+//	@Router			/v1/rate_limits [get]
+func GetRateLimits(c *gin.Context) {
+	group := middleware.GetGroup(c)
+	token := middleware.GetToken(c)
+	enabledModelConfigsMap := middleware.GetModelCaches(c).EnabledModelConfigsMap
+
+	models := make([]ModelRateLimit, 0)
+
+	for _, name := range requestedModels(c, token) {
+		findModelName := token.FindModel(name)
+		if findModelName == "" {
+			continue
+		}
+
+		mc, ok := enabledModelConfigsMap[findModelName]
+		if !ok {
+			continue
+		}
+
+		models = append(models, getModelRateLimit(c, group, mc))
+	}
+
+	if len(models) == 1 && models[0].LimitRequests > 0 {
+		c.Header(middleware.XRateLimitLimitRequests, strconv.FormatInt(models[0].LimitRequests, 10))
+		c.Header(
+			middleware.XRateLimitRemainingRequests,
+			strconv.FormatInt(models[0].RemainingRequests, 10),
+		)
+		c.Header(middleware.XRateLimitResetRequests, models[0].ResetRequests)
+	}
+
+	if len(models) == 1 && models[0].LimitTokens > 0 {
+		c.Header(middleware.XRateLimitLimitTokens, strconv.FormatInt(models[0].LimitTokens, 10))
+		c.Header(
+			middleware.XRateLimitRemainingTokens,
+			strconv.FormatInt(models[0].RemainingTokens, 10),
+		)
+		c.Header(middleware.XRateLimitResetTokens, models[0].ResetTokens)
+	}
+
+	c.JSON(http.StatusOK, RateLimitsResponse{
+		Group:  group.ID,
+		Token:  token.Name,
+		Models: models,
+		Quota:  getTokenQuotaStatus(token),
+	})
+}
@@ -0,0 +1,69 @@
+//nolint:testpackage
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTokenQuotaStatus(t *testing.T) {
+	t.Parallel()
+
+	status := getTokenQuotaStatus(model.TokenCache{UsedAmount: 3})
+	assert.Equal(t, TokenQuotaStatus{UsedAmount: 3}, status)
+
+	periodStart := time.Now().Add(-time.Hour)
+	token := (&model.Token{
+		Quota:                  10,
+		UsedAmount:             12,
+		PeriodQuota:            5,
+		PeriodType:             model.PeriodTypeWeekly,
+		PeriodLastUpdateTime:   periodStart,
+		PeriodLastUpdateAmount: 10,
+	}).ToTokenCache()
+
+	status = getTokenQuotaStatus(*token)
+	assert.InDelta(t, 0, status.RemainingAmount, 1e-9)
+	assert.Equal(t, model.PeriodTypeWeekly, status.PeriodType)
+	assert.InDelta(t, 2, status.PeriodUsedAmount, 1e-9)
+	assert.InDelta(t, 3, status.PeriodRemainingAmount, 1e-9)
+	assert.Equal(t, periodStart.Add(7*24*time.Hour).UnixMilli(), status.PeriodResetAt)
+}
+
+func TestRequestedModels(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodGet,
+		"/v1/rate_limits?model=gpt-4o,%20gpt-4o-mini&model=o3",
+		nil,
+	)
+
+	assert.Equal(
+		t,
+		[]string{"gpt-4o", "gpt-4o-mini", "o3"},
+		requestedModels(c, model.TokenCache{}),
+	)
+
+	// gin caches the parsed query, so the fallback needs a new context
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/v1/rate_limits", nil)
+
+	token := model.TokenCache{}
+	token.SetAvailableSets([]string{model.ChannelDefaultSet})
+	token.SetModelsBySet(map[string][]string{
+		model.ChannelDefaultSet: {"gpt-4o", "o3"},
+	})
+
+	assert.Equal(t, []string{"gpt-4o", "o3"}, requestedModels(c, token))
+}
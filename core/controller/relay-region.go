@@ -0,0 +1,228 @@
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/common/geoip"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+)
+
+// ChannelPolicyError is returned when no channel satisfies the routing policy of the request
+type ChannelPolicyError struct {
+	Message string
+}
+
+func (e *ChannelPolicyError) Error() string {
+	return e.Message
+}
+
+// channelPolicy restricts and orders the candidate channels of a request,
+// allow rejects channels that must never be used, prefer picks the channels
+// that are tried first before falling back to the others
+type channelPolicy struct {
+	allow       func(channel *model.Channel) bool
+	prefer      func(channel *model.Channel) bool
+	description string
+}
+
+func (p *channelPolicy) filter(
+	channels []*model.Channel,
+	modelName string,
+) ([]*model.Channel, error) {
+	if p == nil || p.allow == nil {
+		return channels, nil
+	}
+
+	allowed := make([]*model.Channel, 0, len(channels))
+	for _, channel := range channels {
+		if p.allow(channel) {
+			allowed = append(allowed, channel)
+		}
+	}
+
+	if len(allowed) == 0 {
+		return nil, &ChannelPolicyError{
+			Message: fmt.Sprintf(
+				"no channel of model `%s` satisfies %s",
+				modelName,
+				p.description,
+			),
+		}
+	}
+
+	return allowed, nil
+}
+
+func (p *channelPolicy) preferred(channels []*model.Channel) []*model.Channel {
+	if p == nil || p.prefer == nil {
+		return nil
+	}
+
+	preferred := make([]*model.Channel, 0, len(channels))
+	for _, channel := range channels {
+		if p.prefer(channel) {
+			preferred = append(preferred, channel)
+		}
+	}
+
+	return preferred
+}
+
+func channelInRegions(channel *model.Channel, regions []string) bool {
+	for _, region := range regions {
+		if strings.EqualFold(channel.Region, region) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getGeoRoutingPolicy resolves the geo routing rule of the client location,
+// the country rule takes precedence over the continent rule
+func getGeoRoutingPolicy(c *gin.Context) *channelPolicy {
+	rules := config.GetGeoRoutingRules()
+	if len(rules) == 0 || !geoip.Enabled() {
+		return nil
+	}
+
+	location := geoip.Lookup(middleware.GeoClientIP(c))
+
+	clientRegion := location.Country
+
+	rule, ok := rules[location.Country]
+	if !ok || location.Country == "" {
+		clientRegion = location.Continent
+		rule, ok = rules[location.Continent]
+	}
+
+	if !ok || clientRegion == "" || len(rule.ChannelRegions) == 0 {
+		return nil
+	}
+
+	regions := rule.ChannelRegions
+	match := func(channel *model.Channel) bool {
+		return channelInRegions(channel, regions)
+	}
+
+	policy := &channelPolicy{
+		description: fmt.Sprintf(
+			"the geo routing rule of region %s, allowed channel regions: %v",
+			clientRegion,
+			regions,
+		),
+	}
+
+	if rule.Strict {
+		policy.allow = match
+	} else {
+		policy.prefer = match
+	}
+
+	return policy
+}
+
+// channelResidency returns the data residency tags of the channel,
+// the region of the channel is always one of them
+func channelResidency(channel *model.Channel, modelResidency []string) []string {
+	residency := make([]string, 0, len(channel.DataResidency)+len(modelResidency)+1)
+	if channel.Region != "" {
+		residency = append(residency, channel.Region)
+	}
+
+	residency = append(residency, channel.DataResidency...)
+
+	return append(residency, modelResidency...)
+}
+
+// getDataResidencyPolicy only allows the channels tagged with one of the
+// data residency requirements of the group
+func getDataResidencyPolicy(c *gin.Context) *channelPolicy {
+	group := middleware.GetGroup(c)
+	if len(group.DataResidency) == 0 {
+		return nil
+	}
+
+	required := []string(group.DataResidency)
+	modelResidency, _ := model.GetModelConfigStringSlice(
+		middleware.GetModelConfig(c).Config,
+		model.ModelConfigDataResidencyKey,
+	)
+
+	return &channelPolicy{
+		allow: func(channel *model.Channel) bool {
+			for _, residency := range channelResidency(channel, modelResidency) {
+				if slices.ContainsFunc(required, func(r string) bool {
+					return strings.EqualFold(r, residency)
+				}) {
+					return true
+				}
+			}
+
+			return false
+		},
+		description: fmt.Sprintf("the data residency requirement of group %s: %v", group.ID, required),
+	}
+}
+
+// mergeChannelPolicies requires all allow rules and all prefer rules to match
+func mergeChannelPolicies(policies ...*channelPolicy) *channelPolicy {
+	policies = slices.DeleteFunc(policies, func(p *channelPolicy) bool { return p == nil })
+
+	switch len(policies) {
+	case 0:
+		return nil
+	case 1:
+		return policies[0]
+	}
+
+	merged := &channelPolicy{}
+	descriptions := make([]string, 0, len(policies))
+
+	var allows, prefers []func(channel *model.Channel) bool
+	for _, p := range policies {
+		if p.allow != nil {
+			allows = append(allows, p.allow)
+			descriptions = append(descriptions, p.description)
+		}
+
+		if p.prefer != nil {
+			prefers = append(prefers, p.prefer)
+		}
+	}
+
+	all := func(rules []func(channel *model.Channel) bool) func(channel *model.Channel) bool {
+		if len(rules) == 0 {
+			return nil
+		}
+
+		return func(channel *model.Channel) bool {
+			for _, rule := range rules {
+				if !rule(channel) {
+					return false
+				}
+			}
+
+			return true
+		}
+	}
+
+	merged.allow = all(allows)
+	merged.prefer = all(prefers)
+	merged.description = strings.Join(descriptions, " and ")
+
+	return merged
+}
+
+func getChannelPolicy(c *gin.Context) *channelPolicy {
+	return mergeChannelPolicies(
+		getDataResidencyPolicy(c),
+		getGeoRoutingPolicy(c),
+		getProviderPreferences(c).policy(),
+	)
+}
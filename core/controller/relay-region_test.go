@@ -0,0 +1,211 @@
+//nolint:testpackage
+package controller
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetChannelWithRegionPolicy(t *testing.T) {
+	t.Parallel()
+
+	euChannel := &model.Channel{
+		ID:       1,
+		Type:     model.ChannelTypeOpenAI,
+		Status:   model.ChannelStatusEnabled,
+		Priority: 1,
+		Region:   "EU",
+	}
+	usChannel := &model.Channel{
+		ID:       2,
+		Type:     model.ChannelTypeOpenAI,
+		Status:   model.ChannelStatusEnabled,
+		Priority: 1000,
+		Region:   "US",
+	}
+	mc := &model.ModelCaches{
+		EnabledModel2ChannelsBySet: map[string]map[string][]*model.Channel{
+			model.ChannelDefaultSet: {
+				"gpt-5": {euChannel, usChannel},
+			},
+		},
+	}
+
+	inEU := func(channel *model.Channel) bool {
+		return channelInRegions(channel, []string{"eu"})
+	}
+
+	t.Run("strict policy only uses allowed channels", func(t *testing.T) {
+		t.Parallel()
+
+		channel, migratedChannels, err := getChannelWithPolicy(
+			mc,
+			nil,
+			"gpt-5",
+			mode.ChatCompletions,
+			&channelPolicy{allow: inEU},
+			nil,
+			nil,
+			nil,
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 1, channel.ID)
+		assert.Len(t, migratedChannels, 1)
+	})
+
+	t.Run("prefer policy falls back when preferred channels are unavailable", func(t *testing.T) {
+		t.Parallel()
+
+		channel, _, err := getChannelWithPolicy(
+			mc,
+			nil,
+			"gpt-5",
+			mode.ChatCompletions,
+			&channelPolicy{prefer: inEU},
+			nil,
+			nil,
+			nil,
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 1, channel.ID)
+
+		channel, _, err = getChannelWithPolicy(
+			mc,
+			nil,
+			"gpt-5",
+			mode.ChatCompletions,
+			&channelPolicy{prefer: inEU},
+			nil,
+			nil,
+			map[int64]struct{}{1: {}},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 2, channel.ID)
+	})
+
+	t.Run("strict policy without compliant channel returns policy error", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := getChannelWithPolicy(
+			mc,
+			nil,
+			"gpt-5",
+			mode.ChatCompletions,
+			&channelPolicy{
+				allow: func(channel *model.Channel) bool {
+					return channelInRegions(channel, []string{"CN"})
+				},
+				description: "test policy",
+			},
+			nil,
+			nil,
+			nil,
+		)
+
+		var policyErr *ChannelPolicyError
+		require.ErrorAs(t, err, &policyErr)
+		assert.Contains(t, policyErr.Error(), "test policy")
+	})
+}
+
+func TestMergeChannelPolicies(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, mergeChannelPolicies(nil, nil))
+
+	residency := &channelPolicy{
+		allow: func(channel *model.Channel) bool {
+			return slices.Contains(channelResidency(channel, []string{"GDPR"}), "GDPR")
+		},
+		description: "residency",
+	}
+	geo := &channelPolicy{
+		prefer: func(channel *model.Channel) bool {
+			return channelInRegions(channel, []string{"EU"})
+		},
+	}
+
+	assert.Same(t, residency, mergeChannelPolicies(residency, nil))
+
+	merged := mergeChannelPolicies(residency, geo)
+	require.NotNil(t, merged)
+	assert.Equal(t, "residency", merged.description)
+	assert.True(t, merged.allow(&model.Channel{Region: "US"}))
+	assert.True(t, merged.prefer(&model.Channel{Region: "EU"}))
+	assert.False(t, merged.prefer(&model.Channel{Region: "US"}))
+}
+
+func TestChannelResidency(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(
+		t,
+		[]string{"EU", "GDPR", "SOC2"},
+		channelResidency(
+			&model.Channel{Region: "EU", DataResidency: []string{"GDPR"}},
+			[]string{"SOC2"},
+		),
+	)
+	assert.Empty(t, channelResidency(&model.Channel{}, nil))
+}
+
+func TestGetWebSearchChannelFollowsSetsAndPolicy(t *testing.T) {
+	t.Parallel()
+
+	euChannel := &model.Channel{
+		ID:     11,
+		Type:   model.ChannelTypeOpenAI,
+		Status: model.ChannelStatusEnabled,
+		Region: "EU",
+	}
+	usChannel := &model.Channel{
+		ID:     12,
+		Type:   model.ChannelTypeOpenAI,
+		Status: model.ChannelStatusEnabled,
+		Region: "US",
+	}
+	mc := &model.ModelCaches{
+		EnabledModel2ChannelsBySet: map[string]map[string][]*model.Channel{
+			model.ChannelDefaultSet: {"gpt-5-mini": {euChannel}},
+			"premium":               {"gpt-5-mini": {usChannel}},
+		},
+	}
+
+	inUS := &channelPolicy{
+		allow: func(channel *model.Channel) bool {
+			return channelInRegions(channel, []string{"us"})
+		},
+		description: "the us region",
+	}
+
+	channel, err := getWebSearchChannel(t.Context(), mc, []string{"premium"}, nil, "gpt-5-mini")
+	require.NoError(t, err)
+	assert.Equal(t, 12, channel.ID)
+
+	channel, err = getWebSearchChannel(
+		t.Context(),
+		mc,
+		[]string{model.ChannelDefaultSet, "premium"},
+		inUS,
+		"gpt-5-mini",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 12, channel.ID)
+
+	// the channel outside the policy is not used even if it is the only one
+	_, err = getWebSearchChannel(
+		t.Context(),
+		mc,
+		[]string{model.ChannelDefaultSet},
+		inUS,
+		"gpt-5-mini",
+	)
+
+	var policyErr *ChannelPolicyError
+	require.ErrorAs(t, err, &policyErr)
+}
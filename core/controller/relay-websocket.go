@@ -0,0 +1,310 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/bytedance/sonic/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/middleware"
+)
+
+const (
+	webSocketRequestTimeout = 30 * time.Second
+	webSocketWriteTimeout   = 10 * time.Second
+	// the client must answer the pings within webSocketPongTimeout, otherwise
+	// the connection is considered dead and the relay is canceled
+	webSocketPongTimeout  = 60 * time.Second
+	webSocketPingInterval = webSocketPongTimeout * 9 / 10
+	webSocketDoneMessage  = "[DONE]"
+)
+
+var webSocketUpgrader = websocket.Upgrader{
+	Subprotocols: []string{middleware.WebSocketProtocol},
+	// the upgrade runs after TokenAuth, which rejects the origins not allowed
+	// by the token, and the key is never read from cookies, so a cross site
+	// page can not use the credentials of the browser
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// webSocketResponseWriter bridges the sse response of the relay to websocket
+// messages, each sse data payload is sent as a text message, a non sse
+// response, e.g. an error, is sent as one message when the relay is done
+type webSocketResponseWriter struct {
+	gin.ResponseWriter
+	conn      *websocket.Conn
+	header    http.Header
+	status    int
+	size      int
+	streaming bool
+	buf       bytes.Buffer
+	err       error
+}
+
+var _ gin.ResponseWriter = (*webSocketResponseWriter)(nil)
+
+func (w *webSocketResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *webSocketResponseWriter) WriteHeader(statusCode int) {
+	if w.Written() {
+		return
+	}
+
+	w.status = statusCode
+	w.size = 0
+}
+
+func (w *webSocketResponseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.WriteHeader(w.status)
+	}
+}
+
+func (w *webSocketResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeaderNow()
+
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	// the content type may be set after the status
+	if w.size == 0 {
+		w.streaming = w.status == http.StatusOK &&
+			strings.HasPrefix(w.header.Get("Content-Type"), "text/event-stream")
+	}
+
+	w.buf.Write(b)
+	w.size += len(b)
+
+	if w.streaming {
+		if err := w.sendEvents(); err != nil {
+			w.err = err
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+func (w *webSocketResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *webSocketResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *webSocketResponseWriter) Size() int {
+	return w.size
+}
+
+func (w *webSocketResponseWriter) Written() bool {
+	return w.size != -1
+}
+
+func (w *webSocketResponseWriter) Flush() {}
+
+func (w *webSocketResponseWriter) send(data []byte) error {
+	_ = w.conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+	return w.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// sendEvents sends the complete data lines of the buffered sse events
+func (w *webSocketResponseWriter) sendEvents() error {
+	for {
+		data := w.buf.Bytes()
+
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			return nil
+		}
+
+		line := bytes.TrimRight(data[:end], "\r")
+		if payload, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			payload = bytes.TrimSpace(payload)
+			// the done message is sent after the relay finished
+			if len(payload) > 0 && string(payload) != webSocketDoneMessage {
+				if err := w.send(payload); err != nil {
+					return err
+				}
+			}
+		}
+
+		w.buf.Next(end + 1)
+	}
+}
+
+// finish sends the rest of the response
+func (w *webSocketResponseWriter) finish() {
+	if w.err != nil {
+		return
+	}
+
+	if w.streaming {
+		if w.buf.Len() > 0 {
+			w.buf.WriteByte('\n')
+			w.err = w.sendEvents()
+		}
+
+		return
+	}
+
+	if w.buf.Len() > 0 {
+		w.err = w.send(w.buf.Bytes())
+	}
+}
+
+// readWebSocketRequest reads the first message as the request body, the
+// request is always streamed
+func readWebSocketRequest(conn *websocket.Conn) ([]byte, error) {
+	conn.SetReadLimit(common.MaxRequestBodySize)
+	_ = conn.SetReadDeadline(time.Now().Add(webSocketRequestTimeout))
+
+	_, body, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := sonic.Get(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := node.Set("stream", ast.NewBool(true)); err != nil {
+		return nil, err
+	}
+
+	return node.MarshalJSON()
+}
+
+// keepWebSocketAlive pings the client until the context is done, the relay is
+// canceled when the client closes the connection or stops answering the pings
+func keepWebSocketAlive(ctx context.Context, conn *websocket.Conn, cancel context.CancelFunc) {
+	_ = conn.SetReadDeadline(time.Now().Add(webSocketPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(webSocketPongTimeout))
+	})
+
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(webSocketPingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := conn.WriteControl(
+					websocket.PingMessage,
+					nil,
+					time.Now().Add(webSocketWriteTimeout),
+				)
+				if err != nil {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+}
+
+func closeWebSocket(conn *websocket.Conn, code int, text string) {
+	_ = conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, text),
+		time.Now().Add(webSocketWriteTimeout),
+	)
+	_ = conn.Close()
+}
+
+// webSocketBridge upgrades the connection, reads the request from the first
+// message and runs the rest of the handlers as a streaming http request
+func webSocketBridge(c *gin.Context) {
+	conn, err := webSocketUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader has replied the handshake error
+		c.Abort()
+		return
+	}
+
+	body, err := readWebSocketRequest(conn)
+	if err != nil {
+		common.GetLogger(c).Debugf("read websocket request failed: %v", err)
+		closeWebSocket(conn, websocket.CloseUnsupportedData, "invalid request")
+		c.Abort()
+
+		return
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	keepWebSocketAlive(ctx, conn, cancel)
+
+	c.Request = c.Request.WithContext(ctx)
+	c.Request.Method = http.MethodPost
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set("Accept", "text/event-stream")
+	common.SetRequestBody(c.Request, body)
+
+	w := &webSocketResponseWriter{
+		ResponseWriter: c.Writer,
+		conn:           conn,
+		header:         make(http.Header),
+		status:         http.StatusOK,
+		size:           -1,
+	}
+	c.Writer = w
+
+	c.Next()
+
+	w.finish()
+
+	if w.err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	switch {
+	case w.status >= http.StatusInternalServerError:
+		closeWebSocket(conn, websocket.CloseInternalServerErr, http.StatusText(w.status))
+		return
+	case w.status >= http.StatusBadRequest:
+		closeWebSocket(conn, websocket.ClosePolicyViolation, http.StatusText(w.status))
+		return
+	}
+
+	_ = w.send([]byte(webSocketDoneMessage))
+	closeWebSocket(conn, websocket.CloseNormalClosure, "")
+}
+
+// ChatCompletionsWebSocket godoc
+//
+//	@Summary		ChatCompletions over WebSocket
+//	@Description	Bridges the streaming chat completions to WebSocket, the first text message is the request body, each chunk is sent as a text message followed by [DONE]. Browsers pass the api key by the aiproxy-api-key.<key> subprotocol together with the aiproxy.v1 subprotocol
+//	@Tags			relay
+//	@Security		ApiKeyAuth
+//	@Router			/v1/chat/completions/ws [get]
+func ChatCompletionsWebSocket() []gin.HandlerFunc {
+	return append(
+		[]gin.HandlerFunc{webSocketBridge},
+		ChatCompletions()...,
+	)
+}
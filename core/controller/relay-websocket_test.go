@@ -0,0 +1,131 @@
+//nolint:testpackage
+package controller
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dialWebSocketBridge(t *testing.T, handler gin.HandlerFunc) *websocket.Conn {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.GET("/ws", webSocketBridge, handler)
+
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+
+	dialer := websocket.Dialer{Subprotocols: []string{middleware.WebSocketProtocol}}
+
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = resp.Body.Close()
+		_ = conn.Close()
+	})
+
+	assert.Equal(t, middleware.WebSocketProtocol, conn.Subprotocol())
+
+	return conn
+}
+
+func readWebSocketMessages(t *testing.T, conn *websocket.Conn) ([]string, int) {
+	t.Helper()
+
+	var messages []string
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			require.ErrorAs(t, err, &closeErr)
+
+			return messages, closeErr.Code
+		}
+
+		messages = append(messages, string(data))
+	}
+}
+
+func TestWebSocketBridgeStreamsSSEChunks(t *testing.T) {
+	conn := dialWebSocketBridge(t, func(c *gin.Context) {
+		assert.Equal(t, http.MethodPost, c.Request.Method)
+
+		body, err := io.ReadAll(c.Request.Body)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"model":"gpt-4o","stream":true}`, string(body))
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Status(http.StatusOK)
+		_, _ = c.Writer.WriteString("data: {\"id\":1}\n\n")
+		_, _ = c.Writer.WriteString("data: {\"id\":")
+		_, _ = c.Writer.WriteString("2}\n\ndata: [DONE]\n\n")
+	})
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"model":"gpt-4o"}`)))
+
+	messages, code := readWebSocketMessages(t, conn)
+	assert.Equal(t, []string{`{"id":1}`, `{"id":2}`, "[DONE]"}, messages)
+	assert.Equal(t, websocket.CloseNormalClosure, code)
+}
+
+func TestWebSocketBridgeSendsErrorResponse(t *testing.T) {
+	conn := dialWebSocketBridge(t, func(c *gin.Context) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": gin.H{"message": "rate limited"}})
+	})
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"model":"gpt-4o"}`)))
+
+	messages, code := readWebSocketMessages(t, conn)
+	require.Len(t, messages, 1)
+	assert.JSONEq(t, `{"error":{"message":"rate limited"}}`, messages[0])
+	assert.Equal(t, websocket.ClosePolicyViolation, code)
+}
+
+func TestWebSocketBridgeRejectsInvalidRequest(t *testing.T) {
+	conn := dialWebSocketBridge(t, func(c *gin.Context) {
+		t.Error("handler should not be called")
+	})
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`not json`)))
+
+	messages, code := readWebSocketMessages(t, conn)
+	assert.Empty(t, messages)
+	assert.Equal(t, websocket.CloseUnsupportedData, code)
+}
+
+func TestWebSocketBridgeCancelsRelayWhenClientCloses(t *testing.T) {
+	canceled := make(chan struct{})
+
+	var conn *websocket.Conn
+
+	conn = dialWebSocketBridge(t, func(c *gin.Context) {
+		_ = conn.Close()
+
+		select {
+		case <-c.Request.Context().Done():
+			close(canceled)
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"model":"gpt-4o"}`)))
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the relay is not canceled after the client closed the connection")
+	}
+}
@@ -156,6 +156,55 @@ func ImagesGenerations() []gin.HandlerFunc {
 	}
 }
 
+// AudioGenerations godoc
+//
+//	@Summary		AudioGenerations
+//	@Description	Create a music generation task
+//	@Tags			relay
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			request			body		model.AudioGenerationRequest	true	"Request"
+//	@Param			Aiproxy-Channel	header		string							false	"Optional Aiproxy-Channel header"
+//	@Success		200				{object}	model.AudioGeneration
+//	@Header			all				{integer}	X-RateLimit-Limit-Requests		"X-RateLimit-Limit-Requests"
+//	@Header			all				{integer}	X-RateLimit-Limit-Tokens		"X-RateLimit-Limit-Tokens"
+//	@Header			all				{integer}	X-RateLimit-Remaining-Requests	"X-RateLimit-Remaining-Requests"
+//	@Header			all				{integer}	X-RateLimit-Remaining-Tokens	"X-RateLimit-Remaining-Tokens"
+//	@Header			all				{string}	X-RateLimit-Reset-Requests		"X-RateLimit-Reset-Requests"
+//	@Header			all				{string}	X-RateLimit-Reset-Tokens		"X-RateLimit-Reset-Tokens"
+//	@Router			/v1/audio/generations [post]
+func AudioGenerations() []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		middleware.NewDistribute(mode.AudioGenerations),
+		NewRelay(mode.AudioGenerations),
+	}
+}
+
+// GetAudioGeneration godoc
+//
+//	@Summary		GetAudioGeneration
+//	@Description	Get a music generation task created with the same token
+//	@Tags			relay
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id				path		string							true	"Generation ID"
+//	@Param			Aiproxy-Channel	header		string							false	"Optional Aiproxy-Channel header"
+//	@Success		200				{object}	model.AudioGeneration
+//	@Header			all				{integer}	X-RateLimit-Limit-Requests		"X-RateLimit-Limit-Requests"
+//	@Header			all				{integer}	X-RateLimit-Limit-Tokens		"X-RateLimit-Limit-Tokens"
+//	@Header			all				{integer}	X-RateLimit-Remaining-Requests	"X-RateLimit-Remaining-Requests"
+//	@Header			all				{integer}	X-RateLimit-Remaining-Tokens	"X-RateLimit-Remaining-Tokens"
+//	@Header			all				{string}	X-RateLimit-Reset-Requests		"X-RateLimit-Reset-Requests"
+//	@Header			all				{string}	X-RateLimit-Reset-Tokens		"X-RateLimit-Reset-Tokens"
+//	@Router			/v1/audio/generations/{id} [get]
+func GetAudioGeneration() []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		middleware.NewDistribute(mode.AudioGenerationsGet),
+		NewRelay(mode.AudioGenerationsGet),
+	}
+}
+
 // AudioSpeech godoc
 //
 //	@Summary		AudioSpeech
@@ -710,6 +759,82 @@ func GetResponseInputItems() []gin.HandlerFunc {
 	}
 }
 
+// UploadFile godoc
+//
+//	@Summary		Upload file
+//	@Description	Upload a file to the channel of the model, the model field selects the channel and is not sent to the upstream
+//	@Tags			relay
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			model			formData	string	true	"Model used to select the channel"
+//	@Param			purpose			formData	string	true	"Purpose"
+//	@Param			file			formData	file	true	"File"
+//	@Param			Aiproxy-Channel	header		string	false	"Optional Aiproxy-Channel header"
+//	@Success		200				{object}	openai.FileObject
+//	@Failure		413				{object}	middleware.APIResponse	"File storage quota exceeded"
+//	@Router			/v1/files [post]
+func UploadFile() []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		middleware.NewDistribute(mode.Files),
+		NewRelay(mode.Files),
+	}
+}
+
+// GetFile godoc
+//
+//	@Summary		Get file
+//	@Description	Get an uploaded file by ID
+//	@Tags			relay
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id				path		string	true	"File ID"
+//	@Param			Aiproxy-Channel	header		string	false	"Optional Aiproxy-Channel header"
+//	@Success		200				{object}	openai.FileObject
+//	@Router			/v1/files/{id} [get]
+func GetFile() []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		middleware.NewDistribute(mode.FilesGet),
+		NewRelay(mode.FilesGet),
+	}
+}
+
+// GetFileContent godoc
+//
+//	@Summary		Get file content
+//	@Description	Download the content of an uploaded file
+//	@Tags			relay
+//	@Produce		octet-stream
+//	@Security		ApiKeyAuth
+//	@Param			id				path		string	true	"File ID"
+//	@Param			Aiproxy-Channel	header		string	false	"Optional Aiproxy-Channel header"
+//	@Success		200				{file}		binary
+//	@Router			/v1/files/{id}/content [get]
+func GetFileContent() []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		middleware.NewDistribute(mode.FilesContent),
+		NewRelay(mode.FilesContent),
+	}
+}
+
+// DeleteFile godoc
+//
+//	@Summary		Delete file
+//	@Description	Delete an uploaded file by ID, the file no longer counts in the group storage quota
+//	@Tags			relay
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id				path		string	true	"File ID"
+//	@Param			Aiproxy-Channel	header		string	false	"Optional Aiproxy-Channel header"
+//	@Success		200				{object}	object
+//	@Router			/v1/files/{id} [delete]
+func DeleteFile() []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		middleware.NewDistribute(mode.FilesDelete),
+		NewRelay(mode.FilesDelete),
+	}
+}
+
 // Gemini godoc
 //
 //	@Summary		Gemini Native API
@@ -779,6 +904,31 @@ func GeminiOperation() []gin.HandlerFunc {
 	}
 }
 
+// GeminiCachedContents godoc
+//
+//	@Summary		Gemini Cached Contents API
+//	@Description	Create a Gemini cached content, the cached content is bound to the channel creating it and the requests referencing it by cachedContent (Gemini) or cached_content (OpenAI) are routed to the same channel
+//	@Tags			relay
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			request			body		object	true	"Request"
+//	@Param			Aiproxy-Channel	header		string	false	"Optional Aiproxy-Channel header"
+//	@Success		200				{object}	object
+//	@Header			all				{integer}	X-RateLimit-Limit-Requests		"X-RateLimit-Limit-Requests"
+//	@Header			all				{integer}	X-RateLimit-Limit-Tokens		"X-RateLimit-Limit-Tokens"
+//	@Header			all				{integer}	X-RateLimit-Remaining-Requests	"X-RateLimit-Remaining-Requests"
+//	@Header			all				{integer}	X-RateLimit-Remaining-Tokens	"X-RateLimit-Remaining-Tokens"
+//	@Header			all				{string}	X-RateLimit-Reset-Requests		"X-RateLimit-Reset-Requests"
+//	@Header			all				{string}	X-RateLimit-Reset-Tokens		"X-RateLimit-Reset-Tokens"
+//	@Router			/v1beta/cachedContents [post]
+func GeminiCachedContents() []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		middleware.NewDistribute(mode.GeminiCachedContents),
+		NewRelay(mode.GeminiCachedContents),
+	}
+}
+
 func geminiPathAction(modelPath string) string {
 	modelPath = strings.TrimPrefix(modelPath, "/")
 
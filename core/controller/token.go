@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -45,11 +46,21 @@ type (
 	AddTokenRequest struct {
 		Name                 string   `json:"name"`
 		Subnets              []string `json:"subnets"`
+		Regions              []string `json:"regions"`
 		Models               []string `json:"models"`
 		Quota                float64  `json:"quota"`
 		PeriodQuota          float64  `json:"period_quota"`
 		PeriodType           string   `json:"period_type"`
 		PeriodLastUpdateTime int64    `json:"period_last_update_time"`
+		DebugUpstreamErrors  bool     `json:"debug_upstream_errors"`
+		DebugConversion      bool     `json:"debug_conversion"`
+		HedgeAfterMs         int64    `json:"hedge_after_ms"`
+		// AllowProviderPreferences allows the provider preferences in the requests
+		AllowProviderPreferences bool `json:"allow_provider_preferences"`
+		// AllowedOrigins are the browser origins allowed to use the token
+		AllowedOrigins []string `json:"allowed_origins"`
+		// ServerOnly rejects the browser requests of the token
+		ServerOnly bool `json:"server_only"`
 	}
 
 	UpdateTokenStatusRequest struct {
@@ -65,10 +76,19 @@ func (at *AddTokenRequest) ToToken() *model.Token {
 	token := &model.Token{
 		Name:        model.EmptyNullString(at.Name),
 		Subnets:     at.Subnets,
+		Regions:     at.Regions,
 		Models:      at.Models,
 		Quota:       at.Quota,
 		PeriodQuota: at.PeriodQuota,
 		PeriodType:  model.EmptyNullString(at.PeriodType),
+
+		DebugUpstreamErrors:      at.DebugUpstreamErrors,
+		DebugConversion:          at.DebugConversion,
+		HedgeAfterMs:             at.HedgeAfterMs,
+		AllowProviderPreferences: at.AllowProviderPreferences,
+
+		AllowedOrigins: at.AllowedOrigins,
+		ServerOnly:     at.ServerOnly,
 	}
 
 	if at.PeriodLastUpdateTime > 0 {
@@ -83,6 +103,14 @@ func validateToken(token AddTokenRequest) error {
 		return fmt.Errorf("invalid subnet: %w", err)
 	}
 
+	if token.HedgeAfterMs < 0 {
+		return errors.New("hedge_after_ms must not be negative")
+	}
+
+	if err := network.IsValidOrigins(token.AllowedOrigins); err != nil {
+		return fmt.Errorf("invalid allowed origin: %w", err)
+	}
+
 	return nil
 }
 
@@ -93,19 +121,25 @@ func validateSubnets(subnets []string) error {
 	return nil
 }
 
-func buildTokenResponse(token *model.Token) *TokenResponse {
+func buildTokenResponse(c *gin.Context, token *model.Token) *TokenResponse {
 	lastRequestAt, _ := model.GetGroupTokenLastRequestTimeMinute(token.GroupID, string(token.Name))
 
+	if !middleware.IsAdminRole(c) {
+		masked := *token
+		masked.Key = middleware.MaskKey(masked.Key)
+		token = &masked
+	}
+
 	return &TokenResponse{
 		Token:      token,
 		AccessedAt: lastRequestAt,
 	}
 }
 
-func buildTokenResponses(tokens []*model.Token) []*TokenResponse {
+func buildTokenResponses(c *gin.Context, tokens []*model.Token) []*TokenResponse {
 	responses := make([]*TokenResponse, len(tokens))
 	for i, token := range tokens {
-		responses[i] = buildTokenResponse(token)
+		responses[i] = buildTokenResponse(c, token)
 	}
 
 	return responses
@@ -138,7 +172,7 @@ func GetTokens(c *gin.Context) {
 	}
 
 	middleware.SuccessResponse(c, gin.H{
-		"tokens": buildTokenResponses(tokens),
+		"tokens": buildTokenResponses(c, tokens),
 		"total":  total,
 	})
 }
@@ -175,7 +209,7 @@ func GetGroupTokens(c *gin.Context) {
 	}
 
 	middleware.SuccessResponse(c, gin.H{
-		"tokens": buildTokenResponses(tokens),
+		"tokens": buildTokenResponses(c, tokens),
 		"total":  total,
 	})
 }
@@ -222,7 +256,7 @@ func SearchTokens(c *gin.Context) {
 	}
 
 	middleware.SuccessResponse(c, gin.H{
-		"tokens": buildTokenResponses(tokens),
+		"tokens": buildTokenResponses(c, tokens),
 		"total":  total,
 	})
 }
@@ -274,7 +308,7 @@ func SearchGroupTokens(c *gin.Context) {
 	}
 
 	middleware.SuccessResponse(c, gin.H{
-		"tokens": buildTokenResponses(tokens),
+		"tokens": buildTokenResponses(c, tokens),
 		"total":  total,
 	})
 }
@@ -302,7 +336,7 @@ func GetToken(c *gin.Context) {
 		return
 	}
 
-	middleware.SuccessResponse(c, buildTokenResponse(token))
+	middleware.SuccessResponse(c, buildTokenResponse(c, token))
 }
 
 // GetGroupToken godoc
@@ -335,7 +369,7 @@ func GetGroupToken(c *gin.Context) {
 		return
 	}
 
-	middleware.SuccessResponse(c, buildTokenResponse(token))
+	middleware.SuccessResponse(c, buildTokenResponse(c, token))
 }
 
 // AddGroupToken godoc
@@ -520,6 +554,28 @@ func UpdateToken(c *gin.Context) {
 		}
 	}
 
+	if req.AllowedOrigins != nil {
+		if err := network.IsValidOrigins(*req.AllowedOrigins); err != nil {
+			middleware.ErrorResponse(
+				c,
+				http.StatusBadRequest,
+				"parameter error: invalid allowed origin: "+err.Error(),
+			)
+
+			return
+		}
+	}
+
+	if req.HedgeAfterMs != nil && *req.HedgeAfterMs < 0 {
+		middleware.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			"parameter error: hedge_after_ms must not be negative",
+		)
+
+		return
+	}
+
 	token, err := model.UpdateToken(id, req)
 	if err != nil {
 		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
@@ -564,6 +620,28 @@ func UpdateGroupToken(c *gin.Context) {
 		}
 	}
 
+	if req.AllowedOrigins != nil {
+		if err := network.IsValidOrigins(*req.AllowedOrigins); err != nil {
+			middleware.ErrorResponse(
+				c,
+				http.StatusBadRequest,
+				"parameter error: invalid allowed origin: "+err.Error(),
+			)
+
+			return
+		}
+	}
+
+	if req.HedgeAfterMs != nil && *req.HedgeAfterMs < 0 {
+		middleware.ErrorResponse(
+			c,
+			http.StatusBadRequest,
+			"parameter error: hedge_after_ms must not be negative",
+		)
+
+		return
+	}
+
 	token, err := model.UpdateGroupToken(id, group, req)
 	if err != nil {
 		middleware.ErrorResponse(c, http.StatusInternalServerError, err.Error())
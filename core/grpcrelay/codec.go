@@ -0,0 +1,62 @@
+package grpcrelay
+
+import (
+	"fmt"
+
+	"github.com/bytedance/sonic"
+	"github.com/labring/aiproxy/core/grpcrelay/relaypb"
+	"google.golang.org/grpc/encoding"
+)
+
+// CodecName is the opt-in json content subtype of the relay service, the
+// messages are then the json bodies of the http api instead of the protobuf
+// messages, clients call with grpc.CallContentSubtype(CodecName)
+const CodecName = "json"
+
+func init() {
+	encoding.RegisterCodec(codec{})
+}
+
+// Message is the raw json body of a request or a response
+type Message []byte
+
+type codec struct{}
+
+func (codec) Name() string {
+	return CodecName
+}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	switch m := v.(type) {
+	case *relaypb.RelayResponse:
+		return m.GetBody(), nil
+	case *relaypb.RelayRequest:
+		return m.GetBody(), nil
+	case *Message:
+		return *m, nil
+	case Message:
+		return m, nil
+	default:
+		return sonic.Marshal(v)
+	}
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	switch m := v.(type) {
+	case *relaypb.RelayRequest:
+		m.Body = append(m.Body[:0], data...)
+		return nil
+	case *relaypb.RelayResponse:
+		m.Body = append(m.Body[:0], data...)
+		return nil
+	case *Message:
+		*m = append((*m)[:0], data...)
+		return nil
+	default:
+		if v == nil {
+			return fmt.Errorf("unmarshal into nil %T", v)
+		}
+
+		return sonic.Unmarshal(data, v)
+	}
+}
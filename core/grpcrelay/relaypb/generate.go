@@ -0,0 +1,4 @@
+// Package relaypb contains the protobuf messages and the service of the grpc relay
+package relaypb
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative grpcrelay/relaypb/relay.proto
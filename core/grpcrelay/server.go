@@ -0,0 +1,276 @@
+package grpcrelay
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/bytedance/sonic/ast"
+	"github.com/labring/aiproxy/core/grpcrelay/relaypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceName is the full name of the relay service
+const ServiceName = "aiproxy.relay.v1.Relay"
+
+// Server exposes the relay api by grpc, each call is relayed through the http
+// handler, so the auth, billing and channel selection are the same as the http api.
+// The messages are the protobuf messages of relaypb, json is opt-in by CodecName.
+type Server struct {
+	handler http.Handler
+	server  *grpc.Server
+}
+
+// NewServer creates a grpc server relaying the calls to the http handler
+func NewServer(handler http.Handler, opts ...grpc.ServerOption) *Server {
+	s := &Server{
+		handler: handler,
+		server:  grpc.NewServer(opts...),
+	}
+
+	relaypb.RegisterRelayServer(s.server, &relayServer{s: s})
+
+	return s
+}
+
+func (s *Server) Serve(lis net.Listener) error {
+	return s.server.Serve(lis)
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(lis)
+}
+
+// GracefulStop stops accepting new calls and waits the pending calls to finish
+func (s *Server) GracefulStop() {
+	s.server.GracefulStop()
+}
+
+func (s *Server) Stop() {
+	s.server.Stop()
+}
+
+type relayServer struct {
+	relaypb.UnimplementedRelayServer
+	s *Server
+}
+
+func (r *relayServer) ChatCompletions(
+	ctx context.Context,
+	in *relaypb.RelayRequest,
+) (*relaypb.RelayResponse, error) {
+	return r.s.unary(ctx, "/v1/chat/completions", in.GetBody(), forceStream(false))
+}
+
+func (r *relayServer) Completions(
+	ctx context.Context,
+	in *relaypb.RelayRequest,
+) (*relaypb.RelayResponse, error) {
+	return r.s.unary(ctx, "/v1/completions", in.GetBody(), forceStream(false))
+}
+
+func (r *relayServer) Embeddings(
+	ctx context.Context,
+	in *relaypb.RelayRequest,
+) (*relaypb.RelayResponse, error) {
+	return r.s.unary(ctx, "/v1/embeddings", in.GetBody(), nil)
+}
+
+func (r *relayServer) StreamChatCompletions(
+	in *relaypb.RelayRequest,
+	stream grpc.ServerStreamingServer[relaypb.RelayResponse],
+) error {
+	return r.s.stream(stream, "/v1/chat/completions", in.GetBody())
+}
+
+func (r *relayServer) StreamCompletions(
+	in *relaypb.RelayRequest,
+	stream grpc.ServerStreamingServer[relaypb.RelayResponse],
+) error {
+	return r.s.stream(stream, "/v1/completions", in.GetBody())
+}
+
+// forceStream returns a patch to set the stream field of the request body
+func forceStream(stream bool) func([]byte) ([]byte, error) {
+	return func(body []byte) ([]byte, error) {
+		node, err := sonic.Get(body)
+		if err != nil {
+			return nil, err
+		}
+
+		if !stream && !node.Get("stream").Exists() {
+			return body, nil
+		}
+
+		if _, err := node.Set("stream", ast.NewBool(stream)); err != nil {
+			return nil, err
+		}
+
+		return node.MarshalJSON()
+	}
+}
+
+func (s *Server) unary(
+	ctx context.Context,
+	path string,
+	body []byte,
+	patch func([]byte) ([]byte, error),
+) (*relaypb.RelayResponse, error) {
+	if patch != nil {
+		patched, err := patch(body)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+		}
+
+		body = patched
+	}
+
+	w := newResponseWriter(nil)
+	s.serve(ctx, path, body, w)
+
+	_ = grpc.SetHeader(ctx, responseMetadata(w.header))
+
+	if w.status >= http.StatusBadRequest {
+		return nil, statusError(w.status, w.body.Bytes())
+	}
+
+	return &relaypb.RelayResponse{Body: w.body.Bytes()}, nil
+}
+
+func (s *Server) stream(
+	stream grpc.ServerStreamingServer[relaypb.RelayResponse],
+	path string,
+	body []byte,
+) error {
+	patched, err := forceStream(true)(body)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+	}
+
+	ctx := stream.Context()
+
+	var headerSent bool
+
+	w := newResponseWriter(nil)
+	w.send = func(m Message) error {
+		// the response headers, e.g. the request id, are sent before the first message
+		if !headerSent {
+			headerSent = true
+			_ = stream.SetHeader(responseMetadata(w.header))
+		}
+
+		return stream.Send(&relaypb.RelayResponse{Body: m})
+	}
+
+	s.serve(ctx, path, patched, w)
+
+	if w.status >= http.StatusBadRequest {
+		return statusError(w.status, w.body.Bytes())
+	}
+
+	return w.finish()
+}
+
+// serve relays the call to the http handler, the grpc metadata are passed as
+// the http headers, e.g. authorization
+func (s *Server) serve(ctx context.Context, path string, body []byte, w *responseWriter) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, path, bytes.NewReader(body))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+
+		return
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			if strings.HasPrefix(key, ":") ||
+				strings.HasPrefix(key, "grpc-") ||
+				key == "content-type" {
+				continue
+			}
+
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = int64(len(body))
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		req.RemoteAddr = p.Addr.String()
+	}
+
+	s.handler.ServeHTTP(w, req)
+}
+
+func responseMetadata(header http.Header) metadata.MD {
+	md := metadata.MD{}
+
+	for key, values := range header {
+		key = strings.ToLower(key)
+		if key == "content-type" || key == "content-length" {
+			continue
+		}
+
+		md.Append(key, values...)
+	}
+
+	return md
+}
+
+func statusCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden, http.StatusPaymentRequired:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// statusError converts the http error response to a grpc status,
+// the message is the error message of the openai error body
+func statusError(httpStatus int, body []byte) error {
+	message := strings.TrimSpace(string(body))
+	if m, err := sonic.Get(body, "error", "message"); err == nil {
+		if s, err := m.String(); err == nil && s != "" {
+			message = s
+		}
+	}
+
+	if message == "" {
+		message = http.StatusText(httpStatus)
+	}
+
+	return status.Error(statusCode(httpStatus), message)
+}
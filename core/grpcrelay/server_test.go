@@ -0,0 +1,107 @@
+//nolint:testpackage
+package grpcrelay
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/labring/aiproxy/core/grpcrelay/relaypb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T, handler http.Handler) relaypb.RelayClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(handler)
+
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return relaypb.NewRelayClient(conn)
+}
+
+func TestServerRelaysProtoAndJSONCalls(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"path":"` + r.URL.Path + `","auth":"` +
+			r.Header.Get("Authorization") + `","body":` + string(body) + `}`))
+	})
+
+	client := newTestClient(t, handler)
+	ctx := metadata.AppendToOutgoingContext(t.Context(), "authorization", "Bearer sk-test")
+
+	resp, err := client.Embeddings(ctx, &relaypb.RelayRequest{Body: []byte(`{"input":"hi"}`)})
+	require.NoError(t, err)
+	assert.JSONEq(
+		t,
+		`{"path":"/v1/embeddings","auth":"Bearer sk-test","body":{"input":"hi"}}`,
+		string(resp.GetBody()),
+	)
+
+	// the json codec is opt-in by the content subtype
+	resp, err = client.ChatCompletions(
+		ctx,
+		&relaypb.RelayRequest{Body: []byte(`{"model":"gpt-4o","stream":true}`)},
+		grpc.CallContentSubtype(CodecName),
+	)
+	require.NoError(t, err)
+	assert.JSONEq(
+		t,
+		`{"path":"/v1/chat/completions","auth":"Bearer sk-test","body":{"model":"gpt-4o","stream":false}}`,
+		string(resp.GetBody()),
+	)
+}
+
+func TestServerStreamsSSEChunks(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"id\":1}\n\ndata: {\"id\":2}\n\ndata: [DONE]\n\n"))
+	})
+
+	client := newTestClient(t, handler)
+
+	stream, err := client.StreamChatCompletions(
+		t.Context(),
+		&relaypb.RelayRequest{Body: []byte(`{"model":"gpt-4o"}`)},
+	)
+	require.NoError(t, err)
+
+	var chunks []string
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(t, err)
+
+		chunks = append(chunks, string(resp.GetBody()))
+	}
+
+	assert.Equal(t, []string{`{"id":1}`, `{"id":2}`}, chunks)
+}
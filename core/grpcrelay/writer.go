@@ -0,0 +1,111 @@
+package grpcrelay
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// responseWriter is the http.ResponseWriter of the relayed http request, the
+// body is buffered for unary calls, the sse data payloads are sent one by one
+// for streaming calls
+type responseWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	// send sends a sse data payload to the stream, nil for unary calls
+	send      func(Message) error
+	streaming bool
+}
+
+func newResponseWriter(send func(Message) error) *responseWriter {
+	return &responseWriter{
+		header: make(http.Header),
+		status: http.StatusOK,
+		send:   send,
+	}
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+	w.status = statusCode
+	w.streaming = w.send != nil &&
+		statusCode == http.StatusOK &&
+		strings.HasPrefix(w.header.Get("Content-Type"), "text/event-stream")
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	w.body.Write(b)
+
+	if w.streaming {
+		if err := w.sendEvents(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+func (w *responseWriter) Flush() {}
+
+// sendEvents sends the complete data lines of the buffered sse events,
+// the incomplete line is kept in the buffer
+func (w *responseWriter) sendEvents() error {
+	for {
+		data := w.body.Bytes()
+
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			return nil
+		}
+
+		line := bytes.TrimRight(data[:end], "\r")
+
+		payload, ok := bytes.CutPrefix(line, []byte("data:"))
+		if ok {
+			payload = bytes.TrimSpace(payload)
+			if len(payload) > 0 && !bytes.Equal(payload, []byte("[DONE]")) {
+				if err := w.send(Message(bytes.Clone(payload))); err != nil {
+					return err
+				}
+			}
+		}
+
+		w.body.Next(end + 1)
+	}
+}
+
+// finish sends the rest of the response, a non sse response is sent as one message
+func (w *responseWriter) finish() error {
+	if w.send == nil || w.status >= http.StatusBadRequest {
+		return nil
+	}
+
+	if w.streaming {
+		if w.body.Len() > 0 {
+			w.body.WriteByte('\n')
+			return w.sendEvents()
+		}
+
+		return nil
+	}
+
+	if w.body.Len() == 0 {
+		return nil
+	}
+
+	return w.send(Message(bytes.Clone(w.body.Bytes())))
+}
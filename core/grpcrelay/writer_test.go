@@ -0,0 +1,77 @@
+//nolint:testpackage
+package grpcrelay
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestResponseWriterSendsSSEData(t *testing.T) {
+	var messages []string
+
+	w := newResponseWriter(func(m Message) error {
+		messages = append(messages, string(m))
+		return nil
+	})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+
+	_, err := w.Write([]byte("data: {\"id\":1}\n\ndata: {\"id\""))
+	require.NoError(t, err)
+	_, err = w.Write([]byte(":2}\r\n\r\n: ping\n\ndata: [DONE]\n\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.finish())
+
+	assert.Equal(t, []string{`{"id":1}`, `{"id":2}`}, messages)
+}
+
+func TestResponseWriterSendsNonSSEBody(t *testing.T) {
+	var messages []string
+
+	w := newResponseWriter(func(m Message) error {
+		messages = append(messages, string(m))
+		return nil
+	})
+	w.Header().Set("Content-Type", "application/json")
+
+	_, err := w.Write([]byte(`{"id":1}`))
+	require.NoError(t, err)
+	require.NoError(t, w.finish())
+
+	assert.Equal(t, []string{`{"id":1}`}, messages)
+}
+
+func TestStatusError(t *testing.T) {
+	err := statusError(
+		http.StatusTooManyRequests,
+		[]byte(`{"error":{"message":"rate limit exceeded","type":"aiproxy_error"}}`),
+	)
+
+	s, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, codes.ResourceExhausted, s.Code())
+	assert.Equal(t, "rate limit exceeded", s.Message())
+
+	s, _ = status.FromError(statusError(http.StatusBadGateway, nil))
+	assert.Equal(t, codes.Unavailable, s.Code())
+	assert.Equal(t, http.StatusText(http.StatusBadGateway), s.Message())
+}
+
+func TestForceStream(t *testing.T) {
+	body, err := forceStream(true)([]byte(`{"model":"gpt-4o"}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"model":"gpt-4o","stream":true}`, string(body))
+
+	body, err = forceStream(false)([]byte(`{"model":"gpt-4o"}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"model":"gpt-4o"}`, string(body))
+
+	body, err = forceStream(false)([]byte(`{"model":"gpt-4o","stream":true}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"model":"gpt-4o","stream":false}`, string(body))
+}
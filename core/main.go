@@ -12,20 +12,40 @@ import (
 	"github.com/labring/aiproxy/core/common"
 	"github.com/labring/aiproxy/core/common/config"
 	"github.com/labring/aiproxy/core/common/consume"
-	"github.com/labring/aiproxy/core/controller"
+	"github.com/labring/aiproxy/core/common/mediastore"
+	"github.com/labring/aiproxy/core/grpcrelay"
+	"github.com/labring/aiproxy/core/middleware"
 	"github.com/labring/aiproxy/core/model"
 	"github.com/labring/aiproxy/core/task"
 	log "github.com/sirupsen/logrus"
 )
 
 var (
-	listen    string
-	pprofPort int
+	listen             string
+	adminListen        string
+	serverRole         string
+	grpcListen         string
+	pprofPort          int
+	encryptChannelKeys bool
 )
 
 func init() {
 	flag.StringVar(&listen, "listen", "0.0.0.0:3000", "http server listen")
+	flag.StringVar(
+		&adminListen,
+		"admin-listen",
+		"",
+		"admin api server listen, the admin api is served on the http server listen if empty",
+	)
+	flag.StringVar(&serverRole, "role", serverRoleAll, "server role: all, relay or admin")
+	flag.StringVar(&grpcListen, "grpc-listen", "", "grpc relay server listen, disabled if empty")
 	flag.IntVar(&pprofPort, "pprof-port", 15000, "pport http server port")
+	flag.BoolVar(
+		&encryptChannelKeys,
+		"encrypt-channel-keys",
+		false,
+		"encrypt the plaintext channel keys and configs with the configured secret encryption and exit",
+	)
 }
 
 // Swagger godoc
@@ -50,6 +70,14 @@ func main() {
 
 	printLoadedEnvFiles()
 
+	if encryptChannelKeys {
+		if err := runEncryptChannelKeys(); err != nil {
+			log.Fatal("failed to encrypt channel keys: " + err.Error())
+		}
+
+		return
+	}
+
 	if err := initializeServices(pprofPort); err != nil {
 		log.Fatal("failed to initialize services: " + err.Error())
 	}
@@ -66,7 +94,22 @@ func main() {
 	var wg sync.WaitGroup
 	startSyncServices(ctx, &wg)
 
-	srv, _ := setupHTTPServer(listen)
+	servers, engine, err := setupHTTPServers(serverRole, listen, adminListen)
+	if err != nil {
+		log.Fatal("failed to setup http servers: " + err.Error())
+	}
+
+	var (
+		grpcSrv  *grpcrelay.Server
+		grpcAddr string
+	)
+	if engine != nil {
+		grpcSrv, grpcAddr = setupGRPCServer(grpcListen, engine)
+	}
+
+	log.Info("task leader election started")
+
+	go task.LeaderElectionTask(ctx)
 
 	log.Info("auto test banned models task started")
 
@@ -76,6 +119,27 @@ func main() {
 
 	go task.CleanLogTask(ctx)
 
+	log.Info("purge archived task started")
+
+	go task.PurgeArchivedTask(ctx)
+
+	log.Info("summary rollup task started")
+
+	go task.SummaryRollupTask(ctx)
+
+	log.Info("invoice task started")
+
+	go task.InvoiceTask(ctx)
+
+	if config.ConfigSyncURL != "" {
+		log.Info("config sync task started")
+
+		go task.ConfigSyncTask(
+			ctx,
+			time.Duration(max(config.ConfigSyncIntervalSeconds, 1))*time.Second,
+		)
+	}
+
 	log.Info("detect ip groups task started")
 
 	go task.DetectIPGroupsTask(ctx)
@@ -84,6 +148,10 @@ func main() {
 
 	go task.UsageAlertTask(ctx)
 
+	log.Info("model deprecation alert task started")
+
+	go task.ModelDeprecationAlertTask(ctx)
+
 	log.Info("async usage poll task started")
 
 	go task.AsyncUsagePollTask(ctx)
@@ -92,11 +160,29 @@ func main() {
 		log.Info("redis health check task started")
 
 		go task.RedisHealthCheckTask(ctx)
+
+		log.Info("redis failover watch started")
+
+		go common.WatchRedis(ctx)
 	}
 
 	log.Info("update channels balance task started")
 
-	go controller.UpdateChannelsBalance(time.Minute * 10)
+	go task.UpdateChannelsBalanceTask(ctx, time.Minute*10)
+
+	log.Info("sync channels models task started")
+
+	go task.SyncChannelsModelsTask(ctx, time.Hour)
+
+	log.Info("probe channels readiness task started")
+
+	go task.ProbeChannelsReadinessTask(ctx, time.Second*15)
+
+	if store := mediastore.Default(); store != nil {
+		log.Info("clean media task started")
+
+		go task.CleanMediaTask(ctx, store, time.Minute*5)
+	}
 
 	batchProcessorCtx, batchProcessorCancel := context.WithCancel(context.Background())
 
@@ -104,23 +190,41 @@ func main() {
 
 	go model.StartBatchProcessorSummary(batchProcessorCtx, &wg)
 
-	log.Infof("server started on http://%s", srv.Addr)
-	log.Infof("swagger started on http://%s/swagger/index.html", srv.Addr)
+	for _, srv := range servers {
+		log.Infof("server started on http://%s", srv.Addr)
 
-	go listenAndServe(srv)
+		go listenAndServe(srv)
+	}
+
+	if grpcSrv != nil {
+		log.Infof("grpc relay server started on %s", grpcAddr)
+
+		go grpcListenAndServe(grpcSrv, grpcAddr)
+	}
 
 	<-ctx.Done()
 
-	shutdownSrvCtx, shutdownSrvCancel := context.WithTimeout(context.Background(), 600*time.Second)
+	drainTimeout := middleware.MaxRelayDrainTimeout()
+
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer drainCancel()
+
+	log.Info("draining relay requests...")
+	log.Infof("max wait time: %s", drainTimeout)
+
+	middleware.DrainRelayRequests(drainCtx)
+
+	shutdownSrvCtx, shutdownSrvCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownSrvCancel()
 
 	log.Info("shutting down http server...")
-	log.Info("max wait time: 600s")
+	log.Info("max wait time: 30s")
+
+	shutdownHTTPServers(shutdownSrvCtx, servers)
 
-	if err := srv.Shutdown(shutdownSrvCtx); err != nil {
-		log.Error("server forced to shutdown: " + err.Error())
-	} else {
-		log.Info("server shutdown successfully")
+	if grpcSrv != nil {
+		log.Info("shutting down grpc relay server...")
+		grpcSrv.GracefulStop()
 	}
 
 	log.Info("shutting down consumer...")
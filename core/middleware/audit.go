@@ -0,0 +1,355 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/model"
+)
+
+const (
+	// AuditActorHeader lets the admin console name the operator behind the
+	// shared admin key, it is asserted by the client so it is recorded as the
+	// claimed actor and never as the actor
+	AuditActorHeader   = "X-Aiproxy-Actor"
+	defaultAuditActor  = "admin"
+	maxAuditActorLen   = 64
+	maxAuditRequestLen = 64 * 1024
+	redactedAuditValue = "******"
+)
+
+// auditLoaders loads the current state of a resource by its route param,
+// resources without a loader only record the request body
+var auditLoaders = map[string]struct {
+	param string
+	load  func(id string) (any, error)
+}{
+	"channel": {
+		param: "id",
+		load: func(id string) (any, error) {
+			channelID, err := strconv.Atoi(id)
+			if err != nil {
+				return nil, err
+			}
+			return model.GetChannelByID(channelID)
+		},
+	},
+	"token": {
+		param: "id",
+		load: func(id string) (any, error) {
+			tokenID, err := strconv.Atoi(id)
+			if err != nil {
+				return nil, err
+			}
+			return model.GetTokenByID(tokenID)
+		},
+	},
+	"group": {
+		param: "group",
+		load: func(id string) (any, error) {
+			return model.GetGroupByID(id, true)
+		},
+	},
+	"option": {
+		param: "key",
+		load: func(id string) (any, error) {
+			return model.GetOption(id)
+		},
+	},
+	"model_config": {
+		param: "model",
+		load: func(id string) (any, error) {
+			return model.GetModelConfig(id)
+		},
+	},
+}
+
+// auditRedactFields are the fields of the resources holding secrets, e.g. the
+// channel configs hold the aws and vertex credentials
+var auditRedactFields = map[string]map[string]struct{}{
+	"channel": {
+		"key":     {},
+		"configs": {},
+	},
+	"token": {
+		"key": {},
+	},
+	// the sync spec carries the keys and the configs of its channels and the
+	// keys of its tokens
+	"config_sync": {
+		"key":     {},
+		"configs": {},
+	},
+	// the proxy headers and queries, the openapi authorization, the embed
+	// init values and the reusing params of the mcps hold the upstream secrets
+	"mcp": {
+		"headers":       {},
+		"querys":        {},
+		"authorization": {},
+		"init":          {},
+		"params":        {},
+		"test_config":   {},
+	},
+	"embedmcp": {
+		"init":        {},
+		"params":      {},
+		"test_config": {},
+	},
+}
+
+func isAuditMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// auditResource resolves the resource name from the route, e.g.
+// /api/channels/batch_delete -> channel, /api/token/:group/:id -> token
+func auditResource(fullPath string) string {
+	fullPath = strings.TrimPrefix(fullPath, "/api/")
+	resource, _, _ := strings.Cut(fullPath, "/")
+
+	return strings.TrimSuffix(resource, "s")
+}
+
+func auditResourceID(c *gin.Context, resource string) string {
+	if loader, ok := auditLoaders[resource]; ok {
+		return strings.TrimPrefix(c.Param(loader.param), "/")
+	}
+
+	if id := c.Param("id"); id != "" {
+		return id
+	}
+
+	return c.Param("group")
+}
+
+func auditAction(method, fullPath, before string) string {
+	switch {
+	case method == http.MethodDelete, strings.Contains(fullPath, "delete"):
+		return model.AuditActionDelete
+	case method == http.MethodPost && before == "":
+		return model.AuditActionCreate
+	default:
+		return model.AuditActionUpdate
+	}
+}
+
+func isEmptyAuditValue(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case map[string]any:
+		return len(v) == 0
+	case []any:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
+
+func redactAuditFields(v any, fields map[string]struct{}) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, value := range v {
+			if _, ok := fields[k]; ok {
+				if !isEmptyAuditValue(value) {
+					v[k] = redactedAuditValue
+				}
+
+				continue
+			}
+
+			v[k] = redactAuditFields(value, fields)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactAuditFields(value, fields)
+		}
+	}
+
+	return v
+}
+
+// redactAuditOptions redacts the secret options of a single option, e.g.
+// {"key":"AlertNotifiers","value":"..."}, and of a batch of options keyed by
+// their names, the value of an option updated by its route key is redacted as
+// a whole
+func redactAuditOptions(key string, v any) any {
+	if model.IsSecretOption(key) {
+		return redactedAuditValue
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		if name, ok := v["key"].(string); ok {
+			if model.IsSecretOption(name) && !isEmptyAuditValue(v["value"]) {
+				v["value"] = redactedAuditValue
+			}
+		}
+
+		for name := range v {
+			if model.IsSecretOption(name) {
+				v[name] = redactedAuditValue
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactAuditOptions("", value)
+		}
+	}
+
+	return v
+}
+
+func redactAuditValue(resource, resourceID string, v any) any {
+	if resource == "option" {
+		return redactAuditOptions(resourceID, v)
+	}
+
+	if fields, ok := auditRedactFields[resource]; ok {
+		return redactAuditFields(v, fields)
+	}
+
+	return v
+}
+
+func marshalAuditValue(resource, resourceID string, v any) string {
+	var node any
+
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		if len(v) == 0 || len(v) > maxAuditRequestLen {
+			return ""
+		}
+
+		if err := sonic.Unmarshal(v, &node); err != nil {
+			// a raw option value updated by its route key
+			if resource != "option" {
+				return ""
+			}
+
+			node = string(v)
+		}
+	default:
+		b, err := sonic.Marshal(v)
+		if err != nil {
+			return ""
+		}
+
+		if err := sonic.Unmarshal(b, &node); err != nil {
+			return ""
+		}
+	}
+
+	node = redactAuditValue(resource, resourceID, node)
+
+	s, err := sonic.MarshalString(node)
+	if err != nil {
+		return ""
+	}
+
+	return s
+}
+
+func loadAuditSnapshot(resource, id string) string {
+	loader, ok := auditLoaders[resource]
+	if !ok || id == "" {
+		return ""
+	}
+
+	v, err := loader.load(id)
+	if err != nil {
+		return ""
+	}
+
+	return marshalAuditValue(resource, id, v)
+}
+
+// GetAuditActor returns the authenticated principal of the request, the
+// holders of the admin key are recorded as admin
+func GetAuditActor(c *gin.Context) string {
+	if actor := c.GetString(AuditActor); actor != "" {
+		return common.TruncateByRune(actor, maxAuditActorLen)
+	}
+
+	return defaultAuditActor
+}
+
+// GetAuditClaimedActor returns the operator named by the client in the actor
+// header, it is not authenticated
+func GetAuditClaimedActor(c *gin.Context) string {
+	return common.TruncateByRune(c.GetHeader(AuditActorHeader), maxAuditActorLen)
+}
+
+// AdminAudit records every admin api mutation with the actor, the client ip
+// and the resource state before and after the request
+func AdminAudit(c *gin.Context) {
+	if !isAuditMethod(c.Request.Method) {
+		c.Next()
+		return
+	}
+
+	fullPath := c.FullPath()
+	resource := auditResource(fullPath)
+	resourceID := auditResourceID(c, resource)
+
+	var requestBody []byte
+	if c.ContentType() == gin.MIMEJSON {
+		body, err := common.GetRequestBody(c.Request)
+		if err == nil {
+			requestBody = body
+			common.SetRequestBody(c.Request, body)
+		}
+	}
+
+	before := loadAuditSnapshot(resource, resourceID)
+
+	c.Next()
+
+	code := c.Writer.Status()
+
+	var after string
+	if code < http.StatusBadRequest {
+		after = loadAuditSnapshot(resource, resourceID)
+	}
+
+	log := common.GetLogger(c)
+
+	diff, err := model.DiffAuditSnapshots(before, after)
+	if err != nil {
+		log.Errorf("failed to diff audit snapshots: %v", err)
+	}
+
+	err = model.RecordAuditLog(&model.AuditLog{
+		CreatedAt:    GetRequestAt(c),
+		Before:       before,
+		After:        after,
+		Diff:         diff,
+		Request:      marshalAuditValue(resource, resourceID, requestBody),
+		Actor:        GetAuditActor(c),
+		ClaimedActor: GetAuditClaimedActor(c),
+		Action:       auditAction(c.Request.Method, fullPath, before),
+		Resource:     resource,
+		ResourceID:   resourceID,
+		Method:       c.Request.Method,
+		Path:         c.Request.URL.Path,
+		IP:           c.ClientIP(),
+		RequestID:    GetRequestID(c),
+		Code:         code,
+	})
+	if err != nil {
+		log.Errorf("failed to record audit log: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+//nolint:testpackage
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditResource(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "channel", auditResource("/api/channel/:id/status"))
+	assert.Equal(t, "channel", auditResource("/api/channels/batch_delete"))
+	assert.Equal(t, "token", auditResource("/api/token/:group/:id"))
+	assert.Equal(t, "model_config", auditResource("/api/model_configs/"))
+	assert.Equal(t, "option", auditResource("/api/option/:key"))
+}
+
+func TestAuditAction(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, model.AuditActionCreate, auditAction(http.MethodPost, "/api/channel/", ""))
+	assert.Equal(
+		t,
+		model.AuditActionUpdate,
+		auditAction(http.MethodPost, "/api/channel/:id/status", `{"id":1}`),
+	)
+	assert.Equal(
+		t,
+		model.AuditActionDelete,
+		auditAction(http.MethodPost, "/api/channels/batch_delete", ""),
+	)
+	assert.Equal(t, model.AuditActionDelete, auditAction(http.MethodDelete, "/api/channel/:id", ""))
+	assert.Equal(t, model.AuditActionUpdate, auditAction(http.MethodPut, "/api/channel/:id", ""))
+}
+
+func TestMarshalAuditValueRedactsKeys(t *testing.T) {
+	t.Parallel()
+
+	value := marshalAuditValue(
+		"channel",
+		"",
+		[]byte(`[{"name":"a","key":"sk-secret","config":{"key":"nested"}},{"name":"b","key":""}]`),
+	)
+	assert.JSONEq(
+		t,
+		`[{"name":"a","key":"******","config":{"key":"******"}},{"name":"b","key":""}]`,
+		value,
+	)
+
+	value = marshalAuditValue(
+		"channel",
+		"1",
+		[]byte(`{"name":"a","configs":{"region":"us-east-1","ak":"id","sk":"secret"}}`),
+	)
+	assert.JSONEq(t, `{"name":"a","configs":"******"}`, value)
+
+	value = marshalAuditValue("option", "", []byte(`{"key":"LogDetailStorageHours","value":"1"}`))
+	assert.JSONEq(t, `{"key":"LogDetailStorageHours","value":"1"}`, value)
+
+	assert.Empty(t, marshalAuditValue("channel", "", []byte("not json")))
+
+	value = marshalAuditValue(
+		auditResource("/api/mcp/public/:id"),
+		"",
+		[]byte(`{"id":"m","proxy_config":{"url":"https://mcp.example.com","headers":{"Authorization":"Bearer s"}},`+
+			`"openapi_config":{"authorization":"Bearer s"},"embed_config":{"init":{"API_KEY":"s"}}}`),
+	)
+	assert.JSONEq(
+		t,
+		`{"id":"m","proxy_config":{"url":"https://mcp.example.com","headers":"******"},`+
+			`"openapi_config":{"authorization":"******"},"embed_config":{"init":"******"}}`,
+		value,
+	)
+}
+
+func TestMarshalAuditValueRedactsSecretOptions(t *testing.T) {
+	t.Parallel()
+
+	notifiers := `[{"name":"a","type":"webhook","url":"https://example.com","secret":"s"}]`
+
+	value := marshalAuditValue(
+		"option",
+		"",
+		[]byte(`{"key":"AlertNotifiers","value":`+strconv.Quote(notifiers)+`}`),
+	)
+	assert.JSONEq(t, `{"key":"AlertNotifiers","value":"******"}`, value)
+
+	value = marshalAuditValue(
+		"option",
+		"",
+		[]byte(`{"AlertNotifiers":`+strconv.Quote(notifiers)+`,"RetryTimes":"3"}`),
+	)
+	assert.JSONEq(t, `{"AlertNotifiers":"******","RetryTimes":"3"}`, value)
+
+	// the raw value of an option updated by its route key
+	value = marshalAuditValue("option", "AlertNotifiers", []byte(notifiers))
+	assert.JSONEq(t, `"******"`, value)
+
+	value = marshalAuditValue(
+		"option",
+		"AlertNotifiers",
+		&model.Option{Key: "AlertNotifiers", Value: notifiers},
+	)
+	assert.JSONEq(t, `"******"`, value)
+}
+
+func TestAdminAuditRedactsConfigSyncKeys(t *testing.T) {
+	oldDB := model.DB
+
+	db, err := model.OpenSQLite(filepath.Join(t.TempDir(), "audit_test.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.AuditLog{}))
+
+	model.DB = db
+
+	t.Cleanup(func() {
+		model.DB = oldDB
+
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		require.NoError(t, sqlDB.Close())
+	})
+
+	router := gin.New()
+	router.POST("/api/config_sync/", AdminAudit, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	body := `{
+		"channels":[{"name":"a","type":1,"key":"sk-channel-secret","configs":{"sk":"aws-secret"}}],
+		"tokens":[{"name":"t","group":"g","key":"sk-token-secret"}]
+	}`
+
+	req := httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"/api/config_sync/",
+		strings.NewReader(body),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var logs []model.AuditLog
+	require.NoError(t, db.Find(&logs).Error)
+	require.Len(t, logs, 1)
+
+	assert.Equal(t, "config_sync", logs[0].Resource)
+	assert.NotContains(t, logs[0].Request, "sk-channel-secret")
+	assert.NotContains(t, logs[0].Request, "aws-secret")
+	assert.NotContains(t, logs[0].Request, "sk-token-secret")
+	assert.JSONEq(
+		t,
+		`{
+			"channels":[{"name":"a","type":1,"key":"******","configs":"******"}],
+			"tokens":[{"name":"t","group":"g","key":"******"}]
+		}`,
+		logs[0].Request,
+	)
+}
+
+func TestGetAuditActor(t *testing.T) {
+	t.Parallel()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/api/channel/", nil)
+	c.Request.Header.Set(AuditActorHeader, strings.Repeat("a", 100))
+
+	// the header is only recorded as the claimed actor
+	assert.Equal(t, defaultAuditActor, GetAuditActor(c))
+	assert.Equal(t, strings.Repeat("a", maxAuditActorLen), GetAuditClaimedActor(c))
+
+	c.Set(AuditActor, "oidc:"+strings.Repeat("b", 100))
+	assert.Len(t, GetAuditActor(c), maxAuditActorLen)
+}
+
+func TestDiffAuditSnapshots(t *testing.T) {
+	t.Parallel()
+
+	diff, err := model.DiffAuditSnapshots(
+		`{"name":"a","priority":1,"models":["gpt-4o"]}`,
+		`{"name":"a","priority":2,"models":["gpt-4o"],"status":1}`,
+	)
+	require.NoError(t, err)
+	assert.JSONEq(
+		t,
+		`{"priority":{"before":1,"after":2},"status":{"before":null,"after":1}}`,
+		diff,
+	)
+
+	diff, err = model.DiffAuditSnapshots(`{"name":"a"}`, `{"name":"a"}`)
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+
+	diff, err = model.DiffAuditSnapshots(`{"name":"a"}`, "")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":{"before":"a","after":null}}`, diff)
+}
@@ -10,7 +10,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/labring/aiproxy/core/common"
 	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/common/geoip"
 	"github.com/labring/aiproxy/core/common/network"
+	"github.com/labring/aiproxy/core/common/oidc"
 	"github.com/labring/aiproxy/core/common/oncall"
 	"github.com/labring/aiproxy/core/model"
 	"github.com/labring/aiproxy/core/relay/meta"
@@ -39,7 +41,7 @@ func ErrorResponse(c *gin.Context, code int, message string) {
 }
 
 func AdminAuth(c *gin.Context) {
-	if config.AdminKey == "" {
+	if config.AdminKey == "" && !config.OIDCEnabled() {
 		ErrorResponse(c, http.StatusUnauthorized, "unauthorized, admin key is not set")
 		c.Abort()
 		return
@@ -51,9 +53,31 @@ func AdminAuth(c *gin.Context) {
 	}
 
 	accessToken = strings.TrimPrefix(accessToken, "Bearer ")
-	accessToken = strings.TrimPrefix(accessToken, "sk-")
 
-	if accessToken != config.AdminKey {
+	switch {
+	case config.AdminKey != "" && strings.TrimPrefix(accessToken, "sk-") == config.AdminKey:
+		c.Set(AdminRole, oidc.RoleAdmin)
+	case config.OIDCEnabled() && oidc.IsAdminToken(accessToken):
+		claims, err := oidc.ParseAdminToken(accessToken)
+		if err != nil {
+			ErrorResponse(c, http.StatusUnauthorized, "unauthorized, invalid admin token: "+err.Error())
+			c.Abort()
+			return
+		}
+
+		if claims.Role != oidc.RoleAdmin && !isViewerRoute(c) {
+			ErrorResponse(
+				c,
+				http.StatusForbidden,
+				fmt.Sprintf("forbidden, role %s is read-only", claims.Role),
+			)
+			c.Abort()
+			return
+		}
+
+		c.Set(AdminRole, claims.Role)
+		c.Set(AuditActor, claims.Actor())
+	default:
 		ErrorResponse(c, http.StatusUnauthorized, "unauthorized, no access token provided")
 		c.Abort()
 		return
@@ -72,6 +96,59 @@ func AdminAuth(c *gin.Context) {
 	c.Next()
 }
 
+// IsAdminRole reports whether the admin api caller has full access, callers
+// with a lower role must not see the channel and token keys
+func IsAdminRole(c *gin.Context) bool {
+	role, _ := c.Get(AdminRole)
+	return role == oidc.RoleAdmin
+}
+
+// GeoClientIP returns the client ip located by the geoip, the forwarded headers
+// are only honored with TRUSTED_PROXIES set, the peer address is used otherwise
+// so a forged X-Forwarded-For can not pass the token regions or the geo routing
+func GeoClientIP(c *gin.Context) string {
+	if len(config.TrustedProxies) == 0 {
+		return c.RemoteIP()
+	}
+
+	return c.ClientIP()
+}
+
+// checkTokenRegions rejects clients outside the regions of the token,
+// clients with an unknown location are rejected as well
+func checkTokenRegions(c *gin.Context, token model.TokenCache) bool {
+	if len(token.Regions) == 0 {
+		return true
+	}
+
+	clientIP := GeoClientIP(c)
+
+	location := geoip.Lookup(clientIP)
+	if location.Match(token.Regions) {
+		return true
+	}
+
+	region := location.Country
+	if region == "" {
+		region = "unknown"
+	}
+
+	AbortLogWithMessage(
+		c,
+		http.StatusForbidden,
+		fmt.Sprintf(
+			"token (%s[%d]) can only be used in the specified regions: %v, current ip: %s, region: %s",
+			token.Name,
+			token.ID,
+			token.Regions,
+			clientIP,
+			region,
+		),
+	)
+
+	return false
+}
+
 func TokenAuth(c *gin.Context) {
 	log := common.GetLogger(c)
 
@@ -137,6 +214,14 @@ func TokenAuth(c *gin.Context) {
 		}
 	}
 
+	if !checkTokenRegions(c, token) {
+		return
+	}
+
+	if !checkTokenOrigin(c, token) {
+		return
+	}
+
 	modelCaches := model.LoadModelCaches()
 
 	var group model.GroupCache
@@ -209,6 +294,7 @@ func GetModelCaches(c *gin.Context) *model.ModelCaches {
 func SetLogFieldsFromMeta(m *meta.Meta, fields logrus.Fields) {
 	SetLogServiceTier(fields, m.RequestServiceTier)
 	SetLogPromptCacheKey(fields, m.PromptCacheKey)
+	SetLogRequestSeed(fields, m.Seed)
 	SetLogRequestUser(fields, m.User)
 
 	SetLogRequestIDField(fields, m.RequestID)
@@ -238,6 +324,14 @@ func SetLogPromptCacheKey(fields logrus.Fields, promptCacheKey string) {
 	fields["prompt_cache_key"] = promptCacheKey
 }
 
+func SetLogRequestSeed(fields logrus.Fields, seed *int64) {
+	if seed == nil {
+		return
+	}
+
+	fields["seed"] = *seed
+}
+
 func SetLogRequestUser(fields logrus.Fields, user string) {
 	if user == "" {
 		return
@@ -293,7 +387,7 @@ func SetLogTokenFields(fields logrus.Fields, token model.TokenCache, internal bo
 	}
 
 	if token.Key != "" {
-		fields["key"] = maskTokenKey(token.Key)
+		fields["key"] = MaskKey(token.Key)
 	}
 
 	if internal {
@@ -301,7 +395,8 @@ func SetLogTokenFields(fields logrus.Fields, token model.TokenCache, internal bo
 	}
 }
 
-func maskTokenKey(key string) string {
+// MaskKey keeps the first and last four characters of a key
+func MaskKey(key string) string {
 	if len(key) <= 8 {
 		return "*****"
 	}
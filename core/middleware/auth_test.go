@@ -0,0 +1,101 @@
+//nolint:testpackage
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/common/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminAuthViewerRoutes(t *testing.T) {
+	config.OIDCIssuer = "https://idp.example.com"
+	config.OIDCClientID = "aiproxy"
+	config.OIDCRedirectURL = "https://aiproxy.example.com/api/oidc/callback"
+	config.AdminJWTSecret = "test-secret"
+	config.AdminJWTTTLSeconds = 60
+
+	t.Cleanup(func() {
+		config.OIDCIssuer = ""
+		config.OIDCClientID = ""
+		config.OIDCRedirectURL = ""
+		config.AdminJWTSecret = ""
+	})
+
+	token, _, err := oidc.IssueAdminToken("user-1", "", "", oidc.RoleViewer)
+	require.NoError(t, err)
+
+	router := gin.New()
+	api := router.Group("/api", AdminAuth)
+	handler := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"admin": IsAdminRole(c)})
+	}
+
+	api.GET("/channels/", handler)
+	api.GET("/channels/test", handler)
+	api.GET("/channel/:id/update_balance", handler)
+	api.GET("/option/", handler)
+	api.POST("/channels/", handler)
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodGet, "/api/channels/", http.StatusOK},
+		{http.MethodGet, "/api/channels/test", http.StatusForbidden},
+		{http.MethodGet, "/api/channel/1/update_balance", http.StatusForbidden},
+		{http.MethodGet, "/api/option/", http.StatusForbidden},
+		{http.MethodPost, "/api/channels/", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequestWithContext(t.Context(), tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+
+			if tt.want == http.StatusOK {
+				assert.JSONEq(t, `{"admin":false}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGeoClientIPIgnoresForwardedHeadersWithoutTrustedProxies(t *testing.T) {
+	oldTrustedProxies := config.TrustedProxies
+
+	t.Cleanup(func() {
+		config.TrustedProxies = oldTrustedProxies
+	})
+
+	newContext := func() *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequestWithContext(
+			t.Context(),
+			http.MethodPost,
+			"/v1/chat/completions",
+			nil,
+		)
+		c.Request.RemoteAddr = "203.0.113.1:1234"
+		c.Request.Header.Set("X-Forwarded-For", "1.2.3.4")
+
+		return c
+	}
+
+	config.TrustedProxies = nil
+	assert.Equal(t, "203.0.113.1", GeoClientIP(newContext()))
+
+	// the engine of the server only trusts the headers of the trusted proxies
+	config.TrustedProxies = []string{"203.0.113.0/24"}
+	assert.Equal(t, "1.2.3.4", GeoClientIP(newContext()))
+}
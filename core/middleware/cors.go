@@ -1,8 +1,14 @@
 package middleware
 
 import (
+	"fmt"
+	"net/http"
+	"time"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/network"
+	"github.com/labring/aiproxy/core/model"
 )
 
 func CORS() gin.HandlerFunc {
@@ -10,7 +16,65 @@ func CORS() gin.HandlerFunc {
 	config.AllowAllOrigins = true
 	config.AllowCredentials = true
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"*"}
+	// the wildcard does not cover the Authorization header of the preflight
+	// requests, the key headers are listed explicitly
+	config.AllowHeaders = []string{"*", "Authorization", "X-Api-Key", "X-Goog-Api-Key"}
+	config.MaxAge = 12 * time.Hour
 
 	return cors.New(config)
 }
+
+// checkTokenOrigin enforces the origins of the token on the browser requests,
+// the preflight requests carry no key and are answered by CORS for all the
+// origins, so the origin is checked on the actual request and the allowed
+// origin is echoed instead of `*`
+func checkTokenOrigin(c *gin.Context, token model.TokenCache) bool {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if token.ServerOnly {
+		c.Header("Access-Control-Allow-Origin", "")
+
+		AbortLogWithMessage(
+			c,
+			http.StatusForbidden,
+			fmt.Sprintf(
+				"token (%s[%d]) is server only and can not be used from browsers, origin: %s",
+				token.Name,
+				token.ID,
+				origin,
+			),
+		)
+
+		return false
+	}
+
+	if len(token.AllowedOrigins) == 0 {
+		return true
+	}
+
+	if !network.IsOriginAllowed(origin, token.AllowedOrigins) {
+		c.Header("Access-Control-Allow-Origin", "")
+
+		AbortLogWithMessage(
+			c,
+			http.StatusForbidden,
+			fmt.Sprintf(
+				"token (%s[%d]) can only be used from the specified origins: %v, current origin: %s",
+				token.Name,
+				token.ID,
+				token.AllowedOrigins,
+				origin,
+			),
+		)
+
+		return false
+	}
+
+	c.Header("Access-Control-Allow-Origin", origin)
+	c.Writer.Header().Add("Vary", "Origin")
+
+	return true
+}
@@ -0,0 +1,117 @@
+//nolint:testpackage
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTokenOriginRouter(token model.TokenCache) *gin.Engine {
+	router := gin.New()
+	router.Use(CORS())
+	router.POST("/v1/chat/completions", func(c *gin.Context) {
+		if !checkTokenOrigin(c, token) {
+			return
+		}
+
+		c.Status(http.StatusOK)
+	})
+
+	return router
+}
+
+func TestCheckTokenOrigin(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		token         model.TokenCache
+		origin        string
+		expectedCode  int
+		expectedAllow string
+	}{
+		{
+			name:         "server request",
+			token:        model.TokenCache{ServerOnly: true},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:          "no origins",
+			token:         model.TokenCache{},
+			origin:        "https://app.example.com",
+			expectedCode:  http.StatusOK,
+			expectedAllow: "*",
+		},
+		{
+			name: "allowed origin",
+			token: model.TokenCache{
+				AllowedOrigins: []string{"https://app.example.com"},
+			},
+			origin:        "https://app.example.com",
+			expectedCode:  http.StatusOK,
+			expectedAllow: "https://app.example.com",
+		},
+		{
+			name: "disallowed origin",
+			token: model.TokenCache{
+				AllowedOrigins: []string{"https://app.example.com"},
+			},
+			origin:       "https://evil.example.com",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "server only",
+			token:        model.TokenCache{ServerOnly: true},
+			origin:       "https://app.example.com",
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequestWithContext(
+				t.Context(),
+				http.MethodPost,
+				"/v1/chat/completions",
+				nil,
+			)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			w := httptest.NewRecorder()
+			newTokenOriginRouter(tt.token).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Equal(t, tt.expectedAllow, w.Header().Get("Access-Control-Allow-Origin"))
+		})
+	}
+}
+
+func TestCORSPreflightAllowsKeyHeaders(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodOptions,
+		"/v1/chat/completions",
+		nil,
+	)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "authorization,content-type")
+
+	w := httptest.NewRecorder()
+	newTokenOriginRouter(model.TokenCache{ServerOnly: true}).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+	assert.NotEmpty(t, w.Header().Get("Access-Control-Max-Age"))
+}
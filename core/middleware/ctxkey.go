@@ -6,9 +6,11 @@ const (
 	Token              = "token"
 	GroupBalance       = "group_balance"
 	RequestModel       = "request_model"
+	RequestModelAlias  = "request_model_alias"
 	RequestUser        = "request_user"
 	RequestMetadata    = "request_metadata"
 	PromptCacheKey     = "prompt_cache_key"
+	RequestSeed        = "request_seed"
 	RequestServiceTier = "request_service_tier"
 	RequestAt          = "request_at"
 	RequestID          = "request_id"
@@ -21,6 +23,10 @@ const (
 	ResponseID         = "response_id"
 	VideoID            = "video_id"
 	FileID             = "file_id"
+	AuditActor         = "audit_actor"
+	AdminRole          = "admin_role"
+	GroupModelTPM      = "group_model_tpm"
+	FeatureFlags       = "feature_flags"
 
 	requestBodyNode = "request_body_node"
 )
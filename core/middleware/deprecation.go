@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/model"
+)
+
+const (
+	// XAiproxyModelReplacement is set for the deprecated models with a
+	// replacement, the value is the replacement model
+	XAiproxyModelReplacement = "X-Aiproxy-Model-Replacement"
+	// XAiproxyModelSunsetFrom is set when the request of a sunset model is
+	// routed to its replacement, the value is the requested model
+	XAiproxyModelSunsetFrom = "X-Aiproxy-Model-Sunset-From"
+	// maxSunsetReplacements limits the replacement chain of the sunset models
+	maxSunsetReplacements = 3
+)
+
+// resolveSunsetModel follows the replacements of the sunset models, the
+// replacement must be accessible by the token, the sunset model is kept when
+// no replacement is available
+func resolveSunsetModel(
+	modelName string,
+	getModelConfig func(modelName string) (model.ModelConfig, bool),
+	findModel func(modelName string) string,
+	now time.Time,
+) string {
+	for range maxSunsetReplacements {
+		mc, ok := getModelConfig(modelName)
+		if !ok || !mc.IsSunset(now) || mc.ReplacementModel == "" {
+			return modelName
+		}
+
+		replacement := findModel(mc.ReplacementModel)
+		if replacement == "" {
+			return modelName
+		}
+
+		modelName = replacement
+	}
+
+	return modelName
+}
+
+// setModelDeprecationHeaders sets the Deprecation and Sunset headers of the
+// deprecated model
+func setModelDeprecationHeaders(c *gin.Context, mc model.ModelConfig) {
+	if !mc.IsDeprecated() {
+		return
+	}
+
+	c.Header("Deprecation", "true")
+
+	if mc.SunsetAt != nil {
+		c.Header("Sunset", mc.SunsetAt.UTC().Format(http.TimeFormat))
+	}
+
+	if mc.ReplacementModel != "" {
+		c.Header(XAiproxyModelReplacement, mc.ReplacementModel)
+	}
+}
+
+// applyModelSunset sets the deprecation headers of the requested model and
+// routes the request of the sunset model to its replacement
+func applyModelSunset(c *gin.Context, token model.TokenCache, modelName string) string {
+	modelConfigs := GetModelCaches(c).ModelConfig
+
+	if mc, ok := modelConfigs.GetModelConfig(modelName); ok {
+		setModelDeprecationHeaders(c, mc)
+	}
+
+	resolved := resolveSunsetModel(
+		modelName,
+		modelConfigs.GetModelConfig,
+		token.FindModel,
+		time.Now(),
+	)
+	if resolved != modelName {
+		c.Header(XAiproxyModelSunsetFrom, modelName)
+	}
+
+	return resolved
+}
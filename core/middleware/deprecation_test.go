@@ -0,0 +1,89 @@
+//nolint:testpackage
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveSunsetModel(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	configs := map[string]model.ModelConfig{
+		"old":     {Model: "old", SunsetAt: &past, ReplacementModel: "mid"},
+		"mid":     {Model: "mid", SunsetAt: &past, ReplacementModel: "new"},
+		"new":     {Model: "new"},
+		"soon":    {Model: "soon", SunsetAt: &future, ReplacementModel: "new"},
+		"orphan":  {Model: "orphan", SunsetAt: &past},
+		"private": {Model: "private", SunsetAt: &past, ReplacementModel: "hidden"},
+	}
+	getModelConfig := func(modelName string) (model.ModelConfig, bool) {
+		mc, ok := configs[modelName]
+		return mc, ok
+	}
+	findModel := func(modelName string) string {
+		if modelName == "hidden" {
+			return ""
+		}
+
+		return modelName
+	}
+
+	tests := []struct {
+		name     string
+		model    string
+		expected string
+	}{
+		{name: "follows the replacement chain", model: "old", expected: "new"},
+		{name: "keeps the model before the sunset", model: "soon", expected: "soon"},
+		{name: "keeps the model without replacement", model: "orphan", expected: "orphan"},
+		{name: "keeps the model with an inaccessible replacement", model: "private", expected: "private"},
+		{name: "keeps the unknown model", model: "unknown", expected: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(
+				t,
+				tt.expected,
+				resolveSunsetModel(tt.model, getModelConfig, findModel, now),
+			)
+		})
+	}
+}
+
+func TestSetModelDeprecationHeaders(t *testing.T) {
+	t.Parallel()
+
+	sunsetAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	setModelDeprecationHeaders(c, model.ModelConfig{
+		Model:            "old",
+		SunsetAt:         &sunsetAt,
+		ReplacementModel: "new",
+	})
+
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Equal(t, sunsetAt.Format(http.TimeFormat), w.Header().Get("Sunset"))
+	assert.Equal(t, "new", w.Header().Get(XAiproxyModelReplacement))
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	setModelDeprecationHeaders(c, model.ModelConfig{Model: "current"})
+
+	assert.Empty(t, w.Header().Get("Deprecation"))
+}
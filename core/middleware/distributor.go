@@ -85,6 +85,9 @@ func GetGroupAdjustedModelConfig(group model.GroupCache, mc model.ModelConfig) m
 var (
 	ErrRequestRateLimitExceeded = errors.New("request rate limit exceeded, please try again later")
 	ErrRequestTpmLimitExceeded  = errors.New("request tpm limit exceeded, please try again later")
+	ErrRequestTokensExceedTpm   = errors.New(
+		"request tokens exceed the remaining tpm limit, please try again later",
+	)
 )
 
 const (
@@ -183,11 +186,37 @@ func checkGroupModelRPMAndTPM(
 		}
 
 		setTpmHeaders(c, mc.TPM, mc.TPM-groupModelCountTPM)
+		c.Set(GroupModelTPM, groupModelTPM{limit: mc.TPM, used: groupModelCountTPM})
 	}
 
 	return nil
 }
 
+type groupModelTPM struct {
+	limit int64
+	used  int64
+}
+
+// CheckRequestTPM rejects the request whose estimated input tokens, including
+// the image tokens, exceed the tokens left in the tpm limit of the group, a
+// request larger than the whole limit is still admitted when no tokens are used
+func CheckRequestTPM(c *gin.Context, inputTokens int64) error {
+	v, ok := c.Get(GroupModelTPM)
+	if !ok {
+		return nil
+	}
+
+	tpm, ok := v.(groupModelTPM)
+	if !ok || tpm.used <= 0 || tpm.used+inputTokens <= tpm.limit {
+		return nil
+	}
+
+	common.GetLogger(c).Data["request_input_tokens"] = inputTokens
+	setTpmHeaders(c, tpm.limit, max(0, tpm.limit-tpm.used))
+
+	return ErrRequestTokensExceedTpm
+}
+
 type GroupBalanceConsumer struct {
 	Group        string
 	balance      float64
@@ -256,6 +285,51 @@ const (
 	GroupMinimumBalance   = 0.3
 )
 
+// checkGroupFileStorageQuota rejects the file uploads exceeding the storage
+// quota of the group
+func checkGroupFileStorageQuota(c *gin.Context, group model.GroupCache, m mode.Mode) bool {
+	if m != mode.Files || group.FileStorageQuota <= 0 {
+		return true
+	}
+
+	var size int64
+	if c.Request.MultipartForm != nil {
+		for _, files := range c.Request.MultipartForm.File {
+			for _, file := range files {
+				size += file.Size
+			}
+		}
+	}
+
+	used, err := model.GetGroupFileStorageUsage(group.ID)
+	if err != nil {
+		AbortLogWithMessage(
+			c,
+			http.StatusInternalServerError,
+			fmt.Sprintf("get group `%s` file storage usage error", group.ID),
+		)
+
+		return false
+	}
+
+	if used+size > group.FileStorageQuota {
+		AbortLogWithMessage(
+			c,
+			http.StatusRequestEntityTooLarge,
+			fmt.Sprintf(
+				"file storage quota exceeded: used %d of %d bytes, upload is %d bytes",
+				used,
+				group.FileStorageQuota,
+				size,
+			),
+		)
+
+		return false
+	}
+
+	return true
+}
+
 func checkGroupBalance(c *gin.Context, group model.GroupCache) bool {
 	gbc, err := GetGroupBalanceConsumer(c, group)
 	if err != nil {
@@ -333,6 +407,10 @@ func CheckRelayMode(requestMode, modelMode mode.Mode) bool {
 		return modelMode == mode.GeminiVideo
 	case mode.GeminiFiles:
 		return containsMode(mode.Gemini, mode.GeminiFiles, mode.GeminiVideo)
+	case mode.GeminiCachedContents:
+		return containsMode(mode.ChatCompletions, mode.Gemini, mode.GeminiCachedContents)
+	case mode.GeminiLive:
+		return containsMode(mode.ChatCompletions, mode.Gemini, mode.GeminiLive)
 	case mode.GeminiVideoOperations:
 		return containsMode(mode.GeminiVideo, mode.GeminiVideoOperations)
 	case mode.AliVideo:
@@ -345,6 +423,11 @@ func CheckRelayMode(requestMode, modelMode mode.Mode) bool {
 		return containsMode(mode.DoubaoVideo, mode.DoubaoVideoTasks, mode.DoubaoVideoTasksDelete)
 	case mode.AudioSpeech:
 		return containsMode(mode.AudioSpeech, mode.GeminiTTS)
+	case mode.AudioGenerationsGet:
+		return containsMode(mode.AudioGenerations, mode.AudioGenerationsGet)
+	case mode.Files, mode.FilesGet, mode.FilesContent, mode.FilesDelete:
+		// the model only selects the channel storing the files
+		return true
 	case mode.ChatCompletions, mode.Anthropic, mode.Gemini:
 		return containsMode(
 			mode.ChatCompletions,
@@ -455,6 +538,22 @@ func distribute(c *gin.Context, mode mode.Mode) {
 		return
 	}
 
+	resolveFeatureFlags(c, group)
+
+	promptTemplate, statusCode, err := applyPromptTemplate(c, mode, group)
+	if err != nil {
+		AbortLogWithMessage(c, statusCode, err.Error())
+		return
+	}
+
+	if promptTemplate != nil {
+		log.Data["prompt_template"] = fmt.Sprintf("%s@%d", promptTemplate.Name, promptTemplate.Version)
+	}
+
+	if rejectWhileDraining(c, mode) {
+		return
+	}
+
 	requestModel, err := getRequestModel(c, mode, group.ID, token.ID)
 	if err != nil {
 		AbortLogWithMessage(
@@ -471,6 +570,39 @@ func distribute(c *gin.Context, mode mode.Mode) {
 		return
 	}
 
+	if !checkGroupFileStorageQuota(c, group, mode) {
+		return
+	}
+
+	// the group alias is resolved before the model access and the channel
+	// selection, only the responses keep reporting the alias
+	var modelAlias string
+	if aliasModel := resolveGroupModelAlias(group, requestModel); aliasModel != "" {
+		modelAlias = requestModel
+		requestModel = aliasModel
+		log.Data["model_alias"] = modelAlias
+	}
+
+	// the router models pick the backend model by the prompt, the responses
+	// keep reporting the model the client requested
+	var routerModel string
+
+	backendModel, routerLanguage, err := resolveRouterModel(c, mode, group.ID, requestModel)
+	if err != nil {
+		AbortLogWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if backendModel != "" {
+		routerModel = requestModel
+		requestModel = backendModel
+		log.Data["router_model"] = routerModel
+	}
+
+	if routerLanguage != "" {
+		log.Data["prompt_language"] = routerLanguage
+	}
+
 	findModel := token.FindModel(requestModel)
 
 	if findModel == "" {
@@ -486,6 +618,27 @@ func distribute(c *gin.Context, mode mode.Mode) {
 		return
 	}
 
+	if sunsetModel := applyModelSunset(c, token, findModel); sunsetModel != findModel {
+		log.Data["sunset_from"] = findModel
+		findModel = sunsetModel
+	}
+
+	cappedModel, err := applySpendCap(c, group, token, findModel)
+	if err != nil {
+		if errors.Is(err, ErrSpendCapExceeded) {
+			AbortLogWithMessage(c, http.StatusTooManyRequests, err.Error())
+		} else {
+			AbortLogWithMessage(c, http.StatusInternalServerError, err.Error())
+		}
+
+		return
+	}
+
+	if cappedModel != findModel {
+		log.Data["downgraded_from"] = findModel
+		findModel = cappedModel
+	}
+
 	SetLogModelFields(log.Data, findModel)
 
 	mc, ok := GetModelCaches(c).ModelConfig.GetModelConfig(findModel)
@@ -548,6 +701,22 @@ func distribute(c *gin.Context, mode mode.Mode) {
 	c.Set(PromptCacheKey, promptCacheKey)
 	SetLogPromptCacheKey(log.Data, promptCacheKey)
 
+	seed, err := getRequestSeed(c, mode)
+	if err != nil {
+		AbortLogWithMessage(
+			c,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+
+		return
+	}
+
+	if seed != nil {
+		c.Set(RequestSeed, seed)
+		SetLogRequestSeed(log.Data, seed)
+	}
+
 	requestServiceTier, err := getRequestServiceTier(c, mode)
 	if err != nil {
 		AbortLogWithMessage(
@@ -573,7 +742,8 @@ func distribute(c *gin.Context, mode mode.Mode) {
 		return
 	}
 
-	c.Set(RequestMetadata, metadata)
+	metadata = setPromptTemplateMetadata(metadata, promptTemplate)
+	c.Set(RequestMetadata, setRouterLanguageMetadata(metadata, routerLanguage))
 
 	if err := checkGroupModelRPMAndTPM(c, group, mc, token.Name); err != nil {
 		errMsg := err.Error()
@@ -592,6 +762,25 @@ func distribute(c *gin.Context, mode mode.Mode) {
 		return
 	}
 
+	release, ok := acquireFairShare(c, group)
+	if !ok {
+		return
+	}
+
+	defer release()
+
+	done := trackRelayRequest(c, mode)
+	defer done()
+
+	switch {
+	case modelAlias != "":
+		c.Set(RequestModelAlias, modelAlias)
+		c.Writer = newModelAliasResponseWriter(c.Writer, modelAlias)
+	case routerModel != "":
+		c.Header(XAiproxyRoutedModel, findModel)
+		c.Writer = newModelAliasResponseWriter(c.Writer, routerModel)
+	}
+
 	clearRequestBodyNode(c)
 	c.Next()
 }
@@ -608,6 +797,13 @@ func GetPromptCacheKey(c *gin.Context) string {
 	return c.GetString(PromptCacheKey)
 }
 
+func GetRequestSeed(c *gin.Context) *int64 {
+	v, _ := c.Get(RequestSeed)
+	seed, _ := v.(*int64)
+
+	return seed
+}
+
 func GetChannelID(c *gin.Context) int {
 	return c.GetInt(ChannelID)
 }
@@ -667,6 +863,7 @@ func NewMetaByContext(c *gin.Context,
 	videoID := GetVideoID(c)
 	fileID := GetFileID(c)
 	promptCacheKey := GetPromptCacheKey(c)
+	seed := GetRequestSeed(c)
 	user := GetRequestUser(c)
 	requestServiceTier := GetRequestServiceTier(c)
 
@@ -684,8 +881,10 @@ func NewMetaByContext(c *gin.Context,
 		meta.WithVideoID(videoID),
 		meta.WithFileID(fileID),
 		meta.WithPromptCacheKey(promptCacheKey),
+		meta.WithSeed(seed),
 		meta.WithUser(user),
 		meta.WithRequestServiceTier(requestServiceTier),
+		meta.WithFeatureFlags(GetFeatureFlags(c)),
 	)
 
 	return meta.NewMeta(
@@ -815,8 +1014,28 @@ func getRequestModel(c *gin.Context, m mode.Mode, group string, tokenID int) (st
 		return store.Model, nil
 	case isVideosStoredMode(m):
 		return getStoredVideoRequestModel(c, group, tokenID)
+	case m == mode.AudioGenerationsGet:
+		generationID := c.Param("id")
+
+		store, err := model.CacheGetStore(
+			group,
+			tokenID,
+			model.AudioGenerationStoreID(generationID),
+		)
+		if err != nil {
+			return "", fmt.Errorf("get request model failed: %w", err)
+		}
+
+		c.Set(GenerationID, generationID)
+		c.Set(ChannelID, store.ChannelID)
+
+		return store.Model, nil
 	case isStoredResponseMode(m):
 		return getStoredResponseRequestModel(c, group, tokenID)
+	case m == mode.Files:
+		return getLimitedMultipartFormValue(c.Request, "model")
+	case m == mode.FilesGet, m == mode.FilesContent, m == mode.FilesDelete:
+		return getOpenAIFileRequestModel(c, group, tokenID)
 	case m == mode.Responses:
 		node, err := getRequestBodyNode(c)
 		if err != nil {
@@ -852,10 +1071,56 @@ func getRequestModel(c *gin.Context, m mode.Mode, group string, tokenID int) (st
 		}
 
 		return modelName, nil
-	case m == mode.Gemini || m == mode.GeminiVideo || m == mode.GeminiVideoOperations:
+	case m == mode.Gemini:
+		if err := pinGeminiCachedContentChannel(c, group, tokenID, "cachedContent"); err != nil {
+			return "", err
+		}
+
+		return getGeminiRequestModel(c, group, tokenID)
+	case m == mode.ChatCompletions:
+		if err := pinGeminiCachedContentChannel(c, group, tokenID, "cached_content"); err != nil {
+			return "", err
+		}
+
+		node, err := getRequestBodyNode(c)
+		if err != nil {
+			return "", fmt.Errorf("get request model failed: %w", err)
+		}
+
+		return getStringFieldFromNode(node, "model", "get request model failed")
+	case m == mode.GeminiVideo || m == mode.GeminiVideoOperations:
 		return getGeminiRequestModel(c, group, tokenID)
 	case m == mode.GeminiFiles:
 		return getGeminiFileRequestModel(c, group, tokenID)
+	case m == mode.GeminiCachedContents:
+		node, err := getRequestBodyNode(c)
+		if err != nil {
+			return "", fmt.Errorf("get request model failed: %w", err)
+		}
+
+		modelName, err := getStringFieldFromNode(node, "model", "get request model failed")
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimPrefix(modelName, "models/"), nil
+	case m == mode.GeminiLive:
+		node, err := getRequestBodyNode(c)
+		if err != nil {
+			return "", fmt.Errorf("get request model failed: %w", err)
+		}
+
+		setupNode := node.Get("setup")
+		if setupNode == nil || !setupNode.Exists() {
+			return "", nil
+		}
+
+		modelName, err := getStringFieldFromNode(setupNode, "model", "get request model failed")
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimPrefix(modelName, "models/"), nil
 	case isProviderVideoMode(m):
 		return getProviderVideoRequestModel(c, m, group, tokenID)
 	default:
@@ -962,6 +1227,47 @@ func getGeminiFileRequestModel(c *gin.Context, group string, tokenID int) (strin
 	return store.Model, nil
 }
 
+func getOpenAIFileRequestModel(c *gin.Context, group string, tokenID int) (string, error) {
+	fileID := c.Param("id")
+
+	store, err := model.CacheGetStore(group, tokenID, model.OpenAIFileStoreID(fileID))
+	if err != nil {
+		return "", fmt.Errorf("get request model failed: %w", err)
+	}
+
+	c.Set(FileID, fileID)
+	c.Set(ChannelID, store.ChannelID)
+
+	return store.Model, nil
+}
+
+// pinGeminiCachedContentChannel routes the request referencing a Gemini cached
+// content to the channel that created the cache
+func pinGeminiCachedContentChannel(c *gin.Context, group string, tokenID int, field string) error {
+	if c.Request.Method != http.MethodPost {
+		return nil
+	}
+
+	node, err := getRequestBodyNode(c)
+	if err != nil {
+		return fmt.Errorf("get request cached content failed: %w", err)
+	}
+
+	name, err := getStringFieldFromNode(node, field, "get request cached content failed")
+	if err != nil || name == "" {
+		return err
+	}
+
+	store, err := model.CacheGetStore(group, tokenID, model.GeminiCachedContentStoreID(name))
+	if err != nil {
+		return fmt.Errorf("get request cached content failed: %w", err)
+	}
+
+	c.Set(ChannelID, store.ChannelID)
+
+	return nil
+}
+
 func getGeminiPathModel(c *gin.Context) string {
 	modelName, operationID := getGeminiPathModelAndOperationID(c)
 	if operationID == "" {
@@ -1209,6 +1515,39 @@ func GetPromptCacheKeyFromJSON(body []byte) (string, error) {
 	return getStringFieldFromNode(&node, "prompt_cache_key", "get request prompt_cache_key failed")
 }
 
+// getRequestSeed returns the client supplied sampling seed, nil when the
+// request has no seed
+func getRequestSeed(c *gin.Context, m mode.Mode) (*int64, error) {
+	switch m {
+	case mode.ChatCompletions, mode.Completions, mode.Gemini:
+	default:
+		return nil, nil
+	}
+
+	node, err := getRequestBodyNode(c)
+	if err != nil {
+		return nil, fmt.Errorf("get request seed failed: %w", err)
+	}
+
+	field := node.Get("seed")
+	if m == mode.Gemini {
+		field = node.GetByPath("generationConfig", "seed")
+	}
+
+	if field == nil || !field.Exists() || field.TypeSafe() == ast.V_NULL {
+		return nil, nil
+	}
+
+	value, err := field.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("get request seed failed: %w", err)
+	}
+
+	seed := int64(value)
+
+	return &seed, nil
+}
+
 func getRequestServiceTier(c *gin.Context, m mode.Mode) (string, error) {
 	switch m {
 	case mode.ChatCompletions, mode.Completions, mode.Responses, mode.Anthropic, mode.Gemini:
@@ -0,0 +1,19 @@
+//nolint:testpackage
+package middleware
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckRelayModeAudioGenerations(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, CheckRelayMode(mode.AudioGenerations, mode.AudioGenerations))
+	require.True(t, CheckRelayMode(mode.AudioGenerationsGet, mode.AudioGenerations))
+
+	require.False(t, CheckRelayMode(mode.AudioGenerations, mode.AudioSpeech))
+	require.False(t, CheckRelayMode(mode.AudioSpeech, mode.AudioGenerations))
+}
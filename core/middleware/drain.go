@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/common/drain"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+var relayDrain = drain.New()
+
+// relayDrainTimeout returns the drain deadline of the mode, the per mode
+// timeouts override the default one
+func relayDrainTimeout(m string) time.Duration {
+	if seconds, ok := config.ShutdownDrainModeTimeouts[m]; ok {
+		return time.Duration(seconds) * time.Second
+	}
+
+	return time.Duration(config.ShutdownDrainTimeoutSeconds) * time.Second
+}
+
+// MaxRelayDrainTimeout returns the longest drain deadline of the modes
+func MaxRelayDrainTimeout() time.Duration {
+	timeout := time.Duration(config.ShutdownDrainTimeoutSeconds) * time.Second
+	for _, seconds := range config.ShutdownDrainModeTimeouts {
+		timeout = max(timeout, time.Duration(seconds)*time.Second)
+	}
+
+	return timeout
+}
+
+// DrainRelayRequests stops accepting the new streams and waits for the
+// in-flight relay requests, the requests overrunning the drain deadline of
+// their mode are canceled
+func DrainRelayRequests(ctx context.Context) {
+	relayDrain.Drain(ctx, relayDrainTimeout, 5*time.Second)
+}
+
+// GetDrainStats returns the in-flight relay requests by mode on this instance
+func GetDrainStats() drain.Stats {
+	return relayDrain.Stats()
+}
+
+// rejectWhileDraining rejects the new streams once the instance is draining,
+// the other requests are rejected after the drain deadline of their mode
+func rejectWhileDraining(c *gin.Context, m mode.Mode) bool {
+	if !relayDrain.Draining() {
+		return false
+	}
+
+	if !isStreamRequest(c) && !relayDrain.Expired(relayDrainTimeout(m.String())) {
+		return false
+	}
+
+	c.Header("Retry-After", strconv.FormatInt(config.ShutdownRetryAfterSeconds, 10))
+	AbortLogWithMessage(
+		c,
+		http.StatusServiceUnavailable,
+		"the server is shutting down, please retry later",
+	)
+
+	return true
+}
+
+// trackRelayRequest records the in-flight request of the mode, the returned
+// func must be called once the request is done
+func trackRelayRequest(c *gin.Context, m mode.Mode) func() {
+	ctx, done := relayDrain.Track(c.Request.Context(), m.String())
+	c.Request = c.Request.WithContext(ctx)
+
+	return done
+}
+
+func isStreamRequest(c *gin.Context) bool {
+	if c.IsWebsocket() ||
+		c.Query("alt") == "sse" ||
+		strings.Contains(c.Request.URL.Path, "streamGenerateContent") {
+		return true
+	}
+
+	if !strings.Contains(c.ContentType(), "json") {
+		return false
+	}
+
+	node, err := getRequestBodyNode(c)
+	if err != nil {
+		return false
+	}
+
+	stream, _ := node.Get("stream").Bool()
+
+	return stream
+}
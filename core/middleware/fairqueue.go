@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/common/fairqueue"
+	"github.com/labring/aiproxy/core/model"
+)
+
+var relayScheduler = fairqueue.New()
+
+// acquireFairShare waits for the admission of the request by the fair share of
+// its group, the returned func releases the slot once the request is done
+func acquireFairShare(c *gin.Context, group model.GroupCache) (func(), bool) {
+	capacity := config.GetFairQueueMaxConcurrency()
+	if capacity <= 0 {
+		return func() {}, true
+	}
+
+	ctx := c.Request.Context()
+
+	if timeout := config.GetFairQueueTimeoutSeconds(); timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+
+	release, err := relayScheduler.Acquire(
+		ctx,
+		group.ID,
+		group.FairShareWeight,
+		int(capacity),
+	)
+	if err != nil {
+		AbortLogWithMessage(
+			c,
+			http.StatusTooManyRequests,
+			"the server is saturated, please retry later",
+		)
+
+		return nil, false
+	}
+
+	return release, true
+}
+
+// GetFairQueueStats returns the in-flight and the waiting requests of the
+// fair queue on this instance
+func GetFairQueueStats() fairqueue.Stats {
+	return relayScheduler.Stats()
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/model"
+)
+
+// XAiproxyFeatureFlags lists the feature flags the request turns on, or off
+// with a - prefix, the response echoes the flags on for the request while
+// a rollout is configured
+const XAiproxyFeatureFlags = "X-Aiproxy-Feature-Flags"
+
+// resolveFeatureFlags resolves the feature flags on for the request, the flags
+// are logged while a rollout is configured so its requests can be told apart
+func resolveFeatureFlags(c *gin.Context, group model.GroupCache) {
+	flags := config.ResolveFeatureFlags(
+		group.ID,
+		GetRequestID(c),
+		c.GetHeader(XAiproxyFeatureFlags),
+	)
+
+	c.Set(FeatureFlags, flags)
+
+	if len(config.GetFeatureFlags()) == 0 {
+		return
+	}
+
+	joined := strings.Join(flags, ",")
+	common.GetLogger(c).Data["feature_flags"] = joined
+	c.Header(XAiproxyFeatureFlags, joined)
+}
+
+// GetFeatureFlags returns the feature flags on for the request, nil when they
+// are not resolved and the defaults apply
+func GetFeatureFlags(c *gin.Context) []string {
+	flags, _ := c.Get(FeatureFlags)
+	f, _ := flags.([]string)
+
+	return f
+}
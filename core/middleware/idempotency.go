@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/common/idempotency"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on the recorded responses returned to
+	// the duplicate requests
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// XAiproxyIdempotentRequestID is the request id of the recorded response
+	XAiproxyIdempotentRequestID = "X-Aiproxy-Idempotent-Request-Id"
+
+	maxIdempotencyKeyLength = 255
+	// maxIdempotencyPendingTTL bounds how long a key is held by a request that
+	// never finished, e.g. the instance crashed
+	maxIdempotencyPendingTTL = 30 * time.Minute
+	// maxIdempotentResponseSize limits the recorded body, a larger response is
+	// only recorded by its request id
+	maxIdempotentResponseSize = 1024 * 1024
+)
+
+type idempotentResponseWriter struct {
+	gin.ResponseWriter
+	body      *bytes.Buffer
+	truncated bool
+	// writeFailed is set when the response was not fully written to the
+	// client, e.g. it disconnected in the middle of a stream
+	writeFailed bool
+}
+
+func (w *idempotentResponseWriter) record(b []byte) {
+	if w.truncated {
+		return
+	}
+
+	if w.body.Len()+len(b) > maxIdempotentResponseSize {
+		w.truncated = true
+		w.body.Reset()
+
+		return
+	}
+
+	w.body.Write(b)
+}
+
+func (w *idempotentResponseWriter) Write(b []byte) (int, error) {
+	w.record(b)
+
+	n, err := w.ResponseWriter.Write(b)
+	if err != nil {
+		w.writeFailed = true
+	}
+
+	return n, err
+}
+
+func (w *idempotentResponseWriter) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+
+	n, err := w.ResponseWriter.WriteString(s)
+	if err != nil {
+		w.writeFailed = true
+	}
+
+	return n, err
+}
+
+// shouldRecordIdempotentResponse reports whether the response is final, the
+// server errors and the rate limits are not recorded so the client can retry
+func shouldRecordIdempotentResponse(statusCode int) bool {
+	return statusCode < http.StatusInternalServerError &&
+		statusCode != http.StatusTooManyRequests
+}
+
+// completedIdempotentResponse reports whether the response was fully sent,
+// a response interrupted by a write or render error, or by the client going
+// away, is partial and its key is released instead of recorded
+func completedIdempotentResponse(c *gin.Context, w *idempotentResponseWriter) bool {
+	return !w.writeFailed &&
+		len(c.Errors) == 0 &&
+		c.Request.Context().Err() == nil &&
+		shouldRecordIdempotentResponse(w.Status())
+}
+
+// hashIdempotentRequestBody hashes the request body, the body is kept
+// readable for the handlers
+func hashIdempotentRequestBody(req *http.Request) (string, error) {
+	body, err := common.GetRequestBody(req)
+	if err != nil {
+		return "", err
+	}
+
+	common.SetRequestBody(req, body)
+
+	sum := sha256.Sum256(body)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func replayIdempotentResponse(c *gin.Context, resp *idempotency.Response) {
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Header(XAiproxyIdempotentRequestID, resp.RequestID)
+
+	if resp.Truncated {
+		AbortWithMessage(
+			c,
+			http.StatusConflict,
+			fmt.Sprintf(
+				"the response of the request %s with the same idempotency key is too large to replay",
+				resp.RequestID,
+			),
+		)
+
+		return
+	}
+
+	for k, v := range resp.Header {
+		c.Header(k, v)
+	}
+
+	c.Status(resp.StatusCode)
+	_, _ = c.Writer.Write(resp.Body)
+	c.Abort()
+}
+
+// Idempotency honors the Idempotency-Key header of the requests, the final
+// response is recorded for the ttl and the duplicate requests of the token get
+// the recorded response instead of calling the upstream again, a key reused
+// with another request body is rejected with 422
+func Idempotency(c *gin.Context) {
+	key := c.GetHeader(IdempotencyKeyHeader)
+
+	ttl := time.Duration(config.GetIdempotencyKeyTTLSeconds()) * time.Second
+	if key == "" || ttl <= 0 || c.Request.Method != http.MethodPost {
+		c.Next()
+		return
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		AbortLogWithMessage(
+			c,
+			http.StatusBadRequest,
+			fmt.Sprintf("idempotency key must not exceed %d characters", maxIdempotencyKeyLength),
+		)
+
+		return
+	}
+
+	bodyHash, err := hashIdempotentRequestBody(c.Request)
+	if err != nil {
+		AbortLogWithMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	token := GetToken(c)
+	scopedKey := fmt.Sprintf("%s:%d:%s:%s", token.Group, token.ID, c.Request.URL.Path, key)
+
+	resp, err := idempotency.Acquire(scopedKey, bodyHash, min(ttl, maxIdempotencyPendingTTL))
+	switch {
+	case errors.Is(err, idempotency.ErrInProgress):
+		AbortLogWithMessage(c, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, idempotency.ErrBodyMismatch):
+		AbortLogWithMessage(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	case err != nil:
+		common.GetLogger(c).Errorf("failed to acquire idempotency key: %v", err)
+		c.Next()
+
+		return
+	case resp != nil:
+		replayIdempotentResponse(c, resp)
+		return
+	}
+
+	w := &idempotentResponseWriter{
+		ResponseWriter: c.Writer,
+		body:           bytes.NewBuffer(nil),
+	}
+	c.Writer = w
+
+	defer func() {
+		if r := recover(); r != nil {
+			idempotency.Release(scopedKey)
+			panic(r)
+		}
+
+		if !completedIdempotentResponse(c, w) {
+			idempotency.Release(scopedKey)
+			return
+		}
+
+		resp := &idempotency.Response{
+			StatusCode: w.Status(),
+			RequestID:  GetRequestID(c),
+			Truncated:  w.truncated,
+		}
+		if !w.truncated {
+			resp.Body = w.body.Bytes()
+			if contentType := w.Header().Get("Content-Type"); contentType != "" {
+				resp.Header = map[string]string{"Content-Type": contentType}
+			}
+		}
+
+		idempotency.Complete(scopedKey, bodyHash, resp, ttl)
+	}()
+
+	c.Next()
+}
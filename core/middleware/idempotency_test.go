@@ -0,0 +1,156 @@
+//nolint:testpackage
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIdempotencyRouter(handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST(
+		"/v1/chat/completions",
+		func(c *gin.Context) {
+			c.Set(Token, model.TokenCache{ID: 1, Group: "g1"})
+			c.Set(RequestID, "req_1")
+		},
+		Idempotency,
+		handler,
+	)
+
+	return router
+}
+
+func doIdempotentRequest(router *gin.Engine, key string) *httptest.ResponseRecorder {
+	return doIdempotentRequestWithBody(router, key, `{"model":"gpt-4o"}`)
+}
+
+func doIdempotentRequestWithBody(
+	router *gin.Engine,
+	key, body string,
+) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, key)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+func TestIdempotencyReplaysRecordedResponse(t *testing.T) {
+	calls := 0
+	router := newIdempotencyRouter(func(c *gin.Context) {
+		calls++
+
+		c.Header("Content-Type", "text/event-stream")
+		c.String(http.StatusOK, "data: {\"id\":\"1\"}\n\ndata: [DONE]\n\n")
+	})
+
+	first := doIdempotentRequest(router, "replay-key")
+	require.Equal(t, http.StatusOK, first.Code)
+
+	second := doIdempotentRequest(router, "replay-key")
+	require.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "text/event-stream", second.Header().Get("Content-Type"))
+	assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, "req_1", second.Header().Get(XAiproxyIdempotentRequestID))
+}
+
+func TestIdempotencyRetriesServerErrors(t *testing.T) {
+	calls := 0
+	router := newIdempotencyRouter(func(c *gin.Context) {
+		calls++
+
+		if calls == 1 {
+			c.String(http.StatusBadGateway, "upstream failed")
+			return
+		}
+
+		c.String(http.StatusOK, "ok")
+	})
+
+	require.Equal(t, http.StatusBadGateway, doIdempotentRequest(router, "retry-key").Code)
+	require.Equal(t, http.StatusOK, doIdempotentRequest(router, "retry-key").Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyRejectsInProgressKey(t *testing.T) {
+	var (
+		router *gin.Engine
+		inner  *httptest.ResponseRecorder
+	)
+
+	router = newIdempotencyRouter(func(c *gin.Context) {
+		if inner == nil {
+			inner = doIdempotentRequest(router, "pending-key")
+		}
+
+		c.String(http.StatusOK, "ok")
+	})
+
+	require.Equal(t, http.StatusOK, doIdempotentRequest(router, "pending-key").Code)
+	require.NotNil(t, inner)
+	assert.Equal(t, http.StatusConflict, inner.Code)
+}
+
+func TestIdempotencyRejectsKeyReusedWithAnotherBody(t *testing.T) {
+	calls := 0
+	router := newIdempotencyRouter(func(c *gin.Context) {
+		calls++
+
+		body, err := common.GetRequestBody(c.Request)
+		require.NoError(t, err)
+
+		c.String(http.StatusOK, string(body))
+	})
+
+	first := doIdempotentRequestWithBody(router, "body-key", `{"model":"gpt-4o"}`)
+	require.Equal(t, http.StatusOK, first.Code)
+	assert.JSONEq(t, `{"model":"gpt-4o"}`, first.Body.String())
+
+	second := doIdempotentRequestWithBody(router, "body-key", `{"model":"gpt-4o-mini"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, second.Code)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotencyReleasesInterruptedResponses(t *testing.T) {
+	calls := 0
+	router := newIdempotencyRouter(func(c *gin.Context) {
+		calls++
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Status(http.StatusOK)
+		_, _ = c.Writer.WriteString("data: {\"id\":\"1\"}\n\n")
+
+		if calls == 1 {
+			_ = c.Error(errors.New("write: broken pipe"))
+			c.Abort()
+
+			return
+		}
+
+		_, _ = c.Writer.WriteString("data: [DONE]\n\n")
+	})
+
+	require.Equal(t, http.StatusOK, doIdempotentRequest(router, "interrupted-key").Code)
+
+	second := doIdempotentRequest(router, "interrupted-key")
+	require.Equal(t, http.StatusOK, second.Code)
+	assert.Empty(t, second.Header().Get(IdempotentReplayedHeader))
+	assert.Contains(t, second.Body.String(), "[DONE]")
+	assert.Equal(t, 2, calls)
+}
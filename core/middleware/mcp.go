@@ -69,6 +69,14 @@ func MCPAuth(c *gin.Context) {
 		}
 	}
 
+	if !checkTokenRegions(c, token) {
+		return
+	}
+
+	if !checkTokenOrigin(c, token) {
+		return
+	}
+
 	var group model.GroupCache
 	if useInternalToken {
 		group = model.GroupCache{
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/bytedance/sonic"
+	"github.com/bytedance/sonic/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/conv"
+	"github.com/labring/aiproxy/core/model"
+)
+
+// modelAliasResponsePaths are the paths of the model in the json responses and
+// the stream events, the chat completions and the claude and responses events
+var modelAliasResponsePaths = [][]any{
+	{"model"},
+	{"message", "model"},
+	{"response", "model"},
+}
+
+var modelKeyBytes = []byte(`"model"`)
+
+// resolveGroupModelAlias returns the model the alias of the group points to,
+// an empty string is returned when the model is not an alias of the group
+func resolveGroupModelAlias(group model.GroupCache, modelName string) string {
+	groupModelConfig, ok := group.ModelConfigs[modelName]
+	if !ok {
+		return ""
+	}
+
+	return groupModelConfig.AliasModel
+}
+
+func GetRequestModelAlias(c *gin.Context) string {
+	return c.GetString(RequestModelAlias)
+}
+
+// modelAliasResponseWriter reports the alias as the model of the responses, the
+// upstream and the adaptors only know the model the alias points to
+type modelAliasResponseWriter struct {
+	gin.ResponseWriter
+	alias string
+}
+
+func newModelAliasResponseWriter(w gin.ResponseWriter, alias string) *modelAliasResponseWriter {
+	return &modelAliasResponseWriter{
+		ResponseWriter: w,
+		alias:          alias,
+	}
+}
+
+func (rw *modelAliasResponseWriter) Write(b []byte) (int, error) {
+	out := replaceResponseModel(b, rw.alias)
+	if len(out) != len(b) && rw.Header().Get("Content-Length") != "" {
+		rw.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	}
+
+	n, err := rw.ResponseWriter.Write(out)
+	if err != nil {
+		return n, err
+	}
+
+	return len(b), nil
+}
+
+func (rw *modelAliasResponseWriter) WriteString(s string) (int, error) {
+	return rw.Write(conv.StringToBytes(s))
+}
+
+// replaceResponseModel replaces the model of the json response with the alias,
+// anything else is written as it is
+func replaceResponseModel(b []byte, alias string) []byte {
+	if !bytes.Contains(b, modelKeyBytes) {
+		return b
+	}
+
+	node, err := sonic.Get(b)
+	if err != nil || node.TypeSafe() != ast.V_OBJECT {
+		return b
+	}
+
+	replaced := false
+
+	for _, path := range modelAliasResponsePaths {
+		parent := &node
+		if len(path) > 1 {
+			parent = node.GetByPath(path[:len(path)-1]...)
+			if parent == nil || parent.TypeSafe() != ast.V_OBJECT {
+				continue
+			}
+		}
+
+		key, _ := path[len(path)-1].(string)
+		if n := parent.Get(key); n == nil || !n.Exists() || n.TypeSafe() != ast.V_STRING {
+			continue
+		}
+
+		if _, err := parent.Set(key, ast.NewString(alias)); err != nil {
+			continue
+		}
+
+		replaced = true
+	}
+
+	if !replaced {
+		return b
+	}
+
+	out, err := node.MarshalJSON()
+	if err != nil {
+		return b
+	}
+
+	return out
+}
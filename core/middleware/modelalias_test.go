@@ -0,0 +1,79 @@
+//nolint:testpackage
+package middleware
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveGroupModelAlias(t *testing.T) {
+	t.Parallel()
+
+	group := model.GroupCache{
+		ID: "g1",
+		ModelConfigs: map[string]model.GroupModelConfig{
+			"our-chat-model": {
+				Model:      "our-chat-model",
+				AliasModel: "gpt-4o-mini",
+			},
+			"gpt-4o": {
+				Model:           "gpt-4o",
+				MonthlySpendCap: 10,
+			},
+		},
+	}
+
+	assert.Equal(t, "gpt-4o-mini", resolveGroupModelAlias(group, "our-chat-model"))
+	assert.Empty(t, resolveGroupModelAlias(group, "gpt-4o"))
+	assert.Empty(t, resolveGroupModelAlias(group, "gpt-4o-mini"))
+}
+
+func TestReplaceResponseModel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "chat completion",
+			in:   `{"id":"1","model":"gpt-4o-mini","choices":[]}`,
+			want: `{"id":"1","model":"our-chat-model","choices":[]}`,
+		},
+		{
+			name: "claude message start",
+			in:   `{"type":"message_start","message":{"id":"1","model":"gpt-4o-mini"}}`,
+			want: `{"type":"message_start","message":{"id":"1","model":"our-chat-model"}}`,
+		},
+		{
+			name: "responses event",
+			in:   `{"type":"response.created","response":{"model":"gpt-4o-mini"}}`,
+			want: `{"type":"response.created","response":{"model":"our-chat-model"}}`,
+		},
+		{
+			name: "no model",
+			in:   `{"id":"1","choices":[]}`,
+			want: `{"id":"1","choices":[]}`,
+		},
+		{
+			name: "model only in the content",
+			in:   `{"id":"1","choices":[{"message":{"content":"which model are you"}}]}`,
+			want: `{"id":"1","choices":[{"message":{"content":"which model are you"}}]}`,
+		},
+		{
+			name: "not json",
+			in:   "data: ",
+			want: "data: ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, string(replaceResponseModel([]byte(tt.in), "our-chat-model")))
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bytedance/sonic"
+	"github.com/bytedance/sonic/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"gorm.io/gorm"
+)
+
+const (
+	// PromptTemplateQuery names the prompt template of the group rendered into
+	// the messages of the chat request
+	PromptTemplateQuery = "template"
+	// PromptTemplateVersionQuery pins the version of the prompt template, the
+	// latest version is used without it
+	PromptTemplateVersionQuery = "template_version"
+)
+
+// the keys of the request metadata recording the prompt template version used
+// by the request
+const (
+	promptTemplateMetadataKey        = "prompt_template"
+	promptTemplateVersionMetadataKey = "prompt_template_version"
+)
+
+// applyPromptTemplate renders the prompt template named by the template query
+// with the variables of the request, the rendered messages are prepended to
+// the messages of the request and the variables are removed from the body,
+// nil is returned when the request names no template
+func applyPromptTemplate(
+	c *gin.Context,
+	m mode.Mode,
+	group model.GroupCache,
+) (*model.PromptTemplate, int, error) {
+	name := c.Query(PromptTemplateQuery)
+	if name == "" {
+		return nil, http.StatusOK, nil
+	}
+
+	if m != mode.ChatCompletions {
+		return nil, http.StatusBadRequest, errors.New(
+			"prompt templates are only supported by the chat completions",
+		)
+	}
+
+	var version int64
+
+	if v := c.Query(PromptTemplateVersionQuery); v != "" {
+		var err error
+
+		version, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid template version: %s", v)
+		}
+	}
+
+	template, err := model.GetPromptTemplate(group.ID, name, version)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, http.StatusNotFound, fmt.Errorf("prompt template `%s` not found", name)
+		}
+
+		return nil, http.StatusInternalServerError, err
+	}
+
+	node, err := common.UnmarshalRequest2NodeReusable(c.Request)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	variables, err := getPromptTemplateVariables(&node)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	rendered, err := template.Render(variables)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	if err := prependPromptTemplateMessages(&node, rendered); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	if template.Model != "" {
+		if modelNode := node.Get("model"); modelNode == nil || !modelNode.Exists() {
+			if _, err := node.Set("model", ast.NewString(template.Model)); err != nil {
+				return nil, http.StatusInternalServerError, err
+			}
+		}
+	}
+
+	body, err := node.MarshalJSON()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	common.SetRequestBody(c.Request, body)
+
+	return template, http.StatusOK, nil
+}
+
+// getPromptTemplateVariables returns the variables of the request and removes
+// them from the body, the values which are not strings are rendered as json
+func getPromptTemplateVariables(node *ast.Node) (map[string]string, error) {
+	variablesNode := node.Get("variables")
+	if variablesNode == nil || !variablesNode.Exists() ||
+		variablesNode.TypeSafe() == ast.V_NULL {
+		return nil, nil
+	}
+
+	raw, err := variablesNode.Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]any
+	if err := sonic.UnmarshalString(raw, &values); err != nil {
+		return nil, fmt.Errorf("invalid variables: %w", err)
+	}
+
+	if _, err := node.Unset("variables"); err != nil {
+		return nil, err
+	}
+
+	variables := make(map[string]string, len(values))
+	for k, v := range values {
+		if s, ok := v.(string); ok {
+			variables[k] = s
+			continue
+		}
+
+		s, err := sonic.MarshalString(v)
+		if err != nil {
+			return nil, err
+		}
+
+		variables[k] = s
+	}
+
+	return variables, nil
+}
+
+func prependPromptTemplateMessages(node *ast.Node, rendered []model.PromptTemplateMessage) error {
+	messages := make([]ast.Node, 0, len(rendered))
+	for _, message := range rendered {
+		messages = append(messages, ast.NewObject([]ast.Pair{
+			ast.NewPair("role", ast.NewString(message.Role)),
+			ast.NewPair("content", ast.NewString(message.Content)),
+		}))
+	}
+
+	requestMessages := node.Get("messages")
+	if requestMessages != nil && requestMessages.Exists() &&
+		requestMessages.TypeSafe() != ast.V_NULL {
+		if requestMessages.TypeSafe() != ast.V_ARRAY {
+			return errors.New("messages must be an array")
+		}
+
+		nodes, err := requestMessages.ArrayUseNode()
+		if err != nil {
+			return err
+		}
+
+		messages = append(messages, nodes...)
+	}
+
+	_, err := node.Set("messages", ast.NewArray(messages))
+
+	return err
+}
+
+// setPromptTemplateMetadata records the prompt template version used by the
+// request in the metadata of the request log
+func setPromptTemplateMetadata(
+	metadata map[string]string,
+	template *model.PromptTemplate,
+) map[string]string {
+	if template == nil {
+		return metadata
+	}
+
+	if metadata == nil {
+		metadata = make(map[string]string, 2)
+	}
+
+	metadata[promptTemplateMetadataKey] = template.Name
+	metadata[promptTemplateVersionMetadataKey] = strconv.FormatInt(template.Version, 10)
+
+	return metadata
+}
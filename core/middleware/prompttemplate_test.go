@@ -0,0 +1,46 @@
+//nolint:testpackage
+package middleware
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptTemplateRequestBody(t *testing.T) {
+	node, err := common.GetJSONNodeNoCopy([]byte(
+		`{"model":"gpt-4o","variables":{"name":"Ada","count":3},"messages":[{"role":"user","content":"hi"}],"stream":true}`,
+	))
+	require.NoError(t, err)
+
+	variables, err := getPromptTemplateVariables(&node)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"name": "Ada", "count": "3"}, variables)
+
+	err = prependPromptTemplateMessages(&node, []model.PromptTemplateMessage{
+		{Role: "system", Content: "Greet Ada 3 times"},
+	})
+	require.NoError(t, err)
+
+	body, err := node.MarshalJSON()
+	require.NoError(t, err)
+	require.JSONEq(
+		t,
+		`{"model":"gpt-4o","messages":[{"role":"system","content":"Greet Ada 3 times"},{"role":"user","content":"hi"}],"stream":true}`,
+		string(body),
+	)
+}
+
+func TestSetPromptTemplateMetadata(t *testing.T) {
+	require.Nil(t, setPromptTemplateMetadata(nil, nil))
+	require.Equal(t, map[string]string{
+		"trace":                   "1",
+		"prompt_template":         "support",
+		"prompt_template_version": "4",
+	}, setPromptTemplateMetadata(
+		map[string]string{"trace": "1"},
+		&model.PromptTemplate{Name: "support", Version: 4},
+	))
+}
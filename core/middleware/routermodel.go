@@ -0,0 +1,320 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/bytedance/sonic/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+// XAiproxyRoutedModel is set when the request of a router model is served by a
+// backend model, the value is the backend model
+const XAiproxyRoutedModel = "X-Aiproxy-Routed-Model"
+
+// maxLanguageDetectRunes limits the letters scanned to detect the language
+const maxLanguageDetectRunes = 4096
+
+// routerLanguageMetadataKey is the key of the request metadata recording the
+// prompt language the router model routed the request by
+const routerLanguageMetadataKey = "prompt_language"
+
+// routerFeatures are the prompt heuristics the router rules are evaluated on
+type routerFeatures struct {
+	text         strings.Builder
+	messageCount int
+	maxTokens    int64
+	hasImages    bool
+	hasTools     bool
+}
+
+// resolveRouterModel returns the backend model the router model routes the
+// request to and the detected prompt language, empty strings are returned when
+// the model is not a router model
+func resolveRouterModel(
+	c *gin.Context,
+	m mode.Mode,
+	group, modelName string,
+) (backendModel, language string, err error) {
+	router, ok := config.GetRouterModel(modelName)
+	if !ok {
+		return "", "", nil
+	}
+
+	features, err := getRouterFeatures(c, m)
+	if err != nil {
+		return "", "", err
+	}
+
+	vars := features.vars(m, group)
+	language, _ = vars[config.RouterVarLanguage].(string)
+
+	return router.Route(vars), language, nil
+}
+
+func (f *routerFeatures) vars(m mode.Mode, group string) map[string]any {
+	text := f.text.String()
+
+	return map[string]any{
+		config.RouterVarPromptLength: int64(len([]rune(text))),
+		config.RouterVarMessageCount: int64(f.messageCount),
+		config.RouterVarMaxTokens:    f.maxTokens,
+		config.RouterVarHasImages:    f.hasImages,
+		config.RouterVarHasTools:     f.hasTools,
+		config.RouterVarLanguage:     detectLanguage(text),
+		config.RouterVarMode:         m.String(),
+		config.RouterVarGroup:        group,
+	}
+}
+
+// setRouterLanguageMetadata records the detected prompt language in the
+// metadata of the request log for the analytics of the routing
+func setRouterLanguageMetadata(metadata map[string]string, language string) map[string]string {
+	if language == "" {
+		return metadata
+	}
+
+	if metadata == nil {
+		metadata = make(map[string]string, 1)
+	}
+
+	metadata[routerLanguageMetadataKey] = language
+
+	return metadata
+}
+
+func getRouterFeatures(c *gin.Context, m mode.Mode) (*routerFeatures, error) {
+	features := &routerFeatures{}
+
+	var (
+		messagesKey  string
+		systemKeys   []string
+		maxTokenPath [][]any
+	)
+
+	switch m {
+	case mode.ChatCompletions:
+		messagesKey = "messages"
+		maxTokenPath = [][]any{{"max_completion_tokens"}, {"max_tokens"}}
+	case mode.Completions:
+		messagesKey = "prompt"
+		maxTokenPath = [][]any{{"max_tokens"}}
+	case mode.Anthropic:
+		messagesKey = "messages"
+		systemKeys = []string{"system"}
+		maxTokenPath = [][]any{{"max_tokens"}}
+	case mode.Responses:
+		messagesKey = "input"
+		systemKeys = []string{"instructions"}
+		maxTokenPath = [][]any{{"max_output_tokens"}}
+	case mode.Gemini:
+		messagesKey = "contents"
+		systemKeys = []string{"systemInstruction", "system_instruction"}
+		maxTokenPath = [][]any{{"generationConfig", "maxOutputTokens"}}
+	default:
+		return features, nil
+	}
+
+	node, err := getRequestBodyNode(c)
+	if err != nil {
+		return nil, fmt.Errorf("get router model features failed: %w", err)
+	}
+
+	for _, key := range systemKeys {
+		features.addContent(node.Get(key))
+	}
+
+	messages := node.Get(messagesKey)
+	switch nodeType(messages) {
+	case ast.V_ARRAY:
+		_ = messages.ForEach(func(_ ast.Sequence, message *ast.Node) bool {
+			features.messageCount++
+			features.addMessage(message)
+
+			return true
+		})
+	case ast.V_STRING:
+		features.messageCount = 1
+		features.addContent(messages)
+	}
+
+	if tools := node.Get("tools"); tools != nil && tools.TypeSafe() == ast.V_ARRAY {
+		_ = tools.ForEach(func(ast.Sequence, *ast.Node) bool {
+			features.hasTools = true
+			return false
+		})
+	}
+
+	for _, path := range maxTokenPath {
+		if v, err := node.GetByPath(path...).Int64(); err == nil && v > 0 {
+			features.maxTokens = v
+			break
+		}
+	}
+
+	return features, nil
+}
+
+// addMessage adds a message of the chat, claude and responses, or the gemini
+// content, the items without a content, e.g. the function calls, are skipped
+func (f *routerFeatures) addMessage(message *ast.Node) {
+	if nodeType(message) != ast.V_OBJECT {
+		f.addContent(message)
+		return
+	}
+
+	if content := message.Get("content"); nodeType(content) != ast.V_NONE {
+		f.addContent(content)
+		return
+	}
+
+	if parts := message.Get("parts"); nodeType(parts) != ast.V_NONE {
+		f.addContent(parts)
+		return
+	}
+
+	f.addPart(message)
+}
+
+// addContent adds a content, a string or the parts
+func (f *routerFeatures) addContent(content *ast.Node) {
+	switch nodeType(content) {
+	case ast.V_STRING:
+		text, _ := content.String()
+		f.addText(text)
+	case ast.V_ARRAY:
+		_ = content.ForEach(func(_ ast.Sequence, part *ast.Node) bool {
+			f.addPart(part)
+			return true
+		})
+	case ast.V_OBJECT:
+		// the gemini system instruction is a content of parts
+		if parts := content.Get("parts"); nodeType(parts) != ast.V_NONE {
+			f.addContent(parts)
+			return
+		}
+
+		f.addPart(content)
+	}
+}
+
+func (f *routerFeatures) addPart(part *ast.Node) {
+	if nodeType(part) != ast.V_OBJECT {
+		f.addContent(part)
+		return
+	}
+
+	partType, _ := part.Get("type").String()
+	switch partType {
+	case "image_url", "image", "input_image":
+		f.hasImages = true
+		return
+	}
+
+	for _, key := range []string{"inlineData", "inline_data", "fileData", "file_data"} {
+		data := part.Get(key)
+		if nodeType(data) != ast.V_OBJECT {
+			continue
+		}
+
+		mimeType, _ := data.Get("mimeType").String()
+		if mimeType == "" {
+			mimeType, _ = data.Get("mime_type").String()
+		}
+
+		if strings.HasPrefix(mimeType, "image/") {
+			f.hasImages = true
+		}
+
+		return
+	}
+
+	if text, err := part.Get("text").String(); err == nil {
+		f.addText(text)
+		return
+	}
+
+	// the claude tool results carry their own content
+	if content := part.Get("content"); nodeType(content) != ast.V_NONE {
+		f.addContent(content)
+	}
+}
+
+// nodeType returns the type of the node, V_NONE for the missing fields
+func nodeType(node *ast.Node) int {
+	if node == nil || !node.Exists() {
+		return ast.V_NONE
+	}
+
+	return node.TypeSafe()
+}
+
+func (f *routerFeatures) addText(text string) {
+	if text == "" {
+		return
+	}
+
+	if f.text.Len() > 0 {
+		f.text.WriteByte('\n')
+	}
+
+	f.text.WriteString(text)
+}
+
+// detectLanguage returns the language of the dominant script of the letters,
+// the latin letters are taken as english
+func detectLanguage(text string) string {
+	var han, kana, hangul, cyrillic, arabic, latin, scanned int
+
+	for _, r := range text {
+		if scanned >= maxLanguageDetectRunes {
+			break
+		}
+
+		if !unicode.IsLetter(r) {
+			continue
+		}
+
+		scanned++
+
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	language, best := "", 0
+
+	for _, candidate := range []struct {
+		language string
+		count    int
+	}{
+		// the japanese text mixes the kana with the han characters
+		{"ja", kana + min(han, kana*4)},
+		{"zh", han},
+		{"ko", hangul},
+		{"ru", cyrillic},
+		{"ar", arabic},
+		// a latin letter carries less than a character of the other scripts
+		{"en", (latin + 3) / 4},
+	} {
+		if candidate.count > best {
+			language, best = candidate.language, candidate.count
+		}
+	}
+
+	return language
+}
@@ -0,0 +1,150 @@
+//nolint:testpackage
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRouterFeaturesContext(t *testing.T, body string) *gin.Context {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	req := httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"/v1/chat/completions",
+		strings.NewReader(body),
+	)
+	req.Header.Set("Content-Type", "application/json")
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = req
+
+	return ctx
+}
+
+func TestGetRouterFeatures(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		mode mode.Mode
+		body string
+		want map[string]any
+	}{
+		{
+			name: "chat",
+			mode: mode.ChatCompletions,
+			body: `{"model":"auto","max_tokens":512,"tools":[{"type":"function"}],"messages":[
+				{"role":"system","content":"be brief"},
+				{"role":"user","content":[{"type":"text","text":"what is this"},{"type":"image_url","image_url":{"url":"https://a/b.png"}}]}
+			]}`,
+			want: map[string]any{
+				config.RouterVarPromptLength: int64(len("be brief\nwhat is this")),
+				config.RouterVarMessageCount: int64(2),
+				config.RouterVarMaxTokens:    int64(512),
+				config.RouterVarHasImages:    true,
+				config.RouterVarHasTools:     true,
+				config.RouterVarLanguage:     "en",
+			},
+		},
+		{
+			name: "claude",
+			mode: mode.Anthropic,
+			body: `{"model":"auto","max_tokens":1024,"system":[{"type":"text","text":"你是助手"}],"messages":[
+				{"role":"user","content":"你好，请介绍一下你自己"},
+				{"role":"user","content":[{"type":"tool_result","tool_use_id":"1","content":[{"type":"text","text":"ok"}]}]}
+			]}`,
+			want: map[string]any{
+				config.RouterVarPromptLength: int64(len([]rune("你是助手\n你好，请介绍一下你自己\nok"))),
+				config.RouterVarMessageCount: int64(2),
+				config.RouterVarMaxTokens:    int64(1024),
+				config.RouterVarHasImages:    false,
+				config.RouterVarHasTools:     false,
+				config.RouterVarLanguage:     "zh",
+			},
+		},
+		{
+			name: "responses",
+			mode: mode.Responses,
+			body: `{"model":"auto","instructions":"hi","input":"こんにちは、元気ですか"}`,
+			want: map[string]any{
+				config.RouterVarPromptLength: int64(len([]rune("hi\nこんにちは、元気ですか"))),
+				config.RouterVarMessageCount: int64(1),
+				config.RouterVarMaxTokens:    int64(0),
+				config.RouterVarHasImages:    false,
+				config.RouterVarHasTools:     false,
+				config.RouterVarLanguage:     "ja",
+			},
+		},
+		{
+			name: "gemini",
+			mode: mode.Gemini,
+			body: `{"generationConfig":{"maxOutputTokens":64},"contents":[
+				{"role":"user","parts":[{"text":"Привет"},{"inlineData":{"mimeType":"image/png","data":"AAAA"}}]}
+			]}`,
+			want: map[string]any{
+				config.RouterVarPromptLength: int64(len([]rune("Привет"))),
+				config.RouterVarMessageCount: int64(1),
+				config.RouterVarMaxTokens:    int64(64),
+				config.RouterVarHasImages:    true,
+				config.RouterVarHasTools:     false,
+				config.RouterVarLanguage:     "ru",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			features, err := getRouterFeatures(newRouterFeaturesContext(t, tt.body), tt.mode)
+			require.NoError(t, err)
+
+			vars := features.vars(tt.mode, "group-1")
+			for key, want := range tt.want {
+				assert.Equal(t, want, vars[key], key)
+			}
+
+			assert.Equal(t, tt.mode.String(), vars[config.RouterVarMode])
+			assert.Equal(t, "group-1", vars[config.RouterVarGroup])
+		})
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "en", detectLanguage("Hi"))
+	assert.Equal(t, "zh", detectLanguage("用 Python 写一个快速排序"))
+	assert.Equal(t, "ja", detectLanguage("東京の天気を教えてください"))
+	assert.Equal(t, "ko", detectLanguage("안녕하세요"))
+	assert.Equal(t, "ar", detectLanguage("مرحبا"))
+	assert.Empty(t, detectLanguage("12345 !?"))
+}
+
+func TestSetRouterLanguageMetadata(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, setRouterLanguageMetadata(nil, ""))
+	assert.Equal(
+		t,
+		map[string]string{routerLanguageMetadataKey: "zh"},
+		setRouterLanguageMetadata(nil, "zh"),
+	)
+	assert.Equal(
+		t,
+		map[string]string{"user_key": "v", routerLanguageMetadataKey: "en"},
+		setRouterLanguageMetadata(map[string]string{"user_key": "v"}, "en"),
+	)
+}
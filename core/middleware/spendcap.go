@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/billing"
+	"github.com/labring/aiproxy/core/model"
+	gcache "github.com/patrickmn/go-cache"
+)
+
+const (
+	// XAiproxyModelDowngradedFrom is set when the request is routed to the
+	// spend cap fallback model, the value is the requested model
+	XAiproxyModelDowngradedFrom = "X-Aiproxy-Model-Downgraded-From"
+	// maxSpendCapDowngrades limits the fallback chain of the spend caps
+	maxSpendCapDowngrades = 3
+)
+
+var ErrSpendCapExceeded = errors.New("monthly spend cap of the model exceeded")
+
+// groupModelSpendCache caches the monthly spend of the group models,
+// the summary is written in batches so a short delay is acceptable
+var groupModelSpendCache = gcache.New(time.Minute, 5*time.Minute)
+
+// monthStart returns the start of the spend cap month in UTC like the summary
+// rollups and the invoices, a local month would read a whole rollup bucket of
+// the previous utc day or month
+func monthStart(now time.Time) time.Time {
+	return billing.MonthStart(now)
+}
+
+func getGroupModelMonthlySpend(groupID, modelName string) (float64, error) {
+	start := monthStart(time.Now())
+	cacheKey := fmt.Sprintf("%s:%s:%d", groupID, modelName, start.Unix())
+
+	if v, ok := groupModelSpendCache.Get(cacheKey); ok {
+		if spend, ok := v.(float64); ok {
+			return spend, nil
+		}
+	}
+
+	spend, err := model.GetGroupModelUsedAmount(groupID, modelName, start)
+	if err != nil {
+		return 0, err
+	}
+
+	groupModelSpendCache.SetDefault(cacheKey, spend)
+
+	return spend, nil
+}
+
+// resolveSpendCapModel follows the spend cap fallback models of the group until
+// a model under its cap is found, the fallback model must be accessible by the token
+func resolveSpendCapModel(
+	group model.GroupCache,
+	modelName string,
+	spend func(modelName string) (float64, error),
+	findModel func(modelName string) string,
+) (string, error) {
+	for range maxSpendCapDowngrades + 1 {
+		groupModelConfig, ok := group.ModelConfigs[modelName]
+		if !ok || groupModelConfig.MonthlySpendCap <= 0 {
+			return modelName, nil
+		}
+
+		used, err := spend(modelName)
+		if err != nil {
+			return "", err
+		}
+
+		if used < groupModelConfig.MonthlySpendCap {
+			return modelName, nil
+		}
+
+		if groupModelConfig.SpendCapFallbackModel == "" {
+			return "", fmt.Errorf("%w: %s", ErrSpendCapExceeded, modelName)
+		}
+
+		fallback := findModel(groupModelConfig.SpendCapFallbackModel)
+		if fallback == "" {
+			return "", fmt.Errorf(
+				"%w: %s, the fallback model %s is not available",
+				ErrSpendCapExceeded,
+				modelName,
+				groupModelConfig.SpendCapFallbackModel,
+			)
+		}
+
+		modelName = fallback
+	}
+
+	return "", fmt.Errorf("%w: too many fallback models", ErrSpendCapExceeded)
+}
+
+// applySpendCap routes the request to the fallback model when the monthly
+// spend cap of the requested model in the group is hit
+func applySpendCap(
+	c *gin.Context,
+	group model.GroupCache,
+	token model.TokenCache,
+	modelName string,
+) (string, error) {
+	resolved, err := resolveSpendCapModel(
+		group,
+		modelName,
+		func(modelName string) (float64, error) {
+			return getGroupModelMonthlySpend(group.ID, modelName)
+		},
+		token.FindModel,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if resolved != modelName {
+		c.Header(XAiproxyModelDowngradedFrom, modelName)
+	}
+
+	return resolved, nil
+}
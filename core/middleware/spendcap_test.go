@@ -0,0 +1,133 @@
+//nolint:testpackage
+package middleware
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSpendCapModel(t *testing.T) {
+	t.Parallel()
+
+	group := model.GroupCache{
+		ID: "g1",
+		ModelConfigs: map[string]model.GroupModelConfig{
+			"gpt-4o": {
+				Model:                 "gpt-4o",
+				MonthlySpendCap:       100,
+				SpendCapFallbackModel: "gpt-4o-mini",
+			},
+			"gpt-4o-mini": {
+				Model:                 "gpt-4o-mini",
+				MonthlySpendCap:       10,
+				SpendCapFallbackModel: "gpt-4.1-nano",
+			},
+			"o3": {
+				Model:           "o3",
+				MonthlySpendCap: 50,
+			},
+		},
+	}
+
+	findModel := func(modelName string) string {
+		if modelName == "unknown" {
+			return ""
+		}
+		return modelName
+	}
+
+	newSpend := func(spends map[string]float64) func(string) (float64, error) {
+		return func(modelName string) (float64, error) {
+			return spends[modelName], nil
+		}
+	}
+
+	resolved, err := resolveSpendCapModel(
+		group, "gpt-4o", newSpend(map[string]float64{"gpt-4o": 99}), findModel,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o", resolved)
+
+	resolved, err = resolveSpendCapModel(
+		group, "gpt-4o", newSpend(map[string]float64{"gpt-4o": 100}), findModel,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o-mini", resolved)
+
+	resolved, err = resolveSpendCapModel(
+		group,
+		"gpt-4o",
+		newSpend(map[string]float64{"gpt-4o": 100, "gpt-4o-mini": 20}),
+		findModel,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4.1-nano", resolved)
+
+	resolved, err = resolveSpendCapModel(
+		group, "claude-sonnet-4", newSpend(nil), findModel,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "claude-sonnet-4", resolved)
+
+	_, err = resolveSpendCapModel(
+		group, "o3", newSpend(map[string]float64{"o3": 60}), findModel,
+	)
+	require.ErrorIs(t, err, ErrSpendCapExceeded)
+
+	_, err = resolveSpendCapModel(
+		group,
+		"gpt-4o",
+		func(string) (float64, error) { return 0, errors.New("db error") },
+		findModel,
+	)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrSpendCapExceeded)
+}
+
+func TestResolveSpendCapModelFallbackUnavailable(t *testing.T) {
+	t.Parallel()
+
+	group := model.GroupCache{
+		ModelConfigs: map[string]model.GroupModelConfig{
+			"a": {Model: "a", MonthlySpendCap: 1, SpendCapFallbackModel: "unknown"},
+			"b": {Model: "b", MonthlySpendCap: 1, SpendCapFallbackModel: "c"},
+			"c": {Model: "c", MonthlySpendCap: 1, SpendCapFallbackModel: "b"},
+		},
+	}
+
+	findModel := func(modelName string) string {
+		if modelName == "unknown" {
+			return ""
+		}
+		return modelName
+	}
+	spend := func(string) (float64, error) { return 1, nil }
+
+	_, err := resolveSpendCapModel(group, "a", spend, findModel)
+	require.ErrorIs(t, err, ErrSpendCapExceeded)
+
+	// a fallback loop ends with an error
+	_, err = resolveSpendCapModel(group, "b", spend, findModel)
+	require.ErrorIs(t, err, ErrSpendCapExceeded)
+}
+
+func TestMonthStartIsUTC(t *testing.T) {
+	prevLocal := time.Local
+	time.Local = time.FixedZone("UTC+8", 8*60*60)
+
+	t.Cleanup(func() {
+		time.Local = prevLocal
+	})
+
+	// still february in utc
+	now := time.Date(2025, 3, 1, 2, 0, 0, 0, time.Local)
+	assert.Equal(t, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), monthStart(now))
+
+	now = time.Date(2025, 3, 15, 12, 0, 0, 0, time.Local)
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), monthStart(now))
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// viewerRoutes are the admin apis a viewer may call, anything not listed here
+// is admin only. GET routes that test channels, update balances or call the
+// upstream, and the ones returning secrets such as options, the mcp configs
+// or the config sync export, are left out on purpose. The channel keys and
+// configs are masked for the viewers by the channel apis.
+var viewerRoutes = map[string]struct{}{
+	"/api/models/builtin":                               {},
+	"/api/models/builtin/channel":                       {},
+	"/api/models/builtin/channel/:type":                 {},
+	"/api/models/enabled":                               {},
+	"/api/models/enabled/:set":                          {},
+	"/api/models/sets":                                  {},
+	"/api/models/default":                               {},
+	"/api/models/default/:type":                         {},
+	"/api/models/recommendations":                       {},
+	"/api/dashboard/":                                   {},
+	"/api/dashboard/:group":                             {},
+	"/api/dashboard/:group/models":                      {},
+	"/api/dashboard/:group/timeseries":                  {},
+	"/api/dashboardv2/":                                 {},
+	"/api/dashboardv2/:group":                           {},
+	"/api/dashboardv3/":                                 {},
+	"/api/dashboardv3/:group":                           {},
+	"/api/groups/":                                      {},
+	"/api/groups/ranking":                               {},
+	"/api/groups/consumption_ranking":                   {},
+	"/api/groups/search":                                {},
+	"/api/groups/ip_groups":                             {},
+	"/api/group/:group":                                 {},
+	"/api/group/:group/model_configs/":                  {},
+	"/api/group/:group/model_config/*model":             {},
+	"/api/group/:group/prompt_templates/":               {},
+	"/api/group/:group/prompt_template/:name":           {},
+	"/api/group/:group/prompt_template/:name/versions":  {},
+	"/api/adaptors":                                     {},
+	"/api/channels/":                                    {},
+	"/api/channels/all":                                 {},
+	"/api/channels/type_metas":                          {},
+	"/api/channels/search":                              {},
+	"/api/channel/:id":                                  {},
+	"/api/tokens/":                                      {},
+	"/api/tokens/:id":                                   {},
+	"/api/tokens/search":                                {},
+	"/api/token/:group/search":                          {},
+	"/api/token/:group":                                 {},
+	"/api/token/:group/:id":                             {},
+	"/api/logs/export":                                  {},
+	"/api/logs/":                                        {},
+	"/api/logs/search":                                  {},
+	"/api/logs/consume_error":                           {},
+	"/api/logs/detail/:log_id":                          {},
+	"/api/audit_logs/":                                  {},
+	"/api/invoices/":                                    {},
+	"/api/invoice/:group":                               {},
+	"/api/log/:group/export":                            {},
+	"/api/log/:group":                                   {},
+	"/api/log/:group/search":                            {},
+	"/api/log/:group/detail/:log_id":                    {},
+	"/api/model_configs/":                               {},
+	"/api/model_configs/search":                         {},
+	"/api/model_configs/all":                            {},
+	"/api/model_configs/versions/*model":                {},
+	"/api/model_config/*model":                          {},
+	"/api/monitor/":                                     {},
+	"/api/monitor/runtime_metrics":                      {},
+	"/api/monitor/conversion_metrics":                   {},
+	"/api/monitor/tokenizer_metrics":                    {},
+	"/api/monitor/circuit_breakers":                     {},
+	"/api/monitor/channel_bandit":                       {},
+	"/api/monitor/channel_streams":                      {},
+	"/api/monitor/channel_readiness":                    {},
+	"/api/monitor/fair_queue":                           {},
+	"/api/monitor/drain":                                {},
+	"/api/monitor/batch_summary":                        {},
+	"/api/monitor/group_summary_metrics":                {},
+	"/api/monitor/group_token_metrics/:group":           {},
+	"/api/monitor/group_model_metrics/:group":           {},
+	"/api/monitor/group_tokenname_model_metrics/:group": {},
+	"/api/monitor/models":                               {},
+	"/api/monitor/banned_channels":                      {},
+	"/api/monitor/auto_ban_rules":                       {},
+	"/api/monitor/fingerprint_drift":                    {},
+	"/api/monitor/:id":                                  {},
+}
+
+// isViewerRoute reports whether a viewer may call the matched route, only the
+// GET method of the listed routes is allowed
+func isViewerRoute(c *gin.Context) bool {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+
+	_, ok := viewerRoutes[c.FullPath()]
+
+	return ok
+}
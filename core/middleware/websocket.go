@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// WebSocketProtocol is the subprotocol of the websocket relay endpoints,
+	// the client must offer it so the handshake can echo a subprotocol
+	WebSocketProtocol = "aiproxy.v1"
+	// WebSocketAPIKeyProtocolPrefix carries the api key in the subprotocols,
+	// browsers can not set the authorization header of websocket requests
+	WebSocketAPIKeyProtocolPrefix = "aiproxy-api-key."
+)
+
+// WebSocketAPIKey moves the api key offered by the websocket subprotocols to
+// the authorization header, it must be used before TokenAuth
+func WebSocketAPIKey(c *gin.Context) {
+	if c.Request.Header.Get("Authorization") != "" {
+		return
+	}
+
+	for _, protocol := range websocket.Subprotocols(c.Request) {
+		if key, ok := strings.CutPrefix(protocol, WebSocketAPIKeyProtocolPrefix); ok {
+			c.Request.Header.Set("Authorization", "Bearer "+key)
+			return
+		}
+	}
+}
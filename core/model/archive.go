@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"github.com/labring/aiproxy/core/common/config"
+	"gorm.io/gorm"
+)
+
+const defaultPurgeArchivedBatchSize = 1000
+
+// PurgeArchived hard deletes the channels and the tokens archived longer than
+// the archive purge hours, nothing is purged when the purge hours is zero
+func PurgeArchived(batchSize int) error {
+	purgeHours := config.GetArchivePurgeHours()
+	if purgeHours <= 0 {
+		return nil
+	}
+
+	if batchSize <= 0 {
+		batchSize = defaultPurgeArchivedBatchSize
+	}
+
+	before := time.Now().Add(-time.Duration(purgeHours) * time.Hour)
+
+	if err := purgeArchived(&Channel{}, before, batchSize); err != nil {
+		return err
+	}
+
+	return purgeArchived(&Token{}, before, batchSize)
+}
+
+func purgeArchived(value any, before time.Time, batchSize int) error {
+	subQuery := DB.
+		Unscoped().
+		Model(value).
+		Where("deleted_at IS NOT NULL and deleted_at < ?", before).
+		Limit(batchSize).
+		Select("id")
+
+	return DB.
+		Session(&gorm.Session{SkipDefaultTransaction: true}).
+		Unscoped().
+		Where("id IN (?)", subQuery).
+		Delete(value).
+		Error
+}
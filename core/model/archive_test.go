@@ -0,0 +1,108 @@
+package model_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/model"
+)
+
+func setupArchiveDB(t *testing.T) {
+	t.Helper()
+
+	db, err := model.OpenSQLite(filepath.Join(t.TempDir(), "aiproxy.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+
+	prevDB := model.DB
+	model.DB = db
+
+	t.Cleanup(func() {
+		model.DB = prevDB
+	})
+
+	err = db.AutoMigrate(&model.Group{}, &model.Token{}, &model.Channel{}, &model.ChannelTest{})
+	if err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+}
+
+func TestDeletedTokenIsArchived(t *testing.T) {
+	setupArchiveDB(t)
+
+	token := &model.Token{GroupID: "group-1", Name: "archived"}
+	if err := model.InsertToken(token, true, false); err != nil {
+		t.Fatalf("insert token: %v", err)
+	}
+
+	if err := model.DeleteTokenByID(token.ID); err != nil {
+		t.Fatalf("delete token: %v", err)
+	}
+
+	var archived model.Token
+	if err := model.DB.Unscoped().First(&archived, token.ID).Error; err != nil {
+		t.Fatalf("expected the deleted token to be archived: %v", err)
+	}
+
+	if !archived.DeletedAt.Valid {
+		t.Fatal("expected the archived token to have deleted_at")
+	}
+
+	reused := &model.Token{GroupID: "group-1", Name: "archived"}
+	if err := model.InsertToken(reused, false, false); err != nil {
+		t.Fatalf("expected the name of the archived token to be reusable: %v", err)
+	}
+}
+
+func TestPurgeArchived(t *testing.T) {
+	setupArchiveDB(t)
+
+	prevHours := config.GetArchivePurgeHours()
+	config.SetArchivePurgeHours(24)
+
+	t.Cleanup(func() {
+		config.SetArchivePurgeHours(prevHours)
+	})
+
+	tokens := []*model.Token{
+		{GroupID: "group-1", Name: "old"},
+		{GroupID: "group-1", Name: "recent"},
+	}
+	for _, token := range tokens {
+		if err := model.InsertToken(token, true, false); err != nil {
+			t.Fatalf("insert token: %v", err)
+		}
+	}
+
+	err := model.DB.Model(&model.Token{}).
+		Where("id = ?", tokens[0].ID).
+		Update("deleted_at", time.Now().Add(-48*time.Hour)).
+		Error
+	if err != nil {
+		t.Fatalf("archive old token: %v", err)
+	}
+
+	err = model.DB.Model(&model.Token{}).
+		Where("id = ?", tokens[1].ID).
+		Update("deleted_at", time.Now()).
+		Error
+	if err != nil {
+		t.Fatalf("archive recent token: %v", err)
+	}
+
+	if err := model.PurgeArchived(0); err != nil {
+		t.Fatalf("purge archived: %v", err)
+	}
+
+	var count int64
+	if err := model.DB.Unscoped().Model(&model.Token{}).Count(&count).Error; err != nil {
+		t.Fatalf("count tokens: %v", err)
+	}
+
+	if count != 1 {
+		t.Fatalf("expected only the recently archived token to be kept, got %d tokens", count)
+	}
+}
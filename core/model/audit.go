@@ -0,0 +1,217 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/labring/aiproxy/core/common/config"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// AuditLog records a mutation made through the admin api
+type AuditLog struct {
+	CreatedAt    time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+	Before       string    `gorm:"type:text"            json:"-"`
+	After        string    `gorm:"type:text"            json:"-"`
+	Diff         string    `gorm:"type:text"            json:"-"`
+	Request      string    `gorm:"type:text"            json:"-"`
+	Actor        string    `gorm:"size:64;index"        json:"actor"`
+	ClaimedActor string    `gorm:"size:64"              json:"claimed_actor,omitempty"`
+	Action       string    `gorm:"size:16;index"        json:"action"`
+	Resource     string    `gorm:"size:32;index"        json:"resource"`
+	ResourceID   string    `gorm:"size:128;index"       json:"resource_id,omitempty"`
+	Method       string    `gorm:"size:8"               json:"method"`
+	Path         string    `gorm:"size:256"             json:"path"`
+	IP           string    `gorm:"size:64"              json:"ip"`
+	RequestID    string    `gorm:"size:32;index"        json:"request_id,omitempty"`
+	ID           int       `gorm:"primaryKey"           json:"id"`
+	Code         int       `                            json:"code"`
+}
+
+func rawJSON(s string) json.RawMessage {
+	if s == "" {
+		return nil
+	}
+
+	return json.RawMessage(s)
+}
+
+func (l *AuditLog) MarshalJSON() ([]byte, error) {
+	type Alias AuditLog
+
+	a := &struct {
+		*Alias
+		CreatedAt int64           `json:"created_at"`
+		Before    json.RawMessage `json:"before,omitempty"`
+		After     json.RawMessage `json:"after,omitempty"`
+		Diff      json.RawMessage `json:"diff,omitempty"`
+		Request   json.RawMessage `json:"request,omitempty"`
+	}{
+		Alias:     (*Alias)(l),
+		CreatedAt: l.CreatedAt.UnixMilli(),
+		Before:    rawJSON(l.Before),
+		After:     rawJSON(l.After),
+		Diff:      rawJSON(l.Diff),
+		Request:   rawJSON(l.Request),
+	}
+
+	return sonic.Marshal(a)
+}
+
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// DiffAuditSnapshots returns the top level fields that differ between two
+// json object snapshots, an empty string is returned when nothing changed
+func DiffAuditSnapshots(before, after string) (string, error) {
+	if before == "" && after == "" {
+		return "", nil
+	}
+
+	var beforeFields, afterFields map[string]any
+	if before != "" {
+		if err := sonic.UnmarshalString(before, &beforeFields); err != nil {
+			return "", err
+		}
+	}
+
+	if after != "" {
+		if err := sonic.UnmarshalString(after, &afterFields); err != nil {
+			return "", err
+		}
+	}
+
+	diff := make(map[string]AuditChange)
+
+	for k, v := range beforeFields {
+		if av, ok := afterFields[k]; !ok || !reflect.DeepEqual(v, av) {
+			diff[k] = AuditChange{Before: v, After: afterFields[k]}
+		}
+	}
+
+	for k, v := range afterFields {
+		if _, ok := beforeFields[k]; !ok {
+			diff[k] = AuditChange{After: v}
+		}
+	}
+
+	if len(diff) == 0 {
+		return "", nil
+	}
+
+	return sonic.ConfigStd.MarshalToString(diff)
+}
+
+func RecordAuditLog(log *AuditLog) error {
+	if log.CreatedAt.IsZero() {
+		log.CreatedAt = time.Now()
+	}
+
+	return DB.Create(log).Error
+}
+
+// cleanAuditLog deletes the audit logs older than AuditLogStorageHours, they
+// are kept for good by default
+func cleanAuditLog(batchSize int) error {
+	storageHours := config.GetAuditLogStorageHours()
+	if storageHours == 0 {
+		return nil
+	}
+
+	if batchSize <= 0 {
+		batchSize = defaultCleanLogBatchSize
+	}
+
+	subQuery := DB.
+		Model(&AuditLog{}).
+		Where(
+			"created_at < ?",
+			time.Now().Add(-time.Duration(storageHours)*time.Hour),
+		).
+		Limit(batchSize).
+		Select("id")
+
+	return DB.
+		Session(&gorm.Session{SkipDefaultTransaction: true}).
+		Where("id IN (?)", subQuery).
+		Delete(&AuditLog{}).
+		Error
+}
+
+type GetAuditLogsResult struct {
+	Logs  []*AuditLog `json:"logs"`
+	Total int64       `json:"total"`
+}
+
+func GetAuditLogs(
+	startTimestamp time.Time,
+	endTimestamp time.Time,
+	actor string,
+	action string,
+	resource string,
+	resourceID string,
+	requestID string,
+	page, perPage int,
+) (*GetAuditLogsResult, error) {
+	tx := DB.Model(&AuditLog{})
+
+	switch {
+	case !startTimestamp.IsZero() && !endTimestamp.IsZero():
+		tx = tx.Where("created_at BETWEEN ? AND ?", startTimestamp, endTimestamp)
+	case !startTimestamp.IsZero():
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	case !endTimestamp.IsZero():
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+
+	if actor != "" {
+		tx = tx.Where("actor = ?", actor)
+	}
+
+	if action != "" {
+		tx = tx.Where("action = ?", action)
+	}
+
+	if resource != "" {
+		tx = tx.Where("resource = ?", resource)
+	}
+
+	if resourceID != "" {
+		tx = tx.Where("resource_id = ?", resourceID)
+	}
+
+	if requestID != "" {
+		tx = tx.Where("request_id = ?", requestID)
+	}
+
+	result := &GetAuditLogsResult{}
+
+	err := tx.Count(&result.Total).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Total <= 0 {
+		return result, nil
+	}
+
+	limit, offset := toLimitOffset(page, perPage)
+	err = tx.
+		Order("created_at desc, id desc").
+		Limit(limit).
+		Offset(offset).
+		Find(&result.Logs).
+		Error
+
+	return result, err
+}
@@ -0,0 +1,53 @@
+package model_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanAuditLog(t *testing.T) {
+	db, err := model.OpenSQLite(filepath.Join(t.TempDir(), "aiproxy.db"))
+	require.NoError(t, err)
+
+	prevDB := model.DB
+	model.DB = db
+	prevHours := config.GetAuditLogStorageHours()
+
+	t.Cleanup(func() {
+		model.DB = prevDB
+		config.SetAuditLogStorageHours(prevHours)
+	})
+
+	require.NoError(t, db.AutoMigrate(&model.AuditLog{}))
+
+	now := time.Now()
+	require.NoError(t, db.Create(&model.AuditLog{Path: "old", CreatedAt: now.Add(-48 * time.Hour)}).Error)
+	require.NoError(t, db.Create(&model.AuditLog{Path: "new", CreatedAt: now}).Error)
+
+	config.SetAuditLogStorageHours(0)
+	require.NoError(t, model.CleanAuditLogForTest(0))
+
+	var count int64
+	require.NoError(t, db.Model(&model.AuditLog{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count, "a zero storage keeps the audit logs")
+
+	config.SetAuditLogStorageHours(24)
+	require.NoError(t, model.CleanAuditLogForTest(0))
+
+	var paths []string
+	require.NoError(t, db.Model(&model.AuditLog{}).Pluck("path", &paths).Error)
+	assert.Equal(t, []string{"new"}, paths)
+}
+
+func TestIsSecretOption(t *testing.T) {
+	assert.True(t, model.IsSecretOption("AlertNotifiers"))
+	assert.False(t, model.IsSecretOption("AlertTemplates"))
+	assert.False(t, model.IsSecretOption("LogStorageHours"))
+	assert.False(t, model.IsSecretOption("Unknown"))
+}
@@ -15,7 +15,9 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
-type batchUpdateData struct {
+// batchUpdateMaps are the aggregated updates waiting to be written to the
+// database
+type batchUpdateMaps struct {
 	Groups               map[string]*GroupUpdate
 	Tokens               map[int]*TokenUpdate
 	Channels             map[int]*ChannelUpdate
@@ -23,6 +25,105 @@ type batchUpdateData struct {
 	GroupSummaries       map[GroupSummaryUnique]*GroupSummaryUpdate
 	SummariesMinute      map[SummaryMinuteUnique]*SummaryMinuteUpdate
 	GroupSummariesMinute map[GroupSummaryMinuteUnique]*GroupSummaryMinuteUpdate
+	// restoredFiles are the spill files merged into the updates, they are
+	// removed once the updates are written or spilled again, gob skips them
+	restoredFiles []string
+}
+
+func newBatchUpdateMaps() batchUpdateMaps {
+	return batchUpdateMaps{
+		Groups:               make(map[string]*GroupUpdate),
+		Tokens:               make(map[int]*TokenUpdate),
+		Channels:             make(map[int]*ChannelUpdate),
+		Summaries:            make(map[SummaryUnique]*SummaryUpdate),
+		GroupSummaries:       make(map[GroupSummaryUnique]*GroupSummaryUpdate),
+		SummariesMinute:      make(map[SummaryMinuteUnique]*SummaryMinuteUpdate),
+		GroupSummariesMinute: make(map[GroupSummaryMinuteUnique]*GroupSummaryMinuteUpdate),
+	}
+}
+
+func (b *batchUpdateMaps) isCleanLocked() bool {
+	return b.pendingLocked() == 0
+}
+
+func (b *batchUpdateMaps) pendingLocked() int {
+	return len(b.Groups) +
+		len(b.Tokens) +
+		len(b.Channels) +
+		len(b.Summaries) +
+		len(b.GroupSummaries) +
+		len(b.SummariesMinute) +
+		len(b.GroupSummariesMinute)
+}
+
+// mergeLocked adds the updates of other, the updates are deltas so merging
+// the same key sums them
+func (b *batchUpdateMaps) mergeLocked(other batchUpdateMaps) {
+	b.restoredFiles = append(b.restoredFiles, other.restoredFiles...)
+
+	for key, data := range other.Groups {
+		if existing, ok := b.Groups[key]; ok {
+			existing.Amount = existing.Amount.Add(data.Amount)
+			existing.Count += data.Count
+		} else {
+			b.Groups[key] = data
+		}
+	}
+
+	for key, data := range other.Tokens {
+		if existing, ok := b.Tokens[key]; ok {
+			existing.Amount = existing.Amount.Add(data.Amount)
+			existing.Count += data.Count
+		} else {
+			b.Tokens[key] = data
+		}
+	}
+
+	for key, data := range other.Channels {
+		if existing, ok := b.Channels[key]; ok {
+			existing.Amount = existing.Amount.Add(data.Amount)
+			existing.Count += data.Count
+			existing.RetryCount += data.RetryCount
+		} else {
+			b.Channels[key] = data
+		}
+	}
+
+	for key, data := range other.Summaries {
+		if existing, ok := b.Summaries[key]; ok {
+			existing.Add(data.SummaryData)
+		} else {
+			b.Summaries[key] = data
+		}
+	}
+
+	for key, data := range other.GroupSummaries {
+		if existing, ok := b.GroupSummaries[key]; ok {
+			existing.Add(data.SummaryData)
+		} else {
+			b.GroupSummaries[key] = data
+		}
+	}
+
+	for key, data := range other.SummariesMinute {
+		if existing, ok := b.SummariesMinute[key]; ok {
+			existing.Add(data.SummaryData)
+		} else {
+			b.SummariesMinute[key] = data
+		}
+	}
+
+	for key, data := range other.GroupSummariesMinute {
+		if existing, ok := b.GroupSummariesMinute[key]; ok {
+			existing.Add(data.SummaryData)
+		} else {
+			b.GroupSummariesMinute[key] = data
+		}
+	}
+}
+
+type batchUpdateData struct {
+	batchUpdateMaps
 	sync.Mutex
 }
 
@@ -33,14 +134,14 @@ func (b *batchUpdateData) IsClean() bool {
 	return b.isCleanLocked()
 }
 
-func (b *batchUpdateData) isCleanLocked() bool {
-	return len(b.Groups) == 0 &&
-		len(b.Tokens) == 0 &&
-		len(b.Channels) == 0 &&
-		len(b.Summaries) == 0 &&
-		len(b.GroupSummaries) == 0 &&
-		len(b.SummariesMinute) == 0 &&
-		len(b.GroupSummariesMinute) == 0
+// takeLocked returns the pending updates and replaces them with empty maps,
+// the updates are written without holding the lock so the requests recording
+// their usage are not blocked by a slow database
+func (b *batchUpdateData) takeLocked() batchUpdateMaps {
+	pending := b.batchUpdateMaps
+	b.batchUpdateMaps = newBatchUpdateMaps()
+
+	return pending
 }
 
 type GroupUpdate struct {
@@ -79,23 +180,28 @@ type GroupSummaryMinuteUpdate struct {
 	SummaryData
 }
 
-var batchData batchUpdateData
+var (
+	batchData batchUpdateData
+	// batchProcessLock serializes the flushes, the updates taken by a flush
+	// are merged back when they fail
+	batchProcessLock sync.Mutex
+	// batchFlushSignal wakes the batch processor before the next tick once the
+	// pending updates reach the bound
+	batchFlushSignal = make(chan struct{}, 1)
+)
 
 func init() {
 	batchData = batchUpdateData{
-		Groups:               make(map[string]*GroupUpdate),
-		Tokens:               make(map[int]*TokenUpdate),
-		Channels:             make(map[int]*ChannelUpdate),
-		Summaries:            make(map[SummaryUnique]*SummaryUpdate),
-		GroupSummaries:       make(map[GroupSummaryUnique]*GroupSummaryUpdate),
-		SummariesMinute:      make(map[SummaryMinuteUnique]*SummaryMinuteUpdate),
-		GroupSummariesMinute: make(map[GroupSummaryMinuteUnique]*GroupSummaryMinuteUpdate),
+		batchUpdateMaps: newBatchUpdateMaps(),
 	}
 }
 
 func StartBatchProcessorSummary(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
 
+	recoverRestoringBatchSpillFiles()
+	restoreSpilledBatchUpdates()
+
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
@@ -106,6 +212,8 @@ func StartBatchProcessorSummary(ctx context.Context, wg *sync.WaitGroup) {
 			return
 		case <-ticker.C:
 			ProcessBatchUpdatesSummary()
+		case <-batchFlushSignal:
+			ProcessBatchUpdatesSummary()
 		}
 	}
 }
@@ -115,6 +223,8 @@ func CleanBatchUpdatesSummary(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			ProcessBatchUpdatesSummary()
+			spillBatchUpdates()
+
 			return
 		default:
 			if batchData.IsClean() {
@@ -122,11 +232,32 @@ func CleanBatchUpdatesSummary(ctx context.Context) {
 			}
 		}
 
-		ProcessBatchUpdatesSummary()
+		if !ProcessBatchUpdatesSummary() && spillBatchUpdates() {
+			// the database is unavailable, the updates survive the restart on
+			// the disk instead of blocking the shutdown
+			return
+		}
+
 		time.Sleep(time.Second * 1)
 	}
 }
 
+// notifyBatchPendingLocked triggers a flush once the pending updates reach
+// the bound
+func notifyBatchPendingLocked() {
+	maxPending := config.SummaryBatchMaxPending
+	if maxPending <= 0 || int64(batchData.pendingLocked()) < maxPending {
+		return
+	}
+
+	batchStats.backpressure.Add(1)
+
+	select {
+	case batchFlushSignal <- struct{}{}:
+	default:
+	}
+}
+
 // batchErrors collects errors from batch processors
 type batchErrors struct {
 	mu     sync.Mutex
@@ -164,54 +295,93 @@ func (e *batchErrors) FirstDBConnectionError() error {
 	return nil
 }
 
-func ProcessBatchUpdatesSummary() {
+// ProcessBatchUpdatesSummary writes the pending updates to the database, the
+// failed updates are kept for the next flush, it reports whether all the
+// updates were written
+func ProcessBatchUpdatesSummary() bool {
+	batchProcessLock.Lock()
+	defer batchProcessLock.Unlock()
+
 	batchData.Lock()
-	defer batchData.Unlock()
+	pending := batchData.takeLocked()
+	batchData.Unlock()
+
+	if pending.isCleanLocked() {
+		removeRestoredBatchSpillFiles(pending.restoredFiles)
+		return true
+	}
+
+	start := time.Now()
+	taken := pending.pendingLocked()
 
 	errs := &batchErrors{}
 	g := new(errgroup.Group)
 
 	g.Go(func() error {
-		processGroupUpdates(errs)
+		processGroupUpdates(pending.Groups, errs)
 		return nil
 	})
 	g.Go(func() error {
-		processTokenUpdates(errs)
+		processTokenUpdates(pending.Tokens, errs)
 		return nil
 	})
 	g.Go(func() error {
-		processChannelUpdates(errs)
+		processChannelUpdates(pending.Channels, errs)
 		return nil
 	})
 	g.Go(func() error {
-		processGroupSummaryUpdates(errs)
+		processGroupSummaryUpdates(pending.GroupSummaries, errs)
 		return nil
 	})
 	g.Go(func() error {
-		processSummaryUpdates(errs)
+		processSummaryUpdates(pending.Summaries, errs)
 		return nil
 	})
 	g.Go(func() error {
-		processSummaryMinuteUpdates(errs)
+		processSummaryMinuteUpdates(pending.SummariesMinute, errs)
 		return nil
 	})
 	g.Go(func() error {
-		processGroupSummaryMinuteUpdates(errs)
+		processGroupSummaryMinuteUpdates(pending.GroupSummariesMinute, errs)
 		return nil
 	})
 
 	_ = g.Wait()
 
+	failed := pending.pendingLocked()
+	batchStats.recordFlush(time.Since(start), taken-failed, failed)
+
 	// Check for database connection errors after all processors complete
 	if dbErr := errs.FirstDBConnectionError(); dbErr != nil {
 		oncall.AlertDBError("BatchProcessor", dbErr)
 	} else {
 		oncall.ClearDBError("BatchProcessor")
 	}
+
+	if failed == 0 {
+		removeRestoredBatchSpillFiles(pending.restoredFiles)
+		restoreSpilledBatchUpdates()
+
+		return true
+	}
+
+	batchData.Lock()
+	batchData.mergeLocked(pending)
+	overflow := config.SummaryBatchMaxPending > 0 &&
+		int64(batchData.pendingLocked()) > config.SummaryBatchMaxPending
+	batchData.Unlock()
+
+	// the restored updates that failed go back to the disk, their file is
+	// replaced by the new spill file so the written ones are not restored again
+	if overflow || len(pending.restoredFiles) > 0 {
+		spillBatchUpdates()
+	}
+
+	return false
 }
 
-func processGroupUpdates(errs *batchErrors) {
-	for groupID, data := range batchData.Groups {
+func processGroupUpdates(updates map[string]*GroupUpdate, errs *batchErrors) {
+	for groupID, data := range updates {
 		err := UpdateGroupUsedAmountAndRequestCount(
 			groupID,
 			data.Amount.InexactFloat64(),
@@ -226,13 +396,13 @@ func processGroupUpdates(errs *batchErrors) {
 			)
 			errs.Add(err)
 		} else {
-			delete(batchData.Groups, groupID)
+			delete(updates, groupID)
 		}
 	}
 }
 
-func processTokenUpdates(errs *batchErrors) {
-	for tokenID, data := range batchData.Tokens {
+func processTokenUpdates(updates map[int]*TokenUpdate, errs *batchErrors) {
+	for tokenID, data := range updates {
 		err := UpdateTokenUsedAmount(tokenID, data.Amount.InexactFloat64(), data.Count)
 		if IgnoreNotFound(err) != nil {
 			notify.ErrorThrottle(
@@ -243,13 +413,13 @@ func processTokenUpdates(errs *batchErrors) {
 			)
 			errs.Add(err)
 		} else {
-			delete(batchData.Tokens, tokenID)
+			delete(updates, tokenID)
 		}
 	}
 }
 
-func processChannelUpdates(errs *batchErrors) {
-	for channelID, data := range batchData.Channels {
+func processChannelUpdates(updates map[int]*ChannelUpdate, errs *batchErrors) {
+	for channelID, data := range updates {
 		err := UpdateChannelUsedAmount(
 			channelID,
 			data.Amount.InexactFloat64(),
@@ -265,13 +435,13 @@ func processChannelUpdates(errs *batchErrors) {
 			)
 			errs.Add(err)
 		} else {
-			delete(batchData.Channels, channelID)
+			delete(updates, channelID)
 		}
 	}
 }
 
-func processGroupSummaryUpdates(errs *batchErrors) {
-	for key, data := range batchData.GroupSummaries {
+func processGroupSummaryUpdates(updates map[GroupSummaryUnique]*GroupSummaryUpdate, errs *batchErrors) {
+	for key, data := range updates {
 		err := UpsertGroupSummary(data.GroupSummaryUnique, data.SummaryData)
 		if err != nil {
 			notify.ErrorThrottle(
@@ -282,13 +452,13 @@ func processGroupSummaryUpdates(errs *batchErrors) {
 			)
 			errs.Add(err)
 		} else {
-			delete(batchData.GroupSummaries, key)
+			delete(updates, key)
 		}
 	}
 }
 
-func processGroupSummaryMinuteUpdates(errs *batchErrors) {
-	for key, data := range batchData.GroupSummariesMinute {
+func processGroupSummaryMinuteUpdates(updates map[GroupSummaryMinuteUnique]*GroupSummaryMinuteUpdate, errs *batchErrors) {
+	for key, data := range updates {
 		err := UpsertGroupSummaryMinute(data.GroupSummaryMinuteUnique, data.SummaryData)
 		if err != nil {
 			notify.ErrorThrottle(
@@ -299,13 +469,13 @@ func processGroupSummaryMinuteUpdates(errs *batchErrors) {
 			)
 			errs.Add(err)
 		} else {
-			delete(batchData.GroupSummariesMinute, key)
+			delete(updates, key)
 		}
 	}
 }
 
-func processSummaryUpdates(errs *batchErrors) {
-	for key, data := range batchData.Summaries {
+func processSummaryUpdates(updates map[SummaryUnique]*SummaryUpdate, errs *batchErrors) {
+	for key, data := range updates {
 		err := UpsertSummary(data.SummaryUnique, data.SummaryData)
 		if err != nil {
 			notify.ErrorThrottle(
@@ -316,13 +486,13 @@ func processSummaryUpdates(errs *batchErrors) {
 			)
 			errs.Add(err)
 		} else {
-			delete(batchData.Summaries, key)
+			delete(updates, key)
 		}
 	}
 }
 
-func processSummaryMinuteUpdates(errs *batchErrors) {
-	for key, data := range batchData.SummariesMinute {
+func processSummaryMinuteUpdates(updates map[SummaryMinuteUnique]*SummaryMinuteUpdate, errs *batchErrors) {
+	for key, data := range updates {
 		err := UpsertSummaryMinute(data.SummaryMinuteUnique, data.SummaryData)
 		if err != nil {
 			notify.ErrorThrottle(
@@ -333,7 +503,7 @@ func processSummaryMinuteUpdates(errs *batchErrors) {
 			)
 			errs.Add(err)
 		} else {
-			delete(batchData.SummariesMinute, key)
+			delete(updates, key)
 		}
 	}
 }
@@ -344,6 +514,7 @@ func BatchRecordLogs(
 	requestAt time.Time,
 	retryAt time.Time,
 	firstByteAt time.Time,
+	streamMetrics StreamMetrics,
 	group string,
 	code int,
 	channelID int,
@@ -365,6 +536,11 @@ func BatchRecordLogs(
 	metadata map[string]string,
 	promptCacheKey string,
 	upstreamID string,
+	seed *int64,
+	systemFingerprint string,
+	modelConfigVersion int64,
+	clientAborted bool,
+	usageEstimated bool,
 	asyncUsageStatus AsyncUsageStatus,
 	summaryServiceTier string,
 	summaryClaudeLongContext bool,
@@ -387,6 +563,7 @@ func BatchRecordLogs(
 				requestAt,
 				retryAt,
 				firstByteAt,
+				streamMetrics,
 				group,
 				code,
 				channelID,
@@ -407,6 +584,11 @@ func BatchRecordLogs(
 				metadata,
 				promptCacheKey,
 				upstreamID,
+				seed,
+				systemFingerprint,
+				modelConfigVersion,
+				clientAborted,
+				usageEstimated,
 				asyncUsageStatus,
 			)
 		}
@@ -434,6 +616,7 @@ func BatchRecordLogs(
 		now,
 		requestAt,
 		firstByteAt,
+		streamMetrics,
 		group,
 		code,
 		channelID,
@@ -454,6 +637,7 @@ func BatchUpdateSummary(
 	now time.Time,
 	requestAt time.Time,
 	firstByteAt time.Time,
+	streamMetrics StreamMetrics,
 	group string,
 	code int,
 	channelID int,
@@ -474,6 +658,7 @@ func BatchUpdateSummary(
 
 	batchData.Lock()
 	defer batchData.Unlock()
+	defer notifyBatchPendingLocked()
 
 	updateChannelData(channelID, amount.UsedAmount, amountDecimal, !downstreamResult)
 
@@ -484,6 +669,7 @@ func BatchUpdateSummary(
 			now,
 			requestAt,
 			firstByteAt,
+			streamMetrics,
 			code,
 			amount,
 			usage,
@@ -498,6 +684,7 @@ func BatchUpdateSummary(
 			now,
 			requestAt,
 			firstByteAt,
+			streamMetrics,
 			code,
 			amount,
 			usage,
@@ -573,6 +760,7 @@ func BatchUpdateSummaryOnlyUsage(
 
 	batchData.Lock()
 	defer batchData.Unlock()
+	defer notifyBatchPendingLocked()
 
 	updateChannelAmountData(channelID, amount.UsedAmount, amountDecimal)
 	updateSummaryUsageData(
@@ -1079,6 +1267,7 @@ func updateSummaryData(
 	createAt time.Time,
 	requestAt time.Time,
 	firstByteAt time.Time,
+	streamMetrics StreamMetrics,
 	code int,
 	amount Amount,
 	usage Usage,
@@ -1122,6 +1311,7 @@ func updateSummaryData(
 
 	summary.TotalTimeMilliseconds += totalTimeMilliseconds
 	summary.TotalTTFBMilliseconds += totalTTFBMilliseconds
+	summary.AddStreamMetrics(streamMetrics)
 
 	summary.Usage.Add(usage)
 	summary.AddRequest(code, isRetry)
@@ -1156,6 +1346,7 @@ func updateSummaryDataMinute(
 	createAt time.Time,
 	requestAt time.Time,
 	firstByteAt time.Time,
+	streamMetrics StreamMetrics,
 	code int,
 	amount Amount,
 	usage Usage,
@@ -1199,6 +1390,7 @@ func updateSummaryDataMinute(
 
 	summary.TotalTimeMilliseconds += totalTimeMilliseconds
 	summary.TotalTTFBMilliseconds += totalTTFBMilliseconds
+	summary.AddStreamMetrics(streamMetrics)
 
 	summary.Usage.Add(usage)
 	summary.AddRequest(code, isRetry)
@@ -0,0 +1,266 @@
+package model
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	batchSpillFilePattern      = "summary-*.gob"
+	batchSpillTempPattern      = "summary-*.tmp"
+	batchSpillRestoringSuffix  = ".restoring"
+	batchSpillRestoringPattern = "summary-*.gob" + batchSpillRestoringSuffix
+)
+
+type batchSummaryStats struct {
+	backpressure   atomic.Int64
+	flushes        atomic.Int64
+	written        atomic.Int64
+	failed         atomic.Int64
+	lastFlushMs    atomic.Int64
+	spilled        atomic.Int64
+	restored       atomic.Int64
+	spillFailures  atomic.Int64
+	lastSpillError atomic.Value
+}
+
+var batchStats batchSummaryStats
+
+func (s *batchSummaryStats) recordFlush(duration time.Duration, written, failed int) {
+	s.flushes.Add(1)
+	s.written.Add(int64(written))
+	s.failed.Add(int64(failed))
+	s.lastFlushMs.Store(duration.Milliseconds())
+}
+
+// BatchSummaryStats are the metrics of the summary updates pending in memory
+// and spilled to the disk on this instance
+type BatchSummaryStats struct {
+	Pending               int    `json:"pending"`
+	MaxPending            int64  `json:"max_pending"`
+	Backpressure          int64  `json:"backpressure"`
+	Flushes               int64  `json:"flushes"`
+	Written               int64  `json:"written"`
+	Failed                int64  `json:"failed"`
+	LastFlushMilliseconds int64  `json:"last_flush_milliseconds"`
+	SpillEnabled          bool   `json:"spill_enabled"`
+	SpillFiles            int    `json:"spill_files"`
+	Spilled               int64  `json:"spilled"`
+	Restored              int64  `json:"restored"`
+	SpillFailures         int64  `json:"spill_failures"`
+	LastSpillError        string `json:"last_spill_error,omitempty"`
+}
+
+func GetBatchSummaryStats() BatchSummaryStats {
+	batchData.Lock()
+	pending := batchData.pendingLocked()
+	batchData.Unlock()
+
+	stats := BatchSummaryStats{
+		Pending:               pending,
+		MaxPending:            config.SummaryBatchMaxPending,
+		Backpressure:          batchStats.backpressure.Load(),
+		Flushes:               batchStats.flushes.Load(),
+		Written:               batchStats.written.Load(),
+		Failed:                batchStats.failed.Load(),
+		LastFlushMilliseconds: batchStats.lastFlushMs.Load(),
+		SpillEnabled:          config.SummarySpillDir != "",
+		Spilled:               batchStats.spilled.Load(),
+		Restored:              batchStats.restored.Load(),
+		SpillFailures:         batchStats.spillFailures.Load(),
+	}
+
+	if lastErr, ok := batchStats.lastSpillError.Load().(string); ok {
+		stats.LastSpillError = lastErr
+	}
+
+	if stats.SpillEnabled {
+		files, _ := listBatchSpillFiles(config.SummarySpillDir)
+		stats.SpillFiles = len(files)
+	}
+
+	return stats
+}
+
+func listBatchSpillFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, batchSpillFilePattern))
+	if err != nil {
+		return nil, err
+	}
+
+	// the names carry the spill time, the oldest are restored first
+	slices.Sort(files)
+
+	return files, nil
+}
+
+// spillBatchUpdates moves the pending updates to a file of the spill dir, it
+// reports whether the pending updates are spilled
+func spillBatchUpdates() bool {
+	dir := config.SummarySpillDir
+	if dir == "" {
+		return false
+	}
+
+	batchData.Lock()
+	pending := batchData.takeLocked()
+	batchData.Unlock()
+
+	count := pending.pendingLocked()
+	if count == 0 {
+		removeRestoredBatchSpillFiles(pending.restoredFiles)
+		return true
+	}
+
+	if err := writeBatchSpillFile(dir, pending); err != nil {
+		log.Errorf("spill %d summary updates failed: %v", count, err)
+		batchStats.spillFailures.Add(1)
+		batchStats.lastSpillError.Store(err.Error())
+
+		batchData.Lock()
+		batchData.mergeLocked(pending)
+		batchData.Unlock()
+
+		return false
+	}
+
+	// the restored updates are in the new file now
+	removeRestoredBatchSpillFiles(pending.restoredFiles)
+
+	batchStats.spilled.Add(int64(count))
+	log.Warnf("spilled %d summary updates to %s", count, dir)
+
+	return true
+}
+
+func writeBatchSpillFile(dir string, pending batchUpdateMaps) error {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, batchSpillTempPattern)
+	if err != nil {
+		return err
+	}
+
+	tmpName := f.Name()
+
+	err = gob.NewEncoder(f).Encode(pending)
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+
+	// the zero padded time keeps the names sorted by the spill time
+	name := filepath.Join(dir, fmt.Sprintf("summary-%020d.gob", time.Now().UnixNano()))
+
+	return os.Rename(tmpName, name)
+}
+
+// restoreSpilledBatchUpdates merges the oldest spill file back into the
+// pending updates, one file is restored per successful flush so a large
+// backlog does not flood the database after it recovered. The file is renamed
+// before it is read so it is never restored twice, and it is only removed once
+// the flush containing its updates succeeded or they are spilled again
+func restoreSpilledBatchUpdates() {
+	dir := config.SummarySpillDir
+	if dir == "" {
+		return
+	}
+
+	files, err := listBatchSpillFiles(dir)
+	if err != nil || len(files) == 0 {
+		return
+	}
+
+	file := files[0]
+	restoring := file + batchSpillRestoringSuffix
+
+	if err := os.Rename(file, restoring); err != nil {
+		log.Errorf("claim summary spill file %s failed: %v", file, err)
+		return
+	}
+
+	pending, err := readBatchSpillFile(restoring)
+	if err != nil {
+		log.Errorf("restore summary updates from %s failed: %v", file, err)
+		// keep the unreadable file aside for the manual recovery
+		_ = os.Rename(restoring, file+".corrupt")
+
+		return
+	}
+
+	pending.restoredFiles = []string{restoring}
+
+	batchData.Lock()
+	batchData.mergeLocked(pending)
+	batchData.Unlock()
+
+	count := pending.pendingLocked()
+	batchStats.restored.Add(int64(count))
+	log.Infof("restored %d summary updates from %s", count, file)
+}
+
+// removeRestoredBatchSpillFiles removes the restored spill files whose updates
+// were written or spilled again
+func removeRestoredBatchSpillFiles(files []string) {
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Errorf("remove summary spill file %s failed: %v", file, err)
+		}
+	}
+}
+
+// recoverRestoringBatchSpillFiles returns the files that were being restored
+// when the instance stopped to the spill files, no flush containing their
+// updates succeeded
+func recoverRestoringBatchSpillFiles() {
+	dir := config.SummarySpillDir
+	if dir == "" {
+		return
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, batchSpillRestoringPattern))
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		if err := os.Rename(file, strings.TrimSuffix(file, batchSpillRestoringSuffix)); err != nil {
+			log.Errorf("recover summary spill file %s failed: %v", file, err)
+		}
+	}
+}
+
+func readBatchSpillFile(name string) (batchUpdateMaps, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return batchUpdateMaps{}, err
+	}
+	defer f.Close()
+
+	pending := newBatchUpdateMaps()
+	if err := gob.NewDecoder(f).Decode(&pending); err != nil {
+		return batchUpdateMaps{}, err
+	}
+
+	return pending, nil
+}
@@ -0,0 +1,80 @@
+package model_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchUpdatesSpillAndRestore(t *testing.T) {
+	spillDir := config.SummarySpillDir
+	dir := t.TempDir()
+	config.SummarySpillDir = dir
+
+	defer func() {
+		config.SummarySpillDir = spillDir
+	}()
+
+	model.BatchUpdateSummaryOnlyUsage(
+		time.Now(),
+		time.Time{},
+		"spill-group",
+		1,
+		"gpt-4o",
+		1,
+		"spill-token",
+		model.Usage{InputTokens: 10, OutputTokens: 5},
+		model.Amount{UsedAmount: 1},
+		"",
+		false,
+	)
+
+	pending := model.GetBatchSummaryStats().Pending
+	require.Positive(t, pending)
+
+	require.True(t, model.SpillBatchUpdatesForTest())
+
+	stats := model.GetBatchSummaryStats()
+	assert.Equal(t, 0, stats.Pending)
+	assert.Equal(t, 1, stats.SpillFiles)
+	assert.Equal(t, int64(pending), stats.Spilled)
+
+	model.RestoreSpilledBatchUpdates()
+
+	stats = model.GetBatchSummaryStats()
+	assert.Equal(t, pending, stats.Pending)
+	assert.Equal(t, 0, stats.SpillFiles)
+	assert.Equal(t, int64(pending), stats.Restored)
+
+	// the file is kept until its updates are written or spilled again
+	restoring, err := filepath.Glob(filepath.Join(dir, "*.restoring"))
+	require.NoError(t, err)
+	require.Len(t, restoring, 1)
+
+	// a file being restored is not restored twice
+	model.RestoreSpilledBatchUpdates()
+	assert.Equal(t, pending, model.GetBatchSummaryStats().Pending)
+	assert.Equal(t, int64(pending), model.GetBatchSummaryStats().Restored)
+
+	// spilling the restored updates again replaces their file
+	require.True(t, model.SpillBatchUpdatesForTest())
+
+	restoring, err = filepath.Glob(filepath.Join(dir, "*.restoring"))
+	require.NoError(t, err)
+	assert.Empty(t, restoring)
+	assert.Equal(t, 1, model.GetBatchSummaryStats().SpillFiles)
+
+	// the file being restored when the instance stopped is restored again
+	model.RestoreSpilledBatchUpdates()
+	assert.Equal(t, 0, model.GetBatchSummaryStats().SpillFiles)
+
+	model.RecoverRestoringSpillFiles()
+	assert.Equal(t, 1, model.GetBatchSummaryStats().SpillFiles)
+
+	require.True(t, model.SpillBatchUpdatesForTest())
+}
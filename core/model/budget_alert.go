@@ -0,0 +1,121 @@
+package model
+
+const (
+	BudgetAlertQuotaTotal  = "total"
+	BudgetAlertQuotaPeriod = "period"
+)
+
+// TokenBudgetAlertItem is a token whose usage reached a threshold of its quota
+type TokenBudgetAlertItem struct {
+	GroupID    string
+	TokenID    int
+	TokenName  string
+	QuotaType  string
+	PeriodType string
+	// PeriodStart is the unix time the period started, zero for the total quota
+	PeriodStart int64
+	Quota       float64
+	UsedAmount  float64
+	Ratio       float64
+	// Threshold is the highest threshold of the group reached by the ratio
+	Threshold float64
+}
+
+// GetTokenBudgetAlerts returns the enabled tokens whose total or period usage
+// reached the budget thresholds of their group, thresholds returns the
+// ascending quota ratios of a group
+func GetTokenBudgetAlerts(thresholds func(group string) []float64) ([]TokenBudgetAlertItem, error) {
+	var tokens []*Token
+
+	err := DB.
+		Select(
+			"id", "name", "group_id", "used_amount",
+			"quota", "period_quota", "period_type",
+			"period_last_update_time", "period_last_update_amount",
+		).
+		Where("status = ?", TokenStatusEnabled).
+		Where("quota > 0 OR period_quota > 0").
+		Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []TokenBudgetAlertItem
+
+	for _, token := range tokens {
+		groupThresholds := thresholds(token.GroupID)
+		if len(groupThresholds) == 0 {
+			continue
+		}
+
+		if token.Quota > 0 {
+			if alert, ok := budgetAlert(
+				token,
+				BudgetAlertQuotaTotal,
+				token.Quota,
+				token.UsedAmount,
+				groupThresholds,
+			); ok {
+				alerts = append(alerts, alert)
+			}
+		}
+
+		if token.PeriodQuota <= 0 {
+			continue
+		}
+
+		// the period usage counts as zero until the period is reset
+		if needsReset, err := token.NeedsPeriodReset(); err != nil || needsReset {
+			continue
+		}
+
+		if alert, ok := budgetAlert(
+			token,
+			BudgetAlertQuotaPeriod,
+			token.PeriodQuota,
+			token.UsedAmount-token.PeriodLastUpdateAmount,
+			groupThresholds,
+		); ok {
+			alert.PeriodType = string(token.PeriodType)
+			if alert.PeriodType == "" {
+				alert.PeriodType = PeriodTypeMonthly
+			}
+
+			alert.PeriodStart = token.PeriodLastUpdateTime.Unix()
+			alerts = append(alerts, alert)
+		}
+	}
+
+	return alerts, nil
+}
+
+func budgetAlert(
+	token *Token,
+	quotaType string,
+	quota, usedAmount float64,
+	thresholds []float64,
+) (TokenBudgetAlertItem, bool) {
+	ratio := usedAmount / quota
+
+	reached := 0.0
+	for _, threshold := range thresholds {
+		if ratio >= threshold {
+			reached = threshold
+		}
+	}
+
+	if reached == 0 {
+		return TokenBudgetAlertItem{}, false
+	}
+
+	return TokenBudgetAlertItem{
+		GroupID:    token.GroupID,
+		TokenID:    token.ID,
+		TokenName:  string(token.Name),
+		QuotaType:  quotaType,
+		Quota:      quota,
+		UsedAmount: usedAmount,
+		Ratio:      ratio,
+		Threshold:  reached,
+	}, true
+}
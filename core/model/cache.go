@@ -1,10 +1,12 @@
 package model
 
 import (
+	"context"
 	"encoding"
 	"time"
 
 	"github.com/bytedance/sonic"
+	"github.com/labring/aiproxy/core/common"
 	"github.com/labring/aiproxy/core/common/conv"
 	"github.com/redis/go-redis/v9"
 )
@@ -15,6 +17,44 @@ const (
 	redisTimeout     = 2 * time.Second
 )
 
+func init() {
+	// the cached rows on the redis target taken over by a failover are older
+	// than the writes made on the previous target, they are flushed and
+	// refilled from the database, the group pattern also resets the tpm counters
+	common.RegisterRedisCacheNamespaces(
+		TokenCacheKey,
+		GroupCacheKey,
+		GroupMCPCacheKey,
+		PublicMCPCacheKey,
+		PublicMCPReusingParamCacheKey,
+		StoreCacheKey,
+	)
+}
+
+// cacheWriteRedis runs the write of the cache key on redis, the key is marked
+// stale when redis is down or the write fails, so it is deleted once redis
+// recovers instead of serving the value from before the write
+func cacheWriteRedis(key string, write func(ctx context.Context) error) error {
+	if !common.RedisEnabled {
+		return nil
+	}
+
+	if !common.RedisAvailable() {
+		common.MarkRedisStale(key)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := write(ctx); err != nil {
+		common.MarkRedisStale(key)
+		return err
+	}
+
+	return nil
+}
+
 var (
 	_ encoding.BinaryMarshaler = (*redisStringSlice)(nil)
 	_ redis.Scanner            = (*redisStringSlice)(nil)
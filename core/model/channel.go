@@ -2,7 +2,9 @@ package model
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"slices"
 	"strings"
 	"time"
@@ -10,6 +12,7 @@ import (
 	"github.com/bytedance/sonic"
 	"github.com/labring/aiproxy/core/common"
 	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/common/secret"
 	"github.com/labring/aiproxy/core/monitor"
 	"github.com/labring/aiproxy/core/relay/mode"
 	"gorm.io/gorm"
@@ -38,6 +41,7 @@ type Channel struct {
 	BalanceUpdatedAt        time.Time         `                                          json:"balance_updated_at"         yaml:"-"`
 	ModelMapping            map[string]string `gorm:"serializer:fastjson;type:text"      json:"model_mapping"              yaml:"model_mapping,omitempty"`
 	Key                     string            `gorm:"type:text;index:,length:191"        json:"key"                        yaml:"key,omitempty"`
+	KeyHash                 string            `gorm:"size:64;index"                      json:"-"                          yaml:"-"`
 	Name                    string            `gorm:"size:64;index"                      json:"name"                       yaml:"name,omitempty"`
 	BaseURL                 string            `gorm:"size:128;index"                     json:"base_url"                   yaml:"base_url,omitempty"`
 	ProxyURL                string            `gorm:"size:255"                           json:"proxy_url"                  yaml:"proxy_url,omitempty"`
@@ -51,12 +55,21 @@ type Channel struct {
 	Type                    ChannelType       `gorm:"default:0;index"                    json:"type"                       yaml:"type,omitempty"`
 	Priority                int32             `                                          json:"priority"                   yaml:"priority,omitempty"`
 	EnabledAutoBalanceCheck bool              `                                          json:"enabled_auto_balance_check" yaml:"enabled_auto_balance_check,omitempty"`
+	EnabledAutoModelSync    bool              `                                          json:"enabled_auto_model_sync"    yaml:"enabled_auto_model_sync,omitempty"`
 	BalanceThreshold        float64           `                                          json:"balance_threshold"          yaml:"balance_threshold,omitempty"`
 	SkipTLSVerify           bool              `                                          json:"skip_tls_verify"            yaml:"skip_tls_verify,omitempty"`
 	EnabledNoPermissionBan  bool              `                                          json:"enabled_no_permission_ban"  yaml:"enabled_no_permission_ban,omitempty"`
 	WarnErrorRate           float64           `                                          json:"warn_error_rate"            yaml:"warn_error_rate,omitempty"`
 	MaxErrorRate            float64           `                                          json:"max_error_rate"             yaml:"max_error_rate,omitempty"`
+	BreakerErrorRate        float64           `                                          json:"breaker_error_rate"         yaml:"breaker_error_rate,omitempty"`
+	BreakerLatencySLOMs     int64             `                                          json:"breaker_latency_slo_ms"     yaml:"breaker_latency_slo_ms,omitempty"`
+	MaxConcurrentStreams    int               `                                          json:"max_concurrent_streams"     yaml:"max_concurrent_streams,omitempty"`
+	Region                  string            `gorm:"size:32;index"                      json:"region,omitempty"           yaml:"region,omitempty"`
+	DataResidency           []string          `gorm:"serializer:fastjson;type:text"      json:"data_residency,omitempty"   yaml:"data_residency,omitempty"`
 	Configs                 ChannelConfigs    `gorm:"serializer:fastjson;type:text"      json:"configs,omitempty"          yaml:"configs,omitempty"`
+	ParamOverrides          ParamOverrides    `gorm:"serializer:fastjson;type:text"      json:"param_overrides,omitempty"  yaml:"param_overrides,omitempty"`
+	URLTemplates            URLTemplates      `gorm:"serializer:fastjson;type:text"      json:"url_templates,omitempty"    yaml:"url_templates,omitempty"`
+	AllowedModes            AllowedModes      `gorm:"serializer:fastjson;type:text"      json:"allowed_modes,omitempty"    yaml:"allowed_modes,omitempty"`
 	Sets                    []string          `gorm:"serializer:fastjson;type:text"      json:"sets,omitempty"             yaml:"sets,omitempty"`
 }
 
@@ -67,6 +80,141 @@ func (c *Channel) GetSets() []string {
 	return c.Sets
 }
 
+// BeforeSave encrypts the key and the secret configs when the secret
+// encryption is enabled
+func (c *Channel) BeforeSave(tx *gorm.DB) (err error) {
+	// the encrypted keys read from the api are saved as is, their hash is
+	// recomputed from the plaintext so an update does not clear it
+	plaintext, err := secret.Decrypt(c.Key)
+	if err != nil {
+		return err
+	}
+
+	if plaintext != "" {
+		tx.Statement.SetColumn("key_hash", secret.Hash(plaintext))
+	}
+
+	key, err := secret.Encrypt(c.Key)
+	if err != nil {
+		return err
+	}
+
+	if key != c.Key {
+		tx.Statement.SetColumn("key", key)
+	}
+
+	configs, changed, err := encryptSecretConfigs(c.Configs)
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		tx.Statement.SetColumn("configs", configs)
+	}
+
+	return nil
+}
+
+// GetKey returns the decrypted key, the key is decrypted lazily at request time
+func (c *Channel) GetKey() (string, error) {
+	key, err := secret.Decrypt(c.Key)
+	if err != nil {
+		return "", fmt.Errorf("decrypt key of channel %d failed: %w", c.ID, err)
+	}
+
+	return key, nil
+}
+
+// GetConfigs returns the configs with the secret values decrypted
+func (c *Channel) GetConfigs() (ChannelConfigs, error) {
+	var configs ChannelConfigs
+
+	for name, value := range c.Configs {
+		v, ok := value.(string)
+		if !ok || !secret.IsEncrypted(v) {
+			continue
+		}
+
+		plaintext, err := secret.Decrypt(v)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt config %s of channel %d failed: %w", name, c.ID, err)
+		}
+
+		if configs == nil {
+			configs = maps.Clone(c.Configs)
+		}
+
+		configs[name] = plaintext
+	}
+
+	if configs == nil {
+		return c.Configs, nil
+	}
+
+	return configs, nil
+}
+
+// CheckSecrets reports whether the key and the secret configs can be
+// decrypted, the channels failing it are not selected by the relay
+func (c *Channel) CheckSecrets() error {
+	if _, err := c.GetKey(); err != nil {
+		return err
+	}
+
+	_, err := c.GetConfigs()
+
+	return err
+}
+
+// secretConfigSuffixes are the endings of the config names holding
+// credentials, e.g. access_key, client_secret or service_account_credentials
+var secretConfigSuffixes = []string{"key", "secret", "token", "password", "credentials"}
+
+func isSecretConfig(name string) bool {
+	name = strings.ToLower(name)
+	for _, suffix := range secretConfigSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// encryptSecretConfigs encrypts the string values of the secret configs, the
+// configs are returned as is when nothing was encrypted
+func encryptSecretConfigs(configs ChannelConfigs) (ChannelConfigs, bool, error) {
+	var encrypted ChannelConfigs
+
+	for name, value := range configs {
+		v, ok := value.(string)
+		if !ok || !isSecretConfig(name) {
+			continue
+		}
+
+		ciphertext, err := secret.Encrypt(v)
+		if err != nil {
+			return nil, false, fmt.Errorf("encrypt config %s failed: %w", name, err)
+		}
+
+		if ciphertext == v {
+			continue
+		}
+
+		if encrypted == nil {
+			encrypted = maps.Clone(configs)
+		}
+
+		encrypted[name] = ciphertext
+	}
+
+	if encrypted == nil {
+		return configs, false, nil
+	}
+
+	return encrypted, true, nil
+}
+
 func (c *Channel) BeforeDelete(tx *gorm.DB) (err error) {
 	return tx.Model(&ChannelTest{}).Where("channel_id = ?", c.ID).Delete(&ChannelTest{}).Error
 }
@@ -110,12 +258,150 @@ func (c ChannelConfigs) LoadConfig(config any) error {
 	return sonic.Unmarshal(v, config)
 }
 
+// ParamOverrides are the json merge patches applied to the converted upstream
+// request bodies of the channel, keyed by the model name, the "*" patch applies
+// to all the models and is applied before the model patches
+type ParamOverrides map[string]map[string]any
+
+// ParamOverrideAllModels is the key of the patch applied to all the models
+const ParamOverrideAllModels = "*"
+
+// Patches returns the patches applied to the model in order, the patch of the
+// mapped upstream model name is applied after the one of the origin model name
+func (p ParamOverrides) Patches(originModel, actualModel string) []map[string]any {
+	if len(p) == 0 {
+		return nil
+	}
+
+	keys := []string{ParamOverrideAllModels, originModel}
+	if actualModel != originModel {
+		keys = append(keys, actualModel)
+	}
+
+	patches := make([]map[string]any, 0, len(keys))
+	for _, key := range keys {
+		if patch, ok := p[key]; ok && len(patch) > 0 {
+			patches = append(patches, patch)
+		}
+	}
+
+	return patches
+}
+
+// URLTemplates replace the request urls built by the adaptors of the channel,
+// keyed by the mode name, the "*" template applies to the modes without one
+//
+// The placeholders are {base_url}, {path} (the path and query built by the
+// adaptor relative to the base url), {model} (the upstream model name),
+// {origin_model}, {mode} and {api_version} (the api_version channel config)
+type URLTemplates map[string]string
+
+// URLTemplateAllModes is the key of the template applied to all the modes
+const URLTemplateAllModes = "*"
+
+const (
+	URLPlaceholderBaseURL     = "base_url"
+	URLPlaceholderPath        = "path"
+	URLPlaceholderModel       = "model"
+	URLPlaceholderOriginModel = "origin_model"
+	URLPlaceholderMode        = "mode"
+	URLPlaceholderAPIVersion  = "api_version"
+)
+
+var urlPlaceholders = []string{
+	URLPlaceholderBaseURL,
+	URLPlaceholderPath,
+	URLPlaceholderModel,
+	URLPlaceholderOriginModel,
+	URLPlaceholderMode,
+	URLPlaceholderAPIVersion,
+}
+
+// Template returns the template of the mode, empty when the url built by the
+// adaptor is kept
+func (t URLTemplates) Template(m mode.Mode) string {
+	if tmpl, ok := t[m.String()]; ok {
+		return tmpl
+	}
+	return t[URLTemplateAllModes]
+}
+
+func (t URLTemplates) Validate() error {
+	for key, tmpl := range t {
+		if key != URLTemplateAllModes {
+			if _, ok := mode.Parse(key); !ok {
+				return fmt.Errorf("unknown mode: %s", key)
+			}
+		}
+
+		if _, err := RenderURLTemplate(tmpl, nil); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// RenderURLTemplate replaces the placeholders of the template with the values,
+// the missing values are replaced with an empty string
+func RenderURLTemplate(tmpl string, values map[string]string) (string, error) {
+	var sb strings.Builder
+
+	for {
+		start := strings.IndexByte(tmpl, '{')
+		if start < 0 {
+			sb.WriteString(tmpl)
+			break
+		}
+
+		end := strings.IndexByte(tmpl[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unclosed placeholder in %q", tmpl)
+		}
+
+		name := tmpl[start+1 : start+end]
+		if !slices.Contains(urlPlaceholders, name) {
+			return "", fmt.Errorf("unknown placeholder: {%s}", name)
+		}
+
+		sb.WriteString(tmpl[:start])
+		sb.WriteString(values[name])
+
+		tmpl = tmpl[start+end+1:]
+	}
+
+	return sb.String(), nil
+}
+
+// AllowedModes restricts the channel to the modes by name, e.g. only
+// Embeddings for a cheap key, all the modes supported by the adaptor are
+// allowed when empty
+type AllowedModes []string
+
+func (a AllowedModes) Allows(m mode.Mode) bool {
+	return len(a) == 0 || slices.Contains(a, m.String())
+}
+
+func (a AllowedModes) Validate() error {
+	for _, name := range a {
+		if _, ok := mode.Parse(name); !ok {
+			return fmt.Errorf("unknown mode: %s", name)
+		}
+	}
+
+	return nil
+}
+
 func GetModelConfigWithModels(models []string) ([]string, []string, error) {
+	return getModelConfigWithModels(DB, models)
+}
+
+func getModelConfigWithModels(db *gorm.DB, models []string) ([]string, []string, error) {
 	if len(models) == 0 || config.DisableModelConfig {
 		return models, nil, nil
 	}
 
-	where := DB.Model(&ModelConfig{}).Where("model IN ?", models)
+	where := db.Model(&ModelConfig{}).Where("model IN ?", models)
 
 	var count int64
 	if err := where.Count(&count).Error; err != nil {
@@ -159,7 +445,11 @@ func GetModelConfigWithModels(models []string) ([]string, []string, error) {
 }
 
 func CheckModelConfigExist(models []string) error {
-	_, missingModels, err := GetModelConfigWithModels(models)
+	return checkModelConfigExist(DB, models)
+}
+
+func checkModelConfigExist(db *gorm.DB, models []string) error {
+	_, missingModels, err := getModelConfigWithModels(db, models)
 	if err != nil {
 		return err
 	}
@@ -235,7 +525,7 @@ func GetChannels(
 	}
 
 	if key != "" {
-		tx = tx.Where("key = ?", key)
+		tx = tx.Where("key = ? OR key_hash = ?", key, secret.Hash(key))
 	}
 
 	if channelType != 0 {
@@ -280,7 +570,7 @@ func SearchChannels(
 	}
 
 	if key != "" {
-		tx = tx.Where("key = ?", key)
+		tx = tx.Where("key = ? OR key_hash = ?", key, secret.Hash(key))
 	}
 
 	if channelType != 0 {
@@ -318,13 +608,19 @@ func SearchChannels(
 		}
 
 		if key == "" {
-			if !common.UsingSQLite {
-				conditions = append(conditions, "key ILIKE ?")
+			// the encrypted keys only match the whole keyword by the hash
+			if secret.Enabled() {
+				conditions = append(conditions, "key_hash = ?")
+				values = append(values, secret.Hash(keyword))
 			} else {
-				conditions = append(conditions, "key LIKE ?")
-			}
+				if !common.UsingSQLite {
+					conditions = append(conditions, "key ILIKE ?")
+				} else {
+					conditions = append(conditions, "key LIKE ?")
+				}
 
-			values = append(values, "%"+keyword+"%")
+				values = append(values, "%"+keyword+"%")
+			}
 		}
 
 		if baseURL == "" {
@@ -402,26 +698,42 @@ func UpdateChannel(channel *Channel) (err error) {
 		if err == nil {
 			_ = InitModelConfigAndChannelCache()
 			_ = monitor.ClearChannelAllModelErrors(context.Background(), channel.ID)
+			monitor.ResetChannelBreakers(int64(channel.ID))
+			monitor.ResetChannelBandit(int64(channel.ID))
 		}
 	}()
 
-	if err := CheckModelConfigExist(channel.Models); err != nil {
+	return updateChannel(DB, channel)
+}
+
+func updateChannel(db *gorm.DB, channel *Channel) error {
+	if err := checkModelConfigExist(db, channel.Models); err != nil {
 		return err
 	}
 
 	selects := []string{
 		"model_mapping",
 		"key",
+		"key_hash",
 		"base_url",
 		"proxy_url",
 		"models",
 		"priority",
 		"configs",
+		"param_overrides",
+		"url_templates",
+		"allowed_modes",
 		"enabled_auto_balance_check",
+		"enabled_auto_model_sync",
 		"skip_tls_verify",
 		"enabled_no_permission_ban",
 		"warn_error_rate",
 		"max_error_rate",
+		"breaker_error_rate",
+		"breaker_latency_slo_ms",
+		"max_concurrent_streams",
+		"region",
+		"data_residency",
 		"balance_threshold",
 		"sets",
 	}
@@ -433,7 +745,7 @@ func UpdateChannel(channel *Channel) (err error) {
 		selects = append(selects, "name")
 	}
 
-	result := DB.
+	result := db.
 		Select(selects).
 		Clauses(clause.Returning{}).
 		Where("id = ?", channel.ID).
@@ -442,6 +754,121 @@ func UpdateChannel(channel *Channel) (err error) {
 	return HandleUpdateResult(result, ErrChannelNotFound)
 }
 
+// UpdateChannelModels updates the models of the channel synced from the
+// upstream, the model configs of the models must exist
+func UpdateChannelModels(id int, models []string) (err error) {
+	defer func() {
+		if err == nil {
+			_ = InitModelConfigAndChannelCache()
+		}
+	}()
+
+	if err := CheckModelConfigExist(models); err != nil {
+		return err
+	}
+
+	result := DB.
+		Select("models").
+		Where("id = ?", id).
+		Updates(&Channel{Models: models})
+
+	return HandleUpdateResult(result, ErrChannelNotFound)
+}
+
+// EncryptChannelKeys encrypts the plaintext keys and secret configs stored
+// before the secret encryption was enabled and hashes the keys stored without
+// a hash, it returns the number of the updated keys and configs
+func EncryptChannelKeys() (int, error) {
+	if !secret.Enabled() {
+		return 0, errors.New("secret encryption is not enabled")
+	}
+
+	var channels []*Channel
+
+	err := DB.
+		Select("id", "key").
+		Where("key <> ''").
+		Where(DB.Where("key NOT LIKE ?", "enc:%").
+			Or("key_hash = ''").
+			Or("key_hash IS NULL")).
+		Find(&channels).
+		Error
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for _, channel := range channels {
+		plaintext, err := secret.Decrypt(channel.Key)
+		if err != nil {
+			return count, err
+		}
+
+		key, err := secret.Encrypt(channel.Key)
+		if err != nil {
+			return count, err
+		}
+
+		// skip the hooks, the key is encrypted already
+		result := DB.
+			Model(&Channel{}).
+			Where("id = ? AND key = ?", channel.ID, channel.Key).
+			UpdateColumns(map[string]any{
+				"key":      key,
+				"key_hash": secret.Hash(plaintext),
+			})
+		if result.Error != nil {
+			return count, result.Error
+		}
+
+		count += int(result.RowsAffected)
+	}
+
+	configCount, err := encryptChannelConfigs()
+
+	return count + configCount, err
+}
+
+func encryptChannelConfigs() (int, error) {
+	var channels []*Channel
+
+	err := DB.
+		Select("id", "configs").
+		Where("configs IS NOT NULL AND configs <> ''").
+		Find(&channels).
+		Error
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for _, channel := range channels {
+		configs, changed, err := encryptSecretConfigs(channel.Configs)
+		if err != nil {
+			return count, err
+		}
+
+		if !changed {
+			continue
+		}
+
+		// skip the hooks, the configs are encrypted already
+		result := DB.
+			Model(&Channel{ID: channel.ID}).
+			Select("configs").
+			UpdateColumns(&Channel{Configs: configs})
+		if result.Error != nil {
+			return count, result.Error
+		}
+
+		count += int(result.RowsAffected)
+	}
+
+	return count, nil
+}
+
 func ClearLastTestErrorAt(id int) error {
 	result := DB.Model(&Channel{}).
 		Where("id = ?", id).
@@ -0,0 +1,134 @@
+package model_test
+
+import (
+	"context"
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/secret"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParamOverridesPatches(t *testing.T) {
+	overrides := model.ParamOverrides{
+		model.ParamOverrideAllModels: {"temperature": 1},
+		"deepseek-r1":                {"temperature": 0.6},
+		"DeepSeek-R1-0528":           {"top_p": 0.95},
+	}
+
+	assert.Equal(t, []map[string]any{
+		{"temperature": 1},
+		{"temperature": 0.6},
+		{"top_p": 0.95},
+	}, overrides.Patches("deepseek-r1", "DeepSeek-R1-0528"))
+
+	assert.Equal(t, []map[string]any{
+		{"temperature": 1},
+		{"temperature": 0.6},
+	}, overrides.Patches("deepseek-r1", "deepseek-r1"))
+
+	assert.Equal(t, []map[string]any{
+		{"temperature": 1},
+	}, overrides.Patches("gpt-4o", "gpt-4o"))
+
+	assert.Empty(t, model.ParamOverrides(nil).Patches("gpt-4o", "gpt-4o"))
+}
+
+func TestChannelSecretsDecryption(t *testing.T) {
+	c, err := secret.NewAESGCM([]byte(strings.Repeat("k", 32)))
+	require.NoError(t, err)
+	secret.Register(c)
+
+	encrypt := func(value string) string {
+		ciphertext, err := c.Encrypt(context.Background(), []byte(value))
+		require.NoError(t, err)
+
+		return "enc:" + secret.AESGCMName + ":" + base64.StdEncoding.EncodeToString(ciphertext)
+	}
+
+	channel := &model.Channel{
+		ID:  1,
+		Key: encrypt("sk-test"),
+		Configs: model.ChannelConfigs{
+			"client_secret": encrypt("secret"),
+			"api_version":   "2024-10-21",
+		},
+	}
+
+	key, err := channel.GetKey()
+	require.NoError(t, err)
+	assert.Equal(t, "sk-test", key)
+
+	configs, err := channel.GetConfigs()
+	require.NoError(t, err)
+	assert.Equal(t, model.ChannelConfigs{
+		"client_secret": "secret",
+		"api_version":   "2024-10-21",
+	}, configs)
+	assert.NotEqual(t, "secret", channel.Configs["client_secret"])
+	require.NoError(t, channel.CheckSecrets())
+
+	// a key encrypted by an unknown cipher is never returned as is
+	channel.Key = "enc:unknown:c2stdGVzdA=="
+	key, err = channel.GetKey()
+	require.ErrorIs(t, err, secret.ErrCipherNotFound)
+	assert.Empty(t, key)
+	require.Error(t, channel.CheckSecrets())
+
+	channel.Key = "sk-plain"
+	channel.Configs["client_secret"] = "enc:unknown:c2VjcmV0"
+	require.ErrorIs(t, channel.CheckSecrets(), secret.ErrCipherNotFound)
+}
+
+func TestUpdateChannelKeepsKeyHashOfEncryptedKey(t *testing.T) {
+	prevDB := model.DB
+	prevUsingSQLite := common.UsingSQLite
+	prevCipher := secret.Default
+
+	testDB, err := model.OpenSQLite(filepath.Join(t.TempDir(), "channel-key-hash.db"))
+	require.NoError(t, err)
+
+	model.DB = testDB
+	common.UsingSQLite = true
+
+	c, err := secret.NewAESGCM([]byte(strings.Repeat("k", 32)))
+	require.NoError(t, err)
+	secret.Register(c)
+	secret.Default = c
+
+	t.Cleanup(func() {
+		model.DB = prevDB
+		common.UsingSQLite = prevUsingSQLite
+		secret.Default = prevCipher
+	})
+
+	require.NoError(t, testDB.AutoMigrate(&model.Channel{}, &model.ModelConfig{}))
+	require.NoError(t, testDB.Create(&model.Channel{Name: "encrypted", Key: "sk-search"}).Error)
+
+	var stored model.Channel
+	require.NoError(t, testDB.First(&stored).Error)
+	require.True(t, secret.IsEncrypted(stored.Key))
+
+	// the api sends back the encrypted key when the key is not edited
+	require.NoError(t, model.UpdateChannel(&model.Channel{
+		ID:       stored.ID,
+		Name:     "renamed",
+		Key:      stored.Key,
+		Priority: 10,
+	}))
+
+	channels, total, err := model.GetChannels(1, 10, 0, "", "sk-search", 0, "", "")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	assert.Equal(t, "renamed", channels[0].Name)
+
+	channels, total, err = model.SearchChannels("", 1, 10, 0, "", "sk-search", 0, "", "")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	assert.Equal(t, stored.ID, channels[0].ID)
+}
@@ -55,6 +55,10 @@ const (
 	ChannelTypeFake                    ChannelType = 53
 	ChannelTypeAntLing                 ChannelType = 54
 	ChannelTypeFakeError               ChannelType = 55
+	ChannelTypeKling                   ChannelType = 56
+	ChannelTypeSuno                    ChannelType = 57
+	ChannelTypeHuggingFace             ChannelType = 58
+	ChannelTypeNvidiaNIM               ChannelType = 59
 )
 
 var channelTypeNames = map[ChannelType]string{
@@ -101,4 +105,8 @@ var channelTypeNames = map[ChannelType]string{
 	ChannelTypeFake:                    "fake",
 	ChannelTypeAntLing:                 "antling",
 	ChannelTypeFakeError:               "fake-error",
+	ChannelTypeKling:                   "kling",
+	ChannelTypeSuno:                    "suno",
+	ChannelTypeHuggingFace:             "huggingface",
+	ChannelTypeNvidiaNIM:               "nvidia-nim",
 }
@@ -1,6 +1,9 @@
 package model
 
-import "reflect"
+import (
+	"reflect"
+	"strconv"
+)
 
 type ModelConfigKey string
 
@@ -8,10 +11,51 @@ const (
 	ModelConfigMaxContextTokensKey ModelConfigKey = "max_context_tokens"
 	ModelConfigMaxInputTokensKey   ModelConfigKey = "max_input_tokens"
 	ModelConfigMaxOutputTokensKey  ModelConfigKey = "max_output_tokens"
+	// max_tokens used when the request omits it, for the apis requiring it
+	ModelConfigDefaultMaxTokensKey ModelConfigKey = "default_max_tokens"
 	ModelConfigVisionKey           ModelConfigKey = "vision"
 	ModelConfigToolChoiceKey       ModelConfigKey = "tool_choice"
 	ModelConfigSupportFormatsKey   ModelConfigKey = "support_formats"
 	ModelConfigSupportVoicesKey    ModelConfigKey = "support_voices"
+	// default reasoning effort and summary injected when converting
+	// chat completions to the responses api for reasoning models
+	ModelConfigReasoningEffortKey  ModelConfigKey = "reasoning_effort"
+	ModelConfigReasoningSummaryKey ModelConfigKey = "reasoning_summary"
+	// circuit breaker error budget overrides, see the channel fields of the same name
+	ModelConfigCircuitBreakerErrorRateKey  ModelConfigKey = "circuit_breaker_error_rate"
+	ModelConfigCircuitBreakerLatencySLOKey ModelConfigKey = "circuit_breaker_latency_slo_ms"
+	// data residency tags that apply to every channel serving the model
+	ModelConfigDataResidencyKey ModelConfigKey = "data_residency"
+	// jinja chat template rendering the chat messages into the prompt of the
+	// completions, for the local backends without a chat endpoint and the
+	// channels opted in by the chat_template channel config
+	ModelConfigChatTemplateKey         ModelConfigKey = "chat_template"
+	ModelConfigChatTemplateBOSTokenKey ModelConfigKey = "chat_template_bos_token"
+	ModelConfigChatTemplateEOSTokenKey ModelConfigKey = "chat_template_eos_token"
+	// how the pdf documents are sent to the models without native pdf input,
+	// one of the PDFConversion values
+	ModelConfigPDFConversionKey ModelConfigKey = "pdf_conversion"
+	// the beta header raising the max output tokens over the standard ceiling,
+	// the max_output_tokens is then the ceiling with the beta
+	ModelConfigLongOutputBetaKey          ModelConfigKey = "long_output_beta"
+	ModelConfigStandardMaxOutputTokensKey ModelConfigKey = "standard_max_output_tokens"
+	// the bandit shifting the traffic of the model to the channel with the
+	// best feedback ratings and error rates, the channel costs map the channel
+	// ids to their relative costs
+	ModelConfigChannelBanditKey                ModelConfigKey = "channel_bandit"
+	ModelConfigChannelBanditExplorationRateKey ModelConfigKey = "channel_bandit_exploration_rate"
+	ModelConfigChannelBanditCostWeightKey      ModelConfigKey = "channel_bandit_cost_weight"
+	ModelConfigChannelBanditChannelCostsKey    ModelConfigKey = "channel_bandit_channel_costs"
+)
+
+const (
+	// the pdf is sent as a file part, for the backends reading it natively
+	PDFConversionFile = "file"
+	// the text extracted from every page is sent
+	PDFConversionText = "text"
+	// the images of every page are sent, the pages without images fall back
+	// to their text
+	PDFConversionImage = "image"
 )
 
 type ModelConfigOption func(config map[ModelConfigKey]any)
@@ -34,6 +78,12 @@ func WithModelConfigMaxOutputTokens(maxOutputTokens int) ModelConfigOption {
 	}
 }
 
+func WithModelConfigDefaultMaxTokens(defaultMaxTokens int) ModelConfigOption {
+	return func(config map[ModelConfigKey]any) {
+		config[ModelConfigDefaultMaxTokensKey] = defaultMaxTokens
+	}
+}
+
 func WithModelConfigVision(vision bool) ModelConfigOption {
 	return func(config map[ModelConfigKey]any) {
 		config[ModelConfigVisionKey] = vision
@@ -58,6 +108,82 @@ func WithModelConfigSupportVoices(supportVoices []string) ModelConfigOption {
 	}
 }
 
+func WithModelConfigReasoningEffort(reasoningEffort string) ModelConfigOption {
+	return func(config map[ModelConfigKey]any) {
+		config[ModelConfigReasoningEffortKey] = reasoningEffort
+	}
+}
+
+func WithModelConfigReasoningSummary(reasoningSummary string) ModelConfigOption {
+	return func(config map[ModelConfigKey]any) {
+		config[ModelConfigReasoningSummaryKey] = reasoningSummary
+	}
+}
+
+func WithModelConfigCircuitBreakerErrorRate(errorRate float64) ModelConfigOption {
+	return func(config map[ModelConfigKey]any) {
+		config[ModelConfigCircuitBreakerErrorRateKey] = errorRate
+	}
+}
+
+func WithModelConfigCircuitBreakerLatencySLO(latencySLOMs int) ModelConfigOption {
+	return func(config map[ModelConfigKey]any) {
+		config[ModelConfigCircuitBreakerLatencySLOKey] = latencySLOMs
+	}
+}
+
+func WithModelConfigDataResidency(dataResidency []string) ModelConfigOption {
+	return func(config map[ModelConfigKey]any) {
+		config[ModelConfigDataResidencyKey] = dataResidency
+	}
+}
+
+func WithModelConfigChatTemplate(chatTemplate, bosToken, eosToken string) ModelConfigOption {
+	return func(config map[ModelConfigKey]any) {
+		config[ModelConfigChatTemplateKey] = chatTemplate
+		if bosToken != "" {
+			config[ModelConfigChatTemplateBOSTokenKey] = bosToken
+		}
+
+		if eosToken != "" {
+			config[ModelConfigChatTemplateEOSTokenKey] = eosToken
+		}
+	}
+}
+
+func WithModelConfigPDFConversion(conversion string) ModelConfigOption {
+	return func(config map[ModelConfigKey]any) {
+		config[ModelConfigPDFConversionKey] = conversion
+	}
+}
+
+func WithModelConfigLongOutputBeta(beta string, standardMaxOutputTokens int) ModelConfigOption {
+	return func(config map[ModelConfigKey]any) {
+		config[ModelConfigLongOutputBetaKey] = beta
+		config[ModelConfigStandardMaxOutputTokensKey] = standardMaxOutputTokens
+	}
+}
+
+func WithModelConfigChannelBandit(
+	explorationRate, costWeight float64,
+	channelCosts map[int]float64,
+) ModelConfigOption {
+	return func(config map[ModelConfigKey]any) {
+		config[ModelConfigChannelBanditKey] = true
+		config[ModelConfigChannelBanditExplorationRateKey] = explorationRate
+		config[ModelConfigChannelBanditCostWeightKey] = costWeight
+
+		if len(channelCosts) > 0 {
+			costs := make(map[string]any, len(channelCosts))
+			for channelID, cost := range channelCosts {
+				costs[strconv.Itoa(channelID)] = cost
+			}
+
+			config[ModelConfigChannelBanditChannelCostsKey] = costs
+		}
+	}
+}
+
 func NewModelConfig(opts ...ModelConfigOption) map[ModelConfigKey]any {
 	config := make(map[ModelConfigKey]any)
 	for _, opt := range opts {
@@ -99,23 +225,59 @@ func GetModelConfigUint(config map[ModelConfigKey]any, key ModelConfigKey) (uint
 
 func GetModelConfigFloat(config map[ModelConfigKey]any, key ModelConfigKey) (float64, bool) {
 	if v, ok := config[key]; ok {
-		value := reflect.ValueOf(v)
-		if value.CanFloat() {
-			return value.Float(), true
-		}
+		return toModelConfigFloat(v)
+	}
 
-		if value.CanInt() {
-			return float64(value.Int()), true
-		}
+	return 0, false
+}
 
-		if value.CanUint() {
-			return float64(value.Uint()), true
-		}
+func toModelConfigFloat(v any) (float64, bool) {
+	value := reflect.ValueOf(v)
+	if value.CanFloat() {
+		return value.Float(), true
+	}
+
+	if value.CanInt() {
+		return float64(value.Int()), true
+	}
+
+	if value.CanUint() {
+		return float64(value.Uint()), true
 	}
 
 	return 0, false
 }
 
+// GetModelConfigFloatMap returns the object of the numbers, the values which
+// are not numbers are skipped
+func GetModelConfigFloatMap(
+	config map[ModelConfigKey]any,
+	key ModelConfigKey,
+) (map[string]float64, bool) {
+	v, ok := config[key]
+	if !ok {
+		return nil, false
+	}
+
+	if m, ok := v.(map[string]float64); ok {
+		return m, true
+	}
+
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+
+	result := make(map[string]float64, len(m))
+	for k, v := range m {
+		if f, ok := toModelConfigFloat(v); ok {
+			result[k] = f
+		}
+	}
+
+	return result, true
+}
+
 func GetModelConfigStringSlice(config map[ModelConfigKey]any, key ModelConfigKey) ([]string, bool) {
 	v, ok := config[key]
 	if !ok {
@@ -143,6 +305,13 @@ func GetModelConfigStringSlice(config map[ModelConfigKey]any, key ModelConfigKey
 	return nil, false
 }
 
+func GetModelConfigString(config map[ModelConfigKey]any, key ModelConfigKey) (string, bool) {
+	if v, ok := config[key].(string); ok {
+		return v, true
+	}
+	return "", false
+}
+
 func GetModelConfigBool(config map[ModelConfigKey]any, key ModelConfigKey) (bool, bool) {
 	if v, ok := config[key].(bool); ok {
 		return v, true
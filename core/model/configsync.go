@@ -0,0 +1,216 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/labring/aiproxy/core/monitor"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// the kinds of the resources managed by the config sync
+const (
+	ConfigSyncKindChannel     = "channel"
+	ConfigSyncKindModelConfig = "model_config"
+	ConfigSyncKindToken       = "token"
+)
+
+// ConfigSyncResource records a resource created or adopted by the config sync,
+// only the managed resources are deleted when they leave the desired state
+type ConfigSyncResource struct {
+	Kind string `gorm:"size:32;primaryKey"  json:"kind"`
+	// Name is the channel name, the model, or the group/name of the token
+	Name       string `gorm:"size:256;primaryKey" json:"name"`
+	ResourceID int    `                           json:"resource_id,omitempty"`
+	// Hash is the hash of the applied spec, the unchanged specs are skipped
+	Hash      string    `gorm:"size:64"             json:"hash"`
+	UpdatedAt time.Time `                           json:"updated_at"`
+}
+
+func GetConfigSyncResources(kind string) ([]ConfigSyncResource, error) {
+	var resources []ConfigSyncResource
+
+	tx := DB.Order("kind, name")
+	if kind != "" {
+		tx = tx.Where("kind = ?", kind)
+	}
+
+	err := tx.Find(&resources).Error
+
+	return resources, err
+}
+
+// ConfigSyncTx applies the changes of a config sync, all of them are made in a
+// single transaction so a failed sync leaves nothing half applied
+type ConfigSyncTx struct {
+	tx  *gorm.DB
+	now time.Time
+	// changed reports whether the caches must be reloaded after the commit
+	changed    bool
+	channelIDs []int
+	tokenKeys  []string
+}
+
+// ConfigSyncTransaction runs the sync in a transaction, the caches of the
+// changed resources are refreshed once it is committed
+func ConfigSyncTransaction(fn func(tx *ConfigSyncTx) error) error {
+	sync := &ConfigSyncTx{now: time.Now()}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		sync.tx = tx
+		return fn(sync)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !sync.changed {
+		return nil
+	}
+
+	_ = InitModelConfigAndChannelCache()
+
+	for _, id := range sync.channelIDs {
+		_ = monitor.ClearChannelAllModelErrors(context.Background(), id)
+		monitor.ResetChannelBreakers(int64(id))
+		monitor.ResetChannelBandit(int64(id))
+	}
+
+	for _, key := range sync.tokenKeys {
+		if err := CacheDeleteToken(key); err != nil {
+			log.Error("delete token from cache failed: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (s *ConfigSyncTx) GetResources(kind string) ([]ConfigSyncResource, error) {
+	var resources []ConfigSyncResource
+
+	err := s.tx.Where("kind = ?", kind).Order("name").Find(&resources).Error
+
+	return resources, err
+}
+
+func (s *ConfigSyncTx) SaveResource(resource ConfigSyncResource) error {
+	resource.UpdatedAt = s.now
+
+	return s.tx.
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "kind"}, {Name: "name"}},
+			DoUpdates: clause.AssignmentColumns(
+				[]string{"resource_id", "hash", "updated_at"},
+			),
+		}).
+		Create(&resource).Error
+}
+
+func (s *ConfigSyncTx) DeleteResource(kind, name string) error {
+	return s.tx.
+		Where("kind = ? AND name = ?", kind, name).
+		Delete(&ConfigSyncResource{}).Error
+}
+
+func (s *ConfigSyncTx) GetModelConfig(model string) (ModelConfig, error) {
+	config := ModelConfig{}
+	err := s.tx.Model(&ModelConfig{}).
+		Where("model = ?", model).
+		Omit("created_at", "updated_at").
+		First(&config).
+		Error
+
+	return config, HandleNotFound(err, ErrModelConfigNotFound)
+}
+
+func (s *ConfigSyncTx) SaveModelConfig(config ModelConfig) error {
+	s.changed = true
+	return saveModelConfigWithVersion(s.tx, &config, s.now)
+}
+
+func (s *ConfigSyncTx) DeleteModelConfig(model string) error {
+	s.changed = true
+	return deleteModelConfig(s.tx, model, s.now)
+}
+
+// GetChannelsByName returns the channels of the name
+func (s *ConfigSyncTx) GetChannelsByName(name string) (channels []*Channel, err error) {
+	err = s.tx.Where("name = ?", name).Order("id").Find(&channels).Error
+	return channels, err
+}
+
+func (s *ConfigSyncTx) InsertChannel(channel *Channel) error {
+	if err := checkModelConfigExist(s.tx, channel.Models); err != nil {
+		return err
+	}
+
+	s.changed = true
+
+	return s.tx.Create(channel).Error
+}
+
+func (s *ConfigSyncTx) UpdateChannel(channel *Channel) error {
+	s.changed = true
+	s.channelIDs = append(s.channelIDs, channel.ID)
+
+	return updateChannel(s.tx, channel)
+}
+
+func (s *ConfigSyncTx) UpdateChannelStatus(id, status int) error {
+	s.changed = true
+
+	result := s.tx.Model(&Channel{}).
+		Where("id = ?", id).
+		Update("status", status)
+
+	return HandleUpdateResult(result, ErrChannelNotFound)
+}
+
+func (s *ConfigSyncTx) DeleteChannel(id int) error {
+	s.changed = true
+	s.channelIDs = append(s.channelIDs, id)
+
+	return HandleUpdateResult(s.tx.Delete(&Channel{ID: id}), ErrChannelNotFound)
+}
+
+// GetGroupTokenByName returns the token of the group by its name
+func (s *ConfigSyncTx) GetGroupTokenByName(group, name string) (*Token, error) {
+	var token Token
+
+	err := s.tx.
+		Where("group_id = ? and name = ?", group, name).
+		First(&token).Error
+
+	return &token, HandleNotFound(err, ErrTokenNotFound)
+}
+
+func (s *ConfigSyncTx) InsertToken(token *Token) error {
+	s.changed = true
+	return insertToken(s.tx, token, true, false)
+}
+
+func (s *ConfigSyncTx) UpdateToken(id int, update UpdateTokenRequest) error {
+	token, err := updateToken(s.tx, id, update)
+	if err != nil {
+		return err
+	}
+
+	s.changed = true
+	s.tokenKeys = append(s.tokenKeys, token.Key)
+
+	return nil
+}
+
+func (s *ConfigSyncTx) DeleteToken(id int) error {
+	token := Token{ID: id}
+	if err := deleteToken(s.tx, &token); err != nil {
+		return err
+	}
+
+	s.changed = true
+	s.tokenKeys = append(s.tokenKeys, token.Key)
+
+	return nil
+}
@@ -79,52 +79,37 @@ func GetConsumptionRanking(
 	limit, offset := toLimitOffset(page, perPage)
 
 	var (
-		baseQuery   *gorm.DB
-		groupField  string
-		selectField string
+		daily, monthly *summaryRollup
+		groupField     string
 	)
 
 	switch rankingType {
 	case ConsumptionRankingTypeChannel:
 		groupField = "channel_id"
-		selectField = "channel_id"
-		baseQuery = buildConsumptionRankingTimeQuery(
-			LogDB.Model(&Summary{}),
-			"hour_timestamp",
-			start,
-			end,
-		)
+		daily, monthly = summaryDailyRollup, summaryMonthlyRollup
 	case ConsumptionRankingTypeModel:
 		groupField = "model"
-		selectField = "model"
-		baseQuery = buildConsumptionRankingTimeQuery(
-			LogDB.Model(&Summary{}),
-			"hour_timestamp",
-			start,
-			end,
-		)
-	case ConsumptionRankingTypeGroup:
-		groupField = "group_id"
-		selectField = "group_id"
-		baseQuery = buildConsumptionRankingTimeQuery(
-			LogDB.Model(&GroupSummary{}),
-			"hour_timestamp",
-			start,
-			end,
-		)
+		daily, monthly = summaryDailyRollup, summaryMonthlyRollup
 	default:
 		groupField = "group_id"
-		selectField = "group_id"
-		baseQuery = buildConsumptionRankingTimeQuery(
-			LogDB.Model(&GroupSummary{}),
-			"hour_timestamp",
-			start,
-			end,
-		)
+		daily, monthly = groupSummaryDailyRollup, groupSummaryMonthlyRollup
 	}
 
+	selectField := groupField
 	normalizedOrder, orderClause := normalizeConsumptionRankingOrder(order, groupField)
 
+	// the ranges pruned from the hourly summaries are read from the rollups
+	baseQuery, err := getSummaryRowsTable(
+		daily,
+		monthly,
+		start,
+		end,
+		selectField+", request_count, used_amount, input_tokens, output_tokens, total_tokens",
+	)
+	if err != nil {
+		return nil, 0, normalizedOrder, err
+	}
+
 	var total int64
 	if err := baseQuery.Session(&gorm.Session{}).
 		Distinct(selectField).
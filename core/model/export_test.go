@@ -5,3 +5,11 @@ var (
 	ToLimitOffset              = toLimitOffset
 	AggregateDataToSpanForTest = aggregateDataToSpan
 )
+
+var (
+	SpillBatchUpdatesForTest   = spillBatchUpdates
+	RestoreSpilledBatchUpdates = restoreSpilledBatchUpdates
+	RecoverRestoringSpillFiles = recoverRestoringBatchSpillFiles
+)
+
+var CleanAuditLogForTest = cleanAuditLog
@@ -0,0 +1,143 @@
+package model
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// FingerprintUsage is a system_fingerprint reported by a channel for a model
+type FingerprintUsage struct {
+	SystemFingerprint string    `json:"system_fingerprint"`
+	Requests          int64     `json:"requests"`
+	SeededRequests    int64     `json:"seeded_requests"`
+	FirstSeen         time.Time `json:"first_seen"`
+	LastSeen          time.Time `json:"last_seen"`
+}
+
+// FingerprintDrift lists the fingerprints of a channel model in the order they
+// were first seen, more than one fingerprint means the upstream changed the
+// backend serving the model
+type FingerprintDrift struct {
+	ChannelID    int                `json:"channel_id"`
+	Model        string             `json:"model"`
+	Drifted      bool               `json:"drifted"`
+	Fingerprints []FingerprintUsage `json:"fingerprints"`
+}
+
+// GetFingerprintLogs returns the logs with a system_fingerprint, the newest
+// logs are kept when the limit is reached
+func GetFingerprintLogs(
+	start, end time.Time,
+	channelID int,
+	modelName string,
+	limit int,
+) ([]*Log, error) {
+	var logs []*Log
+
+	tx := LogDB.
+		Model(&Log{}).
+		Select("created_at", "model", "channel_id", "seed", "system_fingerprint").
+		Where("system_fingerprint IS NOT NULL").
+		Where("created_at BETWEEN ? AND ?", start, end)
+
+	if channelID != 0 {
+		tx = tx.Where("channel_id = ?", channelID)
+	}
+
+	if modelName != "" {
+		tx = tx.Where("model = ?", modelName)
+	}
+
+	err := tx.
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&logs).Error
+
+	return logs, err
+}
+
+// FingerprintDriftReport groups the fingerprints of the logs by channel and
+// model, the drifted channel models are listed first
+func FingerprintDriftReport(logs []*Log) []FingerprintDrift {
+	type driftKey struct {
+		channelID int
+		model     string
+	}
+
+	type fingerprintKey struct {
+		driftKey
+		fingerprint string
+	}
+
+	drifts := make(map[driftKey]*FingerprintDrift)
+	usages := make(map[fingerprintKey]*FingerprintUsage)
+
+	for _, log := range logs {
+		if log.SystemFingerprint == "" {
+			continue
+		}
+
+		dk := driftKey{channelID: log.ChannelID, model: log.Model}
+		if _, ok := drifts[dk]; !ok {
+			drifts[dk] = &FingerprintDrift{ChannelID: log.ChannelID, Model: log.Model}
+		}
+
+		fk := fingerprintKey{driftKey: dk, fingerprint: string(log.SystemFingerprint)}
+
+		usage, ok := usages[fk]
+		if !ok {
+			usage = &FingerprintUsage{
+				SystemFingerprint: fk.fingerprint,
+				FirstSeen:         log.CreatedAt,
+				LastSeen:          log.CreatedAt,
+			}
+			usages[fk] = usage
+		}
+
+		usage.Requests++
+		if log.Seed != nil {
+			usage.SeededRequests++
+		}
+
+		if log.CreatedAt.Before(usage.FirstSeen) {
+			usage.FirstSeen = log.CreatedAt
+		}
+
+		if log.CreatedAt.After(usage.LastSeen) {
+			usage.LastSeen = log.CreatedAt
+		}
+	}
+
+	for fk, usage := range usages {
+		drift := drifts[fk.driftKey]
+		drift.Fingerprints = append(drift.Fingerprints, *usage)
+	}
+
+	result := make([]FingerprintDrift, 0, len(drifts))
+	for _, drift := range drifts {
+		slices.SortFunc(drift.Fingerprints, func(a, b FingerprintUsage) int {
+			return a.FirstSeen.Compare(b.FirstSeen)
+		})
+
+		drift.Drifted = len(drift.Fingerprints) > 1
+		result = append(result, *drift)
+	}
+
+	slices.SortFunc(result, func(a, b FingerprintDrift) int {
+		switch {
+		case a.Drifted != b.Drifted:
+			if a.Drifted {
+				return -1
+			}
+
+			return 1
+		case a.ChannelID != b.ChannelID:
+			return a.ChannelID - b.ChannelID
+		default:
+			return strings.Compare(a.Model, b.Model)
+		}
+	})
+
+	return result
+}
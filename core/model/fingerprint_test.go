@@ -0,0 +1,66 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/labring/aiproxy/core/model"
+)
+
+func TestFingerprintDriftReport(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	seed := int64(42)
+
+	logs := []*model.Log{
+		{
+			CreatedAt:         base.Add(3 * time.Minute),
+			ChannelID:         1,
+			Model:             "gpt-4o",
+			SystemFingerprint: "fp_b",
+			Seed:              &seed,
+		},
+		{
+			CreatedAt:         base.Add(2 * time.Minute),
+			ChannelID:         1,
+			Model:             "gpt-4o",
+			SystemFingerprint: "fp_a",
+		},
+		{
+			CreatedAt:         base,
+			ChannelID:         1,
+			Model:             "gpt-4o",
+			SystemFingerprint: "fp_a",
+			Seed:              &seed,
+		},
+		{CreatedAt: base, ChannelID: 2, Model: "gpt-4o", SystemFingerprint: "fp_a"},
+		{CreatedAt: base, ChannelID: 2, Model: "gpt-4o"},
+	}
+
+	report := model.FingerprintDriftReport(logs)
+	if len(report) != 2 {
+		t.Fatalf("expected 2 channel models, got %d", len(report))
+	}
+
+	drifted := report[0]
+	if drifted.ChannelID != 1 || !drifted.Drifted || len(drifted.Fingerprints) != 2 {
+		t.Fatalf("expected channel 1 to drift, got %+v", drifted)
+	}
+
+	first := drifted.Fingerprints[0]
+	if first.SystemFingerprint != "fp_a" ||
+		first.Requests != 2 ||
+		first.SeededRequests != 1 ||
+		!first.FirstSeen.Equal(base) ||
+		!first.LastSeen.Equal(base.Add(2*time.Minute)) {
+		t.Fatalf("unexpected first fingerprint: %+v", first)
+	}
+
+	if drifted.Fingerprints[1].SystemFingerprint != "fp_b" {
+		t.Fatalf("expected fp_b to be seen last, got %+v", drifted.Fingerprints[1])
+	}
+
+	stable := report[1]
+	if stable.ChannelID != 2 || stable.Drifted || stable.Fingerprints[0].Requests != 1 {
+		t.Fatalf("expected channel 2 to be stable, got %+v", stable)
+	}
+}
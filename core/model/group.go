@@ -34,20 +34,35 @@ type Group struct {
 	UsedAmount             float64                 `json:"used_amount"              gorm:"index"`
 	RequestCount           int                     `json:"request_count"            gorm:"index"`
 	AvailableSets          []string                `json:"available_sets,omitempty" gorm:"serializer:fastjson;type:text"`
+	DataResidency          []string                `json:"data_residency,omitempty" gorm:"serializer:fastjson;type:text"`
 
 	BalanceAlertEnabled   bool    `gorm:"default:false" json:"balance_alert_enabled"`
 	BalanceAlertThreshold float64 `gorm:"default:0"     json:"balance_alert_threshold"`
+
+	// FileStorageQuota is the max bytes of the files uploaded by the group,
+	// zero means unlimited
+	FileStorageQuota int64 `gorm:"default:0" json:"file_storage_quota,omitempty"`
+
+	// FairShareWeight is the share of the group in the admission of the
+	// requests when the relay is saturated, zero means a weight of one
+	FairShareWeight float64 `gorm:"default:0" json:"fair_share_weight,omitempty"`
+
+	// Guardrails are the default and the clamped request parameters of the
+	// group
+	Guardrails *GroupGuardrails `gorm:"serializer:fastjson;type:text" json:"guardrails,omitempty"`
 }
 
 func (g *Group) BeforeSave(_ *gorm.DB) error {
 	if len(g.ID) > 64 {
 		return errors.New("group id length too long")
 	}
-	return nil
+
+	return g.Guardrails.Validate()
 }
 
 func (g *Group) BeforeDelete(tx *gorm.DB) (err error) {
-	err = tx.Model(&Token{}).Where("group_id = ?", g.ID).Delete(&Token{}).Error
+	// the tokens, archived ones included, are deleted with the group
+	err = tx.Unscoped().Model(&Token{}).Where("group_id = ?", g.ID).Delete(&Token{}).Error
 	if err != nil {
 		return err
 	}
@@ -65,6 +80,11 @@ func (g *Group) BeforeDelete(tx *gorm.DB) (err error) {
 		return err
 	}
 
+	err = tx.Model(&PromptTemplate{}).Where("group_id = ?", g.ID).Delete(&PromptTemplate{}).Error
+	if err != nil {
+		return err
+	}
+
 	return tx.Model(&GroupModelConfig{}).
 		Where("group_id = ?", g.ID).
 		Delete(&GroupModelConfig{}).
@@ -192,8 +212,14 @@ type UpdateGroupRequest struct {
 	RPMRatio              *float64  `json:"rpm_ratio,omitempty"`
 	TPMRatio              *float64  `json:"tpm_ratio,omitempty"`
 	AvailableSets         *[]string `json:"available_sets,omitempty"`
+	DataResidency         *[]string `json:"data_residency,omitempty"`
 	BalanceAlertEnabled   *bool     `json:"balance_alert_enabled"`
 	BalanceAlertThreshold *float64  `json:"balance_alert_threshold"`
+	FileStorageQuota      *int64    `json:"file_storage_quota,omitempty"`
+	FairShareWeight       *float64  `json:"fair_share_weight,omitempty"`
+	// Guardrails replaces the guardrails of the group, an empty object clears
+	// them
+	Guardrails *GroupGuardrails `json:"guardrails,omitempty"`
 }
 
 func UpdateGroup(id string, update UpdateGroupRequest) (group *Group, err error) {
@@ -233,6 +259,12 @@ func UpdateGroup(id string, update UpdateGroupRequest) (group *Group, err error)
 		selects = append(selects, "available_sets")
 	}
 
+	if update.DataResidency != nil {
+		group.DataResidency = *update.DataResidency
+
+		selects = append(selects, "data_residency")
+	}
+
 	if update.BalanceAlertEnabled != nil {
 		group.BalanceAlertEnabled = *update.BalanceAlertEnabled
 
@@ -245,6 +277,26 @@ func UpdateGroup(id string, update UpdateGroupRequest) (group *Group, err error)
 		selects = append(selects, "balance_alert_threshold")
 	}
 
+	if update.FileStorageQuota != nil {
+		group.FileStorageQuota = *update.FileStorageQuota
+
+		selects = append(selects, "file_storage_quota")
+	}
+
+	if update.FairShareWeight != nil {
+		group.FairShareWeight = *update.FairShareWeight
+
+		selects = append(selects, "fair_share_weight")
+	}
+
+	if update.Guardrails != nil {
+		if !update.Guardrails.IsEmpty() {
+			group.Guardrails = update.Guardrails
+		}
+
+		selects = append(selects, "guardrails")
+	}
+
 	if group.Status != 0 {
 		selects = append(selects, "status")
 	}
@@ -31,10 +31,16 @@ type GroupCache struct {
 	RPMRatio      float64                  `json:"rpm_ratio"      redis:"rpm_r"`
 	TPMRatio      float64                  `json:"tpm_ratio"      redis:"tpm_r"`
 	AvailableSets redisStringSlice         `json:"available_sets" redis:"ass"`
+	DataResidency redisStringSlice         `json:"data_residency" redis:"dr"`
 	ModelConfigs  redisGroupModelConfigMap `json:"model_configs"  redis:"mc"`
 
 	BalanceAlertEnabled   bool    `json:"balance_alert_enabled"   redis:"bae"`
 	BalanceAlertThreshold float64 `json:"balance_alert_threshold" redis:"bat"`
+
+	FileStorageQuota int64   `json:"file_storage_quota" redis:"fsq"`
+	FairShareWeight  float64 `json:"fair_share_weight"  redis:"fsw"`
+
+	Guardrails GroupGuardrails `json:"guardrails" redis:"gr"`
 }
 
 func (g *GroupCache) GetAvailableSets() []string {
@@ -50,6 +56,11 @@ func (g *Group) ToGroupCache() *GroupCache {
 		modelConfigs[modelConfig.Model] = modelConfig
 	}
 
+	var guardrails GroupGuardrails
+	if g.Guardrails != nil {
+		guardrails = *g.Guardrails
+	}
+
 	return &GroupCache{
 		ID:            g.ID,
 		Status:        g.Status,
@@ -57,24 +68,27 @@ func (g *Group) ToGroupCache() *GroupCache {
 		RPMRatio:      g.RPMRatio,
 		TPMRatio:      g.TPMRatio,
 		AvailableSets: g.AvailableSets,
+		DataResidency: g.DataResidency,
 		ModelConfigs:  modelConfigs,
 
 		BalanceAlertEnabled:   g.BalanceAlertEnabled,
 		BalanceAlertThreshold: g.BalanceAlertThreshold,
+
+		FileStorageQuota: g.FileStorageQuota,
+		FairShareWeight:  g.FairShareWeight,
+
+		Guardrails: guardrails,
 	}
 }
 
 func CacheDeleteGroup(id string) error {
 	cacheDeleteModelLocal(getGroupCacheKey(id))
 
-	if !common.RedisEnabled {
-		return nil
-	}
+	cacheKey := common.RedisKeyf(GroupCacheKey, id)
 
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
-
-	return common.RDB.Del(ctx, common.RedisKeyf(GroupCacheKey, id)).Err()
+	return cacheWriteRedis(cacheKey, func(ctx context.Context) error {
+		return common.RDB.Del(ctx, cacheKey).Err()
+	})
 }
 
 var updateGroupRPMRatioScript = redis.NewScript(`
@@ -90,19 +104,16 @@ func CacheUpdateGroupRPMRatio(id string, rpmRatio float64) error {
 		return true
 	})
 
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
+	cacheKey := common.RedisKeyf(GroupCacheKey, id)
 
-	return updateGroupRPMRatioScript.Run(
-		ctx,
-		common.RDB,
-		[]string{common.RedisKeyf(GroupCacheKey, id)},
-		rpmRatio,
-	).Err()
+	return cacheWriteRedis(cacheKey, func(ctx context.Context) error {
+		return updateGroupRPMRatioScript.Run(
+			ctx,
+			common.RDB,
+			[]string{cacheKey},
+			rpmRatio,
+		).Err()
+	})
 }
 
 var updateGroupTPMRatioScript = redis.NewScript(`
@@ -118,19 +129,16 @@ func CacheUpdateGroupTPMRatio(id string, tpmRatio float64) error {
 		return true
 	})
 
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
+	cacheKey := common.RedisKeyf(GroupCacheKey, id)
 
-	return updateGroupTPMRatioScript.Run(
-		ctx,
-		common.RDB,
-		[]string{common.RedisKeyf(GroupCacheKey, id)},
-		tpmRatio,
-	).Err()
+	return cacheWriteRedis(cacheKey, func(ctx context.Context) error {
+		return updateGroupTPMRatioScript.Run(
+			ctx,
+			common.RDB,
+			[]string{cacheKey},
+			tpmRatio,
+		).Err()
+	})
 }
 
 var updateGroupStatusScript = redis.NewScript(`
@@ -146,19 +154,16 @@ func CacheUpdateGroupStatus(id string, status int) error {
 		return true
 	})
 
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
+	cacheKey := common.RedisKeyf(GroupCacheKey, id)
 
-	return updateGroupStatusScript.Run(
-		ctx,
-		common.RDB,
-		[]string{common.RedisKeyf(GroupCacheKey, id)},
-		status,
-	).Err()
+	return cacheWriteRedis(cacheKey, func(ctx context.Context) error {
+		return updateGroupStatusScript.Run(
+			ctx,
+			common.RDB,
+			[]string{cacheKey},
+			status,
+		).Err()
+	})
 }
 
 func CacheSetGroup(group *GroupCache) error {
@@ -177,7 +182,7 @@ func CacheGetGroup(id string) (*GroupCache, error) {
 		return groupCache, nil
 	}
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
 		defer cancel()
 
@@ -233,22 +238,18 @@ func CacheGetGroup(id string) (*GroupCache, error) {
 }
 
 func cacheSetGroupRedis(group *GroupCache) error {
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
-
 	key := getGroupCacheKey(group.ID)
-	pipe := common.RDB.Pipeline()
-	pipe.HSet(ctx, key, group)
 
-	expireTime := SyncFrequency + time.Duration(rand.Int64N(60)-30)*time.Second
-	pipe.Expire(ctx, key, expireTime)
-	_, err := pipe.Exec(ctx)
+	return cacheWriteRedis(key, func(ctx context.Context) error {
+		pipe := common.RDB.Pipeline()
+		pipe.HSet(ctx, key, group)
+
+		expireTime := SyncFrequency + time.Duration(rand.Int64N(60)-30)*time.Second
+		pipe.Expire(ctx, key, expireTime)
+		_, err := pipe.Exec(ctx)
 
-	return err
+		return err
+	})
 }
 
 var updateGroupUsedAmountOnlyIncreaseScript = redis.NewScript(`
@@ -274,17 +275,14 @@ func CacheUpdateGroupUsedAmountOnlyIncrease(id string, amount float64) error {
 		return true
 	})
 
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
+	cacheKey := common.RedisKeyf(GroupCacheKey, id)
 
-	return updateGroupUsedAmountOnlyIncreaseScript.Run(
-		ctx,
-		common.RDB,
-		[]string{common.RedisKeyf(GroupCacheKey, id)},
-		amount,
-	).Err()
+	return cacheWriteRedis(cacheKey, func(ctx context.Context) error {
+		return updateGroupUsedAmountOnlyIncreaseScript.Run(
+			ctx,
+			common.RDB,
+			[]string{cacheKey},
+			amount,
+		).Err()
+	})
 }
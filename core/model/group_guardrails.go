@@ -0,0 +1,102 @@
+package model
+
+import (
+	"encoding"
+	"errors"
+	"slices"
+
+	"github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+)
+
+// GroupGuardrails are the request parameters enforced on the chat, claude and
+// gemini requests of the group before they are converted for the upstream
+type GroupGuardrails struct {
+	// DefaultTemperature is used when the request sets no temperature
+	DefaultTemperature *float64 `json:"default_temperature,omitempty"`
+	// MaxTemperature clamps the temperature of the requests
+	MaxTemperature *float64 `json:"max_temperature,omitempty"`
+	// DefaultMaxTokens is used when the request sets no max tokens
+	DefaultMaxTokens int64 `json:"default_max_tokens,omitempty"`
+	// MaxTokens clamps the max tokens of the requests
+	MaxTokens int64 `json:"max_tokens,omitempty"`
+	// ForbiddenTools are the names and the types of the tools the requests
+	// are rejected for
+	ForbiddenTools []string `json:"forbidden_tools,omitempty"`
+	// SafeModePrompt is prepended to the system prompt of the requests
+	SafeModePrompt string `json:"safe_mode_prompt,omitempty"`
+}
+
+var (
+	_ redis.Scanner            = (*GroupGuardrails)(nil)
+	_ encoding.BinaryMarshaler = (*GroupGuardrails)(nil)
+)
+
+func (g *GroupGuardrails) ScanRedis(value string) error {
+	return sonic.UnmarshalString(value, g)
+}
+
+func (g GroupGuardrails) MarshalBinary() ([]byte, error) {
+	return sonic.Marshal(g)
+}
+
+func (g *GroupGuardrails) IsEmpty() bool {
+	return g == nil ||
+		(g.DefaultTemperature == nil &&
+			g.MaxTemperature == nil &&
+			g.DefaultMaxTokens == 0 &&
+			g.MaxTokens == 0 &&
+			len(g.ForbiddenTools) == 0 &&
+			g.SafeModePrompt == "")
+}
+
+func (g *GroupGuardrails) Validate() error {
+	if g == nil {
+		return nil
+	}
+
+	if g.DefaultTemperature != nil && *g.DefaultTemperature < 0 {
+		return errors.New("default temperature must not be negative")
+	}
+
+	if g.MaxTemperature != nil && *g.MaxTemperature < 0 {
+		return errors.New("max temperature must not be negative")
+	}
+
+	if g.DefaultTemperature != nil && g.MaxTemperature != nil &&
+		*g.DefaultTemperature > *g.MaxTemperature {
+		return errors.New("default temperature must not exceed the max temperature")
+	}
+
+	if g.DefaultMaxTokens < 0 || g.MaxTokens < 0 {
+		return errors.New("max tokens must not be negative")
+	}
+
+	if g.MaxTokens > 0 && g.DefaultMaxTokens > g.MaxTokens {
+		return errors.New("default max tokens must not exceed the max tokens")
+	}
+
+	return nil
+}
+
+// IsToolForbidden reports whether the tool name or type is forbidden
+func (g *GroupGuardrails) IsToolForbidden(name string) bool {
+	return name != "" && slices.Contains(g.ForbiddenTools, name)
+}
+
+func cloneGroupGuardrails(guardrails GroupGuardrails) GroupGuardrails {
+	cloned := guardrails
+	cloned.ForbiddenTools = cloneStringSlice(guardrails.ForbiddenTools)
+
+	if guardrails.DefaultTemperature != nil {
+		v := *guardrails.DefaultTemperature
+		cloned.DefaultTemperature = &v
+	}
+
+	if guardrails.MaxTemperature != nil {
+		v := *guardrails.MaxTemperature
+		cloned.MaxTemperature = &v
+	}
+
+	return cloned
+}
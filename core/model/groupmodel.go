@@ -18,6 +18,9 @@ var groupModelConfigZeroValueUpdateFields = []string{
 	"max_video_generation_seconds",
 	"override_max_video_generation_count",
 	"max_video_generation_count",
+	"monthly_spend_cap",
+	"spend_cap_fallback_model",
+	"alias_model",
 }
 
 type GroupModelConfig struct {
@@ -61,6 +64,15 @@ type GroupModelConfig struct {
 
 	OverrideSummaryClaudeLongContext bool `json:"override_summary_claude_long_context"`
 	SummaryClaudeLongContext         bool `json:"summary_claude_long_context"`
+
+	// MonthlySpendCap caps the used amount of the model in the group per calendar month,
+	// once the cap is hit the requests are routed to SpendCapFallbackModel
+	MonthlySpendCap       float64 `json:"monthly_spend_cap"`
+	SpendCapFallbackModel string  `json:"spend_cap_fallback_model" gorm:"size:128"`
+
+	// AliasModel makes Model a group scoped alias of AliasModel, the requests of
+	// Model are routed to AliasModel and the responses keep reporting Model
+	AliasModel string `json:"alias_model,omitempty" gorm:"size:128"`
 }
 
 func (g *GroupModelConfig) BeforeSave(_ *gorm.DB) (err error) {
@@ -72,6 +84,18 @@ func (g *GroupModelConfig) BeforeSave(_ *gorm.DB) (err error) {
 		return err
 	}
 
+	if g.MonthlySpendCap < 0 {
+		return errors.New("monthly spend cap must not be negative")
+	}
+
+	if g.SpendCapFallbackModel == g.Model {
+		return errors.New("spend cap fallback model must be different from the model")
+	}
+
+	if g.AliasModel == g.Model {
+		return errors.New("alias model must be different from the model")
+	}
+
 	return nil
 }
 
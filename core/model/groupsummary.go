@@ -1,7 +1,9 @@
 package model
 
 import (
+	"cmp"
 	"errors"
+	"slices"
 	"strings"
 	"time"
 
@@ -142,19 +144,122 @@ func normalizeGroupConsumptionRankingOrder(order string) (normalized, clause str
 	}
 }
 
-func buildGroupConsumptionRankingQuery(start, end time.Time) *gorm.DB {
-	query := LogDB.Model(&GroupSummary{})
+// GetGroupModelUsedAmount returns the used amount of the model in the group
+// since the start time, the ranges pruned from the hourly summaries are read
+// from the rollups
+func GetGroupModelUsedAmount(groupID, model string, start time.Time) (float64, error) {
+	filter := func(query *gorm.DB) *gorm.DB {
+		return query.Where("group_id = ? AND model = ?", groupID, model)
+	}
+
+	var usedAmount float64
+
+	err := filter(LogDB.Model(&GroupSummary{})).
+		Select("COALESCE(SUM(used_amount), 0)").
+		Where("hour_timestamp >= ?", start.Unix()).
+		Scan(&usedAmount).Error
+	if err != nil {
+		return 0, err
+	}
 
-	switch {
-	case !start.IsZero() && !end.IsZero():
-		query = query.Where("hour_timestamp BETWEEN ? AND ?", start.Unix(), end.Unix())
-	case !start.IsZero():
-		query = query.Where("hour_timestamp >= ?", start.Unix())
-	case !end.IsZero():
-		query = query.Where("hour_timestamp <= ?", end.Unix())
+	rollupData, err := getRollupData[ModelGroupUsage](
+		groupSummaryDailyRollup,
+		groupSummaryMonthlyRollup,
+		start,
+		time.Time{},
+		filter,
+		func(string) string {
+			return "group_id, SUM(request_count) as request_count, SUM(used_amount) as used_amount"
+		},
+		"group_id",
+	)
+	if err != nil {
+		return 0, err
 	}
 
-	return query
+	for _, data := range rollupData {
+		usedAmount += data.UsedAmount
+	}
+
+	return usedAmount, nil
+}
+
+// ModelGroupUsage is the usage of a model by a group
+type ModelGroupUsage struct {
+	GroupID      string  `json:"group_id"`
+	RequestCount int64   `json:"request_count"`
+	UsedAmount   float64 `json:"used_amount"`
+}
+
+// GetModelGroupUsages returns the groups using the model since start, the
+// groups using it the most come first
+func GetModelGroupUsages(model string, start time.Time) ([]ModelGroupUsage, error) {
+	filter := func(query *gorm.DB) *gorm.DB {
+		return query.Where("model = ?", model)
+	}
+
+	selectFields := func(string) string {
+		return "group_id, " +
+			"SUM(request_count) as request_count, " +
+			"SUM(used_amount) as used_amount"
+	}
+
+	var usages []ModelGroupUsage
+
+	err := filter(LogDB.Model(&GroupSummary{})).
+		Select(selectFields("hour_timestamp")).
+		Where("hour_timestamp >= ?", start.Unix()).
+		Group("group_id").
+		Find(&usages).Error
+	if err != nil {
+		return nil, err
+	}
+
+	rollupData, err := getRollupData[ModelGroupUsage](
+		groupSummaryDailyRollup,
+		groupSummaryMonthlyRollup,
+		start,
+		time.Time{},
+		filter,
+		selectFields,
+		"group_id",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	byGroup := make(map[string]*ModelGroupUsage, len(usages))
+	for _, usage := range append(usages, rollupData...) {
+		merged, ok := byGroup[usage.GroupID]
+		if !ok {
+			byGroup[usage.GroupID] = &usage
+			continue
+		}
+
+		merged.RequestCount += usage.RequestCount
+		merged.UsedAmount += usage.UsedAmount
+	}
+
+	usages = make([]ModelGroupUsage, 0, len(byGroup))
+	for _, usage := range byGroup {
+		usages = append(usages, *usage)
+	}
+
+	slices.SortFunc(usages, compareModelGroupUsages)
+
+	return usages, nil
+}
+
+func compareModelGroupUsages(a, b ModelGroupUsage) int {
+	if c := cmp.Compare(b.UsedAmount, a.UsedAmount); c != 0 {
+		return c
+	}
+
+	if c := cmp.Compare(b.RequestCount, a.RequestCount); c != 0 {
+		return c
+	}
+
+	return strings.Compare(a.GroupID, b.GroupID)
 }
 
 func GetGroupConsumptionRanking(
@@ -166,11 +271,21 @@ func GetGroupConsumptionRanking(
 	normalizedOrder, orderClause := normalizeGroupConsumptionRankingOrder(order)
 	limit, offset := toLimitOffset(page, perPage)
 
-	baseQuery := buildGroupConsumptionRankingQuery(start, end)
+	// the ranges pruned from the hourly summaries are read from the rollups
+	baseQuery, err := getSummaryRowsTable(
+		groupSummaryDailyRollup,
+		groupSummaryMonthlyRollup,
+		start,
+		end,
+		"group_id, request_count, used_amount, input_tokens, output_tokens, total_tokens",
+	)
+	if err != nil {
+		return nil, 0, normalizedOrder, err
+	}
 
 	var total int64
 
-	err := baseQuery.
+	err = baseQuery.
 		Session(&gorm.Session{}).
 		Distinct("group_id").
 		Count(&total).Error
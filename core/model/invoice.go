@@ -0,0 +1,194 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ErrInvoiceNotFound = "invoice"
+
+	// InvoiceMonthLayout is the layout of the month of the invoices, the
+	// months are in UTC like the summary rollups
+	InvoiceMonthLayout = "2006-01"
+)
+
+// InvoiceLineItem is the usage of a model billed at a version of its price
+type InvoiceLineItem struct {
+	Model string `json:"model"`
+	// PriceVersion is the version of the model config in effect, zero when
+	// the usage predates the versioning of the model config
+	PriceVersion int64     `json:"price_version,omitempty"`
+	PeriodStart  time.Time `json:"period_start"`
+	PeriodEnd    time.Time `json:"period_end"`
+	// Price is the snapshot of the unit price of the version
+	Price        Price  `json:"price"`
+	RequestCount int64  `json:"request_count"`
+	Usage        Usage  `json:"usage"`
+	Amount       Amount `json:"amount"`
+	// ListAmount is the amount of the usage at the price snapshot, the
+	// discount is what the group was billed below it, e.g. by the group price
+	// overrides
+	ListAmount float64 `json:"list_amount"`
+	Discount   float64 `json:"discount,omitempty"`
+}
+
+// Invoice is the monthly bill of a group, the invoices of the finished months
+// are stored so they stay unchanged when the prices or the summaries change
+type Invoice struct {
+	ID          int               `gorm:"primaryKey"                                           json:"id"`
+	GroupID     string            `gorm:"size:64;not null;uniqueIndex:idx_invoice_group_month" json:"group_id"`
+	Month       string            `gorm:"size:7;not null;uniqueIndex:idx_invoice_group_month"  json:"month"`
+	PeriodStart time.Time         `                                                            json:"period_start"`
+	PeriodEnd   time.Time         `                                                            json:"period_end"`
+	LineItems   []InvoiceLineItem `gorm:"serializer:fastjson;type:text"                        json:"line_items"`
+	ListAmount  float64           `                                                            json:"list_amount"`
+	Discount    float64           `                                                            json:"discount"`
+	Total       float64           `                                                            json:"total"`
+	GeneratedAt time.Time         `                                                            json:"generated_at"`
+}
+
+// SaveInvoice saves the invoice, replacing the invoice of the same month
+func SaveInvoice(invoice *Invoice) error {
+	return DB.
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "group_id"}, {Name: "month"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"period_start",
+				"period_end",
+				"line_items",
+				"list_amount",
+				"discount",
+				"total",
+				"generated_at",
+			}),
+		}).
+		Create(invoice).Error
+}
+
+func GetInvoice(group, month string) (*Invoice, error) {
+	var invoice Invoice
+
+	err := DB.
+		Where("group_id = ? AND month = ?", group, month).
+		First(&invoice).Error
+
+	return &invoice, HandleNotFound(err, ErrInvoiceNotFound)
+}
+
+type GetInvoicesResult struct {
+	Invoices []*Invoice `json:"invoices"`
+	Total    int64      `json:"total"`
+}
+
+// GetInvoices returns the stored invoices without their line items
+func GetInvoices(group, month string, page, perPage int) (*GetInvoicesResult, error) {
+	tx := DB.Model(&Invoice{})
+
+	if group != "" {
+		tx = tx.Where("group_id = ?", group)
+	}
+
+	if month != "" {
+		tx = tx.Where("month = ?", month)
+	}
+
+	result := &GetInvoicesResult{}
+
+	err := tx.Count(&result.Total).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Total <= 0 {
+		return result, nil
+	}
+
+	limit, offset := toLimitOffset(page, perPage)
+	err = tx.
+		Omit("line_items").
+		Order("month desc, group_id").
+		Limit(limit).
+		Offset(offset).
+		Find(&result.Invoices).
+		Error
+
+	return result, err
+}
+
+// GetGroupModelSummaries returns the summaries of the group by the model and
+// the hour in [start, end), the ranges pruned from the hourly summaries are
+// read from the daily and monthly rollups by the day or the month
+func GetGroupModelSummaries(group string, start, end time.Time) ([]SummaryDataV2, error) {
+	filter := func(query *gorm.DB) *gorm.DB {
+		return query.Where("group_id = ?", group)
+	}
+
+	// the bounds of the rollups are inclusive
+	last := end.Add(-time.Second)
+
+	selectFields := func(timeColumn string) string {
+		return SummarySelectFields(nil).BuildSelectFieldsV2(timeColumn, "model")
+	}
+
+	var data []SummaryDataV2
+
+	err := filter(LogDB.Model(&GroupSummary{})).
+		Where("hour_timestamp BETWEEN ? AND ?", start.Unix(), last.Unix()).
+		Select(selectFields("hour_timestamp")).
+		Group("timestamp, model").
+		Find(&data).Error
+	if err != nil {
+		return nil, err
+	}
+
+	rollupData, err := getRollupData[SummaryDataV2](
+		groupSummaryDailyRollup,
+		groupSummaryMonthlyRollup,
+		start,
+		last,
+		filter,
+		selectFields,
+		"timestamp, model",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, rollupData...), nil
+}
+
+// GetSummaryGroups returns the groups with the summaries in [start, end)
+func GetSummaryGroups(start, end time.Time) ([]string, error) {
+	groups := make(map[string]struct{})
+
+	for table, timeColumn := range map[string]string{
+		groupSummaryDailyRollup.source:  groupSummaryDailyRollup.sourceTimeColumn,
+		groupSummaryDailyRollup.table:   groupSummaryDailyRollup.timeColumn,
+		groupSummaryMonthlyRollup.table: groupSummaryMonthlyRollup.timeColumn,
+	} {
+		var ids []string
+
+		err := LogDB.
+			Table(table).
+			Where(timeColumn+" >= ? AND "+timeColumn+" < ?", start.Unix(), end.Unix()).
+			Distinct("group_id").
+			Pluck("group_id", &ids).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			groups[id] = struct{}{}
+		}
+	}
+
+	result := make([]string, 0, len(groups))
+	for id := range groups {
+		result = append(result, id)
+	}
+
+	return result, nil
+}
@@ -200,6 +200,7 @@ func cloneGroupCache(group *GroupCache) *GroupCache {
 	cloned := *group
 
 	cloned.AvailableSets = redisStringSlice(cloneStringSlice([]string(group.AvailableSets)))
+	cloned.DataResidency = redisStringSlice(cloneStringSlice([]string(group.DataResidency)))
 	if group.ModelConfigs != nil {
 		cloned.ModelConfigs = make(redisGroupModelConfigMap, len(group.ModelConfigs))
 		for key, config := range group.ModelConfigs {
@@ -207,6 +208,8 @@ func cloneGroupCache(group *GroupCache) *GroupCache {
 		}
 	}
 
+	cloned.Guardrails = cloneGroupGuardrails(group.Guardrails)
+
 	return &cloned
 }
 
@@ -217,7 +220,9 @@ func cloneTokenCache(token *TokenCache) *TokenCache {
 
 	cloned := *token
 	cloned.Subnets = redisStringSlice(cloneStringSlice([]string(token.Subnets)))
+	cloned.Regions = redisStringSlice(cloneStringSlice([]string(token.Regions)))
 	cloned.Models = redisStringSlice(cloneStringSlice([]string(token.Models)))
+	cloned.AllowedOrigins = redisStringSlice(cloneStringSlice([]string(token.AllowedOrigins)))
 	cloned.availableSets = cloneStringSlice(token.availableSets)
 	cloned.modelsBySet = cloneStringSliceMap(token.modelsBySet)
 
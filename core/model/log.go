@@ -15,13 +15,24 @@ import (
 )
 
 type RequestDetail struct {
-	CreatedAt             time.Time `gorm:"autoCreateTime;index" json:"-"`
-	RequestBody           string    `gorm:"type:text"            json:"request_body,omitempty"`
-	ResponseBody          string    `gorm:"type:text"            json:"response_body,omitempty"`
-	RequestBodyTruncated  bool      `                            json:"request_body_truncated,omitempty"`
-	ResponseBodyTruncated bool      `                            json:"response_body_truncated,omitempty"`
-	ID                    int       `gorm:"primaryKey"           json:"id"`
-	LogID                 int       `gorm:"index"                json:"log_id"`
+	CreatedAt                 time.Time        `gorm:"autoCreateTime;index"          json:"-"`
+	RequestBody               string           `gorm:"type:text"                     json:"request_body,omitempty"`
+	ResponseBody              string           `gorm:"type:text"                     json:"response_body,omitempty"`
+	RequestBodyTruncated      bool             `                                     json:"request_body_truncated,omitempty"`
+	ResponseBodyTruncated     bool             `                                     json:"response_body_truncated,omitempty"`
+	StreamTranscript          string           `gorm:"type:text"                     json:"stream_transcript,omitempty"`
+	StreamTranscriptTruncated bool             `                                     json:"stream_transcript_truncated,omitempty"`
+	Attempts                  []RequestAttempt `gorm:"serializer:fastjson;type:text" json:"attempts,omitempty"`
+	ID                        int              `gorm:"primaryKey"                    json:"id"`
+	LogID                     int              `gorm:"index"                         json:"log_id"`
+}
+
+// RequestAttempt is an upstream attempt of the request, the attempts are only
+// recorded when the request is retried or falls back to another channel
+type RequestAttempt struct {
+	ChannelID           int   `json:"channel_id"`
+	StatusCode          int   `json:"status_code"`
+	LatencyMilliseconds int64 `json:"latency_ms"`
 }
 
 func truncateDetailBody(body string, maxSize int64) (string, bool) {
@@ -59,31 +70,39 @@ func (d *RequestDetail) DropInvalidUTF8Bodies() {
 }
 
 type Log struct {
-	RequestDetail    *RequestDetail   `gorm:"foreignKey:LogID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"request_detail,omitempty"`
-	RequestAt        time.Time        `                                                                      json:"request_at"`
-	RetryAt          time.Time        `                                                                      json:"retry_at,omitempty"`
-	TTFBMilliseconds ZeroNullInt64    `                                                                      json:"ttfb_milliseconds,omitempty"`
-	CreatedAt        time.Time        `gorm:"autoCreateTime;index"                                           json:"created_at"`
-	TokenName        string           `gorm:"size:32"                                                        json:"token_name,omitempty"`
-	Endpoint         EmptyNullString  `gorm:"size:64"                                                        json:"endpoint,omitempty"`
-	Content          EmptyNullString  `gorm:"type:text"                                                      json:"content,omitempty"`
-	GroupID          string           `gorm:"size:64"                                                        json:"group,omitempty"`
-	Model            string           `gorm:"size:128"                                                       json:"model"`
-	RequestID        EmptyNullString  `gorm:"type:char(16);index:,where:request_id is not null"              json:"request_id"`
-	UpstreamID       EmptyNullString  `gorm:"type:varchar(256)"                                              json:"upstream_id,omitempty"`
-	AsyncUsageStatus AsyncUsageStatus `                                                                      json:"async_usage_status,omitempty"`
-	ID               int              `gorm:"primaryKey"                                                     json:"id"`
-	TokenID          int              `gorm:"index"                                                          json:"token_id,omitempty"`
-	ChannelID        int              `                                                                      json:"channel,omitempty"`
-	Code             int              `gorm:"index"                                                          json:"code,omitempty"`
-	Mode             int              `                                                                      json:"mode,omitempty"`
-	IP               EmptyNullString  `gorm:"size:45;index:,where:ip is not null"                            json:"ip,omitempty"`
-	RetryTimes       ZeroNullInt64    `                                                                      json:"retry_times,omitempty"`
-	Price            Price            `gorm:"embedded"                                                       json:"price,omitempty"`
-	Usage            Usage            `gorm:"embedded"                                                       json:"usage,omitempty"`
-	UsageContext     UsageContext     `gorm:"embedded"                                                       json:"usage_context,omitempty"`
-	Amount           Amount           `gorm:"embedded"                                                       json:"amount,omitempty"`
-	PromptCacheKey   EmptyNullString  `gorm:"type:text"                                                      json:"prompt_cache_key,omitempty"`
+	RequestDetail               *RequestDetail   `gorm:"foreignKey:LogID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"request_detail,omitempty"`
+	RequestAt                   time.Time        `                                                                      json:"request_at"`
+	RetryAt                     time.Time        `                                                                      json:"retry_at,omitempty"`
+	TTFBMilliseconds            ZeroNullInt64    `                                                                      json:"ttfb_milliseconds,omitempty"`
+	UpstreamConnectMilliseconds ZeroNullInt64    `                                                                      json:"upstream_connect_milliseconds,omitempty"`
+	StreamDurationMilliseconds  ZeroNullInt64    `                                                                      json:"stream_duration_milliseconds,omitempty"`
+	StreamChunks                ZeroNullInt64    `                                                                      json:"stream_chunks,omitempty"`
+	CreatedAt                   time.Time        `gorm:"autoCreateTime;index"                                           json:"created_at"`
+	TokenName                   string           `gorm:"size:32"                                                        json:"token_name,omitempty"`
+	Endpoint                    EmptyNullString  `gorm:"size:64"                                                        json:"endpoint,omitempty"`
+	Content                     EmptyNullString  `gorm:"type:text"                                                      json:"content,omitempty"`
+	GroupID                     string           `gorm:"size:64"                                                        json:"group,omitempty"`
+	Model                       string           `gorm:"size:128"                                                       json:"model"`
+	RequestID                   EmptyNullString  `gorm:"type:char(16);index:,where:request_id is not null"              json:"request_id"`
+	UpstreamID                  EmptyNullString  `gorm:"type:varchar(256)"                                              json:"upstream_id,omitempty"`
+	SystemFingerprint           EmptyNullString  `gorm:"size:128"                                                       json:"system_fingerprint,omitempty"`
+	Seed                        *int64           `                                                                      json:"seed,omitempty"`
+	ModelConfigVersion          ZeroNullInt64    `                                                                      json:"model_config_version,omitempty"`
+	AsyncUsageStatus            AsyncUsageStatus `                                                                      json:"async_usage_status,omitempty"`
+	ClientAborted               bool             `                                                                      json:"client_aborted,omitempty"`
+	UsageEstimated              bool             `                                                                      json:"usage_estimated,omitempty"`
+	ID                          int              `gorm:"primaryKey"                                                     json:"id"`
+	TokenID                     int              `gorm:"index"                                                          json:"token_id,omitempty"`
+	ChannelID                   int              `                                                                      json:"channel,omitempty"`
+	Code                        int              `gorm:"index"                                                          json:"code,omitempty"`
+	Mode                        int              `                                                                      json:"mode,omitempty"`
+	IP                          EmptyNullString  `gorm:"size:45;index:,where:ip is not null"                            json:"ip,omitempty"`
+	RetryTimes                  ZeroNullInt64    `                                                                      json:"retry_times,omitempty"`
+	Price                       Price            `gorm:"embedded"                                                       json:"price,omitempty"`
+	Usage                       Usage            `gorm:"embedded"                                                       json:"usage,omitempty"`
+	UsageContext                UsageContext     `gorm:"embedded"                                                       json:"usage_context,omitempty"`
+	Amount                      Amount           `gorm:"embedded"                                                       json:"amount,omitempty"`
+	PromptCacheKey              EmptyNullString  `gorm:"type:text"                                                      json:"prompt_cache_key,omitempty"`
 	// https://platform.openai.com/docs/guides/safety-best-practices#end-user-ids
 	User     EmptyNullString   `gorm:"type:text"                     json:"user,omitempty"`
 	Metadata map[string]string `gorm:"serializer:fastjson;type:text" json:"metadata,omitempty"`
@@ -195,6 +214,22 @@ func GetLogDetail(logID int) (*RequestDetail, error) {
 	return &detail, nil
 }
 
+// GetRecentErrorLogs returns the latest error logs created after start, only
+// the fields used to replay the errors are loaded
+func GetRecentErrorLogs(start time.Time, limit int) ([]*Log, error) {
+	var logs []*Log
+
+	err := LogDB.
+		Model(&Log{}).
+		Select("created_at", "model", "channel_id", "code", "content").
+		Where("created_at >= ? AND code != 200", start).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&logs).Error
+
+	return logs, err
+}
+
 func GetGroupLogDetail(logID int, group string) (*RequestDetail, error) {
 	if group == "" {
 		return nil, errors.New("invalid group parameter")
@@ -233,6 +268,11 @@ func CleanLog(batchSize int, optimize bool) (err error) {
 		return err
 	}
 
+	err = cleanAuditLog(batchSize)
+	if err != nil {
+		return err
+	}
+
 	if optimize {
 		return optimizeLog()
 	}
@@ -348,12 +388,22 @@ func cleanLogDetail(batchSize int) error {
 	return nil
 }
 
+// StreamMetrics are the latency metrics of a request besides the ttfb
+type StreamMetrics struct {
+	// UpstreamConnect is the time spent getting a new upstream connection
+	UpstreamConnect time.Duration
+	// StreamDuration is the time from the first to the last byte streamed
+	StreamDuration time.Duration
+	StreamChunks   int64
+}
+
 func RecordConsumeLog(
 	requestID string,
 	createAt time.Time,
 	requestAt time.Time,
 	retryAt time.Time,
 	firstByteAt time.Time,
+	streamMetrics StreamMetrics,
 	group string,
 	code int,
 	channelID int,
@@ -374,6 +424,11 @@ func RecordConsumeLog(
 	metadata map[string]string,
 	promptCacheKey string,
 	upstreamID string,
+	seed *int64,
+	systemFingerprint string,
+	modelConfigVersion int64,
+	clientAborted bool,
+	usageEstimated bool,
 	asyncUsageStatus AsyncUsageStatus,
 ) error {
 	if createAt.IsZero() {
@@ -394,33 +449,46 @@ func RecordConsumeLog(
 		upstreamID = upstreamID[:maxUpstreamIDLength]
 	}
 
+	const maxSystemFingerprintLength = 128
+	if len(systemFingerprint) > maxSystemFingerprintLength {
+		systemFingerprint = systemFingerprint[:maxSystemFingerprintLength]
+	}
+
 	log := &Log{
-		RequestID:        EmptyNullString(requestID),
-		RequestAt:        requestAt,
-		CreatedAt:        createAt,
-		RetryAt:          retryAt,
-		TTFBMilliseconds: ZeroNullInt64(firstByteAt.Sub(requestAt).Milliseconds()),
-		GroupID:          group,
-		Code:             code,
-		TokenID:          tokenID,
-		TokenName:        tokenName,
-		Model:            modelName,
-		Mode:             mode,
-		IP:               EmptyNullString(ip),
-		ChannelID:        channelID,
-		Endpoint:         EmptyNullString(endpoint),
-		Content:          EmptyNullString(content),
-		RetryTimes:       ZeroNullInt64(retryTimes),
-		RequestDetail:    requestDetail,
-		Price:            modelPrice,
-		Usage:            usage,
-		UsageContext:     usageContext,
-		Amount:           amountDetail,
-		User:             EmptyNullString(user),
-		Metadata:         metadata,
-		PromptCacheKey:   EmptyNullString(promptCacheKey),
-		UpstreamID:       EmptyNullString(upstreamID),
-		AsyncUsageStatus: asyncUsageStatus,
+		RequestID:                   EmptyNullString(requestID),
+		RequestAt:                   requestAt,
+		CreatedAt:                   createAt,
+		RetryAt:                     retryAt,
+		TTFBMilliseconds:            ZeroNullInt64(firstByteAt.Sub(requestAt).Milliseconds()),
+		UpstreamConnectMilliseconds: ZeroNullInt64(streamMetrics.UpstreamConnect.Milliseconds()),
+		StreamDurationMilliseconds:  ZeroNullInt64(streamMetrics.StreamDuration.Milliseconds()),
+		StreamChunks:                ZeroNullInt64(streamMetrics.StreamChunks),
+		GroupID:                     group,
+		Code:                        code,
+		TokenID:                     tokenID,
+		TokenName:                   tokenName,
+		Model:                       modelName,
+		Mode:                        mode,
+		IP:                          EmptyNullString(ip),
+		ChannelID:                   channelID,
+		Endpoint:                    EmptyNullString(endpoint),
+		Content:                     EmptyNullString(content),
+		RetryTimes:                  ZeroNullInt64(retryTimes),
+		RequestDetail:               requestDetail,
+		Price:                       modelPrice,
+		Usage:                       usage,
+		UsageContext:                usageContext,
+		Amount:                      amountDetail,
+		User:                        EmptyNullString(user),
+		Metadata:                    metadata,
+		PromptCacheKey:              EmptyNullString(promptCacheKey),
+		UpstreamID:                  EmptyNullString(upstreamID),
+		Seed:                        seed,
+		SystemFingerprint:           EmptyNullString(systemFingerprint),
+		ModelConfigVersion:          ZeroNullInt64(modelConfigVersion),
+		ClientAborted:               clientAborted,
+		UsageEstimated:              usageEstimated,
+		AsyncUsageStatus:            asyncUsageStatus,
 	}
 
 	return LogDB.Create(log).Error
@@ -1493,3 +1561,20 @@ func GetIPGroups(threshold int, start, end time.Time) (map[string][]string, erro
 
 	return result, nil
 }
+
+// GetLogChannelByRequestID returns the model and the channel serving the
+// request of the group
+func GetLogChannelByRequestID(group, requestID string) (string, int, error) {
+	var logEntry Log
+
+	err := LogDB.
+		Select("model", "channel_id").
+		Where("group_id = ? AND request_id = ?", group, requestID).
+		First(&logEntry).
+		Error
+	if err != nil {
+		return "", 0, HandleNotFound(err, "log")
+	}
+
+	return logEntry.Model, logEntry.ChannelID, nil
+}
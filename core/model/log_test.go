@@ -109,6 +109,11 @@ func TestRecordConsumeLogPersistsWebSearchCount(t *testing.T) {
 		now.Add(-2*time.Second),
 		time.Time{},
 		now.Add(-1500*time.Millisecond),
+		model.StreamMetrics{
+			UpstreamConnect: 120 * time.Millisecond,
+			StreamDuration:  time.Second,
+			StreamChunks:    12,
+		},
 		"test-group",
 		200,
 		1,
@@ -134,6 +139,11 @@ func TestRecordConsumeLogPersistsWebSearchCount(t *testing.T) {
 		nil,
 		"",
 		"resp_test_websearch",
+		nil,
+		"",
+		0,
+		false,
+		false,
 		model.AsyncUsageStatusNone,
 	)
 	if err != nil {
@@ -148,6 +158,17 @@ func TestRecordConsumeLogPersistsWebSearchCount(t *testing.T) {
 	if got.Usage.WebSearchCount != 1 {
 		t.Fatalf("expected web_search_count=1, got %d", got.Usage.WebSearchCount)
 	}
+
+	if got.UpstreamConnectMilliseconds != 120 ||
+		got.StreamDurationMilliseconds != 1000 ||
+		got.StreamChunks != 12 {
+		t.Fatalf(
+			"unexpected stream metrics: connect=%d duration=%d chunks=%d",
+			got.UpstreamConnectMilliseconds,
+			got.StreamDurationMilliseconds,
+			got.StreamChunks,
+		)
+	}
 }
 
 func TestRecordConsumeLogLoadsNullWebSearchCountAsZero(t *testing.T) {
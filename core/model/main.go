@@ -147,9 +147,15 @@ func migrateDB() error {
 		&GroupModelConfig{},
 		&PublicMCPReusingParam{},
 		&GroupMCP{},
+		&PromptTemplate{},
 		&Group{},
 		&Option{},
 		&ModelConfig{},
+		&ModelConfigVersion{},
+		&AuditLog{},
+		&TaskLease{},
+		&ConfigSyncResource{},
+		&Invoice{},
 	)
 	if err != nil {
 		return err
@@ -231,6 +237,11 @@ func migrateLogDB(batchSize int) error {
 		&StoreV2{},
 		&SummaryMinute{},
 		&GroupSummaryMinute{},
+		&SummaryDaily{},
+		&SummaryMonthly{},
+		&GroupSummaryDaily{},
+		&GroupSummaryMonthly{},
+		&SummaryRollup{},
 	)
 	if err != nil {
 		return err
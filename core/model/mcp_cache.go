@@ -47,14 +47,11 @@ func updateGroupMCPLocalCache(groupID, mcpID string, update func(*GroupMCPCache)
 func CacheDeleteGroupMCP(groupID, mcpID string) error {
 	cacheDeleteModelLocal(getGroupMCPCacheKey(groupID, mcpID))
 
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
+	cacheKey := getGroupMCPCacheKey(groupID, mcpID)
 
-	return common.RDB.Del(ctx, getGroupMCPCacheKey(groupID, mcpID)).Err()
+	return cacheWriteRedis(cacheKey, func(ctx context.Context) error {
+		return common.RDB.Del(ctx, cacheKey).Err()
+	})
 }
 
 func CacheSetGroupMCP(groupMCP *GroupMCPCache) error {
@@ -73,7 +70,7 @@ func CacheGetGroupMCP(groupID, mcpID string) (*GroupMCPCache, error) {
 		return groupMCPCache, nil
 	}
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
 		defer cancel()
 
@@ -128,22 +125,18 @@ func CacheGetGroupMCP(groupID, mcpID string) (*GroupMCPCache, error) {
 }
 
 func cacheSetGroupMCPRedis(groupMCP *GroupMCPCache) error {
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
-
 	key := getGroupMCPCacheKey(groupMCP.GroupID, groupMCP.ID)
-	pipe := common.RDB.Pipeline()
-	pipe.HSet(ctx, key, groupMCP)
 
-	expireTime := SyncFrequency + time.Duration(rand.Int64N(60)-30)*time.Second
-	pipe.Expire(ctx, key, expireTime)
-	_, err := pipe.Exec(ctx)
+	return cacheWriteRedis(key, func(ctx context.Context) error {
+		pipe := common.RDB.Pipeline()
+		pipe.HSet(ctx, key, groupMCP)
 
-	return err
+		expireTime := SyncFrequency + time.Duration(rand.Int64N(60)-30)*time.Second
+		pipe.Expire(ctx, key, expireTime)
+		_, err := pipe.Exec(ctx)
+
+		return err
+	})
 }
 
 var updateGroupMCPStatusScript = redis.NewScript(`
@@ -159,19 +152,16 @@ func CacheUpdateGroupMCPStatus(groupID, mcpID string, status GroupMCPStatus) err
 		return true
 	})
 
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
+	cacheKey := getGroupMCPCacheKey(groupID, mcpID)
 
-	return updateGroupMCPStatusScript.Run(
-		ctx,
-		common.RDB,
-		[]string{getGroupMCPCacheKey(groupID, mcpID)},
-		status,
-	).Err()
+	return cacheWriteRedis(cacheKey, func(ctx context.Context) error {
+		return updateGroupMCPStatusScript.Run(
+			ctx,
+			common.RDB,
+			[]string{cacheKey},
+			status,
+		).Err()
+	})
 }
 
 type PublicMCPCache struct {
@@ -211,14 +201,11 @@ func updatePublicMCPLocalCache(mcpID string, update func(*PublicMCPCache) bool)
 func CacheDeletePublicMCP(mcpID string) error {
 	cacheDeleteModelLocal(getPublicMCPCacheKey(mcpID))
 
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
+	cacheKey := getPublicMCPCacheKey(mcpID)
 
-	return common.RDB.Del(ctx, getPublicMCPCacheKey(mcpID)).Err()
+	return cacheWriteRedis(cacheKey, func(ctx context.Context) error {
+		return common.RDB.Del(ctx, cacheKey).Err()
+	})
 }
 
 func CacheSetPublicMCP(publicMCP *PublicMCPCache) error {
@@ -237,7 +224,7 @@ func CacheGetPublicMCP(mcpID string) (*PublicMCPCache, error) {
 		return publicMCPCache, nil
 	}
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
 		defer cancel()
 
@@ -292,22 +279,18 @@ func CacheGetPublicMCP(mcpID string) (*PublicMCPCache, error) {
 }
 
 func cacheSetPublicMCPRedis(publicMCP *PublicMCPCache) error {
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
-
 	key := getPublicMCPCacheKey(publicMCP.ID)
-	pipe := common.RDB.Pipeline()
-	pipe.HSet(ctx, key, publicMCP)
 
-	expireTime := SyncFrequency + time.Duration(rand.Int64N(60)-30)*time.Second
-	pipe.Expire(ctx, key, expireTime)
-	_, err := pipe.Exec(ctx)
+	return cacheWriteRedis(key, func(ctx context.Context) error {
+		pipe := common.RDB.Pipeline()
+		pipe.HSet(ctx, key, publicMCP)
+
+		expireTime := SyncFrequency + time.Duration(rand.Int64N(60)-30)*time.Second
+		pipe.Expire(ctx, key, expireTime)
+		_, err := pipe.Exec(ctx)
 
-	return err
+		return err
+	})
 }
 
 var updatePublicMCPStatusScript = redis.NewScript(`
@@ -323,19 +306,16 @@ func CacheUpdatePublicMCPStatus(mcpID string, status PublicMCPStatus) error {
 		return true
 	})
 
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
+	cacheKey := getPublicMCPCacheKey(mcpID)
 
-	return updatePublicMCPStatusScript.Run(
-		ctx,
-		common.RDB,
-		[]string{getPublicMCPCacheKey(mcpID)},
-		status,
-	).Err()
+	return cacheWriteRedis(cacheKey, func(ctx context.Context) error {
+		return updatePublicMCPStatusScript.Run(
+			ctx,
+			common.RDB,
+			[]string{cacheKey},
+			status,
+		).Err()
+	})
 }
 
 const (
@@ -363,15 +343,11 @@ func (p *PublicMCPReusingParam) ToPublicMCPReusingParamCache() PublicMCPReusingP
 func CacheDeletePublicMCPReusingParam(mcpID, groupID string) error {
 	cacheDeleteModelLocal(getPublicMCPReusingParamCacheKey(mcpID, groupID))
 
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
+	cacheKey := getPublicMCPReusingParamCacheKey(mcpID, groupID)
 
-	return common.RDB.Del(ctx, getPublicMCPReusingParamCacheKey(mcpID, groupID)).
-		Err()
+	return cacheWriteRedis(cacheKey, func(ctx context.Context) error {
+		return common.RDB.Del(ctx, cacheKey).Err()
+	})
 }
 
 func CacheSetPublicMCPReusingParam(param PublicMCPReusingParamCache) error {
@@ -401,7 +377,7 @@ func CacheGetPublicMCPReusingParam(mcpID, groupID string) (PublicMCPReusingParam
 		return paramCache, nil
 	}
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
 		defer cancel()
 
@@ -461,20 +437,16 @@ func CacheGetPublicMCPReusingParam(mcpID, groupID string) (PublicMCPReusingParam
 }
 
 func cacheSetPublicMCPReusingParamRedis(param PublicMCPReusingParamCache) error {
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
-
 	key := getPublicMCPReusingParamCacheKey(param.MCPID, param.GroupID)
-	pipe := common.RDB.Pipeline()
-	pipe.HSet(ctx, key, param)
 
-	expireTime := SyncFrequency + time.Duration(rand.Int64N(60)-30)*time.Second
-	pipe.Expire(ctx, key, expireTime)
-	_, err := pipe.Exec(ctx)
+	return cacheWriteRedis(key, func(ctx context.Context) error {
+		pipe := common.RDB.Pipeline()
+		pipe.HSet(ctx, key, param)
 
-	return err
+		expireTime := SyncFrequency + time.Duration(rand.Int64N(60)-30)*time.Second
+		pipe.Expire(ctx, key, expireTime)
+		_, err := pipe.Exec(ctx)
+
+		return err
+	})
 }
@@ -98,6 +98,17 @@ func LoadEnabledChannels() ([]*Channel, error) {
 		channels = append(channels, configChannels...)
 	}
 
+	// a channel whose secrets can not be decrypted, e.g. after the cipher key
+	// changed, is skipped instead of sending the ciphertext to the upstream
+	channels = slices.DeleteFunc(channels, func(channel *Channel) bool {
+		if err := channel.CheckSecrets(); err != nil {
+			log.Errorf("skip channel %d (%s): %v", channel.ID, channel.Name, err)
+			return true
+		}
+
+		return false
+	})
+
 	for _, channel := range channels {
 		initializeChannelModels(channel)
 		initializeChannelModelMapping(channel)
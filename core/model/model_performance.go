@@ -0,0 +1,111 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ModelPerformance is the observed performance of a model aggregated from summary data
+type ModelPerformance struct {
+	Model                 string  `json:"model"                   gorm:"column:model"`
+	RequestCount          int64   `json:"request_count"           gorm:"column:request_count"`
+	ExceptionCount        int64   `json:"exception_count"         gorm:"column:exception_count"`
+	InputTokens           int64   `json:"input_tokens"            gorm:"column:input_tokens"`
+	OutputTokens          int64   `json:"output_tokens"           gorm:"column:output_tokens"`
+	UsedAmount            float64 `json:"used_amount"             gorm:"column:used_amount"`
+	TotalTimeMilliseconds int64   `json:"total_time_milliseconds" gorm:"column:total_time_milliseconds"`
+	TotalTTFBMilliseconds int64   `json:"total_ttfb_milliseconds" gorm:"column:total_ttfb_milliseconds"`
+}
+
+func (p *ModelPerformance) ErrorRate() float64 {
+	if p.RequestCount == 0 {
+		return 0
+	}
+
+	return float64(p.ExceptionCount) / float64(p.RequestCount)
+}
+
+func (p *ModelPerformance) AvgLatencyMilliseconds() float64 {
+	if p.RequestCount == 0 {
+		return 0
+	}
+
+	return float64(p.TotalTimeMilliseconds) / float64(p.RequestCount)
+}
+
+func (p *ModelPerformance) AvgTTFBMilliseconds() float64 {
+	if p.RequestCount == 0 {
+		return 0
+	}
+
+	return float64(p.TotalTTFBMilliseconds) / float64(p.RequestCount)
+}
+
+// GetModelPerformances returns the performance of each model in the time
+// range, the ranges pruned from the hourly summaries are read from the rollups
+func GetModelPerformances(start, end time.Time) (map[string]ModelPerformance, error) {
+	filter := func(query *gorm.DB) *gorm.DB {
+		return query
+	}
+
+	selectFields := func(string) string {
+		return "model, " +
+			"SUM(request_count) as request_count, " +
+			"SUM(exception_count) as exception_count, " +
+			"SUM(input_tokens) as input_tokens, " +
+			"SUM(output_tokens) as output_tokens, " +
+			"SUM(used_amount) as used_amount, " +
+			"SUM(total_time_milliseconds) as total_time_milliseconds, " +
+			"SUM(total_ttfb_milliseconds) as total_ttfb_milliseconds"
+	}
+
+	var items []ModelPerformance
+
+	err := buildConsumptionRankingTimeQuery(
+		LogDB.Model(&Summary{}),
+		"hour_timestamp",
+		start,
+		end,
+	).
+		Select(selectFields("hour_timestamp")).
+		Group("model").
+		Find(&items).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	rollupItems, err := getRollupData[ModelPerformance](
+		summaryDailyRollup,
+		summaryMonthlyRollup,
+		start,
+		end,
+		filter,
+		selectFields,
+		"model",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	performances := make(map[string]ModelPerformance, len(items))
+	for _, item := range append(items, rollupItems...) {
+		performance, ok := performances[item.Model]
+		if !ok {
+			performances[item.Model] = item
+			continue
+		}
+
+		performance.RequestCount += item.RequestCount
+		performance.ExceptionCount += item.ExceptionCount
+		performance.InputTokens += item.InputTokens
+		performance.OutputTokens += item.OutputTokens
+		performance.UsedAmount += item.UsedAmount
+		performance.TotalTimeMilliseconds += item.TotalTimeMilliseconds
+		performance.TotalTTFBMilliseconds += item.TotalTTFBMilliseconds
+		performances[item.Model] = performance
+	}
+
+	return performances, nil
+}
@@ -49,6 +49,11 @@ type ModelConfig struct {
 	SummaryServiceTier          bool                      `                                     json:"summary_service_tier,omitempty"           yaml:"summary_service_tier,omitempty"`
 	SummaryClaudeLongContext    bool                      `                                     json:"summary_claude_long_context,omitempty"    yaml:"summary_claude_long_context,omitempty"`
 	DisableResolutionFuzzyMatch bool                      `                                     json:"disable_resolution_fuzzy_match,omitempty" yaml:"disable_resolution_fuzzy_match,omitempty"`
+	Deprecated                  bool                      `                                     json:"deprecated,omitempty"                     yaml:"deprecated,omitempty"`
+	SunsetAt                    *time.Time                `                                     json:"sunset_at,omitempty"                      yaml:"sunset_at,omitempty"`
+	ReplacementModel            string                    `gorm:"size:128"                      json:"replacement_model,omitempty"              yaml:"replacement_model,omitempty"`
+	NeedsReview                 bool                      `                                     json:"needs_review,omitempty"                   yaml:"needs_review,omitempty"`
+	Version                     int64                     `                                     json:"version,omitempty"                        yaml:"-"`
 }
 
 func (c *ModelConfig) BeforeSave(_ *gorm.DB) (err error) {
@@ -60,6 +65,10 @@ func (c *ModelConfig) BeforeSave(_ *gorm.DB) (err error) {
 		return err
 	}
 
+	if c.ReplacementModel == c.Model {
+		return errors.New("replacement model must not be the model itself")
+	}
+
 	if !c.SupportStreamTimeout() {
 		c.TimeoutConfig.StreamRequestTimeout = 0
 	}
@@ -67,6 +76,17 @@ func (c *ModelConfig) BeforeSave(_ *gorm.DB) (err error) {
 	return nil
 }
 
+// IsDeprecated reports whether the model is marked deprecated or scheduled to
+// be sunset
+func (c *ModelConfig) IsDeprecated() bool {
+	return c.Deprecated || c.SunsetAt != nil
+}
+
+// IsSunset reports whether the sunset date of the model has passed
+func (c *ModelConfig) IsSunset(now time.Time) bool {
+	return c.SunsetAt != nil && !now.Before(*c.SunsetAt)
+}
+
 func NewDefaultModelConfig(model string) ModelConfig {
 	return ModelConfig{
 		Model: model,
@@ -234,6 +254,10 @@ func (c *ModelConfig) MaxOutputTokens() (int, bool) {
 	return GetModelConfigInt(c.Config, ModelConfigMaxOutputTokensKey)
 }
 
+func (c *ModelConfig) DefaultMaxTokens() (int, bool) {
+	return GetModelConfigInt(c.Config, ModelConfigDefaultMaxTokensKey)
+}
+
 func (c *ModelConfig) SupportVision() (bool, bool) {
 	return GetModelConfigBool(c.Config, ModelConfigVisionKey)
 }
@@ -250,6 +274,30 @@ func (c *ModelConfig) SupportFormats() ([]string, bool) {
 	return GetModelConfigStringSlice(c.Config, ModelConfigSupportFormatsKey)
 }
 
+func (c *ModelConfig) ChatTemplate() (string, bool) {
+	template, ok := GetModelConfigString(c.Config, ModelConfigChatTemplateKey)
+	return template, ok && template != ""
+}
+
+func (c *ModelConfig) PDFConversion() string {
+	conversion, _ := GetModelConfigString(c.Config, ModelConfigPDFConversionKey)
+	switch conversion {
+	case PDFConversionText, PDFConversionImage:
+		return conversion
+	default:
+		return PDFConversionFile
+	}
+}
+
+// LongOutputBeta returns the beta header raising the max output tokens over
+// the standard ceiling of the model
+func (c *ModelConfig) LongOutputBeta() (string, int, bool) {
+	beta, _ := GetModelConfigString(c.Config, ModelConfigLongOutputBetaKey)
+	standard, _ := GetModelConfigInt(c.Config, ModelConfigStandardMaxOutputTokensKey)
+
+	return beta, standard, beta != "" && standard > 0
+}
+
 func GetModelConfigs(
 	page, perPage int,
 	model string,
@@ -384,7 +432,9 @@ func SaveModelConfig(config ModelConfig) (err error) {
 		}
 	}()
 
-	return DB.Save(&config).Error
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return saveModelConfigWithVersion(tx, &config, time.Now())
+	})
 }
 
 func SaveModelConfigs(configs []ModelConfig) (err error) {
@@ -394,9 +444,11 @@ func SaveModelConfigs(configs []ModelConfig) (err error) {
 		}
 	}()
 
+	now := time.Now()
+
 	return DB.Transaction(func(tx *gorm.DB) error {
 		for _, config := range configs {
-			if err := tx.Save(&config).Error; err != nil {
+			if err := saveModelConfigWithVersion(tx, &config, now); err != nil {
 				return err
 			}
 		}
@@ -408,15 +460,29 @@ func SaveModelConfigs(configs []ModelConfig) (err error) {
 const ErrModelConfigNotFound = "model config"
 
 func DeleteModelConfig(model string) error {
-	result := DB.Where("model = ?", model).Delete(&ModelConfig{})
-	return HandleUpdateResult(result, ErrModelConfigNotFound)
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return deleteModelConfig(tx, model, time.Now())
+	})
+}
+
+func deleteModelConfig(tx *gorm.DB, model string, now time.Time) error {
+	result := tx.Where("model = ?", model).Delete(&ModelConfig{})
+	if err := HandleUpdateResult(result, ErrModelConfigNotFound); err != nil {
+		return err
+	}
+
+	return recordModelConfigDeleted(tx, []string{model}, now)
 }
 
 func DeleteModelConfigsByModels(models []string) error {
 	return DB.Transaction(func(tx *gorm.DB) error {
-		return tx.
+		if err := tx.
 			Where("model IN (?)", models).
 			Delete(&ModelConfig{}).
-			Error
+			Error; err != nil {
+			return err
+		}
+
+		return recordModelConfigDeleted(tx, models, time.Now())
 	})
 }
@@ -0,0 +1,143 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const ErrModelConfigVersionNotFound = "model config version"
+
+// ModelConfigVersion is a snapshot of the model config, a version is recorded
+// on every save and delete of the model config and takes effect at EffectiveAt
+type ModelConfigVersion struct {
+	CreatedAt   time.Time   `gorm:"autoCreateTime"                         json:"created_at"`
+	EffectiveAt time.Time   `gorm:"index"                                  json:"effective_at"`
+	Config      ModelConfig `gorm:"serializer:fastjson;type:text"          json:"config"`
+	Model       string      `gorm:"size:128;uniqueIndex:idx_model_version" json:"model"`
+	Version     int64       `gorm:"uniqueIndex:idx_model_version"          json:"version"`
+	Deleted     bool        `                                              json:"deleted,omitempty"`
+	ID          int         `gorm:"primaryKey"                             json:"id"`
+}
+
+func latestModelConfigVersion(tx *gorm.DB, model string) (int64, error) {
+	var version int64
+
+	err := tx.
+		Model(&ModelConfigVersion{}).
+		Where("model = ?", model).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&version).Error
+
+	return version, err
+}
+
+// saveModelConfigWithVersion saves the model config as a new version
+func saveModelConfigWithVersion(tx *gorm.DB, config *ModelConfig, now time.Time) error {
+	latest, err := latestModelConfigVersion(tx, config.Model)
+	if err != nil {
+		return err
+	}
+
+	config.Version = latest + 1
+
+	if err := tx.Save(config).Error; err != nil {
+		return err
+	}
+
+	snapshot := *config
+	snapshot.CreatedAt = time.Time{}
+	snapshot.UpdatedAt = time.Time{}
+
+	return tx.Create(&ModelConfigVersion{
+		EffectiveAt: now,
+		Config:      snapshot,
+		Model:       config.Model,
+		Version:     config.Version,
+	}).Error
+}
+
+// recordModelConfigDeleted records the deletion of the model configs as a new
+// version, so no version is in effect after the deletion
+func recordModelConfigDeleted(tx *gorm.DB, models []string, now time.Time) error {
+	for _, model := range models {
+		latest, err := latestModelConfigVersion(tx, model)
+		if err != nil {
+			return err
+		}
+
+		if latest == 0 {
+			continue
+		}
+
+		if err := tx.Create(&ModelConfigVersion{
+			EffectiveAt: now,
+			Config:      NewDefaultModelConfig(model),
+			Model:       model,
+			Version:     latest + 1,
+			Deleted:     true,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetModelConfigVersions returns the versions of the model config, the latest
+// version first
+func GetModelConfigVersions(model string) ([]ModelConfigVersion, error) {
+	var versions []ModelConfigVersion
+
+	err := DB.
+		Where("model = ?", model).
+		Order("version DESC").
+		Find(&versions).Error
+
+	return versions, err
+}
+
+func GetModelConfigVersion(model string, version int64) (ModelConfigVersion, error) {
+	var v ModelConfigVersion
+
+	err := DB.
+		Where("model = ? AND version = ?", model, version).
+		First(&v).Error
+
+	return v, HandleNotFound(err, ErrModelConfigVersionNotFound)
+}
+
+// GetModelConfigVersionAt returns the version of the model config in effect at
+// the time, used to audit the price of the requests retroactively
+func GetModelConfigVersionAt(model string, at time.Time) (ModelConfigVersion, error) {
+	var v ModelConfigVersion
+
+	err := DB.
+		Where("model = ? AND effective_at <= ?", model, at).
+		Order("effective_at DESC, version DESC").
+		First(&v).Error
+
+	return v, HandleNotFound(err, ErrModelConfigVersionNotFound)
+}
+
+// RollbackModelConfig saves the snapshot of the version as the latest version
+// of the model config
+func RollbackModelConfig(model string, version int64) (ModelConfig, error) {
+	v, err := GetModelConfigVersion(model, version)
+	if err != nil {
+		return ModelConfig{}, err
+	}
+
+	if v.Deleted {
+		return ModelConfig{}, NotFoundError(ErrModelConfigVersionNotFound)
+	}
+
+	config := v.Config
+	config.Model = model
+
+	if err := SaveModelConfig(config); err != nil {
+		return ModelConfig{}, err
+	}
+
+	return GetModelConfig(model)
+}
@@ -0,0 +1,117 @@
+package model_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/model"
+	"gorm.io/gorm"
+)
+
+func setupModelConfigVersionDB(t *testing.T) {
+	t.Helper()
+
+	prevDB := model.DB
+	prevUsingSQLite := common.UsingSQLite
+
+	testDB, err := model.OpenSQLite(filepath.Join(t.TempDir(), "model-config-version.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite db: %v", err)
+	}
+
+	model.DB = testDB
+	common.UsingSQLite = true
+	t.Cleanup(func() {
+		model.DB = prevDB
+		common.UsingSQLite = prevUsingSQLite
+	})
+
+	if err := testDB.AutoMigrate(&model.ModelConfig{}, &model.ModelConfigVersion{}); err != nil {
+		t.Fatalf("failed to migrate model config: %v", err)
+	}
+}
+
+func TestModelConfigVersionsAndRollback(t *testing.T) {
+	setupModelConfigVersionDB(t)
+
+	const modelName = "version-model"
+
+	if err := model.SaveModelConfig(model.ModelConfig{
+		Model: modelName,
+		Price: model.Price{InputPrice: 1},
+	}); err != nil {
+		t.Fatalf("save v1: %v", err)
+	}
+
+	if err := model.SaveModelConfig(model.ModelConfig{
+		Model: modelName,
+		Price: model.Price{InputPrice: 2},
+	}); err != nil {
+		t.Fatalf("save v2: %v", err)
+	}
+
+	versions, err := model.GetModelConfigVersions(modelName)
+	if err != nil {
+		t.Fatalf("get versions: %v", err)
+	}
+
+	if len(versions) != 2 || versions[0].Version != 2 || versions[1].Version != 1 {
+		t.Fatalf("expected versions 2 and 1, got %#v", versions)
+	}
+
+	if versions[1].Config.Price.InputPrice != 1 {
+		t.Fatalf("expected v1 input price 1, got %v", versions[1].Config.Price.InputPrice)
+	}
+
+	current, err := model.GetModelConfig(modelName)
+	if err != nil {
+		t.Fatalf("get model config: %v", err)
+	}
+
+	if current.Version != 2 {
+		t.Fatalf("expected current version 2, got %d", current.Version)
+	}
+
+	at, err := model.GetModelConfigVersionAt(modelName, versions[1].EffectiveAt)
+	if err != nil {
+		t.Fatalf("get version at: %v", err)
+	}
+
+	if at.Version != 1 {
+		t.Fatalf("expected version 1 in effect, got %d", at.Version)
+	}
+
+	_, err = model.GetModelConfigVersionAt(modelName, versions[1].EffectiveAt.Add(-time.Second))
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected no version before v1, got %v", err)
+	}
+
+	rolledBack, err := model.RollbackModelConfig(modelName, 1)
+	if err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+
+	if rolledBack.Version != 3 || rolledBack.Price.InputPrice != 1 {
+		t.Fatalf("expected version 3 with input price 1, got %#v", rolledBack)
+	}
+
+	if err := model.DeleteModelConfig(modelName); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	latest, err := model.GetModelConfigVersion(modelName, 4)
+	if err != nil {
+		t.Fatalf("get deleted version: %v", err)
+	}
+
+	if !latest.Deleted {
+		t.Fatal("expected the latest version to record the deletion")
+	}
+
+	if _, err := model.RollbackModelConfig(modelName, 4); err == nil {
+		t.Fatal("expected rollback to a deleted version to fail")
+	}
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// OpenAIFileMetadata is the metadata of the store of an uploaded OpenAI file
+type OpenAIFileMetadata struct {
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at,omitempty"`
+	Filename  string `json:"filename,omitempty"`
+	Purpose   string `json:"purpose,omitempty"`
+}
+
+func (m OpenAIFileMetadata) String() string {
+	data, err := sonic.MarshalString(m)
+	if err != nil {
+		return ""
+	}
+
+	return data
+}
+
+func ParseOpenAIFileMetadata(value string) OpenAIFileMetadata {
+	var metadata OpenAIFileMetadata
+	if value == "" {
+		return metadata
+	}
+
+	_ = sonic.UnmarshalString(value, &metadata)
+
+	return metadata
+}
+
+// OpenAIFile is an uploaded file tracked by the store
+type OpenAIFile struct {
+	ID        string
+	TokenID   int
+	ChannelID int
+	Model     string
+	ExpiresAt time.Time
+	OpenAIFileMetadata
+}
+
+func storeToOpenAIFile(s *StoreV2) OpenAIFile {
+	return OpenAIFile{
+		ID:                 strings.TrimPrefix(s.ID, StorePrefixOpenAIFile+":"),
+		TokenID:            s.TokenID,
+		ChannelID:          s.ChannelID,
+		Model:              s.Model,
+		ExpiresAt:          s.ExpiresAt,
+		OpenAIFileMetadata: ParseOpenAIFileMetadata(s.Metadata),
+	}
+}
+
+func getOpenAIFileStores(group string, tokenID int) ([]*StoreV2, error) {
+	var stores []*StoreV2
+
+	tx := LogDB.
+		Where("group_id = ? and id like ? and expires_at > ?",
+			group,
+			StorePrefixOpenAIFile+":%",
+			time.Now(),
+		)
+	if tokenID != 0 {
+		tx = tx.Where("token_id = ?", tokenID)
+	}
+
+	err := tx.Order("created_at desc").Find(&stores).Error
+
+	return stores, err
+}
+
+// GetOpenAIFiles returns the unexpired files uploaded by the token, the files
+// of the whole group are returned when tokenID is zero
+func GetOpenAIFiles(group string, tokenID int) ([]OpenAIFile, error) {
+	stores, err := getOpenAIFileStores(group, tokenID)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]OpenAIFile, 0, len(stores))
+	for _, s := range stores {
+		files = append(files, storeToOpenAIFile(s))
+	}
+
+	return files, nil
+}
+
+// GetGroupFileStorageUsage returns the bytes of the unexpired files uploaded
+// by the group
+func GetGroupFileStorageUsage(group string) (int64, error) {
+	stores, err := getOpenAIFileStores(group, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, s := range stores {
+		total += ParseOpenAIFileMetadata(s.Metadata).Bytes
+	}
+
+	return total, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"slices"
 	"sort"
 	"strconv"
@@ -62,6 +63,73 @@ func InitOption2DB() error {
 	return storeOptionMap()
 }
 
+// jsonOptions are the options whose values are json, keyed by their names
+var jsonOptions = map[string]func() any{
+	"DefaultChannelModels":       func() any { return config.GetDefaultChannelModels() },
+	"DefaultChannelModelMapping": func() any { return config.GetDefaultChannelModelMapping() },
+	"GroupConsumeLevelRatio":     func() any { return config.GetGroupConsumeLevelRatioStringKeyMap() },
+	"UsageAlertWhitelist":        func() any { return config.GetUsageAlertWhitelist() },
+	"AlertNotifiers":             func() any { return config.GetAlertNotifiers() },
+	"AlertTemplates":             func() any { return config.GetAlertTemplates() },
+	"BudgetAlertThresholds":      func() any { return config.GetBudgetAlertDefaultThresholds() },
+	"BudgetAlertGroupThresholds": func() any { return config.GetBudgetAlertGroupThresholds() },
+	"GeoRoutingRules":            func() any { return config.GetGeoRoutingRules() },
+	"AutoBanRules":               func() any { return config.GetAutoBanRules() },
+	"SSEEventNames":              func() any { return config.GetSSEEventNames() },
+	"FeatureFlags":               func() any { return config.GetFeatureFlags() },
+	"RouterModels":               func() any { return config.GetRouterModels() },
+}
+
+// secretOptions are the json options whose values hold a field tagged with
+// secret:"true", they are derived from the types of the options so a new
+// secret field is redacted without listing its option
+var secretOptions = sync.OnceValue(func() map[string]struct{} {
+	secrets := make(map[string]struct{})
+
+	for key, value := range jsonOptions {
+		if hasSecretField(reflect.TypeOf(value()), make(map[reflect.Type]struct{})) {
+			secrets[key] = struct{}{}
+		}
+	}
+
+	return secrets
+})
+
+// IsSecretOption reports whether the value of the option holds secrets, e.g.
+// the urls and the secrets of the AlertNotifiers
+func IsSecretOption(key string) bool {
+	_, ok := secretOptions()[key]
+	return ok
+}
+
+func hasSecretField(t reflect.Type, seen map[reflect.Type]struct{}) bool {
+	if t == nil {
+		return false
+	}
+
+	if _, ok := seen[t]; ok {
+		return false
+	}
+
+	seen[t] = struct{}{}
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return hasSecretField(t.Elem(), seen)
+	case reflect.Map:
+		return hasSecretField(t.Key(), seen) || hasSecretField(t.Elem(), seen)
+	case reflect.Struct:
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if field.Tag.Get("secret") == "true" || hasSecretField(field.Type, seen) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 func initOptionMap() error {
 	optionMap["LogStorageHours"] = strconv.FormatInt(config.GetLogStorageHours(), 10)
 	optionMap["RetryLogStorageHours"] = strconv.FormatInt(config.GetRetryLogStorageHours(), 10)
@@ -78,30 +146,49 @@ func initOptionMap() error {
 		config.GetLogDetailResponseBodyMaxSize(),
 		10,
 	)
+	optionMap["LogDetailStreamTranscriptMaxSize"] = strconv.FormatInt(
+		config.GetLogDetailStreamTranscriptMaxSize(),
+		10,
+	)
 	optionMap["DisableServe"] = strconv.FormatBool(config.GetDisableServe())
 	optionMap["RetryTimes"] = strconv.FormatInt(config.GetRetryTimes(), 10)
+	optionMap["ClientAbortGraceSeconds"] = strconv.FormatInt(
+		config.GetClientAbortGraceSeconds(),
+		10,
+	)
+	optionMap["IdempotencyKeyTTLSeconds"] = strconv.FormatInt(
+		config.GetIdempotencyKeyTTLSeconds(),
+		10,
+	)
+	optionMap["LiveMaxSessionSeconds"] = strconv.FormatInt(
+		config.GetLiveMaxSessionSeconds(),
+		10,
+	)
+	optionMap["ArchivePurgeHours"] = strconv.FormatInt(config.GetArchivePurgeHours(), 10)
+	optionMap["AuditLogStorageHours"] = strconv.FormatInt(config.GetAuditLogStorageHours(), 10)
+	optionMap["SummaryMinuteStorageHours"] = strconv.FormatInt(
+		config.GetSummaryMinuteStorageHours(),
+		10,
+	)
+	optionMap["SummaryHourStorageHours"] = strconv.FormatInt(
+		config.GetSummaryHourStorageHours(),
+		10,
+	)
+	optionMap["SummaryDayStorageHours"] = strconv.FormatInt(
+		config.GetSummaryDayStorageHours(),
+		10,
+	)
+	optionMap["FairQueueMaxConcurrency"] = strconv.FormatInt(
+		config.GetFairQueueMaxConcurrency(),
+		10,
+	)
+	optionMap["FairQueueTimeoutSeconds"] = strconv.FormatInt(
+		config.GetFairQueueTimeoutSeconds(),
+		10,
+	)
 
-	defaultChannelModelsJSON, err := sonic.Marshal(config.GetDefaultChannelModels())
-	if err != nil {
-		return err
-	}
-
-	optionMap["DefaultChannelModels"] = conv.BytesToString(defaultChannelModelsJSON)
-
-	defaultChannelModelMappingJSON, err := sonic.Marshal(config.GetDefaultChannelModelMapping())
-	if err != nil {
-		return err
-	}
-
-	optionMap["DefaultChannelModelMapping"] = conv.BytesToString(defaultChannelModelMappingJSON)
 	optionMap["GroupMaxTokenNum"] = strconv.FormatInt(config.GetGroupMaxTokenNum(), 10)
 
-	groupConsumeLevelRatioJSON, err := sonic.Marshal(config.GetGroupConsumeLevelRatioStringKeyMap())
-	if err != nil {
-		return err
-	}
-
-	optionMap["GroupConsumeLevelRatio"] = conv.BytesToString(groupConsumeLevelRatioJSON)
 	optionMap["NotifyNote"] = config.GetNotifyNote()
 	optionMap["DefaultHost"] = config.GetDefaultHost()
 	optionMap["DefaultMCPHost"] = config.GetConfiguredDefaultMCPHost()
@@ -113,20 +200,64 @@ func initOptionMap() error {
 		-1,
 		64,
 	)
+	optionMap["CircuitBreakerErrorRate"] = strconv.FormatFloat(
+		config.GetCircuitBreakerErrorRate(),
+		'f',
+		-1,
+		64,
+	)
+	optionMap["HedgeSurchargeRatio"] = strconv.FormatFloat(
+		config.GetHedgeSurchargeRatio(),
+		'f',
+		-1,
+		64,
+	)
+	optionMap["ConversionDebugSampleRate"] = strconv.FormatFloat(
+		config.GetConversionDebugSampleRate(),
+		'f',
+		-1,
+		64,
+	)
+	optionMap["ConversionDebugMaxPerMinute"] = strconv.FormatInt(
+		config.GetConversionDebugMaxPerMinute(),
+		10,
+	)
+	optionMap["CircuitBreakerSlowRate"] = strconv.FormatFloat(
+		config.GetCircuitBreakerSlowRate(),
+		'f',
+		-1,
+		64,
+	)
+	optionMap["CircuitBreakerLatencySLOMs"] = strconv.FormatInt(
+		config.GetCircuitBreakerLatencySLOMs(),
+		10,
+	)
+	optionMap["CircuitBreakerOpenSeconds"] = strconv.FormatInt(
+		config.GetCircuitBreakerOpenSeconds(),
+		10,
+	)
 	optionMap["UsageAlertThreshold"] = strconv.FormatInt(config.GetUsageAlertThreshold(), 10)
 
-	usageAlertWhitelistJSON, err := sonic.Marshal(config.GetUsageAlertWhitelist())
-	if err != nil {
-		return err
-	}
-
-	optionMap["UsageAlertWhitelist"] = conv.BytesToString(usageAlertWhitelistJSON)
 	optionMap["UsageAlertMinAvgThreshold"] = strconv.FormatInt(
 		config.GetUsageAlertMinAvgThreshold(),
 		10,
 	)
 	optionMap["FuzzyTokenThreshold"] = strconv.FormatInt(config.GetFuzzyTokenThreshold(), 10)
 
+	optionMap["BudgetAlertDedupSeconds"] = strconv.FormatInt(
+		config.GetBudgetAlertDedupSeconds(),
+		10,
+	)
+
+	for key, value := range jsonOptions {
+		data, err := sonic.Marshal(value())
+		if err != nil {
+			return err
+		}
+
+		optionMap[key] = conv.BytesToString(data)
+	}
+
 	optionKeys = make([]string, 0, len(optionMap))
 	for key := range optionMap {
 		optionKeys = append(optionKeys, key)
@@ -316,6 +447,13 @@ func updateOption(key, value string, isInit bool) (err error) {
 		}
 
 		config.SetLogDetailResponseBodyMaxSize(logDetailResponseBodyMaxSize)
+	case "LogDetailStreamTranscriptMaxSize":
+		logDetailStreamTranscriptMaxSize, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		config.SetLogDetailStreamTranscriptMaxSize(logDetailStreamTranscriptMaxSize)
 	case "CleanLogBatchSize":
 		cleanLogBatchSize, err := strconv.ParseInt(value, 10, 64)
 		if err != nil {
@@ -401,6 +539,116 @@ func updateOption(key, value string, isInit bool) (err error) {
 		}
 
 		config.SetRetryTimes(retryTimes)
+	case "ClientAbortGraceSeconds":
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		if seconds < 0 {
+			return errors.New("client abort grace seconds must not be negative")
+		}
+
+		config.SetClientAbortGraceSeconds(seconds)
+	case "IdempotencyKeyTTLSeconds":
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		if seconds < 0 {
+			return errors.New("idempotency key ttl seconds must not be negative")
+		}
+
+		config.SetIdempotencyKeyTTLSeconds(seconds)
+	case "LiveMaxSessionSeconds":
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		if seconds < 0 {
+			return errors.New("live max session seconds must not be negative")
+		}
+
+		config.SetLiveMaxSessionSeconds(seconds)
+	case "ArchivePurgeHours":
+		hours, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		if hours < 0 {
+			return errors.New("archive purge hours must not be negative")
+		}
+
+		config.SetArchivePurgeHours(hours)
+	case "AuditLogStorageHours":
+		hours, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		if hours < 0 {
+			return errors.New("audit log storage hours must not be negative")
+		}
+
+		config.SetAuditLogStorageHours(hours)
+	case "SummaryMinuteStorageHours":
+		hours, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		if hours < 0 {
+			return errors.New("summary minute storage hours must not be negative")
+		}
+
+		config.SetSummaryMinuteStorageHours(hours)
+	case "SummaryHourStorageHours":
+		hours, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		if hours < 0 {
+			return errors.New("summary hour storage hours must not be negative")
+		}
+
+		config.SetSummaryHourStorageHours(hours)
+	case "SummaryDayStorageHours":
+		hours, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		if hours < 0 {
+			return errors.New("summary day storage hours must not be negative")
+		}
+
+		config.SetSummaryDayStorageHours(hours)
+	case "FairQueueMaxConcurrency":
+		concurrency, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		if concurrency < 0 {
+			return errors.New("fair queue max concurrency must not be negative")
+		}
+
+		config.SetFairQueueMaxConcurrency(concurrency)
+	case "FairQueueTimeoutSeconds":
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		if seconds < 0 {
+			return errors.New("fair queue timeout seconds must not be negative")
+		}
+
+		config.SetFairQueueTimeoutSeconds(seconds)
 	case "GroupConsumeLevelRatio":
 		var newGroupRpmRatio map[string]float64
 
@@ -445,6 +693,63 @@ func updateOption(key, value string, isInit bool) (err error) {
 		}
 
 		config.SetDefaultWarnNotifyErrorRate(rate)
+	case "CircuitBreakerErrorRate":
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+
+		config.SetCircuitBreakerErrorRate(rate)
+	case "HedgeSurchargeRatio":
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+
+		if ratio < 0 {
+			return errors.New("hedge surcharge ratio must not be negative")
+		}
+
+		config.SetHedgeSurchargeRatio(ratio)
+	case "ConversionDebugSampleRate":
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+
+		if rate < 0 || rate > 1 {
+			return errors.New("conversion debug sample rate must be between 0 and 1")
+		}
+
+		config.SetConversionDebugSampleRate(rate)
+	case "ConversionDebugMaxPerMinute":
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		config.SetConversionDebugMaxPerMinute(limit)
+	case "CircuitBreakerSlowRate":
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+
+		config.SetCircuitBreakerSlowRate(rate)
+	case "CircuitBreakerLatencySLOMs":
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		config.SetCircuitBreakerLatencySLOMs(ms)
+	case "CircuitBreakerOpenSeconds":
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		config.SetCircuitBreakerOpenSeconds(seconds)
 	case "UsageAlertThreshold":
 		threshold, err := strconv.ParseInt(value, 10, 64)
 		if err != nil {
@@ -461,6 +766,124 @@ func updateOption(key, value string, isInit bool) (err error) {
 		}
 
 		config.SetUsageAlertWhitelist(whitelist)
+	case "GeoRoutingRules":
+		var rules map[string]config.GeoRoutingRule
+
+		err := sonic.Unmarshal(conv.StringToBytes(value), &rules)
+		if err != nil {
+			return err
+		}
+
+		config.SetGeoRoutingRules(rules)
+	case "AutoBanRules":
+		var rules []config.AutoBanRule
+
+		err := sonic.Unmarshal(conv.StringToBytes(value), &rules)
+		if err != nil {
+			return err
+		}
+
+		if err := config.ValidateAutoBanRules(rules); err != nil {
+			return err
+		}
+
+		config.SetAutoBanRules(rules)
+	case "SSEEventNames":
+		var names map[string]string
+
+		err := sonic.Unmarshal(conv.StringToBytes(value), &names)
+		if err != nil {
+			return err
+		}
+
+		config.SetSSEEventNames(names)
+	case "FeatureFlags":
+		var flags map[string]config.FeatureFlag
+
+		err := sonic.Unmarshal(conv.StringToBytes(value), &flags)
+		if err != nil {
+			return err
+		}
+
+		if err := config.ValidateFeatureFlags(flags); err != nil {
+			return err
+		}
+
+		config.SetFeatureFlags(flags)
+	case "RouterModels":
+		var models map[string]config.RouterModel
+
+		err := sonic.Unmarshal(conv.StringToBytes(value), &models)
+		if err != nil {
+			return err
+		}
+
+		if err := config.ValidateRouterModels(models); err != nil {
+			return err
+		}
+
+		config.SetRouterModels(models)
+	case "AlertNotifiers":
+		var notifiers []config.AlertNotifier
+
+		err := sonic.Unmarshal(conv.StringToBytes(value), &notifiers)
+		if err != nil {
+			return err
+		}
+
+		if err := config.ValidateAlertNotifiers(notifiers); err != nil {
+			return err
+		}
+
+		config.SetAlertNotifiers(notifiers)
+	case "AlertTemplates":
+		var templates map[string]config.AlertTemplate
+
+		err := sonic.Unmarshal(conv.StringToBytes(value), &templates)
+		if err != nil {
+			return err
+		}
+
+		if err := notify.ValidateAlertTemplates(templates); err != nil {
+			return err
+		}
+
+		config.SetAlertTemplates(templates)
+	case "BudgetAlertThresholds":
+		var thresholds []float64
+
+		err := sonic.Unmarshal(conv.StringToBytes(value), &thresholds)
+		if err != nil {
+			return err
+		}
+
+		if err := config.ValidateBudgetAlertThresholds(thresholds); err != nil {
+			return err
+		}
+
+		config.SetBudgetAlertDefaultThresholds(thresholds)
+	case "BudgetAlertGroupThresholds":
+		var thresholds map[string][]float64
+
+		err := sonic.Unmarshal(conv.StringToBytes(value), &thresholds)
+		if err != nil {
+			return err
+		}
+
+		for group, groupThresholds := range thresholds {
+			if err := config.ValidateBudgetAlertThresholds(groupThresholds); err != nil {
+				return fmt.Errorf("group %s: %w", group, err)
+			}
+		}
+
+		config.SetBudgetAlertGroupThresholds(thresholds)
+	case "BudgetAlertDedupSeconds":
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		config.SetBudgetAlertDedupSeconds(seconds)
 	case "UsageAlertMinAvgThreshold":
 		threshold, err := strconv.ParseInt(value, 10, 64)
 		if err != nil {
@@ -36,4 +36,7 @@ const (
 	ModelOwnerDoc2x       ModelOwner = "doc2x"
 	ModelOwnerJina        ModelOwner = "jina"
 	ModelOwnerAntGroup    ModelOwner = "antgroup"
+	ModelOwnerKling       ModelOwner = "kling"
+	ModelOwnerSuno        ModelOwner = "suno"
+	ModelOwnerNvidia      ModelOwner = "nvidia"
 )
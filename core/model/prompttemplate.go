@@ -0,0 +1,250 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"gorm.io/gorm"
+)
+
+const ErrPromptTemplateNotFound = "prompt template"
+
+// PromptTemplate is a version of the prompt template of the group, every save
+// records a new version and the versions are never changed, so the version
+// used by a request can be audited later. The Model is used when the request
+// sets no model and the Defaults are the values of the variables the request
+// does not provide
+type PromptTemplate struct {
+	CreatedAt   time.Time               `gorm:"autoCreateTime"                                 json:"created_at"`
+	GroupID     string                  `gorm:"size:64;uniqueIndex:idx_group_template_version" json:"group_id"`
+	Name        string                  `gorm:"size:64;uniqueIndex:idx_group_template_version" json:"name"`
+	Version     int64                   `gorm:"uniqueIndex:idx_group_template_version"         json:"version"`
+	Description string                  `gorm:"type:text"                                      json:"description,omitempty"`
+	Model       string                  `gorm:"size:128"                                       json:"model,omitempty"`
+	Messages    []PromptTemplateMessage `gorm:"serializer:fastjson;type:text"                  json:"messages"`
+	Defaults    map[string]string       `gorm:"serializer:fastjson;type:text"                  json:"defaults,omitempty"`
+	ID          int                     `gorm:"primaryKey"                                     json:"id"`
+}
+
+// PromptTemplateMessage is a chat message of the template, the {{name}}
+// placeholders of the content are replaced with the variables
+type PromptTemplateMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func (t *PromptTemplate) MarshalJSON() ([]byte, error) {
+	type Alias PromptTemplate
+
+	return sonic.Marshal(&struct {
+		*Alias
+		CreatedAt int64 `json:"created_at"`
+	}{
+		Alias:     (*Alias)(t),
+		CreatedAt: t.CreatedAt.UnixMilli(),
+	})
+}
+
+func (t *PromptTemplate) Validate() error {
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if len(t.Messages) == 0 {
+		return errors.New("messages are required")
+	}
+
+	for i, message := range t.Messages {
+		switch message.Role {
+		case "system", "developer", "user", "assistant":
+		default:
+			return fmt.Errorf("message %d has an invalid role: %q", i, message.Role)
+		}
+
+		if _, err := renderPromptTemplateContent(message.Content, nil); err != nil &&
+			!errors.As(err, new(*MissingPromptVariableError)) {
+			return fmt.Errorf("message %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// MissingPromptVariableError is returned when the request provides no value
+// for a variable of the template
+type MissingPromptVariableError struct {
+	Name string
+}
+
+func (e *MissingPromptVariableError) Error() string {
+	return "missing prompt template variable: " + e.Name
+}
+
+// Render replaces the placeholders of the messages with the variables, the
+// defaults of the template are used for the variables not provided
+func (t *PromptTemplate) Render(variables map[string]string) ([]PromptTemplateMessage, error) {
+	lookup := func(name string) (string, bool) {
+		if v, ok := variables[name]; ok {
+			return v, true
+		}
+
+		v, ok := t.Defaults[name]
+
+		return v, ok
+	}
+
+	messages := make([]PromptTemplateMessage, 0, len(t.Messages))
+	for _, message := range t.Messages {
+		content, err := renderPromptTemplateContent(message.Content, lookup)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, PromptTemplateMessage{
+			Role:    message.Role,
+			Content: content,
+		})
+	}
+
+	return messages, nil
+}
+
+func renderPromptTemplateContent(
+	content string,
+	lookup func(name string) (string, bool),
+) (string, error) {
+	var (
+		sb      strings.Builder
+		missing string
+	)
+
+	for {
+		start := strings.Index(content, "{{")
+		if start < 0 {
+			sb.WriteString(content)
+			break
+		}
+
+		end := strings.Index(content[start:], "}}")
+		if end < 0 {
+			return "", errors.New("unclosed placeholder")
+		}
+
+		name := strings.TrimSpace(content[start+2 : start+end])
+		if name == "" {
+			return "", errors.New("empty placeholder")
+		}
+
+		sb.WriteString(content[:start])
+
+		value, ok := "", false
+		if lookup != nil {
+			value, ok = lookup(name)
+		}
+
+		if !ok && missing == "" {
+			missing = name
+		}
+
+		sb.WriteString(value)
+
+		content = content[start+end+2:]
+	}
+
+	if missing != "" {
+		return "", &MissingPromptVariableError{Name: missing}
+	}
+
+	return sb.String(), nil
+}
+
+// SavePromptTemplate saves the template as the next version of the template
+// of the group
+func SavePromptTemplate(t *PromptTemplate) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var latest int64
+
+		err := tx.
+			Model(&PromptTemplate{}).
+			Where("group_id = ? AND name = ?", t.GroupID, t.Name).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&latest).Error
+		if err != nil {
+			return err
+		}
+
+		t.ID = 0
+		t.Version = latest + 1
+
+		return tx.Create(t).Error
+	})
+}
+
+// GetPromptTemplate returns the version of the template of the group, the
+// latest version is returned when the version is 0
+func GetPromptTemplate(group, name string, version int64) (*PromptTemplate, error) {
+	var t PromptTemplate
+
+	tx := DB.Where("group_id = ? AND name = ?", group, name)
+	if version > 0 {
+		tx = tx.Where("version = ?", version)
+	} else {
+		tx = tx.Order("version DESC")
+	}
+
+	err := tx.First(&t).Error
+
+	return &t, HandleNotFound(err, ErrPromptTemplateNotFound)
+}
+
+// GetPromptTemplates returns the latest versions of the templates of the group
+func GetPromptTemplates(group string) ([]*PromptTemplate, error) {
+	var templates []*PromptTemplate
+
+	latest := DB.
+		Model(&PromptTemplate{}).
+		Select("name, MAX(version) AS version").
+		Where("group_id = ?", group).
+		Group("name")
+
+	err := DB.
+		Model(&PromptTemplate{}).
+		Joins(
+			"JOIN (?) AS latest ON latest.name = prompt_templates.name AND latest.version = prompt_templates.version",
+			latest,
+		).
+		Where("prompt_templates.group_id = ?", group).
+		Order("prompt_templates.name").
+		Find(&templates).Error
+
+	return templates, err
+}
+
+// GetPromptTemplateVersions returns the versions of the template, the latest
+// version first
+func GetPromptTemplateVersions(group, name string) ([]*PromptTemplate, error) {
+	var templates []*PromptTemplate
+
+	err := DB.
+		Where("group_id = ? AND name = ?", group, name).
+		Order("version DESC").
+		Find(&templates).Error
+
+	return templates, err
+}
+
+// DeletePromptTemplate deletes all the versions of the template
+func DeletePromptTemplate(group, name string) error {
+	result := DB.
+		Where("group_id = ? AND name = ?", group, name).
+		Delete(&PromptTemplate{})
+
+	return HandleUpdateResult(result, ErrPromptTemplateNotFound)
+}
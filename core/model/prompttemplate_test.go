@@ -0,0 +1,49 @@
+package model_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptTemplateRender(t *testing.T) {
+	template := &model.PromptTemplate{
+		Name: "support",
+		Messages: []model.PromptTemplateMessage{
+			{Role: "system", Content: "You answer for {{ product }} in {{language}}."},
+			{Role: "user", Content: "{{question}}"},
+		},
+		Defaults: map[string]string{"language": "English"},
+	}
+	require.NoError(t, template.Validate())
+
+	messages, err := template.Render(map[string]string{
+		"product":  "aiproxy",
+		"question": "How do I add a channel?",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []model.PromptTemplateMessage{
+		{Role: "system", Content: "You answer for aiproxy in English."},
+		{Role: "user", Content: "How do I add a channel?"},
+	}, messages)
+
+	_, err = template.Render(map[string]string{"product": "aiproxy"})
+
+	var missing *model.MissingPromptVariableError
+	require.True(t, errors.As(err, &missing))
+	require.Equal(t, "question", missing.Name)
+}
+
+func TestPromptTemplateValidate(t *testing.T) {
+	require.Error(t, (&model.PromptTemplate{}).Validate())
+	require.Error(t, (&model.PromptTemplate{
+		Name:     "bad-role",
+		Messages: []model.PromptTemplateMessage{{Role: "tool", Content: "x"}},
+	}).Validate())
+	require.Error(t, (&model.PromptTemplate{
+		Name:     "unclosed",
+		Messages: []model.PromptTemplateMessage{{Role: "user", Content: "{{name"}},
+	}).Validate())
+}
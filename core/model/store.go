@@ -22,6 +22,9 @@ const (
 	StorePrefixVideoJob        = "video_job"
 	StorePrefixVideoGeneration = "video_generation"
 	StorePrefixGeminiFile      = "gemini_file"
+	StorePrefixGeminiCache     = "gemini_cache"
+	StorePrefixOpenAIFile      = "openai_file"
+	StorePrefixAudioGeneration = "audio_generation"
 	StorePrefixPromptCacheKey  = "prompt_cache_key"
 	StorePrefixCacheFollow     = "cachefollow"
 	StorePrefixCacheFollowUser = "cachefollow_user"
@@ -337,10 +340,22 @@ func VideoGenerationStoreID(generationID string) string {
 	return StoreID(StorePrefixVideoGeneration, generationID)
 }
 
+func AudioGenerationStoreID(generationID string) string {
+	return StoreID(StorePrefixAudioGeneration, generationID)
+}
+
 func GeminiFileStoreID(fileID string) string {
 	return StoreID(StorePrefixGeminiFile, fileID)
 }
 
+func OpenAIFileStoreID(fileID string) string {
+	return StoreID(StorePrefixOpenAIFile, fileID)
+}
+
+func GeminiCachedContentStoreID(name string) string {
+	return StoreID(StorePrefixGeminiCache, name)
+}
+
 func PromptCacheStoreID(modelName, promptCacheKey string, keyType CacheKeyType) string {
 	return HashedStoreID(StorePrefixPromptCacheKey, string(keyType), modelName, promptCacheKey)
 }
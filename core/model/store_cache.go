@@ -184,7 +184,7 @@ func cachePeekStore(group string, tokenID int, id string) (*StoreCache, bool) {
 		return storeCache, true
 	}
 
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return nil, false
 	}
 
@@ -219,19 +219,14 @@ func CacheSetStore(store *StoreCache) error {
 	key := getStoreCacheKey(store.GroupID, store.TokenID, store.ID)
 	cacheSetStoreLocal(key, store)
 
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
-
-	return cacheSetStore(
-		ctx,
-		key,
-		getStoreCacheNotFoundKey(store.GroupID, store.TokenID, store.ID),
-		store,
-	)
+	return cacheWriteRedis(key, func(ctx context.Context) error {
+		return cacheSetStore(
+			ctx,
+			key,
+			getStoreCacheNotFoundKey(store.GroupID, store.TokenID, store.ID),
+			store,
+		)
+	})
 }
 
 func cacheSetStore(ctx context.Context, key, notFoundKey string, store *StoreCache) error {
@@ -265,7 +260,7 @@ func CacheGetStore(group string, tokenID int, id string) (*StoreCache, error) {
 		return storeCache, nil
 	}
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
 		defer cancel()
 
@@ -313,7 +308,7 @@ func CacheGetStore(group string, tokenID int, id string) (*StoreCache, error) {
 		},
 	)
 	if err != nil {
-		if loaded && errors.Is(err, gorm.ErrRecordNotFound) && common.RedisEnabled {
+		if loaded && errors.Is(err, gorm.ErrRecordNotFound) && common.RedisAvailable() {
 			ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
 			defer cancel()
 
@@ -332,7 +327,7 @@ func CacheGetStore(group string, tokenID int, id string) (*StoreCache, error) {
 		return nil, NotFoundError(ErrStoreNotFound)
 	}
 
-	if loaded && common.RedisEnabled {
+	if loaded && common.RedisAvailable() {
 		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
 		defer cancel()
 
@@ -62,6 +62,10 @@ var (
 	baseTimeSummaryFields = []string{
 		"total_time_milliseconds",
 		"total_ttfb_milliseconds",
+		"total_upstream_connect_milliseconds",
+		"stream_count",
+		"total_stream_duration_milliseconds",
+		"total_stream_chunks",
 	}
 	serviceTierPrefixes = []string{
 		"service_tier_flex",
@@ -411,6 +415,13 @@ type SummaryDataSet struct {
 	Amount                `      json:",inline"                           gorm:"embedded"`
 	TotalTimeMilliseconds int64 `json:"total_time_milliseconds,omitempty"`
 	TotalTTFBMilliseconds int64 `json:"total_ttfb_milliseconds,omitempty"`
+
+	TotalUpstreamConnectMilliseconds int64 `json:"total_upstream_connect_milliseconds,omitempty"`
+	// StreamCount is the number of the streamed requests, the divisor of the
+	// stream duration and chunks
+	StreamCount                     int64 `json:"stream_count,omitempty"`
+	TotalStreamDurationMilliseconds int64 `json:"total_stream_duration_milliseconds,omitempty"`
+	TotalStreamChunks               int64 `json:"total_stream_chunks,omitempty"`
 }
 
 func (s *SummaryDataSet) Add(other SummaryDataSet) {
@@ -419,6 +430,20 @@ func (s *SummaryDataSet) Add(other SummaryDataSet) {
 	s.Amount.Add(other.Amount)
 	s.TotalTimeMilliseconds += other.TotalTimeMilliseconds
 	s.TotalTTFBMilliseconds += other.TotalTTFBMilliseconds
+	s.TotalUpstreamConnectMilliseconds += other.TotalUpstreamConnectMilliseconds
+	s.StreamCount += other.StreamCount
+	s.TotalStreamDurationMilliseconds += other.TotalStreamDurationMilliseconds
+	s.TotalStreamChunks += other.TotalStreamChunks
+}
+
+func (s *SummaryDataSet) AddStreamMetrics(metrics StreamMetrics) {
+	s.TotalUpstreamConnectMilliseconds += metrics.UpstreamConnect.Milliseconds()
+
+	if metrics.StreamChunks > 0 {
+		s.StreamCount++
+		s.TotalStreamDurationMilliseconds += metrics.StreamDuration.Milliseconds()
+		s.TotalStreamChunks += metrics.StreamChunks
+	}
 }
 
 type SummaryData struct {
@@ -590,6 +615,40 @@ func appendSummaryUsageUpdateData(
 	}
 }
 
+func appendSummaryStreamUpdateData(
+	data map[string]any,
+	tableName, prefix string,
+	set SummaryDataSet,
+) {
+	fields := []struct {
+		column string
+		value  int64
+	}{
+		{
+			column: "total_upstream_connect_milliseconds",
+			value:  set.TotalUpstreamConnectMilliseconds,
+		},
+		{column: "stream_count", value: set.StreamCount},
+		{
+			column: "total_stream_duration_milliseconds",
+			value:  set.TotalStreamDurationMilliseconds,
+		},
+		{column: "total_stream_chunks", value: set.TotalStreamChunks},
+	}
+
+	for _, field := range fields {
+		if field.value <= 0 {
+			continue
+		}
+
+		columnName := prefix + field.column
+		data[columnName] = gorm.Expr(
+			fmt.Sprintf("COALESCE(%s.%s, 0) + ?", tableName, columnName),
+			field.value,
+		)
+	}
+}
+
 func appendSummaryAmountUpdateData(
 	data map[string]any,
 	tableName, prefix string,
@@ -646,6 +705,7 @@ func (d *SummaryData) buildUpdateData(tableName string) map[string]any {
 	}
 
 	appendSummaryUsageUpdateData(data, tableName, "", d.Usage)
+	appendSummaryStreamUpdateData(data, tableName, "", d.SummaryDataSet)
 	appendSummaryCountUpdateData(data, tableName, "service_tier_flex_", d.ServiceTierFlex.Count)
 	appendSummaryUsageUpdateData(data, tableName, "service_tier_flex_", d.ServiceTierFlex.Usage)
 	appendSummaryAmountUpdateData(data, tableName, "service_tier_flex_", d.ServiceTierFlex.Amount)
@@ -830,16 +890,20 @@ func getChartData(
 	timezone *time.Location,
 	fields SummarySelectFields,
 ) ([]ChartData, error) {
-	query := LogDB.Model(&Summary{})
+	filter := func(query *gorm.DB) *gorm.DB {
+		if channelID != 0 {
+			query = query.Where("channel_id = ?", channelID)
+		}
 
-	if channelID != 0 {
-		query = query.Where("channel_id = ?", channelID)
-	}
+		if modelName != "" {
+			query = query.Where("model = ?", modelName)
+		}
 
-	if modelName != "" {
-		query = query.Where("model = ?", modelName)
+		return query
 	}
 
+	query := filter(LogDB.Model(&Summary{}))
+
 	switch {
 	case !start.IsZero() && !end.IsZero():
 		query = query.Where("hour_timestamp BETWEEN ? AND ?", start.Unix(), end.Unix())
@@ -862,7 +926,21 @@ func getChartData(
 		return nil, err
 	}
 
-	if len(chartData) > 0 && timeSpan != TimeSpanHour {
+	rollupChartData, err := getRollupChartData(
+		summaryDailyRollup,
+		summaryMonthlyRollup,
+		start,
+		end,
+		filter,
+		fields,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	chartData = append(chartData, rollupChartData...)
+
+	if len(chartData) > 0 && (timeSpan != TimeSpanHour || len(rollupChartData) > 0) {
 		chartData = aggregateDataToSpan(chartData, timeSpan, timezone)
 	}
 
@@ -881,19 +959,24 @@ func getGroupChartData(
 	timezone *time.Location,
 	fields SummarySelectFields,
 ) ([]ChartData, error) {
-	query := LogDB.Model(&GroupSummary{})
-	if group != "" {
-		query = query.Where("group_id = ?", group)
-	}
+	filter := func(query *gorm.DB) *gorm.DB {
+		if group != "" {
+			query = query.Where("group_id = ?", group)
+		}
 
-	if tokenName != "" {
-		query = query.Where("token_name = ?", tokenName)
-	}
+		if tokenName != "" {
+			query = query.Where("token_name = ?", tokenName)
+		}
+
+		if modelName != "" {
+			query = query.Where("model = ?", modelName)
+		}
 
-	if modelName != "" {
-		query = query.Where("model = ?", modelName)
+		return query
 	}
 
+	query := filter(LogDB.Model(&GroupSummary{}))
+
 	switch {
 	case !start.IsZero() && !end.IsZero():
 		query = query.Where("hour_timestamp BETWEEN ? AND ?", start.Unix(), end.Unix())
@@ -916,7 +999,21 @@ func getGroupChartData(
 		return nil, err
 	}
 
-	if len(chartData) > 0 && timeSpan != TimeSpanHour {
+	rollupChartData, err := getRollupChartData(
+		groupSummaryDailyRollup,
+		groupSummaryMonthlyRollup,
+		start,
+		end,
+		filter,
+		fields,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	chartData = append(chartData, rollupChartData...)
+
+	if len(chartData) > 0 && (timeSpan != TimeSpanHour || len(rollupChartData) > 0) {
 		chartData = aggregateDataToSpan(chartData, timeSpan, timezone)
 	}
 
@@ -0,0 +1,528 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/config"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SummaryDaily is the daily rollup of the hourly summaries, the days are in utc
+type SummaryDaily struct {
+	ID     int                `gorm:"primaryKey"`
+	Unique SummaryDailyUnique `gorm:"embedded"`
+	Data   SummaryData        `gorm:"embedded"`
+}
+
+type SummaryDailyUnique struct {
+	ChannelID    int    `gorm:"not null;uniqueIndex:idx_summary_daily_unique,priority:1"`
+	Model        string `gorm:"size:128;not null;uniqueIndex:idx_summary_daily_unique,priority:2"`
+	DayTimestamp int64  `gorm:"not null;uniqueIndex:idx_summary_daily_unique,priority:3,sort:desc"`
+}
+
+// SummaryMonthly is the monthly rollup of the daily summaries, the months are
+// in utc
+type SummaryMonthly struct {
+	ID     int                  `gorm:"primaryKey"`
+	Unique SummaryMonthlyUnique `gorm:"embedded"`
+	Data   SummaryData          `gorm:"embedded"`
+}
+
+type SummaryMonthlyUnique struct {
+	ChannelID      int    `gorm:"not null;uniqueIndex:idx_summary_monthly_unique,priority:1"`
+	Model          string `gorm:"size:128;not null;uniqueIndex:idx_summary_monthly_unique,priority:2"`
+	MonthTimestamp int64  `gorm:"not null;uniqueIndex:idx_summary_monthly_unique,priority:3,sort:desc"`
+}
+
+type GroupSummaryDaily struct {
+	ID     int                     `gorm:"primaryKey"`
+	Unique GroupSummaryDailyUnique `gorm:"embedded"`
+	Data   SummaryData             `gorm:"embedded"`
+}
+
+type GroupSummaryDailyUnique struct {
+	GroupID      string `gorm:"size:64;not null;uniqueIndex:idx_groupsummary_daily_unique,priority:1"`
+	TokenName    string `gorm:"size:32;not null;uniqueIndex:idx_groupsummary_daily_unique,priority:2"`
+	Model        string `gorm:"size:128;not null;uniqueIndex:idx_groupsummary_daily_unique,priority:3"`
+	DayTimestamp int64  `gorm:"not null;uniqueIndex:idx_groupsummary_daily_unique,priority:4,sort:desc"`
+}
+
+type GroupSummaryMonthly struct {
+	ID     int                       `gorm:"primaryKey"`
+	Unique GroupSummaryMonthlyUnique `gorm:"embedded"`
+	Data   SummaryData               `gorm:"embedded"`
+}
+
+type GroupSummaryMonthlyUnique struct {
+	GroupID        string `gorm:"size:64;not null;uniqueIndex:idx_groupsummary_monthly_unique,priority:1"`
+	TokenName      string `gorm:"size:32;not null;uniqueIndex:idx_groupsummary_monthly_unique,priority:2"`
+	Model          string `gorm:"size:128;not null;uniqueIndex:idx_groupsummary_monthly_unique,priority:3"`
+	MonthTimestamp int64  `gorm:"not null;uniqueIndex:idx_groupsummary_monthly_unique,priority:4,sort:desc"`
+}
+
+// SummaryRollup is the progress of a summary table, RolledUntil is the end of
+// the rolled up range of a rollup table and the rows of the table before
+// PrunedBefore are deleted
+type SummaryRollup struct {
+	Name         string `gorm:"size:64;primaryKey"`
+	RolledUntil  int64
+	PrunedBefore int64
+}
+
+const (
+	// summaryRollupMaxBuckets bounds the work of a run when catching up on the
+	// history, the next runs continue from where it stopped
+	summaryRollupMaxBuckets = 90
+	summaryRollupBatchSize  = 500
+)
+
+func dayBucket(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+func nextDayBucket(t time.Time) time.Time {
+	return t.Add(24 * time.Hour)
+}
+
+func monthBucket(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func nextMonthBucket(t time.Time) time.Time {
+	return t.AddDate(0, 1, 0)
+}
+
+type summaryRollup struct {
+	table            string
+	source           string
+	sourceModel      any
+	sourceTimeColumn string
+	timeColumn       string
+	keys             []string
+	bucket           func(time.Time) time.Time
+	next             func(time.Time) time.Time
+	// sourceStorageHours is the retention of the source rows
+	sourceStorageHours func() int64
+	// sourceRolled reports whether the source is a rollup table whose
+	// progress bounds this rollup
+	sourceRolled bool
+	roll         func(r *summaryRollup, from, to, bucket int64) error
+}
+
+var (
+	summaryDailyRollup = &summaryRollup{
+		table:              "summary_dailies",
+		source:             "summaries",
+		sourceModel:        &Summary{},
+		sourceTimeColumn:   "hour_timestamp",
+		timeColumn:         "day_timestamp",
+		keys:               []string{"channel_id", "model"},
+		bucket:             dayBucket,
+		next:               nextDayBucket,
+		sourceStorageHours: config.GetSummaryHourStorageHours,
+		roll:               rollupSummaries[SummaryDaily],
+	}
+	summaryMonthlyRollup = &summaryRollup{
+		table:              "summary_monthlies",
+		source:             "summary_dailies",
+		sourceModel:        &SummaryDaily{},
+		sourceTimeColumn:   "day_timestamp",
+		timeColumn:         "month_timestamp",
+		keys:               []string{"channel_id", "model"},
+		bucket:             monthBucket,
+		next:               nextMonthBucket,
+		sourceStorageHours: config.GetSummaryDayStorageHours,
+		sourceRolled:       true,
+		roll:               rollupSummaries[SummaryMonthly],
+	}
+	groupSummaryDailyRollup = &summaryRollup{
+		table:              "group_summary_dailies",
+		source:             "group_summaries",
+		sourceModel:        &GroupSummary{},
+		sourceTimeColumn:   "hour_timestamp",
+		timeColumn:         "day_timestamp",
+		keys:               []string{"group_id", "token_name", "model"},
+		bucket:             dayBucket,
+		next:               nextDayBucket,
+		sourceStorageHours: config.GetSummaryHourStorageHours,
+		roll:               rollupSummaries[GroupSummaryDaily],
+	}
+	groupSummaryMonthlyRollup = &summaryRollup{
+		table:              "group_summary_monthlies",
+		source:             "group_summary_dailies",
+		sourceModel:        &GroupSummaryDaily{},
+		sourceTimeColumn:   "day_timestamp",
+		timeColumn:         "month_timestamp",
+		keys:               []string{"group_id", "token_name", "model"},
+		bucket:             monthBucket,
+		next:               nextMonthBucket,
+		sourceStorageHours: config.GetSummaryDayStorageHours,
+		sourceRolled:       true,
+		roll:               rollupSummaries[GroupSummaryMonthly],
+	}
+)
+
+// the daily rollups run before the monthly rollups reading them
+var summaryRollups = []*summaryRollup{
+	summaryDailyRollup,
+	summaryMonthlyRollup,
+	groupSummaryDailyRollup,
+	groupSummaryMonthlyRollup,
+}
+
+// rollupSummaries sums the source rows of [from, to) into a bucket, the bucket
+// rows are overwritten so rolling a bucket again picks up the late rows
+func rollupSummaries[T any](r *summaryRollup, from, to, bucket int64) error {
+	selects := make([]string, 0, len(r.keys)+len(allSummaryFields)+1)
+	selects = append(selects, r.keys...)
+	selects = append(selects, fmt.Sprintf("%d as %s", bucket, r.timeColumn))
+
+	for _, field := range allSummaryFields {
+		selects = append(selects, fmt.Sprintf("sum(%s) as %s", field, field))
+	}
+
+	var rows []T
+
+	err := LogDB.
+		Table(r.source).
+		Select(strings.Join(selects, ", ")).
+		Where(r.sourceTimeColumn+" >= ? AND "+r.sourceTimeColumn+" < ?", from, to).
+		Group(strings.Join(r.keys, ", ")).
+		Find(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	columns := make([]clause.Column, 0, len(r.keys)+1)
+	for _, key := range r.keys {
+		columns = append(columns, clause.Column{Name: key})
+	}
+
+	columns = append(columns, clause.Column{Name: r.timeColumn})
+
+	return LogDB.
+		Clauses(clause.OnConflict{
+			Columns:   columns,
+			DoUpdates: clause.AssignmentColumns(allSummaryFields),
+		}).
+		CreateInBatches(&rows, summaryRollupBatchSize).Error
+}
+
+func getSummaryRollup(name string) (SummaryRollup, error) {
+	state := SummaryRollup{Name: name}
+
+	err := LogDB.Where("name = ?", name).Limit(1).Find(&state).Error
+
+	return state, err
+}
+
+func saveSummaryRollup(state SummaryRollup) error {
+	return LogDB.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rolled_until", "pruned_before"}),
+		}).
+		Create(&state).Error
+}
+
+// firstSourceTimestamp returns the oldest timestamp of the source rows, zero
+// when the source is empty
+func (r *summaryRollup) firstSourceTimestamp() (int64, error) {
+	var first *int64
+
+	err := LogDB.
+		Table(r.source).
+		Select("min(" + r.sourceTimeColumn + ")").
+		Scan(&first).Error
+	if err != nil || first == nil {
+		return 0, err
+	}
+
+	return *first, nil
+}
+
+func (r *summaryRollup) run(now time.Time) error {
+	state, err := getSummaryRollup(r.table)
+	if err != nil {
+		return err
+	}
+
+	sourceState, err := getSummaryRollup(r.source)
+	if err != nil {
+		return err
+	}
+
+	end := r.bucket(now)
+	if r.sourceRolled {
+		end = minTime(end, r.bucket(time.Unix(sourceState.RolledUntil, 0)))
+	}
+
+	var start time.Time
+	if state.RolledUntil != 0 {
+		// roll the last bucket again, the late rows of the source land in it
+		start = r.bucket(time.Unix(state.RolledUntil, 0).Add(-time.Second))
+	} else {
+		first, err := r.firstSourceTimestamp()
+		if err != nil {
+			return err
+		}
+
+		if first == 0 {
+			return r.prune(now, state, sourceState)
+		}
+
+		start = r.bucket(time.Unix(first, 0))
+	}
+
+	if sourceState.PrunedBefore != 0 {
+		start = maxTime(start, r.bucket(time.Unix(sourceState.PrunedBefore, 0)))
+	}
+
+	buckets := 0
+	for bucket := start; bucket.Before(end) && buckets < summaryRollupMaxBuckets; buckets++ {
+		next := r.next(bucket)
+
+		err := r.roll(r, bucket.Unix(), next.Unix(), bucket.Unix())
+		if err != nil {
+			return fmt.Errorf("rollup %s into %s: %w", r.source, r.table, err)
+		}
+
+		state.RolledUntil = next.Unix()
+		bucket = next
+	}
+
+	if err := saveSummaryRollup(state); err != nil {
+		return err
+	}
+
+	return r.prune(now, state, sourceState)
+}
+
+// prune deletes the source rows older than the retention, the rows not rolled
+// up yet are kept
+func (r *summaryRollup) prune(now time.Time, state, sourceState SummaryRollup) error {
+	storageHours := r.sourceStorageHours()
+	if storageHours == 0 || state.RolledUntil == 0 {
+		return nil
+	}
+
+	cutoff := r.bucket(now.Add(-time.Duration(storageHours) * time.Hour))
+	cutoff = minTime(cutoff, r.bucket(time.Unix(state.RolledUntil, 0).Add(-time.Second)))
+
+	if cutoff.Unix() <= sourceState.PrunedBefore {
+		return nil
+	}
+
+	err := deleteSummariesBefore(r.sourceModel, r.sourceTimeColumn, cutoff.Unix())
+	if err != nil {
+		return err
+	}
+
+	sourceState.PrunedBefore = cutoff.Unix()
+
+	return saveSummaryRollup(sourceState)
+}
+
+func deleteSummariesBefore(model any, timeColumn string, before int64) error {
+	batchSize := int(config.GetCleanLogBatchSize())
+	if batchSize <= 0 {
+		batchSize = defaultCleanLogBatchSize
+	}
+
+	for {
+		subQuery := LogDB.
+			Model(model).
+			Where(timeColumn+" < ?", before).
+			Limit(batchSize).
+			Select("id")
+
+		result := LogDB.
+			Session(&gorm.Session{SkipDefaultTransaction: true}).
+			Where("id IN (?)", subQuery).
+			Delete(model)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected < int64(batchSize) {
+			return nil
+		}
+	}
+}
+
+// RollupSummaries rolls the hourly summaries up into the daily and monthly
+// tables and prunes the summaries older than the retentions
+func RollupSummaries() error {
+	now := time.Now()
+
+	var errs []error
+
+	for _, rollup := range summaryRollups {
+		if err := rollup.run(now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	minuteStorageHours := config.GetSummaryMinuteStorageHours()
+	if minuteStorageHours != 0 {
+		before := now.Add(-time.Duration(minuteStorageHours) * time.Hour).Unix()
+
+		for _, model := range []any{&SummaryMinute{}, &GroupSummaryMinute{}} {
+			if err := deleteSummariesBefore(model, "minute_timestamp", before); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// getRollupChartData reads the ranges pruned from the hourly summaries from the
+// daily and monthly rollups, the rows of a rollup table are only read before
+// the prune boundary of its source so nothing is counted twice
+func getRollupChartData(
+	daily, monthly *summaryRollup,
+	start, end time.Time,
+	filter func(*gorm.DB) *gorm.DB,
+	fields SummarySelectFields,
+) ([]ChartData, error) {
+	return getRollupData[ChartData](
+		daily,
+		monthly,
+		start,
+		end,
+		filter,
+		fields.BuildSelectFields,
+		"timestamp",
+	)
+}
+
+func getRollupData[T any](
+	daily, monthly *summaryRollup,
+	start, end time.Time,
+	filter func(*gorm.DB) *gorm.DB,
+	selectFields func(timeColumn string) string,
+	group string,
+) ([]T, error) {
+	queries, err := getRollupQueries(daily, monthly, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []T
+
+	for _, query := range queries {
+		var rows []T
+
+		err = filter(query.db).
+			Select(selectFields(query.timeColumn)).
+			Group(group).
+			Find(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+
+		data = append(data, rows...)
+	}
+
+	return data, nil
+}
+
+type rollupQuery struct {
+	db         *gorm.DB
+	timeColumn string
+}
+
+// getRollupQueries returns the queries of the daily and monthly rollups over
+// the ranges pruned from their sources, the rollups without a pruned range are
+// skipped
+func getRollupQueries(daily, monthly *summaryRollup, start, end time.Time) ([]rollupQuery, error) {
+	var queries []rollupQuery
+
+	for _, rollup := range []*summaryRollup{daily, monthly} {
+		sourceState, err := getSummaryRollup(rollup.source)
+		if err != nil {
+			return nil, err
+		}
+
+		if sourceState.PrunedBefore == 0 ||
+			(!start.IsZero() && start.Unix() >= sourceState.PrunedBefore) {
+			continue
+		}
+
+		query := LogDB.Table(rollup.table).
+			Where(rollup.timeColumn+" < ?", sourceState.PrunedBefore)
+
+		if !start.IsZero() {
+			query = query.Where(rollup.timeColumn+" >= ?", rollup.bucket(start).Unix())
+		}
+
+		if !end.IsZero() {
+			query = query.Where(rollup.timeColumn+" <= ?", end.Unix())
+		}
+
+		queries = append(queries, rollupQuery{db: query, timeColumn: rollup.timeColumn})
+	}
+
+	return queries, nil
+}
+
+// getSummaryRowsTable returns the rows of the hourly summaries in [start, end]
+// together with the rows of the rollups over the ranges pruned from them as
+// one table, the columns are selected from every table so the table can be
+// grouped, ordered and paged by the caller
+func getSummaryRowsTable(
+	daily, monthly *summaryRollup,
+	start, end time.Time,
+	columns string,
+) (*gorm.DB, error) {
+	queries, err := getRollupQueries(daily, monthly, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := []any{
+		buildConsumptionRankingTimeQuery(
+			LogDB.Table(daily.source),
+			daily.sourceTimeColumn,
+			start,
+			end,
+		).Select(columns),
+	}
+
+	for _, query := range queries {
+		parts = append(parts, query.db.Select(columns))
+	}
+
+	union := LogDB.Raw(
+		strings.Repeat("? UNION ALL ", len(parts)-1)+"?",
+		parts...,
+	)
+
+	return LogDB.Table("(?) as summary_rows", union), nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Compare(b) <= 0 {
+		return a
+	}
+
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.Compare(b) >= 0 {
+		return a
+	}
+
+	return b
+}
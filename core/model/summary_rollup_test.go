@@ -0,0 +1,114 @@
+package model_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupSummaryRollupDB(t *testing.T) {
+	t.Helper()
+
+	db, err := model.OpenSQLite(filepath.Join(t.TempDir(), "summary-rollup.db"))
+	require.NoError(t, err)
+
+	prevLogDB := model.LogDB
+	prevHourStorageHours := config.GetSummaryHourStorageHours()
+	prevDayStorageHours := config.GetSummaryDayStorageHours()
+	prevMinuteStorageHours := config.GetSummaryMinuteStorageHours()
+
+	model.LogDB = db
+
+	t.Cleanup(func() {
+		model.LogDB = prevLogDB
+		config.SetSummaryHourStorageHours(prevHourStorageHours)
+		config.SetSummaryDayStorageHours(prevDayStorageHours)
+		config.SetSummaryMinuteStorageHours(prevMinuteStorageHours)
+	})
+
+	require.NoError(t, db.AutoMigrate(
+		&model.Summary{},
+		&model.SummaryDaily{},
+		&model.SummaryMonthly{},
+		&model.GroupSummary{},
+		&model.GroupSummaryDaily{},
+		&model.GroupSummaryMonthly{},
+		&model.SummaryRollup{},
+	))
+}
+
+func TestSummaryReadsIncludePrunedRollups(t *testing.T) {
+	setupSummaryRollupDB(t)
+
+	now := time.Now()
+	old := now.Add(-10 * 24 * time.Hour).Truncate(time.Hour)
+	recent := now.Add(-time.Hour).Truncate(time.Hour)
+
+	for _, row := range []struct {
+		group  string
+		hour   time.Time
+		amount float64
+	}{
+		{group: "g1", hour: old, amount: 1},
+		{group: "g1", hour: recent, amount: 2},
+		{group: "g2", hour: recent, amount: 1.5},
+	} {
+		data := model.SummaryData{}
+		data.RequestCount = 1
+		data.UsedAmount = row.amount
+
+		require.NoError(t, model.UpsertGroupSummary(model.GroupSummaryUnique{
+			GroupID:       row.group,
+			Model:         "gpt-5",
+			HourTimestamp: row.hour.Unix(),
+		}, data))
+	}
+
+	config.SetSummaryHourStorageHours(24)
+	config.SetSummaryDayStorageHours(0)
+	config.SetSummaryMinuteStorageHours(0)
+	require.NoError(t, model.RollupSummaries())
+
+	var hourly int64
+	require.NoError(t, model.LogDB.Model(&model.GroupSummary{}).Count(&hourly).Error)
+	require.EqualValues(t, 2, hourly, "the old hourly row is pruned")
+
+	start := now.Add(-20 * 24 * time.Hour)
+
+	spend, err := model.GetGroupModelUsedAmount("g1", "gpt-5", start)
+	require.NoError(t, err)
+	assert.InDelta(t, 3, spend, 1e-9)
+
+	usages, err := model.GetModelGroupUsages("gpt-5", start)
+	require.NoError(t, err)
+	assert.Equal(t, []model.ModelGroupUsage{
+		{GroupID: "g1", RequestCount: 2, UsedAmount: 3},
+		{GroupID: "g2", RequestCount: 1, UsedAmount: 1.5},
+	}, usages)
+
+	ranking, total, _, err := model.GetGroupConsumptionRanking(start, time.Time{}, 1, 10, "")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.Len(t, ranking, 2)
+	assert.Equal(t, "g1", ranking[0].GroupID)
+	assert.EqualValues(t, 2, ranking[0].RequestCount)
+	assert.InDelta(t, 3, ranking[0].UsedAmount, 1e-9)
+
+	items, total, _, err := model.GetConsumptionRanking(
+		model.ConsumptionRankingTypeGroup,
+		start,
+		now,
+		1,
+		1,
+		"used_amount_asc",
+	)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.Len(t, items, 1)
+	assert.Equal(t, "g2", items[0].GroupID)
+}
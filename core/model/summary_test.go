@@ -1100,3 +1100,28 @@ func TestBuildSelectFieldsV2_WhitelistValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestSummaryDataSetAddStreamMetrics(t *testing.T) {
+	var data model.SummaryDataSet
+
+	data.AddStreamMetrics(model.StreamMetrics{UpstreamConnect: 80 * time.Millisecond})
+	data.AddStreamMetrics(model.StreamMetrics{
+		StreamDuration: 1500 * time.Millisecond,
+		StreamChunks:   30,
+	})
+
+	if data.TotalUpstreamConnectMilliseconds != 80 {
+		t.Fatalf("total upstream connect = %d, want 80", data.TotalUpstreamConnectMilliseconds)
+	}
+
+	if data.StreamCount != 1 ||
+		data.TotalStreamDurationMilliseconds != 1500 ||
+		data.TotalStreamChunks != 30 {
+		t.Fatalf(
+			"stream count = %d, duration = %d, chunks = %d, want 1, 1500, 30",
+			data.StreamCount,
+			data.TotalStreamDurationMilliseconds,
+			data.TotalStreamChunks,
+		)
+	}
+}
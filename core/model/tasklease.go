@@ -0,0 +1,76 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TaskLease is the lease of a periodic task, only the holder of an
+// unexpired lease runs the task, so that the task runs on one instance
+type TaskLease struct {
+	Name      string    `gorm:"size:64;primaryKey" json:"name"`
+	Holder    string    `gorm:"size:64"            json:"holder"`
+	ExpiresAt time.Time `                          json:"expires_at"`
+}
+
+// dbTimeAfter returns the expression of the database time shifted by d, the
+// leases are written and compared with the clock of the database so a skewed
+// clock of an instance can not take over or keep a lease
+func dbTimeAfter(db *gorm.DB, d time.Duration) clause.Expr {
+	switch db.Name() {
+	case "sqlite":
+		return gorm.Expr(
+			"strftime('%Y-%m-%d %H:%M:%f', 'now', ?)",
+			fmt.Sprintf("%+.3f seconds", d.Seconds()),
+		)
+	case "mysql":
+		return gorm.Expr("DATE_ADD(NOW(6), INTERVAL ? MICROSECOND)", d.Microseconds())
+	default:
+		return gorm.Expr("NOW() + ? * INTERVAL '1 microsecond'", d.Microseconds())
+	}
+}
+
+// TryAcquireTaskLease acquires or renews the lease, it succeeds when the lease
+// is free, expired or already held by the holder
+func TryAcquireTaskLease(name, holder string, ttl time.Duration) (bool, error) {
+	tx := DB.
+		Model(&TaskLease{}).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(map[string]any{
+			"name":       name,
+			"holder":     holder,
+			"expires_at": dbTimeAfter(DB, ttl),
+		})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+
+	if tx.RowsAffected > 0 {
+		return true, nil
+	}
+
+	tx = DB.
+		Model(&TaskLease{}).
+		Where("name = ?", name).
+		Where(DB.Where("holder = ?", holder).Or("expires_at < ?", dbTimeAfter(DB, 0))).
+		Updates(map[string]any{
+			"holder":     holder,
+			"expires_at": dbTimeAfter(DB, ttl),
+		})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+
+	return tx.RowsAffected > 0, nil
+}
+
+// ReleaseTaskLease releases the lease held by the holder
+func ReleaseTaskLease(name, holder string) error {
+	return DB.
+		Where("name = ? AND holder = ?", name, holder).
+		Delete(&TaskLease{}).
+		Error
+}
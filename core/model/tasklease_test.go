@@ -0,0 +1,74 @@
+package model_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/model"
+)
+
+func TestTryAcquireTaskLease(t *testing.T) {
+	prevDB := model.DB
+	prevUsingSQLite := common.UsingSQLite
+
+	testDB, err := model.OpenSQLite(filepath.Join(t.TempDir(), "task-lease.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite db: %v", err)
+	}
+
+	model.DB = testDB
+	common.UsingSQLite = true
+	t.Cleanup(func() {
+		model.DB = prevDB
+		common.UsingSQLite = prevUsingSQLite
+	})
+
+	if err := testDB.AutoMigrate(&model.TaskLease{}); err != nil {
+		t.Fatalf("failed to migrate task lease: %v", err)
+	}
+
+	acquire := func(holder string, ttl time.Duration) bool {
+		t.Helper()
+
+		acquired, err := model.TryAcquireTaskLease("task", holder, ttl)
+		if err != nil {
+			t.Fatalf("failed to acquire task lease: %v", err)
+		}
+
+		return acquired
+	}
+
+	if !acquire("a", time.Minute) {
+		t.Fatal("expected a to acquire the free lease")
+	}
+
+	if acquire("b", time.Minute) {
+		t.Fatal("expected b not to acquire the lease held by a")
+	}
+
+	if !acquire("a", -time.Second) {
+		t.Fatal("expected a to renew its lease")
+	}
+
+	if !acquire("b", time.Minute) {
+		t.Fatal("expected b to acquire the expired lease")
+	}
+
+	if err := model.ReleaseTaskLease("task", "a"); err != nil {
+		t.Fatalf("failed to release task lease: %v", err)
+	}
+
+	if acquire("a", time.Minute) {
+		t.Fatal("expected the release by a non holder to be ignored")
+	}
+
+	if err := model.ReleaseTaskLease("task", "b"); err != nil {
+		t.Fatalf("failed to release task lease: %v", err)
+	}
+
+	if !acquire("a", time.Minute) {
+		t.Fatal("expected a to acquire the released lease")
+	}
+}
@@ -30,13 +30,17 @@ const (
 	TokenStatusDisabled = 2
 )
 
+// Token is soft deleted, the deleted tokens are archived so the logs and the
+// usage reports keep their attribution until the archive is purged
 type Token struct {
+	DeletedAt gorm.DeletedAt  `json:"-"          gorm:"index"`
 	CreatedAt time.Time       `json:"created_at"`
 	Group     *Group          `json:"-"          gorm:"foreignKey:GroupID"`
 	Key       string          `json:"key"        gorm:"type:char(48);uniqueIndex"`
 	Name      EmptyNullString `json:"name"       gorm:"size:32;index;uniqueIndex:idx_group_name;not null"`
 	GroupID   string          `json:"group"      gorm:"size:64;index;uniqueIndex:idx_group_name"`
 	Subnets   []string        `json:"subnets"    gorm:"serializer:fastjson;type:text"`
+	Regions   []string        `json:"regions"    gorm:"serializer:fastjson;type:text"` // allowed client countries or continents
 	Models    []string        `json:"models"     gorm:"serializer:fastjson;type:text"`
 	Status    int             `json:"status"     gorm:"default:1;index"`
 	ID        int             `json:"id"         gorm:"primaryKey"`
@@ -49,6 +53,28 @@ type Token struct {
 	PeriodType             EmptyNullString `json:"period_type"               gorm:"size:20"` // daily, weekly, monthly, default is monthly
 	PeriodLastUpdateTime   time.Time       `json:"period_last_update_time"`                  // Last time period was reset
 	PeriodLastUpdateAmount float64         `json:"period_last_update_amount"`                // Total usage at last period reset
+
+	// DebugUpstreamErrors includes the raw upstream error body and headers in
+	// the error responses of the token
+	DebugUpstreamErrors bool `json:"debug_upstream_errors"`
+	// DebugConversion logs the bodies of all the requests of the token before
+	// and after the conversion, regardless of the sample rate
+	DebugConversion bool `json:"debug_conversion"`
+
+	// HedgeAfterMs issues the request to a second channel when the first has
+	// not produced a first byte within it, zero disables the hedging
+	HedgeAfterMs int64 `json:"hedge_after_ms"`
+
+	// AllowProviderPreferences allows the requests of the token to restrict
+	// and order the channels with the provider object of the body
+	AllowProviderPreferences bool `json:"allow_provider_preferences"`
+
+	// AllowedOrigins are the browser origins allowed to use the token, empty
+	// allows all the origins
+	AllowedOrigins []string `json:"allowed_origins" gorm:"serializer:fastjson;type:text"`
+	// ServerOnly rejects the browser requests of the token, the requests with
+	// an Origin header
+	ServerOnly bool `json:"server_only"`
 }
 
 func (t *Token) BeforeCreate(_ *gorm.DB) error {
@@ -146,6 +172,24 @@ func (t *Token) NeedsPeriodReset() (bool, error) {
 	}
 }
 
+// NextPeriodResetTime returns when the period usage is reset next, it
+// follows the boundaries of NeedsPeriodReset
+func (t *Token) NextPeriodResetTime() time.Time {
+	baseTime := t.PeriodLastUpdateTime
+	if baseTime.IsZero() {
+		return time.Now()
+	}
+
+	switch t.PeriodType {
+	case PeriodTypeWeekly:
+		return baseTime.Add(7 * 24 * time.Hour)
+	case PeriodTypeDaily:
+		return baseTime.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	default:
+		return time.Date(baseTime.Year(), baseTime.Month()+1, 1, 0, 0, 0, 0, baseTime.Location())
+	}
+}
+
 const (
 	keyChars = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 )
@@ -175,18 +219,24 @@ func getTokenOrder(order string) string {
 }
 
 func InsertToken(token *Token, autoCreateGroup, ignoreExist bool) error {
+	return insertToken(DB, token, autoCreateGroup, ignoreExist)
+}
+
+func insertToken(db *gorm.DB, token *Token, autoCreateGroup, ignoreExist bool) error {
 	if autoCreateGroup {
 		group := &Group{
 			ID: token.GroupID,
 		}
-		if err := OnConflictDoNothing().Create(group).Error; err != nil {
+
+		err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(group).Error
+		if err != nil {
 			return err
 		}
 	}
 
 	maxTokenNum := config.GetGroupMaxTokenNum()
 
-	err := DB.Transaction(func(tx *gorm.DB) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
 		if maxTokenNum > 0 {
 			var count int64
 
@@ -200,6 +250,15 @@ func InsertToken(token *Token, autoCreateGroup, ignoreExist bool) error {
 			}
 		}
 
+		// the archived token keeps its name, it is purged so the name can be reused
+		err := tx.Unscoped().
+			Where("group_id = ? and name = ? and deleted_at IS NOT NULL", token.GroupID, token.Name).
+			Delete(&Token{}).
+			Error
+		if err != nil {
+			return err
+		}
+
 		if ignoreExist {
 			return tx.
 				Where("group_id = ? and name = ?", token.GroupID, token.Name).
@@ -636,14 +695,20 @@ func DeleteTokenByID(id int) (err error) {
 		}
 	}()
 
-	result := DB.
+	return deleteToken(DB, &token)
+}
+
+// deleteToken deletes the token of the id, the key of the deleted token is
+// returned in the token
+func deleteToken(db *gorm.DB, token *Token) error {
+	result := db.
 		Clauses(clause.Returning{
 			Columns: []clause.Column{
 				{Name: "key"},
 			},
 		}).
-		Where(token).
-		Delete(&token)
+		Where(Token{ID: token.ID}).
+		Delete(token)
 
 	return HandleUpdateResult(result, ErrTokenNotFound)
 }
@@ -680,8 +745,22 @@ func DeleteTokensByIDs(ids []int) (err error) {
 type UpdateTokenRequest struct {
 	Name    *string   `json:"name"`
 	Subnets *[]string `json:"subnets"`
+	Regions *[]string `json:"regions"`
 	Models  *[]string `json:"models"`
 	Status  int       `json:"status"`
+	// DebugUpstreamErrors includes the raw upstream errors in the error responses
+	DebugUpstreamErrors *bool `json:"debug_upstream_errors"`
+	// DebugConversion logs the bodies of the requests before and after the
+	// conversion
+	DebugConversion *bool `json:"debug_conversion"`
+	// HedgeAfterMs hedges the requests of the latency critical tokens
+	HedgeAfterMs *int64 `json:"hedge_after_ms"`
+	// AllowProviderPreferences allows the provider preferences in the requests
+	AllowProviderPreferences *bool `json:"allow_provider_preferences"`
+	// AllowedOrigins are the browser origins allowed to use the token
+	AllowedOrigins *[]string `json:"allowed_origins"`
+	// ServerOnly rejects the browser requests of the token
+	ServerOnly *bool `json:"server_only"`
 	// Quota system
 	Quota                *float64 `json:"quota"`
 	PeriodQuota          *float64 `json:"period_quota"`
@@ -690,29 +769,35 @@ type UpdateTokenRequest struct {
 }
 
 func UpdateToken(id int, update UpdateTokenRequest) (token *Token, err error) {
+	defer func() {
+		if err == nil {
+			if err := CacheDeleteToken(token.Key); err != nil {
+				log.Error("delete token from cache failed: " + err.Error())
+			}
+		}
+	}()
+
+	return updateToken(DB, id, update)
+}
+
+func updateToken(db *gorm.DB, id int, update UpdateTokenRequest) (*Token, error) {
 	if id == 0 {
 		return nil, errors.New("id is empty")
 	}
 
 	// First, get the current token to check if period_last_update_time is already initialized
-	currentToken, err := GetTokenByID(id)
+	currentToken := Token{ID: id}
+
+	err := db.First(&currentToken, "id = ?", id).Error
 	if err != nil {
-		return nil, err
+		return nil, HandleNotFound(err, ErrTokenNotFound)
 	}
 
-	token = &Token{
+	token := &Token{
 		ID:     id,
 		Status: update.Status,
 	}
 
-	defer func() {
-		if err == nil {
-			if err := CacheDeleteToken(token.Key); err != nil {
-				log.Error("delete token from cache failed: " + err.Error())
-			}
-		}
-	}()
-
 	selects := []string{}
 	if update.Name != nil && *update.Name != "" {
 		token.Name = EmptyNullString(*update.Name)
@@ -759,6 +844,48 @@ func UpdateToken(id int, update UpdateTokenRequest) (token *Token, err error) {
 		selects = append(selects, "subnets")
 	}
 
+	if update.Regions != nil {
+		token.Regions = *update.Regions
+
+		selects = append(selects, "regions")
+	}
+
+	if update.DebugUpstreamErrors != nil {
+		token.DebugUpstreamErrors = *update.DebugUpstreamErrors
+
+		selects = append(selects, "debug_upstream_errors")
+	}
+
+	if update.DebugConversion != nil {
+		token.DebugConversion = *update.DebugConversion
+
+		selects = append(selects, "debug_conversion")
+	}
+
+	if update.HedgeAfterMs != nil {
+		token.HedgeAfterMs = *update.HedgeAfterMs
+
+		selects = append(selects, "hedge_after_ms")
+	}
+
+	if update.AllowProviderPreferences != nil {
+		token.AllowProviderPreferences = *update.AllowProviderPreferences
+
+		selects = append(selects, "allow_provider_preferences")
+	}
+
+	if update.AllowedOrigins != nil {
+		token.AllowedOrigins = *update.AllowedOrigins
+
+		selects = append(selects, "allowed_origins")
+	}
+
+	if update.ServerOnly != nil {
+		token.ServerOnly = *update.ServerOnly
+
+		selects = append(selects, "server_only")
+	}
+
 	if update.Models != nil {
 		token.Models = *update.Models
 
@@ -773,7 +900,7 @@ func UpdateToken(id int, update UpdateTokenRequest) (token *Token, err error) {
 		return nil, errors.New("empty update request")
 	}
 
-	result := DB.
+	result := db.
 		Select(selects).
 		Where("id = ?", id).
 		Clauses(clause.Returning{}).
@@ -862,6 +989,48 @@ func UpdateGroupToken(
 		selects = append(selects, "subnets")
 	}
 
+	if update.Regions != nil {
+		token.Regions = *update.Regions
+
+		selects = append(selects, "regions")
+	}
+
+	if update.DebugUpstreamErrors != nil {
+		token.DebugUpstreamErrors = *update.DebugUpstreamErrors
+
+		selects = append(selects, "debug_upstream_errors")
+	}
+
+	if update.DebugConversion != nil {
+		token.DebugConversion = *update.DebugConversion
+
+		selects = append(selects, "debug_conversion")
+	}
+
+	if update.HedgeAfterMs != nil {
+		token.HedgeAfterMs = *update.HedgeAfterMs
+
+		selects = append(selects, "hedge_after_ms")
+	}
+
+	if update.AllowProviderPreferences != nil {
+		token.AllowProviderPreferences = *update.AllowProviderPreferences
+
+		selects = append(selects, "allow_provider_preferences")
+	}
+
+	if update.AllowedOrigins != nil {
+		token.AllowedOrigins = *update.AllowedOrigins
+
+		selects = append(selects, "allowed_origins")
+	}
+
+	if update.ServerOnly != nil {
+		token.ServerOnly = *update.ServerOnly
+
+		selects = append(selects, "server_only")
+	}
+
 	if update.Models != nil {
 		token.Models = *update.Models
 
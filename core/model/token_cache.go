@@ -31,6 +31,7 @@ type TokenCache struct {
 	Key        string           `json:"-"           redis:"-"`
 	Name       string           `json:"name"        redis:"n"`
 	Subnets    redisStringSlice `json:"subnets"     redis:"s"`
+	Regions    redisStringSlice `json:"regions"     redis:"r"`
 	Models     redisStringSlice `json:"models"      redis:"m"`
 	ID         int              `json:"id"          redis:"i"`
 	Status     int              `json:"status"      redis:"st"`
@@ -42,6 +43,14 @@ type TokenCache struct {
 	PeriodLastUpdateTime   redisTime `json:"period_last_update_time"   redis:"plut"`
 	PeriodLastUpdateAmount float64   `json:"period_last_update_amount" redis:"plua"`
 
+	DebugUpstreamErrors      bool  `json:"debug_upstream_errors"      redis:"du"`
+	DebugConversion          bool  `json:"debug_conversion"           redis:"dc"`
+	HedgeAfterMs             int64 `json:"hedge_after_ms"             redis:"ha"`
+	AllowProviderPreferences bool  `json:"allow_provider_preferences" redis:"ap"`
+
+	AllowedOrigins redisStringSlice `json:"allowed_origins" redis:"ao"`
+	ServerOnly     bool             `json:"server_only"     redis:"so"`
+
 	availableSets []string
 	modelsBySet   map[string][]string
 }
@@ -90,6 +99,22 @@ func containsModel(model string, sets []string, modelsBySet map[string][]string)
 	return findModel
 }
 
+// PeriodUsage returns the usage of the current period and when the period is
+// reset, the usage of a period due for a reset is zero
+func (t *TokenCache) PeriodUsage() (float64, time.Time) {
+	token := Token{
+		PeriodType:           EmptyNullString(t.PeriodType),
+		PeriodLastUpdateTime: time.Time(t.PeriodLastUpdateTime),
+	}
+
+	if needsReset, err := token.NeedsPeriodReset(); err != nil || needsReset {
+		token.PeriodLastUpdateTime = time.Now()
+		return 0, token.NextPeriodResetTime()
+	}
+
+	return t.UsedAmount - t.PeriodLastUpdateAmount, token.NextPeriodResetTime()
+}
+
 func (t *TokenCache) Range(fn func(model string) bool) {
 	ranged := make(map[string]struct{})
 	if len(t.Models) != 0 {
@@ -133,6 +158,7 @@ func (t *Token) ToTokenCache() *TokenCache {
 		Name:       string(t.Name),
 		Models:     t.Models,
 		Subnets:    t.Subnets,
+		Regions:    t.Regions,
 		Status:     t.Status,
 		UsedAmount: t.UsedAmount,
 
@@ -141,20 +167,25 @@ func (t *Token) ToTokenCache() *TokenCache {
 		PeriodType:             string(t.PeriodType),
 		PeriodLastUpdateTime:   redisTime(t.PeriodLastUpdateTime),
 		PeriodLastUpdateAmount: t.PeriodLastUpdateAmount,
+
+		DebugUpstreamErrors:      t.DebugUpstreamErrors,
+		DebugConversion:          t.DebugConversion,
+		HedgeAfterMs:             t.HedgeAfterMs,
+		AllowProviderPreferences: t.AllowProviderPreferences,
+
+		AllowedOrigins: t.AllowedOrigins,
+		ServerOnly:     t.ServerOnly,
 	}
 }
 
 func CacheDeleteToken(key string) error {
 	cacheDeleteModelLocal(getTokenCacheKey(key))
 
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
+	cacheKey := getTokenCacheKey(key)
 
-	return common.RDB.Del(ctx, getTokenCacheKey(key)).Err()
+	return cacheWriteRedis(cacheKey, func(ctx context.Context) error {
+		return common.RDB.Del(ctx, cacheKey).Err()
+	})
 }
 
 func CacheSetToken(token *TokenCache) error {
@@ -173,7 +204,7 @@ func CacheGetTokenByKey(key string) (*TokenCache, error) {
 		return tokenCache, nil
 	}
 
-	if common.RedisEnabled {
+	if common.RedisAvailable() {
 		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
 		defer cancel()
 
@@ -229,22 +260,18 @@ func CacheGetTokenByKey(key string) (*TokenCache, error) {
 }
 
 func cacheSetTokenRedis(token *TokenCache) error {
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
-
 	key := getTokenCacheKey(token.Key)
-	pipe := common.RDB.Pipeline()
-	pipe.HSet(ctx, key, token)
 
-	expireTime := SyncFrequency + time.Duration(rand.Int64N(60)-30)*time.Second
-	pipe.Expire(ctx, key, expireTime)
-	_, err := pipe.Exec(ctx)
+	return cacheWriteRedis(key, func(ctx context.Context) error {
+		pipe := common.RDB.Pipeline()
+		pipe.HSet(ctx, key, token)
+
+		expireTime := SyncFrequency + time.Duration(rand.Int64N(60)-30)*time.Second
+		pipe.Expire(ctx, key, expireTime)
+		_, err := pipe.Exec(ctx)
 
-	return err
+		return err
+	})
 }
 
 var updateTokenUsedAmountOnlyIncreaseScript = redis.NewScript(`
@@ -270,19 +297,16 @@ func CacheUpdateTokenUsedAmountOnlyIncrease(key string, amount float64) error {
 		return true
 	})
 
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
+	cacheKey := getTokenCacheKey(key)
 
-	return updateTokenUsedAmountOnlyIncreaseScript.Run(
-		ctx,
-		common.RDB,
-		[]string{getTokenCacheKey(key)},
-		amount,
-	).Err()
+	return cacheWriteRedis(cacheKey, func(ctx context.Context) error {
+		return updateTokenUsedAmountOnlyIncreaseScript.Run(
+			ctx,
+			common.RDB,
+			[]string{cacheKey},
+			amount,
+		).Err()
+	})
 }
 
 func CacheResetTokenPeriodUsage(
@@ -296,21 +320,17 @@ func CacheResetTokenPeriodUsage(
 		return true
 	})
 
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
-
 	cacheKey := getTokenCacheKey(key)
-	pipe := common.RDB.Pipeline()
-	periodLastUpdateTimeBytes, _ := periodLastUpdateTime.MarshalBinary()
-	pipe.HSet(ctx, cacheKey, "plut", periodLastUpdateTimeBytes)
-	pipe.HSet(ctx, cacheKey, "plua", periodLastUpdateAmount)
-	_, err := pipe.Exec(ctx)
 
-	return err
+	return cacheWriteRedis(cacheKey, func(ctx context.Context) error {
+		pipe := common.RDB.Pipeline()
+		periodLastUpdateTimeBytes, _ := periodLastUpdateTime.MarshalBinary()
+		pipe.HSet(ctx, cacheKey, "plut", periodLastUpdateTimeBytes)
+		pipe.HSet(ctx, cacheKey, "plua", periodLastUpdateAmount)
+		_, err := pipe.Exec(ctx)
+
+		return err
+	})
 }
 
 var updateTokenNameScript = redis.NewScript(`
@@ -326,19 +346,16 @@ func CacheUpdateTokenName(key, name string) error {
 		return true
 	})
 
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
+	cacheKey := getTokenCacheKey(key)
 
-	return updateTokenNameScript.Run(
-		ctx,
-		common.RDB,
-		[]string{getTokenCacheKey(key)},
-		name,
-	).Err()
+	return cacheWriteRedis(cacheKey, func(ctx context.Context) error {
+		return updateTokenNameScript.Run(
+			ctx,
+			common.RDB,
+			[]string{cacheKey},
+			name,
+		).Err()
+	})
 }
 
 var updateTokenStatusScript = redis.NewScript(`
@@ -354,17 +371,14 @@ func CacheUpdateTokenStatus(key string, status int) error {
 		return true
 	})
 
-	if !common.RedisEnabled {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
-	defer cancel()
+	cacheKey := getTokenCacheKey(key)
 
-	return updateTokenStatusScript.Run(
-		ctx,
-		common.RDB,
-		[]string{getTokenCacheKey(key)},
-		status,
-	).Err()
+	return cacheWriteRedis(cacheKey, func(ctx context.Context) error {
+		return updateTokenStatusScript.Run(
+			ctx,
+			common.RDB,
+			[]string{cacheKey},
+			status,
+		).Err()
+	})
 }
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// UsageTimeSeriesPoint is a bucket of the usage time series
+type UsageTimeSeriesPoint struct {
+	Timestamp      int64   `json:"timestamp"`
+	RequestCount   int64   `json:"request_count"`
+	ExceptionCount int64   `json:"exception_count"`
+	ErrorRate      float64 `json:"error_rate"`
+	InputTokens    int64   `json:"input_tokens"`
+	OutputTokens   int64   `json:"output_tokens"`
+	TotalTokens    int64   `json:"total_tokens"`
+	UsedAmount     float64 `json:"used_amount"`
+}
+
+type UsageTimeSeries struct {
+	Group     string                 `json:"group"`
+	TokenName string                 `json:"token_name,omitempty"`
+	Bucket    TimeSpanType           `json:"bucket"`
+	Points    []UsageTimeSeriesPoint `json:"points"`
+}
+
+var usageTimeSeriesFields = ParseSummaryFields(
+	"request_count,exception_count,input_tokens,output_tokens,total_tokens,used_amount",
+)
+
+// GetGroupUsageChartData returns the hourly or daily chart data of the group or
+// its token, computed from the group summaries instead of the logs
+func GetGroupUsageChartData(
+	group, tokenName string,
+	start, end time.Time,
+	bucket TimeSpanType,
+	timezone *time.Location,
+) ([]ChartData, error) {
+	if group == "" {
+		return nil, errors.New("group is required")
+	}
+
+	if bucket != TimeSpanHour && bucket != TimeSpanDay {
+		return nil, errors.New("bucket must be hour or day")
+	}
+
+	if !end.IsZero() && end.Before(start) {
+		return nil, errors.New("end time is before start time")
+	}
+
+	return getGroupChartData(
+		group,
+		start,
+		end,
+		tokenName,
+		"",
+		bucket,
+		timezone,
+		usageTimeSeriesFields,
+	)
+}
+
+// NewUsageTimeSeriesPoints converts the chart data to the usage time series
+func NewUsageTimeSeriesPoints(chartData []ChartData) []UsageTimeSeriesPoint {
+	points := make([]UsageTimeSeriesPoint, 0, len(chartData))
+	for _, data := range chartData {
+		point := UsageTimeSeriesPoint{
+			Timestamp:      data.Timestamp,
+			RequestCount:   data.RequestCount,
+			ExceptionCount: int64(data.ExceptionCount),
+			InputTokens:    int64(data.InputTokens),
+			OutputTokens:   int64(data.OutputTokens),
+			TotalTokens:    int64(data.TotalTokens),
+			UsedAmount:     data.UsedAmount,
+		}
+		if point.RequestCount > 0 {
+			point.ErrorRate = float64(point.ExceptionCount) / float64(point.RequestCount)
+		}
+
+		points = append(points, point)
+	}
+
+	return points
+}
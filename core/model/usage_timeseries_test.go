@@ -0,0 +1,52 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/labring/aiproxy/core/model"
+)
+
+func TestNewUsageTimeSeriesPoints(t *testing.T) {
+	data := model.ChartData{Timestamp: 3600}
+	data.RequestCount = 4
+	data.ExceptionCount = 1
+	data.InputTokens = 100
+	data.OutputTokens = 20
+	data.TotalTokens = 120
+	data.UsedAmount = 0.5
+
+	points := model.NewUsageTimeSeriesPoints([]model.ChartData{
+		data,
+		{Timestamp: 7200},
+	})
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(points))
+	}
+
+	if points[0].ErrorRate != 0.25 {
+		t.Fatalf("expected error rate 0.25, got %v", points[0].ErrorRate)
+	}
+
+	if points[0].TotalTokens != 120 || points[0].UsedAmount != 0.5 {
+		t.Fatalf("unexpected point: %+v", points[0])
+	}
+
+	if points[1].ErrorRate != 0 || points[1].RequestCount != 0 {
+		t.Fatalf("expected an empty point, got %+v", points[1])
+	}
+}
+
+func TestGetGroupUsageChartDataRejectsInvalidBucket(t *testing.T) {
+	_, err := model.GetGroupUsageChartData(
+		"g1",
+		"",
+		time.Now().Add(-time.Hour),
+		time.Now(),
+		model.TimeSpanMinute,
+		time.UTC,
+	)
+	if err == nil {
+		t.Fatal("expected an error for the minute bucket")
+	}
+}
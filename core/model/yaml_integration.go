@@ -102,12 +102,18 @@ func ChannelTypeNameToType(typeName string) int {
 		"zhipu coding":                          52,
 		"zhipucoding":                           52,
 		"fake":                                  53,
+		"mock":                                  53,
+		"sandbox":                               53,
 		"antling":                               54,
 		"ant ling":                              54,
 		"蚂蚁百灵":                                  54,
 		"fake-error":                            55,
 		"fake error":                            55,
 		"fakeerror":                             55,
+		"nvidia-nim":                            59,
+		"nvidia nim":                            59,
+		"nim":                                   59,
+		"triton":                                59,
 	}
 
 	if typ, ok := typeMap[typeName]; ok {
@@ -143,6 +149,10 @@ func ModelTypeNameToType(typeName string) mode.Mode {
 		"transcription":             mode.AudioTranscription,
 		"audiotranslation":          mode.AudioTranslation,
 		"translation":               mode.AudioTranslation,
+		"audiogeneration":           mode.AudioGenerations,
+		"audiogenerations":          mode.AudioGenerations,
+		"audiogenerationsget":       mode.AudioGenerationsGet,
+		"music":                     mode.AudioGenerations,
 		"rerank":                    mode.Rerank,
 		"parsepdf":                  mode.ParsePdf,
 		"pdf":                       mode.ParsePdf,
@@ -185,6 +195,12 @@ func ModelTypeNameToType(typeName string) mode.Mode {
 		"geminifiles":               mode.GeminiFiles,
 		"gemini_files":              mode.GeminiFiles,
 		"gemini-files":              mode.GeminiFiles,
+		"geminicachedcontents":      mode.GeminiCachedContents,
+		"gemini_cached_contents":    mode.GeminiCachedContents,
+		"gemini-cached-contents":    mode.GeminiCachedContents,
+		"geminilive":                mode.GeminiLive,
+		"gemini_live":               mode.GeminiLive,
+		"gemini-live":               mode.GeminiLive,
 		"geminitts":                 mode.GeminiTTS,
 		"gemini_tts":                mode.GeminiTTS,
 		"gemini-tts":                mode.GeminiTTS,
@@ -196,6 +212,10 @@ func ModelTypeNameToType(typeName string) mode.Mode {
 		"responsesdelete":           mode.ResponsesDelete,
 		"responsescancel":           mode.ResponsesCancel,
 		"responsesinputitems":       mode.ResponsesInputItems,
+		"files":                     mode.Files,
+		"filesget":                  mode.FilesGet,
+		"filescontent":              mode.FilesContent,
+		"filesdelete":               mode.FilesDelete,
 	}
 
 	if typ, ok := typeMap[typeName]; ok {
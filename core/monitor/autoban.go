@@ -0,0 +1,287 @@
+package monitor
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/config"
+)
+
+// AutoBanEvent is a relay error evaluated by the auto ban rules, a zero status
+// code means the upstream did not respond
+type AutoBanEvent struct {
+	Time       time.Time `json:"time"`
+	Model      string    `json:"model"`
+	ChannelID  int64     `json:"channel_id"`
+	StatusCode int       `json:"status_code"`
+	Message    string    `json:"message"`
+}
+
+// AutoBanTrigger is a rule whose threshold is reached by the event
+type AutoBanTrigger struct {
+	Rule  config.AutoBanRule
+	Count int64
+}
+
+func (t AutoBanTrigger) Cooldown() time.Duration {
+	return time.Duration(t.Rule.GetCooldownSeconds()) * time.Second
+}
+
+// MatchAutoBanRule reports whether the event matches all conditions of the rule
+func MatchAutoBanRule(rule config.AutoBanRule, event AutoBanEvent) bool {
+	if rule.Disabled {
+		return false
+	}
+
+	if len(rule.Models) > 0 && !slices.Contains(rule.Models, event.Model) {
+		return false
+	}
+
+	if len(rule.Channels) > 0 && !slices.Contains(rule.Channels, event.ChannelID) {
+		return false
+	}
+
+	if len(rule.StatusCodes) > 0 && !slices.Contains(rule.StatusCodes, event.StatusCode) {
+		return false
+	}
+
+	if len(rule.ErrorContains) > 0 {
+		message := strings.ToLower(event.Message)
+		if !slices.ContainsFunc(rule.ErrorContains, func(s string) bool {
+			return strings.Contains(message, strings.ToLower(s))
+		}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// autoBanCounterKey counts the matches of the ban target, all models of the
+// channel share the counter of the ban channel action
+func autoBanCounterKey(rule config.AutoBanRule, event AutoBanEvent, window int64) string {
+	model := event.Model
+	if rule.GetAction() == config.AutoBanActionBanChannel {
+		model = "*"
+	}
+
+	return rule.Name + ":" + strconv.FormatInt(event.ChannelID, 10) + ":" + model + ":" +
+		strconv.FormatInt(window, 10)
+}
+
+func autoBanWindow(rule config.AutoBanRule, t time.Time) (int64, time.Duration) {
+	windowSeconds := rule.GetWindowSeconds()
+	window := t.Unix() / windowSeconds
+
+	return window, time.Unix((window+1)*windowSeconds, 0).Sub(t)
+}
+
+// EvaluateAutoBanRules counts the event for the matching rules and returns the
+// rules reaching their threshold within the window
+func EvaluateAutoBanRules(
+	ctx context.Context,
+	rules []config.AutoBanRule,
+	event AutoBanEvent,
+) ([]AutoBanTrigger, error) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	var triggers []AutoBanTrigger
+
+	for _, rule := range rules {
+		if !MatchAutoBanRule(rule, event) {
+			continue
+		}
+
+		window, ttl := autoBanWindow(rule, event.Time)
+
+		count, err := incrAutoBanCounter(ctx, autoBanCounterKey(rule, event, window), ttl)
+		if err != nil {
+			return triggers, err
+		}
+
+		if count >= rule.GetThreshold() {
+			triggers = append(triggers, AutoBanTrigger{Rule: rule, Count: count})
+		}
+	}
+
+	return triggers, nil
+}
+
+func incrAutoBanCounter(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	if !common.RedisAvailable() {
+		return memAutoBanCounters.incr(key, ttl), nil
+	}
+
+	redisKey := common.RedisKey("autoban", key)
+
+	pipe := common.RDB.TxPipeline()
+	incr := pipe.Incr(ctx, redisKey)
+	pipe.PExpire(ctx, redisKey, ttl)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return incr.Val(), nil
+}
+
+type autoBanCounter struct {
+	count     int64
+	expiresAt time.Time
+}
+
+type autoBanCounters struct {
+	mu       sync.Mutex
+	counters map[string]*autoBanCounter
+}
+
+var memAutoBanCounters = &autoBanCounters{
+	counters: make(map[string]*autoBanCounter),
+}
+
+func (c *autoBanCounters) incr(key string, ttl time.Duration) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	counter, ok := c.counters[key]
+	if !ok || !counter.expiresAt.After(now) {
+		counter = &autoBanCounter{}
+		c.counters[key] = counter
+	}
+
+	counter.count++
+	counter.expiresAt = now.Add(ttl)
+
+	return counter.count
+}
+
+func (c *autoBanCounters) cleanup(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, counter := range c.counters {
+		if !counter.expiresAt.After(now) {
+			delete(c.counters, key)
+		}
+	}
+}
+
+// BanChannelModel bans the model on the channel for the duration, returns
+// false when the model is already banned
+func BanChannelModel(
+	ctx context.Context,
+	model string,
+	channelID int64,
+	duration time.Duration,
+) (bool, error) {
+	if !common.RedisAvailable() {
+		return memModelMonitor.BanChannelModel(model, channelID, duration), nil
+	}
+
+	bannedKey := modelKeyPrefix() + model + channelKeyPart +
+		strconv.FormatInt(channelID, 10) + bannedKeySuffix
+
+	banned, err := common.RDB.SetNX(ctx, bannedKey, 1, duration).Result()
+	if err != nil {
+		return false, err
+	}
+
+	deleteBannedChannelsLocal(model)
+
+	return banned, nil
+}
+
+// AutoBanDryRunBan is a ban that the rules would have executed
+type AutoBanDryRunBan struct {
+	Rule        string    `json:"rule"`
+	Action      string    `json:"action"`
+	ChannelID   int64     `json:"channel_id"`
+	Model       string    `json:"model,omitempty"`
+	Matches     int64     `json:"matches"`
+	BannedAt    time.Time `json:"banned_at"`
+	BannedUntil time.Time `json:"banned_until"`
+}
+
+type AutoBanDryRunResult struct {
+	Events  int                `json:"events"`
+	Matches map[string]int64   `json:"matches"`
+	Bans    []AutoBanDryRunBan `json:"bans"`
+}
+
+// DryRunAutoBanRules replays the events in time order against the rules, the
+// events of a banned channel model are skipped since no request is sent to it
+// during the cooldown
+func DryRunAutoBanRules(rules []config.AutoBanRule, events []AutoBanEvent) AutoBanDryRunResult {
+	events = slices.Clone(events)
+	slices.SortStableFunc(events, func(a, b AutoBanEvent) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	result := AutoBanDryRunResult{
+		Events:  len(events),
+		Matches: make(map[string]int64, len(rules)),
+		Bans:    []AutoBanDryRunBan{},
+	}
+
+	counters := make(map[string]int64)
+	bannedUntil := make(map[string]time.Time)
+
+	isBanned := func(event AutoBanEvent) bool {
+		channelKey := strconv.FormatInt(event.ChannelID, 10)
+		return bannedUntil[channelKey+":*"].After(event.Time) ||
+			bannedUntil[channelKey+":"+event.Model].After(event.Time)
+	}
+
+	for _, event := range events {
+		if isBanned(event) {
+			continue
+		}
+
+		for _, rule := range rules {
+			if !MatchAutoBanRule(rule, event) {
+				continue
+			}
+
+			result.Matches[rule.Name]++
+
+			window, _ := autoBanWindow(rule, event.Time)
+			key := autoBanCounterKey(rule, event, window)
+
+			counters[key]++
+			if counters[key] < rule.GetThreshold() {
+				continue
+			}
+
+			ban := AutoBanDryRunBan{
+				Rule:      rule.Name,
+				Action:    rule.GetAction(),
+				ChannelID: event.ChannelID,
+				Matches:   counters[key],
+				BannedAt:  event.Time,
+				BannedUntil: event.Time.Add(
+					time.Duration(rule.GetCooldownSeconds()) * time.Second,
+				),
+			}
+
+			target := strconv.FormatInt(event.ChannelID, 10) + ":*"
+			if ban.Action == config.AutoBanActionBanModel {
+				ban.Model = event.Model
+				target = strconv.FormatInt(event.ChannelID, 10) + ":" + event.Model
+			}
+
+			bannedUntil[target] = ban.BannedUntil
+			result.Bans = append(result.Bans, ban)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,147 @@
+//nolint:testpackage
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchAutoBanRule(t *testing.T) {
+	rule := config.AutoBanRule{
+		Name:          "quota",
+		StatusCodes:   []int{403, 429},
+		ErrorContains: []string{"Insufficient Quota"},
+		Models:        []string{"gpt-4o"},
+	}
+
+	event := AutoBanEvent{
+		Model:      "gpt-4o",
+		ChannelID:  1,
+		StatusCode: 429,
+		Message:    `{"error":{"message":"insufficient quota for this key"}}`,
+	}
+	require.True(t, MatchAutoBanRule(rule, event))
+
+	otherStatus := event
+	otherStatus.StatusCode = 500
+	require.False(t, MatchAutoBanRule(rule, otherStatus))
+
+	otherMessage := event
+	otherMessage.Message = "rate limited"
+	require.False(t, MatchAutoBanRule(rule, otherMessage))
+
+	otherModel := event
+	otherModel.Model = "gpt-4o-mini"
+	require.False(t, MatchAutoBanRule(rule, otherModel))
+
+	rule.Disabled = true
+	require.False(t, MatchAutoBanRule(rule, event))
+}
+
+func TestEvaluateAutoBanRulesThreshold(t *testing.T) {
+	rule := config.AutoBanRule{
+		Name:          "evaluate-threshold",
+		StatusCodes:   []int{500},
+		Threshold:     3,
+		WindowSeconds: 3600,
+	}
+	event := AutoBanEvent{
+		Time:       time.Now(),
+		Model:      "evaluate-threshold-model",
+		ChannelID:  1,
+		StatusCode: 500,
+	}
+
+	for range 2 {
+		triggers, err := EvaluateAutoBanRules(
+			context.Background(),
+			[]config.AutoBanRule{rule},
+			event,
+		)
+		require.NoError(t, err)
+		require.Empty(t, triggers)
+	}
+
+	triggers, err := EvaluateAutoBanRules(context.Background(), []config.AutoBanRule{rule}, event)
+	require.NoError(t, err)
+	require.Len(t, triggers, 1)
+	require.Equal(t, int64(3), triggers[0].Count)
+	require.Equal(t, 300*time.Second, triggers[0].Cooldown())
+}
+
+func TestMemBanChannelModel(t *testing.T) {
+	m := &MemModelMonitor{models: make(map[string]*ModelData)}
+
+	require.True(t, m.BanChannelModel("model", 1, time.Minute))
+	require.False(t, m.BanChannelModel("model", 1, time.Minute))
+
+	banned, err := m.GetBannedChannelsWithModel(context.Background(), "model")
+	require.NoError(t, err)
+	require.Equal(t, []int64{1}, banned)
+}
+
+func TestDryRunAutoBanRules(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0).Truncate(time.Hour)
+	rules := []config.AutoBanRule{
+		{
+			Name:          "server-error",
+			StatusCodes:   []int{500},
+			Threshold:     2,
+			WindowSeconds: 60,
+		},
+		{
+			Name:            "invalid-key",
+			ErrorContains:   []string{"invalid api key"},
+			Action:          config.AutoBanActionBanChannel,
+			CooldownSeconds: 600,
+		},
+	}
+
+	events := []AutoBanEvent{
+		{Time: base.Add(40 * time.Second), Model: "a", ChannelID: 1, StatusCode: 500},
+		// counted in the next window
+		{Time: base.Add(70 * time.Second), Model: "a", ChannelID: 1, StatusCode: 500},
+		{Time: base.Add(80 * time.Second), Model: "a", ChannelID: 1, StatusCode: 500},
+		// skipped while the model is banned
+		{Time: base.Add(90 * time.Second), Model: "a", ChannelID: 1, StatusCode: 500},
+		{Time: base.Add(10 * time.Second), Model: "b", ChannelID: 2, Message: "Invalid API key"},
+		{Time: base.Add(20 * time.Second), Model: "c", ChannelID: 2, Message: "Invalid API key"},
+	}
+
+	result := DryRunAutoBanRules(rules, events)
+	require.Equal(t, 6, result.Events)
+	require.Equal(t, int64(3), result.Matches["server-error"])
+	require.Equal(t, int64(1), result.Matches["invalid-key"])
+	require.Len(t, result.Bans, 2)
+
+	require.Equal(t, "invalid-key", result.Bans[0].Rule)
+	require.Equal(t, config.AutoBanActionBanChannel, result.Bans[0].Action)
+	require.Equal(t, int64(2), result.Bans[0].ChannelID)
+	require.Empty(t, result.Bans[0].Model)
+	require.Equal(t, base.Add(10*time.Second+10*time.Minute), result.Bans[0].BannedUntil)
+
+	require.Equal(t, "server-error", result.Bans[1].Rule)
+	require.Equal(t, "a", result.Bans[1].Model)
+	require.Equal(t, int64(2), result.Bans[1].Matches)
+	require.Equal(t, base.Add(80*time.Second), result.Bans[1].BannedAt)
+}
+
+func TestValidateAutoBanRules(t *testing.T) {
+	require.NoError(t, config.ValidateAutoBanRules([]config.AutoBanRule{
+		{Name: "a", StatusCodes: []int{401}},
+	}))
+	require.Error(t, config.ValidateAutoBanRules([]config.AutoBanRule{
+		{Name: "a", StatusCodes: []int{401}},
+		{Name: "a", StatusCodes: []int{403}},
+	}))
+	require.Error(t, config.ValidateAutoBanRules([]config.AutoBanRule{
+		{Name: "a"},
+	}))
+	require.Error(t, config.ValidateAutoBanRules([]config.AutoBanRule{
+		{Name: "a", StatusCodes: []int{401}, Action: "disable"},
+	}))
+}
@@ -0,0 +1,224 @@
+package monitor
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBanditExplorationRate is the exploration rate of the models
+	// enabling the bandit without one
+	DefaultBanditExplorationRate = 0.1
+	// banditFeedbackDecay fades the older ratings, so the recent ratings of a
+	// channel outweigh the ones before an upstream change
+	banditFeedbackDecay = 0.98
+)
+
+// BanditConfig shifts the traffic of a model to the channel with the best
+// reward, the success rate scaled by the feedback score minus the weighted
+// relative cost of the channel
+type BanditConfig struct {
+	// ExplorationRate is the chance of picking a random channel instead of the
+	// best one, so the channels out of favor still collect the signals
+	ExplorationRate float64
+	// CostWeight scales the relative cost subtracted from the quality
+	CostWeight float64
+	// ChannelCosts is the cost of the channels, relative to the most costly
+	// one, the channels without a cost cost nothing
+	ChannelCosts map[int64]float64
+}
+
+type banditArm struct {
+	ratingSum   float64
+	ratingCount float64
+	ratings     int
+	updatedAt   time.Time
+}
+
+// feedbackScore is the decayed mean of the ratings with a neutral prior, the
+// channels without ratings score 0.5
+func (a *banditArm) feedbackScore() float64 {
+	if a == nil {
+		return 0.5
+	}
+
+	return (a.ratingSum + 1) / (a.ratingCount + 2)
+}
+
+// BanditArmSnapshot is the feedback of a channel-model pair
+type BanditArmSnapshot struct {
+	Model         string    `json:"model"`
+	ChannelID     int64     `json:"channel_id"`
+	Ratings       int       `json:"ratings"`
+	FeedbackScore float64   `json:"feedback_score"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// BanditRegistry keeps the feedback ratings of the channel-model pairs of this
+// instance
+type BanditRegistry struct {
+	mu     sync.Mutex
+	arms   map[breakerKey]*banditArm
+	now    func() time.Time
+	random func() float64
+}
+
+func NewBanditRegistry() *BanditRegistry {
+	return &BanditRegistry{
+		arms:   make(map[breakerKey]*banditArm),
+		now:    time.Now,
+		random: rand.Float64,
+	}
+}
+
+// RecordFeedback adds a rating of a response of the channel, from 0 for a bad
+// response to 1 for a good one
+func (r *BanditRegistry) RecordFeedback(model string, channelID int64, rating float64) {
+	rating = min(max(rating, 0), 1)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := breakerKey{model: model, channelID: channelID}
+
+	arm, ok := r.arms[key]
+	if !ok {
+		arm = &banditArm{}
+		r.arms[key] = arm
+	}
+
+	arm.ratingSum = arm.ratingSum*banditFeedbackDecay + rating
+	arm.ratingCount = arm.ratingCount*banditFeedbackDecay + 1
+	arm.ratings++
+	arm.updatedAt = r.now()
+}
+
+func banditReward(channelID int64, errorRate, feedbackScore float64, cfg BanditConfig) float64 {
+	errorRate = min(max(errorRate, 0), 1)
+	reward := (1 - errorRate) * feedbackScore
+
+	if cfg.CostWeight <= 0 || len(cfg.ChannelCosts) == 0 {
+		return reward
+	}
+
+	var maxCost float64
+	for _, cost := range cfg.ChannelCosts {
+		maxCost = max(maxCost, cost)
+	}
+
+	if maxCost <= 0 {
+		return reward
+	}
+
+	return reward - cfg.CostWeight*max(cfg.ChannelCosts[channelID], 0)/maxCost
+}
+
+// Pick returns the index of the channel to serve the model, a random channel
+// at the exploration rate, otherwise the one with the best reward, -1 is
+// returned without channels
+func (r *BanditRegistry) Pick(
+	model string,
+	channelIDs []int64,
+	errorRates map[int64]float64,
+	cfg BanditConfig,
+) int {
+	if len(channelIDs) == 0 {
+		return -1
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(channelIDs) == 1 {
+		return 0
+	}
+
+	if r.random() < cfg.ExplorationRate {
+		return int(r.random() * float64(len(channelIDs)))
+	}
+
+	best := make([]int, 0, 1)
+
+	var bestReward float64
+
+	for i, channelID := range channelIDs {
+		score := r.arms[breakerKey{model: model, channelID: channelID}].feedbackScore()
+		reward := banditReward(channelID, errorRates[channelID], score, cfg)
+
+		switch {
+		case len(best) == 0 || reward > bestReward:
+			best = append(best[:0], i)
+			bestReward = reward
+		case reward == bestReward:
+			best = append(best, i)
+		}
+	}
+
+	// the ties are broken at random, so the channels without signals share
+	// the traffic
+	return best[int(r.random()*float64(len(best)))]
+}
+
+func (r *BanditRegistry) Snapshot() []BanditArmSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]BanditArmSnapshot, 0, len(r.arms))
+	for key, arm := range r.arms {
+		result = append(result, BanditArmSnapshot{
+			Model:         key.model,
+			ChannelID:     key.channelID,
+			Ratings:       arm.ratings,
+			FeedbackScore: arm.feedbackScore(),
+			UpdatedAt:     arm.updatedAt,
+		})
+	}
+
+	slices.SortFunc(result, func(a, b BanditArmSnapshot) int {
+		if c := strings.Compare(a.Model, b.Model); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.ChannelID, b.ChannelID)
+	})
+
+	return result
+}
+
+func (r *BanditRegistry) ResetChannel(channelID int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key := range r.arms {
+		if key.channelID == channelID {
+			delete(r.arms, key)
+		}
+	}
+}
+
+var banditRegistry = NewBanditRegistry()
+
+func RecordBanditFeedback(model string, channelID int64, rating float64) {
+	banditRegistry.RecordFeedback(model, channelID, rating)
+}
+
+func PickBanditChannel(
+	model string,
+	channelIDs []int64,
+	errorRates map[int64]float64,
+	cfg BanditConfig,
+) int {
+	return banditRegistry.Pick(model, channelIDs, errorRates, cfg)
+}
+
+func GetBanditSnapshots() []BanditArmSnapshot {
+	return banditRegistry.Snapshot()
+}
+
+func ResetChannelBandit(channelID int64) {
+	banditRegistry.ResetChannel(channelID)
+}
@@ -0,0 +1,86 @@
+//nolint:testpackage
+package monitor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBanditPicksBestReward(t *testing.T) {
+	r := NewBanditRegistry()
+	r.random = func() float64 { return 0.99 }
+
+	cfg := BanditConfig{ExplorationRate: 0.1}
+	channels := []int64{1, 2, 3}
+
+	// the lowest error rate wins without feedback
+	require.Equal(t, 1, r.Pick("gpt-4o", channels, map[int64]float64{1: 0.3, 3: 0.2}, cfg))
+
+	// the good ratings outweigh a slightly higher error rate
+	for range 20 {
+		r.RecordFeedback("gpt-4o", 3, 1)
+		r.RecordFeedback("gpt-4o", 2, 0)
+	}
+
+	require.Equal(t, 2, r.Pick("gpt-4o", channels, map[int64]float64{1: 0.3, 3: 0.1}, cfg))
+
+	// the feedback of another model is not used
+	require.Equal(t, 1, r.Pick("gpt-4o-mini", channels, map[int64]float64{1: 0.3, 3: 0.2}, cfg))
+}
+
+func TestBanditExplores(t *testing.T) {
+	r := NewBanditRegistry()
+
+	values := []float64{0.05, 0.7}
+	r.random = func() float64 {
+		v := values[0]
+		values = values[1:]
+
+		return v
+	}
+
+	idx := r.Pick("gpt-4o", []int64{1, 2, 3}, map[int64]float64{1: 0.5, 3: 0.5}, BanditConfig{
+		ExplorationRate: 0.1,
+	})
+	require.Equal(t, 2, idx)
+	require.Empty(t, values)
+}
+
+func TestBanditCost(t *testing.T) {
+	r := NewBanditRegistry()
+	r.random = func() float64 { return 0.99 }
+
+	cfg := BanditConfig{
+		CostWeight:   0.5,
+		ChannelCosts: map[int64]float64{1: 2, 2: 1},
+	}
+
+	require.Equal(t, 1, r.Pick("gpt-4o", []int64{1, 2}, nil, cfg))
+
+	// the quality gap outweighs the cost gap
+	for range 50 {
+		r.RecordFeedback("gpt-4o", 1, 1)
+		r.RecordFeedback("gpt-4o", 2, 0)
+	}
+
+	require.Equal(t, 0, r.Pick("gpt-4o", []int64{1, 2}, nil, cfg))
+}
+
+func TestBanditSnapshotAndReset(t *testing.T) {
+	r := NewBanditRegistry()
+
+	r.RecordFeedback("gpt-4o", 2, 1)
+	r.RecordFeedback("gpt-4o", 1, 2)
+	r.RecordFeedback("claude", 1, -1)
+
+	snapshots := r.Snapshot()
+	require.Len(t, snapshots, 3)
+	require.Equal(t, "claude", snapshots[0].Model)
+	require.InDelta(t, 1.0/3, snapshots[0].FeedbackScore, 1e-9)
+	require.Equal(t, int64(1), snapshots[1].ChannelID)
+	require.InDelta(t, 2.0/3, snapshots[1].FeedbackScore, 1e-9)
+
+	r.ResetChannel(1)
+	require.Len(t, r.Snapshot(), 1)
+}
@@ -0,0 +1,457 @@
+package monitor
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type BreakerState string
+
+const (
+	BreakerStateClosed   BreakerState = "closed"
+	BreakerStateOpen     BreakerState = "open"
+	BreakerStateHalfOpen BreakerState = "half_open"
+)
+
+const (
+	BreakerReasonErrorRate = "error_rate"
+	BreakerReasonLatency   = "latency"
+	BreakerReasonProbe     = "probe_failed"
+)
+
+const (
+	defaultBreakerSlowRate     = 0.5
+	defaultBreakerOpenDuration = 30 * time.Second
+	// the open duration doubles every time a half-open probe fails
+	maxBreakerOpenBackoff = 8
+)
+
+// BreakerConfig is the error budget of a channel-model pair,
+// the breaker is disabled when both ErrorRate and LatencySLO are zero
+type BreakerConfig struct {
+	// ErrorRate opens the breaker when the error rate within the window reaches it
+	ErrorRate float64
+	// LatencySLO marks requests slower than it as slow, the breaker opens when
+	// the slow request rate within the window reaches SlowRate
+	LatencySLO   time.Duration
+	SlowRate     float64
+	OpenDuration time.Duration
+}
+
+func (c BreakerConfig) Enabled() bool {
+	return c.ErrorRate > 0 || c.LatencySLO > 0
+}
+
+func (c BreakerConfig) slowRate() float64 {
+	if c.SlowRate <= 0 {
+		return defaultBreakerSlowRate
+	}
+
+	return c.SlowRate
+}
+
+func (c BreakerConfig) openDuration() time.Duration {
+	if c.OpenDuration <= 0 {
+		return defaultBreakerOpenDuration
+	}
+
+	return c.OpenDuration
+}
+
+type BreakerTransition struct {
+	Model     string
+	ChannelID int64
+	From      BreakerState
+	To        BreakerState
+	Reason    string
+	ErrorRate float64
+	SlowRate  float64
+	OpenUntil time.Time
+}
+
+type BreakerSnapshot struct {
+	Model       string       `json:"model"`
+	ChannelID   int64        `json:"channel_id"`
+	State       BreakerState `json:"state"`
+	Reason      string       `json:"reason,omitempty"`
+	Requests    int          `json:"requests"`
+	ErrorRate   float64      `json:"error_rate"`
+	SlowRate    float64      `json:"slow_rate"`
+	OpenUntil   time.Time    `json:"open_until,omitzero"`
+	ChangedAt   time.Time    `json:"changed_at,omitzero"`
+	Transitions int64        `json:"transitions"`
+}
+
+type breakerKey struct {
+	model     string
+	channelID int64
+}
+
+type breaker struct {
+	state       BreakerState
+	reason      string
+	requests    *TimeWindowStats
+	slow        *TimeWindowStats
+	openUntil   time.Time
+	probeUntil  time.Time
+	openBackoff int
+	changedAt   time.Time
+	transitions int64
+}
+
+func newBreaker() *breaker {
+	return &breaker{
+		state:       BreakerStateClosed,
+		requests:    NewTimeWindowStats(),
+		slow:        NewTimeWindowStats(),
+		openBackoff: 1,
+	}
+}
+
+func (b *breaker) rates() (requests int, errorRate, slowRate float64) {
+	requests, errs := b.requests.GetStats()
+	if requests < minRequestCount {
+		return requests, 0, 0
+	}
+
+	_, slow := b.slow.GetStats()
+
+	return requests, float64(errs) / float64(requests), float64(slow) / float64(requests)
+}
+
+func (b *breaker) transition(
+	key breakerKey,
+	now time.Time,
+	to BreakerState,
+	reason string,
+) *BreakerTransition {
+	_, errorRate, slowRate := b.rates()
+
+	t := &BreakerTransition{
+		Model:     key.model,
+		ChannelID: key.channelID,
+		From:      b.state,
+		To:        to,
+		Reason:    reason,
+		ErrorRate: errorRate,
+		SlowRate:  slowRate,
+	}
+
+	b.state = to
+	b.reason = reason
+	b.changedAt = now
+	b.transitions++
+
+	switch to {
+	case BreakerStateClosed:
+		b.requests = NewTimeWindowStats()
+		b.slow = NewTimeWindowStats()
+		b.openUntil = time.Time{}
+		b.probeUntil = time.Time{}
+		b.openBackoff = 1
+		b.reason = ""
+	case BreakerStateHalfOpen:
+		b.probeUntil = time.Time{}
+	case BreakerStateOpen:
+		t.OpenUntil = b.openUntil
+	}
+
+	return t
+}
+
+// BreakerRegistry keeps the circuit breakers of this instance,
+// breakers are local because probes must be coordinated per instance
+type BreakerRegistry struct {
+	mu       sync.Mutex
+	breakers map[breakerKey]*breaker
+	now      func() time.Time
+}
+
+func NewBreakerRegistry() *BreakerRegistry {
+	return &BreakerRegistry{
+		breakers: make(map[breakerKey]*breaker),
+		now:      time.Now,
+	}
+}
+
+// OpenChannels returns the channels that must not be selected for the model,
+// an expired open breaker is left selectable so it can be probed, Acquire lets
+// only one of the requests selecting it through
+func (r *BreakerRegistry) OpenChannels(model string) map[int64]struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+
+	var result map[int64]struct{}
+	for key, b := range r.breakers {
+		if key.model != model {
+			continue
+		}
+
+		blocked := false
+
+		switch b.state {
+		case BreakerStateOpen:
+			blocked = now.Before(b.openUntil)
+		case BreakerStateHalfOpen:
+			blocked = now.Before(b.probeUntil)
+		}
+
+		if !blocked {
+			continue
+		}
+
+		if result == nil {
+			result = make(map[int64]struct{})
+		}
+
+		result[key.channelID] = struct{}{}
+	}
+
+	return result
+}
+
+// Acquire is called before sending a request, an expired open breaker turns
+// half-open and the request becomes its probe. The probe is claimed under the
+// lock, so of the requests selecting the channel at once only one is allowed,
+// ok is false when the request must not be sent to the channel
+func (r *BreakerRegistry) Acquire(
+	model string,
+	channelID int64,
+	cfg BreakerConfig,
+) (t *BreakerTransition, probe, ok bool) {
+	if !cfg.Enabled() {
+		return nil, false, true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := breakerKey{model: model, channelID: channelID}
+
+	b, exists := r.breakers[key]
+	if !exists {
+		return nil, false, true
+	}
+
+	now := r.now()
+
+	switch b.state {
+	case BreakerStateOpen:
+		if now.Before(b.openUntil) {
+			return nil, false, false
+		}
+
+		t = b.transition(key, now, BreakerStateHalfOpen, b.reason)
+	case BreakerStateHalfOpen:
+		// the probe in flight decides the state, a probe without a result
+		// within the open duration is replaced
+		if now.Before(b.probeUntil) {
+			return nil, false, false
+		}
+	default:
+		return nil, false, true
+	}
+
+	b.probeUntil = now.Add(cfg.openDuration())
+
+	return t, true, true
+}
+
+// Record adds a request result and returns the state transition if any, the
+// state of a half-open breaker is only changed by the result of its probe
+func (r *BreakerRegistry) Record(
+	model string,
+	channelID int64,
+	isError bool,
+	latency time.Duration,
+	cfg BreakerConfig,
+	probe bool,
+) *BreakerTransition {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := breakerKey{model: model, channelID: channelID}
+
+	if !cfg.Enabled() {
+		delete(r.breakers, key)
+		return nil
+	}
+
+	b, ok := r.breakers[key]
+	if !ok {
+		b = newBreaker()
+		r.breakers[key] = b
+	}
+
+	now := r.now()
+	isSlow := cfg.LatencySLO > 0 && latency > cfg.LatencySLO
+
+	switch b.state {
+	case BreakerStateOpen:
+		// requests that were in flight when the breaker opened
+		return nil
+	case BreakerStateHalfOpen:
+		// requests that were in flight when the breaker opened
+		if !probe {
+			return nil
+		}
+
+		if !isError && !isSlow {
+			return b.transition(key, now, BreakerStateClosed, "")
+		}
+
+		b.openBackoff = min(b.openBackoff*2, maxBreakerOpenBackoff)
+		b.openUntil = now.Add(cfg.openDuration() * time.Duration(b.openBackoff))
+
+		return b.transition(key, now, BreakerStateOpen, BreakerReasonProbe)
+	}
+
+	b.requests.AddRequest(now, isError)
+	b.slow.AddRequest(now, isSlow)
+
+	_, errorRate, slowRate := b.rates()
+
+	reason := ""
+
+	switch {
+	case cfg.ErrorRate > 0 && errorRate >= cfg.ErrorRate:
+		reason = BreakerReasonErrorRate
+	case cfg.LatencySLO > 0 && slowRate >= cfg.slowRate():
+		reason = BreakerReasonLatency
+	default:
+		return nil
+	}
+
+	b.openUntil = now.Add(cfg.openDuration())
+
+	return b.transition(key, now, BreakerStateOpen, reason)
+}
+
+// Release ends a request without a result, e.g. a hedged request cancelled
+// because another one won, nothing is recorded and a probe frees the half-open
+// breaker for the next request without changing its state
+func (r *BreakerRegistry) Release(model string, channelID int64, probe bool) {
+	if !probe {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.breakers[breakerKey{model: model, channelID: channelID}]
+	if !ok || b.state != BreakerStateHalfOpen {
+		return
+	}
+
+	b.probeUntil = time.Time{}
+}
+
+func (r *BreakerRegistry) Snapshot() []BreakerSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+
+	result := make([]BreakerSnapshot, 0, len(r.breakers))
+	for key, b := range r.breakers {
+		requests, errorRate, slowRate := b.rates()
+
+		// drop idle closed breakers
+		if b.state == BreakerStateClosed && requests == 0 && b.transitions == 0 {
+			delete(r.breakers, key)
+			continue
+		}
+
+		state := b.state
+		if state == BreakerStateOpen && !now.Before(b.openUntil) {
+			state = BreakerStateHalfOpen
+		}
+
+		result = append(result, BreakerSnapshot{
+			Model:       key.model,
+			ChannelID:   key.channelID,
+			State:       state,
+			Reason:      b.reason,
+			Requests:    requests,
+			ErrorRate:   errorRate,
+			SlowRate:    slowRate,
+			OpenUntil:   b.openUntil,
+			ChangedAt:   b.changedAt,
+			Transitions: b.transitions,
+		})
+	}
+
+	slices.SortFunc(result, func(a, b BreakerSnapshot) int {
+		if c := strings.Compare(a.Model, b.Model); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.ChannelID, b.ChannelID)
+	})
+
+	return result
+}
+
+func (r *BreakerRegistry) ResetChannel(channelID int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key := range r.breakers {
+		if key.channelID == channelID {
+			delete(r.breakers, key)
+		}
+	}
+}
+
+func (r *BreakerRegistry) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	clear(r.breakers)
+}
+
+var breakerRegistry = NewBreakerRegistry()
+
+func GetBreakerOpenChannels(model string) map[int64]struct{} {
+	return breakerRegistry.OpenChannels(model)
+}
+
+func AcquireBreaker(
+	model string,
+	channelID int64,
+	cfg BreakerConfig,
+) (t *BreakerTransition, probe, ok bool) {
+	return breakerRegistry.Acquire(model, channelID, cfg)
+}
+
+func RecordBreaker(
+	model string,
+	channelID int64,
+	isError bool,
+	latency time.Duration,
+	cfg BreakerConfig,
+	probe bool,
+) *BreakerTransition {
+	return breakerRegistry.Record(model, channelID, isError, latency, cfg, probe)
+}
+
+func ReleaseBreaker(model string, channelID int64, probe bool) {
+	breakerRegistry.Release(model, channelID, probe)
+}
+
+func GetBreakerSnapshots() []BreakerSnapshot {
+	return breakerRegistry.Snapshot()
+}
+
+func ResetChannelBreakers(channelID int64) {
+	breakerRegistry.ResetChannel(channelID)
+}
+
+func ResetAllBreakers() {
+	breakerRegistry.Reset()
+}
@@ -0,0 +1,177 @@
+//nolint:testpackage
+package monitor
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakerOpensOnErrorRateAndRecoversThroughProbe(t *testing.T) {
+	r := NewBreakerRegistry()
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	cfg := BreakerConfig{ErrorRate: 0.5, OpenDuration: time.Minute}
+
+	var transition *BreakerTransition
+	for i := range minRequestCount {
+		transition = r.Record("gpt-4o", 1, i%2 == 0, 0, cfg, false)
+	}
+
+	require.NotNil(t, transition)
+	require.Equal(t, BreakerStateClosed, transition.From)
+	require.Equal(t, BreakerStateOpen, transition.To)
+	require.Equal(t, BreakerReasonErrorRate, transition.Reason)
+	require.Contains(t, r.OpenChannels("gpt-4o"), int64(1))
+	require.Empty(t, r.OpenChannels("gpt-4o-mini"))
+
+	now = now.Add(2 * time.Minute)
+	require.NotContains(t, r.OpenChannels("gpt-4o"), int64(1))
+
+	transition, probe, ok := r.Acquire("gpt-4o", 1, cfg)
+	require.True(t, ok)
+	require.True(t, probe)
+	require.NotNil(t, transition)
+	require.Equal(t, BreakerStateHalfOpen, transition.To)
+	// only one probe at a time
+	require.Contains(t, r.OpenChannels("gpt-4o"), int64(1))
+
+	_, _, ok = r.Acquire("gpt-4o", 1, cfg)
+	require.False(t, ok)
+
+	// a request sent before the breaker opened does not decide the probe
+	require.Nil(t, r.Record("gpt-4o", 1, true, 0, cfg, false))
+
+	transition = r.Record("gpt-4o", 1, false, 0, cfg, true)
+	require.NotNil(t, transition)
+	require.Equal(t, BreakerStateClosed, transition.To)
+	require.Empty(t, r.OpenChannels("gpt-4o"))
+}
+
+func TestBreakerFailedProbeBacksOff(t *testing.T) {
+	r := NewBreakerRegistry()
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	cfg := BreakerConfig{ErrorRate: 0.5, OpenDuration: time.Minute}
+	for range minRequestCount {
+		r.Record("gpt-4o", 1, true, 0, cfg, false)
+	}
+
+	now = now.Add(2 * time.Minute)
+	transition, probe, ok := r.Acquire("gpt-4o", 1, cfg)
+	require.NotNil(t, transition)
+	require.True(t, probe)
+	require.True(t, ok)
+
+	transition = r.Record("gpt-4o", 1, true, 0, cfg, true)
+	require.NotNil(t, transition)
+	require.Equal(t, BreakerStateOpen, transition.To)
+	require.Equal(t, BreakerReasonProbe, transition.Reason)
+	require.Equal(t, now.Add(2*time.Minute), transition.OpenUntil)
+}
+
+func TestBreakerOpensOnLatencySLO(t *testing.T) {
+	r := NewBreakerRegistry()
+	cfg := BreakerConfig{LatencySLO: time.Second, SlowRate: 0.8}
+
+	var transition *BreakerTransition
+	for range minRequestCount {
+		transition = r.Record("gpt-4o", 2, false, 2*time.Second, cfg, false)
+	}
+
+	require.NotNil(t, transition)
+	require.Equal(t, BreakerReasonLatency, transition.Reason)
+
+	r.ResetChannel(2)
+	require.Empty(t, r.OpenChannels("gpt-4o"))
+}
+
+func TestBreakerDisabledDoesNotTrack(t *testing.T) {
+	r := NewBreakerRegistry()
+
+	for range minRequestCount {
+		require.Nil(t, r.Record("gpt-4o", 1, true, 0, BreakerConfig{}, false))
+	}
+
+	require.Empty(t, r.OpenChannels("gpt-4o"))
+	require.Empty(t, r.Snapshot())
+}
+
+func TestBreakerLetsOneConcurrentProbeThrough(t *testing.T) {
+	r := NewBreakerRegistry()
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	cfg := BreakerConfig{ErrorRate: 0.5, OpenDuration: time.Minute}
+	for range minRequestCount {
+		r.Record("gpt-4o", 1, true, 0, cfg, false)
+	}
+
+	now = now.Add(2 * time.Minute)
+	require.NotContains(t, r.OpenChannels("gpt-4o"), int64(1))
+
+	var (
+		wg      sync.WaitGroup
+		allowed atomic.Int64
+		probes  atomic.Int64
+	)
+
+	for range 64 {
+		wg.Go(func() {
+			_, probe, ok := r.Acquire("gpt-4o", 1, cfg)
+			if ok {
+				allowed.Add(1)
+			}
+
+			if probe {
+				probes.Add(1)
+			}
+		})
+	}
+
+	wg.Wait()
+
+	require.Equal(t, int64(1), allowed.Load())
+	require.Equal(t, int64(1), probes.Load())
+	require.Contains(t, r.OpenChannels("gpt-4o"), int64(1))
+}
+
+func TestBreakerReleasedProbeDoesNotClose(t *testing.T) {
+	r := NewBreakerRegistry()
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	cfg := BreakerConfig{ErrorRate: 0.5, OpenDuration: time.Minute}
+
+	for range minRequestCount {
+		r.Record("gpt-4o", 1, true, 0, cfg, false)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	_, probe, ok := r.Acquire("gpt-4o", 1, cfg)
+	require.True(t, ok)
+	require.True(t, probe)
+
+	// a hedge loser holding the probe frees it without a transition
+	r.Release("gpt-4o", 1, true)
+
+	snapshots := r.Snapshot()
+	require.Len(t, snapshots, 1)
+	require.Equal(t, BreakerStateHalfOpen, snapshots[0].State)
+	require.Empty(t, r.OpenChannels("gpt-4o"))
+
+	transition, probe, ok := r.Acquire("gpt-4o", 1, cfg)
+	require.True(t, ok)
+	require.True(t, probe)
+	require.Nil(t, transition)
+
+	transition = r.Record("gpt-4o", 1, true, 0, cfg, true)
+	require.NotNil(t, transition)
+	require.Equal(t, BreakerStateOpen, transition.To)
+}
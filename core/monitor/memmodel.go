@@ -97,6 +97,8 @@ func (m *MemModelMonitor) cleanupExpiredData() {
 
 	now := time.Now()
 
+	memAutoBanCounters.cleanup(now)
+
 	for modelName, modelData := range m.models {
 		for channelID, channelStats := range modelData.channels {
 			hasValidSlices := channelStats.timeWindows.HasValidSlices()
@@ -150,6 +152,49 @@ func (m *MemModelMonitor) AddRequest(
 	return m.checkAndBan(now, channel, tryBan, maxErrorRate)
 }
 
+func (m *MemModelMonitor) getOrCreateChannel(model string, channelID int64) *ChannelStats {
+	modelData, exists := m.models[model]
+	if !exists {
+		modelData = &ModelData{
+			channels:   make(map[int64]*ChannelStats),
+			totalStats: NewTimeWindowStats(),
+		}
+		m.models[model] = modelData
+	}
+
+	channel, exists := modelData.channels[channelID]
+	if !exists {
+		channel = &ChannelStats{
+			timeWindows: NewTimeWindowStats(),
+		}
+		modelData.channels[channelID] = channel
+	}
+
+	return channel
+}
+
+// BanChannelModel bans the model on the channel for the duration, returns
+// false when the model is already banned
+func (m *MemModelMonitor) BanChannelModel(
+	model string,
+	channelID int64,
+	duration time.Duration,
+) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	channel := m.getOrCreateChannel(model, channelID)
+	if channel.bannedUntil.After(now) {
+		return false
+	}
+
+	channel.bannedUntil = now.Add(duration)
+
+	return true
+}
+
 func (m *MemModelMonitor) checkAndBan(
 	now time.Time,
 	channel *ChannelStats,
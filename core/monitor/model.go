@@ -49,7 +49,7 @@ func newRedisModelMonitor(getRDB func() *redis.Client) *redisModelMonitor {
 }
 
 func GetModelsErrorRate(ctx context.Context) (map[string]float64, error) {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return memModelMonitor.GetModelsErrorRate(ctx)
 	}
 
@@ -115,7 +115,7 @@ func AddRequest(
 	isError, tryBan bool,
 	maxErrorRate float64,
 ) (errorRate float64, banExecution bool, err error) {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		errorRate, banExecution = memModelMonitor.AddRequest(
 			model,
 			channelID,
@@ -255,7 +255,7 @@ func getModelChannelID(key string) (string, int64, bool) {
 
 // GetChannelModelErrorRates gets error rates for a specific channel
 func GetChannelModelErrorRates(ctx context.Context, channelID int64) (map[string]float64, error) {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return memModelMonitor.GetChannelModelErrorRates(ctx, channelID)
 	}
 
@@ -267,7 +267,7 @@ func GetChannelModelErrorRate(
 	model string,
 	channelID int64,
 ) (float64, error) {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return memModelMonitor.GetChannelModelErrorRate(ctx, model, channelID)
 	}
 
@@ -317,7 +317,7 @@ func (m *redisModelMonitor) GetChannelModelErrorRates(
 }
 
 func GetModelChannelErrorRate(ctx context.Context, model string) (map[int64]float64, error) {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return memModelMonitor.GetModelChannelErrorRate(ctx, model)
 	}
 
@@ -427,7 +427,7 @@ func (m *redisModelMonitor) GetChannelModelErrorRate(
 
 // GetBannedChannelsWithModel gets banned channels for a specific model
 func GetBannedChannelsWithModel(ctx context.Context, model string) ([]int64, error) {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return memModelMonitor.GetBannedChannelsWithModel(ctx, model)
 	}
 
@@ -469,7 +469,7 @@ func (m *redisModelMonitor) GetBannedChannelsWithModel(
 
 // GetBannedChannelsMapWithModel gets banned channels for a specific model as a map for efficient lookups
 func GetBannedChannelsMapWithModel(ctx context.Context, model string) (map[int64]struct{}, error) {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return memModelMonitor.GetBannedChannelsMapWithModel(ctx, model)
 	}
 
@@ -526,7 +526,7 @@ func (m *redisModelMonitor) GetBannedChannelsMapWithModel(
 
 // ClearChannelModelErrors clears errors for a specific channel and model
 func ClearChannelModelErrors(ctx context.Context, model string, channelID int) error {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return memModelMonitor.ClearChannelModelErrors(ctx, model, channelID)
 	}
 
@@ -560,7 +560,7 @@ func (m *redisModelMonitor) ClearChannelModelErrors(
 
 // ClearChannelAllModelErrors clears all errors for a specific channel
 func ClearChannelAllModelErrors(ctx context.Context, channelID int) error {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return memModelMonitor.ClearChannelAllModelErrors(ctx, channelID)
 	}
 
@@ -588,7 +588,7 @@ func (m *redisModelMonitor) ClearChannelAllModelErrors(ctx context.Context, chan
 
 // ClearAllModelErrors clears all error records
 func ClearAllModelErrors(ctx context.Context) error {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return memModelMonitor.ClearAllModelErrors(ctx)
 	}
 
@@ -611,7 +611,7 @@ func (m *redisModelMonitor) ClearAllModelErrors(ctx context.Context) error {
 
 // GetAllBannedModelChannels gets all banned channels for all models
 func GetAllBannedModelChannels(ctx context.Context) (map[string][]int64, error) {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return memModelMonitor.GetAllBannedModelChannels(ctx)
 	}
 
@@ -661,7 +661,7 @@ func (m *redisModelMonitor) GetAllBannedModelChannels(
 
 // GetAllChannelModelErrorRates gets error rates for all channels and models
 func GetAllChannelModelErrorRates(ctx context.Context) (map[int64]map[string]float64, error) {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return memModelMonitor.GetAllChannelModelErrorRates(ctx)
 	}
 
@@ -671,7 +671,7 @@ func GetAllChannelModelErrorRates(ctx context.Context) (map[int64]map[string]flo
 func GetAllModelChannelStats(
 	ctx context.Context,
 ) (map[string]map[int64]ModelChannelStatsSnapshot, error) {
-	if !common.RedisEnabled {
+	if !common.RedisAvailable() {
 		return memModelMonitor.GetAllModelChannelStats(ctx)
 	}
 
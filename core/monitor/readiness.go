@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// channelReadiness keeps the channels reported not ready by the probes of this
+// instance, an entry expires so a channel no longer probed is used again
+type channelReadiness struct {
+	mu       sync.RWMutex
+	notReady map[int64]notReadyChannel
+}
+
+type notReadyChannel struct {
+	reason    string
+	expiresAt time.Time
+}
+
+var readinessRegistry = &channelReadiness{
+	notReady: make(map[int64]notReadyChannel),
+}
+
+// SetChannelNotReady skips the channel in the channel selection until the ttl
+// is over or the channel is reported ready
+func SetChannelNotReady(channelID int64, reason string, ttl time.Duration) {
+	readinessRegistry.mu.Lock()
+	defer readinessRegistry.mu.Unlock()
+
+	readinessRegistry.notReady[channelID] = notReadyChannel{
+		reason:    reason,
+		expiresAt: time.Now().Add(ttl),
+	}
+}
+
+func SetChannelReady(channelID int64) {
+	readinessRegistry.mu.Lock()
+	defer readinessRegistry.mu.Unlock()
+
+	delete(readinessRegistry.notReady, channelID)
+}
+
+// ChannelNotReady reports whether the last probe of the channel found it not
+// ready to take more requests
+func ChannelNotReady(channelID int64) bool {
+	readinessRegistry.mu.RLock()
+	defer readinessRegistry.mu.RUnlock()
+
+	channel, ok := readinessRegistry.notReady[channelID]
+
+	return ok && time.Now().Before(channel.expiresAt)
+}
+
+type NotReadyChannel struct {
+	ChannelID int64     `json:"channel_id"`
+	Reason    string    `json:"reason"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// GetNotReadyChannels returns the channels not ready on this instance
+func GetNotReadyChannels() []NotReadyChannel {
+	readinessRegistry.mu.RLock()
+
+	now := time.Now()
+
+	channels := make([]NotReadyChannel, 0, len(readinessRegistry.notReady))
+	for channelID, channel := range readinessRegistry.notReady {
+		if !now.Before(channel.expiresAt) {
+			continue
+		}
+
+		channels = append(channels, NotReadyChannel{
+			ChannelID: channelID,
+			Reason:    channel.reason,
+			ExpiresAt: channel.expiresAt,
+		})
+	}
+
+	readinessRegistry.mu.RUnlock()
+
+	slices.SortFunc(channels, func(a, b NotReadyChannel) int {
+		return cmp.Compare(a.ChannelID, b.ChannelID)
+	})
+
+	return channels
+}
@@ -0,0 +1,39 @@
+//nolint:testpackage
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelReadiness(t *testing.T) {
+	const channelID = 9101
+
+	require.False(t, ChannelNotReady(channelID))
+
+	SetChannelNotReady(channelID, "gpu cache usage 0.98", time.Minute)
+	require.True(t, ChannelNotReady(channelID))
+
+	var found bool
+
+	for _, channel := range GetNotReadyChannels() {
+		if channel.ChannelID == channelID {
+			found = true
+
+			require.Equal(t, "gpu cache usage 0.98", channel.Reason)
+		}
+	}
+
+	require.True(t, found)
+
+	SetChannelReady(channelID)
+	require.False(t, ChannelNotReady(channelID))
+
+	// an expired entry is ready again
+	SetChannelNotReady(channelID, "not ready", -time.Second)
+	require.False(t, ChannelNotReady(channelID))
+
+	SetChannelReady(channelID)
+}
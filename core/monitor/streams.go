@@ -0,0 +1,103 @@
+package monitor
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+)
+
+// channelStreams counts the in-flight requests of the channels on this
+// instance, a non stream request holds a slot until the response is written.
+// The counts are not shared between the instances, so a limit applies to each
+// instance on its own rather than to the whole cluster
+type channelStreams struct {
+	mu       sync.Mutex
+	inFlight map[int64]int64
+}
+
+var streamRegistry = &channelStreams{
+	inFlight: make(map[int64]int64),
+}
+
+// tryAcquire takes a slot unless the in-flight requests reached maxStreams,
+// the check and the increment are done under the same lock
+func (s *channelStreams) tryAcquire(channelID int64, maxStreams int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if maxStreams > 0 && s.inFlight[channelID] >= int64(maxStreams) {
+		return false
+	}
+
+	s.inFlight[channelID]++
+
+	return true
+}
+
+func (s *channelStreams) release(channelID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inFlight[channelID] <= 1 {
+		delete(s.inFlight, channelID)
+		return
+	}
+
+	s.inFlight[channelID]--
+}
+
+func (s *channelStreams) get(channelID int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.inFlight[channelID]
+}
+
+// TryAcquireChannelStream takes a slot of the channel unless it has maxStreams
+// in-flight requests on this instance, a maxStreams of zero means the channel is
+// not limited. The returned func releases the slot and must be called once the
+// response is done
+func TryAcquireChannelStream(channelID int64, maxStreams int) (func(), bool) {
+	if !streamRegistry.tryAcquire(channelID, maxStreams) {
+		return nil, false
+	}
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			streamRegistry.release(channelID)
+		})
+	}, true
+}
+
+func GetChannelStreams(channelID int64) int64 {
+	return streamRegistry.get(channelID)
+}
+
+type ChannelStreamCount struct {
+	ChannelID int64 `json:"channel_id"`
+	InFlight  int64 `json:"in_flight"`
+}
+
+// GetChannelStreamCounts returns the channels with in-flight requests on this
+// instance
+func GetChannelStreamCounts() []ChannelStreamCount {
+	streamRegistry.mu.Lock()
+
+	counts := make([]ChannelStreamCount, 0, len(streamRegistry.inFlight))
+	for channelID, inFlight := range streamRegistry.inFlight {
+		counts = append(counts, ChannelStreamCount{
+			ChannelID: channelID,
+			InFlight:  inFlight,
+		})
+	}
+
+	streamRegistry.mu.Unlock()
+
+	slices.SortFunc(counts, func(a, b ChannelStreamCount) int {
+		return cmp.Compare(a.ChannelID, b.ChannelID)
+	})
+
+	return counts
+}
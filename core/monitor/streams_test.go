@@ -0,0 +1,45 @@
+//nolint:testpackage
+package monitor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelStreamsSaturation(t *testing.T) {
+	const channelID = 9001
+
+	releaseA, ok := TryAcquireChannelStream(channelID, 2)
+	require.True(t, ok)
+
+	releaseB, ok := TryAcquireChannelStream(channelID, 2)
+	require.True(t, ok)
+
+	require.Equal(t, int64(2), GetChannelStreams(channelID))
+
+	_, ok = TryAcquireChannelStream(channelID, 2)
+	require.False(t, ok)
+	require.Equal(t, int64(2), GetChannelStreams(channelID))
+
+	releaseC, ok := TryAcquireChannelStream(channelID, 0)
+	require.True(t, ok)
+	releaseC()
+
+	require.Contains(t, GetChannelStreamCounts(), ChannelStreamCount{
+		ChannelID: channelID,
+		InFlight:  2,
+	})
+
+	releaseA()
+	// releasing twice must not free the slot of another request
+	releaseA()
+	require.Equal(t, int64(1), GetChannelStreams(channelID))
+
+	releaseA, ok = TryAcquireChannelStream(channelID, 2)
+	require.True(t, ok)
+	releaseA()
+
+	releaseB()
+	require.Equal(t, int64(0), GetChannelStreams(channelID))
+}
@@ -0,0 +1,331 @@
+// Package adaptortest replays the recorded provider responses (cassettes)
+// through the adaptors, so the request and response conversions of every
+// adaptor are covered without calling the providers
+package adaptortest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/controller"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+// Cassette is a recorded exchange with a provider, the request is sent by the
+// client and the upstream is replayed by a local server
+type Cassette struct {
+	Name        string            `json:"name"`
+	ChannelType model.ChannelType `json:"channel_type"`
+	Mode        string            `json:"mode"`
+	Model       string            `json:"model"`
+	// Path is the path of the client request, for the modes routed by path
+	Path     string          `json:"path,omitempty"`
+	Request  json.RawMessage `json:"request"`
+	Upstream Upstream        `json:"upstream"`
+	Expect   Expect          `json:"expect"`
+
+	file string
+}
+
+// Upstream is the recorded provider response, a stream response is recorded
+// as the events written and flushed one by one
+type Upstream struct {
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+	Events []string          `json:"events,omitempty"`
+	Status int               `json:"status"`
+}
+
+type Expect struct {
+	// UpstreamPath is the path the adaptor requests
+	UpstreamPath string `json:"upstream_path,omitempty"`
+	// UpstreamRequestContains are the substrings of the converted request
+	UpstreamRequestContains []string `json:"upstream_request_contains,omitempty"`
+	// ResponseContains are the substrings of the response to the client
+	ResponseContains []string    `json:"response_contains,omitempty"`
+	Usage            model.Usage `json:"usage"`
+	Status           int         `json:"status"`
+	Error            bool        `json:"error,omitempty"`
+}
+
+// Load loads the cassettes of the dir, the cassettes are json files and the
+// sub dirs are loaded recursively
+func Load(dir string) ([]Cassette, error) {
+	var cassettes []Cassette
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var cassette Cassette
+		if err := json.Unmarshal(data, &cassette); err != nil {
+			return fmt.Errorf("invalid cassette %s: %w", path, err)
+		}
+
+		cassette.file = path
+		if cassette.Name == "" {
+			cassette.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+		}
+
+		cassettes = append(cassettes, cassette)
+
+		return nil
+	})
+
+	return cassettes, err
+}
+
+// TestName is the name of the cassette sub test
+func (c *Cassette) TestName() string {
+	rel := c.file
+	if dir := filepath.Dir(filepath.Dir(c.file)); dir != "" {
+		if r, err := filepath.Rel(dir, c.file); err == nil {
+			rel = r
+		}
+	}
+
+	return strings.TrimSuffix(filepath.ToSlash(rel), ".json")
+}
+
+type recordedRequest struct {
+	path string
+	body []byte
+}
+
+// upstreamServer replays the upstream of the cassette and records the request
+// of the adaptor
+func upstreamServer(t *testing.T, upstream Upstream) (*httptest.Server, *recordedRequest) {
+	t.Helper()
+
+	var (
+		mu       sync.Mutex
+		recorded recordedRequest
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		recorded = recordedRequest{path: r.URL.Path, body: body}
+		mu.Unlock()
+
+		for k, v := range upstream.Header {
+			w.Header().Set(k, v)
+		}
+
+		if len(upstream.Events) > 0 && w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+
+		status := upstream.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		w.WriteHeader(status)
+
+		if len(upstream.Events) == 0 {
+			_, _ = w.Write(upstream.Body)
+			return
+		}
+
+		flusher, _ := w.(http.Flusher)
+		for _, event := range upstream.Events {
+			_, _ = io.WriteString(w, event+"\n\n")
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &recorded
+}
+
+// channelBaseURL points the default base url of the adaptor to the server,
+// the path of the default base url is kept
+func channelBaseURL(a adaptor.Adaptor, srv *httptest.Server) (string, error) {
+	base, err := url.Parse(a.DefaultBaseURL())
+	if err != nil {
+		return "", err
+	}
+
+	server, err := url.Parse(srv.URL)
+	if err != nil {
+		return "", err
+	}
+
+	base.Scheme = server.Scheme
+	base.Host = server.Host
+
+	return base.String(), nil
+}
+
+// Run replays the cassette through the adaptor, the usage, the response and
+// the converted request are checked against the expectations of the cassette
+func Run(t *testing.T, a adaptor.Adaptor, cassette Cassette) {
+	t.Helper()
+
+	m, ok := mode.Parse(cassette.Mode)
+	if !ok {
+		t.Fatalf("unknown mode %q", cassette.Mode)
+	}
+
+	srv, recorded := upstreamServer(t, cassette.Upstream)
+
+	baseURL, err := channelBaseURL(a, srv)
+	if err != nil {
+		t.Fatalf("invalid base url: %v", err)
+	}
+
+	channel := &model.Channel{
+		ID:      1,
+		Type:    cassette.ChannelType,
+		Name:    "cassette",
+		BaseURL: baseURL,
+		Key:     "cassette-key",
+	}
+
+	relayMeta := meta.NewMeta(channel, m, cassette.Model, model.ModelConfig{Model: cassette.Model})
+
+	if !a.SupportMode(relayMeta) {
+		t.Fatalf("adaptor does not support mode %s", cassette.Mode)
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	path := cassette.Path
+	if path == "" {
+		path = "/"
+	}
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		path,
+		bytes.NewReader(cassette.Request),
+	)
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	result := controller.Handle(a, c, relayMeta, NewStore())
+
+	if result.Error != nil {
+		if !cassette.Expect.Error {
+			t.Fatalf("unexpected error: %d %s", result.Error.StatusCode(), result.Error.Error())
+		}
+
+		if cassette.Expect.Status != 0 && result.Error.StatusCode() != cassette.Expect.Status {
+			t.Fatalf(
+				"expected error status %d, got %d",
+				cassette.Expect.Status,
+				result.Error.StatusCode(),
+			)
+		}
+	} else {
+		if cassette.Expect.Error {
+			t.Fatalf("expected an error, got response: %s", recorder.Body.String())
+		}
+
+		if cassette.Expect.Status != 0 && recorder.Code != cassette.Expect.Status {
+			t.Fatalf("expected status %d, got %d", cassette.Expect.Status, recorder.Code)
+		}
+	}
+
+	if result.Usage != cassette.Expect.Usage {
+		t.Fatalf("expected usage %+v, got %+v", cassette.Expect.Usage, result.Usage)
+	}
+
+	if cassette.Expect.UpstreamPath != "" && recorded.path != cassette.Expect.UpstreamPath {
+		t.Fatalf("expected upstream path %s, got %s", cassette.Expect.UpstreamPath, recorded.path)
+	}
+
+	for _, s := range cassette.Expect.UpstreamRequestContains {
+		if !bytes.Contains(recorded.body, []byte(s)) {
+			t.Fatalf("expected upstream request to contain %q, got %s", s, recorded.body)
+		}
+	}
+
+	for _, s := range cassette.Expect.ResponseContains {
+		if !strings.Contains(recorder.Body.String(), s) {
+			t.Fatalf("expected response to contain %q, got %s", s, recorder.Body.String())
+		}
+	}
+}
+
+var _ adaptor.Store = (*Store)(nil)
+
+// Store is an in memory adaptor store
+type Store struct {
+	mu     sync.Mutex
+	stores []adaptor.StoreCache
+}
+
+func NewStore() *Store {
+	return &Store{}
+}
+
+func (s *Store) GetStore(group string, tokenID int, id string) (adaptor.StoreCache, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := slices.IndexFunc(s.stores, func(store adaptor.StoreCache) bool {
+		return store.GroupID == group && store.TokenID == tokenID && store.ID == id
+	})
+	if idx == -1 {
+		return adaptor.StoreCache{}, fmt.Errorf("store %s not found", id)
+	}
+
+	return s.stores[idx], nil
+}
+
+func (s *Store) SaveStore(store adaptor.StoreCache) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stores = append(s.stores, store)
+
+	return nil
+}
+
+func (s *Store) SaveStoreWithOption(store adaptor.StoreCache, _ adaptor.SaveStoreOption) error {
+	return s.SaveStore(store)
+}
+
+func (s *Store) SaveIfNotExistStore(store adaptor.StoreCache) error {
+	if _, err := s.GetStore(store.GroupID, store.TokenID, store.ID); err == nil {
+		return nil
+	}
+
+	return s.SaveStore(store)
+}
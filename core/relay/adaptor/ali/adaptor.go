@@ -31,6 +31,18 @@ func (a *Adaptor) DefaultBaseURL() string {
 	return baseURL
 }
 
+// NativeMode reports the claude messages are relayed to the anthropic
+// compatible endpoint as they are
+func (a *Adaptor) NativeMode(mt *meta.Meta) bool {
+	return adaptor.ModeFromMeta(mt) == mode.Anthropic
+}
+
+// NonRetriableAfterSend reports the generation requests are not retried on
+// another channel once sent, they are billed even when the response is lost
+func (a *Adaptor) NonRetriableAfterSend(mt *meta.Meta) bool {
+	return adaptor.IsBilledOnReceiptMode(adaptor.ModeFromMeta(mt))
+}
+
 func (a *Adaptor) SupportMode(mt *meta.Meta) bool {
 	m := adaptor.ModeFromMeta(mt)
 
@@ -407,7 +419,7 @@ func (a *Adaptor) DoResponse(
 
 func (a *Adaptor) Metadata() adaptor.Metadata {
 	return adaptor.Metadata{
-		Readme: "OpenAI compatibility\nNative Responses API support\nNetwork search metering support\nImage generation/edit support: https://help.aliyun.com/zh/model-studio/qwen-image-api and https://help.aliyun.com/zh/model-studio/qwen-image-edit-api\nVideo generation support: DashScope /api/v1/services/aigc/video-generation/video-synthesis\nRerank support: https://help.aliyun.com/zh/model-studio/text-rerank-api\nSTT support: https://help.aliyun.com/zh/model-studio/sambert-speech-synthesis/\nAnthropic support: /api/v2/apps/claude-code-proxy\nGemini support",
+		Readme: "OpenAI compatibility\nNative Responses API support\nNetwork search metering support\nImage generation/edit support: https://help.aliyun.com/zh/model-studio/qwen-image-api and https://help.aliyun.com/zh/model-studio/qwen-image-edit-api\nVideo generation support: DashScope /api/v1/services/aigc/video-generation/video-synthesis\nRerank support: https://help.aliyun.com/zh/model-studio/text-rerank-api\nSTT support: https://help.aliyun.com/zh/model-studio/sambert-speech-synthesis/\nAnthropic support: /api/v2/apps/claude-code-proxy\nGemini support\nQwen VL/Audio/Omni/OCR multimodal support, native image/audio/video parts and large base64 inputs are uploaded to DashScope temporary storage (oss://)",
 		Models: ModelList,
 	}
 }
@@ -215,7 +215,12 @@ func fetchAliVideoTask(
 	}
 
 	if channel != nil {
-		req.Header.Set("Authorization", "Bearer "+channel.Key)
+		key, err := channel.GetKey()
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+key)
 	}
 
 	var (
@@ -114,7 +114,27 @@ func ConvertChatCompletionsRequest(
 		callbacks = append(callbacks, patchQwqOnlySupportStream)
 	}
 
-	return openai.ConvertChatCompletionsRequest(meta, req, false, callbacks...)
+	if !isQwenMultimodalModel(meta) {
+		return openai.ConvertChatCompletionsRequest(meta, req, false, callbacks...)
+	}
+
+	uploader := newOSSUploader(req.Context(), meta)
+	omni := isQwenOmniModel(meta)
+
+	callbacks = append(callbacks, func(node *ast.Node) error {
+		return uploader.patchMultimodalContentFromNode(omni, node)
+	})
+
+	result, err := openai.ConvertChatCompletionsRequest(meta, req, false, callbacks...)
+	if err != nil {
+		return result, err
+	}
+
+	if uploader.resolve {
+		result.Header.Set(ossResourceResolveHeader, "enable")
+	}
+
+	return result, nil
 }
 
 func ConvertGeminiRequest(
@@ -0,0 +1,509 @@
+package ali
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/bytedance/sonic/ast"
+	"github.com/labring/aiproxy/core/relay/meta"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	"github.com/labring/aiproxy/core/relay/utils"
+)
+
+const (
+	// ossResourceResolveHeader must be set when the request refers to files
+	// uploaded to the DashScope temporary storage by oss:// urls
+	ossResourceResolveHeader = "X-DashScope-OssResourceResolve"
+	ossURLPrefix             = "oss://"
+	// maxInlineImageSize is the max decoded size of an inline base64 image,
+	// larger images are uploaded to the temporary storage
+	maxInlineImageSize = 7 << 20
+)
+
+// isQwenMultimodalModel reports whether the model is a qwen vision, audio,
+// omni or ocr model which accepts image, audio and video inputs
+func isQwenMultimodalModel(meta *meta.Meta) bool {
+	return aliModelMatches(meta, func(modelName string) bool {
+		modelName = strings.ToLower(modelName)
+		return strings.Contains(modelName, "-vl") ||
+			strings.HasPrefix(modelName, "qvq") ||
+			strings.Contains(modelName, "audio") ||
+			strings.Contains(modelName, "omni") ||
+			strings.Contains(modelName, "ocr")
+	})
+}
+
+// qwen omni models accept inline base64 audio by data url,
+// other audio models only accept audio urls
+func isQwenOmniModel(meta *meta.Meta) bool {
+	return aliModelMatches(meta, func(modelName string) bool {
+		return strings.Contains(strings.ToLower(modelName), "omni")
+	})
+}
+
+// uploadPolicy is the upload credential of the DashScope temporary storage
+// https://help.aliyun.com/zh/model-studio/get-temporary-file-url
+type uploadPolicy struct {
+	Policy              string `json:"policy"`
+	Signature           string `json:"signature"`
+	UploadDir           string `json:"upload_dir"`
+	UploadHost          string `json:"upload_host"`
+	ExpireInSeconds     int64  `json:"expire_in_seconds"`
+	MaxFileSizeMB       int64  `json:"max_file_size_mb"`
+	OSSAccessKeyID      string `json:"oss_access_key_id"`
+	XOSSObjectACL       string `json:"x_oss_object_acl"`
+	XOSSForbidOverwrite string `json:"x_oss_forbid_overwrite"`
+}
+
+type uploadPolicyResponse struct {
+	Data      *uploadPolicy `json:"data"`
+	Code      string        `json:"code"`
+	Message   string        `json:"message"`
+	RequestID string        `json:"request_id"`
+}
+
+// ossUploader uploads the client provided binaries to the DashScope temporary
+// storage, the upload policy is requested once per request
+type ossUploader struct {
+	ctx    context.Context
+	meta   *meta.Meta
+	policy *uploadPolicy
+	// resolve is true when the converted request refers to oss:// urls
+	resolve bool
+}
+
+func newOSSUploader(ctx context.Context, meta *meta.Meta) *ossUploader {
+	return &ossUploader{ctx: ctx, meta: meta}
+}
+
+func (u *ossUploader) baseURL() string {
+	if u.meta.Channel.BaseURL != "" {
+		return u.meta.Channel.BaseURL
+	}
+
+	return baseURL
+}
+
+func (u *ossUploader) getPolicy() (*uploadPolicy, error) {
+	if u.policy != nil {
+		return u.policy, nil
+	}
+
+	policyURL, err := url.JoinPath(u.baseURL(), "/api/v1/uploads")
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("action", "getPolicy")
+	query.Set("model", u.meta.ActualModel)
+
+	req, err := http.NewRequestWithContext(
+		u.ctx,
+		http.MethodGet,
+		policyURL+"?"+query.Encode(),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+u.meta.Channel.Key)
+
+	resp, err := utils.DoRequestWithMeta(req, u.meta)
+	if err != nil {
+		return nil, fmt.Errorf("get upload policy failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var policyResp uploadPolicyResponse
+	if err := sonic.ConfigDefault.NewDecoder(resp.Body).Decode(&policyResp); err != nil {
+		return nil, fmt.Errorf("decode upload policy failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || policyResp.Data == nil {
+		return nil, fmt.Errorf(
+			"get upload policy failed: status %d, code %s, message %s",
+			resp.StatusCode,
+			policyResp.Code,
+			policyResp.Message,
+		)
+	}
+
+	u.policy = policyResp.Data
+
+	return u.policy, nil
+}
+
+// upload uploads the data and returns the oss:// url of it
+func (u *ossUploader) upload(filename string, data []byte) (string, error) {
+	policy, err := u.getPolicy()
+	if err != nil {
+		return "", err
+	}
+
+	if policy.MaxFileSizeMB > 0 && int64(len(data)) > policy.MaxFileSizeMB<<20 {
+		return "", fmt.Errorf(
+			"file size %d exceeds the upload limit of %dMB",
+			len(data),
+			policy.MaxFileSizeMB,
+		)
+	}
+
+	key := strings.TrimSuffix(policy.UploadDir, "/") + "/" + filename
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for _, field := range [][2]string{
+		{"OSSAccessKeyId", policy.OSSAccessKeyID},
+		{"Signature", policy.Signature},
+		{"policy", policy.Policy},
+		{"x-oss-object-acl", policy.XOSSObjectACL},
+		{"x-oss-forbid-overwrite", policy.XOSSForbidOverwrite},
+		{"key", key},
+		{"success_action_status", "200"},
+	} {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return "", err
+		}
+	}
+
+	// the file field must be the last field of the form
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(u.ctx, http.MethodPost, policy.UploadHost, body)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := utils.DoRequestWithMeta(req, u.meta)
+	if err != nil {
+		return "", fmt.Errorf("upload file failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("upload file failed: status %d, %s", resp.StatusCode, respBody)
+	}
+
+	u.resolve = true
+
+	return ossURLPrefix + key, nil
+}
+
+// checkOSSURL marks the request to resolve oss:// urls provided by the client
+func (u *ossUploader) checkOSSURL(s string) {
+	if strings.HasPrefix(s, ossURLPrefix) {
+		u.resolve = true
+	}
+}
+
+// parseDataURL parses a base64 data url, e.g. data:image/png;base64,xxx
+func parseDataURL(s string) (mimeType string, data []byte, ok bool) {
+	rest, found := strings.CutPrefix(s, "data:")
+	if !found {
+		return "", nil, false
+	}
+
+	header, encoded, found := strings.Cut(rest, ",")
+	if !found {
+		return "", nil, false
+	}
+
+	mimeType, found = strings.CutSuffix(header, ";base64")
+	if !found {
+		return "", nil, false
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, false
+	}
+
+	return mimeType, data, true
+}
+
+// uploadFilename returns a random filename with the extension of the mime type
+func uploadFilename(mimeType, defaultExt string) string {
+	_, subtype, _ := strings.Cut(mimeType, "/")
+	subtype = strings.TrimPrefix(subtype, "x-")
+
+	switch subtype {
+	case "":
+		return rand.Text() + "." + defaultExt
+	case "jpeg":
+		return rand.Text() + ".jpg"
+	case "mpeg":
+		return rand.Text() + ".mp3"
+	default:
+		return rand.Text() + "." + subtype
+	}
+}
+
+func isRemoteURL(s string) bool {
+	return strings.HasPrefix(s, "http://") ||
+		strings.HasPrefix(s, "https://") ||
+		strings.HasPrefix(s, ossURLPrefix) ||
+		strings.HasPrefix(s, "data:")
+}
+
+func newImageURLPart(imageURL string) ast.Node {
+	return ast.NewObject([]ast.Pair{
+		ast.NewPair("type", ast.NewString(relaymodel.ContentTypeImageURL)),
+		ast.NewPair(relaymodel.ContentTypeImageURL, ast.NewObject([]ast.Pair{
+			ast.NewPair("url", ast.NewString(imageURL)),
+		})),
+	})
+}
+
+func newInputAudioPart(data, format string) ast.Node {
+	audio := []ast.Pair{ast.NewPair("data", ast.NewString(data))}
+	if format != "" {
+		audio = append(audio, ast.NewPair("format", ast.NewString(format)))
+	}
+
+	return ast.NewObject([]ast.Pair{
+		ast.NewPair("type", ast.NewString(relaymodel.ContentTypeInputAudio)),
+		ast.NewPair(relaymodel.ContentTypeInputAudio, ast.NewObject(audio)),
+	})
+}
+
+func newVideoURLPart(videoURL string) ast.Node {
+	return ast.NewObject([]ast.Pair{
+		ast.NewPair("type", ast.NewString(relaymodel.ContentTypeVideoURL)),
+		ast.NewPair(relaymodel.ContentTypeVideoURL, ast.NewObject([]ast.Pair{
+			ast.NewPair("url", ast.NewString(videoURL)),
+		})),
+	})
+}
+
+// patchImageURL uploads the large inline images, DashScope rejects base64
+// images larger than 10MB
+func (u *ossUploader) patchImageURL(imageURL string) (string, error) {
+	u.checkOSSURL(imageURL)
+
+	mimeType, data, ok := parseDataURL(imageURL)
+	if !ok || len(data) <= maxInlineImageSize {
+		return imageURL, nil
+	}
+
+	return u.upload(uploadFilename(mimeType, "png"), data)
+}
+
+// patchInputAudio converts the openai input_audio data to the shape accepted
+// by DashScope, the raw base64 audio is sent by data url to the omni models
+// and uploaded to the temporary storage for the other models
+func (u *ossUploader) patchInputAudio(omni bool, data, format string) (string, error) {
+	u.checkOSSURL(data)
+
+	if strings.HasPrefix(data, "data:") {
+		if omni {
+			return data, nil
+		}
+
+		mimeType, decoded, ok := parseDataURL(data)
+		if !ok {
+			return "", errors.New("invalid input_audio data url")
+		}
+
+		return u.upload(uploadFilename(mimeType, "mp3"), decoded)
+	}
+
+	if isRemoteURL(data) {
+		return data, nil
+	}
+
+	if omni {
+		return "data:;base64," + data, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("invalid input_audio data: %w", err)
+	}
+
+	if format == "" {
+		format = "mp3"
+	}
+
+	return u.upload(rand.Text()+"."+format, decoded)
+}
+
+// patchContentPart converts a content part to the openai compatible shape,
+// the native DashScope parts are {"image": url}, {"audio": url} and {"video": url}
+func (u *ossUploader) patchContentPart(omni bool, part *ast.Node) (bool, error) {
+	partType, _ := part.Get("type").String()
+
+	switch {
+	case partType == relaymodel.ContentTypeImageURL:
+		urlNode := part.Get(relaymodel.ContentTypeImageURL)
+
+		imageURL, err := urlNode.Get("url").String()
+		if err != nil {
+			return false, nil
+		}
+
+		patched, err := u.patchImageURL(imageURL)
+		if err != nil || patched == imageURL {
+			return false, err
+		}
+
+		_, err = urlNode.Set("url", ast.NewString(patched))
+
+		return true, err
+	case partType == relaymodel.ContentTypeInputAudio:
+		audioNode := part.Get(relaymodel.ContentTypeInputAudio)
+
+		data, _ := audioNode.Get("data").String()
+		if data == "" {
+			data, _ = audioNode.Get("url").String()
+		}
+
+		if data == "" {
+			return false, nil
+		}
+
+		format, _ := audioNode.Get("format").String()
+
+		patched, err := u.patchInputAudio(omni, data, format)
+		if err != nil {
+			return false, err
+		}
+
+		*part = newInputAudioPart(patched, format)
+
+		return true, nil
+	case partType == "image" || (partType == "" && part.Get("image").Exists()):
+		image, err := part.Get("image").String()
+		if err != nil {
+			return false, nil
+		}
+
+		patched, err := u.patchImageURL(image)
+		if err != nil {
+			return false, err
+		}
+
+		*part = newImageURLPart(patched)
+
+		return true, nil
+	case partType == "audio" || (partType == "" && part.Get("audio").Exists()):
+		audio, err := part.Get("audio").String()
+		if err != nil {
+			return false, nil
+		}
+
+		patched, err := u.patchInputAudio(omni, audio, "")
+		if err != nil {
+			return false, err
+		}
+
+		*part = newInputAudioPart(patched, "")
+
+		return true, nil
+	case partType == "video" || (partType == "" && part.Get("video").Exists()):
+		video, err := part.Get("video").String()
+		if err != nil {
+			// video as a list of frames is passed through
+			return false, nil
+		}
+
+		u.checkOSSURL(video)
+
+		*part = newVideoURLPart(video)
+
+		return true, nil
+	case partType == "" && part.Get("text").Exists():
+		_, err := part.Set("type", ast.NewString(relaymodel.ContentTypeText))
+		return true, err
+	case partType == relaymodel.ContentTypeVideoURL:
+		videoURL, _ := part.Get(relaymodel.ContentTypeVideoURL).Get("url").String()
+		u.checkOSSURL(videoURL)
+	}
+
+	return false, nil
+}
+
+// patchMultimodalContentFromNode converts the multimodal content parts of the
+// messages, the binaries are uploaded to the DashScope temporary storage when required
+func (u *ossUploader) patchMultimodalContentFromNode(omni bool, node *ast.Node) error {
+	messagesNode := node.Get("messages")
+	if messagesNode.Check() != nil || messagesNode.TypeSafe() != ast.V_ARRAY {
+		return nil
+	}
+
+	messages, err := messagesNode.ArrayUseNode()
+	if err != nil {
+		return err
+	}
+
+	for messageIndex, message := range messages {
+		contentNode := message.Get("content")
+		if contentNode.Check() != nil || contentNode.TypeSafe() != ast.V_ARRAY {
+			continue
+		}
+
+		parts, err := contentNode.ArrayUseNode()
+		if err != nil {
+			return err
+		}
+
+		changed := false
+
+		for partIndex, part := range parts {
+			if part.TypeSafe() != ast.V_OBJECT {
+				continue
+			}
+
+			patched, err := u.patchContentPart(omni, &part)
+			if err != nil {
+				return err
+			}
+
+			if !patched {
+				continue
+			}
+
+			changed = true
+
+			if _, err := contentNode.SetByIndex(partIndex, part); err != nil {
+				return err
+			}
+		}
+
+		if !changed {
+			continue
+		}
+
+		if _, err := messagesNode.SetByIndex(messageIndex, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
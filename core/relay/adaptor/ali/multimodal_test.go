@@ -0,0 +1,191 @@
+//nolint:testpackage
+package ali
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	coremodel "github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+func newDashScopeUploadServer(t *testing.T, uploaded *[]string) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/uploads":
+			if r.URL.Query().Get("action") != "getPolicy" {
+				t.Errorf("unexpected action: %s", r.URL.Query().Get("action"))
+			}
+
+			if r.Header.Get("Authorization") != "Bearer sk-test" {
+				t.Errorf("unexpected authorization: %s", r.Header.Get("Authorization"))
+			}
+
+			_, _ = io.WriteString(w, `{"data":{"policy":"p","signature":"s",`+
+				`"upload_dir":"dashscope-instant/abc","upload_host":"`+server.URL+`/oss",`+
+				`"max_file_size_mb":100,"oss_access_key_id":"ak"}}`)
+		case "/oss":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("parse multipart form: %v", err)
+			}
+
+			*uploaded = append(*uploaded, r.FormValue("key"))
+
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+
+	return server
+}
+
+func convertMultimodalChatRequest(
+	t *testing.T,
+	baseURL, modelName, body string,
+) (http.Header, string) {
+	t.Helper()
+
+	channel := &coremodel.Channel{BaseURL: baseURL, Key: "sk-test"}
+	m := meta.NewMeta(channel, mode.ChatCompletions, modelName, coremodel.ModelConfig{})
+
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodPost,
+		"/v1/chat/completions",
+		bytes.NewBufferString(body),
+	)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+
+	result, err := ConvertChatCompletionsRequest(m, nil, req)
+	if err != nil {
+		t.Fatalf("ConvertChatCompletionsRequest returned error: %v", err)
+	}
+
+	converted, err := io.ReadAll(result.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+
+	return result.Header, string(converted)
+}
+
+func TestConvertChatCompletionsRequestUploadsAudio(t *testing.T) {
+	t.Parallel()
+
+	var uploaded []string
+
+	server := newDashScopeUploadServer(t, &uploaded)
+	defer server.Close()
+
+	audio := base64.StdEncoding.EncodeToString([]byte("fake audio"))
+
+	header, body := convertMultimodalChatRequest(
+		t,
+		server.URL,
+		"qwen-audio-turbo",
+		`{"model":"qwen-audio-turbo","messages":[{"role":"user","content":[`+
+			`{"type":"input_audio","input_audio":{"data":"`+audio+`","format":"wav"}},`+
+			`{"type":"text","text":"what is said"}]}]}`,
+	)
+
+	if len(uploaded) != 1 || !strings.HasPrefix(uploaded[0], "dashscope-instant/abc/") ||
+		!strings.HasSuffix(uploaded[0], ".wav") {
+		t.Fatalf("unexpected uploaded keys: %v", uploaded)
+	}
+
+	if header.Get(ossResourceResolveHeader) != "enable" {
+		t.Fatalf("expected oss resource resolve header, got %v", header)
+	}
+
+	data, err := sonic.GetFromString(body, "messages", 0, "content", 0, "input_audio", "data")
+	if err != nil {
+		t.Fatalf("get input_audio data: %v", err)
+	}
+
+	got, _ := data.String()
+	if got != "oss://"+uploaded[0] {
+		t.Fatalf("expected oss url, got %s", got)
+	}
+}
+
+func TestConvertChatCompletionsRequestNativeParts(t *testing.T) {
+	t.Parallel()
+
+	header, body := convertMultimodalChatRequest(
+		t,
+		"http://127.0.0.1:0",
+		"qwen-vl-ocr",
+		`{"model":"qwen-vl-ocr","messages":[{"role":"user","content":[`+
+			`{"image":"oss://dashscope-instant/abc/a.png"},`+
+			`{"video":"https://example.com/a.mp4"},`+
+			`{"text":"read the text"}]}]}`,
+	)
+
+	if header.Get(ossResourceResolveHeader) != "enable" {
+		t.Fatalf("expected oss resource resolve header, got %v", header)
+	}
+
+	for _, want := range []string{
+		`{"type":"image_url","image_url":{"url":"oss://dashscope-instant/abc/a.png"}}`,
+		`{"type":"video_url","video_url":{"url":"https://example.com/a.mp4"}}`,
+		`"type":"text"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %s in body, got %s", want, body)
+		}
+	}
+}
+
+func TestConvertChatCompletionsRequestOmniInlineAudio(t *testing.T) {
+	t.Parallel()
+
+	header, body := convertMultimodalChatRequest(
+		t,
+		"http://127.0.0.1:0",
+		"qwen-omni-turbo",
+		`{"model":"qwen-omni-turbo","stream":true,"messages":[{"role":"user","content":[`+
+			`{"type":"input_audio","input_audio":{"data":"ZmFrZQ==","format":"mp3"}}]}]}`,
+	)
+
+	if header.Get(ossResourceResolveHeader) != "" {
+		t.Fatalf("unexpected oss resource resolve header")
+	}
+
+	if !strings.Contains(body, `"data":"data:;base64,ZmFrZQ=="`) {
+		t.Fatalf("expected inline data url, got %s", body)
+	}
+}
+
+func TestConvertChatCompletionsRequestTextModelUntouched(t *testing.T) {
+	t.Parallel()
+
+	header, body := convertMultimodalChatRequest(
+		t,
+		"http://127.0.0.1:0",
+		"qwen-plus",
+		`{"model":"qwen-plus","messages":[{"role":"user","content":[{"image":"oss://a/b.png"}]}]}`,
+	)
+
+	if header.Get(ossResourceResolveHeader) != "" {
+		t.Fatalf("unexpected oss resource resolve header")
+	}
+
+	if !strings.Contains(body, `{"image":"oss://a/b.png"}`) {
+		t.Fatalf("expected content untouched, got %s", body)
+	}
+}
@@ -34,6 +34,11 @@ func (a *Adaptor) DefaultBaseURL() string {
 	return baseURL
 }
 
+// NativeMode reports the claude messages are relayed as they are
+func (a *Adaptor) NativeMode(mt *meta.Meta) bool {
+	return adaptor.ModeFromMeta(mt) == mode.Anthropic
+}
+
 func (a *Adaptor) SupportMode(mt *meta.Meta) bool {
 	m := adaptor.ModeFromMeta(mt)
 
@@ -78,23 +83,56 @@ const (
 	AnthropicBeta        = "Anthropic-Beta"
 )
 
+// ModelDefaultMaxTokens returns the built-in max_tokens of the known claude models
 func ModelDefaultMaxTokens(model string) int {
+	maxTokens, _ := builtinDefaultMaxTokens(model)
+	return maxTokens
+}
+
+func builtinDefaultMaxTokens(model string) (int, bool) {
 	switch {
 	case strings.Contains(model, "opus-4-5"):
-		return 64000
+		return 64000, true
 	case strings.Contains(model, "sonnet-4-5"):
-		return 64000
+		return 64000, true
 	case strings.Contains(model, "4-1"):
-		return 204800
+		return 204800, true
 	case strings.Contains(model, "sonnet-4-"):
-		return 64000
+		return 64000, true
 	case strings.Contains(model, "opus-4-"):
-		return 32768
+		return 32768, true
 	case strings.Contains(model, "3-7"):
-		return 131072
+		return 64000, true
 	default:
-		return 4096
+		return 4096, false
+	}
+}
+
+// DefaultMaxTokens returns the max_tokens used when the request omits it, the
+// default_max_tokens of the model config takes precedence over the built-in
+// table, the max_output_tokens is used for the unknown models
+func DefaultMaxTokens(modelConfig model.ModelConfig, modelName string) int {
+	maxTokens, ok := modelConfig.DefaultMaxTokens()
+	if !ok || maxTokens <= 0 {
+		maxTokens, ok = builtinDefaultMaxTokens(modelName)
+		if !ok {
+			if maxOutputTokens, ok := modelConfig.MaxOutputTokens(); ok && maxOutputTokens > 0 {
+				maxTokens = maxOutputTokens
+			}
+		}
+	}
+
+	return ClampMaxTokens(modelConfig, maxTokens)
+}
+
+// ClampMaxTokens limits the max_tokens to the max_output_tokens of the model config
+func ClampMaxTokens(modelConfig model.ModelConfig, maxTokens int) int {
+	maxOutputTokens, ok := modelConfig.MaxOutputTokens()
+	if ok && maxOutputTokens > 0 && maxTokens > maxOutputTokens {
+		return maxOutputTokens
 	}
+
+	return maxTokens
 }
 
 func FixBetasStringWithModel(model, betas string, deleteFunc ...func(e string) bool) string {
@@ -141,6 +179,17 @@ func FixBetasWithModel(model string, betas []string, deleteFunc ...func(e string
 	})
 }
 
+func appendBeta(rawBetas, beta string) string {
+	switch {
+	case strings.Contains(rawBetas, beta):
+		return rawBetas
+	case rawBetas == "":
+		return beta
+	default:
+		return rawBetas + "," + beta
+	}
+}
+
 func (a *Adaptor) SetupRequestHeader(
 	meta *meta.Meta,
 	_ adaptor.Store,
@@ -158,13 +207,31 @@ func (a *Adaptor) SetupRequestHeader(
 
 	rawBetas := c.Request.Header.Get(AnthropicBeta)
 
+	if meta.GetBool(metaCodeExecutionBetaKey) {
+		rawBetas = appendBeta(rawBetas, relaymodel.ClaudeBetaCodeExecution)
+	}
+
+	if meta.GetBool(metaFilesBetaKey) {
+		rawBetas = appendBeta(rawBetas, relaymodel.ClaudeBetaFilesAPI)
+	}
+
 	if rawBetas != "" {
-		req.Header.Set(
-			AnthropicBeta,
-			FixBetasStringWithModel(ResolveModelName(meta.OriginModel, meta.ActualModel), rawBetas),
+		rawBetas = FixBetasStringWithModel(
+			ResolveModelName(meta.OriginModel, meta.ActualModel),
+			rawBetas,
 		)
 	}
 
+	// the long output beta is kept even for the models unknown to the beta
+	// filter, it comes from the model config
+	if beta := LongOutputBetaFromMeta(meta); beta != "" {
+		rawBetas = appendBeta(rawBetas, beta)
+	}
+
+	if rawBetas != "" {
+		req.Header.Set(AnthropicBeta, rawBetas)
+	}
+
 	return nil
 }
 
@@ -248,8 +315,12 @@ func (a *Adaptor) DoResponse(
 
 func (a *Adaptor) Metadata() adaptor.Metadata {
 	return adaptor.Metadata{
-		Readme: "Support native Endpoint: /v1/messages",
+		Readme: "Support native Endpoint: /v1/messages\nClaude returns no log probabilities, the logprobs and top_logprobs of the chat requests are dropped",
 		Models: ModelList,
+		Limitations: []string{
+			"logprobs and top_logprobs of the chat requests are dropped",
+			"input_audio content of the chat requests is not supported",
+		},
 		ConfigSchema: map[string]any{
 			"type": "object",
 			"properties": map[string]any{
@@ -281,6 +352,11 @@ func (a *Adaptor) Metadata() adaptor.Metadata {
 					"title":       "Disable Auto Image URL To Base64",
 					"description": "Keep image URLs unchanged instead of downloading and converting them to base64.",
 				},
+				"disable_long_output_beta": map[string]any{
+					"type":        "boolean",
+					"title":       "Disable Long Output Beta",
+					"description": "Limit max_tokens to the standard ceiling of the model instead of attaching the long output beta (e.g. output-128k) when it is exceeded.",
+				},
 			},
 		},
 	}
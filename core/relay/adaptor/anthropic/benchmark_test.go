@@ -0,0 +1,69 @@
+package anthropic_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/labring/aiproxy/core/relay/adaptor/anthropic"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+var benchmarkOpenAIRequest = []byte(`{
+	"model": "claude-sonnet-4-20250514",
+	"stream": true,
+	"max_tokens": 1024,
+	"messages": [
+		{"role": "system", "content": "You are a helpful assistant."},
+		{"role": "user", "content": "What is the weather like in Paris today?"},
+		{"role": "assistant", "content": null, "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}]},
+		{"role": "tool", "tool_call_id": "call_1", "content": "{\"temperature\":22,\"condition\":\"sunny\"}"},
+		{"role": "user", "content": [{"type": "text", "text": "Thanks, and tomorrow?"}]}
+	],
+	"tools": [{"type": "function", "function": {"name": "get_weather", "description": "Get the weather", "parameters": {"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]}}}]
+}`)
+
+func BenchmarkOpenAIConvertRequest(b *testing.B) {
+	m := &meta.Meta{
+		ActualModel: "claude-sonnet-4-20250514",
+		OriginModel: "claude-sonnet-4-20250514",
+		Mode:        mode.ChatCompletions,
+	}
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		req, err := http.NewRequestWithContext(
+			b.Context(),
+			http.MethodPost,
+			"http://localhost/v1/chat/completions",
+			bytes.NewReader(benchmarkOpenAIRequest),
+		)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := anthropic.OpenAIConvertRequest(m, req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStreamResponse2OpenAI(b *testing.B) {
+	m := &meta.Meta{
+		OriginModel: "claude-sonnet-4-20250514",
+	}
+	chunk := []byte(
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello, world"}}`,
+	)
+
+	b.ReportAllocs()
+
+	state := anthropic.NewStreamState()
+	for b.Loop() {
+		if _, err := state.StreamResponse2OpenAI(m, chunk); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -8,6 +8,9 @@ type Config struct {
 	RemoveToolsExamples                 bool     `json:"remove_tools_examples"`
 	RemoveToolsCustomDeferLoading       bool     `json:"remove_tools_custom_defer_loading"`
 	DisableAutoImageURLToBase64         bool     `json:"disable_auto_image_url_to_base64"`
+	// DisableLongOutputBeta limits the max_tokens to the standard ceiling of
+	// the model instead of attaching the long output beta
+	DisableLongOutputBeta bool `json:"disable_long_output_beta"`
 }
 
 func loadConfig(meta *meta.Meta) (Config, error) {
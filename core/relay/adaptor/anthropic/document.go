@@ -0,0 +1,99 @@
+package anthropic
+
+import (
+	"strings"
+
+	"github.com/bytedance/sonic/ast"
+	"github.com/labring/aiproxy/core/common/pdf"
+	"github.com/labring/aiproxy/core/relay/meta"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+)
+
+// metaFilesBetaKey marks that the request references the files uploaded with
+// the files api, the files api beta header is added for it
+const metaFilesBetaKey = "anthropic_files_beta"
+
+// inspectDocuments counts the pages of the base64 pdf documents passed through
+// and marks the requests referencing uploaded files
+func inspectDocuments(meta *meta.Meta, node *ast.Node) {
+	messagesNode := node.Get("messages")
+	if messagesNode == nil || messagesNode.TypeSafe() != ast.V_ARRAY {
+		return
+	}
+
+	_ = messagesNode.ForEach(func(_ ast.Sequence, msgNode *ast.Node) bool {
+		contentNode := msgNode.Get("content")
+		if contentNode == nil || contentNode.TypeSafe() != ast.V_ARRAY {
+			return true
+		}
+
+		_ = contentNode.ForEach(func(_ ast.Sequence, contentItem *ast.Node) bool {
+			contentType, _ := contentItem.Get("type").String()
+			if contentType != relaymodel.ClaudeContentTypeDocument &&
+				contentType != relaymodel.ClaudeContentTypeImage {
+				return true
+			}
+
+			sourceNode := contentItem.Get("source")
+			if sourceNode == nil || !sourceNode.Exists() {
+				return true
+			}
+
+			sourceType, _ := sourceNode.Get("type").String()
+
+			switch sourceType {
+			case relaymodel.ClaudeImageSourceTypeFile:
+				meta.Set(metaFilesBetaKey, true)
+			case relaymodel.ClaudeImageSourceTypeBase64:
+				mediaType, _ := sourceNode.Get("media_type").String()
+				if mediaType != pdf.MediaType {
+					return true
+				}
+
+				data, _ := sourceNode.Get("data").String()
+				if decoded, err := pdf.DecodeBase64(data); err == nil {
+					if doc, err := pdf.Parse(decoded); err == nil {
+						meta.DocumentPages += int64(doc.PageCount())
+					}
+				}
+			}
+
+			return true
+		})
+
+		return true
+	})
+}
+
+// fileDocumentSource converts the openai file part to the document source, the
+// uploaded files are referenced by their id
+func fileDocumentSource(
+	meta *meta.Meta,
+	file *relaymodel.MessageFile,
+) *relaymodel.ClaudeImageSource {
+	if file.FileID != "" {
+		meta.Set(metaFilesBetaKey, true)
+
+		return &relaymodel.ClaudeImageSource{
+			Type:   relaymodel.ClaudeImageSourceTypeFile,
+			FileID: file.FileID,
+		}
+	}
+
+	mediaType, data, ok := strings.Cut(strings.TrimPrefix(file.FileData, "data:"), ";base64,")
+	if !ok {
+		mediaType, data = pdf.MediaType, file.FileData
+	}
+
+	if decoded, err := pdf.DecodeBase64(data); err == nil {
+		if doc, err := pdf.Parse(decoded); err == nil {
+			meta.DocumentPages += int64(doc.PageCount())
+		}
+	}
+
+	return &relaymodel.ClaudeImageSource{
+		Type:      relaymodel.ClaudeImageSourceTypeBase64,
+		MediaType: mediaType,
+		Data:      data,
+	}
+}
@@ -49,16 +49,23 @@ func ConvertGeminiRequestToStruct(
 ) (*relaymodel.ClaudeRequest, error) {
 	resolvedModel := ResolveModelName(meta.OriginModel, meta.ActualModel)
 
+	cfg, err := loadConfig(meta)
+	if err != nil {
+		return nil, err
+	}
+
 	// Parse Gemini request
 	geminiReq, err := utils.UnmarshalGeminiChatRequest(req)
 	if err != nil {
 		return nil, err
 	}
 
+	utils.RemoveGeminiGroundingTools(req, geminiReq)
+
 	// Convert to Claude format
 	claudeReq := relaymodel.ClaudeRequest{
 		Model:     meta.ActualModel,
-		MaxTokens: ModelDefaultMaxTokens(resolvedModel),
+		MaxTokens: DefaultMaxTokens(meta.ModelConfig, resolvedModel),
 		Messages:  []relaymodel.ClaudeMessage{},
 		System:    convertGeminiSystemInstruction(geminiReq),
 	}
@@ -104,7 +111,10 @@ func ConvertGeminiRequestToStruct(
 		}
 
 		if geminiReq.GenerationConfig.MaxOutputTokens != nil {
-			claudeReq.MaxTokens = *geminiReq.GenerationConfig.MaxOutputTokens
+			claudeReq.MaxTokens = ClampMaxTokens(
+				meta.ModelConfig,
+				*geminiReq.GenerationConfig.MaxOutputTokens,
+			)
 		}
 	}
 
@@ -124,12 +134,15 @@ func ConvertGeminiRequestToStruct(
 	if claudeReq.Thinking != nil {
 		normalizeClaudeThinking(
 			resolvedModel,
+			maxTokensCeiling(meta, cfg, resolvedModel),
 			&claudeReq.MaxTokens,
 			&claudeReq.Thinking,
 			&claudeReq.OutputConfig,
 		)
 	}
 
+	applyLongOutput(meta, cfg, resolvedModel, &claudeReq.MaxTokens)
+
 	// Convert tools
 	claudeReq.Tools = convertGeminiTools(geminiReq)
 	claudeReq.ToolChoice = convertGeminiToolConfig(geminiReq)
@@ -314,6 +327,7 @@ func GeminiStreamHandler(
 
 	if err := scanner.Err(); err != nil {
 		log.Error("error reading stream: " + err.Error())
+		render.GeminiStreamError(c, err)
 	}
 
 	return adaptor.DoResponseResult{
@@ -655,6 +669,8 @@ func convertGeminiToolConfig(geminiReq *relaymodel.GeminiChatRequest) any {
 	switch geminiReq.ToolConfig.FunctionCallingConfig.Mode {
 	case relaymodel.GeminiFunctionCallingModeAuto:
 		return map[string]any{"type": relaymodel.ToolChoiceAuto}
+	case relaymodel.GeminiFunctionCallingModeNone:
+		return map[string]any{"type": relaymodel.ToolChoiceNone}
 	case relaymodel.GeminiFunctionCallingModeAny:
 		if len(geminiReq.ToolConfig.FunctionCallingConfig.AllowedFunctionNames) > 0 {
 			return map[string]any{
@@ -6,8 +6,10 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/labring/aiproxy/core/model"
 	"github.com/labring/aiproxy/core/relay/adaptor/anthropic"
 	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
 	relaymodel "github.com/labring/aiproxy/core/relay/model"
 )
 
@@ -324,3 +326,66 @@ func TestConvertGeminiRequestToStruct_NormalizesThinkingForAdaptiveModels(t *tes
 		t.Fatalf("expected budget_tokens to be removed, got %d", claudeReq.Thinking.BudgetTokens)
 	}
 }
+
+func TestConvertGeminiRequestToStruct_ThinkingBudgetWithinLongOutputCeiling(t *testing.T) {
+	requestJSON := `{
+		"contents": [
+			{
+				"parts": [{"text": "test"}],
+				"role": "user"
+			}
+		],
+		"generationConfig": {
+			"maxOutputTokens": 1000,
+			"thinkingConfig": {
+				"thinkingBudget": 100000
+			}
+		}
+	}`
+
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodPost,
+		"/v1beta/models/gemini-pro:generateContent",
+		strings.NewReader(requestJSON),
+	)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// the channel disables the long output beta, so the budget must fit in the
+	// standard 64k ceiling of claude 3.7 sonnet
+	m := meta.NewMeta(
+		&model.Channel{
+			Configs: model.ChannelConfigs{
+				"disable_long_output_beta": true,
+			},
+		},
+		mode.Gemini,
+		"claude-3-7-sonnet-20250219",
+		model.ModelConfig{},
+	)
+
+	claudeReq, err := anthropic.ConvertGeminiRequestToStruct(m, req)
+	if err != nil {
+		t.Fatalf("ConvertGeminiRequestToStruct failed: %v", err)
+	}
+
+	if claudeReq.Thinking == nil {
+		t.Fatal("expected thinking to be set")
+	}
+
+	if claudeReq.MaxTokens != 64000 {
+		t.Fatalf("expected max_tokens 64000, got %d", claudeReq.MaxTokens)
+	}
+
+	if claudeReq.Thinking.BudgetTokens != 32000 {
+		t.Fatalf("expected budget_tokens 32000, got %d", claudeReq.Thinking.BudgetTokens)
+	}
+
+	if beta := anthropic.LongOutputBetaFromMeta(m); beta != "" {
+		t.Fatalf("expected no long output beta, got %s", beta)
+	}
+}
@@ -0,0 +1,88 @@
+package anthropic
+
+import (
+	"strings"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/meta"
+)
+
+const (
+	// LongOutputBeta128k raises the max output tokens of claude 3.7 sonnet
+	// from 64k to 128k
+	LongOutputBeta128k = "output-128k-2025-02-19"
+
+	// metaLongOutputBetaKey is the long output beta the request needs since its
+	// max_tokens is over the standard ceiling
+	metaLongOutputBetaKey = "anthropic_long_output_beta"
+)
+
+// longOutputLimit returns the long output beta of the model with the standard
+// ceiling it raises and the ceiling with the beta, a zero extended ceiling
+// means it is unknown, the model config takes precedence over the built-in
+// betas
+func longOutputLimit(
+	modelConfig model.ModelConfig,
+	modelName string,
+) (beta string, standard, extended int, ok bool) {
+	if beta, standard, ok := modelConfig.LongOutputBeta(); ok {
+		extended, _ := modelConfig.MaxOutputTokens()
+		return beta, standard, extended, true
+	}
+
+	if strings.Contains(modelName, "3-7-sonnet") {
+		extended, ok := modelConfig.MaxOutputTokens()
+		if !ok || extended <= 0 {
+			extended = 128000
+		}
+
+		return LongOutputBeta128k, 64000, extended, true
+	}
+
+	return "", 0, 0, false
+}
+
+// maxTokensCeiling returns the highest max_tokens the request may use, the
+// standard ceiling when the channel disables the long output beta, zero when
+// the ceiling is unknown
+func maxTokensCeiling(meta *meta.Meta, cfg Config, modelName string) int {
+	_, standard, extended, ok := longOutputLimit(meta.ModelConfig, modelName)
+	if ok {
+		if cfg.DisableLongOutputBeta {
+			return standard
+		}
+
+		return extended
+	}
+
+	maxOutputTokens, _ := meta.ModelConfig.MaxOutputTokens()
+
+	return max(maxOutputTokens, 0)
+}
+
+// applyLongOutput attaches the long output beta when the max_tokens is over the
+// standard ceiling of the model, the max_tokens is limited to the standard
+// ceiling instead when the channel disables the beta
+func applyLongOutput(meta *meta.Meta, cfg Config, modelName string, maxTokens *int) {
+	beta, standard, extended, ok := longOutputLimit(meta.ModelConfig, modelName)
+	if !ok || *maxTokens <= standard {
+		return
+	}
+
+	if cfg.DisableLongOutputBeta {
+		*maxTokens = standard
+		return
+	}
+
+	if extended > 0 && *maxTokens > extended {
+		*maxTokens = extended
+	}
+
+	meta.Set(metaLongOutputBetaKey, beta)
+}
+
+// LongOutputBetaFromMeta returns the long output beta the converted request
+// needs, empty when its max_tokens is within the standard ceiling
+func LongOutputBetaFromMeta(meta *meta.Meta) string {
+	return meta.GetString(metaLongOutputBetaKey)
+}
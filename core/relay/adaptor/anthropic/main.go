@@ -228,6 +228,8 @@ func convertRequestBodyToBytes(
 		}
 	}
 
+	inspectDocuments(meta, node)
+
 	// Set the actual model in the request
 	_, err := node.Set("model", ast.NewString(meta.ActualModel))
 	if err != nil {
@@ -247,13 +249,21 @@ func convertRequestBodyToBytes(
 		})
 	}
 
+	resolvedModel := ResolveModelName(meta.OriginModel, meta.ActualModel)
+
 	maxTokensNode := node.Get("max_tokens")
 	if maxTokensNode == nil || !maxTokensNode.Exists() {
-		resolvedModel := ResolveModelName(meta.OriginModel, meta.ActualModel)
 		_, _ = node.Set(
 			"max_tokens",
-			ast.NewNumber(strconv.Itoa(ModelDefaultMaxTokens(resolvedModel))),
+			ast.NewNumber(strconv.Itoa(DefaultMaxTokens(meta.ModelConfig, resolvedModel))),
 		)
+	} else if maxTokens, err := maxTokensNode.Int64(); err == nil {
+		clamped := ClampMaxTokens(meta.ModelConfig, int(maxTokens))
+		applyLongOutput(meta, adaptorConfig, resolvedModel, &clamped)
+
+		if clamped != int(maxTokens) {
+			_, _ = node.Set("max_tokens", ast.NewNumber(strconv.Itoa(clamped)))
+		}
 	}
 
 	if node.Get("thinking").Exists() {
@@ -419,16 +429,26 @@ func StreamHandler(
 		usage      *relaymodel.ChatUsage
 		writed     bool
 		upstreamID string
+		eventName  string
 	)
 
 	streamState := NewStreamState()
 
 	for scanner.Scan() {
 		data := scanner.Bytes()
+		if render.IsSSEEvent(data) {
+			eventName = render.ExtractSSEEvent(data)
+			continue
+		}
+
 		if !render.IsValidSSEData(data) {
 			continue
 		}
 
+		// the event line belongs to the following data line only
+		event := eventName
+		eventName = ""
+
 		data = render.ExtractSSEData(data)
 		if render.IsSSEDone(data) {
 			break
@@ -505,13 +525,19 @@ func StreamHandler(
 			}
 		}
 
-		render.ClaudeData(c, data)
+		// same protocol upstream, keep the upstream event names as is
+		if event != "" {
+			render.RawEventData(c, event, data)
+		} else {
+			render.ClaudeData(c, data)
+		}
 
 		writed = true
 	}
 
 	if err := scanner.Err(); err != nil {
 		log.Error("error reading stream: " + err.Error())
+		render.ClaudeStreamError(c, err)
 	}
 
 	if usage == nil || usage.PromptTokens == 0 || usage.TotalTokens == 0 {
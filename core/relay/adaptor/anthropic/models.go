@@ -0,0 +1,35 @@
+package anthropic
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
+)
+
+var _ adaptor.ModelLister = (*Adaptor)(nil)
+
+const maxListModelsLimit = 1000
+
+// https://docs.anthropic.com/en/api/models-list
+func (a *Adaptor) ListModels(ctx context.Context, channel *model.Channel) ([]string, error) {
+	key, err := channel.GetKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return openai.ListModels(
+		ctx,
+		channel,
+		"/models",
+		url.Values{"limit": {strconv.Itoa(maxListModelsLimit)}},
+		http.Header{
+			AnthropicTokenHeader: {key},
+			"Anthropic-Version":  {AnthropicVersion},
+		},
+	)
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/bytedance/sonic"
 	"github.com/gin-gonic/gin"
@@ -22,11 +23,75 @@ import (
 	"golang.org/x/sync/semaphore"
 )
 
-const (
-	serverToolUseType       = "server_tool_use"
-	webSearchToolResult     = "web_search_tool_result"
-	codeExecutionToolResult = "code_execution_tool_result"
-)
+// metaCodeExecutionBetaKey marks that the converted request uses the code execution server tool,
+// which requires the code execution beta header
+const metaCodeExecutionBetaKey = "anthropic_code_execution_beta"
+
+// webSearchContextSizeMaxUses maps openai search_context_size to claude web search max_uses
+var webSearchContextSizeMaxUses = map[string]int{
+	"low":    1,
+	"medium": 3,
+	"high":   5,
+}
+
+// openAIBuiltinTool2Claude maps openai built-in tools to claude server tools,
+// other non-function tools are passed through unchanged
+func openAIBuiltinTool2Claude(tool *relaymodel.ClaudeOpenaiTool) relaymodel.ClaudeTool {
+	claudeTool := relaymodel.ClaudeTool{
+		Type:            tool.Type,
+		Name:            tool.Name,
+		DisplayWidthPx:  tool.DisplayWidthPx,
+		DisplayHeightPx: tool.DisplayHeightPx,
+		DisplayNumber:   tool.DisplayNumber,
+		CacheControl:    tool.CacheControl.ResetTTL(),
+
+		MaxUses:        tool.MaxUses,
+		AllowedDomains: tool.AllowedDomains,
+		BlockedDomains: tool.BlockedDomains,
+		UserLocation:   tool.UserLocation,
+	}
+
+	switch tool.Type {
+	case "web_search", "web_search_preview":
+		claudeTool.Type = relaymodel.ClaudeToolTypeWebSearch
+		claudeTool.Name = relaymodel.ClaudeToolNameWebSearch
+	case "code_interpreter", "code_execution":
+		claudeTool.Type = relaymodel.ClaudeToolTypeCodeExecution
+		claudeTool.Name = relaymodel.ClaudeToolNameCodeExecution
+	}
+
+	return claudeTool
+}
+
+func webSearchOptions2ClaudeTool(options *relaymodel.WebSearchOptions) relaymodel.ClaudeTool {
+	claudeTool := relaymodel.ClaudeTool{
+		Type:    relaymodel.ClaudeToolTypeWebSearch,
+		Name:    relaymodel.ClaudeToolNameWebSearch,
+		MaxUses: webSearchContextSizeMaxUses[options.SearchContextSize],
+	}
+
+	if options.UserLocation != nil && options.UserLocation.Approximate != nil {
+		claudeTool.UserLocation = &relaymodel.ClaudeUserLocation{
+			Type:     "approximate",
+			City:     options.UserLocation.Approximate.City,
+			Region:   options.UserLocation.Approximate.Region,
+			Country:  options.UserLocation.Approximate.Country,
+			Timezone: options.UserLocation.Approximate.Timezone,
+		}
+	}
+
+	return claudeTool
+}
+
+func hasClaudeToolType(tools []relaymodel.ClaudeTool, toolType string) bool {
+	for _, tool := range tools {
+		if tool.Type == toolType {
+			return true
+		}
+	}
+
+	return false
+}
 
 func stopReasonClaude2OpenAI(reason string) string {
 	switch reason {
@@ -67,6 +132,13 @@ func openAIConvertRequest(
 		return nil, err
 	}
 
+	if (textRequest.Logprobs != nil && *textRequest.Logprobs) || textRequest.TopLogprobs != nil {
+		log.Warnf(
+			"model %s does not support logprobs, logprobs and top_logprobs are dropped",
+			meta.OriginModel,
+		)
+	}
+
 	reasoning := utils.ParseClaudeOpenAIReasoning(&textRequest)
 
 	textRequest.Model = meta.ActualModel
@@ -74,43 +146,42 @@ func openAIConvertRequest(
 
 	for _, tool := range textRequest.Tools {
 		if tool.Type != "function" {
+			claudeTools = append(claudeTools, openAIBuiltinTool2Claude(tool))
+		} else {
+			// a function without parameters takes an empty object
+			params, _ := tool.Function.Parameters.(map[string]any)
+
+			t, _ := params["type"].(string)
+			if t == "" {
+				t = "object"
+			}
+
 			claudeTools = append(claudeTools, relaymodel.ClaudeTool{
-				Type:            tool.Type,
-				Name:            tool.Name,
-				DisplayWidthPx:  tool.DisplayWidthPx,
-				DisplayHeightPx: tool.DisplayHeightPx,
-				DisplayNumber:   tool.DisplayNumber,
-				CacheControl:    tool.CacheControl.ResetTTL(),
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				InputSchema: &relaymodel.ClaudeInputSchema{
+					Type:       t,
+					Properties: params["properties"],
+					Required:   params["required"],
+				},
+				CacheControl: tool.CacheControl.ResetTTL(),
 
 				MaxUses:        tool.MaxUses,
 				AllowedDomains: tool.AllowedDomains,
 				BlockedDomains: tool.BlockedDomains,
 				UserLocation:   tool.UserLocation,
 			})
-		} else {
-			if params, ok := tool.Function.Parameters.(map[string]any); ok {
-				t, _ := params["type"].(string)
-				if t == "" {
-					t = "object"
-				}
+		}
+	}
 
-				claudeTools = append(claudeTools, relaymodel.ClaudeTool{
-					Name:        tool.Function.Name,
-					Description: tool.Function.Description,
-					InputSchema: &relaymodel.ClaudeInputSchema{
-						Type:       t,
-						Properties: params["properties"],
-						Required:   params["required"],
-					},
-					CacheControl: tool.CacheControl.ResetTTL(),
+	if textRequest.WebSearchOptions != nil &&
+		(textRequest.WebSearchOptions.Enable == nil || *textRequest.WebSearchOptions.Enable) &&
+		!hasClaudeToolType(claudeTools, relaymodel.ClaudeToolTypeWebSearch) {
+		claudeTools = append(claudeTools, webSearchOptions2ClaudeTool(textRequest.WebSearchOptions))
+	}
 
-					MaxUses:        tool.MaxUses,
-					AllowedDomains: tool.AllowedDomains,
-					BlockedDomains: tool.BlockedDomains,
-					UserLocation:   tool.UserLocation,
-				})
-			}
-		}
+	if hasClaudeToolType(claudeTools, relaymodel.ClaudeToolTypeCodeExecution) {
+		meta.Set(metaCodeExecutionBetaKey, true)
 	}
 
 	claudeRequest := relaymodel.ClaudeRequest{
@@ -124,7 +195,9 @@ func openAIConvertRequest(
 	}
 
 	if claudeRequest.MaxTokens == 0 {
-		claudeRequest.MaxTokens = ModelDefaultMaxTokens(resolvedModel)
+		claudeRequest.MaxTokens = DefaultMaxTokens(meta.ModelConfig, resolvedModel)
+	} else {
+		claudeRequest.MaxTokens = ClampMaxTokens(meta.ModelConfig, claudeRequest.MaxTokens)
 	}
 
 	if reasoning.Specified {
@@ -160,12 +233,15 @@ func openAIConvertRequest(
 	if claudeRequest.Thinking != nil {
 		normalizeClaudeThinking(
 			resolvedModel,
+			maxTokensCeiling(meta, adaptorConfig, resolvedModel),
 			&claudeRequest.MaxTokens,
 			&claudeRequest.Thinking,
 			&claudeRequest.OutputConfig,
 		)
 	}
 
+	applyLongOutput(meta, adaptorConfig, resolvedModel, &claudeRequest.MaxTokens)
+
 	if claudeRequest.Thinking != nil {
 		claudeRequest.Temperature = nil
 	}
@@ -179,16 +255,16 @@ func openAIConvertRequest(
 		claudeToolChoice := struct {
 			Type string `json:"type"`
 			Name string `json:"name,omitempty"`
-		}{Type: "auto"}
-		if choice, ok := textRequest.ToolChoice.(map[string]any); ok {
-			if function, ok := choice["function"].(map[string]any); ok {
-				claudeToolChoice.Type = relaymodel.RoleTool
-				name, _ := function["name"].(string)
-				claudeToolChoice.Name = name
-			}
+		}{Type: relaymodel.ToolChoiceAuto}
+		if name := relaymodel.ForcedToolName(textRequest.ToolChoice); name != "" {
+			claudeToolChoice.Type = relaymodel.ToolChoiceTypeTool
+			claudeToolChoice.Name = name
 		} else if toolChoiceType, ok := textRequest.ToolChoice.(string); ok {
-			if toolChoiceType == "any" {
-				claudeToolChoice.Type = toolChoiceType
+			switch toolChoiceType {
+			case relaymodel.ToolChoiceRequired, relaymodel.ToolChoiceAny:
+				claudeToolChoice.Type = relaymodel.ToolChoiceAny
+			case relaymodel.ToolChoiceNone:
+				claudeToolChoice.Type = relaymodel.ToolChoiceNone
 			}
 		}
 
@@ -197,17 +273,18 @@ func openAIConvertRequest(
 
 	disableAutoImageURLToBase64 := autoImageURLToBase64Disabled(meta, adaptorConfig)
 
-	var imageTasks []*relaymodel.ClaudeContent
+	var imageTasks []imageTask
 
-	hasToolCalls := false
+	// thinking is kept only when the signed thinking blocks of all the tool
+	// use turns are sent back, claude rejects them otherwise
+	missingThinking := false
 
 	for _, message := range textRequest.Messages {
 		if message.Role == relaymodel.RoleSystem {
-			claudeRequest.System = append(claudeRequest.System, relaymodel.ClaudeContent{
-				Type:         relaymodel.ClaudeContentTypeText,
-				Text:         message.StringContent(),
-				CacheControl: message.CacheControl.ResetTTL(),
-			})
+			claudeRequest.System = append(
+				claudeRequest.System,
+				convertSystemMessage(message)...,
+			)
 
 			continue
 		}
@@ -258,8 +335,16 @@ func openAIConvertRequest(
 						URL:  part.ImageURL.URL,
 					}
 					if !disableAutoImageURLToBase64 {
-						imageTasks = append(imageTasks, &content)
+						imageTasks = append(imageTasks, imageTask{
+							source: content.Source,
+							detail: part.ImageURL.Detail,
+						})
 					}
+				case relaymodel.ContentTypeInputAudio:
+					return nil, adaptor.ErrInputAudioNotSupported
+				case relaymodel.ContentTypeFile:
+					content.Type = relaymodel.ClaudeContentTypeDocument
+					content.Source = fileDocumentSource(meta, part.File)
 				}
 
 				contents = append(contents, content)
@@ -268,8 +353,16 @@ func openAIConvertRequest(
 			claudeMessage.Content = contents
 		}
 
+		if message.Role == relaymodel.RoleAssistant && claudeRequest.Thinking != nil {
+			thinkingBlocks := signedThinkingBlocks(&message.Message)
+			if len(message.ToolCalls) > 0 && len(thinkingBlocks) == 0 {
+				missingThinking = true
+			}
+
+			claudeMessage.Content = append(thinkingBlocks, claudeMessage.Content...)
+		}
+
 		for _, toolCall := range message.ToolCalls {
-			hasToolCalls = true
 			inputParam := make(map[string]any)
 			_ = sonic.UnmarshalString(toolCall.Function.Arguments, &inputParam)
 			claudeMessage.Content = append(claudeMessage.Content, relaymodel.ClaudeContent{
@@ -287,20 +380,63 @@ func openAIConvertRequest(
 		batchPatchImage2Base64(req.Context(), imageTasks)
 	}
 
-	if hasToolCalls {
+	if missingThinking {
 		claudeRequest.Thinking = nil
 	}
 
 	return &claudeRequest, nil
 }
 
-func batchPatchImage2Base64(ctx context.Context, imageTasks []*relaymodel.ClaudeContent) {
+// signedThinkingBlocks returns the thinking blocks of the assistant message
+// claude accepts, the unsigned thinking of the other providers is dropped
+func signedThinkingBlocks(message *relaymodel.Message) []relaymodel.ClaudeContent {
+	var blocks []relaymodel.ClaudeContent
+
+	for _, block := range message.ClaudeThinkingBlocks() {
+		switch {
+		case block.Type == relaymodel.ClaudeContentTypeThinking && block.Signature != "":
+			blocks = append(blocks, relaymodel.ClaudeContent{
+				Type:      relaymodel.ClaudeContentTypeThinking,
+				Thinking:  block.Thinking,
+				Signature: block.Signature,
+			})
+		case block.Type == relaymodel.ClaudeContentTypeRedactedThinking && block.Data != "":
+			blocks = append(blocks, relaymodel.ClaudeContent{
+				Type: relaymodel.ClaudeContentTypeRedactedThinking,
+				Data: block.Data,
+			})
+		}
+	}
+
+	return blocks
+}
+
+// imageTask is an image url converted to base64, the image is scaled down as
+// the detail asks for
+type imageTask struct {
+	source *relaymodel.ClaudeImageSource
+	detail string
+}
+
+// claudeImageMaxEdge is the longest image edge claude reads, the larger images
+// are scaled down by claude itself, so they are sent scaled down
+const claudeImageMaxEdge = 1568
+
+func imageMaxEdge(detail string) int {
+	if detail == image.DetailLow {
+		return image.LowDetailMaxEdge
+	}
+
+	return claudeImageMaxEdge
+}
+
+func batchPatchImage2Base64(ctx context.Context, imageTasks []imageTask) {
 	sem := semaphore.NewWeighted(3)
 
 	var wg sync.WaitGroup
 
 	for _, task := range imageTasks {
-		if task.Source.URL == "" {
+		if task.source.URL == "" {
 			continue
 		}
 
@@ -315,7 +451,7 @@ func batchPatchImage2Base64(ctx context.Context, imageTasks []*relaymodel.Claude
 			}
 			defer sem.Release(1)
 
-			mimeType, data, err := image.GetImageFromURL(ctx, task.Source.URL)
+			mimeType, data, err := image.GetImageFromURL(ctx, task.source.URL)
 			if err != nil {
 				log.Warnf(
 					"convert anthropic image url to base64 failed, keep original url: %v",
@@ -325,10 +461,20 @@ func batchPatchImage2Base64(ctx context.Context, imageTasks []*relaymodel.Claude
 				return
 			}
 
-			task.Source.Type = relaymodel.ClaudeImageSourceTypeBase64
-			task.Source.URL = ""
-			task.Source.MediaType = mimeType
-			task.Source.Data = data
+			if resizedType, resized, err := image.ResizeBase64(
+				mimeType,
+				data,
+				imageMaxEdge(task.detail),
+			); err == nil {
+				mimeType, data = resizedType, resized
+			} else {
+				log.Warnf("resize anthropic image failed, keep original image: %v", err)
+			}
+
+			task.source.Type = relaymodel.ClaudeImageSourceTypeBase64
+			task.source.URL = ""
+			task.source.MediaType = mimeType
+			task.source.Data = data
 		})
 	}
 
@@ -342,13 +488,47 @@ type StreamState struct {
 	claudeIndexToToolCallIndex map[int]int
 	// nextToolCallIndex tracks the next tool call index to assign (0-based)
 	nextToolCallIndex int
+	// serverToolBlocks records the content block indexes of server tool calls,
+	// their input deltas are executed by claude and must not be exposed as tool calls
+	serverToolBlocks map[int]struct{}
+	// contentLength is the rune length of the text content sent so far
+	contentLength int
+	// textBlockStart records the content offset where a text block starts
+	textBlockStart map[int]int
+	// pendingCitations buffers citations until the cited text block is complete
+	pendingCitations map[int][]relaymodel.ClaudeCitation
+	webSearchCount   int64
 }
 
 func NewStreamState() *StreamState {
 	return &StreamState{
 		claudeIndexToToolCallIndex: make(map[int]int),
 		nextToolCallIndex:          0,
+		serverToolBlocks:           make(map[int]struct{}),
+		textBlockStart:             make(map[int]int),
+		pendingCitations:           make(map[int][]relaymodel.ClaudeCitation),
+	}
+}
+
+// flushCitations converts the buffered citations of a text block to annotations
+func (s *StreamState) flushCitations(index int) []relaymodel.Annotation {
+	citations := s.pendingCitations[index]
+	if len(citations) == 0 {
+		return nil
+	}
+
+	delete(s.pendingCitations, index)
+
+	start := s.textBlockStart[index]
+	annotations := make([]relaymodel.Annotation, 0, len(citations))
+
+	for _, citation := range citations {
+		if annotation, ok := citation.ToAnnotation(start, s.contentLength); ok {
+			annotations = append(annotations, annotation)
+		}
 	}
+
+	return annotations
 }
 
 // getToolCallIndex returns the OpenAI tool call index for a given Claude content block index
@@ -375,12 +555,14 @@ func (s *StreamState) StreamResponse2OpenAI(
 	respData []byte,
 ) (*relaymodel.ChatCompletionsStreamResponse, adaptor.Error) {
 	var (
-		usage      *relaymodel.ChatUsage
-		content    string
-		thinking   string
-		signature  string
-		stopReason string
-		upstreamID string
+		usage          *relaymodel.ChatUsage
+		content        string
+		thinking       string
+		signature      string
+		thinkingBlocks []relaymodel.ClaudeContent
+		stopReason     string
+		upstreamID     string
+		annotations    []relaymodel.Annotation
 	)
 
 	tools := make([]relaymodel.ToolCall, 0)
@@ -402,12 +584,18 @@ func (s *StreamState) StreamResponse2OpenAI(
 			http.StatusBadRequest,
 			respData,
 		)
-	case "ping", "message_stop", "content_block_stop":
+	case "ping", "message_stop":
 		return nil, nil
+	case "content_block_stop":
+		annotations = s.flushCitations(claudeResponse.Index)
+		if len(annotations) == 0 {
+			return nil, nil
+		}
 	case "content_block_start":
 		if claudeResponse.ContentBlock != nil {
 			content = claudeResponse.ContentBlock.Text
-			if claudeResponse.ContentBlock.Type == relaymodel.ClaudeContentTypeToolUse {
+			switch claudeResponse.ContentBlock.Type {
+			case relaymodel.ClaudeContentTypeToolUse:
 				toolCallIndex := s.getToolCallIndex(claudeResponse.Index, true)
 				tools = append(tools, relaymodel.ToolCall{
 					Index: toolCallIndex,
@@ -417,12 +605,29 @@ func (s *StreamState) StreamResponse2OpenAI(
 						Name: claudeResponse.ContentBlock.Name,
 					},
 				})
+			case relaymodel.ClaudeContentTypeServerToolUse:
+				s.serverToolBlocks[claudeResponse.Index] = struct{}{}
+				if claudeResponse.ContentBlock.Name == relaymodel.ClaudeToolNameWebSearch {
+					s.webSearchCount++
+				}
+			case relaymodel.ClaudeContentTypeRedactedThinking:
+				thinkingBlocks = append(thinkingBlocks, *claudeResponse.ContentBlock)
+			case relaymodel.ClaudeContentTypeText:
+				s.textBlockStart[claudeResponse.Index] = s.contentLength
+				s.pendingCitations[claudeResponse.Index] = append(
+					s.pendingCitations[claudeResponse.Index],
+					claudeResponse.ContentBlock.Citations...,
+				)
 			}
 		}
 	case "content_block_delta":
 		if claudeResponse.Delta != nil {
 			switch claudeResponse.Delta.Type {
 			case "input_json_delta":
+				if _, ok := s.serverToolBlocks[claudeResponse.Index]; ok {
+					return nil, nil
+				}
+
 				toolCallIndex := s.getToolCallIndex(claudeResponse.Index, false)
 				tools = append(tools, relaymodel.ToolCall{
 					Index: toolCallIndex,
@@ -435,6 +640,15 @@ func (s *StreamState) StreamResponse2OpenAI(
 				thinking = claudeResponse.Delta.Thinking
 			case "signature_delta":
 				signature = claudeResponse.Delta.Signature
+			case "citations_delta":
+				if claudeResponse.Delta.Citation != nil {
+					s.pendingCitations[claudeResponse.Index] = append(
+						s.pendingCitations[claudeResponse.Index],
+						*claudeResponse.Delta.Citation,
+					)
+				}
+
+				return nil, nil
 			default:
 				content = claudeResponse.Delta.Text
 			}
@@ -450,6 +664,10 @@ func (s *StreamState) StreamResponse2OpenAI(
 	case "message_delta":
 		if claudeResponse.Usage != nil {
 			openAIUsage := claudeResponse.Usage.ToOpenAIUsage()
+			if openAIUsage.WebSearchCount == 0 {
+				openAIUsage.WebSearchCount = s.webSearchCount
+			}
+
 			usage = &openAIUsage
 		}
 
@@ -458,12 +676,16 @@ func (s *StreamState) StreamResponse2OpenAI(
 		}
 	}
 
+	s.contentLength += utf8.RuneCountInString(content)
+
 	choice := relaymodel.ChatCompletionsStreamResponseChoice{
 		Delta: relaymodel.Message{
 			Content:          content,
 			ReasoningContent: thinking,
 			Signature:        signature,
+			ThinkingBlocks:   thinkingBlocks,
 			ToolCalls:        tools,
+			Annotations:      annotations,
 			Role:             relaymodel.RoleAssistant,
 		},
 		Index:        0,
@@ -511,19 +733,37 @@ func Response2OpenAI(
 	}
 
 	var (
-		content   string
-		thinking  string
-		signature string
+		content        strings.Builder
+		contentLength  int
+		thinking       []string
+		signature      string
+		thinkingBlocks []relaymodel.ClaudeContent
+		redacted       bool
+		annotations    []relaymodel.Annotation
+		webSearchCount int64
 	)
 
 	tools := make([]relaymodel.ToolCall, 0)
 	for _, v := range claudeResponse.Content {
 		switch v.Type {
 		case relaymodel.ClaudeContentTypeText:
-			content = v.Text
+			start := contentLength
+			content.WriteString(v.Text)
+			contentLength += utf8.RuneCountInString(v.Text)
+
+			for _, citation := range v.Citations {
+				if annotation, ok := citation.ToAnnotation(start, contentLength); ok {
+					annotations = append(annotations, annotation)
+				}
+			}
 		case relaymodel.ClaudeContentTypeThinking:
-			thinking = v.Thinking
+			thinking = append(thinking, v.Thinking)
 			signature = v.Signature
+
+			thinkingBlocks = append(thinkingBlocks, v)
+		case relaymodel.ClaudeContentTypeRedactedThinking:
+			thinkingBlocks = append(thinkingBlocks, v)
+			redacted = true
 		case relaymodel.ClaudeContentTypeToolUse:
 			args, _ := sonic.MarshalString(v.Input)
 			tools = append(tools, relaymodel.ToolCall{
@@ -535,9 +775,12 @@ func Response2OpenAI(
 					Arguments: args,
 				},
 			})
-		case serverToolUseType:
-		case webSearchToolResult:
-		case codeExecutionToolResult:
+		case relaymodel.ClaudeContentTypeServerToolUse:
+			if v.Name == relaymodel.ClaudeToolNameWebSearch {
+				webSearchCount++
+			}
+		case relaymodel.ClaudeContentTypeWebSearchToolResult,
+			relaymodel.ClaudeContentTypeCodeExecutionToolResult:
 		}
 	}
 
@@ -545,15 +788,22 @@ func Response2OpenAI(
 		Index: 0,
 		Message: relaymodel.Message{
 			Role:             relaymodel.RoleAssistant,
-			Content:          content,
-			ReasoningContent: thinking,
+			Content:          content.String(),
+			ReasoningContent: strings.Join(thinking, "\n\n"),
 			Signature:        signature,
 			Name:             nil,
 			ToolCalls:        tools,
+			Annotations:      annotations,
 		},
 		FinishReason: stopReasonClaude2OpenAI(claudeResponse.StopReason),
 	}
 
+	// the redacted and the interleaved thinking can not be rebuilt from
+	// reasoning_content and signature
+	if redacted || len(thinking) > 1 {
+		choice.Message.ThinkingBlocks = thinkingBlocks
+	}
+
 	// Use upstream ID if available, otherwise generate a new one
 	responseID := claudeResponse.ID
 	if responseID == "" {
@@ -572,6 +822,10 @@ func Response2OpenAI(
 		fullTextResponse.Usage.PromptTokens = int64(meta.RequestUsage.InputTokens)
 	}
 
+	if fullTextResponse.Usage.WebSearchCount == 0 {
+		fullTextResponse.Usage.WebSearchCount = webSearchCount
+	}
+
 	fullTextResponse.Usage.TotalTokens = fullTextResponse.Usage.PromptTokens + fullTextResponse.Usage.CompletionTokens
 
 	return &fullTextResponse, nil
@@ -668,8 +922,9 @@ func OpenAIStreamHandler(
 		writed = true
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Error("error reading stream: " + err.Error())
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		log.Error("error reading stream: " + scanErr.Error())
 	}
 
 	if usage == nil {
@@ -693,7 +948,11 @@ func OpenAIStreamHandler(
 		})
 	}
 
-	render.OpenaiDone(c)
+	if scanErr != nil {
+		render.OpenaiStreamError(c, scanErr)
+	} else {
+		render.OpenaiDone(c)
+	}
 
 	return adaptor.DoResponseResult{
 		Usage:      usage.ToModelUsage(),
@@ -744,3 +1003,36 @@ func OpenAIHandler(
 		UpstreamID: fullTextResponse.ID,
 	}, nil
 }
+
+// convertSystemMessage keeps every text part of the system message as a
+// system block of its own, the cache control of the message is set on the
+// last block so the whole system prompt is cached
+func convertSystemMessage(message *relaymodel.ClaudeOpenaiMessage) []relaymodel.ClaudeContent {
+	if message.IsStringContent() {
+		return []relaymodel.ClaudeContent{{
+			Type:         relaymodel.ClaudeContentTypeText,
+			Text:         message.StringContent(),
+			CacheControl: message.CacheControl.ResetTTL(),
+		}}
+	}
+
+	var blocks []relaymodel.ClaudeContent
+	for _, part := range message.ParseContent() {
+		if part.Type != relaymodel.ContentTypeText || part.Text == "" {
+			continue
+		}
+
+		blocks = append(blocks, relaymodel.ClaudeContent{
+			Type: relaymodel.ClaudeContentTypeText,
+			Text: part.Text,
+		})
+	}
+
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	blocks[len(blocks)-1].CacheControl = message.CacheControl.ResetTTL()
+
+	return blocks
+}
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
 	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
 	"github.com/labring/aiproxy/core/relay/adaptor/anthropic"
 	"github.com/labring/aiproxy/core/relay/meta"
 	"github.com/labring/aiproxy/core/relay/mode"
@@ -37,6 +40,51 @@ func TestModelDefaultMaxTokens(t *testing.T) {
 	})
 }
 
+func TestDefaultMaxTokensFromModelConfig(t *testing.T) {
+	convey.Convey("DefaultMaxTokens consults the model config", t, func() {
+		convey.So(
+			anthropic.DefaultMaxTokens(model.ModelConfig{}, "claude-sonnet-4-20250514"),
+			convey.ShouldEqual,
+			64000,
+		)
+
+		configured := model.ModelConfig{
+			Config: model.NewModelConfig(
+				model.WithModelConfigDefaultMaxTokens(16000),
+				model.WithModelConfigMaxOutputTokens(64000),
+			),
+		}
+		convey.So(
+			anthropic.DefaultMaxTokens(configured, "claude-sonnet-4-20250514"),
+			convey.ShouldEqual,
+			16000,
+		)
+
+		// unknown models use the max output tokens instead of 4096
+		unknown := model.ModelConfig{
+			Config: model.NewModelConfig(model.WithModelConfigMaxOutputTokens(128000)),
+		}
+		convey.So(
+			anthropic.DefaultMaxTokens(unknown, "claude-next"),
+			convey.ShouldEqual,
+			128000,
+		)
+		convey.So(anthropic.DefaultMaxTokens(model.ModelConfig{}, "claude-next"), convey.ShouldEqual, 4096)
+
+		// the built-in default never exceeds the ceiling
+		ceiling := model.ModelConfig{
+			Config: model.NewModelConfig(model.WithModelConfigMaxOutputTokens(32000)),
+		}
+		convey.So(
+			anthropic.DefaultMaxTokens(ceiling, "claude-opus-4-1-20250805"),
+			convey.ShouldEqual,
+			32000,
+		)
+		convey.So(anthropic.ClampMaxTokens(ceiling, 100000), convey.ShouldEqual, 32000)
+		convey.So(anthropic.ClampMaxTokens(ceiling, 1000), convey.ShouldEqual, 1000)
+	})
+}
+
 func TestOpenAIConvertRequest_DefaultMaxTokensForSonnet4(t *testing.T) {
 	convey.Convey("OpenAIConvertRequest default max_tokens for Sonnet 4", t, func() {
 		m := &meta.Meta{
@@ -232,6 +280,86 @@ func TestOpenAIConvertRequest_DisableAutoImageURLToBase64(t *testing.T) {
 	require.Equal(t, "https://example.com/test.png", claudeReq.Messages[0].Content[0].Source.URL)
 }
 
+func TestOpenAIConvertRequest_SystemParts(t *testing.T) {
+	m := meta.NewMeta(
+		nil,
+		mode.ChatCompletions,
+		"claude-sonnet-4-20250514",
+		model.ModelConfig{},
+	)
+
+	data := []byte(`{
+		"model": "claude-sonnet-4-20250514",
+		"messages": [
+			{"role": "system", "content": [
+				{"type": "text", "text": "You are a helpful assistant."},
+				{"type": "text", "text": "Answer in English."}
+			], "cache_control": {"type": "ephemeral"}},
+			{"role": "user", "content": "hello"},
+			{"role": "system", "content": "Be brief."}
+		]
+	}`)
+
+	req, err := http.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"http://localhost/v1/chat/completions",
+		bytes.NewReader(data),
+	)
+	require.NoError(t, err)
+
+	claudeReq, err := anthropic.OpenAIConvertRequest(m, req)
+	require.NoError(t, err)
+	require.Len(t, claudeReq.System, 3)
+	assert.Equal(t, "You are a helpful assistant.", claudeReq.System[0].Text)
+	assert.Nil(t, claudeReq.System[0].CacheControl)
+	assert.Equal(t, "Answer in English.", claudeReq.System[1].Text)
+	assert.NotNil(t, claudeReq.System[1].CacheControl)
+	assert.Equal(t, "Be brief.", claudeReq.System[2].Text)
+	require.Len(t, claudeReq.Messages, 1)
+}
+
+func TestOpenAIConvertRequest_RejectsInputAudio(t *testing.T) {
+	m := meta.NewMeta(
+		&model.Channel{},
+		mode.ChatCompletions,
+		"claude-sonnet-4-20250514",
+		model.ModelConfig{},
+	)
+
+	reqBody := relaymodel.GeneralOpenAIRequest{
+		Model: "claude-sonnet-4-20250514",
+		Messages: []relaymodel.Message{
+			{
+				Role: "user",
+				Content: []relaymodel.MessageContent{
+					{
+						Type: relaymodel.ContentTypeInputAudio,
+						InputAudio: &relaymodel.InputAudio{
+							Data:   "UklGRg==",
+							Format: "wav",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	data, err := sonic.Marshal(reqBody)
+	require.NoError(t, err)
+
+	req, err := http.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"http://localhost/v1/chat/completions",
+		bytes.NewBuffer(data),
+	)
+	require.NoError(t, err)
+
+	_, err = anthropic.OpenAIConvertRequest(m, req)
+	require.ErrorIs(t, err, adaptor.ErrInputAudioNotSupported)
+}
+
 func TestOpenAIConvertRequest_KeepsImageURLWhenAutoBase64Fails(t *testing.T) {
 	m := meta.NewMeta(
 		&model.Channel{},
@@ -609,3 +737,513 @@ func TestOpenAIConvertRequest_UsesOriginModelNameFirst(t *testing.T) {
 	require.NotNil(t, claudeReq.OutputConfig.Effort)
 	assert.Equal(t, "low", *claudeReq.OutputConfig.Effort)
 }
+
+func TestOpenAIConvertRequest_ServerTools(t *testing.T) {
+	convert := func(t *testing.T, m *meta.Meta, body string) *relaymodel.ClaudeRequest {
+		t.Helper()
+
+		req, err := http.NewRequestWithContext(
+			t.Context(),
+			http.MethodPost,
+			"http://localhost/v1/chat/completions",
+			bytes.NewBufferString(body),
+		)
+		require.NoError(t, err)
+
+		claudeReq, err := anthropic.OpenAIConvertRequest(m, req)
+		require.NoError(t, err)
+
+		return claudeReq
+	}
+
+	t.Run("web_search_options adds web search tool", func(t *testing.T) {
+		m := meta.NewMeta(nil, mode.ChatCompletions, "claude-sonnet-4-20250514", model.ModelConfig{})
+
+		claudeReq := convert(t, m, `{
+			"model": "claude-sonnet-4-20250514",
+			"messages": [{"role": "user", "content": "news today"}],
+			"web_search_options": {
+				"search_context_size": "low",
+				"user_location": {
+					"type": "approximate",
+					"approximate": {"city": "Paris", "country": "FR"}
+				}
+			}
+		}`)
+
+		require.Len(t, claudeReq.Tools, 1)
+		assert.Equal(t, relaymodel.ClaudeToolTypeWebSearch, claudeReq.Tools[0].Type)
+		assert.Equal(t, relaymodel.ClaudeToolNameWebSearch, claudeReq.Tools[0].Name)
+		assert.Equal(t, 1, claudeReq.Tools[0].MaxUses)
+		require.NotNil(t, claudeReq.Tools[0].UserLocation)
+		assert.Equal(t, "approximate", claudeReq.Tools[0].UserLocation.Type)
+		assert.Equal(t, "Paris", claudeReq.Tools[0].UserLocation.City)
+		assert.Equal(t, "FR", claudeReq.Tools[0].UserLocation.Country)
+	})
+
+	t.Run("web_search_options disabled", func(t *testing.T) {
+		m := meta.NewMeta(nil, mode.ChatCompletions, "claude-sonnet-4-20250514", model.ModelConfig{})
+
+		claudeReq := convert(t, m, `{
+			"model": "claude-sonnet-4-20250514",
+			"messages": [{"role": "user", "content": "hello"}],
+			"web_search_options": {"enable": false}
+		}`)
+
+		assert.Empty(t, claudeReq.Tools)
+	})
+
+	t.Run("built-in tools map to server tools", func(t *testing.T) {
+		m := meta.NewMeta(nil, mode.ChatCompletions, "claude-sonnet-4-20250514", model.ModelConfig{})
+
+		claudeReq := convert(t, m, `{
+			"model": "claude-sonnet-4-20250514",
+			"messages": [{"role": "user", "content": "hello"}],
+			"web_search_options": {},
+			"tools": [
+				{"type": "web_search_preview"},
+				{"type": "code_interpreter"}
+			]
+		}`)
+
+		require.Len(t, claudeReq.Tools, 2)
+		assert.Equal(t, relaymodel.ClaudeToolTypeWebSearch, claudeReq.Tools[0].Type)
+		assert.Equal(t, relaymodel.ClaudeToolNameWebSearch, claudeReq.Tools[0].Name)
+		assert.Equal(t, relaymodel.ClaudeToolTypeCodeExecution, claudeReq.Tools[1].Type)
+		assert.Equal(t, relaymodel.ClaudeToolNameCodeExecution, claudeReq.Tools[1].Name)
+	})
+}
+
+func TestResponse2OpenAI_WebSearchCitations(t *testing.T) {
+	m := &meta.Meta{
+		OriginModel: "claude-sonnet-4-20250514",
+	}
+
+	data := []byte(`{
+		"id": "msg_123",
+		"type": "message",
+		"role": "assistant",
+		"model": "claude-sonnet-4-20250514",
+		"content": [
+			{
+				"type": "server_tool_use",
+				"id": "srvtoolu_1",
+				"name": "web_search",
+				"input": {"query": "weather"}
+			},
+			{
+				"type": "web_search_tool_result",
+				"tool_use_id": "srvtoolu_1",
+				"content": []
+			},
+			{
+				"type": "text",
+				"text": "Résumé: "
+			},
+			{
+				"type": "text",
+				"text": "it is sunny",
+				"citations": [
+					{
+						"type": "web_search_result_location",
+						"url": "https://example.com/weather",
+						"title": "Weather",
+						"cited_text": "sunny",
+						"encrypted_index": "abc"
+					}
+				]
+			}
+		],
+		"usage": {
+			"input_tokens": 10,
+			"output_tokens": 20
+		}
+	}`)
+
+	resp, err := anthropic.Response2OpenAI(m, data)
+	require.NoError(t, err)
+
+	message := resp.Choices[0].Message
+	assert.Equal(t, "Résumé: it is sunny", message.Content)
+	assert.Empty(t, message.ToolCalls)
+	require.Len(t, message.Annotations, 1)
+	assert.Equal(t, relaymodel.AnnotationTypeURLCitation, message.Annotations[0].Type)
+	require.NotNil(t, message.Annotations[0].URLCitation)
+	assert.Equal(t, "https://example.com/weather", message.Annotations[0].URLCitation.URL)
+	assert.Equal(t, 8, message.Annotations[0].URLCitation.StartIndex)
+	assert.Equal(t, 19, message.Annotations[0].URLCitation.EndIndex)
+	assert.Equal(t, int64(1), resp.Usage.WebSearchCount)
+}
+
+func TestStreamResponse2OpenAI_WebSearchCitations(t *testing.T) {
+	streamState := anthropic.NewStreamState()
+	m := &meta.Meta{
+		OriginModel: "claude-sonnet-4-20250514",
+	}
+
+	events := []string{
+		`{"type":"content_block_start","index":0,"content_block":{"type":"server_tool_use","id":"srvtoolu_1","name":"web_search","input":{}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"query\":\"weather\"}"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Hi. "}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"citations_delta","citation":{"type":"web_search_result_location","url":"https://example.com","title":"Example","cited_text":"sunny"}}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"sunny"}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":10}}`,
+	}
+
+	var (
+		toolCalls   []relaymodel.ToolCall
+		annotations []relaymodel.Annotation
+		usage       *relaymodel.ChatUsage
+	)
+
+	for _, event := range events {
+		resp, err := streamState.StreamResponse2OpenAI(m, []byte(event))
+		require.NoError(t, err)
+
+		if resp == nil {
+			continue
+		}
+
+		if resp.Usage != nil {
+			usage = resp.Usage
+		}
+
+		for _, choice := range resp.Choices {
+			toolCalls = append(toolCalls, choice.Delta.ToolCalls...)
+			annotations = append(annotations, choice.Delta.Annotations...)
+		}
+	}
+
+	assert.Empty(t, toolCalls)
+	require.Len(t, annotations, 1)
+	assert.Equal(t, "https://example.com", annotations[0].URLCitation.URL)
+	assert.Equal(t, 4, annotations[0].URLCitation.StartIndex)
+	assert.Equal(t, 9, annotations[0].URLCitation.EndIndex)
+	require.NotNil(t, usage)
+	assert.Equal(t, int64(1), usage.WebSearchCount)
+}
+
+func TestOpenAIConvertRequest_ToolChoice(t *testing.T) {
+	convey.Convey("OpenAIConvertRequest tool_choice", t, func() {
+		convert := func(toolChoice any) string {
+			m := &meta.Meta{
+				ActualModel: "claude-sonnet-4-20250514",
+				OriginModel: "claude-sonnet-4-20250514",
+				Mode:        mode.ChatCompletions,
+			}
+
+			reqBody := relaymodel.GeneralOpenAIRequest{
+				Model: "claude-sonnet-4-20250514",
+				Messages: []relaymodel.Message{
+					{Role: "user", Content: "hello"},
+				},
+				Tools: []relaymodel.Tool{
+					{
+						Type:     relaymodel.ToolChoiceTypeFunction,
+						Function: relaymodel.Function{Name: "get_weather"},
+					},
+				},
+				ToolChoice: toolChoice,
+			}
+
+			data, err := sonic.Marshal(reqBody)
+			convey.So(err, convey.ShouldBeNil)
+
+			req, err := http.NewRequestWithContext(
+				t.Context(),
+				http.MethodPost,
+				"http://localhost/v1/chat/completions",
+				bytes.NewBuffer(data),
+			)
+			convey.So(err, convey.ShouldBeNil)
+
+			claudeReq, err := anthropic.OpenAIConvertRequest(m, req)
+			convey.So(err, convey.ShouldBeNil)
+
+			// the function without parameters is sent with an empty object schema
+			convey.So(claudeReq.Tools, convey.ShouldHaveLength, 1)
+			convey.So(claudeReq.Tools[0].InputSchema, convey.ShouldNotBeNil)
+			convey.So(claudeReq.Tools[0].InputSchema.Type, convey.ShouldEqual, "object")
+
+			marshaled, err := json.Marshal(claudeReq.ToolChoice)
+			convey.So(err, convey.ShouldBeNil)
+
+			return string(marshaled)
+		}
+
+		convey.So(
+			convert(map[string]any{
+				"type":     "function",
+				"function": map[string]any{"name": "get_weather"},
+			}),
+			convey.ShouldEqual,
+			`{"type":"tool","name":"get_weather"}`,
+		)
+		convey.So(convert("required"), convey.ShouldEqual, `{"type":"any"}`)
+		convey.So(convert("none"), convey.ShouldEqual, `{"type":"none"}`)
+		convey.So(convert("auto"), convey.ShouldEqual, `{"type":"auto"}`)
+	})
+}
+
+func TestOpenAIConvertRequest_LongOutputBeta(t *testing.T) {
+	newRequest := func(t *testing.T, maxTokens int) *http.Request {
+		t.Helper()
+
+		data, err := sonic.Marshal(relaymodel.GeneralOpenAIRequest{
+			Model:     "claude-3-7-sonnet-20250219",
+			MaxTokens: maxTokens,
+			Messages: []relaymodel.Message{
+				{Role: relaymodel.RoleUser, Content: "hello"},
+			},
+		})
+		require.NoError(t, err)
+
+		req, err := http.NewRequestWithContext(
+			t.Context(),
+			http.MethodPost,
+			"http://localhost/v1/chat/completions",
+			bytes.NewBuffer(data),
+		)
+		require.NoError(t, err)
+
+		return req
+	}
+
+	t.Run("within the standard ceiling", func(t *testing.T) {
+		m := meta.NewMeta(
+			nil,
+			mode.ChatCompletions,
+			"claude-3-7-sonnet-20250219",
+			model.ModelConfig{},
+		)
+
+		claudeReq, err := anthropic.OpenAIConvertRequest(m, newRequest(t, 64000))
+		require.NoError(t, err)
+		assert.Equal(t, 64000, claudeReq.MaxTokens)
+		assert.Empty(t, anthropic.LongOutputBetaFromMeta(m))
+	})
+
+	t.Run("over the standard ceiling attaches the beta", func(t *testing.T) {
+		m := meta.NewMeta(
+			nil,
+			mode.ChatCompletions,
+			"claude-3-7-sonnet-20250219",
+			model.ModelConfig{},
+		)
+
+		claudeReq, err := anthropic.OpenAIConvertRequest(m, newRequest(t, 100000))
+		require.NoError(t, err)
+		assert.Equal(t, 100000, claudeReq.MaxTokens)
+		assert.Equal(t, anthropic.LongOutputBeta128k, anthropic.LongOutputBetaFromMeta(m))
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequestWithContext(
+			t.Context(),
+			http.MethodPost,
+			"/v1/chat/completions",
+			nil,
+		)
+		c.Request.Header.Set(anthropic.AnthropicBeta, "token-efficient-tools-2025-02-19")
+
+		req := httptest.NewRequestWithContext(
+			t.Context(),
+			http.MethodPost,
+			"https://api.anthropic.com/v1/messages",
+			nil,
+		)
+		require.NoError(t, (&anthropic.Adaptor{}).SetupRequestHeader(m, nil, c, req))
+		assert.Equal(
+			t,
+			"token-efficient-tools-2025-02-19,"+anthropic.LongOutputBeta128k,
+			req.Header.Get(anthropic.AnthropicBeta),
+		)
+	})
+
+	t.Run("over the extended ceiling is clamped", func(t *testing.T) {
+		m := meta.NewMeta(
+			nil,
+			mode.ChatCompletions,
+			"claude-3-7-sonnet-20250219",
+			model.ModelConfig{},
+		)
+
+		claudeReq, err := anthropic.OpenAIConvertRequest(m, newRequest(t, 200000))
+		require.NoError(t, err)
+		assert.Equal(t, 128000, claudeReq.MaxTokens)
+		assert.Equal(t, anthropic.LongOutputBeta128k, anthropic.LongOutputBetaFromMeta(m))
+	})
+
+	t.Run("disabled by the channel", func(t *testing.T) {
+		m := meta.NewMeta(
+			&model.Channel{
+				Configs: model.ChannelConfigs{
+					"disable_long_output_beta": true,
+				},
+			},
+			mode.ChatCompletions,
+			"claude-3-7-sonnet-20250219",
+			model.ModelConfig{},
+		)
+
+		claudeReq, err := anthropic.OpenAIConvertRequest(m, newRequest(t, 100000))
+		require.NoError(t, err)
+		assert.Equal(t, 64000, claudeReq.MaxTokens)
+		assert.Empty(t, anthropic.LongOutputBetaFromMeta(m))
+	})
+
+	t.Run("from the model config", func(t *testing.T) {
+		m := meta.NewMeta(
+			nil,
+			mode.ChatCompletions,
+			"claude-next",
+			model.ModelConfig{
+				Config: model.NewModelConfig(
+					model.WithModelConfigMaxOutputTokens(256000),
+					model.WithModelConfigLongOutputBeta("output-256k-2026-01-01", 128000),
+				),
+			},
+		)
+
+		claudeReq, err := anthropic.OpenAIConvertRequest(m, newRequest(t, 200000))
+		require.NoError(t, err)
+		assert.Equal(t, 200000, claudeReq.MaxTokens)
+		assert.Equal(t, "output-256k-2026-01-01", anthropic.LongOutputBetaFromMeta(m))
+	})
+}
+
+func TestOpenAIConvertRequest_ThinkingBudgetWithinLongOutputCeiling(t *testing.T) {
+	m := meta.NewMeta(
+		&model.Channel{
+			Configs: model.ChannelConfigs{
+				"disable_long_output_beta": true,
+			},
+		},
+		mode.ChatCompletions,
+		"claude-3-7-sonnet-20250219",
+		model.ModelConfig{
+			Config: model.NewModelConfig(
+				model.WithModelConfigMaxOutputTokens(32000),
+				model.WithModelConfigLongOutputBeta("output-32k-2026-01-01", 16000),
+			),
+		},
+	)
+
+	// the xhigh budget needs more max_tokens than the standard ceiling allows
+	data, err := sonic.Marshal(relaymodel.ClaudeOpenAIRequest{
+		Model:           "claude-3-7-sonnet-20250219",
+		MaxTokens:       1000,
+		ReasoningEffort: new("xhigh"),
+		Messages: []*relaymodel.ClaudeOpenaiMessage{
+			{
+				Message: relaymodel.Message{
+					Role:    relaymodel.RoleUser,
+					Content: "hello",
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	req, err := http.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"http://localhost/v1/chat/completions",
+		bytes.NewBuffer(data),
+	)
+	require.NoError(t, err)
+
+	claudeReq, err := anthropic.OpenAIConvertRequest(m, req)
+	require.NoError(t, err)
+	require.NotNil(t, claudeReq.Thinking)
+	assert.Equal(t, relaymodel.ClaudeThinkingTypeEnabled, claudeReq.Thinking.Type)
+	assert.Equal(t, 16000, claudeReq.MaxTokens)
+	assert.Equal(t, 8000, claudeReq.Thinking.BudgetTokens)
+	assert.Less(t, claudeReq.Thinking.BudgetTokens, claudeReq.MaxTokens)
+	assert.Empty(t, anthropic.LongOutputBetaFromMeta(m))
+}
+
+func TestOpenAIConvertRequest_SignedThinkingBlocks(t *testing.T) {
+	newRequest := func(t *testing.T, assistant relaymodel.Message) *http.Request {
+		t.Helper()
+
+		reqBody := relaymodel.ClaudeOpenAIRequest{
+			Model:           "claude-3-7-sonnet-20250219",
+			MaxTokens:       4096,
+			ReasoningEffort: new("low"),
+			Messages: []*relaymodel.ClaudeOpenaiMessage{
+				{Message: relaymodel.Message{Role: relaymodel.RoleUser, Content: "weather?"}},
+				{Message: assistant},
+				{Message: relaymodel.Message{
+					Role:       relaymodel.RoleTool,
+					Content:    "sunny",
+					ToolCallID: "toolu_1",
+				}},
+			},
+		}
+
+		data, err := sonic.Marshal(reqBody)
+		require.NoError(t, err)
+
+		req, err := http.NewRequestWithContext(
+			t.Context(),
+			http.MethodPost,
+			"http://localhost/v1/chat/completions",
+			bytes.NewBuffer(data),
+		)
+		require.NoError(t, err)
+
+		return req
+	}
+
+	m := &meta.Meta{
+		ActualModel: "claude-3-7-sonnet-20250219",
+		OriginModel: "claude-3-7-sonnet-20250219",
+		Mode:        mode.ChatCompletions,
+	}
+
+	toolCalls := []relaymodel.ToolCall{{
+		ID:       "toolu_1",
+		Type:     "function",
+		Function: relaymodel.Function{Name: "weather", Arguments: `{"city":"Paris"}`},
+	}}
+
+	t.Run("signed", func(t *testing.T) {
+		claudeReq, err := anthropic.OpenAIConvertRequest(m, newRequest(t, relaymodel.Message{
+			Role:      relaymodel.RoleAssistant,
+			ToolCalls: toolCalls,
+			ThinkingBlocks: []relaymodel.ClaudeContent{
+				{Type: relaymodel.ClaudeContentTypeThinking, Thinking: "Call it.", Signature: "sig_1"},
+				{Type: relaymodel.ClaudeContentTypeRedactedThinking, Data: "encrypted"},
+			},
+		}))
+		require.NoError(t, err)
+		require.NotNil(t, claudeReq.Thinking)
+
+		content := claudeReq.Messages[1].Content
+		require.Len(t, content, 3)
+		assert.Equal(t, relaymodel.ClaudeContentTypeThinking, content[0].Type)
+		assert.Equal(t, "sig_1", content[0].Signature)
+		assert.Equal(t, relaymodel.ClaudeContentTypeRedactedThinking, content[1].Type)
+		assert.Equal(t, "encrypted", content[1].Data)
+		assert.Equal(t, relaymodel.ClaudeContentTypeToolUse, content[2].Type)
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		claudeReq, err := anthropic.OpenAIConvertRequest(m, newRequest(t, relaymodel.Message{
+			Role:             relaymodel.RoleAssistant,
+			ReasoningContent: "Call it.",
+			ToolCalls:        toolCalls,
+		}))
+		require.NoError(t, err)
+		assert.Nil(t, claudeReq.Thinking)
+
+		content := claudeReq.Messages[1].Content
+		require.Len(t, content, 1)
+		assert.Equal(t, relaymodel.ClaudeContentTypeToolUse, content[0].Type)
+	})
+}
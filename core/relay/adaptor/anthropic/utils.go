@@ -125,6 +125,7 @@ func parseClaudeFamilyVersion(model string) (family string, major, minor int, ok
 
 func normalizeClaudeThinking(
 	model string,
+	maxTokensCeiling int,
 	maxTokens *int,
 	thinking **relaymodel.ClaudeThinking,
 	outputConfig **relaymodel.ClaudeOutputConfig,
@@ -170,7 +171,7 @@ func normalizeClaudeThinking(
 				)
 			}
 
-			adjustThinkingBudgetTokens(maxTokens, &currentThinking.BudgetTokens)
+			adjustThinkingBudgetTokens(maxTokens, &currentThinking.BudgetTokens, maxTokensCeiling)
 
 			if outputConfig != nil {
 				*outputConfig = nil
@@ -207,7 +208,7 @@ func normalizeClaudeThinking(
 		}
 
 		currentThinking.Type = relaymodel.ClaudeThinkingTypeEnabled
-		adjustThinkingBudgetTokens(maxTokens, &currentThinking.BudgetTokens)
+		adjustThinkingBudgetTokens(maxTokens, &currentThinking.BudgetTokens, maxTokensCeiling)
 
 		if outputConfig != nil {
 			*outputConfig = nil
@@ -230,7 +231,7 @@ func normalizeClaudeThinking(
 		}
 
 		currentThinking.Type = relaymodel.ClaudeThinkingTypeEnabled
-		adjustThinkingBudgetTokens(maxTokens, &currentThinking.BudgetTokens)
+		adjustThinkingBudgetTokens(maxTokens, &currentThinking.BudgetTokens, maxTokensCeiling)
 
 		if outputConfig != nil {
 			*outputConfig = nil
@@ -240,18 +241,17 @@ func normalizeClaudeThinking(
 
 // adjustThinkingBudgetTokens adjusts thinking.budget_tokens to ensure it's less than max_tokens
 // according to the following rules:
-// 1. If budget_tokens is 0 or >= max_tokens, set it to max_tokens / 2
-// 2. If budget_tokens < 1024, set it to 1024
-// 3. If max_tokens is still <= budget_tokens, set max_tokens to budget_tokens * 2
-func adjustThinkingBudgetTokens(maxTokens, budgetTokens *int) {
+// 1. If budget_tokens is below 1024, set it to 1024
+// 2. If max_tokens is over the ceiling of the model, set it to the ceiling
+// 3. If max_tokens is still <= budget_tokens, set max_tokens to budget_tokens + 1, at least 2048
+// 4. If that is over the ceiling, set max_tokens to the ceiling and budget_tokens to half of it
+//
+// a zero ceiling means the ceiling of the model is unknown
+func adjustThinkingBudgetTokens(maxTokens, budgetTokens *int, ceiling int) {
 	if budgetTokens == nil {
 		return
 	}
 
-	if *budgetTokens == 0 {
-		*budgetTokens = 1024
-	}
-
 	if *budgetTokens < 1024 {
 		*budgetTokens = 1024
 	}
@@ -260,7 +260,18 @@ func adjustThinkingBudgetTokens(maxTokens, budgetTokens *int) {
 		return
 	}
 
-	if *maxTokens <= *budgetTokens {
-		*maxTokens = max(*budgetTokens+1, 2048)
+	if ceiling > 0 && *maxTokens > ceiling {
+		*maxTokens = ceiling
+	}
+
+	if *maxTokens > *budgetTokens {
+		return
+	}
+
+	*maxTokens = max(*budgetTokens+1, 2048)
+
+	if ceiling > 0 && *maxTokens > ceiling {
+		*maxTokens = ceiling
+		*budgetTokens = max(ceiling/2, 1024)
 	}
 }
@@ -37,6 +37,12 @@ func (a *Adaptor) DefaultBaseURL() string {
 	return baseURL
 }
 
+// NativeMode reports the claude messages are relayed to the anthropic
+// compatible endpoint as they are
+func (a *Adaptor) NativeMode(mt *meta.Meta) bool {
+	return adaptor.ModeFromMeta(mt) == mode.Anthropic
+}
+
 func (a *Adaptor) SupportMode(mt *meta.Meta) bool {
 	m := adaptor.ModeFromMeta(mt)
 
@@ -25,6 +25,11 @@ func (a *Adaptor) DefaultBaseURL() string {
 	return ""
 }
 
+// NativeMode reports the claude messages are relayed to bedrock as they are
+func (a *Adaptor) NativeMode(mt *meta.Meta) bool {
+	return adaptor.ModeFromMeta(mt) == mode.Anthropic
+}
+
 func (a *Adaptor) SupportMode(mt *meta.Meta) bool {
 	m := adaptor.ModeFromMeta(mt)
 
@@ -3,6 +3,7 @@ package aws
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -106,6 +107,11 @@ func handleChatCompletionsRequest(meta *meta.Meta, request *http.Request) ([]byt
 		)
 	}
 
+	if beta := anthropic.LongOutputBetaFromMeta(meta); beta != "" &&
+		!slices.Contains(req.AnthropicBeta, beta) {
+		req.AnthropicBeta = append(req.AnthropicBeta, beta)
+	}
+
 	return sonic.Marshal(req)
 }
 
@@ -115,16 +121,23 @@ func handleAnthropicRequest(meta *meta.Meta, request *http.Request) ([]byte, err
 			return err
 		}
 
-		if betas := request.Header.Get(anthropic.AnthropicBeta); betas != "" {
-			_, _ = node.SetAny(
-				"anthropic_beta",
-				fixBetas(
-					anthropic.ResolveModelName(meta.OriginModel, meta.ActualModel),
-					strings.Split(betas, ","),
-				),
+		var betas []string
+		if rawBetas := request.Header.Get(anthropic.AnthropicBeta); rawBetas != "" {
+			betas = fixBetas(
+				anthropic.ResolveModelName(meta.OriginModel, meta.ActualModel),
+				strings.Split(rawBetas, ","),
 			)
 		}
 
+		if beta := anthropic.LongOutputBetaFromMeta(meta); beta != "" &&
+			!slices.Contains(betas, beta) {
+			betas = append(betas, beta)
+		}
+
+		if len(betas) > 0 {
+			_, _ = node.SetAny("anthropic_beta", betas)
+		}
+
 		if strings.Contains(
 			strings.ToLower(anthropic.ResolveModelName(meta.OriginModel, meta.ActualModel)),
 			"4-6",
@@ -192,7 +192,12 @@ func (a *Adaptor) fetchAsyncUsageObject(
 		return nil, errors.New("upstream id is empty")
 	}
 
-	token, apiVersion, err := GetTokenAndAPIVersion(channel.Key)
+	key, err := channel.GetKey()
+	if err != nil {
+		return nil, err
+	}
+
+	token, apiVersion, err := GetTokenAndAPIVersion(key)
 	if err != nil {
 		return nil, fmt.Errorf("parse azure key: %w", err)
 	}
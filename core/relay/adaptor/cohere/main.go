@@ -203,10 +203,11 @@ func StreamHandler(
 
 	if err := scanner.Err(); err != nil {
 		log.Error("error reading stream: " + err.Error())
+		render.OpenaiStreamError(c, err)
+	} else {
+		render.OpenaiDone(c)
 	}
 
-	render.OpenaiDone(c)
-
 	return adaptor.DoResponseResult{Usage: usage.ToModelUsage()}, nil
 }
 
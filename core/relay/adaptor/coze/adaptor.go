@@ -148,5 +148,8 @@ func (a *Adaptor) Metadata() adaptor.Metadata {
 		Readme:  "Coze bot chat endpoint\nOnly chat completions mode is supported\nActual model should be a bot ID; the `bot-` prefix is stripped before upstream requests\nKey format: `token|user_id`",
 		KeyHelp: "token|user_id",
 		Models:  ModelList,
+		Limitations: []string{
+			"the actual model must be a bot ID",
+		},
 	}
 }
@@ -156,10 +156,11 @@ func StreamHandler(
 
 	if err := scanner.Err(); err != nil {
 		log.Error("error reading stream: " + err.Error())
+		render.OpenaiStreamError(c, err)
+	} else {
+		render.OpenaiDone(c)
 	}
 
-	render.OpenaiDone(c)
-
 	return adaptor.DoResponseResult{Usage: openai.ResponseText2Usage(
 		responseText.String(),
 		meta.ActualModel,
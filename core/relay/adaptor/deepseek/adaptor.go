@@ -37,6 +37,12 @@ func (a *Adaptor) DefaultBaseURL() string {
 	return baseURL
 }
 
+// NativeMode reports the claude messages are relayed to the anthropic
+// compatible endpoint as they are
+func (a *Adaptor) NativeMode(mt *meta.Meta) bool {
+	return adaptor.ModeFromMeta(mt) == mode.Anthropic
+}
+
 func (a *Adaptor) SupportMode(mt *meta.Meta) bool {
 	m := adaptor.ModeFromMeta(mt)
 
@@ -132,20 +138,23 @@ func (a *Adaptor) DoResponse(
 	c *gin.Context,
 	resp *http.Response,
 ) (adaptor.DoResponseResult, adaptor.Error) {
-	if meta.Mode != mode.Anthropic {
-		return a.Adaptor.DoResponse(meta, store, c, resp)
-	}
+	switch meta.Mode {
+	case mode.ChatCompletions, mode.Completions:
+		return chatDoResponse(meta, c, resp)
+	case mode.Anthropic:
+		if utils.IsStreamResponse(resp) {
+			return anthropic.StreamHandler(meta, c, resp)
+		}
 
-	if utils.IsStreamResponse(resp) {
-		return anthropic.StreamHandler(meta, c, resp)
+		return anthropic.Handler(meta, c, resp)
+	default:
+		return a.Adaptor.DoResponse(meta, store, c, resp)
 	}
-
-	return anthropic.Handler(meta, c, resp)
 }
 
 func (a *Adaptor) Metadata() adaptor.Metadata {
 	return adaptor.Metadata{
-		Readme: "DeepSeek API\nOpenAI-compatible chat and completions endpoints\nSupports native Anthropic-compatible endpoint and Gemini-compatible request conversion",
+		Readme: "DeepSeek API\nOpenAI-compatible chat and completions endpoints\nSupports native Anthropic-compatible endpoint and Gemini-compatible request conversion\nPasses through `reasoning_content` and maps `prompt_cache_hit_tokens` to cached tokens for cache pricing",
 		Models: ModelList,
 	}
 }
@@ -21,12 +21,17 @@ func (a *Adaptor) GetBalance(channel *model.Channel) (float64, error) {
 
 	url := u + "/user/balance"
 
+	key, err := channel.GetKey()
+	if err != nil {
+		return 0, err
+	}
+
 	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
 	if err != nil {
 		return 0, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+channel.Key)
+	req.Header.Set("Authorization", "Bearer "+key)
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
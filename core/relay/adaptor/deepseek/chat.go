@@ -0,0 +1,126 @@
+package deepseek
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/bytedance/sonic/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/utils"
+)
+
+// patchCacheUsageFromNode copies usage.prompt_cache_hit_tokens to
+// usage.prompt_tokens_details.cached_tokens, so the cache hit tokens are
+// billed with the cached price, the miss tokens are the rest of the prompt tokens
+func patchCacheUsageFromNode(node *ast.Node) error {
+	usageNode := node.Get("usage")
+	if usageNode.Check() != nil || usageNode.TypeSafe() != ast.V_OBJECT {
+		return nil
+	}
+
+	hitTokens, err := usageNode.Get("prompt_cache_hit_tokens").Int64()
+	if err != nil {
+		if errors.Is(err, ast.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	cachedTokens := ast.NewNumber(strconv.FormatInt(hitTokens, 10))
+
+	detailsNode := usageNode.Get("prompt_tokens_details")
+	if detailsNode.Check() != nil || detailsNode.TypeSafe() != ast.V_OBJECT {
+		_, err = usageNode.Set("prompt_tokens_details", ast.NewObject([]ast.Pair{
+			ast.NewPair("cached_tokens", cachedTokens),
+		}))
+
+		return err
+	}
+
+	if cached, err := detailsNode.Get("cached_tokens").Int64(); err == nil && cached != 0 {
+		return nil
+	}
+
+	_, err = detailsNode.Set("cached_tokens", cachedTokens)
+
+	return err
+}
+
+// patchReasoningContentFromNode rewrites the reasoning field returned by some
+// deepseek compatible deployments to reasoning_content, the field is the
+// message for non stream responses and the delta for stream responses
+func patchReasoningContentFromNode(node *ast.Node, field string) error {
+	choicesNode := node.Get("choices")
+	if choicesNode.Check() != nil || choicesNode.TypeSafe() != ast.V_ARRAY {
+		return nil
+	}
+
+	choices, err := choicesNode.ArrayUseNode()
+	if err != nil {
+		return err
+	}
+
+	for index, choice := range choices {
+		messageNode := choice.Get(field)
+		if messageNode.Check() != nil {
+			continue
+		}
+
+		if messageNode.Get("reasoning_content").Exists() {
+			continue
+		}
+
+		reasoning, err := messageNode.Get("reasoning").String()
+		if err != nil {
+			continue
+		}
+
+		if _, err := messageNode.Set("reasoning_content", ast.NewString(reasoning)); err != nil {
+			return err
+		}
+
+		if _, err := messageNode.Unset("reasoning"); err != nil {
+			return err
+		}
+
+		if _, err := choicesNode.SetByIndex(index, choice); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func streamPreHandler(_ *meta.Meta, node *ast.Node) error {
+	if err := patchReasoningContentFromNode(node, "delta"); err != nil {
+		return err
+	}
+
+	return patchCacheUsageFromNode(node)
+}
+
+func handlerPreHandler(_ *meta.Meta, node *ast.Node) error {
+	if err := patchReasoningContentFromNode(node, "message"); err != nil {
+		return err
+	}
+
+	return patchCacheUsageFromNode(node)
+}
+
+// chatDoResponse passes through reasoning_content and surfaces the prompt
+// cache metrics of chat completions and completions responses
+func chatDoResponse(
+	meta *meta.Meta,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if utils.IsStreamResponse(resp) {
+		return openai.StreamHandler(meta, c, resp, streamPreHandler)
+	}
+
+	return openai.Handler(meta, c, resp, handlerPreHandler)
+}
@@ -0,0 +1,133 @@
+//nolint:testpackage
+package deepseek
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	coremodel "github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatchCacheUsageFromNode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		input  string
+		cached int64
+	}{
+		{
+			name:   "without details",
+			input:  `{"usage":{"prompt_tokens":100,"prompt_cache_hit_tokens":60,"prompt_cache_miss_tokens":40}}`,
+			cached: 60,
+		},
+		{
+			name:   "with empty details",
+			input:  `{"usage":{"prompt_tokens":100,"prompt_cache_hit_tokens":60,"prompt_tokens_details":{"cached_tokens":0}}}`,
+			cached: 60,
+		},
+		{
+			name:   "keeps upstream cached tokens",
+			input:  `{"usage":{"prompt_tokens":100,"prompt_cache_hit_tokens":60,"prompt_tokens_details":{"cached_tokens":50}}}`,
+			cached: 50,
+		},
+		{
+			name:   "no cache metrics",
+			input:  `{"usage":{"prompt_tokens":100}}`,
+			cached: -1,
+		},
+		{
+			name:   "no usage",
+			input:  `{"choices":[]}`,
+			cached: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			node, err := common.GetJSONNodeNoCopy([]byte(tt.input))
+			require.NoError(t, err)
+			require.NoError(t, patchCacheUsageFromNode(&node))
+
+			cached, err := node.GetByPath("usage", "prompt_tokens_details", "cached_tokens").Int64()
+			if tt.cached < 0 {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.cached, cached)
+		})
+	}
+}
+
+func TestChatDoResponse(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	t.Run("stream", func(t *testing.T) {
+		t.Parallel()
+
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+		m := meta.NewMeta(nil, mode.ChatCompletions, "deepseek-reasoner", coremodel.ModelConfig{})
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body: io.NopCloser(strings.NewReader(
+				"data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"reasoning_content\":\"think\"}}]}\n\n" +
+					"data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"reasoning\":\"more\"}}]}\n\n" +
+					"data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"answer\"},\"finish_reason\":\"stop\"}]," +
+					"\"usage\":{\"prompt_tokens\":100,\"completion_tokens\":10,\"total_tokens\":110," +
+					"\"prompt_cache_hit_tokens\":64,\"prompt_cache_miss_tokens\":36}}\n\n" +
+					"data: [DONE]\n\n",
+			)),
+		}
+
+		result, err := chatDoResponse(m, c, resp)
+		require.Nil(t, err)
+		assert.Equal(t, coremodel.ZeroNullInt64(100), result.Usage.InputTokens)
+		assert.Equal(t, coremodel.ZeroNullInt64(64), result.Usage.CachedTokens)
+
+		body := recorder.Body.String()
+		assert.Contains(t, body, `"reasoning_content":"think"`)
+		assert.Contains(t, body, `"reasoning_content":"more"`)
+		assert.Contains(t, body, `"cached_tokens":64`)
+	})
+
+	t.Run("non stream", func(t *testing.T) {
+		t.Parallel()
+
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+		m := meta.NewMeta(nil, mode.ChatCompletions, "deepseek-reasoner", coremodel.ModelConfig{})
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body: io.NopCloser(strings.NewReader(
+				`{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"answer","reasoning_content":"think"},"finish_reason":"stop"}],` +
+					`"usage":{"prompt_tokens":100,"completion_tokens":10,"total_tokens":110,"prompt_cache_hit_tokens":32,"prompt_cache_miss_tokens":68}}`,
+			)),
+		}
+
+		result, err := chatDoResponse(m, c, resp)
+		require.Nil(t, err)
+		assert.Equal(t, coremodel.ZeroNullInt64(32), result.Usage.CachedTokens)
+		assert.Contains(t, recorder.Body.String(), `"reasoning_content":"think"`)
+	})
+}
@@ -189,7 +189,12 @@ func (a *Adaptor) fetchVideoTask(
 	}
 
 	if channel != nil {
-		req.Header.Set("Authorization", "Bearer "+channel.Key)
+		key, err := channel.GetKey()
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+key)
 	}
 
 	var (
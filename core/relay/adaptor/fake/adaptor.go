@@ -3,6 +3,7 @@ package fake
 import (
 	"bytes"
 	"io"
+	"math/rand/v2"
 	"net/http"
 	"strconv"
 	"time"
@@ -116,6 +117,10 @@ func (a *Adaptor) DoRequest(
 		time.Sleep(time.Duration(cfg.DelayMS) * time.Millisecond)
 	}
 
+	if cfg.Error.Rate > 0 && rand.Float64() < cfg.Error.Rate {
+		meta.Set("fake_inject_error", true)
+	}
+
 	resp := &http.Response{
 		StatusCode: statusCode,
 		Header: http.Header{
@@ -137,6 +142,10 @@ func (a *Adaptor) DoResponse(
 	_ *http.Response,
 ) (adaptor.DoResponseResult, adaptor.Error) {
 	cfg := a.loadConfig(meta)
+	if meta.GetBool("fake_inject_error") {
+		return adaptor.DoResponseResult{}, injectedError(meta, cfg.Error)
+	}
+
 	usage := buildUsage(cfg)
 	reqCtx := getRequestContext(meta)
 
@@ -174,10 +183,25 @@ func (a *Adaptor) DoResponse(
 	}
 }
 
+func injectedError(meta *meta.Meta, cfg ErrorCfg) adaptor.Error {
+	statusCode := cfg.StatusCode
+	if statusCode <= 0 {
+		statusCode = http.StatusInternalServerError
+	}
+
+	return relaymodel.WrapperErrorWithMessage(
+		meta.Mode,
+		statusCode,
+		firstNonEmpty(cfg.Message, "fake injected error"),
+		relaymodel.WithType(relaymodel.ErrorTypeUpstream),
+		relaymodel.WithCode(firstNonEmpty(cfg.Code, "fake_injected_error")),
+	)
+}
+
 func (a *Adaptor) Metadata() adaptor.Metadata {
 	return adaptor.Metadata{
 		KeyHelp:      "Any non-empty value. The fake adaptor does not call an upstream provider.",
-		Readme:       "Fake adaptor for protocol debugging and integration testing. Supports chat, completions, responses, anthropic, gemini native, embeddings, images generations, and rerank. All outputs are synthesized locally and controlled by channel configs.",
+		Readme:       "Fake adaptor for protocol debugging and integration testing. Supports chat, completions, responses, anthropic, gemini native, embeddings, images generations, and rerank. All outputs are synthesized locally and controlled by channel configs, with optional latency and random error injection, so quota, logging, and routing can be tested without calling a provider.",
 		ConfigSchema: configSchema(),
 		Models: []model.ModelConfig{
 			{Model: "fake-chat", Owner: "fake", Type: mode.ChatCompletions},
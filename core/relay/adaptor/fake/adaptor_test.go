@@ -74,6 +74,89 @@ type fakeRunResult struct {
 
 func TestFakeChannelTypeNameToType(t *testing.T) {
 	assert.Equal(t, int(model.ChannelTypeFake), model.ChannelTypeNameToType("fake"))
+	assert.Equal(t, int(model.ChannelTypeFake), model.ChannelTypeNameToType("mock"))
+	assert.Equal(t, int(model.ChannelTypeFake), model.ChannelTypeNameToType("sandbox"))
+}
+
+func TestFakeAdaptorErrorInjection(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	newMeta := func(configs model.ChannelConfigs) *meta.Meta {
+		return meta.NewMeta(
+			&model.Channel{Type: model.ChannelTypeFake, BaseURL: "https://fake.local/v1", Configs: configs},
+			mode.ChatCompletions,
+			"fake-chat",
+			model.ModelConfig{},
+		)
+	}
+
+	run := func(t *testing.T, m *meta.Meta, stream bool) adaptor.Error {
+		t.Helper()
+
+		bodyBytes, err := json.Marshal(relaymodel.GeneralOpenAIRequest{
+			Model:  "fake-chat",
+			Stream: stream,
+			Messages: []relaymodel.Message{
+				{Role: relaymodel.RoleUser, Content: "hello fake"},
+			},
+		})
+		require.NoError(t, err)
+
+		req := httptest.NewRequestWithContext(
+			t.Context(),
+			http.MethodPost,
+			"/v1/chat/completions",
+			bytes.NewReader(bodyBytes),
+		)
+		req.Header.Set("Content-Type", "application/json")
+
+		a := &fake.Adaptor{}
+		_, err = a.ConvertRequest(m, noopStore{}, req)
+		require.NoError(t, err)
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = req
+
+		resp, err := a.DoRequest(m, noopStore{}, c, req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		_, relayErr := a.DoResponse(m, noopStore{}, c, resp)
+
+		return relayErr
+	}
+
+	t.Run("always", func(t *testing.T) {
+		t.Parallel()
+
+		for _, stream := range []bool{false, true} {
+			relayErr := run(t, newMeta(model.ChannelConfigs{
+				"error": map[string]any{
+					"rate":        1,
+					"status_code": http.StatusTooManyRequests,
+					"message":     "rate limited",
+				},
+			}), stream)
+			require.NotNil(t, relayErr)
+			assert.Equal(t, http.StatusTooManyRequests, relayErr.StatusCode())
+
+			data, err := relayErr.MarshalJSON()
+			require.NoError(t, err)
+			assert.Contains(t, string(data), "rate limited")
+			assert.Contains(t, string(data), "fake_injected_error")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+
+		relayErr := run(t, newMeta(model.ChannelConfigs{
+			"error": map[string]any{"rate": 0},
+		}), false)
+		assert.Nil(t, relayErr)
+	})
 }
 
 func TestFakeAdaptorMetadataSchema(t *testing.T) {
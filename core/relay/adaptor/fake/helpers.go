@@ -630,6 +630,11 @@ func defaultConfig() Config {
 		OpenAPI: OpenAPICfg{
 			SpecVersion: "3.1.0",
 		},
+		Error: ErrorCfg{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "fake injected error",
+			Code:       "fake_injected_error",
+		},
 	}
 }
 
@@ -799,6 +804,21 @@ func configSchema() map[string]any {
 				"title":       "Metadata",
 				"description": "Arbitrary metadata copied into Responses API objects.",
 			},
+			"error": map[string]any{
+				"type":        "object",
+				"title":       "Error Injection",
+				"description": "Randomly returns a synthetic upstream error instead of the fake response, after the delay.",
+				"properties": map[string]any{
+					"rate": map[string]any{
+						"type":        "number",
+						"title":       "Error Rate",
+						"description": "Probability between 0 and 1 of returning the error. 0 disables the injection.",
+					},
+					"status_code": map[string]any{"type": "integer", "title": "Error Status Code"},
+					"message":     map[string]any{"type": "string", "title": "Error Message"},
+					"code":        map[string]any{"type": "string", "title": "Error Code"},
+				},
+			},
 			"openapi": map[string]any{
 				"type":        "object",
 				"title":       "OpenAPI Template",
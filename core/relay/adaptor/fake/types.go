@@ -28,6 +28,7 @@ type Config struct {
 	Anthropic         AnthropicCfg   `json:"anthropic"`
 	Gemini            GeminiCfg      `json:"gemini"`
 	OpenAPI           OpenAPICfg     `json:"openapi"`
+	Error             ErrorCfg       `json:"error"`
 	Metadata          map[string]any `json:"metadata"`
 }
 
@@ -85,6 +86,16 @@ type OpenAPICfg struct {
 	Components  map[string]any `json:"components"`
 }
 
+// ErrorCfg injects upstream errors into the fake responses, streaming
+// requests fail before the stream starts
+type ErrorCfg struct {
+	// Rate is the probability in [0, 1] of returning the error instead of the response
+	Rate       float64 `json:"rate"`
+	StatusCode int     `json:"status_code"`
+	Message    string  `json:"message"`
+	Code       string  `json:"code"`
+}
+
 type requestContext struct {
 	Text                string
 	Model               string
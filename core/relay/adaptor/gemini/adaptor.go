@@ -30,6 +30,12 @@ func (a *Adaptor) DefaultBaseURL() string {
 	return baseURL
 }
 
+// NonRetriableAfterSend reports the generation requests are not retried on
+// another channel once sent, they are billed even when the response is lost
+func (a *Adaptor) NonRetriableAfterSend(mt *meta.Meta) bool {
+	return adaptor.IsBilledOnReceiptMode(adaptor.ModeFromMeta(mt))
+}
+
 func (a *Adaptor) SupportMode(mt *meta.Meta) bool {
 	m := adaptor.ModeFromMeta(mt)
 
@@ -46,6 +52,8 @@ func (a *Adaptor) SupportMode(mt *meta.Meta) bool {
 		m == mode.Embeddings ||
 		m == mode.Gemini ||
 		m == mode.GeminiFiles ||
+		m == mode.GeminiCachedContents ||
+		m == mode.GeminiLive ||
 		m == mode.GeminiVideo ||
 		m == mode.GeminiVideoOperations ||
 		m == mode.GeminiTTS ||
@@ -143,6 +151,10 @@ func (a *Adaptor) GetRequestURL(
 		return getNativeVideoOperationRequestURL(meta, store)
 	case mode.GeminiFiles:
 		return getGeminiFileRequestURL(meta, store)
+	case mode.GeminiCachedContents:
+		return getCachedContentRequestURL(meta), nil
+	case mode.GeminiLive:
+		return getLiveRequestURL(meta, c)
 	case mode.VideoGenerationsGetJobs:
 		operationID, err := ResolveVideoJobOperationID(meta, store, meta.JobID)
 		if err != nil {
@@ -219,6 +231,10 @@ func (a *Adaptor) ConvertRequest(
 		return ConvertVideoNoBodyRequest(meta, req)
 	case mode.GeminiFiles:
 		return ConvertVideoNoBodyRequest(meta, req)
+	case mode.GeminiCachedContents:
+		return ConvertCachedContentRequest(meta, req, "")
+	case mode.GeminiLive:
+		return ConvertLiveRequest(meta, req)
 	case mode.VideoGenerationsJobs:
 		return ConvertVideoGenerationJobRequest(meta, req)
 	case mode.Videos:
@@ -248,6 +264,10 @@ func (a *Adaptor) DoRequest(
 	_ *gin.Context,
 	req *http.Request,
 ) (*http.Response, error) {
+	if meta.Mode == mode.GeminiLive {
+		return LiveDoRequest(meta, req)
+	}
+
 	return utils.DoRequestWithMeta(req, meta)
 }
 
@@ -286,6 +306,10 @@ func (a *Adaptor) DoResponse(
 		return NativeVideoOperationHandler(meta, store, c, resp)
 	case mode.GeminiFiles:
 		return GeminiFileHandler(meta, c, resp)
+	case mode.GeminiCachedContents:
+		return CachedContentHandler(meta, store, c, resp)
+	case mode.GeminiLive:
+		return LiveHandler(meta, c, resp)
 	case mode.VideoGenerationsJobs:
 		return VideoGenerationJobSubmitHandler(meta, store, c, resp)
 	case mode.Videos, mode.VideosEdits, mode.VideosExtensions:
@@ -309,7 +333,7 @@ func (a *Adaptor) DoResponse(
 
 func (a *Adaptor) Metadata() adaptor.Metadata {
 	return adaptor.Metadata{
-		Readme: "https://ai.google.dev\nGoogle Gemini native API\nSupports chat, embeddings, native Gemini requests, and image generation",
+		Readme: "https://ai.google.dev\nGoogle Gemini native API\nSupports chat, embeddings, native Gemini requests, Live API websocket sessions, and image generation",
 		Models: ModelList,
 		ConfigSchema: map[string]any{
 			"type": "object",
@@ -345,6 +369,11 @@ func (a *Adaptor) Metadata() adaptor.Metadata {
 					"title":       "Enable Person Generation Allow All",
 					"description": "When personGeneration is absent, set it to allow_all for Gemini image/video generation requests that support the field.",
 				},
+				"mid_conversation_system_as_user": map[string]any{
+					"type":        "boolean",
+					"title":       "Mid Conversation System As User",
+					"description": "Send system messages that appear after the conversation started as user preambles instead of merging them into systemInstruction.",
+				},
 			},
 		},
 	}
@@ -108,7 +108,12 @@ func (a *Adaptor) fetchVideoOperation(
 	}
 
 	if channel != nil {
-		req.Header.Set("X-Goog-Api-Key", channel.Key)
+		key, err := channel.GetKey()
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("X-Goog-Api-Key", key)
 	}
 
 	var (
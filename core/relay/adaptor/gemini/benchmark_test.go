@@ -0,0 +1,52 @@
+package gemini_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor/gemini"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+var benchmarkOpenAIRequest = []byte(`{
+	"model": "gemini-2.5-pro",
+	"stream": true,
+	"messages": [
+		{"role": "system", "content": "You are a helpful assistant."},
+		{"role": "user", "content": "What is the weather like in Paris today?"},
+		{"role": "assistant", "content": null, "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}]},
+		{"role": "tool", "tool_call_id": "call_1", "content": "{\"temperature\":22}"},
+		{"role": "user", "content": "And tomorrow?"}
+	],
+	"tools": [{"type": "function", "function": {"name": "get_weather", "parameters": {"type": "object", "properties": {"city": {"type": "string"}}}}}]
+}`)
+
+func BenchmarkConvertRequest(b *testing.B) {
+	m := meta.NewMeta(
+		&model.Channel{Type: model.ChannelTypeGoogleGemini},
+		mode.ChatCompletions,
+		"gemini-2.5-pro",
+		model.ModelConfig{},
+	)
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		req, err := http.NewRequestWithContext(
+			b.Context(),
+			http.MethodPost,
+			"http://localhost/v1/chat/completions",
+			bytes.NewReader(benchmarkOpenAIRequest),
+		)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := gemini.ConvertRequest(m, req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package gemini
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/bytedance/sonic/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+)
+
+// geminiCachedContentDefaultTTL is the upstream default ttl of a cached content
+const geminiCachedContentDefaultTTL = time.Hour
+
+type geminiCachedContent struct {
+	Name          string `json:"name"`
+	Model         string `json:"model"`
+	ExpireTime    string `json:"expireTime"`
+	UsageMetadata struct {
+		TotalTokenCount int64 `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+func getCachedContentRequestURL(meta *meta.Meta) adaptor.RequestURL {
+	u := meta.Channel.BaseURL
+	if u == "" {
+		u = baseURL
+	}
+
+	return adaptor.RequestURL{
+		Method: http.MethodPost,
+		URL:    u + "/v1beta/cachedContents",
+	}
+}
+
+// ConvertCachedContentRequest replaces the model of the cached content request
+// with the model resource of the channel, the rest of the request is kept
+func ConvertCachedContentRequest(
+	meta *meta.Meta,
+	req *http.Request,
+	modelResource string,
+) (adaptor.ConvertResult, error) {
+	node, err := common.UnmarshalRequest2NodeReusable(req)
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	if modelResource == "" {
+		modelResource = "models/" + meta.ActualModel
+	}
+
+	if _, err := node.Set("model", ast.NewString(modelResource)); err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	body, err := node.MarshalJSON()
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	return adaptor.ConvertResult{
+		Header: http.Header{
+			"Content-Type":   {"application/json"},
+			"Content-Length": {strconv.Itoa(len(body))},
+		},
+		Body: bytes.NewReader(body),
+	}, nil
+}
+
+// CachedContentHandler binds the created cached content to the channel, so the
+// requests referencing it are routed to the same channel, and bills the cached
+// tokens as cache creation
+func CachedContentHandler(
+	meta *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if resp.StatusCode != http.StatusOK {
+		return adaptor.DoResponseResult{}, ErrorHandler(resp)
+	}
+
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return adaptor.DoResponseResult{}, relaymodel.WrapperOpenAIError(
+			err,
+			"read_response_body_failed",
+			http.StatusInternalServerError,
+		)
+	}
+
+	var cachedContent geminiCachedContent
+	if err := sonic.Unmarshal(respBody, &cachedContent); err != nil {
+		return adaptor.DoResponseResult{}, relaymodel.WrapperOpenAIError(
+			err,
+			"unmarshal_response_body_failed",
+			http.StatusInternalServerError,
+		)
+	}
+
+	log := common.GetLogger(c)
+
+	if cachedContent.Name != "" {
+		if err := store.SaveStore(adaptor.StoreCache{
+			ID:        model.GeminiCachedContentStoreID(cachedContent.Name),
+			GroupID:   meta.Group.ID,
+			TokenID:   meta.Token.ID,
+			ChannelID: meta.Channel.ID,
+			Model:     meta.OriginModel,
+			ExpiresAt: cachedContentExpiresAt(cachedContent.ExpireTime),
+		}); err != nil {
+			log.Errorf("save gemini cached content store failed: %v", err)
+		}
+	}
+
+	if cachedContent.Model != "" {
+		respBody = rewriteCachedContentModel(respBody, cachedContent.Model, meta.OriginModel)
+	}
+
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.Header().Set("Content-Length", strconv.Itoa(len(respBody)))
+	_, _ = c.Writer.Write(respBody)
+
+	tokens := model.ZeroNullInt64(cachedContent.UsageMetadata.TotalTokenCount)
+
+	return adaptor.DoResponseResult{
+		Usage: model.Usage{
+			InputTokens:         tokens,
+			CacheCreationTokens: tokens,
+			TotalTokens:         tokens,
+		},
+		UpstreamID: cachedContent.Name,
+	}, nil
+}
+
+// applyCachedContent references the cached content of the `cached_content`
+// field, the system instruction and the tools are part of the cached content
+// and the upstream rejects them along with it
+func applyCachedContent(req *http.Request, geminiRequest *relaymodel.GeminiChatRequest) error {
+	var request struct {
+		CachedContent string `json:"cached_content,omitempty"`
+	}
+	if err := common.UnmarshalRequestReusable(req, &request); err != nil {
+		return err
+	}
+
+	if request.CachedContent == "" {
+		return nil
+	}
+
+	geminiRequest.CachedContent = request.CachedContent
+	geminiRequest.SystemInstruction = nil
+	geminiRequest.Tools = nil
+	geminiRequest.ToolConfig = nil
+
+	return nil
+}
+
+func cachedContentExpiresAt(expireTime string) time.Time {
+	if expireTime != "" {
+		if t, err := time.Parse(time.RFC3339Nano, expireTime); err == nil {
+			return t
+		}
+	}
+
+	return time.Now().Add(geminiCachedContentDefaultTTL)
+}
+
+// rewriteCachedContentModel keeps the resource prefix of the upstream model and
+// replaces the model id with the requested one
+func rewriteCachedContentModel(body []byte, upstreamModel, originModel string) []byte {
+	modelResource := "models/" + originModel
+	if idx := strings.LastIndex(upstreamModel, "/models/"); idx >= 0 {
+		modelResource = upstreamModel[:idx] + "/models/" + originModel
+	}
+
+	node, err := sonic.Get(body)
+	if err != nil {
+		return body
+	}
+
+	if _, err := node.Set("model", ast.NewString(modelResource)); err != nil {
+		return body
+	}
+
+	newBody, err := node.MarshalJSON()
+	if err != nil {
+		return body
+	}
+
+	return newBody
+}
+
+// VertexCachedContentModelResource returns the model resource of the cached
+// content on Vertex AI, the project and the region are required
+func VertexCachedContentModelResource(projectID, region, modelName string) (string, error) {
+	if projectID == "" || region == "" {
+		return "", errors.New(
+			"cached content requires the project id and the region of the channel key",
+		)
+	}
+
+	return fmt.Sprintf(
+		"projects/%s/locations/%s/publishers/google/models/%s",
+		projectID,
+		region,
+		modelName,
+	), nil
+}
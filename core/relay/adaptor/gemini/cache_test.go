@@ -0,0 +1,116 @@
+package gemini_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	coremodel "github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor/gemini"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertCachedContentRequest(t *testing.T) {
+	m := meta.NewMeta(
+		&coremodel.Channel{},
+		mode.GeminiCachedContents,
+		"gemini-2.5-flash",
+		coremodel.ModelConfig{},
+	)
+	m.ActualModel = "gemini-2.5-flash-001"
+
+	req := httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"/v1beta/cachedContents",
+		strings.NewReader(`{"model":"models/gemini-2.5-flash","ttl":"600s"}`),
+	)
+
+	result, err := gemini.ConvertCachedContentRequest(m, req, "")
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+
+	var request map[string]any
+	require.NoError(t, sonic.Unmarshal(body, &request))
+	require.Equal(t, "models/gemini-2.5-flash-001", request["model"])
+	require.Equal(t, "600s", request["ttl"])
+}
+
+func TestCachedContentHandlerBindsChannel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"/v1beta/cachedContents",
+		nil,
+	)
+
+	m := meta.NewMeta(
+		&coremodel.Channel{ID: 7},
+		mode.GeminiCachedContents,
+		"gemini-2.5-flash",
+		coremodel.ModelConfig{},
+	)
+	m.ActualModel = "gemini-2.5-flash-001"
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body: io.NopCloser(strings.NewReader(`{
+			"name":"cachedContents/abc",
+			"model":"models/gemini-2.5-flash-001",
+			"expireTime":"2030-01-01T00:00:00.123456Z",
+			"usageMetadata":{"totalTokenCount":4096}
+		}`)),
+	}
+
+	store := &geminiVideoTestStore{}
+
+	result, adaptorErr := gemini.CachedContentHandler(m, store, c, resp)
+	require.Nil(t, adaptorErr)
+	require.Equal(t, "cachedContents/abc", result.UpstreamID)
+	require.Equal(t, coremodel.ZeroNullInt64(4096), result.Usage.InputTokens)
+	require.Equal(t, coremodel.ZeroNullInt64(4096), result.Usage.CacheCreationTokens)
+
+	require.Len(t, store.saved, 1)
+	require.Equal(t, coremodel.GeminiCachedContentStoreID("cachedContents/abc"), store.saved[0].ID)
+	require.Equal(t, 7, store.saved[0].ChannelID)
+	require.Equal(
+		t,
+		time.Date(2030, 1, 1, 0, 0, 0, 123456000, time.UTC),
+		store.saved[0].ExpiresAt.UTC(),
+	)
+
+	var response map[string]any
+	require.NoError(t, sonic.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Equal(t, "models/gemini-2.5-flash", response["model"])
+}
+
+func TestVertexCachedContentModelResource(t *testing.T) {
+	resource, err := gemini.VertexCachedContentModelResource(
+		"project",
+		"us-central1",
+		"gemini-2.5-flash",
+	)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		"projects/project/locations/us-central1/publishers/google/models/gemini-2.5-flash",
+		resource,
+	)
+
+	_, err = gemini.VertexCachedContentModelResource("", "us-central1", "gemini-2.5-flash")
+	require.Error(t, err)
+}
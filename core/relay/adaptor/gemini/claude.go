@@ -8,6 +8,7 @@ import (
 	"github.com/bytedance/sonic"
 	"github.com/gin-gonic/gin"
 	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/config"
 	"github.com/labring/aiproxy/core/model"
 	"github.com/labring/aiproxy/core/relay/adaptor"
 	"github.com/labring/aiproxy/core/relay/adaptor/openai"
@@ -58,6 +59,8 @@ func convertClaudeRequest(
 		!disableAutoImageURLToBase64,
 		false,
 		false,
+		adaptorConfig.MidConversationSystemAsUser,
+		meta.FeatureEnabled(config.FeatureGeminiSystemParts),
 	)
 
 	// Process image tasks concurrently
@@ -352,8 +355,9 @@ func ClaudeStreamHandler(
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Error("error reading stream: " + err.Error())
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		log.Error("error reading stream: " + scanErr.Error())
 	}
 
 	// Close the last open content block
@@ -369,6 +373,12 @@ func ClaudeStreamHandler(
 		stopReason = relaymodel.ClaudeStopReasonEndTurn
 	}
 
+	// the interrupted stream ends with the error event instead of message_stop
+	if scanErr != nil {
+		render.ClaudeStreamError(c, scanErr)
+		return adaptor.DoResponseResult{Usage: usage}, nil
+	}
+
 	// Send message_delta with final usage
 	_ = render.ClaudeObjectData(c, relaymodel.ClaudeStreamResponse{
 		Type: relaymodel.ClaudeStreamTypeMessageDelta,
@@ -252,3 +252,36 @@ func TestConvertClaudeRequest_DisableAutoImageURLToBase64(t *testing.T) {
 	)
 	require.Equal(t, "", geminiReq.Contents[0].Parts[0].FileData.MimeType)
 }
+
+func TestConvertClaudeRequest_SystemBlocks(t *testing.T) {
+	m := meta.NewMeta(&model.Channel{}, 0, "gemini-2.5-pro", model.ModelConfig{})
+
+	data := []byte(`{
+		"model": "gemini-2.5-pro",
+		"system": [
+			{"type": "text", "text": "You are a helpful assistant."},
+			{"type": "text", "text": "Answer in English.", "cache_control": {"type": "ephemeral"}}
+		],
+		"messages": [{"role": "user", "content": "hello"}]
+	}`)
+
+	req, _ := http.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"/v1/messages",
+		bytes.NewReader(data),
+	)
+
+	result, err := gemini.ConvertClaudeRequest(m, req)
+	require.NoError(t, err)
+
+	body, _ := io.ReadAll(result.Body)
+
+	var geminiReq relaymodel.GeminiChatRequest
+	require.NoError(t, json.Unmarshal(body, &geminiReq))
+	require.NotNil(t, geminiReq.SystemInstruction)
+	require.Len(t, geminiReq.SystemInstruction.Parts, 2)
+	require.Equal(t, "You are a helpful assistant.", geminiReq.SystemInstruction.Parts[0].Text)
+	require.Equal(t, "Answer in English.", geminiReq.SystemInstruction.Parts[1].Text)
+	require.Len(t, geminiReq.Contents, 1)
+}
@@ -8,6 +8,10 @@ type Config struct {
 	DisableAutoAudioURLToBase64    bool   `json:"disable_auto_audio_url_to_base64"`
 	DisableAutoVideoURLToBase64    bool   `json:"disable_auto_video_url_to_base64"`
 	EnablePersonGenerationAllowAll bool   `json:"enable_person_generation_allow_all"`
+	// MidConversationSystemAsUser converts the system messages after the first
+	// non system message to user preambles instead of merging them into the
+	// system instruction
+	MidConversationSystemAsUser bool `json:"mid_conversation_system_as_user"`
 }
 
 func loadConfig(meta *meta.Meta) (Config, error) {
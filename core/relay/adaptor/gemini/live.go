@@ -0,0 +1,418 @@
+package gemini
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/bytedance/sonic/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+)
+
+const (
+	liveClientConnKey   = "gemini_live_client_conn"
+	liveBalanceCheckKey = "gemini_live_balance_check"
+	metaLiveConnKey     = "gemini_live_conn"
+	metaLiveSetupKey    = "gemini_live_setup"
+	liveSetupTimeout    = 30 * time.Second
+	liveWriteTimeout    = 10 * time.Second
+	liveServicePath     = "/ws/google.ai.generativelanguage.%s.GenerativeService.BidiGenerateContent"
+	liveDefaultVersion  = "v1beta"
+	liveUsageMetadata   = `"usageMetadata"`
+	liveSetupComplete   = `"setupComplete"`
+	liveMaxCloseTextLen = 123
+	// liveMaxMessageSize limits the messages read from the upstream
+	liveMaxMessageSize = common.MaxRequestBodySize
+)
+
+// SetLiveClientConn binds the websocket connection of the client to the live
+// session relayed by the request
+func SetLiveClientConn(c *gin.Context, conn *websocket.Conn) {
+	c.Set(liveClientConnKey, conn)
+}
+
+func getLiveClientConn(c *gin.Context) (*websocket.Conn, bool) {
+	conn, ok := c.Value(liveClientConnKey).(*websocket.Conn)
+	return conn, ok
+}
+
+// LiveBalanceCheck reports whether the group can still pay for the usage
+// accumulated by the live session
+type LiveBalanceCheck func(usage model.Usage) bool
+
+// SetLiveBalanceCheck binds the balance check of the group to the live session
+// relayed by the request, the session is closed once the check fails
+func SetLiveBalanceCheck(c *gin.Context, check LiveBalanceCheck) {
+	c.Set(liveBalanceCheckKey, check)
+}
+
+func getLiveBalanceCheck(c *gin.Context) LiveBalanceCheck {
+	check, _ := c.Value(liveBalanceCheckKey).(LiveBalanceCheck)
+	return check
+}
+
+// LiveAPIVersion returns the api version of the live websocket path, e.g.
+// v1alpha
+func LiveAPIVersion(path string) string {
+	_, rest, ok := strings.Cut(path, "google.ai.generativelanguage.")
+	if !ok {
+		return liveDefaultVersion
+	}
+
+	version, _, ok := strings.Cut(rest, ".")
+	if !ok || version == "" {
+		return liveDefaultVersion
+	}
+
+	return version
+}
+
+func getLiveRequestURL(meta *meta.Meta, c *gin.Context) (adaptor.RequestURL, error) {
+	u := meta.Channel.BaseURL
+	if u == "" {
+		u = baseURL
+	}
+
+	pu, err := url.Parse(u)
+	if err != nil {
+		return adaptor.RequestURL{}, err
+	}
+
+	switch pu.Scheme {
+	case "http":
+		pu.Scheme = "ws"
+	default:
+		pu.Scheme = "wss"
+	}
+
+	version := liveDefaultVersion
+	if c != nil {
+		version = LiveAPIVersion(c.Request.URL.Path)
+	}
+
+	pu = pu.JoinPath(fmt.Sprintf(liveServicePath, version))
+
+	return adaptor.RequestURL{
+		Method: http.MethodGet,
+		URL:    pu.String(),
+	}, nil
+}
+
+// ConvertLiveRequest rewrites the model of the setup message, the setup
+// message is the first message of the live session
+func ConvertLiveRequest(meta *meta.Meta, req *http.Request) (adaptor.ConvertResult, error) {
+	node, err := common.UnmarshalRequest2NodeReusable(req)
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	setupNode := node.Get("setup")
+	if setupNode == nil || !setupNode.Exists() {
+		return adaptor.ConvertResult{}, errors.New("setup is required")
+	}
+
+	_, err = setupNode.Set("model", ast.NewString("models/"+meta.ActualModel))
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	body, err := node.MarshalJSON()
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	return adaptor.ConvertResult{
+		Header: http.Header{
+			"Content-Type":   {"application/json"},
+			"Content-Length": {strconv.Itoa(len(body))},
+		},
+		Body: bytes.NewReader(body),
+	}, nil
+}
+
+func liveDialer(meta *meta.Meta) (*websocket.Dialer, error) {
+	dialer := *websocket.DefaultDialer
+
+	if meta.Channel.ProxyURL != "" {
+		proxyURL, err := url.Parse(meta.Channel.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+
+		dialer.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if meta.Channel.SkipTLSVerify {
+		//nolint:gosec
+		dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &dialer, nil
+}
+
+// LiveDoRequest opens the upstream live session and sends the setup message,
+// the request fails unless the upstream completes the setup, so the request
+// can be retried on the other channels before the session starts
+func LiveDoRequest(meta *meta.Meta, req *http.Request) (*http.Response, error) {
+	setup, err := common.GetRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	header := req.Header.Clone()
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+
+	dialer, err := liveDialer(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, resp, err := dialer.DialContext(req.Context(), req.URL.String(), header)
+	if err != nil {
+		if resp != nil {
+			// the handshake response carries the upstream error
+			return resp, nil
+		}
+
+		return nil, err
+	}
+
+	conn.SetReadLimit(liveMaxMessageSize)
+
+	_ = conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+	if err := conn.WriteMessage(websocket.TextMessage, setup); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(liveSetupTimeout))
+
+	messageType, message, err := conn.ReadMessage()
+	if err != nil {
+		_ = conn.Close()
+
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) {
+			return nil, fmt.Errorf("live setup rejected: %d %s", closeErr.Code, closeErr.Text)
+		}
+
+		return nil, err
+	}
+
+	if !bytes.Contains(message, []byte(liveSetupComplete)) {
+		_ = conn.Close()
+		return nil, fmt.Errorf("live setup failed: %s", message)
+	}
+
+	_ = conn.SetReadDeadline(time.Time{})
+
+	meta.Set(metaLiveConnKey, conn)
+	meta.Set(metaLiveSetupKey, liveMessage{messageType: messageType, data: message})
+
+	return &http.Response{
+		StatusCode: http.StatusSwitchingProtocols,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+	}, nil
+}
+
+type liveMessage struct {
+	messageType int
+	data        []byte
+}
+
+// addLiveUsage adds the usage metadata of the live message, each usage metadata
+// covers the responses since the previous one, it reports whether the message
+// carried usage
+func addLiveUsage(usage *model.Usage, message []byte) bool {
+	if !bytes.Contains(message, []byte(liveUsageMetadata)) {
+		return false
+	}
+
+	node, err := sonic.Get(message, "usageMetadata")
+	if err != nil {
+		return false
+	}
+
+	raw, err := node.Raw()
+	if err != nil {
+		return false
+	}
+
+	var liveUsage relaymodel.GeminiLiveUsageMetadata
+	if err := sonic.UnmarshalString(raw, &liveUsage); err != nil {
+		return false
+	}
+
+	metadata := liveUsage.ToUsageMetadata()
+	usage.Add(metadata.ToModelUsage())
+
+	return true
+}
+
+func liveCloseMessage(err error) []byte {
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		return websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	}
+
+	text := closeErr.Text
+	if len(text) > liveMaxCloseTextLen {
+		text = text[:liveMaxCloseTextLen]
+	}
+
+	switch closeErr.Code {
+	case websocket.CloseNoStatusReceived, websocket.CloseAbnormalClosure, websocket.CloseTLSHandshake:
+		return websocket.FormatCloseMessage(websocket.CloseNormalClosure, text)
+	default:
+		return websocket.FormatCloseMessage(closeErr.Code, text)
+	}
+}
+
+// LiveHandler relays the messages of the live session in both directions
+// until one side closes it, the usage metadata of the upstream messages is
+// accounted, the close frame of one side is forwarded to the other, the
+// session is closed by the proxy once it exceeds the max duration or the usage
+// exceeds the balance of the group
+func LiveHandler(
+	meta *meta.Meta,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return adaptor.DoResponseResult{}, ErrorHandler(resp)
+	}
+
+	conn, ok := meta.MustGet(metaLiveConnKey).(*websocket.Conn)
+	if !ok {
+		panic(fmt.Sprintf("live conn type error: %T", conn))
+	}
+	defer conn.Close()
+
+	setup, ok := meta.MustGet(metaLiveSetupKey).(liveMessage)
+	if !ok {
+		panic(fmt.Sprintf("live setup type error: %T", setup))
+	}
+
+	clientConn, ok := getLiveClientConn(c)
+	if !ok {
+		return adaptor.DoResponseResult{}, relaymodel.WrapperErrorWithMessage(
+			meta.Mode,
+			http.StatusBadRequest,
+			"live session requires a websocket connection",
+		)
+	}
+
+	log := common.GetLogger(c)
+
+	usage := model.Usage{}
+
+	_ = clientConn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+	if err := clientConn.WriteMessage(setup.messageType, setup.data); err != nil {
+		log.Debugf("write live setup complete failed: %v", err)
+		return adaptor.DoResponseResult{Usage: usage}, nil
+	}
+
+	var closeOnce sync.Once
+
+	// closeSession ends the session on behalf of the proxy, the client gets
+	// the reason and the upstream a normal closure
+	closeSession := func(code int, text string) {
+		closeOnce.Do(func() {
+			deadline := time.Now().Add(liveWriteTimeout)
+			_ = clientConn.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(code, text),
+				deadline,
+			)
+			_ = conn.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				deadline,
+			)
+			_ = conn.Close()
+		})
+	}
+
+	if seconds := config.GetLiveMaxSessionSeconds(); seconds > 0 {
+		timer := time.AfterFunc(time.Duration(seconds)*time.Second, func() {
+			log.Debugf("live session exceeded the max duration of %d seconds", seconds)
+			closeSession(websocket.ClosePolicyViolation, "session duration limit reached")
+		})
+		defer timer.Stop()
+	}
+
+	checkBalance := getLiveBalanceCheck(c)
+
+	// the client messages are forwarded to the upstream until the client
+	// closes the session
+	go func() {
+		for {
+			messageType, message, err := clientConn.ReadMessage()
+			if err != nil {
+				_ = conn.WriteControl(
+					websocket.CloseMessage,
+					liveCloseMessage(err),
+					time.Now().Add(liveWriteTimeout),
+				)
+				_ = conn.Close()
+
+				return
+			}
+
+			_ = conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			if err := conn.WriteMessage(messageType, message); err != nil {
+				log.Debugf("write live client message failed: %v", err)
+				_ = conn.Close()
+
+				return
+			}
+		}
+	}()
+
+	for {
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			_ = clientConn.WriteControl(
+				websocket.CloseMessage,
+				liveCloseMessage(err),
+				time.Now().Add(liveWriteTimeout),
+			)
+
+			break
+		}
+
+		accounted := addLiveUsage(&usage, message)
+
+		_ = clientConn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+		if err := clientConn.WriteMessage(messageType, message); err != nil {
+			log.Debugf("write live upstream message failed: %v", err)
+			break
+		}
+
+		if accounted && checkBalance != nil && !checkBalance(usage) {
+			log.Warn("live session closed, the group balance is not enough")
+			closeSession(websocket.ClosePolicyViolation, "balance not enough")
+
+			break
+		}
+	}
+
+	return adaptor.DoResponseResult{Usage: usage}, nil
+}
@@ -0,0 +1,258 @@
+package gemini_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor/gemini"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveAPIVersion(t *testing.T) {
+	assert.Equal(
+		t,
+		"v1alpha",
+		gemini.LiveAPIVersion(
+			"/ws/google.ai.generativelanguage.v1alpha.GenerativeService.BidiGenerateContent",
+		),
+	)
+	assert.Equal(t, "v1beta", gemini.LiveAPIVersion("/ws/unknown"))
+}
+
+func newLiveUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "upstream-key", r.Header.Get("X-Goog-Api-Key"))
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		_, setup, err := conn.ReadMessage()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		modelNode, _ := sonic.Get(setup, "setup", "model")
+		modelName, _ := modelNode.String()
+		assert.Equal(t, "models/gemini-live-upstream", modelName)
+
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"setupComplete":{}}`))
+
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+
+		_ = conn.WriteMessage(websocket.BinaryMessage, []byte(`{
+			"serverContent": {"turnComplete": true},
+			"usageMetadata": {
+				"promptTokenCount": 10,
+				"responseTokenCount": 20,
+				"totalTokenCount": 30,
+				"promptTokensDetails": [{"modality": "AUDIO", "tokenCount": 8}],
+				"responseTokensDetails": [{"modality": "AUDIO", "tokenCount": 20}]
+			}
+		}`))
+		_ = conn.WriteMessage(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"),
+		)
+	}))
+}
+
+// dialLiveProxy relays the live sessions of the client to the upstream, the
+// usage of the sessions is sent to the returned channel
+func dialLiveProxy(
+	t *testing.T,
+	upstreamURL string,
+	setupContext func(c *gin.Context),
+) (*websocket.Conn, <-chan model.Usage) {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	usageCh := make(chan model.Usage, 1)
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientConn, err := upgrader.Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer clientConn.Close()
+
+		_, setup, err := clientConn.ReadMessage()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequestWithContext(
+			t.Context(),
+			http.MethodPost,
+			"/ws/google.ai.generativelanguage.v1beta.GenerativeService.BidiGenerateContent",
+			bytes.NewReader(setup),
+		)
+		gemini.SetLiveClientConn(c, clientConn)
+
+		if setupContext != nil {
+			setupContext(c)
+		}
+
+		m := meta.NewMeta(
+			&model.Channel{
+				Type:         model.ChannelTypeGoogleGemini,
+				Key:          "upstream-key",
+				BaseURL:      upstreamURL,
+				ModelMapping: map[string]string{"gemini-live": "gemini-live-upstream"},
+			},
+			mode.GeminiLive,
+			"gemini-live",
+			model.ModelConfig{},
+		)
+
+		a := &gemini.Adaptor{}
+
+		resp, err := doLiveRequest(a, m, c)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		result, relayErr := a.DoResponse(m, nil, c, resp)
+		assert.Nil(t, relayErr)
+
+		usageCh <- result.Usage
+	}))
+	t.Cleanup(proxy.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial(
+		"ws"+strings.TrimPrefix(proxy.URL, "http"),
+		nil,
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	require.NoError(t, conn.WriteMessage(
+		websocket.TextMessage,
+		[]byte(`{"setup":{"model":"models/gemini-live"}}`),
+	))
+
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"setupComplete":{}}`, string(message))
+
+	return conn, usageCh
+}
+
+func TestLiveSession(t *testing.T) {
+	upstream := newLiveUpstream(t)
+	defer upstream.Close()
+
+	conn, usageCh := dialLiveProxy(t, upstream.URL, nil)
+
+	require.NoError(t, conn.WriteMessage(
+		websocket.TextMessage,
+		[]byte(`{"realtimeInput":{"text":"hello"}}`),
+	))
+
+	messageType, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.BinaryMessage, messageType)
+	assert.Contains(t, string(message), "turnComplete")
+
+	_, _, err = conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure))
+
+	usage := <-usageCh
+	assert.Equal(t, model.ZeroNullInt64(10), usage.InputTokens)
+	assert.Equal(t, model.ZeroNullInt64(8), usage.AudioInputTokens)
+	assert.Equal(t, model.ZeroNullInt64(20), usage.OutputTokens)
+	assert.Equal(t, model.ZeroNullInt64(20), usage.AudioOutputTokens)
+	assert.Equal(t, model.ZeroNullInt64(30), usage.TotalTokens)
+}
+
+func TestLiveSessionClosedWhenBalanceNotEnough(t *testing.T) {
+	upstream := newLiveUpstream(t)
+	defer upstream.Close()
+
+	conn, usageCh := dialLiveProxy(t, upstream.URL, func(c *gin.Context) {
+		gemini.SetLiveBalanceCheck(c, func(usage model.Usage) bool {
+			return usage.TotalTokens < 30
+		})
+	})
+
+	require.NoError(t, conn.WriteMessage(
+		websocket.TextMessage,
+		[]byte(`{"realtimeInput":{"text":"hello"}}`),
+	))
+
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Contains(t, string(message), "turnComplete")
+
+	_, _, err = conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation))
+	assert.Contains(t, err.Error(), "balance not enough")
+
+	// the usage before the close is still billed
+	assert.Equal(t, model.ZeroNullInt64(30), (<-usageCh).TotalTokens)
+}
+
+func TestLiveSessionMaxDuration(t *testing.T) {
+	maxSessionSeconds := config.GetLiveMaxSessionSeconds()
+
+	config.SetLiveMaxSessionSeconds(1)
+	t.Cleanup(func() { config.SetLiveMaxSessionSeconds(maxSessionSeconds) })
+
+	upstream := newLiveUpstream(t)
+	defer upstream.Close()
+
+	conn, usageCh := dialLiveProxy(t, upstream.URL, nil)
+
+	_, _, err := conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation))
+	assert.Contains(t, err.Error(), "session duration limit reached")
+	assert.Zero(t, (<-usageCh).TotalTokens)
+}
+
+func doLiveRequest(a *gemini.Adaptor, m *meta.Meta, c *gin.Context) (*http.Response, error) {
+	convertResult, err := a.ConvertRequest(m, nil, c.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	requestURL, err := a.GetRequestURL(m, nil, c)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(
+		c.Request.Context(),
+		requestURL.Method,
+		requestURL.URL,
+		convertResult.Body,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.SetupRequestHeader(m, nil, c, req); err != nil {
+		return nil, err
+	}
+
+	return a.DoRequest(m, nil, c, req)
+}
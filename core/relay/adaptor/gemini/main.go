@@ -201,8 +201,17 @@ func NativeHandler(
 
 	defer resp.Body.Close()
 
+	jsonResponse, err := common.GetResponseBody(resp)
+	if err != nil {
+		return adaptor.DoResponseResult{}, relaymodel.WrapperOpenAIError(
+			err,
+			"read_response_body_failed",
+			http.StatusInternalServerError,
+		)
+	}
+
 	var geminiResponse relaymodel.GeminiChatResponse
-	if err := sonic.ConfigDefault.NewDecoder(resp.Body).Decode(&geminiResponse); err != nil {
+	if err := sonic.Unmarshal(jsonResponse, &geminiResponse); err != nil {
 		return adaptor.DoResponseResult{}, relaymodel.WrapperOpenAIError(
 			err,
 			"unmarshal_response_body_failed",
@@ -218,16 +227,6 @@ func NativeHandler(
 	// Get web search count from grounding metadata
 	usage.WebSearchCount = model.ZeroNullInt64(geminiResponse.GetWebSearchCount())
 
-	// Pass through the response as-is
-	jsonResponse, err := sonic.Marshal(geminiResponse)
-	if err != nil {
-		return adaptor.DoResponseResult{Usage: usage}, relaymodel.WrapperOpenAIError(
-			err,
-			"marshal_response_body_failed",
-			http.StatusInternalServerError,
-		)
-	}
-
 	c.Writer.Header().Set("Content-Type", "application/json")
 	c.Writer.Header().Set("Content-Length", strconv.Itoa(len(jsonResponse)))
 	_, _ = c.Writer.Write(jsonResponse)
@@ -286,6 +285,7 @@ func NativeStreamHandler(
 
 	if err := scanner.Err(); err != nil {
 		log.Error("error reading stream: " + err.Error())
+		render.GeminiStreamError(c, err)
 	}
 
 	usage.WebSearchCount = model.ZeroNullInt64(
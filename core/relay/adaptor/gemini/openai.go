@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,6 +17,7 @@ import (
 	"github.com/bytedance/sonic"
 	"github.com/gin-gonic/gin"
 	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/config"
 	"github.com/labring/aiproxy/core/common/conv"
 	"github.com/labring/aiproxy/core/common/image"
 	"github.com/labring/aiproxy/core/model"
@@ -34,6 +36,7 @@ var toolChoiceTypeMap = map[string]string{
 	relaymodel.ToolChoiceNone:     relaymodel.GeminiFunctionCallingModeNone,
 	relaymodel.ToolChoiceAuto:     relaymodel.GeminiFunctionCallingModeAuto,
 	relaymodel.ToolChoiceRequired: relaymodel.GeminiFunctionCallingModeAny,
+	relaymodel.ToolChoiceAny:      relaymodel.GeminiFunctionCallingModeAny,
 }
 
 var mimeTypeMap = map[string]string{
@@ -216,6 +219,16 @@ func buildGenerationConfig(
 		config.TopP = textRequest.TopP
 	}
 
+	if !config.ResponseLogprobs && textRequest.Logprobs != nil && *textRequest.Logprobs {
+		config.ResponseLogprobs = true
+		config.Logprobs = textRequest.TopLogprobs
+	}
+
+	if config.Seed == nil && textRequest.Seed != 0 {
+		seed := int64(textRequest.Seed)
+		config.Seed = &seed
+	}
+
 	// Convert MaxTokens (int) to MaxOutputTokens (*int)
 	if config.MaxOutputTokens == nil && textRequest.MaxTokens != 0 {
 		config.MaxOutputTokens = &textRequest.MaxTokens
@@ -229,7 +242,7 @@ func buildGenerationConfig(
 		}
 	}
 
-	if isGeminiTTSModel(meta) {
+	if isGeminiTTSModel(meta) || requestsAudioOutput(textRequest) {
 		if len(config.ResponseModalities) == 0 {
 			config.ResponseModalities = []string{relaymodel.GeminiModalityAudio}
 		}
@@ -251,6 +264,10 @@ func buildGenerationConfig(
 		}
 	}
 
+	if config.MediaResolution == "" {
+		config.MediaResolution = mediaResolutionForDetail(textRequest.Messages)
+	}
+
 	if config.ThinkingConfig == nil && !isGeminiTTSModel(meta) {
 		utils.ApplyReasoningToGeminiConfig(
 			meta.OriginModel,
@@ -279,6 +296,44 @@ func buildGenerationConfig(
 	return &config
 }
 
+// mediaResolutionForDetail maps the detail of the image inputs to the media
+// resolution of the request, it is high when any image asks for the high
+// detail and low when all of them ask for the low detail
+func mediaResolutionForDetail(messages []relaymodel.Message) string {
+	var low, other bool
+
+	for _, message := range messages {
+		for _, part := range message.ParseContent() {
+			if part.ImageURL == nil {
+				continue
+			}
+
+			switch part.ImageURL.Detail {
+			case image.DetailHigh:
+				return relaymodel.GeminiMediaResolutionHigh
+			case image.DetailLow:
+				low = true
+			default:
+				other = true
+			}
+		}
+	}
+
+	if low && !other {
+		return relaymodel.GeminiMediaResolutionLow
+	}
+
+	return ""
+}
+
+// requestsAudioOutput reports whether the chat request asks for the audio
+// output with `modalities: ["text", "audio"]`
+func requestsAudioOutput(textRequest *relaymodel.GeneralOpenAIRequest) bool {
+	return slices.ContainsFunc(textRequest.Modalities, func(modality string) bool {
+		return strings.EqualFold(modality, "audio")
+	})
+}
+
 func buildGeminiSpeechConfig(audio *relaymodel.Audio) *relaymodel.GeminiSpeechConfig {
 	voiceName := "Kore"
 	if audio != nil && audio.Voice != "" {
@@ -403,11 +458,18 @@ func buildToolConfig(textRequest *relaymodel.GeneralOpenAIRequest) *relaymodel.G
 			toolConfig.FunctionCallingConfig.Mode = toolChoiceType
 		}
 	case map[string]any:
-		toolConfig.FunctionCallingConfig.Mode = relaymodel.GeminiFunctionCallingModeAny
-		if fn, ok := mode["function"].(map[string]any); ok {
-			if fnName, ok := fn["name"].(string); ok {
-				toolConfig.FunctionCallingConfig.AllowedFunctionNames = []string{fnName}
-			}
+		if fnName := relaymodel.ForcedToolName(mode); fnName != "" {
+			toolConfig.FunctionCallingConfig.Mode = relaymodel.GeminiFunctionCallingModeAny
+			toolConfig.FunctionCallingConfig.AllowedFunctionNames = []string{fnName}
+			break
+		}
+
+		// the claude style {"type":"any"} and {"type":"none"} choices
+		choiceType, _ := mode["type"].(string)
+		if toolChoiceType, ok := toolChoiceTypeMap[choiceType]; ok {
+			toolConfig.FunctionCallingConfig.Mode = toolChoiceType
+		} else {
+			toolConfig.FunctionCallingConfig.Mode = relaymodel.GeminiFunctionCallingModeAny
 		}
 	}
 
@@ -455,6 +517,18 @@ func buildMessageParts(
 		return buildGeminiMediaPart("", message.VideoURL.URL, "", "video")
 	}
 
+	// gemini reads the pdf files natively
+	if message.File != nil {
+		if mimeType, data, ok := parseMediaDataURL(message.File.FileData, "application"); ok {
+			return &relaymodel.GeminiPart{
+				InlineData: &relaymodel.GeminiInlineData{
+					MimeType: mimeType,
+					Data:     data,
+				},
+			}
+		}
+	}
+
 	return part
 }
 
@@ -476,10 +550,16 @@ func buildGeminiMediaPart(data, uri, format, mediaType string) *relaymodel.Gemin
 			return part
 		}
 
+		base64Data := normalizeBase64Data(data)
+
 		mimeType := geminiMediaMIMEType(format, mediaType)
+		if mimeType == "" && mediaType == "audio" {
+			mimeType = sniffAudioMIMEType(base64Data)
+		}
+
 		part.InlineData = &relaymodel.GeminiInlineData{
 			MimeType: mimeType,
-			Data:     normalizeBase64Data(data),
+			Data:     base64Data,
 		}
 
 		return part
@@ -509,14 +589,54 @@ func buildGeminiMediaPart(data, uri, format, mediaType string) *relaymodel.Gemin
 	return part
 }
 
+// base64Whitespace removes the line breaks and spaces of wrapped base64 data
+var base64Whitespace = strings.NewReplacer("\n", "", "\r", "", "\t", "", " ", "")
+
+// base64URLEncoding converts the url safe alphabet to the standard one
+var base64URLEncoding = strings.NewReplacer("-", "+", "_", "/")
+
+// normalizeBase64Data converts the base64 data, or the data url, sent by
+// clients to the padded standard base64 required by gemini inline data
 func normalizeBase64Data(data string) string {
 	if _, base64Data, ok := strings.Cut(data, ";base64,"); ok {
-		return base64Data
+		data = base64Data
+	}
+
+	data = base64URLEncoding.Replace(base64Whitespace.Replace(data))
+	if remainder := len(data) % 4; remainder != 0 {
+		data += strings.Repeat("=", 4-remainder)
 	}
 
 	return data
 }
 
+// sniffAudioMIMEType detects the wav and mp3 audio sent without a format
+func sniffAudioMIMEType(base64Data string) string {
+	// 16 base64 characters decode to the 12 bytes of the longest header
+	if len(base64Data) < 16 {
+		return ""
+	}
+
+	header, err := base64.StdEncoding.DecodeString(base64Data[:16])
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return "audio/wav"
+	case bytes.HasPrefix(header, []byte("ID3")),
+		header[0] == 0xff && header[1]&0xe0 == 0xe0:
+		return geminiAudioMimeTypes["mp3"]
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return "audio/ogg"
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return "audio/flac"
+	default:
+		return ""
+	}
+}
+
 func parseMediaDataURL(dataURL, mediaType string) (string, string, bool) {
 	prefix := "data:" + mediaType + "/"
 	if !strings.HasPrefix(dataURL, prefix) {
@@ -765,11 +885,41 @@ func mergeConsecutiveContents(
 	return mergedContents
 }
 
+// buildSystemParts keeps every text part of the system message as a part of
+// its own, the parts which are not text are dropped
+func buildSystemParts(message relaymodel.Message) []*relaymodel.GeminiPart {
+	if text, ok := message.Content.(string); ok {
+		if text == "" {
+			return nil
+		}
+
+		return []*relaymodel.GeminiPart{{Text: text}}
+	}
+
+	var parts []*relaymodel.GeminiPart
+	for _, part := range message.ParseContent() {
+		if part.Type != relaymodel.ContentTypeText || part.Text == "" {
+			continue
+		}
+
+		parts = append(parts, &relaymodel.GeminiPart{Text: part.Text})
+	}
+
+	return parts
+}
+
+// buildContents converts the messages to the gemini contents, all the system
+// messages are merged into the system instruction, the system messages after
+// the conversation started are sent as user preambles with
+// midConversationSystemAsUser, without systemParts only the last system
+// message is kept
 func buildContents(
 	textRequest *relaymodel.GeneralOpenAIRequest,
 	collectImageTasks bool,
 	collectAudioTasks bool,
 	collectVideoTasks bool,
+	midConversationSystemAsUser bool,
+	systemParts bool,
 ) (
 	*relaymodel.GeminiChatContent,
 	[]*relaymodel.GeminiChatContent,
@@ -798,7 +948,7 @@ func buildContents(
 			appendAssistantToolCalls(&content, message.ToolCalls, toolCallMap)
 		case message.Role == "tool" && message.ToolCallID != "":
 			appendToolResponse(&content, message, toolCallMap)
-		case message.Role == relaymodel.RoleSystem:
+		case message.Role == relaymodel.RoleSystem && !systemParts:
 			systemContent = &relaymodel.GeminiChatContent{
 				Role: relaymodel.RoleUser,
 				Parts: []*relaymodel.GeminiPart{{
@@ -806,6 +956,28 @@ func buildContents(
 				}},
 			}
 
+			continue
+		case message.Role == relaymodel.RoleSystem:
+			parts := buildSystemParts(message)
+			if len(parts) == 0 {
+				continue
+			}
+
+			if midConversationSystemAsUser && len(contents) > 0 {
+				content.Role = relaymodel.RoleUser
+				content.Parts = parts
+
+				break
+			}
+
+			if systemContent == nil {
+				systemContent = &relaymodel.GeminiChatContent{
+					Role: relaymodel.RoleUser,
+				}
+			}
+
+			systemContent.Parts = append(systemContent.Parts, parts...)
+
 			continue
 		default:
 			parts, imageTaskParts, audioTaskParts, videoTaskParts := buildRegularMessageParts(
@@ -1078,6 +1250,8 @@ func convertRequest(
 		!disableAutoImageURLToBase64,
 		!disableAutoAudioURLToBase64,
 		!disableAutoVideoURLToBase64,
+		adaptorConfig.MidConversationSystemAsUser,
+		meta.FeatureEnabled(config.FeatureGeminiSystemParts),
 	)
 
 	// Process image tasks concurrently
@@ -1105,6 +1279,10 @@ func convertRequest(
 		ToolConfig:        buildToolConfig(textRequest),
 	}
 
+	if err := applyCachedContent(req, &geminiRequest); err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
 	data, err := sonic.Marshal(geminiRequest)
 	if err != nil {
 		return adaptor.ConvertResult{}, err
@@ -1315,6 +1493,7 @@ func responseChat2OpenAI(
 				Role: relaymodel.RoleAssistant,
 			},
 			FinishReason: FinishReason2OpenAI(candidate.FinishReason),
+			Logprobs:     candidate.LogprobsResult.ToOpenAI(),
 		}
 		if len(candidate.Content.Parts) > 0 {
 			var (
@@ -1421,6 +1600,7 @@ func streamResponseChat2OpenAI(
 				Content: "",
 			},
 			FinishReason: FinishReason2OpenAI(candidate.FinishReason),
+			Logprobs:     candidate.LogprobsResult.ToOpenAI(),
 		}
 		if len(candidate.Content.Parts) > 0 {
 			var (
@@ -1557,10 +1737,11 @@ func StreamHandler(
 
 	if err := scanner.Err(); err != nil {
 		log.Error("error reading stream: " + err.Error())
+		render.OpenaiStreamError(c, err)
+	} else {
+		render.OpenaiDone(c)
 	}
 
-	render.OpenaiDone(c)
-
 	usage.WebSearchCount = model.ZeroNullInt64(
 		geminiWebSearchCount(webSearchQueries, webSearchGrounded, webSearchGemini3),
 	)
@@ -71,6 +71,111 @@ func TestConvertRequest_JsonObject(t *testing.T) {
 	assert.Equal(t, "application/json", geminiReq.GenerationConfig.ResponseMimeType)
 }
 
+func TestConvertRequest_LogprobsToResponseLogprobs(t *testing.T) {
+	meta := meta.NewMeta(
+		&model.Channel{Type: model.ChannelTypeGoogleGemini},
+		mode.ChatCompletions,
+		"gemini-2.0-flash",
+		model.ModelConfig{},
+	)
+
+	logprobs := true
+	topLogprobs := 3
+	openAIReq := relaymodel.GeneralOpenAIRequest{
+		Model: "gemini-2.0-flash",
+		Messages: []relaymodel.Message{
+			{Role: "user", Content: "Hello"},
+		},
+		Logprobs:    &logprobs,
+		TopLogprobs: &topLogprobs,
+	}
+
+	jsonData, _ := sonic.Marshal(openAIReq)
+	req, _ := http.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"http://localhost/v1/chat/completions",
+		bytes.NewBuffer(jsonData),
+	)
+
+	result, err := gemini.ConvertRequest(meta, req)
+	assert.NoError(t, err)
+
+	bodyBytes, _ := io.ReadAll(result.Body)
+
+	var geminiReq relaymodel.GeminiChatRequest
+
+	err = json.Unmarshal(bodyBytes, &geminiReq)
+	assert.NoError(t, err)
+	assert.NotNil(t, geminiReq.GenerationConfig)
+	assert.True(t, geminiReq.GenerationConfig.ResponseLogprobs)
+	assert.Equal(t, &topLogprobs, geminiReq.GenerationConfig.Logprobs)
+}
+
+func TestConvertRequest_ImageDetailToMediaResolution(t *testing.T) {
+	imageURL := "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8BQDwAEhQGAhKmMIQAAAABJRU5ErkJggg=="
+
+	tests := []struct {
+		name     string
+		details  []string
+		expected string
+	}{
+		{
+			name:     "low",
+			details:  []string{"low", "low"},
+			expected: relaymodel.GeminiMediaResolutionLow,
+		},
+		{
+			name:     "any high",
+			details:  []string{"low", "high"},
+			expected: relaymodel.GeminiMediaResolutionHigh,
+		},
+		{
+			name:     "auto",
+			details:  []string{"low", "auto"},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := meta.NewMeta(
+				&model.Channel{Type: model.ChannelTypeGoogleGemini},
+				mode.ChatCompletions,
+				"gemini-2.0-flash",
+				model.ModelConfig{},
+			)
+
+			content := []any{map[string]any{"type": "text", "text": "Compare them"}}
+			for _, detail := range tt.details {
+				content = append(content, map[string]any{
+					"type":      "image_url",
+					"image_url": map[string]any{"url": imageURL, "detail": detail},
+				})
+			}
+
+			jsonData, _ := sonic.Marshal(relaymodel.GeneralOpenAIRequest{
+				Model:    "gemini-2.0-flash",
+				Messages: []relaymodel.Message{{Role: "user", Content: content}},
+			})
+			req, _ := http.NewRequestWithContext(
+				t.Context(),
+				http.MethodPost,
+				"http://localhost/v1/chat/completions",
+				bytes.NewBuffer(jsonData),
+			)
+
+			result, err := gemini.ConvertRequest(meta, req)
+			assert.NoError(t, err)
+
+			var geminiReq relaymodel.GeminiChatRequest
+			assert.NoError(t, json.NewDecoder(result.Body).Decode(&geminiReq))
+			assert.NotNil(t, geminiReq.GenerationConfig)
+			assert.Equal(t, tt.expected, geminiReq.GenerationConfig.MediaResolution)
+		})
+	}
+}
+
 func TestConvertRequest_TTSModelSetsAudioModalityAndSpeechConfig(t *testing.T) {
 	t.Parallel()
 
@@ -138,6 +243,55 @@ func TestConvertRequest_TTSModelSetsAudioModalityAndSpeechConfig(t *testing.T) {
 	assert.Nil(t, geminiReq.GenerationConfig.ThinkingConfig)
 }
 
+func TestConvertRequest_AudioModalitySetsSpeechConfig(t *testing.T) {
+	t.Parallel()
+
+	channel := &model.Channel{
+		Type: model.ChannelTypeGoogleGemini,
+	}
+	meta := meta.NewMeta(
+		channel,
+		mode.ChatCompletions,
+		"gemini-2.5-flash-native-audio",
+		model.ModelConfig{},
+	)
+
+	req, err := http.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"http://localhost/v1/chat/completions",
+		bytes.NewBufferString(`{
+			"model":"gemini-2.5-flash-native-audio",
+			"modalities":["text","audio"],
+			"audio":{"voice":"Puck","format":"pcm16"},
+			"messages":[{"role":"user","content":"Say hello."}]
+		}`),
+	)
+	assert.NoError(t, err)
+
+	result, err := gemini.ConvertRequest(meta, req)
+	assert.NoError(t, err)
+
+	bodyBytes, err := io.ReadAll(result.Body)
+	assert.NoError(t, err)
+
+	var geminiReq relaymodel.GeminiChatRequest
+
+	err = json.Unmarshal(bodyBytes, &geminiReq)
+	assert.NoError(t, err)
+	assert.NotNil(t, geminiReq.GenerationConfig)
+	assert.Equal(
+		t,
+		[]string{relaymodel.GeminiModalityAudio},
+		geminiReq.GenerationConfig.ResponseModalities,
+	)
+	assert.Equal(
+		t,
+		"Puck",
+		geminiReq.GenerationConfig.SpeechConfig.VoiceConfig.PrebuiltVoiceConfig.VoiceName,
+	)
+}
+
 func TestConvertTTSRequestMapsOpenAISpeechToGemini(t *testing.T) {
 	t.Parallel()
 
@@ -1344,6 +1498,64 @@ func TestBuildMessagePartsUsesInlineDataForMediaDataURL(t *testing.T) {
 	})
 }
 
+func TestBuildMessagePartsNormalizesInputAudioBase64(t *testing.T) {
+	t.Parallel()
+
+	t.Run("wrapped wav without format", func(t *testing.T) {
+		t.Parallel()
+
+		wav := []byte("RIFF\x24\x00\x00\x00WAVEfmt audio bytes")
+		audioData := base64.StdEncoding.EncodeToString(wav)
+		part := gemini.BuildMessagePartForTest(
+			relaymodel.MessageContent{
+				Type: relaymodel.ContentTypeInputAudio,
+				InputAudio: &relaymodel.InputAudio{
+					Data: audioData[:12] + "\r\n" + audioData[12:],
+				},
+			},
+		)
+
+		assert.NotNil(t, part.InlineData)
+		assert.Equal(t, "audio/wav", part.InlineData.MimeType)
+		assert.Equal(t, audioData, part.InlineData.Data)
+	})
+
+	t.Run("url safe mp3 without padding", func(t *testing.T) {
+		t.Parallel()
+
+		mp3 := []byte("ID3\x04\x00\x00\x00\x00\x00\x00\xff\xfb mp3")
+		part := gemini.BuildMessagePartForTest(
+			relaymodel.MessageContent{
+				Type: relaymodel.ContentTypeInputAudio,
+				InputAudio: &relaymodel.InputAudio{
+					Data: base64.RawURLEncoding.EncodeToString(mp3),
+				},
+			},
+		)
+
+		assert.NotNil(t, part.InlineData)
+		assert.Equal(t, "audio/mp3", part.InlineData.MimeType)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(mp3), part.InlineData.Data)
+	})
+
+	t.Run("format overrides detection", func(t *testing.T) {
+		t.Parallel()
+
+		part := gemini.BuildMessagePartForTest(
+			relaymodel.MessageContent{
+				Type: relaymodel.ContentTypeInputAudio,
+				InputAudio: &relaymodel.InputAudio{
+					Data:   base64.StdEncoding.EncodeToString([]byte("RIFF0000WAVEdata")),
+					Format: "mp3",
+				},
+			},
+		)
+
+		assert.NotNil(t, part.InlineData)
+		assert.Equal(t, "audio/mp3", part.InlineData.MimeType)
+	})
+}
+
 func TestResponseChat2OpenAIConvertsAudioInlineDataAndUsage(t *testing.T) {
 	t.Parallel()
 
@@ -1431,3 +1643,164 @@ func TestStreamResponseChat2OpenAIConvertsAudioInlineDataToDeltaAudio(t *testing
 	assert.NotNil(t, openAIChunk.Choices[0].Delta.Audio)
 	assert.Equal(t, audioData, openAIChunk.Choices[0].Delta.Audio.Data)
 }
+
+func TestConvertRequest_ForcedToolChoice(t *testing.T) {
+	toolChoices := []any{
+		map[string]any{"type": "function", "function": map[string]any{"name": "get_weather"}},
+		map[string]any{"type": "tool", "name": "get_weather"},
+		map[string]any{"type": "function", "name": "get_weather"},
+	}
+
+	for _, toolChoice := range toolChoices {
+		m := meta.NewMeta(
+			&model.Channel{Type: model.ChannelTypeGoogleGemini},
+			mode.ChatCompletions,
+			"gemini-2.5-flash",
+			model.ModelConfig{},
+		)
+
+		jsonData, _ := sonic.Marshal(relaymodel.GeneralOpenAIRequest{
+			Model: "gemini-2.5-flash",
+			Messages: []relaymodel.Message{
+				{Role: "user", Content: "weather?"},
+			},
+			Tools: []relaymodel.Tool{
+				{
+					Type:     relaymodel.ToolChoiceTypeFunction,
+					Function: relaymodel.Function{Name: "get_weather"},
+				},
+			},
+			ToolChoice: toolChoice,
+		})
+		req, _ := http.NewRequestWithContext(
+			t.Context(),
+			http.MethodPost,
+			"http://localhost/v1/chat/completions",
+			bytes.NewBuffer(jsonData),
+		)
+
+		result, err := gemini.ConvertRequest(m, req)
+		assert.NoError(t, err)
+
+		bodyBytes, _ := io.ReadAll(result.Body)
+
+		var geminiReq relaymodel.GeminiChatRequest
+		assert.NoError(t, json.Unmarshal(bodyBytes, &geminiReq))
+		assert.NotNil(t, geminiReq.ToolConfig)
+		assert.Equal(
+			t,
+			relaymodel.GeminiFunctionCallingModeAny,
+			geminiReq.ToolConfig.FunctionCallingConfig.Mode,
+		)
+		assert.Equal(
+			t,
+			[]string{"get_weather"},
+			geminiReq.ToolConfig.FunctionCallingConfig.AllowedFunctionNames,
+		)
+	}
+}
+
+func TestConvertRequest_SystemInstructionParts(t *testing.T) {
+	data := []byte(`{
+		"model": "gemini-2.5-pro",
+		"messages": [
+			{"role": "system", "content": "You are a helpful assistant."},
+			{"role": "system", "content": [
+				{"type": "text", "text": "Answer in English."},
+				{"type": "text", "text": "Use markdown."}
+			]},
+			{"role": "user", "content": "hello"},
+			{"role": "system", "content": "Be brief."},
+			{"role": "user", "content": "again"}
+		]
+	}`)
+
+	convert := func(configs model.ChannelConfigs) relaymodel.GeminiChatRequest {
+		m := meta.NewMeta(
+			&model.Channel{Configs: configs},
+			mode.ChatCompletions,
+			"gemini-2.5-pro",
+			model.ModelConfig{},
+		)
+
+		req, _ := http.NewRequestWithContext(
+			t.Context(),
+			http.MethodPost,
+			"http://localhost/v1/chat/completions",
+			bytes.NewReader(data),
+		)
+
+		result, err := gemini.ConvertRequest(m, req)
+		assert.NoError(t, err)
+
+		body, _ := io.ReadAll(result.Body)
+
+		var geminiReq relaymodel.GeminiChatRequest
+		assert.NoError(t, json.Unmarshal(body, &geminiReq))
+
+		return geminiReq
+	}
+
+	t.Run("merge into system instruction", func(t *testing.T) {
+		geminiReq := convert(nil)
+
+		assert.NotNil(t, geminiReq.SystemInstruction)
+		assert.Len(t, geminiReq.SystemInstruction.Parts, 4)
+		assert.Equal(t, "You are a helpful assistant.", geminiReq.SystemInstruction.Parts[0].Text)
+		assert.Equal(t, "Answer in English.", geminiReq.SystemInstruction.Parts[1].Text)
+		assert.Equal(t, "Use markdown.", geminiReq.SystemInstruction.Parts[2].Text)
+		assert.Equal(t, "Be brief.", geminiReq.SystemInstruction.Parts[3].Text)
+		assert.Len(t, geminiReq.Contents, 1)
+		assert.Len(t, geminiReq.Contents[0].Parts, 2)
+	})
+
+	t.Run("mid conversation system as user", func(t *testing.T) {
+		geminiReq := convert(model.ChannelConfigs{"mid_conversation_system_as_user": true})
+
+		assert.NotNil(t, geminiReq.SystemInstruction)
+		assert.Len(t, geminiReq.SystemInstruction.Parts, 3)
+		assert.Len(t, geminiReq.Contents, 1)
+		assert.Equal(t, relaymodel.RoleUser, geminiReq.Contents[0].Role)
+		assert.Len(t, geminiReq.Contents[0].Parts, 3)
+		assert.Equal(t, "hello", geminiReq.Contents[0].Parts[0].Text)
+		assert.Equal(t, "Be brief.", geminiReq.Contents[0].Parts[1].Text)
+		assert.Equal(t, "again", geminiReq.Contents[0].Parts[2].Text)
+	})
+}
+
+func TestConvertRequest_SystemPartsFeatureFlagOff(t *testing.T) {
+	m := meta.NewMeta(
+		&model.Channel{},
+		mode.ChatCompletions,
+		"gemini-2.5-pro",
+		model.ModelConfig{},
+		meta.WithFeatureFlags([]string{}),
+	)
+
+	data := []byte(`{
+		"model": "gemini-2.5-pro",
+		"messages": [
+			{"role": "system", "content": "You are a helpful assistant."},
+			{"role": "system", "content": "Be brief."},
+			{"role": "user", "content": "hello"}
+		]
+	}`)
+
+	req, _ := http.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"http://localhost/v1/chat/completions",
+		bytes.NewReader(data),
+	)
+
+	result, err := gemini.ConvertRequest(m, req)
+	assert.NoError(t, err)
+
+	body, _ := io.ReadAll(result.Body)
+
+	var geminiReq relaymodel.GeminiChatRequest
+	assert.NoError(t, json.Unmarshal(body, &geminiReq))
+	assert.NotNil(t, geminiReq.SystemInstruction)
+	assert.Len(t, geminiReq.SystemInstruction.Parts, 1)
+	assert.Equal(t, "Be brief.", geminiReq.SystemInstruction.Parts[0].Text)
+}
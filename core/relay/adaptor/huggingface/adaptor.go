@@ -0,0 +1,124 @@
+package huggingface
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
+	"github.com/labring/aiproxy/core/relay/adaptor/registry"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+// Adaptor supports the Hugging Face serverless inference router and the
+// dedicated Inference Endpoints running TGI or TEI, both of them expose the
+// OpenAI compatible api
+type Adaptor struct {
+	openai.Adaptor
+}
+
+func init() {
+	registry.Register(model.ChannelTypeHuggingFace, &Adaptor{})
+}
+
+const (
+	baseURL = "https://router.huggingface.co/v1"
+	// routerHost is the host of the serverless inference router, its
+	// embeddings are served by the feature extraction pipeline
+	routerHost = "router.huggingface.co"
+)
+
+func (a *Adaptor) DefaultBaseURL() string {
+	return baseURL
+}
+
+func (a *Adaptor) SupportMode(mt *meta.Meta) bool {
+	m := adaptor.ModeFromMeta(mt)
+
+	return m == mode.ChatCompletions ||
+		m == mode.Completions ||
+		m == mode.Embeddings ||
+		m == mode.Anthropic ||
+		m == mode.Gemini
+}
+
+func isRouter(baseURL string) bool {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return false
+	}
+
+	return u.Hostname() == routerHost
+}
+
+func isFeatureExtraction(meta *meta.Meta) bool {
+	return meta.Mode == mode.Embeddings && isRouter(meta.Channel.BaseURL)
+}
+
+func (a *Adaptor) GetRequestURL(
+	meta *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+) (adaptor.RequestURL, error) {
+	if !isFeatureExtraction(meta) {
+		return a.Adaptor.GetRequestURL(meta, store, c)
+	}
+
+	url, err := featureExtractionURL(meta.Channel.BaseURL, meta.ActualModel)
+	if err != nil {
+		return adaptor.RequestURL{}, err
+	}
+
+	return adaptor.RequestURL{
+		Method: http.MethodPost,
+		URL:    url,
+	}, nil
+}
+
+func (a *Adaptor) ConvertRequest(
+	meta *meta.Meta,
+	store adaptor.Store,
+	req *http.Request,
+) (adaptor.ConvertResult, error) {
+	if isFeatureExtraction(meta) {
+		return ConvertFeatureExtractionRequest(meta, req)
+	}
+
+	return a.Adaptor.ConvertRequest(meta, store, req)
+}
+
+func (a *Adaptor) DoRequest(
+	meta *meta.Meta,
+	_ adaptor.Store,
+	_ *gin.Context,
+	req *http.Request,
+) (*http.Response, error) {
+	return doRequestWithColdStart(meta, req)
+}
+
+func (a *Adaptor) DoResponse(
+	meta *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if resp.StatusCode != http.StatusOK {
+		return adaptor.DoResponseResult{}, ErrorHandler(resp)
+	}
+
+	if isFeatureExtraction(meta) {
+		return FeatureExtractionHandler(meta, c, resp)
+	}
+
+	return a.Adaptor.DoResponse(meta, store, c, resp)
+}
+
+func (a *Adaptor) Metadata() adaptor.Metadata {
+	return adaptor.Metadata{
+		Readme: "https://huggingface.co/docs/inference-providers\nHugging Face serverless inference router and Inference Endpoints\nThe default base url is the serverless router, set the base url to `https://<endpoint>.endpoints.huggingface.cloud/v1` for a dedicated endpoint running TGI or TEI\nThe key is a Hugging Face access token\nEmbeddings of the serverless router use the feature extraction pipeline\nRequests of a cold model waiting for loading are retried with the estimated time of the upstream, up to 3 times\nUse the model mapping of the channel to map the model names to the Hugging Face repository ids",
+		Models: ModelList,
+	}
+}
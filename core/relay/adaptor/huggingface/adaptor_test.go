@@ -0,0 +1,167 @@
+//nolint:testpackage
+package huggingface
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	coremodel "github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptorGetRequestURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		baseURL string
+		mode    mode.Mode
+		wantURL string
+	}{
+		{
+			name:    "router chat",
+			baseURL: baseURL,
+			mode:    mode.ChatCompletions,
+			wantURL: "https://router.huggingface.co/v1/chat/completions",
+		},
+		{
+			name:    "router embeddings",
+			baseURL: baseURL,
+			mode:    mode.Embeddings,
+			wantURL: "https://router.huggingface.co/hf-inference/models/BAAI/bge-m3/pipeline/feature-extraction",
+		},
+		{
+			name:    "endpoint embeddings",
+			baseURL: "https://xyz.us-east-1.aws.endpoints.huggingface.cloud/v1",
+			mode:    mode.Embeddings,
+			wantURL: "https://xyz.us-east-1.aws.endpoints.huggingface.cloud/v1/embeddings",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := meta.NewMeta(
+				&coremodel.Channel{BaseURL: tt.baseURL},
+				tt.mode,
+				"BAAI/bge-m3",
+				coremodel.ModelConfig{},
+			)
+
+			got, err := (&Adaptor{}).GetRequestURL(m, nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, http.MethodPost, got.Method)
+			assert.Equal(t, tt.wantURL, got.URL)
+		})
+	}
+}
+
+func TestDoRequestRetriesColdStart(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.Header.Get("X-Wait-For-Model"))
+
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"inputs":["hi"]}`, string(body))
+
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"error":"Model BAAI/bge-m3 is currently loading","estimated_time":0.01}`))
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`[[0.1,0.2]]`))
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		server.URL,
+		bytes.NewReader([]byte(`{"inputs":["hi"]}`)),
+	)
+	require.NoError(t, err)
+
+	m := meta.NewMeta(&coremodel.Channel{}, mode.Embeddings, "BAAI/bge-m3", coremodel.ModelConfig{})
+
+	resp, err := (&Adaptor{}).DoRequest(m, nil, nil, req)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), hits.Load())
+}
+
+func TestColdStartWait(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		body       string
+		wantRetry  bool
+	}{
+		{name: "estimated time", status: 503, body: `{"error":"loading","estimated_time":12.5}`, wantRetry: true},
+		{name: "loading message", status: 503, body: `{"error":"Model is currently loading"}`, wantRetry: true},
+		{name: "retry after", status: 503, retryAfter: "3", body: `upstream unavailable`, wantRetry: true},
+		{name: "overloaded", status: 503, body: `{"error":"Service overloaded"}`},
+		{name: "bad request", status: 400, body: `{"error":"loading"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Header:     http.Header{},
+				Body:       io.NopCloser(bytes.NewReader([]byte(tt.body))),
+			}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+
+			wait, ok := coldStartWait(resp)
+			assert.Equal(t, tt.wantRetry, ok)
+			assert.LessOrEqual(t, wait, maxColdStartWait)
+
+			// the body stays readable for the error handler
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, string(body))
+		})
+	}
+}
+
+func TestErrorHandlerWithBody(t *testing.T) {
+	t.Parallel()
+
+	err := ErrorHandlerWithBody(
+		http.StatusUnprocessableEntity,
+		[]byte(`{"error":"Input validation error","error_type":"validation"}`),
+	)
+	assert.Equal(t, http.StatusUnprocessableEntity, err.StatusCode())
+	assert.Contains(t, err.Error(), "Input validation error")
+
+	err = ErrorHandlerWithBody(
+		http.StatusUnauthorized,
+		[]byte(`{"error":{"message":"Invalid credentials","type":"invalid_request_error"}}`),
+	)
+	assert.Equal(t, http.StatusUnauthorized, err.StatusCode())
+	assert.Contains(t, err.Error(), "Invalid credentials")
+}
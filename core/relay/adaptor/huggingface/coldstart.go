@@ -0,0 +1,147 @@
+package huggingface
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/utils"
+)
+
+const (
+	// maxColdStartRetries limits the retries of a request waiting for the
+	// model loading
+	maxColdStartRetries = 3
+	// defaultColdStartWait is used when the upstream gives no estimated time
+	defaultColdStartWait = 5 * time.Second
+	// maxColdStartWait caps the wait before every retry
+	maxColdStartWait = 30 * time.Second
+)
+
+type loadingResponse struct {
+	Error         any     `json:"error"`
+	EstimatedTime float64 `json:"estimated_time"`
+}
+
+// coldStartWait reports whether the response is the 503 of a model still
+// loading and how long to wait before the retry, the body of the response
+// is kept readable
+func coldStartWait(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	body, err := common.GetResponseBody(resp)
+	_ = resp.Body.Close()
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+
+	if err != nil {
+		return 0, false
+	}
+
+	retryAfter, hasRetryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+
+	var loading loadingResponse
+	if err := sonic.Unmarshal(body, &loading); err != nil {
+		if !hasRetryAfter {
+			return 0, false
+		}
+
+		return capColdStartWait(retryAfter), true
+	}
+
+	switch {
+	case loading.EstimatedTime > 0:
+		return capColdStartWait(time.Duration(loading.EstimatedTime * float64(time.Second))), true
+	case hasRetryAfter:
+		return capColdStartWait(retryAfter), true
+	case isLoadingMessage(loading.Error):
+		return defaultColdStartWait, true
+	default:
+		return 0, false
+	}
+}
+
+func isLoadingMessage(v any) bool {
+	message, ok := v.(string)
+	if !ok {
+		return false
+	}
+
+	message = strings.ToLower(message)
+
+	return strings.Contains(message, "loading") ||
+		strings.Contains(message, "initializing")
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t), true
+	}
+
+	return 0, false
+}
+
+func capColdStartWait(wait time.Duration) time.Duration {
+	if wait <= 0 {
+		return defaultColdStartWait
+	}
+
+	return min(wait, maxColdStartWait)
+}
+
+// doRequestWithColdStart asks the serverless api to wait for the model and
+// retries the 503 of the scaled to zero endpoints until the model is loaded
+func doRequestWithColdStart(meta *meta.Meta, req *http.Request) (*http.Response, error) {
+	req.Header.Set("X-Wait-For-Model", "true")
+
+	for attempt := 0; ; attempt++ {
+		resp, err := utils.DoRequestWithMeta(req, meta)
+		if err != nil {
+			return nil, err
+		}
+
+		if attempt >= maxColdStartRetries || req.GetBody == nil {
+			return resp, nil
+		}
+
+		wait, ok := coldStartWait(resp)
+		if !ok {
+			return resp, nil
+		}
+
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			_ = body.Close()
+
+			return resp, nil
+		case <-timer.C:
+		}
+
+		_ = resp.Body.Close()
+		req.Body = body
+	}
+}
@@ -0,0 +1,46 @@
+package huggingface
+
+import (
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+// https://huggingface.co/docs/inference-providers
+
+var ModelList = []model.ModelConfig{
+	{
+		Model: "meta-llama/Llama-3.1-8B-Instruct",
+		Type:  mode.ChatCompletions,
+		Owner: model.ModelOwnerMeta,
+	},
+	{
+		Model: "meta-llama/Llama-3.3-70B-Instruct",
+		Type:  mode.ChatCompletions,
+		Owner: model.ModelOwnerMeta,
+	},
+	{
+		Model: "mistralai/Mistral-7B-Instruct-v0.3",
+		Type:  mode.ChatCompletions,
+		Owner: model.ModelOwnerMistral,
+	},
+	{
+		Model: "Qwen/Qwen2.5-72B-Instruct",
+		Type:  mode.ChatCompletions,
+		Owner: model.ModelOwnerAlibaba,
+	},
+	{
+		Model: "deepseek-ai/DeepSeek-R1",
+		Type:  mode.ChatCompletions,
+		Owner: model.ModelOwnerDeepSeek,
+	},
+	{
+		Model: "BAAI/bge-m3",
+		Type:  mode.Embeddings,
+		Owner: model.ModelOwnerBAAI,
+	},
+	{
+		Model: "sentence-transformers/all-MiniLM-L6-v2",
+		Type:  mode.Embeddings,
+		Owner: model.ModelOwnerHuggingFace,
+	},
+}
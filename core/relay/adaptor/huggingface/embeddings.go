@@ -0,0 +1,134 @@
+package huggingface
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	"github.com/labring/aiproxy/core/relay/utils"
+)
+
+// https://huggingface.co/docs/inference-providers/tasks/feature-extraction
+
+type FeatureExtractionRequest struct {
+	Inputs []string `json:"inputs"`
+}
+
+func featureExtractionURL(baseURL, model string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+
+	return url.JoinPath(
+		u.Scheme+"://"+u.Host,
+		"hf-inference/models",
+		model,
+		"pipeline/feature-extraction",
+	)
+}
+
+func ConvertFeatureExtractionRequest(
+	meta *meta.Meta,
+	req *http.Request,
+) (adaptor.ConvertResult, error) {
+	request, err := utils.UnmarshalGeneralOpenAIRequest(req)
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	request.Model = meta.ActualModel
+
+	data, err := sonic.Marshal(&FeatureExtractionRequest{
+		Inputs: request.ParseInput(),
+	})
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	return adaptor.ConvertResult{
+		Header: http.Header{
+			"Content-Type":   {"application/json"},
+			"Content-Length": {strconv.Itoa(len(data))},
+		},
+		Body: bytes.NewReader(data),
+	}, nil
+}
+
+func FeatureExtractionHandler(
+	meta *meta.Meta,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if resp.StatusCode != http.StatusOK {
+		return adaptor.DoResponseResult{}, ErrorHandler(resp)
+	}
+
+	defer resp.Body.Close()
+
+	var embeddings [][]float64
+
+	err := sonic.ConfigDefault.NewDecoder(resp.Body).Decode(&embeddings)
+	if err != nil {
+		return adaptor.DoResponseResult{}, relaymodel.WrapperOpenAIError(
+			err,
+			"unmarshal_response_body_failed",
+			http.StatusInternalServerError,
+		)
+	}
+
+	fullTextResponse := featureExtraction2OpenAI(meta, embeddings)
+
+	jsonResponse, err := sonic.Marshal(fullTextResponse)
+	if err != nil {
+		return adaptor.DoResponseResult{
+			Usage: fullTextResponse.Usage.ToModelUsage(),
+		}, relaymodel.WrapperOpenAIError(
+			err,
+			"marshal_response_body_failed",
+			http.StatusInternalServerError,
+		)
+	}
+
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.Header().Set("Content-Length", strconv.Itoa(len(jsonResponse)))
+	_, _ = c.Writer.Write(jsonResponse)
+
+	return adaptor.DoResponseResult{Usage: fullTextResponse.Usage.ToModelUsage()}, nil
+}
+
+// featureExtraction2OpenAI converts the pooled embeddings of the inputs, the
+// pipeline returns no usage so the tokens counted by the request are used
+func featureExtraction2OpenAI(
+	meta *meta.Meta,
+	embeddings [][]float64,
+) *relaymodel.EmbeddingResponse {
+	openAIEmbeddingResponse := relaymodel.EmbeddingResponse{
+		Object: "list",
+		Data:   make([]*relaymodel.EmbeddingResponseItem, 0, len(embeddings)),
+		Model:  meta.OriginModel,
+		Usage: relaymodel.EmbeddingUsage{
+			PromptTokens: int64(meta.RequestUsage.InputTokens),
+			TotalTokens:  int64(meta.RequestUsage.InputTokens),
+		},
+	}
+
+	for i, embedding := range embeddings {
+		openAIEmbeddingResponse.Data = append(
+			openAIEmbeddingResponse.Data,
+			&relaymodel.EmbeddingResponseItem{
+				Object:    "embedding",
+				Index:     i,
+				Embedding: embedding,
+			},
+		)
+	}
+
+	return &openAIEmbeddingResponse
+}
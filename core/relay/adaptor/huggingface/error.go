@@ -0,0 +1,58 @@
+package huggingface
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bytedance/sonic"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+)
+
+// errorResponse is the error of the inference api and TGI, the router
+// returns the errors of the OpenAI format
+type errorResponse struct {
+	Error     any    `json:"error"`
+	ErrorType string `json:"error_type"`
+}
+
+func ErrorHandler(resp *http.Response) adaptor.Error {
+	defer resp.Body.Close()
+
+	respBody, err := common.GetResponseBody(resp)
+	if err != nil {
+		return relaymodel.WrapperOpenAIError(
+			err,
+			"read_response_body_failed",
+			resp.StatusCode,
+		)
+	}
+
+	return ErrorHandlerWithBody(resp.StatusCode, respBody)
+}
+
+func ErrorHandlerWithBody(statusCode int, respBody []byte) adaptor.Error {
+	var errResponse errorResponse
+	if err := sonic.Unmarshal(respBody, &errResponse); err != nil {
+		return openai.ErrorHanlderWithBody(statusCode, respBody)
+	}
+
+	message, ok := errResponse.Error.(string)
+	if !ok || message == "" {
+		return openai.ErrorHanlderWithBody(statusCode, respBody)
+	}
+
+	code := errResponse.ErrorType
+	if code == "" {
+		code = relaymodel.ErrorCodeBadResponse
+	}
+
+	return relaymodel.NewOpenAIError(statusCode, relaymodel.OpenAIError{
+		Message: message,
+		Type:    relaymodel.ErrorTypeUpstream,
+		Code:    code,
+		Param:   strconv.Itoa(statusCode),
+	})
+}
@@ -41,6 +41,9 @@ type Metadata struct {
 	KeyHelp      string
 	Readme       string
 	Models       []model.ModelConfig
+	// Limitations are the known limitations of the adaptor not reflected by
+	// its modes, reported by the capability matrix
+	Limitations []string
 }
 
 type RequestURL struct {
@@ -75,6 +78,8 @@ type DoResponseResult struct {
 	UsageContext model.UsageContext
 	UpstreamID   string // ID from response body or x-request-id header
 	AsyncUsage   bool   // usage will be fetched asynchronously by upstream ID
+	// SystemFingerprint is the backend configuration reported by the upstream
+	SystemFingerprint string
 }
 
 type DoResponse interface {
@@ -123,6 +128,31 @@ type Error interface {
 
 var ErrGetBalanceNotImplemented = errors.New("get balance not implemented")
 
+// ErrHedgeLost is the cause of the request context of the attempt that lost
+// the race of a hedged request, it is canceled on purpose and is not a
+// channel error
+var ErrHedgeLost = errors.New("hedged request lost the race")
+
+// ErrInputAudioNotSupported is returned by the adaptors converting the openai
+// chat request to an api without audio input
+var ErrInputAudioNotSupported = errors.New(
+	"input_audio content is not supported by this model, use a model with audio input",
+)
+
+// NativeModeAdaptor is implemented by the adaptors relaying the requests of
+// some modes in the protocol of the client, e.g. the claude messages sent to an
+// anthropic compatible endpoint, instead of converting them to openai and back
+type NativeModeAdaptor interface {
+	NativeMode(meta *meta.Meta) bool
+}
+
+// RetrySafety is implemented by the adaptors of the providers billing some
+// requests once received, such a request is not retried on another channel
+// once it was sent, e.g. after a timeout, to avoid billing it twice
+type RetrySafety interface {
+	NonRetriableAfterSend(meta *meta.Meta) bool
+}
+
 type Balancer interface {
 	GetBalance(channel *model.Channel) (float64, error)
 }
@@ -132,3 +162,23 @@ type KeyValidator interface {
 }
 
 type ConfigValidator func(model.ChannelConfigs) error
+
+// Readiness is the state of a self hosted upstream reported by its probe
+type Readiness struct {
+	Ready bool
+	// Reason explains why the upstream is not ready
+	Reason string
+}
+
+// ReadinessProber is implemented by the adaptors of the self hosted inference
+// servers able to report whether they can take more requests, the channels
+// not ready are skipped by the channel selection until the next probe
+type ReadinessProber interface {
+	ProbeReadiness(ctx context.Context, channel *model.Channel) (Readiness, error)
+}
+
+// ModelLister is implemented by the adaptors able to list the models served by
+// the upstream, the base url of the channel is set by the caller
+type ModelLister interface {
+	ListModels(ctx context.Context, channel *model.Channel) ([]string, error)
+}
@@ -0,0 +1,144 @@
+package kling
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptor/registry"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/labring/aiproxy/core/relay/utils"
+)
+
+var _ adaptor.Adaptor = (*Adaptor)(nil)
+
+type Adaptor struct{}
+
+func init() {
+	registry.Register(model.ChannelTypeKling, &Adaptor{})
+}
+
+const baseURL = "https://api-singapore.klingai.com"
+
+func (a *Adaptor) DefaultBaseURL() string {
+	return baseURL
+}
+
+func (a *Adaptor) SupportMode(mt *meta.Meta) bool {
+	m := adaptor.ModeFromMeta(mt)
+
+	return m == mode.Videos ||
+		m == mode.VideosGet ||
+		m == mode.VideosContent
+}
+
+func (a *Adaptor) Metadata() adaptor.Metadata {
+	return adaptor.Metadata{
+		Readme:  "Kling AI video generation\nExposes text-to-video and image-to-video through the OpenAI-compatible /v1/videos API with async task polling\nKey format uses `access_key|secret_key`",
+		KeyHelp: "access_key|secret_key",
+		Models:  ModelList,
+	}
+}
+
+func (a *Adaptor) GetRequestURL(
+	meta *meta.Meta,
+	store adaptor.Store,
+	_ *gin.Context,
+) (adaptor.RequestURL, error) {
+	switch meta.Mode {
+	case mode.Videos:
+		endpoint := videoEndpointFromMeta(meta)
+
+		u, err := url.JoinPath(meta.Channel.BaseURL, "/v1/videos", endpoint)
+		if err != nil {
+			return adaptor.RequestURL{}, err
+		}
+
+		return adaptor.RequestURL{
+			Method: http.MethodPost,
+			URL:    u,
+		}, nil
+	case mode.VideosGet, mode.VideosContent:
+		metadata := loadVideoStoreMetadata(meta, store, meta.VideoID)
+		setVideoMetadata(meta, metadata)
+
+		u, err := url.JoinPath(
+			meta.Channel.BaseURL,
+			"/v1/videos",
+			metadata.endpoint(),
+			meta.VideoID,
+		)
+		if err != nil {
+			return adaptor.RequestURL{}, err
+		}
+
+		return adaptor.RequestURL{
+			Method: http.MethodGet,
+			URL:    u,
+		}, nil
+	default:
+		return adaptor.RequestURL{}, fmt.Errorf("unsupported relay mode %d for kling", meta.Mode)
+	}
+}
+
+func (a *Adaptor) SetupRequestHeader(
+	meta *meta.Meta,
+	_ adaptor.Store,
+	_ *gin.Context,
+	req *http.Request,
+) error {
+	token, err := getToken(meta.Channel.Key)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return nil
+}
+
+func (a *Adaptor) ConvertRequest(
+	meta *meta.Meta,
+	_ adaptor.Store,
+	req *http.Request,
+) (adaptor.ConvertResult, error) {
+	switch meta.Mode {
+	case mode.Videos:
+		return ConvertVideosRequest(meta, req)
+	case mode.VideosGet, mode.VideosContent:
+		return adaptor.ConvertResult{}, nil
+	default:
+		return adaptor.ConvertResult{}, fmt.Errorf("unsupported relay mode %d for kling", meta.Mode)
+	}
+}
+
+func (a *Adaptor) DoRequest(
+	meta *meta.Meta,
+	_ adaptor.Store,
+	_ *gin.Context,
+	req *http.Request,
+) (*http.Response, error) {
+	return utils.DoRequestWithMeta(req, meta)
+}
+
+func (a *Adaptor) DoResponse(
+	meta *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	switch meta.Mode {
+	case mode.Videos:
+		return VideosSubmitHandler(meta, store, c, resp)
+	case mode.VideosGet:
+		return VideosStatusHandler(meta, store, c, resp)
+	case mode.VideosContent:
+		return VideosContentHandler(meta, c, resp)
+	default:
+		return adaptor.DoResponseResult{}, ErrorHandler(resp)
+	}
+}
@@ -0,0 +1,164 @@
+package kling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/bytedance/sonic"
+	coremodel "github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/mode"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	relayutils "github.com/labring/aiproxy/core/relay/utils"
+)
+
+var _ adaptor.AsyncUsageFetcher = (*Adaptor)(nil)
+
+func (a *Adaptor) FetchAsyncUsage(
+	ctx context.Context,
+	request adaptor.AsyncUsageRequest,
+) (coremodel.Usage, coremodel.UsageContext, bool, error) {
+	info := request.Info
+	if info == nil {
+		return coremodel.Usage{}, coremodel.UsageContext{}, false, errors.New(
+			"async usage info is nil",
+		)
+	}
+
+	if mode.Mode(info.Mode) != mode.Videos {
+		return coremodel.Usage{}, coremodel.UsageContext{}, false, fmt.Errorf(
+			"unsupported async usage mode: %d",
+			info.Mode,
+		)
+	}
+
+	metadata := asyncVideoMetadataFromStore(request.Store, info)
+
+	data, err := a.fetchVideoTask(ctx, request.Channel, info, metadata.endpoint())
+	if err != nil {
+		return coremodel.Usage{}, coremodel.UsageContext{}, false, err
+	}
+
+	switch data.TaskStatus {
+	case relaymodel.KlingTaskStatusSucceed:
+		seconds := videoSeconds(data, metadata)
+		if seconds <= 0 {
+			return coremodel.Usage{}, coremodel.UsageContext{}, true, errors.New(
+				"kling video task succeeded without duration",
+			)
+		}
+
+		return coremodel.Usage{
+				OutputTokens: coremodel.ZeroNullInt64(seconds),
+				TotalTokens:  coremodel.ZeroNullInt64(seconds),
+			},
+			videoUsageContext(metadata).WithFallback(info.UsageContext),
+			true,
+			nil
+	case relaymodel.KlingTaskStatusSubmitted, relaymodel.KlingTaskStatusProcessing, "":
+		return coremodel.Usage{}, coremodel.UsageContext{}, false, nil
+	default:
+		return coremodel.Usage{}, coremodel.UsageContext{}, true, fmt.Errorf(
+			"kling video task ended with status %q: %s",
+			data.TaskStatus,
+			data.TaskStatusMsg,
+		)
+	}
+}
+
+func asyncVideoMetadataFromStore(
+	store adaptor.Store,
+	info *coremodel.AsyncUsageInfo,
+) videoStoreMetadata {
+	if store == nil || info.UpstreamID == "" {
+		return videoStoreMetadata{}
+	}
+
+	cache, err := store.GetStore(
+		info.GroupID,
+		info.TokenID,
+		coremodel.VideoGenerationStoreID(info.UpstreamID),
+	)
+	if err != nil {
+		return videoStoreMetadata{}
+	}
+
+	return parseVideoStoreMetadata(cache.Metadata)
+}
+
+func (a *Adaptor) fetchVideoTask(
+	ctx context.Context,
+	channel *coremodel.Channel,
+	info *coremodel.AsyncUsageInfo,
+	endpoint string,
+) (*relaymodel.KlingTaskData, error) {
+	if info.UpstreamID == "" {
+		return nil, errors.New("upstream id is empty")
+	}
+
+	if channel == nil {
+		return nil, errors.New("channel is nil")
+	}
+
+	baseURL := a.DefaultBaseURL()
+	if info.BaseURL != "" {
+		baseURL = info.BaseURL
+	} else if channel.BaseURL != "" {
+		baseURL = channel.BaseURL
+	}
+
+	requestURL, err := url.JoinPath(baseURL, "/v1/videos", endpoint, info.UpstreamID)
+	if err != nil {
+		return nil, fmt.Errorf("build kling video task url: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new kling video task request: %w", err)
+	}
+
+	key, err := channel.GetKey()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := getToken(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client, err := relayutils.LoadHTTPClientWithTLSConfigE(
+		0,
+		channel.ProxyURL,
+		channel.SkipTLSVerify,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do kling video task request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var response relaymodel.KlingTaskResponse
+	if err := sonic.ConfigDefault.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decode kling video task: %w", err)
+	}
+
+	if response.Code != 0 {
+		return nil, fmt.Errorf("kling video task error %d: %s", response.Code, response.Message)
+	}
+
+	return &response.Data, nil
+}
@@ -0,0 +1,40 @@
+package kling
+
+import (
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+// https://app.klingai.com/global/dev/document-api/apiReference/model/textToVideo
+//
+// Kling video usage is reported as generated seconds in output tokens,
+// set output_price with output_price_unit 1 for per-second billing or
+// per_request_price for per-clip billing. The std/pro mode is reported as
+// the usage quality so conditional prices can distinguish them.
+
+var ModelList = []model.ModelConfig{
+	{
+		Model:                     "kling-v2-1",
+		Type:                      mode.Videos,
+		Owner:                     model.ModelOwnerKling,
+		MaxVideoGenerationSeconds: 10,
+	},
+	{
+		Model:                     "kling-v2-1-master",
+		Type:                      mode.Videos,
+		Owner:                     model.ModelOwnerKling,
+		MaxVideoGenerationSeconds: 10,
+	},
+	{
+		Model:                     "kling-v2-master",
+		Type:                      mode.Videos,
+		Owner:                     model.ModelOwnerKling,
+		MaxVideoGenerationSeconds: 10,
+	},
+	{
+		Model:                     "kling-v1-6",
+		Type:                      mode.Videos,
+		Owner:                     model.ModelOwnerKling,
+		MaxVideoGenerationSeconds: 10,
+	},
+}
@@ -0,0 +1,44 @@
+package kling
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bytedance/sonic"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/conv"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+)
+
+func ErrorHandler(resp *http.Response) adaptor.Error {
+	defer resp.Body.Close()
+
+	respBody, err := common.GetResponseBody(resp)
+	if err != nil {
+		return relaymodel.NewOpenAIVideoError(resp.StatusCode, relaymodel.OpenAIVideoError{
+			Detail: err.Error(),
+		})
+	}
+
+	return relaymodel.NewOpenAIVideoError(resp.StatusCode, relaymodel.OpenAIVideoError{
+		Detail: errorMessageWithBody(resp.StatusCode, respBody),
+	})
+}
+
+func errorMessageWithBody(statusCode int, respBody []byte) string {
+	var response relaymodel.KlingTaskResponse
+	if err := sonic.Unmarshal(respBody, &response); err != nil || response.Message == "" {
+		if len(respBody) == 0 {
+			return fmt.Sprintf("bad response status code %d", statusCode)
+		}
+
+		return conv.BytesToString(respBody)
+	}
+
+	if response.Code != 0 {
+		return fmt.Sprintf("%s (code %d)", response.Message, response.Code)
+	}
+
+	return response.Message
+}
@@ -0,0 +1,46 @@
+package kling
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+)
+
+var _ adaptor.KeyValidator = (*Adaptor)(nil)
+
+func (a *Adaptor) ValidateKey(key string) error {
+	_, _, err := getAccessKeyAndSecretKey(key)
+	return err
+}
+
+// key format: access_key|secret_key
+func getAccessKeyAndSecretKey(key string) (string, string, error) {
+	parts := strings.Split(key, "|")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.New("invalid key format")
+	}
+
+	return parts[0], parts[1], nil
+}
+
+const tokenTTL = 30 * time.Minute
+
+// https://app.klingai.com/global/dev/document-api/apiReference/commonInfo
+func getToken(key string) (string, error) {
+	accessKey, secretKey, err := getAccessKeyAndSecretKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Issuer:    accessKey,
+		ExpiresAt: jwt.NewNumericDate(now.Add(tokenTTL)),
+		NotBefore: jwt.NewNumericDate(now.Add(-5 * time.Second)),
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secretKey))
+}
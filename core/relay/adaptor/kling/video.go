@@ -0,0 +1,591 @@
+package kling
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	coremodel "github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	relayutils "github.com/labring/aiproxy/core/relay/utils"
+)
+
+const (
+	metaKlingVideoMetadata = "kling_video_metadata"
+	// kling keeps generated videos for 30 days
+	klingVideoTTL = 30 * 24 * time.Hour
+
+	defaultKlingVideoDuration = 5
+	maxKlingVideoDuration     = 10
+)
+
+// openAIVideoRequest is the OpenAI /v1/videos request with the kling specific extensions
+type openAIVideoRequest struct {
+	Model          string         `json:"model"`
+	Prompt         string         `json:"prompt"`
+	Seconds        flexibleString `json:"seconds"`
+	Size           string         `json:"size"`
+	InputReference string         `json:"input_reference"`
+	Image          string         `json:"image"`
+	ImageTail      string         `json:"image_tail"`
+	NegativePrompt string         `json:"negative_prompt"`
+	CfgScale       *float64       `json:"cfg_scale"`
+	Mode           string         `json:"mode"`
+	AspectRatio    string         `json:"aspect_ratio"`
+	Duration       flexibleString `json:"duration"`
+}
+
+// flexibleString accepts both json strings and numbers
+type flexibleString string
+
+func (value *flexibleString) UnmarshalJSON(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	if text == "" || text == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(text, `"`) {
+		var raw string
+		if err := sonic.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+
+		text = raw
+	}
+
+	*value = flexibleString(strings.TrimSpace(text))
+
+	return nil
+}
+
+func (value flexibleString) Int() int {
+	number, err := json.Number(value).Float64()
+	if err != nil {
+		return 0
+	}
+
+	return int(math.Round(number))
+}
+
+type videoStoreMetadata struct {
+	Endpoint    string `json:"endpoint,omitempty"`
+	Prompt      string `json:"prompt,omitempty"`
+	Duration    int    `json:"duration,omitempty"`
+	AspectRatio string `json:"aspect_ratio,omitempty"`
+	Mode        string `json:"mode,omitempty"`
+}
+
+func (metadata videoStoreMetadata) endpoint() string {
+	if metadata.Endpoint == "" {
+		return relaymodel.KlingVideoEndpointText2Video
+	}
+
+	return metadata.Endpoint
+}
+
+func ConvertVideosRequest(meta *meta.Meta, req *http.Request) (adaptor.ConvertResult, error) {
+	raw, err := parseVideosRequest(req)
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	request := relaymodel.KlingVideoRequest{
+		ModelName:      meta.ActualModel,
+		Prompt:         raw.Prompt,
+		NegativePrompt: raw.NegativePrompt,
+		CfgScale:       raw.CfgScale,
+		Mode:           raw.Mode,
+		Duration:       strconv.Itoa(klingVideoDuration(raw)),
+		Image:          stripDataURLPrefix(firstNonEmpty(raw.Image, raw.InputReference)),
+		ImageTail:      stripDataURLPrefix(raw.ImageTail),
+	}
+
+	endpoint := relaymodel.KlingVideoEndpointText2Video
+	if request.Image != "" {
+		endpoint = relaymodel.KlingVideoEndpointImage2Video
+	} else {
+		request.AspectRatio = firstNonEmpty(raw.AspectRatio, aspectRatioFromSize(raw.Size))
+	}
+
+	if request.Prompt == "" && request.Image == "" {
+		return adaptor.ConvertResult{}, relaymodel.WrapperOpenAIVideoErrorWithMessage(
+			"prompt is required",
+			http.StatusBadRequest,
+		)
+	}
+
+	setVideoMetadata(meta, videoStoreMetadata{
+		Endpoint:    endpoint,
+		Prompt:      request.Prompt,
+		Duration:    klingVideoDuration(raw),
+		AspectRatio: request.AspectRatio,
+		Mode:        firstNonEmpty(request.Mode, "std"),
+	})
+
+	data, err := sonic.Marshal(&request)
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	return adaptor.ConvertResult{
+		Header: http.Header{
+			"Content-Type":   {"application/json"},
+			"Content-Length": {strconv.Itoa(len(data))},
+		},
+		Body: bytes.NewReader(data),
+	}, nil
+}
+
+func parseVideosRequest(req *http.Request) (openAIVideoRequest, error) {
+	if !strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		var raw openAIVideoRequest
+		if err := common.UnmarshalRequestReusable(req, &raw); err != nil {
+			return openAIVideoRequest{}, err
+		}
+
+		return raw, nil
+	}
+
+	if err := common.ParseMultipartFormWithLimit(req); err != nil {
+		return openAIVideoRequest{}, fmt.Errorf("parse multipart form: %w", err)
+	}
+
+	raw := openAIVideoRequest{
+		Model:          req.PostFormValue("model"),
+		Prompt:         req.PostFormValue("prompt"),
+		Seconds:        flexibleString(req.PostFormValue("seconds")),
+		Size:           req.PostFormValue("size"),
+		InputReference: req.PostFormValue("input_reference"),
+		Image:          req.PostFormValue("image"),
+		ImageTail:      req.PostFormValue("image_tail"),
+		NegativePrompt: req.PostFormValue("negative_prompt"),
+		Mode:           req.PostFormValue("mode"),
+		AspectRatio:    req.PostFormValue("aspect_ratio"),
+		Duration:       flexibleString(req.PostFormValue("duration")),
+	}
+
+	if cfgScale := req.PostFormValue("cfg_scale"); cfgScale != "" {
+		value, err := strconv.ParseFloat(cfgScale, 64)
+		if err != nil {
+			return openAIVideoRequest{}, fmt.Errorf("invalid cfg_scale: %w", err)
+		}
+
+		raw.CfgScale = &value
+	}
+
+	if raw.InputReference == "" {
+		image, err := multipartFileBase64(req, "input_reference")
+		if err != nil {
+			return openAIVideoRequest{}, err
+		}
+
+		raw.InputReference = image
+	}
+
+	return raw, nil
+}
+
+func multipartFileBase64(req *http.Request, field string) (string, error) {
+	files := req.MultipartForm.File[field]
+	if len(files) == 0 {
+		return "", nil
+	}
+
+	file, err := files[0].Open()
+	if err != nil {
+		return "", fmt.Errorf("open %s: %w", field, err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", field, err)
+	}
+
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// klingVideoDuration maps the requested seconds to the durations kling supports (5 or 10)
+func klingVideoDuration(raw openAIVideoRequest) int {
+	seconds := raw.Duration.Int()
+	if seconds <= 0 {
+		seconds = raw.Seconds.Int()
+	}
+
+	switch {
+	case seconds <= 0:
+		return defaultKlingVideoDuration
+	case seconds <= defaultKlingVideoDuration:
+		return defaultKlingVideoDuration
+	default:
+		return maxKlingVideoDuration
+	}
+}
+
+func aspectRatioFromSize(size string) string {
+	width, height, ok := strings.Cut(strings.ToLower(strings.TrimSpace(size)), "x")
+	if !ok {
+		return ""
+	}
+
+	w, err := strconv.Atoi(width)
+	if err != nil || w <= 0 {
+		return ""
+	}
+
+	h, err := strconv.Atoi(height)
+	if err != nil || h <= 0 {
+		return ""
+	}
+
+	switch {
+	case w > h:
+		return "16:9"
+	case w < h:
+		return "9:16"
+	default:
+		return "1:1"
+	}
+}
+
+// stripDataURLPrefix removes the data url prefix, kling expects raw base64 or an image url
+func stripDataURLPrefix(image string) string {
+	if !strings.HasPrefix(image, "data:") {
+		return image
+	}
+
+	if _, data, ok := strings.Cut(image, ","); ok {
+		return data
+	}
+
+	return image
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+func VideosSubmitHandler(
+	meta *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	response, relayErr := readTaskResponse(resp)
+	if relayErr != nil {
+		return adaptor.DoResponseResult{}, relayErr
+	}
+
+	if err := saveVideoStore(meta, store, response.Data.TaskID); err != nil {
+		common.GetLogger(c).Errorf("save kling video store failed: %v", err)
+	}
+
+	return writeVideoObject(c, buildVideo(meta, &response.Data), adaptor.DoResponseResult{
+		UpstreamID:   response.Data.TaskID,
+		AsyncUsage:   true,
+		UsageContext: videoUsageContext(videoMetadataFromMeta(meta)),
+	})
+}
+
+func VideosStatusHandler(
+	meta *meta.Meta,
+	_ adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	response, relayErr := readTaskResponse(resp)
+	if relayErr != nil {
+		return adaptor.DoResponseResult{}, relayErr
+	}
+
+	return writeVideoObject(c, buildVideo(meta, &response.Data), adaptor.DoResponseResult{
+		UpstreamID:   response.Data.TaskID,
+		UsageContext: videoUsageContext(videoMetadataFromMeta(meta)),
+	})
+}
+
+func VideosContentHandler(
+	meta *meta.Meta,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	response, relayErr := readTaskResponse(resp)
+	if relayErr != nil {
+		return adaptor.DoResponseResult{}, relayErr
+	}
+
+	videoURL := resultVideoURL(&response.Data)
+	if videoURL == "" {
+		return adaptor.DoResponseResult{}, relaymodel.WrapperOpenAIVideoErrorWithMessage(
+			"video is not ready",
+			http.StatusNotFound,
+		)
+	}
+
+	videoResp, err := fetchVideoContent(c.Request.Context(), meta, videoURL)
+	if err != nil {
+		return adaptor.DoResponseResult{}, relaymodel.WrapperOpenAIVideoError(
+			err,
+			http.StatusInternalServerError,
+		)
+	}
+	defer videoResp.Body.Close()
+
+	if videoResp.StatusCode != http.StatusOK {
+		return adaptor.DoResponseResult{}, relaymodel.WrapperOpenAIVideoErrorWithMessage(
+			fmt.Sprintf("unexpected video status code: %d", videoResp.StatusCode),
+			http.StatusInternalServerError,
+		)
+	}
+
+	c.Writer.Header().
+		Set("Content-Type", firstNonEmpty(videoResp.Header.Get("Content-Type"), "video/mp4"))
+	c.Writer.Header().Set("Content-Length", videoResp.Header.Get("Content-Length"))
+	_, _ = io.Copy(c.Writer, videoResp.Body)
+
+	return adaptor.DoResponseResult{UpstreamID: meta.VideoID}, nil
+}
+
+func readTaskResponse(resp *http.Response) (relaymodel.KlingTaskResponse, adaptor.Error) {
+	if resp.StatusCode != http.StatusOK {
+		return relaymodel.KlingTaskResponse{}, ErrorHandler(resp)
+	}
+
+	defer resp.Body.Close()
+
+	var response relaymodel.KlingTaskResponse
+	if err := common.UnmarshalResponse(resp, &response); err != nil {
+		return relaymodel.KlingTaskResponse{}, relaymodel.WrapperOpenAIVideoError(
+			err,
+			http.StatusInternalServerError,
+		)
+	}
+
+	if response.Code != 0 {
+		return relaymodel.KlingTaskResponse{}, relaymodel.WrapperOpenAIVideoErrorWithMessage(
+			response.Message,
+			http.StatusBadRequest,
+		)
+	}
+
+	if response.Data.TaskID == "" {
+		return relaymodel.KlingTaskResponse{}, relaymodel.WrapperOpenAIVideoErrorWithMessage(
+			"missing task_id in kling video response",
+			http.StatusInternalServerError,
+		)
+	}
+
+	return response, nil
+}
+
+func buildVideo(meta *meta.Meta, data *relaymodel.KlingTaskData) relaymodel.Video {
+	metadata := videoMetadataFromMeta(meta)
+
+	createdAt := data.CreatedAt / 1000
+	if createdAt <= 0 {
+		createdAt = time.Now().Unix()
+	}
+
+	video := relaymodel.Video{
+		ID:        data.TaskID,
+		Object:    relaymodel.VideoObject,
+		CreatedAt: createdAt,
+		Status:    videoStatus(data.TaskStatus),
+		Model:     meta.OriginModel,
+		Prompt:    metadata.Prompt,
+		Seconds:   videoSeconds(data, metadata),
+		Size:      metadata.AspectRatio,
+	}
+
+	switch video.Status {
+	case relaymodel.VideoStatusCompleted:
+		video.Progress = 100
+	case relaymodel.VideoStatusInProgress:
+		video.Progress = 50
+	}
+
+	if video.Status == relaymodel.VideoStatusFailed && data.TaskStatusMsg != "" {
+		video.Error = map[string]any{"message": data.TaskStatusMsg}
+	}
+
+	return video
+}
+
+// videoSeconds prefers the generated duration and falls back to the requested one
+func videoSeconds(data *relaymodel.KlingTaskData, metadata videoStoreMetadata) int {
+	for _, video := range data.TaskResult.Videos {
+		if seconds := flexibleString(video.Duration).Int(); seconds > 0 {
+			return seconds
+		}
+	}
+
+	return metadata.Duration
+}
+
+func resultVideoURL(data *relaymodel.KlingTaskData) string {
+	for _, video := range data.TaskResult.Videos {
+		if video.URL != "" {
+			return video.URL
+		}
+	}
+
+	return ""
+}
+
+func videoStatus(status string) relaymodel.VideoStatus {
+	switch status {
+	case relaymodel.KlingTaskStatusSucceed:
+		return relaymodel.VideoStatusCompleted
+	case relaymodel.KlingTaskStatusProcessing:
+		return relaymodel.VideoStatusInProgress
+	case relaymodel.KlingTaskStatusFailed:
+		return relaymodel.VideoStatusFailed
+	default:
+		return relaymodel.VideoStatusQueued
+	}
+}
+
+func videoUsageContext(metadata videoStoreMetadata) coremodel.UsageContext {
+	return coremodel.UsageContext{
+		Quality:    metadata.Mode,
+		InputMedia: new(metadata.Endpoint == relaymodel.KlingVideoEndpointImage2Video),
+	}
+}
+
+func writeVideoObject(
+	c *gin.Context,
+	value any,
+	result adaptor.DoResponseResult,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	data, err := sonic.Marshal(value)
+	if err != nil {
+		return result, relaymodel.WrapperOpenAIVideoError(err, http.StatusInternalServerError)
+	}
+
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	_, _ = c.Writer.Write(data)
+
+	return result, nil
+}
+
+func fetchVideoContent(
+	ctx context.Context,
+	meta *meta.Meta,
+	videoURL string,
+) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, videoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := relayutils.LoadHTTPClientWithTLSConfigE(
+		0,
+		meta.Channel.ProxyURL,
+		meta.Channel.SkipTLSVerify,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Do(req)
+}
+
+func saveVideoStore(meta *meta.Meta, store adaptor.Store, taskID string) error {
+	if store == nil || taskID == "" {
+		return nil
+	}
+
+	metadata, err := sonic.MarshalString(videoMetadataFromMeta(meta))
+	if err != nil {
+		return err
+	}
+
+	return store.SaveStore(adaptor.StoreCache{
+		ID:        coremodel.VideoGenerationStoreID(taskID),
+		GroupID:   meta.Group.ID,
+		TokenID:   meta.Token.ID,
+		ChannelID: meta.Channel.ID,
+		Model:     meta.OriginModel,
+		Metadata:  metadata,
+		ExpiresAt: time.Now().Add(klingVideoTTL),
+	})
+}
+
+func loadVideoStoreMetadata(
+	meta *meta.Meta,
+	store adaptor.Store,
+	taskID string,
+) videoStoreMetadata {
+	if store == nil || taskID == "" {
+		return videoStoreMetadata{}
+	}
+
+	cache, err := store.GetStore(
+		meta.Group.ID,
+		meta.Token.ID,
+		coremodel.VideoGenerationStoreID(taskID),
+	)
+	if err != nil {
+		return videoStoreMetadata{}
+	}
+
+	return parseVideoStoreMetadata(cache.Metadata)
+}
+
+func parseVideoStoreMetadata(data string) videoStoreMetadata {
+	if data == "" {
+		return videoStoreMetadata{}
+	}
+
+	var metadata videoStoreMetadata
+	if err := sonic.UnmarshalString(data, &metadata); err != nil {
+		return videoStoreMetadata{}
+	}
+
+	return metadata
+}
+
+func videoMetadataFromMeta(meta *meta.Meta) videoStoreMetadata {
+	if meta == nil {
+		return videoStoreMetadata{}
+	}
+
+	if value, ok := meta.Get(metaKlingVideoMetadata); ok {
+		metadata, _ := value.(videoStoreMetadata)
+		return metadata
+	}
+
+	return videoStoreMetadata{}
+}
+
+func setVideoMetadata(meta *meta.Meta, metadata videoStoreMetadata) {
+	if meta == nil || metadata == (videoStoreMetadata{}) {
+		return
+	}
+
+	meta.Set(metaKlingVideoMetadata, metadata)
+}
+
+func videoEndpointFromMeta(meta *meta.Meta) string {
+	return videoMetadataFromMeta(meta).endpoint()
+}
@@ -0,0 +1,132 @@
+//nolint:testpackage
+package kling
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	coremodel "github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+)
+
+func TestConvertVideosRequestText2Video(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"/v1/videos",
+		bytes.NewBufferString(
+			`{"model":"kling-v2-1","prompt":"a cat surfing","seconds":"8","size":"720x1280","mode":"pro"}`,
+		),
+	)
+	req.Header.Set("Content-Type", "application/json")
+
+	m := meta.NewMeta(nil, mode.Videos, "kling-v2-1", coremodel.ModelConfig{})
+	m.ActualModel = "kling-v2-1-master"
+
+	result, err := ConvertVideosRequest(m, req)
+	if err != nil {
+		t.Fatalf("ConvertVideosRequest returned error: %v", err)
+	}
+
+	var body relaymodel.KlingVideoRequest
+	if err := json.NewDecoder(result.Body).Decode(&body); err != nil {
+		t.Fatalf("decode converted body: %v", err)
+	}
+
+	if body.ModelName != "kling-v2-1-master" {
+		t.Fatalf("model_name was not rewritten: %q", body.ModelName)
+	}
+
+	if body.Duration != "10" {
+		t.Fatalf("unexpected duration: %q", body.Duration)
+	}
+
+	if body.AspectRatio != "9:16" {
+		t.Fatalf("unexpected aspect_ratio: %q", body.AspectRatio)
+	}
+
+	if endpoint := videoEndpointFromMeta(m); endpoint != relaymodel.KlingVideoEndpointText2Video {
+		t.Fatalf("unexpected endpoint: %q", endpoint)
+	}
+
+	if usageContext := videoUsageContext(videoMetadataFromMeta(m)); usageContext.Quality != "pro" {
+		t.Fatalf("unexpected usage quality: %q", usageContext.Quality)
+	}
+}
+
+func TestConvertVideosRequestImage2Video(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"/v1/videos",
+		bytes.NewBufferString(
+			`{"model":"kling-v2-1","prompt":"zoom in","input_reference":"data:image/png;base64,aGVsbG8="}`,
+		),
+	)
+	req.Header.Set("Content-Type", "application/json")
+
+	m := meta.NewMeta(nil, mode.Videos, "kling-v2-1", coremodel.ModelConfig{})
+
+	result, err := ConvertVideosRequest(m, req)
+	if err != nil {
+		t.Fatalf("ConvertVideosRequest returned error: %v", err)
+	}
+
+	var body relaymodel.KlingVideoRequest
+	if err := json.NewDecoder(result.Body).Decode(&body); err != nil {
+		t.Fatalf("decode converted body: %v", err)
+	}
+
+	if body.Image != "aGVsbG8=" {
+		t.Fatalf("data url prefix was not stripped: %q", body.Image)
+	}
+
+	if body.Duration != "5" {
+		t.Fatalf("unexpected default duration: %q", body.Duration)
+	}
+
+	if endpoint := videoEndpointFromMeta(m); endpoint != relaymodel.KlingVideoEndpointImage2Video {
+		t.Fatalf("unexpected endpoint: %q", endpoint)
+	}
+}
+
+func TestBuildVideoMapsTaskStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]relaymodel.VideoStatus{
+		relaymodel.KlingTaskStatusSubmitted:  relaymodel.VideoStatusQueued,
+		relaymodel.KlingTaskStatusProcessing: relaymodel.VideoStatusInProgress,
+		relaymodel.KlingTaskStatusSucceed:    relaymodel.VideoStatusCompleted,
+		relaymodel.KlingTaskStatusFailed:     relaymodel.VideoStatusFailed,
+	}
+
+	for status, want := range tests {
+		if got := videoStatus(status); got != want {
+			t.Fatalf("videoStatus(%q) = %q, want %q", status, got, want)
+		}
+	}
+
+	m := meta.NewMeta(nil, mode.VideosGet, "kling-v2-1", coremodel.ModelConfig{})
+	setVideoMetadata(m, videoStoreMetadata{Duration: 5})
+
+	video := buildVideo(m, &relaymodel.KlingTaskData{
+		TaskID:     "task-1",
+		TaskStatus: relaymodel.KlingTaskStatusSucceed,
+		TaskResult: relaymodel.KlingTaskResult{
+			Videos: []relaymodel.KlingVideoResult{{URL: "https://example.com/a.mp4", Duration: "5.1"}},
+		},
+	})
+
+	if video.Seconds != 5 || video.Progress != 100 {
+		t.Fatalf("unexpected video: %#v", video)
+	}
+}
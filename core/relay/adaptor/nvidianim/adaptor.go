@@ -0,0 +1,62 @@
+package nvidianim
+
+import (
+	"net/http"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
+	"github.com/labring/aiproxy/core/relay/adaptor/registry"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+// Adaptor supports the NVIDIA API catalog and the self hosted NIM and Triton
+// endpoints, all of them expose the OpenAI compatible api including the tool
+// calls, the self hosted endpoints report their readiness to the selector
+type Adaptor struct {
+	openai.Adaptor
+}
+
+func init() {
+	registry.Register(model.ChannelTypeNvidiaNIM, &Adaptor{})
+}
+
+const baseURL = "https://integrate.api.nvidia.com/v1"
+
+func (a *Adaptor) DefaultBaseURL() string {
+	return baseURL
+}
+
+func (a *Adaptor) SupportMode(mt *meta.Meta) bool {
+	m := adaptor.ModeFromMeta(mt)
+
+	return m == mode.ChatCompletions ||
+		m == mode.Completions ||
+		m == mode.Embeddings ||
+		m == mode.Anthropic ||
+		m == mode.Gemini
+}
+
+func (a *Adaptor) ConvertRequest(
+	meta *meta.Meta,
+	store adaptor.Store,
+	req *http.Request,
+) (adaptor.ConvertResult, error) {
+	if meta.Mode == mode.Embeddings {
+		return ConvertEmbeddingsRequest(meta, req)
+	}
+
+	return a.Adaptor.ConvertRequest(meta, store, req)
+}
+
+func (a *Adaptor) Metadata() adaptor.Metadata {
+	return adaptor.Metadata{
+		Readme:       "https://docs.nvidia.com/nim/large-language-models/latest/api-reference.html\nNVIDIA API catalog and self hosted NIM or Triton OpenAI compatible endpoints\nThe default base url is the API catalog, set the base url to `http://<host>:8000/v1` for a self hosted NIM\nThe key is an NVIDIA API key, any value works for a self hosted endpoint without auth\nThe self hosted endpoints are probed every 15 seconds with `/v1/health/ready` and `/v1/metrics`, the channel is skipped by the selector while not ready or while the gpu kv cache usage or the waiting requests reach the limits of the config\nSet `health_url` and `metrics_url` for a Triton endpoint, e.g. `http://<host>:8000/health/ready` and `http://<host>:8002/metrics`\nThe embedding requests without `input_type` are sent with the `embedding_input_type` of the config, `query` by default",
+		Models:       ModelList,
+		ConfigSchema: configSchema(),
+		Limitations: []string{
+			"the API catalog is not probed for readiness, only the self hosted endpoints are",
+		},
+	}
+}
@@ -0,0 +1,112 @@
+package nvidianim
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/labring/aiproxy/core/model"
+)
+
+const (
+	defaultMaxGPUCacheUsage   = 0.95
+	defaultEmbeddingInputType = "query"
+)
+
+type Config struct {
+	// HealthURL is the readiness endpoint, the `/v1/health/ready` of the base
+	// url by default
+	HealthURL string `json:"health_url"`
+	// MetricsURL is the prometheus metrics endpoint, the `/v1/metrics` of the
+	// base url by default
+	MetricsURL string `json:"metrics_url"`
+	// MaxGPUCacheUsage is the gpu kv cache usage from 0 to 1 at which the
+	// channel is not ready, 0.95 by default, a negative value disables it
+	MaxGPUCacheUsage float64 `json:"max_gpu_cache_usage"`
+	// MaxRequestsWaiting is the queued requests at which the channel is not
+	// ready, zero disables it
+	MaxRequestsWaiting float64 `json:"max_requests_waiting"`
+	// DisableReadinessProbe never probes the channel
+	DisableReadinessProbe bool `json:"disable_readiness_probe"`
+	// EmbeddingInputType is the input_type of the embedding requests without
+	// one, the retrieval embedding models require it
+	EmbeddingInputType string `json:"embedding_input_type"`
+}
+
+func loadConfig(configs model.ChannelConfigs) (Config, error) {
+	config := Config{}
+	if err := configs.LoadConfig(&config); err != nil {
+		return Config{}, err
+	}
+
+	if config.MaxGPUCacheUsage == 0 {
+		config.MaxGPUCacheUsage = defaultMaxGPUCacheUsage
+	}
+
+	if config.EmbeddingInputType == "" {
+		config.EmbeddingInputType = defaultEmbeddingInputType
+	}
+
+	return config, nil
+}
+
+// serverRoot returns the base url without the trailing `/v1`
+func serverRoot(baseURL string) string {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return strings.TrimSuffix(baseURL, "/v1")
+}
+
+func (c Config) healthURL(baseURL string) (string, error) {
+	if c.HealthURL != "" {
+		return c.HealthURL, nil
+	}
+
+	return url.JoinPath(serverRoot(baseURL), "/v1/health/ready")
+}
+
+func (c Config) metricsURL(baseURL string) (string, error) {
+	if c.MetricsURL != "" {
+		return c.MetricsURL, nil
+	}
+
+	return url.JoinPath(serverRoot(baseURL), "/v1/metrics")
+}
+
+func configSchema() map[string]any {
+	return map[string]any{
+		"type":  "object",
+		"title": "NVIDIA NIM Config",
+		"properties": map[string]any{
+			"health_url": map[string]any{
+				"type":        "string",
+				"title":       "Health URL",
+				"description": "Readiness endpoint of a self hosted endpoint, `/v1/health/ready` of the base url by default.",
+			},
+			"metrics_url": map[string]any{
+				"type":        "string",
+				"title":       "Metrics URL",
+				"description": "Prometheus metrics endpoint of a self hosted endpoint, `/v1/metrics` of the base url by default.",
+			},
+			"max_gpu_cache_usage": map[string]any{
+				"type":        "number",
+				"title":       "Max GPU Cache Usage",
+				"description": "GPU KV cache usage from 0 to 1 at which the channel is skipped, 0.95 by default, negative disables it.",
+			},
+			"max_requests_waiting": map[string]any{
+				"type":        "number",
+				"title":       "Max Requests Waiting",
+				"description": "Queued requests at which the channel is skipped, empty disables it.",
+			},
+			"disable_readiness_probe": map[string]any{
+				"type":        "boolean",
+				"title":       "Disable Readiness Probe",
+				"description": "Never probe the readiness of the channel.",
+			},
+			"embedding_input_type": map[string]any{
+				"type":        "string",
+				"title":       "Embedding Input Type",
+				"description": "input_type of the embedding requests without one, `query` by default.",
+				"enum":        []string{"query", "passage"},
+			},
+		},
+	}
+}
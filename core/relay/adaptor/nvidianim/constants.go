@@ -0,0 +1,46 @@
+package nvidianim
+
+import (
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+// https://build.nvidia.com/models
+
+var ModelList = []model.ModelConfig{
+	{
+		Model: "meta/llama-3.1-8b-instruct",
+		Type:  mode.ChatCompletions,
+		Owner: model.ModelOwnerMeta,
+	},
+	{
+		Model: "meta/llama-3.3-70b-instruct",
+		Type:  mode.ChatCompletions,
+		Owner: model.ModelOwnerMeta,
+	},
+	{
+		Model: "nvidia/llama-3.1-nemotron-70b-instruct",
+		Type:  mode.ChatCompletions,
+		Owner: model.ModelOwnerNvidia,
+	},
+	{
+		Model: "mistralai/mixtral-8x22b-instruct-v0.1",
+		Type:  mode.ChatCompletions,
+		Owner: model.ModelOwnerMistral,
+	},
+	{
+		Model: "deepseek-ai/deepseek-r1",
+		Type:  mode.ChatCompletions,
+		Owner: model.ModelOwnerDeepSeek,
+	},
+	{
+		Model: "nvidia/nv-embedqa-e5-v5",
+		Type:  mode.Embeddings,
+		Owner: model.ModelOwnerNvidia,
+	},
+	{
+		Model: "nvidia/llama-3.2-nv-embedqa-1b-v2",
+		Type:  mode.Embeddings,
+		Owner: model.ModelOwnerNvidia,
+	},
+}
@@ -0,0 +1,33 @@
+package nvidianim
+
+import (
+	"net/http"
+
+	"github.com/bytedance/sonic/ast"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
+	"github.com/labring/aiproxy/core/relay/meta"
+)
+
+// ConvertEmbeddingsRequest sets the input_type required by the retrieval
+// embedding models of NIM when the client sends none
+func ConvertEmbeddingsRequest(meta *meta.Meta, req *http.Request) (adaptor.ConvertResult, error) {
+	config, err := loadConfig(meta.ChannelConfigs)
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	return openai.ConvertEmbeddingsRequest(meta, req, false, func(node *ast.Node) error {
+		return patchEmbeddingsInputType(node, config.EmbeddingInputType)
+	})
+}
+
+func patchEmbeddingsInputType(node *ast.Node, inputType string) error {
+	if inputTypeNode := node.Get("input_type"); inputTypeNode != nil && inputTypeNode.Exists() {
+		return nil
+	}
+
+	_, err := node.Set("input_type", ast.NewString(inputType))
+
+	return err
+}
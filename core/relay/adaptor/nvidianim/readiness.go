@@ -0,0 +1,257 @@
+package nvidianim
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/utils"
+)
+
+const (
+	// catalogHost is the host of the NVIDIA API catalog, it is not probed
+	catalogHost = "integrate.api.nvidia.com"
+
+	probeTimeout = 5 * time.Second
+	// maxMetricsSize bounds the metrics read from an endpoint
+	maxMetricsSize = 4 << 20
+)
+
+// the gpu kv cache usage gauges of the NIM LLM and vLLM versions, from 0 to 1
+var gpuCacheUsageMetrics = map[string]struct{}{
+	"gpu_cache_usage_perc":      {},
+	"vllm:gpu_cache_usage_perc": {},
+	"vllm:kv_cache_usage_perc":  {},
+}
+
+// the queued requests gauges of NIM LLM, vLLM and Triton
+var requestsWaitingMetrics = map[string]struct{}{
+	"num_requests_waiting":               {},
+	"vllm:num_requests_waiting":          {},
+	"nv_inference_pending_request_count": {},
+}
+
+type serverMetrics struct {
+	// GPUCacheUsage is the highest gpu kv cache usage of the models
+	GPUCacheUsage    float64
+	hasGPUCacheUsage bool
+	// RequestsWaiting is the queued requests of all the models
+	RequestsWaiting    float64
+	hasRequestsWaiting bool
+}
+
+// parseMetrics reads the saturation gauges of the prometheus text exposition
+func parseMetrics(r io.Reader) (serverMetrics, error) {
+	metrics := serverMetrics{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, ok := parseSample(line)
+		if !ok {
+			continue
+		}
+
+		if _, ok := gpuCacheUsageMetrics[name]; ok {
+			metrics.GPUCacheUsage = max(metrics.GPUCacheUsage, value)
+			metrics.hasGPUCacheUsage = true
+		}
+
+		if _, ok := requestsWaitingMetrics[name]; ok {
+			metrics.RequestsWaiting += value
+			metrics.hasRequestsWaiting = true
+		}
+	}
+
+	return metrics, scanner.Err()
+}
+
+// parseSample parses a `name{labels} value [timestamp]` sample line
+func parseSample(line string) (string, float64, bool) {
+	name := line
+	rest := ""
+
+	if i := strings.IndexAny(line, "{ "); i >= 0 {
+		name = line[:i]
+		rest = line[i:]
+	}
+
+	if strings.HasPrefix(rest, "{") {
+		end := strings.LastIndex(rest, "}")
+		if end < 0 {
+			return "", 0, false
+		}
+
+		rest = rest[end+1:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", 0, false
+	}
+
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return name, value, true
+}
+
+// readiness checks the metrics against the limits of the config
+func (c Config) readiness(metrics serverMetrics) adaptor.Readiness {
+	if c.MaxGPUCacheUsage > 0 &&
+		metrics.hasGPUCacheUsage &&
+		metrics.GPUCacheUsage >= c.MaxGPUCacheUsage {
+		return adaptor.Readiness{
+			Reason: fmt.Sprintf(
+				"gpu cache usage %s reached %s",
+				strconv.FormatFloat(metrics.GPUCacheUsage, 'f', -1, 64),
+				strconv.FormatFloat(c.MaxGPUCacheUsage, 'f', -1, 64),
+			),
+		}
+	}
+
+	if c.MaxRequestsWaiting > 0 &&
+		metrics.hasRequestsWaiting &&
+		metrics.RequestsWaiting >= c.MaxRequestsWaiting {
+		return adaptor.Readiness{
+			Reason: fmt.Sprintf(
+				"waiting requests %s reached %s",
+				strconv.FormatFloat(metrics.RequestsWaiting, 'f', -1, 64),
+				strconv.FormatFloat(c.MaxRequestsWaiting, 'f', -1, 64),
+			),
+		}
+	}
+
+	return adaptor.Readiness{Ready: true}
+}
+
+func isCatalog(baseURL string) bool {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return false
+	}
+
+	return u.Hostname() == catalogHost
+}
+
+func probeGet(
+	ctx context.Context,
+	client *http.Client,
+	channel *model.Channel,
+	url string,
+) (*http.Response, error) {
+	key, err := channel.GetKey()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	return client.Do(req)
+}
+
+// ProbeReadiness reports a self hosted endpoint not ready while its health
+// check fails or its gpu is saturated, the API catalog is always ready
+func (a *Adaptor) ProbeReadiness(
+	ctx context.Context,
+	channel *model.Channel,
+) (adaptor.Readiness, error) {
+	baseURL := channel.BaseURL
+	if baseURL == "" {
+		baseURL = a.DefaultBaseURL()
+	}
+
+	configs, err := channel.GetConfigs()
+	if err != nil {
+		return adaptor.Readiness{}, err
+	}
+
+	config, err := loadConfig(configs)
+	if err != nil {
+		return adaptor.Readiness{}, err
+	}
+
+	if config.DisableReadinessProbe ||
+		(isCatalog(baseURL) && config.HealthURL == "" && config.MetricsURL == "") {
+		return adaptor.Readiness{Ready: true}, nil
+	}
+
+	healthURL, err := config.healthURL(baseURL)
+	if err != nil {
+		return adaptor.Readiness{}, err
+	}
+
+	metricsURL, err := config.metricsURL(baseURL)
+	if err != nil {
+		return adaptor.Readiness{}, err
+	}
+
+	client, err := utils.LoadHTTPClientWithTLSConfigE(
+		probeTimeout,
+		channel.ProxyURL,
+		channel.SkipTLSVerify,
+	)
+	if err != nil {
+		return adaptor.Readiness{}, err
+	}
+
+	resp, err := probeGet(ctx, client, channel, healthURL)
+	if err != nil {
+		if ctx.Err() != nil {
+			return adaptor.Readiness{}, ctx.Err()
+		}
+
+		// an endpoint not reachable is not ready, e.g. a pod restarting
+		return adaptor.Readiness{Reason: "health check failed: " + err.Error()}, nil
+	}
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxMetricsSize))
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return adaptor.Readiness{
+			Reason: fmt.Sprintf("health check status %d", resp.StatusCode),
+		}, nil
+	}
+
+	resp, err = probeGet(ctx, client, channel, metricsURL)
+	if err != nil {
+		// the metrics are optional, the health check is enough without them
+		return adaptor.Readiness{Ready: true}, nil //nolint:nilerr
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return adaptor.Readiness{Ready: true}, nil
+	}
+
+	metrics, err := parseMetrics(io.LimitReader(resp.Body, maxMetricsSize))
+	if err != nil {
+		return adaptor.Readiness{Ready: true}, nil //nolint:nilerr
+	}
+
+	return config.readiness(metrics), nil
+}
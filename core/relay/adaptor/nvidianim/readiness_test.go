@@ -0,0 +1,135 @@
+//nolint:testpackage
+package nvidianim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMetrics = `# HELP gpu_cache_usage_perc GPU KV-cache usage. 1 means 100 percent usage.
+# TYPE gpu_cache_usage_perc gauge
+gpu_cache_usage_perc{model_name="meta/llama-3.1-8b-instruct"} 0.42
+gpu_cache_usage_perc{model_name="meta/llama-3.1-70b-instruct"} 0.97
+# TYPE num_requests_waiting gauge
+num_requests_waiting{model_name="meta/llama-3.1-8b-instruct"} 3
+num_requests_waiting{model_name="meta/llama-3.1-70b-instruct"} 5 1700000000000
+num_requests_running 12
+`
+
+func TestParseMetrics(t *testing.T) {
+	t.Parallel()
+
+	metrics, err := parseMetrics(strings.NewReader(testMetrics))
+	require.NoError(t, err)
+	assert.InDelta(t, 0.97, metrics.GPUCacheUsage, 1e-9)
+	assert.InDelta(t, 8, metrics.RequestsWaiting, 1e-9)
+
+	metrics, err = parseMetrics(strings.NewReader(
+		"nv_inference_pending_request_count{model=\"ensemble\",version=\"1\"} 7\n",
+	))
+	require.NoError(t, err)
+	assert.False(t, metrics.hasGPUCacheUsage)
+	assert.True(t, metrics.hasRequestsWaiting)
+	assert.InDelta(t, 7, metrics.RequestsWaiting, 1e-9)
+}
+
+func TestConfigReadiness(t *testing.T) {
+	t.Parallel()
+
+	metrics := serverMetrics{
+		GPUCacheUsage:      0.9,
+		hasGPUCacheUsage:   true,
+		RequestsWaiting:    10,
+		hasRequestsWaiting: true,
+	}
+
+	config, err := loadConfig(nil)
+	require.NoError(t, err)
+	assert.True(t, config.readiness(metrics).Ready)
+
+	config.MaxRequestsWaiting = 10
+	readiness := config.readiness(metrics)
+	assert.False(t, readiness.Ready)
+	assert.Contains(t, readiness.Reason, "waiting requests")
+
+	config, err = loadConfig(model.ChannelConfigs{"max_gpu_cache_usage": 0.8})
+	require.NoError(t, err)
+
+	readiness = config.readiness(metrics)
+	assert.False(t, readiness.Ready)
+	assert.Contains(t, readiness.Reason, "gpu cache usage")
+
+	config.MaxGPUCacheUsage = -1
+	assert.True(t, config.readiness(metrics).Ready)
+}
+
+func TestProbeReadiness(t *testing.T) {
+	t.Parallel()
+
+	var healthStatus atomic.Int32
+	healthStatus.Store(http.StatusOK)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/health/ready":
+			w.WriteHeader(int(healthStatus.Load()))
+		case "/v1/metrics":
+			_, _ = w.Write([]byte(testMetrics))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	a := &Adaptor{}
+	channel := &model.Channel{BaseURL: server.URL + "/v1"}
+
+	readiness, err := a.ProbeReadiness(t.Context(), channel)
+	require.NoError(t, err)
+	assert.False(t, readiness.Ready)
+	assert.Contains(t, readiness.Reason, "gpu cache usage 0.97")
+
+	channel.Configs = model.ChannelConfigs{"max_gpu_cache_usage": 0.99}
+	readiness, err = a.ProbeReadiness(t.Context(), channel)
+	require.NoError(t, err)
+	assert.True(t, readiness.Ready)
+
+	healthStatus.Store(http.StatusServiceUnavailable)
+	readiness, err = a.ProbeReadiness(t.Context(), channel)
+	require.NoError(t, err)
+	assert.False(t, readiness.Ready)
+	assert.Equal(t, "health check status 503", readiness.Reason)
+
+	// the API catalog is not probed
+	readiness, err = a.ProbeReadiness(t.Context(), &model.Channel{})
+	require.NoError(t, err)
+	assert.True(t, readiness.Ready)
+}
+
+func TestPatchEmbeddingsInputType(t *testing.T) {
+	t.Parallel()
+
+	node, err := common.GetJSONNodeNoCopy([]byte(`{"input":"hello"}`))
+	require.NoError(t, err)
+	require.NoError(t, patchEmbeddingsInputType(&node, "query"))
+
+	inputType, err := node.Get("input_type").String()
+	require.NoError(t, err)
+	assert.Equal(t, "query", inputType)
+
+	node, err = common.GetJSONNodeNoCopy([]byte(`{"input":"hello","input_type":"passage"}`))
+	require.NoError(t, err)
+	require.NoError(t, patchEmbeddingsInputType(&node, "query"))
+
+	inputType, err = node.Get("input_type").String()
+	require.NoError(t, err)
+	assert.Equal(t, "passage", inputType)
+}
@@ -142,5 +142,8 @@ func (a *Adaptor) Metadata() adaptor.Metadata {
 	return adaptor.Metadata{
 		Readme: "Ollama local API\nSupports chat, completions, and embeddings\nDefault base URL points to a local Ollama instance",
 		Models: ModelList,
+		Limitations: []string{
+			"input_audio content of the chat requests is not supported",
+		},
 	}
 }
@@ -73,6 +73,8 @@ func ConvertRequest(meta *meta.Meta, req *http.Request) (adaptor.ConvertResult,
 				}
 
 				imageUrls = append(imageUrls, data)
+			case relaymodel.ContentTypeInputAudio:
+				return adaptor.ConvertResult{}, adaptor.ErrInputAudioNotSupported
 			}
 		}
 
@@ -261,10 +263,11 @@ func StreamHandler(
 
 	if err := scanner.Err(); err != nil {
 		log.Error("error reading stream: " + err.Error())
+		render.OpenaiStreamError(c, err)
+	} else {
+		render.OpenaiDone(c)
 	}
 
-	render.OpenaiDone(c)
-
 	if usage == nil {
 		return adaptor.DoResponseResult{Usage: meta.RequestUsage}, nil
 	}
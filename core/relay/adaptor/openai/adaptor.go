@@ -32,6 +32,12 @@ func (a *Adaptor) DefaultBaseURL() string {
 	return baseURL
 }
 
+// NonRetriableAfterSend reports the generation requests are not retried on
+// another channel once sent, they are billed even when the response is lost
+func (a *Adaptor) NonRetriableAfterSend(mt *meta.Meta) bool {
+	return adaptor.IsBilledOnReceiptMode(adaptor.ModeFromMeta(mt))
+}
+
 func (a *Adaptor) SupportMode(mt *meta.Meta) bool {
 	m := adaptor.ModeFromMeta(mt)
 
@@ -62,7 +68,11 @@ func (a *Adaptor) SupportMode(mt *meta.Meta) bool {
 		m == mode.ResponsesGet ||
 		m == mode.ResponsesDelete ||
 		m == mode.ResponsesCancel ||
-		m == mode.ResponsesInputItems
+		m == mode.ResponsesInputItems ||
+		m == mode.Files ||
+		m == mode.FilesGet ||
+		m == mode.FilesContent ||
+		m == mode.FilesDelete
 }
 
 //nolint:gocyclo
@@ -124,6 +134,46 @@ func (a *Adaptor) GetRequestURL(
 			Method: http.MethodGet,
 			URL:    url,
 		}, nil
+	case mode.Files:
+		url, err := url.JoinPath(u, "/files")
+		if err != nil {
+			return adaptor.RequestURL{}, err
+		}
+
+		return adaptor.RequestURL{
+			Method: http.MethodPost,
+			URL:    url,
+		}, nil
+	case mode.FilesGet:
+		url, err := url.JoinPath(u, "/files", meta.FileID)
+		if err != nil {
+			return adaptor.RequestURL{}, err
+		}
+
+		return adaptor.RequestURL{
+			Method: http.MethodGet,
+			URL:    url,
+		}, nil
+	case mode.FilesContent:
+		url, err := url.JoinPath(u, "/files", meta.FileID, "content")
+		if err != nil {
+			return adaptor.RequestURL{}, err
+		}
+
+		return adaptor.RequestURL{
+			Method: http.MethodGet,
+			URL:    url,
+		}, nil
+	case mode.FilesDelete:
+		url, err := url.JoinPath(u, "/files", meta.FileID)
+		if err != nil {
+			return adaptor.RequestURL{}, err
+		}
+
+		return adaptor.RequestURL{
+			Method: http.MethodDelete,
+			URL:    url,
+		}, nil
 	case mode.ChatCompletions, mode.Anthropic, mode.Gemini:
 		// Check if model requires Responses API
 		if IsResponsesOnlyModelAny(&meta.ModelConfig, meta.OriginModel, meta.ActualModel) {
@@ -423,6 +473,10 @@ func ConvertRequest(
 		return ConvertVideosContentRequest(meta, req)
 	case mode.VideosDelete:
 		return ConvertVideoNoBodyRequest(meta, req)
+	case mode.Files:
+		return ConvertFileUploadRequest(meta, req)
+	case mode.FilesGet, mode.FilesContent, mode.FilesDelete:
+		return adaptor.ConvertResult{}, nil
 	case mode.Gemini:
 		// Check if model requires Responses API conversion
 		if IsResponsesOnlyModelAny(&meta.ModelConfig, meta.OriginModel, meta.ActualModel) {
@@ -544,6 +598,14 @@ func DoResponse(
 		result, err = VideosContentHandler(meta, c, resp)
 	case mode.VideosDelete:
 		result, err = VideoDeleteHandler(meta, c, resp)
+	case mode.Files:
+		result, err = FileUploadHandler(meta, store, c, resp)
+	case mode.FilesGet:
+		result, err = FileGetHandler(meta, c, resp)
+	case mode.FilesContent:
+		result, err = FileContentHandler(meta, c, resp)
+	case mode.FilesDelete:
+		result, err = FileDeleteHandler(meta, store, c, resp)
 	case mode.Gemini:
 		// Check if model required Responses API conversion
 		if IsResponsesOnlyModelAny(&meta.ModelConfig, meta.OriginModel, meta.ActualModel) {
@@ -571,7 +633,10 @@ func DoResponse(
 	return result, err
 }
 
-const MetaResponseFormat = "response_format"
+const (
+	MetaResponseFormat = "response_format"
+	MetaImageStream    = "image_stream"
+)
 
 func (a *Adaptor) DoRequest(
 	meta *meta.Meta,
@@ -593,7 +658,7 @@ func (a *Adaptor) DoResponse(
 
 func (a *Adaptor) Metadata() adaptor.Metadata {
 	return adaptor.Metadata{
-		Readme:       "OpenAI native API\nSupports chat, completions, embeddings, moderations, image, audio, rerank, PDF parsing, video generation, and Responses API\nAlso supports Anthropic-compatible and Gemini-compatible request conversion on top of the OpenAI endpoint\nChannel config `map_reasoning_to_reasoning_content` rewrites upstream `reasoning` fields to `reasoning_content` in chat completion responses",
+		Readme:       "OpenAI native API\nSupports chat, completions, embeddings, moderations, image, audio, rerank, PDF parsing, video generation, files, and Responses API\nAlso supports Anthropic-compatible and Gemini-compatible request conversion on top of the OpenAI endpoint\nChannel config `map_reasoning_to_reasoning_content` rewrites upstream `reasoning` fields to `reasoning_content` in chat completion responses",
 		ConfigSchema: configSchema(),
 		Models:       ModelList,
 	}
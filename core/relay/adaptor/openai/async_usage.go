@@ -203,7 +203,9 @@ func (a *Adaptor) fetchAsyncUsageObject(
 		return nil, fmt.Errorf("new async usage request: %w", err)
 	}
 
-	setupOpenAIAsyncUsageRequestHeader(channel, req)
+	if err := setupOpenAIAsyncUsageRequestHeader(channel, req); err != nil {
+		return nil, err
+	}
 
 	client, err := relayutils.LoadHTTPClientWithTLSConfigE(
 		0,
@@ -238,9 +240,16 @@ func asyncUsageBaseURL(
 	return defaultBaseURL
 }
 
-func setupOpenAIAsyncUsageRequestHeader(channel *model.Channel, req *http.Request) {
-	req.Header.Set("Authorization", "Bearer "+channel.Key)
+func setupOpenAIAsyncUsageRequestHeader(channel *model.Channel, req *http.Request) error {
+	key, err := channel.GetKey()
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+key)
 	req.Header.Set("Content-Type", "application/json")
+
+	return nil
 }
 
 func calculateVideoUsage(job *relaymodel.VideoGenerationJob) (model.Usage, model.UsageContext) {
@@ -13,7 +13,12 @@ import (
 var _ adaptor.Balancer = (*Adaptor)(nil)
 
 func (a *Adaptor) GetBalance(channel *model.Channel) (float64, error) {
-	return GetBalance(channel.BaseURL, channel.Key)
+	key, err := channel.GetKey()
+	if err != nil {
+		return 0, err
+	}
+
+	return GetBalance(channel.BaseURL, key)
 }
 
 type SubscriptionResponse struct {
@@ -0,0 +1,107 @@
+package openai_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+var benchmarkClaudeRequest = []byte(`{
+	"model": "gpt-4o",
+	"max_tokens": 1024,
+	"stream": true,
+	"system": [{"type": "text", "text": "You are a helpful assistant."}],
+	"messages": [
+		{"role": "user", "content": "What is the weather like in Paris today?"},
+		{"role": "assistant", "content": [{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "Paris"}}]},
+		{"role": "user", "content": [{"type": "tool_result", "tool_use_id": "toolu_1", "content": "{\"temperature\":22}"}, {"type": "text", "text": "And tomorrow?"}]}
+	],
+	"tools": [{"name": "get_weather", "description": "Get the weather", "input_schema": {"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]}}]
+}`)
+
+var benchmarkChatRequest = []byte(`{
+	"model": "gpt-5",
+	"stream": true,
+	"messages": [
+		{"role": "system", "content": "You are a helpful assistant."},
+		{"role": "user", "content": "What is the weather like in Paris today?"},
+		{"role": "assistant", "content": null, "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}]},
+		{"role": "tool", "tool_call_id": "call_1", "content": "{\"temperature\":22}"}
+	],
+	"tools": [{"type": "function", "function": {"name": "get_weather", "parameters": {"type": "object", "properties": {"city": {"type": "string"}}}}}]
+}`)
+
+func newBenchmarkRequest(b *testing.B, body []byte) *http.Request {
+	b.Helper()
+
+	req, err := http.NewRequestWithContext(
+		b.Context(),
+		http.MethodPost,
+		"http://localhost/v1/messages",
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return req
+}
+
+func BenchmarkConvertClaudeRequest(b *testing.B) {
+	m := &meta.Meta{
+		ActualModel: "gpt-4o",
+		OriginModel: "gpt-4o",
+		Mode:        mode.Anthropic,
+	}
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if _, err := openai.ConvertClaudeRequest(m, newBenchmarkRequest(b, benchmarkClaudeRequest)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConvertChatCompletionsRequest(b *testing.B) {
+	m := &meta.Meta{
+		ActualModel: "gpt-5",
+		OriginModel: "gpt-5",
+		Mode:        mode.ChatCompletions,
+	}
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if _, err := openai.ConvertChatCompletionsRequest(
+			m,
+			newBenchmarkRequest(b, benchmarkChatRequest),
+			false,
+		); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConvertChatCompletionToResponsesRequest(b *testing.B) {
+	m := &meta.Meta{
+		ActualModel: "gpt-5",
+		OriginModel: "gpt-5",
+		Mode:        mode.ChatCompletions,
+	}
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if _, err := openai.ConvertChatCompletionToResponsesRequest(
+			m,
+			newBenchmarkRequest(b, benchmarkChatRequest),
+		); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -30,6 +30,10 @@ type chatCompletionStreamState struct {
 	currentToolCallID string
 	toolCallArgs      string
 	hasToolCall       bool
+	// hasReasoningSummary and pendingSummarySeparator join summary parts with a newline,
+	// matching the non-stream conversion
+	hasReasoningSummary     bool
+	pendingSummarySeparator bool
 }
 
 func responseModelName(meta *meta.Meta) string {
@@ -173,6 +177,12 @@ func (s *chatCompletionStreamState) handleOutputTextDelta(
 	}
 }
 
+func (s *chatCompletionStreamState) handleReasoningSummaryPartAdded() {
+	if s.hasReasoningSummary {
+		s.pendingSummarySeparator = true
+	}
+}
+
 func (s *chatCompletionStreamState) handleReasoningSummaryTextDelta(
 	event *relaymodel.ResponseStreamEvent,
 ) *relaymodel.ChatCompletionsStreamResponse {
@@ -180,6 +190,14 @@ func (s *chatCompletionStreamState) handleReasoningSummaryTextDelta(
 		return nil
 	}
 
+	delta := event.Delta
+	if s.pendingSummarySeparator {
+		delta = "\n" + delta
+		s.pendingSummarySeparator = false
+	}
+
+	s.hasReasoningSummary = true
+
 	return &relaymodel.ChatCompletionsStreamResponse{
 		ID:      s.messageID,
 		Object:  relaymodel.ChatCompletionChunkObject,
@@ -189,7 +207,7 @@ func (s *chatCompletionStreamState) handleReasoningSummaryTextDelta(
 			{
 				Index: 0,
 				Delta: relaymodel.Message{
-					ReasoningContent: event.Delta,
+					ReasoningContent: delta,
 				},
 			},
 		},
@@ -422,6 +440,8 @@ func ConvertChatCompletionsRequest(
 		}
 	}
 
+	recordStreamObfuscationOption(meta, &node)
+
 	if !doNotPatchStreamOptionsIncludeUsage {
 		if err := patchStreamOptions(&node); err != nil {
 			return adaptor.ConvertResult{}, convertRequestError(meta, err.Error())
@@ -543,8 +563,9 @@ func StreamHandler(
 	defer cleanup()
 
 	var (
-		usage      relaymodel.ChatUsage
-		upstreamID string
+		usage             relaymodel.ChatUsage
+		upstreamID        string
+		systemFingerprint string
 	)
 
 	for scanner.Scan() {
@@ -593,6 +614,10 @@ func StreamHandler(
 			}
 		}
 
+		if systemFingerprint == "" {
+			systemFingerprint = GetSystemFingerprintFromNode(&node)
+		}
+
 		for _, choice := range ch {
 			if usage.TotalTokens == 0 {
 				if choice.Text != "" {
@@ -600,6 +625,10 @@ func StreamHandler(
 				} else {
 					responseText.WriteString(choice.Delta.StringContent())
 				}
+
+				if choice.Delta.Audio != nil {
+					responseText.WriteString(choice.Delta.Audio.Transcript)
+				}
 			}
 		}
 
@@ -608,11 +637,16 @@ func StreamHandler(
 			log.Error("error set model: " + err.Error())
 		}
 
+		if meta.GetBool(MetaStripObfuscation) {
+			stripObfuscationFromNode(&node)
+		}
+
 		_ = render.OpenaiObjectData(c, &node)
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Error("error reading stream: " + err.Error())
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		log.Error("error reading stream: " + scanErr.Error())
 	}
 
 	if usage.TotalTokens == 0 && responseText.Len() > 0 {
@@ -634,14 +668,33 @@ func StreamHandler(
 		usage.CompletionTokens = usage.TotalTokens - int64(meta.RequestUsage.InputTokens)
 	}
 
-	render.OpenaiDone(c)
+	if scanErr != nil {
+		render.OpenaiStreamError(c, scanErr)
+	} else {
+		render.OpenaiDone(c)
+	}
 
 	return adaptor.DoResponseResult{
-		Usage:      usage.ToModelUsage(),
-		UpstreamID: upstreamID,
+		Usage:             usage.ToModelUsage(),
+		UpstreamID:        upstreamID,
+		SystemFingerprint: systemFingerprint,
 	}, nil
 }
 
+// GetSystemFingerprintFromNode returns the system_fingerprint of the response,
+// it changes when the upstream changes the backend serving the model
+func GetSystemFingerprintFromNode(node *ast.Node) string {
+	fingerprintNode := node.Get("system_fingerprint")
+	if fingerprintNode == nil || !fingerprintNode.Exists() ||
+		fingerprintNode.TypeSafe() != ast.V_STRING {
+		return ""
+	}
+
+	fingerprint, _ := fingerprintNode.String()
+
+	return fingerprint
+}
+
 func GetUsageOrChoicesResponseFromNode(
 	node *ast.Node,
 ) (*relaymodel.ChatUsage, []*relaymodel.TextResponseChoice, error) {
@@ -746,6 +799,9 @@ func Handler(
 			}
 
 			completionTokens += CountTokenText(choice.Message.StringContent(), meta.ActualModel)
+			if choice.Message.Audio != nil {
+				completionTokens += CountTokenText(choice.Message.Audio.Transcript, meta.ActualModel)
+			}
 		}
 
 		usage = &relaymodel.ChatUsage{
@@ -815,8 +871,9 @@ func Handler(
 	}
 
 	return adaptor.DoResponseResult{
-		Usage:      usage.ToModelUsage(),
-		UpstreamID: upstreamID,
+		Usage:             usage.ToModelUsage(),
+		UpstreamID:        upstreamID,
+		SystemFingerprint: GetSystemFingerprintFromNode(&node),
 	}, nil
 }
 
@@ -1154,11 +1211,25 @@ func ConvertMessagesToInputItems(messages []relaymodel.Message) []relaymodel.Inp
 		case []relaymodel.MessageContent:
 			// Array of MessageContent (from Claude conversion)
 			for _, part := range content {
-				if part.Type == relaymodel.ContentTypeText && part.Text != "" {
+				switch {
+				case part.Type == relaymodel.ContentTypeText && part.Text != "":
 					inputItem.Content = append(inputItem.Content, relaymodel.InputContent{
 						Type: contentType,
 						Text: part.Text,
 					})
+				case part.Type == relaymodel.ContentTypeImageURL && part.ImageURL != nil:
+					inputItem.Content = append(inputItem.Content, relaymodel.InputContent{
+						Type:     "input_image",
+						ImageURL: part.ImageURL.URL,
+						Detail:   part.ImageURL.Detail,
+					})
+				case part.Type == relaymodel.ContentTypeFile && part.File != nil:
+					inputItem.Content = append(inputItem.Content, relaymodel.InputContent{
+						Type:     "input_file",
+						FileID:   part.File.FileID,
+						Filename: part.File.Filename,
+						FileData: part.File.FileData,
+					})
 				}
 			}
 		case []any:
@@ -1199,6 +1270,13 @@ func ConvertChatCompletionToResponsesRequest(
 		Stream: chatReq.Stream,
 	}
 
+	if chatReq.Stream && chatReq.StreamOptions != nil &&
+		chatReq.StreamOptions.IncludeObfuscation != nil {
+		responsesReq.StreamOptions = &relaymodel.ResponseStreamOptions{
+			IncludeObfuscation: chatReq.StreamOptions.IncludeObfuscation,
+		}
+	}
+
 	// Map common fields
 	if chatReq.Temperature != nil {
 		responsesReq.Temperature = chatReq.Temperature
@@ -1270,6 +1348,7 @@ func ConvertChatCompletionToResponsesRequest(
 
 	reasoning := utils.ParseOpenAIReasoning(&chatReq)
 	applyReasoningToResponsesRequestForModel(meta, &responsesReq, reasoning)
+	applyReasoningDefaultsToResponsesRequest(meta, &responsesReq)
 
 	// Map metadata
 	if chatReq.Metadata != nil {
@@ -1359,13 +1438,22 @@ func ConvertResponsesToChatCompletionResponse(
 				},
 			}
 
-			var contentParts []string
+			var (
+				contentParts []string
+				logprobs     []relaymodel.TokenLogprob
+			)
+
 			for _, content := range outputItem.Content {
 				if (content.Type == "text" || content.Type == "output_text") && content.Text != "" {
 					contentParts = append(contentParts, content.Text)
+					logprobs = append(logprobs, content.Logprobs...)
 				}
 			}
 
+			if len(logprobs) > 0 {
+				choice.Logprobs = &relaymodel.ChoiceLogprobs{Content: logprobs}
+			}
+
 			if len(contentParts) > 0 {
 				choice.Message.Content = strings.Join(contentParts, "\n")
 			}
@@ -1629,6 +1717,8 @@ func ConvertResponsesToChatCompletionStreamResponse(
 			pendingInitialChunk = state.handleResponseCreated(&event)
 		case relaymodel.EventOutputTextDelta:
 			chatStreamResp = state.handleOutputTextDelta(&event)
+		case relaymodel.EventReasoningSummaryPartAdded:
+			state.handleReasoningSummaryPartAdded()
 		case relaymodel.EventReasoningSummaryTextDelta:
 			chatStreamResp = state.handleReasoningSummaryTextDelta(&event)
 		case relaymodel.EventOutputItemAdded:
@@ -1667,15 +1757,18 @@ func ConvertResponsesToChatCompletionStreamResponse(
 		writeChatStreamResp(chatStreamResp)
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Error("error reading response stream: " + err.Error())
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		log.Error("error reading response stream: " + scanErr.Error())
 	}
 
 	if errorState.pendingFailure != nil && !wroteStream {
 		return errorState.result(), responseStreamError(errorState.pendingFailure)
 	}
 
-	if wroteStream {
+	if scanErr != nil {
+		render.OpenaiStreamError(c, scanErr)
+	} else if wroteStream {
 		render.OpenaiDone(c)
 	}
 
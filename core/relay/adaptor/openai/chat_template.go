@@ -0,0 +1,478 @@
+package openai
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/bytedance/sonic/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/chattemplate"
+	"github.com/labring/aiproxy/core/common/conv"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+)
+
+// chatOnlyFields are the chat fields the completions requests do not have,
+// they are dropped when the messages are sent as a prompt
+var chatOnlyFields = []string{
+	"messages",
+	"tools",
+	"tool_choice",
+	"parallel_tool_calls",
+	"functions",
+	"function_call",
+	"response_format",
+	"logprobs",
+	"top_logprobs",
+	"modalities",
+	"audio",
+	"reasoning_effort",
+	"max_completion_tokens",
+}
+
+var _ adaptor.Adaptor = (*ChatTemplateAdaptor)(nil)
+
+// ChatTemplateAdaptor serves the chat completions of the models configured
+// with a chat template by the completions of the wrapped adaptor, the messages
+// are rendered into the prompt and the completions are converted back to the
+// chat format, for the local backends without a chat endpoint
+type ChatTemplateAdaptor struct {
+	adaptor.Adaptor
+}
+
+func NewChatTemplateAdaptor(a adaptor.Adaptor) adaptor.Adaptor {
+	return &ChatTemplateAdaptor{Adaptor: a}
+}
+
+// withCompletionsMode runs fn with the meta in the completions mode, the mode
+// is restored after fn returns
+func withCompletionsMode[T any](m *meta.Meta, fn func() T) T {
+	origin := m.Mode
+	m.Mode = mode.Completions
+
+	defer func() {
+		m.Mode = origin
+	}()
+
+	return fn()
+}
+
+// ChannelConfigChatTemplate is the channel config that opts the channel into
+// the chat templates, the chat requests of the models with a chat template are
+// then sent to the completions even if the channel serves the chat natively
+const ChannelConfigChatTemplate = "chat_template"
+
+// converting reports whether the chat request is rendered by the chat template
+// of the model and served by the completions of the wrapped adaptor, only the
+// adaptors without the chat completions and the opted in channels are converted
+func (a *ChatTemplateAdaptor) converting(m *meta.Meta) bool {
+	if m == nil || m.Mode != mode.ChatCompletions {
+		return false
+	}
+
+	if _, ok := m.ModelConfig.ChatTemplate(); !ok {
+		return false
+	}
+
+	if optIn, _ := m.ChannelConfigs[ChannelConfigChatTemplate].(bool); !optIn &&
+		a.Adaptor.SupportMode(m) {
+		return false
+	}
+
+	return withCompletionsMode(m, func() bool {
+		return a.Adaptor.SupportMode(m)
+	})
+}
+
+func (a *ChatTemplateAdaptor) SupportMode(m *meta.Meta) bool {
+	return a.converting(m) || a.Adaptor.SupportMode(m)
+}
+
+func (a *ChatTemplateAdaptor) GetRequestURL(
+	m *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+) (adaptor.RequestURL, error) {
+	if !a.converting(m) {
+		return a.Adaptor.GetRequestURL(m, store, c)
+	}
+
+	type result struct {
+		url adaptor.RequestURL
+		err error
+	}
+
+	r := withCompletionsMode(m, func() result {
+		url, err := a.Adaptor.GetRequestURL(m, store, c)
+		return result{url: url, err: err}
+	})
+
+	return r.url, r.err
+}
+
+func (a *ChatTemplateAdaptor) SetupRequestHeader(
+	m *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	req *http.Request,
+) error {
+	if !a.converting(m) {
+		return a.Adaptor.SetupRequestHeader(m, store, c, req)
+	}
+
+	return withCompletionsMode(m, func() error {
+		return a.Adaptor.SetupRequestHeader(m, store, c, req)
+	})
+}
+
+func (a *ChatTemplateAdaptor) ConvertRequest(
+	m *meta.Meta,
+	store adaptor.Store,
+	req *http.Request,
+) (adaptor.ConvertResult, error) {
+	if !a.converting(m) {
+		return a.Adaptor.ConvertRequest(m, store, req)
+	}
+
+	completionsBody, dropped, err := ConvertChatToCompletionsRequest(&m.ModelConfig, req)
+	if err != nil {
+		return adaptor.ConvertResult{}, convertRequestError(m, err.Error())
+	}
+
+	if len(dropped) > 0 {
+		common.GetLoggerFromReq(req).
+			Warnf("chat fields not supported by the chat template are dropped: %v", dropped)
+	}
+
+	completionsReq := req.Clone(req.Context())
+	common.SetRequestBody(completionsReq, completionsBody)
+
+	type result struct {
+		convert adaptor.ConvertResult
+		err     error
+	}
+
+	r := withCompletionsMode(m, func() result {
+		convert, err := a.Adaptor.ConvertRequest(m, store, completionsReq)
+		return result{convert: convert, err: err}
+	})
+
+	return r.convert, r.err
+}
+
+func (a *ChatTemplateAdaptor) DoRequest(
+	m *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	req *http.Request,
+) (*http.Response, error) {
+	if !a.converting(m) {
+		return a.Adaptor.DoRequest(m, store, c, req)
+	}
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+
+	r := withCompletionsMode(m, func() result {
+		resp, err := a.Adaptor.DoRequest(m, store, c, req)
+		return result{resp: resp, err: err}
+	})
+
+	return r.resp, r.err
+}
+
+func (a *ChatTemplateAdaptor) DoResponse(
+	m *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if !a.converting(m) {
+		return a.Adaptor.DoResponse(m, store, c, resp)
+	}
+
+	writer := c.Writer
+	c.Writer = &chatTemplateResponseWriter{ResponseWriter: writer}
+
+	defer func() {
+		c.Writer = writer
+	}()
+
+	type result struct {
+		result adaptor.DoResponseResult
+		err    adaptor.Error
+	}
+
+	r := withCompletionsMode(m, func() result {
+		res, err := a.Adaptor.DoResponse(m, store, c, resp)
+		return result{result: res, err: err}
+	})
+
+	return r.result, r.err
+}
+
+// ConvertChatToCompletionsRequest renders the messages of the chat request into
+// the prompt with the chat template of the model, the fields the completions
+// requests do not have are returned as dropped
+func ConvertChatToCompletionsRequest(
+	mc *model.ModelConfig,
+	req *http.Request,
+) ([]byte, []string, error) {
+	src, ok := mc.ChatTemplate()
+	if !ok {
+		return nil, nil, errors.New("chat template is not configured")
+	}
+
+	tmpl, err := chattemplate.Load(mc.Model, src)
+	if err != nil {
+		return nil, nil, errors.New("invalid chat template: " + err.Error())
+	}
+
+	node, err := common.UnmarshalRequest2NodeReusable(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vars, err := chatTemplateVars(mc, &node)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prompt, err := tmpl.Render(vars)
+	if err != nil {
+		return nil, nil, errors.New("render chat template failed: " + err.Error())
+	}
+
+	var dropped []string
+
+	if maxTokens := node.Get("max_completion_tokens"); maxTokens.Exists() &&
+		!node.Get("max_tokens").Exists() {
+		raw, err := maxTokens.Raw()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if _, err := node.Set("max_tokens", ast.NewRaw(raw)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, field := range chatOnlyFields {
+		v := node.Get(field)
+		if !v.Exists() {
+			continue
+		}
+
+		// the messages and the tools are rendered by the template, the max
+		// completion tokens is moved to the max tokens
+		rendered := field == "messages" || field == "tools" || field == "max_completion_tokens"
+		if !rendered && v.TypeSafe() != ast.V_NULL && v.TypeSafe() != ast.V_FALSE {
+			dropped = append(dropped, field)
+		}
+
+		if _, err := node.Unset(field); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if _, err := node.Set("prompt", ast.NewString(prompt)); err != nil {
+		return nil, nil, err
+	}
+
+	body, err := node.MarshalJSON()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return body, dropped, nil
+}
+
+// chatTemplateVars returns the variables of the chat template, the text parts
+// of the messages are joined as the content since the local models the
+// templates are configured for only take text
+func chatTemplateVars(mc *model.ModelConfig, node *ast.Node) (map[string]any, error) {
+	messagesNode := node.Get("messages")
+	if messagesNode.TypeSafe() != ast.V_ARRAY {
+		return nil, errors.New("messages is required")
+	}
+
+	messagesJSON, err := joinTextContents(messagesNode)
+	if err != nil {
+		return nil, err
+	}
+
+	// the objects are decoded in their order so the tojson of the template
+	// renders the messages and the tools like the request
+	messages, err := chattemplate.DecodeJSON(messagesJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	bosToken, _ := model.GetModelConfigString(mc.Config, model.ModelConfigChatTemplateBOSTokenKey)
+	eosToken, _ := model.GetModelConfigString(mc.Config, model.ModelConfigChatTemplateEOSTokenKey)
+
+	vars := map[string]any{
+		"messages":              messages,
+		"add_generation_prompt": true,
+		"bos_token":             bosToken,
+		"eos_token":             eosToken,
+	}
+
+	if tools := node.Get("tools"); tools.Exists() && tools.TypeSafe() == ast.V_ARRAY {
+		toolsRaw, err := tools.Raw()
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := chattemplate.DecodeJSON(conv.StringToBytes(toolsRaw))
+		if err != nil {
+			return nil, err
+		}
+
+		vars["tools"] = v
+	}
+
+	return vars, nil
+}
+
+// joinTextContents returns the json of the messages with the content parts
+// replaced by their joined text, the other parts are rejected
+func joinTextContents(messagesNode *ast.Node) ([]byte, error) {
+	messages, err := messagesNode.ArrayUseNode()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range messages {
+		content := messages[i].Get("content")
+		if content.TypeSafe() != ast.V_ARRAY {
+			continue
+		}
+
+		parts, err := content.ArrayUseNode()
+		if err != nil {
+			return nil, err
+		}
+
+		var text strings.Builder
+
+		for _, part := range parts {
+			if typ, _ := part.Get("type").String(); typ != "text" {
+				return nil, errors.New("only text content is supported by the chat template")
+			}
+
+			s, _ := part.Get("text").String()
+			text.WriteString(s)
+		}
+
+		if _, err := messages[i].Set("content", ast.NewString(text.String())); err != nil {
+			return nil, err
+		}
+	}
+
+	joined := ast.NewArray(messages)
+
+	return joined.MarshalJSON()
+}
+
+// chatTemplateResponseWriter converts the completions responses and the stream
+// chunks written by the adaptor to the chat format
+type chatTemplateResponseWriter struct {
+	gin.ResponseWriter
+}
+
+func (rw *chatTemplateResponseWriter) Write(b []byte) (int, error) {
+	stream := strings.HasPrefix(rw.Header().Get("Content-Type"), "text/event-stream")
+
+	out := ConvertCompletionsToChatResponse(b, stream)
+	if len(out) != len(b) && rw.Header().Get("Content-Length") != "" {
+		rw.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	}
+
+	n, err := rw.ResponseWriter.Write(out)
+	if err != nil {
+		return n, err
+	}
+
+	return len(b), nil
+}
+
+func (rw *chatTemplateResponseWriter) WriteString(s string) (int, error) {
+	return rw.Write(conv.StringToBytes(s))
+}
+
+// ConvertCompletionsToChatResponse converts a completion or a completions
+// stream chunk to the chat format, anything else is returned as it is
+func ConvertCompletionsToChatResponse(b []byte, stream bool) []byte {
+	if !bytes.Contains(b, choicesKeyBytes) {
+		return b
+	}
+
+	node, err := sonic.Get(b)
+	if err != nil || node.TypeSafe() != ast.V_OBJECT {
+		return b
+	}
+
+	choices, err := node.Get("choices").ArrayUseNode()
+	if err != nil {
+		return b
+	}
+
+	object := "chat.completion"
+	messageKey := "message"
+
+	if stream {
+		object = "chat.completion.chunk"
+		messageKey = "delta"
+	}
+
+	converted := make([]any, 0, len(choices))
+	for _, choice := range choices {
+		converted = append(converted, convertCompletionsChoice(&choice, messageKey))
+	}
+
+	if _, err := node.Set("choices", ast.NewAny(converted)); err != nil {
+		return b
+	}
+
+	if _, err := node.Set("object", ast.NewString(object)); err != nil {
+		return b
+	}
+
+	out, err := node.MarshalJSON()
+	if err != nil {
+		return b
+	}
+
+	return out
+}
+
+func convertCompletionsChoice(choice *ast.Node, messageKey string) map[string]any {
+	index, _ := choice.Get("index").Int64()
+	text, _ := choice.Get("text").String()
+
+	var finishReason any
+	if reason, err := choice.Get("finish_reason").String(); err == nil && reason != "" {
+		finishReason = reason
+	}
+
+	return map[string]any{
+		"index": index,
+		messageKey: map[string]any{
+			"role":    relaymodel.RoleAssistant,
+			"content": text,
+		},
+		"logprobs":      nil,
+		"finish_reason": finishReason,
+	}
+}
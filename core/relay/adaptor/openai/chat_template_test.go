@@ -0,0 +1,170 @@
+package openai_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testChatTemplate = `{{ bos_token }}{% for message in messages %}
+{{- '<|' + message.role + '|>' + message.content + eos_token }}
+{%- endfor %}
+{%- if add_generation_prompt %}<|assistant|>{% endif %}`
+
+func TestConvertChatToCompletionsRequest(t *testing.T) {
+	mc := model.ModelConfig{
+		Model: "m",
+		Config: model.NewModelConfig(
+			model.WithModelConfigChatTemplate(testChatTemplate, "<s>", "</s>"),
+		),
+	}
+
+	req := httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"/v1/chat/completions",
+		strings.NewReader(`{
+			"model":"m",
+			"messages":[
+				{"role":"system","content":"Be brief."},
+				{"role":"user","content":[{"type":"text","text":"Say "},{"type":"text","text":"hi"}]}
+			],
+			"max_completion_tokens":16,
+			"response_format":{"type":"json_object"},
+			"stream":true
+		}`),
+	)
+	req.Header.Set("Content-Type", "application/json")
+
+	body, dropped, err := openai.ConvertChatToCompletionsRequest(&mc, req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"response_format"}, dropped)
+
+	var request map[string]any
+	require.NoError(t, sonic.Unmarshal(body, &request))
+	assert.NotContains(t, request, "messages")
+	assert.NotContains(t, request, "max_completion_tokens")
+	assert.NotContains(t, request, "response_format")
+	assert.InDelta(t, 16, request["max_tokens"], 0)
+	assert.Equal(t, true, request["stream"])
+	assert.Equal(
+		t,
+		"<s><|system|>Be brief.</s><|user|>Say hi</s><|assistant|>",
+		request["prompt"],
+	)
+
+	req = httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"/v1/chat/completions",
+		strings.NewReader(
+			`{"model":"m","messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"x"}}]}]}`,
+		),
+	)
+
+	_, _, err = openai.ConvertChatToCompletionsRequest(&mc, req)
+	require.Error(t, err)
+}
+
+func TestConvertChatToCompletionsRequestKeepsToolOrder(t *testing.T) {
+	mc := model.ModelConfig{
+		Model: "tools",
+		Config: model.NewModelConfig(
+			model.WithModelConfigChatTemplate(
+				`{% for tool in tools %}{{ tool | tojson }}{% endfor %}|{{ messages[0] | tojson }}`,
+				"",
+				"",
+			),
+		),
+	}
+
+	req := httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"/v1/chat/completions",
+		strings.NewReader(`{
+			"model":"tools",
+			"messages":[{"role":"user","content":[{"type":"text","text":"hi"}],"name":"u"}],
+			"tools":[{"type":"function","function":{"name":"f","parameters":{"type":"object","properties":{"z":{},"a":{}}}}}]
+		}`),
+	)
+
+	body, _, err := openai.ConvertChatToCompletionsRequest(&mc, req)
+	require.NoError(t, err)
+
+	var request map[string]any
+	require.NoError(t, sonic.Unmarshal(body, &request))
+	assert.Equal(
+		t,
+		`{"type": "function", "function": {"name": "f", "parameters": {"type": "object", "properties": {"z": {}, "a": {}}}}}`+
+			`|{"role": "user", "content": "hi", "name": "u"}`,
+		request["prompt"],
+	)
+}
+
+func TestChatTemplateAdaptorOptIn(t *testing.T) {
+	mc := model.ModelConfig{
+		Model: "m",
+		Config: model.NewModelConfig(
+			model.WithModelConfigChatTemplate(testChatTemplate, "", ""),
+		),
+	}
+
+	a := openai.NewChatTemplateAdaptor(&openai.Adaptor{})
+
+	channel := &model.Channel{BaseURL: "http://localhost:8000/v1"}
+	u, err := a.GetRequestURL(meta.NewMeta(channel, mode.ChatCompletions, "m", mc), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8000/v1/chat/completions", u.URL)
+
+	channel.Configs = model.ChannelConfigs{openai.ChannelConfigChatTemplate: true}
+	u, err = a.GetRequestURL(meta.NewMeta(channel, mode.ChatCompletions, "m", mc), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8000/v1/completions", u.URL)
+}
+
+func TestConvertCompletionsToChatResponse(t *testing.T) {
+	out := openai.ConvertCompletionsToChatResponse([]byte(`{
+		"id":"1",
+		"object":"text_completion",
+		"model":"m",
+		"choices":[{"index":0,"text":"hi","logprobs":null,"finish_reason":"stop"}],
+		"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}
+	}`), false)
+
+	var response map[string]any
+	require.NoError(t, sonic.Unmarshal(out, &response))
+	assert.Equal(t, "chat.completion", response["object"])
+	assert.Equal(t, []any{map[string]any{
+		"index":         float64(0),
+		"message":       map[string]any{"role": "assistant", "content": "hi"},
+		"logprobs":      nil,
+		"finish_reason": "stop",
+	}}, response["choices"])
+
+	out = openai.ConvertCompletionsToChatResponse(
+		[]byte(`{"id":"1","object":"text_completion","choices":[{"index":0,"text":"h","finish_reason":null}]}`),
+		true,
+	)
+
+	response = nil
+	require.NoError(t, sonic.Unmarshal(out, &response))
+	assert.Equal(t, "chat.completion.chunk", response["object"])
+	assert.Equal(t, []any{map[string]any{
+		"index":         float64(0),
+		"delta":         map[string]any{"role": "assistant", "content": "h"},
+		"logprobs":      nil,
+		"finish_reason": nil,
+	}}, response["choices"])
+
+	assert.Equal(t, []byte("[DONE]"), openai.ConvertCompletionsToChatResponse([]byte("[DONE]"), true))
+}
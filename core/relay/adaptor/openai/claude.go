@@ -28,6 +28,8 @@ func ConvertClaudeRequest(
 		return adaptor.ConvertResult{}, err
 	}
 
+	joinSystemParts(openAIRequest)
+
 	for _, hook := range hooks {
 		if hook == nil {
 			continue
@@ -76,7 +78,12 @@ func ConvertClaudeRequestModel(
 	}
 
 	// Convert messages
-	openAIRequest.Messages = convertClaudeMessagesToOpenAI(claudeRequest)
+	documents := newClaudeDocumentConverter(meta, req)
+
+	openAIRequest.Messages = convertClaudeMessagesToOpenAI(claudeRequest, documents)
+	if documents.err != nil {
+		return nil, documents.err
+	}
 
 	// Convert tools
 	if len(claudeRequest.Tools) > 0 {
@@ -105,44 +112,75 @@ func ConvertClaudeRequestModel(
 	return &openAIRequest, nil
 }
 
+// joinSystemParts joins the text parts of the system messages with newlines,
+// many openai compatible upstreams only accept string system content
+func joinSystemParts(request *relaymodel.GeneralOpenAIRequest) {
+	for i := range request.Messages {
+		message := &request.Messages[i]
+
+		parts, ok := message.Content.([]relaymodel.MessageContent)
+		if message.Role != relaymodel.RoleSystem || !ok {
+			continue
+		}
+
+		texts := make([]string, 0, len(parts))
+		for _, part := range parts {
+			if part.Type == relaymodel.ContentTypeText {
+				texts = append(texts, part.Text)
+			}
+		}
+
+		message.Content = strings.Join(texts, "\n")
+	}
+}
+
 // convertClaudeMessagesToOpenAI converts Claude message format to OpenAI format
 func convertClaudeMessagesToOpenAI(
 	claudeRequest relaymodel.ClaudeAnyContentRequest,
+	documents *claudeDocumentConverter,
 ) []relaymodel.Message {
 	messages := make([]relaymodel.Message, 0)
 
-	// Add system messages
-	if len(claudeRequest.System) > 0 {
-		var systemContent strings.Builder
-		for i, content := range claudeRequest.System {
-			if i > 0 {
-				systemContent.WriteString("\n")
-			}
-
-			if content.Type == relaymodel.ClaudeContentTypeText {
-				systemContent.WriteString(content.Text)
-			}
-		}
-
-		if systemContent.Len() > 0 {
-			messages = append(messages, relaymodel.Message{
-				Role:    relaymodel.RoleSystem,
-				Content: systemContent.String(),
+	// Add system messages, multiple system blocks are kept as the text parts of
+	// the system message
+	var systemParts []relaymodel.MessageContent
+	for _, content := range claudeRequest.System {
+		if content.Type == relaymodel.ClaudeContentTypeText && content.Text != "" {
+			systemParts = append(systemParts, relaymodel.MessageContent{
+				Type: relaymodel.ContentTypeText,
+				Text: content.Text,
 			})
 		}
 	}
 
+	switch len(systemParts) {
+	case 0:
+	case 1:
+		messages = append(messages, relaymodel.Message{
+			Role:    relaymodel.RoleSystem,
+			Content: systemParts[0].Text,
+		})
+	default:
+		messages = append(messages, relaymodel.Message{
+			Role:    relaymodel.RoleSystem,
+			Content: systemParts,
+		})
+	}
+
 	// Convert regular messages
 	for _, msg := range claudeRequest.Messages {
 		openAIMsg := relaymodel.Message{
 			Role: msg.Role,
 		}
 
-		result := convertClaudeContent(msg.Content)
+		result := convertClaudeContent(msg.Content, documents)
 		messages = append(messages, result.Messages...)
 		openAIMsg.ToolCalls = result.ToolCalls
 
 		openAIMsg.Content = result.Content
+		if msg.Role == relaymodel.RoleAssistant {
+			setThinkingBlocks(&openAIMsg, result.ThinkingBlocks)
+		}
 		// Include the message if it has content OR tool calls
 		// This is important for function calling flow where assistant may only have tool calls
 		if openAIMsg.Content != nil || len(openAIMsg.ToolCalls) > 0 {
@@ -154,12 +192,48 @@ func convertClaudeMessagesToOpenAI(
 }
 
 type convertClaudeContentResult struct {
-	Content   any
-	ToolCalls []relaymodel.ToolCall
-	Messages  []relaymodel.Message
+	Content        any
+	ToolCalls      []relaymodel.ToolCall
+	Messages       []relaymodel.Message
+	ThinkingBlocks []relaymodel.ClaudeContent
+}
+
+// setThinkingBlocks keeps the thinking of the prior assistant turns as
+// reasoning_content and signature, the blocks are kept as is when they can not
+// be rebuilt from them, claude rejects the tool use turns without the signed
+// thinking blocks
+func setThinkingBlocks(message *relaymodel.Message, blocks []relaymodel.ClaudeContent) {
+	if len(blocks) == 0 {
+		return
+	}
+
+	thinking := make([]string, 0, len(blocks))
+	redacted := false
+
+	for _, block := range blocks {
+		if block.Type == relaymodel.ClaudeContentTypeRedactedThinking {
+			redacted = true
+			continue
+		}
+
+		thinking = append(thinking, block.Thinking)
+
+		if block.Signature != "" {
+			message.Signature = block.Signature
+		}
+	}
+
+	message.ReasoningContent = strings.Join(thinking, "\n\n")
+
+	if redacted || len(thinking) > 1 {
+		message.ThinkingBlocks = blocks
+	}
 }
 
-func convertClaudeContent(content any) convertClaudeContentResult {
+func convertClaudeContent(
+	content any,
+	documents *claudeDocumentConverter,
+) convertClaudeContentResult {
 	result := convertClaudeContentResult{}
 	switch content := content.(type) {
 	case string:
@@ -184,15 +258,24 @@ func convertClaudeContent(content any) convertClaudeContentResult {
 					Type: relaymodel.ContentTypeText,
 					Text: text,
 				})
-			case "thinking":
-				text := strings.TrimSpace(content.Thinking)
-				if text == "" {
+			case relaymodel.ClaudeContentTypeThinking:
+				if content.Thinking == "" && content.Signature == "" {
 					continue
 				}
 
-				parts = append(parts, relaymodel.MessageContent{
-					Type: relaymodel.ContentTypeText,
-					Text: text,
+				result.ThinkingBlocks = append(result.ThinkingBlocks, relaymodel.ClaudeContent{
+					Type:      relaymodel.ClaudeContentTypeThinking,
+					Thinking:  content.Thinking,
+					Signature: content.Signature,
+				})
+			case relaymodel.ClaudeContentTypeRedactedThinking:
+				if content.Data == "" {
+					continue
+				}
+
+				result.ThinkingBlocks = append(result.ThinkingBlocks, relaymodel.ClaudeContent{
+					Type: relaymodel.ClaudeContentTypeRedactedThinking,
+					Data: content.Data,
 				})
 			case relaymodel.ClaudeContentTypeImage:
 				if content.Source != nil {
@@ -210,6 +293,8 @@ func convertClaudeContent(content any) convertClaudeContentResult {
 						ImageURL: &imageURL,
 					})
 				}
+			case relaymodel.ClaudeContentTypeDocument:
+				parts = append(parts, documents.convert(content)...)
 			case "tool_use":
 				// Handle tool calls
 				args, _ := sonic.MarshalString(content.Input)
@@ -238,7 +323,7 @@ func convertClaudeContent(content any) convertClaudeContentResult {
 				case string:
 					newContent = v
 				case []any:
-					result := convertClaudeContent(v)
+					result := convertClaudeContent(v, documents)
 					newContent = result.Content
 				}
 
@@ -332,6 +417,8 @@ func convertClaudeToolChoice(toolChoice any) any {
 				return relaymodel.ToolChoiceRequired
 			case relaymodel.ToolChoiceAuto:
 				return relaymodel.ToolChoiceAuto
+			case relaymodel.ToolChoiceNone:
+				return relaymodel.ToolChoiceNone
 			}
 		}
 	}
@@ -437,6 +524,27 @@ func ClaudeStreamHandler(
 
 		// Process each choice
 		for _, choice := range openAIResponse.Choices {
+			// Handle the redacted thinking, the block is sent as a whole
+			for _, block := range choice.Delta.ThinkingBlocks {
+				if block.Type != relaymodel.ClaudeContentTypeRedactedThinking {
+					continue
+				}
+
+				closeCurrentBlock()
+
+				currentContentIndex++
+				currentContentType = relaymodel.ClaudeContentTypeRedactedThinking
+
+				_ = render.ClaudeObjectData(c, relaymodel.ClaudeStreamResponse{
+					Type:  relaymodel.ClaudeStreamTypeContentBlockStart,
+					Index: currentContentIndex,
+					ContentBlock: &relaymodel.ClaudeContent{
+						Type: relaymodel.ClaudeContentTypeRedactedThinking,
+						Data: block.Data,
+					},
+				})
+			}
+
 			// Handle reasoning/thinking content
 			if choice.Delta.ReasoningContent != "" {
 				// If we're not in a thinking block, start one
@@ -468,6 +576,19 @@ func ClaudeStreamHandler(
 				})
 			}
 
+			// The signature closes the thinking block
+			if choice.Delta.Signature != "" &&
+				currentContentType == relaymodel.ClaudeContentTypeThinking {
+				_ = render.ClaudeObjectData(c, relaymodel.ClaudeStreamResponse{
+					Type:  relaymodel.ClaudeStreamTypeContentBlockDelta,
+					Index: currentContentIndex,
+					Delta: &relaymodel.ClaudeDelta{
+						Type:      relaymodel.ClaudeDeltaTypeSignatureDelta,
+						Signature: choice.Delta.Signature,
+					},
+				})
+			}
+
 			// Handle text content
 			if content, ok := choice.Delta.Content.(string); ok && content != "" {
 				// If we're not in a text block, start one
@@ -548,8 +669,9 @@ func ClaudeStreamHandler(
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Error("error reading stream: " + err.Error())
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		log.Error("error reading stream: " + scanErr.Error())
 	}
 
 	// Close the last open content block
@@ -575,6 +697,12 @@ func ClaudeStreamHandler(
 		stopReason = relaymodel.ClaudeStopReasonEndTurn
 	}
 
+	// the interrupted stream ends with the error event instead of message_stop
+	if scanErr != nil {
+		render.ClaudeStreamError(c, scanErr)
+		return adaptor.DoResponseResult{Usage: usage.ToModelUsage()}, nil
+	}
+
 	// Send message_delta with final usage
 	_ = render.ClaudeObjectData(c, relaymodel.ClaudeStreamResponse{
 		Type: relaymodel.ClaudeStreamTypeMessageDelta,
@@ -639,6 +767,12 @@ func ClaudeHandler(
 
 	// Process each choice (typically only one)
 	for _, choice := range openAIResponse.Choices {
+		// Handle reasoning content, the thinking blocks come before the text
+		claudeResponse.Content = append(
+			claudeResponse.Content,
+			choice.Message.ClaudeThinkingBlocks()...,
+		)
+
 		// Handle text content
 		if content, ok := choice.Message.Content.(string); ok {
 			claudeResponse.Content = append(claudeResponse.Content, relaymodel.ClaudeContent{
@@ -647,14 +781,6 @@ func ClaudeHandler(
 			})
 		}
 
-		// Handle reasoning content (for o1 models)
-		if choice.Message.ReasoningContent != "" {
-			claudeResponse.Content = append(claudeResponse.Content, relaymodel.ClaudeContent{
-				Type:     relaymodel.ClaudeContentTypeThinking,
-				Thinking: choice.Message.ReasoningContent,
-			})
-		}
-
 		// Handle tool calls
 		for _, toolCall := range choice.Message.ToolCalls {
 			var input map[string]any
@@ -1067,14 +1193,19 @@ func ConvertResponsesToClaudeStreamResponse(
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Error("error reading response stream: " + err.Error())
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		log.Error("error reading response stream: " + scanErr.Error())
 	}
 
 	if errorState.pendingFailure != nil && !wroteStream {
 		return errorState.result(), responseStreamError(errorState.pendingFailure)
 	}
 
+	if scanErr != nil {
+		render.ClaudeStreamError(c, scanErr)
+	}
+
 	return errorState.result(), nil
 }
 
@@ -0,0 +1,174 @@
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labring/aiproxy/core/common/pdf"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/meta"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+)
+
+var ErrClaudeFileSourceUnsupported = errors.New(
+	"document file source is only supported by anthropic channels",
+)
+
+// claudeDocumentConverter converts the claude document blocks to the openai
+// content parts, the pdf documents are sent as configured by the model config
+type claudeDocumentConverter struct {
+	ctx        context.Context
+	meta       *meta.Meta
+	conversion string
+	err        error
+}
+
+func newClaudeDocumentConverter(meta *meta.Meta, req *http.Request) *claudeDocumentConverter {
+	return &claudeDocumentConverter{
+		ctx:        req.Context(),
+		meta:       meta,
+		conversion: meta.ModelConfig.PDFConversion(),
+	}
+}
+
+func (d *claudeDocumentConverter) convert(
+	content relaymodel.ClaudeContent,
+) []relaymodel.MessageContent {
+	if d == nil || d.err != nil || content.Source == nil {
+		return nil
+	}
+
+	var parts []relaymodel.MessageContent
+	if content.Title != "" {
+		parts = append(parts, documentTextPart(content.Title))
+	}
+
+	if content.Context != "" {
+		parts = append(parts, documentTextPart(content.Context))
+	}
+
+	source := content.Source
+
+	switch source.Type {
+	case relaymodel.ClaudeImageSourceTypeText:
+		return append(parts, documentTextPart(source.Data))
+	case relaymodel.ClaudeImageSourceTypeContent:
+		switch v := source.Content.(type) {
+		case string:
+			return append(parts, documentTextPart(v))
+		case []any:
+			if converted, ok := convertClaudeContent(v, d).Content.([]relaymodel.MessageContent); ok {
+				parts = append(parts, converted...)
+			}
+		}
+
+		return parts
+	case relaymodel.ClaudeImageSourceTypeFile:
+		d.err = ErrClaudeFileSourceUnsupported
+		return nil
+	case relaymodel.ClaudeImageSourceTypeBase64, relaymodel.ClaudeImageSourceTypeURL:
+		converted, err := d.convertPDF(source)
+		if err != nil {
+			d.err = err
+			return nil
+		}
+
+		return append(parts, converted...)
+	default:
+		return nil
+	}
+}
+
+func (d *claudeDocumentConverter) convertPDF(
+	source *relaymodel.ClaudeImageSource,
+) ([]relaymodel.MessageContent, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	if source.Type == relaymodel.ClaudeImageSourceTypeURL {
+		data, err = pdf.Download(d.ctx, source.URL)
+	} else {
+		data, err = pdf.DecodeBase64(source.Data)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read pdf document error: %w", err)
+	}
+
+	doc, err := pdf.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse pdf document error: %w", err)
+	}
+
+	d.meta.DocumentPages += int64(doc.PageCount())
+
+	switch d.conversion {
+	case model.PDFConversionText:
+		return pdfTextParts(doc.PageTexts()), nil
+	case model.PDFConversionImage:
+		return pdfImageParts(doc), nil
+	default:
+		return []relaymodel.MessageContent{
+			{
+				Type: relaymodel.ContentTypeFile,
+				File: &relaymodel.MessageFile{
+					Filename: "document.pdf",
+					FileData: "data:" + pdf.MediaType + ";base64," +
+						base64.StdEncoding.EncodeToString(data),
+				},
+			},
+		}, nil
+	}
+}
+
+func pdfTextParts(texts []string) []relaymodel.MessageContent {
+	parts := make([]relaymodel.MessageContent, 0, len(texts))
+	for i, text := range texts {
+		parts = append(parts, pdfPageText(i, text))
+	}
+
+	return parts
+}
+
+func pdfPageText(page int, text string) relaymodel.MessageContent {
+	return documentTextPart(fmt.Sprintf("[page %d]\n%s", page+1, strings.TrimSpace(text)))
+}
+
+// pdfImageParts sends the images of every page, the pages without images,
+// e.g. the pages of text, fall back to their text
+func pdfImageParts(doc *pdf.Document) []relaymodel.MessageContent {
+	texts := doc.PageTexts()
+
+	var parts []relaymodel.MessageContent
+
+	for i, images := range doc.PageImages() {
+		if len(images) == 0 {
+			parts = append(parts, pdfPageText(i, texts[i]))
+			continue
+		}
+
+		for _, image := range images {
+			parts = append(parts, relaymodel.MessageContent{
+				Type: relaymodel.ContentTypeImageURL,
+				ImageURL: &relaymodel.ImageURL{
+					URL: "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(image),
+				},
+			})
+		}
+	}
+
+	return parts
+}
+
+func documentTextPart(text string) relaymodel.MessageContent {
+	return relaymodel.MessageContent{
+		Type: relaymodel.ContentTypeText,
+		Text: text,
+	}
+}
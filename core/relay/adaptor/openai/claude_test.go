@@ -3,14 +3,17 @@ package openai_test
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/model"
 	"github.com/labring/aiproxy/core/relay/adaptor/openai"
 	"github.com/labring/aiproxy/core/relay/meta"
 	relaymodel "github.com/labring/aiproxy/core/relay/model"
@@ -653,3 +656,235 @@ func TestConvertResponsesToClaudeStreamResponseReturnsErrorBeforeRealOutputAfter
 	assert.Equal(t, "resp_123", result.UpstreamID)
 	assert.Empty(t, w.Body.String())
 }
+
+func TestConvertClaudeRequest_Document(t *testing.T) {
+	t.Parallel()
+
+	content := "BT /F1 12 Tf 72 712 Td (Quarterly report) Tj ET"
+	document := "%PDF-1.4\n" +
+		"1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		"2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 >>\nendobj\n" +
+		"3 0 obj\n<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>\nendobj\n" +
+		"4 0 obj\n<< /Length " + strconv.Itoa(len(content)) + " >>\nstream\n" +
+		content + "\nendstream\nendobj\n%%EOF\n"
+
+	newRequest := func(source string) *http.Request {
+		requestJSON := `{
+			"model": "claude",
+			"max_tokens": 1024,
+			"messages": [{"role": "user", "content": [
+				{"type": "document", "source": ` + source + `},
+				{"type": "text", "text": "Summarize it"}
+			]}]
+		}`
+		httpReq := httptest.NewRequestWithContext(t.Context(),
+			http.MethodPost,
+			"/v1/messages",
+			bytes.NewReader([]byte(requestJSON)),
+		)
+		httpReq.Header.Set("Content-Type", "application/json")
+
+		return httpReq
+	}
+
+	base64Source := `{"type": "base64", "media_type": "application/pdf", "data": "` +
+		base64.StdEncoding.EncodeToString([]byte(document)) + `"}`
+
+	t.Run("text", func(t *testing.T) {
+		t.Parallel()
+
+		m := &meta.Meta{ActualModel: "gpt-4o"}
+		m.ModelConfig.Config = model.NewModelConfig(
+			model.WithModelConfigPDFConversion(model.PDFConversionText),
+		)
+
+		openAIReq, err := openai.ConvertClaudeRequestModel(m, newRequest(base64Source))
+		require.NoError(t, err)
+		require.Len(t, openAIReq.Messages, 1)
+
+		parts := openAIReq.Messages[0].ParseContent()
+		require.Len(t, parts, 2)
+		assert.Equal(t, "[page 1]\nQuarterly report", parts[0].Text)
+		assert.Equal(t, "Summarize it", parts[1].Text)
+		assert.Equal(t, int64(1), m.DocumentPages)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		t.Parallel()
+
+		m := &meta.Meta{ActualModel: "gpt-4o"}
+
+		openAIReq, err := openai.ConvertClaudeRequestModel(m, newRequest(base64Source))
+		require.NoError(t, err)
+
+		parts := openAIReq.Messages[0].ParseContent()
+		require.Len(t, parts, 2)
+		assert.Equal(t, relaymodel.ContentTypeFile, parts[0].Type)
+		require.NotNil(t, parts[0].File)
+		assert.True(t, strings.HasPrefix(parts[0].File.FileData, "data:application/pdf;base64,"))
+	})
+
+	t.Run("file id", func(t *testing.T) {
+		t.Parallel()
+
+		m := &meta.Meta{ActualModel: "gpt-4o"}
+
+		_, err := openai.ConvertClaudeRequestModel(
+			m,
+			newRequest(`{"type": "file", "file_id": "file_011"}`),
+		)
+		require.ErrorIs(t, err, openai.ErrClaudeFileSourceUnsupported)
+	})
+}
+
+func TestConvertClaudeRequest_Image(t *testing.T) {
+	t.Parallel()
+
+	requestJSON := `{
+		"model": "claude",
+		"max_tokens": 1024,
+		"messages": [{"role": "user", "content": [
+			{"type": "image", "source": {"type": "url", "url": "https://example.com/cat.png"}},
+			{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "aGVsbG8="}},
+			{"type": "text", "text": "Compare them"}
+		]}]
+	}`
+	httpReq := httptest.NewRequestWithContext(t.Context(),
+		http.MethodPost,
+		"/v1/messages",
+		bytes.NewReader([]byte(requestJSON)),
+	)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	openAIReq, err := openai.ConvertClaudeRequestModel(&meta.Meta{ActualModel: "gpt-4o"}, httpReq)
+	require.NoError(t, err)
+	require.Len(t, openAIReq.Messages, 1)
+
+	parts := openAIReq.Messages[0].ParseContent()
+	require.Len(t, parts, 3)
+
+	// the url images are passed through without being downloaded
+	assert.Equal(t, relaymodel.ContentTypeImageURL, parts[0].Type)
+	require.NotNil(t, parts[0].ImageURL)
+	assert.Equal(t, "https://example.com/cat.png", parts[0].ImageURL.URL)
+
+	assert.Equal(t, relaymodel.ContentTypeImageURL, parts[1].Type)
+	require.NotNil(t, parts[1].ImageURL)
+	assert.Equal(t, "data:image/png;base64,aGVsbG8=", parts[1].ImageURL.URL)
+
+	assert.Equal(t, "Compare them", parts[2].Text)
+}
+
+func TestConvertClaudeRequest_ThinkingRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	requestJSON := `{
+		"model": "claude",
+		"max_tokens": 1024,
+		"thinking": {"type": "enabled", "budget_tokens": 512},
+		"messages": [
+			{"role": "user", "content": "What is the weather?"},
+			{"role": "assistant", "content": [
+				{"type": "thinking", "thinking": "Call the tool.", "signature": "sig_1"},
+				{"type": "redacted_thinking", "data": "encrypted"},
+				{"type": "tool_use", "id": "toolu_1", "name": "weather", "input": {"city": "Paris"}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": "sunny"}
+			]},
+			{"role": "assistant", "content": [
+				{"type": "thinking", "thinking": "It is sunny.", "signature": "sig_2"},
+				{"type": "text", "text": "Sunny."}
+			]}
+		]
+	}`
+	httpReq := httptest.NewRequestWithContext(t.Context(),
+		http.MethodPost,
+		"/v1/messages",
+		bytes.NewReader([]byte(requestJSON)),
+	)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	openAIReq, err := openai.ConvertClaudeRequestModel(&meta.Meta{ActualModel: "gpt-4o"}, httpReq)
+	require.NoError(t, err)
+	require.Len(t, openAIReq.Messages, 4)
+
+	toolUse := openAIReq.Messages[1]
+	assert.Equal(t, "Call the tool.", toolUse.ReasoningContent)
+	assert.Equal(t, "sig_1", toolUse.Signature)
+	assert.Nil(t, toolUse.Content)
+	assert.Equal(t, []relaymodel.ClaudeContent{
+		{Type: relaymodel.ClaudeContentTypeThinking, Thinking: "Call the tool.", Signature: "sig_1"},
+		{Type: relaymodel.ClaudeContentTypeRedactedThinking, Data: "encrypted"},
+	}, toolUse.ThinkingBlocks)
+
+	// a single signed block is kept as reasoning_content and signature only
+	answer := openAIReq.Messages[3]
+	assert.Equal(t, "It is sunny.", answer.ReasoningContent)
+	assert.Equal(t, "sig_2", answer.Signature)
+	assert.Empty(t, answer.ThinkingBlocks)
+	assert.Equal(t, []relaymodel.ClaudeContent{
+		{Type: relaymodel.ClaudeContentTypeThinking, Thinking: "It is sunny.", Signature: "sig_2"},
+	}, answer.ClaudeThinkingBlocks())
+
+	parts := answer.ParseContent()
+	require.Len(t, parts, 1)
+	assert.Equal(t, "Sunny.", parts[0].Text)
+}
+
+func TestClaudeHandler_RebuildsThinkingBlocks(t *testing.T) {
+	t.Parallel()
+
+	respBody := `{
+		"id": "chatcmpl-1",
+		"object": "chat.completion",
+		"model": "gpt-4o",
+		"choices": [{
+			"index": 0,
+			"message": {
+				"role": "assistant",
+				"content": "",
+				"reasoning_content": "Call the tool.",
+				"signature": "sig_1",
+				"thinking_blocks": [
+					{"type": "thinking", "thinking": "Call the tool.", "signature": "sig_1"},
+					{"type": "redacted_thinking", "data": "encrypted"}
+				],
+				"tool_calls": [{
+					"id": "toolu_1",
+					"type": "function",
+					"function": {"name": "weather", "arguments": "{\"city\":\"Paris\"}"}
+				}]
+			},
+			"finish_reason": "tool_calls"
+		}],
+		"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+	}`
+
+	httpResp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(respBody)),
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	_, relayErr := openai.ClaudeHandler(
+		&meta.Meta{OriginModel: "claude", ActualModel: "gpt-4o"},
+		c,
+		httpResp,
+	)
+	require.Nil(t, relayErr)
+
+	var claudeResp relaymodel.ClaudeResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &claudeResp))
+	require.Len(t, claudeResp.Content, 4)
+
+	assert.Equal(t, relaymodel.ClaudeContentTypeThinking, claudeResp.Content[0].Type)
+	assert.Equal(t, "sig_1", claudeResp.Content[0].Signature)
+	assert.Equal(t, relaymodel.ClaudeContentTypeRedactedThinking, claudeResp.Content[1].Type)
+	assert.Equal(t, "encrypted", claudeResp.Content[1].Data)
+	assert.Equal(t, relaymodel.ClaudeContentTypeText, claudeResp.Content[2].Type)
+	assert.Equal(t, relaymodel.ClaudeContentTypeToolUse, claudeResp.Content[3].Type)
+}
@@ -0,0 +1,362 @@
+package openai
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/bytedance/sonic"
+	"github.com/bytedance/sonic/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/conv"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+)
+
+// completionsOnlyFields are the legacy completions fields the chat requests do
+// not have, they are dropped when the prompt is sent as a chat request
+var completionsOnlyFields = []string{
+	"prompt",
+	"suffix",
+	"echo",
+	"best_of",
+	"logprobs",
+}
+
+var _ adaptor.Adaptor = (*CompletionsToChatAdaptor)(nil)
+
+// CompletionsToChatAdaptor serves the legacy completions of the chat only
+// adaptors, the prompt is sent as a chat request and the chat responses are
+// converted back to the completions format
+type CompletionsToChatAdaptor struct {
+	adaptor.Adaptor
+}
+
+func NewCompletionsToChatAdaptor(a adaptor.Adaptor) adaptor.Adaptor {
+	return &CompletionsToChatAdaptor{Adaptor: a}
+}
+
+// withChatMode runs fn with the meta in the chat completions mode, the mode is
+// restored after fn returns
+func withChatMode[T any](m *meta.Meta, fn func() T) T {
+	origin := m.Mode
+	m.Mode = mode.ChatCompletions
+
+	defer func() {
+		m.Mode = origin
+	}()
+
+	return fn()
+}
+
+// converting reports whether the completions request is served by the chat of
+// the wrapped adaptor
+func (a *CompletionsToChatAdaptor) converting(m *meta.Meta) bool {
+	if m == nil || m.Mode != mode.Completions || a.Adaptor.SupportMode(m) {
+		return false
+	}
+
+	return withChatMode(m, func() bool {
+		return a.Adaptor.SupportMode(m)
+	})
+}
+
+func (a *CompletionsToChatAdaptor) SupportMode(m *meta.Meta) bool {
+	return a.Adaptor.SupportMode(m) || a.converting(m)
+}
+
+func (a *CompletionsToChatAdaptor) GetRequestURL(
+	m *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+) (adaptor.RequestURL, error) {
+	if !a.converting(m) {
+		return a.Adaptor.GetRequestURL(m, store, c)
+	}
+
+	type result struct {
+		url adaptor.RequestURL
+		err error
+	}
+
+	r := withChatMode(m, func() result {
+		url, err := a.Adaptor.GetRequestURL(m, store, c)
+		return result{url: url, err: err}
+	})
+
+	return r.url, r.err
+}
+
+func (a *CompletionsToChatAdaptor) SetupRequestHeader(
+	m *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	req *http.Request,
+) error {
+	if !a.converting(m) {
+		return a.Adaptor.SetupRequestHeader(m, store, c, req)
+	}
+
+	return withChatMode(m, func() error {
+		return a.Adaptor.SetupRequestHeader(m, store, c, req)
+	})
+}
+
+func (a *CompletionsToChatAdaptor) ConvertRequest(
+	m *meta.Meta,
+	store adaptor.Store,
+	req *http.Request,
+) (adaptor.ConvertResult, error) {
+	if !a.converting(m) {
+		return a.Adaptor.ConvertRequest(m, store, req)
+	}
+
+	chatBody, dropped, err := ConvertCompletionsToChatRequest(req)
+	if err != nil {
+		return adaptor.ConvertResult{}, convertRequestError(m, err.Error())
+	}
+
+	if len(dropped) > 0 {
+		common.GetLoggerFromReq(req).
+			Warnf("completions fields not supported by chat are dropped: %v", dropped)
+	}
+
+	chatReq := req.Clone(req.Context())
+	common.SetRequestBody(chatReq, chatBody)
+
+	type result struct {
+		convert adaptor.ConvertResult
+		err     error
+	}
+
+	r := withChatMode(m, func() result {
+		convert, err := a.Adaptor.ConvertRequest(m, store, chatReq)
+		return result{convert: convert, err: err}
+	})
+
+	return r.convert, r.err
+}
+
+func (a *CompletionsToChatAdaptor) DoRequest(
+	m *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	req *http.Request,
+) (*http.Response, error) {
+	if !a.converting(m) {
+		return a.Adaptor.DoRequest(m, store, c, req)
+	}
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+
+	r := withChatMode(m, func() result {
+		resp, err := a.Adaptor.DoRequest(m, store, c, req)
+		return result{resp: resp, err: err}
+	})
+
+	return r.resp, r.err
+}
+
+func (a *CompletionsToChatAdaptor) DoResponse(
+	m *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if !a.converting(m) {
+		return a.Adaptor.DoResponse(m, store, c, resp)
+	}
+
+	writer := c.Writer
+	c.Writer = &completionsResponseWriter{ResponseWriter: writer}
+
+	defer func() {
+		c.Writer = writer
+	}()
+
+	type result struct {
+		result adaptor.DoResponseResult
+		err    adaptor.Error
+	}
+
+	r := withChatMode(m, func() result {
+		res, err := a.Adaptor.DoResponse(m, store, c, resp)
+		return result{result: res, err: err}
+	})
+
+	return r.result, r.err
+}
+
+// ConvertCompletionsToChatRequest wraps the prompt of the completions request
+// into a user message, the fields the chat requests do not have are returned
+// as dropped
+func ConvertCompletionsToChatRequest(req *http.Request) ([]byte, []string, error) {
+	node, err := common.UnmarshalRequest2NodeReusable(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prompt, err := completionsPrompt(node.Get("prompt"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var dropped []string
+
+	for _, field := range completionsOnlyFields {
+		v := node.Get(field)
+		if !v.Exists() {
+			continue
+		}
+
+		if field != "prompt" && v.TypeSafe() != ast.V_NULL && v.TypeSafe() != ast.V_FALSE {
+			dropped = append(dropped, field)
+		}
+
+		if _, err := node.Unset(field); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	messages, err := sonic.Marshal([]relaymodel.Message{
+		{
+			Role:    relaymodel.RoleUser,
+			Content: prompt,
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	messagesNode, err := sonic.Get(messages)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := node.Set("messages", messagesNode); err != nil {
+		return nil, nil, err
+	}
+
+	body, err := node.MarshalJSON()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return body, dropped, nil
+}
+
+// completionsPrompt returns the prompt of the completions request, a chat
+// request has one conversation so only one prompt is accepted
+func completionsPrompt(node *ast.Node) (string, error) {
+	switch node.TypeSafe() {
+	case ast.V_STRING:
+		return node.String()
+	case ast.V_ARRAY:
+		prompts, err := node.ArrayUseNode()
+		if err != nil {
+			return "", err
+		}
+
+		if len(prompts) != 1 || prompts[0].TypeSafe() != ast.V_STRING {
+			return "", errors.New("only a single text prompt is supported by this channel")
+		}
+
+		return prompts[0].String()
+	default:
+		return "", errors.New("prompt is required")
+	}
+}
+
+// completionsResponseWriter converts the chat responses and the stream chunks
+// written by the adaptor to the completions format
+type completionsResponseWriter struct {
+	gin.ResponseWriter
+}
+
+func (rw *completionsResponseWriter) Write(b []byte) (int, error) {
+	out := ConvertChatToCompletionsResponse(b)
+	if len(out) != len(b) && rw.Header().Get("Content-Length") != "" {
+		rw.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	}
+
+	n, err := rw.ResponseWriter.Write(out)
+	if err != nil {
+		return n, err
+	}
+
+	return len(b), nil
+}
+
+func (rw *completionsResponseWriter) WriteString(s string) (int, error) {
+	return rw.Write(conv.StringToBytes(s))
+}
+
+var choicesKeyBytes = []byte(`"choices"`)
+
+// ConvertChatToCompletionsResponse converts a chat completion or a chat stream
+// chunk to the completions format, anything else is returned as it is
+func ConvertChatToCompletionsResponse(b []byte) []byte {
+	if !bytes.Contains(b, choicesKeyBytes) {
+		return b
+	}
+
+	node, err := sonic.Get(b)
+	if err != nil || node.TypeSafe() != ast.V_OBJECT {
+		return b
+	}
+
+	choices, err := node.Get("choices").ArrayUseNode()
+	if err != nil {
+		return b
+	}
+
+	converted := make([]any, 0, len(choices))
+	for _, choice := range choices {
+		converted = append(converted, convertChatChoice(&choice))
+	}
+
+	if _, err := node.Set("choices", ast.NewAny(converted)); err != nil {
+		return b
+	}
+
+	if _, err := node.Set("object", ast.NewString("text_completion")); err != nil {
+		return b
+	}
+
+	out, err := node.MarshalJSON()
+	if err != nil {
+		return b
+	}
+
+	return out
+}
+
+func convertChatChoice(choice *ast.Node) map[string]any {
+	index, _ := choice.Get("index").Int64()
+
+	content := choice.Get("message")
+	if !content.Exists() {
+		content = choice.Get("delta")
+	}
+
+	text, _ := content.Get("content").String()
+
+	var finishReason any
+	if reason, err := choice.Get("finish_reason").String(); err == nil && reason != "" {
+		finishReason = reason
+	}
+
+	return map[string]any{
+		"index":         index,
+		"text":          text,
+		"logprobs":      nil,
+		"finish_reason": finishReason,
+	}
+}
@@ -0,0 +1,89 @@
+package openai_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertCompletionsToChatRequest(t *testing.T) {
+	req := httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"/v1/completions",
+		strings.NewReader(
+			`{"model":"m","prompt":["Say hi"],"max_tokens":16,"logprobs":2,"echo":false,"stream":true}`,
+		),
+	)
+	req.Header.Set("Content-Type", "application/json")
+
+	body, dropped, err := openai.ConvertCompletionsToChatRequest(req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"logprobs"}, dropped)
+
+	var request map[string]any
+	require.NoError(t, sonic.Unmarshal(body, &request))
+	assert.NotContains(t, request, "prompt")
+	assert.NotContains(t, request, "logprobs")
+	assert.NotContains(t, request, "echo")
+	assert.InDelta(t, 16, request["max_tokens"], 0)
+	assert.Equal(t, true, request["stream"])
+	assert.Equal(
+		t,
+		[]any{map[string]any{"role": "user", "content": "Say hi"}},
+		request["messages"],
+	)
+
+	req = httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"/v1/completions",
+		strings.NewReader(`{"model":"m","prompt":["a","b"]}`),
+	)
+
+	_, _, err = openai.ConvertCompletionsToChatRequest(req)
+	require.Error(t, err)
+}
+
+func TestConvertChatToCompletionsResponse(t *testing.T) {
+	out := openai.ConvertChatToCompletionsResponse([]byte(`{
+		"id":"1",
+		"object":"chat.completion",
+		"model":"m",
+		"choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],
+		"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}
+	}`))
+
+	var response map[string]any
+	require.NoError(t, sonic.Unmarshal(out, &response))
+	assert.Equal(t, "text_completion", response["object"])
+	assert.Equal(t, []any{map[string]any{
+		"index":         float64(0),
+		"text":          "hi",
+		"logprobs":      nil,
+		"finish_reason": "stop",
+	}}, response["choices"])
+	assert.NotNil(t, response["usage"])
+
+	out = openai.ConvertChatToCompletionsResponse([]byte(
+		`{"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"h"}}]}`,
+	))
+
+	response = nil
+	require.NoError(t, sonic.Unmarshal(out, &response))
+	assert.Equal(t, "text_completion", response["object"])
+	assert.Equal(t, []any{map[string]any{
+		"index":         float64(0),
+		"text":          "h",
+		"logprobs":      nil,
+		"finish_reason": nil,
+	}}, response["choices"])
+
+	assert.Equal(t, "[DONE]", string(openai.ConvertChatToCompletionsResponse([]byte("[DONE]"))))
+}
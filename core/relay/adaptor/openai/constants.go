@@ -543,6 +543,34 @@ var ModelList = []model.ModelConfig{
 			model.WithModelConfigMaxContextTokens(128000),
 		),
 	},
+	{
+		Model: "gpt-4o-audio-preview",
+		Type:  mode.ChatCompletions,
+		Owner: model.ModelOwnerOpenAI,
+		Price: model.Price{
+			InputPrice:       0.0025,
+			OutputPrice:      0.010,
+			AudioInputPrice:  0.040,
+			AudioOutputPrice: 0.080,
+		},
+		Config: model.NewModelConfig(
+			model.WithModelConfigMaxContextTokens(128000),
+		),
+	},
+	{
+		Model: "gpt-4o-mini-audio-preview",
+		Type:  mode.ChatCompletions,
+		Owner: model.ModelOwnerOpenAI,
+		Price: model.Price{
+			InputPrice:       0.00015,
+			OutputPrice:      0.0006,
+			AudioInputPrice:  0.010,
+			AudioOutputPrice: 0.020,
+		},
+		Config: model.NewModelConfig(
+			model.WithModelConfigMaxContextTokens(128000),
+		),
+	},
 	{
 		Model: "o3-deep-research",
 		Type:  mode.Responses,
@@ -0,0 +1,220 @@
+package openai
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+)
+
+type FileObject struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+}
+
+type FileListResponse struct {
+	Object string       `json:"object"`
+	Data   []FileObject `json:"data"`
+}
+
+// ConvertFileUploadRequest rebuilds the multipart form of the upload, the
+// model field only selects the channel and is not sent to the upstream
+func ConvertFileUploadRequest(
+	meta *meta.Meta,
+	request *http.Request,
+) (adaptor.ConvertResult, error) {
+	if err := common.ParseMultipartFormWithLimit(request); err != nil {
+		return adaptor.ConvertResult{}, convertRequestError(
+			meta,
+			fmt.Sprintf("parse multipart form: %s", err),
+		)
+	}
+
+	multipartBody := &bytes.Buffer{}
+	multipartWriter := multipart.NewWriter(multipartBody)
+
+	for key, values := range request.MultipartForm.Value {
+		if key == "model" || len(values) == 0 {
+			continue
+		}
+
+		if err := multipartWriter.WriteField(key, values[0]); err != nil {
+			return adaptor.ConvertResult{}, fmt.Errorf("write field %s: %w", key, err)
+		}
+	}
+
+	if err := processFormFiles(multipartWriter, request.MultipartForm.File); err != nil {
+		return adaptor.ConvertResult{}, fmt.Errorf("process form files: %w", err)
+	}
+
+	multipartWriter.Close()
+
+	return adaptor.ConvertResult{
+		Header: http.Header{
+			"Content-Type": {multipartWriter.FormDataContentType()},
+		},
+		Body: multipartBody,
+	}, nil
+}
+
+// FileUploadHandler records the uploaded file, so the later requests of the
+// file are routed to the same channel and counted in the group storage quota
+func FileUploadHandler(
+	meta *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if resp.StatusCode != http.StatusOK {
+		return adaptor.DoResponseResult{}, ErrorHanlder(resp)
+	}
+
+	defer resp.Body.Close()
+
+	responseBody, err := common.GetResponseBody(resp)
+	if err != nil {
+		return adaptor.DoResponseResult{}, relaymodel.WrapperOpenAIError(
+			err,
+			"read_response_body_failed",
+			http.StatusInternalServerError,
+		)
+	}
+
+	var file FileObject
+	if err := sonic.Unmarshal(responseBody, &file); err != nil {
+		return adaptor.DoResponseResult{}, relaymodel.WrapperOpenAIError(
+			err,
+			"unmarshal_response_body_failed",
+			http.StatusInternalServerError,
+		)
+	}
+
+	if file.ID != "" {
+		var expiresAt time.Time
+		if file.ExpiresAt > 0 {
+			expiresAt = time.Unix(file.ExpiresAt, 0)
+		}
+
+		err := store.SaveStore(adaptor.StoreCache{
+			ID:        model.OpenAIFileStoreID(file.ID),
+			GroupID:   meta.Group.ID,
+			TokenID:   meta.Token.ID,
+			ChannelID: meta.Channel.ID,
+			Model:     meta.OriginModel,
+			Metadata: model.OpenAIFileMetadata{
+				Bytes:     file.Bytes,
+				CreatedAt: file.CreatedAt,
+				Filename:  file.Filename,
+				Purpose:   file.Purpose,
+			}.String(),
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			log := common.GetLogger(c)
+			log.Errorf("save store failed: %v", err)
+		}
+	}
+
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.Header().Set("Content-Length", strconv.Itoa(len(responseBody)))
+	_, _ = c.Writer.Write(responseBody)
+
+	return adaptor.DoResponseResult{}, nil
+}
+
+func FileGetHandler(
+	_ *meta.Meta,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if resp.StatusCode != http.StatusOK {
+		return adaptor.DoResponseResult{}, ErrorHanlder(resp)
+	}
+
+	defer resp.Body.Close()
+
+	c.Writer.Header().Set("Content-Type", firstNonEmptyString(
+		resp.Header.Get("Content-Type"),
+		"application/json",
+	))
+	c.Writer.Header().Set("Content-Length", resp.Header.Get("Content-Length"))
+	_, _ = io.Copy(c.Writer, resp.Body)
+
+	return adaptor.DoResponseResult{}, nil
+}
+
+func FileContentHandler(
+	_ *meta.Meta,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if resp.StatusCode != http.StatusOK {
+		return adaptor.DoResponseResult{}, ErrorHanlder(resp)
+	}
+
+	defer resp.Body.Close()
+
+	c.Writer.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	c.Writer.Header().Set("Content-Length", resp.Header.Get("Content-Length"))
+
+	if disposition := resp.Header.Get("Content-Disposition"); disposition != "" {
+		c.Writer.Header().Set("Content-Disposition", disposition)
+	}
+
+	_, _ = io.Copy(c.Writer, resp.Body)
+
+	return adaptor.DoResponseResult{}, nil
+}
+
+// FileDeleteHandler expires the store of the deleted file, which releases its
+// bytes from the group storage quota
+func FileDeleteHandler(
+	meta *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if resp.StatusCode != http.StatusOK {
+		return adaptor.DoResponseResult{}, ErrorHanlder(resp)
+	}
+
+	defer resp.Body.Close()
+
+	err := store.SaveStore(adaptor.StoreCache{
+		ID:        model.OpenAIFileStoreID(meta.FileID),
+		GroupID:   meta.Group.ID,
+		TokenID:   meta.Token.ID,
+		ChannelID: meta.Channel.ID,
+		Model:     meta.OriginModel,
+		ExpiresAt: time.Now(),
+	})
+	if err != nil {
+		log := common.GetLogger(c)
+		log.Errorf("save store failed: %v", err)
+	}
+
+	c.Writer.Header().Set("Content-Type", firstNonEmptyString(
+		resp.Header.Get("Content-Type"),
+		"application/json",
+	))
+	c.Writer.Header().Set("Content-Length", resp.Header.Get("Content-Length"))
+	_, _ = io.Copy(c.Writer, resp.Body)
+
+	return adaptor.DoResponseResult{}, nil
+}
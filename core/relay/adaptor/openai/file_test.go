@@ -0,0 +1,137 @@
+//nolint:testpackage
+package openai
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFileUploadRequest(t *testing.T) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	require.NoError(t, writer.WriteField("model", "gpt-4o-mini"))
+	require.NoError(t, writer.WriteField("purpose", "batch"))
+
+	part, err := writer.CreateFormFile("file", "batch.jsonl")
+	require.NoError(t, err)
+
+	_, err = part.Write([]byte(`{"custom_id":"1"}`))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/v1/files", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return req
+}
+
+func TestConvertFileUploadRequestDropsModel(t *testing.T) {
+	m := &meta.Meta{Mode: mode.Files, OriginModel: "gpt-4o-mini", ActualModel: "gpt-4o-mini"}
+
+	result, err := ConvertFileUploadRequest(m, newFileUploadRequest(t))
+	require.NoError(t, err)
+
+	converted := httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"/files",
+		result.Body,
+	)
+	converted.Header.Set("Content-Type", result.Header.Get("Content-Type"))
+	require.NoError(t, converted.ParseMultipartForm(1<<20))
+
+	assert.NotContains(t, converted.MultipartForm.Value, "model")
+	assert.Equal(t, []string{"batch"}, converted.MultipartForm.Value["purpose"])
+	require.Len(t, converted.MultipartForm.File["file"], 1)
+	assert.Equal(t, "batch.jsonl", converted.MultipartForm.File["file"][0].Filename)
+}
+
+func TestFileUploadHandlerSavesStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/v1/files", nil)
+
+	store := &responseTestStore{}
+	m := &meta.Meta{
+		Mode:        mode.Files,
+		OriginModel: "gpt-4o-mini",
+		Group:       model.GroupCache{ID: "group-1"},
+		Token:       model.TokenCache{ID: 7},
+		Channel:     meta.ChannelMeta{ID: 9},
+	}
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body: io.NopCloser(bytes.NewBufferString(
+			`{"id":"file-abc","object":"file","bytes":1024,"created_at":1700000000,"filename":"batch.jsonl","purpose":"batch"}`,
+		)),
+	}
+
+	_, err := FileUploadHandler(m, store, c, resp)
+	require.Nil(t, err)
+	require.Len(t, store.saved, 1)
+
+	saved := store.saved[0]
+	assert.Equal(t, model.OpenAIFileStoreID("file-abc"), saved.ID)
+	assert.Equal(t, "group-1", saved.GroupID)
+	assert.Equal(t, 7, saved.TokenID)
+	assert.Equal(t, 9, saved.ChannelID)
+	assert.Equal(t, "gpt-4o-mini", saved.Model)
+
+	metadata := model.ParseOpenAIFileMetadata(saved.Metadata)
+	assert.Equal(t, int64(1024), metadata.Bytes)
+	assert.Equal(t, "batch", metadata.Purpose)
+	assert.Contains(t, recorder.Body.String(), `"file-abc"`)
+}
+
+func TestFileDeleteHandlerExpiresStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodDelete,
+		"/v1/files/file-abc",
+		nil,
+	)
+
+	store := &responseTestStore{}
+	m := &meta.Meta{
+		Mode:    mode.FilesDelete,
+		FileID:  "file-abc",
+		Group:   model.GroupCache{ID: "group-1"},
+		Token:   model.TokenCache{ID: 7},
+		Channel: meta.ChannelMeta{ID: 9},
+	}
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body: io.NopCloser(bytes.NewBufferString(
+			`{"id":"file-abc","object":"file","deleted":true}`,
+		)),
+	}
+
+	_, err := FileDeleteHandler(m, store, c, resp)
+	require.Nil(t, err)
+	require.Len(t, store.saved, 1)
+	assert.Equal(t, model.OpenAIFileStoreID("file-abc"), store.saved[0].ID)
+	assert.False(t, store.saved[0].ExpiresAt.After(time.Now()))
+	assert.Contains(t, recorder.Body.String(), `"deleted":true`)
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -36,6 +37,8 @@ func ConvertGeminiRequest(
 		return adaptor.ConvertResult{}, err
 	}
 
+	utils.RemoveGeminiGroundingTools(req, geminiReq)
+
 	// Convert to OpenAI format
 	openaiReq := relaymodel.GeneralOpenAIRequest{
 		Model: meta.ActualModel,
@@ -169,6 +172,10 @@ func ConvertOpenAIToGeminiResponse(
 			}
 		}
 
+		if part := outputAudioToGeminiPart(choice.Message.Audio); part != nil {
+			candidate.Content.Parts = append(candidate.Content.Parts, part)
+		}
+
 		// Convert tool calls
 		for _, toolCall := range choice.Message.ToolCalls {
 			var args map[string]any
@@ -287,6 +294,11 @@ func (s *GeminiStreamState) ConvertOpenAIStreamToGemini(
 			}
 		}
 
+		if part := outputAudioToGeminiPart(choice.Delta.Audio); part != nil {
+			candidate.Content.Parts = append(candidate.Content.Parts, part)
+			hasContent = true
+		}
+
 		// Buffer tool calls
 		for _, toolCall := range choice.Delta.ToolCalls {
 			key := fmt.Sprintf("%d-%d", choice.Index, toolCall.Index)
@@ -544,6 +556,19 @@ func convertGeminiGenerationConfigToOpenAI(
 			}
 		}
 
+		if slices.ContainsFunc(
+			geminiReq.GenerationConfig.ResponseModalities,
+			func(modality string) bool {
+				return strings.EqualFold(modality, relaymodel.GeminiModalityAudio)
+			},
+		) {
+			openaiReq.Modalities = []string{"text", "audio"}
+			openaiReq.Audio = &relaymodel.Audio{
+				Voice:  geminiSpeechVoice(geminiReq.GenerationConfig.SpeechConfig),
+				Format: "pcm16",
+			}
+		}
+
 		applyReasoningToOpenAIRequestForModel(
 			meta,
 			openaiReq,
@@ -552,6 +577,38 @@ func convertGeminiGenerationConfigToOpenAI(
 	}
 }
 
+// geminiSpeechVoice returns the prebuilt voice of the speech config, the openai
+// default voice is used when no voice is configured
+func geminiSpeechVoice(config *relaymodel.GeminiSpeechConfig) string {
+	if config == nil ||
+		config.VoiceConfig == nil ||
+		config.VoiceConfig.PrebuiltVoiceConfig == nil ||
+		config.VoiceConfig.PrebuiltVoiceConfig.VoiceName == "" {
+		return "alloy"
+	}
+
+	return config.VoiceConfig.PrebuiltVoiceConfig.VoiceName
+}
+
+// geminiPCMMimeType is the mime type of the pcm16 audio, the same as the audio
+// output of gemini
+const geminiPCMMimeType = "audio/L16;codec=pcm;rate=24000"
+
+// outputAudioToGeminiPart converts the pcm16 audio output (or delta) of the
+// chat completions to a gemini inline data part
+func outputAudioToGeminiPart(audio *relaymodel.OutputAudio) *relaymodel.GeminiPart {
+	if audio == nil || audio.Data == "" {
+		return nil
+	}
+
+	return &relaymodel.GeminiPart{
+		InlineData: &relaymodel.GeminiInlineData{
+			MimeType: geminiPCMMimeType,
+			Data:     audio.Data,
+		},
+	}
+}
+
 func convertGeminiContentToOpenAI(
 	content *relaymodel.GeminiChatContent,
 	pendingTools *[]relaymodel.ToolCall,
@@ -821,6 +878,8 @@ func ConvertGeminiToResponsesRequest(
 		return adaptor.ConvertResult{}, err
 	}
 
+	utils.RemoveGeminiGroundingTools(req, geminiReq)
+
 	// Convert to OpenAI messages format first
 	var messages []relaymodel.Message
 
@@ -1178,14 +1237,19 @@ func ConvertResponsesToGeminiStreamResponse(
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Error("error reading response stream: " + err.Error())
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		log.Error("error reading response stream: " + scanErr.Error())
 	}
 
 	if errorState.pendingFailure != nil && !wroteStream {
 		return errorState.result(), responseStreamError(errorState.pendingFailure)
 	}
 
+	if scanErr != nil {
+		render.GeminiStreamError(c, scanErr)
+	}
+
 	return errorState.result(), nil
 }
 
@@ -973,3 +973,98 @@ func TestConvertGeminiRequest_ToolsWithRequiredField(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertGeminiRequest_AudioResponseModality(t *testing.T) {
+	requestJSON := `
+	{
+		"contents": [{"parts": [{"text": "Say hello"}], "role": "user"}],
+		"generationConfig": {
+			"responseModalities": ["AUDIO"],
+			"speechConfig": {
+				"voiceConfig": {"prebuiltVoiceConfig": {"voiceName": "verse"}}
+			}
+		}
+	}`
+
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodPost,
+		"/v1beta/models/gpt-4o-audio-preview:generateContent",
+		strings.NewReader(requestJSON),
+	)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	meta := &meta.Meta{
+		ActualModel: "gpt-4o-audio-preview",
+	}
+
+	result, err := openai.ConvertGeminiRequest(meta, req)
+	if err != nil {
+		t.Fatalf("ConvertGeminiRequest failed: %v", err)
+	}
+
+	bodyBytes, _ := io.ReadAll(result.Body)
+
+	var openAIReq relaymodel.GeneralOpenAIRequest
+	if err := json.Unmarshal(bodyBytes, &openAIReq); err != nil {
+		t.Fatalf("failed to unmarshal result body: %v", err)
+	}
+
+	assert.Equal(t, []string{"text", "audio"}, openAIReq.Modalities)
+	require.NotNil(t, openAIReq.Audio)
+	assert.Equal(t, "verse", openAIReq.Audio.Voice)
+	assert.Equal(t, "pcm16", openAIReq.Audio.Format)
+}
+
+func TestConvertOpenAIToGemini_AudioOutput(t *testing.T) {
+	meta := &meta.Meta{
+		ActualModel: "gpt-4o-audio-preview",
+	}
+
+	resp := openai.ConvertOpenAIToGeminiResponse(meta, &relaymodel.TextResponse{
+		Choices: []*relaymodel.TextResponseChoice{
+			{
+				FinishReason: relaymodel.FinishReasonStop,
+				Message: relaymodel.Message{
+					Role: relaymodel.RoleAssistant,
+					Audio: &relaymodel.OutputAudio{
+						ID:         "audio_1",
+						Data:       "UklGRg==",
+						Transcript: "Hello",
+					},
+				},
+			},
+		},
+	})
+	require.Len(t, resp.Candidates, 1)
+	require.Len(t, resp.Candidates[0].Content.Parts, 1)
+	require.NotNil(t, resp.Candidates[0].Content.Parts[0].InlineData)
+	assert.Equal(t, "UklGRg==", resp.Candidates[0].Content.Parts[0].InlineData.Data)
+	assert.True(
+		t,
+		strings.HasPrefix(resp.Candidates[0].Content.Parts[0].InlineData.MimeType, "audio/"),
+	)
+
+	streamResp := openai.NewGeminiStreamState().ConvertOpenAIStreamToGemini(
+		meta,
+		&relaymodel.ChatCompletionsStreamResponse{
+			Choices: []*relaymodel.ChatCompletionsStreamResponseChoice{
+				{
+					Delta: relaymodel.Message{
+						Audio: &relaymodel.OutputAudio{
+							Data: "AAAA",
+						},
+					},
+				},
+			},
+		},
+	)
+	require.NotNil(t, streamResp)
+	require.Len(t, streamResp.Candidates, 1)
+	require.Len(t, streamResp.Candidates[0].Content.Parts, 1)
+	assert.Equal(t, "AAAA", streamResp.Candidates[0].Content.Parts[0].InlineData.Data)
+}
@@ -38,6 +38,13 @@ func ConvertImagesRequest(
 
 	meta.Set(MetaResponseFormat, responseFormat)
 
+	stream, err := node.Get("stream").Bool()
+	if err != nil && !errors.Is(err, ast.ErrNotExist) {
+		return adaptor.ConvertResult{}, convertRequestError(meta, err.Error())
+	}
+
+	meta.Set(MetaImageStream, stream)
+
 	_, err = node.Set("model", ast.NewString(meta.ActualModel))
 	if err != nil {
 		return adaptor.ConvertResult{}, err
@@ -99,8 +106,11 @@ func ConvertImagesEditsRequest(
 			continue
 		}
 
-		if key == "response_format" {
+		switch key {
+		case "response_format":
 			meta.Set(MetaResponseFormat, value)
+		case "stream":
+			meta.Set(MetaImageStream, value == "true")
 		}
 
 		err = multipartWriter.WriteField(key, value)
@@ -205,6 +215,11 @@ func ImagesHandler(
 		}
 	}
 
+	if meta.GetBool(MetaImageStream) {
+		renderImagesAsStream(c, &imageResponse, usage)
+		return adaptor.DoResponseResult{Usage: usage}, nil
+	}
+
 	data, err := sonic.Marshal(imageResponse)
 	if err != nil {
 		return adaptor.DoResponseResult{Usage: usage}, relaymodel.WrapperOpenAIError(
@@ -225,6 +240,52 @@ func ImagesHandler(
 	return adaptor.DoResponseResult{Usage: usage}, nil
 }
 
+// renderImagesAsStream answers a stream request the upstream did not stream,
+// each image is sent as a completed event and the last one carries the usage
+func renderImagesAsStream(
+	c *gin.Context,
+	imageResponse *relaymodel.ImageResponse,
+	usage model.Usage,
+) {
+	log := common.GetLogger(c)
+
+	imageUsage := imageResponse.Usage
+	if imageUsage == nil {
+		imageUsage = &relaymodel.ImageUsage{
+			InputTokens:  int64(usage.InputTokens),
+			OutputTokens: int64(usage.OutputTokens),
+			TotalTokens:  int64(usage.TotalTokens),
+		}
+	}
+
+	images := make([]*relaymodel.ImageData, 0, len(imageResponse.Data))
+	for _, data := range imageResponse.Data {
+		if data != nil {
+			images = append(images, data)
+		}
+	}
+
+	if len(images) == 0 {
+		images = append(images, &relaymodel.ImageData{})
+	}
+
+	for i, data := range images {
+		event := relaymodel.ImageStreamEvent{
+			Type:      relaymodel.ImageStreamEventCompleted,
+			B64Json:   data.B64Json,
+			URL:       data.URL,
+			CreatedAt: imageResponse.Created,
+		}
+		if i == len(images)-1 {
+			event.Usage = imageUsage
+		}
+
+		if err := render.ResponsesObjectData(c, event); err != nil {
+			log.Warnf("write image stream completed event failed: %v", err)
+		}
+	}
+}
+
 func successfulOpenAIImageCount(data []*relaymodel.ImageData) int64 {
 	var count int64
 	for _, item := range data {
@@ -274,10 +335,15 @@ func ImagesStreamHandler(
 			continue
 		}
 
-		if streamUsage, err := getImageStreamUsage(&node); err != nil {
-			log.Error("error unmarshalling image stream usage: " + err.Error())
-		} else if streamUsage != nil {
-			usage = streamUsage.ToModelUsage()
+		// the usage is accounted from the final event, the partial images are
+		// relayed as they are
+		eventType, _ := node.Get("type").String()
+		if eventType != relaymodel.ImageStreamEventPartialImage {
+			if streamUsage, err := getImageStreamUsage(&node); err != nil {
+				log.Error("error unmarshalling image stream usage: " + err.Error())
+			} else if streamUsage != nil {
+				usage = streamUsage.ToModelUsage()
+			}
 		}
 
 		render.ResponsesData(c, data)
@@ -344,3 +344,65 @@ func TestConvertVideosRequestMultipartRewritesModel(t *testing.T) {
 	assert.Equal(t, "Animate the reference", convertedReq.MultipartForm.Value["prompt"][0])
 	require.Len(t, convertedReq.MultipartForm.File["input_reference"], 1)
 }
+
+func TestConvertImagesRequestRecordsStream(t *testing.T) {
+	m := meta.NewMeta(nil, mode.ImagesGenerations, "gpt-image-1", model.ModelConfig{})
+
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodPost,
+		"http://example.com/v1/images/generations",
+		strings.NewReader(`{"prompt":"test","stream":true,"partial_images":2}`),
+	)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	result, err := ConvertImagesRequest(m, req)
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, true, payload["stream"])
+	assert.InDelta(t, 2, payload["partial_images"], 0)
+	assert.True(t, m.GetBool(MetaImageStream))
+}
+
+func TestImagesHandlerEmitsFinalEventWhenUpstreamDoesNotStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequestWithContext(
+		context.Background(),
+		http.MethodPost,
+		"/v1/images/generations",
+		nil,
+	)
+
+	m := meta.NewMeta(nil, mode.ImagesGenerations, "gpt-image-1", model.ModelConfig{})
+	m.Set(MetaImageStream, true)
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body: io.NopCloser(strings.NewReader(`{
+			"created": 1713833628,
+			"data": [{"b64_json":"final"}],
+			"usage": {"input_tokens":10,"output_tokens":20,"total_tokens":30,"input_tokens_details":{"text_tokens":10,"image_tokens":0}}
+		}`)),
+	}
+
+	result, err := ImagesHandler(m, c, resp)
+	require.Nil(t, err)
+	assert.Equal(t, model.ZeroNullInt64(20), result.Usage.OutputTokens)
+
+	body := recorder.Body.String()
+	assert.Contains(t, body, "event: "+relaymodel.ImageStreamEventCompleted+"\n")
+	assert.Contains(t, body, `"b64_json":"final"`)
+	assert.Contains(t, body, `"total_tokens":30`)
+	assert.NotContains(t, body, relaymodel.ImageStreamEventPartialImage)
+	assert.Equal(t, "text/event-stream", recorder.Header().Get("Content-Type"))
+}
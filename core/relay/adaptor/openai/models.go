@@ -0,0 +1,102 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/utils"
+)
+
+var _ adaptor.ModelLister = (*Adaptor)(nil)
+
+const listModelsTimeout = 30 * time.Second
+
+// ModelListResponse is the response of the models api, the anthropic models
+// api shares the layout
+type ModelListResponse struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+func (a *Adaptor) ListModels(ctx context.Context, channel *model.Channel) ([]string, error) {
+	key, err := channel.GetKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return ListModels(ctx, channel, "/models", nil, http.Header{
+		"Authorization": {"Bearer " + key},
+	})
+}
+
+// ListModels lists the model ids of the models api of the channel
+func ListModels(
+	ctx context.Context,
+	channel *model.Channel,
+	path string,
+	query url.Values,
+	header http.Header,
+) ([]string, error) {
+	modelsURL, err := url.JoinPath(channel.BaseURL, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(query) > 0 {
+		modelsURL += "?" + query.Encode()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, listModelsTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, modelsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+
+	client, err := utils.LoadHTTPClientWithTLSConfigE(
+		listModelsTimeout,
+		channel.ProxyURL,
+		channel.SkipTLSVerify,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list models failed: status %d", resp.StatusCode)
+	}
+
+	var list ModelListResponse
+	if err := sonic.ConfigDefault.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	models := make([]string, 0, len(list.Data))
+	for _, m := range list.Data {
+		if m.ID != "" {
+			models = append(models, m.ID)
+		}
+	}
+
+	return models, nil
+}
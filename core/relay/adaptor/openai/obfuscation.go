@@ -0,0 +1,55 @@
+package openai
+
+import (
+	"bytes"
+
+	"github.com/bytedance/sonic/ast"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/relay/meta"
+)
+
+// MetaStripObfuscation is set when the client disables the obfuscation padding
+// of the stream chunks, the padding is stripped even if the upstream ignores
+// stream_options.include_obfuscation
+const MetaStripObfuscation = "strip_obfuscation"
+
+const obfuscationField = "obfuscation"
+
+var obfuscationFieldBytes = []byte(`"` + obfuscationField + `"`)
+
+func recordStreamObfuscationOption(meta *meta.Meta, node *ast.Node) {
+	includeNode := node.GetByPath("stream_options", "include_obfuscation")
+	if !includeNode.Exists() {
+		return
+	}
+
+	if include, err := includeNode.Bool(); err == nil && !include {
+		meta.Set(MetaStripObfuscation, true)
+	}
+}
+
+func stripObfuscationFromNode(node *ast.Node) {
+	if node.Get(obfuscationField).Exists() {
+		_, _ = node.Unset(obfuscationField)
+	}
+}
+
+func stripObfuscation(data []byte) []byte {
+	if !bytes.Contains(data, obfuscationFieldBytes) {
+		return data
+	}
+
+	node, err := common.GetJSONNodeNoCopy(data)
+	if err != nil {
+		return data
+	}
+
+	stripObfuscationFromNode(&node)
+
+	stripped, err := node.MarshalJSON()
+	if err != nil {
+		return data
+	}
+
+	return stripped
+}
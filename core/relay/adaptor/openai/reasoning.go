@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/bytedance/sonic/ast"
+	"github.com/labring/aiproxy/core/model"
 	"github.com/labring/aiproxy/core/relay/meta"
 	relaymodel "github.com/labring/aiproxy/core/relay/model"
 	"github.com/labring/aiproxy/core/relay/utils"
@@ -131,6 +132,64 @@ func applyReasoningToResponsesRequestForModel(
 	}
 }
 
+// applyReasoningDefaultsToResponsesRequest fills the reasoning effort and summary
+// configured on the model when the client did not set them
+func applyReasoningDefaultsToResponsesRequest(
+	m *meta.Meta,
+	req *relaymodel.CreateResponseRequest,
+) {
+	if m == nil || req == nil || !isOpenAIReasoningModel(m.OriginModel, m.ActualModel) {
+		return
+	}
+
+	effort, _ := model.GetModelConfigString(m.ModelConfig.Config, model.ModelConfigReasoningEffortKey)
+	summary, _ := model.GetModelConfigString(
+		m.ModelConfig.Config,
+		model.ModelConfigReasoningSummaryKey,
+	)
+
+	effort = openAIReasoningEffortForMeta(m, relaymodel.NormalizeReasoningEffort(effort))
+	if effort == "" && summary == "" {
+		return
+	}
+
+	if req.Reasoning == nil {
+		req.Reasoning = &relaymodel.ResponseReasoning{}
+	}
+
+	if req.Reasoning.Effort == nil && effort != "" {
+		req.Reasoning.Effort = &effort
+	}
+
+	if req.Reasoning.Summary == nil && summary != "" {
+		req.Reasoning.Summary = summary
+	}
+}
+
+// isOpenAIReasoningModel reports whether the model is an o-series or gpt-5 family model
+func isOpenAIReasoningModel(originModel, actualModel string) bool {
+	return utils.FirstMatchingModelName(
+		isOpenAIReasoningModelName,
+		originModel,
+		actualModel,
+	) != ""
+}
+
+func isOpenAIReasoningModelName(modelName string) bool {
+	if _, ok := openAIReasoningEffortsForName(modelName); ok {
+		return true
+	}
+
+	modelName = strings.ToLower(strings.TrimSpace(modelName))
+	if i := strings.LastIndexAny(modelName, "/:"); i >= 0 {
+		modelName = modelName[i+1:]
+	}
+
+	return len(modelName) > 1 &&
+		modelName[0] == 'o' &&
+		modelName[1] >= '0' && modelName[1] <= '9'
+}
+
 func patchOpenAIReasoningEffort(m *meta.Meta) func(node *ast.Node) error {
 	return func(node *ast.Node) error {
 		if node == nil {
@@ -377,3 +377,91 @@ func TestDoResponseStream_MapReasoningToReasoningContent(t *testing.T) {
 	assert.Contains(t, body, `"reasoning_content":"internal-thought"`)
 	assert.NotContains(t, body, `"reasoning":"internal-thought"`)
 }
+
+func TestConvertChatCompletionToResponsesRequest_ReasoningDefaultsFromModelConfig(t *testing.T) {
+	t.Parallel()
+
+	convert := func(t *testing.T, modelName, body string) *relaymodel.ResponseReasoning {
+		t.Helper()
+
+		req := httptest.NewRequestWithContext(t.Context(),
+			http.MethodPost,
+			"/v1/chat/completions",
+			strings.NewReader(body),
+		)
+		m := &meta.Meta{
+			OriginModel: modelName,
+			ActualModel: modelName,
+			ModelConfig: model.ModelConfig{
+				Config: model.NewModelConfig(
+					model.WithModelConfigReasoningEffort("medium"),
+					model.WithModelConfigReasoningSummary("auto"),
+				),
+			},
+		}
+
+		result, err := ConvertChatCompletionToResponsesRequest(m, req)
+		require.NoError(t, err)
+
+		var responsesReq relaymodel.CreateResponseRequest
+		require.NoError(t, json.NewDecoder(result.Body).Decode(&responsesReq))
+
+		return responsesReq.Reasoning
+	}
+
+	t.Run("defaults injected for o-series", func(t *testing.T) {
+		t.Parallel()
+
+		reasoning := convert(t, "o3", `{"model":"o3","messages":[{"role":"user","content":"hi"}]}`)
+		require.NotNil(t, reasoning)
+		require.NotNil(t, reasoning.Effort)
+		assert.Equal(t, "medium", *reasoning.Effort)
+		assert.Equal(t, "auto", reasoning.Summary)
+	})
+
+	t.Run("client effort wins", func(t *testing.T) {
+		t.Parallel()
+
+		reasoning := convert(
+			t,
+			"gpt-5",
+			`{"model":"gpt-5","messages":[{"role":"user","content":"hi"}],"reasoning_effort":"high"}`,
+		)
+		require.NotNil(t, reasoning)
+		require.NotNil(t, reasoning.Effort)
+		assert.Equal(t, "high", *reasoning.Effort)
+		assert.Equal(t, "auto", reasoning.Summary)
+	})
+
+	t.Run("non reasoning model untouched", func(t *testing.T) {
+		t.Parallel()
+
+		reasoning := convert(t, "gpt-4o", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+		assert.Nil(t, reasoning)
+	})
+}
+
+func TestChatCompletionStreamState_ReasoningSummaryPartsJoined(t *testing.T) {
+	t.Parallel()
+
+	state := &chatCompletionStreamState{}
+
+	var reasoning strings.Builder
+	for _, event := range []relaymodel.ResponseStreamEvent{
+		{Type: relaymodel.EventReasoningSummaryPartAdded},
+		{Type: relaymodel.EventReasoningSummaryTextDelta, Delta: "first"},
+		{Type: relaymodel.EventReasoningSummaryPartAdded},
+		{Type: relaymodel.EventReasoningSummaryTextDelta, Delta: "second"},
+	} {
+		switch event.Type {
+		case relaymodel.EventReasoningSummaryPartAdded:
+			state.handleReasoningSummaryPartAdded()
+		case relaymodel.EventReasoningSummaryTextDelta:
+			resp := state.handleReasoningSummaryTextDelta(&event)
+			require.NotNil(t, resp)
+			reasoning.WriteString(resp.Choices[0].Delta.ReasoningContent)
+		}
+	}
+
+	assert.Equal(t, "first\nsecond", reasoning.String())
+}
@@ -48,6 +48,8 @@ func ConvertResponseRequest(
 		return adaptor.ConvertResult{}, err
 	}
 
+	recordStreamObfuscationOption(meta, &node)
+
 	// Set the model
 	_, err = node.Set("model", ast.NewString(meta.ActualModel))
 	if err != nil {
@@ -211,6 +213,7 @@ func ResponseStreamHandler(
 		pendingEvents [][]byte
 		wroteStream   bool
 		bufferTimer   *time.Timer
+		scanErr       error
 	)
 	defer func() {
 		stopResponseStreamBufferTimer(bufferTimer)
@@ -245,6 +248,9 @@ readLoop:
 
 		if item.scanErr != nil {
 			log.Error("error reading response stream: " + item.scanErr.Error())
+
+			scanErr = item.scanErr
+
 			continue
 		}
 
@@ -257,6 +263,10 @@ readLoop:
 		data := item.data
 		data = rewriteResponseStreamEventModel(data, &event, responseModelName(meta), log)
 
+		if meta.GetBool(MetaStripObfuscation) {
+			data = stripObfuscation(data)
+		}
+
 		if err := errorState.errorBeforeEvent(&event); err != nil {
 			return errorState.result(), err
 		}
@@ -328,6 +338,10 @@ readLoop:
 
 	flushDelayedResponseStreamEvents(c, &pendingEvents, &wroteStream)
 
+	if scanErr != nil {
+		render.ResponsesStreamError(c, scanErr)
+	}
+
 	return errorState.result(), nil
 }
 
@@ -16,6 +16,7 @@ import (
 	"github.com/labring/aiproxy/core/model"
 	"github.com/labring/aiproxy/core/relay/adaptor"
 	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
 	relaymodel "github.com/labring/aiproxy/core/relay/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -1119,3 +1120,46 @@ func TestVideosGetHandlerRewritesModelToOriginModel(t *testing.T) {
 	assert.Contains(t, recorder.Body.String(), `"model":"sora-2"`)
 	assert.NotContains(t, recorder.Body.String(), "mapped-sora-2")
 }
+
+func TestResponseStreamHandlerStripsObfuscationWhenDisabled(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	m := meta.NewMeta(nil, mode.Responses, "gpt-5", model.ModelConfig{})
+
+	req := httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"/v1/responses",
+		strings.NewReader(
+			`{"model":"gpt-5","input":"hi","stream":true,"stream_options":{"include_obfuscation":false}}`,
+		),
+	)
+	_, err := ConvertResponseRequest(m, req)
+	require.NoError(t, err)
+	require.True(t, m.GetBool(MetaStripObfuscation))
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = req
+
+	body := "event: response.output_text.delta\n" +
+		"data: {\"type\":\"response.output_text.delta\",\"item_id\":\"msg_1\",\"output_index\":0,\"content_index\":0,\"delta\":\"Hi\",\"obfuscation\":\"x7Gk2\"}\n\n"
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+		Header:     make(http.Header),
+	}
+
+	_, relayErr := ResponseStreamHandler(m, &responseTestStore{}, c, resp)
+	require.Nil(t, relayErr)
+	assert.Contains(t, recorder.Body.String(), `"delta":"Hi"`)
+	assert.NotContains(t, recorder.Body.String(), "obfuscation")
+}
+
+func TestStripObfuscationKeepsDataWithoutPadding(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`{"type":"response.output_text.delta","delta":"Hi"}`)
+	assert.Equal(t, data, stripObfuscation(data))
+}
@@ -0,0 +1,942 @@
+package openai
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/conv"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	"github.com/labring/aiproxy/core/relay/render"
+)
+
+const metaResponsesChatState = "responses_chat_state"
+
+var _ adaptor.Adaptor = (*ResponsesToChatAdaptor)(nil)
+
+// ResponsesToChatAdaptor serves the responses of the chat only adaptors, the
+// providers have no server side state, so the conversation of every response
+// is kept in the store and replayed as the message history when a request
+// continues it by previous_response_id
+type ResponsesToChatAdaptor struct {
+	adaptor.Adaptor
+}
+
+func NewResponsesToChatAdaptor(a adaptor.Adaptor) adaptor.Adaptor {
+	return &ResponsesToChatAdaptor{Adaptor: a}
+}
+
+// responsesChatState is the converted request kept in the meta until the chat
+// response is converted back
+type responsesChatState struct {
+	request relaymodel.CreateResponseRequest
+	// input is the messages added by the request without the instructions,
+	// the instructions of a response are not carried over to the responses
+	// continuing it
+	input []relaymodel.Message
+}
+
+// responsesChatHistory is the metadata of the store of a converted response,
+// it keeps only the messages added by the response, the earlier messages are
+// loaded from the previous responses so the stored history grows linearly
+type responsesChatHistory struct {
+	PreviousResponseID string               `json:"previous_response_id,omitempty"`
+	Messages           []relaymodel.Message `json:"messages,omitempty"`
+	// Oversized is set instead of the messages when they do not fit in the
+	// store, the response can not be continued
+	Oversized bool `json:"oversized,omitempty"`
+}
+
+const (
+	// maxResponsesChatHistorySize keeps the metadata of a response within a
+	// mysql text column
+	maxResponsesChatHistorySize = 60 * 1024
+	// maxResponsesChatHistoryDepth bounds the previous responses loaded to
+	// rebuild a conversation
+	maxResponsesChatHistoryDepth = 256
+)
+
+// converting reports whether the responses request is served by the chat of
+// the wrapped adaptor
+func (a *ResponsesToChatAdaptor) converting(m *meta.Meta) bool {
+	if m == nil || m.Mode != mode.Responses || a.Adaptor.SupportMode(m) {
+		return false
+	}
+
+	return withChatMode(m, func() bool {
+		return a.Adaptor.SupportMode(m)
+	})
+}
+
+func (a *ResponsesToChatAdaptor) SupportMode(m *meta.Meta) bool {
+	return a.Adaptor.SupportMode(m) || a.converting(m)
+}
+
+func (a *ResponsesToChatAdaptor) GetRequestURL(
+	m *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+) (adaptor.RequestURL, error) {
+	if !a.converting(m) {
+		return a.Adaptor.GetRequestURL(m, store, c)
+	}
+
+	type result struct {
+		url adaptor.RequestURL
+		err error
+	}
+
+	r := withChatMode(m, func() result {
+		url, err := a.Adaptor.GetRequestURL(m, store, c)
+		return result{url: url, err: err}
+	})
+
+	return r.url, r.err
+}
+
+func (a *ResponsesToChatAdaptor) SetupRequestHeader(
+	m *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	req *http.Request,
+) error {
+	if !a.converting(m) {
+		return a.Adaptor.SetupRequestHeader(m, store, c, req)
+	}
+
+	return withChatMode(m, func() error {
+		return a.Adaptor.SetupRequestHeader(m, store, c, req)
+	})
+}
+
+func (a *ResponsesToChatAdaptor) ConvertRequest(
+	m *meta.Meta,
+	store adaptor.Store,
+	req *http.Request,
+) (adaptor.ConvertResult, error) {
+	if !a.converting(m) {
+		return a.Adaptor.ConvertRequest(m, store, req)
+	}
+
+	var responsesReq relaymodel.CreateResponseRequest
+	if err := common.UnmarshalRequestReusable(req, &responsesReq); err != nil {
+		return adaptor.ConvertResult{}, convertRequestError(m, err.Error())
+	}
+
+	var history []relaymodel.Message
+	if responsesReq.PreviousResponseID != nil && *responsesReq.PreviousResponseID != "" {
+		previous, err := loadResponsesChatHistory(m, store, *responsesReq.PreviousResponseID)
+		if err != nil {
+			return adaptor.ConvertResult{}, convertRequestError(m, err.Error())
+		}
+
+		history = previous
+	}
+
+	input, err := ConvertResponsesInputToMessages(responsesReq.Input)
+	if err != nil {
+		return adaptor.ConvertResult{}, convertRequestError(m, err.Error())
+	}
+
+	history = append(history, input...)
+
+	chatBody, err := ConvertResponsesToChatRequest(&responsesReq, history)
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	m.Set(metaResponsesChatState, &responsesChatState{
+		request: responsesReq,
+		input:   input,
+	})
+
+	chatReq := req.Clone(req.Context())
+	common.SetRequestBody(chatReq, chatBody)
+
+	type result struct {
+		convert adaptor.ConvertResult
+		err     error
+	}
+
+	r := withChatMode(m, func() result {
+		convert, err := a.Adaptor.ConvertRequest(m, store, chatReq)
+		return result{convert: convert, err: err}
+	})
+
+	return r.convert, r.err
+}
+
+func (a *ResponsesToChatAdaptor) DoRequest(
+	m *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	req *http.Request,
+) (*http.Response, error) {
+	if !a.converting(m) {
+		return a.Adaptor.DoRequest(m, store, c, req)
+	}
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+
+	r := withChatMode(m, func() result {
+		resp, err := a.Adaptor.DoRequest(m, store, c, req)
+		return result{resp: resp, err: err}
+	})
+
+	return r.resp, r.err
+}
+
+func (a *ResponsesToChatAdaptor) DoResponse(
+	m *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if !a.converting(m) {
+		return a.Adaptor.DoResponse(m, store, c, resp)
+	}
+
+	v, _ := m.Get(metaResponsesChatState)
+
+	state, ok := v.(*responsesChatState)
+	if !ok {
+		return adaptor.DoResponseResult{}, relaymodel.WrapperOpenAIErrorWithMessage(
+			"responses request is not converted",
+			"convert_request_failed",
+			http.StatusInternalServerError,
+		)
+	}
+
+	writer := c.Writer
+	capture := &chatCaptureWriter{ResponseWriter: writer}
+	c.Writer = capture
+
+	type result struct {
+		result adaptor.DoResponseResult
+		err    adaptor.Error
+	}
+
+	r := withChatMode(m, func() result {
+		res, err := a.Adaptor.DoResponse(m, store, c, resp)
+		return result{result: res, err: err}
+	})
+
+	c.Writer = writer
+
+	if r.err != nil {
+		return r.result, r.err
+	}
+
+	var chatResp relaymodel.TextResponse
+	if err := sonic.Unmarshal(capture.body.Bytes(), &chatResp); err != nil {
+		return r.result, relaymodel.WrapperOpenAIError(
+			err,
+			"unmarshal_response_body_failed",
+			http.StatusInternalServerError,
+		)
+	}
+
+	response, assistant := ConvertChatToResponsesResponse(m, &state.request, &chatResp)
+
+	if response.Store {
+		var previousResponseID string
+		if state.request.PreviousResponseID != nil {
+			previousResponseID = *state.request.PreviousResponseID
+		}
+
+		saveResponsesChatHistory(
+			m,
+			store,
+			c,
+			response.ID,
+			previousResponseID,
+			append(state.input, assistant),
+		)
+	}
+
+	c.Writer.Header().Del("Content-Length")
+
+	if state.request.Stream {
+		writeResponsesChatStream(c, response)
+	} else {
+		body, err := sonic.Marshal(response)
+		if err != nil {
+			return r.result, relaymodel.WrapperOpenAIError(
+				err,
+				"marshal_response_body_failed",
+				http.StatusInternalServerError,
+			)
+		}
+
+		c.Writer.Header().Set("Content-Type", "application/json")
+		c.Writer.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		c.Writer.WriteHeader(http.StatusOK)
+		_, _ = c.Writer.Write(body)
+	}
+
+	r.result.UpstreamID = response.ID
+
+	return r.result, nil
+}
+
+// loadResponsesChatHistory rebuilds the conversation of the response by
+// following its previous responses
+func loadResponsesChatHistory(
+	m *meta.Meta,
+	store adaptor.Store,
+	responseID string,
+) ([]relaymodel.Message, error) {
+	var turns [][]relaymodel.Message
+
+	for id := responseID; id != ""; {
+		if len(turns) == maxResponsesChatHistoryDepth {
+			return nil, fmt.Errorf(
+				"the conversation of previous response %s exceeds %d responses",
+				responseID,
+				maxResponsesChatHistoryDepth,
+			)
+		}
+
+		history, err := loadResponsesChatTurn(m, store, id)
+		if err != nil {
+			if id != responseID {
+				return nil, fmt.Errorf(
+					"the conversation of previous response %s is incomplete: %w",
+					responseID,
+					err,
+				)
+			}
+
+			return nil, err
+		}
+
+		turns = append(turns, history.Messages)
+		id = history.PreviousResponseID
+	}
+
+	var messages []relaymodel.Message
+	for _, turn := range slices.Backward(turns) {
+		messages = append(messages, turn...)
+	}
+
+	return messages, nil
+}
+
+func loadResponsesChatTurn(
+	m *meta.Meta,
+	store adaptor.Store,
+	responseID string,
+) (responsesChatHistory, error) {
+	cache, err := store.GetStore(m.Group.ID, m.Token.ID, model.ResponseStoreID(responseID))
+	if err != nil {
+		return responsesChatHistory{}, fmt.Errorf("previous response %s not found", responseID)
+	}
+
+	if cache.Metadata == "" {
+		return responsesChatHistory{}, fmt.Errorf(
+			"previous response %s can not be continued by this channel",
+			responseID,
+		)
+	}
+
+	var history responsesChatHistory
+	if err := sonic.UnmarshalString(cache.Metadata, &history); err != nil {
+		return responsesChatHistory{}, fmt.Errorf(
+			"invalid history of previous response %s: %w",
+			responseID,
+			err,
+		)
+	}
+
+	if history.Oversized {
+		return responsesChatHistory{}, fmt.Errorf(
+			"previous response %s is larger than %d bytes and can not be continued",
+			responseID,
+			maxResponsesChatHistorySize,
+		)
+	}
+
+	return history, nil
+}
+
+// saveResponsesChatHistory stores the messages added by the response, a
+// response too large for the store is saved as oversized so continuing it
+// fails with a clear error
+func saveResponsesChatHistory(
+	m *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	responseID, previousResponseID string,
+	messages []relaymodel.Message,
+) {
+	log := common.GetLogger(c)
+
+	history := responsesChatHistory{
+		PreviousResponseID: previousResponseID,
+		Messages:           messages,
+	}
+
+	metadata, err := sonic.MarshalString(history)
+	if err != nil {
+		log.Errorf("marshal response history failed: %v", err)
+		return
+	}
+
+	if len(metadata) > maxResponsesChatHistorySize {
+		log.Warnf(
+			"response history of %s is %d bytes, larger than %d bytes",
+			responseID,
+			len(metadata),
+			maxResponsesChatHistorySize,
+		)
+
+		metadata, err = sonic.MarshalString(responsesChatHistory{Oversized: true})
+		if err != nil {
+			log.Errorf("marshal response history failed: %v", err)
+			return
+		}
+	}
+
+	err = store.SaveStore(adaptor.StoreCache{
+		ID:        model.ResponseStoreID(responseID),
+		GroupID:   m.Group.ID,
+		TokenID:   m.Token.ID,
+		ChannelID: m.Channel.ID,
+		Model:     m.OriginModel,
+		Metadata:  metadata,
+		ExpiresAt: time.Now().Add(time.Hour * 24 * 7),
+	})
+	if err != nil {
+		log.Errorf("save response store failed: %v", err)
+	}
+}
+
+// ConvertResponsesToChatRequest builds the chat request of the responses
+// request, the conversation is sent as the messages and the upstream is always
+// requested without streaming
+func ConvertResponsesToChatRequest(
+	req *relaymodel.CreateResponseRequest,
+	history []relaymodel.Message,
+) ([]byte, error) {
+	messages := make([]relaymodel.Message, 0, len(history)+1)
+	if req.Instructions != nil && *req.Instructions != "" {
+		messages = append(messages, relaymodel.Message{
+			Role:    relaymodel.RoleSystem,
+			Content: *req.Instructions,
+		})
+	}
+
+	messages = append(messages, history...)
+
+	chatReq := relaymodel.GeneralOpenAIRequest{
+		Model:             req.Model,
+		Messages:          messages,
+		Temperature:       req.Temperature,
+		TopP:              req.TopP,
+		TopLogprobs:       req.TopLogprobs,
+		ParallelToolCalls: req.ParallelToolCalls,
+		ToolChoice:        convertResponseToolChoiceToChatToolChoice(req.ToolChoice),
+		Tools:             convertResponseToolsToChatTools(req.Tools),
+		ResponseFormat:    convertResponseTextToChatResponseFormat(req.Text),
+	}
+
+	if req.MaxOutputTokens != nil {
+		chatReq.MaxTokens = *req.MaxOutputTokens
+	}
+
+	if req.TopLogprobs != nil ||
+		slices.Contains(req.Include, "message.output_text.logprobs") {
+		logprobs := true
+		chatReq.Logprobs = &logprobs
+	}
+
+	if req.User != nil {
+		chatReq.User = *req.User
+	}
+
+	if req.Reasoning != nil && req.Reasoning.Effort != nil {
+		chatReq.ReasoningEffort = req.Reasoning.Effort
+	}
+
+	return sonic.Marshal(chatReq)
+}
+
+func convertResponseToolsToChatTools(tools []relaymodel.ResponseTool) []relaymodel.Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	chatTools := make([]relaymodel.Tool, 0, len(tools))
+	for _, tool := range tools {
+		// the built-in tools of the responses have no chat equivalent
+		if tool.Type != relaymodel.ToolChoiceTypeFunction {
+			continue
+		}
+
+		chatTools = append(chatTools, relaymodel.Tool{
+			Type: relaymodel.ToolChoiceTypeFunction,
+			Function: relaymodel.Function{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+
+	return chatTools
+}
+
+func convertResponseToolChoiceToChatToolChoice(toolChoice any) any {
+	toolChoiceMap, ok := toolChoice.(map[string]any)
+	if !ok {
+		return toolChoice
+	}
+
+	name, _ := toolChoiceMap["name"].(string)
+	if name == "" {
+		return toolChoice
+	}
+
+	return map[string]any{
+		"type": relaymodel.ToolChoiceTypeFunction,
+		"function": map[string]any{
+			"name": name,
+		},
+	}
+}
+
+func convertResponseTextToChatResponseFormat(
+	text *relaymodel.ResponseText,
+) *relaymodel.ResponseFormat {
+	if text == nil || text.Format.Type == "" || text.Format.Type == "text" {
+		return nil
+	}
+
+	format := &relaymodel.ResponseFormat{
+		Type: text.Format.Type,
+	}
+
+	if text.Format.Type == "json_schema" {
+		format.JSONSchema = &relaymodel.JSONSchema{
+			Name:        text.Format.Name,
+			Schema:      text.Format.Schema,
+			Strict:      text.Format.Strict,
+			Description: text.Format.Description,
+		}
+	}
+
+	return format
+}
+
+// ConvertResponsesInputToMessages converts the input of a responses request to
+// the chat messages, the reasoning items are dropped
+func ConvertResponsesInputToMessages(input any) ([]relaymodel.Message, error) {
+	switch input := input.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []relaymodel.Message{
+			{
+				Role:    relaymodel.RoleUser,
+				Content: input,
+			},
+		}, nil
+	case []any:
+		messages := make([]relaymodel.Message, 0, len(input))
+
+		for _, item := range input {
+			itemMap, ok := item.(map[string]any)
+			if !ok {
+				return nil, errors.New("invalid input item")
+			}
+
+			messages = appendResponseInputItem(messages, itemMap)
+		}
+
+		return messages, nil
+	default:
+		return nil, errors.New("input must be a string or an array")
+	}
+}
+
+func appendResponseInputItem(
+	messages []relaymodel.Message,
+	item map[string]any,
+) []relaymodel.Message {
+	itemType, _ := item["type"].(string)
+
+	switch itemType {
+	case "", relaymodel.InputItemTypeMessage:
+		role, _ := item["role"].(string)
+		if role == relaymodel.RoleDeveloper {
+			role = relaymodel.RoleSystem
+		}
+
+		return append(messages, relaymodel.Message{
+			Role:    role,
+			Content: convertResponseInputContent(item["content"]),
+		})
+	case relaymodel.InputItemTypeFunctionCall:
+		callID, _ := item["call_id"].(string)
+		name, _ := item["name"].(string)
+		arguments, _ := item["arguments"].(string)
+
+		toolCall := relaymodel.ToolCall{
+			ID:   callID,
+			Type: relaymodel.ToolChoiceTypeFunction,
+			Function: relaymodel.Function{
+				Name:      name,
+				Arguments: arguments,
+			},
+		}
+
+		// the parallel function calls are the tool calls of one assistant message
+		if last := len(messages) - 1; last >= 0 &&
+			messages[last].Role == relaymodel.RoleAssistant &&
+			len(messages[last].ToolCalls) > 0 {
+			toolCall.Index = len(messages[last].ToolCalls)
+			messages[last].ToolCalls = append(messages[last].ToolCalls, toolCall)
+
+			return messages
+		}
+
+		return append(messages, relaymodel.Message{
+			Role:      relaymodel.RoleAssistant,
+			ToolCalls: []relaymodel.ToolCall{toolCall},
+		})
+	case relaymodel.InputItemTypeFunctionCallOutput:
+		callID, _ := item["call_id"].(string)
+
+		output, ok := item["output"].(string)
+		if !ok {
+			output, _ = sonic.MarshalString(item["output"])
+		}
+
+		return append(messages, relaymodel.Message{
+			Role:       relaymodel.RoleTool,
+			ToolCallID: callID,
+			Content:    output,
+		})
+	default:
+		return messages
+	}
+}
+
+// convertResponseInputContent converts the content of an input message, the
+// text only content is joined to a string
+func convertResponseInputContent(content any) any {
+	parts, ok := content.([]any)
+	if !ok {
+		return content
+	}
+
+	var (
+		texts    []string
+		contents []relaymodel.MessageContent
+		hasImage bool
+	)
+
+	for _, part := range parts {
+		partMap, ok := part.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		partType, _ := partMap["type"].(string)
+		switch partType {
+		case relaymodel.InputContentTypeInputText,
+			relaymodel.InputContentTypeOutputText,
+			relaymodel.ContentTypeText:
+			text, _ := partMap["text"].(string)
+			texts = append(texts, text)
+			contents = append(contents, relaymodel.MessageContent{
+				Type: relaymodel.ContentTypeText,
+				Text: text,
+			})
+		case "input_image":
+			url, _ := partMap["image_url"].(string)
+			if url == "" {
+				continue
+			}
+
+			detail, _ := partMap["detail"].(string)
+			hasImage = true
+			contents = append(contents, relaymodel.MessageContent{
+				Type: relaymodel.ContentTypeImageURL,
+				ImageURL: &relaymodel.ImageURL{
+					URL:    url,
+					Detail: detail,
+				},
+			})
+		}
+	}
+
+	if hasImage {
+		return contents
+	}
+
+	return strings.Join(texts, "\n")
+}
+
+// ConvertChatToResponsesResponse converts the chat completion to the response
+// object, the assistant message is returned to be kept in the history
+func ConvertChatToResponsesResponse(
+	m *meta.Meta,
+	req *relaymodel.CreateResponseRequest,
+	chatResp *relaymodel.TextResponse,
+) (relaymodel.Response, relaymodel.Message) {
+	storeResponse := req.Store == nil || *req.Store
+
+	parallelToolCalls := req.ParallelToolCalls == nil || *req.ParallelToolCalls
+
+	response := relaymodel.Response{
+		ID:                 "resp_" + common.ShortUUID(),
+		Object:             "response",
+		CreatedAt:          time.Now().Unix(),
+		Status:             relaymodel.ResponseStatusCompleted,
+		Instructions:       req.Instructions,
+		MaxOutputTokens:    req.MaxOutputTokens,
+		Model:              responseModelName(m),
+		Output:             []relaymodel.OutputItem{},
+		ParallelToolCalls:  parallelToolCalls,
+		PreviousResponseID: req.PreviousResponseID,
+		Store:              storeResponse,
+		Temperature:        1,
+		Text:               relaymodel.ResponseText{Format: relaymodel.ResponseTextFormat{Type: "text"}},
+		ToolChoice:         req.ToolChoice,
+		Tools:              req.Tools,
+		TopP:               1,
+		Truncation:         "disabled",
+		User:               req.User,
+		Metadata:           req.Metadata,
+	}
+
+	if req.Temperature != nil {
+		response.Temperature = *req.Temperature
+	}
+
+	if req.TopP != nil {
+		response.TopP = *req.TopP
+	}
+
+	if req.Text != nil {
+		response.Text = *req.Text
+	}
+
+	if response.ToolChoice == nil {
+		response.ToolChoice = relaymodel.ToolChoiceAuto
+	}
+
+	if response.Tools == nil {
+		response.Tools = []relaymodel.ResponseTool{}
+	}
+
+	usage := chatResp.Usage.ToResponseUsage()
+	response.Usage = &usage
+
+	assistant := relaymodel.Message{Role: relaymodel.RoleAssistant}
+
+	if len(chatResp.Choices) == 0 {
+		return response, assistant
+	}
+
+	choice := chatResp.Choices[0]
+
+	switch choice.FinishReason {
+	case relaymodel.FinishReasonLength:
+		response.Status = relaymodel.ResponseStatusIncomplete
+		response.IncompleteDetails = &relaymodel.IncompleteDetails{Reason: "max_output_tokens"}
+	case relaymodel.FinishReasonContentFilter:
+		response.Status = relaymodel.ResponseStatusIncomplete
+		response.IncompleteDetails = &relaymodel.IncompleteDetails{Reason: "content_filter"}
+	}
+
+	if reasoning := choice.Message.ReasoningContent; reasoning != "" {
+		response.Output = append(response.Output, relaymodel.OutputItem{
+			ID:   "rs_" + common.ShortUUID(),
+			Type: relaymodel.InputItemTypeReasoning,
+			Summary: []relaymodel.SummaryPart{
+				{Type: "summary_text", Text: reasoning},
+			},
+		})
+	}
+
+	if text := choice.Message.StringContent(); text != "" && text != choice.Message.ReasoningContent {
+		assistant.Content = text
+		response.Output = append(response.Output, relaymodel.OutputItem{
+			ID:     "msg_" + common.ShortUUID(),
+			Type:   relaymodel.InputItemTypeMessage,
+			Status: relaymodel.ResponseStatusCompleted,
+			Role:   relaymodel.RoleAssistant,
+			Content: []relaymodel.OutputContent{
+				{
+					Type:        relaymodel.OutputContentTypeOutputText,
+					Text:        text,
+					Annotations: []any{},
+					Logprobs:    choiceLogprobsContent(choice.Logprobs),
+				},
+			},
+		})
+	}
+
+	for _, toolCall := range choice.Message.ToolCalls {
+		assistant.ToolCalls = append(assistant.ToolCalls, relaymodel.ToolCall{
+			Index:    len(assistant.ToolCalls),
+			ID:       toolCall.ID,
+			Type:     relaymodel.ToolChoiceTypeFunction,
+			Function: toolCall.Function,
+		})
+		response.Output = append(response.Output, relaymodel.OutputItem{
+			ID:        "fc_" + common.ShortUUID(),
+			Type:      relaymodel.InputItemTypeFunctionCall,
+			Status:    relaymodel.ResponseStatusCompleted,
+			CallID:    toolCall.ID,
+			Name:      toolCall.Function.Name,
+			Arguments: relaymodel.ResponseArguments(toolCall.Function.Arguments),
+		})
+	}
+
+	return response, assistant
+}
+
+func choiceLogprobsContent(logprobs *relaymodel.ChoiceLogprobs) []relaymodel.TokenLogprob {
+	if logprobs == nil {
+		return nil
+	}
+
+	return logprobs.Content
+}
+
+// writeResponsesChatStream writes the response as the responses stream events,
+// the upstream is not streamed so every output item is sent at once
+func writeResponsesChatStream(c *gin.Context, response relaymodel.Response) {
+	created := response
+	created.Status = relaymodel.ResponseStatusInProgress
+	created.Output = []relaymodel.OutputItem{}
+	created.Usage = nil
+
+	sequence := 0
+	emit := func(event relaymodel.ResponseStreamEvent) {
+		event.SequenceNumber = sequence
+		sequence++
+		_ = render.ResponsesEventObjectData(c, event.Type, event)
+	}
+
+	emit(relaymodel.ResponseStreamEvent{
+		Type:     relaymodel.EventResponseCreated,
+		Response: &created,
+	})
+
+	for i, item := range response.Output {
+		added := item
+		if item.Type == relaymodel.InputItemTypeMessage {
+			added.Content = []relaymodel.OutputContent{}
+		}
+
+		emit(relaymodel.ResponseStreamEvent{
+			Type:        relaymodel.EventOutputItemAdded,
+			OutputIndex: new(i),
+			Item:        &added,
+		})
+
+		switch item.Type {
+		case relaymodel.InputItemTypeMessage:
+			for j, content := range item.Content {
+				emit(relaymodel.ResponseStreamEvent{
+					Type:         relaymodel.EventContentPartAdded,
+					ItemID:       item.ID,
+					OutputIndex:  new(i),
+					ContentIndex: new(j),
+					Part: &relaymodel.OutputContent{
+						Type:        content.Type,
+						Annotations: []any{},
+					},
+				})
+				emit(relaymodel.ResponseStreamEvent{
+					Type:         relaymodel.EventOutputTextDelta,
+					ItemID:       item.ID,
+					OutputIndex:  new(i),
+					ContentIndex: new(j),
+					Delta:        content.Text,
+				})
+				emit(relaymodel.ResponseStreamEvent{
+					Type:         relaymodel.EventOutputTextDone,
+					ItemID:       item.ID,
+					OutputIndex:  new(i),
+					ContentIndex: new(j),
+					Text:         content.Text,
+				})
+				emit(relaymodel.ResponseStreamEvent{
+					Type:         relaymodel.EventContentPartDone,
+					ItemID:       item.ID,
+					OutputIndex:  new(i),
+					ContentIndex: new(j),
+					Part:         &content,
+				})
+			}
+		case relaymodel.InputItemTypeFunctionCall:
+			emit(relaymodel.ResponseStreamEvent{
+				Type:        relaymodel.EventFunctionCallArgumentsDelta,
+				ItemID:      item.ID,
+				OutputIndex: new(i),
+				Delta:       item.Arguments.String(),
+			})
+			emit(relaymodel.ResponseStreamEvent{
+				Type:        relaymodel.EventFunctionCallArgumentsDone,
+				ItemID:      item.ID,
+				OutputIndex: new(i),
+				Arguments:   item.Arguments,
+			})
+		}
+
+		emit(relaymodel.ResponseStreamEvent{
+			Type:        relaymodel.EventOutputItemDone,
+			OutputIndex: new(i),
+			Item:        &item,
+		})
+	}
+
+	completed := relaymodel.EventResponseCompleted
+	if response.Status == relaymodel.ResponseStatusIncomplete {
+		completed = relaymodel.EventResponseIncomplete
+	}
+
+	emit(relaymodel.ResponseStreamEvent{
+		Type:     completed,
+		Response: &response,
+	})
+}
+
+// chatCaptureWriter keeps the chat response written by the adaptor, the
+// response is converted before it is sent to the client
+type chatCaptureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *chatCaptureWriter) WriteHeader(int) {}
+
+func (w *chatCaptureWriter) WriteHeaderNow() {}
+
+func (w *chatCaptureWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *chatCaptureWriter) WriteString(s string) (int, error) {
+	return w.Write(conv.StringToBytes(s))
+}
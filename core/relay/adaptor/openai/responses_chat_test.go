@@ -0,0 +1,214 @@
+//nolint:testpackage
+package openai
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type responsesChatTestStore struct {
+	responseTestStore
+	stores map[string]adaptor.StoreCache
+}
+
+func (s *responsesChatTestStore) GetStore(_ string, _ int, id string) (adaptor.StoreCache, error) {
+	cache, ok := s.stores[id]
+	if !ok {
+		return adaptor.StoreCache{}, assert.AnError
+	}
+
+	return cache, nil
+}
+
+func TestConvertResponsesInputToMessages(t *testing.T) {
+	var input any
+	require.NoError(t, sonic.UnmarshalString(`[
+		{"role":"developer","content":"be brief"},
+		{"type":"message","role":"user","content":[{"type":"input_text","text":"weather?"}]},
+		{"type":"reasoning","summary":[]},
+		{"type":"function_call","call_id":"call_1","name":"weather","arguments":"{}"},
+		{"type":"function_call","call_id":"call_2","name":"time","arguments":"{}"},
+		{"type":"function_call_output","call_id":"call_1","output":"sunny"}
+	]`, &input))
+
+	messages, err := ConvertResponsesInputToMessages(input)
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+
+	assert.Equal(t, relaymodel.RoleSystem, messages[0].Role)
+	assert.Equal(t, "be brief", messages[0].Content)
+	assert.Equal(t, "weather?", messages[1].Content)
+	assert.Equal(t, relaymodel.RoleAssistant, messages[2].Role)
+	require.Len(t, messages[2].ToolCalls, 2)
+	assert.Equal(t, "call_2", messages[2].ToolCalls[1].ID)
+	assert.Equal(t, 1, messages[2].ToolCalls[1].Index)
+	assert.Equal(t, relaymodel.RoleTool, messages[3].Role)
+	assert.Equal(t, "call_1", messages[3].ToolCallID)
+}
+
+func TestResponsesToChatReplaysPreviousResponse(t *testing.T) {
+	history, err := sonic.MarshalString(responsesChatHistory{
+		Messages: []relaymodel.Message{
+			{Role: relaymodel.RoleUser, Content: "hi"},
+			{Role: relaymodel.RoleAssistant, Content: "hello"},
+		},
+	})
+	require.NoError(t, err)
+
+	store := &responsesChatTestStore{
+		stores: map[string]adaptor.StoreCache{
+			model.ResponseStoreID("resp_1"): {Metadata: history},
+		},
+	}
+	m := &meta.Meta{
+		Mode:  mode.Responses,
+		Group: model.GroupCache{ID: "group-1"},
+		Token: model.TokenCache{ID: 7},
+	}
+
+	previous, err := loadResponsesChatHistory(m, store, "resp_1")
+	require.NoError(t, err)
+
+	instructions := "be brief"
+	body, err := ConvertResponsesToChatRequest(&relaymodel.CreateResponseRequest{
+		Model:        "gpt-4o-mini",
+		Instructions: &instructions,
+	}, append(previous, relaymodel.Message{Role: relaymodel.RoleUser, Content: "again"}))
+	require.NoError(t, err)
+
+	var chatReq relaymodel.GeneralOpenAIRequest
+	require.NoError(t, sonic.Unmarshal(body, &chatReq))
+	require.Len(t, chatReq.Messages, 4)
+	assert.Equal(t, relaymodel.RoleSystem, chatReq.Messages[0].Role)
+	assert.Equal(t, "hello", chatReq.Messages[2].Content)
+	assert.Equal(t, "again", chatReq.Messages[3].Content)
+	assert.False(t, chatReq.Stream)
+
+	_, err = loadResponsesChatHistory(m, store, "resp_unknown")
+	require.Error(t, err)
+
+	store.stores[model.ResponseStoreID("resp_native")] = adaptor.StoreCache{}
+	_, err = loadResponsesChatHistory(m, store, "resp_native")
+	require.Error(t, err)
+}
+
+func TestConvertChatToResponsesResponse(t *testing.T) {
+	m := &meta.Meta{Mode: mode.Responses, OriginModel: "gpt-4o-mini"}
+	chatResp := &relaymodel.TextResponse{
+		Choices: []*relaymodel.TextResponseChoice{
+			{
+				FinishReason: relaymodel.FinishReasonToolCalls,
+				Message: relaymodel.Message{
+					Role:    relaymodel.RoleAssistant,
+					Content: "checking",
+					ToolCalls: []relaymodel.ToolCall{
+						{
+							ID:   "call_1",
+							Type: relaymodel.ToolChoiceTypeFunction,
+							Function: relaymodel.Function{
+								Name:      "weather",
+								Arguments: `{"city":"Paris"}`,
+							},
+						},
+					},
+				},
+			},
+		},
+		Usage: relaymodel.ChatUsage{PromptTokens: 3, CompletionTokens: 5, TotalTokens: 8},
+	}
+
+	response, assistant := ConvertChatToResponsesResponse(
+		m,
+		&relaymodel.CreateResponseRequest{},
+		chatResp,
+	)
+
+	assert.True(t, response.Store)
+	assert.Equal(t, relaymodel.ResponseStatusCompleted, response.Status)
+	require.Len(t, response.Output, 2)
+	assert.Equal(t, relaymodel.InputItemTypeMessage, response.Output[0].Type)
+	assert.Equal(t, "checking", response.Output[0].Content[0].Text)
+	assert.Equal(t, relaymodel.InputItemTypeFunctionCall, response.Output[1].Type)
+	assert.Equal(t, "call_1", response.Output[1].CallID)
+	assert.Equal(t, int64(8), response.Usage.TotalTokens)
+
+	assert.Equal(t, "checking", assistant.Content)
+	require.Len(t, assistant.ToolCalls, 1)
+	assert.Equal(t, "weather", assistant.ToolCalls[0].Function.Name)
+}
+
+func TestResponsesToChatHistoryChain(t *testing.T) {
+	marshal := func(history responsesChatHistory) string {
+		metadata, err := sonic.MarshalString(history)
+		require.NoError(t, err)
+
+		return metadata
+	}
+
+	store := &responsesChatTestStore{
+		stores: map[string]adaptor.StoreCache{
+			model.ResponseStoreID("resp_1"): {Metadata: marshal(responsesChatHistory{
+				Messages: []relaymodel.Message{
+					{Role: relaymodel.RoleUser, Content: "hi"},
+					{Role: relaymodel.RoleAssistant, Content: "hello"},
+				},
+			})},
+			model.ResponseStoreID("resp_2"): {Metadata: marshal(responsesChatHistory{
+				PreviousResponseID: "resp_1",
+				Messages: []relaymodel.Message{
+					{Role: relaymodel.RoleUser, Content: "again"},
+					{Role: relaymodel.RoleAssistant, Content: "hello again"},
+				},
+			})},
+			model.ResponseStoreID("resp_big"): {Metadata: marshal(responsesChatHistory{
+				Oversized: true,
+			})},
+			model.ResponseStoreID("resp_3"): {Metadata: marshal(responsesChatHistory{
+				PreviousResponseID: "resp_big",
+				Messages:           []relaymodel.Message{{Role: relaymodel.RoleUser, Content: "x"}},
+			})},
+		},
+	}
+	m := &meta.Meta{
+		Mode:  mode.Responses,
+		Group: model.GroupCache{ID: "group-1"},
+		Token: model.TokenCache{ID: 7},
+	}
+
+	messages, err := loadResponsesChatHistory(m, store, "resp_2")
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+	assert.Equal(t, "hi", messages[0].Content)
+	assert.Equal(t, "hello again", messages[3].Content)
+
+	_, err = loadResponsesChatHistory(m, store, "resp_big")
+	require.ErrorContains(t, err, "can not be continued")
+
+	_, err = loadResponsesChatHistory(m, store, "resp_3")
+	require.ErrorContains(t, err, "incomplete")
+
+	saved := &responseTestStore{}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	saveResponsesChatHistory(m, saved, c, "resp_4", "resp_2", []relaymodel.Message{
+		{Role: relaymodel.RoleUser, Content: strings.Repeat("a", maxResponsesChatHistorySize)},
+	})
+	require.Len(t, saved.saved, 1)
+
+	var history responsesChatHistory
+	require.NoError(t, sonic.UnmarshalString(saved.saved[0].Metadata, &history))
+	assert.True(t, history.Oversized)
+	assert.Empty(t, history.Messages)
+}
@@ -107,6 +107,9 @@ func CountTokenMessages(messages []model.Message, model string, fetchImage bool)
 							imageTokenCount += imageTokens
 						}
 					}
+				case "image":
+					// the claude images, the source is only inspected when it is inline
+					imageTokenCount += countClaudeImageTokens(m["source"])
 				}
 			}
 		}
@@ -170,20 +173,21 @@ func countImageTokens(url, detail, model string, fetchImage bool) (_ int64, err
 	// The following image, which is 125x50, is still treated as high-res, taken
 	// 255 tokens in the response of non-stream chat completion api.
 	// https://upload.wikimedia.org/wikipedia/commons/1/10/18_Infantry_Division_Messina.jpg
-	if detail == "" || detail == "auto" {
+	if detail == "" || detail == image.DetailAuto {
 		// assume by test, not sure if this is correct
-		detail = "high"
+		detail = image.DetailHigh
 	}
 
 	switch detail {
-	case "low":
+	case image.DetailLow:
 		if strings.HasPrefix(model, "gpt-4o-mini") {
 			return gpt4oMiniLowDetailCost, nil
 		}
 		return lowDetailCost, nil
 	// case "high":
 	default:
-		if fetchImage {
+		// the size of the inline images is read without fetching
+		if fetchImage || strings.HasPrefix(url, "data:image/") {
 			width, height, err = image.GetImageSize(url)
 			if err != nil {
 				return 0, err
@@ -213,6 +217,31 @@ func countImageTokens(url, detail, model string, fetchImage bool) (_ int64, err
 	}
 }
 
+// claudeMaxImageTokens is the tokens of the largest image claude reads, about
+// 1.15 megapixels, the larger images are scaled down to it
+const claudeMaxImageTokens = 1600
+
+// countClaudeImageTokens estimates the tokens of a claude image as
+// width * height / 750, the images not sent inline count as the largest image
+func countClaudeImageTokens(source any) int64 {
+	src, ok := source.(map[string]any)
+	if !ok {
+		return claudeMaxImageTokens
+	}
+
+	data, _ := src["data"].(string)
+	if src["type"] != "base64" || data == "" {
+		return claudeMaxImageTokens
+	}
+
+	width, height, err := image.GetImageSizeFromBase64(data)
+	if err != nil {
+		return claudeMaxImageTokens
+	}
+
+	return min(int64(width)*int64(height)/750, claudeMaxImageTokens)
+}
+
 func CountTokenInput(input any, model string) int64 {
 	switch v := input.(type) {
 	case string:
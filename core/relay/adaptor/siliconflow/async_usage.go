@@ -145,7 +145,12 @@ func (a *Adaptor) fetchVideoStatus(
 	req.Header.Set("Content-Type", "application/json")
 
 	if channel != nil {
-		req.Header.Set("Authorization", "Bearer "+channel.Key)
+		key, err := channel.GetKey()
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+key)
 	}
 
 	var (
@@ -0,0 +1,124 @@
+package suno
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptor/registry"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/labring/aiproxy/core/relay/utils"
+)
+
+var _ adaptor.Adaptor = (*Adaptor)(nil)
+
+type Adaptor struct{}
+
+func init() {
+	registry.Register(model.ChannelTypeSuno, &Adaptor{})
+}
+
+// suno has no official api, the base url points to a self-hosted suno-compatible service
+func (a *Adaptor) DefaultBaseURL() string {
+	return ""
+}
+
+func (a *Adaptor) SupportMode(mt *meta.Meta) bool {
+	m := adaptor.ModeFromMeta(mt)
+
+	return m == mode.AudioGenerations ||
+		m == mode.AudioGenerationsGet
+}
+
+func (a *Adaptor) Metadata() adaptor.Metadata {
+	return adaptor.Metadata{
+		Readme:  "Suno-compatible music generation service\nExposes `/v1/audio/generations` with async task polling at `/v1/audio/generations/{id}`\nUsage is reported as generated clips in output tokens",
+		KeyHelp: "api key of the suno-compatible service",
+		Models:  ModelList,
+	}
+}
+
+func (a *Adaptor) GetRequestURL(
+	meta *meta.Meta,
+	_ adaptor.Store,
+	_ *gin.Context,
+) (adaptor.RequestURL, error) {
+	switch meta.Mode {
+	case mode.AudioGenerations:
+		u, err := url.JoinPath(meta.Channel.BaseURL, "/suno/submit/music")
+		if err != nil {
+			return adaptor.RequestURL{}, err
+		}
+
+		return adaptor.RequestURL{
+			Method: http.MethodPost,
+			URL:    u,
+		}, nil
+	case mode.AudioGenerationsGet:
+		u, err := url.JoinPath(meta.Channel.BaseURL, "/suno/fetch", meta.GenerationID)
+		if err != nil {
+			return adaptor.RequestURL{}, err
+		}
+
+		return adaptor.RequestURL{
+			Method: http.MethodGet,
+			URL:    u,
+		}, nil
+	default:
+		return adaptor.RequestURL{}, fmt.Errorf("unsupported relay mode %d for suno", meta.Mode)
+	}
+}
+
+func (a *Adaptor) SetupRequestHeader(
+	meta *meta.Meta,
+	_ adaptor.Store,
+	_ *gin.Context,
+	req *http.Request,
+) error {
+	req.Header.Set("Authorization", "Bearer "+meta.Channel.Key)
+	return nil
+}
+
+func (a *Adaptor) ConvertRequest(
+	meta *meta.Meta,
+	_ adaptor.Store,
+	req *http.Request,
+) (adaptor.ConvertResult, error) {
+	switch meta.Mode {
+	case mode.AudioGenerations:
+		return ConvertAudioGenerationRequest(meta, req)
+	case mode.AudioGenerationsGet:
+		return adaptor.ConvertResult{}, nil
+	default:
+		return adaptor.ConvertResult{}, fmt.Errorf("unsupported relay mode %d for suno", meta.Mode)
+	}
+}
+
+func (a *Adaptor) DoRequest(
+	meta *meta.Meta,
+	_ adaptor.Store,
+	_ *gin.Context,
+	req *http.Request,
+) (*http.Response, error) {
+	return utils.DoRequestWithMeta(req, meta)
+}
+
+func (a *Adaptor) DoResponse(
+	meta *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	switch meta.Mode {
+	case mode.AudioGenerations:
+		return SubmitHandler(meta, store, c, resp)
+	case mode.AudioGenerationsGet:
+		return FetchHandler(meta, c, resp)
+	default:
+		return adaptor.DoResponseResult{}, ErrorHandler(resp)
+	}
+}
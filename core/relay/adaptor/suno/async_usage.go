@@ -0,0 +1,130 @@
+package suno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/bytedance/sonic"
+	coremodel "github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/mode"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	relayutils "github.com/labring/aiproxy/core/relay/utils"
+)
+
+var _ adaptor.AsyncUsageFetcher = (*Adaptor)(nil)
+
+func (a *Adaptor) FetchAsyncUsage(
+	ctx context.Context,
+	request adaptor.AsyncUsageRequest,
+) (coremodel.Usage, coremodel.UsageContext, bool, error) {
+	info := request.Info
+	if info == nil {
+		return coremodel.Usage{}, coremodel.UsageContext{}, false, errors.New(
+			"async usage info is nil",
+		)
+	}
+
+	if mode.Mode(info.Mode) != mode.AudioGenerations {
+		return coremodel.Usage{}, coremodel.UsageContext{}, false, fmt.Errorf(
+			"unsupported async usage mode: %d",
+			info.Mode,
+		)
+	}
+
+	data, err := a.fetchTask(ctx, request.Channel, info)
+	if err != nil {
+		return coremodel.Usage{}, coremodel.UsageContext{}, false, err
+	}
+
+	switch generationStatus(data.Status) {
+	case relaymodel.AudioGenerationStatusCompleted:
+		clips := int64(len(data.Data))
+		if clips == 0 {
+			return coremodel.Usage{}, coremodel.UsageContext{}, true, errors.New(
+				"suno task succeeded without clips",
+			)
+		}
+
+		return coremodel.Usage{
+			OutputTokens: coremodel.ZeroNullInt64(clips),
+			TotalTokens:  coremodel.ZeroNullInt64(clips),
+		}, info.UsageContext, true, nil
+	case relaymodel.AudioGenerationStatusFailed:
+		return coremodel.Usage{}, coremodel.UsageContext{}, true, fmt.Errorf(
+			"suno task failed: %s",
+			data.FailReason,
+		)
+	default:
+		return coremodel.Usage{}, coremodel.UsageContext{}, false, nil
+	}
+}
+
+func (a *Adaptor) fetchTask(
+	ctx context.Context,
+	channel *coremodel.Channel,
+	info *coremodel.AsyncUsageInfo,
+) (*relaymodel.SunoTaskData, error) {
+	if info.UpstreamID == "" {
+		return nil, errors.New("upstream id is empty")
+	}
+
+	if channel == nil {
+		return nil, errors.New("channel is nil")
+	}
+
+	baseURL := channel.BaseURL
+	if info.BaseURL != "" {
+		baseURL = info.BaseURL
+	}
+
+	requestURL, err := url.JoinPath(baseURL, "/suno/fetch", info.UpstreamID)
+	if err != nil {
+		return nil, fmt.Errorf("build suno task url: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new suno task request: %w", err)
+	}
+
+	key, err := channel.GetKey()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+key)
+
+	client, err := relayutils.LoadHTTPClientWithTLSConfigE(
+		0,
+		channel.ProxyURL,
+		channel.SkipTLSVerify,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do suno task request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var response relaymodel.SunoFetchResponse
+	if err := sonic.ConfigDefault.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decode suno task: %w", err)
+	}
+
+	if response.Code != relaymodel.SunoResponseCodeSuccess {
+		return nil, fmt.Errorf("suno task error %s: %s", response.Code, response.Message)
+	}
+
+	return &response.Data, nil
+}
@@ -0,0 +1,28 @@
+package suno
+
+import (
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+// Suno usage is reported as generated clips in output tokens,
+// set output_price with output_price_unit 1 to bill per generation.
+// The model name is passed as the suno model version (mv).
+
+var ModelList = []model.ModelConfig{
+	{
+		Model: "chirp-v3-5",
+		Type:  mode.AudioGenerations,
+		Owner: model.ModelOwnerSuno,
+	},
+	{
+		Model: "chirp-v4",
+		Type:  mode.AudioGenerations,
+		Owner: model.ModelOwnerSuno,
+	},
+	{
+		Model: "chirp-auk",
+		Type:  mode.AudioGenerations,
+		Owner: model.ModelOwnerSuno,
+	},
+}
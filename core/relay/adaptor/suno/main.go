@@ -0,0 +1,281 @@
+package suno
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/conv"
+	coremodel "github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+)
+
+const audioGenerationTTL = 7 * 24 * time.Hour
+
+func ConvertAudioGenerationRequest(
+	meta *meta.Meta,
+	req *http.Request,
+) (adaptor.ConvertResult, error) {
+	var request relaymodel.AudioGenerationRequest
+	if err := common.UnmarshalRequestReusable(req, &request); err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	if request.Prompt == "" && request.Lyrics == "" {
+		return adaptor.ConvertResult{}, relaymodel.WrapperOpenAIErrorWithMessage(
+			"prompt or lyrics is required",
+			"invalid_request_error",
+			http.StatusBadRequest,
+		)
+	}
+
+	data, err := sonic.Marshal(convertSubmitRequest(meta.ActualModel, &request))
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	return adaptor.ConvertResult{
+		Header: http.Header{
+			"Content-Type":   {"application/json"},
+			"Content-Length": {strconv.Itoa(len(data))},
+		},
+		Body: bytes.NewReader(data),
+	}, nil
+}
+
+// convertSubmitRequest uses the custom mode when lyrics are provided,
+// otherwise suno writes the lyrics from the description prompt
+func convertSubmitRequest(
+	model string,
+	request *relaymodel.AudioGenerationRequest,
+) relaymodel.SunoSubmitRequest {
+	submit := relaymodel.SunoSubmitRequest{
+		Mv:               model,
+		Title:            request.Title,
+		Tags:             request.Tags,
+		ContinueAt:       request.ContinueAt,
+		ContinueClipID:   request.ContinueClipID,
+		MakeInstrumental: request.Instrumental,
+	}
+
+	if request.Lyrics != "" {
+		submit.Prompt = request.Lyrics
+		if submit.Tags == "" {
+			submit.Tags = request.Prompt
+		}
+	} else {
+		submit.GptDescriptionPrompt = request.Prompt
+	}
+
+	return submit
+}
+
+func SubmitHandler(
+	meta *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if resp.StatusCode != http.StatusOK {
+		return adaptor.DoResponseResult{}, ErrorHandler(resp)
+	}
+
+	defer resp.Body.Close()
+
+	var response relaymodel.SunoSubmitResponse
+	if err := common.UnmarshalResponse(resp, &response); err != nil {
+		return adaptor.DoResponseResult{}, relaymodel.WrapperOpenAIError(
+			err,
+			"unmarshal_response_body_failed",
+			http.StatusInternalServerError,
+		)
+	}
+
+	if response.Code != relaymodel.SunoResponseCodeSuccess || response.Data == "" {
+		return adaptor.DoResponseResult{}, relaymodel.WrapperOpenAIErrorWithMessage(
+			response.Message,
+			response.Code,
+			http.StatusBadRequest,
+		)
+	}
+
+	if store != nil {
+		if err := store.SaveStore(adaptor.StoreCache{
+			ID:        coremodel.AudioGenerationStoreID(response.Data),
+			GroupID:   meta.Group.ID,
+			TokenID:   meta.Token.ID,
+			ChannelID: meta.Channel.ID,
+			Model:     meta.OriginModel,
+			ExpiresAt: time.Now().Add(audioGenerationTTL),
+		}); err != nil {
+			common.GetLogger(c).Errorf("save suno audio generation store failed: %v", err)
+		}
+	}
+
+	return writeAudioGeneration(c, relaymodel.AudioGeneration{
+		ID:        response.Data,
+		Object:    relaymodel.AudioGenerationObject,
+		CreatedAt: time.Now().Unix(),
+		Status:    relaymodel.AudioGenerationStatusQueued,
+		Model:     meta.OriginModel,
+	}, adaptor.DoResponseResult{
+		UpstreamID: response.Data,
+		AsyncUsage: true,
+	})
+}
+
+func FetchHandler(
+	meta *meta.Meta,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if resp.StatusCode != http.StatusOK {
+		return adaptor.DoResponseResult{}, ErrorHandler(resp)
+	}
+
+	defer resp.Body.Close()
+
+	var response relaymodel.SunoFetchResponse
+	if err := common.UnmarshalResponse(resp, &response); err != nil {
+		return adaptor.DoResponseResult{}, relaymodel.WrapperOpenAIError(
+			err,
+			"unmarshal_response_body_failed",
+			http.StatusInternalServerError,
+		)
+	}
+
+	if response.Code != relaymodel.SunoResponseCodeSuccess {
+		return adaptor.DoResponseResult{}, relaymodel.WrapperOpenAIErrorWithMessage(
+			response.Message,
+			response.Code,
+			http.StatusBadRequest,
+		)
+	}
+
+	generation := convertTaskData(&response.Data)
+	generation.Model = meta.OriginModel
+
+	if generation.ID == "" {
+		generation.ID = meta.GenerationID
+	}
+
+	return writeAudioGeneration(c, generation, adaptor.DoResponseResult{
+		UpstreamID: generation.ID,
+	})
+}
+
+func convertTaskData(data *relaymodel.SunoTaskData) relaymodel.AudioGeneration {
+	generation := relaymodel.AudioGeneration{
+		ID:        data.TaskID,
+		Object:    relaymodel.AudioGenerationObject,
+		CreatedAt: data.SubmitTime,
+		Status:    generationStatus(data.Status),
+		Progress:  parseProgress(data.Progress),
+	}
+
+	if generation.Status == relaymodel.AudioGenerationStatusCompleted {
+		generation.Progress = 100
+	}
+
+	if generation.Status == relaymodel.AudioGenerationStatusFailed {
+		generation.Error = &relaymodel.AudioGenerationError{
+			Message: data.FailReason,
+		}
+	}
+
+	for _, clip := range data.Data {
+		generation.Clips = append(generation.Clips, relaymodel.AudioGenerationClip{
+			ID:       clip.ID,
+			Title:    clip.Title,
+			Tags:     clip.Metadata.Tags,
+			Lyrics:   clip.Metadata.Prompt,
+			AudioURL: clip.AudioURL,
+			VideoURL: clip.VideoURL,
+			ImageURL: clip.ImageURL,
+			Duration: clip.Metadata.Duration,
+		})
+	}
+
+	return generation
+}
+
+func generationStatus(status string) relaymodel.AudioGenerationStatus {
+	switch strings.ToUpper(status) {
+	case relaymodel.SunoTaskStatusSuccess:
+		return relaymodel.AudioGenerationStatusCompleted
+	case relaymodel.SunoTaskStatusFailure:
+		return relaymodel.AudioGenerationStatusFailed
+	case relaymodel.SunoTaskStatusInProgress:
+		return relaymodel.AudioGenerationStatusInProgress
+	default:
+		return relaymodel.AudioGenerationStatusQueued
+	}
+}
+
+func parseProgress(progress string) int {
+	value, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(progress), "%"))
+	if err != nil || value < 0 {
+		return 0
+	}
+
+	return min(value, 100)
+}
+
+func writeAudioGeneration(
+	c *gin.Context,
+	generation relaymodel.AudioGeneration,
+	result adaptor.DoResponseResult,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	data, err := sonic.Marshal(generation)
+	if err != nil {
+		return result, relaymodel.WrapperOpenAIError(
+			err,
+			"marshal_response_body_failed",
+			http.StatusInternalServerError,
+		)
+	}
+
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	_, _ = c.Writer.Write(data)
+
+	return result, nil
+}
+
+func ErrorHandler(resp *http.Response) adaptor.Error {
+	defer resp.Body.Close()
+
+	respBody, err := common.GetResponseBody(resp)
+	if err != nil {
+		return relaymodel.WrapperOpenAIErrorWithMessage(
+			err.Error(),
+			relaymodel.ErrorCodeBadResponse,
+			resp.StatusCode,
+			relaymodel.ErrorTypeUpstream,
+		)
+	}
+
+	var response relaymodel.SunoSubmitResponse
+	if err := sonic.Unmarshal(respBody, &response); err != nil || response.Message == "" {
+		return relaymodel.WrapperOpenAIErrorWithMessage(
+			conv.BytesToString(respBody),
+			relaymodel.ErrorCodeBadResponse,
+			resp.StatusCode,
+			relaymodel.ErrorTypeUpstream,
+		)
+	}
+
+	return relaymodel.WrapperOpenAIErrorWithMessage(
+		response.Message,
+		response.Code,
+		resp.StatusCode,
+		relaymodel.ErrorTypeUpstream,
+	)
+}
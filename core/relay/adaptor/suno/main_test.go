@@ -0,0 +1,79 @@
+//nolint:testpackage
+package suno
+
+import (
+	"testing"
+
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+)
+
+func TestConvertSubmitRequest(t *testing.T) {
+	t.Parallel()
+
+	description := convertSubmitRequest("chirp-v4", &relaymodel.AudioGenerationRequest{
+		Prompt:       "an upbeat song about the sea",
+		Instrumental: true,
+	})
+	if description.Mv != "chirp-v4" ||
+		description.GptDescriptionPrompt != "an upbeat song about the sea" ||
+		description.Prompt != "" ||
+		!description.MakeInstrumental {
+		t.Fatalf("unexpected description request: %#v", description)
+	}
+
+	custom := convertSubmitRequest("chirp-v4", &relaymodel.AudioGenerationRequest{
+		Prompt: "synthwave",
+		Lyrics: "[Verse]\nhello",
+		Title:  "Hello",
+	})
+	if custom.Prompt != "[Verse]\nhello" ||
+		custom.Tags != "synthwave" ||
+		custom.GptDescriptionPrompt != "" ||
+		custom.Title != "Hello" {
+		t.Fatalf("unexpected custom request: %#v", custom)
+	}
+}
+
+func TestConvertTaskData(t *testing.T) {
+	t.Parallel()
+
+	generation := convertTaskData(&relaymodel.SunoTaskData{
+		TaskID:   "task-1",
+		Status:   relaymodel.SunoTaskStatusInProgress,
+		Progress: "42%",
+	})
+	if generation.Status != relaymodel.AudioGenerationStatusInProgress ||
+		generation.Progress != 42 {
+		t.Fatalf("unexpected in progress generation: %#v", generation)
+	}
+
+	generation = convertTaskData(&relaymodel.SunoTaskData{
+		TaskID: "task-1",
+		Status: relaymodel.SunoTaskStatusSuccess,
+		Data: []relaymodel.SunoClip{
+			{
+				ID:       "clip-1",
+				AudioURL: "https://example.com/1.mp3",
+				Metadata: relaymodel.SunoClipMetadata{Duration: 120.5},
+			},
+			{ID: "clip-2", AudioURL: "https://example.com/2.mp3"},
+		},
+	})
+	if generation.Status != relaymodel.AudioGenerationStatusCompleted ||
+		generation.Progress != 100 ||
+		len(generation.Clips) != 2 ||
+		generation.Clips[0].Duration != 120.5 {
+		t.Fatalf("unexpected completed generation: %#v", generation)
+	}
+
+	generation = convertTaskData(&relaymodel.SunoTaskData{
+		TaskID:     "task-1",
+		Status:     relaymodel.SunoTaskStatusFailure,
+		FailReason: "content policy",
+	})
+	if generation.Status != relaymodel.AudioGenerationStatusFailed ||
+		generation.Error == nil ||
+		generation.Error.Message != "content policy" {
+		t.Fatalf("unexpected failed generation: %#v", generation)
+	}
+}
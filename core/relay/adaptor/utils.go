@@ -33,6 +33,29 @@ func NewError[T any](statusCode int, err T) Error {
 	}
 }
 
+// IsBilledOnReceiptMode reports the generation modes billed by most providers
+// once the request is received, the generation goes on and is billed even when
+// the response times out or the connection is lost
+func IsBilledOnReceiptMode(m mode.Mode) bool {
+	switch m {
+	case mode.ImagesGenerations,
+		mode.ImagesEdits,
+		mode.VideoGenerationsJobs,
+		mode.Videos,
+		mode.VideosRemix,
+		mode.VideosEdits,
+		mode.VideosExtensions,
+		mode.GeminiVideo,
+		mode.GeminiImage,
+		mode.AliVideo,
+		mode.DoubaoVideo,
+		mode.AudioGenerations:
+		return true
+	default:
+		return false
+	}
+}
+
 func IsSuccessfulResponseStatus(m mode.Mode, statusCode int) bool {
 	switch m {
 	case mode.Responses, mode.Videos, mode.VideosRemix, mode.VideosEdits, mode.VideosExtensions:
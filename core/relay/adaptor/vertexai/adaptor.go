@@ -31,6 +31,13 @@ func (a *Adaptor) DefaultBaseURL() string {
 	return ""
 }
 
+// NativeMode reports the claude messages of the claude models are relayed to
+// vertex ai as they are
+func (a *Adaptor) NativeMode(mt *meta.Meta) bool {
+	return adaptor.ModeFromMeta(mt) == mode.Anthropic &&
+		strings.Contains(strings.ToLower(resolveFeatureModel(mt)), "claude")
+}
+
 func (a *Adaptor) SupportMode(mt *meta.Meta) bool {
 	m := adaptor.ModeFromMeta(mt)
 
@@ -46,6 +53,7 @@ func (a *Adaptor) SupportMode(mt *meta.Meta) bool {
 		m == mode.Anthropic ||
 		m == mode.Gemini ||
 		m == mode.GeminiFiles ||
+		m == mode.GeminiCachedContents ||
 		m == mode.GeminiVideo ||
 		m == mode.GeminiVideoOperations ||
 		m == mode.GeminiTTS ||
@@ -92,6 +100,7 @@ func innerAdaptor(meta *meta.Meta) innerAIAdapter {
 	switch meta.Mode {
 	case mode.GeminiVideo,
 		mode.GeminiFiles,
+		mode.GeminiCachedContents,
 		mode.GeminiVideoOperations,
 		mode.GeminiTTS,
 		mode.GeminiImage,
@@ -114,6 +123,24 @@ func (a *Adaptor) ConvertRequest(
 	store adaptor.Store,
 	request *http.Request,
 ) (adaptor.ConvertResult, error) {
+	if meta.Mode == mode.GeminiCachedContents {
+		config, err := getConfigFromKey(meta.Channel.Key)
+		if err != nil {
+			return adaptor.ConvertResult{}, err
+		}
+
+		modelResource, err := gemini.VertexCachedContentModelResource(
+			config.ProjectID,
+			config.Region,
+			meta.ActualModel,
+		)
+		if err != nil {
+			return adaptor.ConvertResult{}, err
+		}
+
+		return gemini.ConvertCachedContentRequest(meta, request, modelResource)
+	}
+
 	aa := innerAdaptor(meta)
 	if aa == nil {
 		return adaptor.ConvertResult{}, errors.New("adaptor not found")
@@ -162,6 +189,10 @@ func (a *Adaptor) GetRequestURL(
 		return (&gemini.Adaptor{}).GetRequestURL(meta, store, c)
 	}
 
+	if meta.Mode == mode.GeminiCachedContents {
+		return a.getCachedContentRequestURL(meta, config)
+	}
+
 	featureModel := resolveFeatureModel(meta)
 
 	publisher := "google"
@@ -272,6 +303,37 @@ func (a *Adaptor) getOperationRequestURL(
 	}, nil
 }
 
+func (a *Adaptor) getCachedContentRequestURL(
+	meta *meta.Meta,
+	config Config,
+) (adaptor.RequestURL, error) {
+	if config.ProjectID == "" || config.Region == "" {
+		return adaptor.RequestURL{}, errors.New(
+			"cached content requires the project id and the region of the channel key",
+		)
+	}
+
+	u := meta.Channel.BaseURL
+	if u == "" {
+		requestDomain := "aiplatform.googleapis.com"
+		if config.Region != "global" {
+			requestDomain = config.Region + "-aiplatform.googleapis.com"
+		}
+
+		u = "https://" + requestDomain
+	}
+
+	return adaptor.RequestURL{
+		Method: http.MethodPost,
+		URL: fmt.Sprintf(
+			"%s/v1/projects/%s/locations/%s/cachedContents",
+			u,
+			config.ProjectID,
+			config.Region,
+		),
+	}, nil
+}
+
 func vertexModelScopedOperationName(operationName string) string {
 	operationName = strings.TrimPrefix(operationName, "/")
 	if !strings.HasPrefix(operationName, "models/") {
@@ -485,7 +547,12 @@ func (a *Adaptor) FetchAsyncUsage(
 		requestMeta.Channel.BaseURL = info.BaseURL
 	}
 
-	config, err := getConfigFromKey(channel.Key)
+	key, err := channel.GetKey()
+	if err != nil {
+		return model.Usage{}, model.UsageContext{}, false, err
+	}
+
+	config, err := getConfigFromKey(key)
 	if err != nil {
 		return model.Usage{}, model.UsageContext{}, false, err
 	}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -81,20 +82,23 @@ func (a *Adaptor) SetupRequestHeader(
 	c *gin.Context,
 	req *http.Request,
 ) error {
-	betas := c.Request.Header.Get(anthropic.AnthropicBeta)
-	if betas != "" {
-		req.Header.Set(
-			anthropic.AnthropicBeta,
-			strings.Join(
-				fixBetas(
-					anthropic.ResolveModelName(meta.OriginModel, meta.ActualModel),
-					strings.Split(betas, ","),
-				),
-				",",
-			),
+	var fixed []string
+	if betas := c.Request.Header.Get(anthropic.AnthropicBeta); betas != "" {
+		fixed = fixBetas(
+			anthropic.ResolveModelName(meta.OriginModel, meta.ActualModel),
+			strings.Split(betas, ","),
 		)
 	}
 
+	if beta := anthropic.LongOutputBetaFromMeta(meta); beta != "" &&
+		!slices.Contains(fixed, beta) {
+		fixed = append(fixed, beta)
+	}
+
+	if len(fixed) > 0 {
+		req.Header.Set(anthropic.AnthropicBeta, strings.Join(fixed, ","))
+	}
+
 	return nil
 }
 
@@ -147,6 +147,8 @@ func (a *Adaptor) DoResponse(
 		return gemini.NativeVideoOperationHandler(meta, store, c, resp)
 	case mode.GeminiFiles:
 		return gemini.GeminiFileHandler(meta, c, resp)
+	case mode.GeminiCachedContents:
+		return gemini.CachedContentHandler(meta, store, c, resp)
 	case mode.VideoGenerationsJobs:
 		return gemini.VideoGenerationJobSubmitHandler(meta, store, c, resp)
 	case mode.Videos:
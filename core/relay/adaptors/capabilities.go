@@ -0,0 +1,217 @@
+package adaptors
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
+	"github.com/labring/aiproxy/core/relay/adaptor/registry"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+)
+
+const (
+	ProtocolOpenAI = "openai"
+	ProtocolClaude = "claude"
+	ProtocolGemini = "gemini"
+)
+
+const (
+	FeatureBalance     = "balance"
+	FeatureModelList   = "model_list"
+	FeatureReadiness   = "readiness_probe"
+	FeatureKeyValidate = "key_validation"
+	FeatureAsyncUsage  = "async_usage"
+	FeatureRetrySafety = "retry_safety"
+)
+
+// AdaptorCapabilities is a row of the capability matrix, generated from the
+// adaptor and its metadata
+type AdaptorCapabilities struct {
+	Type           model.ChannelType `json:"type"`
+	Name           string            `json:"name"`
+	DefaultBaseURL string            `json:"default_base_url"`
+	// Modes are the modes supported by the adaptor with the default channel
+	// config, the support of some modes also depends on the model
+	Modes []string `json:"modes"`
+	// Protocols are the client protocols accepted by the adaptor
+	Protocols []string `json:"protocols"`
+	// NativeProtocols are the claude or gemini protocols relayed as is
+	// instead of converting them to openai and back
+	NativeProtocols []string `json:"native_protocols"`
+	ConfigKeys      []string `json:"config_keys"`
+	Features        []string `json:"features"`
+	Limitations     []string `json:"limitations"`
+	BuiltinModels   int      `json:"builtin_models"`
+}
+
+// supportMode probes the adaptor like the channel selection, the chat
+// completions, completions and responses are converted for the adaptors not
+// supporting them natively
+func supportMode(a adaptor.Adaptor, m mode.Mode) bool {
+	switch m {
+	case mode.ChatCompletions:
+		a = openai.NewChatTemplateAdaptor(a)
+	case mode.Completions:
+		a = openai.NewCompletionsToChatAdaptor(a)
+	case mode.Responses:
+		a = openai.NewResponsesToChatAdaptor(a)
+	}
+
+	return probe(func() bool {
+		return a.SupportMode(&meta.Meta{Mode: m})
+	})
+}
+
+func nativeMode(a adaptor.Adaptor, m mode.Mode) bool {
+	nativeAdaptor, ok := a.(adaptor.NativeModeAdaptor)
+	if !ok {
+		return false
+	}
+
+	return probe(func() bool {
+		return nativeAdaptor.NativeMode(&meta.Meta{Mode: m})
+	})
+}
+
+// probe reports false when an adaptor reading the channel or the model of the
+// meta panics without them
+func probe(f func() bool) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+
+	return f()
+}
+
+// modeProtocol returns the client protocol of the mode, empty for the
+// provider native video apis
+func modeProtocol(name string) string {
+	switch {
+	case name == mode.Anthropic.String():
+		return ProtocolClaude
+	case strings.HasPrefix(name, "Gemini"):
+		return ProtocolGemini
+	case strings.HasPrefix(name, "Ali"), strings.HasPrefix(name, "Doubao"):
+		return ""
+	default:
+		return ProtocolOpenAI
+	}
+}
+
+func configKeys(schema map[string]any) []string {
+	properties, ok := schema["properties"].(map[string]any)
+	if !ok {
+		return []string{}
+	}
+
+	return slices.Sorted(maps.Keys(properties))
+}
+
+func features(a adaptor.Adaptor) []string {
+	features := []string{}
+
+	if _, ok := a.(adaptor.Balancer); ok {
+		features = append(features, FeatureBalance)
+	}
+
+	if _, ok := a.(adaptor.ModelLister); ok {
+		features = append(features, FeatureModelList)
+	}
+
+	if _, ok := a.(adaptor.ReadinessProber); ok {
+		features = append(features, FeatureReadiness)
+	}
+
+	if _, ok := a.(adaptor.KeyValidator); ok {
+		features = append(features, FeatureKeyValidate)
+	}
+
+	if _, ok := a.(adaptor.AsyncUsageFetcher); ok {
+		features = append(features, FeatureAsyncUsage)
+	}
+
+	if _, ok := a.(adaptor.RetrySafety); ok {
+		features = append(features, FeatureRetrySafety)
+	}
+
+	return features
+}
+
+func GetAdaptorCapabilities(channelType model.ChannelType, a adaptor.Adaptor) AdaptorCapabilities {
+	metadata := a.Metadata()
+
+	capabilities := AdaptorCapabilities{
+		Type:            channelType,
+		Name:            channelType.String(),
+		DefaultBaseURL:  a.DefaultBaseURL(),
+		Modes:           []string{},
+		Protocols:       []string{},
+		NativeProtocols: []string{},
+		ConfigKeys:      configKeys(metadata.ConfigSchema),
+		Features:        features(a),
+		Limitations:     slices.Clone(metadata.Limitations),
+		BuiltinModels:   len(metadata.Models),
+	}
+
+	if capabilities.Limitations == nil {
+		capabilities.Limitations = []string{}
+	}
+
+	for _, m := range mode.All() {
+		if supportMode(a, m) {
+			capabilities.Modes = append(capabilities.Modes, m.String())
+		}
+	}
+
+	if slices.ContainsFunc(capabilities.Modes, func(name string) bool {
+		return modeProtocol(name) == ProtocolOpenAI
+	}) {
+		capabilities.Protocols = append(capabilities.Protocols, ProtocolOpenAI)
+	}
+
+	if slices.ContainsFunc(capabilities.Modes, func(name string) bool {
+		return modeProtocol(name) == ProtocolClaude
+	}) {
+		capabilities.Protocols = append(capabilities.Protocols, ProtocolClaude)
+	}
+
+	if slices.ContainsFunc(capabilities.Modes, func(name string) bool {
+		return modeProtocol(name) == ProtocolGemini
+	}) {
+		capabilities.Protocols = append(capabilities.Protocols, ProtocolGemini)
+	}
+
+	if nativeMode(a, mode.Anthropic) {
+		capabilities.NativeProtocols = append(capabilities.NativeProtocols, ProtocolClaude)
+	}
+
+	if nativeMode(a, mode.Gemini) {
+		capabilities.NativeProtocols = append(capabilities.NativeProtocols, ProtocolGemini)
+	}
+
+	return capabilities
+}
+
+// GetCapabilityMatrix returns the capabilities of all the adaptors ordered by
+// the channel type
+func GetCapabilityMatrix() []AdaptorCapabilities {
+	types := registry.SortedTypes()
+
+	matrix := make([]AdaptorCapabilities, 0, len(types))
+	for _, channelType := range types {
+		a, ok := registry.Get(channelType)
+		if !ok {
+			continue
+		}
+
+		matrix = append(matrix, GetAdaptorCapabilities(channelType, a))
+	}
+
+	return matrix
+}
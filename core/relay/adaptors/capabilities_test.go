@@ -0,0 +1,50 @@
+//nolint:testpackage
+package adaptors
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCapabilityMatrix(t *testing.T) {
+	matrix := GetCapabilityMatrix()
+	require.Len(t, matrix, len(ChannelAdaptor))
+
+	byType := make(map[model.ChannelType]AdaptorCapabilities, len(matrix))
+	for i, capabilities := range matrix {
+		if i > 0 {
+			assert.Less(t, matrix[i-1].Type, capabilities.Type)
+		}
+
+		byType[capabilities.Type] = capabilities
+	}
+
+	openAI := byType[model.ChannelTypeOpenAI]
+	assert.Contains(t, openAI.Modes, mode.ChatCompletions.String())
+	assert.Contains(t, openAI.Modes, mode.Embeddings.String())
+	assert.Contains(t, openAI.Protocols, ProtocolOpenAI)
+
+	anthropic := byType[model.ChannelTypeAnthropic]
+	assert.Contains(t, anthropic.Modes, mode.Anthropic.String())
+	assert.Contains(t, anthropic.Protocols, ProtocolClaude)
+	assert.Contains(t, anthropic.NativeProtocols, ProtocolClaude)
+	assert.NotEmpty(t, anthropic.Limitations)
+
+	nim := byType[model.ChannelTypeNvidiaNIM]
+	assert.Contains(t, nim.Features, FeatureReadiness)
+	assert.Contains(t, nim.ConfigKeys, "health_url")
+	assert.Equal(t, "https://integrate.api.nvidia.com/v1", nim.DefaultBaseURL)
+}
+
+func TestModeProtocol(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, ProtocolOpenAI, modeProtocol(mode.Embeddings.String()))
+	assert.Equal(t, ProtocolClaude, modeProtocol(mode.Anthropic.String()))
+	assert.Equal(t, ProtocolGemini, modeProtocol(mode.GeminiTTS.String()))
+	assert.Empty(t, modeProtocol(mode.DoubaoVideoTasks.String()))
+}
@@ -0,0 +1,49 @@
+//nolint:testpackage
+package adaptors
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor/adaptortest"
+)
+
+// TestCassettes replays the recorded provider responses of testdata/cassettes
+// through the adaptors, run with `make test-adaptors`
+func TestCassettes(t *testing.T) {
+	cassettes, err := adaptortest.Load("testdata/cassettes")
+	if err != nil {
+		t.Fatalf("load cassettes: %v", err)
+	}
+
+	if len(cassettes) == 0 {
+		t.Fatal("no cassettes found")
+	}
+
+	covered := make(map[model.ChannelType]struct{})
+
+	for _, cassette := range cassettes {
+		t.Run(cassette.TestName(), func(t *testing.T) {
+			a, ok := GetAdaptor(cassette.ChannelType)
+			if !ok {
+				t.Fatalf("no adaptor for channel type %d", cassette.ChannelType)
+			}
+
+			adaptortest.Run(t, a, cassette)
+		})
+
+		covered[cassette.ChannelType] = struct{}{}
+	}
+
+	var missing []string
+
+	for channelType := range ChannelAdaptor {
+		if _, ok := covered[channelType]; !ok {
+			missing = append(missing, channelType.String())
+		}
+	}
+
+	slices.Sort(missing)
+	t.Logf("adaptors without cassettes: %v", missing)
+}
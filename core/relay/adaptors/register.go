@@ -25,12 +25,15 @@ import (
 	_ "github.com/labring/aiproxy/core/relay/adaptor/gemini"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/geminiopenai"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/groq"
+	_ "github.com/labring/aiproxy/core/relay/adaptor/huggingface"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/jina"
+	_ "github.com/labring/aiproxy/core/relay/adaptor/kling"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/lingyiwanwu"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/minimax"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/mistral"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/moonshot"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/novita"
+	_ "github.com/labring/aiproxy/core/relay/adaptor/nvidianim"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/ollama"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/openai"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/openrouter"
@@ -40,6 +43,7 @@ import (
 	_ "github.com/labring/aiproxy/core/relay/adaptor/siliconflow"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/stepfun"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/streamlake"
+	_ "github.com/labring/aiproxy/core/relay/adaptor/suno"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/tencent"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/text-embeddings-inference"
 	_ "github.com/labring/aiproxy/core/relay/adaptor/vertexai"
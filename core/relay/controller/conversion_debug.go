@@ -0,0 +1,256 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	log "github.com/sirupsen/logrus"
+)
+
+// conversionDebugMaxBodySize caps each of the logged bodies
+const conversionDebugMaxBodySize = 64 * 1024
+
+var (
+	conversionDebugLogger     *log.Logger
+	conversionDebugLoggerOnce sync.Once
+	conversionDebugLimiter    minuteLimiter
+)
+
+// getConversionDebugLogger returns the logger of the conversion debug entries,
+// they are kept apart from the service logs, nil when no log file is set
+func getConversionDebugLogger() *log.Logger {
+	conversionDebugLoggerOnce.Do(func() {
+		path := config.ConversionDebugLogFile
+		if path == "" {
+			return
+		}
+
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			log.Errorf("open conversion debug log file %s failed: %v", path, err)
+			return
+		}
+
+		conversionDebugLogger = newConversionDebugLogger(f)
+	})
+
+	return conversionDebugLogger
+}
+
+func newConversionDebugLogger(w io.Writer) *log.Logger {
+	l := log.New()
+	l.SetFormatter(&log.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	l.SetOutput(w)
+
+	return l
+}
+
+// minuteLimiter allows up to a number of events in each minute
+type minuteLimiter struct {
+	mu     sync.Mutex
+	minute int64
+	count  int64
+}
+
+// allow reports whether the event is within the limit, a limit not above
+// zero allows all the events
+func (l *minuteLimiter) allow(now time.Time, limit int64) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	minute := now.Unix() / 60
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.minute != minute {
+		l.minute = minute
+		l.count = 0
+	}
+
+	if l.count >= limit {
+		return false
+	}
+
+	l.count++
+
+	return true
+}
+
+// shouldDebugConversion samples the requests by the sample rate, the requests
+// of the tokens debugging the conversion are always taken, both are throttled
+func shouldDebugConversion(meta *meta.Meta) bool {
+	if !meta.Token.DebugConversion {
+		rate := config.GetConversionDebugSampleRate()
+		if rate <= 0 || rand.Float64() >= rate {
+			return false
+		}
+	}
+
+	return conversionDebugLimiter.allow(time.Now(), config.GetConversionDebugMaxPerMinute())
+}
+
+// debugConversion logs the request body before and after the conversion with
+// the credentials redacted, the converted body is buffered so it is still sent
+func debugConversion(
+	c *gin.Context,
+	meta *meta.Meta,
+	convertResult adaptor.ConvertResult,
+) (adaptor.ConvertResult, error) {
+	logger := getConversionDebugLogger()
+	if logger == nil || !shouldDebugConversion(meta) {
+		return convertResult, nil
+	}
+
+	var converted []byte
+
+	if convertResult.Body != nil {
+		body, err := io.ReadAll(convertResult.Body)
+		closeRequestReader(convertResult.Body)
+
+		if err != nil {
+			return adaptor.ConvertResult{}, err
+		}
+
+		converted = body
+		convertResult.Body = bytes.NewReader(body)
+	}
+
+	var original []byte
+	if c.Request != nil {
+		body, err := common.GetRequestBodyReusable(c.Request)
+		if err != nil {
+			common.GetLogger(c).Warnf("get request body for conversion debug failed: %v", err)
+		}
+
+		original = body
+	}
+
+	if !config.ConversionDebugLogContent {
+		original = redactConversionDebugContent(original)
+		converted = redactConversionDebugContent(converted)
+	}
+
+	redactor := newErrorRedactor(meta)
+
+	logger.WithFields(log.Fields{
+		"request_id":     meta.RequestID,
+		"group":          meta.Group.ID,
+		"token_name":     meta.Token.Name,
+		"mode":           meta.Mode.String(),
+		"origin_model":   meta.OriginModel,
+		"actual_model":   meta.ActualModel,
+		"channel_id":     meta.Channel.ID,
+		"channel_type":   int(meta.Channel.Type),
+		"content_type":   convertResult.Header.Get("Content-Type"),
+		"original_body":  redactor.redact(conversionDebugBody(original)),
+		"converted_body": redactor.redact(conversionDebugBody(converted)),
+	}).Info("conversion debug")
+
+	return convertResult, nil
+}
+
+// conversionDebugBody returns the body as text capped to the max size, the
+// binary bodies, e.g. the multipart uploads, are replaced by their size
+func conversionDebugBody(body []byte) string {
+	if !utf8.Valid(body) {
+		return "<binary " + strconv.Itoa(len(body)) + " bytes>"
+	}
+
+	if len(body) <= conversionDebugMaxBodySize {
+		return string(body)
+	}
+
+	return limitBodyDetailString(string(body[:conversionDebugMaxBodySize])) +
+		"...<truncated " + strconv.Itoa(len(body)) + " bytes>"
+}
+
+// conversionDebugStructureKeys are the keys whose string values describe the
+// structure of the body rather than the content of the messages
+var conversionDebugStructureKeys = map[string]struct{}{
+	"model":              {},
+	"role":               {},
+	"type":               {},
+	"name":               {},
+	"id":                 {},
+	"tool_call_id":       {},
+	"tool_use_id":        {},
+	"tool_choice":        {},
+	"finish_reason":      {},
+	"stop_reason":        {},
+	"media_type":         {},
+	"mime_type":          {},
+	"mimeType":           {},
+	"detail":             {},
+	"format":             {},
+	"reasoning_effort":   {},
+	"effort":             {},
+	"service_tier":       {},
+	"encoding_format":    {},
+	"response_format":    {},
+	"response_mime_type": {},
+	"modalities":         {},
+	"voice":              {},
+	"quality":            {},
+	"size":               {},
+}
+
+// redactConversionDebugContent replaces the string values of the json body by
+// their size, except the values of the structure keys, the bodies which are not
+// json are replaced as a whole
+func redactConversionDebugContent(body []byte) []byte {
+	if len(body) == 0 || !utf8.Valid(body) {
+		return body
+	}
+
+	var v any
+	if err := sonic.Unmarshal(body, &v); err != nil {
+		return []byte("<redacted " + strconv.Itoa(len(body)) + " bytes>")
+	}
+
+	redacted, err := sonic.ConfigStd.Marshal(redactConversionDebugValue(v, false))
+	if err != nil {
+		return []byte("<redacted " + strconv.Itoa(len(body)) + " bytes>")
+	}
+
+	return redacted
+}
+
+func redactConversionDebugValue(v any, keep bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			_, keep := conversionDebugStructureKeys[key]
+			v[key] = redactConversionDebugValue(value, keep)
+		}
+
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = redactConversionDebugValue(value, keep)
+		}
+
+		return v
+	case string:
+		if keep {
+			return v
+		}
+
+		return "<redacted " + strconv.Itoa(len(v)) + " bytes>"
+	default:
+		return v
+	}
+}
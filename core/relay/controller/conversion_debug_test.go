@@ -0,0 +1,140 @@
+//nolint:testpackage
+package controller
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinuteLimiter(t *testing.T) {
+	var l minuteLimiter
+
+	now := time.Date(2025, 1, 1, 0, 0, 10, 0, time.UTC)
+
+	assert.True(t, l.allow(now, 2))
+	assert.True(t, l.allow(now, 2))
+	assert.False(t, l.allow(now.Add(40*time.Second), 2))
+	assert.True(t, l.allow(now.Add(time.Minute), 2))
+	assert.True(t, l.allow(now, 0))
+}
+
+func TestConversionDebugBody(t *testing.T) {
+	assert.Equal(t, `{"a":1}`, conversionDebugBody([]byte(`{"a":1}`)))
+	assert.Equal(t, "<binary 2 bytes>", conversionDebugBody([]byte{0xff, 0xfe}))
+
+	long := conversionDebugBody([]byte(strings.Repeat("a", conversionDebugMaxBodySize+1)))
+	assert.True(t, strings.HasSuffix(long, "...<truncated 65537 bytes>"))
+}
+
+// withConversionDebugLogger replaces the log file of the conversion debug by
+// the buffer during the test
+func withConversionDebugLogger(t *testing.T, buf *bytes.Buffer, logContent bool) {
+	t.Helper()
+
+	getConversionDebugLogger()
+
+	logger, content := conversionDebugLogger, config.ConversionDebugLogContent
+	conversionDebugLogger = newConversionDebugLogger(buf)
+	config.ConversionDebugLogContent = logContent
+
+	t.Cleanup(func() {
+		conversionDebugLogger = logger
+		config.ConversionDebugLogContent = content
+	})
+}
+
+func TestDebugConversionDisabledWithoutLogFile(t *testing.T) {
+	getConversionDebugLogger()
+	require.Nil(t, conversionDebugLogger)
+
+	m := newRedactTestMeta()
+	m.Token.DebugConversion = true
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	body := strings.NewReader(`{"model":"gpt"}`)
+
+	result, err := debugConversion(c, m, adaptor.ConvertResult{Body: body})
+	require.NoError(t, err)
+	// the body is not buffered when nothing is logged
+	assert.Same(t, body, result.Body)
+}
+
+func TestDebugConversion(t *testing.T) {
+	var buf bytes.Buffer
+
+	withConversionDebugLogger(t, &buf, true)
+
+	m := newRedactTestMeta()
+	m.RequestID = "req-1"
+	m.Token.DebugConversion = true
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(
+		http.MethodPost,
+		"/v1/chat/completions",
+		strings.NewReader(`{"model":"gpt","messages":[]}`),
+	)
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	result, err := debugConversion(c, m, adaptor.ConvertResult{
+		Header: http.Header{"Content-Type": {"application/json"}},
+		Body:   strings.NewReader(`{"model":"gpt","key":"sk-secret-abcdef"}`),
+	})
+	require.NoError(t, err)
+
+	// the converted body is still sent
+	sent, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"model":"gpt","key":"sk-secret-abcdef"}`, string(sent))
+
+	var entry map[string]any
+	require.NoError(t, sonic.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.JSONEq(t, `{"model":"gpt","messages":[]}`, entry["original_body"].(string))
+	assert.Equal(t, `{"model":"gpt","key":"[REDACTED]"}`, entry["converted_body"])
+}
+
+func TestDebugConversionRedactsContent(t *testing.T) {
+	var buf bytes.Buffer
+
+	withConversionDebugLogger(t, &buf, false)
+
+	m := newRedactTestMeta()
+	m.Token.DebugConversion = true
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(
+		http.MethodPost,
+		"/v1/chat/completions",
+		strings.NewReader(
+			`{"model":"gpt","messages":[{"role":"user","content":"my secret plan"}],"stop":["END"]}`,
+		),
+	)
+
+	_, err := debugConversion(c, m, adaptor.ConvertResult{
+		Body: strings.NewReader(`prompt=my+secret+plan`),
+	})
+	require.NoError(t, err)
+
+	var entry map[string]any
+	require.NoError(t, sonic.Unmarshal(buf.Bytes(), &entry))
+	assert.JSONEq(
+		t,
+		`{"model":"gpt","messages":[{"role":"user","content":"<redacted 14 bytes>"}],"stop":["<redacted 3 bytes>"]}`,
+		entry["original_body"].(string),
+	)
+	assert.Equal(t, "<redacted 21 bytes>", entry["converted_body"])
+	assert.NotContains(t, buf.String(), "secret")
+}
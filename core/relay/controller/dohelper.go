@@ -8,17 +8,22 @@ import (
 	"io"
 	"maps"
 	"net/http"
+	"net/http/httptrace"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/config"
 	"github.com/labring/aiproxy/core/common/conv"
 	"github.com/labring/aiproxy/core/relay/adaptor"
 	"github.com/labring/aiproxy/core/relay/meta"
 	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	"github.com/labring/aiproxy/core/relay/utils"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -32,13 +37,21 @@ type responseWriter struct {
 	body        *bytes.Buffer
 	bodyLimit   int
 	firstByteAt time.Time
+	lastByteAt  time.Time
+	// chunks counts the flushes with data written since the previous one
+	chunks     int64
+	unflushed  bool
+	transcript *streamTranscript
 }
 
 func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.lastByteAt = time.Now()
 	if rw.firstByteAt.IsZero() {
-		rw.firstByteAt = time.Now()
+		rw.firstByteAt = rw.lastByteAt
 	}
 
+	rw.unflushed = true
+
 	if rw.body != nil && rw.bodyLimit > rw.body.Len() {
 		remain := min(rw.bodyLimit-rw.body.Len(), len(b))
 
@@ -47,6 +60,10 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 		}
 	}
 
+	if rw.transcript != nil {
+		rw.transcript.write(rw.Header(), b)
+	}
+
 	return rw.ResponseWriter.Write(b)
 }
 
@@ -54,6 +71,15 @@ func (rw *responseWriter) WriteString(s string) (int, error) {
 	return rw.Write(conv.StringToBytes(s))
 }
 
+func (rw *responseWriter) Flush() {
+	if rw.unflushed {
+		rw.chunks++
+		rw.unflushed = false
+	}
+
+	rw.ResponseWriter.Flush()
+}
+
 var bufferPool = sync.Pool{
 	New: func() any {
 		return bytes.NewBuffer(make([]byte, 0, maxBufferSize))
@@ -82,7 +108,13 @@ func putBuffer(buf *bytes.Buffer) {
 type BodyDetail struct {
 	RequestBody  string
 	ResponseBody string
-	FirstByteAt  time.Time
+	// StreamTranscript is the reassembled text of the streamed response
+	StreamTranscript          string
+	StreamTranscriptTruncated bool
+	FirstByteAt               time.Time
+	// UpstreamError is the raw upstream error, only recorded for the tokens
+	// debugging the upstream errors
+	UpstreamError *UpstreamError
 }
 
 type BodyDetailOption struct {
@@ -90,6 +122,9 @@ type BodyDetailOption struct {
 	IncludeResponseBody bool
 	MaxRequestBodySize  int64
 	MaxResponseBodySize int64
+	// MaxStreamTranscriptSize is the max size of the reassembled text of the
+	// streamed response, 0 disables the transcript
+	MaxStreamTranscriptSize int64
 }
 
 func DoHelper(
@@ -112,8 +147,39 @@ func DoHelper(
 		detail.RequestBody = requestBody
 	}
 
-	// donot use c.Request.Context() because it will be canceled by the client
-	ctx := context.Background()
+	// donot use c.Request.Context() because it will be canceled by the client,
+	// only a streamed response is canceled after the client disconnected so
+	// the handlers finalize the usage streamed so far, a non-stream request
+	// keeps running so the upstream usage of its response is billed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var clientAborted, streaming atomic.Bool
+	if c.Request != nil {
+		reqCtx := c.Request.Context()
+		stop := context.AfterFunc(reqCtx, func() {
+			// the loser of a hedged request is canceled at once, its usage is
+			// not billed
+			if errors.Is(context.Cause(reqCtx), adaptor.ErrHedgeLost) {
+				cancel()
+				return
+			}
+
+			clientAborted.Store(true)
+
+			if streaming.Load() {
+				cancelUpstreamAfterGrace(cancel)
+			}
+		})
+		defer stop()
+	}
+
+	defer func() {
+		if clientAborted.Load() {
+			meta.ClientAborted = true
+			common.GetLogger(c).Data["client_aborted"] = true
+		}
+	}()
 
 	resp, err := prepareAndDoRequest(ctx, a, c, meta, store)
 	if err != nil {
@@ -136,6 +202,14 @@ func DoHelper(
 		defer resp.Body.Close()
 	}
 
+	if utils.IsStreamResponse(resp) {
+		streaming.Store(true)
+
+		if clientAborted.Load() {
+			cancelUpstreamAfterGrace(cancel)
+		}
+	}
+
 	result, relayErr := handleResponse(a, c, meta, store, resp, &detail, detailOption)
 	if relayErr != nil {
 		return adaptor.DoResponseResult{}, &detail, relayErr
@@ -148,14 +222,43 @@ func DoHelper(
 		log.Data["upstream_id"] = result.UpstreamID
 	}
 
+	if result.SystemFingerprint != "" {
+		log.Data["system_fingerprint"] = result.SystemFingerprint
+	}
+
 	if !detail.FirstByteAt.IsZero() {
 		ttfb := detail.FirstByteAt.Sub(meta.RequestAt)
 		log.Data["ttfb"] = common.TruncateDuration(ttfb).String()
 	}
 
+	if meta.UpstreamConnectDuration > 0 {
+		log.Data["upstream_connect"] = common.TruncateDuration(meta.UpstreamConnectDuration).String()
+	}
+
+	if meta.StreamChunks > 0 {
+		log.Data["stream_chunks"] = meta.StreamChunks
+		log.Data["stream_duration"] = common.TruncateDuration(meta.StreamDuration).String()
+	}
+
+	if meta.DocumentPages > 0 {
+		log.Data["pdf_pages"] = meta.DocumentPages
+	}
+
 	return result, &detail, nil
 }
 
+// cancelUpstreamAfterGrace cancels the upstream request once the grace period
+// after the client disconnected passed, the upstream usage may arrive within it
+func cancelUpstreamAfterGrace(cancel context.CancelFunc) {
+	grace := time.Duration(config.GetClientAbortGraceSeconds()) * time.Second
+	if grace <= 0 {
+		cancel()
+		return
+	}
+
+	time.AfterFunc(grace, cancel)
+}
+
 func prepareAndDoRequest(
 	ctx context.Context,
 	a adaptor.Adaptor,
@@ -163,28 +266,72 @@ func prepareAndDoRequest(
 	meta *meta.Meta,
 	store adaptor.Store,
 ) (*http.Response, adaptor.Error) {
+	req, err := prepareRequest(
+		httptrace.WithClientTrace(ctx, upstreamConnectTrace(meta)),
+		a,
+		c,
+		meta,
+		store,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRequest(req)
+
+	return doRequest(a, c, meta, store, req)
+}
+
+// PrepareRequest builds the upstream request of the adaptor without sending it,
+// the caller closes the request with CloseRequest
+func PrepareRequest(
+	a adaptor.Adaptor,
+	c *gin.Context,
+	meta *meta.Meta,
+	store adaptor.Store,
+) (*http.Request, adaptor.Error) {
+	return prepareRequest(c.Request.Context(), a, c, meta, store)
+}
+
+// CloseRequest closes the body of the request built by PrepareRequest
+func CloseRequest(req *http.Request) {
+	closeRequest(req)
+}
+
+func prepareRequest(
+	ctx context.Context,
+	a adaptor.Adaptor,
+	c *gin.Context,
+	meta *meta.Meta,
+	store adaptor.Store,
+) (*http.Request, adaptor.Error) {
 	log := common.GetLogger(c)
 
+	if guardrailsErr := applyGroupGuardrails(meta, c.Request); guardrailsErr != nil {
+		return nil, guardrailsErr
+	}
+
 	convertResult, err := a.ConvertRequest(meta, store, c.Request)
 	if err != nil {
 		return nil, mapRequestError(meta, err, http.StatusBadRequest, "convert request failed")
 	}
 
-	var req *http.Request
-	defer func() {
-		if req != nil {
-			if req.Body != nil {
-				_ = req.Body.Close()
-				req.Body = http.NoBody
-			}
-
-			req.GetBody = nil
-
-			return
-		}
+	convertResult, err = applyParamOverrides(meta, convertResult)
+	if err != nil {
+		return nil, relaymodel.WrapperErrorWithMessage(
+			meta.Mode,
+			http.StatusInternalServerError,
+			"apply param overrides failed: "+err.Error(),
+		)
+	}
 
-		closeRequestReader(convertResult.Body)
-	}()
+	convertResult, err = debugConversion(c, meta, convertResult)
+	if err != nil {
+		return nil, relaymodel.WrapperErrorWithMessage(
+			meta.Mode,
+			http.StatusInternalServerError,
+			"read converted request failed: "+err.Error(),
+		)
+	}
 
 	if meta.Channel.BaseURL == "" {
 		meta.Channel.BaseURL = a.DefaultBaseURL()
@@ -192,6 +339,8 @@ func prepareAndDoRequest(
 
 	fullRequestURL, err := a.GetRequestURL(meta, store, c)
 	if err != nil {
+		closeRequestReader(convertResult.Body)
+
 		return nil, relaymodel.WrapperErrorWithMessage(
 			meta.Mode,
 			http.StatusBadRequest,
@@ -199,15 +348,28 @@ func prepareAndDoRequest(
 		)
 	}
 
+	fullRequestURL.URL, err = applyURLTemplate(meta, fullRequestURL.URL)
+	if err != nil {
+		closeRequestReader(convertResult.Body)
+
+		return nil, relaymodel.WrapperErrorWithMessage(
+			meta.Mode,
+			http.StatusBadRequest,
+			"apply url template failed: "+err.Error(),
+		)
+	}
+
 	log.Debugf("request url: %s %s", fullRequestURL.Method, fullRequestURL.URL)
 
-	req, err = http.NewRequestWithContext(
+	req, err := http.NewRequestWithContext(
 		ctx,
 		fullRequestURL.Method,
 		fullRequestURL.URL,
 		convertResult.Body,
 	)
 	if err != nil {
+		closeRequestReader(convertResult.Body)
+
 		return nil, relaymodel.WrapperErrorWithMessage(
 			meta.Mode,
 			http.StatusBadRequest,
@@ -216,10 +378,84 @@ func prepareAndDoRequest(
 	}
 
 	if err := setupRequestHeader(a, c, meta, store, req, convertResult.Header); err != nil {
+		closeRequest(req)
 		return nil, err
 	}
 
-	return doRequest(a, c, meta, store, req)
+	return req, nil
+}
+
+// applyParamOverrides applies the param overrides of the channel to the json
+// body of the converted request, the other bodies are sent unchanged
+func applyParamOverrides(
+	meta *meta.Meta,
+	convertResult adaptor.ConvertResult,
+) (adaptor.ConvertResult, error) {
+	patches := meta.Channel.ParamOverrides.Patches(meta.OriginModel, meta.ActualModel)
+	if len(patches) == 0 || convertResult.Body == nil {
+		return convertResult, nil
+	}
+
+	contentType := convertResult.Header.Get("Content-Type")
+	if contentType != "" && !strings.Contains(contentType, "json") {
+		return convertResult, nil
+	}
+
+	body, err := io.ReadAll(convertResult.Body)
+	closeRequestReader(convertResult.Body)
+
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	body, err = common.MergePatchJSON(body, patches...)
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	header := convertResult.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return adaptor.ConvertResult{
+		Header: header,
+		Body:   bytes.NewReader(body),
+	}, nil
+}
+
+func closeRequest(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+		req.Body = http.NoBody
+	}
+
+	req.GetBody = nil
+}
+
+// upstreamConnectTrace records the time spent getting a new upstream
+// connection, the dns lookup and the tls handshake included, and whether the
+// request was written
+func upstreamConnectTrace(meta *meta.Meta) *httptrace.ClientTrace {
+	var getConnAt time.Time
+
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			getConnAt = time.Now()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			if !info.Reused && !getConnAt.IsZero() {
+				meta.UpstreamConnectDuration = time.Since(getConnAt)
+			}
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				meta.MarkRequestSent()
+			}
+		},
+	}
 }
 
 func closeRequestReader(r io.Reader) {
@@ -314,6 +550,11 @@ func doRequest(
 	req *http.Request,
 ) (*http.Response, adaptor.Error) {
 	resp, err := a.DoRequest(meta, store, c, req)
+	if resp != nil {
+		meta.MarkRequestSent()
+		meta.UpstreamStatusCode = resp.StatusCode
+	}
+
 	if err != nil {
 		return nil, mapRequestError(meta, err, http.StatusInternalServerError, "request error")
 	}
@@ -346,17 +587,30 @@ func handleResponse(
 		ResponseWriter: c.Writer,
 		body:           buf,
 		bodyLimit:      bodyLimit,
+		transcript:     newStreamTranscript(opt.MaxStreamTranscriptSize),
 	}
 
 	rawWriter := c.Writer
 	defer func() {
 		c.Writer = rawWriter
 		detail.FirstByteAt = rw.firstByteAt
+		detail.StreamTranscript, detail.StreamTranscriptTruncated = rw.transcript.result()
+
+		if rw.chunks > 0 {
+			meta.StreamChunks = rw.chunks
+			meta.StreamDuration = rw.lastByteAt.Sub(rw.firstByteAt)
+		}
 	}()
 
 	c.Writer = rw
 
+	upstreamError := captureUpstreamError(meta, resp)
+
 	result, relayErr := a.DoResponse(meta, store, c, resp)
+	if relayErr != nil && upstreamError != nil {
+		detail.UpstreamError = upstreamError()
+	}
+
 	if relayErr != nil && opt.IncludeResponseBody && opt.MaxResponseBodySize >= 0 {
 		respBody, _ := relayErr.MarshalJSON()
 		detail.ResponseBody = responseBodyDetail(respBody, opt.MaxResponseBodySize)
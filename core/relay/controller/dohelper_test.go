@@ -10,6 +10,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/labring/aiproxy/core/model"
@@ -610,3 +611,123 @@ func TestPrepareAndDoRequestClosesConvertedRequestBodyWhenGetRequestURLFails(t *
 	require.Contains(t, err.Error(), "get request url failed: bad url")
 	require.Equal(t, 1, closeCounter.closed)
 }
+
+func TestDoHelperCancelsUpstreamStreamOnClientAbort(t *testing.T) {
+	c, relayMeta := newTestRelayContext()
+
+	clientCtx, cancelClient := context.WithCancel(context.Background())
+	c.Request = c.Request.WithContext(clientCtx)
+
+	var upstreamCtx context.Context
+
+	result, _, relayErr := DoHelper(
+		testAdaptor{
+			convertRequest: func(
+				_ *meta.Meta,
+				_ adaptor.Store,
+				_ *http.Request,
+			) (adaptor.ConvertResult, error) {
+				return adaptor.ConvertResult{Body: http.NoBody}, nil
+			},
+			doRequest: func(
+				_ *meta.Meta,
+				_ adaptor.Store,
+				_ *gin.Context,
+				req *http.Request,
+			) (*http.Response, error) {
+				upstreamCtx = req.Context()
+
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(strings.NewReader("data: upstream\n\n")),
+					Header:     http.Header{"Content-Type": {"text/event-stream"}},
+				}, nil
+			},
+			doResponse: func(
+				_ *meta.Meta,
+				_ adaptor.Store,
+				_ *gin.Context,
+				_ *http.Response,
+			) (adaptor.DoResponseResult, adaptor.Error) {
+				cancelClient()
+
+				select {
+				case <-upstreamCtx.Done():
+				case <-time.After(time.Second):
+					t.Error("upstream request was not canceled after the client aborted")
+				}
+
+				return adaptor.DoResponseResult{
+					Usage: model.Usage{OutputTokens: 3, TotalTokens: 3},
+				}, nil
+			},
+		},
+		c,
+		relayMeta,
+		nil,
+	)
+
+	require.Nil(t, relayErr)
+	assert.Equal(t, model.ZeroNullInt64(3), result.Usage.OutputTokens)
+	assert.True(t, relayMeta.ClientAborted)
+}
+
+func TestDoHelperKeepsNonStreamUpstreamOnClientAbort(t *testing.T) {
+	c, relayMeta := newTestRelayContext()
+
+	clientCtx, cancelClient := context.WithCancel(context.Background())
+	c.Request = c.Request.WithContext(clientCtx)
+
+	var upstreamCtx context.Context
+
+	result, _, relayErr := DoHelper(
+		testAdaptor{
+			convertRequest: func(
+				_ *meta.Meta,
+				_ adaptor.Store,
+				_ *http.Request,
+			) (adaptor.ConvertResult, error) {
+				return adaptor.ConvertResult{Body: http.NoBody}, nil
+			},
+			doRequest: func(
+				_ *meta.Meta,
+				_ adaptor.Store,
+				_ *gin.Context,
+				req *http.Request,
+			) (*http.Response, error) {
+				upstreamCtx = req.Context()
+
+				cancelClient()
+
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(strings.NewReader(`{"usage":{}}`)),
+					Header:     http.Header{"Content-Type": {"application/json"}},
+				}, nil
+			},
+			doResponse: func(
+				_ *meta.Meta,
+				_ adaptor.Store,
+				_ *gin.Context,
+				_ *http.Response,
+			) (adaptor.DoResponseResult, adaptor.Error) {
+				select {
+				case <-upstreamCtx.Done():
+					t.Error("non-stream upstream request was canceled after the client aborted")
+				case <-time.After(100 * time.Millisecond):
+				}
+
+				return adaptor.DoResponseResult{
+					Usage: model.Usage{InputTokens: 5, OutputTokens: 7, TotalTokens: 12},
+				}, nil
+			},
+		},
+		c,
+		relayMeta,
+		nil,
+	)
+
+	require.Nil(t, relayErr)
+	assert.Equal(t, model.ZeroNullInt64(12), result.Usage.TotalTokens)
+	assert.True(t, relayMeta.ClientAborted)
+}
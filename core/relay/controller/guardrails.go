@@ -0,0 +1,436 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bytedance/sonic/ast"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+)
+
+// guardrailsRequest is the layout of the request fields enforced by the group
+// guardrails in the protocol of the client
+type guardrailsRequest struct {
+	// generationConfig is the object holding the sampling parameters, empty
+	// for the top level
+	generationConfig string
+	temperature      string
+	// maxTokens are the fields of the max tokens, the first one is set by
+	// the default
+	maxTokens     []string
+	toolNames     func(node *ast.Node) []string
+	prependSystem func(node *ast.Node, prompt string) error
+}
+
+var (
+	chatGuardrailsRequest = guardrailsRequest{
+		temperature:   "temperature",
+		maxTokens:     []string{"max_tokens", "max_completion_tokens"},
+		toolNames:     chatToolNames,
+		prependSystem: prependChatSystemPrompt,
+	}
+	claudeGuardrailsRequest = guardrailsRequest{
+		temperature:   "temperature",
+		maxTokens:     []string{"max_tokens"},
+		toolNames:     claudeToolNames,
+		prependSystem: prependClaudeSystemPrompt,
+	}
+	geminiGuardrailsRequest = guardrailsRequest{
+		generationConfig: "generationConfig",
+		temperature:      "temperature",
+		maxTokens:        []string{"maxOutputTokens"},
+		toolNames:        geminiToolNames,
+		prependSystem:    prependGeminiSystemPrompt,
+	}
+)
+
+func getGuardrailsRequest(m mode.Mode) (guardrailsRequest, bool) {
+	switch m {
+	case mode.ChatCompletions:
+		return chatGuardrailsRequest, true
+	case mode.Anthropic:
+		return claudeGuardrailsRequest, true
+	case mode.Gemini:
+		return geminiGuardrailsRequest, true
+	default:
+		return guardrailsRequest{}, false
+	}
+}
+
+// applyGroupGuardrails enforces the guardrails of the group on the request of
+// the client before it is converted for the upstream, it is applied again on
+// the retries so every step keeps the request unchanged the second time
+func applyGroupGuardrails(meta *meta.Meta, req *http.Request) adaptor.Error {
+	guardrails := &meta.Group.Guardrails
+	if guardrails.IsEmpty() {
+		return nil
+	}
+
+	layout, ok := getGuardrailsRequest(meta.Mode)
+	if !ok {
+		return nil
+	}
+
+	node, err := common.UnmarshalRequest2NodeReusable(req)
+	if err != nil {
+		return relaymodel.WrapperErrorWithMessage(
+			meta.Mode,
+			http.StatusBadRequest,
+			"invalid request: "+err.Error(),
+		)
+	}
+
+	for _, name := range layout.toolNames(&node) {
+		if guardrails.IsToolForbidden(name) {
+			return relaymodel.WrapperErrorWithMessage(
+				meta.Mode,
+				http.StatusBadRequest,
+				fmt.Sprintf("the tool `%s` is forbidden for the group", name),
+			)
+		}
+	}
+
+	if err := applyGuardrailsParams(&node, layout, guardrails); err != nil {
+		return relaymodel.WrapperErrorWithMessage(
+			meta.Mode,
+			http.StatusBadRequest,
+			"apply group guardrails failed: "+err.Error(),
+		)
+	}
+
+	if guardrails.SafeModePrompt != "" {
+		if err := layout.prependSystem(&node, guardrails.SafeModePrompt); err != nil {
+			return relaymodel.WrapperErrorWithMessage(
+				meta.Mode,
+				http.StatusBadRequest,
+				"apply group guardrails failed: "+err.Error(),
+			)
+		}
+	}
+
+	body, err := node.MarshalJSON()
+	if err != nil {
+		return relaymodel.WrapperErrorWithMessage(
+			meta.Mode,
+			http.StatusInternalServerError,
+			"apply group guardrails failed: "+err.Error(),
+		)
+	}
+
+	common.SetRequestBody(req, body)
+
+	return nil
+}
+
+func applyGuardrailsParams(
+	node *ast.Node,
+	layout guardrailsRequest,
+	guardrails *model.GroupGuardrails,
+) error {
+	if guardrails.DefaultTemperature == nil &&
+		guardrails.MaxTemperature == nil &&
+		guardrails.DefaultMaxTokens == 0 &&
+		guardrails.MaxTokens == 0 {
+		return nil
+	}
+
+	params := node
+	if layout.generationConfig != "" {
+		params = node.Get(layout.generationConfig)
+		if !isPresent(params) {
+			if _, err := node.Set(layout.generationConfig, ast.NewObject(nil)); err != nil {
+				return err
+			}
+
+			params = node.Get(layout.generationConfig)
+		}
+	}
+
+	if err := clampFloatParam(
+		params,
+		layout.temperature,
+		guardrails.DefaultTemperature,
+		guardrails.MaxTemperature,
+	); err != nil {
+		return err
+	}
+
+	return clampMaxTokensParams(params, layout.maxTokens, guardrails)
+}
+
+func isPresent(node *ast.Node) bool {
+	return node.Exists() && node.TypeSafe() != ast.V_NULL
+}
+
+func formatFloatParam(v float64) ast.Node {
+	return ast.NewNumber(strconv.FormatFloat(v, 'f', -1, 64))
+}
+
+func clampFloatParam(params *ast.Node, key string, defaultValue, maxValue *float64) error {
+	value := params.Get(key)
+	if !isPresent(value) {
+		if defaultValue == nil {
+			return nil
+		}
+
+		_, err := params.Set(key, formatFloatParam(*defaultValue))
+
+		return err
+	}
+
+	if maxValue == nil {
+		return nil
+	}
+
+	v, err := value.Float64()
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+
+	if v <= *maxValue {
+		return nil
+	}
+
+	_, err = params.Set(key, formatFloatParam(*maxValue))
+
+	return err
+}
+
+func clampMaxTokensParams(
+	params *ast.Node,
+	keys []string,
+	guardrails *model.GroupGuardrails,
+) error {
+	present := false
+
+	for _, key := range keys {
+		value := params.Get(key)
+		if !isPresent(value) {
+			continue
+		}
+
+		present = true
+
+		if guardrails.MaxTokens <= 0 {
+			continue
+		}
+
+		v, err := value.Int64()
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+
+		if v <= guardrails.MaxTokens {
+			continue
+		}
+
+		if _, err := params.Set(key, ast.NewNumber(strconv.FormatInt(guardrails.MaxTokens, 10))); err != nil {
+			return err
+		}
+	}
+
+	if present || guardrails.DefaultMaxTokens <= 0 {
+		return nil
+	}
+
+	_, err := params.Set(
+		keys[0],
+		ast.NewNumber(strconv.FormatInt(guardrails.DefaultMaxTokens, 10)),
+	)
+
+	return err
+}
+
+func nodeString(node *ast.Node, key string) string {
+	s, _ := node.Get(key).String()
+	return s
+}
+
+func forEachNode(node *ast.Node, f func(node *ast.Node)) {
+	if !isPresent(node) {
+		return
+	}
+
+	_ = node.ForEach(func(_ ast.Sequence, node *ast.Node) bool {
+		f(node)
+		return true
+	})
+}
+
+func chatToolNames(node *ast.Node) []string {
+	var names []string
+
+	forEachNode(node.Get("tools"), func(tool *ast.Node) {
+		toolType := nodeString(tool, "type")
+		if toolType != "" && toolType != "function" {
+			names = append(names, toolType)
+		}
+
+		if name := nodeString(tool.Get("function"), "name"); name != "" {
+			names = append(names, name)
+		}
+	})
+
+	return names
+}
+
+func claudeToolNames(node *ast.Node) []string {
+	var names []string
+
+	forEachNode(node.Get("tools"), func(tool *ast.Node) {
+		if toolType := nodeString(tool, "type"); toolType != "" && toolType != "custom" {
+			names = append(names, toolType)
+		}
+
+		if name := nodeString(tool, "name"); name != "" {
+			names = append(names, name)
+		}
+	})
+
+	return names
+}
+
+func geminiToolNames(node *ast.Node) []string {
+	var names []string
+
+	forEachNode(node.Get("tools"), func(tool *ast.Node) {
+		_ = tool.ForEach(func(kv ast.Sequence, value *ast.Node) bool {
+			if kv.Key == nil {
+				return true
+			}
+
+			switch *kv.Key {
+			case "functionDeclarations", "function_declarations":
+				forEachNode(value, func(declaration *ast.Node) {
+					if name := nodeString(declaration, "name"); name != "" {
+						names = append(names, name)
+					}
+				})
+			default:
+				// the built-in tools, e.g. googleSearch
+				names = append(names, *kv.Key)
+			}
+
+			return true
+		})
+	})
+
+	return names
+}
+
+func prependNode(array *ast.Node, first ast.Node) error {
+	nodes, err := array.ArrayUseNode()
+	if err != nil {
+		return err
+	}
+
+	newNodes := make([]ast.Node, 0, len(nodes)+1)
+	newNodes = append(newNodes, first)
+	newNodes = append(newNodes, nodes...)
+
+	*array = ast.NewArray(newNodes)
+
+	return nil
+}
+
+func textNode(text string) ast.Node {
+	return ast.NewObject([]ast.Pair{
+		ast.NewPair("type", ast.NewString("text")),
+		ast.NewPair("text", ast.NewString(text)),
+	})
+}
+
+func prependChatSystemPrompt(node *ast.Node, prompt string) error {
+	messages := node.Get("messages")
+	if !isPresent(messages) {
+		return nil
+	}
+
+	first := messages.Index(0)
+	if isPresent(first) &&
+		nodeString(first, "role") == relaymodel.RoleSystem &&
+		nodeString(first, "content") == prompt {
+		return nil
+	}
+
+	return prependNode(messages, ast.NewObject([]ast.Pair{
+		ast.NewPair("role", ast.NewString(relaymodel.RoleSystem)),
+		ast.NewPair("content", ast.NewString(prompt)),
+	}))
+}
+
+func prependClaudeSystemPrompt(node *ast.Node, prompt string) error {
+	system := node.Get("system")
+	if !isPresent(system) {
+		_, err := node.Set("system", ast.NewString(prompt))
+		return err
+	}
+
+	switch system.TypeSafe() {
+	case ast.V_STRING:
+		s, err := system.String()
+		if err != nil {
+			return err
+		}
+
+		if s == prompt {
+			return nil
+		}
+
+		_, err = node.Set("system", ast.NewArray([]ast.Node{
+			textNode(prompt),
+			textNode(s),
+		}))
+
+		return err
+	case ast.V_ARRAY:
+		if nodeString(system.Index(0), "text") == prompt {
+			return nil
+		}
+
+		return prependNode(system, textNode(prompt))
+	default:
+		return fmt.Errorf("invalid system type: %d", system.TypeSafe())
+	}
+}
+
+func prependGeminiSystemPrompt(node *ast.Node, prompt string) error {
+	key := "systemInstruction"
+
+	system := node.Get(key)
+	if !isPresent(system) {
+		if snake := node.Get("system_instruction"); isPresent(snake) {
+			key, system = "system_instruction", snake
+		}
+	}
+
+	part := ast.NewObject([]ast.Pair{
+		ast.NewPair("text", ast.NewString(prompt)),
+	})
+
+	if !isPresent(system) {
+		_, err := node.Set(key, ast.NewObject([]ast.Pair{
+			ast.NewPair("parts", ast.NewArray([]ast.Node{part})),
+		}))
+
+		return err
+	}
+
+	parts := system.Get("parts")
+	if !isPresent(parts) {
+		_, err := system.Set("parts", ast.NewArray([]ast.Node{part}))
+		return err
+	}
+
+	if nodeString(parts.Index(0), "text") == prompt {
+		return nil
+	}
+
+	return prependNode(parts, part)
+}
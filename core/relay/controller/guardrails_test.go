@@ -0,0 +1,144 @@
+//nolint:testpackage
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/require"
+)
+
+func newGuardrailsRequest(t *testing.T, body string) *http.Request {
+	t.Helper()
+
+	return httptest.NewRequestWithContext(
+		context.Background(),
+		http.MethodPost,
+		"/v1/chat/completions",
+		strings.NewReader(body),
+	)
+}
+
+func newGuardrailsMeta(m mode.Mode, guardrails model.GroupGuardrails) *meta.Meta {
+	relayMeta := meta.NewMeta(nil, m, "gpt-4o-mini", model.ModelConfig{})
+	relayMeta.Group.Guardrails = guardrails
+
+	return relayMeta
+}
+
+func TestApplyGroupGuardrails(t *testing.T) {
+	maxTemperature := 1.0
+	defaultTemperature := 0.5
+
+	guardrails := model.GroupGuardrails{
+		DefaultTemperature: &defaultTemperature,
+		MaxTemperature:     &maxTemperature,
+		DefaultMaxTokens:   100,
+		MaxTokens:          1000,
+		SafeModePrompt:     "stay safe",
+	}
+
+	tests := []struct {
+		name     string
+		mode     mode.Mode
+		body     string
+		expected string
+	}{
+		{
+			name:     "chat clamps the parameters",
+			mode:     mode.ChatCompletions,
+			body:     `{"temperature":1.7,"max_completion_tokens":5000,"messages":[{"role":"user","content":"hi"}]}`,
+			expected: `{"temperature":1,"max_completion_tokens":1000,"messages":[{"role":"system","content":"stay safe"},{"role":"user","content":"hi"}]}`,
+		},
+		{
+			name:     "chat sets the defaults",
+			mode:     mode.ChatCompletions,
+			body:     `{"messages":[{"role":"user","content":"hi"}]}`,
+			expected: `{"messages":[{"role":"system","content":"stay safe"},{"role":"user","content":"hi"}],"temperature":0.5,"max_tokens":100}`,
+		},
+		{
+			name:     "claude prepends the system prompt",
+			mode:     mode.Anthropic,
+			body:     `{"max_tokens":9000,"system":"be nice","messages":[]}`,
+			expected: `{"max_tokens":1000,"system":[{"type":"text","text":"stay safe"},{"type":"text","text":"be nice"}],"messages":[],"temperature":0.5}`,
+		},
+		{
+			name:     "gemini clamps the generation config",
+			mode:     mode.Gemini,
+			body:     `{"contents":[],"generationConfig":{"temperature":2,"maxOutputTokens":50000},"systemInstruction":{"parts":[{"text":"x"}]}}`,
+			expected: `{"contents":[],"generationConfig":{"temperature":1,"maxOutputTokens":1000},"systemInstruction":{"parts":[{"text":"stay safe"},{"text":"x"}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newGuardrailsRequest(t, tt.body)
+			relayMeta := newGuardrailsMeta(tt.mode, guardrails)
+
+			require.Nil(t, applyGroupGuardrails(relayMeta, req))
+
+			body, err := common.GetRequestBodyReusable(req)
+			require.NoError(t, err)
+			require.JSONEq(t, tt.expected, string(body))
+
+			// the retries apply the guardrails again
+			require.Nil(t, applyGroupGuardrails(relayMeta, req))
+
+			retried, err := common.GetRequestBodyReusable(req)
+			require.NoError(t, err)
+			require.JSONEq(t, string(body), string(retried))
+		})
+	}
+}
+
+func TestApplyGroupGuardrailsForbiddenTools(t *testing.T) {
+	guardrails := model.GroupGuardrails{
+		ForbiddenTools: []string{"web_search", "googleSearch"},
+	}
+
+	tests := []struct {
+		name string
+		mode mode.Mode
+		body string
+	}{
+		{
+			name: "chat",
+			mode: mode.ChatCompletions,
+			body: `{"messages":[],"tools":[{"type":"function","function":{"name":"web_search"}}]}`,
+		},
+		{
+			name: "claude",
+			mode: mode.Anthropic,
+			body: `{"messages":[],"tools":[{"type":"web_search_20250305","name":"web_search"}]}`,
+		},
+		{
+			name: "gemini",
+			mode: mode.Gemini,
+			body: `{"contents":[],"tools":[{"googleSearch":{}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relayErr := applyGroupGuardrails(
+				newGuardrailsMeta(tt.mode, guardrails),
+				newGuardrailsRequest(t, tt.body),
+			)
+			require.NotNil(t, relayErr)
+			require.Equal(t, http.StatusBadRequest, relayErr.StatusCode())
+			require.Contains(t, relayErr.Error(), "forbidden")
+		})
+	}
+
+	require.Nil(t, applyGroupGuardrails(
+		newGuardrailsMeta(mode.ChatCompletions, guardrails),
+		newGuardrailsRequest(t, `{"messages":[],"tools":[{"type":"function","function":{"name":"lookup"}}]}`),
+	))
+}
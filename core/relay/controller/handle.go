@@ -21,6 +21,11 @@ type HandleResult struct {
 	UpstreamID   string
 	AsyncUsage   bool
 	BodyDetail   *BodyDetail
+	// StreamsSaturated reports the channel had no free stream slot, the
+	// request was not sent
+	StreamsSaturated bool
+
+	SystemFingerprint string
 }
 
 func ShouldSkipRequestBodyDetailForStatus(statusCode int) bool {
@@ -84,15 +89,21 @@ func Handle(
 
 	result, detail, respErr := DoHelper(adaptor, c, meta, store, opts...)
 	if respErr != nil {
+		redactor := newErrorRedactor(meta)
+		respErr = redactor.redactError(respErr)
+		redactor.redactDetail(detail)
+
 		logHandleError(log, respErr, detail, config.DebugEnabled)
 
 		return &HandleResult{
-			Error:        respErr,
+			Error:        WithUpstreamError(respErr, detail.UpstreamError),
 			Usage:        result.Usage,
 			UsageContext: result.UsageContext,
 			UpstreamID:   result.UpstreamID,
 			AsyncUsage:   result.AsyncUsage,
 			BodyDetail:   detail,
+
+			SystemFingerprint: result.SystemFingerprint,
 		}
 	}
 
@@ -102,5 +113,7 @@ func Handle(
 		UpstreamID:   result.UpstreamID,
 		AsyncUsage:   result.AsyncUsage,
 		BodyDetail:   detail,
+
+		SystemFingerprint: result.SystemFingerprint,
 	}
 }
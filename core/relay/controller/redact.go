@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+)
+
+const (
+	redactedSecret = "[REDACTED]"
+	redactedHost   = "[upstream]"
+	// the key parts shorter than this are too common to be replaced
+	minRedactSecretLen = 8
+)
+
+var (
+	// the credential headers echoed as `name: value` or `"name": "value"`
+	redactHeaderPattern = regexp.MustCompile(
+		`(?i)\b((?:x-goog-api-key|x-api-key|api-key|authorization)["']?\s*[:=]\s*["']?)(?:(?:bearer|basic)\s+)?[^\s"',;}]+`,
+	)
+	redactBearerPattern = regexp.MustCompile(
+		`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]{8,}`,
+	)
+	// the credentials carried by the query of the echoed urls
+	redactQueryPattern = regexp.MustCompile(
+		`(?i)([?&](?:key|api[_-]?key|access[_-]?token|token|signature|sig)=)[^&\s"'#]+`,
+	)
+	// the well known key formats of the upstreams
+	redactKeyPattern = regexp.MustCompile(
+		`\b(?:sk-[A-Za-z0-9_-]{16,}|AIza[0-9A-Za-z_-]{35})\b`,
+	)
+	// the private addresses and the cluster internal hostnames
+	redactInternalHostPattern = regexp.MustCompile(
+		`\b(?:10\.\d{1,3}\.\d{1,3}\.\d{1,3}|192\.168\.\d{1,3}\.\d{1,3}|172\.(?:1[6-9]|2\d|3[01])\.\d{1,3}\.\d{1,3}|127\.\d{1,3}\.\d{1,3}\.\d{1,3}|[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.(?:svc|cluster\.local|internal))(?::\d+)?\b`,
+	)
+)
+
+// errorRedactor strips the credentials and the internal hostnames echoed by
+// the upstream error messages
+type errorRedactor struct {
+	secrets []string
+	hosts   []string
+}
+
+func newErrorRedactor(meta *meta.Meta) *errorRedactor {
+	r := &errorRedactor{}
+
+	if key := meta.Channel.Key; key != "" {
+		r.addSecret(key)
+		// the composite keys, e.g. `ak|sk`, may be echoed by parts
+		for part := range strings.FieldsFuncSeq(key, func(c rune) bool {
+			return c == '|' || c == ',' || c == '\n'
+		}) {
+			r.addSecret(strings.TrimSpace(part))
+		}
+	}
+
+	r.addHost(meta.Channel.BaseURL)
+	r.addHost(meta.Channel.ProxyURL)
+
+	// the longer values first, so the parts do not break the whole values
+	sortByLenDesc(r.secrets)
+	sortByLenDesc(r.hosts)
+
+	return r
+}
+
+func sortByLenDesc(values []string) {
+	slices.SortFunc(values, func(a, b string) int {
+		return len(b) - len(a)
+	})
+}
+
+func (r *errorRedactor) addSecret(secret string) {
+	if len(secret) < minRedactSecretLen || slices.Contains(r.secrets, secret) {
+		return
+	}
+
+	r.secrets = append(r.secrets, secret)
+}
+
+func (r *errorRedactor) addHost(rawURL string) {
+	if rawURL == "" {
+		return
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return
+	}
+
+	for _, host := range []string{u.Host, u.Hostname()} {
+		if host != "" && !slices.Contains(r.hosts, host) {
+			r.hosts = append(r.hosts, host)
+		}
+	}
+
+	if u.User != nil {
+		r.addSecret(u.User.String())
+	}
+}
+
+func (r *errorRedactor) redact(s string) string {
+	if s == "" {
+		return s
+	}
+
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redactedSecret)
+	}
+
+	s = redactHeaderPattern.ReplaceAllString(s, "${1}"+redactedSecret)
+	s = redactBearerPattern.ReplaceAllString(s, "${1} "+redactedSecret)
+	s = redactQueryPattern.ReplaceAllString(s, "${1}"+redactedSecret)
+	s = redactKeyPattern.ReplaceAllString(s, redactedSecret)
+
+	for _, host := range r.hosts {
+		s = strings.ReplaceAll(s, host, redactedHost)
+	}
+
+	return redactInternalHostPattern.ReplaceAllString(s, redactedHost)
+}
+
+// redactDetail redacts the error response recorded for the logs and the
+// tokens debugging the upstream errors
+func (r *errorRedactor) redactDetail(detail *BodyDetail) {
+	if detail == nil {
+		return
+	}
+
+	detail.ResponseBody = r.redact(detail.ResponseBody)
+
+	if detail.UpstreamError == nil {
+		return
+	}
+
+	detail.UpstreamError.Body = r.redact(detail.UpstreamError.Body)
+	for k, v := range detail.UpstreamError.Header {
+		detail.UpstreamError.Header[k] = r.redact(v)
+	}
+}
+
+var _ adaptor.Error = (*redactedError)(nil)
+
+// redactedError is the error with the credentials and the internal hostnames
+// stripped from its message, the replacements contain no json special
+// characters so the marshaled error stays valid
+type redactedError struct {
+	err      adaptor.Error
+	redactor *errorRedactor
+}
+
+// redactError returns the error whose message and json are redacted
+func (r *errorRedactor) redactError(err adaptor.Error) adaptor.Error {
+	if err == nil {
+		return nil
+	}
+
+	return &redactedError{
+		err:      err,
+		redactor: r,
+	}
+}
+
+func (e *redactedError) Error() string {
+	return e.redactor.redact(e.err.Error())
+}
+
+func (e *redactedError) StatusCode() int {
+	return e.err.StatusCode()
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+func (e *redactedError) MarshalJSON() ([]byte, error) {
+	data, err := e.err.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(e.redactor.redact(string(data))), nil
+}
@@ -0,0 +1,125 @@
+//nolint:testpackage
+package controller
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/stretchr/testify/require"
+)
+
+func newRedactTestMeta() *meta.Meta {
+	m := &meta.Meta{}
+	m.Channel.Key = "ak-0123456789|sk-secret-abcdef"
+	m.Channel.BaseURL = "https://llm-gateway.corp.example.com:8443/v1"
+
+	return m
+}
+
+func TestErrorRedactorRedact(t *testing.T) {
+	r := newErrorRedactor(newRedactTestMeta())
+
+	tests := []struct {
+		name     string
+		input    string
+		contains string
+		hidden   []string
+	}{
+		{
+			name:     "channel key parts",
+			input:    "invalid access key ak-0123456789, secret sk-secret-abcdef",
+			contains: "invalid access key [REDACTED]",
+			hidden:   []string{"ak-0123456789", "sk-secret-abcdef"},
+		},
+		{
+			name:     "authorization header",
+			input:    `{"headers":{"Authorization":"Bearer token-abcdefgh"}}`,
+			contains: `"Authorization":"[REDACTED]"`,
+			hidden:   []string{"token-abcdefgh"},
+		},
+		{
+			name:     "api key header",
+			input:    "api-key: 0f9e8d7c6b5a",
+			contains: "api-key: [REDACTED]",
+			hidden:   []string{"0f9e8d7c6b5a"},
+		},
+		{
+			name:     "bearer token",
+			input:    "token Bearer abcdefgh12345678 expired",
+			contains: "Bearer [REDACTED] expired",
+			hidden:   []string{"abcdefgh12345678"},
+		},
+		{
+			name:     "query key",
+			input:    "GET https://example.com/v1/models?key=AIzaSomething&alt=sse failed",
+			contains: "?key=[REDACTED]&alt=sse",
+			hidden:   []string{"AIzaSomething"},
+		},
+		{
+			name:     "well known key format",
+			input:    "Incorrect API key provided: sk-proj-abcdefghijklmnopqrstu",
+			contains: "provided: [REDACTED]",
+			hidden:   []string{"sk-proj-abcdefghijklmnopqrstu"},
+		},
+		{
+			name:     "channel host",
+			input:    "dial tcp llm-gateway.corp.example.com:8443: connection refused",
+			contains: "dial tcp [upstream]: connection refused",
+			hidden:   []string{"llm-gateway.corp.example.com"},
+		},
+		{
+			name:     "internal hosts",
+			input:    "upstream 10.0.3.17:8080 and model-server.default.svc unreachable",
+			contains: "upstream [upstream] and [upstream] unreachable",
+			hidden:   []string{"10.0.3.17", "model-server"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.redact(tt.input)
+			require.Contains(t, got, tt.contains)
+
+			for _, hidden := range tt.hidden {
+				require.NotContains(t, got, hidden)
+			}
+		})
+	}
+}
+
+func TestErrorRedactorKeepsMessages(t *testing.T) {
+	r := newErrorRedactor(newRedactTestMeta())
+
+	message := `{"error":{"message":"Invalid schema for function 'f'","param":"tools[0]"}}`
+	require.Equal(t, message, r.redact(message))
+}
+
+func TestRedactedErrorMarshalJSON(t *testing.T) {
+	relayMeta := newRedactTestMeta()
+
+	resp := &http.Response{
+		StatusCode: http.StatusUnauthorized,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body: io.NopCloser(strings.NewReader(
+			`{"error":{"message":"invalid key sk-secret-abcdef for https://llm-gateway.corp.example.com:8443/v1"}}`,
+		)),
+	}
+
+	relayErr := newErrorRedactor(relayMeta).redactError(openai.ErrorHanlder(resp))
+	require.Equal(t, http.StatusUnauthorized, relayErr.StatusCode())
+	require.NotContains(t, relayErr.Error(), "sk-secret-abcdef")
+
+	data, err := relayErr.MarshalJSON()
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "sk-secret-abcdef")
+	require.NotContains(t, string(data), "llm-gateway")
+
+	var response map[string]any
+	require.NoError(t, sonic.Unmarshal(data, &response))
+	require.Contains(t, response, "error")
+}
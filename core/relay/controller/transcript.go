@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bytedance/sonic"
+	"github.com/bytedance/sonic/ast"
+)
+
+// the sse lines longer than this, e.g. the base64 images, are skipped instead
+// of being buffered
+const maxTranscriptLineSize = 1024 * 1024
+
+// streamTranscript reassembles the text deltas of a streamed response, the
+// head and the tail of the text are kept when it is over the max size
+type streamTranscript struct {
+	maxSize int
+	head    []byte
+	tail    []byte
+	total   int
+
+	checked  bool
+	isStream bool
+	line     []byte
+	skipLine bool
+}
+
+func newStreamTranscript(maxSize int64) *streamTranscript {
+	if maxSize <= 0 {
+		return nil
+	}
+
+	return &streamTranscript{maxSize: int(min(maxSize, int64(maxBufferSize)))}
+}
+
+func (t *streamTranscript) headLimit() int {
+	return t.maxSize - t.tailLimit()
+}
+
+func (t *streamTranscript) tailLimit() int {
+	return t.maxSize / 2
+}
+
+// write feeds the bytes written to the client, only the event streams are
+// reassembled
+func (t *streamTranscript) write(header http.Header, b []byte) {
+	if !t.checked {
+		t.checked = true
+		t.isStream = strings.Contains(header.Get("Content-Type"), "text/event-stream")
+	}
+
+	if !t.isStream {
+		return
+	}
+
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			t.bufferLine(b)
+			return
+		}
+
+		t.bufferLine(b[:i])
+		b = b[i+1:]
+
+		if !t.skipLine {
+			t.handleLine(t.line)
+		}
+
+		t.line = t.line[:0]
+		t.skipLine = false
+	}
+}
+
+func (t *streamTranscript) bufferLine(b []byte) {
+	if t.skipLine {
+		return
+	}
+
+	if len(t.line)+len(b) > maxTranscriptLineSize {
+		t.line = t.line[:0]
+		t.skipLine = true
+
+		return
+	}
+
+	t.line = append(t.line, b...)
+}
+
+func (t *streamTranscript) handleLine(line []byte) {
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+
+	data, ok := bytes.CutPrefix(line, []byte("data:"))
+	if !ok {
+		return
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("[DONE]")) {
+		return
+	}
+
+	t.appendText(transcriptDeltaText(data))
+}
+
+func (t *streamTranscript) appendText(text string) {
+	if text == "" {
+		return
+	}
+
+	t.total += len(text)
+
+	if remain := t.headLimit() - len(t.head); remain > 0 {
+		n := min(remain, len(text))
+		t.head = append(t.head, text[:n]...)
+		text = text[n:]
+	}
+
+	if text == "" {
+		return
+	}
+
+	t.tail = append(t.tail, text...)
+
+	// trim the tail once it is twice the limit, so the copies are amortized
+	if limit := t.tailLimit(); len(t.tail) > 2*limit {
+		t.tail = append(t.tail[:0], t.tail[len(t.tail)-limit:]...)
+	}
+}
+
+// result returns the reassembled text, the truncated middle is replaced with
+// a marker of its size
+func (t *streamTranscript) result() (string, bool) {
+	if t == nil || t.total == 0 {
+		return "", false
+	}
+
+	if t.total <= t.maxSize {
+		return string(t.head) + string(t.tail), false
+	}
+
+	tail := t.tail
+	if limit := t.tailLimit(); len(tail) > limit {
+		tail = tail[len(tail)-limit:]
+	}
+
+	head := t.head
+	for len(head) > 0 && !utf8.Valid(head) {
+		head = head[:len(head)-1]
+	}
+
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+
+	truncated := t.total - len(head) - len(tail)
+
+	return fmt.Sprintf(
+		"%s\n[... %d bytes truncated ...]\n%s",
+		head,
+		truncated,
+		tail,
+	), true
+}
+
+// transcriptDeltaText returns the text delta of a stream event of the openai
+// chat, completions and responses, the claude messages or the gemini
+func transcriptDeltaText(data []byte) string {
+	node, err := sonic.Get(data)
+	if err != nil {
+		return ""
+	}
+
+	if typ, err := node.Get("type").String(); err == nil {
+		switch typ {
+		case "content_block_delta":
+			text, _ := node.GetByPath("delta", "text").String()
+			return text
+		case "response.output_text.delta":
+			text, _ := node.Get("delta").String()
+			return text
+		default:
+			return ""
+		}
+	}
+
+	if choice := node.Get("choices").Index(0); choice.Exists() {
+		if text, err := choice.GetByPath("delta", "content").String(); err == nil {
+			return text
+		}
+
+		text, _ := choice.Get("text").String()
+
+		return text
+	}
+
+	parts := node.Get("candidates").Index(0).GetByPath("content", "parts")
+	if !parts.Exists() {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	_ = parts.ForEach(func(_ ast.Sequence, part *ast.Node) bool {
+		if thought, _ := part.Get("thought").Bool(); thought {
+			return true
+		}
+
+		text, _ := part.Get("text").String()
+		sb.WriteString(text)
+
+		return true
+	})
+
+	return sb.String()
+}
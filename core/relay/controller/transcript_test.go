@@ -0,0 +1,114 @@
+//nolint:testpackage
+package controller
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func eventStreamHeader() http.Header {
+	header := http.Header{}
+	header.Set("Content-Type", "text/event-stream")
+
+	return header
+}
+
+func TestStreamTranscript_ReassemblesDeltas(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   string
+	}{
+		{
+			name: "openai chat",
+			stream: "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\"}}]}\n\n" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello\"}}]}\n\n" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\", world\"}}]}\n\n" +
+				"data: [DONE]\n\n",
+			want: "Hello, world",
+		},
+		{
+			name: "claude messages",
+			stream: "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{}}\n\n" +
+				"event: content_block_delta\r\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\r\n\r\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\" there\"}}\n\n",
+			want: "Hi there",
+		},
+		{
+			name: "gemini",
+			stream: "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"plan\",\"thought\":true},{\"text\":\"4\"}]}}]}\n\n" +
+				"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"2\"}]}}]}\n\n",
+			want: "42",
+		},
+		{
+			name: "responses",
+			stream: "event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"delta\":\"ok\"}\n\n" +
+				"event: response.completed\ndata: {\"type\":\"response.completed\",\"response\":{}}\n\n",
+			want: "ok",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcript := newStreamTranscript(1024)
+			// the events are split across the writes
+			for chunk := range strings.SplitAfterSeq(tt.stream, "\"") {
+				transcript.write(eventStreamHeader(), []byte(chunk))
+			}
+
+			text, truncated := transcript.result()
+			assert.Equal(t, tt.want, text)
+			assert.False(t, truncated)
+		})
+	}
+}
+
+func TestStreamTranscript_Truncates(t *testing.T) {
+	transcript := newStreamTranscript(10)
+
+	for _, delta := range []string{"abcde", "fghij", "klmno", "pqrst"} {
+		transcript.write(
+			eventStreamHeader(),
+			[]byte(`data: {"choices":[{"delta":{"content":"`+delta+`"}}]}`+"\n\n"),
+		)
+	}
+
+	text, truncated := transcript.result()
+	assert.True(t, truncated)
+	assert.Equal(t, "abcde\n[... 10 bytes truncated ...]\npqrst", text)
+}
+
+func TestStreamTranscript_KeepsValidUTF8(t *testing.T) {
+	transcript := newStreamTranscript(8)
+
+	transcript.write(
+		eventStreamHeader(),
+		[]byte(`data: {"choices":[{"delta":{"content":"你好世界你好世界"}}]}`+"\n\n"),
+	)
+
+	text, truncated := transcript.result()
+	assert.True(t, truncated)
+	assert.Equal(t, "你\n[... 18 bytes truncated ...]\n界", text)
+}
+
+func TestStreamTranscript_IgnoresNonStreams(t *testing.T) {
+	transcript := newStreamTranscript(1024)
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	transcript.write(header, []byte(`data: {"choices":[{"delta":{"content":"x"}}]}`+"\n"))
+
+	text, truncated := transcript.result()
+	assert.Empty(t, text)
+	assert.False(t, truncated)
+
+	assert.Nil(t, newStreamTranscript(0))
+
+	var disabled *streamTranscript
+
+	text, _ = disabled.result()
+	assert.Empty(t, text)
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/bytedance/sonic/ast"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+)
+
+const maxUpstreamErrorBodySize = 64 * 1024
+
+// upstreamErrorHiddenHeaders are never returned to the client, even for the
+// tokens debugging the upstream errors
+var upstreamErrorHiddenHeaders = map[string]struct{}{
+	"Set-Cookie": {},
+}
+
+// UpstreamError is the raw error response of the upstream, returned to the
+// tokens debugging the upstream errors
+type UpstreamError struct {
+	StatusCode int               `json:"status_code"`
+	Header     map[string]string `json:"header,omitempty"`
+	Body       string            `json:"body"`
+}
+
+type upstreamErrorBody struct {
+	io.ReadCloser
+	buf *bytes.Buffer
+}
+
+func (b *upstreamErrorBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if remain := maxUpstreamErrorBodySize - b.buf.Len(); remain > 0 {
+			b.buf.Write(p[:min(n, remain)])
+		}
+	}
+
+	return n, err
+}
+
+// captureUpstreamError records the error body read by the adaptor, nil is
+// returned when the token does not debug the upstream errors
+func captureUpstreamError(meta *meta.Meta, resp *http.Response) func() *UpstreamError {
+	if !meta.Token.DebugUpstreamErrors ||
+		resp == nil ||
+		resp.Body == nil ||
+		resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	body := &upstreamErrorBody{
+		ReadCloser: resp.Body,
+		buf:        bytes.NewBuffer(nil),
+	}
+	resp.Body = body
+
+	return func() *UpstreamError {
+		// the adaptors may return the error without reading the body
+		_, _ = io.Copy(io.Discard, io.LimitReader(body, maxUpstreamErrorBodySize))
+
+		header := make(map[string]string, len(resp.Header))
+		for k, v := range resp.Header {
+			if _, ok := upstreamErrorHiddenHeaders[http.CanonicalHeaderKey(k)]; ok {
+				continue
+			}
+
+			header[k] = strings.Join(v, ", ")
+		}
+
+		return &UpstreamError{
+			StatusCode: resp.StatusCode,
+			Header:     header,
+			Body:       body.buf.String(),
+		}
+	}
+}
+
+var _ adaptor.Error = (*upstreamDebugError)(nil)
+
+// upstreamDebugError adds the raw upstream error to the `upstream` field of
+// the error response
+type upstreamDebugError struct {
+	err      adaptor.Error
+	upstream *UpstreamError
+}
+
+// WithUpstreamError returns the error with the raw upstream error, the error
+// is returned as it is when upstream is nil
+func WithUpstreamError(err adaptor.Error, upstream *UpstreamError) adaptor.Error {
+	if err == nil || upstream == nil {
+		return err
+	}
+
+	return &upstreamDebugError{
+		err:      err,
+		upstream: upstream,
+	}
+}
+
+func (e *upstreamDebugError) Error() string {
+	return e.err.Error()
+}
+
+func (e *upstreamDebugError) StatusCode() int {
+	return e.err.StatusCode()
+}
+
+func (e *upstreamDebugError) Unwrap() error {
+	return e.err
+}
+
+func (e *upstreamDebugError) MarshalJSON() ([]byte, error) {
+	data, err := e.err.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := common.GetJSONNodeNoCopy(data)
+	if err != nil || node.TypeSafe() != ast.V_OBJECT {
+		return data, nil
+	}
+
+	upstream, err := sonic.Marshal(e.upstream)
+	if err != nil {
+		return data, nil
+	}
+
+	if _, err := node.Set("upstream", ast.NewRaw(string(upstream))); err != nil {
+		return data, nil
+	}
+
+	return node.MarshalJSON()
+}
@@ -0,0 +1,98 @@
+//nolint:testpackage
+package controller
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/stretchr/testify/require"
+)
+
+func newUpstreamErrorResponse() *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusBadRequest,
+		Header: http.Header{
+			"Content-Type": {"application/json"},
+			"X-Request-Id": {"req_upstream"},
+			"Set-Cookie":   {"session=secret"},
+		},
+		Body: io.NopCloser(strings.NewReader(
+			`{"error":{"message":"Invalid schema for function 'f'","param":"tools[0]"}}`,
+		)),
+	}
+}
+
+func upstreamErrorAdaptor() testAdaptor {
+	return testAdaptor{
+		doResponse: func(
+			_ *meta.Meta,
+			_ adaptor.Store,
+			_ *gin.Context,
+			resp *http.Response,
+		) (adaptor.DoResponseResult, adaptor.Error) {
+			return adaptor.DoResponseResult{}, openai.ErrorHanlder(resp)
+		},
+	}
+}
+
+func TestHandleResponseIncludesUpstreamErrorForDebugTokens(t *testing.T) {
+	c, relayMeta := newTestRelayContext()
+	relayMeta.Token.DebugUpstreamErrors = true
+
+	detail := BodyDetail{}
+	_, relayErr := handleResponse(
+		upstreamErrorAdaptor(),
+		c,
+		relayMeta,
+		nil,
+		newUpstreamErrorResponse(),
+		&detail,
+		BodyDetailOption{},
+	)
+	require.NotNil(t, relayErr)
+	require.NotNil(t, detail.UpstreamError)
+	require.Equal(t, http.StatusBadRequest, detail.UpstreamError.StatusCode)
+	require.Equal(t, "req_upstream", detail.UpstreamError.Header["X-Request-Id"])
+	require.NotContains(t, detail.UpstreamError.Header, "Set-Cookie")
+	require.Contains(t, detail.UpstreamError.Body, "tools[0]")
+
+	data, err := WithUpstreamError(relayErr, detail.UpstreamError).MarshalJSON()
+	require.NoError(t, err)
+
+	var response map[string]any
+	require.NoError(t, sonic.Unmarshal(data, &response))
+	require.Contains(t, response, "error")
+
+	upstream, ok := response["upstream"].(map[string]any)
+	require.True(t, ok)
+	require.InDelta(t, http.StatusBadRequest, upstream["status_code"], 0)
+	require.Contains(t, upstream["body"], "Invalid schema")
+}
+
+func TestHandleResponseRedactsUpstreamErrorByDefault(t *testing.T) {
+	c, relayMeta := newTestRelayContext()
+
+	detail := BodyDetail{}
+	_, relayErr := handleResponse(
+		upstreamErrorAdaptor(),
+		c,
+		relayMeta,
+		nil,
+		newUpstreamErrorResponse(),
+		&detail,
+		BodyDetailOption{},
+	)
+	require.NotNil(t, relayErr)
+	require.Nil(t, detail.UpstreamError)
+
+	data, err := WithUpstreamError(relayErr, detail.UpstreamError).MarshalJSON()
+	require.NoError(t, err)
+	require.NotContains(t, string(data), `"upstream"`)
+}
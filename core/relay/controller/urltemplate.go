@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/meta"
+)
+
+// applyURLTemplate replaces the request url built by the adaptor with the url
+// template of the channel, the url is kept when the channel has no template
+// for the mode
+func applyURLTemplate(meta *meta.Meta, requestURL string) (string, error) {
+	tmpl := meta.Channel.URLTemplates.Template(meta.Mode)
+	if tmpl == "" {
+		return requestURL, nil
+	}
+
+	apiVersion, _ := meta.ChannelConfigs[model.URLPlaceholderAPIVersion].(string)
+
+	rendered, err := model.RenderURLTemplate(tmpl, map[string]string{
+		model.URLPlaceholderBaseURL:     strings.TrimSuffix(meta.Channel.BaseURL, "/"),
+		model.URLPlaceholderPath:        relativeRequestPath(meta.Channel.BaseURL, requestURL),
+		model.URLPlaceholderModel:       url.PathEscape(meta.ActualModel),
+		model.URLPlaceholderOriginModel: url.PathEscape(meta.OriginModel),
+		model.URLPlaceholderMode:        meta.Mode.String(),
+		model.URLPlaceholderAPIVersion:  url.QueryEscape(apiVersion),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(rendered)
+	if err != nil {
+		return "", fmt.Errorf("invalid url rendered from the template: %w", err)
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return "", errors.New("the url rendered from the template must be absolute: " + rendered)
+	}
+
+	return rendered, nil
+}
+
+// relativeRequestPath returns the path and query of the request url relative
+// to the base url, the whole path is returned when the request url is not
+// under the base url
+func relativeRequestPath(baseURL, requestURL string) string {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if baseURL != "" {
+		if rest, ok := strings.CutPrefix(requestURL, baseURL); ok &&
+			(rest == "" || rest[0] == '/' || rest[0] == '?') {
+			return rest
+		}
+	}
+
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return ""
+	}
+
+	if u.RawQuery == "" {
+		return u.EscapedPath()
+	}
+
+	return u.EscapedPath() + "?" + u.RawQuery
+}
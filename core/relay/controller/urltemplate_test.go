@@ -0,0 +1,97 @@
+//nolint:testpackage
+package controller
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/require"
+)
+
+func newURLTemplateMeta(m mode.Mode, templates model.URLTemplates) *meta.Meta {
+	relayMeta := meta.NewMeta(nil, m, "gpt-4o", model.ModelConfig{})
+	relayMeta.ActualModel = "org/gpt 4o"
+	relayMeta.Channel.BaseURL = "https://gateway.example.com/v1/"
+	relayMeta.Channel.URLTemplates = templates
+	relayMeta.ChannelConfigs = model.ChannelConfigs{"api_version": "2025-01-01"}
+
+	return relayMeta
+}
+
+func TestApplyURLTemplate(t *testing.T) {
+	templates := model.URLTemplates{
+		model.URLTemplateAllModes:     "https://gateway.example.com/llm/v1{path}",
+		mode.ChatCompletions.String(): "https://gateway.example.com/openai/v2/{model}/{mode}?api-version={api_version}",
+	}
+
+	tests := []struct {
+		name       string
+		mode       mode.Mode
+		templates  model.URLTemplates
+		requestURL string
+		expected   string
+	}{
+		{
+			name:       "mode template",
+			mode:       mode.ChatCompletions,
+			templates:  templates,
+			requestURL: "https://gateway.example.com/v1/chat/completions",
+			expected:   "https://gateway.example.com/openai/v2/org%2Fgpt%204o/ChatCompletions?api-version=2025-01-01",
+		},
+		{
+			name:       "all modes template keeps the path",
+			mode:       mode.Embeddings,
+			templates:  templates,
+			requestURL: "https://gateway.example.com/v1/embeddings?x=1",
+			expected:   "https://gateway.example.com/llm/v1/embeddings?x=1",
+		},
+		{
+			name:       "no template",
+			mode:       mode.Embeddings,
+			templates:  nil,
+			requestURL: "https://gateway.example.com/v1/embeddings",
+			expected:   "https://gateway.example.com/v1/embeddings",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyURLTemplate(newURLTemplateMeta(tt.mode, tt.templates), tt.requestURL)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, got)
+		})
+	}
+
+	_, err := applyURLTemplate(
+		newURLTemplateMeta(mode.ChatCompletions, model.URLTemplates{"*": "{path}"}),
+		"https://gateway.example.com/v1/chat/completions",
+	)
+	require.Error(t, err)
+}
+
+func TestRelativeRequestPath(t *testing.T) {
+	require.Equal(t, "/chat/completions", relativeRequestPath(
+		"https://api.example.com/v1",
+		"https://api.example.com/v1/chat/completions",
+	))
+	require.Equal(t, "/other/v1/models?limit=1", relativeRequestPath(
+		"https://api.example.com/v1",
+		"https://upstream.example.com/other/v1/models?limit=1",
+	))
+	require.Equal(t, "/v10/models", relativeRequestPath(
+		"https://api.example.com/v1",
+		"https://api.example.com/v10/models",
+	))
+}
+
+func TestURLTemplatesValidate(t *testing.T) {
+	require.NoError(t, model.URLTemplates{
+		"*":               "{base_url}{path}",
+		"ChatCompletions": "{base_url}/deployments/{model}/chat?v={api_version}",
+	}.Validate())
+	require.Error(t, model.URLTemplates{"Chat": "{base_url}"}.Validate())
+	require.Error(t, model.URLTemplates{"*": "{base_url}/{unknown}"}.Validate())
+	require.Error(t, model.URLTemplates{"*": "{base_url"}.Validate())
+}
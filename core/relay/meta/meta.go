@@ -2,10 +2,14 @@ package meta
 
 import (
 	"fmt"
+	"slices"
+	"sync/atomic"
 	"time"
 
+	"github.com/labring/aiproxy/core/common/config"
 	"github.com/labring/aiproxy/core/model"
 	"github.com/labring/aiproxy/core/relay/mode"
+	log "github.com/sirupsen/logrus"
 )
 
 type ChannelMeta struct {
@@ -16,11 +20,16 @@ type ChannelMeta struct {
 	ID                      int
 	Type                    model.ChannelType
 	ModelMapping            map[string]string
+	ParamOverrides          model.ParamOverrides
+	URLTemplates            model.URLTemplates
 	EnabledAutoBalanceCheck bool
 	SkipTLSVerify           bool
 	EnabledNoPermissionBan  bool
 	WarnErrorRate           float64
 	MaxErrorRate            float64
+	BreakerErrorRate        float64
+	BreakerLatencySLOMs     int64
+	MaxConcurrentStreams    int
 }
 
 type Meta struct {
@@ -45,6 +54,7 @@ type Meta struct {
 	RequestUsageContext model.UsageContext
 	RequestServiceTier  string
 	PromptCacheKey      string
+	Seed                *int64
 	User                string
 
 	JobID        string
@@ -53,6 +63,46 @@ type Meta struct {
 	ResponseID   string
 	VideoID      string
 	FileID       string
+
+	// SystemFingerprint is the backend configuration reported by the upstream
+	SystemFingerprint string
+	// ClientAborted is set when the client disconnected before the response
+	// finished, the usage is the partial usage streamed so far
+	ClientAborted bool
+	// UpstreamConnectDuration is the time spent getting the upstream
+	// connection, zero when a pooled connection was reused
+	UpstreamConnectDuration time.Duration
+	// StreamChunks is the number of chunks flushed to the client and
+	// StreamDuration the time from the first to the last written byte, both
+	// are zero for the responses that are not streamed
+	StreamChunks   int64
+	StreamDuration time.Duration
+	// DocumentPages is the number of the pdf pages sent in the request
+	DocumentPages int64
+	// HedgeSurchargeRatio is added to the amount when the request was hedged
+	// and this attempt won the race
+	HedgeSurchargeRatio float64
+	// FeatureFlags are the sorted feature flags on for the request
+	FeatureFlags []string
+
+	// UpstreamStatusCode is the status code of the upstream response, zero
+	// when no response was received
+	UpstreamStatusCode int
+
+	// requestSent is set once the request was written to the upstream, from
+	// the goroutine of the transport
+	requestSent atomic.Bool
+}
+
+// MarkRequestSent records the request was written to the upstream
+func (m *Meta) MarkRequestSent() {
+	m.requestSent.Store(true)
+}
+
+// RequestSent reports whether the request was written to the upstream, the
+// upstream may then have received it even when no response was read
+func (m *Meta) RequestSent() bool {
+	return m.requestSent.Load()
 }
 
 type Option func(meta *Meta)
@@ -153,12 +203,36 @@ func WithPromptCacheKey(promptCacheKey string) Option {
 	}
 }
 
+func WithSeed(seed *int64) Option {
+	return func(meta *Meta) {
+		meta.Seed = seed
+	}
+}
+
 func WithUser(user string) Option {
 	return func(meta *Meta) {
 		meta.User = user
 	}
 }
 
+func WithFeatureFlags(flags []string) Option {
+	return func(meta *Meta) {
+		meta.FeatureFlags = flags
+	}
+}
+
+// FeatureEnabled reports whether the feature flag is on for the request, the
+// defaults are used when the flags of the request are not resolved
+func (m *Meta) FeatureEnabled(flag string) bool {
+	if m.FeatureFlags == nil {
+		return config.GetFeatureFlagDefaults()[flag]
+	}
+
+	_, ok := slices.BinarySearch(m.FeatureFlags, flag)
+
+	return ok
+}
+
 func NewMeta(
 	channel *model.Channel,
 	mode mode.Mode,
@@ -189,11 +263,24 @@ func NewMeta(
 	return &meta
 }
 
+// SetChannel sets the channel the request is sent to, the key and the secret
+// configs are left empty when they can not be decrypted, the channels of the
+// relay cache are checked to be decryptable when loaded
 func (m *Meta) SetChannel(channel *model.Channel) {
+	key, err := channel.GetKey()
+	if err != nil {
+		log.Error(err)
+	}
+
+	configs, err := channel.GetConfigs()
+	if err != nil {
+		log.Error(err)
+	}
+
 	m.Channel.Name = channel.Name
 	m.Channel.BaseURL = channel.BaseURL
 	m.Channel.ProxyURL = channel.ProxyURL
-	m.Channel.Key = channel.Key
+	m.Channel.Key = key
 	m.Channel.ID = channel.ID
 	m.Channel.Type = channel.Type
 	m.Channel.EnabledAutoBalanceCheck = channel.EnabledAutoBalanceCheck
@@ -201,9 +288,14 @@ func (m *Meta) SetChannel(channel *model.Channel) {
 	m.Channel.EnabledNoPermissionBan = channel.EnabledNoPermissionBan
 	m.Channel.WarnErrorRate = channel.WarnErrorRate
 	m.Channel.MaxErrorRate = channel.MaxErrorRate
+	m.Channel.BreakerErrorRate = channel.BreakerErrorRate
+	m.Channel.BreakerLatencySLOMs = channel.BreakerLatencySLOMs
+	m.Channel.MaxConcurrentStreams = channel.MaxConcurrentStreams
 
 	m.Channel.ModelMapping = channel.ModelMapping
-	m.ChannelConfigs = channel.Configs
+	m.Channel.ParamOverrides = channel.ParamOverrides
+	m.Channel.URLTemplates = channel.URLTemplates
+	m.ChannelConfigs = configs
 
 	m.ActualModel, _ = GetMappedModelName(m.OriginModel, channel.ModelMapping)
 }
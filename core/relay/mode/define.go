@@ -52,6 +52,14 @@ var modeNames = map[Mode]string{
 	ResponsesCancel:         "ResponsesCancel",
 	ResponsesInputItems:     "ResponsesInputItems",
 	Gemini:                  "Gemini",
+	AudioGenerations:        "AudioGenerations",
+	AudioGenerationsGet:     "AudioGenerationsGet",
+	GeminiCachedContents:    "GeminiCachedContents",
+	Files:                   "Files",
+	FilesGet:                "FilesGet",
+	FilesContent:            "FilesContent",
+	FilesDelete:             "FilesDelete",
+	GeminiLive:              "GeminiLive",
 }
 
 const (
@@ -94,4 +102,37 @@ const (
 	DoubaoVideo
 	DoubaoVideoTasks
 	DoubaoVideoTasksDelete
+	AudioGenerations
+	AudioGenerationsGet
+	GeminiCachedContents
+	Files
+	FilesGet
+	FilesContent
+	FilesDelete
+	GeminiLive
 )
+
+// Parse returns the mode of the name returned by String
+func Parse(name string) (Mode, bool) {
+	for m, n := range modeNames {
+		if n == name {
+			return m, true
+		}
+	}
+
+	return Unknown, false
+}
+
+// All returns the known modes in order, Unknown excluded
+func All() []Mode {
+	modes := make([]Mode, 0, len(modeNames)-1)
+	for m := Unknown + 1; ; m++ {
+		if _, ok := modeNames[m]; !ok {
+			break
+		}
+
+		modes = append(modes, m)
+	}
+
+	return modes
+}
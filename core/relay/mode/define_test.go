@@ -47,6 +47,14 @@ func TestPersistedModeIDsStayStable(t *testing.T) {
 		mode.DoubaoVideo:             36,
 		mode.DoubaoVideoTasks:        37,
 		mode.DoubaoVideoTasksDelete:  38,
+		mode.AudioGenerations:        39,
+		mode.AudioGenerationsGet:     40,
+		mode.GeminiCachedContents:    41,
+		mode.Files:                   42,
+		mode.FilesGet:                43,
+		mode.FilesContent:            44,
+		mode.FilesDelete:             45,
+		mode.GeminiLive:              46,
 	}
 
 	for relayMode, want := range tests {
@@ -55,3 +63,17 @@ func TestPersistedModeIDsStayStable(t *testing.T) {
 		}
 	}
 }
+
+func TestAllModes(t *testing.T) {
+	modes := mode.All()
+
+	if modes[0] != mode.ChatCompletions || modes[len(modes)-1] != mode.GeminiLive {
+		t.Fatalf("unexpected modes range: %v..%v", modes[0], modes[len(modes)-1])
+	}
+
+	for _, m := range modes {
+		if parsed, ok := mode.Parse(m.String()); !ok || parsed != m {
+			t.Fatalf("mode %d does not round trip its name %q", m, m.String())
+		}
+	}
+}
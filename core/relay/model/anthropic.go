@@ -10,6 +10,8 @@ type AnthropicMessageRequest struct {
 type AnthropicError struct {
 	Type    string `json:"type"`
 	Message string `json:"message"`
+	// Code is the aiproxy error code of the errors raised by the proxy
+	Code string `json:"code,omitempty"`
 }
 
 type AnthropicErrorResponse struct {
@@ -0,0 +1,118 @@
+package model
+
+const AudioGenerationObject = "audio.generation"
+
+type AudioGenerationStatus string
+
+const (
+	AudioGenerationStatusQueued     AudioGenerationStatus = "queued"
+	AudioGenerationStatusInProgress AudioGenerationStatus = "in_progress"
+	AudioGenerationStatusCompleted  AudioGenerationStatus = "completed"
+	AudioGenerationStatusFailed     AudioGenerationStatus = "failed"
+)
+
+// AudioGenerationRequest creates a music generation task,
+// the prompt describes the song, lyrics switches the upstream to custom mode
+type AudioGenerationRequest struct {
+	Model          string   `json:"model"`
+	Prompt         string   `json:"prompt,omitempty"`
+	Lyrics         string   `json:"lyrics,omitempty"`
+	Title          string   `json:"title,omitempty"`
+	Tags           string   `json:"tags,omitempty"`
+	Instrumental   bool     `json:"instrumental,omitempty"`
+	ContinueClipID string   `json:"continue_clip_id,omitempty"`
+	ContinueAt     *float64 `json:"continue_at,omitempty"`
+}
+
+type AudioGeneration struct {
+	ID        string                `json:"id"`
+	Object    string                `json:"object"`
+	CreatedAt int64                 `json:"created_at,omitempty"`
+	Status    AudioGenerationStatus `json:"status"`
+	Progress  int                   `json:"progress,omitempty"`
+	Model     string                `json:"model,omitempty"`
+	Clips     []AudioGenerationClip `json:"clips,omitempty"`
+	Error     *AudioGenerationError `json:"error,omitempty"`
+}
+
+type AudioGenerationClip struct {
+	ID       string  `json:"id"`
+	Title    string  `json:"title,omitempty"`
+	Tags     string  `json:"tags,omitempty"`
+	Lyrics   string  `json:"lyrics,omitempty"`
+	AudioURL string  `json:"audio_url,omitempty"`
+	VideoURL string  `json:"video_url,omitempty"`
+	ImageURL string  `json:"image_url,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+}
+
+type AudioGenerationError struct {
+	Message string `json:"message"`
+}
+
+// https://github.com/Suno-API/Suno-API
+
+const (
+	SunoTaskStatusNotStart   = "NOT_START"
+	SunoTaskStatusSubmitted  = "SUBMITTED"
+	SunoTaskStatusQueued     = "QUEUED"
+	SunoTaskStatusInProgress = "IN_PROGRESS"
+	SunoTaskStatusSuccess    = "SUCCESS"
+	SunoTaskStatusFailure    = "FAILURE"
+)
+
+const SunoResponseCodeSuccess = "success"
+
+type SunoSubmitRequest struct {
+	Prompt               string   `json:"prompt,omitempty"`
+	Mv                   string   `json:"mv,omitempty"`
+	Title                string   `json:"title,omitempty"`
+	Tags                 string   `json:"tags,omitempty"`
+	ContinueAt           *float64 `json:"continue_at,omitempty"`
+	ContinueClipID       string   `json:"continue_clip_id,omitempty"`
+	GptDescriptionPrompt string   `json:"gpt_description_prompt,omitempty"`
+	MakeInstrumental     bool     `json:"make_instrumental,omitempty"`
+}
+
+type SunoSubmitResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+	// Data is the task id
+	Data string `json:"data"`
+}
+
+type SunoFetchResponse struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message,omitempty"`
+	Data    SunoTaskData `json:"data"`
+}
+
+type SunoTaskData struct {
+	TaskID     string `json:"task_id"`
+	Action     string `json:"action,omitempty"`
+	Status     string `json:"status"`
+	FailReason string `json:"fail_reason,omitempty"`
+	SubmitTime int64  `json:"submit_time,omitempty"`
+	StartTime  int64  `json:"start_time,omitempty"`
+	FinishTime int64  `json:"finish_time,omitempty"`
+	// Progress is a percentage string, e.g. "50%"
+	Progress string     `json:"progress,omitempty"`
+	Data     []SunoClip `json:"data,omitempty"`
+}
+
+type SunoClip struct {
+	ID        string           `json:"id"`
+	Title     string           `json:"title,omitempty"`
+	Status    string           `json:"status,omitempty"`
+	AudioURL  string           `json:"audio_url,omitempty"`
+	VideoURL  string           `json:"video_url,omitempty"`
+	ImageURL  string           `json:"image_url,omitempty"`
+	ModelName string           `json:"model_name,omitempty"`
+	Metadata  SunoClipMetadata `json:"metadata"`
+}
+
+type SunoClipMetadata struct {
+	Tags     string  `json:"tags,omitempty"`
+	Prompt   string  `json:"prompt,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+}
@@ -5,18 +5,23 @@ import (
 )
 
 type ClaudeOpenAIRequest struct {
-	ToolChoice      any                    `json:"tool_choice,omitempty"`
-	Stop            any                    `json:"stop,omitempty"`
-	Temperature     *float64               `json:"temperature,omitempty"`
-	TopP            *float64               `json:"top_p,omitempty"`
-	ReasoningEffort *string                `json:"reasoning_effort,omitempty"`
-	Model           string                 `json:"model,omitempty"`
-	Messages        []*ClaudeOpenaiMessage `json:"messages,omitempty"`
-	Tools           []*ClaudeOpenaiTool    `json:"tools,omitempty"`
-	Seed            float64                `json:"seed,omitempty"`
-	MaxTokens       int                    `json:"max_tokens,omitempty"`
-	TopK            int                    `json:"top_k,omitempty"`
-	Stream          bool                   `json:"stream,omitempty"`
+	ToolChoice       any                    `json:"tool_choice,omitempty"`
+	Stop             any                    `json:"stop,omitempty"`
+	Temperature      *float64               `json:"temperature,omitempty"`
+	TopP             *float64               `json:"top_p,omitempty"`
+	ReasoningEffort  *string                `json:"reasoning_effort,omitempty"`
+	WebSearchOptions *WebSearchOptions      `json:"web_search_options,omitempty"`
+	Model            string                 `json:"model,omitempty"`
+	Messages         []*ClaudeOpenaiMessage `json:"messages,omitempty"`
+	Tools            []*ClaudeOpenaiTool    `json:"tools,omitempty"`
+	Seed             float64                `json:"seed,omitempty"`
+	MaxTokens        int                    `json:"max_tokens,omitempty"`
+	TopK             int                    `json:"top_k,omitempty"`
+	Stream           bool                   `json:"stream,omitempty"`
+	// Logprobs and TopLogprobs are only read to warn, claude returns no log
+	// probabilities
+	Logprobs    *bool `json:"logprobs,omitempty"`
+	TopLogprobs *int  `json:"top_logprobs,omitempty"`
 }
 
 type ClaudeOpenaiMessage struct {
@@ -50,6 +55,8 @@ type ClaudeImageSource struct {
 	MediaType string `json:"media_type,omitempty"`
 	Data      string `json:"data,omitempty"`
 	URL       string `json:"url,omitempty"`
+	FileID    string `json:"file_id,omitempty"`
+	Content   any    `json:"content,omitempty"`
 }
 
 type ClaudeContent struct {
@@ -57,6 +64,8 @@ type ClaudeContent struct {
 	Text         string              `json:"text,omitempty"`
 	Thinking     string              `json:"thinking,omitempty"`
 	Source       *ClaudeImageSource  `json:"source,omitempty"`
+	Title        string              `json:"title,omitempty"`
+	Context      string              `json:"context,omitempty"`
 	ID           string              `json:"id,omitempty"`
 	Name         string              `json:"name,omitempty"`
 	Input        any                 `json:"input,omitempty"`
@@ -64,6 +73,38 @@ type ClaudeContent struct {
 	ToolUseID    string              `json:"tool_use_id,omitempty"`
 	CacheControl *ClaudeCacheControl `json:"cache_control,omitempty"`
 	Signature    string              `json:"signature,omitempty"`
+	// Data is the encrypted thinking of the redacted_thinking blocks
+	Data      string           `json:"data,omitempty"`
+	Citations []ClaudeCitation `json:"citations,omitempty"`
+}
+
+// https://docs.anthropic.com/en/docs/agents-and-tools/tool-use/web-search-tool#citations
+type ClaudeCitation struct {
+	Type           string `json:"type"`
+	URL            string `json:"url,omitempty"`
+	Title          string `json:"title,omitempty"`
+	CitedText      string `json:"cited_text,omitempty"`
+	EncryptedIndex string `json:"encrypted_index,omitempty"`
+}
+
+const ClaudeCitationTypeWebSearchResultLocation = "web_search_result_location"
+
+// ToAnnotation converts a web search citation to an OpenAI url_citation annotation,
+// startIndex and endIndex are the rune offsets of the cited text in the message content
+func (c *ClaudeCitation) ToAnnotation(startIndex, endIndex int) (Annotation, bool) {
+	if c.Type != ClaudeCitationTypeWebSearchResultLocation || c.URL == "" {
+		return Annotation{}, false
+	}
+
+	return Annotation{
+		Type: AnnotationTypeURLCitation,
+		URLCitation: &URLCitation{
+			URL:        c.URL,
+			Title:      c.Title,
+			StartIndex: startIndex,
+			EndIndex:   endIndex,
+		},
+	}, true
 }
 
 type ClaudeAnyContentMessage struct {
@@ -279,13 +320,14 @@ type ClaudeResponse struct {
 }
 
 type ClaudeDelta struct {
-	StopReason   *string `json:"stop_reason,omitempty"`
-	StopSequence *string `json:"stop_sequence,omitempty"`
-	Type         string  `json:"type,omitempty"`
-	Thinking     string  `json:"thinking,omitempty"`
-	Signature    string  `json:"signature,omitempty"`
-	Text         string  `json:"text,omitempty"`
-	PartialJSON  string  `json:"partial_json,omitempty"`
+	StopReason   *string         `json:"stop_reason,omitempty"`
+	StopSequence *string         `json:"stop_sequence,omitempty"`
+	Type         string          `json:"type,omitempty"`
+	Thinking     string          `json:"thinking,omitempty"`
+	Signature    string          `json:"signature,omitempty"`
+	Text         string          `json:"text,omitempty"`
+	PartialJSON  string          `json:"partial_json,omitempty"`
+	Citation     *ClaudeCitation `json:"citation,omitempty"`
 }
 
 type ClaudeStreamResponse struct {
@@ -317,6 +359,24 @@ const (
 	ClaudeContentTypeToolUse    = "tool_use"
 	ClaudeContentTypeToolResult = "tool_result"
 	ClaudeContentTypeImage      = "image"
+	ClaudeContentTypeDocument   = "document"
+
+	ClaudeContentTypeRedactedThinking = "redacted_thinking"
+
+	ClaudeContentTypeServerToolUse           = "server_tool_use"
+	ClaudeContentTypeWebSearchToolResult     = "web_search_tool_result"
+	ClaudeContentTypeCodeExecutionToolResult = "code_execution_tool_result"
+)
+
+// Claude server tool constants
+const (
+	ClaudeToolTypeWebSearch     = "web_search_20250305"
+	ClaudeToolNameWebSearch     = "web_search"
+	ClaudeToolTypeCodeExecution = "code_execution_20250522"
+	ClaudeToolNameCodeExecution = "code_execution"
+
+	ClaudeBetaCodeExecution = "code-execution-2025-05-22"
+	ClaudeBetaFilesAPI      = "files-api-2025-04-14"
 )
 
 // Claude Stream Event Type constants
@@ -335,10 +395,15 @@ const (
 	ClaudeDeltaTypeTextDelta      = "text_delta"
 	ClaudeDeltaTypeThinkingDelta  = "thinking_delta"
 	ClaudeDeltaTypeInputJSONDelta = "input_json_delta"
+	ClaudeDeltaTypeSignatureDelta = "signature_delta"
 )
 
 // Claude Image Source Type constants
 const (
 	ClaudeImageSourceTypeBase64 = "base64"
 	ClaudeImageSourceTypeURL    = "url"
+	// the document sources
+	ClaudeImageSourceTypeText    = "text"
+	ClaudeImageSourceTypeContent = "content"
+	ClaudeImageSourceTypeFile    = "file"
 )
@@ -29,7 +29,8 @@ type OutputAudio struct {
 }
 
 type StreamOptions struct {
-	IncludeUsage bool `json:"include_usage,omitempty"`
+	IncludeUsage       bool  `json:"include_usage,omitempty"`
+	IncludeObfuscation *bool `json:"include_obfuscation,omitempty"`
 }
 
 type GeneralOpenAIRequest struct {
@@ -102,10 +103,11 @@ type GeneralOpenAIThinkingRequest struct {
 }
 
 type ChatCompletionsStreamResponseChoice struct {
-	FinishReason FinishReason `json:"finish_reason,omitempty"`
-	Delta        Message      `json:"delta"`
-	Index        int          `json:"index"`
-	Text         string       `json:"text,omitempty"`
+	FinishReason FinishReason    `json:"finish_reason,omitempty"`
+	Delta        Message         `json:"delta"`
+	Logprobs     *ChoiceLogprobs `json:"logprobs,omitempty"`
+	Index        int             `json:"index"`
+	Text         string          `json:"text,omitempty"`
 }
 
 type ChatCompletionsStreamResponse struct {
@@ -118,10 +120,31 @@ type ChatCompletionsStreamResponse struct {
 }
 
 type TextResponseChoice struct {
-	FinishReason FinishReason `json:"finish_reason"`
-	Message      Message      `json:"message"`
-	Index        int          `json:"index"`
-	Text         string       `json:"text,omitempty"`
+	FinishReason FinishReason    `json:"finish_reason"`
+	Message      Message         `json:"message"`
+	Logprobs     *ChoiceLogprobs `json:"logprobs,omitempty"`
+	Index        int             `json:"index"`
+	Text         string          `json:"text,omitempty"`
+}
+
+// ChoiceLogprobs is the log probabilities of the tokens of a choice
+type ChoiceLogprobs struct {
+	Content []TokenLogprob `json:"content"`
+}
+
+// TokenLogprob is the log probability of an output token, the responses use
+// the same structure in the logprobs of the output text
+type TokenLogprob struct {
+	Token       string       `json:"token"`
+	Logprob     float64      `json:"logprob"`
+	Bytes       []int        `json:"bytes"`
+	TopLogprobs []TopLogprob `json:"top_logprobs"`
+}
+
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes"`
 }
 
 type TextResponse struct {
@@ -142,6 +165,11 @@ type Message struct {
 	Role             string       `json:"role,omitempty"`
 	ToolCallID       string       `json:"tool_call_id,omitempty"`
 	ToolCalls        []ToolCall   `json:"tool_calls,omitempty"`
+	Annotations      []Annotation `json:"annotations,omitempty"`
+	// ThinkingBlocks are the claude thinking and redacted_thinking blocks of
+	// the assistant message, kept when reasoning_content and signature can not
+	// hold them, e.g. the redacted or the interleaved thinking
+	ThinkingBlocks []ClaudeContent `json:"thinking_blocks,omitempty"`
 }
 
 func (m *Message) IsStringContent() bool {
@@ -190,6 +218,25 @@ func (m *Message) StringContent() string {
 	return strBuilder.String()
 }
 
+// ClaudeThinkingBlocks returns the thinking blocks of the assistant message,
+// a single block is rebuilt from reasoning_content and signature when the
+// blocks are not kept
+func (m *Message) ClaudeThinkingBlocks() []ClaudeContent {
+	if len(m.ThinkingBlocks) > 0 {
+		return m.ThinkingBlocks
+	}
+
+	if m.ReasoningContent == "" {
+		return nil
+	}
+
+	return []ClaudeContent{{
+		Type:      ClaudeContentTypeThinking,
+		Thinking:  m.ReasoningContent,
+		Signature: m.Signature,
+	}}
+}
+
 func (m *Message) ParseContent() []MessageContent {
 	var contentList []MessageContent
 
@@ -223,10 +270,13 @@ func (m *Message) ParseContent() []MessageContent {
 						continue
 					}
 
+					detail, _ := subObj["detail"].(string)
+
 					contentList = append(contentList, MessageContent{
 						Type: ContentTypeImageURL,
 						ImageURL: &ImageURL{
-							URL: url,
+							URL:    url,
+							Detail: detail,
 						},
 					})
 				}
@@ -259,6 +309,21 @@ func (m *Message) ParseContent() []MessageContent {
 						},
 					})
 				}
+			case ContentTypeFile:
+				if subObj, ok := contentMap["file"].(map[string]any); ok {
+					fileID, _ := subObj["file_id"].(string)
+					filename, _ := subObj["filename"].(string)
+					fileData, _ := subObj["file_data"].(string)
+
+					contentList = append(contentList, MessageContent{
+						Type: ContentTypeFile,
+						File: &MessageFile{
+							FileID:   fileID,
+							Filename: filename,
+							FileData: fileData,
+						},
+					})
+				}
 			}
 		}
 
@@ -280,7 +345,8 @@ func (m *Message) ParseContent() []MessageContent {
 				contentList = append(contentList, MessageContent{
 					Type: ContentTypeImageURL,
 					ImageURL: &ImageURL{
-						URL: imageURL.URL,
+						URL:    imageURL.URL,
+						Detail: imageURL.Detail,
 					},
 				})
 			case ContentTypeInputAudio:
@@ -301,6 +367,15 @@ func (m *Message) ParseContent() []MessageContent {
 					Type:     ContentTypeVideoURL,
 					VideoURL: contentItem.VideoURL,
 				})
+			case ContentTypeFile:
+				if contentItem.File == nil {
+					continue
+				}
+
+				contentList = append(contentList, MessageContent{
+					Type: ContentTypeFile,
+					File: contentItem.File,
+				})
 			}
 		}
 
@@ -325,10 +400,18 @@ type VideoURL struct {
 	URL string `json:"url,omitempty"`
 }
 
+// MessageFile is a file input, the file data is a base64 data url
+type MessageFile struct {
+	FileID   string `json:"file_id,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+}
+
 type MessageContent struct {
-	ImageURL   *ImageURL   `json:"image_url,omitempty"`
-	InputAudio *InputAudio `json:"input_audio,omitempty"`
-	VideoURL   *VideoURL   `json:"video_url,omitempty"`
-	Type       string      `json:"type,omitempty"`
-	Text       string      `json:"text,omitempty"`
+	ImageURL   *ImageURL    `json:"image_url,omitempty"`
+	InputAudio *InputAudio  `json:"input_audio,omitempty"`
+	VideoURL   *VideoURL    `json:"video_url,omitempty"`
+	File       *MessageFile `json:"file,omitempty"`
+	Type       string       `json:"type,omitempty"`
+	Text       string       `json:"text,omitempty"`
 }
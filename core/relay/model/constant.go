@@ -14,6 +14,7 @@ const (
 	ContentTypeImageURL   = "image_url"
 	ContentTypeInputAudio = "input_audio"
 	ContentTypeVideoURL   = "video_url"
+	ContentTypeFile       = "file"
 )
 
 const (
@@ -81,6 +81,8 @@ func WrapperErrorWithMessage(
 		})
 	case mode.Gemini,
 		mode.GeminiFiles,
+		mode.GeminiCachedContents,
+		mode.GeminiLive,
 		mode.GeminiVideo,
 		mode.GeminiVideoOperations:
 		return NewGeminiError(statusCode, GeminiError{
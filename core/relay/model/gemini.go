@@ -17,6 +17,7 @@ type GeminiChatRequest struct {
 	GenerationConfig  *GeminiChatGenerationConfig `json:"generationConfig,omitempty"`
 	Tools             []GeminiChatTools           `json:"tools,omitempty"`
 	ToolConfig        *GeminiToolConfig           `json:"toolConfig,omitempty"`
+	CachedContent     string                      `json:"cachedContent,omitempty"`
 }
 
 type GeminiChatContent struct {
@@ -63,6 +64,47 @@ type GeminiChatSafetySettings struct {
 
 type GeminiChatTools struct {
 	FunctionDeclarations any `json:"functionDeclarations,omitempty"`
+	// Google Search grounding tools, only supported by gemini backends
+	// https://ai.google.dev/gemini-api/docs/google-search
+	GoogleSearch               any `json:"googleSearch,omitempty"`
+	GoogleSearchSnake          any `json:"google_search,omitempty"`
+	GoogleSearchRetrieval      any `json:"googleSearchRetrieval,omitempty"`
+	GoogleSearchRetrievalSnake any `json:"google_search_retrieval,omitempty"`
+}
+
+// IsGrounding reports whether the tool enables Google Search grounding
+func (t *GeminiChatTools) IsGrounding() bool {
+	return t.GoogleSearch != nil ||
+		t.GoogleSearchSnake != nil ||
+		t.GoogleSearchRetrieval != nil ||
+		t.GoogleSearchRetrievalSnake != nil
+}
+
+// RemoveGroundingTools removes the Google Search grounding tools,
+// tools that also declare functions are kept with the grounding fields cleared.
+// It returns the number of grounding tools removed.
+func (r *GeminiChatRequest) RemoveGroundingTools() int {
+	removed := 0
+	tools := r.Tools[:0]
+
+	for _, tool := range r.Tools {
+		if !tool.IsGrounding() {
+			tools = append(tools, tool)
+			continue
+		}
+
+		removed++
+
+		if tool.FunctionDeclarations != nil {
+			tools = append(tools, GeminiChatTools{
+				FunctionDeclarations: tool.FunctionDeclarations,
+			})
+		}
+	}
+
+	r.Tools = tools
+
+	return removed
 }
 
 type GeminiChatGenerationConfig struct {
@@ -74,10 +116,14 @@ type GeminiChatGenerationConfig struct {
 	TopK               float64               `json:"topK,omitempty"`
 	MaxOutputTokens    *int                  `json:"maxOutputTokens,omitempty"`
 	CandidateCount     int                   `json:"candidateCount,omitempty"`
+	Seed               *int64                `json:"seed,omitempty"`
 	ResponseModalities []string              `json:"responseModalities,omitempty"`
 	ThinkingConfig     *GeminiThinkingConfig `json:"thinkingConfig,omitempty"`
 	ImageConfig        *GeminiImageConfig    `json:"imageConfig,omitempty"`
 	SpeechConfig       *GeminiSpeechConfig   `json:"speechConfig,omitempty"`
+	ResponseLogprobs   bool                  `json:"responseLogprobs,omitempty"`
+	Logprobs           *int                  `json:"logprobs,omitempty"`
+	MediaResolution    string                `json:"mediaResolution,omitempty"`
 }
 
 type GeminiImageConfig struct {
@@ -126,8 +172,7 @@ func (r *GeminiChatResponse) GetWebSearchCount() int64 {
 	}
 
 	for _, candidate := range r.Candidates {
-		if candidate.GroundingMetadata != nil &&
-			len(candidate.GroundingMetadata.WebSearchQueries) > 0 {
+		if candidate != nil && candidate.GroundingMetadata.IsGrounded() {
 			return 1
 		}
 	}
@@ -182,6 +227,37 @@ type GeminiUsageMetadata struct {
 	ToolUsePromptTokensDetails []GeminiTokensDetail `json:"toolUsePromptTokensDetails,omitempty"`
 }
 
+// GeminiLiveUsageMetadata is the usage metadata sent during a live session,
+// the output tokens are named response tokens
+type GeminiLiveUsageMetadata struct {
+	PromptTokenCount           int64                `json:"promptTokenCount"`
+	CachedContentTokenCount    int64                `json:"cachedContentTokenCount,omitempty"`
+	ResponseTokenCount         int64                `json:"responseTokenCount"`
+	ToolUsePromptTokenCount    int64                `json:"toolUsePromptTokenCount,omitempty"`
+	ThoughtsTokenCount         int64                `json:"thoughtsTokenCount,omitempty"`
+	TotalTokenCount            int64                `json:"totalTokenCount"`
+	PromptTokensDetails        []GeminiTokensDetail `json:"promptTokensDetails,omitempty"`
+	CacheTokensDetails         []GeminiTokensDetail `json:"cacheTokensDetails,omitempty"`
+	ResponseTokensDetails      []GeminiTokensDetail `json:"responseTokensDetails,omitempty"`
+	ToolUsePromptTokensDetails []GeminiTokensDetail `json:"toolUsePromptTokensDetails,omitempty"`
+}
+
+// ToUsageMetadata converts the live usage metadata to the generate content one
+func (u *GeminiLiveUsageMetadata) ToUsageMetadata() GeminiUsageMetadata {
+	return GeminiUsageMetadata{
+		PromptTokenCount:           u.PromptTokenCount,
+		CandidatesTokenCount:       u.ResponseTokenCount,
+		TotalTokenCount:            u.TotalTokenCount,
+		ThoughtsTokenCount:         u.ThoughtsTokenCount,
+		PromptTokensDetails:        u.PromptTokensDetails,
+		CandidatesTokensDetails:    u.ResponseTokensDetails,
+		CachedContentTokenCount:    u.CachedContentTokenCount,
+		CacheTokensDetails:         u.CacheTokensDetails,
+		ToolUsePromptTokenCount:    u.ToolUsePromptTokenCount,
+		ToolUsePromptTokensDetails: u.ToolUsePromptTokensDetails,
+	}
+}
+
 type GeminiTokensDetail struct {
 	Modality   string `json:"modality"`
 	TokenCount int64  `json:"tokenCount"`
@@ -196,10 +272,88 @@ type GeminiChatCandidate struct {
 	} `json:"safetyRatings,omitempty"`
 	Index             int64                    `json:"index"`
 	GroundingMetadata *GeminiGroundingMetadata `json:"groundingMetadata,omitempty"`
+	LogprobsResult    *GeminiLogprobsResult    `json:"logprobsResult,omitempty"`
+}
+
+// GeminiLogprobsResult is the log probabilities of the candidate tokens, the
+// top candidates of a step are at the same index as its chosen candidate
+type GeminiLogprobsResult struct {
+	TopCandidates    []GeminiLogprobsTopCandidates `json:"topCandidates,omitempty"`
+	ChosenCandidates []GeminiLogprobsCandidate     `json:"chosenCandidates,omitempty"`
+}
+
+type GeminiLogprobsTopCandidates struct {
+	Candidates []GeminiLogprobsCandidate `json:"candidates,omitempty"`
+}
+
+type GeminiLogprobsCandidate struct {
+	Token          string  `json:"token"`
+	TokenID        int64   `json:"tokenId"`
+	LogProbability float64 `json:"logProbability"`
+}
+
+// ToOpenAI converts the log probabilities to the openai choice logprobs, the
+// gemini tokens carry no bytes
+func (r *GeminiLogprobsResult) ToOpenAI() *ChoiceLogprobs {
+	if r == nil || len(r.ChosenCandidates) == 0 {
+		return nil
+	}
+
+	content := make([]TokenLogprob, 0, len(r.ChosenCandidates))
+	for i, chosen := range r.ChosenCandidates {
+		tokenLogprob := TokenLogprob{
+			Token:       chosen.Token,
+			Logprob:     chosen.LogProbability,
+			TopLogprobs: []TopLogprob{},
+		}
+
+		if i < len(r.TopCandidates) {
+			for _, candidate := range r.TopCandidates[i].Candidates {
+				tokenLogprob.TopLogprobs = append(tokenLogprob.TopLogprobs, TopLogprob{
+					Token:   candidate.Token,
+					Logprob: candidate.LogProbability,
+				})
+			}
+		}
+
+		content = append(content, tokenLogprob)
+	}
+
+	return &ChoiceLogprobs{Content: content}
 }
 
 type GeminiGroundingMetadata struct {
-	WebSearchQueries []string `json:"webSearchQueries,omitempty"`
+	WebSearchQueries []string               `json:"webSearchQueries,omitempty"`
+	GroundingChunks  []GeminiGroundingChunk `json:"groundingChunks,omitempty"`
+}
+
+type GeminiGroundingChunk struct {
+	Web *GeminiGroundingChunkWeb `json:"web,omitempty"`
+}
+
+type GeminiGroundingChunkWeb struct {
+	URI   string `json:"uri,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+// IsGrounded reports whether the candidate was grounded with Google Search,
+// googleSearchRetrieval responses may only carry grounding chunks without queries
+func (m *GeminiGroundingMetadata) IsGrounded() bool {
+	if m == nil {
+		return false
+	}
+
+	if len(m.WebSearchQueries) > 0 {
+		return true
+	}
+
+	for _, chunk := range m.GroundingChunks {
+		if chunk.Web != nil {
+			return true
+		}
+	}
+
+	return false
 }
 
 type GeminiChatPromptFeedback struct {
@@ -217,6 +371,13 @@ const (
 	GeminiModalityVideo = "VIDEO"
 )
 
+// Gemini media resolution constants
+const (
+	GeminiMediaResolutionLow    = "MEDIA_RESOLUTION_LOW"
+	GeminiMediaResolutionMedium = "MEDIA_RESOLUTION_MEDIUM"
+	GeminiMediaResolutionHigh   = "MEDIA_RESOLUTION_HIGH"
+)
+
 // GetImageInputTokens returns the number of image input tokens from PromptTokensDetails
 func (u *GeminiUsageMetadata) GetImageInputTokens() int64 {
 	for _, detail := range u.PromptTokensDetails {
@@ -369,11 +530,21 @@ func (u *GeminiUsageMetadata) ToClaudeUsage() ClaudeUsage {
 }
 
 type GeminiError struct {
-	Message string `json:"message,omitempty"`
-	Status  string `json:"status,omitempty"`
-	Code    int    `json:"code,omitempty"`
+	Message string              `json:"message,omitempty"`
+	Status  string              `json:"status,omitempty"`
+	Code    int                 `json:"code,omitempty"`
+	Details []GeminiErrorDetail `json:"details,omitempty"`
 }
 
+// GeminiErrorDetail is the google.rpc.ErrorInfo detail of the error
+type GeminiErrorDetail struct {
+	Type   string `json:"@type"`
+	Reason string `json:"reason,omitempty"`
+	Domain string `json:"domain,omitempty"`
+}
+
+const GeminiErrorInfoType = "type.googleapis.com/google.rpc.ErrorInfo"
+
 type GeminiErrorResponse struct {
 	Error GeminiError `json:"error,omitempty"`
 }
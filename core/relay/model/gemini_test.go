@@ -40,3 +40,94 @@ func TestGeminiChatResponseWebSearchCountUsesSearchQueriesForGemini3(t *testing.
 		t.Fatalf("expected two search queries, got %d", got)
 	}
 }
+
+func TestGeminiChatResponseWebSearchCountUsesGroundingChunks(t *testing.T) {
+	t.Parallel()
+
+	response := GeminiChatResponse{
+		ModelVersion: "gemini-1.5-pro",
+		Candidates: []*GeminiChatCandidate{
+			{
+				GroundingMetadata: &GeminiGroundingMetadata{
+					GroundingChunks: []GeminiGroundingChunk{
+						{Web: &GeminiGroundingChunkWeb{URI: "https://example.com"}},
+					},
+				},
+			},
+		},
+	}
+
+	if got := response.GetWebSearchCount(); got != 1 {
+		t.Fatalf("expected one grounded prompt, got %d", got)
+	}
+}
+
+func TestGeminiChatRequestRemoveGroundingTools(t *testing.T) {
+	t.Parallel()
+
+	functions := []any{map[string]any{"name": "get_weather"}}
+	request := GeminiChatRequest{
+		Tools: []GeminiChatTools{
+			{GoogleSearch: map[string]any{}},
+			{GoogleSearchRetrievalSnake: map[string]any{}},
+			{FunctionDeclarations: functions},
+			{FunctionDeclarations: functions, GoogleSearchSnake: map[string]any{}},
+		},
+	}
+
+	if removed := request.RemoveGroundingTools(); removed != 3 {
+		t.Fatalf("expected three grounding tools removed, got %d", removed)
+	}
+
+	if len(request.Tools) != 2 {
+		t.Fatalf("expected two function tools kept, got %d", len(request.Tools))
+	}
+
+	for _, tool := range request.Tools {
+		if tool.IsGrounding() || tool.FunctionDeclarations == nil {
+			t.Fatalf("unexpected tool kept: %+v", tool)
+		}
+	}
+}
+
+func TestGeminiLogprobsResultToOpenAI(t *testing.T) {
+	t.Parallel()
+
+	result := &GeminiLogprobsResult{
+		ChosenCandidates: []GeminiLogprobsCandidate{
+			{Token: "Hello", LogProbability: -0.1},
+			{Token: "!", LogProbability: -0.5},
+		},
+		TopCandidates: []GeminiLogprobsTopCandidates{
+			{
+				Candidates: []GeminiLogprobsCandidate{
+					{Token: "Hello", LogProbability: -0.1},
+					{Token: "Hi", LogProbability: -2.3},
+				},
+			},
+		},
+	}
+
+	logprobs := result.ToOpenAI()
+	if logprobs == nil || len(logprobs.Content) != 2 {
+		t.Fatalf("expected 2 token logprobs, got %+v", logprobs)
+	}
+
+	if logprobs.Content[0].Token != "Hello" || logprobs.Content[0].Logprob != -0.1 {
+		t.Fatalf("unexpected first token logprob: %+v", logprobs.Content[0])
+	}
+
+	if len(logprobs.Content[0].TopLogprobs) != 2 ||
+		logprobs.Content[0].TopLogprobs[1].Token != "Hi" {
+		t.Fatalf("unexpected top logprobs: %+v", logprobs.Content[0].TopLogprobs)
+	}
+
+	if len(logprobs.Content[1].TopLogprobs) != 0 {
+		t.Fatalf("expected no top logprobs, got %+v", logprobs.Content[1].TopLogprobs)
+	}
+
+	var empty *GeminiLogprobsResult
+	if empty.ToOpenAI() != nil {
+		t.Fatal("expected nil logprobs for nil result")
+	}
+}
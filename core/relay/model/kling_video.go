@@ -0,0 +1,57 @@
+package model
+
+// https://app.klingai.com/global/dev/document-api/apiReference/model/textToVideo
+
+const (
+	KlingVideoEndpointText2Video  = "text2video"
+	KlingVideoEndpointImage2Video = "image2video"
+)
+
+const (
+	KlingTaskStatusSubmitted  = "submitted"
+	KlingTaskStatusProcessing = "processing"
+	KlingTaskStatusSucceed    = "succeed"
+	KlingTaskStatusFailed     = "failed"
+)
+
+type KlingVideoRequest struct {
+	ModelName      string   `json:"model_name,omitempty"`
+	Prompt         string   `json:"prompt,omitempty"`
+	NegativePrompt string   `json:"negative_prompt,omitempty"`
+	CfgScale       *float64 `json:"cfg_scale,omitempty"`
+	Mode           string   `json:"mode,omitempty"`
+	AspectRatio    string   `json:"aspect_ratio,omitempty"`
+	Duration       string   `json:"duration,omitempty"`
+	Image          string   `json:"image,omitempty"`
+	ImageTail      string   `json:"image_tail,omitempty"`
+	CallbackURL    string   `json:"callback_url,omitempty"`
+	ExternalTaskID string   `json:"external_task_id,omitempty"`
+}
+
+type KlingTaskResponse struct {
+	Code      int           `json:"code"`
+	Message   string        `json:"message,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+	Data      KlingTaskData `json:"data"`
+}
+
+type KlingTaskData struct {
+	TaskID        string          `json:"task_id"`
+	TaskStatus    string          `json:"task_status,omitempty"`
+	TaskStatusMsg string          `json:"task_status_msg,omitempty"`
+	TaskResult    KlingTaskResult `json:"task_result"`
+	// CreatedAt and UpdatedAt are unix milliseconds
+	CreatedAt int64 `json:"created_at,omitempty"`
+	UpdatedAt int64 `json:"updated_at,omitempty"`
+}
+
+type KlingTaskResult struct {
+	Videos []KlingVideoResult `json:"videos,omitempty"`
+}
+
+type KlingVideoResult struct {
+	ID  string `json:"id,omitempty"`
+	URL string `json:"url,omitempty"`
+	// Duration is the generated video duration in seconds, e.g. "5.1"
+	Duration string `json:"duration,omitempty"`
+}
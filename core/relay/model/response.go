@@ -204,9 +204,10 @@ type ResponseText struct {
 
 // OutputContent represents content in an output item
 type OutputContent struct {
-	Type        string `json:"type"`
-	Text        string `json:"text,omitempty"`
-	Annotations []any  `json:"annotations,omitempty"`
+	Type        string         `json:"type"`
+	Text        string         `json:"text,omitempty"`
+	Annotations []any          `json:"annotations,omitempty"`
+	Logprobs    []TokenLogprob `json:"logprobs,omitempty"`
 }
 
 // OutputItem represents an output item in a response
@@ -230,6 +231,9 @@ type InputContent struct {
 	ImageURL string `json:"image_url,omitempty"`
 	FileID   string `json:"file_id,omitempty"`
 	Detail   string `json:"detail,omitempty"`
+	// Fields for input_file type
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"`
 	// Fields for function_call type
 	ID        string `json:"id,omitempty"`
 	Name      string `json:"name,omitempty"`
@@ -326,32 +330,38 @@ type Response struct {
 
 // CreateResponseRequest represents a request to create a response
 type CreateResponseRequest struct {
-	Model                string             `json:"model"`
-	Input                any                `json:"input"`
-	Background           *bool              `json:"background,omitempty"`
-	Conversation         any                `json:"conversation,omitempty"` // string or object
-	Include              []string           `json:"include,omitempty"`
-	Instructions         *string            `json:"instructions,omitempty"`
-	MaxOutputTokens      *int               `json:"max_output_tokens,omitempty"`
-	MaxToolCalls         *int               `json:"max_tool_calls,omitempty"`
-	Metadata             map[string]any     `json:"metadata,omitempty"`
-	ParallelToolCalls    *bool              `json:"parallel_tool_calls,omitempty"`
-	PreviousResponseID   *string            `json:"previous_response_id,omitempty"`
-	PromptCacheKey       *string            `json:"prompt_cache_key,omitempty"`
-	PromptCacheRetention *string            `json:"prompt_cache_retention,omitempty"`
-	Reasoning            *ResponseReasoning `json:"reasoning,omitempty"`
-	SafetyIdentifier     *string            `json:"safety_identifier,omitempty"`
-	ServiceTier          *string            `json:"service_tier,omitempty"`
-	Store                *bool              `json:"store,omitempty"`
-	Stream               bool               `json:"stream,omitempty"`
-	Temperature          *float64           `json:"temperature,omitempty"`
-	Text                 *ResponseText      `json:"text,omitempty"`
-	ToolChoice           any                `json:"tool_choice,omitempty"`
-	Tools                []ResponseTool     `json:"tools,omitempty"`
-	TopLogprobs          *int               `json:"top_logprobs,omitempty"`
-	TopP                 *float64           `json:"top_p,omitempty"`
-	Truncation           *string            `json:"truncation,omitempty"`
-	User                 *string            `json:"user,omitempty"` // Deprecated, use prompt_cache_key
+	Model                string                 `json:"model"`
+	Input                any                    `json:"input"`
+	Background           *bool                  `json:"background,omitempty"`
+	Conversation         any                    `json:"conversation,omitempty"` // string or object
+	Include              []string               `json:"include,omitempty"`
+	Instructions         *string                `json:"instructions,omitempty"`
+	MaxOutputTokens      *int                   `json:"max_output_tokens,omitempty"`
+	MaxToolCalls         *int                   `json:"max_tool_calls,omitempty"`
+	Metadata             map[string]any         `json:"metadata,omitempty"`
+	ParallelToolCalls    *bool                  `json:"parallel_tool_calls,omitempty"`
+	PreviousResponseID   *string                `json:"previous_response_id,omitempty"`
+	PromptCacheKey       *string                `json:"prompt_cache_key,omitempty"`
+	PromptCacheRetention *string                `json:"prompt_cache_retention,omitempty"`
+	Reasoning            *ResponseReasoning     `json:"reasoning,omitempty"`
+	SafetyIdentifier     *string                `json:"safety_identifier,omitempty"`
+	ServiceTier          *string                `json:"service_tier,omitempty"`
+	Store                *bool                  `json:"store,omitempty"`
+	Stream               bool                   `json:"stream,omitempty"`
+	StreamOptions        *ResponseStreamOptions `json:"stream_options,omitempty"`
+	Temperature          *float64               `json:"temperature,omitempty"`
+	Text                 *ResponseText          `json:"text,omitempty"`
+	ToolChoice           any                    `json:"tool_choice,omitempty"`
+	Tools                []ResponseTool         `json:"tools,omitempty"`
+	TopLogprobs          *int                   `json:"top_logprobs,omitempty"`
+	TopP                 *float64               `json:"top_p,omitempty"`
+	Truncation           *string                `json:"truncation,omitempty"`
+	User                 *string                `json:"user,omitempty"` // Deprecated, use prompt_cache_key
+}
+
+// ResponseStreamOptions represents the stream options of a response creation
+type ResponseStreamOptions struct {
+	IncludeObfuscation *bool `json:"include_obfuscation,omitempty"`
 }
 
 // InputItemList represents a list of input items
@@ -372,6 +382,7 @@ type ResponseStreamEvent struct {
 	Item           *OutputItem       `json:"item,omitempty"`
 	ItemID         string            `json:"item_id,omitempty"`
 	ContentIndex   *int              `json:"content_index,omitempty"`
+	SummaryIndex   *int              `json:"summary_index,omitempty"`
 	Part           *OutputContent    `json:"part,omitempty"`      // For content_part events
 	Delta          string            `json:"delta,omitempty"`     // For text.delta, function_call_arguments.delta
 	Text           string            `json:"text,omitempty"`      // For text content
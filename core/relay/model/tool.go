@@ -27,3 +27,62 @@ type ToolCall struct {
 	Function     Function      `json:"function"`
 	ExtraContent *ExtraContent `json:"extra_content,omitempty"`
 }
+
+// ForcedToolName returns the name of the tool forced by the tool choice, the
+// OpenAI chat `{"type":"function","function":{"name":...}}`, the OpenAI
+// responses `{"type":"function","name":...}` and the Claude
+// `{"type":"tool","name":...}` forms are accepted
+func ForcedToolName(toolChoice any) string {
+	choice, ok := toolChoice.(map[string]any)
+	if !ok {
+		return ""
+	}
+
+	if function, ok := choice["function"].(map[string]any); ok {
+		if name, ok := function["name"].(string); ok && name != "" {
+			return name
+		}
+	}
+
+	switch choice["type"] {
+	case ToolChoiceTypeFunction, ToolChoiceTypeTool:
+		name, _ := choice["name"].(string)
+		return name
+	}
+
+	return ""
+}
+
+// https://platform.openai.com/docs/guides/tools-web-search?api-mode=chat
+type WebSearchOptions struct {
+	// Enable is used by the web search plugin, false disables searching for the request
+	Enable            *bool                  `json:"enable,omitempty"`
+	SearchContextSize string                 `json:"search_context_size,omitempty"`
+	UserLocation      *WebSearchUserLocation `json:"user_location,omitempty"`
+}
+
+type WebSearchUserLocation struct {
+	Type        string                        `json:"type,omitempty"`
+	Approximate *WebSearchApproximateLocation `json:"approximate,omitempty"`
+}
+
+type WebSearchApproximateLocation struct {
+	City     string `json:"city,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Country  string `json:"country,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+const AnnotationTypeURLCitation = "url_citation"
+
+type Annotation struct {
+	Type        string       `json:"type"`
+	URLCitation *URLCitation `json:"url_citation,omitempty"`
+}
+
+type URLCitation struct {
+	URL        string `json:"url"`
+	Title      string `json:"title,omitempty"`
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+}
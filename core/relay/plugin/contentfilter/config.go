@@ -0,0 +1,10 @@
+package contentfilter
+
+// Config represents the plugin configuration
+type Config struct {
+	Enable bool `json:"enable"`
+	// StopSequences truncate the output before the first occurrence of the sequence
+	StopSequences []string `json:"stop_sequences,omitempty"`
+	// BannedPhrases truncate the output before the first occurrence of the phrase
+	BannedPhrases []string `json:"banned_phrases,omitempty"`
+}
@@ -0,0 +1,467 @@
+package contentfilter
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/conv"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	"github.com/labring/aiproxy/core/relay/plugin"
+	"github.com/labring/aiproxy/core/relay/plugin/noop"
+	"github.com/labring/aiproxy/core/relay/render"
+	"github.com/labring/aiproxy/core/relay/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var _ plugin.Plugin = (*ContentFilter)(nil)
+
+// ContentFilter truncates the response at proxy enforced stop sequences and banned phrases
+type ContentFilter struct {
+	noop.Noop
+	configCache utils.PluginConfigCache[Config]
+}
+
+// NewContentFilterPlugin creates a new content filter plugin instance
+func NewContentFilterPlugin() plugin.Plugin {
+	return &ContentFilter{}
+}
+
+// getConfig retrieves the plugin configuration
+func (p *ContentFilter) getConfig(meta *meta.Meta) (*Config, error) {
+	pluginConfig, err := p.configCache.Load(meta, "content-filter", Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pluginConfig, nil
+}
+
+// DoResponse filters the content of the chat completions response
+func (p *ContentFilter) DoResponse(
+	meta *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+	do adaptor.DoResponse,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if meta.Mode != mode.ChatCompletions {
+		return do.DoResponse(meta, store, c, resp)
+	}
+
+	pluginConfig, err := p.getConfig(meta)
+	if err != nil || !pluginConfig.Enable {
+		return do.DoResponse(meta, store, c, resp)
+	}
+
+	filter := NewFilter(pluginConfig)
+	if filter.Empty() {
+		return do.DoResponse(meta, store, c, resp)
+	}
+
+	rw := &filterResponseWriter{
+		ResponseWriter: c.Writer,
+		filter:         filter,
+		log:            common.GetLogger(c),
+	}
+
+	c.Writer = rw
+	defer func() {
+		c.Writer = rw.ResponseWriter
+	}()
+
+	return do.DoResponse(meta, store, c, resp)
+}
+
+// filterResponseWriter wraps the response writer to filter the content
+type filterResponseWriter struct {
+	gin.ResponseWriter
+	filter *Filter
+	log    *logrus.Entry
+
+	isStream  bool
+	done      bool
+	choices   map[int]*StreamState
+	lastChunk map[string]any
+
+	// after a rule matched, the stream is truncated, only the usage chunk
+	// and the done event are written
+	truncated    bool
+	heldPrefix   []byte
+	keepNewlines bool
+}
+
+// ignore WriteHeaderNow
+func (rw *filterResponseWriter) WriteHeaderNow() {}
+
+func (rw *filterResponseWriter) writeWithOriginalLength(original, out []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(out)
+	if err != nil {
+		return n, err
+	}
+
+	return len(original), nil
+}
+
+func (rw *filterResponseWriter) WriteString(s string) (int, error) {
+	return rw.Write(conv.StringToBytes(s))
+}
+
+func (rw *filterResponseWriter) Write(b []byte) (int, error) {
+	if rw.done {
+		return rw.writeWithOriginalLength(b, b)
+	}
+
+	if rw.isStream || utils.IsStreamResponseWithHeader(rw.Header()) {
+		rw.isStream = true
+		return rw.writeStream(b)
+	}
+
+	return rw.writeNonStream(b)
+}
+
+func (rw *filterResponseWriter) choiceState(index int) *StreamState {
+	if rw.choices == nil {
+		rw.choices = make(map[int]*StreamState)
+	}
+
+	state := rw.choices[index]
+	if state == nil {
+		state = &StreamState{}
+		rw.choices[index] = state
+	}
+
+	return state
+}
+
+func (rw *filterResponseWriter) logMatch(rule *Rule) {
+	rw.log.Data["content_filter"] = rule
+	rw.log.Warnf("content filter %s matched: %q", rule.Type, rule.Pattern)
+}
+
+// splitSSEData returns the json payload of the event, the payload is a
+// subslice of b so the surrounding bytes can be kept
+func splitSSEData(b []byte) (payload []byte, prefix, suffix []byte) {
+	payload = b
+	if render.IsValidSSEData(b) {
+		payload = render.ExtractSSEData(b)
+	}
+
+	start := bytes.Index(b, payload)
+	if start == -1 || len(payload) == 0 {
+		return payload, nil, nil
+	}
+
+	return payload, b[:start], b[start+len(payload):]
+}
+
+func (rw *filterResponseWriter) writeStream(b []byte) (int, error) {
+	if rw.truncated {
+		return rw.writeTruncated(b)
+	}
+
+	payload, prefix, suffix := splitSSEData(b)
+	if len(payload) == 0 {
+		return rw.writeWithOriginalLength(b, b)
+	}
+
+	if render.IsSSEDone(payload) {
+		if flushChunk := rw.flushPending(); flushChunk != nil {
+			if _, err := rw.writeEvent(prefix, flushChunk); err != nil {
+				return 0, err
+			}
+		}
+
+		return rw.writeWithOriginalLength(b, b)
+	}
+
+	node, err := common.GetJSONNodeNoCopy(payload)
+	if err != nil || !node.Valid() {
+		return rw.writeWithOriginalLength(b, b)
+	}
+
+	respMap, err := node.Map()
+	if err != nil {
+		return rw.writeWithOriginalLength(b, b)
+	}
+
+	if !rw.filterStreamChunk(respMap) {
+		return rw.writeWithOriginalLength(b, b)
+	}
+
+	jsonData, err := sonic.Marshal(respMap)
+	if err != nil {
+		return rw.writeWithOriginalLength(b, b)
+	}
+
+	out := make([]byte, 0, len(prefix)+len(jsonData)+len(suffix))
+	out = append(out, prefix...)
+	out = append(out, jsonData...)
+	out = append(out, suffix...)
+
+	return rw.writeWithOriginalLength(b, out)
+}
+
+// writeEvent writes a whole sse event, the prefix tells whether the
+// renderer writes the data prefix in the same write as the payload
+func (rw *filterResponseWriter) writeEvent(prefix, jsonData []byte) (int, error) {
+	if len(prefix) == 0 {
+		// the renderer writes the prefix and the newlines separately,
+		// the prefix of the current event is already written
+		if _, err := rw.ResponseWriter.Write(jsonData); err != nil {
+			return 0, err
+		}
+
+		return rw.ResponseWriter.Write([]byte("\n\ndata: "))
+	}
+
+	out := make([]byte, 0, len(prefix)+len(jsonData)+2)
+	out = append(out, prefix...)
+	out = append(out, jsonData...)
+	out = append(out, "\n\n"...)
+
+	return rw.ResponseWriter.Write(out)
+}
+
+// writeTruncated drops the events after the truncation except the usage
+// chunk and the done event, the renderer writes the data prefix, the
+// payload and the newlines separately so the prefix is held back until
+// the payload tells whether the event is kept
+func (rw *filterResponseWriter) writeTruncated(b []byte) (int, error) {
+	if len(bytes.TrimSpace(b)) == 0 {
+		keep := rw.keepNewlines
+		rw.keepNewlines = false
+
+		if keep {
+			return rw.writeWithOriginalLength(b, b)
+		}
+
+		return len(b), nil
+	}
+
+	payload, _, _ := splitSSEData(b)
+	if len(payload) == 0 {
+		rw.heldPrefix = append(rw.heldPrefix[:0], b...)
+		return len(b), nil
+	}
+
+	heldPrefix := rw.heldPrefix
+	rw.heldPrefix = nil
+
+	if !render.IsSSEDone(payload) && !isUsageChunk(payload) {
+		rw.keepNewlines = false
+		return len(b), nil
+	}
+
+	rw.keepNewlines = true
+
+	if len(heldPrefix) != 0 {
+		if _, err := rw.ResponseWriter.Write(heldPrefix); err != nil {
+			return 0, err
+		}
+	}
+
+	return rw.writeWithOriginalLength(b, b)
+}
+
+func isUsageChunk(payload []byte) bool {
+	node, err := common.GetJSONNodeNoCopy(payload)
+	if err != nil || !node.Valid() {
+		return false
+	}
+
+	if err := node.Get("usage").Check(); err != nil {
+		return false
+	}
+
+	n, err := node.Get("choices").Len()
+
+	return err != nil || n == 0
+}
+
+// filterStreamChunk filters the delta content of the chunk, returns whether the chunk is modified
+func (rw *filterResponseWriter) filterStreamChunk(respMap map[string]any) bool {
+	choices, ok := respMap["choices"].([]any)
+	if !ok || len(choices) == 0 {
+		return false
+	}
+
+	rw.lastChunk = respMap
+	modified := false
+
+	for _, choice := range choices {
+		choiceMap, ok := choice.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		state := rw.choiceState(choiceIndex(choiceMap))
+
+		finishReason, _ := choiceMap["finish_reason"].(string)
+
+		delta, ok := choiceMap["delta"].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		content, hasContent := delta["content"].(string)
+		if !hasContent && finishReason == "" {
+			continue
+		}
+
+		out, rule := rw.filter.Process(state, content)
+		if rule != nil {
+			delta["content"] = out
+			choiceMap["finish_reason"] = relaymodel.FinishReasonContentFilter
+			rw.truncated = true
+			rw.keepNewlines = true
+			rw.logMatch(rule)
+
+			return true
+		}
+
+		if finishReason != "" {
+			out += state.Flush()
+		}
+
+		if out != content {
+			delta["content"] = out
+			modified = true
+		}
+	}
+
+	return modified
+}
+
+func choiceIndex(choice map[string]any) int {
+	switch index := choice["index"].(type) {
+	case float64:
+		return int(index)
+	case int64:
+		return int(index)
+	case json.Number:
+		i, _ := index.Int64()
+		return int(i)
+	default:
+		return 0
+	}
+}
+
+// flushPending builds a chunk with the held back text of the stream
+// that ended without a finish reason
+func (rw *filterResponseWriter) flushPending() []byte {
+	if rw.lastChunk == nil {
+		return nil
+	}
+
+	choices := make([]any, 0, len(rw.choices))
+	for index, state := range rw.choices {
+		if !state.Pending() {
+			continue
+		}
+
+		choices = append(choices, map[string]any{
+			"index": index,
+			"delta": map[string]any{
+				"role":    relaymodel.RoleAssistant,
+				"content": state.Flush(),
+			},
+		})
+	}
+
+	if len(choices) == 0 {
+		return nil
+	}
+
+	chunk := make(map[string]any, len(rw.lastChunk))
+	for _, key := range []string{"id", "object", "created", "model"} {
+		if v, ok := rw.lastChunk[key]; ok {
+			chunk[key] = v
+		}
+	}
+
+	chunk["choices"] = choices
+
+	jsonData, err := sonic.Marshal(chunk)
+	if err != nil {
+		return nil
+	}
+
+	return jsonData
+}
+
+func (rw *filterResponseWriter) writeNonStream(b []byte) (int, error) {
+	rw.done = true
+
+	node, err := common.GetJSONNodeNoCopy(b)
+	if err != nil || !node.Valid() {
+		return rw.writeWithOriginalLength(b, b)
+	}
+
+	respMap, err := node.Map()
+	if err != nil {
+		return rw.writeWithOriginalLength(b, b)
+	}
+
+	if !rw.filterResponse(respMap) {
+		return rw.writeWithOriginalLength(b, b)
+	}
+
+	jsonData, err := sonic.Marshal(respMap)
+	if err != nil {
+		return rw.writeWithOriginalLength(b, b)
+	}
+
+	if rw.ResponseWriter.Header().Get("Content-Length") != "" {
+		rw.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(len(jsonData)))
+	}
+
+	return rw.writeWithOriginalLength(b, jsonData)
+}
+
+// filterResponse truncates the message content of each choice, returns whether the response is modified
+func (rw *filterResponseWriter) filterResponse(respMap map[string]any) bool {
+	choices, ok := respMap["choices"].([]any)
+	if !ok {
+		return false
+	}
+
+	modified := false
+
+	for _, choice := range choices {
+		choiceMap, ok := choice.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		message, ok := choiceMap["message"].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		content, ok := message["content"].(string)
+		if !ok {
+			continue
+		}
+
+		rule, index := rw.filter.Match(content)
+		if rule == nil {
+			continue
+		}
+
+		message["content"] = content[:index]
+		choiceMap["finish_reason"] = relaymodel.FinishReasonContentFilter
+		modified = true
+
+		rw.logMatch(rule)
+	}
+
+	return modified
+}
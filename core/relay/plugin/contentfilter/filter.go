@@ -0,0 +1,121 @@
+package contentfilter
+
+import (
+	"strings"
+)
+
+type RuleType string
+
+const (
+	RuleTypeStopSequence RuleType = "stop_sequence"
+	RuleTypeBannedPhrase RuleType = "banned_phrase"
+)
+
+// Rule is a pattern that truncates the output when it appears
+type Rule struct {
+	Type    RuleType `json:"type"`
+	Pattern string   `json:"pattern"`
+}
+
+// Filter matches the rules over the accumulated text of a stream
+type Filter struct {
+	rules []Rule
+}
+
+func NewFilter(config *Config) *Filter {
+	rules := make([]Rule, 0, len(config.StopSequences)+len(config.BannedPhrases))
+	for _, s := range config.StopSequences {
+		if s != "" {
+			rules = append(rules, Rule{Type: RuleTypeStopSequence, Pattern: s})
+		}
+	}
+
+	for _, s := range config.BannedPhrases {
+		if s != "" {
+			rules = append(rules, Rule{Type: RuleTypeBannedPhrase, Pattern: s})
+		}
+	}
+
+	return &Filter{rules: rules}
+}
+
+func (f *Filter) Empty() bool {
+	return len(f.rules) == 0
+}
+
+// Match returns the earliest rule found in the text and its position
+func (f *Filter) Match(text string) (*Rule, int) {
+	var (
+		matched *Rule
+		index   = -1
+	)
+
+	for i := range f.rules {
+		rule := &f.rules[i]
+
+		idx := strings.Index(text, rule.Pattern)
+		if idx == -1 {
+			continue
+		}
+
+		if index == -1 || idx < index {
+			matched = rule
+			index = idx
+		}
+	}
+
+	return matched, index
+}
+
+// holdLength returns the length of the longest suffix of the text that is
+// a prefix of a rule, the suffix must be held back until the next chunk
+// tells whether the rule matches
+func (f *Filter) holdLength(text string) int {
+	hold := 0
+	for _, rule := range f.rules {
+		n := min(len(rule.Pattern)-1, len(text))
+		for ; n > hold; n-- {
+			if strings.HasPrefix(rule.Pattern, text[len(text)-n:]) {
+				hold = n
+				break
+			}
+		}
+	}
+
+	return hold
+}
+
+// StreamState keeps the text of a stream choice that is not written yet
+type StreamState struct {
+	pending string
+}
+
+// Process appends the content to the stream and returns the text that is
+// safe to write, a matched rule means the stream must be truncated after
+// the returned text
+func (f *Filter) Process(state *StreamState, content string) (string, *Rule) {
+	text := state.pending + content
+
+	rule, index := f.Match(text)
+	if rule != nil {
+		state.pending = ""
+		return text[:index], rule
+	}
+
+	hold := f.holdLength(text)
+	state.pending = text[len(text)-hold:]
+
+	return text[:len(text)-hold], nil
+}
+
+// Flush returns the held back text at the end of the stream
+func (s *StreamState) Flush() string {
+	pending := s.pending
+	s.pending = ""
+
+	return pending
+}
+
+func (s *StreamState) Pending() bool {
+	return s.pending != ""
+}
@@ -0,0 +1,135 @@
+//nolint:testpackage
+package contentfilter
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/relay/render"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterProcessAcrossChunks(t *testing.T) {
+	t.Parallel()
+
+	filter := NewFilter(&Config{
+		StopSequences: []string{"END"},
+		BannedPhrases: []string{"secret key"},
+	})
+
+	state := &StreamState{}
+
+	out, rule := filter.Process(state, "hello sec")
+	assert.Nil(t, rule)
+	assert.Equal(t, "hello ", out)
+
+	out, rule = filter.Process(state, "ond")
+	assert.Nil(t, rule)
+	assert.Equal(t, "second", out)
+
+	out, rule = filter.Process(state, " the secret ")
+	assert.Nil(t, rule)
+	assert.Equal(t, " the ", out)
+
+	out, rule = filter.Process(state, "key is")
+	require.NotNil(t, rule)
+	assert.Equal(t, RuleTypeBannedPhrase, rule.Type)
+	assert.Empty(t, out)
+	assert.False(t, state.Pending())
+}
+
+func TestFilterMatchEarliestRule(t *testing.T) {
+	t.Parallel()
+
+	filter := NewFilter(&Config{
+		StopSequences: []string{"", "STOP"},
+		BannedPhrases: []string{"bad"},
+	})
+
+	rule, index := filter.Match("a bad word before STOP")
+	require.NotNil(t, rule)
+	assert.Equal(t, "bad", rule.Pattern)
+	assert.Equal(t, 2, index)
+
+	rule, _ = filter.Match("nothing here")
+	assert.Nil(t, rule)
+}
+
+func newTestWriter(t *testing.T, config *Config) (*filterResponseWriter, *httptest.ResponseRecorder) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+
+	return &filterResponseWriter{
+		ResponseWriter: c.Writer,
+		filter:         NewFilter(config),
+		log:            common.NewLogger(),
+	}, recorder
+}
+
+func TestFilterResponseWriterStream(t *testing.T) {
+	t.Parallel()
+
+	rw, recorder := newTestWriter(t, &Config{BannedPhrases: []string{"forbidden"}})
+	rw.Header().Set("Content-Type", "text/event-stream")
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Writer = rw
+
+	for _, content := range []string{"this is for", "bidden text", "more"} {
+		render.OpenaiStringData(
+			c,
+			`{"id":"1","choices":[{"index":0,"delta":{"content":"`+content+`"}}]}`,
+		)
+	}
+
+	render.OpenaiStringData(c, `{"id":"1","choices":[],"usage":{"total_tokens":3}}`)
+	render.OpenaiDone(c)
+
+	body := recorder.Body.String()
+	assert.Equal(t, 4, strings.Count(body, "data: "), body)
+	assert.Contains(t, body, `"content":"this is "`)
+	assert.Contains(t, body, `"finish_reason":"content_filter"`)
+	assert.NotContains(t, body, "bidden")
+	assert.NotContains(t, body, "more")
+	assert.Contains(t, body, `"total_tokens":3`)
+	assert.True(t, strings.HasSuffix(body, "data: [DONE]\n\n"), body)
+}
+
+func TestFilterResponseWriterStreamFlushAtDone(t *testing.T) {
+	t.Parallel()
+
+	rw, recorder := newTestWriter(t, &Config{StopSequences: []string{"STOP"}})
+	rw.Header().Set("Content-Type", "text/event-stream")
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Writer = rw
+
+	render.OpenaiStringData(c, `{"id":"1","choices":[{"index":0,"delta":{"content":"go ST"}}]}`)
+	render.OpenaiDone(c)
+
+	body := recorder.Body.String()
+	assert.Contains(t, body, `"content":"go "`)
+	assert.Contains(t, body, `"content":"ST"`)
+	assert.True(t, strings.HasSuffix(body, "\n\ndata: [DONE]\n\n"), body)
+}
+
+func TestFilterResponseWriterNonStream(t *testing.T) {
+	t.Parallel()
+
+	rw, recorder := newTestWriter(t, &Config{StopSequences: []string{"\n\nUser:"}})
+
+	input := []byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"content":"answer\n\nUser: next"}}]}`)
+
+	n, err := rw.Write(input)
+	require.NoError(t, err)
+	assert.Equal(t, len(input), n)
+	assert.Contains(t, recorder.Body.String(), `"content":"answer"`)
+	assert.Contains(t, recorder.Body.String(), `"finish_reason":"content_filter"`)
+}
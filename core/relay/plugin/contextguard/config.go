@@ -0,0 +1,44 @@
+package contextguard
+
+const PluginName = "context-guard"
+
+const (
+	// StrategyReject rejects the request with a context length error
+	StrategyReject = "reject"
+	// StrategyDropOldest drops the oldest messages until the prompt fits
+	StrategyDropOldest = "drop_oldest"
+	// StrategySummarize replaces the older messages with a summary, the oldest
+	// messages are dropped when the prompt still does not fit
+	StrategySummarize = "summarize"
+)
+
+// Config represents the plugin configuration
+type Config struct {
+	Enable bool `json:"enable"`
+	// Strategy applied when the prompt exceeds the context window
+	Strategy string `json:"strategy,omitempty"`
+	// ContextTokens overrides the context window of the model config
+	ContextTokens int `json:"context_tokens,omitempty"`
+	// ReserveOutputTokens are kept for the output of the requests without max
+	// tokens, the max tokens of the request are kept otherwise
+	ReserveOutputTokens int `json:"reserve_output_tokens,omitempty"`
+	// KeepRecentMessages is the number of the latest messages never dropped
+	// nor summarized
+	KeepRecentMessages int `json:"keep_recent_messages,omitempty"`
+	// SummaryModel summarizes the older messages, the requested model is used when empty
+	SummaryModel     string `json:"summary_model,omitempty"`
+	SummaryMaxTokens int    `json:"summary_max_tokens,omitempty"`
+	SummaryPrompt    string `json:"summary_prompt,omitempty"`
+}
+
+const defaultKeepRecentMessages = 1
+
+func (c *Config) applyDefaults() {
+	if c.Strategy == "" {
+		c.Strategy = StrategyReject
+	}
+
+	if c.KeepRecentMessages <= 0 {
+		c.KeepRecentMessages = defaultKeepRecentMessages
+	}
+}
@@ -0,0 +1,437 @@
+package contextguard
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/consume"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	"github.com/labring/aiproxy/core/relay/plugin"
+	"github.com/labring/aiproxy/core/relay/plugin/noop"
+	"github.com/labring/aiproxy/core/relay/plugin/promptcompress"
+	"github.com/labring/aiproxy/core/relay/utils"
+)
+
+var _ plugin.Plugin = (*ContextGuard)(nil)
+
+// replyPrimingTokens are counted once per prompt, every reply is primed with
+// <|start|>assistant<|message|>
+const replyPrimingTokens = 3
+
+// ContextGuard checks the prompt tokens against the context window of the
+// model before the request is sent, and rejects or trims the prompt instead of
+// letting the upstream fail with a context length error
+type ContextGuard struct {
+	noop.Noop
+	summarizer  *promptcompress.PromptCompress
+	configCache utils.PluginConfigCache[Config]
+}
+
+// NewContextGuardPlugin creates a new context guard plugin, the channel and
+// the model config getters are used by the summarize strategy
+func NewContextGuardPlugin(
+	getChannel promptcompress.GetChannel,
+	getModelConfig promptcompress.GetModelConfig,
+) plugin.Plugin {
+	return &ContextGuard{
+		summarizer: &promptcompress.PromptCompress{
+			GetChannel:     getChannel,
+			GetModelConfig: getModelConfig,
+		},
+	}
+}
+
+const guardKey = "context-guard"
+
+// guard is the result of the guard stored in the meta
+type guard struct {
+	Strategy        string
+	TokensBefore    int64
+	TokensAfter     int64
+	DroppedMessages int
+	SummaryModel    string
+	SummaryUsage    model.Usage
+	SummaryPrice    model.Price
+}
+
+func setGuard(m *meta.Meta, g guard) {
+	m.Set(guardKey, g)
+}
+
+func getGuard(m *meta.Meta) (guard, bool) {
+	v, ok := m.Get(guardKey)
+	if !ok {
+		return guard{}, false
+	}
+
+	g, ok := v.(guard)
+	if !ok {
+		panic(fmt.Sprintf("context guard type %T is not a guard", v))
+	}
+
+	return g, true
+}
+
+func (p *ContextGuard) getConfig(m *meta.Meta) (Config, error) {
+	pluginConfig, err := p.configCache.Load(m, PluginName, Config{})
+	if err != nil {
+		return Config{}, err
+	}
+
+	pluginConfig.applyDefaults()
+
+	return pluginConfig, nil
+}
+
+// promptTokenLimit returns the tokens the prompt may use, the max input tokens
+// of the model, or the context window minus the output tokens, false is
+// returned when the context window is unknown
+func promptTokenLimit(config Config, modelConfig model.ModelConfig, maxTokens int) (int64, bool) {
+	window := config.ContextTokens
+	if window <= 0 {
+		if maxInput, ok := modelConfig.MaxInputTokens(); ok && maxInput > 0 {
+			return int64(maxInput), true
+		}
+
+		maxContext, ok := modelConfig.MaxContextTokens()
+		if !ok || maxContext <= 0 {
+			return 0, false
+		}
+
+		window = maxContext
+	}
+
+	if maxTokens <= 0 {
+		maxTokens = config.ReserveOutputTokens
+	}
+
+	return int64(max(window-maxTokens, 0)), true
+}
+
+// requestMaxTokens returns the max output tokens of the chat request, 0
+// without one
+func requestMaxTokens(chatRequest map[string]any) int {
+	for _, key := range []string{"max_completion_tokens", "max_tokens"} {
+		if v, ok := chatRequest[key].(float64); ok && v > 0 {
+			return int(v)
+		}
+	}
+
+	return 0
+}
+
+// conversation keeps the typed and the raw messages together with the tokens
+// of each message, so the messages are dropped without recounting the prompt
+type conversation struct {
+	typed  []relaymodel.Message
+	raw    []any
+	tokens []int64
+}
+
+func countMessageTokens(message relaymodel.Message, modelName string) int64 {
+	return openai.CountTokenMessages(
+		[]relaymodel.Message{message},
+		modelName,
+		false,
+	) - replyPrimingTokens
+}
+
+func newConversation(typed []relaymodel.Message, raw []any, modelName string) *conversation {
+	tokens := make([]int64, len(typed))
+	for i, message := range typed {
+		tokens[i] = countMessageTokens(message, modelName)
+	}
+
+	return &conversation{typed: typed, raw: raw, tokens: tokens}
+}
+
+func (c *conversation) total() int64 {
+	total := int64(replyPrimingTokens)
+	for _, tokens := range c.tokens {
+		total += tokens
+	}
+
+	return total
+}
+
+// replace replaces the messages [start, end) with the message, the messages
+// are only removed when the message is nil
+func (c *conversation) replace(start, end int, message *relaymodel.Message, tokens int64) {
+	if message == nil {
+		c.typed = append(c.typed[:start:start], c.typed[end:]...)
+		c.raw = append(c.raw[:start:start], c.raw[end:]...)
+		c.tokens = append(c.tokens[:start:start], c.tokens[end:]...)
+
+		return
+	}
+
+	c.typed = append(append(c.typed[:start:start], *message), c.typed[end:]...)
+	c.raw = append(append(c.raw[:start:start], map[string]any{
+		"role":    message.Role,
+		"content": message.Content,
+	}), c.raw[end:]...)
+	c.tokens = append(append(c.tokens[:start:start], tokens), c.tokens[end:]...)
+}
+
+// droppableRange returns the range [start, end) of the messages that may be
+// dropped, the leading system messages and the latest keepRecent messages are
+// kept, the kept messages never start with a tool result
+func (c *conversation) droppableRange(keepRecent int) (int, int) {
+	start := 0
+	for start < len(c.typed) &&
+		(c.typed[start].Role == relaymodel.RoleSystem ||
+			c.typed[start].Role == relaymodel.RoleDeveloper) {
+		start++
+	}
+
+	end := max(start, len(c.typed)-keepRecent)
+	for end > start && end < len(c.typed) && c.typed[end].Role == relaymodel.RoleTool {
+		end--
+	}
+
+	return start, end
+}
+
+// dropOldest drops the oldest turns until the prompt fits the limit, a turn is
+// a message with the replies and the tool results following it, so the kept
+// conversation starts with a user message, the dropped messages are returned
+func (c *conversation) dropOldest(limit int64, keepRecent int) int {
+	dropped := 0
+
+	for c.total() > limit {
+		start, end := c.droppableRange(keepRecent)
+		if start >= end {
+			break
+		}
+
+		n := 1
+		for start+n < end && c.typed[start+n].Role != relaymodel.RoleUser {
+			n++
+		}
+
+		c.replace(start, start+n, nil, 0)
+		dropped += n
+	}
+
+	return dropped
+}
+
+func contextLengthError(limit, tokens int64) error {
+	return relaymodel.WrapperOpenAIErrorWithMessage(
+		fmt.Sprintf(
+			"the prompt of this model is limited to %d tokens, however the messages resulted in %d tokens, please reduce the length of the messages",
+			limit,
+			tokens,
+		),
+		"context_length_exceeded",
+		http.StatusBadRequest,
+	)
+}
+
+// ConvertRequest applies the strategy when the prompt exceeds the context
+// window of the model
+func (p *ContextGuard) ConvertRequest(
+	meta *meta.Meta,
+	store adaptor.Store,
+	req *http.Request,
+	do adaptor.ConvertRequest,
+) (adaptor.ConvertResult, error) {
+	// the previous attempt may be sent to another channel
+	meta.Delete(guardKey)
+
+	if meta.Mode != mode.ChatCompletions {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	pluginConfig, err := p.getConfig(meta)
+	if err != nil || !pluginConfig.Enable {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	body, err := common.GetRequestBodyReusable(req)
+	if err != nil {
+		return adaptor.ConvertResult{}, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	var chatRequest map[string]any
+	if err := sonic.Unmarshal(body, &chatRequest); err != nil {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	limit, ok := promptTokenLimit(pluginConfig, meta.ModelConfig, requestMaxTokens(chatRequest))
+	if !ok {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	var typedRequest struct {
+		Messages []relaymodel.Message `json:"messages"`
+	}
+	if err := sonic.Unmarshal(body, &typedRequest); err != nil {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	rawMessages, ok := chatRequest["messages"].([]any)
+	if !ok || len(rawMessages) != len(typedRequest.Messages) {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	conv := newConversation(typedRequest.Messages, rawMessages, meta.ActualModel)
+
+	tokensBefore := conv.total()
+	if tokensBefore <= limit {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	log := common.GetLoggerFromReq(req)
+	log.Data["context_guard_strategy"] = pluginConfig.Strategy
+
+	result := guard{
+		Strategy:     pluginConfig.Strategy,
+		TokensBefore: tokensBefore,
+	}
+
+	switch pluginConfig.Strategy {
+	case StrategySummarize:
+		if err := p.summarize(meta, store, pluginConfig, conv, &result); err != nil {
+			log.Warnf("context-guard: summarize failed, dropping the oldest messages: %v", err)
+		}
+
+		result.DroppedMessages = conv.dropOldest(limit, pluginConfig.KeepRecentMessages)
+	case StrategyDropOldest:
+		result.DroppedMessages = conv.dropOldest(limit, pluginConfig.KeepRecentMessages)
+	}
+
+	result.TokensAfter = conv.total()
+	if result.TokensAfter > limit {
+		log.Warnf(
+			"context-guard: rejected, %d prompt tokens exceed the limit of %d tokens",
+			result.TokensAfter,
+			limit,
+		)
+
+		return adaptor.ConvertResult{}, contextLengthError(limit, tokensBefore)
+	}
+
+	chatRequest["messages"] = conv.raw
+
+	modifiedBody, err := sonic.Marshal(chatRequest)
+	if err != nil {
+		return adaptor.ConvertResult{}, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	setGuard(meta, result)
+
+	common.SetRequestBody(req, modifiedBody)
+	defer common.SetRequestBody(req, body)
+
+	return do.ConvertRequest(meta, store, req)
+}
+
+// summarize replaces the older messages with a summary
+func (p *ContextGuard) summarize(
+	m *meta.Meta,
+	store adaptor.Store,
+	config Config,
+	conv *conversation,
+	result *guard,
+) error {
+	start, end, ok := promptcompress.SplitMessages(conv.typed, config.KeepRecentMessages)
+	if !ok {
+		return nil
+	}
+
+	summaryConfig := promptcompress.Config{
+		SummaryModel:     config.SummaryModel,
+		SummaryMaxTokens: config.SummaryMaxTokens,
+		SummaryPrompt:    config.SummaryPrompt,
+	}
+	summaryConfig.ApplyDefaults()
+
+	summary, usage, err := p.summarizer.Summarize(
+		m,
+		store,
+		summaryConfig,
+		promptcompress.BuildTranscript(conv.typed[start:end]),
+	)
+	if err != nil {
+		return err
+	}
+
+	if summary == "" {
+		return nil
+	}
+
+	summaryMessage := relaymodel.Message{
+		Role:    relaymodel.RoleSystem,
+		Content: "Summary of the earlier conversation:\n" + summary,
+	}
+
+	conv.replace(start, end, &summaryMessage, countMessageTokens(summaryMessage, m.ActualModel))
+
+	result.SummaryModel, result.SummaryPrice = p.summarizer.SummaryModelPrice(m, summaryConfig)
+	result.SummaryUsage = usage
+
+	return nil
+}
+
+// DoResponse records the strategy applied to the prompt in the log
+func (p *ContextGuard) DoResponse(
+	meta *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+	do adaptor.DoResponse,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	result, respErr := do.DoResponse(meta, store, c, resp)
+
+	g, ok := getGuard(meta)
+	if !ok {
+		return result, respErr
+	}
+
+	fields := map[string]string{
+		"context_guard_strategy":         g.Strategy,
+		"context_guard_tokens_before":    strconv.FormatInt(g.TokensBefore, 10),
+		"context_guard_tokens_after":     strconv.FormatInt(g.TokensAfter, 10),
+		"context_guard_dropped_messages": strconv.Itoa(g.DroppedMessages),
+	}
+
+	if g.SummaryModel != "" {
+		fields["context_guard_summary_model"] = g.SummaryModel
+		fields["context_guard_summary_cost"] = strconv.FormatFloat(
+			consume.CalculateAmount(
+				http.StatusOK,
+				g.SummaryUsage,
+				model.UsageContext{},
+				g.SummaryPrice,
+			),
+			'f',
+			-1,
+			64,
+		)
+	}
+
+	metadata := middleware.GetRequestMetadata(c)
+	if metadata == nil {
+		metadata = make(map[string]string, len(fields))
+		c.Set(middleware.RequestMetadata, metadata)
+	}
+
+	log := common.GetLogger(c)
+	for k, v := range fields {
+		metadata[k] = v
+		log.Data[k] = v
+	}
+
+	return result, respErr
+}
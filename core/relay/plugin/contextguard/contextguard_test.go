@@ -0,0 +1,181 @@
+//nolint:testpackage
+package contextguard
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestConversation builds a conversation of the roles, every message costs
+// ten tokens
+func newTestConversation(roles ...string) *conversation {
+	c := &conversation{}
+	for _, role := range roles {
+		c.typed = append(c.typed, relaymodel.Message{Role: role, Content: role})
+		c.raw = append(c.raw, map[string]any{"role": role, "content": role})
+		c.tokens = append(c.tokens, 10)
+	}
+
+	return c
+}
+
+func roles(c *conversation) []string {
+	result := make([]string, 0, len(c.typed))
+	for _, message := range c.typed {
+		result = append(result, message.Role)
+	}
+
+	return result
+}
+
+func TestPromptTokenLimit(t *testing.T) {
+	t.Parallel()
+
+	mc := model.ModelConfig{Config: map[model.ModelConfigKey]any{
+		model.ModelConfigMaxContextTokensKey: 8000,
+	}}
+
+	limit, ok := promptTokenLimit(Config{}, mc, 1000)
+	assert.True(t, ok)
+	assert.Equal(t, int64(7000), limit)
+
+	limit, ok = promptTokenLimit(Config{ReserveOutputTokens: 500}, mc, 0)
+	assert.True(t, ok)
+	assert.Equal(t, int64(7500), limit)
+
+	limit, ok = promptTokenLimit(Config{ContextTokens: 4000}, mc, 1000)
+	assert.True(t, ok)
+	assert.Equal(t, int64(3000), limit)
+
+	mc.Config[model.ModelConfigMaxInputTokensKey] = 6000
+	limit, ok = promptTokenLimit(Config{}, mc, 1000)
+	assert.True(t, ok)
+	assert.Equal(t, int64(6000), limit)
+
+	_, ok = promptTokenLimit(Config{}, model.ModelConfig{}, 1000)
+	assert.False(t, ok)
+}
+
+func TestRequestMaxTokens(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 100, requestMaxTokens(map[string]any{"max_tokens": float64(100)}))
+	assert.Equal(t, 200, requestMaxTokens(map[string]any{
+		"max_tokens":            float64(100),
+		"max_completion_tokens": float64(200),
+	}))
+	assert.Equal(t, 0, requestMaxTokens(map[string]any{}))
+}
+
+func TestDropOldestDropsWholeTurns(t *testing.T) {
+	t.Parallel()
+
+	c := newTestConversation(
+		relaymodel.RoleSystem,
+		relaymodel.RoleUser,
+		relaymodel.RoleAssistant,
+		relaymodel.RoleTool,
+		relaymodel.RoleAssistant,
+		relaymodel.RoleUser,
+		relaymodel.RoleAssistant,
+		relaymodel.RoleUser,
+	)
+
+	// 8 messages of 10 tokens and 3 tokens of the reply priming
+	assert.Equal(t, int64(83), c.total())
+
+	dropped := c.dropOldest(60, 1)
+	assert.Equal(t, 4, dropped)
+	assert.Equal(t, []string{
+		relaymodel.RoleSystem,
+		relaymodel.RoleUser,
+		relaymodel.RoleAssistant,
+		relaymodel.RoleUser,
+	}, roles(c))
+	assert.Len(t, c.raw, 4)
+	assert.Equal(t, int64(43), c.total())
+}
+
+func TestDropOldestKeepsRecentMessages(t *testing.T) {
+	t.Parallel()
+
+	c := newTestConversation(
+		relaymodel.RoleUser,
+		relaymodel.RoleAssistant,
+		relaymodel.RoleUser,
+		relaymodel.RoleAssistant,
+		relaymodel.RoleTool,
+	)
+
+	// the tool result stays with the assistant message calling the tool
+	dropped := c.dropOldest(0, 1)
+	assert.Equal(t, 3, dropped)
+	assert.Equal(t, []string{relaymodel.RoleAssistant, relaymodel.RoleTool}, roles(c))
+	assert.Greater(t, c.total(), int64(0))
+}
+
+func TestConversationReplace(t *testing.T) {
+	t.Parallel()
+
+	c := newTestConversation(
+		relaymodel.RoleSystem,
+		relaymodel.RoleUser,
+		relaymodel.RoleAssistant,
+		relaymodel.RoleUser,
+	)
+
+	c.replace(1, 3, &relaymodel.Message{Role: relaymodel.RoleSystem, Content: "summary"}, 5)
+	assert.Equal(t, []string{
+		relaymodel.RoleSystem,
+		relaymodel.RoleSystem,
+		relaymodel.RoleUser,
+	}, roles(c))
+	assert.Equal(t, map[string]any{"role": relaymodel.RoleSystem, "content": "summary"}, c.raw[1])
+	assert.Equal(t, int64(28), c.total())
+}
+
+func TestSummarizePicksTheChannelWithTheRequestGetter(t *testing.T) {
+	t.Parallel()
+
+	// the getter is bound to the sets and the channel policy of the request,
+	// the summary is not sent when no channel complies
+	errNoChannel := errors.New("no channel complies with the channel policy")
+
+	var requested []string
+
+	p, ok := NewContextGuardPlugin(
+		func(modelName string) (*model.Channel, error) {
+			requested = append(requested, modelName)
+			return nil, errNoChannel
+		},
+		nil,
+	).(*ContextGuard)
+	require.True(t, ok)
+
+	c := newTestConversation(
+		relaymodel.RoleUser,
+		relaymodel.RoleAssistant,
+		relaymodel.RoleUser,
+		relaymodel.RoleAssistant,
+		relaymodel.RoleUser,
+	)
+	m := meta.NewMeta(nil, mode.ChatCompletions, "gpt-4o", model.ModelConfig{})
+
+	var result guard
+
+	err := p.summarize(m, nil, Config{
+		SummaryModel:       "gpt-5-mini",
+		KeepRecentMessages: 1,
+	}, c, &result)
+	require.ErrorIs(t, err, errNoChannel)
+	assert.Equal(t, []string{"gpt-5-mini"}, requested)
+	assert.Len(t, c.typed, 5)
+	assert.Empty(t, result.SummaryModel)
+}
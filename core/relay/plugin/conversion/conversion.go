@@ -0,0 +1,141 @@
+package conversion
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/plugin"
+	"github.com/labring/aiproxy/core/relay/plugin/noop"
+)
+
+var _ plugin.Plugin = (*Conversion)(nil)
+
+// Conversion records the latency of the adaptor request/response conversion
+// and attaches pprof labels so the cpu profile can be split per adaptor
+type Conversion struct {
+	noop.Noop
+}
+
+func NewConversionPlugin() plugin.Plugin {
+	return &Conversion{}
+}
+
+func labels(meta *meta.Meta, stage Stage) pprof.LabelSet {
+	return pprof.Labels(
+		"adaptor", meta.Channel.Type.String(),
+		"mode", meta.Mode.String(),
+		"stage", string(stage),
+	)
+}
+
+func (p *Conversion) ConvertRequest(
+	meta *meta.Meta,
+	store adaptor.Store,
+	req *http.Request,
+	do adaptor.ConvertRequest,
+) (result adaptor.ConvertResult, err error) {
+	start := time.Now()
+
+	pprof.Do(req.Context(), labels(meta, StageConvertRequest), func(context.Context) {
+		result, err = do.ConvertRequest(meta, store, req)
+	})
+
+	Observe(
+		meta.Channel.Type.String(),
+		meta.Mode.String(),
+		StageConvertRequest,
+		time.Since(start),
+	)
+
+	return result, err
+}
+
+// DoResponse records only the conversion of the response, the time blocked on
+// reading the upstream body and writing to the client is left out so the
+// upstream generation time of the streams is not counted
+func (p *Conversion) DoResponse(
+	meta *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+	do adaptor.DoResponse,
+) (result adaptor.DoResponseResult, err adaptor.Error) {
+	timer := &ioTimer{}
+
+	if resp.Body != nil {
+		resp.Body = &timedBody{ReadCloser: resp.Body, timer: timer}
+	}
+
+	writer := c.Writer
+	c.Writer = &timedWriter{ResponseWriter: writer, timer: timer}
+
+	start := time.Now()
+
+	pprof.Do(c.Request.Context(), labels(meta, StageDoResponse), func(context.Context) {
+		result, err = do.DoResponse(meta, store, c, resp)
+	})
+
+	elapsed := time.Since(start)
+
+	c.Writer = writer
+
+	Observe(
+		meta.Channel.Type.String(),
+		meta.Mode.String(),
+		StageDoResponse,
+		max(elapsed-timer.get(), 0),
+	)
+
+	return result, err
+}
+
+// ioTimer sums the time blocked on the upstream and the client, the body may
+// be read by another goroutine than the one writing the response
+type ioTimer struct {
+	nanos atomic.Int64
+}
+
+func (t *ioTimer) add(start time.Time) {
+	t.nanos.Add(int64(time.Since(start)))
+}
+
+func (t *ioTimer) get() time.Duration {
+	return time.Duration(t.nanos.Load())
+}
+
+type timedBody struct {
+	io.ReadCloser
+	timer *ioTimer
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	defer b.timer.add(time.Now())
+	return b.ReadCloser.Read(p)
+}
+
+type timedWriter struct {
+	gin.ResponseWriter
+	timer *ioTimer
+}
+
+func (w *timedWriter) Write(data []byte) (int, error) {
+	defer w.timer.add(time.Now())
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timedWriter) WriteString(s string) (int, error) {
+	defer w.timer.add(time.Now())
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timedWriter) Flush() {
+	defer w.timer.add(time.Now())
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,75 @@
+package conversion_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/labring/aiproxy/core/relay/plugin/conversion"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type slowBody struct {
+	io.Reader
+}
+
+func (b slowBody) Read(p []byte) (int, error) {
+	time.Sleep(50 * time.Millisecond)
+	return b.Reader.Read(p)
+}
+
+func (slowBody) Close() error {
+	return nil
+}
+
+type copyResponse struct{}
+
+func (copyResponse) DoResponse(
+	_ *meta.Meta,
+	_ adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	buf := make([]byte, 4)
+	for {
+		n, err := resp.Body.Read(buf)
+		_, _ = c.Writer.Write(buf[:n])
+
+		if err != nil {
+			return adaptor.DoResponseResult{}, nil
+		}
+	}
+}
+
+func TestDoResponseLeavesOutUpstreamReads(t *testing.T) {
+	conversion.Reset()
+	t.Cleanup(conversion.Reset)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	m := &meta.Meta{
+		Mode:    mode.ChatCompletions,
+		Channel: meta.ChannelMeta{Type: model.ChannelTypeOpenAI},
+	}
+	resp := &http.Response{Body: slowBody{Reader: strings.NewReader("upstream body")}}
+
+	_, err := conversion.NewConversionPlugin().DoResponse(m, nil, c, resp, copyResponse{})
+	require.Nil(t, err)
+	assert.Equal(t, "upstream body", recorder.Body.String())
+
+	snapshot := conversion.Snapshot()
+	require.Len(t, snapshot, 1)
+	assert.Equal(t, conversion.StageDoResponse, snapshot[0].Stage)
+	assert.Less(t, snapshot[0].MaxMicroseconds, int64(50_000))
+}
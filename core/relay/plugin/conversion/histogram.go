@@ -0,0 +1,227 @@
+package conversion
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Stage string
+
+const (
+	StageConvertRequest Stage = "convert_request"
+	StageDoResponse     Stage = "do_response"
+)
+
+// bucketBounds are the upper bounds (in microseconds) of the latency buckets,
+// the last bucket is unbounded
+var bucketBounds = []int64{
+	50,
+	100,
+	250,
+	500,
+	1000,
+	2500,
+	5000,
+	10000,
+	25000,
+	50000,
+	100000,
+	250000,
+	500000,
+	1000000,
+}
+
+type histogramKey struct {
+	adaptor string
+	mode    string
+	stage   Stage
+}
+
+type histogram struct {
+	count   atomic.Int64
+	sum     atomic.Int64
+	max     atomic.Int64
+	buckets []atomic.Int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{
+		buckets: make([]atomic.Int64, len(bucketBounds)+1),
+	}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	us := d.Microseconds()
+
+	h.count.Add(1)
+	h.sum.Add(us)
+
+	for {
+		current := h.max.Load()
+		if us <= current || h.max.CompareAndSwap(current, us) {
+			break
+		}
+	}
+
+	idx, _ := slices.BinarySearch(bucketBounds, us)
+	h.buckets[idx].Add(1)
+}
+
+type Bucket struct {
+	// LeMicroseconds is the inclusive upper bound of the bucket, 0 means +Inf
+	LeMicroseconds int64 `json:"le_us"`
+	Count          int64 `json:"count"`
+}
+
+type Histogram struct {
+	Adaptor         string   `json:"adaptor"`
+	Mode            string   `json:"mode"`
+	Stage           Stage    `json:"stage"`
+	Count           int64    `json:"count"`
+	SumMicroseconds int64    `json:"sum_us"`
+	AvgMicroseconds int64    `json:"avg_us"`
+	MaxMicroseconds int64    `json:"max_us"`
+	P50Microseconds int64    `json:"p50_us"`
+	P90Microseconds int64    `json:"p90_us"`
+	P99Microseconds int64    `json:"p99_us"`
+	Buckets         []Bucket `json:"buckets"`
+}
+
+func (h *histogram) snapshot(key histogramKey) Histogram {
+	result := Histogram{
+		Adaptor:         key.adaptor,
+		Mode:            key.mode,
+		Stage:           key.stage,
+		Count:           h.count.Load(),
+		SumMicroseconds: h.sum.Load(),
+		MaxMicroseconds: h.max.Load(),
+		Buckets:         make([]Bucket, len(h.buckets)),
+	}
+
+	for i := range h.buckets {
+		var le int64
+		if i < len(bucketBounds) {
+			le = bucketBounds[i]
+		}
+
+		result.Buckets[i] = Bucket{
+			LeMicroseconds: le,
+			Count:          h.buckets[i].Load(),
+		}
+	}
+
+	if result.Count > 0 {
+		result.AvgMicroseconds = result.SumMicroseconds / result.Count
+	}
+
+	result.P50Microseconds = result.quantile(0.5)
+	result.P90Microseconds = result.quantile(0.9)
+	result.P99Microseconds = result.quantile(0.99)
+
+	return result
+}
+
+// quantile returns the upper bound of the bucket containing the quantile,
+// the max observed value is used for the unbounded bucket
+func (h *Histogram) quantile(q float64) int64 {
+	var total int64
+	for _, b := range h.Buckets {
+		total += b.Count
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	rank := int64(float64(total) * q)
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for _, b := range h.Buckets {
+		seen += b.Count
+		if seen < rank {
+			continue
+		}
+
+		if b.LeMicroseconds == 0 || b.LeMicroseconds > h.MaxMicroseconds {
+			return h.MaxMicroseconds
+		}
+
+		return b.LeMicroseconds
+	}
+
+	return h.MaxMicroseconds
+}
+
+var (
+	histogramsLock sync.RWMutex
+	histograms     = make(map[histogramKey]*histogram)
+)
+
+func getHistogram(key histogramKey) *histogram {
+	histogramsLock.RLock()
+	h, ok := histograms[key]
+	histogramsLock.RUnlock()
+
+	if ok {
+		return h
+	}
+
+	histogramsLock.Lock()
+	defer histogramsLock.Unlock()
+
+	if h, ok := histograms[key]; ok {
+		return h
+	}
+
+	h = newHistogram()
+	histograms[key] = h
+
+	return h
+}
+
+func Observe(adaptor, mode string, stage Stage, d time.Duration) {
+	getHistogram(histogramKey{
+		adaptor: adaptor,
+		mode:    mode,
+		stage:   stage,
+	}).observe(d)
+}
+
+// Snapshot returns the conversion latency histograms sorted by adaptor, mode and stage
+func Snapshot() []Histogram {
+	histogramsLock.RLock()
+
+	result := make([]Histogram, 0, len(histograms))
+	for key, h := range histograms {
+		result = append(result, h.snapshot(key))
+	}
+
+	histogramsLock.RUnlock()
+
+	slices.SortFunc(result, func(a, b Histogram) int {
+		if c := strings.Compare(a.Adaptor, b.Adaptor); c != 0 {
+			return c
+		}
+
+		if c := strings.Compare(a.Mode, b.Mode); c != 0 {
+			return c
+		}
+
+		return strings.Compare(string(a.Stage), string(b.Stage))
+	})
+
+	return result
+}
+
+func Reset() {
+	histogramsLock.Lock()
+	defer histogramsLock.Unlock()
+
+	clear(histograms)
+}
@@ -0,0 +1,53 @@
+package conversion_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/labring/aiproxy/core/relay/plugin/conversion"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserveAndSnapshot(t *testing.T) {
+	conversion.Reset()
+	t.Cleanup(conversion.Reset)
+
+	for range 98 {
+		conversion.Observe("openai", "ChatCompletions", conversion.StageConvertRequest, 80*time.Microsecond)
+	}
+
+	conversion.Observe("openai", "ChatCompletions", conversion.StageConvertRequest, 3*time.Millisecond)
+	conversion.Observe("openai", "ChatCompletions", conversion.StageConvertRequest, 2*time.Second)
+	conversion.Observe("anthropic", "ChatCompletions", conversion.StageDoResponse, time.Millisecond)
+
+	snapshot := conversion.Snapshot()
+	require.Len(t, snapshot, 2)
+
+	assert.Equal(t, "anthropic", snapshot[0].Adaptor)
+	assert.Equal(t, conversion.StageDoResponse, snapshot[0].Stage)
+	assert.Equal(t, int64(1), snapshot[0].Count)
+
+	h := snapshot[1]
+	assert.Equal(t, "openai", h.Adaptor)
+	assert.Equal(t, int64(100), h.Count)
+	assert.Equal(t, int64(2_000_000), h.MaxMicroseconds)
+	assert.Equal(t, int64(100), h.P50Microseconds)
+	assert.Equal(t, int64(100), h.P90Microseconds)
+	assert.Equal(t, int64(5000), h.P99Microseconds)
+
+	var total int64
+	for _, b := range h.Buckets {
+		total += b.Count
+	}
+
+	assert.Equal(t, h.Count, total)
+	assert.Equal(t, int64(1), h.Buckets[len(h.Buckets)-1].Count)
+}
+
+func TestReset(t *testing.T) {
+	conversion.Observe("openai", "Embeddings", conversion.StageConvertRequest, time.Millisecond)
+	conversion.Reset()
+
+	assert.Empty(t, conversion.Snapshot())
+}
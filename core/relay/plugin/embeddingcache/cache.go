@@ -0,0 +1,338 @@
+package embeddingcache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/conv"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/labring/aiproxy/core/relay/plugin"
+	"github.com/labring/aiproxy/core/relay/plugin/noop"
+	"github.com/labring/aiproxy/core/relay/utils"
+	gcache "github.com/patrickmn/go-cache"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	pluginName       = "embedding-cache"
+	stateKey         = "embedding_cache_state"
+	cacheHeader      = "X-Aiproxy-Embedding-Cache"
+	redisCachePrefix = "embedding_cache:"
+	defaultTTL       = 7 * 24 * time.Hour
+)
+
+// cache is the fallback when redis is not available
+var cache = gcache.New(defaultTTL, 10*time.Minute)
+
+// state is the cache lookup result of a request
+type state struct {
+	input *embeddingInput
+	// cached is the raw embedding of each item, empty when not cached
+	cached   []string
+	uncached []int
+}
+
+func (s *state) allHit() bool {
+	return len(s.uncached) == 0
+}
+
+func (s *state) headerValue() string {
+	switch {
+	case s.allHit():
+		return "hit"
+	case len(s.uncached) == len(s.cached):
+		return "miss"
+	default:
+		return "partial"
+	}
+}
+
+// EmbeddingCache caches the embedding of each input item by its content hash,
+// only the uncached items are sent upstream
+type EmbeddingCache struct {
+	noop.Noop
+	rdb         *redis.Client
+	configCache utils.PluginConfigCache[Config]
+}
+
+var _ plugin.Plugin = (*EmbeddingCache)(nil)
+
+// NewEmbeddingCachePlugin creates a new embedding cache plugin
+func NewEmbeddingCachePlugin(rdb *redis.Client) plugin.Plugin {
+	return &EmbeddingCache{rdb: rdb}
+}
+
+func (c *EmbeddingCache) getConfig(meta *meta.Meta) (*Config, error) {
+	pluginConfig, err := c.configCache.Load(meta, pluginName, Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pluginConfig, nil
+}
+
+func getState(meta *meta.Meta) *state {
+	v, ok := meta.Get(stateKey)
+	if !ok || v == nil {
+		return nil
+	}
+
+	s, ok := v.(*state)
+	if !ok {
+		panic(fmt.Sprintf("embedding cache state type not match: %T", v))
+	}
+
+	return s
+}
+
+func (c *EmbeddingCache) ttl(pluginConfig *Config) time.Duration {
+	if pluginConfig.TTL <= 0 {
+		return defaultTTL
+	}
+
+	return time.Duration(pluginConfig.TTL) * time.Second
+}
+
+// lookup returns the cached embeddings of the keys, an empty string means not cached
+func (c *EmbeddingCache) lookup(ctx context.Context, keys []string) []string {
+	cached := make([]string, len(keys))
+
+	if c.rdb != nil {
+		redisKeys := make([]string, len(keys))
+		for i, key := range keys {
+			redisKeys[i] = common.RedisKey(redisCachePrefix, key)
+		}
+
+		values, err := c.rdb.MGet(ctx, redisKeys...).Result()
+		if err == nil {
+			for i, v := range values {
+				if s, ok := v.(string); ok {
+					cached[i] = s
+				}
+			}
+
+			return cached
+		}
+		// If Redis fails, fallback to memory cache
+	}
+
+	for i, key := range keys {
+		if v, ok := cache.Get(key); ok {
+			if s, ok := v.(string); ok {
+				cached[i] = s
+			}
+		}
+	}
+
+	return cached
+}
+
+func (c *EmbeddingCache) store(
+	ctx context.Context,
+	input *embeddingInput,
+	fresh map[int]string,
+	ttl time.Duration,
+) {
+	if c.rdb != nil {
+		_, err := c.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+			for index, embedding := range fresh {
+				p.Set(ctx, common.RedisKey(redisCachePrefix, input.keys[index]), embedding, ttl)
+			}
+			return nil
+		})
+		if err == nil {
+			return
+		}
+		// If Redis fails, fallback to memory cache only
+	}
+
+	for index, embedding := range fresh {
+		cache.Set(input.keys[index], embedding, ttl)
+	}
+}
+
+// ConvertRequest looks up the cached embeddings and only converts the uncached items
+func (c *EmbeddingCache) ConvertRequest(
+	meta *meta.Meta,
+	store adaptor.Store,
+	req *http.Request,
+	do adaptor.ConvertRequest,
+) (adaptor.ConvertResult, error) {
+	if meta.Mode != mode.Embeddings {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	meta.Set(stateKey, nil)
+
+	pluginConfig, err := c.getConfig(meta)
+	if err != nil || !pluginConfig.Enable {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	body, err := common.GetRequestBodyReusable(req)
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	input, err := parseInput(body, meta.ActualModel)
+	if err != nil {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	s := &state{
+		input:  input,
+		cached: c.lookup(req.Context(), input.keys),
+	}
+	for i, embedding := range s.cached {
+		if embedding == "" {
+			s.uncached = append(s.uncached, i)
+		}
+	}
+
+	meta.Set(stateKey, s)
+
+	if s.allHit() {
+		return adaptor.ConvertResult{}, nil
+	}
+
+	if len(s.uncached) == len(input.items) {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	uncachedBody, err := input.buildRequestBody(s.uncached)
+	if err != nil {
+		meta.Set(stateKey, nil)
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	common.SetRequestBody(req, uncachedBody)
+	defer func() {
+		common.SetRequestBody(req, body)
+	}()
+
+	return do.ConvertRequest(meta, store, req)
+}
+
+// DoRequest skips the upstream request when all items are cached
+func (c *EmbeddingCache) DoRequest(
+	meta *meta.Meta,
+	store adaptor.Store,
+	ctx *gin.Context,
+	req *http.Request,
+	do adaptor.DoRequest,
+) (*http.Response, error) {
+	if s := getState(meta); s != nil && s.allHit() {
+		return &http.Response{}, nil
+	}
+
+	return do.DoRequest(meta, store, ctx, req)
+}
+
+// responseWriter buffers the upstream response so the cached embeddings can be merged
+type responseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// ignore WriteHeaderNow
+func (rw *responseWriter) WriteHeaderNow() {}
+
+// ignore flush
+func (rw *responseWriter) Flush() {}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	return rw.body.Write(b)
+}
+
+func (rw *responseWriter) WriteString(s string) (int, error) {
+	return rw.Write(conv.StringToBytes(s))
+}
+
+func (c *EmbeddingCache) writeCacheHeader(ctx *gin.Context, pluginConfig *Config, value string) {
+	if pluginConfig.AddCacheHitHeader {
+		header := pluginConfig.CacheHitHeader
+		if header == "" {
+			header = cacheHeader
+		}
+
+		ctx.Header(header, value)
+	}
+}
+
+func writeBody(ctx *gin.Context, body []byte) {
+	ctx.Header("Content-Type", "application/json")
+	ctx.Header("Content-Length", strconv.Itoa(len(body)))
+	_, _ = ctx.Writer.Write(body)
+}
+
+// DoResponse merges the cached embeddings with the upstream response and caches the new ones
+func (c *EmbeddingCache) DoResponse(
+	meta *meta.Meta,
+	store adaptor.Store,
+	ctx *gin.Context,
+	resp *http.Response,
+	do adaptor.DoResponse,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	s := getState(meta)
+	if s == nil {
+		return do.DoResponse(meta, store, ctx, resp)
+	}
+
+	pluginConfig, err := c.getConfig(meta)
+	if err != nil {
+		return do.DoResponse(meta, store, ctx, resp)
+	}
+
+	c.writeCacheHeader(ctx, pluginConfig, s.headerValue())
+
+	if s.allHit() {
+		modelName, _ := sonic.MarshalString(meta.OriginModel)
+		writeBody(ctx, conv.StringToBytes(
+			`{"object":"list","model":`+modelName+
+				`,"data":`+buildData(s.cached)+
+				`,"usage":{"prompt_tokens":0,"total_tokens":0}}`,
+		))
+
+		return adaptor.DoResponseResult{Usage: model.Usage{}}, nil
+	}
+
+	rw := &responseWriter{ResponseWriter: ctx.Writer}
+
+	ctx.Writer = rw
+	result, relayErr := do.DoResponse(meta, store, ctx, resp)
+	ctx.Writer = rw.ResponseWriter
+
+	body := rw.body.Bytes()
+
+	if relayErr != nil {
+		if len(body) > 0 {
+			_, _ = ctx.Writer.Write(body)
+		}
+
+		return result, relayErr
+	}
+
+	merged, fresh, err := mergeResponse(body, s.cached, s.uncached)
+	if err != nil {
+		common.GetLogger(ctx).Warnf("merge embedding cache failed: %v", err)
+		writeBody(ctx, body)
+
+		return result, nil
+	}
+
+	c.store(ctx.Request.Context(), s.input, fresh, c.ttl(pluginConfig))
+
+	writeBody(ctx, merged)
+
+	return result, nil
+}
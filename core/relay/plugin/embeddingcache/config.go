@@ -0,0 +1,9 @@
+package embeddingcache
+
+type Config struct {
+	Enable bool `json:"enable"`
+	// TTL of the cached vectors in seconds, default is 7 days
+	TTL               int    `json:"ttl"`
+	AddCacheHitHeader bool   `json:"add_cache_hit_header"`
+	CacheHitHeader    string `json:"cache_hit_header"`
+}
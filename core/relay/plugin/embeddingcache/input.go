@@ -0,0 +1,241 @@
+package embeddingcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/bytedance/sonic/ast"
+)
+
+// embeddingInput is the input of an embeddings request split into items,
+// each item is cached by itself
+type embeddingInput struct {
+	root ast.Node
+	// items are the raw json of each input item
+	items []string
+	keys  []string
+	// batch is false when the input is a single string or a single token array
+	batch bool
+}
+
+func parseInput(body []byte, modelName string) (*embeddingInput, error) {
+	root, err := sonic.Get(body)
+	if err != nil {
+		return nil, err
+	}
+
+	inputNode := root.Get("input")
+	if err := inputNode.Check(); err != nil {
+		return nil, err
+	}
+
+	encodingFormat, _ := root.Get("encoding_format").String()
+	if encodingFormat == "" {
+		encodingFormat = "float"
+	}
+
+	dimensions, _ := root.Get("dimensions").Int64()
+
+	input := &embeddingInput{root: root}
+
+	switch inputNode.TypeSafe() {
+	case ast.V_STRING:
+		raw, err := inputNode.Raw()
+		if err != nil {
+			return nil, err
+		}
+
+		input.items = []string{raw}
+	case ast.V_ARRAY:
+		elements, err := inputNode.ArrayUseNode()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(elements) == 0 {
+			return nil, errors.New("empty input")
+		}
+
+		// a flat number array is a single token array
+		if elements[0].TypeSafe() == ast.V_NUMBER {
+			raw, err := inputNode.Raw()
+			if err != nil {
+				return nil, err
+			}
+
+			input.items = []string{raw}
+
+			break
+		}
+
+		input.batch = true
+		input.items = make([]string, 0, len(elements))
+
+		for _, element := range elements {
+			raw, err := element.Raw()
+			if err != nil {
+				return nil, err
+			}
+
+			input.items = append(input.items, raw)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported input type: %d", inputNode.TypeSafe())
+	}
+
+	input.keys = make([]string, 0, len(input.items))
+	for _, item := range input.items {
+		input.keys = append(input.keys, itemKey(modelName, encodingFormat, dimensions, item))
+	}
+
+	return input, nil
+}
+
+// normalizeItem trims the text input and removes the whitespace of token arrays,
+// so the same content always has the same hash
+func normalizeItem(raw string) string {
+	var s string
+	if err := sonic.UnmarshalString(raw, &s); err == nil {
+		return strings.TrimSpace(s)
+	}
+
+	return strings.Join(strings.Fields(raw), "")
+}
+
+func itemKey(modelName, encodingFormat string, dimensions int64, raw string) string {
+	h := sha256.New()
+	h.Write([]byte(modelName))
+	h.Write([]byte{0})
+	h.Write([]byte(encodingFormat))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatInt(dimensions, 10)))
+	h.Write([]byte{0})
+	h.Write([]byte(normalizeItem(raw)))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// buildRequestBody replaces the input of the request with the uncached items
+func (i *embeddingInput) buildRequestBody(uncached []int) ([]byte, error) {
+	var sb strings.Builder
+
+	sb.WriteByte('[')
+
+	for n, index := range uncached {
+		if n > 0 {
+			sb.WriteByte(',')
+		}
+
+		sb.WriteString(i.items[index])
+	}
+
+	sb.WriteByte(']')
+
+	root := i.root
+	if _, err := root.Set("input", ast.NewRaw(sb.String())); err != nil {
+		return nil, err
+	}
+
+	return root.MarshalJSON()
+}
+
+// parseEmbeddings returns the raw embeddings of the response by their index
+func parseEmbeddings(body []byte) (map[int]string, error) {
+	node, err := sonic.Get(body, "data")
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := node.ArrayUseNode()
+	if err != nil {
+		return nil, err
+	}
+
+	embeddings := make(map[int]string, len(items))
+	for pos, item := range items {
+		index := pos
+		if i, err := item.Get("index").Int64(); err == nil {
+			index = int(i)
+		}
+
+		raw, err := item.Get("embedding").Raw()
+		if err != nil {
+			return nil, err
+		}
+
+		embeddings[index] = raw
+	}
+
+	return embeddings, nil
+}
+
+// buildData builds the data field of the response in the order of the input
+func buildData(embeddings []string) string {
+	var sb strings.Builder
+
+	sb.WriteByte('[')
+
+	for index, embedding := range embeddings {
+		if index > 0 {
+			sb.WriteByte(',')
+		}
+
+		sb.WriteString(`{"object":"embedding","index":`)
+		sb.WriteString(strconv.Itoa(index))
+		sb.WriteString(`,"embedding":`)
+		sb.WriteString(embedding)
+		sb.WriteByte('}')
+	}
+
+	sb.WriteByte(']')
+
+	return sb.String()
+}
+
+// mergeResponse merges the cached embeddings into the upstream response,
+// the upstream response only contains the embeddings of the uncached items
+func mergeResponse(
+	body []byte,
+	cached []string,
+	uncached []int,
+) (merged []byte, fresh map[int]string, err error) {
+	upstream, err := parseEmbeddings(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	embeddings := make([]string, len(cached))
+	copy(embeddings, cached)
+
+	fresh = make(map[int]string, len(uncached))
+	for n, index := range uncached {
+		embedding, ok := upstream[n]
+		if !ok {
+			return nil, nil, fmt.Errorf("embedding of input %d not found in response", n)
+		}
+
+		embeddings[index] = embedding
+		fresh[index] = embedding
+	}
+
+	root, err := sonic.Get(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := root.Set("data", ast.NewRaw(buildData(embeddings))); err != nil {
+		return nil, nil, err
+	}
+
+	merged, err = root.MarshalJSON()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return merged, fresh, nil
+}
@@ -0,0 +1,95 @@
+//nolint:testpackage
+package embeddingcache
+
+import (
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInput(t *testing.T) {
+	t.Parallel()
+
+	single, err := parseInput([]byte(`{"model":"m","input":" hello "}`), "m")
+	require.NoError(t, err)
+	assert.False(t, single.batch)
+	assert.Len(t, single.items, 1)
+
+	batch, err := parseInput([]byte(`{"model":"m","input":["hello","world"]}`), "m")
+	require.NoError(t, err)
+	assert.True(t, batch.batch)
+	assert.Len(t, batch.items, 2)
+	assert.Equal(t, single.keys[0], batch.keys[0], "text input is normalized")
+	assert.NotEqual(t, batch.keys[0], batch.keys[1])
+
+	tokens, err := parseInput([]byte(`{"model":"m","input":[1, 2, 3]}`), "m")
+	require.NoError(t, err)
+	assert.False(t, tokens.batch)
+	assert.Len(t, tokens.items, 1)
+
+	dimensions, err := parseInput([]byte(`{"model":"m","input":"hello","dimensions":256}`), "m")
+	require.NoError(t, err)
+	assert.NotEqual(t, single.keys[0], dimensions.keys[0])
+
+	otherModel, err := parseInput([]byte(`{"model":"m","input":"hello"}`), "other")
+	require.NoError(t, err)
+	assert.NotEqual(t, single.keys[0], otherModel.keys[0])
+
+	_, err = parseInput([]byte(`{"model":"m","input":[]}`), "m")
+	require.Error(t, err)
+}
+
+func TestBuildRequestBody(t *testing.T) {
+	t.Parallel()
+
+	input, err := parseInput([]byte(`{"model":"m","input":["a","b","c"]}`), "m")
+	require.NoError(t, err)
+
+	body, err := input.buildRequestBody([]int{0, 2})
+	require.NoError(t, err)
+
+	var req struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}
+	require.NoError(t, sonic.Unmarshal(body, &req))
+	assert.Equal(t, "m", req.Model)
+	assert.Equal(t, []string{"a", "c"}, req.Input)
+}
+
+func TestMergeResponse(t *testing.T) {
+	t.Parallel()
+
+	upstream := []byte(`{"object":"list","model":"m","data":[` +
+		`{"object":"embedding","index":1,"embedding":[0.3]},` +
+		`{"object":"embedding","index":0,"embedding":[0.1]}` +
+		`],"usage":{"prompt_tokens":2,"total_tokens":2}}`)
+
+	merged, fresh, err := mergeResponse(upstream, []string{"", "[0.2]", ""}, []int{0, 2})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{0: "[0.1]", 2: "[0.3]"}, fresh)
+
+	var resp struct {
+		Data []struct {
+			Embedding []float64 `json:"embedding"`
+			Index     int       `json:"index"`
+		} `json:"data"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	require.NoError(t, sonic.Unmarshal(merged, &resp))
+	require.Len(t, resp.Data, 3)
+
+	for i, item := range resp.Data {
+		assert.Equal(t, i, item.Index)
+		assert.InDelta(t, float64(i+1)/10, item.Embedding[0], 1e-9)
+	}
+
+	assert.Equal(t, 2, resp.Usage.TotalTokens)
+
+	_, _, err = mergeResponse(upstream, []string{"", "", "", ""}, []int{0, 1, 2})
+	require.Error(t, err)
+}
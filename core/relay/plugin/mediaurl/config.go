@@ -0,0 +1,13 @@
+package mediaurl
+
+const PluginName = "media-url"
+
+// Config represents the plugin configuration
+type Config struct {
+	Enable bool `json:"enable"`
+	// DefaultURL returns urls for the image requests without response_format,
+	// e.g. for the models that only return b64_json
+	DefaultURL bool `json:"default_url,omitempty"`
+	// Audio returns the speech as a signed url instead of the audio data
+	Audio bool `json:"audio,omitempty"`
+}
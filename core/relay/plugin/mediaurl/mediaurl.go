@@ -0,0 +1,370 @@
+package mediaurl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/bytedance/sonic/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/common/conv"
+	"github.com/labring/aiproxy/core/common/mediastore"
+	"github.com/labring/aiproxy/core/common/network"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/labring/aiproxy/core/relay/plugin"
+	"github.com/labring/aiproxy/core/relay/plugin/noop"
+	"github.com/labring/aiproxy/core/relay/utils"
+)
+
+var _ plugin.Plugin = (*MediaURL)(nil)
+
+// MediaURL stores the generated images and audio and returns short-lived
+// signed urls instead of the base64 or binary data
+type MediaURL struct {
+	noop.Noop
+	configCache utils.PluginConfigCache[Config]
+}
+
+func NewMediaURLPlugin() plugin.Plugin {
+	return &MediaURL{}
+}
+
+const mediaURLKey = "media_url"
+
+// SpeechURLResponse replaces the audio of the speech requests
+type SpeechURLResponse struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	// ExpiresAt is the unix seconds the url expires at
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+func (p *MediaURL) getConfig(meta *meta.Meta) (Config, error) {
+	return p.configCache.Load(meta, PluginName, Config{})
+}
+
+// requestFields reads response_format and the stream fields of the request
+func requestFields(req *http.Request) (responseFormat string, stream bool, err error) {
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := common.ParseMultipartFormWithLimit(req); err != nil {
+			return "", false, err
+		}
+
+		return req.FormValue("response_format"), req.FormValue("stream") == "true", nil
+	}
+
+	node, err := common.UnmarshalRequest2NodeReusable(req)
+	if err != nil {
+		return "", false, err
+	}
+
+	responseFormat, _ = node.Get("response_format").String()
+	stream, _ = node.Get("stream").Bool()
+
+	if streamFormat, _ := node.Get("stream_format").String(); streamFormat == "sse" {
+		stream = true
+	}
+
+	return responseFormat, stream, nil
+}
+
+func (p *MediaURL) ConvertRequest(
+	meta *meta.Meta,
+	store adaptor.Store,
+	req *http.Request,
+	do adaptor.ConvertRequest,
+) (adaptor.ConvertResult, error) {
+	if !isMediaMode(meta.Mode) || mediastore.Default() == nil {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	pluginConfig, err := p.getConfig(meta)
+	if err != nil || !pluginConfig.Enable {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	if meta.Mode == mode.AudioSpeech && !pluginConfig.Audio {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	responseFormat, stream, err := requestFields(req)
+	if err != nil || stream {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	switch {
+	case meta.Mode == mode.AudioSpeech:
+		meta.Set(mediaURLKey, true)
+	case responseFormat == "url",
+		responseFormat == "" && pluginConfig.DefaultURL:
+		meta.Set(mediaURLKey, true)
+
+		b64Req, err := upstreamB64Request(req)
+		if err != nil {
+			return adaptor.ConvertResult{}, err
+		}
+
+		req = b64Req
+	}
+
+	return do.ConvertRequest(meta, store, req)
+}
+
+// upstreamB64Request returns the request asking the upstream for b64_json
+// images, the plugin stores them and returns the signed urls, so the providers
+// only returning base64 images serve the url requests too, and the images the
+// upstream returns as urls are downloaded by the adaptor
+func upstreamB64Request(req *http.Request) (*http.Request, error) {
+	b64Req := req.Clone(req.Context())
+
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		if b64Req.MultipartForm != nil {
+			b64Req.MultipartForm.Value["response_format"] = []string{"b64_json"}
+		}
+
+		if b64Req.PostForm != nil {
+			b64Req.PostForm.Set("response_format", "b64_json")
+		}
+
+		if b64Req.Form != nil {
+			b64Req.Form.Set("response_format", "b64_json")
+		}
+
+		return b64Req, nil
+	}
+
+	node, err := common.UnmarshalRequest2NodeReusable(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := node.Set("response_format", ast.NewString("b64_json")); err != nil {
+		return nil, err
+	}
+
+	body, err := node.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	common.SetRequestBody(b64Req, body)
+
+	return b64Req, nil
+}
+
+func isMediaMode(m mode.Mode) bool {
+	switch m {
+	case mode.ImagesGenerations, mode.ImagesEdits, mode.AudioSpeech:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *MediaURL) DoResponse(
+	meta *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+	do adaptor.DoResponse,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	if !meta.GetBool(mediaURLKey) {
+		return do.DoResponse(meta, store, c, resp)
+	}
+
+	rw := &bufferedResponseWriter{ResponseWriter: c.Writer}
+
+	c.Writer = rw
+	defer func() {
+		c.Writer = rw.ResponseWriter
+	}()
+
+	result, relayErr := do.DoResponse(meta, store, c, resp)
+	if relayErr != nil || rw.Status() != http.StatusOK {
+		if rw.body.Len() > 0 {
+			_, _ = rw.ResponseWriter.Write(rw.body.Bytes())
+		}
+
+		return result, relayErr
+	}
+
+	mediaStore := mediastore.Default()
+	baseURL := publicBaseURL(c)
+	ttl := mediastore.TTL()
+
+	var (
+		body []byte
+		err  error
+	)
+
+	if meta.Mode == mode.AudioSpeech {
+		body, err = speechToURL(
+			mediaStore,
+			baseURL,
+			ttl,
+			rw.body.Bytes(),
+			rw.Header().Get("Content-Type"),
+		)
+	} else {
+		body, err = imagesToURL(mediaStore, baseURL, ttl, rw.body.Bytes())
+	}
+
+	if err != nil {
+		common.GetLogger(c).Errorf("failed to store media: %v", err)
+
+		_, _ = rw.ResponseWriter.Write(rw.body.Bytes())
+
+		return result, nil
+	}
+
+	c.Header("Content-Type", "application/json")
+	c.Header("Content-Length", strconv.Itoa(len(body)))
+	_, _ = rw.ResponseWriter.Write(body)
+
+	return result, nil
+}
+
+// publicBaseURL returns MEDIA_PUBLIC_URL, or the scheme and the host of the
+// request, the X-Forwarded-Proto is honored from the TRUSTED_PROXIES like the
+// forwarded client ips, so from every peer when it is unset
+func publicBaseURL(c *gin.Context) string {
+	if config.MediaPublicURL != "" {
+		return config.MediaPublicURL
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+
+	if proto := c.GetHeader("X-Forwarded-Proto"); (proto == "http" || proto == "https") &&
+		network.IsTrustedProxy(c.RemoteIP(), config.TrustedProxies) {
+		scheme = proto
+	}
+
+	host := c.Request.Host
+	if defaultHost := config.GetDefaultHost(); defaultHost != "" {
+		host = defaultHost
+	}
+
+	return scheme + "://" + host
+}
+
+// imagesToURL stores the b64_json images of the response and replaces them
+// with signed urls, the images already returned as urls are kept
+func imagesToURL(
+	mediaStore *mediastore.Store,
+	baseURL string,
+	ttl time.Duration,
+	body []byte,
+) ([]byte, error) {
+	node, err := common.GetJSONNodeNoCopy(body)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := ""
+	if outputFormat, _ := node.Get("output_format").String(); outputFormat != "" {
+		contentType = "image/" + strings.Replace(outputFormat, "jpg", "jpeg", 1)
+	}
+
+	dataNode := node.Get("data")
+	if !dataNode.Exists() || dataNode.TypeSafe() != ast.V_ARRAY {
+		return nil, errors.New("response data is not an array")
+	}
+
+	var storeErr error
+
+	err = dataNode.ForEach(func(_ ast.Sequence, item *ast.Node) bool {
+		b64, _ := item.Get("b64_json").String()
+		if b64 == "" {
+			return true
+		}
+
+		data, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			storeErr = fmt.Errorf("decode b64_json: %w", err)
+			return false
+		}
+
+		key, err := mediaStore.Save(data, contentType)
+		if err != nil {
+			storeErr = err
+			return false
+		}
+
+		signedURL, _ := mediaStore.SignedURL(baseURL, key, ttl)
+
+		if _, err := item.Set("url", ast.NewString(signedURL)); err != nil {
+			storeErr = err
+			return false
+		}
+
+		if _, err := item.Unset("b64_json"); err != nil {
+			storeErr = err
+			return false
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if storeErr != nil {
+		return nil, storeErr
+	}
+
+	return node.MarshalJSON()
+}
+
+func speechToURL(
+	mediaStore *mediastore.Store,
+	baseURL string,
+	ttl time.Duration,
+	audio []byte,
+	contentType string,
+) ([]byte, error) {
+	key, err := mediaStore.Save(audio, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	signedURL, expiresAt := mediaStore.SignedURL(baseURL, key, ttl)
+
+	return sonic.Marshal(SpeechURLResponse{
+		URL:         signedURL,
+		ContentType: mediastore.ContentType(key),
+		ExpiresAt:   expiresAt.Unix(),
+	})
+}
+
+// bufferedResponseWriter holds the response until the media is stored
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// ignore flush
+func (rw *bufferedResponseWriter) Flush() {}
+
+// ignore WriteHeaderNow
+func (rw *bufferedResponseWriter) WriteHeaderNow() {}
+
+func (rw *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return rw.body.Write(b)
+}
+
+func (rw *bufferedResponseWriter) WriteString(s string) (int, error) {
+	return rw.body.Write(conv.StringToBytes(s))
+}
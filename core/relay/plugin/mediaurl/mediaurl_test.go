@@ -0,0 +1,208 @@
+//nolint:testpackage
+package mediaurl
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/common/mediastore"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pngData = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestImagesToURL(t *testing.T) {
+	t.Parallel()
+
+	mediaStore := mediastore.New(t.TempDir(), []byte("secret"))
+
+	body := `{"created":1,"output_format":"webp","data":[` +
+		`{"b64_json":"` + base64.StdEncoding.EncodeToString(pngData) + `","revised_prompt":"a cat"},` +
+		`{"url":"https://provider.example.com/image.png"}` +
+		`],"usage":{"total_tokens":10}}`
+
+	out, err := imagesToURL(mediaStore, "https://aiproxy.example.com", time.Hour, []byte(body))
+	require.NoError(t, err)
+
+	var resp struct {
+		Created int64 `json:"created"`
+		Data    []struct {
+			URL           string `json:"url"`
+			B64JSON       string `json:"b64_json"`
+			RevisedPrompt string `json:"revised_prompt"`
+		} `json:"data"`
+		Usage map[string]int `json:"usage"`
+	}
+	require.NoError(t, sonic.Unmarshal(out, &resp))
+
+	require.Len(t, resp.Data, 2)
+	assert.Empty(t, resp.Data[0].B64JSON)
+	assert.Equal(t, "a cat", resp.Data[0].RevisedPrompt)
+	assert.Equal(t, "https://provider.example.com/image.png", resp.Data[1].URL)
+	assert.Equal(t, 10, resp.Usage["total_tokens"])
+
+	u, err := url.Parse(resp.Data[0].URL)
+	require.NoError(t, err)
+
+	key := strings.TrimPrefix(u.Path, mediastore.PathPrefix)
+	assert.True(t, strings.HasSuffix(key, ".webp"))
+	require.NoError(t, mediaStore.Verify(key, u.Query().Get("expires"), u.Query().Get("signature")))
+
+	path, err := mediaStore.Path(key)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, pngData, data)
+
+	_, err = imagesToURL(mediaStore, "", time.Hour, []byte(`{"data":[{"b64_json":"%%%"}]}`))
+	require.Error(t, err)
+}
+
+func TestSpeechToURL(t *testing.T) {
+	t.Parallel()
+
+	mediaStore := mediastore.New(t.TempDir(), []byte("secret"))
+
+	out, err := speechToURL(mediaStore, "http://localhost:3000", time.Hour, []byte("ID3"), "audio/mpeg")
+	require.NoError(t, err)
+
+	var resp SpeechURLResponse
+	require.NoError(t, sonic.Unmarshal(out, &resp))
+
+	assert.Equal(t, "audio/mpeg", resp.ContentType)
+	assert.True(t, strings.HasPrefix(resp.URL, "http://localhost:3000"+mediastore.PathPrefix))
+	assert.Greater(t, resp.ExpiresAt, time.Now().Unix())
+}
+
+// b64OnlyUpstream rejects the url images like the providers only returning
+// base64 images, e.g. gpt-image-1
+type b64OnlyUpstream struct {
+	responseFormat string
+}
+
+func (u *b64OnlyUpstream) ConvertRequest(
+	_ *meta.Meta,
+	_ adaptor.Store,
+	req *http.Request,
+) (adaptor.ConvertResult, error) {
+	node, err := common.UnmarshalRequest2NodeReusable(req)
+	if err != nil {
+		return adaptor.ConvertResult{}, err
+	}
+
+	u.responseFormat, _ = node.Get("response_format").String()
+	if u.responseFormat == "url" {
+		return adaptor.ConvertResult{}, errors.New("response_format url is not supported")
+	}
+
+	return adaptor.ConvertResult{}, nil
+}
+
+func (u *b64OnlyUpstream) DoResponse(
+	_ *meta.Meta,
+	_ adaptor.Store,
+	c *gin.Context,
+	_ *http.Response,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	c.JSON(http.StatusOK, map[string]any{
+		"created": 1,
+		"data":    []any{map[string]any{"b64_json": base64.StdEncoding.EncodeToString(pngData)}},
+	})
+
+	return adaptor.DoResponseResult{}, nil
+}
+
+func TestMediaURLAsksB64OnlyUpstreamForB64(t *testing.T) {
+	config.MediaStorageDir = t.TempDir()
+	require.NotNil(t, mediastore.Default())
+
+	m := meta.NewMeta(nil, mode.ImagesGenerations, "gpt-image-1", model.ModelConfig{
+		Model:  "gpt-image-1",
+		Plugin: map[string]map[string]any{PluginName: {"enable": true}},
+	})
+
+	req := httptest.NewRequestWithContext(
+		t.Context(),
+		http.MethodPost,
+		"/v1/images/generations",
+		strings.NewReader(`{"model":"gpt-image-1","prompt":"a cat","response_format":"url"}`),
+	)
+	req.Header.Set("Content-Type", "application/json")
+
+	p := &MediaURL{}
+	upstream := &b64OnlyUpstream{}
+
+	_, err := p.ConvertRequest(m, nil, req, upstream)
+	require.NoError(t, err)
+	assert.Equal(t, "b64_json", upstream.responseFormat)
+
+	// the request of the client is kept for the logs and the retries
+	responseFormat, _, err := requestFields(req)
+	require.NoError(t, err)
+	assert.Equal(t, "url", responseFormat)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = req
+
+	_, relayErr := p.DoResponse(m, nil, c, nil, upstream)
+	require.Nil(t, relayErr)
+
+	var resp struct {
+		Data []struct {
+			URL     string `json:"url"`
+			B64JSON string `json:"b64_json"`
+		} `json:"data"`
+	}
+	require.NoError(t, sonic.Unmarshal(recorder.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1)
+	assert.Empty(t, resp.Data[0].B64JSON)
+	assert.Contains(t, resp.Data[0].URL, mediastore.PathPrefix)
+}
+
+func TestPublicBaseURLTrustsForwardedProtoFromTrustedProxies(t *testing.T) {
+	oldTrustedProxies := config.TrustedProxies
+
+	t.Cleanup(func() {
+		config.TrustedProxies = oldTrustedProxies
+	})
+
+	newContext := func(remoteAddr string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequestWithContext(
+			t.Context(),
+			http.MethodPost,
+			"http://aiproxy.example.com/v1/images/generations",
+			nil,
+		)
+		c.Request.RemoteAddr = remoteAddr
+		c.Request.Header.Set("X-Forwarded-Proto", "https")
+
+		return c
+	}
+
+	config.TrustedProxies = nil
+	assert.Equal(t, "https://aiproxy.example.com", publicBaseURL(newContext("203.0.113.1:1234")))
+
+	config.TrustedProxies = []string{"10.0.0.0/8", "192.168.1.1"}
+	assert.Equal(t, "https://aiproxy.example.com", publicBaseURL(newContext("10.0.0.1:1234")))
+	assert.Equal(t, "https://aiproxy.example.com", publicBaseURL(newContext("192.168.1.1:1234")))
+	assert.Equal(t, "http://aiproxy.example.com", publicBaseURL(newContext("203.0.113.1:1234")))
+}
@@ -0,0 +1,130 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/common/conv"
+	"github.com/labring/aiproxy/core/common/notify"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/monitor"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/meta"
+)
+
+// applyAutoBanRules evaluates the configured auto ban rules against the error
+// and executes the actions of the rules reaching their threshold
+func applyAutoBanRules(c *gin.Context, meta *meta.Meta, err error) {
+	rules := config.GetAutoBanRules()
+	if len(rules) == 0 {
+		return
+	}
+
+	event := monitor.AutoBanEvent{
+		Time:      time.Now(),
+		Model:     meta.OriginModel,
+		ChannelID: int64(meta.Channel.ID),
+		Message:   err.Error(),
+	}
+
+	var adaptorErr adaptor.Error
+	if errors.As(err, &adaptorErr) {
+		event.StatusCode = adaptorErr.StatusCode()
+		if body, marshalErr := adaptorErr.MarshalJSON(); marshalErr == nil {
+			event.Message = conv.BytesToString(body)
+		}
+	}
+
+	log := common.GetLogger(c)
+
+	triggers, evalErr := monitor.EvaluateAutoBanRules(context.Background(), rules, event)
+	if evalErr != nil {
+		log.Errorf("evaluate auto ban rules failed: %+v", evalErr)
+	}
+
+	for _, trigger := range triggers {
+		models := []string{meta.OriginModel}
+		if trigger.Rule.GetAction() == config.AutoBanActionBanChannel {
+			models = channelModels(meta.Channel.ID, meta.OriginModel)
+		}
+
+		banned := false
+
+		for _, modelName := range models {
+			ok, banErr := monitor.BanChannelModel(
+				context.Background(),
+				modelName,
+				int64(meta.Channel.ID),
+				trigger.Cooldown(),
+			)
+			if banErr != nil {
+				log.Errorf("auto ban rule %s ban failed: %+v", trigger.Rule.Name, banErr)
+				continue
+			}
+
+			banned = banned || ok
+		}
+
+		if banned {
+			notifyAutoBanRule(meta, trigger, event)
+		}
+	}
+}
+
+// channelModels returns the enabled models of the channel, the current model
+// is always included
+func channelModels(channelID int, current string) []string {
+	models := map[string]struct{}{current: {}}
+
+	for _, model2Channels := range model.LoadModelCaches().EnabledModel2ChannelsBySet {
+		for modelName, channels := range model2Channels {
+			for _, channel := range channels {
+				if channel.ID == channelID {
+					models[modelName] = struct{}{}
+					break
+				}
+			}
+		}
+	}
+
+	result := make([]string, 0, len(models))
+	for modelName := range models {
+		result = append(result, modelName)
+	}
+
+	return result
+}
+
+func notifyAutoBanRule(meta *meta.Meta, trigger monitor.AutoBanTrigger, event monitor.AutoBanEvent) {
+	target := fmt.Sprintf("`%s`", meta.OriginModel)
+	if trigger.Rule.GetAction() == config.AutoBanActionBanChannel {
+		target = "all models"
+	}
+
+	notify.ErrorThrottle(
+		fmt.Sprintf("autoBanRule:%s:%d:%s", trigger.Rule.Name, meta.Channel.ID, meta.OriginModel),
+		time.Minute*15,
+		fmt.Sprintf("%s %s Auto Banned By Rule %s", meta.Channel.Name, target, trigger.Rule.Name),
+		fmt.Sprintf(
+			"channel: %s (type: %d, type name: %s, id: %d)\nmodel: %s\nrule: %s\naction: %s\nmatches: %d in %ds\ncooldown: %s\nstatus code: %d\ndetail: %s\nrequest id: %s",
+			meta.Channel.Name,
+			meta.Channel.Type,
+			meta.Channel.Type.String(),
+			meta.Channel.ID,
+			meta.OriginModel,
+			trigger.Rule.Name,
+			trigger.Rule.GetAction(),
+			trigger.Count,
+			trigger.Rule.GetWindowSeconds(),
+			trigger.Cooldown().String(),
+			event.StatusCode,
+			event.Message,
+			meta.RequestID,
+		),
+	)
+}
@@ -0,0 +1,155 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/common/notify"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/monitor"
+	"github.com/labring/aiproxy/core/relay/meta"
+)
+
+const (
+	metaRequestCost  = "request_cost"
+	metaBreakerProbe = "breaker_probe"
+)
+
+// getBreakerConfig resolves the error budget of the channel-model pair,
+// model config overrides the channel which overrides the global options
+func getBreakerConfig(meta *meta.Meta) monitor.BreakerConfig {
+	cfg := monitor.BreakerConfig{
+		ErrorRate:    config.GetCircuitBreakerErrorRate(),
+		LatencySLO:   time.Duration(config.GetCircuitBreakerLatencySLOMs()) * time.Millisecond,
+		SlowRate:     config.GetCircuitBreakerSlowRate(),
+		OpenDuration: time.Duration(config.GetCircuitBreakerOpenSeconds()) * time.Second,
+	}
+
+	if meta.Channel.BreakerErrorRate > 0 {
+		cfg.ErrorRate = meta.Channel.BreakerErrorRate
+	}
+
+	if meta.Channel.BreakerLatencySLOMs > 0 {
+		cfg.LatencySLO = time.Duration(meta.Channel.BreakerLatencySLOMs) * time.Millisecond
+	}
+
+	if errorRate, ok := model.GetModelConfigFloat(
+		meta.ModelConfig.Config,
+		model.ModelConfigCircuitBreakerErrorRateKey,
+	); ok {
+		cfg.ErrorRate = errorRate
+	}
+
+	if latencySLO, ok := model.GetModelConfigInt(
+		meta.ModelConfig.Config,
+		model.ModelConfigCircuitBreakerLatencySLOKey,
+	); ok {
+		cfg.LatencySLO = time.Duration(latencySLO) * time.Millisecond
+	}
+
+	return cfg
+}
+
+// acquireBreaker reports whether the request may be sent to the channel, it is
+// not when the breaker is open or another request is probing it
+func acquireBreaker(meta *meta.Meta, c *gin.Context) bool {
+	transition, probe, ok := monitor.AcquireBreaker(
+		meta.OriginModel,
+		int64(meta.Channel.ID),
+		getBreakerConfig(meta),
+	)
+	handleBreakerTransition(meta, c, transition)
+
+	if probe {
+		meta.Set(metaBreakerProbe, true)
+	}
+
+	return ok
+}
+
+func recordBreaker(meta *meta.Meta, c *gin.Context, isError bool) {
+	requestCost, _ := meta.Get(metaRequestCost)
+	latency, _ := requestCost.(time.Duration)
+
+	transition := monitor.RecordBreaker(
+		meta.OriginModel,
+		int64(meta.Channel.ID),
+		isError,
+		latency,
+		getBreakerConfig(meta),
+		meta.GetBool(metaBreakerProbe),
+	)
+	handleBreakerTransition(meta, c, transition)
+}
+
+// releaseBreaker ends a request whose outcome says nothing of the channel,
+// e.g. a hedge loser cancelled by the proxy, its probe slot is freed
+func releaseBreaker(meta *meta.Meta) {
+	monitor.ReleaseBreaker(
+		meta.OriginModel,
+		int64(meta.Channel.ID),
+		meta.GetBool(metaBreakerProbe),
+	)
+}
+
+func handleBreakerTransition(
+	meta *meta.Meta,
+	c *gin.Context,
+	transition *monitor.BreakerTransition,
+) {
+	if transition == nil {
+		return
+	}
+
+	log := common.GetLogger(c)
+	log.Data["breaker"] = string(transition.To)
+	log.Warnf(
+		"circuit breaker of channel %d model %s changed from %s to %s, reason: %s",
+		transition.ChannelID,
+		transition.Model,
+		transition.From,
+		transition.To,
+		transition.Reason,
+	)
+
+	message := fmt.Sprintf(
+		"channel: %s (type: %d, type name: %s, id: %d)\nmodel: %s\nstate: %s -> %s\nreason: %s\nerror rate: %.2f\nslow rate: %.2f\nrequest id: %s",
+		meta.Channel.Name,
+		meta.Channel.Type,
+		meta.Channel.Type.String(),
+		meta.Channel.ID,
+		meta.OriginModel,
+		transition.From,
+		transition.To,
+		transition.Reason,
+		transition.ErrorRate,
+		transition.SlowRate,
+		meta.RequestID,
+	)
+	if !transition.OpenUntil.IsZero() {
+		message += "\nopen until: " + transition.OpenUntil.Format(time.RFC3339)
+	}
+
+	title := fmt.Sprintf(
+		"%s `%s` Circuit Breaker %s",
+		meta.Channel.Name,
+		meta.OriginModel,
+		transition.To,
+	)
+	lockKey := fmt.Sprintf(
+		"circuitBreaker:%d:%s:%s",
+		meta.Channel.ID,
+		meta.OriginModel,
+		transition.To,
+	)
+
+	switch transition.To {
+	case monitor.BreakerStateOpen:
+		notify.ErrorThrottle(lockKey, time.Minute, title, message)
+	case monitor.BreakerStateClosed:
+		notify.InfoThrottle(lockKey, time.Minute, title, message)
+	}
+}
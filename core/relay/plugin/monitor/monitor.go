@@ -17,6 +17,7 @@ import (
 	"github.com/labring/aiproxy/core/monitor"
 	"github.com/labring/aiproxy/core/relay/adaptor"
 	"github.com/labring/aiproxy/core/relay/meta"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
 	"github.com/labring/aiproxy/core/relay/plugin"
 	"github.com/labring/aiproxy/core/relay/plugin/noop"
 )
@@ -80,6 +81,15 @@ func (m *ChannelMonitor) DoRequest(
 	req *http.Request,
 	do adaptor.DoRequest,
 ) (*http.Response, error) {
+	// the error is retriable, so the retry picks another channel
+	if !acquireBreaker(meta, c) {
+		return nil, relaymodel.WrapperErrorWithMessage(
+			meta.Mode,
+			http.StatusServiceUnavailable,
+			fmt.Sprintf("circuit breaker of channel (id: %d) is open", meta.Channel.ID),
+		)
+	}
+
 	count, overLimitCount, secondCount := reqlimit.PushChannelModelRequest(
 		context.Background(),
 		strconv.Itoa(meta.Channel.ID),
@@ -93,6 +103,8 @@ func (m *ChannelMonitor) DoRequest(
 	resp, err := do.DoRequest(meta, store, c, req)
 
 	requestCost := common.TruncateDuration(time.Since(requestAt))
+	meta.Set(metaRequestCost, requestCost)
+
 	log := common.GetLogger(c)
 	log.Data["req_cost"] = requestCost.String()
 
@@ -100,11 +112,19 @@ func (m *ChannelMonitor) DoRequest(
 		return resp, nil
 	}
 
+	if hedgeLost(c) {
+		releaseBreaker(meta)
+		return resp, err
+	}
+
+	applyAutoBanRules(c, meta, err)
+
 	var adaptorErr adaptor.Error
 
 	ok := errors.As(err, &adaptorErr)
 	if ok {
 		if !ShouldRetry(adaptorErr) {
+			recordBreaker(meta, c, false)
 			return resp, err
 		}
 
@@ -113,9 +133,18 @@ func (m *ChannelMonitor) DoRequest(
 		handleDoRequestError(meta, c, err, requestCost)
 	}
 
+	recordBreaker(meta, c, true)
+
 	return resp, err
 }
 
+// hedgeLost reports whether the attempt lost the race of a hedged request,
+// its cancellation is not counted against the channel
+func hedgeLost(c *gin.Context) bool {
+	return c.Request != nil &&
+		errors.Is(context.Cause(c.Request.Context()), adaptor.ErrHedgeLost)
+}
+
 func handleDoRequestError(meta *meta.Meta, c *gin.Context, err error, requestCost time.Duration) {
 	warnErrorRate := getChannelWarnErrorRate(meta)
 	maxErrorRate := getChannelMaxErrorRate(meta)
@@ -232,14 +261,25 @@ func (m *ChannelMonitor) DoResponse(
 			common.GetLogger(c).Errorf("add request failed: %+v", err)
 		}
 
+		recordBreaker(meta, c, false)
+
 		return result, nil
 	}
 
+	if hedgeLost(c) {
+		releaseBreaker(meta)
+		return result, relayErr
+	}
+
+	applyAutoBanRules(c, meta, relayErr)
+
 	if !ShouldRetry(relayErr) {
+		recordBreaker(meta, c, false)
 		return result, relayErr
 	}
 
 	handleAdaptorError(meta, c, relayErr)
+	recordBreaker(meta, c, true)
 
 	return result, relayErr
 }
@@ -0,0 +1,44 @@
+package promptcompress
+
+// Config represents the plugin configuration
+type Config struct {
+	Enable bool `json:"enable"`
+	// Threshold is the fraction of the context window above which the prompt is compressed
+	Threshold float64 `json:"threshold,omitempty"`
+	// ContextTokens overrides the context window of the model config
+	ContextTokens int `json:"context_tokens,omitempty"`
+	// KeepRecentMessages is the number of the latest messages kept as is
+	KeepRecentMessages int `json:"keep_recent_messages,omitempty"`
+	// SummaryModel summarizes the older messages, the requested model is used when empty
+	SummaryModel     string `json:"summary_model,omitempty"`
+	SummaryMaxTokens int    `json:"summary_max_tokens,omitempty"`
+	SummaryPrompt    string `json:"summary_prompt,omitempty"`
+}
+
+const (
+	defaultThreshold          = 0.8
+	defaultKeepRecentMessages = 6
+	defaultSummaryMaxTokens   = 1024
+	defaultSummaryPrompt      = "You compress conversation history. Summarize the conversation below " +
+		"into a concise note that keeps every fact, decision, open question, name, number and " +
+		"code identifier needed to continue the conversation. Write the summary in the language " +
+		"of the conversation and output the summary only."
+)
+
+func (c *Config) ApplyDefaults() {
+	if c.Threshold <= 0 || c.Threshold > 1 {
+		c.Threshold = defaultThreshold
+	}
+
+	if c.KeepRecentMessages <= 0 {
+		c.KeepRecentMessages = defaultKeepRecentMessages
+	}
+
+	if c.SummaryMaxTokens <= 0 {
+		c.SummaryMaxTokens = defaultSummaryMaxTokens
+	}
+
+	if c.SummaryPrompt == "" {
+		c.SummaryPrompt = defaultSummaryPrompt
+	}
+}
@@ -0,0 +1,443 @@
+package promptcompress
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/consume"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptor/openai"
+	"github.com/labring/aiproxy/core/relay/adaptors"
+	"github.com/labring/aiproxy/core/relay/controller"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	"github.com/labring/aiproxy/core/relay/plugin"
+	"github.com/labring/aiproxy/core/relay/plugin/noop"
+	"github.com/labring/aiproxy/core/relay/utils"
+)
+
+var _ plugin.Plugin = (*PromptCompress)(nil)
+
+type (
+	GetChannel     func(modelName string) (*model.Channel, error)
+	GetModelConfig func(modelName string) (model.ModelConfig, bool)
+)
+
+// PromptCompress summarizes the older turns of a long conversation with a
+// cheap model when the prompt gets close to the context window of the model
+type PromptCompress struct {
+	noop.Noop
+	GetChannel     GetChannel
+	GetModelConfig GetModelConfig
+	configCache    utils.PluginConfigCache[Config]
+}
+
+// NewPromptCompressPlugin creates a new prompt compress plugin
+func NewPromptCompressPlugin(getChannel GetChannel, getModelConfig GetModelConfig) plugin.Plugin {
+	return &PromptCompress{
+		GetChannel:     getChannel,
+		GetModelConfig: getModelConfig,
+	}
+}
+
+const compressionKey = "prompt-compress"
+
+// compression is the result of the compression stored in the meta
+type compression struct {
+	SummaryModel string
+	TokensBefore int64
+	TokensAfter  int64
+	SummaryUsage model.Usage
+	SummaryPrice model.Price
+}
+
+func setCompression(m *meta.Meta, c compression) {
+	m.Set(compressionKey, c)
+}
+
+func getCompression(m *meta.Meta) (compression, bool) {
+	v, ok := m.Get(compressionKey)
+	if !ok {
+		return compression{}, false
+	}
+
+	c, ok := v.(compression)
+	if !ok {
+		panic(fmt.Sprintf("compression type %T is not a compression", v))
+	}
+
+	return c, true
+}
+
+func (p *PromptCompress) getConfig(meta *meta.Meta) (Config, error) {
+	return p.configCache.Load(meta, "prompt-compress", Config{})
+}
+
+// contextTokens returns the context window used to decide whether to compress
+func contextTokens(config Config, modelConfig model.ModelConfig) int {
+	if config.ContextTokens > 0 {
+		return config.ContextTokens
+	}
+
+	if maxInput, ok := modelConfig.MaxInputTokens(); ok && maxInput > 0 {
+		return maxInput
+	}
+
+	if maxContext, ok := modelConfig.MaxContextTokens(); ok && maxContext > 0 {
+		return maxContext
+	}
+
+	return 0
+}
+
+// SplitMessages returns the range [start, end) of the messages to summarize,
+// the leading system messages and the latest keepRecent messages are kept,
+// the kept messages never start with a tool result, so that the tool result
+// stays together with the assistant message calling the tool
+func SplitMessages(messages []relaymodel.Message, keepRecent int) (int, int, bool) {
+	start := 0
+	for start < len(messages) &&
+		(messages[start].Role == relaymodel.RoleSystem ||
+			messages[start].Role == relaymodel.RoleDeveloper) {
+		start++
+	}
+
+	end := max(start, len(messages)-keepRecent)
+	for end > start && messages[end].Role == relaymodel.RoleTool {
+		end--
+	}
+
+	// summarizing a single message saves nearly nothing
+	if end-start < 2 {
+		return 0, 0, false
+	}
+
+	return start, end, true
+}
+
+// BuildTranscript renders the messages to summarize as plain text
+func BuildTranscript(messages []relaymodel.Message) string {
+	var sb strings.Builder
+
+	for _, message := range messages {
+		// the reasoning is not part of the conversation
+		message.ReasoningContent = ""
+
+		sb.WriteString(message.Role)
+		sb.WriteString(": ")
+		sb.WriteString(strings.TrimSpace(message.StringContent()))
+
+		for _, toolCall := range message.ToolCalls {
+			sb.WriteString("\n[tool call ")
+			sb.WriteString(toolCall.Function.Name)
+			sb.WriteString("] ")
+			sb.WriteString(toolCall.Function.Arguments)
+		}
+
+		sb.WriteString("\n\n")
+	}
+
+	return sb.String()
+}
+
+func fallback(
+	m *meta.Meta,
+	store adaptor.Store,
+	req *http.Request,
+	do adaptor.ConvertRequest,
+	reason string,
+) (adaptor.ConvertResult, error) {
+	common.GetLoggerFromReq(req).Warnf("prompt-compress: skipped, reason: %s", reason)
+	return do.ConvertRequest(m, store, req)
+}
+
+// ConvertRequest replaces the older turns with a summary when the prompt
+// exceeds the configured fraction of the context window
+func (p *PromptCompress) ConvertRequest(
+	meta *meta.Meta,
+	store adaptor.Store,
+	req *http.Request,
+	do adaptor.ConvertRequest,
+) (adaptor.ConvertResult, error) {
+	if meta.Mode != mode.ChatCompletions {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	pluginConfig, err := p.getConfig(meta)
+	if err != nil || !pluginConfig.Enable {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	pluginConfig.ApplyDefaults()
+
+	contextWindow := contextTokens(pluginConfig, meta.ModelConfig)
+	if contextWindow <= 0 {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	body, err := common.GetRequestBodyReusable(req)
+	if err != nil {
+		return adaptor.ConvertResult{}, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	var chatRequest map[string]any
+	if err := sonic.Unmarshal(body, &chatRequest); err != nil {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	var typedRequest struct {
+		Messages []relaymodel.Message `json:"messages"`
+	}
+	if err := sonic.Unmarshal(body, &typedRequest); err != nil {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	rawMessages, ok := chatRequest["messages"].([]any)
+	if !ok || len(rawMessages) != len(typedRequest.Messages) {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	tokensBefore := openai.CountTokenMessages(typedRequest.Messages, meta.ActualModel, false)
+	if float64(tokensBefore) < float64(contextWindow)*pluginConfig.Threshold {
+		return do.ConvertRequest(meta, store, req)
+	}
+
+	start, end, ok := SplitMessages(typedRequest.Messages, pluginConfig.KeepRecentMessages)
+	if !ok {
+		return fallback(meta, store, req, do, "not enough messages to compress")
+	}
+
+	summary, summaryUsage, err := p.Summarize(
+		meta,
+		store,
+		pluginConfig,
+		BuildTranscript(typedRequest.Messages[start:end]),
+	)
+	if err != nil {
+		return fallback(meta, store, req, do, fmt.Sprintf("summarize failed: %v", err))
+	}
+
+	if summary == "" {
+		return fallback(meta, store, req, do, "empty summary")
+	}
+
+	summaryMessage := relaymodel.Message{
+		Role:    relaymodel.RoleSystem,
+		Content: "Summary of the earlier conversation:\n" + summary,
+	}
+
+	compressedMessages := make([]relaymodel.Message, 0, len(typedRequest.Messages)-(end-start)+1)
+	compressedMessages = append(compressedMessages, typedRequest.Messages[:start]...)
+	compressedMessages = append(compressedMessages, summaryMessage)
+	compressedMessages = append(compressedMessages, typedRequest.Messages[end:]...)
+
+	newRawMessages := make([]any, 0, len(compressedMessages))
+	newRawMessages = append(newRawMessages, rawMessages[:start]...)
+	newRawMessages = append(newRawMessages, map[string]any{
+		"role":    summaryMessage.Role,
+		"content": summaryMessage.Content,
+	})
+	newRawMessages = append(newRawMessages, rawMessages[end:]...)
+	chatRequest["messages"] = newRawMessages
+
+	modifiedBody, err := sonic.Marshal(chatRequest)
+	if err != nil {
+		return fallback(meta, store, req, do, fmt.Sprintf("marshal failed: %v", err))
+	}
+
+	summaryModel, summaryPrice := p.SummaryModelPrice(meta, pluginConfig)
+
+	setCompression(meta, compression{
+		SummaryModel: summaryModel,
+		TokensBefore: tokensBefore,
+		TokensAfter:  openai.CountTokenMessages(compressedMessages, meta.ActualModel, false),
+		SummaryUsage: summaryUsage,
+		SummaryPrice: summaryPrice,
+	})
+
+	common.SetRequestBody(req, modifiedBody)
+	defer common.SetRequestBody(req, body)
+
+	return do.ConvertRequest(meta, store, req)
+}
+
+// SummaryModelPrice returns the model and the price of the summary request
+func (p *PromptCompress) SummaryModelPrice(m *meta.Meta, config Config) (string, model.Price) {
+	if config.SummaryModel == "" {
+		return m.OriginModel, m.ModelConfig.Price
+	}
+
+	if p.GetModelConfig != nil {
+		if modelConfig, ok := p.GetModelConfig(config.SummaryModel); ok {
+			return config.SummaryModel, modelConfig.Price
+		}
+	}
+
+	return config.SummaryModel, model.Price{}
+}
+
+// Summarize sends the transcript to the summary model
+func (p *PromptCompress) Summarize(
+	m *meta.Meta,
+	store adaptor.Store,
+	config Config,
+	transcript string,
+) (string, model.Usage, error) {
+	modelName := config.SummaryModel
+	if modelName == "" {
+		modelName = m.OriginModel
+	}
+
+	summaryBody, err := sonic.Marshal(map[string]any{
+		"stream":     false,
+		"max_tokens": config.SummaryMaxTokens,
+		"model":      modelName,
+		"messages": []map[string]any{
+			{
+				"role":    relaymodel.RoleSystem,
+				"content": config.SummaryPrompt,
+			},
+			{
+				"role":    relaymodel.RoleUser,
+				"content": transcript,
+			},
+		},
+	})
+	if err != nil {
+		return "", model.Usage{}, err
+	}
+
+	w := httptest.NewRecorder()
+	newc, _ := gin.CreateTestContext(w)
+	newc.Request = &http.Request{
+		URL:    &url.URL{},
+		Body:   io.NopCloser(bytes.NewReader(summaryBody)),
+		Header: make(http.Header),
+	}
+	middleware.SetRequestID(newc, "prompt-compress")
+
+	newMeta := meta.NewMeta(
+		nil,
+		mode.ChatCompletions,
+		modelName,
+		model.ModelConfig{
+			Model: modelName,
+			Type:  mode.ChatCompletions,
+		},
+		meta.WithRequestID("prompt-compress"),
+	)
+
+	if config.SummaryModel == "" {
+		newMeta.CopyChannelFromMeta(m)
+	} else {
+		if p.GetChannel == nil {
+			return "", model.Usage{}, errors.New("get channel is not set")
+		}
+
+		channel, err := p.GetChannel(config.SummaryModel)
+		if err != nil {
+			return "", model.Usage{}, err
+		}
+
+		newMeta.SetChannel(channel)
+	}
+
+	adaptor, ok := adaptors.GetAdaptor(newMeta.Channel.Type)
+	if !ok {
+		return "", model.Usage{}, errors.New("adaptor not found")
+	}
+
+	result := controller.Handle(adaptor, newc, newMeta, store)
+	if result.Error != nil {
+		return "", model.Usage{}, result.Error
+	}
+
+	contentNode, err := common.GetJSONNodeNoCopy(
+		w.Body.Bytes(),
+		"choices",
+		0,
+		"message",
+		"content",
+	)
+	if err != nil {
+		return "", result.Usage, err
+	}
+
+	content, err := contentNode.String()
+	if err != nil {
+		return "", result.Usage, err
+	}
+
+	return strings.TrimSpace(content), result.Usage, nil
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
+
+// DoResponse records the cost and the savings of the compression in the log
+func (p *PromptCompress) DoResponse(
+	meta *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+	do adaptor.DoResponse,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	result, respErr := do.DoResponse(meta, store, c, resp)
+
+	compressed, ok := getCompression(meta)
+	if !ok {
+		return result, respErr
+	}
+
+	savedTokens := max(compressed.TokensBefore-compressed.TokensAfter, 0)
+
+	cost := consume.CalculateAmount(
+		http.StatusOK,
+		compressed.SummaryUsage,
+		model.UsageContext{},
+		compressed.SummaryPrice,
+	)
+	savings := consume.CalculateAmount(
+		http.StatusOK,
+		model.Usage{InputTokens: model.ZeroNullInt64(savedTokens)},
+		model.UsageContext{},
+		meta.ModelConfig.Price,
+	)
+
+	fields := map[string]string{
+		"prompt_compress_model":         compressed.SummaryModel,
+		"prompt_compress_tokens_before": strconv.FormatInt(compressed.TokensBefore, 10),
+		"prompt_compress_tokens_after":  strconv.FormatInt(compressed.TokensAfter, 10),
+		"prompt_compress_tokens_saved":  strconv.FormatInt(savedTokens, 10),
+		"prompt_compress_cost":          formatAmount(cost),
+		"prompt_compress_savings":       formatAmount(savings),
+	}
+
+	metadata := middleware.GetRequestMetadata(c)
+	if metadata == nil {
+		metadata = make(map[string]string, len(fields))
+		c.Set(middleware.RequestMetadata, metadata)
+	}
+
+	log := common.GetLogger(c)
+	for k, v := range fields {
+		metadata[k] = v
+		log.Data[k] = v
+	}
+
+	return result, respErr
+}
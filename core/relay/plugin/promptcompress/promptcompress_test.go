@@ -0,0 +1,108 @@
+//nolint:testpackage
+package promptcompress
+
+import (
+	"testing"
+
+	"github.com/labring/aiproxy/core/model"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func messagesWithRoles(roles ...string) []relaymodel.Message {
+	messages := make([]relaymodel.Message, 0, len(roles))
+	for _, role := range roles {
+		messages = append(messages, relaymodel.Message{Role: role, Content: role})
+	}
+
+	return messages
+}
+
+func TestSplitMessagesKeepsSystemAndRecent(t *testing.T) {
+	t.Parallel()
+
+	messages := messagesWithRoles(
+		relaymodel.RoleSystem,
+		relaymodel.RoleUser,
+		relaymodel.RoleAssistant,
+		relaymodel.RoleUser,
+		relaymodel.RoleAssistant,
+		relaymodel.RoleUser,
+	)
+
+	start, end, ok := SplitMessages(messages, 2)
+	require.True(t, ok)
+	assert.Equal(t, 1, start)
+	assert.Equal(t, 4, end)
+}
+
+func TestSplitMessagesDoesNotSplitToolResults(t *testing.T) {
+	t.Parallel()
+
+	messages := messagesWithRoles(
+		relaymodel.RoleUser,
+		relaymodel.RoleAssistant,
+		relaymodel.RoleUser,
+		relaymodel.RoleAssistant,
+		relaymodel.RoleTool,
+		relaymodel.RoleTool,
+		relaymodel.RoleUser,
+	)
+
+	start, end, ok := SplitMessages(messages, 2)
+	require.True(t, ok)
+	assert.Equal(t, 0, start)
+	// the assistant message calling the tools is kept with the tool results
+	assert.Equal(t, 3, end)
+}
+
+func TestSplitMessagesTooFewMessages(t *testing.T) {
+	t.Parallel()
+
+	messages := messagesWithRoles(
+		relaymodel.RoleSystem,
+		relaymodel.RoleUser,
+		relaymodel.RoleAssistant,
+		relaymodel.RoleUser,
+	)
+
+	_, _, ok := SplitMessages(messages, 2)
+	assert.False(t, ok)
+}
+
+func TestBuildTranscript(t *testing.T) {
+	t.Parallel()
+
+	transcript := BuildTranscript([]relaymodel.Message{
+		{Role: relaymodel.RoleUser, Content: "weather in Paris?"},
+		{
+			Role:             relaymodel.RoleAssistant,
+			ReasoningContent: "hidden",
+			ToolCalls: []relaymodel.ToolCall{{
+				Function: relaymodel.Function{Name: "get_weather", Arguments: `{"city":"Paris"}`},
+			}},
+		},
+	})
+
+	assert.Equal(
+		t,
+		"user: weather in Paris?\n\nassistant: \n[tool call get_weather] {\"city\":\"Paris\"}\n\n",
+		transcript,
+	)
+}
+
+func TestContextTokens(t *testing.T) {
+	t.Parallel()
+
+	modelConfig := model.ModelConfig{
+		Config: model.NewModelConfig(
+			model.WithModelConfigMaxContextTokens(128000),
+			model.WithModelConfigMaxInputTokens(100000),
+		),
+	}
+
+	assert.Equal(t, 100000, contextTokens(Config{}, modelConfig))
+	assert.Equal(t, 32000, contextTokens(Config{ContextTokens: 32000}, modelConfig))
+	assert.Equal(t, 0, contextTokens(Config{}, model.ModelConfig{}))
+}
@@ -0,0 +1,27 @@
+package strictschema
+
+import "github.com/labring/aiproxy/core/model"
+
+const PluginName = "strict-json-schema"
+
+// Config represents the plugin configuration
+type Config struct {
+	Enable bool `json:"enable"`
+	// Retry retries once with the correction prompt when the output does not
+	// match the schema
+	Retry bool `json:"retry,omitempty"`
+	// CorrectionPrompt is sent with the validation error on the retry
+	CorrectionPrompt string `json:"correction_prompt,omitempty"`
+	// NativeChannelTypes are the channel types enforcing the strict mode
+	// natively, their outputs are not validated
+	NativeChannelTypes []model.ChannelType `json:"native_channel_types,omitempty"`
+}
+
+const defaultCorrectionPrompt = "Your previous response does not match the required JSON schema. " +
+	"Respond again with only the JSON that matches the schema, without any other text."
+
+func (c *Config) applyDefaults() {
+	if c.CorrectionPrompt == "" {
+		c.CorrectionPrompt = defaultCorrectionPrompt
+	}
+}
@@ -0,0 +1,121 @@
+package strictschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// maxValidationErrorLen limits the validation error in the response header
+const maxValidationErrorLen = 256
+
+type responseFormatRequest struct {
+	Stream         bool `json:"stream"`
+	ResponseFormat *struct {
+		Type       string `json:"type"`
+		JSONSchema *struct {
+			Name   string          `json:"name"`
+			Schema json.RawMessage `json:"schema"`
+			Strict bool            `json:"strict"`
+		} `json:"json_schema"`
+	} `json:"response_format"`
+}
+
+// getStrictSchema returns the resolved schema of the non stream chat request
+// with the strict json schema response format
+func getStrictSchema(body []byte) (*jsonschema.Resolved, bool, error) {
+	var req responseFormatRequest
+	if err := sonic.Unmarshal(body, &req); err != nil {
+		return nil, false, err
+	}
+
+	if req.Stream ||
+		req.ResponseFormat == nil ||
+		req.ResponseFormat.Type != "json_schema" ||
+		req.ResponseFormat.JSONSchema == nil ||
+		!req.ResponseFormat.JSONSchema.Strict ||
+		len(req.ResponseFormat.JSONSchema.Schema) == 0 {
+		return nil, false, nil
+	}
+
+	var schema jsonschema.Schema
+	if err := json.Unmarshal(req.ResponseFormat.JSONSchema.Schema, &schema); err != nil {
+		return nil, false, fmt.Errorf("invalid json schema: %w", err)
+	}
+
+	resolved, err := schema.Resolve(nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid json schema: %w", err)
+	}
+
+	return resolved, true, nil
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content *string `json:"content"`
+			Refusal string  `json:"refusal"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+// firstContent returns the content of the first choice
+func firstContent(body []byte) string {
+	var resp chatResponse
+	if err := sonic.Unmarshal(body, &resp); err != nil ||
+		len(resp.Choices) == 0 ||
+		resp.Choices[0].Message.Content == nil {
+		return ""
+	}
+
+	return *resp.Choices[0].Message.Content
+}
+
+// validateResponse validates the content of every choice of the chat
+// response against the schema, the refusals are not validated
+func validateResponse(schema *jsonschema.Resolved, body []byte) error {
+	var resp chatResponse
+	if err := sonic.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return errors.New("response has no choices")
+	}
+
+	for i, choice := range resp.Choices {
+		if choice.Message.Refusal != "" {
+			continue
+		}
+
+		if choice.Message.Content == nil {
+			return fmt.Errorf("choice %d has no content", i)
+		}
+
+		var instance any
+		if err := json.Unmarshal([]byte(*choice.Message.Content), &instance); err != nil {
+			return fmt.Errorf("choice %d content is not json: %w", i, err)
+		}
+
+		if err := schema.Validate(instance); err != nil {
+			return fmt.Errorf("choice %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// headerValue makes the validation error fit in a response header
+func headerValue(err error) string {
+	s := strings.Join(strings.Fields(err.Error()), " ")
+	if len(s) > maxValidationErrorLen {
+		s = strings.ToValidUTF8(s[:maxValidationErrorLen], "")
+	}
+
+	return s
+}
@@ -0,0 +1,141 @@
+//nolint:testpackage
+package strictschema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const strictRequest = `{
+	"model": "llama",
+	"messages": [{"role": "user", "content": "hi"}],
+	"response_format": {
+		"type": "json_schema",
+		"json_schema": {
+			"name": "answer",
+			"strict": true,
+			"schema": {
+				"type": "object",
+				"properties": {"answer": {"type": "string"}},
+				"required": ["answer"],
+				"additionalProperties": false
+			}
+		}
+	}
+}`
+
+func chatResponseWithContent(t *testing.T, content string) []byte {
+	t.Helper()
+
+	body, err := sonic.Marshal(map[string]any{
+		"choices": []any{
+			map[string]any{
+				"index":   0,
+				"message": map[string]any{"role": "assistant", "content": content},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	return body
+}
+
+func TestGetStrictSchema(t *testing.T) {
+	t.Parallel()
+
+	schema, ok, err := getStrictSchema([]byte(strictRequest))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NotNil(t, schema)
+
+	tests := []string{
+		strings.Replace(strictRequest, `"strict": true`, `"strict": false`, 1),
+		strings.Replace(strictRequest, `"model": "llama"`, `"model": "llama", "stream": true`, 1),
+		`{"messages": [], "response_format": {"type": "json_object"}}`,
+		`{"messages": []}`,
+	}
+	for _, body := range tests {
+		_, ok, err := getStrictSchema([]byte(body))
+		require.NoError(t, err)
+		assert.False(t, ok, body)
+	}
+
+	_, _, err = getStrictSchema([]byte(strings.Replace(
+		strictRequest,
+		`"type": "object"`,
+		`"type": 1`,
+		1,
+	)))
+	assert.Error(t, err)
+}
+
+func TestValidateResponse(t *testing.T) {
+	t.Parallel()
+
+	schema, ok, err := getStrictSchema([]byte(strictRequest))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, validateResponse(
+		schema,
+		chatResponseWithContent(t, `{"answer": "42"}`),
+	))
+
+	assert.Error(t, validateResponse(
+		schema,
+		chatResponseWithContent(t, `{"answer": 42}`),
+	))
+	assert.Error(t, validateResponse(
+		schema,
+		chatResponseWithContent(t, "```json\n{\"answer\": \"42\"}\n```"),
+	))
+	assert.Error(t, validateResponse(
+		schema,
+		chatResponseWithContent(t, `{"answer": "42", "extra": true}`),
+	))
+
+	require.NoError(t, validateResponse(
+		schema,
+		[]byte(`{"choices":[{"message":{"role":"assistant","content":null,"refusal":"no"}}]}`),
+	))
+}
+
+func TestCorrectionRequest(t *testing.T) {
+	t.Parallel()
+
+	body, err := correctionRequest(
+		[]byte(strictRequest),
+		`{"answer": 42}`,
+		defaultCorrectionPrompt,
+		errors.New("answer: type mismatch"),
+	)
+	require.NoError(t, err)
+
+	var req struct {
+		Stream   bool `json:"stream"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	require.NoError(t, sonic.Unmarshal(body, &req))
+
+	assert.False(t, req.Stream)
+	require.Len(t, req.Messages, 3)
+	assert.Equal(t, "assistant", req.Messages[1].Role)
+	assert.JSONEq(t, `{"answer": 42}`, req.Messages[1].Content)
+	assert.Equal(t, "user", req.Messages[2].Role)
+	assert.Contains(t, req.Messages[2].Content, "answer: type mismatch")
+}
+
+func TestHeaderValue(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "a b c", headerValue(errors.New("a\nb\t c")))
+	assert.Len(t, headerValue(errors.New(strings.Repeat("x", 1000))), maxValidationErrorLen)
+}
@@ -0,0 +1,312 @@
+package strictschema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/conv"
+	"github.com/labring/aiproxy/core/middleware"
+	"github.com/labring/aiproxy/core/model"
+	"github.com/labring/aiproxy/core/relay/adaptor"
+	"github.com/labring/aiproxy/core/relay/adaptors"
+	"github.com/labring/aiproxy/core/relay/controller"
+	"github.com/labring/aiproxy/core/relay/meta"
+	"github.com/labring/aiproxy/core/relay/mode"
+	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	"github.com/labring/aiproxy/core/relay/plugin"
+	"github.com/labring/aiproxy/core/relay/plugin/noop"
+	"github.com/labring/aiproxy/core/relay/utils"
+)
+
+var _ plugin.Plugin = (*StrictSchema)(nil)
+
+const (
+	// XAiproxyJSONSchemaValidation is the result of the validation at the
+	// proxy, one of valid, corrected and invalid
+	XAiproxyJSONSchemaValidation = "X-Aiproxy-Json-Schema-Validation"
+	// XAiproxyJSONSchemaError is the validation error of the invalid output
+	XAiproxyJSONSchemaError = "X-Aiproxy-Json-Schema-Error"
+
+	ValidationValid     = "valid"
+	ValidationCorrected = "corrected"
+	ValidationInvalid   = "invalid"
+
+	strictSchemaKey = "strict_json_schema"
+)
+
+// StrictSchema validates the output of the chat requests with a strict json
+// schema response format at the proxy, for the providers lacking the strict
+// mode
+type StrictSchema struct {
+	noop.Noop
+	configCache utils.PluginConfigCache[Config]
+}
+
+// NewStrictSchemaPlugin creates a new strict json schema plugin
+func NewStrictSchemaPlugin() plugin.Plugin {
+	return &StrictSchema{}
+}
+
+func (p *StrictSchema) getConfig(m *meta.Meta) (Config, error) {
+	pluginConfig, err := p.configCache.Load(m, PluginName, Config{})
+	if err != nil {
+		return Config{}, err
+	}
+
+	pluginConfig.applyDefaults()
+
+	return pluginConfig, nil
+}
+
+func getSchema(m *meta.Meta) (*jsonschema.Resolved, bool) {
+	v, ok := m.Get(strictSchemaKey)
+	if !ok {
+		return nil, false
+	}
+
+	schema, ok := v.(*jsonschema.Resolved)
+	if !ok {
+		panic(fmt.Sprintf("strict json schema type %T is not a *jsonschema.Resolved", v))
+	}
+
+	return schema, true
+}
+
+// ConvertRequest records the schema of the request, the request is sent as is
+func (p *StrictSchema) ConvertRequest(
+	m *meta.Meta,
+	store adaptor.Store,
+	req *http.Request,
+	do adaptor.ConvertRequest,
+) (adaptor.ConvertResult, error) {
+	// the previous attempt may be sent to another channel
+	m.Delete(strictSchemaKey)
+
+	if m.Mode != mode.ChatCompletions {
+		return do.ConvertRequest(m, store, req)
+	}
+
+	pluginConfig, err := p.getConfig(m)
+	if err != nil ||
+		!pluginConfig.Enable ||
+		slices.Contains(pluginConfig.NativeChannelTypes, m.Channel.Type) {
+		return do.ConvertRequest(m, store, req)
+	}
+
+	body, err := common.GetRequestBodyReusable(req)
+	if err != nil {
+		return adaptor.ConvertResult{}, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	schema, ok, err := getStrictSchema(body)
+	if err != nil {
+		return adaptor.ConvertResult{}, relaymodel.WrapperOpenAIErrorWithMessage(
+			err.Error(),
+			"invalid_json_schema",
+			http.StatusBadRequest,
+		)
+	}
+
+	if ok {
+		m.Set(strictSchemaKey, schema)
+	}
+
+	return do.ConvertRequest(m, store, req)
+}
+
+// DoResponse validates the output before it is sent to the client
+func (p *StrictSchema) DoResponse(
+	m *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	resp *http.Response,
+	do adaptor.DoResponse,
+) (adaptor.DoResponseResult, adaptor.Error) {
+	schema, ok := getSchema(m)
+	if !ok || resp.StatusCode != http.StatusOK {
+		return do.DoResponse(m, store, c, resp)
+	}
+
+	rw := &bufferedResponseWriter{ResponseWriter: c.Writer}
+	c.Writer = rw
+
+	result, relayErr := do.DoResponse(m, store, c, resp)
+
+	c.Writer = rw.ResponseWriter
+	body := rw.body.Bytes()
+
+	if relayErr != nil {
+		if len(body) > 0 {
+			_, _ = c.Writer.Write(body)
+		}
+
+		return result, relayErr
+	}
+
+	validationErr := validateResponse(schema, body)
+	if validationErr == nil {
+		writeResponse(c, ValidationValid, nil, body)
+		return result, nil
+	}
+
+	pluginConfig, err := p.getConfig(m)
+	if err != nil || !pluginConfig.Retry {
+		writeResponse(c, ValidationInvalid, validationErr, body)
+		return result, nil
+	}
+
+	log := common.GetLogger(c)
+
+	correctedBody, usage, err := p.correct(m, store, c, pluginConfig, body, validationErr)
+	result.Usage.Add(usage)
+
+	if err != nil {
+		log.Errorf("strict json schema correction failed: %v", err)
+		writeResponse(c, ValidationInvalid, validationErr, body)
+
+		return result, nil
+	}
+
+	if correctedErr := validateResponse(schema, correctedBody); correctedErr != nil {
+		writeResponse(c, ValidationInvalid, correctedErr, correctedBody)
+		return result, nil
+	}
+
+	writeResponse(c, ValidationCorrected, nil, correctedBody)
+
+	return result, nil
+}
+
+func writeResponse(c *gin.Context, validation string, validationErr error, body []byte) {
+	c.Header(XAiproxyJSONSchemaValidation, validation)
+
+	if validationErr != nil {
+		c.Header(XAiproxyJSONSchemaError, headerValue(validationErr))
+	}
+
+	c.Header("Content-Type", "application/json")
+	c.Header("Content-Length", strconv.Itoa(len(body)))
+	_, _ = c.Writer.Write(body)
+}
+
+// correctionRequest appends the invalid output and the correction prompt to
+// the messages of the request
+func correctionRequest(
+	reqBody []byte,
+	invalidContent, prompt string,
+	validationErr error,
+) ([]byte, error) {
+	var req map[string]any
+	if err := sonic.Unmarshal(reqBody, &req); err != nil {
+		return nil, err
+	}
+
+	messages, ok := req["messages"].([]any)
+	if !ok {
+		return nil, errors.New("invalid messages")
+	}
+
+	req["messages"] = append(messages,
+		map[string]any{
+			"role":    relaymodel.RoleAssistant,
+			"content": invalidContent,
+		},
+		map[string]any{
+			"role":    relaymodel.RoleUser,
+			"content": prompt + "\n\nValidation error: " + validationErr.Error(),
+		},
+	)
+	req["stream"] = false
+	req["n"] = 1
+	delete(req, "stream_options")
+
+	return sonic.Marshal(req)
+}
+
+// correct retries the request once on the same channel with the correction
+// prompt
+func (p *StrictSchema) correct(
+	m *meta.Meta,
+	store adaptor.Store,
+	c *gin.Context,
+	pluginConfig Config,
+	body []byte,
+	validationErr error,
+) ([]byte, model.Usage, error) {
+	reqBody, err := common.GetRequestBodyReusable(c.Request)
+	if err != nil {
+		return nil, model.Usage{}, err
+	}
+
+	correctionBody, err := correctionRequest(
+		reqBody,
+		firstContent(body),
+		pluginConfig.CorrectionPrompt,
+		validationErr,
+	)
+	if err != nil {
+		return nil, model.Usage{}, err
+	}
+
+	w := httptest.NewRecorder()
+	newc, _ := gin.CreateTestContext(w)
+	newc.Request = (&http.Request{
+		URL:    &url.URL{},
+		Body:   io.NopCloser(bytes.NewReader(correctionBody)),
+		Header: make(http.Header),
+	}).WithContext(c.Request.Context())
+	middleware.SetRequestID(newc, PluginName)
+
+	newMeta := meta.NewMeta(
+		nil,
+		mode.ChatCompletions,
+		m.OriginModel,
+		m.ModelConfig,
+		meta.WithRequestID(PluginName),
+	)
+	newMeta.CopyChannelFromMeta(m)
+	newMeta.Group = m.Group
+
+	a, ok := adaptors.GetAdaptor(newMeta.Channel.Type)
+	if !ok {
+		return nil, model.Usage{}, errors.New("adaptor not found")
+	}
+
+	result := controller.Handle(a, newc, newMeta, store)
+	if result.Error != nil {
+		return nil, result.Usage, result.Error
+	}
+
+	return w.Body.Bytes(), result.Usage, nil
+}
+
+// bufferedResponseWriter holds the response until it is validated
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (rw *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return rw.body.Write(b)
+}
+
+func (rw *bufferedResponseWriter) WriteString(s string) (int, error) {
+	return rw.Write(conv.StringToBytes(s))
+}
+
+// ignore flush
+func (rw *bufferedResponseWriter) Flush() {}
+
+// ignore WriteHeaderNow
+func (rw *bufferedResponseWriter) WriteHeaderNow() {}
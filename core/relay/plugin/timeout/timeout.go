@@ -55,11 +55,20 @@ func (t *Timeout) ConvertRequest(
 		mode.VideosEdits,
 		mode.VideosExtensions:
 		meta.RequestTimeout = time.Second * 30
+	case mode.AudioGenerations,
+		mode.AudioGenerationsGet:
+		meta.RequestTimeout = time.Second * 30
 	case mode.ResponsesGet,
 		mode.ResponsesDelete,
 		mode.ResponsesCancel,
 		mode.ResponsesInputItems:
 		meta.RequestTimeout = time.Second * 30
+	case mode.Files:
+		meta.RequestTimeout = time.Minute * 5
+	case mode.FilesGet,
+		mode.FilesContent,
+		mode.FilesDelete:
+		meta.RequestTimeout = time.Minute
 	case mode.ChatCompletions,
 		mode.Completions,
 		mode.Responses,
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/config"
 	"github.com/labring/aiproxy/core/common/conv"
 )
 
@@ -29,6 +30,8 @@ func (r *Anthropic) Render(w http.ResponseWriter) error {
 		}
 	}
 
+	event = EventName(event)
+
 	for _, bytes := range [][]byte{
 		eventBytes,
 		conv.StringToBytes(event),
@@ -50,3 +53,12 @@ func (r *Anthropic) Render(w http.ResponseWriter) error {
 func (r *Anthropic) WriteContentType(w http.ResponseWriter) {
 	WriteSSEContentType(w)
 }
+
+// EventName returns the configured event name emitted for the rendered event
+func EventName(event string) string {
+	if name, ok := config.GetSSEEventNames()[event]; ok && name != "" {
+		return name
+	}
+
+	return event
+}
@@ -23,14 +23,17 @@ var (
 )
 
 const (
-	DONE             = "[DONE]"
-	DataPrefix       = "data:"
-	DataPrefixLength = len(DataPrefix)
+	DONE              = "[DONE]"
+	DataPrefix        = "data:"
+	DataPrefixLength  = len(DataPrefix)
+	EventPrefix       = "event:"
+	EventPrefixLength = len(EventPrefix)
 )
 
 var (
-	DataPrefixBytes = conv.StringToBytes(DataPrefix)
-	DoneBytes       = conv.StringToBytes(DONE)
+	DataPrefixBytes  = conv.StringToBytes(DataPrefix)
+	DoneBytes        = conv.StringToBytes(DONE)
+	EventPrefixBytes = conv.StringToBytes(EventPrefix)
 )
 
 // IsValidSSEData checks if data is valid SSE format
@@ -44,6 +47,17 @@ func ExtractSSEData(data []byte) []byte {
 	return bytes.TrimSpace(data[DataPrefixLength:])
 }
 
+// IsSSEEvent checks if data is a SSE event line
+func IsSSEEvent(data []byte) bool {
+	return len(data) >= EventPrefixLength &&
+		slices.Equal(data[:EventPrefixLength], EventPrefixBytes)
+}
+
+// ExtractSSEEvent extracts the event name from the SSE event line
+func ExtractSSEEvent(data []byte) string {
+	return string(bytes.TrimSpace(data[EventPrefixLength:]))
+}
+
 // IsSSEDone checks if SSE data indicates completion
 func IsSSEDone(data []byte) bool {
 	return slices.Equal(data, DoneBytes)
@@ -0,0 +1,52 @@
+package render
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/common/conv"
+)
+
+// RawSSE passes an upstream event through unchanged, used when the upstream
+// speaks the same protocol as the client so the event names and order are kept
+// exactly, the event line is omitted when the upstream sent none
+type RawSSE struct {
+	Event string
+	Data  []byte
+}
+
+func (r *RawSSE) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+
+	chunks := [][]byte{dataBytes, r.Data, nnBytes}
+	if r.Event != "" {
+		chunks = append([][]byte{eventBytes, conv.StringToBytes(r.Event), nBytes}, chunks...)
+	}
+
+	for _, chunk := range chunks {
+		// nosemgrep:
+		// go.lang.security.audit.xss.no-direct-write-to-responsewriter.no-direct-write-to-responsewriter
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *RawSSE) WriteContentType(w http.ResponseWriter) {
+	WriteSSEContentType(w)
+}
+
+func RawEventData(c *gin.Context, event string, data []byte) {
+	if len(c.Errors) > 0 {
+		return
+	}
+
+	if c.IsAborted() {
+		return
+	}
+
+	c.Render(-1, &RawSSE{Event: event, Data: data})
+	c.Writer.Flush()
+}
@@ -0,0 +1,50 @@
+package render_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labring/aiproxy/core/common/config"
+	"github.com/labring/aiproxy/core/relay/render"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawSSEKeepsUpstreamEvent(t *testing.T) {
+	config.SetSSEEventNames(map[string]string{"message_start": "start"})
+	defer config.SetSSEEventNames(nil)
+
+	w := httptest.NewRecorder()
+	err := (&render.RawSSE{
+		Event: "message_start",
+		Data:  []byte(`{"type":"message_start"}`),
+	}).Render(w)
+	require.NoError(t, err)
+	require.Equal(t, "event: message_start\ndata: {\"type\":\"message_start\"}\n\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	err = (&render.RawSSE{Data: []byte(`{"type":"ping"}`)}).Render(w)
+	require.NoError(t, err)
+	require.Equal(t, "data: {\"type\":\"ping\"}\n\n", w.Body.String())
+}
+
+func TestAnthropicRenderMapsEventName(t *testing.T) {
+	config.SetSSEEventNames(map[string]string{"message_start": "start"})
+	defer config.SetSSEEventNames(nil)
+
+	w := httptest.NewRecorder()
+	err := (&render.Anthropic{Data: []byte(`{"type":"message_start"}`)}).Render(w)
+	require.NoError(t, err)
+	require.Equal(t, "event: start\ndata: {\"type\":\"message_start\"}\n\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	err = (&render.Anthropic{Event: "ping", Data: []byte(`{"type":"ping"}`)}).Render(w)
+	require.NoError(t, err)
+	require.Equal(t, "event: ping\ndata: {\"type\":\"ping\"}\n\n", w.Body.String())
+}
+
+func TestExtractSSEEvent(t *testing.T) {
+	line := []byte("event: content_block_delta ")
+	require.True(t, render.IsSSEEvent(line))
+	require.Equal(t, "content_block_delta", render.ExtractSSEEvent(line))
+	require.False(t, render.IsSSEEvent([]byte("data: {}")))
+}
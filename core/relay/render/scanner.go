@@ -0,0 +1,98 @@
+package render
+
+import (
+	"bytes"
+
+	"github.com/bytedance/sonic"
+)
+
+// IsSSEComment checks if data is a SSE comment line, e.g. the `: keepalive`
+// heartbeats of some upstreams
+func IsSSEComment(data []byte) bool {
+	return len(data) > 0 && data[0] == ':'
+}
+
+// ScanSSELines is a bufio.SplitFunc for the SSE streams of the upstreams, the
+// lines may end with CRLF, LF or CR, the comment lines are dropped, and the
+// consecutive data lines of an event are joined by LF into one data line.
+// A data line holding a complete JSON payload is never joined, which keeps
+// the upstreams omitting the blank lines between the events working
+func ScanSSELines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	line, advance, ok := scanSSELine(data, atEOF)
+	if !ok {
+		return 0, nil, nil
+	}
+
+	if IsSSEComment(line) {
+		return advance, nil, nil
+	}
+
+	if !IsValidSSEData(line) {
+		return advance, line, nil
+	}
+
+	var joined []byte
+
+	for {
+		next, size, ok := scanSSELine(data[advance:], atEOF)
+		if !ok {
+			if atEOF || isCompleteSSEData(line) {
+				break
+			}
+			// wait for the next line, it may continue the data of the event
+			return 0, nil, nil
+		}
+
+		if !IsValidSSEData(next) || isCompleteSSEData(line) {
+			break
+		}
+
+		if joined == nil {
+			joined = append(make([]byte, 0, len(line)+len(next)), line...)
+		}
+
+		joined = append(joined, '\n')
+		joined = append(joined, sseFieldValue(next[DataPrefixLength:])...)
+		line = joined
+		advance += size
+	}
+
+	return advance, line, nil
+}
+
+func isCompleteSSEData(line []byte) bool {
+	data := ExtractSSEData(line)
+	return IsSSEDone(data) || sonic.Valid(data)
+}
+
+// scanSSELine returns the next line without the line ending
+func scanSSELine(data []byte, atEOF bool) (line []byte, size int, ok bool) {
+	i := bytes.IndexAny(data, "\r\n")
+	switch {
+	case i < 0:
+		if atEOF && len(data) > 0 {
+			return data, len(data), true
+		}
+		return nil, 0, false
+	case data[i] == '\n':
+		return data[:i], i + 1, true
+	case i+1 < len(data):
+		if data[i+1] == '\n' {
+			return data[:i], i + 2, true
+		}
+		return data[:i], i + 1, true
+	case atEOF:
+		return data[:i], i + 1, true
+	default:
+		// a CR at the end of the buffer may be followed by a LF
+		return nil, 0, false
+	}
+}
+
+// sseFieldValue strips the single leading space of the field value
+func sseFieldValue(value []byte) []byte {
+	if len(value) > 0 && value[0] == ' ' {
+		return value[1:]
+	}
+	return value
+}
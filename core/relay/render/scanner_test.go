@@ -0,0 +1,90 @@
+package render_test
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/labring/aiproxy/core/relay/render"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scanSSELines(t *testing.T, stream string) []string {
+	t.Helper()
+
+	// read one byte at a time to split the lines across the buffer boundaries
+	scanner := bufio.NewScanner(iotest.OneByteReader(strings.NewReader(stream)))
+	scanner.Split(render.ScanSSELines)
+
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	require.NoError(t, scanner.Err())
+
+	return lines
+}
+
+func TestScanSSELines(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		stream   string
+		expected []string
+	}{
+		{
+			name:     "lf",
+			stream:   "event: ping\ndata: {\"a\":1}\n\ndata: [DONE]\n\n",
+			expected: []string{"event: ping", `data: {"a":1}`, "", "data: [DONE]", ""},
+		},
+		{
+			name:     "crlf",
+			stream:   "data: {\"a\":1}\r\n\r\ndata: [DONE]\r\n\r\n",
+			expected: []string{`data: {"a":1}`, "", "data: [DONE]", ""},
+		},
+		{
+			name:     "cr",
+			stream:   "data: {\"a\":1}\r\rdata: [DONE]\r",
+			expected: []string{`data: {"a":1}`, "", "data: [DONE]"},
+		},
+		{
+			name:     "comments",
+			stream:   ": keepalive\n\n:\ndata: {\"a\":1}\n\n",
+			expected: []string{"", `data: {"a":1}`, ""},
+		},
+		{
+			name:     "multi-line data",
+			stream:   "data: {\"a\":\r\ndata: 1,\ndata:\"b\":2}\n\n",
+			expected: []string{"data: {\"a\":\n1,\n\"b\":2}", ""},
+		},
+		{
+			name:     "complete data lines without blank lines",
+			stream:   "data: {\"a\":1}\ndata: {\"a\":2}\ndata: [DONE]\n",
+			expected: []string{`data: {"a":1}`, `data: {"a":2}`, "data: [DONE]"},
+		},
+		{
+			name:     "incomplete data at eof",
+			stream:   "data: {\"a\":",
+			expected: []string{`data: {"a":`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.expected, scanSSELines(t, tt.stream))
+		})
+	}
+}
+
+func TestIsSSEComment(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, render.IsSSEComment([]byte(": keepalive")))
+	assert.False(t, render.IsSSEComment([]byte("data: {}")))
+	assert.False(t, render.IsSSEComment(nil))
+}
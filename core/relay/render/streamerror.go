@@ -0,0 +1,97 @@
+package render
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/relay/model"
+)
+
+// ErrorCodeStreamInterrupted is the code of the error event sent when the
+// upstream stream fails before it completes, so the clients can tell a
+// truncated stream from a completed one
+const ErrorCodeStreamInterrupted = "stream_interrupted"
+
+func streamErrorMessage(err error) string {
+	return "upstream stream interrupted: " + err.Error()
+}
+
+// streamClientGone reports whether the stream failed because the client went
+// away, there is nobody to send the error event to
+func streamClientGone(c *gin.Context) bool {
+	return c.Request != nil && c.Request.Context().Err() != nil
+}
+
+// OpenaiStreamError sends the error object chunk of the interrupted stream
+func OpenaiStreamError(c *gin.Context, err error) {
+	if streamClientGone(c) {
+		return
+	}
+
+	_ = OpenaiObjectData(c, &model.OpenAIErrorResponse{
+		Error: model.OpenAIError{
+			Code:    ErrorCodeStreamInterrupted,
+			Message: streamErrorMessage(err),
+			Type:    model.ErrorTypeUpstream,
+		},
+	})
+}
+
+// ClaudeStreamError sends the error event of the interrupted stream
+func ClaudeStreamError(c *gin.Context, err error) {
+	if streamClientGone(c) {
+		return
+	}
+
+	_ = ClaudeEventObjectData(c, "error", &model.AnthropicErrorResponse{
+		Type: "error",
+		Error: model.AnthropicError{
+			Type:    "api_error",
+			Message: streamErrorMessage(err),
+			Code:    ErrorCodeStreamInterrupted,
+		},
+	})
+}
+
+// GeminiStreamError sends the error json of the interrupted stream, the code
+// is carried by the ErrorInfo reason
+func GeminiStreamError(c *gin.Context, err error) {
+	if streamClientGone(c) {
+		return
+	}
+
+	_ = GeminiObjectData(c, &model.GeminiErrorResponse{
+		Error: model.GeminiError{
+			Code:    http.StatusInternalServerError,
+			Status:  "INTERNAL",
+			Message: streamErrorMessage(err),
+			Details: []model.GeminiErrorDetail{
+				{
+					Type:   model.GeminiErrorInfoType,
+					Reason: strings.ToUpper(ErrorCodeStreamInterrupted),
+					Domain: "aiproxy",
+				},
+			},
+		},
+	})
+}
+
+// ResponsesStreamError sends the error event of the interrupted stream
+func ResponsesStreamError(c *gin.Context, err error) {
+	if streamClientGone(c) {
+		return
+	}
+
+	_ = ResponsesEventObjectData(c, string(model.EventError), &responsesStreamError{
+		Type:    string(model.EventError),
+		Code:    ErrorCodeStreamInterrupted,
+		Message: streamErrorMessage(err),
+	})
+}
+
+type responsesStreamError struct {
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,69 @@
+package render_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/relay/render"
+	"github.com/stretchr/testify/require"
+)
+
+func newStreamErrorContext(ctx context.Context) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+
+	return c, w
+}
+
+func TestStreamErrorEvents(t *testing.T) {
+	streamErr := errors.New("unexpected EOF")
+
+	tests := []struct {
+		name     string
+		render   func(c *gin.Context, err error)
+		expected string
+	}{
+		{
+			name:     "openai",
+			render:   render.OpenaiStreamError,
+			expected: `data: {"error":{"code":"stream_interrupted","message":"upstream stream interrupted: unexpected EOF","type":"upstream_error"}}` + "\n\n",
+		},
+		{
+			name:     "claude",
+			render:   render.ClaudeStreamError,
+			expected: "event: error\n" + `data: {"type":"error","error":{"type":"api_error","message":"upstream stream interrupted: unexpected EOF","code":"stream_interrupted"}}` + "\n\n",
+		},
+		{
+			name:     "gemini",
+			render:   render.GeminiStreamError,
+			expected: `data: {"error":{"message":"upstream stream interrupted: unexpected EOF","status":"INTERNAL","code":500,"details":[{"@type":"type.googleapis.com/google.rpc.ErrorInfo","reason":"STREAM_INTERRUPTED","domain":"aiproxy"}]}}` + "\n\n",
+		},
+		{
+			name:     "responses",
+			render:   render.ResponsesStreamError,
+			expected: "event: error\n" + `data: {"type":"error","code":"stream_interrupted","message":"upstream stream interrupted: unexpected EOF"}` + "\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newStreamErrorContext(context.Background())
+			tt.render(c, streamErr)
+			require.Equal(t, tt.expected, w.Body.String())
+		})
+	}
+}
+
+func TestStreamErrorSkipsGoneClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c, w := newStreamErrorContext(ctx)
+	render.OpenaiStreamError(c, context.Canceled)
+	require.Empty(t, w.Body.String())
+}
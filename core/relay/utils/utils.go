@@ -14,6 +14,7 @@ import (
 	"github.com/labring/aiproxy/core/common"
 	"github.com/labring/aiproxy/core/relay/meta"
 	relaymodel "github.com/labring/aiproxy/core/relay/model"
+	"github.com/labring/aiproxy/core/relay/render"
 )
 
 func UnmarshalGeneralThinking(req *http.Request) (relaymodel.GeneralOpenAIThinkingRequest, error) {
@@ -155,6 +156,17 @@ func UnmarshalGeminiChatRequest(req *http.Request) (*relaymodel.GeminiChatReques
 	return &request, nil
 }
 
+// RemoveGeminiGroundingTools strips the Google Search grounding tools from a gemini request
+// converted for a non-gemini backend, which cannot execute them
+func RemoveGeminiGroundingTools(req *http.Request, geminiReq *relaymodel.GeminiChatRequest) {
+	if removed := geminiReq.RemoveGroundingTools(); removed > 0 {
+		common.GetLoggerFromReq(req).Warnf(
+			"removed %d google search grounding tools unsupported by the backend",
+			removed,
+		)
+	}
+}
+
 func UnmarshalMap(req *http.Request) (map[string]any, error) {
 	var request map[string]any
 
@@ -276,9 +288,11 @@ func IsImageModel(modelName string) bool {
 }
 
 // NewStreamScanner creates a bufio.Scanner with appropriate buffer size based on model type.
+// The scanner splits the SSE lines with render.ScanSSELines.
 // Returns the scanner and a cleanup function that must be called when done.
 func NewStreamScanner(r io.Reader, modelNames ...string) (*bufio.Scanner, func()) {
 	scanner := bufio.NewScanner(r)
+	scanner.Split(render.ScanSSELines)
 
 	if FirstMatchingModelName(IsImageModel, modelNames...) != "" {
 		buf := GetImageScannerBuffer()
@@ -298,9 +312,11 @@ func NewStreamScanner(r io.Reader, modelNames ...string) (*bufio.Scanner, func()
 }
 
 // NewScanner creates a bufio.Scanner with standard buffer size.
+// The scanner splits the SSE lines with render.ScanSSELines.
 // Returns the scanner and a cleanup function that must be called when done.
 func NewScanner(r io.Reader) (*bufio.Scanner, func()) {
 	scanner := bufio.NewScanner(r)
+	scanner.Split(render.ScanSSELines)
 	buf := GetScannerBuffer()
 	scanner.Buffer(*buf, cap(*buf))
 
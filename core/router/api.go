@@ -18,8 +18,14 @@ func SetAPIRouter(router *gin.Engine) {
 	healthRouter := api.Group("")
 	healthRouter.GET("/status", controller.GetStatus)
 
+	oidcRouter := api.Group("/oidc")
+	{
+		oidcRouter.GET("/login", controller.OIDCLogin)
+		oidcRouter.GET("/callback", controller.OIDCCallback)
+	}
+
 	apiRouter := api.Group("")
-	apiRouter.Use(middleware.AdminAuth)
+	apiRouter.Use(middleware.AdminAuth, middleware.AdminAudit)
 	{
 		modelsRoute := apiRouter.Group("/models")
 		{
@@ -31,6 +37,7 @@ func SetAPIRouter(router *gin.Engine) {
 			modelsRoute.GET("/sets", controller.EnabledModelSets)
 			modelsRoute.GET("/default", controller.ChannelDefaultModelsAndMapping)
 			modelsRoute.GET("/default/:type", controller.ChannelDefaultModelsAndMappingByType)
+			modelsRoute.GET("/recommendations", controller.GetModelRecommendations)
 		}
 
 		dashboardRoute := apiRouter.Group("/dashboard")
@@ -38,6 +45,7 @@ func SetAPIRouter(router *gin.Engine) {
 			dashboardRoute.GET("/", controller.GetDashboard)
 			dashboardRoute.GET("/:group", controller.GetGroupDashboard)
 			dashboardRoute.GET("/:group/models", controller.GetGroupDashboardModels)
+			dashboardRoute.GET("/:group/timeseries", controller.GetGroupUsageTimeSeries)
 		}
 
 		dashboardV2Route := apiRouter.Group("/dashboardv2")
@@ -94,6 +102,20 @@ func SetAPIRouter(router *gin.Engine) {
 				groupMcpRoute.GET("/", mcp.GetGroupPublicMCPs)
 				groupMcpRoute.GET("/:id", mcp.GetGroupPublicMCPByID)
 			}
+
+			groupPromptTemplatesRoute := groupRoute.Group("/:group/prompt_templates")
+			{
+				groupPromptTemplatesRoute.GET("/", controller.GetGroupPromptTemplates)
+				groupPromptTemplatesRoute.POST("/", controller.SaveGroupPromptTemplate)
+			}
+
+			groupPromptTemplateRoute := groupRoute.Group("/:group/prompt_template")
+			{
+				groupPromptTemplateRoute.GET("/:name", controller.GetGroupPromptTemplate)
+				groupPromptTemplateRoute.DELETE("/:name", controller.DeleteGroupPromptTemplate)
+				groupPromptTemplateRoute.GET("/:name/versions", controller.GetGroupPromptTemplateVersions)
+				groupPromptTemplateRoute.POST("/:name/render", controller.RenderGroupPromptTemplate)
+			}
 		}
 
 		optionRoute := apiRouter.Group("/option")
@@ -106,6 +128,8 @@ func SetAPIRouter(router *gin.Engine) {
 			optionRoute.POST("/batch", controller.UpdateOptions)
 		}
 
+		apiRouter.GET("/adaptors", controller.GetAdaptors)
+
 		channelsRoute := apiRouter.Group("/channels")
 		{
 			channelsRoute.GET("/", controller.GetChannels)
@@ -142,6 +166,8 @@ func SetAPIRouter(router *gin.Engine) {
 				controller.TestChannelPreviewAll,
 			) // 测试未保存的渠道配置（所有模型）
 			channelRoute.GET("/:id/update_balance", controller.UpdateChannelBalance)
+			channelRoute.GET("/:id/models/sync", controller.DiffChannelModels)
+			channelRoute.POST("/:id/models/sync", controller.SyncChannelModels)
 		}
 
 		tokensRoute := apiRouter.Group("/tokens")
@@ -179,6 +205,22 @@ func SetAPIRouter(router *gin.Engine) {
 			logsRoute.GET("/detail/:log_id", controller.GetLogDetail)
 		}
 
+		auditLogsRoute := apiRouter.Group("/audit_logs")
+		{
+			auditLogsRoute.GET("/", controller.GetAuditLogs)
+		}
+
+		invoicesRoute := apiRouter.Group("/invoices")
+		{
+			invoicesRoute.GET("/", controller.GetInvoices)
+		}
+
+		invoiceRoute := apiRouter.Group("/invoice")
+		{
+			invoiceRoute.GET("/:group", controller.GetGroupInvoice)
+			invoiceRoute.POST("/:group/regenerate", controller.RegenerateGroupInvoice)
+		}
+
 		logRoute := apiRouter.Group("/log")
 		{
 			logRoute.GET("/:group/export", controller.ExportGroupLogs)
@@ -195,6 +237,14 @@ func SetAPIRouter(router *gin.Engine) {
 			modelConfigsRoute.POST("/contains", controller.GetModelConfigsByModelsContains)
 			modelConfigsRoute.POST("/", controller.SaveModelConfigs)
 			modelConfigsRoute.POST("/batch_delete", controller.DeleteModelConfigs)
+			modelConfigsRoute.GET("/versions/*model", controller.GetModelConfigVersions)
+			modelConfigsRoute.POST("/rollback/*model", controller.RollbackModelConfig)
+		}
+
+		configSyncRoute := apiRouter.Group("/config_sync")
+		{
+			configSyncRoute.GET("/", controller.GetConfigSyncResources)
+			configSyncRoute.POST("/", controller.SyncConfig)
 		}
 
 		modelConfigRoute := apiRouter.Group("/model_config")
@@ -208,6 +258,17 @@ func SetAPIRouter(router *gin.Engine) {
 		{
 			monitorRoute.GET("/", controller.GetAllChannelModelErrorRates)
 			monitorRoute.GET("/runtime_metrics", controller.GetRuntimeMetrics)
+			monitorRoute.GET("/conversion_metrics", controller.GetConversionMetrics)
+			monitorRoute.DELETE("/conversion_metrics", controller.ResetConversionMetrics)
+			monitorRoute.GET("/tokenizer_metrics", controller.GetTokenizerMetrics)
+			monitorRoute.GET("/circuit_breakers", controller.GetCircuitBreakers)
+			monitorRoute.DELETE("/circuit_breakers", controller.ResetCircuitBreakers)
+			monitorRoute.GET("/channel_bandit", controller.GetChannelBandit)
+			monitorRoute.GET("/channel_streams", controller.GetChannelStreams)
+			monitorRoute.GET("/channel_readiness", controller.GetChannelReadiness)
+			monitorRoute.GET("/fair_queue", controller.GetFairQueueStats)
+			monitorRoute.GET("/drain", controller.GetDrainStats)
+			monitorRoute.GET("/batch_summary", controller.GetBatchSummaryStats)
 			monitorRoute.GET("/group_summary_metrics", controller.GetGroupSummaryMetrics)
 			monitorRoute.GET("/group_token_metrics/:group", controller.GetGroupTokenMetrics)
 			monitorRoute.GET("/group_model_metrics/:group", controller.GetGroupModelMetrics)
@@ -218,12 +279,21 @@ func SetAPIRouter(router *gin.Engine) {
 			monitorRoute.POST("/batch_group_token_metrics", controller.BatchGetGroupTokenMetrics)
 			monitorRoute.GET("/models", controller.GetModelsErrorRate)
 			monitorRoute.GET("/banned_channels", controller.GetAllBannedModelChannels)
+			monitorRoute.GET("/auto_ban_rules", controller.GetAutoBanRules)
+			monitorRoute.PUT("/auto_ban_rules", controller.SaveAutoBanRules)
+			monitorRoute.POST("/auto_ban_rules/dry_run", controller.DryRunAutoBanRules)
+			monitorRoute.GET("/fingerprint_drift", controller.GetFingerprintDrift)
 			monitorRoute.GET("/:id", controller.GetChannelModelErrorRates)
 			monitorRoute.DELETE("/", controller.ClearAllModelErrors)
 			monitorRoute.DELETE("/:id", controller.ClearChannelAllModelErrors)
 			monitorRoute.DELETE("/:id/*model", controller.ClearChannelModelErrors)
 		}
 
+		debugRoute := apiRouter.Group("/debug")
+		{
+			debugRoute.POST("/convert", controller.DebugConvert)
+		}
+
 		publicsMcpRoute := apiRouter.Group("/mcp/publics")
 		{
 			publicsMcpRoute.GET("/", mcp.GetPublicMCPs)
@@ -2,6 +2,7 @@ package router
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/controller"
 )
 
 func SetRouter(router *gin.Engine) {
@@ -11,3 +12,19 @@ func SetRouter(router *gin.Engine) {
 	SetStaticFileRouter(router)
 	SetSwaggerRouter(router)
 }
+
+// SetRelayServerRouter sets the data plane routes served by a standalone relay
+// server, the admin api and the web are not exposed
+func SetRelayServerRouter(router *gin.Engine) {
+	router.GET("/api/status", controller.GetStatus)
+	SetRelayRouter(router)
+	SetMCPRouter(router)
+}
+
+// SetAdminServerRouter sets the control plane routes served by a standalone
+// admin server, the relay api is not exposed
+func SetAdminServerRouter(router *gin.Engine) {
+	SetAPIRouter(router)
+	SetStaticFileRouter(router)
+	SetSwaggerRouter(router)
+}
@@ -0,0 +1,530 @@
+package router
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/labring/aiproxy/core/controller"
+	"github.com/labring/aiproxy/core/docs"
+	"github.com/labring/aiproxy/core/middleware"
+)
+
+const (
+	openAPIVersion         = "3.1.0"
+	swaggerDefinitionsRef  = "#/definitions/"
+	openAPISchemasRef      = "#/components/schemas/"
+	openAPIVersionPathPart = "/{version}/"
+)
+
+// relayPathPrefixes are the paths of the relay surface exported to the openapi document
+var relayPathPrefixes = []string{
+	"/v1/",
+	"/v1beta/",
+	openAPIVersionPathPart,
+}
+
+// geminiVersions expands the /{version}/ paths of the gemini native api
+var geminiVersions = []string{"v1beta", "v1"}
+
+// streamingOperations may respond with server-sent events
+var streamingOperations = map[string]bool{
+	"post /v1/completions":        true,
+	"post /v1/chat/completions":   true,
+	"post /v1/messages":           true,
+	"post /v1/responses":          true,
+	"post /v1/models/{model}":     true,
+	"post /v1beta/models/{model}": true,
+}
+
+var (
+	relayOpenAPIOnce sync.Once
+	relayOpenAPIDoc  map[string]any
+	relayOpenAPIErr  error
+)
+
+func isRelayPath(path string) bool {
+	for _, prefix := range relayPathPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// convertRef rewrites the swagger 2.0 definition refs to openapi 3 schema refs
+func convertRef(v any, refs map[string]struct{}) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			if ref, ok := item.(string); ok && k == "$ref" &&
+				strings.HasPrefix(ref, swaggerDefinitionsRef) {
+				name := strings.TrimPrefix(ref, swaggerDefinitionsRef)
+				refs[name] = struct{}{}
+				out[k] = openAPISchemasRef + name
+
+				continue
+			}
+
+			out[k] = convertRef(item, refs)
+		}
+
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = convertRef(item, refs)
+		}
+
+		return out
+	default:
+		return v
+	}
+}
+
+// parameterSchema builds the schema of a non body swagger 2.0 parameter
+func parameterSchema(param map[string]any) map[string]any {
+	schema := map[string]any{}
+
+	for _, key := range []string{"type", "format", "enum", "items", "default", "minimum", "maximum"} {
+		if v, ok := param[key]; ok {
+			schema[key] = v
+		}
+	}
+
+	if schema["type"] == "file" {
+		schema["type"] = "string"
+		schema["contentMediaType"] = "application/octet-stream"
+	}
+
+	return schema
+}
+
+func firstString(v any, fallback string) string {
+	list, ok := v.([]any)
+	if !ok || len(list) == 0 {
+		return fallback
+	}
+
+	s, ok := list[0].(string)
+	if !ok || s == "" {
+		return fallback
+	}
+
+	return s
+}
+
+// aiproxyResponseHeaders are returned by every relay request
+func aiproxyResponseHeaders() map[string]any {
+	return map[string]any{
+		middleware.RequestIDHeader: map[string]any{
+			"$ref": "#/components/headers/RequestID",
+		},
+		middleware.XAiproxyModelDowngradedFrom: map[string]any{
+			"$ref": "#/components/headers/ModelDowngradedFrom",
+		},
+	}
+}
+
+// convertOperation converts a swagger 2.0 operation to an openapi 3.1 operation
+func convertOperation(
+	path, method string,
+	op map[string]any,
+	refs map[string]struct{},
+) map[string]any {
+	op, _ = convertRef(op, refs).(map[string]any)
+
+	out := make(map[string]any, len(op))
+	for _, key := range []string{"summary", "description", "tags", "security", "deprecated"} {
+		if v, ok := op[key]; ok {
+			out[key] = v
+		}
+	}
+
+	out["operationId"] = operationID(method, path)
+
+	var (
+		parameters   []any
+		formRequired []any
+	)
+
+	formProperties := map[string]any{}
+
+	params, _ := op["parameters"].([]any)
+	for _, p := range params {
+		param, ok := p.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		name, _ := param["name"].(string)
+		required, _ := param["required"].(bool)
+
+		switch param["in"] {
+		case "body":
+			requestBody := map[string]any{
+				"required": required,
+				"content": map[string]any{
+					firstString(op["consumes"], "application/json"): map[string]any{
+						"schema": param["schema"],
+					},
+				},
+			}
+			if desc, ok := param["description"]; ok {
+				requestBody["description"] = desc
+			}
+
+			out["requestBody"] = requestBody
+		case "formData":
+			schema := parameterSchema(param)
+			if desc, ok := param["description"]; ok {
+				schema["description"] = desc
+			}
+
+			formProperties[name] = schema
+
+			if required {
+				formRequired = append(formRequired, name)
+			}
+		case "header":
+			if name == controller.AIProxyChannelHeader {
+				parameters = append(parameters, map[string]any{
+					"$ref": "#/components/parameters/AiproxyChannel",
+				})
+
+				continue
+			}
+
+			fallthrough
+		default:
+			converted := map[string]any{
+				"name":     name,
+				"in":       param["in"],
+				"required": required || param["in"] == "path",
+				"schema":   parameterSchema(param),
+			}
+			if desc, ok := param["description"]; ok {
+				converted["description"] = desc
+			}
+
+			parameters = append(parameters, converted)
+		}
+	}
+
+	if len(formProperties) > 0 {
+		schema := map[string]any{
+			"type":       "object",
+			"properties": formProperties,
+		}
+		if len(formRequired) > 0 {
+			schema["required"] = formRequired
+		}
+
+		out["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"multipart/form-data": map[string]any{
+					"schema": schema,
+				},
+			},
+		}
+	}
+
+	if len(parameters) > 0 {
+		out["parameters"] = parameters
+	}
+
+	out["responses"] = convertResponses(streamingOperations[method+" "+path], op)
+
+	return out
+}
+
+func convertResponses(streaming bool, op map[string]any) map[string]any {
+	produces := firstString(op["produces"], "application/json")
+	responses, _ := op["responses"].(map[string]any)
+
+	out := make(map[string]any, len(responses))
+	for code, r := range responses {
+		resp, ok := r.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		description, _ := resp["description"].(string)
+		if description == "" {
+			status, _ := strconv.Atoi(code)
+			description = http.StatusText(status)
+		}
+
+		converted := map[string]any{
+			"description": description,
+		}
+
+		content := map[string]any{}
+		if schema, ok := resp["schema"]; ok {
+			content[produces] = map[string]any{"schema": schema}
+		}
+
+		headers := map[string]any{}
+
+		if strings.HasPrefix(code, "2") {
+			if streaming {
+				content["text/event-stream"] = map[string]any{
+					"schema": map[string]any{"type": "string"},
+				}
+			}
+
+			maps.Copy(headers, aiproxyResponseHeaders())
+		}
+
+		if swaggerHeaders, ok := resp["headers"].(map[string]any); ok {
+			for name, h := range swaggerHeaders {
+				header, ok := h.(map[string]any)
+				if !ok {
+					continue
+				}
+
+				convertedHeader := map[string]any{
+					"schema": parameterSchema(header),
+				}
+				if desc, ok := header["description"]; ok {
+					convertedHeader["description"] = desc
+				}
+
+				headers[name] = convertedHeader
+			}
+		}
+
+		if len(content) > 0 {
+			converted["content"] = content
+		}
+
+		if len(headers) > 0 {
+			converted["headers"] = headers
+		}
+
+		out[code] = converted
+	}
+
+	if len(out) == 0 {
+		out["200"] = map[string]any{"description": "OK"}
+	}
+
+	return out
+}
+
+var operationIDReplacer = strings.NewReplacer("-", "_", ".", "_", ":", "_")
+
+// operationID builds a unique operation id from the method and the path,
+// e.g. post /v1/chat/completions is post_v1_chat_completions
+func operationID(method, path string) string {
+	var sb strings.Builder
+
+	sb.WriteString(method)
+
+	for part := range strings.SplitSeq(path, "/") {
+		part = strings.Trim(part, "{}")
+		if part == "" {
+			continue
+		}
+
+		sb.WriteByte('_')
+		sb.WriteString(operationIDReplacer.Replace(part))
+	}
+
+	return sb.String()
+}
+
+func addOperation(
+	paths map[string]any,
+	path, method string,
+	op map[string]any,
+	refs map[string]struct{},
+) {
+	item, ok := paths[path].(map[string]any)
+	if !ok {
+		item = map[string]any{}
+		paths[path] = item
+	}
+
+	item[method] = convertOperation(path, method, op, refs)
+}
+
+// removeVersionParameter removes the version path parameter of the expanded gemini paths
+func removeVersionParameter(op map[string]any) map[string]any {
+	params, _ := op["parameters"].([]any)
+
+	out := maps.Clone(op)
+	out["parameters"] = slices.DeleteFunc(slices.Clone(params), func(p any) bool {
+		param, ok := p.(map[string]any)
+		return ok && param["in"] == "path" && param["name"] == "version"
+	})
+
+	return out
+}
+
+// collectSchemas returns the definitions referenced by the relay operations,
+// including the definitions referenced by other definitions
+func collectSchemas(definitions map[string]any, refs map[string]struct{}) map[string]any {
+	schemas := map[string]any{}
+
+	pending := slices.Collect(maps.Keys(refs))
+	for len(pending) > 0 {
+		name := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if _, ok := schemas[name]; ok {
+			continue
+		}
+
+		definition, ok := definitions[name]
+		if !ok {
+			continue
+		}
+
+		nested := map[string]struct{}{}
+		schemas[name] = convertRef(definition, nested)
+
+		for nestedName := range nested {
+			if _, ok := schemas[nestedName]; !ok {
+				pending = append(pending, nestedName)
+			}
+		}
+	}
+
+	return schemas
+}
+
+// buildRelayOpenAPI converts the swagger 2.0 document generated by swag to an
+// openapi 3.1 document of the relay surface
+func buildRelayOpenAPI(swaggerDoc string) (map[string]any, error) {
+	var swagger map[string]any
+	if err := sonic.UnmarshalString(swaggerDoc, &swagger); err != nil {
+		return nil, err
+	}
+
+	swaggerPaths, _ := swagger["paths"].(map[string]any)
+	definitions, _ := swagger["definitions"].(map[string]any)
+
+	refs := map[string]struct{}{}
+	paths := map[string]any{}
+
+	for path, item := range swaggerPaths {
+		if !isRelayPath(path) {
+			continue
+		}
+
+		operations, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		for method, o := range operations {
+			op, ok := o.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			versionPath, isVersioned := strings.CutPrefix(path, openAPIVersionPathPart)
+			if !isVersioned {
+				addOperation(paths, path, method, op, refs)
+				continue
+			}
+
+			for _, version := range geminiVersions {
+				addOperation(
+					paths,
+					"/"+version+"/"+versionPath,
+					method,
+					removeVersionParameter(op),
+					refs,
+				)
+			}
+		}
+	}
+
+	info := map[string]any{"version": "1.0"}
+	if swaggerInfo, ok := swagger["info"].(map[string]any); ok {
+		maps.Copy(info, swaggerInfo)
+	}
+
+	info["title"] = "AI Proxy Relay API"
+
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info":    info,
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": collectSchemas(definitions, refs),
+			"securitySchemes": map[string]any{
+				"ApiKeyAuth": map[string]any{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        "Authorization",
+					"description": "Bearer token, the X-Api-Key and X-Goog-Api-Key headers are accepted as well",
+				},
+			},
+			"headers": map[string]any{
+				"RequestID": map[string]any{
+					"description": "Request id of the aiproxy log",
+					"schema":      map[string]any{"type": "string"},
+				},
+				"ModelDowngradedFrom": map[string]any{
+					"description": "Requested model when the request is routed to the spend cap fallback model",
+					"schema":      map[string]any{"type": "string"},
+				},
+			},
+			"parameters": map[string]any{
+				"AiproxyChannel": map[string]any{
+					"name":        controller.AIProxyChannelHeader,
+					"in":          "header",
+					"description": "Pin the request to a channel id, only available for admin keys",
+					"schema":      map[string]any{"type": "string"},
+				},
+			},
+		},
+	}, nil
+}
+
+func getRelayOpenAPI() (map[string]any, error) {
+	relayOpenAPIOnce.Do(func() {
+		relayOpenAPIDoc, relayOpenAPIErr = buildRelayOpenAPI(docs.SwaggerInfo.ReadDoc())
+	})
+
+	return relayOpenAPIDoc, relayOpenAPIErr
+}
+
+func openAPIHandler(ctx *gin.Context) {
+	doc, err := getRelayOpenAPI()
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if ctx.Request.Host != "" {
+		scheme := "http"
+		if ctx.Request.TLS != nil || ctx.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+
+		doc = maps.Clone(doc)
+		doc["servers"] = []any{
+			map[string]any{"url": scheme + "://" + ctx.Request.Host},
+		}
+	}
+
+	body, err := sonic.ConfigStd.Marshal(doc)
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
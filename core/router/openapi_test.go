@@ -0,0 +1,71 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	corerouter "github.com/labring/aiproxy/core/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIDocumentCoversRelaySurface(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	corerouter.SetSwaggerRouter(router)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	req.Host = "aiproxy.example.com"
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc map[string]any
+	require.NoError(t, sonic.Unmarshal(w.Body.Bytes(), &doc))
+
+	assert.Equal(t, "3.1.0", doc["openapi"])
+
+	servers, _ := doc["servers"].([]any)
+	require.Len(t, servers, 1)
+
+	paths, _ := doc["paths"].(map[string]any)
+	for _, path := range []string{
+		"/v1/chat/completions",
+		"/v1/messages",
+		"/v1/embeddings",
+		"/v1/audio/speech",
+		"/v1/images/generations",
+		"/v1beta/models/{model}",
+	} {
+		assert.Contains(t, paths, path)
+	}
+
+	for path := range paths {
+		assert.False(t, strings.HasPrefix(path, "/api/"), "unexpected admin path %s", path)
+		assert.NotContains(t, path, "{version}")
+	}
+
+	// every schema ref must be resolvable
+	components, _ := doc["components"].(map[string]any)
+	schemas, _ := components["schemas"].(map[string]any)
+
+	body := w.Body.String()
+	for ref := range strings.SplitSeq(body, `"#/components/schemas/`) {
+		name, _, ok := strings.Cut(ref, `"`)
+		if !ok || strings.HasPrefix(body, ref) {
+			continue
+		}
+
+		assert.Contains(t, schemas, name)
+	}
+
+	assert.NotContains(t, body, "#/definitions/")
+}
@@ -9,16 +9,29 @@ import (
 func SetRelayRouter(router *gin.Engine) {
 	// https://platform.openai.com/docs/api-reference/introduction
 	v1Router := router.Group("/v1")
-	v1Router.Use(middleware.IPBlock, middleware.TokenAuth)
+	v1Router.Use(middleware.IPBlock, middleware.TokenAuth, middleware.Idempotency)
 
 	v1betaRouter := router.Group("/v1beta")
-	v1betaRouter.Use(middleware.IPBlock, middleware.TokenAuth)
+	v1betaRouter.Use(middleware.IPBlock, middleware.TokenAuth, middleware.Idempotency)
 
 	aliRouter := router.Group("/api/v1")
-	aliRouter.Use(middleware.IPBlock, middleware.TokenAuth)
+	aliRouter.Use(middleware.IPBlock, middleware.TokenAuth, middleware.Idempotency)
+
+	// websocket clients, e.g. browsers, pass the api key by the subprotocols
+	wsRouter := router.Group("/v1")
+	wsRouter.Use(middleware.WebSocketAPIKey, middleware.IPBlock, middleware.TokenAuth)
+
+	// https://ai.google.dev/api/live
+	liveRouter := router.Group("/ws")
+	liveRouter.Use(middleware.IPBlock, middleware.TokenAuth)
+
+	// the signed media urls are public, they are short-lived instead
+	mediaRouter := router.Group("/v1/media")
+	mediaRouter.Use(middleware.IPBlock)
+	mediaRouter.GET("/:key", controller.GetMedia)
 
 	doubaoRouter := router.Group("/api/v3")
-	doubaoRouter.Use(middleware.IPBlock, middleware.TokenAuth)
+	doubaoRouter.Use(middleware.IPBlock, middleware.TokenAuth, middleware.Idempotency)
 
 	modelsRouter := v1Router.Group("/models")
 	{
@@ -80,6 +93,18 @@ func SetRelayRouter(router *gin.Engine) {
 			"/files/*model",
 			controller.GeminiByPath()...,
 		)
+		v1betaRouter.POST(
+			"/cachedContents",
+			controller.GeminiCachedContents()...,
+		)
+		liveRouter.GET(
+			"/google.ai.generativelanguage.v1beta.GenerativeService.BidiGenerateContent",
+			controller.GeminiLive()...,
+		)
+		liveRouter.GET(
+			"/google.ai.generativelanguage.v1alpha.GenerativeService.BidiGenerateContent",
+			controller.GeminiLive()...,
+		)
 	}
 
 	dashboardRouter := v1Router.Group("/dashboard")
@@ -87,8 +112,12 @@ func SetRelayRouter(router *gin.Engine) {
 		dashboardRouter.GET("/billing/subscription", controller.GetSubscription)
 		dashboardRouter.GET("/billing/usage", controller.GetUsage)
 		dashboardRouter.GET("/billing/quota", controller.GetQuota)
+		dashboardRouter.GET("/usage/timeseries", controller.GetTokenUsageTimeSeries)
 	}
 
+	v1Router.GET("/rate_limits", controller.GetRateLimits)
+	v1Router.POST("/feedback", controller.Feedback)
+
 	relayRouter := v1Router.Group("")
 	{
 		relayRouter.POST(
@@ -99,6 +128,10 @@ func SetRelayRouter(router *gin.Engine) {
 			"/chat/completions",
 			controller.ChatCompletions()...,
 		)
+		wsRouter.GET(
+			"/chat/completions/ws",
+			controller.ChatCompletionsWebSocket()...,
+		)
 		relayRouter.POST(
 			"/messages",
 			controller.Anthropic()...,
@@ -131,6 +164,14 @@ func SetRelayRouter(router *gin.Engine) {
 			"/audio/speech",
 			controller.AudioSpeech()...,
 		)
+		relayRouter.POST(
+			"/audio/generations",
+			controller.AudioGenerations()...,
+		)
+		relayRouter.GET(
+			"/audio/generations/:id",
+			controller.GetAudioGeneration()...,
+		)
 		relayRouter.POST(
 			"/rerank",
 			controller.Rerank()...,
@@ -194,13 +235,17 @@ func SetRelayRouter(router *gin.Engine) {
 		relayRouter.GET(
 			"/responses/:response_id/input_items",
 			controller.GetResponseInputItems()...)
+		relayRouter.GET("/files", controller.ListFiles)
+		relayRouter.POST("/files",
+			controller.UploadFile()...)
+		relayRouter.GET("/files/:id",
+			controller.GetFile()...)
+		relayRouter.GET("/files/:id/content",
+			controller.GetFileContent()...)
+		relayRouter.DELETE("/files/:id",
+			controller.DeleteFile()...)
 
 		relayRouter.POST("/images/variations", controller.RelayNotImplemented)
-		relayRouter.GET("/files", controller.RelayNotImplemented)
-		relayRouter.POST("/files", controller.RelayNotImplemented)
-		relayRouter.DELETE("/files/:id", controller.RelayNotImplemented)
-		relayRouter.GET("/files/:id", controller.RelayNotImplemented)
-		relayRouter.GET("/files/:id/content", controller.RelayNotImplemented)
 		relayRouter.POST("/fine_tuning/jobs", controller.RelayNotImplemented)
 		relayRouter.GET("/fine_tuning/jobs", controller.RelayNotImplemented)
 		relayRouter.GET("/fine_tuning/jobs/:id", controller.RelayNotImplemented)
@@ -25,3 +25,33 @@ func TestSetRelayRouterRegistersGeminiModelScopedOperationRoutes(t *testing.T) {
 	require.True(t, registered["GET /v1beta/models/:model/operations/*operation_id"])
 	require.True(t, registered["GET /v1beta/files/*model"])
 }
+
+func TestSplitServerRoutersSeparateRelayAndAdminRoutes(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+
+	relayRouter := gin.New()
+	corerouter.SetRelayServerRouter(relayRouter)
+
+	relayRoutes := map[string]bool{}
+	for _, route := range relayRouter.Routes() {
+		relayRoutes[route.Method+" "+route.Path] = true
+	}
+
+	require.True(t, relayRoutes["POST /v1/chat/completions"])
+	require.True(t, relayRoutes["GET /api/status"])
+	require.False(t, relayRoutes["GET /api/channels/"])
+
+	adminRouter := gin.New()
+	corerouter.SetAdminServerRouter(adminRouter)
+
+	adminRoutes := map[string]bool{}
+	for _, route := range adminRouter.Routes() {
+		adminRoutes[route.Method+" "+route.Path] = true
+	}
+
+	require.True(t, adminRoutes["GET /api/channels/"])
+	require.True(t, adminRoutes["GET /api/status"])
+	require.False(t, adminRoutes["POST /v1/chat/completions"])
+}
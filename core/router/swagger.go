@@ -29,6 +29,8 @@ func SetSwaggerRouter(router *gin.Engine) {
 		swagInfo.Host = ctx.Request.Host
 		ctx.String(http.StatusOK, swagInfo.ReadDoc())
 	})
+	// openapi 3.1 document of the relay surface for generating client sdks
+	router.GET("/openapi.json", openAPIHandler)
 	router.GET("/swagger", func(ctx *gin.Context) {
 		ctx.Redirect(http.StatusMovedPermanently, "/swagger/index.html")
 	})
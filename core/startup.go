@@ -18,13 +18,19 @@ import (
 	"github.com/labring/aiproxy/core/common/balance"
 	"github.com/labring/aiproxy/core/common/config"
 	"github.com/labring/aiproxy/core/common/conv"
+	"github.com/labring/aiproxy/core/common/geoip"
 	"github.com/labring/aiproxy/core/common/notify"
+	"github.com/labring/aiproxy/core/common/oidc"
 	"github.com/labring/aiproxy/core/common/oncall"
 	"github.com/labring/aiproxy/core/common/pprof"
+	"github.com/labring/aiproxy/core/common/secret"
+	"github.com/labring/aiproxy/core/common/tiktoken"
+	"github.com/labring/aiproxy/core/grpcrelay"
 	"github.com/labring/aiproxy/core/middleware"
 	"github.com/labring/aiproxy/core/model"
 	"github.com/labring/aiproxy/core/router"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 )
 
 func initializeServices(pprofPort int) error {
@@ -42,6 +48,18 @@ func initializeServices(pprofPort int) error {
 		return err
 	}
 
+	if err := initializeGeoIP(); err != nil {
+		return err
+	}
+
+	if err := oidc.Init(); err != nil {
+		return err
+	}
+
+	if err := secret.Init(); err != nil {
+		return err
+	}
+
 	if err := model.InitDB(); err != nil {
 		return err
 	}
@@ -50,9 +68,36 @@ func initializeServices(pprofPort int) error {
 		return err
 	}
 
+	initializeTokenEncoders()
+
 	return model.InitLogDB(int(config.GetCleanLogBatchSize()))
 }
 
+// runEncryptChannelKeys encrypts the channel keys stored before the secret
+// encryption was enabled
+func runEncryptChannelKeys() error {
+	if err := secret.Init(); err != nil {
+		return err
+	}
+
+	if err := model.InitDB(); err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = model.CloseDB()
+	}()
+
+	count, err := model.EncryptChannelKeys()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("encrypted %d channel keys and configs", count)
+
+	return nil
+}
+
 func initializePprof(pprofPort int) {
 	go func() {
 		err := pprof.RunPprofServer(pprofPort)
@@ -74,6 +119,18 @@ func initializeBalance() error {
 	return balance.InitSealos(sealosJwtKey, os.Getenv("SEALOS_ACCOUNT_URL"))
 }
 
+func initializeGeoIP() error {
+	geoIPDBPath := os.Getenv("GEOIP_DB_PATH")
+	if geoIPDBPath == "" {
+		log.Info("GEOIP_DB_PATH is not set, geo restrictions and routing will not be enabled")
+		return nil
+	}
+
+	log.Info("GEOIP_DB_PATH is set, geo restrictions and routing will be enabled")
+
+	return geoip.Init(geoIPDBPath)
+}
+
 func initializeNotifier() {
 	feishuWh := os.Getenv("NOTIFY_FEISHU_WEBHOOK")
 	if feishuWh != "" {
@@ -92,6 +149,31 @@ func initializeOptionAndCaches() error {
 	return model.InitModelConfigAndChannelCache()
 }
 
+// initializeTokenEncoders builds the token encoders of the configured models
+// and the mapped upstream models, so the first requests do not build them
+func initializeTokenEncoders() {
+	caches := model.LoadModelCaches()
+
+	models := make([]string, 0, len(caches.EnabledModelConfigsMap))
+	for name := range caches.EnabledModelConfigsMap {
+		models = append(models, name)
+	}
+
+	for _, model2Channels := range caches.EnabledModel2ChannelsBySet {
+		for _, channels := range model2Channels {
+			for _, channel := range channels {
+				for _, actualModel := range channel.ModelMapping {
+					models = append(models, actualModel)
+				}
+			}
+		}
+	}
+
+	start := time.Now()
+	loaded := tiktoken.PreWarm(models)
+	log.Infof("pre-warmed token encoders of %d models in %s", loaded, time.Since(start))
+}
+
 func startSyncServices(ctx context.Context, wg *sync.WaitGroup) {
 	wg.Add(2)
 
@@ -99,27 +181,145 @@ func startSyncServices(ctx context.Context, wg *sync.WaitGroup) {
 	go model.SyncModelConfigAndChannelCache(ctx, wg, time.Second*10)
 }
 
-func setupHTTPServer(listen string) (*http.Server, *gin.Engine) {
+const (
+	serverRoleAll   = "all"
+	serverRoleRelay = "relay"
+	serverRoleAdmin = "admin"
+)
+
+func newHTTPServer(
+	listen string,
+	setRouter func(*gin.Engine),
+) (*http.Server, *gin.Engine, error) {
 	server := gin.New()
 
+	// the forwarded headers decide the client ip used by the token subnets,
+	// with the trusted proxies set they are only honored from them, every peer
+	// is trusted like before otherwise. The token regions and the geo routing
+	// use the peer address then, see middleware.GeoClientIP
+	if len(config.TrustedProxies) > 0 {
+		if err := server.SetTrustedProxies(config.TrustedProxies); err != nil {
+			return nil, nil, fmt.Errorf("invalid trusted proxies: %w", err)
+		}
+	}
+
 	server.Use(
 		middleware.GinRecoveryHandler,
 		middleware.NewLog(log.StandardLogger()),
 		middleware.RequestIDMiddleware,
 		middleware.CORS(),
 	)
-	router.SetRouter(server)
-
-	listenEnv := os.Getenv("LISTEN")
-	if listenEnv != "" {
-		listen = listenEnv
-	}
+	setRouter(server)
 
 	return &http.Server{
 		Addr:              listen,
 		ReadHeaderTimeout: 10 * time.Second,
 		Handler:           server,
-	}, server
+	}, server, nil
+}
+
+// setupHTTPServers creates the http servers of the role, the relay and the
+// admin api are served by separate servers when the admin listen address is
+// set, the returned engine is the relay engine and is nil for the admin role
+func setupHTTPServers(role, listen, adminListen string) ([]*http.Server, *gin.Engine, error) {
+	if listenEnv := os.Getenv("LISTEN"); listenEnv != "" {
+		listen = listenEnv
+	}
+
+	if adminListenEnv := os.Getenv("ADMIN_LISTEN"); adminListenEnv != "" {
+		adminListen = adminListenEnv
+	}
+
+	if roleEnv := os.Getenv("SERVER_ROLE"); roleEnv != "" {
+		role = roleEnv
+	}
+
+	if len(config.TrustedProxies) == 0 {
+		log.Warn(
+			"TRUSTED_PROXIES is not set, the X-Forwarded-For and X-Real-IP headers of every peer " +
+				"are trusted as the client ip of the token subnets and the peer address is used " +
+				"for the token regions and the geo routing, set it to the proxies in front of aiproxy",
+		)
+	}
+
+	switch role {
+	case serverRoleAll, "":
+		if adminListen == "" {
+			srv, engine, err := newHTTPServer(listen, router.SetRouter)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return []*http.Server{srv}, engine, nil
+		}
+
+		relaySrv, engine, err := newHTTPServer(listen, router.SetRelayServerRouter)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		adminSrv, _, err := newHTTPServer(adminListen, router.SetAdminServerRouter)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return []*http.Server{relaySrv, adminSrv}, engine, nil
+	case serverRoleRelay:
+		srv, engine, err := newHTTPServer(listen, router.SetRelayServerRouter)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return []*http.Server{srv}, engine, nil
+	case serverRoleAdmin:
+		if adminListen == "" {
+			adminListen = listen
+		}
+
+		srv, _, err := newHTTPServer(adminListen, router.SetAdminServerRouter)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return []*http.Server{srv}, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown server role: %s", role)
+	}
+}
+
+func shutdownHTTPServers(ctx context.Context, servers []*http.Server) {
+	var wg sync.WaitGroup
+
+	for _, srv := range servers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Errorf("server %s forced to shutdown: %s", srv.Addr, err.Error())
+			} else {
+				log.Infof("server %s shutdown successfully", srv.Addr)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// setupGRPCServer creates the grpc relay server sharing the http handler,
+// it is disabled when the listen address is empty
+func setupGRPCServer(listen string, handler http.Handler) (*grpcrelay.Server, string) {
+	listenEnv := os.Getenv("GRPC_LISTEN")
+	if listenEnv != "" {
+		listen = listenEnv
+	}
+
+	if listen == "" {
+		return nil, ""
+	}
+
+	return grpcrelay.NewServer(handler), listen
 }
 
 var loadedEnvFiles []string
@@ -172,6 +372,13 @@ func listenAndServe(srv *http.Server) {
 	}
 }
 
+func grpcListenAndServe(srv *grpcrelay.Server, addr string) {
+	if err := srv.ListenAndServe(addr); err != nil &&
+		!errors.Is(err, grpc.ErrServerStopped) {
+		log.Fatal("failed to start grpc server: " + err.Error())
+	}
+}
+
 const (
 	keyChars = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 )
@@ -0,0 +1,97 @@
+package task
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/labring/aiproxy/core/common"
+	"github.com/labring/aiproxy/core/common/notify"
+	"github.com/labring/aiproxy/core/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// leaderLeaseName is the lease shared by the periodic tasks that must run on one instance
+	leaderLeaseName = "task-leader"
+	// LeaderLeaseTTL is how long the lease is held without renewal, a new leader
+	// is elected within this duration after the leader is gone
+	LeaderLeaseTTL = 30 * time.Second
+	// LeaderRenewInterval is how often the lease is acquired or renewed
+	LeaderRenewInterval = 10 * time.Second
+)
+
+var (
+	leaderID = newLeaderID()
+	isLeader atomic.Bool
+)
+
+func newLeaderID() string {
+	hostname, _ := os.Hostname()
+	if len(hostname) > 31 {
+		hostname = hostname[:31]
+	}
+
+	return hostname + "-" + common.ShortUUID()
+}
+
+// IsLeader reports whether this instance holds the task lease
+func IsLeader() bool {
+	return isLeader.Load()
+}
+
+// acquireLeaderLease acquires or renews the lease in the database, the lease
+// is kept in the database only, so all the instances agree on the leader
+// whether redis is available or not
+func acquireLeaderLease() (bool, error) {
+	return model.TryAcquireTaskLease(leaderLeaseName, leaderID, LeaderLeaseTTL)
+}
+
+func releaseLeaderLease() {
+	if err := model.ReleaseTaskLease(leaderLeaseName, leaderID); err != nil {
+		log.Errorf("release task leader lease failed: %v", err)
+	}
+}
+
+func campaign() {
+	acquired, err := acquireLeaderLease()
+	if err != nil {
+		notify.ErrorThrottle(
+			"taskLeaderElection",
+			time.Minute*5,
+			"task leader election failed",
+			err.Error(),
+		)
+	}
+
+	if isLeader.Swap(acquired) != acquired {
+		if acquired {
+			log.Infof("became task leader: %s", leaderID)
+		} else {
+			log.Infof("lost task leader: %s", leaderID)
+		}
+	}
+}
+
+// LeaderElectionTask elects one instance to run the periodic tasks, the lease
+// is released on shutdown so that another instance takes over immediately
+func LeaderElectionTask(ctx context.Context) {
+	campaign()
+
+	ticker := time.NewTicker(LeaderRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if isLeader.Swap(false) {
+				releaseLeaderLease()
+			}
+
+			return
+		case <-ticker.C:
+			campaign()
+		}
+	}
+}
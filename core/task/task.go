@@ -8,17 +8,18 @@ import (
 	"fmt"
 	"net/http"
 	"slices"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/bytedance/sonic"
 	"github.com/labring/aiproxy/core/common"
 	"github.com/labring/aiproxy/core/common/balance"
+	"github.com/labring/aiproxy/core/common/billing"
 	"github.com/labring/aiproxy/core/common/config"
 	"github.com/labring/aiproxy/core/common/consume"
 	"github.com/labring/aiproxy/core/common/conv"
 	"github.com/labring/aiproxy/core/common/ipblack"
+	"github.com/labring/aiproxy/core/common/mediastore"
 	"github.com/labring/aiproxy/core/common/notify"
 	"github.com/labring/aiproxy/core/common/oncall"
 	"github.com/labring/aiproxy/core/common/trylock"
@@ -40,11 +41,99 @@ func AutoTestBannedModelsTask(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			if !IsLeader() {
+				continue
+			}
+
 			controller.AutoTestBannedModels()
 		}
 	}
 }
 
+// UpdateChannelsBalanceTask 更新渠道余额任务
+func UpdateChannelsBalanceTask(ctx context.Context, frequency time.Duration) {
+	ticker := time.NewTicker(frequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !IsLeader() {
+				continue
+			}
+
+			if err := controller.RefreshAllChannelsBalance(); err != nil {
+				log.Errorf("update channels balance failed: %v", err)
+			}
+		}
+	}
+}
+
+// SyncChannelsModelsTask adds the new upstream models to the channels with the
+// auto model sync enabled
+func SyncChannelsModelsTask(ctx context.Context, frequency time.Duration) {
+	ticker := time.NewTicker(frequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !IsLeader() {
+				continue
+			}
+
+			if err := controller.SyncAllChannelsModels(ctx); err != nil {
+				log.Errorf("sync channels models failed: %v", err)
+			}
+		}
+	}
+}
+
+// ProbeChannelsReadinessTask probes the readiness of the self hosted upstreams,
+// it runs on every instance as the readiness is kept per instance
+func ProbeChannelsReadinessTask(ctx context.Context, frequency time.Duration) {
+	ticker := time.NewTicker(frequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// a channel not probed again, e.g. disabled, is used again after
+			// missing a few probes
+			controller.ProbeChannelsReadiness(ctx, frequency*3)
+		}
+	}
+}
+
+// CleanMediaTask removes the media files whose signed urls have expired, it
+// runs on every instance as the storage dir may not be shared
+func CleanMediaTask(ctx context.Context, store *mediastore.Store, frequency time.Duration) {
+	ticker := time.NewTicker(frequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := store.Cleanup(mediastore.TTL()); err != nil {
+				notify.ErrorThrottle(
+					"cleanMediaError",
+					time.Minute*5,
+					"clean media failed",
+					err.Error(),
+				)
+			}
+		}
+	}
+}
+
 // DetectIPGroupsTask 检测 IP 使用多个 group 的情况
 func DetectIPGroupsTask(ctx context.Context) {
 	ticker := time.NewTicker(time.Minute)
@@ -142,9 +231,9 @@ func detectIPGroups() {
 	}
 }
 
-// UsageAlertTask 用量异常告警任务
+// UsageAlertTask 用量异常告警与预算告警任务
 func UsageAlertTask(ctx context.Context) {
-	ticker := time.NewTicker(time.Hour)
+	ticker := time.NewTicker(budgetAlertInterval)
 	defer ticker.Stop()
 
 	for {
@@ -152,6 +241,11 @@ func UsageAlertTask(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			// the lease may overlap while the leader changes
+			if IsLeader() && trylock.Lock("runBudgetAlert", budgetAlertInterval) {
+				checkBudgetAlert()
+			}
+
 			if !trylock.Lock("runUsageAlert", time.Hour) {
 				continue
 			}
@@ -161,6 +255,20 @@ func UsageAlertTask(ctx context.Context) {
 	}
 }
 
+// usageAlertTemplate 用量异常告警的默认模板
+var usageAlertTemplate = config.AlertTemplate{
+	Title: "Detected {{ len .Alerts }} groups with abnormal usage",
+	Message: "{{ range .Alerts }}GroupID: {{ .GroupID }}" +
+		" | 3-Day Avg: {{ printf \"%.4f\" .ThreeDayAvgAmount }}" +
+		" | Today: {{ printf \"%.4f\" .TodayAmount }}" +
+		" | Ratio: {{ printf \"%.2f\" .Ratio }}x\n{{ end }}",
+}
+
+// UsageAlertData is the data of the usage alert templates
+type UsageAlertData struct {
+	Alerts []model.GroupUsageAlertItem
+}
+
 func checkUsageAlert() {
 	threshold := config.GetUsageAlertThreshold()
 	if threshold <= 0 {
@@ -208,29 +316,207 @@ func checkUsageAlert() {
 		return
 	}
 
-	message := formatGroupUsageAlerts(validAlerts)
-	notify.Warn(
-		fmt.Sprintf("Detected %d groups with abnormal usage", len(validAlerts)),
-		message,
+	notify.Alert(
+		notify.LevelWarn,
+		notify.AlertKindUsage,
+		usageAlertTemplate,
+		UsageAlertData{Alerts: validAlerts},
 	)
 }
 
-// formatGroupUsageAlerts 格式化告警消息
-func formatGroupUsageAlerts(alerts []model.GroupUsageAlertItem) string {
-	if len(alerts) == 0 {
-		return ""
+const budgetAlertInterval = 5 * time.Minute
+
+// budgetAlertTemplate is the default template of the budget alerts
+var budgetAlertTemplate = config.AlertTemplate{
+	Title: "Detected {{ len .Alerts }} tokens reaching their budget",
+	Message: "{{ range .Alerts }}Group: {{ .GroupID }} | Token: {{ .TokenName }} ({{ .TokenID }})" +
+		" | {{ if eq .QuotaType \"period\" }}{{ .PeriodType }} {{ end }}Quota: {{ printf \"%.4f\" .Quota }}" +
+		" | Used: {{ printf \"%.4f\" .UsedAmount }} ({{ percent .Ratio }})" +
+		" | Threshold: {{ percent .Threshold }}\n{{ end }}",
+}
+
+// BudgetAlertData is the data of the budget alert templates
+type BudgetAlertData struct {
+	Alerts []model.TokenBudgetAlertItem
+}
+
+func checkBudgetAlert() {
+	if len(config.GetBudgetAlertDefaultThresholds()) == 0 &&
+		len(config.GetBudgetAlertGroupThresholds()) == 0 {
+		return
+	}
+
+	alerts, err := model.GetTokenBudgetAlerts(config.GetBudgetAlertThresholds)
+	if err != nil {
+		notify.ErrorThrottle(
+			"budgetAlertError",
+			time.Minute*5,
+			"check budget alert failed",
+			err.Error(),
+		)
+
+		return
 	}
 
-	var result strings.Builder
+	dedup := time.Duration(config.GetBudgetAlertDedupSeconds()) * time.Second
+
+	var (
+		validAlerts []model.TokenBudgetAlertItem
+		exhausted   bool
+	)
+
 	for _, alert := range alerts {
-		fmt.Fprintf(&result, "GroupID: %s | 3-Day Avg: %.4f | Today: %.4f | Ratio: %.2fx\n",
-			alert.GroupID,
-			alert.ThreeDayAvgAmount,
-			alert.TodayAmount,
-			alert.Ratio)
+		// a threshold of a quota alerts once within the window, the period
+		// quota alerts again after the period is reset
+		lockKey := fmt.Sprintf(
+			"budgetAlert:%d:%s:%d:%g",
+			alert.TokenID,
+			alert.QuotaType,
+			alert.PeriodStart,
+			alert.Threshold,
+		)
+		if !trylock.Lock(lockKey, dedup) {
+			continue
+		}
+
+		validAlerts = append(validAlerts, alert)
+
+		if alert.Ratio >= 1 {
+			exhausted = true
+		}
+	}
+
+	if len(validAlerts) == 0 {
+		return
 	}
 
-	return result.String()
+	level := notify.LevelWarn
+	if exhausted {
+		level = notify.LevelError
+	}
+
+	notify.Alert(
+		level,
+		notify.AlertKindBudget,
+		budgetAlertTemplate,
+		BudgetAlertData{Alerts: validAlerts},
+	)
+}
+
+const (
+	deprecationAlertInterval = time.Hour
+	// the deprecated models are alerted once a day within the window before
+	// their sunset
+	deprecationAlertWindow = 7 * 24 * time.Hour
+	// the groups affected by a deprecated model are the groups using it within
+	// the lookback
+	deprecationUsageLookback = 7 * 24 * time.Hour
+)
+
+// deprecationAlertTemplate is the default template of the deprecation alerts
+var deprecationAlertTemplate = config.AlertTemplate{
+	Title: "{{ len .Alerts }} deprecated models will be sunset soon",
+	Message: "{{ range .Alerts }}Model: {{ .Model }}" +
+		" | Sunset: {{ .SunsetAt.Format \"2006-01-02 15:04 MST\" }}" +
+		"{{ if .ReplacementModel }} | Replacement: {{ .ReplacementModel }}{{ end }}\n" +
+		"{{ range .Groups }}  GroupID: {{ .GroupID }}" +
+		" | Requests: {{ .RequestCount }}" +
+		" | Amount: {{ printf \"%.4f\" .UsedAmount }}\n{{ end }}{{ end }}",
+}
+
+// ModelDeprecationAlert is a deprecated model to be sunset, the affected
+// groups are sorted by their usage of the model
+type ModelDeprecationAlert struct {
+	Model            string
+	SunsetAt         time.Time
+	ReplacementModel string
+	Groups           []model.ModelGroupUsage
+}
+
+// ModelDeprecationAlertData is the data of the deprecation alert templates
+type ModelDeprecationAlertData struct {
+	Alerts []ModelDeprecationAlert
+}
+
+// ModelDeprecationAlertTask notifies the groups using the models to be sunset
+func ModelDeprecationAlertTask(ctx context.Context) {
+	ticker := time.NewTicker(deprecationAlertInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !IsLeader() {
+				continue
+			}
+
+			// the lease may overlap while the leader changes
+			if !trylock.Lock("runModelDeprecationAlert", deprecationAlertInterval) {
+				continue
+			}
+
+			checkModelDeprecationAlert()
+		}
+	}
+}
+
+func checkModelDeprecationAlert() {
+	now := time.Now()
+
+	var alerts []ModelDeprecationAlert
+
+	for _, mc := range model.LoadModelCaches().EnabledModelConfigsMap {
+		if mc.SunsetAt == nil ||
+			mc.IsSunset(now) ||
+			mc.SunsetAt.Sub(now) > deprecationAlertWindow {
+			continue
+		}
+
+		lockKey := fmt.Sprintf("deprecationAlert:%s:%d", mc.Model, mc.SunsetAt.Unix())
+		if !trylock.Lock(lockKey, 24*time.Hour) {
+			continue
+		}
+
+		groups, err := model.GetModelGroupUsages(mc.Model, now.Add(-deprecationUsageLookback))
+		if err != nil {
+			notify.ErrorThrottle(
+				"deprecationAlertError",
+				time.Minute*5,
+				"check model deprecation alert failed",
+				err.Error(),
+			)
+
+			return
+		}
+
+		if len(groups) == 0 {
+			continue
+		}
+
+		alerts = append(alerts, ModelDeprecationAlert{
+			Model:            mc.Model,
+			SunsetAt:         *mc.SunsetAt,
+			ReplacementModel: mc.ReplacementModel,
+			Groups:           groups,
+		})
+	}
+
+	if len(alerts) == 0 {
+		return
+	}
+
+	slices.SortFunc(alerts, func(a, b ModelDeprecationAlert) int {
+		return a.SunsetAt.Compare(b.SunsetAt)
+	})
+
+	notify.Alert(
+		notify.LevelWarn,
+		notify.AlertKindDeprecation,
+		deprecationAlertTemplate,
+		ModelDeprecationAlertData{Alerts: alerts},
+	)
 }
 
 // CleanLogTask 清理日志任务
@@ -244,6 +530,11 @@ func CleanLogTask(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			if !IsLeader() {
+				continue
+			}
+
+			// the lease may overlap while the leader changes
 			if !trylock.Lock("runCleanLog", time.Second*3) {
 				continue
 			}
@@ -263,6 +554,146 @@ func CleanLogTask(ctx context.Context) {
 	}
 }
 
+// PurgeArchivedTask purges the channels and the tokens archived longer than
+// the archive purge hours
+func PurgeArchivedTask(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !IsLeader() {
+				continue
+			}
+
+			if err := model.PurgeArchived(0); err != nil {
+				notify.ErrorThrottle(
+					"purgeArchivedError",
+					time.Minute*5,
+					"purge archived channels and tokens failed",
+					err.Error(),
+				)
+			}
+		}
+	}
+}
+
+// ConfigSyncTask polls CONFIG_SYNC_URL and reconciles the desired state when
+// it changed
+func ConfigSyncTask(ctx context.Context, frequency time.Duration) {
+	ticker := time.NewTicker(frequency)
+	defer ticker.Stop()
+
+	var lastHash string
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !IsLeader() {
+				// the new leader applies the state again
+				lastHash = ""
+				continue
+			}
+
+			hash, err := controller.PollConfigSync(ctx, lastHash)
+			if err != nil {
+				notify.ErrorThrottle(
+					"configSyncError",
+					time.Minute*5,
+					"config sync failed",
+					err.Error(),
+				)
+
+				continue
+			}
+
+			lastHash = hash
+		}
+	}
+}
+
+// SummaryRollupTask rolls the summaries up into the daily and monthly tables
+// and prunes the summaries older than the retentions
+func SummaryRollupTask(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !IsLeader() {
+				continue
+			}
+
+			if err := model.RollupSummaries(); err != nil {
+				notify.ErrorThrottle(
+					"summaryRollupError",
+					time.Minute*5,
+					"rollup summaries failed",
+					err.Error(),
+				)
+			}
+		}
+	}
+}
+
+// InvoiceTask stores the invoices of the groups for the last month once it is
+// finalized, so they are kept unchanged by the later price changes
+func InvoiceTask(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !IsLeader() {
+				continue
+			}
+
+			if err := storeLastMonthInvoices(); err != nil {
+				notify.ErrorThrottle(
+					"invoiceError",
+					time.Minute*5,
+					"store invoices failed",
+					err.Error(),
+				)
+			}
+		}
+	}
+}
+
+func storeLastMonthInvoices() error {
+	month := billing.MonthStart(time.Now()).AddDate(0, -1, 0)
+	if !billing.IsMonthFinalized(month, time.Now()) {
+		return nil
+	}
+
+	groups, err := model.GetSummaryGroups(month, month.AddDate(0, 1, 0))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, group := range groups {
+		// the stored invoices are returned as is
+		if _, err := billing.GetInvoice(group, month); err != nil {
+			errs = append(errs, fmt.Errorf("group %s: %w", group, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 const (
 	asyncUsagePollInterval    = time.Second * 3
 	asyncUsageProcessingLease = time.Minute * 3